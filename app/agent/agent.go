@@ -0,0 +1,294 @@
+// Package agent renders config files from templates reading stash keys, like consul-template, and keeps them
+// up to date with the change feed. A template is rendered again when a key it read changes, and only a changed
+// file is written, after which the template command runs and the watched process is signaled.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// resubscribeDelay is the pause before subscribing again after the change feed failed.
+const resubscribeDelay = 5 * time.Second
+
+// Client is the stash server templates read keys from, implemented by stash.Client.
+type Client interface {
+	List(ctx context.Context, prefix string) ([]stash.KeyInfo, error)
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+	SubscribeAll(ctx context.Context) (*stash.Subscription, error)
+}
+
+// Template is a template file rendered to the destination file.
+type Template struct {
+	Source  string // template file
+	Dest    string // rendered file, written only when changed
+	Command string // shell command run after the file changed, optional
+}
+
+// ParseTemplate parses a template spec, source:dest or source:dest:command.
+func ParseTemplate(spec string) (Template, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Template{}, fmt.Errorf("invalid template %q, expected source:dest[:command]", spec)
+	}
+	t := Template{Source: parts[0], Dest: parts[1]}
+	if len(parts) == 3 {
+		t.Command = parts[2]
+	}
+	return t, nil
+}
+
+// Config defines the rendered templates and reactions to changes.
+type Config struct {
+	Templates []Template
+	Wait      time.Duration // quiet period after a change before rendering, batches bursts of changes
+	PIDFile   string        // file with the pid of the process signaled after files changed, optional
+	Signal    os.Signal     // signal sent to the process of PIDFile
+}
+
+// Agent renders templates and watches keys they read.
+type Agent struct {
+	client Client
+	cfg    Config
+	tmpls  []*tmpl
+}
+
+// tmpl is a parsed template with keys and prefixes read by its last render.
+type tmpl struct {
+	Template
+	t    *template.Template
+	deps deps
+}
+
+// New makes an agent, parsing the templates.
+func New(client Client, cfg Config) (*Agent, error) {
+	if len(cfg.Templates) == 0 {
+		return nil, errors.New("no templates")
+	}
+	if cfg.PIDFile != "" && cfg.Signal == nil {
+		return nil, errors.New("no signal for the pid file")
+	}
+	a := &Agent{client: client, cfg: cfg}
+	for _, t := range cfg.Templates {
+		data, err := os.ReadFile(t.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		parsed, err := template.New(filepath.Base(t.Source)).Funcs(funcs(context.Background(), client, deps{})).
+			Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", t.Source, err)
+		}
+		a.tmpls = append(a.tmpls, &tmpl{Template: t, t: parsed})
+	}
+	return a, nil
+}
+
+// Render renders all templates once and returns the number of changed files. All templates are rendered
+// even if some fail, the error reports the failed ones.
+func (a *Agent) Render(ctx context.Context) (int, error) {
+	return a.render(ctx, a.tmpls)
+}
+
+// Run renders all templates and then renders them again on changes of keys they read, until the context is
+// canceled. A failed render keeps the previous file and is retried with the next change. The subscription
+// is renewed if it fails, and all templates are rendered then as changes could be missed.
+func (a *Agent) Run(ctx context.Context) error {
+	for {
+		err := a.follow(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("[WARN] change feed failed: %v", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// follow renders all templates and then those affected by events of a subscription, until it fails or
+// the context is canceled.
+func (a *Agent) follow(ctx context.Context) error {
+	sub, err := a.client.SubscribeAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Close()
+
+	// subscribed before the render to narrow the window of missed changes, the subscription connects
+	// in background and changes made before that are not sent
+	if _, err := a.render(ctx, a.tmpls); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+
+	pending := map[*tmpl]bool{}
+	wait := time.NewTimer(a.cfg.Wait)
+	wait.Stop()
+	defer wait.Stop()
+	subErrors := sub.Errors()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return errors.New("subscription closed")
+			}
+			for _, t := range a.tmpls {
+				if ev.Action == "reset" || t.deps.match(ev.Key) {
+					pending[t] = true
+				}
+			}
+			if len(pending) > 0 {
+				wait.Reset(a.cfg.Wait)
+			}
+		case <-wait.C:
+			var changed []*tmpl
+			for _, t := range a.tmpls { // in the configured order
+				if pending[t] {
+					changed = append(changed, t)
+				}
+			}
+			clear(pending)
+			if _, err := a.render(ctx, changed); err != nil {
+				log.Printf("[WARN] %v", err)
+			}
+		case err, ok := <-subErrors:
+			if !ok {
+				subErrors = nil // closed with the events channel, reported there
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// render renders the templates, runs commands of changed files and signals the process if any changed.
+func (a *Agent) render(ctx context.Context, tmpls []*tmpl) (int, error) {
+	var errs []error
+	changes := 0
+	for _, t := range tmpls {
+		changed, err := a.renderOne(ctx, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		changes++
+		log.Printf("[INFO] rendered %s", t.Dest)
+		if t.Command != "" {
+			if err := runCommand(ctx, t.Command); err != nil {
+				errs = append(errs, fmt.Errorf("command of %s failed: %w", t.Dest, err))
+			}
+		}
+	}
+	if changes > 0 && a.cfg.PIDFile != "" {
+		if err := a.signal(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return changes, errors.Join(errs...)
+}
+
+// renderOne renders the template and writes the file if its content changed. Keys read by the template
+// are recorded even if it fails, so a missing key being created renders it again.
+func (a *Agent) renderOne(ctx context.Context, t *tmpl) (bool, error) {
+	t.deps = deps{keys: map[string]bool{}, prefixes: map[string]bool{}}
+	var buf bytes.Buffer
+	if err := t.t.Funcs(funcs(ctx, a.client, t.deps)).Execute(&buf, nil); err != nil {
+		return false, fmt.Errorf("failed to render %s: %w", t.Source, err)
+	}
+	current, err := os.ReadFile(t.Dest)
+	if err == nil && bytes.Equal(current, buf.Bytes()) {
+		return false, nil
+	}
+	if err := writeFile(t.Dest, buf.Bytes()); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", t.Dest, err)
+	}
+	return true, nil
+}
+
+// signal sends the configured signal to the process of the pid file.
+func (a *Agent) signal() error {
+	data, err := os.ReadFile(a.cfg.PIDFile)
+	if err != nil {
+		return fmt.Errorf("failed to read pid file: %w", err)
+	}
+	var pid int
+	if _, err := fmt.Sscan(string(data), &pid); err != nil || pid <= 0 {
+		return fmt.Errorf("invalid pid in %s", a.cfg.PIDFile)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find process %d: %w", pid, err)
+	}
+	if err := proc.Signal(a.cfg.Signal); err != nil {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	log.Printf("[INFO] sent %v to process %d", a.cfg.Signal, pid)
+	return nil
+}
+
+// writeFile replaces the file atomically, keeping the mode of an existing file, 0600 for a new one
+// as rendered files often hold secrets.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to make directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
+
+// runCommand runs the shell command, logging its output.
+func runCommand(ctx context.Context, command string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) //nolint:gosec // command is set by the operator
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command) //nolint:gosec // command is set by the operator
+	}
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		log.Printf("[INFO] %s: %s", command, strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run %q: %w", command, err)
+	}
+	return nil
+}
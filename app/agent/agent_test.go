@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate("app.tpl:/etc/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, Template{Source: "app.tpl", Dest: "/etc/app.conf"}, tmpl)
+	tmpl, err = ParseTemplate("app.tpl:/etc/app.conf:nginx -s reload && echo ok")
+	require.NoError(t, err)
+	assert.Equal(t, "nginx -s reload && echo ok", tmpl.Command)
+	_, err = ParseTemplate("app.tpl")
+	require.ErrorContains(t, err, "expected source:dest[:command]")
+	_, err = ParseTemplate(":/etc/app.conf")
+	require.Error(t, err)
+}
+
+func TestAgent_Render(t *testing.T) {
+	client := fakeClient{"app/db": `{"host":"db","port":5432}`, "app/name": "svc", "app/env/a": "1", "app/env/b": "2",
+		"app/env/nested/c": "3"}
+	dir := t.TempDir()
+	t.Setenv("AGENT_TEST_ENV", "prod")
+	src := testFile(t, dir, "app.tpl", `name={{ key "/app/name" }}
+host={{ (parseJSON (key "app/db")).host }}
+level={{ keyOrDefault "app/level" "info" }} cache={{ keyExists "app/cache" }} env={{ env "AGENT_TEST_ENV" }}
+{{ range ls "app/env" }}{{ .Key }}={{ .Value }};{{ end }}
+{{ range tree "app/env/" }}{{ .Key }};{{ end }}
+{{ toJSON (parseJSON (key "app/db")).port }}`)
+	dest := filepath.Join(dir, "out", "app.conf")
+	marker := filepath.Join(dir, "marker")
+	cmd := "echo done >> " + marker
+	if runtime.GOOS == "windows" {
+		cmd = "echo done>>" + marker
+	}
+
+	a, err := New(client, Config{Templates: []Template{{Source: src, Dest: dest, Command: cmd}}})
+	require.NoError(t, err)
+	changes, err := a.Render(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, changes)
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "name=svc\nhost=db\nlevel=info cache=false env=prod\na=1;b=2;\na;b;nested/c;\n5432", string(data))
+	fi, err := os.Stat(dest)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+	assert.FileExists(t, marker, "command run")
+
+	deps := a.tmpls[0].deps
+	assert.True(t, deps.match("app/name"))
+	assert.True(t, deps.match("app/level"), "missing keys are watched too")
+	assert.True(t, deps.match("app/env/new"))
+	assert.False(t, deps.match("app/other"))
+
+	t.Run("unchanged file is not written", func(t *testing.T) {
+		require.NoError(t, os.Remove(marker))
+		changes, err := a.Render(t.Context())
+		require.NoError(t, err)
+		assert.Zero(t, changes)
+		assert.NoFileExists(t, marker)
+	})
+
+	t.Run("failed render keeps the file", func(t *testing.T) {
+		delete(client, "app/name")
+		changes, err := a.Render(t.Context())
+		require.ErrorContains(t, err, "key /app/name not found")
+		assert.Zero(t, changes)
+		after, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, data, after)
+		assert.True(t, a.tmpls[0].deps.match("app/name"))
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		client["app/name"] = "!fail"
+		_, err := a.Render(t.Context())
+		require.ErrorContains(t, err, "failed to get app/name: server unavailable")
+	})
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	_, err := New(fakeClient{}, Config{})
+	require.ErrorContains(t, err, "no templates")
+	_, err = New(fakeClient{}, Config{Templates: []Template{{Source: filepath.Join(dir, "missing"), Dest: "x"}}})
+	require.ErrorContains(t, err, "failed to read template")
+	src := testFile(t, dir, "bad.tpl", "{{ key ")
+	_, err = New(fakeClient{}, Config{Templates: []Template{{Source: src, Dest: "x"}}})
+	require.ErrorContains(t, err, "failed to parse template")
+	src = testFile(t, dir, "ok.tpl", "ok")
+	_, err = New(fakeClient{}, Config{Templates: []Template{{Source: src, Dest: "x"}}, PIDFile: "app.pid"})
+	require.ErrorContains(t, err, "no signal")
+}
+
+// fakeClient keeps keys in memory, a value starting with ! fails as a server error, subscriptions
+// are not supported.
+type fakeClient map[string]string
+
+func (c fakeClient) List(_ context.Context, prefix string) ([]stash.KeyInfo, error) {
+	var res []stash.KeyInfo
+	for k := range c {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, stash.KeyInfo{Key: k})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key > res[j].Key }) // reversed, sorted by the agent
+	return res, nil
+}
+
+func (c fakeClient) GetBytes(_ context.Context, key string) ([]byte, error) {
+	v, ok := c[key]
+	switch {
+	case !ok:
+		return nil, stash.ErrNotFound
+	case strings.HasPrefix(v, "!"):
+		return nil, errors.New("server unavailable")
+	}
+	return []byte(v), nil
+}
+
+func (c fakeClient) SubscribeAll(context.Context) (*stash.Subscription, error) {
+	panic("not supported")
+}
+
+func testFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, []byte(data), 0o600))
+	return p
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// deps are keys and prefixes read by a render.
+type deps struct {
+	keys     map[string]bool
+	prefixes map[string]bool // with the trailing slash
+}
+
+// match reports whether a change of the key affects the render.
+func (d deps) match(key string) bool {
+	if d.keys[key] {
+		return true
+	}
+	for p := range d.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pair is a key of ls and tree results, Key is relative to the listed prefix.
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// funcs returns template functions reading keys with the client and recording them in deps. Names and
+// arguments follow consul-template, so its templates reading keys work as is.
+func funcs(ctx context.Context, client Client, d deps) template.FuncMap {
+	get := func(key string) (string, bool, error) {
+		key = strings.Trim(key, "/")
+		if d.keys != nil {
+			d.keys[key] = true
+		}
+		v, err := client.GetBytes(ctx, key)
+		if errors.Is(err, stash.ErrNotFound) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get %s: %w", key, err)
+		}
+		return string(v), true, nil
+	}
+	list := func(prefix string, recursive bool) ([]Pair, error) {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefix += "/"
+		}
+		if d.prefixes != nil {
+			d.prefixes[prefix] = true
+		}
+		keys, err := client.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		res := []Pair{}
+		for _, k := range keys {
+			rel, ok := strings.CutPrefix(k.Key, prefix)
+			if !ok || rel == "" || (!recursive && strings.Contains(rel, "/")) {
+				continue
+			}
+			v, err := client.GetBytes(ctx, k.Key)
+			if errors.Is(err, stash.ErrNotFound) {
+				continue // deleted after the listing
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get %s: %w", k.Key, err)
+			}
+			res = append(res, Pair{Key: rel, Value: string(v)})
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+		return res, nil
+	}
+
+	return template.FuncMap{
+		// key returns the value of the key, failing the render if it doesn't exist
+		"key": func(key string) (string, error) {
+			v, ok, err := get(key)
+			if err == nil && !ok {
+				return "", fmt.Errorf("key %s not found", key)
+			}
+			return v, err
+		},
+		"keyOrDefault": func(key, def string) (string, error) {
+			v, ok, err := get(key)
+			if err == nil && !ok {
+				return def, nil
+			}
+			return v, err
+		},
+		"keyExists": func(key string) (bool, error) {
+			_, ok, err := get(key)
+			return ok, err
+		},
+		// ls returns keys directly under the prefix, tree returns all nested keys
+		"ls":   func(prefix string) ([]Pair, error) { return list(prefix, false) },
+		"tree": func(prefix string) ([]Pair, error) { return list(prefix, true) },
+		"env":  os.Getenv,
+		"parseJSON": func(s string) (any, error) {
+			var v any
+			if err := json.Unmarshal([]byte(s), &v); err != nil {
+				return nil, fmt.Errorf("failed to parse json: %w", err)
+			}
+			return v, nil
+		},
+		"toJSON": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal json: %w", err)
+			}
+			return string(data), nil
+		},
+	}
+}
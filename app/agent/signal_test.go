@@ -0,0 +1,53 @@
+//go:build !windows
+
+package agent
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignal(t *testing.T) {
+	sig, err := ParseSignal("hup")
+	require.NoError(t, err)
+	assert.Equal(t, syscall.SIGHUP, sig)
+	sig, err = ParseSignal("SIGUSR1")
+	require.NoError(t, err)
+	assert.Equal(t, syscall.SIGUSR1, sig)
+	_, err = ParseSignal("KILL")
+	require.ErrorContains(t, err, `unsupported signal "KILL"`)
+}
+
+func TestAgent_Signal(t *testing.T) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, syscall.SIGUSR2)
+	defer signal.Stop(received)
+
+	dir := t.TempDir()
+	pidFile := testFile(t, dir, "app.pid", strconv.Itoa(os.Getpid())+"\n")
+	src := testFile(t, dir, "app.tpl", `{{ key "app/name" }}`)
+	a, err := New(fakeClient{"app/name": "svc"}, Config{Templates: []Template{{Source: src, Dest: filepath.Join(dir, "app.conf")}},
+		PIDFile: pidFile, Signal: syscall.SIGUSR2})
+	require.NoError(t, err)
+	_, err = a.Render(t.Context())
+	require.NoError(t, err)
+	select {
+	case sig := <-received:
+		assert.Equal(t, syscall.SIGUSR2, sig)
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not received")
+	}
+
+	require.NoError(t, os.WriteFile(pidFile, []byte("bad"), 0o600))
+	require.NoError(t, os.Remove(filepath.Join(dir, "app.conf")))
+	_, err = a.Render(t.Context())
+	require.ErrorContains(t, err, "invalid pid")
+}
@@ -0,0 +1,11 @@
+package agent
+
+import (
+	"errors"
+	"os"
+)
+
+// ParseSignal always fails, processes can't be signaled on Windows, template commands can be used instead.
+func ParseSignal(string) (os.Signal, error) {
+	return nil, errors.New("signals are not supported on windows")
+}
@@ -0,0 +1,76 @@
+// Package bundle defines export bundles, JSON files with keys moved between stash instances, e.g. promoted
+// from staging to prod, and checks them against formats and a validation policy without a server.
+package bundle
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Version is the bundle format version written and accepted.
+const Version = 1
+
+// Bundle is a set of keys with values and formats.
+type Bundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at,omitzero"`
+	Keys       []Key     `json:"keys"`
+}
+
+// EncodingBase64 marks values that are not valid UTF-8, stored base64 encoded.
+const EncodingBase64 = "base64"
+
+// Key is a bundled key. Empty format means text. UpdatedAt is informational, it's not restored on import.
+type Key struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	Format    string    `json:"format,omitempty"`
+	Encoding  string    `json:"encoding,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// NewKey makes a bundled key of the value, encoding it as base64 if it's not valid UTF-8.
+func NewKey(key string, value []byte, format string) Key {
+	if utf8.Valid(value) {
+		return Key{Key: key, Value: string(value), Format: format}
+	}
+	return Key{Key: key, Value: base64.StdEncoding.EncodeToString(value), Format: format, Encoding: EncodingBase64}
+}
+
+// Data returns the decoded value of the key.
+func (k Key) Data() ([]byte, error) {
+	switch k.Encoding {
+	case "":
+		return []byte(k.Value), nil
+	case EncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(k.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", k.Encoding)
+	}
+}
+
+// Read decodes a bundle. Unknown fields are rejected, so a typo in a hand-edited bundle doesn't pass unnoticed.
+func Read(r io.Reader) (Bundle, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var b Bundle
+	if err := dec.Decode(&b); err != nil {
+		return Bundle{}, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if dec.More() {
+		return Bundle{}, errors.New("failed to decode bundle: data after the bundle object")
+	}
+	if b.Version != Version {
+		return Bundle{}, fmt.Errorf("unsupported bundle version %d, expected %d", b.Version, Version)
+	}
+	return b, nil
+}
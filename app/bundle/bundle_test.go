@@ -0,0 +1,44 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	b, err := Read(strings.NewReader(`{"version":1,"exported_at":"2025-01-15T10:00:00Z",
+		"keys":[{"key":"app/db","value":"{\"port\":5432}","format":"json"},{"key":"app/name","value":"svc"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Key{{Key: "app/db", Value: `{"port":5432}`, Format: "json"}, {Key: "app/name", Value: "svc"}}, b.Keys)
+	assert.Equal(t, 2025, b.ExportedAt.Year())
+
+	tbl := []struct {
+		name, data, err string
+	}{
+		{"bad version", `{"version":2,"keys":[]}`, "unsupported bundle version 2, expected 1"},
+		{"no version", `{"keys":[]}`, "unsupported bundle version 0, expected 1"},
+		{"unknown field", `{"version":1,"keys":[{"key":"a","value":"b","fromat":"json"}]}`, `unknown field "fromat"`},
+		{"trailing data", `{"version":1,"keys":[]} {}`, "data after the bundle object"},
+		{"not json", `keys: []`, "failed to decode bundle"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.data))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	k := NewKey("app/name", []byte("svc ✓"), "text")
+	assert.Equal(t, Key{Key: "app/name", Value: "svc ✓", Format: "text"}, k)
+
+	k = NewKey("app/blob", []byte{0xff, 0x00, 0x01}, "")
+	assert.Equal(t, Key{Key: "app/blob", Value: "/wAB", Encoding: EncodingBase64}, k)
+	data, err := k.Data()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00, 0x01}, data)
+}
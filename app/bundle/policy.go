@@ -0,0 +1,219 @@
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+
+	"github.com/umputun/stash/app/store"
+	"github.com/umputun/stash/app/validator"
+	"github.com/umputun/stash/lib/stash"
+)
+
+// Policy is a set of naming and content rules for bundled keys, loaded from a yaml file. Zero fields don't limit anything.
+type Policy struct {
+	KeyPattern   string `yaml:"key_pattern"`    // regexp every key must match
+	MaxDepth     int    `yaml:"max_depth"`      // max number of key path segments
+	MaxValueSize int    `yaml:"max_value_size"` // max value size in bytes
+	Rules        []Rule `yaml:"rules"`
+
+	keyRe *regexp.Regexp
+}
+
+// Rule applies to keys matching Key, an exact key or a prefix with * suffix. All matching rules apply.
+type Rule struct {
+	Key    string `yaml:"key"`
+	Format string `yaml:"format"` // required format of the value
+	Schema string `yaml:"schema"` // JSON schema file of json and yaml values, relative to the policy file
+
+	schema *jsonschema.Schema
+}
+
+// Problem is a failed check of a bundled key.
+type Problem struct {
+	Key     string
+	Message string
+}
+
+// String returns the problem as "key: message".
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// LoadPolicy reads the policy file and compiles its key pattern and schemas.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is set by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var p Policy
+	if err = yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if p.KeyPattern != "" {
+		if p.keyRe, err = regexp.Compile(p.KeyPattern); err != nil {
+			return nil, fmt.Errorf("invalid key_pattern: %w", err)
+		}
+	}
+
+	fv := validator.NewService()
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Key == "" {
+			return nil, fmt.Errorf("rule %d has no key", i+1)
+		}
+		if r.Format != "" && !fv.IsValidFormat(r.Format) {
+			return nil, fmt.Errorf("rule %q has unknown format %q", r.Key, r.Format)
+		}
+		if r.Schema == "" {
+			continue
+		}
+		schemaPath := r.Schema
+		if !filepath.IsAbs(schemaPath) {
+			schemaPath = filepath.Join(filepath.Dir(path), schemaPath)
+		}
+		if schemaPath, err = filepath.Abs(schemaPath); err != nil {
+			return nil, fmt.Errorf("rule %q schema path: %w", r.Key, err)
+		}
+		if r.schema, err = jsonschema.NewCompiler().Compile(schemaPath); err != nil {
+			return nil, fmt.Errorf("rule %q has invalid schema: %w", r.Key, err)
+		}
+	}
+	return &p, nil
+}
+
+// Validate checks keys of the bundle and returns the problems found, in bundle order. Keys must be normalized
+// and unique, and values must parse in their formats. The policy is optional, nil checks only the bundle itself.
+// Values of zero-knowledge encrypted keys can't be read, only their names are checked.
+func Validate(b Bundle, p *Policy) []Problem {
+	var res []Problem
+	fv := validator.NewService()
+	seen := make(map[string]bool, len(b.Keys))
+	for _, k := range b.Keys {
+		add := func(format string, args ...any) {
+			res = append(res, Problem{Key: k.Key, Message: fmt.Sprintf(format, args...)})
+		}
+		if k.Key == "" {
+			add("empty key")
+			continue
+		}
+		if norm := store.NormalizeKey(k.Key); norm != k.Key {
+			add("key is not normalized, would be stored as %q", norm)
+		}
+		if seen[k.Key] {
+			add("duplicate key")
+		}
+		seen[k.Key] = true
+
+		format := k.Format
+		if format == "" {
+			format = stash.FormatText.String()
+		}
+		if !fv.IsValidFormat(format) {
+			add("unknown format %q", format)
+			continue
+		}
+		value, err := k.Data()
+		if err != nil {
+			add("%v", err)
+			continue
+		}
+		zk := stash.IsZKEncrypted(value)
+		if !zk {
+			if err := fv.Validate(format, value); err != nil {
+				add("%v", err)
+			}
+		}
+		if p != nil {
+			for _, msg := range p.check(k.Key, string(value), format, zk) {
+				add("%s", msg)
+			}
+		}
+	}
+	return res
+}
+
+// check returns policy violations of the key.
+func (p *Policy) check(key, value, format string, zk bool) []string {
+	var res []string
+	if p.keyRe != nil && !p.keyRe.MatchString(key) {
+		res = append(res, fmt.Sprintf("key doesn't match %s", p.KeyPattern))
+	}
+	if depth := strings.Count(key, "/") + 1; p.MaxDepth > 0 && depth > p.MaxDepth {
+		res = append(res, fmt.Sprintf("key depth %d exceeds %d", depth, p.MaxDepth))
+	}
+	if p.MaxValueSize > 0 && len(value) > p.MaxValueSize {
+		res = append(res, fmt.Sprintf("value size %d exceeds %d", len(value), p.MaxValueSize))
+	}
+	for _, r := range p.Rules {
+		if !r.matches(key) {
+			continue
+		}
+		if r.Format != "" && r.Format != format {
+			res = append(res, fmt.Sprintf("format %s, %q requires %s", format, r.Key, r.Format))
+			continue
+		}
+		if r.schema == nil || zk {
+			continue
+		}
+		if msg := r.checkSchema(value, format); msg != "" {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+// matches reports whether the rule applies to the key.
+func (r Rule) matches(key string) bool {
+	if prefix, ok := strings.CutSuffix(r.Key, "*"); ok {
+		return strings.HasPrefix(key, prefix)
+	}
+	return key == r.Key
+}
+
+// checkSchema validates the value against the rule schema, returns an empty string if it conforms.
+// The value is already known to parse in its format.
+func (r Rule) checkSchema(value, format string) string {
+	var doc any
+	switch format {
+	case stash.FormatJSON.String():
+		_ = json.Unmarshal([]byte(value), &doc)
+	case stash.FormatYAML.String():
+		_ = yaml.Unmarshal([]byte(value), &doc)
+	default:
+		return fmt.Sprintf("schema of %q needs json or yaml format, got %s", r.Key, format)
+	}
+	err := r.schema.Validate(doc)
+	if err == nil {
+		return ""
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return fmt.Sprintf("doesn't match schema %s: %v", r.Schema, err)
+	}
+	return fmt.Sprintf("doesn't match schema %s: %s", r.Schema, strings.Join(schemaErrors(verr), "; "))
+}
+
+// schemaErrors returns the leaf errors of the schema validation as "/location: message", without the
+// schema locations of the library error text.
+func schemaErrors(e *jsonschema.ValidationError) []string {
+	if len(e.Causes) == 0 {
+		loc := e.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		return []string{loc + ": " + e.Message}
+	}
+	var res []string
+	for _, c := range e.Causes {
+		res = append(res, schemaErrors(c)...)
+	}
+	return res
+}
@@ -0,0 +1,119 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	b := Bundle{Version: Version, Keys: []Key{
+		{Key: "app/db", Value: `{"port":5432}`, Format: "json"},
+		{Key: "app/name", Value: "svc"},
+		{Key: "/app/name ", Value: "svc"},
+		{Key: "app/name", Value: "other"},
+		{Key: "app/bad", Value: `{"port":`, Format: "json"},
+		{Key: "app/weird", Value: "x", Format: "csv"},
+		{Key: "app/secrets/zk", Value: "$ZK$AAAA", Format: "json"},
+		{Key: "app/blob", Value: "/w==", Encoding: EncodingBase64},
+		{Key: "app/blob2", Value: "not base64!", Encoding: EncodingBase64},
+		{Key: "app/hex", Value: "ff", Encoding: "hex"},
+		{Key: "", Value: "x"},
+	}}
+	problems := Validate(b, nil)
+	msgs := make([]string, 0, len(problems))
+	for _, p := range problems {
+		msgs = append(msgs, p.String())
+	}
+	assert.Equal(t, []string{
+		`/app/name : key is not normalized, would be stored as "app/name"`,
+		"app/name: duplicate key",
+		"app/bad: invalid json: unexpected end of JSON input",
+		`app/weird: unknown format "csv"`,
+		"app/blob2: invalid base64 value: illegal base64 data at input byte 3",
+		`app/hex: unknown encoding "hex"`,
+		": empty key",
+	}, msgs)
+
+	assert.Empty(t, Validate(Bundle{Version: Version}, nil))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "schemas"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "schemas", "db.json"), []byte(`{
+		"type": "object",
+		"required": ["host", "port"],
+		"properties": {"host": {"type": "string"}, "port": {"type": "integer"}}
+	}`), 0o600))
+	write := func(t *testing.T, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, "policy.yml")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+
+	p, err := LoadPolicy(write(t, `
+key_pattern: '^[a-z0-9/_-]+$'
+max_depth: 3
+max_value_size: 21
+rules:
+  - key: "svc/db*"
+    schema: schemas/db.json
+`))
+	require.NoError(t, err)
+
+	b := Bundle{Version: Version, Keys: []Key{
+		{Key: "svc/db", Value: `{"host":"h","port":1}`, Format: "json"},
+		{Key: "svc/db-yaml", Value: "host: h\nport: x\n", Format: "yaml"},
+		{Key: "svc/db-text", Value: "host=h", Format: "text"},
+		{Key: "svc/dbzk", Value: "$ZK$AAAA", Format: "json"},
+		{Key: "App/Name", Value: "svc"},
+		{Key: "a/b/c/d", Value: "x"},
+		{Key: "app/big", Value: "0123456789012345678901"},
+	}}
+	msgs := []string{}
+	for _, pr := range Validate(b, p) {
+		msgs = append(msgs, pr.String())
+	}
+	assert.Equal(t, []string{
+		"svc/db-yaml: doesn't match schema schemas/db.json: /port: expected integer, but got string",
+		`svc/db-text: schema of "svc/db*" needs json or yaml format, got text`,
+		"App/Name: key doesn't match ^[a-z0-9/_-]+$",
+		"a/b/c/d: key depth 4 exceeds 3",
+		"app/big: value size 22 exceeds 21",
+	}, msgs)
+
+	t.Run("rule format", func(t *testing.T) {
+		p, err := LoadPolicy(write(t, "rules:\n  - key: app/db\n    format: json\n"))
+		require.NoError(t, err)
+		problems := Validate(Bundle{Keys: []Key{{Key: "app/db", Value: "x"}, {Key: "app/dbx", Value: "x"}}}, p)
+		assert.Equal(t, []Problem{{Key: "app/db", Message: `format text, "app/db" requires json`}}, problems)
+	})
+
+	t.Run("missing required property", func(t *testing.T) {
+		p, err := LoadPolicy(write(t, "rules:\n  - key: \"*\"\n    schema: schemas/db.json\n"))
+		require.NoError(t, err)
+		problems := Validate(Bundle{Keys: []Key{{Key: "db", Value: `{"host":"h"}`, Format: "json"}}}, p)
+		require.Len(t, problems, 1)
+		assert.Contains(t, problems[0].Message, "missing properties: 'port'")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := LoadPolicy(filepath.Join(dir, "missing.yml"))
+		require.ErrorContains(t, err, "failed to read policy")
+		_, err = LoadPolicy(write(t, "key_pattern: '['\n"))
+		require.ErrorContains(t, err, "invalid key_pattern")
+		_, err = LoadPolicy(write(t, "rules:\n  - format: json\n"))
+		require.EqualError(t, err, "rule 1 has no key")
+		_, err = LoadPolicy(write(t, "rules:\n  - key: a\n    format: csv\n"))
+		require.EqualError(t, err, `rule "a" has unknown format "csv"`)
+		_, err = LoadPolicy(write(t, "rules:\n  - key: a\n    schema: schemas/none.json\n"))
+		require.ErrorContains(t, err, `rule "a" has invalid schema`)
+		_, err = LoadPolicy(write(t, "rules: [\n"))
+		require.ErrorContains(t, err, "failed to parse policy")
+	})
+}
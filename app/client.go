@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/agent"
+	"github.com/umputun/stash/app/dirsync"
+	"github.com/umputun/stash/app/dockersecret"
+	"github.com/umputun/stash/app/mount"
+	"github.com/umputun/stash/app/scan"
+	"github.com/umputun/stash/lib/stash"
+)
+
+// isClientCommand reports whether the command line runs a client command, kv, fs or scan. Their output goes
+// to scripts and pipelines, so the version banner is skipped for them. Only the first non-flag argument
+// is checked, client commands use none of the global options taking a value.
+func isClientCommand(args []string) bool {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			return a == "kv" || a == "fs" || a == "scan"
+		}
+	}
+	return false
+}
+
+// newClient makes a client of the stash server set by the options.
+func newClient(co clientOptions) (*stash.Client, error) {
+	clientOpts := []stash.Option{stash.WithTimeout(co.Timeout)}
+	switch {
+	case co.Token != "" && co.TokenFile != "":
+		return nil, errors.New("--token and --token-file can't be set both")
+	case co.TokenFile != "":
+		clientOpts = append(clientOpts, stash.WithTokenFile(co.TokenFile)) // long-running commands see rotated tokens
+	default:
+		clientOpts = append(clientOpts, stash.WithToken(co.Token))
+	}
+	if co.ZKKey != "" {
+		clientOpts = append(clientOpts, stash.WithZKKey(co.ZKKey))
+	}
+	if co.Sign {
+		clientOpts = append(clientOpts, stash.WithRequestSigning())
+	}
+	client, err := stash.New(co.URL, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make stash client: %w", err)
+	}
+	return client, nil
+}
+
+// runKVGet writes values of the keys to w, as is for a single key and newline terminated for several.
+func runKVGet(ctx context.Context, w io.Writer) error {
+	client, err := newClient(opts.KVCmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	keys := opts.KVCmd.GetCmd.Args.Keys
+	for _, key := range keys {
+		value, err := client.GetBytes(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", key, err)
+		}
+		if len(keys) > 1 {
+			value = append(value, '\n')
+		}
+		if _, err := w.Write(value); err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// runKVSet stores the value of the key, read from in for "-".
+func runKVSet(ctx context.Context, in io.Reader) error {
+	format, err := stash.ParseFormat(opts.KVCmd.SetCmd.Format)
+	if err != nil {
+		return fmt.Errorf("invalid format: %w", err)
+	}
+	key, value := opts.KVCmd.SetCmd.Args.Key, opts.KVCmd.SetCmd.Args.Value
+	if value == "-" {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		value = string(data)
+	}
+
+	client, err := newClient(opts.KVCmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.SetWithFormat(ctx, key, value, format); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// runKVLogin exchanges the OIDC token of the GitHub Actions job for a stash token and writes it to w.
+func runKVLogin(ctx context.Context, w io.Writer, getenv func(string) string) error {
+	oidcToken, err := githubOIDCToken(ctx, getenv, opts.KVCmd.LoginCmd.GitHubOIDC)
+	if err != nil {
+		return err
+	}
+	client, err := stash.New(opts.KVCmd.URL, stash.WithTimeout(opts.KVCmd.Timeout))
+	if err != nil {
+		return fmt.Errorf("failed to make stash client: %w", err)
+	}
+	defer client.Close()
+	tok, err := client.GitHubLogin(ctx, oidcToken, opts.KVCmd.LoginCmd.TTL)
+	if err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	_, err = fmt.Fprintln(w, tok.Token)
+	return err
+}
+
+// githubOIDCToken requests the OIDC token of the running GitHub Actions job for the audience. The runner sets
+// the request URL and token only for jobs with "id-token: write" permission.
+func githubOIDCToken(ctx context.Context, getenv func(string) string, audience string) (string, error) {
+	reqURL, reqToken := getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if reqURL == "" || reqToken == "" {
+		return "", errors.New("no GitHub OIDC token request in environment, the job needs \"id-token: write\" permission")
+	}
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("audience", audience)
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.KVCmd.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to make OIDC token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC token request responded with status %d", resp.StatusCode)
+	}
+	var res struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&res); err != nil || res.Value == "" {
+		return "", errors.New("no OIDC token in response")
+	}
+	return res.Value, nil
+}
+
+// runFSSync syncs the directory with keys under the prefix in the selected mode and writes the changes to w.
+// Watch runs until the context is canceled.
+func runFSSync(ctx context.Context, w io.Writer) error {
+	cmd := opts.FSCmd.SyncCmd
+	if cmd.DryRun && cmd.Mode == "watch" {
+		return errors.New("--dry-run is not supported in watch mode")
+	}
+	client, err := newClient(opts.FSCmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	syncer := dirsync.New(client, dirsync.Config{Prefix: cmd.Prefix, Dir: cmd.Dir, Delete: cmd.Delete, DryRun: cmd.DryRun, Out: w})
+	var changes int
+	switch cmd.Mode {
+	case "watch":
+		log.Printf("[INFO] syncing %s with keys %q, press Ctrl+C to stop", cmd.Dir, cmd.Prefix)
+		return syncer.Watch(ctx)
+	case "push":
+		changes, err = syncer.Push(ctx)
+	default:
+		changes, err = syncer.Pull(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	dryRun := ""
+	if cmd.DryRun {
+		dryRun = " (dry run)"
+	}
+	fmt.Fprintf(w, "%s: %d changes%s\n", cmd.Mode, changes, dryRun)
+	return nil
+}
+
+// runMount mounts keys to the directory and serves them until the context is canceled or the directory
+// is unmounted externally.
+func runMount(ctx context.Context) error {
+	cmd := opts.MountCmd
+	client, err := newClient(cmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	fsys, err := mount.Mount(client, cmd.Args.Dir,
+		mount.Config{Prefix: cmd.Prefix, Writable: cmd.Writable, AllowOther: cmd.AllowOther, CacheTTL: cmd.CacheTTL})
+	if err != nil {
+		return err
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go fsys.Watch(watchCtx)
+
+	unmounted := make(chan struct{})
+	go func() {
+		fsys.Wait()
+		close(unmounted)
+	}()
+	log.Printf("[INFO] mounted keys %q to %s, writable: %v", cmd.Prefix, cmd.Args.Dir, cmd.Writable)
+	select {
+	case <-ctx.Done():
+		return fsys.Unmount()
+	case <-unmounted:
+		log.Printf("[INFO] %s unmounted", cmd.Args.Dir)
+		return nil
+	}
+}
+
+// runDockerSecrets serves the Docker secret driver API on the plugin socket until ctx is canceled.
+func runDockerSecrets(ctx context.Context) error {
+	cmd := opts.DockerSecretsCmd
+	client, err := newClient(cmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	log.Printf("[INFO] serving secrets of keys %q as docker secret driver on %s", cmd.Prefix, cmd.Socket)
+	d := dockersecret.New(client, dockersecret.Config{Prefix: cmd.Prefix, NoReuse: cmd.NoReuse})
+	return d.Serve(ctx, cmd.Socket)
+}
+
+// runAgent renders the templates and keeps the files updated until ctx is canceled, or once with --once.
+func runAgent(ctx context.Context) error {
+	cmd := opts.AgentCmd
+	cfg := agent.Config{Wait: cmd.Wait, PIDFile: cmd.PIDFile}
+	for _, spec := range cmd.Templates {
+		t, err := agent.ParseTemplate(spec)
+		if err != nil {
+			return err
+		}
+		cfg.Templates = append(cfg.Templates, t)
+	}
+	if cmd.PIDFile != "" {
+		sig, err := agent.ParseSignal(cmd.Signal)
+		if err != nil {
+			return err
+		}
+		cfg.Signal = sig
+	}
+	client, err := newClient(cmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	a, err := agent.New(client, cfg)
+	if err != nil {
+		return err
+	}
+	if cmd.Once {
+		changes, err := a.Render(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] rendered %d templates, %d files changed", len(cfg.Templates), changes)
+		return nil
+	}
+	log.Printf("[INFO] rendering %d templates, watching changes", len(cfg.Templates))
+	return a.Run(ctx)
+}
+
+// runScan finds key references in the scanned paths and writes keys referenced in code but missing in stash,
+// and server keys not referenced in code. Missing keys fail the command, unreferenced ones are only reported.
+// Keys the token can't list are reported as missing.
+func runScan(ctx context.Context, w io.Writer) error {
+	scanner, err := scan.New(opts.ScanCmd.Patterns)
+	if err != nil {
+		return err
+	}
+	paths := opts.ScanCmd.Args.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	refs, err := scanner.Scan(paths)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(opts.ScanCmd.clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	keys, err := client.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list keys: %w", err)
+	}
+	stored := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		stored[k.Key] = true
+	}
+
+	referenced := map[string][]string{} // key -> locations
+	var missing []string                // refs are sorted by key, so are missing keys
+	for _, r := range refs {
+		if _, seen := referenced[r.Key]; !seen && !stored[r.Key] {
+			missing = append(missing, r.Key)
+		}
+		referenced[r.Key] = append(referenced[r.Key], r.String())
+	}
+	var unreferenced []string
+	for _, k := range keys {
+		if referenced[k.Key] == nil && scanPrefixMatch(k.Key, opts.ScanCmd.Prefix) {
+			unreferenced = append(unreferenced, k.Key)
+		}
+	}
+	slices.Sort(unreferenced)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(missing) > 0 {
+		fmt.Fprintf(tw, "missing in stash (%d):\n", len(missing))
+		for _, k := range missing {
+			fmt.Fprintf(tw, "  %s\t%s\n", k, strings.Join(referenced[k], ", "))
+		}
+	}
+	if len(unreferenced) > 0 {
+		fmt.Fprintf(tw, "not referenced in code (%d):\n", len(unreferenced))
+		for _, k := range unreferenced {
+			fmt.Fprintf(tw, "  %s\n", k)
+		}
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "%d references to %d keys, %d keys in stash\n", len(refs), len(referenced), len(keys))
+	if len(missing) > 0 {
+		return fmt.Errorf("%d referenced keys missing in stash", len(missing))
+	}
+	return nil
+}
+
+// scanPrefixMatch reports whether the key is under one of the prefixes, any key matches no prefixes.
+func scanPrefixMatch(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsClientCommand(t *testing.T) {
+	assert.True(t, isClientCommand([]string{"kv", "get", "a"}))
+	assert.True(t, isClientCommand([]string{"--dbg", "kv", "set", "a", "-"}))
+	assert.True(t, isClientCommand([]string{"scan", "./..."}))
+	assert.True(t, isClientCommand([]string{"fs", "sync", "--dir", "conf"}))
+	assert.False(t, isClientCommand([]string{"server", "kv"}))
+	assert.False(t, isClientCommand([]string{"--version"}))
+	assert.False(t, isClientCommand(nil))
+}
+
+func TestRunKV(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]string{"app/host": "db1", "app/port": "5432"}
+	formats := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/kv/")
+		switch r.Method {
+		case http.MethodGet:
+			v, ok := keys[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(v))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			keys[key], formats[key] = string(body), r.Header.Get("X-Stash-Format")
+		}
+	}))
+	defer srv.Close()
+
+	opts.KVCmd.URL, opts.KVCmd.Token, opts.KVCmd.Timeout = srv.URL, "tok", time.Second
+	t.Cleanup(func() { opts.KVCmd.URL, opts.KVCmd.Token = "", "" })
+
+	t.Run("get", func(t *testing.T) {
+		var out bytes.Buffer
+		opts.KVCmd.GetCmd.Args.Keys = []string{"app/host"}
+		require.NoError(t, runKVGet(t.Context(), &out))
+		assert.Equal(t, "db1", out.String(), "single value as is")
+
+		out.Reset()
+		opts.KVCmd.GetCmd.Args.Keys = []string{"app/host", "app/port"}
+		require.NoError(t, runKVGet(t.Context(), &out))
+		assert.Equal(t, "db1\n5432\n", out.String())
+
+		opts.KVCmd.GetCmd.Args.Keys = []string{"app/missing"}
+		require.EqualError(t, runKVGet(t.Context(), &out), "failed to get app/missing: key not found")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		opts.KVCmd.SetCmd.Format = "text"
+		opts.KVCmd.SetCmd.Args.Key, opts.KVCmd.SetCmd.Args.Value = "deploy/version", "v1.2.3"
+		require.NoError(t, runKVSet(t.Context(), strings.NewReader("")))
+		assert.Equal(t, "v1.2.3", keys["deploy/version"])
+
+		opts.KVCmd.SetCmd.Format = "json"
+		opts.KVCmd.SetCmd.Args.Key, opts.KVCmd.SetCmd.Args.Value = "deploy/info", "-"
+		require.NoError(t, runKVSet(t.Context(), strings.NewReader(`{"sha":"abc"}`)))
+		assert.JSONEq(t, `{"sha":"abc"}`, keys["deploy/info"])
+		assert.Equal(t, "json", formats["deploy/info"])
+
+		opts.KVCmd.SetCmd.Format = "bad"
+		require.ErrorContains(t, runKVSet(t.Context(), strings.NewReader("")), "invalid format")
+	})
+
+	t.Run("token file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("tok\n"), 0o600))
+		opts.KVCmd.Token, opts.KVCmd.TokenFile = "", path
+		defer func() { opts.KVCmd.Token, opts.KVCmd.TokenFile = "tok", "" }()
+		var out bytes.Buffer
+		opts.KVCmd.GetCmd.Args.Keys = []string{"app/host"}
+		require.NoError(t, runKVGet(t.Context(), &out))
+		assert.Equal(t, "db1", out.String())
+
+		opts.KVCmd.Token = "tok"
+		require.ErrorContains(t, runKVGet(t.Context(), &out), "--token and --token-file can't be set both")
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		opts.KVCmd.Token = "other"
+		defer func() { opts.KVCmd.Token = "tok" }()
+		opts.KVCmd.GetCmd.Args.Keys = []string{"app/host"}
+		require.EqualError(t, runKVGet(t.Context(), io.Discard), "failed to get app/host: unauthorized")
+	})
+}
+
+func TestRunKVLogin(t *testing.T) {
+	actions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer req-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "1", r.URL.Query().Get("api-version"), "request url query kept")
+		_, _ = w.Write([]byte(`{"count":1,"value":"oidc-for-` + r.URL.Query().Get("audience") + `"}`))
+	}))
+	defer actions.Close()
+
+	var login map[string]any
+	stashSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/auth/cloud", r.URL.Path)
+		login = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&login))
+		_, _ = w.Write([]byte(`{"token":"minted","expires_at":"2025-01-15T10:15:00Z"}`))
+	}))
+	defer stashSrv.Close()
+
+	opts.KVCmd.URL, opts.KVCmd.Timeout = stashSrv.URL, time.Second
+	opts.KVCmd.LoginCmd.GitHubOIDC, opts.KVCmd.LoginCmd.TTL = "stash", 10*time.Minute
+	t.Cleanup(func() { opts.KVCmd.URL, opts.KVCmd.LoginCmd.GitHubOIDC, opts.KVCmd.LoginCmd.TTL = "", "", 0 })
+
+	env := map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": actions.URL + "/token?api-version=1",
+		"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "req-token"}
+	getenv := func(k string) string { return env[k] }
+
+	var out bytes.Buffer
+	require.NoError(t, runKVLogin(t.Context(), &out, getenv))
+	assert.Equal(t, "minted\n", out.String())
+	assert.Equal(t, map[string]any{"provider": "github", "ttl": "10m0s", "github": map[string]any{"token": "oidc-for-stash"}}, login)
+
+	env["ACTIONS_ID_TOKEN_REQUEST_TOKEN"] = "wrong"
+	require.EqualError(t, runKVLogin(t.Context(), &out, getenv), "OIDC token request responded with status 403")
+
+	require.ErrorContains(t, runKVLogin(t.Context(), &out, func(string) string { return "" }), "id-token: write")
+}
+
+func TestRunScan(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kv/", r.URL.Path)
+		_, _ = w.Write([]byte(`[{"key":"app/db/host"},{"key":"app/legacy"},{"key":"other/x"}]`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"),
+		[]byte("a := get(\"app/db/host\")\nb := get(\"app/db/port\")\nc := get(\"app/db/port\")\n"), 0o600))
+
+	opts.ScanCmd.URL, opts.ScanCmd.Timeout = srv.URL, time.Second
+	opts.ScanCmd.Patterns, opts.ScanCmd.Args.Paths = []string{`^app/.+$`}, []string{dir}
+	t.Cleanup(func() { opts.ScanCmd.URL, opts.ScanCmd.Patterns, opts.ScanCmd.Prefix = "", nil, nil })
+
+	var out bytes.Buffer
+	require.EqualError(t, runScan(t.Context(), &out), "1 referenced keys missing in stash")
+	file := filepath.Join(dir, "main.go")
+	assert.Equal(t, "missing in stash (1):\n  app/db/port  "+file+":2, "+file+":3\n"+
+		"not referenced in code (2):\n  app/legacy\n  other/x\n3 references to 2 keys, 3 keys in stash\n", out.String())
+
+	out.Reset()
+	opts.ScanCmd.Prefix = []string{"app/"}
+	opts.ScanCmd.Patterns = []string{`^app/db/host$`}
+	require.NoError(t, runScan(t.Context(), &out))
+	assert.Equal(t, "not referenced in code (1):\n  app/legacy\n1 references to 1 keys, 3 keys in stash\n", out.String())
+}
+
+func TestRunFSSync(t *testing.T) {
+	opts.DevCmd.Address, opts.DevCmd.Args.Dir = "127.0.0.1:18509", t.TempDir()
+	t.Cleanup(func() { opts.DevCmd.Args.Dir = "" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	devErr := make(chan error, 1)
+	go func() { devErr <- runDev(ctx) }()
+	waitForServer(t, "http://127.0.0.1:18509/ping")
+
+	src, dst := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "db.json"), []byte(`{"port":5432}`), 0o600))
+	opts.FSCmd.URL, opts.FSCmd.Timeout = "http://127.0.0.1:18509", 5*time.Second
+	opts.FSCmd.SyncCmd.Prefix = "app/"
+	t.Cleanup(func() { opts.FSCmd.URL, opts.FSCmd.SyncCmd.Prefix, opts.FSCmd.SyncCmd.Dir = "", "", "" })
+
+	var out bytes.Buffer
+	opts.FSCmd.SyncCmd.Dir, opts.FSCmd.SyncCmd.Mode = src, "push"
+	require.NoError(t, runFSSync(ctx, &out))
+	assert.Equal(t, "push db.json -> app/db\npush: 1 changes\n", out.String())
+
+	out.Reset()
+	opts.FSCmd.SyncCmd.Dir, opts.FSCmd.SyncCmd.Mode = dst, "pull"
+	require.NoError(t, runFSSync(ctx, &out))
+	assert.Equal(t, "pull app/db -> db.json\npull: 1 changes\n", out.String())
+	data, err := os.ReadFile(filepath.Join(dst, "db.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":5432}`, string(data))
+
+	opts.FSCmd.SyncCmd.Mode, opts.FSCmd.SyncCmd.DryRun = "watch", true
+	require.EqualError(t, runFSSync(ctx, io.Discard), "--dry-run is not supported in watch mode")
+	opts.FSCmd.SyncCmd.DryRun = false
+
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- runFSSync(watchCtx, io.Discard) }()
+	client, err := newClient(opts.FSCmd.clientOptions)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// server change is pulled, also shows the watch is started. the key is set again until then, as
+	// changes made before the subscription is connected are not sent
+	assert.Eventually(t, func() bool {
+		if data, err := os.ReadFile(filepath.Join(dst, "name.txt")); err == nil && string(data) == "svc" {
+			return true
+		}
+		require.NoError(t, client.Set(ctx, "app/name", "svc"))
+		return false
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// local edit is pushed
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "db.json"), []byte(`{"port":5433}`), 0o600))
+	assert.Eventually(t, func() bool {
+		v, err := client.Get(ctx, "app/db")
+		return err == nil && v == `{"port":5433}`
+	}, 5*time.Second, 50*time.Millisecond)
+
+	watchCancel()
+	require.NoError(t, <-watchErr)
+	cancel()
+	require.NoError(t, <-devErr)
+}
+
+func TestRunMount(t *testing.T) {
+	devDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(devDir, "app"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(devDir, "app", "name.txt"), []byte("svc"), 0o600))
+	opts.DevCmd.Address, opts.DevCmd.Args.Dir = "127.0.0.1:18510", devDir
+	t.Cleanup(func() { opts.DevCmd.Args.Dir = "" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	devErr := make(chan error, 1)
+	go func() { devErr <- runDev(ctx) }()
+	waitForServer(t, "http://127.0.0.1:18510/ping")
+
+	mnt := t.TempDir()
+	opts.MountCmd.URL, opts.MountCmd.Timeout = "http://127.0.0.1:18510", 5*time.Second
+	opts.MountCmd.Prefix, opts.MountCmd.CacheTTL, opts.MountCmd.Args.Dir = "app/", time.Minute, mnt
+	t.Cleanup(func() { opts.MountCmd.URL, opts.MountCmd.Prefix, opts.MountCmd.Args.Dir = "", "", "" })
+	mountCtx, mountCancel := context.WithCancel(ctx)
+	mountErr := make(chan error, 1)
+	go func() { mountErr <- runMount(mountCtx) }()
+
+	var data []byte
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		select {
+		case err := <-mountErr:
+			t.Skipf("fuse is not available: %v", err)
+		default:
+		}
+		var err error
+		if data, err = os.ReadFile(filepath.Join(mnt, "name")); err == nil {
+			break
+		}
+		require.Less(t, time.Since(start), 5*time.Second, "not mounted")
+	}
+	assert.Equal(t, "svc", string(data))
+
+	// a change on the server reaches the cached file through the change feed
+	client, err := newClient(opts.MountCmd.clientOptions)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Eventually(t, func() bool {
+		require.NoError(t, client.Set(ctx, "app/name", "svc2")) // again until the feed is connected
+		data, err := os.ReadFile(filepath.Join(mnt, "name"))
+		return err == nil && string(data) == "svc2"
+	}, 5*time.Second, 100*time.Millisecond)
+
+	mountCancel()
+	require.NoError(t, <-mountErr)
+	cancel()
+	require.NoError(t, <-devErr)
+}
+
+func TestRunDockerSecrets(t *testing.T) {
+	devDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(devDir, "swarm"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(devDir, "swarm", "db.txt"), []byte("pass"), 0o600))
+	opts.DevCmd.Address, opts.DevCmd.Args.Dir = "127.0.0.1:18511", devDir
+	t.Cleanup(func() { opts.DevCmd.Args.Dir = "" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	devErr := make(chan error, 1)
+	go func() { devErr <- runDev(ctx) }()
+	waitForServer(t, "http://127.0.0.1:18511/ping")
+
+	socket := filepath.Join(t.TempDir(), "stash.sock")
+	opts.DockerSecretsCmd.URL, opts.DockerSecretsCmd.Timeout = "http://127.0.0.1:18511", 5*time.Second
+	opts.DockerSecretsCmd.Prefix, opts.DockerSecretsCmd.Socket = "swarm", socket
+	t.Cleanup(func() {
+		opts.DockerSecretsCmd.URL, opts.DockerSecretsCmd.Prefix, opts.DockerSecretsCmd.Socket = "", "", ""
+	})
+	driverCtx, driverCancel := context.WithCancel(ctx)
+	driverErr := make(chan error, 1)
+	go func() { driverErr <- runDockerSecrets(driverCtx) }()
+
+	// docker daemon calls the plugin over the unix socket
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		var err error
+		resp, err = httpClient.Post("http://plugin/SecretProvider.GetSecret", "application/json",
+			strings.NewReader(`{"SecretName":"db_password","SecretLabels":{"stash.key":"db"}}`))
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"Value":"cGFzcw=="}`, string(body)) // base64 of "pass"
+
+	driverCancel()
+	require.NoError(t, <-driverErr)
+	cancel()
+	require.NoError(t, <-devErr)
+}
+
+func TestRunAgent(t *testing.T) {
+	devDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(devDir, "app"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(devDir, "app", "port.txt"), []byte("8080"), 0o600))
+	opts.DevCmd.Address, opts.DevCmd.Args.Dir = "127.0.0.1:18512", devDir
+	t.Cleanup(func() { opts.DevCmd.Args.Dir = "" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	devErr := make(chan error, 1)
+	go func() { devErr <- runDev(ctx) }()
+	waitForServer(t, "http://127.0.0.1:18512/ping")
+
+	dir := t.TempDir()
+	tpl, dest := filepath.Join(dir, "app.tpl"), filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(tpl, []byte(`listen {{ key "app/port" }}`), 0o600))
+	opts.AgentCmd.URL, opts.AgentCmd.Timeout = "http://127.0.0.1:18512", 5*time.Second
+	opts.AgentCmd.Templates, opts.AgentCmd.Wait = []string{tpl + ":" + dest}, 10*time.Millisecond
+	t.Cleanup(func() { opts.AgentCmd.URL, opts.AgentCmd.Templates, opts.AgentCmd.Once = "", nil, false })
+
+	opts.AgentCmd.Once = true
+	require.NoError(t, runAgent(ctx))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "listen 8080", string(data))
+
+	opts.AgentCmd.Once = false
+	agentCtx, agentCancel := context.WithCancel(ctx)
+	agentErr := make(chan error, 1)
+	go func() { agentErr <- runAgent(agentCtx) }()
+
+	// a change on the server renders the file again
+	client, err := newClient(opts.AgentCmd.clientOptions)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Eventually(t, func() bool {
+		require.NoError(t, client.Set(ctx, "app/port", "9090")) // again until the feed is connected
+		data, err := os.ReadFile(dest)
+		return err == nil && string(data) == "listen 9090"
+	}, 5*time.Second, 100*time.Millisecond)
+
+	agentCancel()
+	require.NoError(t, <-agentErr)
+	cancel()
+	require.NoError(t, <-devErr)
+}
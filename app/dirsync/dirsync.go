@@ -0,0 +1,526 @@
+// Package dirsync mirrors keys under a prefix to files of a local directory and back, so config can be
+// edited in an IDE and synced with the server. Files map to keys the same way as for stash dev, with the
+// prefix added: db.json in the directory is the json key app/db for prefix app/.
+package dirsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/store"
+	"github.com/umputun/stash/lib/stash"
+)
+
+// debounce delays the scan of a changed directory, editors often save a file in several steps.
+const debounce = 300 * time.Millisecond
+
+// Client is the stash server the directory is synced with, implemented by stash.Client.
+type Client interface {
+	List(ctx context.Context, prefix string) ([]stash.KeyInfo, error)
+	Info(ctx context.Context, key string) (stash.KeyInfo, error)
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+	SetWithFormat(ctx context.Context, key, value string, format stash.Format) error
+	Delete(ctx context.Context, key string) error
+	SubscribePrefix(ctx context.Context, prefix string) (*stash.Subscription, error)
+	SubscribeAll(ctx context.Context) (*stash.Subscription, error)
+}
+
+// Config defines the synced prefix and directory.
+type Config struct {
+	Prefix string    // key prefix mapped to the directory, all keys if empty
+	Dir    string    // local directory, created by pull if missing
+	Delete bool      // delete keys and files missing on the other side
+	DryRun bool      // report changes without making them
+	Out    io.Writer // report of changes, a line per key
+}
+
+// Syncer syncs a directory with keys under a prefix.
+type Syncer struct {
+	client Client
+	cfg    Config
+	synced map[string]entry // relative key -> content on both sides after the last sync, used by Watch
+}
+
+// file is a key file in the directory.
+type file struct {
+	rel    string // slash separated path relative to the directory
+	format string // format of the extension, empty for files without a format extension
+}
+
+// entry is a synced key content.
+type entry struct {
+	value  string
+	format string
+}
+
+// New makes a syncer of the directory with the server.
+func New(client Client, cfg Config) *Syncer {
+	if cfg.Prefix = strings.Trim(cfg.Prefix, "/"); cfg.Prefix != "" {
+		cfg.Prefix += "/"
+	}
+	if cfg.Out == nil {
+		cfg.Out = io.Discard
+	}
+	return &Syncer{client: client, cfg: cfg, synced: map[string]entry{}}
+}
+
+// Pull writes server keys to their files and returns the number of changes. Files of existing keys keep their
+// names unless the key format doesn't match the file extension, new keys get a file with the format extension.
+// Zero-knowledge encrypted keys which can't be decrypted are skipped.
+func (s *Syncer) Pull(ctx context.Context) (int, error) {
+	keys, err := s.serverKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	files, err := s.localFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	changes := 0
+	for _, rel := range sortedKeys(keys) {
+		changed, err := s.pullKey(ctx, rel, keys[rel].Format, files)
+		if err != nil {
+			return changes, err
+		}
+		if changed {
+			changes++
+		}
+	}
+	if !s.cfg.Delete {
+		return changes, nil
+	}
+	for _, rel := range sortedKeys(files) {
+		if _, ok := keys[rel]; ok {
+			continue
+		}
+		if err := s.removeFile(rel, files[rel]); err != nil {
+			return changes, err
+		}
+		changes++
+	}
+	return changes, nil
+}
+
+// Push stores files as server keys and returns the number of changes. Files without a format extension keep
+// the format of an existing key and are text otherwise. Keys with unchanged values and formats are not updated.
+func (s *Syncer) Push(ctx context.Context) (int, error) {
+	keys, err := s.serverKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	files, err := s.localFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	changes := 0
+	for _, rel := range sortedKeys(files) {
+		f := files[rel]
+		value, err := os.ReadFile(filepath.Join(s.cfg.Dir, filepath.FromSlash(f.rel)))
+		if err != nil {
+			return changes, fmt.Errorf("failed to read %s: %w", f.rel, err)
+		}
+		format := f.format
+		info, exists := keys[rel]
+		if format == "" {
+			format = stash.FormatText.String()
+			if exists {
+				format = info.Format
+			}
+		}
+		if exists && info.Format == format {
+			current, err := s.client.GetBytes(ctx, s.cfg.Prefix+rel)
+			if err != nil {
+				return changes, fmt.Errorf("failed to get %s: %w", s.cfg.Prefix+rel, err)
+			}
+			if bytes.Equal(current, value) {
+				s.synced[rel] = entry{value: string(value), format: format}
+				continue
+			}
+		}
+		if err := s.pushKey(ctx, rel, f, string(value), format); err != nil {
+			return changes, err
+		}
+		changes++
+	}
+	if !s.cfg.Delete {
+		return changes, nil
+	}
+	for _, rel := range sortedKeys(keys) {
+		if _, ok := files[rel]; ok {
+			continue
+		}
+		if err := s.deleteKey(ctx, rel); err != nil {
+			return changes, err
+		}
+		changes++
+	}
+	return changes, nil
+}
+
+// Watch pulls the keys, then keeps the directory and the server in sync until the context is canceled. Changed
+// files are pushed and changed keys are pulled, deletions are synced only with Config.Delete. Files without
+// keys left after the first pull are pushed, while local changes to existing keys made before Watch are replaced
+// by server values, push them first. Failures of a single change are logged and don't stop watching.
+func (s *Syncer) Watch(ctx context.Context) error {
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return fmt.Errorf("failed to make %s: %w", s.cfg.Dir, err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", s.cfg.Dir, err)
+	}
+	defer watcher.Close()
+	if err = s.watchDirs(watcher, s.cfg.Dir); err != nil {
+		return err
+	}
+
+	var sub *stash.Subscription
+	if s.cfg.Prefix == "" {
+		sub, err = s.client.SubscribeAll(ctx)
+	} else {
+		sub, err = s.client.SubscribePrefix(ctx, strings.TrimSuffix(s.cfg.Prefix, "/"))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to changes: %w", err)
+	}
+	defer sub.Close()
+
+	// subscribed before the pull to narrow the window of changes missed by both, the subscription connects
+	// in background and changes made before that are not sent
+	if _, err = s.Pull(ctx); err != nil {
+		return err
+	}
+	s.syncLocal(ctx)
+
+	scan := time.NewTimer(debounce)
+	scan.Stop()
+	defer scan.Stop()
+	subErrors := sub.Errors()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("directory watcher closed")
+			}
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() && !skipName(fi.Name()) {
+					if err := s.watchDirs(watcher, ev.Name); err != nil {
+						log.Printf("[WARN] %v", err)
+					}
+				}
+			}
+			scan.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("directory watcher closed")
+			}
+			log.Printf("[WARN] directory watcher error: %v", err)
+		case <-scan.C:
+			s.syncLocal(ctx)
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("subscription closed")
+			}
+			s.syncEvent(ctx, ev)
+		case err, ok := <-subErrors:
+			if !ok {
+				subErrors = nil // closed with the events channel, reported there
+				continue
+			}
+			if ctx.Err() == nil {
+				return fmt.Errorf("subscription failed: %w", err)
+			}
+		}
+	}
+}
+
+// syncLocal pushes files changed since the last sync and, with Config.Delete, deletes keys of removed files.
+// Files are compared to the synced content rather than the server, a key changed on the server and not
+// pulled yet is not overwritten by the old file.
+func (s *Syncer) syncLocal(ctx context.Context) {
+	files, err := s.localFiles()
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		return
+	}
+	for _, rel := range sortedKeys(files) {
+		f := files[rel]
+		value, err := os.ReadFile(filepath.Join(s.cfg.Dir, filepath.FromSlash(f.rel)))
+		if err != nil {
+			log.Printf("[WARN] failed to read %s: %v", f.rel, err)
+			continue
+		}
+		prev, known := s.synced[rel]
+		format := f.format
+		if format == "" {
+			format = stash.FormatText.String()
+			if known {
+				format = prev.format
+			}
+		}
+		if known && prev.value == string(value) && prev.format == format {
+			continue
+		}
+		if err := s.pushKey(ctx, rel, f, string(value), format); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+	if !s.cfg.Delete {
+		return
+	}
+	for _, rel := range sortedKeys(s.synced) {
+		if _, ok := files[rel]; ok {
+			continue
+		}
+		if err := s.deleteKey(ctx, rel); err != nil && !errors.Is(err, stash.ErrNotFound) {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+}
+
+// syncEvent applies a server change to the directory. A reset means events were lost, all keys are pulled.
+func (s *Syncer) syncEvent(ctx context.Context, ev stash.Event) {
+	if ev.Action == "reset" {
+		if _, err := s.Pull(ctx); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+		return
+	}
+	rel, ok := strings.CutPrefix(ev.Key, s.cfg.Prefix)
+	if !ok || rel == "" {
+		return
+	}
+	files, err := s.localFiles()
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		return
+	}
+
+	if ev.Action == "delete" {
+		if !s.cfg.Delete {
+			return // the synced entry stays, so the kept file isn't pushed back until changed
+		}
+		f, exists := files[rel]
+		if !exists {
+			delete(s.synced, rel)
+			return
+		}
+		if err := s.removeFile(rel, f); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+		return
+	}
+	info, err := s.client.Info(ctx, ev.Key)
+	if errors.Is(err, stash.ErrNotFound) {
+		return // deleted after the event
+	}
+	if err != nil {
+		log.Printf("[WARN] failed to get %s: %v", ev.Key, err)
+		return
+	}
+	if _, err := s.pullKey(ctx, rel, info.Format, files); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+}
+
+// pullKey writes the key value to its file, returns false if the file is up to date.
+func (s *Syncer) pullKey(ctx context.Context, rel, format string, files map[string]file) (bool, error) {
+	key := s.cfg.Prefix + rel
+	value, err := s.client.GetBytes(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	if stash.IsZKEncrypted(value) {
+		fmt.Fprintf(s.cfg.Out, "skip %s, zero-knowledge encrypted\n", key)
+		return false, nil
+	}
+
+	f, exists := files[rel]
+	target := f.rel
+	if !exists || (f.format != "" && f.format != format) {
+		target = store.KeyFile(rel, format)
+	}
+	if prev, ok := s.synced[rel]; ok && exists && target == f.rel && prev == (entry{value: string(value), format: format}) {
+		return false, nil // not changed since the last sync, a differing file is a local change to push
+	}
+	if !filepath.IsLocal(filepath.FromSlash(target)) {
+		return false, fmt.Errorf("key %s is outside of %s", key, s.cfg.Dir)
+	}
+	full := filepath.Join(s.cfg.Dir, filepath.FromSlash(target))
+	if exists && target == f.rel {
+		if current, err := os.ReadFile(full); err == nil && bytes.Equal(current, value) { //nolint:gosec // file of the synced directory
+			s.synced[rel] = entry{value: string(value), format: format}
+			return false, nil
+		}
+	}
+
+	fmt.Fprintf(s.cfg.Out, "pull %s -> %s\n", key, target)
+	if s.cfg.DryRun {
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+		return false, fmt.Errorf("failed to make directory of %s: %w", target, err)
+	}
+	if err := os.WriteFile(full, value, 0o600); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if exists && target != f.rel {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, filepath.FromSlash(f.rel))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove %s: %w", f.rel, err)
+		}
+	}
+	s.synced[rel] = entry{value: string(value), format: format}
+	return true, nil
+}
+
+// pushKey stores the file value as the key.
+func (s *Syncer) pushKey(ctx context.Context, rel string, f file, value, format string) error {
+	key := s.cfg.Prefix + rel
+	fmt.Fprintf(s.cfg.Out, "push %s -> %s\n", f.rel, key)
+	if s.cfg.DryRun {
+		return nil
+	}
+	fmtVal, err := stash.ParseFormat(format)
+	if err != nil {
+		return fmt.Errorf("invalid format of %s: %w", key, err)
+	}
+	if err := s.client.SetWithFormat(ctx, key, value, fmtVal); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	s.synced[rel] = entry{value: value, format: format}
+	return nil
+}
+
+// deleteKey deletes the server key of a removed file.
+func (s *Syncer) deleteKey(ctx context.Context, rel string) error {
+	key := s.cfg.Prefix + rel
+	fmt.Fprintf(s.cfg.Out, "delete %s\n", key)
+	if s.cfg.DryRun {
+		return nil
+	}
+	delete(s.synced, rel)
+	if err := s.client.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// removeFile removes the file of a deleted key.
+func (s *Syncer) removeFile(rel string, f file) error {
+	fmt.Fprintf(s.cfg.Out, "remove %s\n", f.rel)
+	if s.cfg.DryRun {
+		return nil
+	}
+	delete(s.synced, rel)
+	if err := os.Remove(filepath.Join(s.cfg.Dir, filepath.FromSlash(f.rel))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", f.rel, err)
+	}
+	return nil
+}
+
+// serverKeys returns server keys under the prefix by key relative to the prefix.
+func (s *Syncer) serverKeys(ctx context.Context) (map[string]stash.KeyInfo, error) {
+	list, err := s.client.List(ctx, s.cfg.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	res := make(map[string]stash.KeyInfo, len(list))
+	for _, k := range list {
+		if rel, ok := strings.CutPrefix(k.Key, s.cfg.Prefix); ok && rel != "" {
+			res[rel] = k
+		}
+	}
+	return res, nil
+}
+
+// localFiles returns key files of the directory by key relative to the prefix, none if the directory doesn't
+// exist. Hidden files and editor backups ending with ~ are skipped, two files of the same key are an error.
+func (s *Syncer) localFiles() (map[string]file, error) {
+	res := map[string]file{}
+	err := filepath.WalkDir(s.cfg.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == s.cfg.Dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if p != s.cfg.Dir && skipName(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.cfg.Dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		key, format := store.FileKey(rel)
+		if prev, ok := res[key]; ok {
+			return fmt.Errorf("%s and %s are both key %q", prev.rel, rel, s.cfg.Prefix+key)
+		}
+		res[key] = file{rel: rel, format: format}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.cfg.Dir, err)
+	}
+	return res, nil
+}
+
+// watchDirs adds the directory and its subdirectories to the watcher, fsnotify doesn't watch recursively.
+func (s *Syncer) watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != dir && skipName(d.Name()) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(p)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	return nil
+}
+
+// skipName reports whether a file or directory is not synced, hidden ones and editor backups.
+func skipName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~")
+}
+
+// sortedKeys returns keys of the map in order, so changes are made and reported in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
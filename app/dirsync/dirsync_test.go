@@ -0,0 +1,223 @@
+package dirsync
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+func TestSyncer_Pull(t *testing.T) {
+	client := newFakeClient(map[string]stash.KeyInfo{
+		"app/db":           {Format: "json"},
+		"app/name":         {Format: "text"},
+		"app/conf/run":     {Format: "shell"},
+		"app/zk":           {Format: "text"},
+		"app/notes":        {Format: "yaml"},
+		"other/not-in-app": {Format: "text"},
+	}, map[string]string{
+		"app/db": `{"port":5432}`, "app/name": "svc", "app/conf/run": "echo hi", "app/zk": "$ZK$abc",
+		"app/notes": "a: 1", "other/not-in-app": "x",
+	})
+
+	dir := t.TempDir()
+	writeFile(t, dir, "name.txt", "svc")          // up to date
+	writeFile(t, dir, "notes.json", "{}")         // format changed, moves to notes.yaml
+	writeFile(t, dir, "README", "old")            // no key, kept without delete
+	writeFile(t, dir, ".hidden/x.txt", "ignored") // hidden, not synced
+
+	var out bytes.Buffer
+	s := New(client, Config{Prefix: "/app", Dir: dir, Out: &out})
+	changes, err := s.Pull(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 3, changes)
+	assert.Equal(t, "pull app/conf/run -> conf/run.sh\npull app/db -> db.json\npull app/notes -> notes.yaml\n"+
+		"skip app/zk, zero-knowledge encrypted\n", out.String())
+	assert.Equal(t, []string{"README", "conf/run.sh", "db.json", "name.txt", "notes.yaml"}, listFiles(t, dir))
+	assert.Equal(t, `{"port":5432}`, readFile(t, dir, "db.json"))
+	assert.Equal(t, "a: 1", readFile(t, dir, "notes.yaml"))
+
+	t.Run("delete", func(t *testing.T) {
+		out.Reset()
+		s := New(client, Config{Prefix: "app/", Dir: dir, Delete: true, Out: &out})
+		changes, err := s.Pull(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 1, changes)
+		assert.Equal(t, "skip app/zk, zero-knowledge encrypted\nremove README\n", out.String())
+		assert.NotContains(t, listFiles(t, dir), "README")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		out.Reset()
+		dryDir := filepath.Join(t.TempDir(), "new")
+		s := New(client, Config{Prefix: "app/", Dir: dryDir, DryRun: true, Out: &out})
+		changes, err := s.Pull(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 4, changes)
+		assert.Contains(t, out.String(), "pull app/name -> name.txt\n")
+		assert.NoDirExists(t, dryDir)
+	})
+}
+
+func TestSyncer_Push(t *testing.T) {
+	client := newFakeClient(map[string]stash.KeyInfo{
+		"app/db":   {Format: "json"},
+		"app/name": {Format: "text"},
+		"app/cert": {Format: "yaml"},
+		"app/old":  {Format: "text"},
+	}, map[string]string{"app/db": `{"port":5432}`, "app/name": "svc", "app/cert": "a: 1", "app/old": "x"})
+
+	dir := t.TempDir()
+	writeFile(t, dir, "db.json", `{"port":5433}`) // changed
+	writeFile(t, dir, "name.txt", "svc")          // unchanged
+	writeFile(t, dir, "cert", "a: 2")             // no format extension, keeps yaml
+	writeFile(t, dir, "new/key.toml", "a = 1")    // new key
+	writeFile(t, dir, "backup.txt~", "skipped")   // editor backup
+
+	var out bytes.Buffer
+	s := New(client, Config{Prefix: "app", Dir: dir, Out: &out})
+	changes, err := s.Push(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 3, changes)
+	assert.Equal(t, "push cert -> app/cert\npush db.json -> app/db\npush new/key.toml -> app/new/key\n", out.String())
+	assert.Equal(t, `{"port":5433}`, client.values["app/db"])
+	assert.Equal(t, "a: 2", client.values["app/cert"])
+	assert.Equal(t, "yaml", client.keys["app/cert"].Format)
+	assert.Equal(t, "toml", client.keys["app/new/key"].Format)
+	assert.Equal(t, []string{"app/cert", "app/db", "app/new/key"}, client.sets)
+
+	t.Run("delete", func(t *testing.T) {
+		out.Reset()
+		s := New(client, Config{Prefix: "app", Dir: dir, Delete: true, DryRun: true, Out: &out})
+		changes, err := s.Push(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 1, changes)
+		assert.Equal(t, "delete app/old\n", out.String())
+		assert.Contains(t, client.keys, "app/old", "dry run")
+
+		s = New(client, Config{Prefix: "app", Dir: dir, Delete: true})
+		_, err = s.Push(t.Context())
+		require.NoError(t, err)
+		assert.NotContains(t, client.keys, "app/old")
+	})
+
+	t.Run("duplicate key", func(t *testing.T) {
+		writeFile(t, dir, "db.yaml", "port: 1")
+		_, err := New(client, Config{Prefix: "app", Dir: dir}).Push(t.Context())
+		require.ErrorContains(t, err, `db.json and db.yaml are both key "app/db"`)
+	})
+}
+
+func TestSkipName(t *testing.T) {
+	assert.True(t, skipName(".git"))
+	assert.True(t, skipName("db.json~"))
+	assert.False(t, skipName("db.json"))
+}
+
+// fakeClient keeps keys in memory, subscriptions are not supported.
+type fakeClient struct {
+	keys   map[string]stash.KeyInfo
+	values map[string]string
+	sets   []string // keys set, in order
+}
+
+func newFakeClient(keys map[string]stash.KeyInfo, values map[string]string) *fakeClient {
+	for k, info := range keys {
+		info.Key = k
+		keys[k] = info
+	}
+	return &fakeClient{keys: keys, values: values}
+}
+
+func (c *fakeClient) List(_ context.Context, prefix string) ([]stash.KeyInfo, error) {
+	var res []stash.KeyInfo
+	for k, info := range c.keys {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, info)
+		}
+	}
+	return res, nil
+}
+
+func (c *fakeClient) Info(_ context.Context, key string) (stash.KeyInfo, error) {
+	info, ok := c.keys[key]
+	if !ok {
+		return stash.KeyInfo{}, stash.ErrNotFound
+	}
+	return info, nil
+}
+
+func (c *fakeClient) GetBytes(_ context.Context, key string) ([]byte, error) {
+	v, ok := c.values[key]
+	if !ok {
+		return nil, stash.ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+func (c *fakeClient) SetWithFormat(_ context.Context, key, value string, format stash.Format) error {
+	c.keys[key] = stash.KeyInfo{Key: key, Format: format.String()}
+	c.values[key] = value
+	c.sets = append(c.sets, key)
+	return nil
+}
+
+func (c *fakeClient) Delete(_ context.Context, key string) error {
+	if _, ok := c.keys[key]; !ok {
+		return stash.ErrNotFound
+	}
+	delete(c.keys, key)
+	delete(c.values, key)
+	return nil
+}
+
+func (c *fakeClient) SubscribePrefix(context.Context, string) (*stash.Subscription, error) {
+	panic("not supported")
+}
+
+func (c *fakeClient) SubscribeAll(context.Context) (*stash.Subscription, error) {
+	panic("not supported")
+}
+
+func writeFile(t *testing.T, dir, rel, data string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+	require.NoError(t, os.WriteFile(p, []byte(data), 0o600))
+}
+
+func readFile(t *testing.T, dir, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// listFiles returns slash separated paths of visible files under dir, sorted.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var res []string
+	require.NoError(t, filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			res = append(res, filepath.ToSlash(rel))
+		}
+		return nil
+	}))
+	sort.Strings(res)
+	return res
+}
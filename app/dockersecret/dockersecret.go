@@ -0,0 +1,165 @@
+// Package dockersecret implements the Docker secret driver plugin protocol on top of stash, so Swarm services
+// get secrets created with --driver stash straight from the server. Docker asks the driver for the value of
+// a secret when a task using it is scheduled, the value is the key set by the stash.key label of the secret
+// or, without the label, the key named as the secret, both under the configured prefix.
+package dockersecret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// KeyLabel is the secret label with the key of the value, relative to the prefix.
+const KeyLabel = "stash.key"
+
+// contentType is the media type of plugin API requests and responses.
+const contentType = "application/vnd.docker.plugins.v1.1+json"
+
+// Client is the stash server secrets are read from, implemented by stash.Client.
+type Client interface {
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+}
+
+// Config defines the keys available as secrets.
+type Config struct {
+	Prefix  string // key prefix of secrets, all keys if empty
+	NoReuse bool   // ask Docker to get the value for every task instead of reusing it for the service
+}
+
+// Driver serves the plugin API of a secret driver, Plugin.Activate and SecretProvider.GetSecret.
+type Driver struct {
+	client Client
+	cfg    Config
+}
+
+// request is the SecretProvider.GetSecret request, only fields used by the driver.
+type request struct {
+	SecretName   string
+	SecretLabels map[string]string
+	ServiceName  string
+	TaskID       string
+}
+
+// response is the SecretProvider.GetSecret response, Value is base64 encoded by json.
+type response struct {
+	Value      []byte `json:",omitempty"`
+	Err        string `json:",omitempty"`
+	DoNotReuse bool   `json:",omitempty"`
+}
+
+// New makes a driver reading secrets with the client.
+func New(client Client, cfg Config) *Driver {
+	if cfg.Prefix = strings.Trim(cfg.Prefix, "/"); cfg.Prefix != "" {
+		cfg.Prefix += "/"
+	}
+	return &Driver{client: client, cfg: cfg}
+}
+
+// ServeHTTP handles plugin API calls, all of them are POST requests.
+func (d *Driver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/Plugin.Activate":
+		writeJSON(w, http.StatusOK, map[string][]string{"Implements": {"secretprovider"}})
+	case "/SecretProvider.GetSecret":
+		d.getSecret(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// getSecret responds with the value of the secret key. Errors are reported in the Err field with a non-200
+// status, Docker fails the task with the message.
+func (d *Driver) getSecret(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, response{Err: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	key, err := d.key(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, response{Err: err.Error()})
+		return
+	}
+	value, err := d.client.GetBytes(r.Context(), key)
+	if err != nil {
+		log.Printf("[WARN] secret %s of service %s, task %s: failed to get %s: %v", req.SecretName, req.ServiceName, req.TaskID, key, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, stash.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, response{Err: fmt.Sprintf("failed to get stash key %s: %v", key, err)})
+		return
+	}
+	log.Printf("[INFO] secret %s of service %s, task %s: key %s", req.SecretName, req.ServiceName, req.TaskID, key)
+	writeJSON(w, http.StatusOK, response{Value: value, DoNotReuse: d.cfg.NoReuse})
+}
+
+// key returns the stash key of the requested secret, from the label or the secret name.
+func (d *Driver) key(req request) (string, error) {
+	name := strings.Trim(req.SecretLabels[KeyLabel], "/")
+	if name == "" {
+		name = req.SecretName
+	}
+	if name == "" {
+		return "", errors.New("no secret name")
+	}
+	return d.cfg.Prefix + name, nil
+}
+
+// Serve listens on the unix socket and serves the plugin API until ctx is canceled. A socket left by a previous
+// run is replaced. Docker finds the driver by the socket name, /run/docker/plugins/stash.sock is driver stash.
+func (d *Driver) Serve(ctx context.Context, socket string) error {
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove old socket: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o750); err != nil {
+		return fmt.Errorf("failed to make socket directory: %w", err)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	// secret values are readable by anyone connecting, keep the socket to the owner, the docker daemon is root
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	srv := &http.Server{Handler: d, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[WARN] failed to shut down secret driver: %v", err)
+		}
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("secret driver failed: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[WARN] failed to write plugin response: %v", err)
+	}
+}
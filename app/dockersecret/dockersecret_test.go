@@ -0,0 +1,129 @@
+package dockersecret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+func TestDriver_ServeHTTP(t *testing.T) {
+	client := fakeClient{"swarm/db-password": "secret1", "swarm/app/api-key": "secret2", "swarm/broken": ""}
+	d := New(client, Config{Prefix: "/swarm/"})
+
+	t.Run("activate", func(t *testing.T) {
+		rec := post(t, d, "/Plugin.Activate", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"Implements":["secretprovider"]}`, rec.Body.String())
+	})
+
+	tbl := []struct {
+		name    string
+		req     string
+		status  int
+		value   string
+		errText string
+	}{
+		{name: "secret name", req: `{"SecretName":"db-password","ServiceName":"api"}`, status: http.StatusOK, value: "secret1"},
+		{name: "key label", req: `{"SecretName":"api_key","SecretLabels":{"stash.key":"/app/api-key"}}`,
+			status: http.StatusOK, value: "secret2"},
+		{name: "missing key", req: `{"SecretName":"nope"}`, status: http.StatusNotFound, errText: "failed to get stash key swarm/nope"},
+		{name: "server error", req: `{"SecretName":"broken"}`, status: http.StatusInternalServerError, errText: "unavailable"},
+		{name: "no name", req: `{}`, status: http.StatusBadRequest, errText: "no secret name"},
+		{name: "invalid json", req: `{`, status: http.StatusBadRequest, errText: "invalid request"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(t, d, "/SecretProvider.GetSecret", tt.req)
+			assert.Equal(t, tt.status, rec.Code)
+			var resp response
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.value, string(resp.Value))
+			assert.Contains(t, resp.Err, tt.errText)
+			assert.False(t, resp.DoNotReuse)
+		})
+	}
+
+	t.Run("no reuse", func(t *testing.T) {
+		rec := post(t, New(client, Config{Prefix: "swarm", NoReuse: true}), "/SecretProvider.GetSecret", `{"SecretName":"db-password"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"Value":"c2VjcmV0MQ==","DoNotReuse":true}`, rec.Body.String())
+	})
+
+	t.Run("unknown call", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, post(t, d, "/SecretProvider.Other", "{}").Code)
+		req := httptest.NewRequest(http.MethodGet, "/Plugin.Activate", http.NoBody)
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+func TestDriver_Serve(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "plugins", "stash.sock")
+	d := New(fakeClient{"db": "secret"}, Config{})
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- d.Serve(ctx, socket) }()
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		var err error
+		resp, err = httpClient.Post("http://plugin/SecretProvider.GetSecret", contentType, strings.NewReader(`{"SecretName":"db"}`))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var res response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.Equal(t, "secret", string(res.Value))
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("driver not stopped")
+	}
+	assert.NoFileExists(t, socket, "socket removed on stop")
+}
+
+func post(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// fakeClient returns values of the keys, an empty value fails as a server error.
+type fakeClient map[string]string
+
+func (c fakeClient) GetBytes(_ context.Context, key string) ([]byte, error) {
+	v, ok := c[key]
+	switch {
+	case !ok:
+		return nil, stash.ErrNotFound
+	case v == "":
+		return nil, errors.New("server unavailable")
+	}
+	return []byte(v), nil
+}
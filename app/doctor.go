@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server"
+	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/store"
+)
+
+// clock skew limits of the doctor, expiry of exchanged tokens, cloud logins and passkey ceremonies
+// is checked against the local clock
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+// doctorStatus is the outcome of a doctor check.
+type doctorStatus string
+
+const (
+	doctorOK   doctorStatus = "ok"
+	doctorSkip doctorStatus = "skip"
+	doctorWarn doctorStatus = "warn"
+	doctorFail doctorStatus = "FAIL"
+)
+
+// finding is the result of one doctor check, with the fix of a problem.
+type finding struct {
+	check  string
+	status doctorStatus
+	msg    string
+	fix    string // empty for ok and skipped checks
+}
+
+// runDoctor checks the server configuration and environment without starting the server and prints a finding
+// per check. Failed checks fail the command, warnings don't.
+func runDoctor(ctx context.Context, w io.Writer) error {
+	var findings []finding
+	add := func(f finding) {
+		findings = append(findings, f)
+		fmt.Fprintf(w, "%-5s %-9s %s\n", f.status, f.check, f.msg)
+		if f.fix != "" {
+			fmt.Fprintf(w, "      -> %s\n", f.fix)
+		}
+	}
+
+	storeOpts, secretsErr := secretsStoreOptions(nil)
+	st, dbFinding := doctorDB(ctx, storeOpts)
+	add(dbFinding)
+	if st != nil {
+		defer st.Close()
+	}
+	add(doctorSecrets(ctx, st, secretsErr))
+	add(doctorAuth(st))
+	add(doctorGit())
+	add(doctorClock(ctx, opts.DoctorCmd.TimeURL))
+	add(doctorListen(opts.Server.Address))
+
+	failed := 0
+	for _, f := range findings {
+		if f.status == doctorFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(findings))
+	}
+	return nil
+}
+
+// doctorDB opens the database and checks it accepts writes. The store is nil if it can't be opened.
+func doctorDB(ctx context.Context, storeOpts []store.Option) (*store.Store, finding) {
+	st, err := store.New(opts.DB, storeOpts...)
+	if err != nil && len(storeOpts) > 0 {
+		st, err = store.New(opts.DB) // secrets problems are reported by their own check
+	}
+	if err != nil {
+		return nil, finding{check: "database", status: doctorFail, msg: fmt.Sprintf("can't open %s: %v", opts.DB, err),
+			fix: "check --db, the directory of a sqlite file must exist and be writable, postgres must be reachable"}
+	}
+	if err := st.CheckWrite(ctx); err != nil {
+		return st, finding{check: "database", status: doctorFail, msg: fmt.Sprintf("%s is not writable: %v", opts.DB, err),
+			fix: "check file ownership and permissions, or that no other process holds a lock; postgres must not be a replica"}
+	}
+	return st, finding{check: "database", status: doctorOK, msg: fmt.Sprintf("%s is writable", opts.DB)}
+}
+
+// doctorSecrets checks the secrets key encrypts and decrypts, then decrypts the stored secrets with it,
+// which fails if they were written with another key.
+func doctorSecrets(ctx context.Context, st *store.Store, optsErr error) finding {
+	res := finding{check: "secrets"}
+	switch {
+	case opts.Secrets.Sealed:
+		res.status, res.msg = doctorSkip, "sealed mode, the key is known only after unseal"
+		return res
+	case optsErr != nil:
+		res.status, res.msg = doctorFail, optsErr.Error()
+		res.fix = "--secrets.key must be at least 16 characters, prefix keys prefix:id:key, and a wrapped key must unwrap with the kek"
+		return res
+	case opts.Secrets.Key == "" && opts.Secrets.WrappedKey == "":
+		res.status, res.msg = doctorSkip, "secrets are disabled, no --secrets.key"
+		return res
+	}
+
+	key, err := secretsKey()
+	if err == nil {
+		err = cryptoRoundTrip(key)
+	}
+	if err != nil {
+		res.status, res.msg, res.fix = doctorFail, err.Error(), "replace the secrets key, it can't encrypt values"
+		return res
+	}
+	if st == nil || !st.SecretsEnabled() {
+		res.status, res.msg = doctorWarn, "key works, stored secrets not checked as the database can't be opened"
+		return res
+	}
+
+	keys, err := st.List(ctx, enum.SecretsFilterSecretsOnly)
+	if err != nil {
+		res.status, res.msg, res.fix = doctorWarn, fmt.Sprintf("key works, can't list secrets: %v", err), "see the database check"
+		return res
+	}
+	checked, broken := 0, 0
+	for _, k := range keys {
+		if k.ZKEncrypted {
+			continue // encrypted by clients, the server key doesn't apply
+		}
+		checked++
+		if _, err := st.Get(ctx, k.Key); err != nil {
+			broken++
+		}
+	}
+	if broken > 0 {
+		res.status, res.msg = doctorFail, fmt.Sprintf("%d of %d secrets can't be decrypted", broken, checked)
+		res.fix = "the secrets were written with another key, start with the key used before or restore them from git"
+		return res
+	}
+	res.status, res.msg = doctorOK, fmt.Sprintf("key works, %d secrets decrypted", checked)
+	return res
+}
+
+// cryptoRoundTrip encrypts and decrypts a value with the key.
+func cryptoRoundTrip(key string) error {
+	enc, err := initSecretsEncryptor(key)
+	if err != nil {
+		return err
+	}
+	probe := []byte("stash doctor")
+	sealed, err := enc.Encrypt(probe)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+	plain, err := enc.Decrypt(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if string(plain) != string(probe) {
+		return errors.New("decrypted value differs from the encrypted one")
+	}
+	return nil
+}
+
+// doctorAuth loads the auth config with the schema validation of the server.
+func doctorAuth(st *store.Store) finding {
+	res := finding{check: "auth"}
+	if opts.Auth.File == "" {
+		res.status, res.msg = doctorWarn, "auth is disabled, anyone reaching the server can read and write all keys"
+		res.fix = "set --auth.file unless the server is reachable only by trusted clients"
+		return res
+	}
+	if st == nil {
+		res.status, res.msg = doctorSkip, "not checked as the database can't be opened"
+		return res
+	}
+	if _, err := auth.New(opts.Auth.File, opts.Auth.LoginTTL, false, st, server.VerifyAuthConfig); err != nil {
+		res.status, res.msg = doctorFail, err.Error()
+		res.fix = "check --auth.file exists and matches the config schema, the error names the problem"
+		return res
+	}
+	res.status, res.msg = doctorOK, opts.Auth.File+" is valid"
+	return res
+}
+
+// doctorGit checks the history repository. A missing repository is fine, the server creates it.
+func doctorGit() finding {
+	res := finding{check: "git"}
+	if !opts.Git.Enabled {
+		res.status, res.msg = doctorSkip, "git tracking is disabled"
+		return res
+	}
+	if _, err := os.Stat(opts.Git.Path); errors.Is(err, os.ErrNotExist) {
+		res.status, res.msg = doctorOK, fmt.Sprintf("%s doesn't exist yet, created on start", opts.Git.Path)
+		return res
+	}
+	if err := git.Verify(git.Config{Path: opts.Git.Path, Remote: opts.Git.Remote}); err != nil {
+		res.status, res.msg = doctorFail, fmt.Sprintf("%s: %v", opts.Git.Path, err)
+		res.fix = "commit or remove stray files in the repository, check --git.remote is configured in it, or restore it from the remote"
+		return res
+	}
+	if opts.Git.SSHKey != "" {
+		if _, err := os.Stat(opts.Git.SSHKey); err != nil {
+			res.status, res.msg = doctorFail, fmt.Sprintf("ssh key: %v", err)
+			res.fix = "check --git.ssh-key points to a readable private key"
+			return res
+		}
+	}
+	res.status, res.msg = doctorOK, opts.Git.Path+" is healthy"
+	return res
+}
+
+// doctorClock compares the local clock with the Date header of the time server.
+func doctorClock(ctx context.Context, timeURL string) finding {
+	res := finding{check: "clock"}
+	if timeURL == "" {
+		res.status, res.msg = doctorSkip, "no --time-url"
+		return res
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, timeURL, http.NoBody)
+	if err != nil {
+		res.status, res.msg = doctorWarn, fmt.Sprintf("invalid time url: %v", err)
+		return res
+	}
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		res.status, res.msg = doctorWarn, fmt.Sprintf("can't reach %s: %v", timeURL, err)
+		res.fix = "set --time-url to a reachable http server, or --time-url= to skip"
+		return res
+	}
+	_ = resp.Body.Close()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		res.status, res.msg = doctorWarn, fmt.Sprintf("%s sent no valid Date header", timeURL)
+		return res
+	}
+
+	// the Date header has a resolution of a second, compare with the middle of the request
+	local := sent.Add(time.Since(sent) / 2)
+	skew := local.Sub(remote).Round(time.Second)
+	switch {
+	case skew.Abs() >= clockSkewFail:
+		res.status = doctorFail
+	case skew.Abs() >= clockSkewWarn:
+		res.status = doctorWarn
+	default:
+		res.status, res.msg = doctorOK, fmt.Sprintf("skew %s", skew)
+		return res
+	}
+	res.msg = fmt.Sprintf("local clock is off by %s", skew)
+	res.fix = "sync the clock with ntp, token expiry and cloud logins depend on it"
+	return res
+}
+
+// doctorListen checks the server address can be listened on.
+func doctorListen(addr string) finding {
+	res := finding{check: "listen"}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		res.status, res.msg = doctorFail, fmt.Sprintf("can't listen on %s: %v", addr, err)
+		res.fix = "stop the process using the port (fine if it's this stash server), or change --server.address"
+		return res
+	}
+	_ = ln.Close()
+	res.status, res.msg = doctorOK, addr+" is available"
+	return res
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestRunDoctor(t *testing.T) {
+	dir := t.TempDir()
+	opts.DB = filepath.Join(dir, "test.db")
+	opts.Secrets.Key = "doctor-master-key-1"
+	opts.Auth.File = filepath.Join(dir, "auth.yml")
+	opts.Git.Enabled, opts.Git.Path = true, filepath.Join(dir, ".history")
+	opts.DoctorCmd.TimeURL = ""
+	opts.Server.Address = "127.0.0.1:0"
+	t.Cleanup(func() {
+		opts.Secrets.Key, opts.Auth.File, opts.Git.Enabled, opts.Server.Address = "", "", false, ":8080"
+	})
+	require.NoError(t, os.WriteFile(opts.Auth.File, []byte("users:\n  - name: admin\n    password: \"$2a$10$hash\"\n"), 0o600))
+
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	require.NoError(t, err)
+	_, err = kvStore.Set(t.Context(), "secrets/db/password", []byte("hunter2"), "text")
+	require.NoError(t, err)
+	require.NoError(t, kvStore.Close())
+
+	t.Run("healthy", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, runDoctor(t.Context(), &buf))
+		out := buf.String()
+		assert.Contains(t, out, "ok    database")
+		assert.Contains(t, out, "ok    secrets   key works, 1 secrets decrypted")
+		assert.Contains(t, out, "ok    auth")
+		assert.Contains(t, out, "created on start")
+		assert.Contains(t, out, "skip  clock")
+		assert.Contains(t, out, "ok    listen")
+		assert.NotContains(t, out, "->")
+	})
+
+	t.Run("wrong secrets key and invalid auth config", func(t *testing.T) {
+		opts.Secrets.Key = "another-master-key"
+		defer func() { opts.Secrets.Key = "doctor-master-key-1" }()
+		require.NoError(t, os.WriteFile(opts.Auth.File, []byte("users: [{bogus: 1}]\n"), 0o600))
+		defer os.WriteFile(opts.Auth.File, []byte("users: []\n"), 0o600) //nolint:errcheck // test cleanup
+
+		var buf bytes.Buffer
+		err := runDoctor(t.Context(), &buf)
+		require.EqualError(t, err, "2 of 6 checks failed")
+		out := buf.String()
+		assert.Contains(t, out, "FAIL  secrets   1 of 1 secrets can't be decrypted")
+		assert.Contains(t, out, "FAIL  auth")
+		assert.Contains(t, out, "-> the secrets were written with another key")
+	})
+}
+
+func TestDoctorDB(t *testing.T) {
+	opts.DB = filepath.Join(t.TempDir(), "missing", "test.db")
+	st, f := doctorDB(t.Context(), nil)
+	assert.Nil(t, st)
+	assert.Equal(t, doctorFail, f.status)
+	assert.NotEmpty(t, f.fix)
+}
+
+func TestDoctorGit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".history")
+	opts.Git.Enabled, opts.Git.Path = true, path
+	t.Cleanup(func() { opts.Git.Enabled = false })
+	_, err := git.New(git.Config{Path: path})
+	require.NoError(t, err)
+
+	assert.Equal(t, doctorOK, doctorGit().status)
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, "stray.val"), []byte("x"), 0o600))
+	f := doctorGit()
+	assert.Equal(t, doctorFail, f.status)
+	assert.Contains(t, f.msg, "uncommitted files")
+	assert.FileExists(t, filepath.Join(path, "stray.val"), "doctor leaves the repo as is")
+
+	opts.Git.Enabled = false
+	assert.Equal(t, doctorSkip, doctorGit().status)
+}
+
+func TestDoctorClock(t *testing.T) {
+	skewed := func(d time.Duration) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Date", time.Now().Add(d).UTC().Format(http.TimeFormat))
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+
+	tests := []struct {
+		name string
+		skew time.Duration
+		want doctorStatus
+	}{
+		{name: "in sync", skew: 0, want: doctorOK},
+		{name: "slightly off", skew: -2 * time.Minute, want: doctorWarn},
+		{name: "far off", skew: time.Hour, want: doctorFail},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := doctorClock(t.Context(), skewed(tc.skew))
+			assert.Equal(t, tc.want, f.status, f.msg)
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		f := doctorClock(t.Context(), "http://127.0.0.1:1")
+		assert.Equal(t, doctorWarn, f.status)
+		assert.Contains(t, f.fix, "--time-url")
+	})
+}
+
+func TestDoctorListen(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	f := doctorListen(ln.Addr().String())
+	assert.Equal(t, doctorFail, f.status)
+	assert.Contains(t, f.fix, "--server.address")
+	assert.Equal(t, doctorOK, doctorListen("127.0.0.1:0").status)
+}
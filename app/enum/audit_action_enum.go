@@ -31,10 +31,13 @@ func (e *AuditAction) UnmarshalText(text []byte) error {
 
 // _auditActionParseMap is used for efficient string to enum conversion
 var _auditActionParseMap = map[string]AuditAction{
-	"read":   AuditActionRead,
-	"create": AuditActionCreate,
-	"update": AuditActionUpdate,
-	"delete": AuditActionDelete,
+	"read":        AuditActionRead,
+	"create":      AuditActionCreate,
+	"update":      AuditActionUpdate,
+	"delete":      AuditActionDelete,
+	"canary":      AuditActionCanary,
+	"breakglass":  AuditActionBreakGlass,
+	"break_glass": AuditActionBreakGlass,
 }
 
 // ParseAuditAction converts string to auditAction enum value.
@@ -57,10 +60,12 @@ func MustAuditAction(v string) AuditAction {
 
 // Public constants for auditAction values
 var (
-	AuditActionRead   = AuditAction{name: "read", value: 0}
-	AuditActionCreate = AuditAction{name: "create", value: 1}
-	AuditActionUpdate = AuditAction{name: "update", value: 2}
-	AuditActionDelete = AuditAction{name: "delete", value: 3}
+	AuditActionRead       = AuditAction{name: "read", value: 0}
+	AuditActionCreate     = AuditAction{name: "create", value: 1}
+	AuditActionUpdate     = AuditAction{name: "update", value: 2}
+	AuditActionDelete     = AuditAction{name: "delete", value: 3}
+	AuditActionCanary     = AuditAction{name: "canary", value: 4}
+	AuditActionBreakGlass = AuditAction{name: "breakglass", value: 5}
 )
 
 // AuditActionValues contains all possible enum values
@@ -69,6 +74,8 @@ var AuditActionValues = []AuditAction{
 	AuditActionCreate,
 	AuditActionUpdate,
 	AuditActionDelete,
+	AuditActionCanary,
+	AuditActionBreakGlass,
 }
 
 // AuditActionNames contains all possible enum names
@@ -77,6 +84,8 @@ var AuditActionNames = []string{
 	"create",
 	"update",
 	"delete",
+	"canary",
+	"breakglass",
 }
 
 // AuditActionIter returns a function compatible with Go 1.23's range-over-func syntax.
@@ -108,5 +117,9 @@ var _ = func() bool {
 	var _ auditAction = auditActionUpdate
 	// This avoids "defined but not used" linter error for auditActionDelete
 	var _ auditAction = auditActionDelete
+	// This avoids "defined but not used" linter error for auditActionCanary
+	var _ auditAction = auditActionCanary
+	// This avoids "defined but not used" linter error for auditActionBreakGlass
+	var _ auditAction = auditActionBreakGlass
 	return true
 }()
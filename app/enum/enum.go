@@ -24,6 +24,7 @@ type viewMode int
 const (
 	viewModeGrid viewMode = iota
 	viewModeCards
+	viewModeTree
 )
 
 //go:generate go run github.com/go-pkgz/enum@latest -type sortMode -lower
@@ -62,6 +63,8 @@ const (
 	auditActionCreate
 	auditActionUpdate
 	auditActionDelete
+	auditActionCanary     // read of a key marked as canary
+	auditActionBreakGlass // enum:alias=break_glass
 )
 
 //go:generate go run github.com/go-pkgz/enum@latest -type auditResult -lower
@@ -81,3 +84,16 @@ const (
 	actorTypeToken
 	actorTypePublic
 )
+
+//go:generate go run github.com/go-pkgz/enum@latest -type scope -lower
+type scope int
+
+// scopes are the operations a token is allowed to perform, on top of its prefix permissions
+const (
+	scopeList scope = iota
+	scopeRead
+	scopeWrite
+	scopeDelete
+	scopeHistory
+	scopeExport
+)
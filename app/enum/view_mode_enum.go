@@ -33,6 +33,7 @@ func (e *ViewMode) UnmarshalText(text []byte) error {
 var _viewModeParseMap = map[string]ViewMode{
 	"grid":  ViewModeGrid,
 	"cards": ViewModeCards,
+	"tree":  ViewModeTree,
 }
 
 // ParseViewMode converts string to viewMode enum value.
@@ -57,18 +58,21 @@ func MustViewMode(v string) ViewMode {
 var (
 	ViewModeGrid  = ViewMode{name: "grid", value: 0}
 	ViewModeCards = ViewMode{name: "cards", value: 1}
+	ViewModeTree  = ViewMode{name: "tree", value: 2}
 )
 
 // ViewModeValues contains all possible enum values
 var ViewModeValues = []ViewMode{
 	ViewModeGrid,
 	ViewModeCards,
+	ViewModeTree,
 }
 
 // ViewModeNames contains all possible enum names
 var ViewModeNames = []string{
 	"grid",
 	"cards",
+	"tree",
 }
 
 // ViewModeIter returns a function compatible with Go 1.23's range-over-func syntax.
@@ -96,5 +100,7 @@ var _ = func() bool {
 	var _ viewMode = viewModeGrid
 	// This avoids "defined but not used" linter error for viewModeCards
 	var _ viewMode = viewModeCards
+	// This avoids "defined but not used" linter error for viewModeTree
+	var _ viewMode = viewModeTree
 	return true
 }()
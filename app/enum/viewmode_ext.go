@@ -1,9 +1,6 @@
 package enum
 
-// Toggle returns the opposite view mode (grid↔cards).
-func (v ViewMode) Toggle() ViewMode {
-	if v == ViewModeCards {
-		return ViewModeGrid
-	}
-	return ViewModeCards
+// Next returns the next view mode in the cycle: grid -> cards -> tree -> grid.
+func (v ViewMode) Next() ViewMode {
+	return ViewModeValues[(v.Index()+1)%len(ViewModeValues)]
 }
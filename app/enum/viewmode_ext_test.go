@@ -6,18 +6,19 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestViewMode_Toggle(t *testing.T) {
+func TestViewMode_Next(t *testing.T) {
 	tests := []struct {
 		current  ViewMode
 		expected ViewMode
 	}{
 		{ViewModeGrid, ViewModeCards},
-		{ViewModeCards, ViewModeGrid},
+		{ViewModeCards, ViewModeTree},
+		{ViewModeTree, ViewModeGrid},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.current.String()+"->"+tc.expected.String(), func(t *testing.T) {
-			assert.Equal(t, tc.expected, tc.current.Toggle())
+			assert.Equal(t, tc.expected, tc.current.Next())
 		})
 	}
 }
@@ -4,6 +4,7 @@
 package git
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -21,6 +22,8 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+
+	"github.com/umputun/stash/app/store"
 )
 
 // Author represents the author of a git commit.
@@ -48,6 +51,8 @@ type HistoryEntry struct {
 	Operation string    `json:"operation"`
 	Format    string    `json:"format"`
 	Value     []byte    `json:"value"`
+	// MinVersion is the minimum client version the revision requires, empty if it has no constraint
+	MinVersion string `json:"min_version,omitempty"`
 }
 
 // CommitRequest holds parameters for a git commit operation.
@@ -57,6 +62,8 @@ type CommitRequest struct {
 	Operation string
 	Format    string
 	Author    Author
+	// MinVersion is recorded in the commit metadata, see HistoryEntry.MinVersion
+	MinVersion string
 }
 
 // Config holds git repository configuration
@@ -65,6 +72,12 @@ type Config struct {
 	Branch string // branch name (default: master)
 	Remote string // remote name (optional, for push/pull)
 	SSHKey string // path to SSH private key (optional, for push)
+	// LFSThreshold is the size in bytes above which values are committed as git-lfs pointer files,
+	// with the values kept in .git/lfs/objects. 0 commits all values as is.
+	LFSThreshold int
+	// Secrets encrypts values of secret keys before commit, see SecretCodec. Without it secrets are
+	// committed as stored, and encrypted revisions can't be read.
+	Secrets SecretCodec
 }
 
 // Store provides git-backed versioning for key-value storage
@@ -200,7 +213,9 @@ func (s *Store) writeKeyFile(key string, value []byte) (string, error) {
 	return filePath, nil
 }
 
-// Commit writes key-value to file and commits to git.
+// Commit writes key-value to file and commits to git. A value and metadata equal to the last commit of the key
+// are not committed again, so re-writes of the same value don't add empty revisions to the history.
+// Values above Config.LFSThreshold are committed as git-lfs pointers, secrets are encrypted with Config.Secrets.
 func (s *Store) Commit(req CommitRequest) error {
 	if err := s.validateKey(req.Key); err != nil {
 		return err
@@ -216,7 +231,24 @@ func (s *Store) Commit(req CommitRequest) error {
 		format = "text"
 	}
 
-	filePath, err := s.writeKeyFile(req.Key, req.Value)
+	content, err := s.sealSecret(req.Key, req.Value)
+	if err != nil {
+		return err
+	}
+	if s.cfg.LFSThreshold > 0 && len(content) > s.cfg.LFSThreshold {
+		pointer, lfsErr := s.storeLFS(content)
+		if lfsErr != nil {
+			return lfsErr
+		}
+		content = pointer
+	}
+
+	if s.unchanged(req.Key, req.Value, content, format, req.MinVersion) {
+		log.Printf("[DEBUG] git commit of %s skipped, value and format are unchanged", req.Key)
+		return nil
+	}
+
+	filePath, err := s.writeKeyFile(req.Key, content)
 	if err != nil {
 		return err
 	}
@@ -233,6 +265,9 @@ func (s *Store) Commit(req CommitRequest) error {
 	// commit with metadata including format
 	msg := fmt.Sprintf("%s %s\n\ntimestamp: %s\noperation: %s\nkey: %s\nformat: %s",
 		req.Operation, req.Key, now.Format(time.RFC3339), req.Operation, req.Key, format)
+	if req.MinVersion != "" {
+		msg += "\nmin_version: " + req.MinVersion
+	}
 
 	_, commitErr := wt.Commit(msg, &git.CommitOptions{
 		Author: &object.Signature{
@@ -240,6 +275,7 @@ func (s *Store) Commit(req CommitRequest) error {
 			Email: req.Author.Email,
 			When:  now,
 		},
+		AllowEmptyCommits: true, // a format or min version change keeps the file as is
 	})
 	if commitErr != nil {
 		return fmt.Errorf("failed to commit: %w", commitErr)
@@ -248,6 +284,55 @@ func (s *Store) Commit(req CommitRequest) error {
 	return nil
 }
 
+// unchanged reports whether the file at HEAD has the content, and its last commit the format and min version.
+// The blob hashes are compared first, the log lookup is done for equal content only. Encrypted secrets differ
+// on each encryption, so the committed one is decrypted and compared with the value.
+func (s *Store) unchanged(key string, value, content []byte, format, minVersion string) bool {
+	filePath := keyToPath(key)
+	head, err := s.repo.Head()
+	if err != nil {
+		return false
+	}
+	commit, err := s.repo.CommitObject(head.Hash())
+	if err != nil {
+		return false
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return false
+	}
+	file, err := tree.File(filePath)
+	if err != nil {
+		return false
+	}
+	if file.Hash != plumbing.ComputeHash(plumbing.BlobObject, content) && !s.sameSecret(key, file, value) {
+		return false
+	}
+	last := s.lastCommit(filePath)
+	if last == nil {
+		return false
+	}
+	return parseFormatFromCommit(last.Message) == format && parseMinVersionFromCommit(last.Message) == minVersion
+}
+
+// sameSecret reports whether the committed file holds the secret value encrypted. A plaintext revision
+// is never the same, so enabling encryption replaces it on the next write.
+func (s *Store) sameSecret(key string, file *object.File, value []byte) bool {
+	if s.cfg.Secrets == nil || !store.IsSecret(key) {
+		return false
+	}
+	committed, err := file.Contents()
+	if err != nil {
+		return false
+	}
+	resolved, err := s.resolveLFS([]byte(committed))
+	if err != nil || !bytes.HasPrefix(resolved, []byte(secretMarker)) {
+		return false
+	}
+	plain, err := s.readValue(key, resolved)
+	return err == nil && bytes.Equal(plain, value)
+}
+
 // Delete removes key file and commits the deletion.
 // The author parameter specifies who made the change.
 func (s *Store) Delete(key string, author Author) error {
@@ -307,7 +392,11 @@ func (s *Store) Push() error {
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.push(false)
+}
 
+// push pushes the branch to the remote, replacing the remote branch if force is set. Caller holds the lock.
+func (s *Store) push(force bool) error {
 	var auth transport.AuthMethod
 	if s.cfg.SSHKey != "" {
 		var err error
@@ -323,6 +412,7 @@ func (s *Store) Push() error {
 		RefSpecs: []config.RefSpec{
 			config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", s.cfg.Branch, s.cfg.Branch)),
 		},
+		Force: force,
 	})
 	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return fmt.Errorf("failed to push: %w", err)
@@ -330,6 +420,104 @@ func (s *Store) Push() error {
 	return nil
 }
 
+// RewriteAuthor replaces the author and committer named name with the given author in the history of
+// the branch, e.g. to pseudonymize a user on an erasure request. Commits are rewritten from the first
+// changed one on, each pointing to its rewritten parents, so the history stays a valid chain with new
+// hashes; older revision hashes of the rewritten part stop resolving. With a remote, the rewritten branch
+// replaces the remote one by a force push, and replaced commits are deleted from the local object store.
+// Objects packed by a clone or pull stay until git gc. Returns the number of commits of the author.
+func (s *Store) RewriteAuthor(name string, to Author) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branchRef := plumbing.NewBranchReferenceName(s.cfg.Branch)
+	ref, err := s.repo.Reference(branchRef, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get branch %s: %w", s.cfg.Branch, err)
+	}
+
+	// parents are rewritten before children, iteratively as the history can be long
+	rewritten := map[plumbing.Hash]plumbing.Hash{}
+	count := 0
+	for stack := []plumbing.Hash{ref.Hash()}; len(stack) > 0; {
+		hash := stack[len(stack)-1]
+		if _, done := rewritten[hash]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		commit, err := s.repo.CommitObject(hash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+		pending := false
+		for _, p := range commit.ParentHashes {
+			if _, done := rewritten[p]; !done {
+				stack = append(stack, p)
+				pending = true
+			}
+		}
+		if pending {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+
+		changed := false
+		parents := make([]plumbing.Hash, len(commit.ParentHashes))
+		for i, p := range commit.ParentHashes {
+			parents[i] = rewritten[p]
+			changed = changed || parents[i] != p
+		}
+		author, committer := commit.Author, commit.Committer
+		if author.Name == name || committer.Name == name {
+			count++
+			changed = true
+		}
+		if author.Name == name {
+			author.Name, author.Email = to.Name, to.Email
+		}
+		if committer.Name == name {
+			committer.Name, committer.Email = to.Name, to.Email
+		}
+		if !changed {
+			rewritten[hash] = hash
+			continue
+		}
+		updated := &object.Commit{Author: author, Committer: committer, Message: commit.Message,
+			TreeHash: commit.TreeHash, ParentHashes: parents, Encoding: commit.Encoding}
+		obj := s.repo.Storer.NewEncodedObject()
+		if err := updated.Encode(obj); err != nil {
+			return 0, fmt.Errorf("failed to encode commit: %w", err)
+		}
+		if rewritten[hash], err = s.repo.Storer.SetEncodedObject(obj); err != nil {
+			return 0, fmt.Errorf("failed to store commit: %w", err)
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	head := rewritten[ref.Hash()]
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(branchRef, head)); err != nil {
+		return 0, fmt.Errorf("failed to update branch %s: %w", s.cfg.Branch, err)
+	}
+	if s.cfg.Remote != "" {
+		if err := s.push(true); err != nil {
+			return count, fmt.Errorf("history rewritten locally, remote not updated: %w", err)
+		}
+		remoteRef := plumbing.NewRemoteReferenceName(s.cfg.Remote, s.cfg.Branch)
+		if _, err := s.repo.Reference(remoteRef, false); err == nil {
+			if err := s.repo.Storer.SetReference(plumbing.NewHashReference(remoteRef, head)); err != nil {
+				return count, fmt.Errorf("failed to update %s: %w", remoteRef, err)
+			}
+		}
+	}
+	err = s.repo.Prune(git.PruneOptions{Handler: s.repo.DeleteObject})
+	if err != nil && !errors.Is(err, git.ErrLooseObjectsNotSupported) {
+		return count, fmt.Errorf("failed to prune replaced commits: %w", err)
+	}
+	return count, nil
+}
+
 // Head returns the current HEAD commit hash as a short string
 func (s *Store) Head() (string, error) {
 	s.mu.Lock()
@@ -342,6 +530,40 @@ func (s *Store) Head() (string, error) {
 	return ref.Hash().String()[:7], nil
 }
 
+// Verify checks the repository at the configured path without changing it: HEAD resolves to a readable
+// commit, the worktree has no changes left by an interrupted commit, and the configured remote exists.
+// Unlike New it doesn't check out the branch, which would discard the changes.
+func Verify(cfg Config) error {
+	repo, err := git.PlainOpen(cfg.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open repo: %w", err)
+	}
+	ref, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	if _, err := repo.CommitObject(ref.Hash()); err != nil {
+		return fmt.Errorf("failed to read HEAD commit %s: %w", ref.Hash().String()[:7], err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree status: %w", err)
+	}
+	if !status.IsClean() {
+		return fmt.Errorf("worktree has %d uncommitted files", len(status))
+	}
+	if cfg.Remote != "" {
+		if _, err := repo.Remote(cfg.Remote); err != nil {
+			return fmt.Errorf("failed to get remote %s: %w", cfg.Remote, err)
+		}
+	}
+	return nil
+}
+
 // Pull fetches and merges from remote repository
 func (s *Store) Pull() error {
 	if s.cfg.Remote == "" {
@@ -459,6 +681,9 @@ func (s *Store) ReadAll() (map[string]KeyValue, error) {
 			return fmt.Errorf("failed to get relative path: %w", relErr)
 		}
 		key := pathToKey(relPath)
+		if content, readErr = s.readValue(key, content); readErr != nil {
+			return fmt.Errorf("failed to read %s: %w", path, readErr)
+		}
 
 		// get format from the last commit that modified this file
 		format := s.getFileFormat(relPath)
@@ -487,9 +712,7 @@ func (s *Store) History(key string, limit int) ([]HistoryEntry, error) {
 
 	filePath := keyToPath(key)
 
-	logIter, err := s.repo.Log(&git.LogOptions{
-		FileName: &filePath,
-	})
+	logIter, err := s.keyLog(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get log: %w", err)
 	}
@@ -511,11 +734,12 @@ func (s *Store) History(key string, limit int) ([]HistoryEntry, error) {
 
 		// extract metadata from commit
 		entry := HistoryEntry{
-			Hash:      commit.Hash.String()[:7],
-			Timestamp: commit.Author.When,
-			Author:    commit.Author.Name,
-			Operation: parseOperationFromCommit(commit.Message),
-			Format:    parseFormatFromCommit(commit.Message),
+			Hash:       commit.Hash.String()[:7],
+			Timestamp:  commit.Author.When,
+			Author:     commit.Author.Name,
+			Operation:  parseOperationFromCommit(commit.Message),
+			Format:     parseFormatFromCommit(commit.Message),
+			MinVersion: parseMinVersionFromCommit(commit.Message),
 		}
 
 		// get file content at this commit (may be missing for delete commits)
@@ -548,7 +772,12 @@ func (s *Store) getFileContentAtCommit(commit *object.Commit, filePath, key, has
 		return nil
 	}
 
-	return []byte(content)
+	value, readErr := s.readValue(key, []byte(content))
+	if readErr != nil {
+		log.Printf("[WARN] git history: failed to read value at %s for key %q: %v", hash, key, readErr)
+		return nil
+	}
+	return value
 }
 
 // GetRevision returns value and format at specific revision.
@@ -590,32 +819,110 @@ func (s *Store) GetRevision(key, rev string) ([]byte, string, error) {
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to read file: %w", err)
 	}
+	value, err := s.readValue(key, []byte(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read value at revision %s: %w", rev, err)
+	}
 
 	// get format from commit message
 	format := parseFormatFromCommit(commit.Message)
 
-	return []byte(content), format, nil
+	return value, format, nil
 }
 
 // getFileFormat finds the last commit that modified a file and extracts format from its message.
 // returns "text" if no format is found.
 func (s *Store) getFileFormat(filePath string) string {
-	// get log for this specific file
-	logIter, err := s.repo.Log(&git.LogOptions{
-		FileName: &filePath,
-	})
-	if err != nil {
+	commit := s.lastCommit(filePath)
+	if commit == nil {
 		return "text"
 	}
+	return parseFormatFromCommit(commit.Message)
+}
+
+// lastCommit returns the most recent commit that modified a file, nil if there is none.
+func (s *Store) lastCommit(filePath string) *object.Commit {
+	logIter, err := s.keyLog(filePath)
+	if err != nil {
+		return nil
+	}
 	defer logIter.Close()
 
-	// get the most recent commit for this file
 	commit, err := logIter.Next()
 	if err != nil {
-		return "text"
+		return nil
 	}
+	return commit
+}
 
-	return parseFormatFromCommit(commit.Message)
+// keyLog iterates commits changing the key of a file, newest first. Commits made by stash are matched by the key
+// line of their metadata, which also finds commits changing only the format of a value, as these keep the file.
+// Other commits, e.g. merges or commits made outside stash, are matched by a change of the file.
+type keyLog struct {
+	object.CommitIter
+	key      string
+	filePath string
+}
+
+// keyLog returns the log of the key stored in the file, starting at HEAD.
+func (s *Store) keyLog(filePath string) (*keyLog, error) {
+	iter, err := s.repo.Log(&git.LogOptions{})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by callers
+	}
+	return &keyLog{CommitIter: iter, key: pathToKey(filePath), filePath: filePath}, nil
+}
+
+// Next returns the next commit changing the key, io.EOF at the end of the log.
+func (l *keyLog) Next() (*object.Commit, error) {
+	for {
+		commit, err := l.CommitIter.Next()
+		if err != nil {
+			return nil, err //nolint:wrapcheck // io.EOF is checked by callers
+		}
+		if l.changes(commit) {
+			return commit, nil
+		}
+	}
+}
+
+// changes reports whether the commit changes the key.
+func (l *keyLog) changes(commit *object.Commit) bool {
+	if key, ok := parseKeyFromCommit(commit.Message); ok {
+		return key == l.key
+	}
+	hash := fileHash(commit, l.filePath)
+	if commit.NumParents() == 0 {
+		return hash != plumbing.ZeroHash
+	}
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return hash != plumbing.ZeroHash
+	}
+	return hash != fileHash(parent, l.filePath)
+}
+
+// fileHash returns the blob hash of the file at the commit, zero hash if the file doesn't exist there.
+func fileHash(commit *object.Commit, filePath string) plumbing.Hash {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	file, err := tree.File(filePath)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return file.Hash
+}
+
+// parseKeyFromCommit extracts the key from commit message metadata, false for commits without the "key: <value>" line.
+func parseKeyFromCommit(message string) (string, bool) {
+	for line := range strings.SplitSeq(message, "\n") {
+		if key, found := strings.CutPrefix(line, "key: "); found {
+			return key, true
+		}
+	}
+	return "", false
 }
 
 // parseFormatFromCommit extracts format value from commit message metadata.
@@ -629,6 +936,17 @@ func parseFormatFromCommit(message string) string {
 	return "text"
 }
 
+// parseMinVersionFromCommit extracts the minimum client version from commit message metadata.
+// returns empty string for commits without the "min_version: <value>" line.
+func parseMinVersionFromCommit(message string) string {
+	for line := range strings.SplitSeq(message, "\n") {
+		if v, found := strings.CutPrefix(line, "min_version: "); found {
+			return v
+		}
+	}
+	return ""
+}
+
 // parseOperationFromCommit extracts operation from commit message metadata.
 // looks for "operation: <value>" line, or parses first word of commit message.
 func parseOperationFromCommit(message string) string {
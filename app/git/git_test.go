@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -113,6 +115,44 @@ func TestStore_Commit(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, binary, content)
 	})
+
+	t.Run("skips unchanged value", func(t *testing.T) {
+		store, err := New(Config{Path: filepath.Join(t.TempDir(), ".history")})
+		require.NoError(t, err)
+		req := CommitRequest{Key: "app/config", Value: []byte("v1"), Operation: "update", Format: "json", Author: DefaultAuthor()}
+		require.NoError(t, store.Commit(req))
+		head, err := store.Head()
+		require.NoError(t, err)
+
+		require.NoError(t, store.Commit(req))
+		req.Author = Author{Name: "other", Email: "other@example.com"}
+		require.NoError(t, store.Commit(req))
+		after, err := store.Head()
+		require.NoError(t, err)
+		assert.Equal(t, head, after, "same value and format make no commit")
+
+		// a format or min version change keeps the file, but is committed
+		req.Format = "yaml"
+		require.NoError(t, store.Commit(req))
+		req.MinVersion = "2"
+		require.NoError(t, store.Commit(req))
+		history, err := store.History("app/config", 0)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, "2", history[0].MinVersion)
+		assert.Equal(t, "yaml", history[1].Format)
+		assert.Equal(t, []byte("v1"), history[1].Value)
+		assert.Equal(t, "json", history[2].Format)
+
+		// set after delete is committed
+		require.NoError(t, store.Delete("app/config", DefaultAuthor()))
+		require.NoError(t, store.Commit(req))
+		history, err = store.History("app/config", 0)
+		require.NoError(t, err)
+		require.Len(t, history, 5)
+		assert.Equal(t, []byte("v1"), history[0].Value)
+		assert.Equal(t, "delete", history[1].Operation)
+	})
 }
 
 func TestStore_Delete(t *testing.T) {
@@ -656,6 +696,34 @@ func TestStore_Head(t *testing.T) {
 	})
 }
 
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".history")
+	store, err := New(Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, store.Commit(CommitRequest{Key: "key1", Value: []byte("value1"), Operation: "set", Author: DefaultAuthor()}))
+	require.NoError(t, Verify(Config{Path: path}))
+
+	t.Run("uncommitted file", func(t *testing.T) {
+		stray := filepath.Join(path, "stray.val")
+		require.NoError(t, os.WriteFile(stray, []byte("x"), 0o600))
+		defer os.Remove(stray)
+		err := Verify(Config{Path: path})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 uncommitted files")
+		assert.FileExists(t, stray, "verify doesn't touch the worktree")
+	})
+
+	t.Run("missing remote", func(t *testing.T) {
+		err := Verify(Config{Path: path, Remote: "origin"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "remote origin")
+	})
+
+	t.Run("not a repo", func(t *testing.T) {
+		assert.Error(t, Verify(Config{Path: t.TempDir()}))
+	})
+}
+
 func TestParseFormatFromCommit(t *testing.T) {
 	tests := []struct {
 		name, message, expected string
@@ -674,6 +742,11 @@ func TestParseFormatFromCommit(t *testing.T) {
 	}
 }
 
+func TestParseMinVersionFromCommit(t *testing.T) {
+	assert.Equal(t, "1.2", parseMinVersionFromCommit("set key\n\nkey: test\nformat: json\nmin_version: 1.2"))
+	assert.Empty(t, parseMinVersionFromCommit("set key\n\nkey: test\nformat: json"))
+}
+
 func TestParseOperationFromCommit(t *testing.T) {
 	tests := []struct {
 		name, message, expected string
@@ -730,6 +803,21 @@ func TestStore_History(t *testing.T) {
 		assert.Equal(t, "text", history[2].Format)
 	})
 
+	t.Run("returns min version", func(t *testing.T) {
+		store, err := New(Config{Path: filepath.Join(t.TempDir(), ".history")})
+		require.NoError(t, err)
+		require.NoError(t, store.Commit(CommitRequest{Key: "app/config", Value: []byte("v1"), Operation: "create",
+			Author: DefaultAuthor()}))
+		require.NoError(t, store.Commit(CommitRequest{Key: "app/config", Value: []byte("v2"), Operation: "update",
+			Author: DefaultAuthor(), MinVersion: "2.0"}))
+
+		history, err := store.History("app/config", 0)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, "2.0", history[0].MinVersion)
+		assert.Empty(t, history[1].MinVersion)
+	})
+
 	t.Run("respects limit", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		store, err := New(Config{Path: filepath.Join(tmpDir, ".history")})
@@ -934,3 +1022,85 @@ func TestStore_GetRevision(t *testing.T) {
 		assert.Contains(t, err.Error(), "invalid key")
 	})
 }
+
+func TestStore_RewriteAuthor(t *testing.T) {
+	alice, bob := Author{Name: "alice", Email: "alice@stash"}, Author{Name: "bob", Email: "bob@stash"}
+	anon := Author{Name: "redacted-1f2e", Email: "redacted-1f2e@stash"}
+	setup := func(t *testing.T, cfg Config) *Store {
+		t.Helper()
+		store, err := New(cfg)
+		require.NoError(t, err)
+		for i, a := range []Author{bob, alice, bob, alice} {
+			require.NoError(t, store.Commit(CommitRequest{Key: "app/config", Value: []byte(fmt.Sprintf("v%d", i+1)),
+				Operation: "set", Format: "text", Author: a}))
+		}
+		require.NoError(t, store.Delete("app/config", bob))
+		return store
+	}
+
+	t.Run("rewrites local history", func(t *testing.T) {
+		store := setup(t, Config{Path: filepath.Join(t.TempDir(), ".history")})
+		before, err := store.History("app/config", 0)
+		require.NoError(t, err)
+		oldAlice, err := store.repo.ResolveRevision(plumbing.Revision(before[1].Hash))
+		require.NoError(t, err)
+
+		count, err := store.RewriteAuthor("alice", anon)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		after, err := store.History("app/config", 0)
+		require.NoError(t, err)
+		require.Len(t, after, len(before))
+		for i := range after {
+			assert.Equal(t, before[i].Value, after[i].Value)
+			assert.Equal(t, before[i].Operation, after[i].Operation)
+			assert.True(t, before[i].Timestamp.Equal(after[i].Timestamp))
+		}
+		assert.Equal(t, []string{"bob", "redacted-1f2e", "bob", "redacted-1f2e", "bob"},
+			[]string{after[0].Author, after[1].Author, after[2].Author, after[3].Author, after[4].Author})
+		assert.Equal(t, before[4].Hash, after[4].Hash, "commits before the first rewritten one are kept")
+		assert.NotEqual(t, before[0].Hash, after[0].Hash, "later commits get new hashes")
+
+		_, err = store.repo.CommitObject(*oldAlice)
+		require.Error(t, err, "replaced commit is pruned")
+
+		head, err := store.repo.Head()
+		require.NoError(t, err)
+		commit, err := store.repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+		iter := object.NewCommitPreorderIter(commit, nil, nil)
+		err = iter.ForEach(func(c *object.Commit) error {
+			assert.NotEqual(t, "alice", c.Author.Name)
+			assert.NotEqual(t, "alice", c.Committer.Name)
+			return nil
+		})
+		require.NoError(t, err)
+
+		count, err = store.RewriteAuthor("alice", anon)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		require.NoError(t, store.Commit(CommitRequest{Key: "app/other", Value: []byte("x"), Operation: "set", Author: bob}),
+			"still usable after rewrite")
+	})
+
+	t.Run("force pushes to remote", func(t *testing.T) {
+		remoteDir := filepath.Join(t.TempDir(), "remote.git")
+		remote, err := git.PlainInit(remoteDir, true)
+		require.NoError(t, err)
+		localDir := filepath.Join(t.TempDir(), ".history")
+		store := setup(t, Config{Path: localDir, Remote: "origin"})
+		_, err = store.repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}})
+		require.NoError(t, err)
+		require.NoError(t, store.Push())
+
+		_, err = store.RewriteAuthor("alice", anon)
+		require.NoError(t, err)
+
+		local, err := store.repo.Reference(plumbing.NewBranchReferenceName("master"), true)
+		require.NoError(t, err)
+		remoteHead, err := remote.Reference(plumbing.NewBranchReferenceName("master"), true)
+		require.NoError(t, err)
+		assert.Equal(t, local.Hash(), remoteHead.Hash())
+	})
+}
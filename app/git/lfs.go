@@ -0,0 +1,99 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lfsVersion is the first line of a git-lfs pointer file.
+const lfsVersion = "version https://git-lfs.github.com/spec/v1"
+
+// lfsPointerMax is the size above which content can't be a pointer, pointers are about 130 bytes.
+const lfsPointerMax = 200
+
+// lfsPointer returns the git-lfs pointer file of a value with the given sha256 oid.
+func lfsPointer(oid string, size int) []byte {
+	return fmt.Appendf(nil, "%s\noid sha256:%s\nsize %d\n", lfsVersion, oid, size)
+}
+
+// parseLFSPointer returns the oid and size of a git-lfs pointer file, false if the content isn't one.
+func parseLFSPointer(content []byte) (oid string, size int, ok bool) {
+	if len(content) > lfsPointerMax || !bytes.HasPrefix(content, []byte(lfsVersion+"\n")) {
+		return "", 0, false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 3 {
+		return "", 0, false
+	}
+	oid, found := strings.CutPrefix(lines[1], "oid sha256:")
+	if !found || len(oid) != sha256.Size*2 {
+		return "", 0, false
+	}
+	if _, err := hex.DecodeString(oid); err != nil {
+		return "", 0, false
+	}
+	sizeStr, found := strings.CutPrefix(lines[2], "size ")
+	if !found {
+		return "", 0, false
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size < 0 {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// lfsObjectPath returns the path of a large value in the object store, laid out as git-lfs does
+// under .git/lfs/objects, so the objects stay out of commits.
+func (s *Store) lfsObjectPath(oid string) string {
+	return filepath.Join(s.cfg.Path, ".git", "lfs", "objects", oid[:2], oid[2:4], oid)
+}
+
+// storeLFS writes the value to the object store and returns its pointer. Objects are addressed by content,
+// an existing one is the same value and is kept as is.
+func (s *Store) storeLFS(value []byte) ([]byte, error) {
+	sum := sha256.Sum256(value)
+	oid := hex.EncodeToString(sum[:])
+	objPath := s.lfsObjectPath(oid)
+	if _, err := os.Stat(objPath); err == nil {
+		return lfsPointer(oid, len(value)), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(objPath), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create lfs object directory: %w", err)
+	}
+	// written under a temporary name first, a partial object would be taken for a complete one
+	tmp := objPath + ".tmp"
+	if err := os.WriteFile(tmp, value, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write lfs object: %w", err)
+	}
+	if err := os.Rename(tmp, objPath); err != nil {
+		return nil, fmt.Errorf("failed to store lfs object: %w", err)
+	}
+	return lfsPointer(oid, len(value)), nil
+}
+
+// resolveLFS returns the value a pointer file refers to, other content is returned as is.
+func (s *Store) resolveLFS(content []byte) ([]byte, error) {
+	oid, size, ok := parseLFSPointer(content)
+	if !ok {
+		return content, nil
+	}
+	value, err := os.ReadFile(s.lfsObjectPath(oid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("lfs object %s is missing", oid[:12])
+		}
+		return nil, fmt.Errorf("failed to read lfs object: %w", err)
+	}
+	if sum := sha256.Sum256(value); len(value) != size || hex.EncodeToString(sum[:]) != oid {
+		return nil, fmt.Errorf("lfs object %s is corrupted", oid[:12])
+	}
+	return value, nil
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_CommitLFS(t *testing.T) {
+	store, err := New(Config{Path: filepath.Join(t.TempDir(), ".history"), LFSThreshold: 16})
+	require.NoError(t, err)
+	large := bytes.Repeat([]byte{0x00, 0xFF}, 100)
+	require.NoError(t, store.Commit(CommitRequest{Key: "app/blob", Value: large, Operation: "create", Format: "text",
+		Author: DefaultAuthor()}))
+	require.NoError(t, store.Commit(CommitRequest{Key: "app/small", Value: []byte("small value"), Operation: "create",
+		Author: DefaultAuthor()}))
+
+	// the large value is committed as a pointer, its content is kept out of the repository
+	pointer, err := os.ReadFile(filepath.Join(store.cfg.Path, "app", "blob.val"))
+	require.NoError(t, err)
+	assert.Equal(t, "version https://git-lfs.github.com/spec/v1\n"+
+		"oid sha256:1d8f4aa690fea048ce553451118f3f7fcbed9a1685d0d41f9127e7b71725bbcb\nsize 200\n", string(pointer))
+	oid, size, ok := parseLFSPointer(pointer)
+	require.True(t, ok)
+	assert.Equal(t, 200, size)
+	obj, err := os.ReadFile(store.lfsObjectPath(oid))
+	require.NoError(t, err)
+	assert.Equal(t, large, obj)
+	small, err := os.ReadFile(filepath.Join(store.cfg.Path, "app", "small.val"))
+	require.NoError(t, err)
+	assert.Equal(t, "small value", string(small))
+
+	head, err := store.Head()
+	require.NoError(t, err)
+	require.NoError(t, store.Commit(CommitRequest{Key: "app/blob", Value: large, Operation: "update", Format: "text",
+		Author: DefaultAuthor()}))
+	after, err := store.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head, after, "re-write of the large value makes no commit")
+
+	// reads resolve pointers
+	all, err := store.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, large, all["app/blob"].Value)
+	assert.Equal(t, []byte("small value"), all["app/small"].Value)
+	history, err := store.History("app/blob", 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, large, history[0].Value)
+	value, _, err := store.GetRevision("app/blob", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	// a missing or changed object fails reads instead of returning the pointer
+	require.NoError(t, os.WriteFile(store.lfsObjectPath(oid), []byte("changed"), 0o600))
+	_, _, err = store.GetRevision("app/blob", "HEAD")
+	require.ErrorContains(t, err, "is corrupted")
+	require.NoError(t, os.Remove(store.lfsObjectPath(oid)))
+	_, err = store.ReadAll()
+	require.ErrorContains(t, err, "is missing")
+	history, err = store.History("app/blob", 0)
+	require.NoError(t, err)
+	assert.Nil(t, history[0].Value)
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+	}{
+		{name: "pointer", content: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12\n", ok: true},
+		{name: "no trailing newline", content: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12", ok: true},
+		{name: "plain value", content: "some value"},
+		{name: "short oid", content: "version https://git-lfs.github.com/spec/v1\noid sha256:abcd\nsize 12\n"},
+		{name: "not hex", content: "version https://git-lfs.github.com/spec/v1\noid sha256:" + strings.Repeat("zz", 32) + "\nsize 12\n"},
+		{name: "bad size", content: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize -1\n"},
+		{name: "extra line", content: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12\nx\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotOID, size, ok := parseLFSPointer([]byte(tc.content))
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, oid, gotOID)
+				assert.Equal(t, 12, size)
+			}
+		})
+	}
+}
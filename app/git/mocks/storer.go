@@ -33,6 +33,9 @@ import (
 //			PushFunc: func() error {
 //				panic("mock out the Push method")
 //			},
+//			RewriteAuthorFunc: func(name string, to git.Author) (int, error) {
+//				panic("mock out the RewriteAuthor method")
+//			},
 //		}
 //
 //		// use mockedStorer in code that requires git.Storer
@@ -58,6 +61,9 @@ type StorerMock struct {
 	// PushFunc mocks the Push method.
 	PushFunc func() error
 
+	// RewriteAuthorFunc mocks the RewriteAuthor method.
+	RewriteAuthorFunc func(name string, to git.Author) (int, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Commit holds details about calls to the Commit method.
@@ -92,13 +98,21 @@ type StorerMock struct {
 		// Push holds details about calls to the Push method.
 		Push []struct {
 		}
+		// RewriteAuthor holds details about calls to the RewriteAuthor method.
+		RewriteAuthor []struct {
+			// Name is the name argument value.
+			Name string
+			// To is the to argument value.
+			To git.Author
+		}
 	}
-	lockCommit      sync.RWMutex
-	lockDelete      sync.RWMutex
-	lockGetRevision sync.RWMutex
-	lockHistory     sync.RWMutex
-	lockPull        sync.RWMutex
-	lockPush        sync.RWMutex
+	lockCommit        sync.RWMutex
+	lockDelete        sync.RWMutex
+	lockGetRevision   sync.RWMutex
+	lockHistory       sync.RWMutex
+	lockPull          sync.RWMutex
+	lockPush          sync.RWMutex
+	lockRewriteAuthor sync.RWMutex
 }
 
 // Commit calls CommitFunc.
@@ -294,3 +308,39 @@ func (mock *StorerMock) PushCalls() []struct {
 	mock.lockPush.RUnlock()
 	return calls
 }
+
+// RewriteAuthor calls RewriteAuthorFunc.
+func (mock *StorerMock) RewriteAuthor(name string, to git.Author) (int, error) {
+	if mock.RewriteAuthorFunc == nil {
+		panic("StorerMock.RewriteAuthorFunc: method is nil but Storer.RewriteAuthor was just called")
+	}
+	callInfo := struct {
+		Name string
+		To   git.Author
+	}{
+		Name: name,
+		To:   to,
+	}
+	mock.lockRewriteAuthor.Lock()
+	mock.calls.RewriteAuthor = append(mock.calls.RewriteAuthor, callInfo)
+	mock.lockRewriteAuthor.Unlock()
+	return mock.RewriteAuthorFunc(name, to)
+}
+
+// RewriteAuthorCalls gets all the calls that were made to RewriteAuthor.
+// Check the length with:
+//
+//	len(mockedStorer.RewriteAuthorCalls())
+func (mock *StorerMock) RewriteAuthorCalls() []struct {
+	Name string
+	To   git.Author
+} {
+	var calls []struct {
+		Name string
+		To   git.Author
+	}
+	mock.lockRewriteAuthor.RLock()
+	calls = mock.calls.RewriteAuthor
+	mock.lockRewriteAuthor.RUnlock()
+	return calls
+}
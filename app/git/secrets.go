@@ -0,0 +1,152 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/umputun/stash/app/store"
+	"github.com/umputun/stash/lib/stash"
+)
+
+// secretMarker starts the committed content of an encrypted secret value.
+const secretMarker = "$ENC$"
+
+// SecretCodec encrypts values of secret keys before they are committed and decrypts them on reads,
+// so the repository and its remotes never hold the plaintext. Implemented by store.Store.
+type SecretCodec interface {
+	EncryptSecret(key string, value []byte) ([]byte, error)
+	DecryptSecret(value []byte) ([]byte, error)
+}
+
+// PlaintextSecret is a value of a secret key committed unencrypted, reported by ScanSecrets.
+type PlaintextSecret struct {
+	Key    string
+	Commit string    // short hash of the commit which added the value
+	When   time.Time // time of the commit
+}
+
+// sealSecret returns the content committed for the value. Secrets are encrypted and marked if a codec is set,
+// ZK-encrypted values are committed as is, as the server can't read them anyway.
+func (s *Store) sealSecret(key string, value []byte) ([]byte, error) {
+	if s.cfg.Secrets == nil || !store.IsSecret(key) || stash.IsZKEncrypted(value) {
+		return value, nil
+	}
+	encrypted, err := s.cfg.Secrets.EncryptSecret(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+	return append([]byte(secretMarker), encrypted...), nil
+}
+
+// readValue returns the value of the key from committed content: lfs pointers are resolved and encrypted
+// secrets decrypted. Secrets committed before encryption was enabled are returned as is.
+func (s *Store) readValue(key string, content []byte) ([]byte, error) {
+	value, err := s.resolveLFS(content)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, found := bytes.CutPrefix(value, []byte(secretMarker))
+	if !found || !store.IsSecret(key) {
+		return value, nil
+	}
+	if s.cfg.Secrets == nil {
+		return nil, fmt.Errorf("value of %s is encrypted, secrets key is required", key)
+	}
+	plain, err := s.cfg.Secrets.DecryptSecret(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return plain, nil
+}
+
+// ScanSecrets checks all revisions of the branch for values of secret keys committed in plaintext, e.g. before
+// encryption was enabled. Encrypted, ZK-encrypted and empty values are fine. Each plaintext value is reported
+// once, with the commit which added it. The history has to be rewritten to remove them.
+func (s *Store) ScanSecrets() ([]PlaintextSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref, err := s.repo.Reference(plumbing.NewBranchReferenceName(s.cfg.Branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch %s: %w", s.cfg.Branch, err)
+	}
+	commits, err := s.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log: %w", err)
+	}
+	defer commits.Close()
+
+	type blobKey struct {
+		key  string
+		hash plumbing.Hash
+	}
+	found := map[blobKey]*PlaintextSecret{}
+	checked := map[plumbing.Hash]bool{} // blobs already read, plaintext or not
+	err = commits.ForEach(func(commit *object.Commit) error {
+		tree, err := commit.Tree()
+		if err != nil {
+			return fmt.Errorf("failed to get tree of %s: %w", commit.Hash, err)
+		}
+		return tree.Files().ForEach(func(f *object.File) error {
+			key := pathToKey(f.Name)
+			if !strings.HasSuffix(f.Name, ".val") || !store.IsSecret(key) {
+				return nil
+			}
+			bk := blobKey{key: key, hash: f.Hash}
+			if ps, ok := found[bk]; ok {
+				// commits come newest first, the last one seen added the value
+				ps.Commit, ps.When = commit.Hash.String()[:7], commit.Author.When
+				return nil
+			}
+			if checked[f.Hash] {
+				return nil
+			}
+			checked[f.Hash] = true
+			plain, err := s.plaintext(f)
+			if err != nil {
+				return err
+			}
+			if plain {
+				found[bk] = &PlaintextSecret{Key: key, Commit: commit.Hash.String()[:7], When: commit.Author.When}
+			}
+			return nil
+		})
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to scan history: %w", err)
+	}
+
+	res := make([]PlaintextSecret, 0, len(found))
+	for _, ps := range found {
+		res = append(res, *ps)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Key != res[j].Key {
+			return res[i].Key < res[j].Key
+		}
+		return res[i].When.Before(res[j].When)
+	})
+	return res, nil
+}
+
+// plaintext reports whether the committed file of a secret holds a readable value.
+func (s *Store) plaintext(f *object.File) (bool, error) {
+	content, err := f.Contents()
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	value, err := s.resolveLFS([]byte(content))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return len(value) > 0 && !bytes.HasPrefix(value, []byte(secretMarker)) && !stash.IsZKEncrypted(value), nil
+}
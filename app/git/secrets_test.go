@@ -0,0 +1,131 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reverseCodec "encrypts" secrets by reversing them, with a random-like suffix to differ on each call
+type reverseCodec struct{ calls int }
+
+func (c *reverseCodec) EncryptSecret(_ string, value []byte) ([]byte, error) {
+	c.calls++
+	res := make([]byte, 0, len(value)+2)
+	for i := len(value) - 1; i >= 0; i-- {
+		res = append(res, value[i])
+	}
+	return append(res, '#', byte('0'+c.calls%10)), nil
+}
+
+func (c *reverseCodec) DecryptSecret(value []byte) ([]byte, error) {
+	i := strings.LastIndexByte(string(value), '#')
+	if i < 0 {
+		return nil, errors.New("not encrypted")
+	}
+	res := make([]byte, 0, i)
+	for j := i - 1; j >= 0; j-- {
+		res = append(res, value[j])
+	}
+	return res, nil
+}
+
+func TestStore_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Config{Path: dir, Secrets: &reverseCodec{}})
+	require.NoError(t, err)
+	author := DefaultAuthor()
+	commit := func(key, value string) {
+		require.NoError(t, s.Commit(CommitRequest{Key: key, Value: []byte(value), Operation: "set", Author: author}))
+	}
+
+	t.Run("secrets are committed encrypted", func(t *testing.T) {
+		commit("secrets/db", "pass")
+		commit("app/db", "host")
+		commit("secrets/zk", "$ZK$payload")
+
+		content, err := os.ReadFile(filepath.Join(dir, "secrets", "db.val"))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), secretMarker+"ssap#"), string(content))
+		content, err = os.ReadFile(filepath.Join(dir, "app", "db.val"))
+		require.NoError(t, err)
+		assert.Equal(t, "host", string(content))
+		content, err = os.ReadFile(filepath.Join(dir, "secrets", "zk.val"))
+		require.NoError(t, err)
+		assert.Equal(t, "$ZK$payload", string(content), "zk values are committed as is")
+	})
+
+	t.Run("reads decrypt", func(t *testing.T) {
+		all, err := s.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "pass", string(all["secrets/db"].Value))
+		head, err := s.Head()
+		require.NoError(t, err)
+		value, _, err := s.GetRevision("secrets/db", head)
+		require.NoError(t, err)
+		assert.Equal(t, "pass", string(value))
+		history, err := s.History("secrets/db", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "pass", string(history[0].Value))
+	})
+
+	t.Run("same secret isn't committed again", func(t *testing.T) {
+		commit("secrets/db", "pass")
+		history, err := s.History("secrets/db", 10)
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+		commit("secrets/db", "pass2")
+		history, err = s.History("secrets/db", 10)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("encrypted revision needs the codec", func(t *testing.T) {
+		plain, err := New(Config{Path: dir})
+		require.NoError(t, err)
+		head, err := plain.Head()
+		require.NoError(t, err)
+		_, _, err = plain.GetRevision("secrets/db", head)
+		require.ErrorContains(t, err, "secrets key is required")
+	})
+}
+
+func TestStore_ScanSecrets(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := New(Config{Path: dir})
+	require.NoError(t, err)
+	author := DefaultAuthor()
+	commit := func(s *Store, key, value string) {
+		require.NoError(t, s.Commit(CommitRequest{Key: key, Value: []byte(value), Operation: "set", Author: author}))
+	}
+
+	found, err := plain.ScanSecrets()
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	commit(plain, "secrets/db", "pass")
+	first, err := plain.Head()
+	require.NoError(t, err)
+	commit(plain, "app/db", "host")
+	commit(plain, "secrets/empty", "")
+	commit(plain, "secrets/zk", "$ZK$payload")
+
+	// encryption enabled later, the plaintext stays in older revisions
+	sealed, err := New(Config{Path: dir, Secrets: &reverseCodec{}})
+	require.NoError(t, err)
+	commit(sealed, "secrets/db", "pass")
+	commit(sealed, "secrets/api", "token")
+
+	found, err = sealed.ScanSecrets()
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "secrets/db", found[0].Key)
+	assert.Equal(t, first[:7], found[0].Commit, "reported with the commit which added it")
+	assert.False(t, found[0].When.IsZero())
+}
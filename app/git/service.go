@@ -12,6 +12,7 @@ type Storer interface {
 	Push() error
 	History(key string, limit int) ([]HistoryEntry, error)
 	GetRevision(key string, rev string) ([]byte, string, error)
+	RewriteAuthor(name string, to Author) (int, error)
 }
 
 // Service wraps Store and provides orchestrated git operations.
@@ -77,3 +78,12 @@ func (s *Service) GetRevision(key, rev string) ([]byte, string, error) {
 	}
 	return value, format, nil
 }
+
+// RewriteAuthor replaces an author in the history, see Store.RewriteAuthor.
+func (s *Service) RewriteAuthor(name string, to Author) (int, error) {
+	count, err := s.store.RewriteAuthor(name, to)
+	if err != nil {
+		return count, fmt.Errorf("rewrite author: %w", err)
+	}
+	return count, nil
+}
@@ -208,3 +208,16 @@ func TestService_GetRevision(t *testing.T) {
 		assert.Contains(t, err.Error(), "revision error")
 	})
 }
+
+func TestService_RewriteAuthor(t *testing.T) {
+	st := &mocks.StorerMock{
+		RewriteAuthorFunc: func(name string, to git.Author) (int, error) { return 3, errors.New("push failed") },
+	}
+	s := git.NewService(st, true)
+	count, err := s.RewriteAuthor("alice", git.Author{Name: "anon", Email: "anon@stash"})
+	require.ErrorContains(t, err, "rewrite author: push failed")
+	assert.Equal(t, 3, count, "count of rewritten commits is kept with the error")
+	require.Len(t, st.RewriteAuthorCalls(), 1)
+	assert.Equal(t, "alice", st.RewriteAuthorCalls()[0].Name)
+	assert.Empty(t, st.PullCalls(), "rewrite pushes on its own, no pull merging old history back")
+}
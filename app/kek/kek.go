@@ -0,0 +1,46 @@
+// Package kek wraps the secrets master key with a key encryption key (KEK), so the master key is
+// stored only in wrapped form. The KEK lives in an HSM, accessed via PKCS#11 (build with -tags pkcs11),
+// or, as a software fallback, in a local file. Both providers use AES-256-GCM and share the wrapped
+// format: version byte, 12-byte IV, ciphertext with tag.
+package kek
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	wrapVersion = 1
+	ivSize      = 12
+	tagSize     = 16
+)
+
+// ErrNotSupported is returned by PKCS#11 calls in builds without PKCS#11 support.
+var ErrNotSupported = errors.New("pkcs11 support not compiled in, rebuild with -tags pkcs11")
+
+// PKCS11Config configures access to the KEK in an HSM.
+type PKCS11Config struct {
+	Module   string // path to the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Token    string // token label
+	PIN      string // user PIN
+	KeyLabel string // label of the AES key used as KEK
+}
+
+// encodeWrapped joins the IV and ciphertext into the wrapped format.
+func encodeWrapped(iv, ciphertext []byte) []byte {
+	res := make([]byte, 0, 1+len(iv)+len(ciphertext))
+	res = append(res, wrapVersion)
+	res = append(res, iv...)
+	return append(res, ciphertext...)
+}
+
+// decodeWrapped splits a wrapped key into the IV and ciphertext.
+func decodeWrapped(wrapped []byte) (iv, ciphertext []byte, err error) {
+	if len(wrapped) < 1+ivSize+tagSize+1 {
+		return nil, nil, errors.New("wrapped key is too short")
+	}
+	if wrapped[0] != wrapVersion {
+		return nil, nil, fmt.Errorf("unsupported wrapped key version %d", wrapped[0])
+	}
+	return wrapped[1 : 1+ivSize], wrapped[1+ivSize:], nil
+}
@@ -0,0 +1,154 @@
+//go:build pkcs11 && cgo
+
+package kek
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11 wraps keys with an AES key that never leaves the HSM. Wrap and unwrap run as AES-GCM
+// encrypt and decrypt operations on the token.
+type PKCS11 struct {
+	mu      sync.Mutex // a PKCS#11 session runs one operation at a time
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+}
+
+// NewPKCS11 loads the module, logs into the token and looks up the KEK by label.
+func NewPKCS11(cfg PKCS11Config) (res *PKCS11, err error) {
+	if cfg.Module == "" || cfg.Token == "" || cfg.KeyLabel == "" {
+		return nil, errors.New("pkcs11 module, token and key label are required")
+	}
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load pkcs11 module %s", cfg.Module)
+	}
+	if err = ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("initialize pkcs11 module: %w", err)
+	}
+	p := &PKCS11{ctx: ctx}
+	defer func() {
+		if err != nil {
+			_ = p.Close()
+		}
+	}()
+
+	slot, err := p.findSlot(cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	if p.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION); err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	if err = ctx.Login(p.session, pkcs11.CKU_USER, cfg.PIN); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return nil, fmt.Errorf("login to token %q: %w", cfg.Token, err)
+	}
+	if p.key, err = p.findKey(cfg.KeyLabel); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Wrap encrypts the key on the HSM.
+func (p *PKCS11) Wrap(key []byte) ([]byte, error) {
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("generate iv: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	params := pkcs11.NewGCMParams(iv, nil, tagSize*8)
+	defer params.Free()
+	if err := p.ctx.EncryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, p.key); err != nil {
+		return nil, fmt.Errorf("encrypt init: %w", err)
+	}
+	ciphertext, err := p.ctx.Encrypt(p.session, key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if used := params.IV(); len(used) == ivSize {
+		iv = used // some HSMs generate their own IV
+	}
+	return encodeWrapped(iv, ciphertext), nil
+}
+
+// Unwrap decrypts the key on the HSM.
+func (p *PKCS11) Unwrap(wrapped []byte) ([]byte, error) {
+	iv, ciphertext, err := decodeWrapped(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	params := pkcs11.NewGCMParams(iv, nil, tagSize*8)
+	defer params.Free()
+	if err := p.ctx.DecryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, p.key); err != nil {
+		return nil, fmt.Errorf("decrypt init: %w", err)
+	}
+	key, err := p.ctx.Decrypt(p.session, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key, wrong kek or corrupted data: %w", err)
+	}
+	return key, nil
+}
+
+// Close logs out and releases the module.
+func (p *PKCS11) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.session != 0 {
+		_ = p.ctx.Logout(p.session)
+		_ = p.ctx.CloseSession(p.session)
+		p.session = 0
+	}
+	err := p.ctx.Finalize()
+	p.ctx.Destroy()
+	if err != nil {
+		return fmt.Errorf("finalize pkcs11 module: %w", err)
+	}
+	return nil
+}
+
+func (p *PKCS11) findSlot(label string) (uint, error) {
+	slots, err := p.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("list slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := p.ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("token %q not found", label)
+}
+
+func (p *PKCS11) findKey(label string) (pkcs11.ObjectHandle, error) {
+	tmpl := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, tmpl); err != nil {
+		return 0, fmt.Errorf("find key: %w", err)
+	}
+	objs, _, err := p.ctx.FindObjects(p.session, 2)
+	_ = p.ctx.FindObjectsFinal(p.session)
+	if err != nil {
+		return 0, fmt.Errorf("find key: %w", err)
+	}
+	switch len(objs) {
+	case 0:
+		return 0, fmt.Errorf("key %q not found on token", label)
+	case 1:
+		return objs[0], nil
+	default:
+		return 0, fmt.Errorf("several keys labeled %q on token", label)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !pkcs11 || !cgo
+
+package kek
+
+// PKCS11 is a placeholder in builds without PKCS#11 support, the default static build.
+type PKCS11 struct{}
+
+// NewPKCS11 always fails with ErrNotSupported.
+func NewPKCS11(PKCS11Config) (*PKCS11, error) { return nil, ErrNotSupported }
+
+// Wrap always fails with ErrNotSupported.
+func (p *PKCS11) Wrap([]byte) ([]byte, error) { return nil, ErrNotSupported }
+
+// Unwrap always fails with ErrNotSupported.
+func (p *PKCS11) Unwrap([]byte) ([]byte, error) { return nil, ErrNotSupported }
+
+// Close is a no-op.
+func (p *PKCS11) Close() error { return nil }
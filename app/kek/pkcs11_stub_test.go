@@ -0,0 +1,14 @@
+//go:build !pkcs11 || !cgo
+
+package kek
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCS11_NotSupported(t *testing.T) {
+	_, err := NewPKCS11(PKCS11Config{Module: "/usr/lib/softhsm/libsofthsm2.so", Token: "stash", KeyLabel: "stash-kek"})
+	require.ErrorIs(t, err, ErrNotSupported)
+}
@@ -0,0 +1,41 @@
+//go:build pkcs11 && cgo
+
+package kek
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPKCS11 runs against a real token, e.g. SoftHSM with an AES key:
+//
+//	softhsm2-util --init-token --free --label stash --pin 1234 --so-pin 1234
+//	pkcs11-tool --module /usr/lib/softhsm/libsofthsm2.so --login --pin 1234 --token-label stash \
+//	  --keygen --key-type AES:32 --label stash-kek
+//	STASH_TEST_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so go test -tags pkcs11 ./app/kek/
+func TestPKCS11(t *testing.T) {
+	module := os.Getenv("STASH_TEST_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("STASH_TEST_PKCS11_MODULE not set")
+	}
+	cfg := PKCS11Config{Module: module, Token: "stash", PIN: "1234", KeyLabel: "stash-kek"}
+	w, err := NewPKCS11(cfg)
+	require.NoError(t, err)
+	defer w.Close()
+
+	wrapped, err := w.Wrap([]byte("test-master-key-1234"))
+	require.NoError(t, err)
+	key, err := w.Unwrap(wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "test-master-key-1234", string(key))
+
+	wrapped[len(wrapped)-1] ^= 0xff
+	_, err = w.Unwrap(wrapped)
+	require.Error(t, err)
+
+	_, err = NewPKCS11(PKCS11Config{Module: module, Token: "stash", PIN: "1234", KeyLabel: "missing"})
+	require.ErrorContains(t, err, `key "missing" not found on token`)
+}
@@ -0,0 +1,73 @@
+package kek
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// Software wraps keys with a KEK held in process memory. It's the fallback for hosts without an HSM
+// and keeps the same wrapped format, so moving to an HSM only means re-wrapping the master key.
+type Software struct {
+	aead cipher.AEAD
+}
+
+// NewSoftware creates a software wrapper from a 32-byte KEK.
+func NewSoftware(kek []byte) (*Software, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("kek must be 32 bytes, got %d", len(kek))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return &Software{aead: aead}, nil
+}
+
+// LoadSoftware creates a software wrapper from a KEK file with 32 raw bytes or 64 hex characters,
+// e.g. made by "openssl rand -hex 32 > kek".
+func LoadSoftware(path string) (*Software, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the operator
+	if err != nil {
+		return nil, fmt.Errorf("read kek file: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 64 {
+		if decoded, decErr := hex.DecodeString(string(trimmed)); decErr == nil {
+			data = decoded
+		}
+	}
+	return NewSoftware(data)
+}
+
+// Wrap encrypts the key.
+func (s *Software) Wrap(key []byte) ([]byte, error) {
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("generate iv: %w", err)
+	}
+	return encodeWrapped(iv, s.aead.Seal(nil, iv, key, nil)), nil
+}
+
+// Unwrap decrypts a key wrapped with the same KEK.
+func (s *Software) Unwrap(wrapped []byte) ([]byte, error) {
+	iv, ciphertext, err := decodeWrapped(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.aead.Open(nil, iv, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key, wrong kek or corrupted data: %w", err)
+	}
+	return key, nil
+}
+
+// Close is a no-op, present for symmetry with the PKCS#11 wrapper.
+func (s *Software) Close() error { return nil }
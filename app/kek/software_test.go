@@ -0,0 +1,88 @@
+package kek
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftware(t *testing.T) {
+	w, err := NewSoftware(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+
+	wrapped, err := w.Wrap([]byte("test-master-key-1234"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(wrapVersion), wrapped[0])
+	assert.NotContains(t, string(wrapped), "test-master-key-1234")
+
+	again, err := w.Wrap([]byte("test-master-key-1234"))
+	require.NoError(t, err)
+	assert.NotEqual(t, wrapped, again, "random iv per wrap")
+
+	key, err := w.Unwrap(wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "test-master-key-1234", string(key))
+	require.NoError(t, w.Close())
+
+	t.Run("wrong kek", func(t *testing.T) {
+		other, err := NewSoftware(bytes.Repeat([]byte{2}, 32))
+		require.NoError(t, err)
+		_, err = other.Unwrap(wrapped)
+		require.ErrorContains(t, err, "wrong kek or corrupted data")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		bad := bytes.Clone(wrapped)
+		bad[len(bad)-1] ^= 0xff
+		_, err := w.Unwrap(bad)
+		require.ErrorContains(t, err, "wrong kek or corrupted data")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := w.Unwrap([]byte{wrapVersion, 1, 2})
+		require.EqualError(t, err, "wrapped key is too short")
+		bad := bytes.Clone(wrapped)
+		bad[0] = 9
+		_, err = w.Unwrap(bad)
+		require.EqualError(t, err, "unsupported wrapped key version 9")
+	})
+
+	t.Run("invalid kek size", func(t *testing.T) {
+		_, err := NewSoftware([]byte("short"))
+		require.EqualError(t, err, "kek must be 32 bytes, got 5")
+	})
+}
+
+func TestLoadSoftware(t *testing.T) {
+	dir := t.TempDir()
+	raw := bytes.Repeat([]byte{7}, 32)
+
+	rawFile := filepath.Join(dir, "raw")
+	require.NoError(t, os.WriteFile(rawFile, raw, 0o600))
+	hexFile := filepath.Join(dir, "hex")
+	require.NoError(t, os.WriteFile(hexFile, []byte(hex.EncodeToString(raw)+"\n"), 0o600))
+
+	fromRaw, err := LoadSoftware(rawFile)
+	require.NoError(t, err)
+	fromHex, err := LoadSoftware(hexFile)
+	require.NoError(t, err)
+
+	wrapped, err := fromRaw.Wrap([]byte("test-master-key-1234"))
+	require.NoError(t, err)
+	key, err := fromHex.Unwrap(wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "test-master-key-1234", string(key), "raw and hex files hold the same kek")
+
+	_, err = LoadSoftware(filepath.Join(dir, "missing"))
+	require.ErrorContains(t, err, "read kek file")
+
+	badFile := filepath.Join(dir, "bad")
+	require.NoError(t, os.WriteFile(badFile, []byte("not a key"), 0o600))
+	_, err = LoadSoftware(badFile)
+	require.ErrorContains(t, err, "kek must be 32 bytes")
+}
@@ -1,23 +1,37 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
 	"github.com/jessevdk/go-flags"
 
+	"github.com/umputun/stash/app/bundle"
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/kek"
 	"github.com/umputun/stash/app/server"
+	"github.com/umputun/stash/app/server/alert"
+	"github.com/umputun/stash/app/server/audit"
 	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/bridge"
+	"github.com/umputun/stash/app/server/seal"
 	"github.com/umputun/stash/app/server/sse"
 	"github.com/umputun/stash/app/store"
 	"github.com/umputun/stash/app/validator"
@@ -27,29 +41,48 @@ var opts struct {
 	DB string `short:"d" long:"db" env:"STASH_DB" default:"stash.db" description:"database URL (sqlite file or postgres://...)"`
 
 	Git struct {
-		Enabled bool   `long:"enabled" env:"ENABLED" description:"enable git tracking"`
-		Path    string `long:"path" env:"PATH" default:".history" description:"git repository path"`
-		Branch  string `long:"branch" env:"BRANCH" default:"master" description:"git branch"`
-		Remote  string `long:"remote" env:"REMOTE" description:"git remote name (optional)"`
-		Push    bool   `long:"push" env:"PUSH" description:"auto-push after commits"`
-		SSHKey  string `long:"ssh-key" env:"SSH_KEY" description:"SSH private key path for git push"`
+		Enabled      bool   `long:"enabled" env:"ENABLED" description:"enable git tracking"`
+		Path         string `long:"path" env:"PATH" default:".history" description:"git repository path"`
+		Branch       string `long:"branch" env:"BRANCH" default:"master" description:"git branch"`
+		Remote       string `long:"remote" env:"REMOTE" description:"git remote name (optional)"`
+		Push         bool   `long:"push" env:"PUSH" description:"auto-push after commits"`
+		SSHKey       string `long:"ssh-key" env:"SSH_KEY" description:"SSH private key path for git push"`
+		LFSThreshold int    `long:"lfs-threshold" env:"LFS_THRESHOLD" description:"values larger than this many bytes are committed as git-lfs pointers (0 disables)"`
 	} `group:"git" namespace:"git" env-namespace:"STASH_GIT"`
 
+	History struct {
+		Revisions   int      `long:"revisions" env:"REVISIONS" description:"previous values kept per key in the database, for history and rollback without git (0 disables)"`
+		Hide        []string `long:"hide" env:"HIDE" env-delim:"," description:"key or prefix with * suffix, history shown to admins only (can be repeated)"`
+		HideSecrets bool     `long:"hide-secrets" env:"HIDE_SECRETS" description:"history of secret keys shown to admins only"`
+	} `group:"history" namespace:"history" env-namespace:"STASH_HISTORY"`
+
 	Server struct {
 		Address         string        `long:"address" env:"ADDRESS" default:":8080" description:"server listen address"`
+		GRPCAddress     string        `long:"grpc-address" env:"GRPC_ADDRESS" description:"listen address of the gRPC API, disabled if empty"`
 		ReadTimeout     time.Duration `long:"read-timeout" env:"READ_TIMEOUT" default:"5s" description:"read timeout"`
 		WriteTimeout    time.Duration `long:"write-timeout" env:"WRITE_TIMEOUT" default:"30s" description:"write timeout"`
 		IdleTimeout     time.Duration `long:"idle-timeout" env:"IDLE_TIMEOUT" default:"30s" description:"idle timeout"`
 		ShutdownTimeout time.Duration `long:"shutdown-timeout" env:"SHUTDOWN_TIMEOUT" default:"5s" description:"shutdown timeout"`
 		BaseURL         string        `long:"base-url" env:"BASE_URL" description:"base URL path for reverse proxy (e.g., /stash)"`
 		PageSize        int           `long:"page-size" env:"PAGE_SIZE" default:"50" description:"keys per page, 0 to disable"`
+		FrameAncestors  []string      `long:"frame-ancestors" env:"FRAME_ANCESTORS" env-delim:"," description:"origin allowed to embed the web UI in a frame, e.g. a portal (can be repeated)"`
+		TLSCert         string        `long:"tls-cert" env:"TLS_CERT" description:"TLS certificate file, enables HTTPS"`
+		TLSKey          string        `long:"tls-key" env:"TLS_KEY" description:"TLS key file"`
+		SSECoalesce     time.Duration `long:"sse-coalesce" env:"SSE_COALESCE" description:"coalesce key change events within this window before sending to subscribers (0 disables)"`
+		Environments    []string      `long:"env" env:"ENV" env-delim:"," description:"environment of keys selected with ?env=, as name or name:base inheriting unset keys from base (can be repeated)"`
+		Variants        bool          `long:"variants" env:"VARIANTS" description:"serve A/B variants of values targeted by caller subject and attributes"`
+		ExpiryInterval  time.Duration `long:"expiry-interval" env:"EXPIRY_INTERVAL" default:"1m" description:"how often keys past their TTL are deleted (0 disables)"`
 	} `group:"server" namespace:"server" env-namespace:"STASH_SERVER"`
 
 	Limits struct {
 		BodySize         int64   `long:"body-size" env:"BODY_SIZE" default:"1048576" description:"max body size in bytes"`
 		RequestsPerSec   float64 `long:"requests-per-sec" env:"REQUESTS_PER_SEC" default:"100" description:"max requests per second (rate limit)"`
+		TokenRequests    float64 `long:"token-requests-per-sec" env:"TOKEN_REQUESTS_PER_SEC" description:"max requests per second per API token or web session (0 disables)"`
+		TokenBurst       int     `long:"token-burst" env:"TOKEN_BURST" description:"requests an API token or web session can make at once (default: token-requests-per-sec)"`
 		MaxConcurrent    int64   `long:"max-concurrent" env:"MAX_CONCURRENT" default:"1000" description:"max concurrent in-flight requests"`
 		LoginConcurrency int64   `long:"login-concurrency" env:"LOGIN_CONCURRENCY" default:"5" description:"max concurrent logins"`
+		ShedLow          float64 `long:"shed-low" env:"SHED_LOW" default:"0.5" description:"share of max-concurrent for key lists, exports and imports"`
+		ShedAPI          float64 `long:"shed-api" env:"SHED_API" default:"0.8" description:"share of max-concurrent for API requests, the rest is kept for the web UI"`
 	} `group:"limits" namespace:"limits" env-namespace:"STASH_LIMITS"`
 
 	Cache struct {
@@ -58,21 +91,118 @@ var opts struct {
 	} `group:"cache" namespace:"cache" env-namespace:"STASH_CACHE"`
 
 	Auth struct {
-		File      string        `long:"file" env:"FILE" description:"path to auth config file (stash-auth.yml)"`
-		LoginTTL  time.Duration `long:"login-ttl" env:"LOGIN_TTL" default:"720h" description:"login session TTL"`
-		HotReload bool          `long:"hot-reload" env:"HOT_RELOAD" description:"watch auth config for changes and reload"`
+		File         string        `long:"file" env:"FILE" description:"path to auth config file (stash-auth.yml)"`
+		LoginTTL     time.Duration `long:"login-ttl" env:"LOGIN_TTL" default:"12h" description:"max login session lifetime"`
+		SessionIdle  time.Duration `long:"session-idle" env:"SESSION_IDLE" default:"2h" description:"session idle timeout, renewed on activity (0 for fixed login-ttl sessions)"`
+		RememberTTL  time.Duration `long:"remember-ttl" env:"REMEMBER_TTL" default:"720h" description:"max lifetime of \"remember me\" sessions (0 to disable remember me)"`
+		RememberIdle time.Duration `long:"remember-idle" env:"REMEMBER_IDLE" default:"168h" description:"idle timeout of \"remember me\" sessions (0 for fixed remember-ttl sessions)"`
+		HotReload    bool          `long:"hot-reload" env:"HOT_RELOAD" description:"watch auth config for changes and reload"`
+		OwnerDelete  bool          `long:"owner-delete" env:"OWNER_DELETE" description:"only key owners and admins can delete keys with a recorded owner"`
+		Location     string        `long:"location-header" env:"LOCATION_HEADER" description:"header with approximate client location set by a trusted proxy, e.g. CF-IPCountry"`
+		StaleTokens  time.Duration `long:"stale-token-age" env:"STALE_TOKEN_AGE" default:"2160h" description:"report api tokens unused for this long as stale"`
+		Signed       bool          `long:"signed-requests" env:"SIGNED_REQUESTS" description:"accept requests signed with HMAC of a named token instead of carrying it"`
+		SignedSkew   time.Duration `long:"signed-skew" env:"SIGNED_SKEW" default:"5m" description:"max difference of signed request timestamps from server time"`
+
+		Notify struct {
+			SMTPHost string `long:"smtp-host" env:"SMTP_HOST" description:"SMTP server emailing users on login from a new device, enables notifications"`
+			SMTPPort int    `long:"smtp-port" env:"SMTP_PORT" default:"587" description:"SMTP server port"`
+			Username string `long:"username" env:"USERNAME" description:"SMTP auth username"`
+			Password string `long:"password" env:"PASSWORD" description:"SMTP auth password"`
+			From     string `long:"from" env:"FROM" description:"sender address of login notifications"`
+		} `group:"notify" namespace:"notify" env-namespace:"NOTIFY"`
+
+		Passkey struct {
+			Origin       string `long:"origin" env:"ORIGIN" description:"web UI origin as seen by browsers, e.g. https://stash.example.com, enables passkeys"`
+			RPID         string `long:"rp-id" env:"RP_ID" description:"WebAuthn relying party ID (default: origin host)"`
+			SecondFactor bool   `long:"second-factor" env:"SECOND_FACTOR" description:"passkeys confirm password logins instead of replacing passwords"`
+		} `group:"passkey" namespace:"passkey" env-namespace:"PASSKEY"`
+
+		Exchange struct {
+			Enabled bool          `long:"enabled" env:"ENABLED" description:"enable exchange of named tokens for short-lived child tokens"`
+			Secret  string        `long:"secret" env:"SECRET" description:"secret signing exchanged tokens (random if not set, tokens don't survive restart)"`
+			MaxTTL  time.Duration `long:"max-ttl" env:"MAX_TTL" default:"1h" description:"max lifetime of exchanged tokens"`
+		} `group:"exchange" namespace:"exchange" env-namespace:"EXCHANGE"`
+
+		SPIFFE struct {
+			TrustDomain string `long:"trust-domain" env:"TRUST_DOMAIN" description:"SPIFFE trust domain of workloads authenticated with mTLS"`
+			Bundle      string `long:"bundle" env:"BUNDLE" description:"SPIFFE trust bundle file with PEM CA certificates verifying workload SVIDs"`
+		} `group:"spiffe" namespace:"spiffe" env-namespace:"SPIFFE"`
+
+		Cloud struct {
+			AWS            bool   `long:"aws" env:"AWS" description:"enable AWS IAM login with signed sts:GetCallerIdentity requests"`
+			STSURL         string `long:"sts-url" env:"STS_URL" default:"https://sts.amazonaws.com/" description:"STS endpoint AWS login requests must target"`
+			ServerID       string `long:"server-id" env:"SERVER_ID" description:"value of X-Stash-Server-Id header AWS login requests must sign, required with --auth.cloud.aws; guards against replay of requests signed for other services"`
+			GCPAudience    string `long:"gcp-audience" env:"GCP_AUDIENCE" description:"audience of GCP identity tokens, enables GCP service account login"`
+			GitHubAudience string `long:"github-audience" env:"GITHUB_AUDIENCE" description:"audience of GitHub Actions OIDC tokens, enables workflow login"`
+		} `group:"cloud" namespace:"cloud" env-namespace:"CLOUD"`
+
+		Policy struct {
+			URL      string        `long:"url" env:"URL" description:"OPA decision URL deciding access to keys, e.g. http://localhost:8181/v1/data/stash/allow"`
+			Timeout  time.Duration `long:"timeout" env:"TIMEOUT" default:"2s" description:"timeout of policy decisions, failed decisions deny"`
+			CacheTTL time.Duration `long:"cache-ttl" env:"CACHE_TTL" default:"5s" description:"reuse policy decisions for this long (0 disables caching)"`
+		} `group:"policy" namespace:"policy" env-namespace:"POLICY"`
 	} `group:"auth" namespace:"auth" env-namespace:"STASH_AUTH"`
 
 	Secrets struct {
-		Key string `long:"key" env:"KEY" description:"master key for encrypting secrets (min 16 chars)"`
+		Key        string   `long:"key" env:"KEY" description:"master key for encrypting secrets (min 16 chars)"`
+		PrefixKeys []string `long:"prefix-key" env:"PREFIX_KEY" env-delim:"," description:"extra key for secrets under a prefix as prefix:id:key, on top of the master key (can be repeated)"`
+		Sealed     bool     `long:"sealed" env:"SEALED" description:"start sealed, the master key is reconstructed from unseal shares posted to /unseal"`
+		WrappedKey string   `long:"wrapped-key" env:"WRAPPED_KEY" description:"master key wrapped by the KEK (base64, see wrap-key command), instead of --secrets.key"`
+		EncryptAll bool     `long:"encrypt-all" env:"ENCRYPT_ALL" description:"encrypt values of all keys at rest, not only secrets, with the data key or the master key"`
+		DataKey    string   `long:"data-key" env:"DATA_KEY" description:"separate key for values of non-secret keys encrypted at rest (min 16 chars), enables --secrets.encrypt-all"`
+
+		KEK struct {
+			Provider string `long:"provider" env:"PROVIDER" default:"software" choice:"software" choice:"pkcs11" description:"KEK provider"`
+			File     string `long:"file" env:"FILE" description:"software KEK file, 32 raw bytes or 64 hex chars"`
+			Module   string `long:"module" env:"MODULE" description:"PKCS#11 module path"`
+			Token    string `long:"token" env:"TOKEN" description:"PKCS#11 token label"`
+			PIN      string `long:"pin" env:"PIN" description:"PKCS#11 user PIN"`
+			KeyLabel string `long:"key-label" env:"KEY_LABEL" default:"stash-kek" description:"PKCS#11 label of the AES key used as KEK"`
+		} `group:"kek" namespace:"kek" env-namespace:"KEK"`
 	} `group:"secrets" namespace:"secrets" env-namespace:"STASH_SECRETS"`
 
 	Audit struct {
 		Enabled    bool          `long:"enabled" env:"ENABLED" description:"enable audit logging"`
 		Retention  time.Duration `long:"retention" env:"RETENTION" default:"2160h" description:"audit log retention period (default 90d)"`
 		QueryLimit int           `long:"query-limit" env:"QUERY_LIMIT" default:"10000" description:"max entries per audit query"`
+		Justify    []string      `long:"justify" env:"JUSTIFY" env-delim:"," description:"key or prefix with * suffix, access requires a reason (can be repeated)"`
 	} `group:"audit" namespace:"audit" env-namespace:"STASH_AUDIT"`
 
+	Alert struct {
+		Webhook     string        `long:"webhook" env:"WEBHOOK" description:"alerting webhook URL (optional for pagerduty and opsgenie)"`
+		Format      string        `long:"format" env:"FORMAT" default:"json" choice:"json" choice:"pagerduty" choice:"opsgenie" description:"webhook payload format"`
+		Key         string        `long:"key" env:"KEY" description:"PagerDuty routing key or Opsgenie API key"`
+		DeniedLimit int           `long:"denied-limit" env:"DENIED_LIMIT" default:"20" description:"alert on this many denied requests from one actor per window (0 disables)"`
+		SecretReads int           `long:"secret-reads" env:"SECRET_READS" default:"50" description:"alert on this many secret reads by one actor per window (0 disables)"`
+		AdminNewIP  bool          `long:"admin-new-ip" env:"ADMIN_NEW_IP" description:"alert when admin credentials are used from a new IP"`
+		Window      time.Duration `long:"window" env:"WINDOW" default:"1m" description:"sliding window for counting rules"`
+		Cooldown    time.Duration `long:"cooldown" env:"COOLDOWN" default:"10m" description:"min interval between alerts for the same rule and actor"`
+		MaxAttempts int           `long:"max-attempts" env:"MAX_ATTEMPTS" default:"10" description:"delivery attempts before an alert moves to dead letters"`
+		Canary      []string      `long:"canary" env:"CANARY" env-delim:"," description:"canary key or prefix with * suffix, any read is alerted (can be repeated)"`
+	} `group:"alert" namespace:"alert" env-namespace:"STASH_ALERT"`
+
+	Bridge struct {
+		NATS        string `long:"nats" env:"NATS" description:"NATS server URL to forward key change events to (nats:// or tls://)"`
+		NATSSubject string `long:"nats-subject" env:"NATS_SUBJECT" default:"stash.changes" description:"NATS subject prefix, key segments are appended as tokens"`
+		Kafka       string `long:"kafka" env:"KAFKA" description:"Kafka REST proxy URL to forward key change events to"`
+		KafkaTopic  string `long:"kafka-topic" env:"KAFKA_TOPIC" default:"stash-changes" description:"Kafka topic for key change events"`
+		MQTT        string `long:"mqtt" env:"MQTT" description:"MQTT broker URL to publish retained key change events to (mqtt:// or mqtts://)"`
+		MQTTTopic   string `long:"mqtt-topic" env:"MQTT_TOPIC" default:"stash" description:"MQTT topic prefix, the key path is appended"`
+		Format      string `long:"format" env:"FORMAT" default:"json" choice:"json" choice:"cloudevents" description:"event serialization"`
+	} `group:"bridge" namespace:"bridge" env-namespace:"STASH_BRIDGE"`
+
+	CDN struct {
+		Provider string `long:"provider" env:"PROVIDER" choice:"cloudflare" choice:"fastly" description:"CDN to purge cached public keys from when they change"`
+		BaseURL  string `long:"base-url" env:"BASE_URL" description:"public stash URL served by the CDN, e.g. https://config.example.com"`
+		Zone     string `long:"zone" env:"ZONE" description:"Cloudflare zone id"`
+		Token    string `long:"token" env:"TOKEN" description:"Cloudflare API token or Fastly API key"`
+	} `group:"cdn" namespace:"cdn" env-namespace:"STASH_CDN"`
+
+	DebugOpts struct {
+		FaultInjection []string `long:"fault-injection" env:"FAULT_INJECTION" env-delim:";" description:"inject faults into requests of a route for testing clients, as /route:param=value,... (can be repeated, never in production)"`
+		Profiler       bool     `long:"profiler" env:"PROFILER" description:"serve pprof and expvar under /debug/ to loopback clients, for leak hunting and soak tests"`
+	} `group:"debug" namespace:"debug" env-namespace:"STASH_DEBUG"`
+
 	ServerCmd struct {
 	} `command:"server" description:"run the stash server"`
 
@@ -80,14 +210,151 @@ var opts struct {
 		Rev string `long:"rev" required:"true" description:"git revision to restore (commit/tag/branch)"`
 	} `command:"restore" description:"restore database from a git revision"`
 
+	RekeyCmd struct {
+	} `command:"rekey" description:"re-encrypt secrets with the active prefix keys"`
+
+	SplitKeyCmd struct {
+		Shares    int `long:"shares" default:"5" description:"number of unseal shares to generate"`
+		Threshold int `long:"threshold" default:"3" description:"number of shares required to unseal"`
+	} `command:"split-key" description:"split the secrets master key into unseal shares for sealed mode"`
+
+	WrapKeyCmd struct {
+	} `command:"wrap-key" description:"wrap the secrets master key with the KEK for --secrets.wrapped-key"`
+
+	GCCmd struct {
+		Unread time.Duration `long:"unread" default:"8760h" description:"report ZK-encrypted keys not updated and not read for this long"`
+		Delete bool          `long:"delete" description:"delete reported keys after confirmation"`
+		Yes    bool          `long:"yes" description:"don't ask for confirmation with --delete"`
+	} `command:"gc" description:"report (and optionally delete) empty keys and stale ZK-encrypted keys"`
+
+	DBCmd struct {
+		StatsCmd struct {
+			Depth  int `long:"depth" default:"1" description:"number of key folders forming a prefix"`
+			Top    int `long:"top" default:"20" description:"number of largest prefixes to show"`
+			Months int `long:"months" default:"12" description:"months ahead for the projected size"`
+		} `command:"stats" description:"show value size histogram, per-prefix totals, growth and projected size"`
+	} `command:"db" description:"database maintenance"`
+
+	GitCmd struct {
+		VerifyCmd struct {
+		} `command:"verify" description:"scan all revisions for secrets committed in plaintext"`
+	} `command:"git" description:"git history maintenance"`
+
+	AdminCmd struct {
+		EncryptAllCmd struct {
+		} `command:"encrypt-all" description:"encrypt values stored in plaintext before --secrets.encrypt-all was enabled"`
+	} `command:"admin" description:"administrative maintenance"`
+
+	DoctorCmd struct {
+		TimeURL string `long:"time-url" default:"https://www.google.com" description:"http server whose Date header the local clock is compared with, empty to skip"`
+	} `command:"doctor" description:"check database, git repo, auth config, secrets key, clock and listen address of the server config"`
+
+	DevCmd struct {
+		Address   string `long:"address" default:"127.0.0.1:8080" description:"listen address, local only by default as auth is off"`
+		WriteBack bool   `long:"write-back" description:"write keys changed through the API and web UI back to the files"`
+		Args      struct {
+			Dir string `positional-arg-name:"dir" required:"yes" description:"directory with key files, app/db.json is the json key app/db"`
+		} `positional-args:"yes"`
+	} `command:"dev" description:"run an in-memory server without auth, seeded from a directory of key files"`
+
+	ValidateCmd struct {
+		Policy string `long:"policy" description:"validation policy file with key naming, size, format and schema rules"`
+		Args   struct {
+			Bundle string `positional-arg-name:"bundle" required:"yes" description:"export bundle file (json)"`
+		} `positional-args:"yes"`
+	} `command:"validate" description:"check an export bundle offline against formats and a validation policy"`
+
+	ScanCmd struct {
+		clientOptions
+		Patterns []string `long:"pattern" required:"true" description:"regexp of string literals referencing keys, the first group is the key if any (can be repeated)"`
+		Prefix   []string `long:"prefix" description:"report unreferenced server keys only under this prefix (can be repeated)"`
+		Args     struct {
+			Paths []string `positional-arg-name:"path" description:"files and directories to scan, recursively (default: .)"`
+		} `positional-args:"yes"`
+	} `command:"scan" description:"find key references in source code and cross-check them with the server"`
+
+	FSCmd struct {
+		clientOptions
+
+		SyncCmd struct {
+			Prefix string `long:"prefix" description:"key prefix mapped to the directory, e.g. app/ (default: all keys)"`
+			Dir    string `long:"dir" required:"true" description:"local directory, app/db.json is the json key <prefix>app/db"`
+			Mode   string `long:"mode" default:"pull" choice:"pull" choice:"push" choice:"watch" description:"pull keys to files, push files to keys, or watch both and sync changes"`
+			Delete bool   `long:"delete" description:"delete keys and files missing on the other side"`
+			DryRun bool   `long:"dry-run" description:"report changes without making them, pull and push only"`
+		} `command:"sync" description:"sync keys under a prefix with files of a local directory"`
+	} `command:"fs" description:"sync keys with the local filesystem"`
+
+	MountCmd struct {
+		clientOptions
+		Prefix     string        `long:"prefix" description:"key prefix mounted as the root, e.g. app/ (default: all keys)"`
+		Writable   bool          `long:"writable" description:"allow writing, creating and removing keys through the files"`
+		AllowOther bool          `long:"allow-other" description:"allow access by other users, needs user_allow_other in /etc/fuse.conf unless root"`
+		CacheTTL   time.Duration `long:"cache-ttl" default:"1m" description:"kernel cache time of files, changes reported by the server invalidate it earlier"`
+		Args       struct {
+			Dir string `positional-arg-name:"dir" required:"yes" description:"mount point"`
+		} `positional-args:"yes"`
+	} `command:"mount" description:"mount keys as files of a FUSE filesystem, read-only by default"`
+
+	DockerSecretsCmd struct {
+		clientOptions
+		Prefix  string `long:"prefix" description:"key prefix of secrets, e.g. swarm/ (default: all keys)"`
+		Socket  string `long:"socket" default:"/run/docker/plugins/stash.sock" description:"plugin socket, its name is the driver name"`
+		NoReuse bool   `long:"no-reuse" description:"make docker get the value for every task instead of once per secret"`
+	} `command:"docker-secrets" description:"serve the Docker secret driver API for secrets created with --driver stash"`
+
+	AgentCmd struct {
+		clientOptions
+		Templates []string      `long:"template" required:"true" value-name:"SRC:DEST[:COMMAND]" description:"template rendered to the file, the command runs after the file changed (can be repeated)"`
+		Wait      time.Duration `long:"wait" default:"1s" description:"quiet period after a change before rendering, batches bursts of changes"`
+		PIDFile   string        `long:"pid-file" description:"pid file of the process signaled after files changed"`
+		Signal    string        `long:"signal" default:"HUP" description:"signal sent to the process of --pid-file"`
+		Once      bool          `long:"once" description:"render the templates once and exit"`
+	} `command:"agent" description:"render templates reading keys to files and keep them updated, like consul-template"`
+
+	KVCmd struct {
+		clientOptions
+
+		GetCmd struct {
+			Args struct {
+				Keys []string `positional-arg-name:"key" required:"1"`
+			} `positional-args:"yes"`
+		} `command:"get" description:"print values of the keys"`
+
+		SetCmd struct {
+			Format string `long:"format" default:"text" choice:"text" choice:"json" choice:"yaml" choice:"xml" choice:"toml" choice:"ini" choice:"hcl" choice:"shell" description:"value format"`
+			Args   struct {
+				Key   string `positional-arg-name:"key" required:"yes"`
+				Value string `positional-arg-name:"value" required:"yes" description:"value, - reads it from stdin"`
+			} `positional-args:"yes"`
+		} `command:"set" description:"set value of the key"`
+
+		LoginCmd struct {
+			GitHubOIDC string        `long:"github-oidc" required:"true" value-name:"AUDIENCE" description:"log in with the GitHub Actions OIDC token for the audience"`
+			TTL        time.Duration `long:"ttl" description:"token lifetime, server default if not set"`
+		} `command:"login" description:"log in with a workload identity and print a short-lived token"`
+	} `command:"kv" description:"read and write keys of a stash server"`
+
 	Debug   bool `long:"dbg" env:"DEBUG" description:"debug mode"`
 	Version bool `long:"version" description:"show version and exit"`
 }
 
+// clientOptions connect client commands to a stash server.
+type clientOptions struct {
+	URL       string        `long:"url" env:"STASH_URL" default:"http://localhost:8080" description:"stash server URL"`
+	Token     string        `long:"token" env:"STASH_TOKEN" description:"API token"`
+	TokenFile string        `long:"token-file" env:"STASH_TOKEN_FILE" description:"file with the API token, read again when it changes"`
+	ZKKey     string        `long:"zk-key" env:"STASH_ZK_KEY" description:"passphrase of zero-knowledge encrypted keys"`
+	Timeout   time.Duration `long:"timeout" default:"30s" description:"request timeout"`
+	Sign      bool          `long:"sign-requests" env:"STASH_SIGN_REQUESTS" description:"sign requests with the token instead of sending it"`
+}
+
 var revision = "unknown"
 
 func main() {
-	fmt.Printf("stash %s\n", revision)
+	if !isClientCommand(os.Args[1:]) {
+		fmt.Printf("stash %s\n", revision)
+	}
 
 	p := flags.NewParser(&opts, flags.Default)
 
@@ -104,6 +371,10 @@ func main() {
 	}
 
 	setupLogs(opts.Debug)
+	if isClientCommand(os.Args[1:]) {
+		// client commands print values and reports to stdout, keep it clean of logs
+		log.Setup(log.Msec, log.Out(os.Stderr), log.Err(io.Discard))
+	}
 
 	defer func() {
 		if x := recover(); x != nil {
@@ -121,6 +392,42 @@ func main() {
 		err = runServer(ctx)
 	case p.Active != nil && p.Find("restore") == p.Active:
 		err = runRestore(ctx)
+	case p.Active != nil && p.Find("rekey") == p.Active:
+		err = runRekey(ctx)
+	case p.Active != nil && p.Find("split-key") == p.Active:
+		err = runSplitKey()
+	case p.Active != nil && p.Find("wrap-key") == p.Active:
+		err = runWrapKey()
+	case p.Active != nil && p.Find("gc") == p.Active:
+		err = runGC(ctx, os.Stdin)
+	case p.Active != nil && p.Find("db") == p.Active && p.Active.Find("stats") == p.Active.Active:
+		err = runDBStats(ctx)
+	case p.Active != nil && p.Find("git") == p.Active && p.Active.Find("verify") == p.Active.Active:
+		err = runGitVerify(os.Stdout)
+	case p.Active != nil && p.Find("admin") == p.Active && p.Active.Find("encrypt-all") == p.Active.Active:
+		err = runAdminEncryptAll(ctx)
+	case p.Active != nil && p.Find("doctor") == p.Active:
+		err = runDoctor(ctx, os.Stdout)
+	case p.Active != nil && p.Find("dev") == p.Active:
+		err = runDev(ctx)
+	case p.Active != nil && p.Find("validate") == p.Active:
+		err = runValidate(os.Stdout)
+	case p.Active != nil && p.Find("scan") == p.Active:
+		err = runScan(ctx, os.Stdout)
+	case p.Active != nil && p.Find("fs") == p.Active && p.Active.Find("sync") == p.Active.Active:
+		err = runFSSync(ctx, os.Stdout)
+	case p.Active != nil && p.Find("mount") == p.Active:
+		err = runMount(ctx)
+	case p.Active != nil && p.Find("docker-secrets") == p.Active:
+		err = runDockerSecrets(ctx)
+	case p.Active != nil && p.Find("agent") == p.Active:
+		err = runAgent(ctx)
+	case p.Active != nil && p.Find("kv") == p.Active && p.Active.Find("get") == p.Active.Active:
+		err = runKVGet(ctx, os.Stdout)
+	case p.Active != nil && p.Find("kv") == p.Active && p.Active.Find("set") == p.Active.Active:
+		err = runKVSet(ctx, os.Stdin)
+	case p.Active != nil && p.Find("kv") == p.Active && p.Active.Find("login") == p.Active.Active:
+		err = runKVLogin(ctx, os.Stdout, os.Getenv)
 	default:
 		p.WriteHelp(os.Stderr)
 		os.Exit(2)
@@ -139,15 +446,20 @@ func runServer(ctx context.Context) error {
 	}
 	logServerConfig(baseURL)
 
-	// configure secrets encryption if key is provided
-	var storeOpts []store.Option
-	encryptor, encErr := initSecretsEncryptor(opts.Secrets.Key)
+	// configure secrets encryption if key is provided, or wait for unseal shares in sealed mode
+	sealer, err := initSealer()
+	if err != nil {
+		return err
+	}
+	storeOpts, encErr := secretsStoreOptions(sealer)
 	if encErr != nil {
 		return encErr
 	}
-	if encryptor != nil {
-		storeOpts = append(storeOpts, store.WithEncryptor(encryptor))
-		log.Printf("[INFO] secrets encryption enabled")
+	if opts.History.Revisions < 0 {
+		return errors.New("--history.revisions can't be negative")
+	}
+	if opts.History.Revisions > 0 {
+		storeOpts = append(storeOpts, store.WithHistory(opts.History.Revisions))
 	}
 
 	// initialize store - keep raw store reference for session operations
@@ -167,7 +479,7 @@ func runServer(ctx context.Context) error {
 	defer kvStore.Close()
 
 	// initialize git service if enabled
-	gitService, err := initGitService()
+	gitService, err := initGitService(rawStore)
 	if err != nil {
 		return err
 	}
@@ -177,15 +489,46 @@ func runServer(ctx context.Context) error {
 	if opts.Audit.Enabled {
 		auditStore = rawStore
 	}
+	if len(opts.Audit.Justify) > 0 && !opts.Audit.Enabled {
+		return errors.New("--audit.justify requires --audit.enabled, access reasons are recorded in the audit log")
+	}
+
+	if opts.Auth.OwnerDelete && opts.Auth.File == "" {
+		return errors.New("--auth.owner-delete requires --auth.file, owners are authenticated identities")
+	}
+
+	if (len(opts.History.Hide) > 0 || opts.History.HideSecrets) && opts.Auth.File == "" {
+		return errors.New("--history.hide and --history.hide-secrets require --auth.file, history is shown to admins only")
+	}
 
 	// initialize auth service if config file is provided
 	authSvc, err := initAuthService(ctx, rawStore)
 	if err != nil {
 		return err
 	}
+	clientCAs, err := spiffeTrustBundle()
+	if err != nil {
+		return err
+	}
 
 	// create SSE service for key change subscriptions
-	sseService := sse.New(authSvc)
+	sseService := sse.New(authSvc, sse.WithCoalesce(opts.Server.SSECoalesce))
+
+	alerts, outbox, err := initAlerts(rawStore)
+	if err != nil {
+		return err
+	}
+	if outbox != nil {
+		go outbox.Run(ctx)
+	}
+
+	eventBridge, err := initBridge(authSvc)
+	if err != nil {
+		return err
+	}
+	if eventBridge != nil {
+		go eventBridge.Run(ctx)
+	}
 
 	srv, err := server.New(
 		server.Deps{
@@ -194,10 +537,18 @@ func runServer(ctx context.Context) error {
 			Git:        gitService,
 			Auth:       authSvc,
 			AuditStore: auditStore,
+			PrefsStore: rawStore,
+			Records:    rawStore,
 			SSE:        sseService,
+			Alerts:     alerts,
+			Outbox:     outbox,
+			Sealer:     sealer,
+			Bridge:     eventBridge,
+			Freezes:    rawStore,
 		},
 		server.Config{
 			Address:          opts.Server.Address,
+			GRPCAddress:      opts.Server.GRPCAddress,
 			ReadTimeout:      opts.Server.ReadTimeout,
 			WriteTimeout:     opts.Server.WriteTimeout,
 			IdleTimeout:      opts.Server.IdleTimeout,
@@ -206,11 +557,30 @@ func runServer(ctx context.Context) error {
 			BaseURL:          baseURL,
 			BodySizeLimit:    opts.Limits.BodySize,
 			RequestsPerSec:   opts.Limits.RequestsPerSec,
+			TokenRequests:    opts.Limits.TokenRequests,
+			TokenBurst:       opts.Limits.TokenBurst,
 			MaxConcurrent:    opts.Limits.MaxConcurrent,
 			LoginConcurrency: opts.Limits.LoginConcurrency,
+			ShedLowShare:     opts.Limits.ShedLow,
+			ShedAPIShare:     opts.Limits.ShedAPI,
 			PageSize:         opts.Server.PageSize,
+			FrameAncestors:   opts.Server.FrameAncestors,
+			TLSCert:          opts.Server.TLSCert,
+			TLSKey:           opts.Server.TLSKey,
+			ClientCAs:        clientCAs,
 			AuditEnabled:     opts.Audit.Enabled,
 			AuditQueryLimit:  opts.Audit.QueryLimit,
+			Canaries:         opts.Alert.Canary,
+			Justify:          opts.Audit.Justify,
+			OwnerDelete:      opts.Auth.OwnerDelete,
+			Environments:     opts.Server.Environments,
+			Variants:         opts.Server.Variants,
+			History:          opts.History.Revisions > 0,
+			HistoryHide:      opts.History.Hide,
+			HistorySecrets:   opts.History.HideSecrets,
+			FaultInjection:   opts.DebugOpts.FaultInjection,
+			Profiler:         opts.DebugOpts.Profiler,
+			ExpiryInterval:   opts.Server.ExpiryInterval,
 		})
 	if err != nil {
 		return fmt.Errorf("failed to initialize server: %w", err)
@@ -232,16 +602,88 @@ func runServer(ctx context.Context) error {
 	return nil
 }
 
+// runDev runs an in-memory server with auth disabled, seeded from the key files of a directory, so
+// developers can run against realistic config offline. Nothing survives the restart except files
+// written back with --write-back.
+func runDev(ctx context.Context) error {
+	dir := opts.DevCmd.Args.Dir
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	// secrets are kept in memory only, so a random key is enough to store keys under secrets/
+	enc, err := initSecretsEncryptor(rand.Text())
+	if err != nil {
+		return err
+	}
+	rawStore, err := store.New(":memory:", store.WithEncryptor(enc))
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer rawStore.Close()
+	files, err := store.LoadDir(ctx, rawStore, dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] loaded %d keys from %s", len(files), dir)
+
+	var kvStore store.Interface = rawStore
+	if opts.DevCmd.WriteBack {
+		kvStore = store.NewDirMirror(rawStore, dir, files)
+		log.Printf("[INFO] changes are written back to %s", dir)
+	}
+
+	srv, err := server.New(
+		server.Deps{Store: kvStore, Validator: validator.NewService(), PrefsStore: rawStore, SSE: sse.New(nil)},
+		server.Config{
+			Address:          opts.DevCmd.Address,
+			ReadTimeout:      opts.Server.ReadTimeout,
+			WriteTimeout:     opts.Server.WriteTimeout,
+			IdleTimeout:      opts.Server.IdleTimeout,
+			ShutdownTimeout:  opts.Server.ShutdownTimeout,
+			Version:          revision,
+			BodySizeLimit:    opts.Limits.BodySize,
+			RequestsPerSec:   opts.Limits.RequestsPerSec,
+			TokenRequests:    opts.Limits.TokenRequests,
+			TokenBurst:       opts.Limits.TokenBurst,
+			MaxConcurrent:    opts.Limits.MaxConcurrent,
+			LoginConcurrency: opts.Limits.LoginConcurrency,
+			ShedLowShare:     opts.Limits.ShedLow,
+			ShedAPIShare:     opts.Limits.ShedAPI,
+			PageSize:         opts.Server.PageSize,
+			Environments:     opts.Server.Environments,
+			Variants:         opts.Server.Variants,
+			FaultInjection:   opts.DebugOpts.FaultInjection,
+			ExpiryInterval:   opts.Server.ExpiryInterval,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to initialize server: %w", err)
+	}
+	log.Printf("[INFO] starting dev server on %s, auth disabled", opts.DevCmd.Address)
+	if err := srv.Run(ctx); err != nil {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
 func runRestore(ctx context.Context) error {
 	log.Printf("[INFO] restoring from revision %s", opts.RestoreCmd.Rev)
 	log.Printf("[INFO] git path: %s, db: %s", opts.Git.Path, opts.DB)
 
-	gitStore, err := git.New(git.Config{
-		Path:   opts.Git.Path,
-		Branch: opts.Git.Branch,
-		Remote: opts.Git.Remote,
-		SSHKey: opts.Git.SSHKey,
-	})
+	// configure secrets encryption if key is provided, git history has secrets encrypted with it
+	storeOpts, encErr := secretsStoreOptions(nil)
+	if encErr != nil {
+		return encErr
+	}
+
+	// initialize database store
+	kvStore, dbErr := store.New(opts.DB, storeOpts...)
+	if dbErr != nil {
+		return fmt.Errorf("failed to initialize store: %w", dbErr)
+	}
+	defer kvStore.Close()
+
+	gitStore, err := git.New(gitConfig(kvStore))
 	if err != nil {
 		return fmt.Errorf("failed to initialize git store: %w", err)
 	}
@@ -266,24 +708,6 @@ func runRestore(ctx context.Context) error {
 		return fmt.Errorf("failed to read keys from git: %w", readErr)
 	}
 
-	// configure secrets encryption if key is provided
-	var storeOpts []store.Option
-	encryptor, encErr := initSecretsEncryptor(opts.Secrets.Key)
-	if encErr != nil {
-		return encErr
-	}
-	if encryptor != nil {
-		storeOpts = append(storeOpts, store.WithEncryptor(encryptor))
-		log.Printf("[INFO] secrets encryption enabled")
-	}
-
-	// initialize database store
-	kvStore, dbErr := store.New(opts.DB, storeOpts...)
-	if dbErr != nil {
-		return fmt.Errorf("failed to initialize store: %w", dbErr)
-	}
-	defer kvStore.Close()
-
 	// clear all keys from database
 	existingKeys, listErr := kvStore.List(ctx, enum.SecretsFilterAll)
 	if listErr != nil {
@@ -311,6 +735,468 @@ func runRestore(ctx context.Context) error {
 	return nil
 }
 
+// runRekey re-encrypts secrets after prefix keys were added or rotated, see store.Rekey.
+func runRekey(ctx context.Context) error {
+	if len(opts.Secrets.PrefixKeys) == 0 {
+		log.Printf("[WARN] no prefix keys configured, secrets will be re-encrypted with the master key only")
+	}
+	storeOpts, err := secretsStoreOptions(nil)
+	if err != nil {
+		return err
+	}
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer kvStore.Close()
+
+	n, err := kvStore.Rekey(ctx)
+	if err != nil {
+		return fmt.Errorf("rekey failed after %d keys: %w", n, err)
+	}
+	log.Printf("[INFO] re-encrypted %d secrets", n)
+	fmt.Printf("re-encrypted %d secrets\n", n)
+	return nil
+}
+
+// runAdminEncryptAll encrypts values of non-secret keys written in plaintext before encryption at rest
+// was enabled, history included. The server encrypts them on the next write anyway, this does it at once.
+func runAdminEncryptAll(ctx context.Context) error {
+	if !opts.Secrets.EncryptAll && opts.Secrets.DataKey == "" {
+		return errors.New("encryption at rest is not enabled, set --secrets.encrypt-all or --secrets.data-key")
+	}
+	storeOpts, err := secretsStoreOptions(nil)
+	if err != nil {
+		return err
+	}
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer kvStore.Close()
+
+	n, err := kvStore.EncryptAll(ctx)
+	if err != nil {
+		return fmt.Errorf("encrypt-all failed after %d keys: %w", n, err)
+	}
+	log.Printf("[INFO] encrypted %d keys at rest", n)
+	fmt.Printf("encrypted %d keys\n", n)
+	return nil
+}
+
+// runGC reports keys with empty values and ZK-encrypted keys nobody updated or read since the --unread cutoff.
+// With --delete reported keys are removed after the confirmation read from in, or without it with --yes.
+func runGC(ctx context.Context, in io.Reader) error {
+	storeOpts, err := secretsStoreOptions(nil)
+	if err != nil {
+		return err
+	}
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer kvStore.Close()
+
+	cutoff := time.Now().Add(-opts.GCCmd.Unread)
+	report, err := kvStore.GCReport(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to build gc report: %w", err)
+	}
+	writeGCReport(os.Stdout, report, cutoff)
+	keys := make([]string, 0, len(report.Empty)+len(report.StaleZK))
+	for _, k := range append(report.Empty, report.StaleZK...) {
+		keys = append(keys, k.Key)
+	}
+	if !opts.GCCmd.Delete || len(keys) == 0 {
+		return nil
+	}
+
+	if !opts.GCCmd.Yes {
+		fmt.Printf("delete %d keys? [y/N]: ", len(keys))
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("nothing deleted")
+			return nil
+		}
+	}
+
+	gitSvc, err := initGitService(kvStore)
+	if err != nil {
+		return err
+	}
+	var deleted int
+	for _, key := range keys {
+		if delErr := kvStore.Delete(ctx, key); delErr != nil {
+			log.Printf("[WARN] failed to delete key %s: %v", key, delErr)
+			continue
+		}
+		deleted++
+		if gitSvc != nil {
+			if gitErr := gitSvc.Delete(key, git.DefaultAuthor()); gitErr != nil {
+				log.Printf("[WARN] failed to delete key %s from git: %v", key, gitErr)
+			}
+		}
+	}
+	log.Printf("[INFO] gc deleted %d keys", deleted)
+	fmt.Printf("deleted %d keys\n", deleted)
+	return nil
+}
+
+// writeGCReport prints the gc report, warning if the audit log doesn't cover the whole unread period.
+func writeGCReport(w io.Writer, report store.GCReport, cutoff time.Time) {
+	fmt.Fprintf(w, "empty keys: %d\n", len(report.Empty))
+	for _, k := range report.Empty {
+		fmt.Fprintf(w, "  %s\n", k.Key)
+	}
+	fmt.Fprintf(w, "zk keys not updated or read since %s: %d\n", cutoff.Format(time.DateOnly), len(report.StaleZK))
+	for _, k := range report.StaleZK {
+		fmt.Fprintf(w, "  %s (updated %s)\n", k.Key, k.UpdatedAt.Format(time.DateOnly))
+	}
+	switch {
+	case report.AuditSince.IsZero() && len(report.StaleZK) > 0:
+		fmt.Fprintln(w, "warning: audit log is empty, zk reads are unknown")
+	case report.AuditSince.After(cutoff) && len(report.StaleZK) > 0:
+		fmt.Fprintf(w, "warning: audit log starts at %s, zk reads before it are unknown\n",
+			report.AuditSince.Format(time.DateOnly))
+	}
+}
+
+// runDBStats prints database usage stats to guide quota and retention settings.
+func runDBStats(ctx context.Context) error {
+	kvStore, err := store.New(opts.DB)
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer kvStore.Close()
+
+	stats, err := kvStore.DBStats(ctx, opts.DBCmd.StatsCmd.Depth)
+	if err != nil {
+		return fmt.Errorf("failed to collect db stats: %w", err)
+	}
+	writeDBStats(os.Stdout, stats, opts.DBCmd.StatsCmd.Top, opts.DBCmd.StatsCmd.Months)
+	return nil
+}
+
+// runGitVerify prints secrets committed to the git history in plaintext, one per line, and fails if any found.
+// No secrets key is needed, encrypted values are recognized by their marker.
+func runGitVerify(w io.Writer) error {
+	gitStore, err := git.New(git.Config{Path: opts.Git.Path, Branch: opts.Git.Branch})
+	if err != nil {
+		return fmt.Errorf("failed to initialize git store: %w", err)
+	}
+	found, err := gitStore.ScanSecrets()
+	if err != nil {
+		return err
+	}
+	for _, ps := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ps.Key, ps.Commit, ps.When.Format(time.RFC3339))
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%s: %d plaintext secret values in git history", opts.Git.Path, len(found))
+	}
+	fmt.Fprintln(w, "no plaintext secrets in git history")
+	return nil
+}
+
+// runValidate checks the export bundle and prints its problems, one per line. Any problem fails the command,
+// so a promotion pipeline stops before importing the bundle.
+func runValidate(w io.Writer) error {
+	var policy *bundle.Policy
+	if opts.ValidateCmd.Policy != "" {
+		var err error
+		if policy, err = bundle.LoadPolicy(opts.ValidateCmd.Policy); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(opts.ValidateCmd.Args.Bundle)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	b, err := bundle.Read(f)
+	if err != nil {
+		return err
+	}
+
+	problems := bundle.Validate(b, policy)
+	for _, pr := range problems {
+		fmt.Fprintln(w, pr)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d keys, %d problems", opts.ValidateCmd.Args.Bundle, len(b.Keys), len(problems))
+	}
+	fmt.Fprintf(w, "%s: %d keys, no problems\n", opts.ValidateCmd.Args.Bundle, len(b.Keys))
+	return nil
+}
+
+// writeDBStats prints db stats as aligned tables, with up to top prefixes.
+func writeDBStats(w io.Writer, stats store.DBStats, top, months int) {
+	fmt.Fprintf(w, "keys: %d, values: %s, database: %s\n", stats.Keys, formatBytes(stats.ValueBytes), formatBytes(stats.DBBytes))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\nvalue size\tkeys\tbytes\t")
+	for i, b := range stats.Sizes {
+		label := "<= " + formatBytes(b.Max)
+		switch {
+		case b.Max == 0:
+			label = "empty"
+		case b.Max == math.MaxInt64:
+			label = "> " + formatBytes(stats.Sizes[i-1].Max)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", label, b.Keys, formatBytes(b.Bytes))
+	}
+	_ = tw.Flush()
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nprefix\tkeys\tbytes")
+	for i, p := range stats.Prefixes {
+		if i == top {
+			fmt.Fprintf(tw, "(%d more)\n", len(stats.Prefixes)-top)
+			break
+		}
+		prefix := p.Prefix
+		if prefix == "" {
+			prefix = "(no folder)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", prefix, p.Keys, formatBytes(p.Bytes))
+	}
+	_ = tw.Flush()
+
+	if len(stats.Growth) == 0 {
+		fmt.Fprintln(w, "\nno growth data, the audit log is empty (see --audit.enabled)")
+		return
+	}
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nmonth\tcreated\tupdated\tdeleted\twritten")
+	for _, g := range stats.Growth {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", g.Month.Format("2006-01"), g.Created, g.Updated, g.Deleted, formatBytes(g.Written))
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\nprojected database size in %d months: %s\n", months, formatBytes(stats.Projected(months)))
+}
+
+// formatBytes formats a size with binary units, e.g. 1.5 KB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit && exp < 4; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// runSplitKey prints the secrets master key split into unseal shares, one per line.
+func runSplitKey() error {
+	key, err := secretsKey()
+	if err != nil {
+		return err
+	}
+	if len(key) < 16 {
+		return errors.New("secrets key of at least 16 characters is required to split")
+	}
+	shares, err := seal.Split([]byte(key), opts.SplitKeyCmd.Shares, opts.SplitKeyCmd.Threshold)
+	if err != nil {
+		return fmt.Errorf("failed to split key: %w", err)
+	}
+	log.Printf("[INFO] split secrets key into %d shares, %d required to unseal", len(shares), opts.SplitKeyCmd.Threshold)
+	for _, sh := range shares {
+		fmt.Println(sh)
+	}
+	return nil
+}
+
+// runWrapKey prints the secrets master key wrapped by the KEK, to be used as --secrets.wrapped-key.
+func runWrapKey() error {
+	if len(opts.Secrets.Key) < 16 {
+		return errors.New("secrets key of at least 16 characters is required to wrap")
+	}
+	wrapper, err := initKeyWrapper()
+	if err != nil {
+		return err
+	}
+	defer wrapper.Close()
+	wrapped, err := wrapper.Wrap([]byte(opts.Secrets.Key))
+	if err != nil {
+		return fmt.Errorf("failed to wrap key: %w", err)
+	}
+	log.Printf("[INFO] wrapped secrets key with %s kek", opts.Secrets.KEK.Provider)
+	fmt.Println(base64.StdEncoding.EncodeToString(wrapped))
+	return nil
+}
+
+// initSealer creates the sealer for sealed mode, nil if the server starts with the master key.
+// Sealed mode needs auth, as only admins can submit unseal shares.
+func initSealer() (*seal.Sealer, error) {
+	if !opts.Secrets.Sealed {
+		return nil, nil //nolint:nilnil // nil sealer is valid when not sealed
+	}
+	if opts.Secrets.Key != "" || opts.Secrets.WrappedKey != "" {
+		return nil, errors.New("secrets key must not be set in sealed mode")
+	}
+	if opts.Auth.File == "" {
+		return nil, errors.New("sealed mode requires auth, unseal shares are submitted by admins")
+	}
+	log.Printf("[INFO] starting sealed, secrets are unavailable until unsealed via POST /unseal")
+	return seal.New(func(key []byte) (store.Encryptor, error) {
+		enc, err := initSecretsEncryptor(string(key))
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	}), nil
+}
+
+// secretsStoreOptions returns store options for secrets encryption, nil if secrets are disabled.
+// With a sealer, secrets are enabled but fail with store.ErrSealed until unsealed.
+func secretsStoreOptions(sealer *seal.Sealer) ([]store.Option, error) {
+	var encryptor store.Encryptor
+	if sealer != nil {
+		encryptor = sealer
+	} else {
+		key, err := secretsKey()
+		if err != nil {
+			return nil, err
+		}
+		enc, err := initSecretsEncryptor(key)
+		if err != nil {
+			return nil, err
+		}
+		if enc != nil {
+			encryptor = enc
+		}
+	}
+	if encryptor == nil && len(opts.Secrets.PrefixKeys) > 0 {
+		return nil, errors.New("prefix keys require secrets key")
+	}
+	var storeOpts []store.Option
+	if encryptor != nil {
+		storeOpts = append(storeOpts, store.WithEncryptor(encryptor))
+		if sealer == nil {
+			log.Printf("[INFO] secrets encryption enabled")
+		}
+	}
+
+	if len(opts.Secrets.PrefixKeys) > 0 {
+		kr, err := initPrefixKeys(opts.Secrets.PrefixKeys)
+		if err != nil {
+			return nil, err
+		}
+		storeOpts = append(storeOpts, store.WithKeyring(kr))
+		log.Printf("[INFO] prefix encryption keys: %d", len(opts.Secrets.PrefixKeys))
+	}
+
+	dataEnc, err := initDataEncryptor(encryptor)
+	if err != nil {
+		return nil, err
+	}
+	if dataEnc != nil {
+		storeOpts = append(storeOpts, store.WithDataEncryptor(dataEnc))
+		log.Printf("[INFO] encryption at rest enabled for all keys")
+	}
+	return storeOpts, nil
+}
+
+// initDataEncryptor returns the encryptor of non-secret values with encryption at rest, the data key if set
+// or the secrets encryptor otherwise. Returns nil, nil if encryption at rest is off.
+func initDataEncryptor(secrets store.Encryptor) (store.Encryptor, error) {
+	switch {
+	case opts.Secrets.DataKey != "":
+		if len(opts.Secrets.DataKey) < 16 {
+			return nil, errors.New("data key must be at least 16 characters")
+		}
+		enc, err := store.NewCrypto([]byte(opts.Secrets.DataKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data encryptor: %w", err)
+		}
+		return enc, nil
+	case !opts.Secrets.EncryptAll:
+		return nil, nil //nolint:nilnil // nil encryptor is valid when encryption at rest is off
+	case secrets == nil:
+		return nil, errors.New("--secrets.encrypt-all requires --secrets.key or --secrets.data-key")
+	}
+	return secrets, nil
+}
+
+// keyWrapper wraps and unwraps the master key with a KEK, implemented by kek.Software and kek.PKCS11.
+type keyWrapper interface {
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+	Close() error
+}
+
+// initKeyWrapper creates the KEK provider selected by --secrets.kek.provider.
+func initKeyWrapper() (keyWrapper, error) {
+	switch opts.Secrets.KEK.Provider {
+	case "pkcs11":
+		w, err := kek.NewPKCS11(kek.PKCS11Config{Module: opts.Secrets.KEK.Module, Token: opts.Secrets.KEK.Token,
+			PIN: opts.Secrets.KEK.PIN, KeyLabel: opts.Secrets.KEK.KeyLabel})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pkcs11 kek: %w", err)
+		}
+		return w, nil
+	default:
+		if opts.Secrets.KEK.File == "" {
+			return nil, errors.New("software kek requires --secrets.kek.file")
+		}
+		w, err := kek.LoadSoftware(opts.Secrets.KEK.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kek: %w", err)
+		}
+		return w, nil
+	}
+}
+
+// secretsKey returns the master key, either set directly or unwrapped from --secrets.wrapped-key.
+// The wrapped form keeps the plain key out of config and environment, it exists only in memory after unwrap.
+func secretsKey() (string, error) {
+	if opts.Secrets.WrappedKey == "" {
+		return opts.Secrets.Key, nil
+	}
+	if opts.Secrets.Key != "" {
+		return "", errors.New("secrets key and wrapped key are mutually exclusive")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(strings.TrimSpace(opts.Secrets.WrappedKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped key, expected base64: %w", err)
+	}
+	wrapper, err := initKeyWrapper()
+	if err != nil {
+		return "", err
+	}
+	defer wrapper.Close()
+	key, err := wrapper.Unwrap(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap secrets key: %w", err)
+	}
+	log.Printf("[INFO] secrets key unwrapped with %s kek", opts.Secrets.KEK.Provider)
+	return string(key), nil
+}
+
+// initPrefixKeys creates a keyring from prefix:id:key specs, e.g. "secrets/payments/*:pay-2025:<key>".
+// The key part may contain colons.
+func initPrefixKeys(specs []string) (*store.Keyring, error) {
+	keys := make([]store.PrefixKey, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid prefix key for %q, expected prefix:id:key", parts[0]) // don't leak key material
+		}
+		if len(parts[2]) < 16 {
+			return nil, fmt.Errorf("prefix key %q must be at least 16 characters", parts[1])
+		}
+		keys = append(keys, store.PrefixKey{Prefix: parts[0], ID: parts[1], Key: []byte(parts[2])})
+	}
+	kr, err := store.NewKeyring(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prefix keys: %w", err)
+	}
+	return kr, nil
+}
+
 // initSecretsEncryptor creates a secrets encryptor from the given key.
 // Returns nil, nil if key is empty (secrets disabled).
 // Returns error if key is too short.
@@ -419,6 +1305,9 @@ func cleanupAudit(ctx context.Context, cleaner auditCleaner, retention time.Dura
 // logServerConfig logs startup configuration.
 func logServerConfig(baseURL string) {
 	log.Printf("[INFO] starting stash server on %s", opts.Server.Address)
+	if opts.Server.GRPCAddress != "" {
+		log.Printf("[INFO] grpc api on %s", opts.Server.GRPCAddress)
+	}
 	if baseURL != "" {
 		log.Printf("[INFO] base URL: %s", baseURL)
 	}
@@ -427,6 +1316,24 @@ func logServerConfig(baseURL string) {
 		if opts.Auth.HotReload {
 			log.Printf("[INFO] auth config hot-reload enabled")
 		}
+		if opts.Auth.OwnerDelete {
+			log.Printf("[INFO] owner-only delete enabled")
+		}
+		if opts.Auth.SPIFFE.TrustDomain != "" {
+			log.Printf("[INFO] spiffe workload identities enabled, trust domain: %s", opts.Auth.SPIFFE.TrustDomain)
+		}
+		if opts.Auth.Notify.SMTPHost != "" {
+			log.Printf("[INFO] new device login notifications enabled, smtp: %s:%d", opts.Auth.Notify.SMTPHost, opts.Auth.Notify.SMTPPort)
+		}
+		if opts.Auth.Passkey.Origin != "" {
+			log.Printf("[INFO] passkeys enabled, origin: %s, second factor: %v", opts.Auth.Passkey.Origin, opts.Auth.Passkey.SecondFactor)
+		}
+	}
+	if len(opts.DebugOpts.FaultInjection) > 0 {
+		log.Printf("[WARN] fault injection enabled, requests fail on purpose: %s", strings.Join(opts.DebugOpts.FaultInjection, "; "))
+	}
+	if opts.DebugOpts.Profiler {
+		log.Printf("[WARN] profiler enabled on /debug/ for loopback clients")
 	}
 	if opts.Git.Enabled {
 		log.Printf("[INFO] git tracking enabled, path: %s, branch: %s", opts.Git.Path, opts.Git.Branch)
@@ -434,34 +1341,191 @@ func logServerConfig(baseURL string) {
 	if opts.Cache.Enabled {
 		log.Printf("[INFO] cache enabled, max keys: %d", opts.Cache.MaxKeys)
 	}
+	if len(opts.Server.Environments) > 0 {
+		log.Printf("[INFO] environments: %s", strings.Join(opts.Server.Environments, ", "))
+	}
+	if opts.Server.Variants {
+		log.Printf("[INFO] value variants enabled")
+	}
 	if opts.Audit.Enabled {
 		log.Printf("[INFO] audit logging enabled, retention: %s", opts.Audit.Retention)
 	}
+	if len(opts.Audit.Justify) > 0 {
+		log.Printf("[INFO] keys requiring access reason: %s", strings.Join(opts.Audit.Justify, ", "))
+	}
+	if len(opts.History.Hide) > 0 {
+		log.Printf("[INFO] keys with history shown to admins only: %s", strings.Join(opts.History.Hide, ", "))
+	}
+	if opts.History.HideSecrets {
+		log.Printf("[INFO] history of secrets shown to admins only")
+	}
+	if opts.Alert.Webhook != "" || opts.Alert.Key != "" {
+		log.Printf("[INFO] alerts enabled, format: %s", opts.Alert.Format)
+	}
+	if len(opts.Alert.Canary) > 0 {
+		log.Printf("[INFO] canary keys: %s", strings.Join(opts.Alert.Canary, ", "))
+		if !opts.Audit.Enabled && opts.Alert.Webhook == "" && opts.Alert.Key == "" {
+			log.Printf("[WARN] canary keys have no effect without audit logging or alert webhook")
+		}
+	}
 }
 
-// initGitService creates git service if enabled.
-func initGitService() (server.GitService, error) {
+// initAlerts creates the suspicious activity detector if an alerting webhook is configured.
+// Alerts are persisted in the outbox and delivered to the webhook with retries.
+func initAlerts(st alert.OutboxStore) (audit.Observer, *alert.Outbox, error) {
+	if opts.Alert.Webhook == "" && opts.Alert.Key == "" {
+		return nil, nil, nil
+	}
+	webhook, err := alert.NewWebhook(opts.Alert.Webhook, opts.Alert.Format, opts.Alert.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize alert webhook: %w", err)
+	}
+	outbox := alert.NewOutbox(st, webhook, alert.OutboxConfig{MaxAttempts: opts.Alert.MaxAttempts})
+	return alert.NewDetector(alert.Config{
+		DeniedLimit: opts.Alert.DeniedLimit,
+		SecretReads: opts.Alert.SecretReads,
+		AdminNewIP:  opts.Alert.AdminNewIP,
+		Window:      opts.Alert.Window,
+		Cooldown:    opts.Alert.Cooldown,
+	}, outbox), outbox, nil
+}
+
+// initBridge creates the bridge forwarding key change events to NATS, Kafka and MQTT and purging them
+// from the CDN, nil if none is set. Only keys the public can read are purged, with auth disabled that's all keys.
+func initBridge(authSvc *auth.Service) (*bridge.Bridge, error) {
+	var senders []bridge.Sender
+	if opts.Bridge.NATS != "" {
+		n, err := bridge.NewNATS(opts.Bridge.NATS, opts.Bridge.NATSSubject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize nats bridge: %w", err)
+		}
+		senders = append(senders, n)
+	}
+	if opts.Bridge.Kafka != "" {
+		k, err := bridge.NewKafka(opts.Bridge.Kafka, opts.Bridge.KafkaTopic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize kafka bridge: %w", err)
+		}
+		senders = append(senders, k)
+	}
+	if opts.Bridge.MQTT != "" {
+		m, err := bridge.NewMQTT(opts.Bridge.MQTT, opts.Bridge.MQTTTopic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize mqtt bridge: %w", err)
+		}
+		senders = append(senders, m)
+	}
+	if opts.CDN.Provider != "" {
+		c, err := bridge.NewCDN(bridge.CDNConfig{Provider: opts.CDN.Provider, BaseURL: opts.CDN.BaseURL,
+			Zone: opts.CDN.Zone, Token: opts.CDN.Token, Public: authSvc.PublicReadable})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize cdn purge: %w", err)
+		}
+		senders = append(senders, c)
+	}
+	if len(senders) == 0 {
+		return nil, nil //nolint:nilnil // nil bridge is valid when no bus is configured
+	}
+	b, err := bridge.New(opts.Bridge.Format, senders...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize bridge: %w", err)
+	}
+	for _, s := range senders {
+		log.Printf("[INFO] forwarding key change events to %s", s)
+	}
+	return b, nil
+}
+
+// initGitService creates git service if enabled. Secrets are committed encrypted with the store's keys
+// if secrets encryption is configured.
+func initGitService(kvStore *store.Store) (server.GitService, error) {
 	if !opts.Git.Enabled {
 		return nil, nil //nolint:nilnil // nil git service is valid when disabled
 	}
-	gitStore, err := git.New(git.Config{
-		Path:   opts.Git.Path,
-		Branch: opts.Git.Branch,
-		Remote: opts.Git.Remote,
-		SSHKey: opts.Git.SSHKey,
-	})
+	gitStore, err := git.New(gitConfig(kvStore))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize git store: %w", err)
 	}
 	return git.NewService(gitStore, opts.Git.Push), nil
 }
 
+// gitConfig returns the git store config from options, with secrets encrypted by the store if it has a key.
+func gitConfig(kvStore *store.Store) git.Config {
+	cfg := git.Config{
+		Path:         opts.Git.Path,
+		Branch:       opts.Git.Branch,
+		Remote:       opts.Git.Remote,
+		SSHKey:       opts.Git.SSHKey,
+		LFSThreshold: opts.Git.LFSThreshold,
+	}
+	if kvStore != nil && kvStore.SecretsEnabled() {
+		cfg.Secrets = kvStore
+	}
+	return cfg
+}
+
 // initAuthService creates auth service if enabled and activates it.
 func initAuthService(ctx context.Context, sessionStore auth.SessionStore) (*auth.Service, error) {
 	if opts.Auth.File == "" {
 		return nil, nil //nolint:nilnil // nil auth service is valid when auth is disabled
 	}
-	authSvc, err := auth.New(opts.Auth.File, opts.Auth.LoginTTL, opts.Auth.HotReload, sessionStore, server.VerifyAuthConfig)
+	authOpts := []auth.Option{auth.WithSessionIdle(opts.Auth.SessionIdle),
+		auth.WithRemember(opts.Auth.RememberTTL, opts.Auth.RememberIdle)}
+	if opts.Auth.Exchange.Enabled {
+		secret, err := exchangeSecret(opts.Auth.Exchange.Secret)
+		if err != nil {
+			return nil, err
+		}
+		authOpts = append(authOpts, auth.WithTokenExchange(secret, opts.Auth.Exchange.MaxTTL))
+	}
+	if opts.Auth.SPIFFE.TrustDomain != "" {
+		authOpts = append(authOpts, auth.WithSPIFFE(opts.Auth.SPIFFE.TrustDomain))
+	}
+	if opts.Auth.Cloud.AWS || opts.Auth.Cloud.GCPAudience != "" || opts.Auth.Cloud.GitHubAudience != "" {
+		// cloud logins get minted tokens signed with the token exchange secret
+		if !opts.Auth.Exchange.Enabled {
+			return nil, errors.New("cloud login requires --auth.exchange.enabled")
+		}
+		if opts.Auth.Cloud.AWS && opts.Auth.Cloud.ServerID == "" {
+			return nil, errors.New("AWS login requires --auth.cloud.server-id")
+		}
+		authOpts = append(authOpts, auth.WithCloudAuth(auth.CloudAuth{AWS: opts.Auth.Cloud.AWS, STSURL: opts.Auth.Cloud.STSURL,
+			ServerID: opts.Auth.Cloud.ServerID, GCPAudience: opts.Auth.Cloud.GCPAudience,
+			GitHubAudience: opts.Auth.Cloud.GitHubAudience}))
+	}
+	if opts.Auth.Location != "" {
+		authOpts = append(authOpts, auth.WithLocationHeader(opts.Auth.Location))
+	}
+	authOpts = append(authOpts, auth.WithStaleTokenAge(opts.Auth.StaleTokens))
+	if opts.Auth.Signed {
+		authOpts = append(authOpts, auth.WithSignedRequests(opts.Auth.SignedSkew))
+	}
+	if opts.Auth.Notify.SMTPHost != "" {
+		mailer, err := auth.NewMailer(auth.MailConfig{Host: opts.Auth.Notify.SMTPHost, Port: opts.Auth.Notify.SMTPPort,
+			Username: opts.Auth.Notify.Username, Password: opts.Auth.Notify.Password, From: opts.Auth.Notify.From})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize login notifications: %w", err)
+		}
+		authOpts = append(authOpts, auth.WithLoginNotifier(mailer))
+	}
+	if opts.Auth.Policy.URL != "" {
+		policy, err := auth.NewPolicy(auth.PolicyConfig{URL: opts.Auth.Policy.URL, Timeout: opts.Auth.Policy.Timeout,
+			CacheTTL: opts.Auth.Policy.CacheTTL})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize policy: %w", err)
+		}
+		authOpts = append(authOpts, auth.WithPolicy(policy))
+	}
+	if opts.Auth.Passkey.Origin != "" {
+		cfg, err := passkeyConfig(opts.Auth.Passkey.Origin, opts.Auth.Passkey.RPID)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SecondFactor = opts.Auth.Passkey.SecondFactor
+		authOpts = append(authOpts, auth.WithPasskeys(cfg))
+	}
+	authSvc, err := auth.New(opts.Auth.File, opts.Auth.LoginTTL, opts.Auth.HotReload, sessionStore, server.VerifyAuthConfig,
+		authOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize auth: %w", err)
 	}
@@ -470,3 +1534,60 @@ func initAuthService(ctx context.Context, sessionStore auth.SessionStore) (*auth
 	}
 	return authSvc, nil
 }
+
+// passkeyConfig checks the web UI origin and the relying party ID of passkeys. The ID defaults to the origin
+// host, otherwise it must be the host or a parent domain of it, browsers refuse other IDs.
+func passkeyConfig(origin, rpID string) (auth.PasskeyConfig, error) {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+		return auth.PasskeyConfig{}, fmt.Errorf("invalid passkey origin %q, expected scheme://host[:port]", origin)
+	}
+	host := u.Hostname()
+	if u.Scheme == "http" && host != "localhost" {
+		return auth.PasskeyConfig{}, fmt.Errorf("passkey origin %q must be https, browsers allow http for localhost only", origin)
+	}
+	if rpID == "" {
+		rpID = host
+	}
+	if host != rpID && !strings.HasSuffix(host, "."+rpID) {
+		return auth.PasskeyConfig{}, fmt.Errorf("passkey relying party id %q doesn't match origin host %q", rpID, host)
+	}
+	return auth.PasskeyConfig{Origin: u.Scheme + "://" + u.Host, RPID: rpID}, nil
+}
+
+// spiffeTrustBundle loads the SPIFFE trust bundle verifying workload client certificates, nil if workload
+// identities are not enabled. Workloads authenticate with mTLS, so the server must serve TLS.
+func spiffeTrustBundle() (*x509.CertPool, error) {
+	if opts.Auth.SPIFFE.TrustDomain == "" {
+		return nil, nil //nolint:nilnil // nil pool means client certificates are ignored
+	}
+	if opts.Auth.File == "" || opts.Server.TLSCert == "" || opts.Auth.SPIFFE.Bundle == "" {
+		return nil, errors.New("spiffe trust domain requires --auth.file, --server.tls-cert and --auth.spiffe.bundle")
+	}
+	data, err := os.ReadFile(opts.Auth.SPIFFE.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spiffe trust bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in spiffe trust bundle %s", opts.Auth.SPIFFE.Bundle)
+	}
+	return pool, nil
+}
+
+// exchangeSecret returns the secret signing exchanged tokens. Without a configured secret a random one is
+// generated, exchanged tokens are invalidated on restart then.
+func exchangeSecret(secret string) ([]byte, error) {
+	if secret != "" {
+		if len(secret) < 32 {
+			return nil, errors.New("token exchange secret must be at least 32 characters")
+		}
+		return []byte(secret), nil
+	}
+	res := make([]byte, 32)
+	if _, err := rand.Read(res); err != nil {
+		return nil, fmt.Errorf("failed to generate token exchange secret: %w", err)
+	}
+	log.Printf("[WARN] token exchange secret is not set, exchanged tokens will be invalid after restart")
+	return res, nil
+}
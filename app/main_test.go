@@ -3,10 +3,12 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -21,7 +23,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/seal"
 	"github.com/umputun/stash/app/store"
 	"github.com/umputun/stash/lib/stash"
 )
@@ -155,6 +160,8 @@ tokens:
 	require.NoError(t, os.WriteFile(authFile, []byte(authContent), 0o600))
 	opts.Auth.File = authFile
 	opts.Auth.LoginTTL = time.Hour
+	opts.Auth.SessionIdle = 10 * time.Minute
+	defer func() { opts.Auth.SessionIdle = 0 }()
 
 	// start server in background
 	ctx, cancel := context.WithCancel(context.Background())
@@ -332,6 +339,57 @@ tokens:
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 	})
 
+	t.Run("session status and renewal", func(t *testing.T) {
+		noRedirectClient := &http.Client{
+			Timeout: 5 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := noRedirectClient.PostForm("http://127.0.0.1:18485/login",
+			map[string][]string{"username": {"admin"}, "password": {"testpass"}})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var authCookie *http.Cookie
+		for _, c := range resp.Cookies() {
+			if c.Name == "stash-auth" || c.Name == "__Host-stash-auth" {
+				authCookie = c
+				break
+			}
+		}
+		require.NotNil(t, authCookie)
+
+		// status endpoint is outside of the session middleware, no login redirect
+		resp, err = noRedirectClient.Get("http://127.0.0.1:18485/web/session")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:18485/web/session", http.NoBody)
+		require.NoError(t, err)
+		req.AddCookie(authCookie)
+		resp, err = noRedirectClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var status struct {
+			ExpiresAt time.Time `json:"expires_at"`
+			Renewable bool      `json:"renewable"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), status.ExpiresAt, time.Minute, "expires after idle timeout")
+		assert.True(t, status.Renewable)
+
+		req, err = http.NewRequest(http.MethodPost, "http://127.0.0.1:18485/web/session/renew", http.NoBody)
+		require.NoError(t, err)
+		req.AddCookie(authCookie)
+		resp, err = noRedirectClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("X-Session-Expires"))
+	})
+
 	t.Run("logout clears session", func(t *testing.T) {
 		noRedirectClient := &http.Client{
 			Timeout: 5 * time.Second,
@@ -673,6 +731,28 @@ func TestRun_InvalidAuthFile(t *testing.T) {
 	opts.Auth.File = ""
 }
 
+func TestRun_AWSLoginWithoutServerID(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts.DB = filepath.Join(tmpDir, "test.db")
+	opts.Server.Address = "127.0.0.1:18487"
+	opts.Server.ReadTimeout = 5 * time.Second
+
+	authFile := filepath.Join(tmpDir, "auth.yml")
+	require.NoError(t, os.WriteFile(authFile, []byte("tokens:\n  - token: \"t1\"\n    permissions:\n      - prefix: \"*\"\n        access: rw\n"), 0o600))
+	opts.Auth.File = authFile
+	opts.Auth.Exchange.Enabled = true
+	opts.Auth.Cloud.AWS = true
+
+	err := runServer(t.Context())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AWS login requires --auth.cloud.server-id")
+
+	// reset
+	opts.Auth.File = ""
+	opts.Auth.Exchange.Enabled = false
+	opts.Auth.Cloud.AWS = false
+}
+
 func TestRun_InvalidAuthFileSchema(t *testing.T) {
 	tmpDir := t.TempDir()
 	opts.DB = filepath.Join(tmpDir, "test.db")
@@ -771,6 +851,37 @@ func TestValidateBaseURL(t *testing.T) {
 	}
 }
 
+func TestPasskeyConfig(t *testing.T) {
+	tests := []struct {
+		name, origin, rpID string
+		want               auth.PasskeyConfig
+		wantErr            bool
+	}{
+		{name: "default rp id", origin: "https://stash.example.com/", want: auth.PasskeyConfig{Origin: "https://stash.example.com",
+			RPID: "stash.example.com"}},
+		{name: "parent domain rp id", origin: "https://stash.example.com:8443", rpID: "example.com",
+			want: auth.PasskeyConfig{Origin: "https://stash.example.com:8443", RPID: "example.com"}},
+		{name: "localhost over http", origin: "http://localhost:8484", want: auth.PasskeyConfig{Origin: "http://localhost:8484",
+			RPID: "localhost"}},
+		{name: "http", origin: "http://stash.example.com", wantErr: true},
+		{name: "path", origin: "https://stash.example.com/stash", wantErr: true},
+		{name: "no scheme", origin: "stash.example.com", wantErr: true},
+		{name: "foreign rp id", origin: "https://stash.example.com", rpID: "other.com", wantErr: true},
+		{name: "suffix but not a domain", origin: "https://badexample.com", rpID: "example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := passkeyConfig(tt.origin, tt.rpID)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestIntegration_WithBaseURL(t *testing.T) {
 	// setup options with base URL
 	tmpDir := t.TempDir()
@@ -911,6 +1022,328 @@ func TestRunRestore_InvalidRevision(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to checkout revision")
 }
 
+func TestRunRekey(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	opts.DB = dbPath
+	opts.Secrets.Key = "test-master-key-1234"
+	opts.Secrets.PrefixKeys = nil
+	t.Cleanup(func() { opts.Secrets.Key, opts.Secrets.PrefixKeys = "", nil })
+
+	// secret written before prefix keys were configured
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err := store.New(dbPath, storeOpts...)
+	require.NoError(t, err)
+	_, err = kvStore.Set(t.Context(), "secrets/pay/stripe", []byte("sk_live_1"), "text")
+	require.NoError(t, err)
+	require.NoError(t, kvStore.Close())
+
+	opts.Secrets.PrefixKeys = []string{"secrets/pay/*:pay-1:payments-key-0001"}
+	require.NoError(t, runRekey(t.Context()))
+
+	// the master key alone can't read it anymore
+	opts.Secrets.PrefixKeys = nil
+	storeOpts, err = secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err = store.New(dbPath, storeOpts...)
+	require.NoError(t, err)
+	defer kvStore.Close()
+	_, err = kvStore.Get(t.Context(), "secrets/pay/stripe")
+	require.ErrorIs(t, err, store.ErrPrefixKeyNotFound)
+}
+
+func TestRunAdminEncryptAll(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	opts.DB = dbPath
+	t.Cleanup(func() { opts.Secrets.Key, opts.Secrets.DataKey, opts.Secrets.EncryptAll = "", "", false })
+
+	kvStore, err := store.New(dbPath)
+	require.NoError(t, err)
+	_, err = kvStore.Set(t.Context(), "app/db", []byte("postgres://db"), "text")
+	require.NoError(t, err)
+	require.NoError(t, kvStore.Close())
+
+	require.ErrorContains(t, runAdminEncryptAll(t.Context()), "encryption at rest is not enabled")
+	opts.Secrets.EncryptAll = true
+	require.ErrorContains(t, runAdminEncryptAll(t.Context()), "requires --secrets.key or --secrets.data-key")
+	opts.Secrets.DataKey = "short"
+	require.ErrorContains(t, runAdminEncryptAll(t.Context()), "at least 16 characters")
+
+	opts.Secrets.DataKey = "test-data-key-12345"
+	require.NoError(t, runAdminEncryptAll(t.Context()))
+
+	// readable with the data key only
+	kvStore, err = store.New(dbPath)
+	require.NoError(t, err)
+	_, err = kvStore.Get(t.Context(), "app/db")
+	require.ErrorIs(t, err, store.ErrDataKeyNotConfigured)
+	require.NoError(t, kvStore.Close())
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err = store.New(dbPath, storeOpts...)
+	require.NoError(t, err)
+	defer kvStore.Close()
+	value, err := kvStore.Get(t.Context(), "app/db")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://db", string(value))
+	assert.False(t, kvStore.SecretsEnabled(), "data key doesn't enable secrets")
+}
+
+func TestRunGC(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	opts.DB = dbPath
+	opts.GCCmd.Unread = time.Hour
+	t.Cleanup(func() { opts.GCCmd.Delete, opts.GCCmd.Yes = false, false })
+
+	kvStore, err := store.New(dbPath)
+	require.NoError(t, err)
+	for key, value := range map[string]string{"app/empty": "", "app/full": "v"} {
+		_, err = kvStore.Set(t.Context(), key, []byte(value), "text")
+		require.NoError(t, err)
+	}
+	require.NoError(t, kvStore.Close())
+
+	keys := func() []string {
+		st, err := store.New(dbPath)
+		require.NoError(t, err)
+		defer st.Close()
+		list, err := st.List(t.Context(), enum.SecretsFilterAll)
+		require.NoError(t, err)
+		res := []string{}
+		for _, k := range list {
+			res = append(res, k.Key)
+		}
+		return res
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		require.NoError(t, runGC(t.Context(), strings.NewReader("y\n")))
+		assert.ElementsMatch(t, []string{"app/empty", "app/full"}, keys())
+	})
+
+	t.Run("delete declined", func(t *testing.T) {
+		opts.GCCmd.Delete = true
+		require.NoError(t, runGC(t.Context(), strings.NewReader("n\n")))
+		assert.ElementsMatch(t, []string{"app/empty", "app/full"}, keys())
+	})
+
+	t.Run("delete confirmed", func(t *testing.T) {
+		opts.GCCmd.Delete = true
+		require.NoError(t, runGC(t.Context(), strings.NewReader("yes\n")))
+		assert.Equal(t, []string{"app/full"}, keys())
+	})
+}
+
+func TestWriteGCReport(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := store.GCReport{
+		Empty:      []store.KeyInfo{{Key: "app/empty"}},
+		StaleZK:    []store.KeyInfo{{Key: "zk/old", UpdatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}},
+		AuditSince: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	writeGCReport(&buf, report, cutoff)
+	assert.Equal(t, "empty keys: 1\n  app/empty\nzk keys not updated or read since 2025-01-01: 1\n"+
+		"  zk/old (updated 2024-06-01)\nwarning: audit log starts at 2025-03-01, zk reads before it are unknown\n", buf.String())
+
+	buf.Reset()
+	report.AuditSince = time.Time{}
+	writeGCReport(&buf, report, cutoff)
+	assert.Contains(t, buf.String(), "warning: audit log is empty")
+
+	buf.Reset()
+	report.AuditSince = cutoff.Add(-time.Hour)
+	writeGCReport(&buf, report, cutoff)
+	assert.NotContains(t, buf.String(), "warning")
+}
+
+func TestWriteDBStats(t *testing.T) {
+	stats := store.DBStats{Keys: 3, ValueBytes: 2100, DBBytes: 8192,
+		Sizes:    []store.SizeBucket{{Max: 0, Keys: 1}, {Max: 1024, Keys: 1, Bytes: 100}, {Max: math.MaxInt64, Keys: 1, Bytes: 2000}},
+		Prefixes: []store.PrefixStats{{Prefix: "app/", Keys: 2, Bytes: 2100}, {Prefix: "", Keys: 1}},
+		Growth:   []store.GrowthStats{{Month: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Created: 3, Written: 2100}},
+	}
+	var buf bytes.Buffer
+	writeDBStats(&buf, stats, 1, 12)
+	out := buf.String()
+	assert.Contains(t, out, "keys: 3, values: 2.1 KB, database: 8.0 KB")
+	assert.Contains(t, out, "       empty     1     0 B\n")
+	assert.Contains(t, out, "> 1.0 KB     1  2.0 KB")
+	assert.Contains(t, out, "app/    2     2.1 KB\n(1 more)\n")
+	assert.Contains(t, out, "(1 more)")
+	assert.NotContains(t, out, "(no folder)")
+	assert.Contains(t, out, "2025-01  3        0        0        2.1 KB")
+	assert.Contains(t, out, "projected database size in 12 months: 32.6 KB")
+
+	buf.Reset()
+	writeDBStats(&buf, store.DBStats{}, 20, 12)
+	assert.Contains(t, buf.String(), "no growth data")
+	assert.NotContains(t, buf.String(), "projected")
+}
+
+func TestRunDev(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "app", "secrets"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "db.json"), []byte(`{"port":5432}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "secrets", "pass.txt"), []byte("pw"), 0o600))
+	opts.DevCmd.Address, opts.DevCmd.Args.Dir, opts.DevCmd.WriteBack = "127.0.0.1:18508", dir, true
+	t.Cleanup(func() { opts.DevCmd.Args.Dir, opts.DevCmd.WriteBack = "", false })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- runDev(ctx) }()
+	waitForServer(t, "http://127.0.0.1:18508/ping")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(key string) (int, string) {
+		resp, err := client.Get("http://127.0.0.1:18508/kv/" + key)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	code, body := get("app/db")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"port":5432}`, body)
+	code, body = get("app/secrets/pass")
+	assert.Equal(t, http.StatusOK, code, "secrets work without a key")
+	assert.Equal(t, "pw", body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://127.0.0.1:18508/kv/app/name", strings.NewReader("svc"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	data, err := os.ReadFile(filepath.Join(dir, "app", "name.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "svc", string(data), "written back")
+
+	cancel()
+	require.NoError(t, <-errCh)
+
+	opts.DevCmd.Args.Dir = filepath.Join(dir, "app", "db.json")
+	require.EqualError(t, runDev(t.Context()), opts.DevCmd.Args.Dir+" is not a directory")
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	bundlePath, policyPath := filepath.Join(dir, "bundle.json"), filepath.Join(dir, "policy.yml")
+	require.NoError(t, os.WriteFile(bundlePath, []byte(`{"version":1,"keys":[
+		{"key":"app/db","value":"{\"port\":5432}","format":"json"},
+		{"key":"App/Name","value":"svc"}]}`), 0o600))
+	require.NoError(t, os.WriteFile(policyPath, []byte("key_pattern: '^[a-z/]+$'\n"), 0o600))
+	t.Cleanup(func() { opts.ValidateCmd.Policy, opts.ValidateCmd.Args.Bundle = "", "" })
+
+	var buf bytes.Buffer
+	opts.ValidateCmd.Args.Bundle = bundlePath
+	require.NoError(t, runValidate(&buf))
+	assert.Equal(t, bundlePath+": 2 keys, no problems\n", buf.String())
+
+	buf.Reset()
+	opts.ValidateCmd.Policy = policyPath
+	require.EqualError(t, runValidate(&buf), bundlePath+": 2 keys, 1 problems")
+	assert.Equal(t, "App/Name: key doesn't match ^[a-z/]+$\n", buf.String())
+
+	opts.ValidateCmd.Args.Bundle = filepath.Join(dir, "missing.json")
+	require.ErrorContains(t, runValidate(&buf), "failed to open bundle")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.5 KB", formatBytes(1536))
+	assert.Equal(t, "1.0 MB", formatBytes(1024*1024))
+	assert.Equal(t, "2.0 GB", formatBytes(2<<30))
+}
+
+func TestInitPrefixKeys(t *testing.T) {
+	kr, err := initPrefixKeys([]string{"secrets/pay/*:pay-1:key:with:colons-0001"})
+	require.NoError(t, err)
+	assert.NotNil(t, kr)
+
+	_, err = initPrefixKeys([]string{"secrets/pay/*:pay-1"})
+	require.ErrorContains(t, err, "expected prefix:id:key")
+	_, err = initPrefixKeys([]string{":pay-1:payments-key-0001"})
+	require.ErrorContains(t, err, "expected prefix:id:key")
+	_, err = initPrefixKeys([]string{"secrets/pay/*:pay-1:short"})
+	require.ErrorContains(t, err, "at least 16 characters")
+	_, err = initPrefixKeys([]string{"secrets/pay/*:pay 1:payments-key-0001"})
+	require.ErrorContains(t, err, "invalid prefix key id")
+
+	opts.Secrets.Key, opts.Secrets.PrefixKeys = "", []string{"secrets/pay/*:pay-1:payments-key-0001"}
+	t.Cleanup(func() { opts.Secrets.PrefixKeys = nil })
+	_, err = secretsStoreOptions(nil)
+	require.ErrorContains(t, err, "prefix keys require secrets key")
+}
+
+func TestSecretsKey(t *testing.T) {
+	t.Cleanup(func() {
+		opts.Secrets.Key, opts.Secrets.WrappedKey, opts.Secrets.Sealed = "", "", false
+		opts.Secrets.KEK.Provider, opts.Secrets.KEK.File = "", ""
+	})
+	kekFile := filepath.Join(t.TempDir(), "kek")
+	require.NoError(t, os.WriteFile(kekFile, []byte(strings.Repeat("ab", 32)), 0o600))
+	opts.Secrets.KEK.Provider, opts.Secrets.KEK.File = "software", kekFile
+
+	w, err := initKeyWrapper()
+	require.NoError(t, err)
+	wrapped, err := w.Wrap([]byte("test-master-key-1234"))
+	require.NoError(t, err)
+
+	opts.Secrets.Key = "test-master-key-1234"
+	key, err := secretsKey()
+	require.NoError(t, err)
+	assert.Equal(t, "test-master-key-1234", key, "plain key used as is")
+
+	opts.Secrets.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	_, err = secretsKey()
+	require.ErrorContains(t, err, "mutually exclusive")
+
+	opts.Secrets.Key = ""
+	key, err = secretsKey()
+	require.NoError(t, err)
+	assert.Equal(t, "test-master-key-1234", key)
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	assert.Len(t, storeOpts, 1, "secrets enabled with the unwrapped key")
+
+	opts.Secrets.Sealed = true
+	_, err = initSealer()
+	require.ErrorContains(t, err, "must not be set in sealed mode")
+	opts.Secrets.Sealed = false
+
+	opts.Secrets.WrappedKey = "not base64!"
+	_, err = secretsKey()
+	require.ErrorContains(t, err, "expected base64")
+
+	require.NoError(t, os.WriteFile(kekFile, []byte(strings.Repeat("cd", 32)), 0o600))
+	opts.Secrets.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	_, err = secretsKey()
+	require.ErrorContains(t, err, "wrong kek")
+
+	opts.Secrets.KEK.File = ""
+	_, err = secretsKey()
+	require.ErrorContains(t, err, "requires --secrets.kek.file")
+
+	opts.Secrets.KEK.Provider = "pkcs11"
+	_, err = initKeyWrapper()
+	require.ErrorContains(t, err, "failed to open pkcs11 kek")
+}
+
+func TestRunWrapKey(t *testing.T) {
+	t.Cleanup(func() { opts.Secrets.Key, opts.Secrets.KEK.Provider, opts.Secrets.KEK.File = "", "", "" })
+	opts.Secrets.Key = "short"
+	require.ErrorContains(t, runWrapKey(), "at least 16 characters")
+
+	kekFile := filepath.Join(t.TempDir(), "kek")
+	require.NoError(t, os.WriteFile(kekFile, []byte(strings.Repeat("ab", 32)), 0o600))
+	opts.Secrets.Key, opts.Secrets.KEK.Provider, opts.Secrets.KEK.File = "test-master-key-1234", "software", kekFile
+	require.NoError(t, runWrapKey())
+}
+
 func TestIntegration_WithCache(t *testing.T) {
 	tmpDir := t.TempDir()
 	opts.DB = filepath.Join(tmpDir, "test.db")
@@ -2234,6 +2667,28 @@ tokens:
 		assert.GreaterOrEqual(t, actions["read"], 1, "should have at least 1 read action")
 		assert.Equal(t, 1, actions["update"], "should have 1 update action")
 		assert.Equal(t, 1, actions["delete"], "should have 1 delete action")
+
+		// aggregated counts for the same keys
+		statsReq, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:18502/audit/stats?group_by=action&range=1h&key=audit-test/*", http.NoBody)
+		require.NoError(t, err)
+		statsReq.AddCookie(sessionCookie)
+		statsResp, err := client.Do(statsReq)
+		require.NoError(t, err)
+		defer statsResp.Body.Close()
+		require.Equal(t, http.StatusOK, statsResp.StatusCode)
+
+		var stats struct {
+			Counts []struct {
+				Value string `json:"value"`
+				Count int    `json:"count"`
+			} `json:"counts"`
+		}
+		require.NoError(t, json.NewDecoder(statsResp.Body).Decode(&stats))
+		statCounts := make(map[string]int)
+		for _, c := range stats.Counts {
+			statCounts[c.Value] = c.Count
+		}
+		assert.Equal(t, actions, statCounts)
 	})
 
 	t.Run("audit query requires admin", func(t *testing.T) {
@@ -2598,3 +3053,206 @@ func TestIntegration_SSE_AuthDenied(t *testing.T) {
 		t.Fatal("server did not shut down in time")
 	}
 }
+
+func TestIntegration_Sealed(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts.DB = filepath.Join(tmpDir, "test.db")
+	opts.Server.Address = "127.0.0.1:18507"
+	opts.Server.ReadTimeout = 5 * time.Second
+	opts.Secrets.Key = ""
+	opts.Secrets.Sealed = true
+	t.Cleanup(func() { opts.Secrets.Sealed, opts.Auth.File = false, "" })
+
+	shares, err := seal.Split([]byte("test-sealed-master-key"), 3, 2)
+	require.NoError(t, err)
+
+	authContent := `tokens:
+  - token: admin-token
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: app-token
+    permissions:
+      - prefix: "*"
+        access: rw
+      - prefix: "secrets/*"
+        access: rw
+`
+	authFile := filepath.Join(tmpDir, "auth.yml")
+	require.NoError(t, os.WriteFile(authFile, []byte(authContent), 0o600))
+	opts.Auth.File = authFile
+	opts.Auth.LoginTTL = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runServer(ctx)
+	}()
+	waitForServer(t, "http://127.0.0.1:18507/ping")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	doRequest := func(method, path, token, body string) (int, string) {
+		req, err := http.NewRequest(method, "http://127.0.0.1:18507"+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(respBody)
+	}
+
+	code, body := doRequest(http.MethodGet, "/unseal", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"sealed":true,"threshold":0,"progress":0}`, body)
+
+	code, _ = doRequest(http.MethodPut, "/kv/secrets/db", "app-token", "s3cret")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "secrets unavailable while sealed")
+	code, _ = doRequest(http.MethodPut, "/kv/app/config", "app-token", "plain")
+	assert.Equal(t, http.StatusCreated, code, "regular keys work while sealed")
+
+	code, _ = doRequest(http.MethodPost, "/unseal", "app-token", `{"share":"`+shares[0]+`"}`)
+	assert.Equal(t, http.StatusForbidden, code, "only admins submit shares")
+
+	code, body = doRequest(http.MethodPost, "/unseal", "admin-token", `{"share":"`+shares[0]+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"sealed":true,"threshold":2,"progress":1}`, body)
+	code, body = doRequest(http.MethodPost, "/unseal", "admin-token", `{"share":"`+shares[2]+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"sealed":false,"threshold":0,"progress":0}`, body)
+
+	code, _ = doRequest(http.MethodPut, "/kv/secrets/db", "app-token", "s3cret")
+	assert.Equal(t, http.StatusCreated, code)
+	code, body = doRequest(http.MethodGet, "/kv/secrets/db", "app-token", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "s3cret", body)
+
+	cancel()
+	select {
+	case err := <-errCh:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down in time")
+	}
+
+	// the master key reconstructed from shares reads secrets written after unseal
+	opts.Secrets.Sealed = false
+	opts.Secrets.Key = "test-sealed-master-key"
+	defer func() { opts.Secrets.Key = "" }()
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	require.NoError(t, err)
+	defer kvStore.Close()
+	value, err := kvStore.Get(t.Context(), "secrets/db")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", string(value))
+}
+
+func TestInitSealer(t *testing.T) {
+	t.Cleanup(func() { opts.Secrets.Sealed, opts.Secrets.Key, opts.Auth.File = false, "", "" })
+
+	opts.Secrets.Sealed = false
+	sealer, err := initSealer()
+	require.NoError(t, err)
+	assert.Nil(t, sealer)
+
+	opts.Secrets.Sealed, opts.Secrets.Key = true, "test-master-key-1234"
+	_, err = initSealer()
+	require.ErrorContains(t, err, "must not be set in sealed mode")
+
+	opts.Secrets.Key, opts.Auth.File = "", ""
+	_, err = initSealer()
+	require.ErrorContains(t, err, "requires auth")
+
+	opts.Auth.File = "auth.yml"
+	sealer, err = initSealer()
+	require.NoError(t, err)
+	assert.True(t, sealer.Status().Sealed)
+}
+
+func TestInitBridge(t *testing.T) {
+	t.Cleanup(func() {
+		opts.Bridge.NATS, opts.Bridge.Kafka, opts.Bridge.MQTT, opts.Bridge.Format = "", "", "", ""
+		opts.CDN.Provider, opts.CDN.BaseURL, opts.CDN.Token = "", "", ""
+	})
+	opts.Bridge.NATSSubject, opts.Bridge.KafkaTopic, opts.Bridge.MQTTTopic = "stash.changes", "stash-changes", "stash"
+
+	b, err := initBridge(nil)
+	require.NoError(t, err)
+	assert.Nil(t, b, "no bus configured")
+
+	opts.Bridge.NATS, opts.Bridge.Kafka, opts.Bridge.Format = "nats://localhost:4222", "http://localhost:8082", "cloudevents"
+	b, err = initBridge(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+
+	opts.Bridge.MQTT = "mqtt://localhost:1883"
+	b, err = initBridge(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+
+	opts.CDN.Provider, opts.CDN.BaseURL, opts.CDN.Token = "fastly", "https://cdn.example.com", "key"
+	b, err = initBridge(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+
+	opts.CDN.Provider = "cloudflare"
+	_, err = initBridge(nil)
+	require.ErrorContains(t, err, "failed to initialize cdn purge: zone id is required")
+
+	opts.Bridge.MQTT = "ws://localhost"
+	_, err = initBridge(nil)
+	require.ErrorContains(t, err, "failed to initialize mqtt bridge")
+
+	opts.Bridge.Kafka = "localhost:9092"
+	_, err = initBridge(nil)
+	require.ErrorContains(t, err, "failed to initialize kafka bridge")
+
+	opts.Bridge.NATS = "http://localhost"
+	_, err = initBridge(nil)
+	require.ErrorContains(t, err, "failed to initialize nats bridge")
+}
+
+func TestRunGitVerify(t *testing.T) {
+	dir := t.TempDir()
+	opts.DB, opts.Git.Path, opts.Git.Branch = filepath.Join(dir, "test.db"), filepath.Join(dir, ".history"), "master"
+	opts.Secrets.Key = "test-master-key-1234"
+	t.Cleanup(func() { opts.Secrets.Key = "" })
+
+	storeOpts, err := secretsStoreOptions(nil)
+	require.NoError(t, err)
+	kvStore, err := store.New(opts.DB, storeOpts...)
+	require.NoError(t, err)
+	defer kvStore.Close()
+	gitStore, err := git.New(gitConfig(kvStore))
+	require.NoError(t, err)
+	author := git.DefaultAuthor()
+	require.NoError(t, gitStore.Commit(git.CommitRequest{Key: "secrets/db", Value: []byte("pass"), Operation: "set", Author: author}))
+	require.NoError(t, gitStore.Commit(git.CommitRequest{Key: "app/db", Value: []byte("host"), Operation: "set", Author: author}))
+
+	var buf bytes.Buffer
+	require.NoError(t, runGitVerify(&buf))
+	assert.Equal(t, "no plaintext secrets in git history\n", buf.String())
+
+	// restore decrypts the committed secret and stores it encrypted with the key
+	opts.RestoreCmd.Rev, err = gitStore.Head()
+	require.NoError(t, err)
+	require.NoError(t, runRestore(t.Context()))
+	val, err := kvStore.Get(t.Context(), "secrets/db")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", string(val))
+
+	// a secret committed without the key is reported
+	plainGit, err := git.New(git.Config{Path: opts.Git.Path, Branch: "master"})
+	require.NoError(t, err)
+	require.NoError(t, plainGit.Commit(git.CommitRequest{Key: "secrets/api", Value: []byte("token"), Operation: "set", Author: author}))
+	buf.Reset()
+	require.EqualError(t, runGitVerify(&buf), opts.Git.Path+": 1 plaintext secret values in git history")
+	assert.True(t, strings.HasPrefix(buf.String(), "secrets/api\t"), buf.String())
+}
@@ -0,0 +1,226 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// cache keeps the key list and fetched values of the mounted prefix. Nothing expires by time, a change event
+// drops the list and the value of the changed key, and they are fetched again on the next access.
+type cache struct {
+	client Client
+	prefix string
+
+	mu     sync.Mutex
+	keys   map[string]stash.KeyInfo // key relative to the prefix -> info, nil if not loaded
+	values map[string][]byte        // key relative to the prefix -> value
+	dirs   map[string]bool          // directories made by mkdir and not holding keys yet
+	gen    uint64                   // incremented by invalidate, a value fetched meanwhile may be stale
+}
+
+// entry is a directory entry.
+type entry struct {
+	name string
+	dir  bool
+}
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+func newCache(client Client, prefix string) *cache {
+	return &cache{client: client, prefix: prefix, values: map[string][]byte{}, dirs: map[string]bool{}}
+}
+
+// list returns the keys, loading them if not loaded. Must be called with the lock held.
+func (c *cache) list(ctx context.Context) (map[string]stash.KeyInfo, error) {
+	if c.keys != nil {
+		return c.keys, nil
+	}
+	list, err := c.client.List(ctx, c.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	c.keys = make(map[string]stash.KeyInfo, len(list))
+	for _, k := range list {
+		if rel, ok := strings.CutPrefix(k.Key, c.prefix); ok && rel != "" {
+			c.keys[rel] = k
+		}
+	}
+	return c.keys, nil
+}
+
+// stat returns the info of the key, or isDir for a directory. A key which is also a directory of other keys,
+// like app with app/db, is the directory. Neither is stash.ErrNotFound.
+func (c *cache) stat(ctx context.Context, rel string) (info stash.KeyInfo, isDir bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys, err := c.list(ctx)
+	if err != nil {
+		return stash.KeyInfo{}, false, err
+	}
+	if c.isDir(keys, rel) {
+		return stash.KeyInfo{}, true, nil
+	}
+	if info, ok := keys[rel]; ok {
+		return info, false, nil
+	}
+	return stash.KeyInfo{}, false, stash.ErrNotFound
+}
+
+// isDir reports whether rel is a directory, the root, a prefix of keys or made by mkdir.
+func (c *cache) isDir(keys map[string]stash.KeyInfo, rel string) bool {
+	if rel == "" || c.dirs[rel] {
+		return true
+	}
+	for k := range keys {
+		if strings.HasPrefix(k, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// entries returns the entries of a directory sorted by name.
+func (c *cache) entries(ctx context.Context, dir string) ([]entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !c.isDir(keys, dir) {
+		if _, ok := keys[dir]; ok {
+			return nil, errNotDir
+		}
+		return nil, stash.ErrNotFound
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	isDir := map[string]bool{}
+	add := func(path string) {
+		rest, ok := strings.CutPrefix(path, prefix)
+		if !ok || rest == "" {
+			return
+		}
+		name, _, sub := strings.Cut(rest, "/")
+		isDir[name] = isDir[name] || sub
+	}
+	for k := range keys {
+		add(k)
+	}
+	for d := range c.dirs {
+		add(d + "/")
+	}
+
+	res := make([]entry, 0, len(isDir))
+	for name, d := range isDir {
+		res = append(res, entry{name: name, dir: d})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res, nil
+}
+
+// value returns the value of the key, fetched once until the key changes.
+func (c *cache) value(ctx context.Context, rel string) ([]byte, error) {
+	c.mu.Lock()
+	v, ok := c.values[rel]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := c.client.GetBytes(ctx, c.prefix+rel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", c.prefix+rel, err)
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.values[rel] = v
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+// set stores the value of the key, keeping the format of an existing key, text for a new one.
+func (c *cache) set(ctx context.Context, rel string, value []byte) error {
+	c.mu.Lock()
+	keys, err := c.list(ctx)
+	format := stash.FormatText.String()
+	if info, ok := keys[rel]; ok && info.Format != "" {
+		format = info.Format
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f, err := stash.ParseFormat(format)
+	if err != nil {
+		return fmt.Errorf("invalid format of %s: %w", c.prefix+rel, err)
+	}
+	if err := c.client.SetWithFormat(ctx, c.prefix+rel, string(value), f); err != nil {
+		return fmt.Errorf("failed to set %s: %w", c.prefix+rel, err)
+	}
+	c.invalidate(rel)
+	return nil
+}
+
+// remove deletes the key.
+func (c *cache) remove(ctx context.Context, rel string) error {
+	if err := c.client.Delete(ctx, c.prefix+rel); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", c.prefix+rel, err)
+	}
+	c.invalidate(rel)
+	return nil
+}
+
+// mkdir adds an empty directory, kept in memory until a key is stored in it.
+func (c *cache) mkdir(rel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[rel] = true
+}
+
+// rmdir removes an empty directory made by mkdir.
+func (c *cache) rmdir(ctx context.Context, rel string) error {
+	entries, err := c.entries(ctx, rel)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(entries) > 0 || !c.dirs[rel] {
+		return errNotEmpty
+	}
+	delete(c.dirs, rel)
+	return nil
+}
+
+// invalidate drops the key list and the value of the changed key, all values for an empty key.
+func (c *cache) invalidate(rel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = nil
+	c.gen++
+	if rel == "" {
+		c.values = map[string][]byte{}
+		return
+	}
+	delete(c.values, rel)
+	for d := range c.dirs {
+		if strings.HasPrefix(rel, d+"/") {
+			delete(c.dirs, d) // holds a key now
+		}
+	}
+}
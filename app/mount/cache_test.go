@@ -0,0 +1,168 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+func TestCache(t *testing.T) {
+	client := newFakeClient(map[string]string{"app/db": `{"port":5432}`, "app/conf/a": "1", "app/conf": "shadowed",
+		"app/name": "svc", "other/x": "x"})
+	client.formats["app/db"] = "json"
+	c := newCache(client, "app/")
+	ctx := t.Context()
+
+	entries, err := c.entries(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []entry{{name: "conf", dir: true}, {name: "db"}, {name: "name"}}, entries)
+	entries, err = c.entries(ctx, "conf")
+	require.NoError(t, err)
+	assert.Equal(t, []entry{{name: "a"}}, entries)
+	_, err = c.entries(ctx, "db")
+	require.ErrorIs(t, err, errNotDir)
+	_, err = c.entries(ctx, "missing")
+	require.ErrorIs(t, err, stash.ErrNotFound)
+
+	info, isDir, err := c.stat(ctx, "db")
+	require.NoError(t, err)
+	assert.False(t, isDir)
+	assert.Equal(t, "json", info.Format)
+	_, isDir, err = c.stat(ctx, "conf")
+	require.NoError(t, err)
+	assert.True(t, isDir, "a key with subkeys is a directory")
+	_, _, err = c.stat(ctx, "missing")
+	require.ErrorIs(t, err, stash.ErrNotFound)
+
+	t.Run("values cached until invalidated", func(t *testing.T) {
+		v, err := c.value(ctx, "name")
+		require.NoError(t, err)
+		assert.Equal(t, "svc", string(v))
+		client.put("app/name", "svc2", "text")
+		v, err = c.value(ctx, "name")
+		require.NoError(t, err)
+		assert.Equal(t, "svc", string(v))
+
+		c.invalidate("name")
+		v, err = c.value(ctx, "name")
+		require.NoError(t, err)
+		assert.Equal(t, "svc2", string(v))
+	})
+
+	t.Run("set keeps format", func(t *testing.T) {
+		require.NoError(t, c.set(ctx, "db", []byte(`{"port":1}`)))
+		require.NoError(t, c.set(ctx, "new", []byte("n")))
+		assert.Equal(t, "json", client.format("app/db"))
+		assert.Equal(t, "text", client.format("app/new"))
+		v, err := c.value(ctx, "db")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"port":1}`, string(v))
+	})
+
+	t.Run("mkdir and rmdir", func(t *testing.T) {
+		c.mkdir("empty")
+		_, isDir, err := c.stat(ctx, "empty")
+		require.NoError(t, err)
+		assert.True(t, isDir)
+		require.ErrorIs(t, c.rmdir(ctx, "conf"), errNotEmpty)
+		require.NoError(t, c.rmdir(ctx, "empty"))
+		_, _, err = c.stat(ctx, "empty")
+		require.ErrorIs(t, err, stash.ErrNotFound)
+
+		c.mkdir("dir")
+		require.NoError(t, c.set(ctx, "dir/key", []byte("v")))
+		require.NoError(t, c.remove(ctx, "dir/key"))
+		_, _, err = c.stat(ctx, "dir")
+		require.ErrorIs(t, err, stash.ErrNotFound, "made directory ends with its keys")
+	})
+}
+
+// fakeClient keeps keys in memory, subscriptions are not supported.
+type fakeClient struct {
+	mu      sync.Mutex
+	values  map[string]string
+	formats map[string]string
+}
+
+func newFakeClient(values map[string]string) *fakeClient {
+	return &fakeClient{values: values, formats: map[string]string{}}
+}
+
+func (c *fakeClient) put(key, value, format string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key], c.formats[key] = value, format
+}
+
+func (c *fakeClient) value(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *fakeClient) format(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.formats[key]
+}
+
+func (c *fakeClient) List(_ context.Context, prefix string) ([]stash.KeyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var res []stash.KeyInfo
+	for k, v := range c.values {
+		if strings.HasPrefix(k, prefix) {
+			format := c.formats[k]
+			if format == "" {
+				format = "text"
+			}
+			res = append(res, stash.KeyInfo{Key: k, Size: len(v), Format: format, UpdatedAt: time.Unix(1700000000, 0)})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+func (c *fakeClient) GetBytes(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		return nil, stash.ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+func (c *fakeClient) SetWithFormat(_ context.Context, key, value string, format stash.Format) error {
+	c.put(key, value, format.String())
+	return nil
+}
+
+func (c *fakeClient) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; !ok {
+		return stash.ErrNotFound
+	}
+	delete(c.values, key)
+	delete(c.formats, key)
+	return nil
+}
+
+func (c *fakeClient) SubscribePrefix(context.Context, string) (*stash.Subscription, error) {
+	panic("not supported")
+}
+
+func (c *fakeClient) SubscribeAll(context.Context) (*stash.Subscription, error) {
+	panic("not supported")
+}
@@ -0,0 +1,29 @@
+// Package mount exposes stash keys as files of a FUSE filesystem, so programs reading config from files can use
+// stash without changes. Key app/db of the mounted prefix app/ is the file db, key folders are directories. Keys and
+// values are cached until the change feed reports a change, then the kernel caches of the key are invalidated too.
+package mount
+
+import (
+	"context"
+	"time"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// Client is the stash server of the mounted keys, implemented by stash.Client.
+type Client interface {
+	List(ctx context.Context, prefix string) ([]stash.KeyInfo, error)
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+	SetWithFormat(ctx context.Context, key, value string, format stash.Format) error
+	Delete(ctx context.Context, key string) error
+	SubscribePrefix(ctx context.Context, prefix string) (*stash.Subscription, error)
+	SubscribeAll(ctx context.Context) (*stash.Subscription, error)
+}
+
+// Config defines the mounted keys and the mount options.
+type Config struct {
+	Prefix     string        // key prefix mounted as the root, all keys if empty
+	Writable   bool          // allow writing, creating and removing keys, read-only otherwise
+	AllowOther bool          // allow access by other users, needs user_allow_other in /etc/fuse.conf for non-root
+	CacheTTL   time.Duration // kernel cache time of entries and attributes, invalidated earlier on changes
+}
@@ -0,0 +1,563 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// resubscribeDelay is the pause before subscribing again after the change feed failed.
+const resubscribeDelay = 5 * time.Second
+
+// FS is a mounted filesystem of keys.
+type FS struct {
+	cfg    Config
+	cache  *cache
+	client Client
+	root   *dirNode
+	server *fuse.Server
+}
+
+// Mount mounts the keys to the directory. The filesystem is served in background until Unmount.
+func Mount(client Client, dir string, cfg Config) (*FS, error) {
+	if cfg.Prefix = strings.Trim(cfg.Prefix, "/"); cfg.Prefix != "" {
+		cfg.Prefix += "/"
+	}
+	f := &FS{cfg: cfg, client: client, cache: newCache(client, cfg.Prefix)}
+	f.root = &dirNode{fsys: f}
+
+	opts := []string{"ro"}
+	if cfg.Writable {
+		opts = nil
+	}
+	server, err := fs.Mount(dir, f.root, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:      "stash",
+			Name:        "stash",
+			AllowOther:  cfg.AllowOther,
+			Options:     opts,
+			DirectMount: true,
+		},
+		EntryTimeout:    &cfg.CacheTTL,
+		AttrTimeout:     &cfg.CacheTTL,
+		NegativeTimeout: &cfg.CacheTTL,
+		UID:             uint32(os.Getuid()), //nolint:gosec // uid fits uint32
+		GID:             uint32(os.Getgid()), //nolint:gosec // gid fits uint32
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mount %s: %w", dir, err)
+	}
+	f.server = server
+	return f, nil
+}
+
+// Wait waits until the filesystem is unmounted, by Unmount or externally, e.g. with fusermount -u.
+func (f *FS) Wait() {
+	f.server.Wait()
+}
+
+// Unmount unmounts the filesystem and waits for it to stop serving.
+func (f *FS) Unmount() error {
+	if err := f.server.Unmount(); err != nil {
+		return fmt.Errorf("failed to unmount: %w", err)
+	}
+	f.server.Wait()
+	return nil
+}
+
+// Watch follows the change feed of the mounted keys until the context is canceled, dropping the cached state
+// of changed keys. The subscription is renewed if it fails, everything is dropped then as changes could be missed.
+func (f *FS) Watch(ctx context.Context) {
+	for {
+		err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[WARN] change feed failed, cached keys are dropped: %v", err)
+		f.changed("")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// follow applies events of a subscription until it fails or the context is canceled.
+func (f *FS) follow(ctx context.Context) error {
+	var sub *stash.Subscription
+	var err error
+	if f.cfg.Prefix == "" {
+		sub, err = f.client.SubscribeAll(ctx)
+	} else {
+		sub, err = f.client.SubscribePrefix(ctx, strings.TrimSuffix(f.cfg.Prefix, "/"))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Close()
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return errors.New("subscription closed")
+			}
+			if ev.Action == "reset" {
+				f.changed("")
+				continue
+			}
+			if rel, ok := strings.CutPrefix(ev.Key, f.cfg.Prefix); ok && rel != "" {
+				f.changed(rel)
+			}
+		case err, ok := <-sub.Errors():
+			if ok && err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// changed drops the cached state of the key and invalidates its kernel entries, everything for an empty key.
+func (f *FS) changed(rel string) {
+	f.cache.invalidate(rel)
+	if rel == "" {
+		f.invalidateTree(&f.root.Inode)
+		return
+	}
+	// the entry is invalidated at the deepest known directory, a new key can add directories
+	parent := &f.root.Inode
+	parts := strings.Split(rel, "/")
+	for _, name := range parts {
+		child := parent.GetChild(name)
+		if child == nil || !child.IsDir() {
+			if child != nil {
+				_ = child.NotifyContent(0, 0)
+			}
+			_ = parent.NotifyEntry(name)
+			return
+		}
+		parent = child
+	}
+	_ = parent.NotifyContent(0, 0) // a key which became a directory
+}
+
+// invalidateTree invalidates kernel entries and content of all known children of the directory.
+func (f *FS) invalidateTree(dir *fs.Inode) {
+	for name, child := range dir.Children() {
+		if child.IsDir() {
+			f.invalidateTree(child)
+		} else {
+			_ = child.NotifyContent(0, 0)
+		}
+		_ = dir.NotifyEntry(name)
+	}
+}
+
+// child returns the inode of an existing key or directory under the parent.
+func (f *FS) child(ctx context.Context, parent *fs.Inode, rel string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	info, isDir, err := f.cache.stat(ctx, rel)
+	if err != nil {
+		return nil, errno(err)
+	}
+	if isDir {
+		d := &dirNode{fsys: f, rel: rel}
+		d.fillAttr(&out.Attr)
+		return parent.NewInode(ctx, d, fs.StableAttr{Mode: fuse.S_IFDIR, Ino: ino("d", rel)}), 0
+	}
+	n := &fileNode{fsys: f, rel: rel}
+	if e := n.fillAttr(ctx, info, &out.Attr); e != 0 {
+		return nil, e
+	}
+	return parent.NewInode(ctx, n, fs.StableAttr{Mode: fuse.S_IFREG, Ino: ino("f", rel)}), 0
+}
+
+// dirNode is a key folder.
+type dirNode struct {
+	fs.Inode
+	fsys *FS
+	rel  string // path relative to the mounted prefix, empty for the root
+}
+
+var (
+	_ fs.NodeLookuper  = (*dirNode)(nil)
+	_ fs.NodeReaddirer = (*dirNode)(nil)
+	_ fs.NodeGetattrer = (*dirNode)(nil)
+	_ fs.NodeCreater   = (*dirNode)(nil)
+	_ fs.NodeUnlinker  = (*dirNode)(nil)
+	_ fs.NodeMkdirer   = (*dirNode)(nil)
+	_ fs.NodeRmdirer   = (*dirNode)(nil)
+	_ fs.NodeRenamer   = (*dirNode)(nil)
+)
+
+// Lookup finds a key or a folder in the directory.
+func (d *dirNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return d.fsys.child(ctx, &d.Inode, d.path(name), out)
+}
+
+// Readdir lists keys and folders of the directory.
+func (d *dirNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := d.fsys.cache.entries(ctx, d.rel)
+	if err != nil {
+		return nil, errno(err)
+	}
+	res := make([]fuse.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.dir {
+			res = append(res, fuse.DirEntry{Name: e.name, Mode: fuse.S_IFDIR, Ino: ino("d", d.path(e.name))})
+			continue
+		}
+		res = append(res, fuse.DirEntry{Name: e.name, Mode: fuse.S_IFREG, Ino: ino("f", d.path(e.name))})
+	}
+	return fs.NewListDirStream(res), 0
+}
+
+// Getattr returns the directory attributes.
+func (d *dirNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	d.fillAttr(&out.Attr)
+	return 0
+}
+
+// Create makes a new key, stored when the file is closed.
+func (d *dirNode) Create(ctx context.Context, name string, _, _ uint32,
+	out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if e := d.checkWrite(name); e != 0 {
+		return nil, nil, 0, e
+	}
+	rel := d.path(name)
+	if err := d.fsys.cache.set(ctx, rel, nil); err != nil {
+		return nil, nil, 0, errno(err)
+	}
+	n := &fileNode{fsys: d.fsys, rel: rel}
+	if e := n.fillAttr(ctx, stash.KeyInfo{UpdatedAt: time.Now()}, &out.Attr); e != 0 {
+		return nil, nil, 0, e
+	}
+	child := d.NewInode(ctx, n, fs.StableAttr{Mode: fuse.S_IFREG, Ino: ino("f", rel)})
+	h := &handle{}
+	if existing, ok := child.Operations().(*fileNode); ok {
+		existing.addWriter(h) // NewInode returns the known inode of the key, if any
+	}
+	return child, h, 0, 0
+}
+
+// Unlink deletes the key.
+func (d *dirNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	if e := d.checkWrite(name); e != 0 {
+		return e
+	}
+	return errno(d.fsys.cache.remove(ctx, d.path(name)))
+}
+
+// Mkdir makes an empty folder, it exists in memory only until a key is created in it.
+func (d *dirNode) Mkdir(ctx context.Context, name string, _ uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if e := d.checkWrite(name); e != 0 {
+		return nil, e
+	}
+	rel := d.path(name)
+	if _, _, err := d.fsys.cache.stat(ctx, rel); err == nil {
+		return nil, syscall.EEXIST
+	}
+	d.fsys.cache.mkdir(rel)
+	child := &dirNode{fsys: d.fsys, rel: rel}
+	child.fillAttr(&out.Attr)
+	return d.NewInode(ctx, child, fs.StableAttr{Mode: fuse.S_IFDIR, Ino: ino("d", rel)}), 0
+}
+
+// Rmdir removes an empty folder made by Mkdir, folders of keys are removed with their keys.
+func (d *dirNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if e := d.checkWrite(name); e != 0 {
+		return e
+	}
+	return errno(d.fsys.cache.rmdir(ctx, d.path(name)))
+}
+
+// Rename moves a key, editors save files by writing a temporary file and renaming it over the original.
+// The key is copied with its format and then deleted, folders can't be renamed.
+func (d *dirNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, _ uint32) syscall.Errno {
+	if e := d.checkWrite(name); e != 0 {
+		return e
+	}
+	target, ok := newParent.(*dirNode)
+	if !ok {
+		return syscall.EXDEV
+	}
+	if e := target.checkWrite(newName); e != 0 {
+		return e
+	}
+	from, to := d.path(name), target.path(newName)
+	_, isDir, err := d.fsys.cache.stat(ctx, from)
+	if err != nil {
+		return errno(err)
+	}
+	if isDir {
+		return syscall.EPERM
+	}
+	value, err := d.fsys.cache.value(ctx, from)
+	if err != nil {
+		return errno(err)
+	}
+	if err := d.fsys.cache.set(ctx, to, value); err != nil {
+		return errno(err)
+	}
+	return errno(d.fsys.cache.remove(ctx, from))
+}
+
+// checkWrite returns EROFS for a read-only mount, and EPERM for hidden names, so temporary and swap files of
+// editors don't become keys.
+func (d *dirNode) checkWrite(name string) syscall.Errno {
+	if !d.fsys.cfg.Writable {
+		return syscall.EROFS
+	}
+	if strings.HasPrefix(name, ".") {
+		return syscall.EPERM
+	}
+	return 0
+}
+
+func (d *dirNode) path(name string) string {
+	return path.Join(d.rel, name)
+}
+
+func (d *dirNode) fillAttr(out *fuse.Attr) {
+	out.Mode = fuse.S_IFDIR | 0o555
+	if d.fsys.cfg.Writable {
+		out.Mode |= 0o200
+	}
+}
+
+// fileNode is a key.
+type fileNode struct {
+	fs.Inode
+	fsys *FS
+	rel  string // key relative to the mounted prefix
+
+	mu      sync.Mutex
+	writers map[*handle]bool // handles open for writing, truncated by Setattr without a handle
+}
+
+var (
+	_ fs.NodeOpener    = (*fileNode)(nil)
+	_ fs.NodeReader    = (*fileNode)(nil)
+	_ fs.NodeWriter    = (*fileNode)(nil)
+	_ fs.NodeFlusher   = (*fileNode)(nil)
+	_ fs.NodeReleaser  = (*fileNode)(nil)
+	_ fs.NodeGetattrer = (*fileNode)(nil)
+	_ fs.NodeSetattrer = (*fileNode)(nil)
+)
+
+// handle is an open file. Writes go to data, which is stored as the key value on flush if changed.
+type handle struct {
+	mu    sync.Mutex
+	data  []byte
+	dirty bool
+}
+
+// truncate resizes the data of the handle.
+func (h *handle) truncate(size uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.data = resize(h.data, size)
+	h.dirty = true
+}
+
+// Open reads the value, reads of the handle see it unchanged even if the key is changed meanwhile.
+func (n *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	write := flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0
+	if write && !n.fsys.cfg.Writable {
+		return nil, 0, syscall.EROFS
+	}
+	if write && flags&syscall.O_TRUNC != 0 {
+		return n.addWriter(&handle{dirty: true}), 0, 0
+	}
+	value, err := n.fsys.cache.value(ctx, n.rel)
+	if err != nil {
+		return nil, 0, errno(err)
+	}
+	h := &handle{data: value}
+	if write {
+		n.addWriter(h)
+	}
+	return h, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+// Release forgets the closed handle.
+func (n *fileNode) Release(_ context.Context, fh fs.FileHandle) syscall.Errno {
+	if h, ok := fh.(*handle); ok {
+		n.mu.Lock()
+		delete(n.writers, h)
+		n.mu.Unlock()
+	}
+	return 0
+}
+
+// addWriter registers a handle open for writing.
+func (n *fileNode) addWriter(h *handle) *handle {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.writers == nil {
+		n.writers = map[*handle]bool{}
+	}
+	n.writers[h] = true
+	return h
+}
+
+// Read reads the value of the open file.
+func (n *fileNode) Read(_ context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h, ok := fh.(*handle)
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if off >= int64(len(h.data)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	end := min(off+int64(len(dest)), int64(len(h.data)))
+	return fuse.ReadResultData(h.data[off:end]), 0
+}
+
+// Write changes the value of the open file.
+func (n *fileNode) Write(_ context.Context, fh fs.FileHandle, data []byte, off int64) (uint32, syscall.Errno) {
+	h, ok := fh.(*handle)
+	if !ok {
+		return 0, syscall.EBADF
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if end := off + int64(len(data)); end > int64(len(h.data)) {
+		h.data = append(h.data, make([]byte, end-int64(len(h.data)))...)
+	}
+	copy(h.data[off:], data)
+	h.dirty = true
+	return uint32(len(data)), 0 //nolint:gosec // fuse writes are limited to MaxWrite
+}
+
+// Flush stores the value changed through the handle.
+func (n *fileNode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errno {
+	h, ok := fh.(*handle)
+	if !ok {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty {
+		return 0
+	}
+	if err := n.fsys.cache.set(ctx, n.rel, h.data); err != nil {
+		return errno(err)
+	}
+	h.dirty = false
+	return 0
+}
+
+// Getattr returns attributes of the key, the size is of the value.
+func (n *fileNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if h, ok := fh.(*handle); ok && h.dirty {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		n.fillMode(&out.Attr)
+		out.Size = uint64(len(h.data))
+		return 0
+	}
+	info, _, err := n.fsys.cache.stat(ctx, n.rel)
+	if err != nil {
+		return errno(err)
+	}
+	return n.fillAttr(ctx, info, &out.Attr)
+}
+
+// Setattr supports truncation, other attributes are fixed.
+func (n *fileNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	size, ok := in.GetSize()
+	if !ok {
+		return n.Getattr(ctx, fh, out)
+	}
+	if !n.fsys.cfg.Writable {
+		return syscall.EROFS
+	}
+	if h, ok := fh.(*handle); ok {
+		h.truncate(size)
+		return n.Getattr(ctx, fh, out)
+	}
+
+	// open(O_TRUNC) comes as a separate truncate without a handle, the handles open for writing are truncated
+	// too, otherwise their writes would bring the old value back
+	value, err := n.fsys.cache.value(ctx, n.rel)
+	if err != nil {
+		return errno(err)
+	}
+	if err := n.fsys.cache.set(ctx, n.rel, resize(value, size)); err != nil {
+		return errno(err)
+	}
+	n.mu.Lock()
+	for h := range n.writers {
+		h.truncate(size)
+	}
+	n.mu.Unlock()
+	return n.Getattr(ctx, nil, out)
+}
+
+func (n *fileNode) fillMode(out *fuse.Attr) {
+	out.Mode = fuse.S_IFREG | 0o444
+	if n.fsys.cfg.Writable {
+		out.Mode |= 0o200
+	}
+}
+
+func (n *fileNode) fillAttr(ctx context.Context, info stash.KeyInfo, out *fuse.Attr) syscall.Errno {
+	value, err := n.fsys.cache.value(ctx, n.rel)
+	if err != nil {
+		return errno(err)
+	}
+	n.fillMode(out)
+	out.Size = uint64(len(value))
+	out.SetTimes(nil, &info.UpdatedAt, &info.UpdatedAt)
+	return 0
+}
+
+// resize returns a copy of data truncated or zero-extended to size.
+func resize(data []byte, size uint64) []byte {
+	res := make([]byte, size)
+	copy(res, data)
+	return res
+}
+
+// ino returns a stable inode number of a key or a folder, so the kernel sees the same file after invalidation.
+func ino(kind, rel string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(kind + ":" + rel))
+	return max(h.Sum64(), 2) // 1 is the root
+}
+
+// errno converts a client error to the errno returned to the caller, logging unexpected errors.
+func errno(err error) syscall.Errno {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, stash.ErrNotFound):
+		return syscall.ENOENT
+	case errors.Is(err, stash.ErrUnauthorized), errors.Is(err, stash.ErrForbidden):
+		return syscall.EACCES
+	case errors.Is(err, errNotDir):
+		return syscall.ENOTDIR
+	case errors.Is(err, errNotEmpty):
+		return syscall.ENOTEMPTY
+	default:
+		log.Printf("[WARN] %v", err)
+		return syscall.EIO
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !windows
+
+package mount
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMount_ReadOnly(t *testing.T) {
+	client := newFakeClient(map[string]string{"app/db": `{"port":5432}`, "app/conf/a": "1", "other/x": "x"})
+	fsys, dir := mountTest(t, client, Config{Prefix: "app", CacheTTL: time.Minute})
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "conf", entries[0].Name())
+	assert.True(t, entries[0].IsDir())
+	assert.Equal(t, "db", entries[1].Name())
+
+	data, err := os.ReadFile(filepath.Join(dir, "db"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":5432}`, string(data))
+	st, err := os.Stat(filepath.Join(dir, "conf", "a"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), st.Size())
+	assert.Equal(t, os.FileMode(0o444), st.Mode().Perm())
+
+	err = os.WriteFile(filepath.Join(dir, "db"), []byte("x"), 0o600)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, syscall.EROFS) || errors.Is(err, syscall.EACCES), err)
+
+	t.Run("change invalidates caches", func(t *testing.T) {
+		client.put("app/db", `{"port":5433}`, "json")
+		client.put("app/new/key", "v", "text")
+		fsys.changed("db")
+		fsys.changed("new/key")
+		data, err := os.ReadFile(filepath.Join(dir, "db"))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"port":5433}`, string(data))
+		data, err = os.ReadFile(filepath.Join(dir, "new", "key"))
+		require.NoError(t, err)
+		assert.Equal(t, "v", string(data))
+
+		require.NoError(t, client.Delete(t.Context(), "app/new/key"))
+		fsys.changed("new/key")
+		_, err = os.Stat(filepath.Join(dir, "new", "key"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestMount_Writable(t *testing.T) {
+	client := newFakeClient(map[string]string{"app/db": `{"port":5432}`})
+	client.formats["app/db"] = "json"
+	_, dir := mountTest(t, client, Config{Prefix: "app/", Writable: true, CacheTTL: time.Minute})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db"), []byte(`{"port":1}`), 0o600))
+	assert.JSONEq(t, `{"port":1}`, client.value("app/db"))
+	assert.Equal(t, "json", client.format("app/db"), "format kept")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "svc"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc", "name"), []byte("api"), 0o600))
+	assert.Equal(t, "api", client.value("app/svc/name"))
+	assert.Equal(t, "text", client.format("app/svc/name"))
+
+	f, err := os.OpenFile(filepath.Join(dir, "svc", "name"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("-v2")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "api-v2", client.value("app/svc/name"))
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "svc", "name"), filepath.Join(dir, "svc", "title")))
+	assert.Equal(t, "api-v2", client.value("app/svc/title"))
+	assert.Empty(t, client.value("app/svc/name"))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "svc", "title")))
+	assert.Empty(t, client.value("app/svc/title"))
+
+	err = os.WriteFile(filepath.Join(dir, ".db.swp"), []byte("x"), 0o600)
+	assert.ErrorIs(t, err, syscall.EPERM, "hidden files are not keys")
+}
+
+// mountTest mounts the client keys to a temporary directory, skipping the test if FUSE is not available.
+func mountTest(t *testing.T, client Client, cfg Config) (*FS, string) {
+	t.Helper()
+	dir := t.TempDir()
+	fsys, err := Mount(client, dir, cfg)
+	if err != nil {
+		t.Skipf("fuse is not available: %v", err)
+	}
+	t.Cleanup(func() { require.NoError(t, fsys.Unmount()) })
+	return fsys, dir
+}
@@ -0,0 +1,24 @@
+package mount
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotSupported is returned by Mount on Windows, FUSE is not available there.
+var ErrNotSupported = errors.New("mount is not supported on windows")
+
+// FS is a placeholder, keys can't be mounted on Windows.
+type FS struct{}
+
+// Mount always fails with ErrNotSupported.
+func Mount(Client, string, Config) (*FS, error) { return nil, ErrNotSupported }
+
+// Wait returns immediately.
+func (f *FS) Wait() {}
+
+// Unmount is a no-op.
+func (f *FS) Unmount() error { return nil }
+
+// Watch returns immediately.
+func (f *FS) Watch(context.Context) {}
@@ -0,0 +1,156 @@
+// Package scan finds stash key references in source code. It doesn't parse languages, string literals
+// in double quotes, single quotes and backticks are taken from every text file and matched against
+// key patterns, so keys built at runtime from several parts are not found.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxFileSize limits scanned files, larger ones are data rather than source.
+const maxFileSize = 1 << 20
+
+// skipDirs are not scanned, they hold dependencies and repository data rather than own code.
+var skipDirs = map[string]bool{"vendor": true, "node_modules": true}
+
+// literalRe matches single line string literals, escaped quotes included.
+var literalRe = regexp.MustCompile("\"(?:[^\"\\\\\\n]|\\\\.)*\"|'(?:[^'\\\\\\n]|\\\\.)*'|`[^`\\n]*`")
+
+// Ref is a reference to a key in a source file.
+type Ref struct {
+	Key  string
+	File string
+	Line int
+}
+
+// String returns the location of the reference as file:line.
+func (r Ref) String() string {
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// Scanner finds string literals matching key patterns. A pattern with a capture group takes the key
+// from the first group, e.g. `^stash:(.+)$`, otherwise the matched text is the key.
+type Scanner struct {
+	patterns []*regexp.Regexp
+}
+
+// New makes a scanner with the key patterns.
+func New(patterns []string) (*Scanner, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no key patterns")
+	}
+	s := &Scanner{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	return s, nil
+}
+
+// Scan walks the paths and returns key references sorted by key and location. A path ending with "/..."
+// is the same as the directory, directories are always scanned recursively. Hidden directories,
+// vendor and node_modules, binary and large files are skipped.
+func (s *Scanner) Scan(paths []string) ([]Ref, error) {
+	var refs []Ref
+	for _, root := range paths {
+		root = strings.TrimSuffix(strings.TrimSuffix(root, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (skipDirs[name] || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			fileRefs, err := s.scanFile(path)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, fileRefs...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Key != refs[j].Key {
+			return refs[i].Key < refs[j].Key
+		}
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		return refs[i].Line < refs[j].Line
+	})
+	return refs, nil
+}
+
+// scanFile returns key references of a text file, nothing for binary and large files.
+func (s *Scanner) scanFile(path string) ([]Ref, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxFileSize {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // scanned paths are set by the user
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil // binary
+	}
+
+	var refs []Ref
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+	for line := 1; sc.Scan(); line++ {
+		for _, lit := range literalRe.FindAllString(sc.Text(), -1) {
+			if key, ok := s.match(lit[1 : len(lit)-1]); ok {
+				refs = append(refs, Ref{Key: key, File: path, Line: line})
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// match returns the key referenced by the literal content if any pattern matches it.
+func (s *Scanner) match(lit string) (string, bool) {
+	for _, re := range s.patterns {
+		m := re.FindStringSubmatch(lit)
+		switch {
+		case m == nil:
+			continue
+		case len(m) > 1 && m[1] != "":
+			return m[1], true
+		default:
+			return m[0], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanner_Scan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go": "package main\n\nvar host = client.Get(ctx, \"app/db/host\")\n" +
+			"var port = cfg(`app/db/port`) // and \"app/db/host\" again\n",
+		"web/app.js":         "const url = await stash.get('app/api/url');\nconst s = \"escaped \\\"app/nope\\\"\";\n",
+		"config/values.yaml": "db: \"stash:app/db/user\"\nname: plain app/unquoted\n",
+		"vendor/lib/x.go":    "var k = \"app/vendored\"\n",
+		".git/config":        "url = \"app/hidden\"\n",
+		"node_modules/m.js":  "'app/module'\n",
+		"bin/tool":           "\x00\"app/binary\"",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	s, err := New([]string{`^stash:(.+)$`, `^app/[a-z0-9/_.-]+$`})
+	require.NoError(t, err)
+	refs, err := s.Scan([]string{dir + "/..."})
+	require.NoError(t, err)
+	assert.Equal(t, []Ref{
+		{Key: "app/api/url", File: filepath.Join(dir, "web/app.js"), Line: 1},
+		{Key: "app/db/host", File: filepath.Join(dir, "main.go"), Line: 3},
+		{Key: "app/db/host", File: filepath.Join(dir, "main.go"), Line: 4},
+		{Key: "app/db/port", File: filepath.Join(dir, "main.go"), Line: 4},
+		{Key: "app/db/user", File: filepath.Join(dir, "config/values.yaml"), Line: 1},
+	}, refs)
+	assert.Equal(t, filepath.Join(dir, "web/app.js")+":1", refs[0].String())
+
+	refs, err = s.Scan([]string{filepath.Join(dir, "main.go")})
+	require.NoError(t, err)
+	assert.Len(t, refs, 3, "single file")
+
+	_, err = s.Scan([]string{filepath.Join(dir, "missing")})
+	require.ErrorContains(t, err, "failed to scan")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	require.EqualError(t, err, "no key patterns")
+	_, err = New([]string{"("})
+	require.ErrorContains(t, err, `invalid pattern "("`)
+}
@@ -0,0 +1,238 @@
+// Package alert provides basic anomaly detection on top of the audit stream. Each audited request
+// is checked against a small set of rules (bursts of denied requests, bulk secret reads, admin
+// credentials used from a new IP, reads of canary keys, break-glass elevations) and matches are sent to an alerting webhook,
+// through a persisted outbox retrying failed deliveries.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// rule names, reported in Alert.Rule
+const (
+	RuleDeniedBurst = "denied_burst" // too many denied requests from one actor
+	RuleSecretsBulk = "secrets_bulk" // too many secret reads from one actor, looks like an export
+	RuleAdminNewIP  = "admin_new_ip" // admin credentials used from an address not seen before
+	RuleCanaryRead  = "canary_read"  // a canary key was read
+	RuleBreakGlass  = "break_glass"  // a user self-elevated to break-glass permissions
+)
+
+// alert severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// defaults for Config
+const (
+	DefaultWindow   = time.Minute
+	DefaultCooldown = 10 * time.Minute
+	maxAdminIPs     = 100 // known addresses kept per admin actor
+	notifyTimeout   = 10 * time.Second
+)
+
+// Notifier delivers alerts to an external system.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Alert describes a triggered rule.
+type Alert struct {
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Summary  string    `json:"summary"`
+	Actor    string    `json:"actor"`
+	IP       string    `json:"ip,omitempty"`
+	Key      string    `json:"key,omitempty"`   // canary key for canary_read
+	Count    int       `json:"count,omitempty"` // number of matching requests in the window
+	Time     time.Time `json:"time"`
+}
+
+// Config defines the alert rules. Zero limits disable the corresponding rule.
+// Canary reads and break-glass elevations are always alerted on, canaries are marked by the audit logger.
+type Config struct {
+	DeniedLimit int           // denied requests from one actor within Window
+	SecretReads int           // successful secret reads by one actor within Window
+	AdminNewIP  bool          // alert when an admin actor shows up from a new IP
+	Window      time.Duration // sliding window for counting rules, default 1m
+	Cooldown    time.Duration // min interval between alerts for the same rule and actor, default 10m
+}
+
+// Detector evaluates audit entries against the configured rules. State is in memory only,
+// so counters and known admin addresses start from scratch after restart.
+type Detector struct {
+	cfg      Config
+	notifier Notifier
+
+	mu        sync.Mutex
+	denied    map[string][]time.Time     // actor -> timestamps of denied requests
+	secrets   map[string][]time.Time     // actor -> timestamps of secret reads
+	adminIPs  map[string]map[string]bool // admin actor -> known addresses
+	fired     map[string]time.Time       // rule:actor:key -> last alert time
+	lastSweep time.Time
+	wg        sync.WaitGroup
+}
+
+// NewDetector creates a detector sending alerts to the notifier.
+func NewDetector(cfg Config, notifier Notifier) *Detector {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultCooldown
+	}
+	return &Detector{
+		cfg:       cfg,
+		notifier:  notifier,
+		denied:    map[string][]time.Time{},
+		secrets:   map[string][]time.Time{},
+		adminIPs:  map[string]map[string]bool{},
+		fired:     map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Observe checks an audit entry against the rules; admin reports whether the request was made
+// with admin credentials. Matching alerts are sent in the background, Observe doesn't block on delivery.
+func (d *Detector) Observe(entry store.AuditEntry, admin bool) {
+	ts := entry.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	actor := entry.Actor
+	if entry.ActorType == enum.ActorTypePublic {
+		actor = "public@" + entry.IP // anonymous requests are told apart by address
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep(ts)
+
+	var alerts []Alert
+	if d.cfg.DeniedLimit > 0 && entry.Result == enum.AuditResultDenied {
+		if n := d.count(d.denied, actor, ts); n >= d.cfg.DeniedLimit {
+			alerts = append(alerts, Alert{Rule: RuleDeniedBurst, Count: n,
+				Summary: fmt.Sprintf("%d denied requests from %s within %s", n, actor, d.cfg.Window)})
+		}
+	}
+
+	if d.cfg.SecretReads > 0 && entry.Action == enum.AuditActionRead && entry.Result == enum.AuditResultSuccess &&
+		store.IsSecret(entry.Key) {
+		if n := d.count(d.secrets, actor, ts); n >= d.cfg.SecretReads {
+			alerts = append(alerts, Alert{Rule: RuleSecretsBulk, Count: n,
+				Summary: fmt.Sprintf("%d secrets read by %s within %s", n, actor, d.cfg.Window)})
+		}
+	}
+
+	if d.cfg.AdminNewIP && admin && entry.IP != "" && d.newAdminIP(actor, entry.IP) {
+		alerts = append(alerts, Alert{Rule: RuleAdminNewIP,
+			Summary: fmt.Sprintf("admin %s used from new address %s", actor, entry.IP)})
+	}
+
+	if entry.Action == enum.AuditActionCanary {
+		alerts = append(alerts, Alert{Rule: RuleCanaryRead, Severity: SeverityCritical, Key: entry.Key,
+			Summary: fmt.Sprintf("canary key %s read by %s (%s)", entry.Key, actor, entry.Result)})
+	}
+
+	if entry.Action == enum.AuditActionBreakGlass && entry.Result == enum.AuditResultSuccess {
+		alerts = append(alerts, Alert{Rule: RuleBreakGlass, Severity: SeverityCritical,
+			Summary: fmt.Sprintf("break-glass access by %s: %s", actor, entry.Reason)})
+	}
+
+	for _, a := range alerts {
+		key := a.Rule + ":" + actor + ":" + a.Key
+		// every break-glass elevation is reported, no cooldown
+		if last, ok := d.fired[key]; ok && ts.Sub(last) < d.cfg.Cooldown && a.Rule != RuleBreakGlass {
+			continue
+		}
+		d.fired[key] = ts
+		a.Actor, a.IP, a.Time = entry.Actor, entry.IP, ts
+		if a.Severity == "" {
+			a.Severity = SeverityWarning
+		}
+		d.send(a)
+	}
+}
+
+// Wait blocks until alerts sent so far are delivered or failed.
+func (d *Detector) Wait() {
+	d.wg.Wait()
+}
+
+// send delivers the alert in the background.
+func (d *Detector) send(a Alert) {
+	log.Printf("[WARN] alert %s: %s", a.Rule, a.Summary)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := d.notifier.Notify(ctx, a); err != nil {
+			log.Printf("[WARN] failed to send alert %s: %v", a.Rule, err)
+		}
+	}()
+}
+
+// count records an event for the actor and returns the number of events within the window.
+func (d *Detector) count(events map[string][]time.Time, actor string, ts time.Time) int {
+	list := append(prune(events[actor], ts.Add(-d.cfg.Window)), ts)
+	events[actor] = list
+	return len(list)
+}
+
+// newAdminIP records the address for an admin actor and reports whether it is new. The first
+// address seen for an actor is the baseline and doesn't trigger.
+func (d *Detector) newAdminIP(actor, ip string) bool {
+	known, ok := d.adminIPs[actor]
+	if !ok {
+		d.adminIPs[actor] = map[string]bool{ip: true}
+		return false
+	}
+	if known[ip] {
+		return false
+	}
+	if len(known) < maxAdminIPs {
+		known[ip] = true
+	}
+	return true
+}
+
+// sweep drops stale counters and cooldowns, at most once per window.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.cfg.Window {
+		return
+	}
+	d.lastSweep = now
+	cutoff := now.Add(-d.cfg.Window)
+	for _, events := range []map[string][]time.Time{d.denied, d.secrets} {
+		for actor, list := range events {
+			if list = prune(list, cutoff); len(list) == 0 {
+				delete(events, actor)
+				continue
+			}
+			events[actor] = list
+		}
+	}
+	for key, ts := range d.fired {
+		if now.Sub(ts) >= d.cfg.Cooldown {
+			delete(d.fired, key)
+		}
+	}
+}
+
+// prune removes timestamps not after the cutoff, list is ordered by time.
+func prune(list []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(list) && !list[i].After(cutoff) {
+		i++
+	}
+	return list[i:]
+}
@@ -0,0 +1,196 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// notifierRecorder collects notified alerts.
+type notifierRecorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (n *notifierRecorder) Notify(_ context.Context, a Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+	return n.err
+}
+
+func (n *notifierRecorder) rules() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	res := make([]string, 0, len(n.alerts))
+	for _, a := range n.alerts {
+		res = append(res, a.Rule+":"+a.Actor)
+	}
+	return res
+}
+
+func TestDetector_DeniedBurst(t *testing.T) {
+	rec := &notifierRecorder{}
+	d := NewDetector(Config{DeniedLimit: 3}, rec)
+	start := time.Now()
+
+	denied := func(actor string, at time.Duration) {
+		d.Observe(store.AuditEntry{Timestamp: start.Add(at), Actor: actor, ActorType: enum.ActorTypeToken,
+			Action: enum.AuditActionRead, Result: enum.AuditResultDenied, Key: "app/x", IP: "10.0.0.1"}, false)
+	}
+	denied("token:aaaa****", 0)
+	denied("token:aaaa****", 10*time.Second)
+	denied("token:bbbb****", 20*time.Second)
+	d.Observe(store.AuditEntry{Timestamp: start.Add(25 * time.Second), Actor: "token:aaaa****",
+		ActorType: enum.ActorTypeToken, Result: enum.AuditResultSuccess}, false) // successful requests don't count
+	d.Wait()
+	assert.Empty(t, rec.rules())
+
+	denied("token:aaaa****", 30*time.Second)
+	d.Wait()
+	require.Equal(t, []string{"denied_burst:token:aaaa****"}, rec.rules())
+	assert.Equal(t, SeverityWarning, rec.alerts[0].Severity)
+	assert.Equal(t, 3, rec.alerts[0].Count)
+	assert.Equal(t, "10.0.0.1", rec.alerts[0].IP)
+	assert.Contains(t, rec.alerts[0].Summary, "3 denied requests from token:aaaa****")
+
+	// still over the limit, but within cooldown
+	denied("token:aaaa****", 40*time.Second)
+	d.Wait()
+	assert.Len(t, rec.rules(), 1)
+
+	// first events left the window
+	denied("token:bbbb****", 90*time.Second)
+	denied("token:bbbb****", 95*time.Second)
+	d.Wait()
+	assert.Len(t, rec.rules(), 1, "b has 2 denied within a minute, first one expired")
+
+	t.Run("public actors are counted per address", func(t *testing.T) {
+		rec := &notifierRecorder{}
+		d := NewDetector(Config{DeniedLimit: 2}, rec)
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.1"} {
+			d.Observe(store.AuditEntry{Actor: "anonymous", ActorType: enum.ActorTypePublic, IP: ip,
+				Result: enum.AuditResultDenied}, false)
+		}
+		d.Wait()
+		require.Len(t, rec.alerts, 1)
+		assert.Equal(t, "1.1.1.1", rec.alerts[0].IP)
+		assert.Contains(t, rec.alerts[0].Summary, "public@1.1.1.1")
+	})
+}
+
+func TestDetector_SecretsBulk(t *testing.T) {
+	rec := &notifierRecorder{}
+	d := NewDetector(Config{SecretReads: 2, Cooldown: time.Minute}, rec)
+	start := time.Now()
+
+	read := func(key string, at time.Duration, result enum.AuditResult) {
+		d.Observe(store.AuditEntry{Timestamp: start.Add(at), Actor: "alice", ActorType: enum.ActorTypeUser,
+			Action: enum.AuditActionRead, Result: result, Key: key}, false)
+	}
+	read("secrets/db", 0, enum.AuditResultSuccess)
+	read("app/config", time.Second, enum.AuditResultSuccess)     // not a secret
+	read("secrets/api", 2*time.Second, enum.AuditResultNotFound) // failed read
+	d.Wait()
+	assert.Empty(t, rec.rules())
+
+	read("app/secrets/token", 3*time.Second, enum.AuditResultSuccess)
+	d.Wait()
+	assert.Equal(t, []string{"secrets_bulk:alice"}, rec.rules())
+
+	// cooldown expired, still reading in bulk
+	read("secrets/a", 70*time.Second, enum.AuditResultSuccess)
+	read("secrets/b", 71*time.Second, enum.AuditResultSuccess)
+	d.Wait()
+	assert.Equal(t, []string{"secrets_bulk:alice", "secrets_bulk:alice"}, rec.rules())
+}
+
+func TestDetector_AdminNewIP(t *testing.T) {
+	rec := &notifierRecorder{err: errors.New("delivery failed")} // failures are logged only
+	d := NewDetector(Config{AdminNewIP: true}, rec)
+
+	use := func(actor, ip string, admin bool) {
+		d.Observe(store.AuditEntry{Actor: actor, ActorType: enum.ActorTypeToken, IP: ip,
+			Action: enum.AuditActionRead, Result: enum.AuditResultSuccess}, admin)
+	}
+	use("token:adm1****", "10.0.0.1", true) // baseline
+	use("token:adm1****", "10.0.0.1", true)
+	use("token:user****", "10.0.0.1", false)
+	use("token:user****", "10.0.0.9", false) // not an admin
+	d.Wait()
+	assert.Empty(t, rec.rules())
+
+	use("token:adm1****", "10.0.0.2", true)
+	use("token:adm1****", "10.0.0.2", true) // known now
+	d.Wait()
+	require.Equal(t, []string{"admin_new_ip:token:adm1****"}, rec.rules())
+	assert.Equal(t, "10.0.0.2", rec.alerts[0].IP)
+}
+
+func TestDetector_CanaryRead(t *testing.T) {
+	rec := &notifierRecorder{}
+	d := NewDetector(Config{}, rec) // canary alerts don't depend on rule config
+
+	read := func(actor, key string, result enum.AuditResult) {
+		d.Observe(store.AuditEntry{Actor: actor, ActorType: enum.ActorTypeToken, IP: "10.0.0.7",
+			Action: enum.AuditActionCanary, Result: result, Key: key}, false)
+	}
+	read("token:leak****", "honeypot/db", enum.AuditResultSuccess)
+	read("token:leak****", "honeypot/db", enum.AuditResultSuccess) // same actor and key, within cooldown
+	read("token:leak****", "honeypot/api", enum.AuditResultDenied)
+	d.Observe(store.AuditEntry{Actor: "token:leak****", Action: enum.AuditActionRead, Key: "app/x"}, false)
+	d.Wait()
+
+	require.Equal(t, []string{"canary_read:token:leak****", "canary_read:token:leak****"}, rec.rules())
+	summaries := []string{}
+	for _, a := range rec.alerts {
+		assert.Equal(t, SeverityCritical, a.Severity)
+		summaries = append(summaries, a.Summary)
+	}
+	assert.ElementsMatch(t, []string{"canary key honeypot/db read by token:leak**** (success)",
+		"canary key honeypot/api read by token:leak**** (denied)"}, summaries)
+}
+
+func TestDetector_BreakGlass(t *testing.T) {
+	rec := &notifierRecorder{}
+	d := NewDetector(Config{}, rec)
+
+	elevate := func(reason string, result enum.AuditResult) {
+		d.Observe(store.AuditEntry{Actor: "oncall", ActorType: enum.ActorTypeUser, IP: "10.0.0.9",
+			Action: enum.AuditActionBreakGlass, Result: result, Key: "*", Reason: reason}, false)
+	}
+	elevate("db is down", enum.AuditResultSuccess)
+	elevate("still down", enum.AuditResultSuccess) // no cooldown for break-glass
+	elevate("not allowed", enum.AuditResultDenied)
+	d.Wait()
+
+	require.Equal(t, []string{"break_glass:oncall", "break_glass:oncall"}, rec.rules())
+	summaries := []string{}
+	for _, a := range rec.alerts {
+		assert.Equal(t, SeverityCritical, a.Severity)
+		summaries = append(summaries, a.Summary)
+	}
+	assert.ElementsMatch(t, []string{"break-glass access by oncall: db is down",
+		"break-glass access by oncall: still down"}, summaries)
+}
+
+func TestDetector_Sweep(t *testing.T) {
+	d := NewDetector(Config{DeniedLimit: 10, Window: time.Minute, Cooldown: time.Minute}, &notifierRecorder{})
+	start := time.Now()
+	d.Observe(store.AuditEntry{Timestamp: start, Actor: "a", Result: enum.AuditResultDenied}, false)
+	d.fired["x:a"] = start
+	assert.Len(t, d.denied, 1)
+
+	d.Observe(store.AuditEntry{Timestamp: start.Add(2 * time.Minute), Actor: "b", Result: enum.AuditResultSuccess}, false)
+	assert.Empty(t, d.denied)
+	assert.Empty(t, d.fired)
+}
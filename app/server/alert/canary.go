@@ -0,0 +1,57 @@
+package alert
+
+import (
+	"strings"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Canaries matches keys marked as canaries (honeypots). Nobody legitimate is expected to read
+// them, so any read is a sign of leaked credentials or an over-broad token being explored.
+type Canaries struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// NewCanaries creates a matcher from key patterns: an exact key or a prefix ending with "*",
+// e.g. "secrets/aws/root-key" or "honeypot/*". Returns nil if there are no patterns.
+func NewCanaries(patterns []string) *Canaries {
+	c := &Canaries{keys: map[string]bool{}}
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(strings.TrimSpace(p), "*"); ok {
+			norm := store.NormalizeKey(prefix)
+			if norm == "" {
+				continue // a bare "*" would turn every key into a canary
+			}
+			if strings.HasSuffix(prefix, "/") {
+				norm += "/" // keep "app/*" from matching "application"
+			}
+			c.prefixes = append(c.prefixes, norm)
+			continue
+		}
+		if key := store.NormalizeKey(p); key != "" {
+			c.keys[key] = true
+		}
+	}
+	if len(c.keys) == 0 && len(c.prefixes) == 0 {
+		return nil
+	}
+	return c
+}
+
+// IsCanary reports whether the key is a canary. Safe to call on nil.
+func (c *Canaries) IsCanary(key string) bool {
+	if c == nil {
+		return false
+	}
+	key = store.NormalizeKey(key)
+	if c.keys[key] {
+		return true
+	}
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
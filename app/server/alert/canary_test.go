@@ -0,0 +1,32 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanaries(t *testing.T) {
+	c := NewCanaries([]string{"secrets/aws/root-key", " honeypot/* ", "/legacy/db ", "svc*", "*", ""})
+
+	for key, want := range map[string]bool{
+		"secrets/aws/root-key":  true,
+		"/secrets/aws/root-key": true,
+		"secrets/aws/other":     false,
+		"honeypot/a":            true,
+		"honeypot/a/b":          true,
+		"honeypot":              false,
+		"honeypotter":           false,
+		"legacy/db":             true,
+		"svc":                   true,
+		"svc-billing/x":         true,
+		"app/config":            false,
+	} {
+		assert.Equal(t, want, c.IsCanary(key), key)
+	}
+
+	assert.Nil(t, NewCanaries(nil))
+	assert.Nil(t, NewCanaries([]string{"*", " "}))
+	var empty *Canaries
+	assert.False(t, empty.IsCanary("anything"))
+}
@@ -0,0 +1,85 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/access"
+	"github.com/umputun/stash/app/store"
+)
+
+// Auth defines the interface for admin checks on dead letter requests.
+type Auth interface {
+	GetRequestActor(r *http.Request) (actorType, actorName string)
+	IsRequestAdmin(r *http.Request) bool
+}
+
+// Handler serves the admin endpoints listing and redriving dead letters of the outbox.
+type Handler struct {
+	outbox *Outbox
+	auth   Auth
+}
+
+// NewHandler creates a dead letter handler.
+func NewHandler(outbox *Outbox, authSvc Auth) *Handler {
+	return &Handler{outbox: outbox, auth: authSvc}
+}
+
+// DeadLetter is an alert delivery which ran out of attempts.
+type DeadLetter struct {
+	ID        int64           `json:"id"`
+	Alert     json.RawMessage `json:"alert"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// HandleDeadLetters lists dead letters, oldest first (admin only).
+// GET /alerts/dead-letters
+func (h *Handler) HandleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	deliveries, err := h.outbox.DeadLetters(r.Context())
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get dead letters")
+		return
+	}
+	res := make([]DeadLetter, 0, len(deliveries))
+	for _, d := range deliveries {
+		res = append(res, DeadLetter{ID: d.ID, Alert: d.Payload, Attempts: d.Attempts, LastError: d.LastError,
+			CreatedAt: d.CreatedAt})
+	}
+	rest.RenderJSON(w, res)
+}
+
+// HandleRedrive schedules a dead letter for delivery again (admin only).
+// POST /alerts/dead-letters/{id}/redrive
+func (h *Handler) HandleRedrive(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid id")
+		return
+	}
+	err = h.outbox.Redrive(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "dead letter not found")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to redrive")
+		return
+	}
+	_, actor := h.auth.GetRequestActor(r)
+	log.Printf("[INFO] alert dead letter %d redriven by %s", id, actor)
+	rest.RenderJSON(w, rest.JSON{"id": id, "status": "pending"})
+}
@@ -0,0 +1,65 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAuth treats "Bearer admin" as admin, other tokens as regular ones and requests without a token as public.
+type tokenAuth struct{}
+
+func (tokenAuth) GetRequestActor(r *http.Request) (actorType, actorName string) {
+	if r.Header.Get("Authorization") == "" {
+		return "public", ""
+	}
+	return "token", "token:xxxx****"
+}
+
+func (tokenAuth) IsRequestAdmin(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer admin"
+}
+
+func TestHandler(t *testing.T) {
+	st := newMemOutbox()
+	notifier := &notifierRecorder{err: errors.New("webhook down")}
+	o := NewOutbox(st, notifier, OutboxConfig{MaxAttempts: 1})
+	require.NoError(t, o.Notify(t.Context(), Alert{Rule: RuleCanaryRead, Actor: "alice", Time: time.Unix(0, 0).UTC()}))
+	o.deliverDue(t.Context())
+	h := NewHandler(o, tokenAuth{})
+
+	call := func(handler http.HandlerFunc, method, path, id, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		req.SetPathValue("id", id)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call(h.HandleDeadLetters, http.MethodGet, "/alerts/dead-letters", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, call(h.HandleDeadLetters, http.MethodGet, "/alerts/dead-letters", "", "user").Code)
+	assert.Equal(t, http.StatusForbidden, call(h.HandleRedrive, http.MethodPost, "/alerts/dead-letters/1/redrive", "1", "user").Code)
+
+	rec := call(h.HandleDeadLetters, http.MethodGet, "/alerts/dead-letters", "", "admin")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":1,"alert":{"rule":"canary_read"`)
+	assert.Contains(t, rec.Body.String(), `"attempts":1,"last_error":"webhook down"`)
+
+	assert.Equal(t, http.StatusBadRequest, call(h.HandleRedrive, http.MethodPost, "/alerts/dead-letters/x/redrive", "x", "admin").Code)
+	assert.Equal(t, http.StatusNotFound, call(h.HandleRedrive, http.MethodPost, "/alerts/dead-letters/2/redrive", "2", "admin").Code)
+	rec = call(h.HandleRedrive, http.MethodPost, "/alerts/dead-letters/1/redrive", "1", "admin")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":1,"status":"pending"}`, rec.Body.String())
+
+	rec = call(h.HandleDeadLetters, http.MethodGet, "/alerts/dead-letters", "", "admin")
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, call(NewHandler(o, nil).HandleDeadLetters, http.MethodGet, "/", "", "admin").Code)
+}
@@ -0,0 +1,177 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// OutboxStore persists webhook deliveries.
+type OutboxStore interface {
+	EnqueueDelivery(ctx context.Context, payload []byte) (int64, error)
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]store.Delivery, error)
+	DeadDeliveries(ctx context.Context) ([]store.Delivery, error)
+	CompleteDelivery(ctx context.Context, id int64) error
+	FailDelivery(ctx context.Context, id int64, lastErr string, next time.Time, dead bool) error
+	RedriveDelivery(ctx context.Context, id int64) error
+}
+
+// defaults for OutboxConfig
+const (
+	DefaultMaxAttempts  = 10
+	DefaultMinBackoff   = 5 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultPollInterval = 5 * time.Second
+	outboxBatch         = 100 // deliveries attempted per poll
+)
+
+// OutboxConfig defines retries of persisted deliveries, zero values select the defaults.
+type OutboxConfig struct {
+	MaxAttempts  int           // failed attempts before a delivery is dead
+	MinBackoff   time.Duration // delay after the first failure, doubled after each next one
+	MaxBackoff   time.Duration // max delay between attempts
+	PollInterval time.Duration // how often due deliveries are checked
+}
+
+// Outbox is a Notifier persisting alerts and delivering them to the wrapped notifier at least once.
+// Failed deliveries are retried with exponential backoff and become dead letters after MaxAttempts,
+// dead letters are kept until redriven. With several instances sharing a database an alert may be
+// delivered more than once.
+type Outbox struct {
+	store    OutboxStore
+	notifier Notifier
+	cfg      OutboxConfig
+	wake     chan struct{}
+}
+
+// NewOutbox creates an outbox delivering to the notifier, Run must be started to deliver.
+func NewOutbox(st OutboxStore, notifier Notifier, cfg OutboxConfig) *Outbox {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	return &Outbox{store: st, notifier: notifier, cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Notify persists the alert and wakes up the delivery loop.
+func (o *Outbox) Notify(ctx context.Context, a Alert) error {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+	if _, err := o.store.EnqueueDelivery(ctx, payload); err != nil {
+		return fmt.Errorf("persist alert: %w", err)
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers due alerts until the context is canceled. Deliveries left from a previous run
+// are picked up on start.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		o.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// DeadLetters returns deliveries which ran out of attempts.
+func (o *Outbox) DeadLetters(ctx context.Context) ([]store.Delivery, error) {
+	res, err := o.store.DeadDeliveries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get dead letters: %w", err)
+	}
+	return res, nil
+}
+
+// Redrive schedules a dead letter for delivery again with fresh attempts.
+// Returns store.ErrNotFound if there is no dead letter with the id.
+func (o *Outbox) Redrive(ctx context.Context, id int64) error {
+	if err := o.store.RedriveDelivery(ctx, id); err != nil {
+		return fmt.Errorf("redrive %d: %w", id, err)
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// deliverDue attempts all due deliveries, one at a time and in order.
+func (o *Outbox) deliverDue(ctx context.Context) {
+	due, err := o.store.DueDeliveries(ctx, time.Now(), outboxBatch)
+	if err != nil {
+		log.Printf("[WARN] failed to get due alert deliveries: %v", err)
+		return
+	}
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		o.deliver(ctx, d)
+	}
+}
+
+// deliver sends a single delivery and records the outcome. A payload which can't be decoded
+// is never delivered and goes to dead letters right away.
+func (o *Outbox) deliver(ctx context.Context, d store.Delivery) {
+	var a Alert
+	broken := json.Unmarshal(d.Payload, &a)
+	err := broken
+	if broken == nil {
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err = o.notifier.Notify(nctx, a)
+		cancel()
+	}
+	if err == nil {
+		if cerr := o.store.CompleteDelivery(ctx, d.ID); cerr != nil {
+			log.Printf("[WARN] alert delivery %d sent, but not completed and will be sent again: %v", d.ID, cerr)
+		}
+		return
+	}
+
+	attempts := d.Attempts + 1
+	dead := attempts >= o.cfg.MaxAttempts || broken != nil
+	next := time.Now().Add(o.backoff(attempts))
+	if dead {
+		log.Printf("[ERROR] alert delivery %d (%s) failed after %d attempts, moved to dead letters: %v", d.ID, a.Rule, attempts, err)
+	} else {
+		log.Printf("[WARN] alert delivery %d (%s) failed, attempt %d of %d, retry at %s: %v", d.ID, a.Rule, attempts,
+			o.cfg.MaxAttempts, next.Format(time.RFC3339), err)
+	}
+	if ferr := o.store.FailDelivery(ctx, d.ID, err.Error(), next, dead); ferr != nil {
+		log.Printf("[WARN] failed to record alert delivery %d failure: %v", d.ID, ferr)
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.cfg.MinBackoff
+	for i := 1; i < attempts && delay < o.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, o.cfg.MaxBackoff)
+}
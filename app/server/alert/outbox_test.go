@@ -0,0 +1,181 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// memOutbox keeps deliveries in memory.
+type memOutbox struct {
+	mu     sync.Mutex
+	nextID int64
+	items  map[int64]*store.Delivery
+}
+
+func newMemOutbox() *memOutbox {
+	return &memOutbox{items: map[int64]*store.Delivery{}}
+}
+
+func (m *memOutbox) EnqueueDelivery(_ context.Context, payload []byte) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	m.items[m.nextID] = &store.Delivery{ID: m.nextID, Payload: payload, NextAttempt: time.Now(), CreatedAt: time.Now()}
+	return m.nextID, nil
+}
+
+func (m *memOutbox) DueDeliveries(_ context.Context, now time.Time, limit int) ([]store.Delivery, error) {
+	return m.list(func(d *store.Delivery) bool { return !d.Dead && !d.NextAttempt.After(now) }, limit), nil
+}
+
+func (m *memOutbox) DeadDeliveries(context.Context) ([]store.Delivery, error) {
+	return m.list(func(d *store.Delivery) bool { return d.Dead }, 0), nil
+}
+
+func (m *memOutbox) CompleteDelivery(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, id)
+	return nil
+}
+
+func (m *memOutbox) FailDelivery(_ context.Context, id int64, lastErr string, next time.Time, dead bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.items[id]
+	d.Attempts++
+	d.LastError, d.NextAttempt, d.Dead = lastErr, next, dead
+	return nil
+}
+
+func (m *memOutbox) RedriveDelivery(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.items[id]
+	if !ok || !d.Dead {
+		return store.ErrNotFound
+	}
+	d.Dead, d.Attempts, d.NextAttempt = false, 0, time.Now()
+	return nil
+}
+
+func (m *memOutbox) list(match func(d *store.Delivery) bool, limit int) []store.Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []store.Delivery
+	for _, d := range m.items {
+		if match(d) {
+			res = append(res, *d)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// makeDue moves retries of all pending deliveries to now.
+func (m *memOutbox) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.items {
+		d.NextAttempt = time.Now()
+	}
+}
+
+func TestOutbox_Deliver(t *testing.T) {
+	st := newMemOutbox()
+	notifier := &notifierRecorder{err: errors.New("webhook down")}
+	o := NewOutbox(st, notifier, OutboxConfig{MaxAttempts: 3, MinBackoff: time.Minute})
+
+	require.NoError(t, o.Notify(t.Context(), Alert{Rule: RuleCanaryRead, Actor: "alice"}))
+	assert.Empty(t, notifier.rules(), "notify only persists")
+
+	o.deliverDue(t.Context())
+	assert.Equal(t, []string{"canary_read:alice"}, notifier.rules())
+	o.deliverDue(t.Context())
+	assert.Len(t, notifier.rules(), 1, "retry is not due yet")
+
+	st.makeDue()
+	o.deliverDue(t.Context())
+	st.makeDue()
+	o.deliverDue(t.Context())
+	assert.Len(t, notifier.rules(), 3)
+	dead, err := o.DeadLetters(t.Context())
+	require.NoError(t, err)
+	require.Len(t, dead, 1, "dead after max attempts")
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, "webhook down", dead[0].LastError)
+
+	st.makeDue()
+	o.deliverDue(t.Context())
+	assert.Len(t, notifier.rules(), 3, "dead letters are not retried")
+
+	// webhook is back, redrive delivers it
+	notifier.mu.Lock()
+	notifier.err = nil
+	notifier.mu.Unlock()
+	require.ErrorIs(t, o.Redrive(t.Context(), 42), store.ErrNotFound)
+	require.NoError(t, o.Redrive(t.Context(), dead[0].ID))
+	o.deliverDue(t.Context())
+	assert.Len(t, notifier.rules(), 4)
+	assert.Empty(t, st.items, "delivered alert is removed")
+}
+
+func TestOutbox_BrokenPayload(t *testing.T) {
+	st := newMemOutbox()
+	notifier := &notifierRecorder{}
+	o := NewOutbox(st, notifier, OutboxConfig{})
+	_, err := st.EnqueueDelivery(t.Context(), []byte("not json"))
+	require.NoError(t, err)
+
+	o.deliverDue(t.Context())
+	assert.Empty(t, notifier.rules())
+	dead, err := o.DeadLetters(t.Context())
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 1, dead[0].Attempts)
+}
+
+func TestOutbox_Run(t *testing.T) {
+	st := newMemOutbox()
+	notifier := &notifierRecorder{}
+	o := NewOutbox(st, notifier, OutboxConfig{PollInterval: time.Hour})
+	_, err := st.EnqueueDelivery(t.Context(), []byte(`{"rule":"left_from_previous_run"}`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(notifier.rules()) == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, o.Notify(t.Context(), Alert{Rule: RuleBreakGlass, Actor: "bob"}))
+	require.Eventually(t, func() bool { return len(notifier.rules()) == 2 }, time.Second, 10*time.Millisecond,
+		"notify wakes up delivery")
+	assert.Equal(t, []string{"left_from_previous_run:", "break_glass:bob"}, notifier.rules())
+
+	cancel()
+	<-done
+}
+
+func TestOutbox_Backoff(t *testing.T) {
+	o := NewOutbox(newMemOutbox(), &notifierRecorder{}, OutboxConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	assert.Equal(t, time.Second, o.backoff(1))
+	assert.Equal(t, 2*time.Second, o.backoff(2))
+	assert.Equal(t, 8*time.Second, o.backoff(4))
+	assert.Equal(t, 10*time.Second, o.backoff(5))
+	assert.Equal(t, 10*time.Second, o.backoff(100))
+}
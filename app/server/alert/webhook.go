@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhook payload formats
+const (
+	FormatJSON      = "json"      // Alert as is
+	FormatPagerDuty = "pagerduty" // PagerDuty Events API v2
+	FormatOpsgenie  = "opsgenie"  // Opsgenie Alert API
+)
+
+// default endpoints for the vendor formats
+const (
+	pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// Webhook posts alerts to an HTTP endpoint in one of the supported formats.
+type Webhook struct {
+	url    string
+	format string
+	key    string // PagerDuty routing key or Opsgenie API key
+	client *http.Client
+}
+
+// NewWebhook creates a webhook notifier. An empty url selects the vendor endpoint for the
+// pagerduty and opsgenie formats; those formats also require the integration key.
+func NewWebhook(url, format, key string) (*Webhook, error) {
+	switch format {
+	case "", FormatJSON:
+		format = FormatJSON
+		if url == "" {
+			return nil, fmt.Errorf("webhook url is required for %s format", format)
+		}
+	case FormatPagerDuty, FormatOpsgenie:
+		if key == "" {
+			return nil, fmt.Errorf("integration key is required for %s format", format)
+		}
+		if url == "" {
+			url = map[string]string{FormatPagerDuty: pagerDutyURL, FormatOpsgenie: opsgenieURL}[format]
+		}
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+	return &Webhook{url: url, format: format, key: key, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Notify sends the alert, non-2xx responses are reported as errors.
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(w.payload(a))
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.format == FormatOpsgenie {
+		req.Header.Set("Authorization", "GenieKey "+w.key)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alert webhook returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// payload builds the request body for the configured format. Rule, actor and canary key form
+// the dedup key, so repeated alerts for the same actor are grouped into one incident.
+func (w *Webhook) payload(a Alert) any {
+	dedup := "stash:" + a.Rule + ":" + a.Actor
+	if a.Key != "" {
+		dedup += ":" + a.Key
+	}
+	details := map[string]any{"rule": a.Rule, "actor": a.Actor, "ip": a.IP, "key": a.Key, "count": a.Count}
+	switch w.format {
+	case FormatPagerDuty:
+		return map[string]any{
+			"routing_key":  w.key,
+			"event_action": "trigger",
+			"dedup_key":    dedup,
+			"payload": map[string]any{
+				"summary":        a.Summary,
+				"source":         "stash",
+				"severity":       a.Severity, // warning and critical are valid PagerDuty severities
+				"timestamp":      a.Time.UTC().Format(time.RFC3339),
+				"component":      "audit",
+				"custom_details": details,
+			},
+		}
+	case FormatOpsgenie:
+		priority := "P3"
+		if a.Severity == SeverityCritical {
+			priority = "P1"
+		}
+		return map[string]any{
+			"message":  a.Summary,
+			"alias":    dedup,
+			"source":   "stash",
+			"priority": priority,
+			"tags":     []string{"stash", a.Rule},
+			"details": map[string]string{"rule": a.Rule, "actor": a.Actor, "ip": a.IP, "key": a.Key,
+				"count": fmt.Sprint(a.Count), "time": a.Time.UTC().Format(time.RFC3339)},
+		}
+	default:
+		return a
+	}
+}
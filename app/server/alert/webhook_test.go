@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhook(t *testing.T) {
+	w, err := NewWebhook("", FormatPagerDuty, "routing-key")
+	require.NoError(t, err)
+	assert.Equal(t, pagerDutyURL, w.url)
+
+	w, err = NewWebhook("", FormatOpsgenie, "api-key")
+	require.NoError(t, err)
+	assert.Equal(t, opsgenieURL, w.url)
+
+	w, err = NewWebhook("http://example.com/hook", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, w.format)
+
+	_, err = NewWebhook("", FormatJSON, "")
+	require.Error(t, err)
+	_, err = NewWebhook("http://example.com/hook", FormatPagerDuty, "")
+	require.Error(t, err)
+	_, err = NewWebhook("http://example.com/hook", "slack", "")
+	require.Error(t, err)
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	alert := Alert{Rule: RuleDeniedBurst, Severity: SeverityWarning, Summary: "20 denied requests",
+		Actor: "token:abcd****", IP: "10.0.0.1", Count: 20, Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	var gotBody map[string]any
+	var gotAuth string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = nil
+		require.NoError(t, json.Unmarshal(body, &gotBody))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("bad key\n"))
+	}))
+	defer srv.Close()
+
+	t.Run("json", func(t *testing.T) {
+		w, err := NewWebhook(srv.URL, FormatJSON, "")
+		require.NoError(t, err)
+		require.NoError(t, w.Notify(context.Background(), alert))
+		assert.Equal(t, "denied_burst", gotBody["rule"])
+		assert.Equal(t, "token:abcd****", gotBody["actor"])
+		assert.InDelta(t, 20, gotBody["count"], 0)
+		assert.Empty(t, gotAuth)
+	})
+
+	t.Run("pagerduty", func(t *testing.T) {
+		w, err := NewWebhook(srv.URL, FormatPagerDuty, "rk")
+		require.NoError(t, err)
+		require.NoError(t, w.Notify(context.Background(), alert))
+		assert.Equal(t, "rk", gotBody["routing_key"])
+		assert.Equal(t, "trigger", gotBody["event_action"])
+		assert.Equal(t, "stash:denied_burst:token:abcd****", gotBody["dedup_key"])
+		payload := gotBody["payload"].(map[string]any)
+		assert.Equal(t, "20 denied requests", payload["summary"])
+		assert.Equal(t, "2025-01-02T03:04:05Z", payload["timestamp"])
+		assert.Equal(t, "warning", payload["severity"])
+	})
+
+	t.Run("opsgenie", func(t *testing.T) {
+		w, err := NewWebhook(srv.URL, FormatOpsgenie, "gk")
+		require.NoError(t, err)
+		require.NoError(t, w.Notify(context.Background(), alert))
+		assert.Equal(t, "GenieKey gk", gotAuth)
+		assert.Equal(t, "20 denied requests", gotBody["message"])
+		assert.Equal(t, "P3", gotBody["priority"])
+		assert.Equal(t, "stash:denied_burst:token:abcd****", gotBody["alias"])
+		assert.Equal(t, "10.0.0.1", gotBody["details"].(map[string]any)["ip"])
+	})
+
+	t.Run("canary alert severity", func(t *testing.T) {
+		canary := Alert{Rule: RuleCanaryRead, Severity: SeverityCritical, Summary: "canary read", Actor: "alice",
+			Key: "honeypot/db", Time: alert.Time}
+
+		w, err := NewWebhook(srv.URL, FormatPagerDuty, "rk")
+		require.NoError(t, err)
+		require.NoError(t, w.Notify(context.Background(), canary))
+		assert.Equal(t, "stash:canary_read:alice:honeypot/db", gotBody["dedup_key"])
+		assert.Equal(t, "critical", gotBody["payload"].(map[string]any)["severity"])
+
+		w, err = NewWebhook(srv.URL, FormatOpsgenie, "gk")
+		require.NoError(t, err)
+		require.NoError(t, w.Notify(context.Background(), canary))
+		assert.Equal(t, "P1", gotBody["priority"])
+		assert.Equal(t, "honeypot/db", gotBody["details"].(map[string]any)["key"])
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		status = http.StatusUnauthorized
+		w, err := NewWebhook(srv.URL, FormatJSON, "")
+		require.NoError(t, err)
+		err = w.Notify(context.Background(), alert)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "returned 401: bad key")
+	})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// consistency token headers, issued on writes and passed back on reads
+const (
+	versionHeader    = "X-Stash-Version"
+	minVersionHeader = "X-Stash-Min-Version"
+)
+
+// consistencyMargin extends the time of a consistency token when deciding whether a cached value may
+// predate the write, covering clock differences between instances sharing the database.
+const consistencyMargin = time.Second
+
+// setVersion sets the consistency token of a completed write. The token is the write time in unix
+// nanoseconds, increasing with every write of the instance, and opaque to clients.
+func (h *Handler) setVersion(w http.ResponseWriter) {
+	now := time.Now().UnixNano()
+	for {
+		last := h.lastVersion.Load()
+		next := max(now, last+1)
+		if h.lastVersion.CompareAndSwap(last, next) {
+			w.Header().Set(versionHeader, strconv.FormatInt(next, 10))
+			return
+		}
+	}
+}
+
+// readContext returns the context for reading values of the request. With a consistency token in
+// X-Stash-Min-Version or ?min_version=, the read doesn't use cached values that may be older than the write
+// of the token, so a client reads its own writes made through other instances.
+func readContext(r *http.Request) (context.Context, error) {
+	token := r.Header.Get(minVersionHeader)
+	if token == "" {
+		token = r.URL.Query().Get("min_version")
+	}
+	if token == "" {
+		return r.Context(), nil
+	}
+	nanos, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || nanos <= 0 {
+		return nil, fmt.Errorf("invalid consistency token %q", token)
+	}
+	return store.WithLoadedAfter(r.Context(), time.Unix(0, nanos).Add(consistencyMargin)), nil
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/bundle"
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/internal/environ"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/store"
+)
+
+// tarContentType is the content type of tar archives, exported with ?output=tar and accepted by import
+const tarContentType = "application/x-tar"
+
+// import modes, see handleImport
+const (
+	importMerge     = "merge"
+	importOverwrite = "overwrite"
+	importSkip      = "skip"
+)
+
+// importResponse is the number of keys changed by an import.
+type importResponse struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+}
+
+// handleExport returns the keys the caller can read with values and formats, as a bundle accepted by import.
+// Secrets are included decrypted, ?filter=keys leaves them out. ZK-encrypted values are exported as stored.
+// With ?output=tar the keys are files of a tar archive, named as in the stash dev directory.
+// GET /kv/_export?prefix=app/
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	if environ.FromContext(r.Context()) != "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "env parameter is not supported by export")
+		return
+	}
+	output := r.URL.Query().Get("output")
+	if output != "" && output != "json" && output != "tar" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid output parameter, must be json or tar")
+		return
+	}
+	q := store.ListQuery{Prefix: r.URL.Query().Get("prefix"), Sort: enum.SortModeKey, Filter: enum.SecretsFilterAll}
+	if filterParam := r.URL.Query().Get("filter"); filterParam != "" {
+		parsed, err := enum.ParseSecretsFilter(filterParam)
+		if err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid filter parameter")
+			return
+		}
+		q.Filter = parsed
+	}
+	if !h.Store.SecretsEnabled() {
+		if q.Filter == enum.SecretsFilterSecretsOnly {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, store.ErrSecretsNotConfigured, "secrets not configured")
+			return
+		}
+		q.Filter = enum.SecretsFilterKeysOnly // secrets can't be read, they are left out rather than failing the export
+	}
+	if h.Auth != nil && h.Auth.Enabled() {
+		q.Allow = func(keys []string) []string { return h.Auth.FilterKeysForRequest(r, keys) }
+	}
+
+	b, err := h.exportBundle(r, q)
+	if err != nil {
+		if errors.Is(err, store.ErrSealed) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, err, "secrets sealed")
+			return
+		}
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to export keys")
+		return
+	}
+	log.Printf("[INFO] export %d keys with prefix %q by %s", len(b.Keys), q.Prefix, h.getIdentityForLog(r))
+	for _, k := range b.Keys {
+		data, _ := k.Data() // encoded by the export
+		size := len(data)
+		keyaudit.Add(r.Context(), keyaudit.Record{Key: k.Key, Action: enum.AuditActionRead, Size: &size})
+	}
+
+	name := "stash-export-" + b.ExportedAt.Format("20060102-150405")
+	if output == "tar" {
+		w.Header().Set("Content-Type", tarContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.tar"`)
+		if err := writeTar(w, b); err != nil {
+			log.Printf("[WARN] failed to write export tar: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+	rest.RenderJSON(w, b)
+}
+
+// exportBundle reads the listed keys with their values. Keys deleted while exporting are left out.
+func (h *Handler) exportBundle(r *http.Request, q store.ListQuery) (bundle.Bundle, error) {
+	keys, _, err := h.Store.ListPage(r.Context(), q)
+	if err != nil {
+		return bundle.Bundle{}, fmt.Errorf("failed to list keys: %w", err)
+	}
+	res := bundle.Bundle{Version: bundle.Version, ExportedAt: time.Now().UTC(), Keys: make([]bundle.Key, 0, len(keys))}
+	for _, k := range keys {
+		value, format, err := h.Store.GetWithFormat(r.Context(), k.Key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return bundle.Bundle{}, fmt.Errorf("failed to get key %q: %w", k.Key, err)
+		}
+		bk := bundle.NewKey(k.Key, value, format)
+		bk.UpdatedAt = k.UpdatedAt.UTC()
+		res.Keys = append(res.Keys, bk)
+	}
+	return res, nil
+}
+
+// writeTar writes keys of the bundle as files of a tar archive, modification times are the key update times.
+func writeTar(w io.Writer, b bundle.Bundle) error {
+	tw := tar.NewWriter(w)
+	for _, k := range b.Keys {
+		data, err := k.Data()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k.Key, err)
+		}
+		hdr := &tar.Header{Name: store.KeyFile(k.Key, k.Format), Mode: 0o600, Size: int64(len(data)),
+			ModTime: k.UpdatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write header of %q: %w", k.Key, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write key %q: %w", k.Key, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar: %w", err)
+	}
+	return nil
+}
+
+// readTar reads files of a tar archive as keys of a bundle, named and formatted by the file extension.
+func readTar(r io.Reader) (bundle.Bundle, error) {
+	res := bundle.Bundle{Version: bundle.Version}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return bundle.Bundle{}, fmt.Errorf("failed to read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue // directories and links are not keys
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return bundle.Bundle{}, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		key, format := store.FileKey(hdr.Name)
+		res.Keys = append(res.Keys, bundle.NewKey(key, data, format))
+	}
+}
+
+// handleImport restores keys from a bundle made by export, or a tar archive with Content-Type application/x-tar.
+// Only admins can import when auth is enabled. The bundle is validated first, a bad key fails the import
+// before anything is changed, and all changes are applied in one transaction. Modes:
+//   - merge (default) sets all bundled keys, other keys are kept
+//   - skip sets only keys that don't exist yet
+//   - overwrite sets all bundled keys and deletes other keys under ?prefix=, which is required, bundled keys
+//     must be under it
+//
+// POST /kv/_import?mode=overwrite&prefix=app/
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	if h.Auth != nil && h.Auth.Enabled() && !h.Auth.IsRequestAdmin(r) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "import requires admin")
+		return
+	}
+	if environ.FromContext(r.Context()) != "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "env parameter is not supported by import")
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = importMerge
+	case importMerge, importOverwrite, importSkip:
+	default:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid mode, must be merge, overwrite or skip")
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	if mode == importOverwrite && prefix == "" {
+		// without a prefix every key not in the bundle would be deleted
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "overwrite mode requires a prefix")
+		return
+	}
+
+	var b bundle.Bundle
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), tarContentType) {
+		b, err = readTar(r.Body)
+	} else {
+		b, err = bundle.Read(r.Body)
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid bundle")
+		return
+	}
+	if problems := bundle.Validate(b, nil); len(problems) > 0 {
+		msgs := make([]string, len(problems))
+		for i, p := range problems {
+			msgs[i] = p.String()
+		}
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid bundle: "+strings.Join(msgs, "; "))
+		return
+	}
+	for _, k := range b.Keys {
+		if mode == importOverwrite && !strings.HasPrefix(k.Key, prefix) {
+			msg := fmt.Sprintf("key %q is not under prefix %q", k.Key, prefix)
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, msg)
+			return
+		}
+		if store.IsSecret(k.Key) && !h.Store.SecretsEnabled() {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, store.ErrSecretsNotConfigured, "secrets not configured")
+			return
+		}
+	}
+
+	res, err := h.importBundle(r, b, mode, prefix)
+	log.Printf("[INFO] import %d keys, mode %s, prefix %q by %s: %+v", len(b.Keys), mode, prefix, h.getIdentityForLog(r), res)
+	if err != nil {
+		status, msg := http.StatusInternalServerError, "failed to import keys"
+		var conflict *store.TxnConflictError
+		switch {
+		case errors.As(err, &conflict):
+			status, msg = http.StatusConflict, conflict.Error()
+		case errors.Is(err, store.ErrSealed):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, store.ErrInvalidZKPayload):
+			status = http.StatusBadRequest
+		case errors.Is(err, store.ErrFrozen):
+			status, msg = http.StatusLocked, frozenMessage(err)
+		}
+		rest.SendErrorJSON(w, r, log.Default(), status, err, msg)
+		return
+	}
+	h.setVersion(w)
+	rest.RenderJSON(w, res)
+}
+
+// importBundle sets keys of the validated bundle and, in overwrite mode, deletes other keys under the prefix.
+// All changes are made in one store transaction, nothing is changed on failure. A key created or changed
+// by someone else after it was checked fails the import with *store.TxnConflictError.
+func (h *Handler) importBundle(r *http.Request, b bundle.Bundle, mode, prefix string) (importResponse, error) {
+	var res importResponse
+	ctx, owner := r.Context(), ownership.Owner(h.getIdentityForLog(r))
+	bundled := make(map[string]bool, len(b.Keys))
+	ops := make([]store.TxnOp, 0, len(b.Keys))
+	for _, k := range b.Keys {
+		bundled[k.Key] = true
+		op := store.TxnOp{Key: k.Key, Format: k.Format, Owner: owner}
+		op.Value, _ = k.Data() // decoding is checked by validation
+		if op.Format == "" {
+			op.Format = "text"
+		}
+		if mode == importSkip {
+			_, err := h.Store.Get(ctx, k.Key)
+			if err == nil {
+				res.Skipped++
+				continue
+			}
+			if !errors.Is(err, store.ErrNotFound) {
+				return importResponse{}, fmt.Errorf("failed to check key %q: %w", k.Key, err)
+			}
+			op.Absent = true
+		}
+		ops = append(ops, op)
+	}
+	if mode == importOverwrite {
+		existing, _, err := h.Store.ListPage(ctx, store.ListQuery{Prefix: prefix, Sort: enum.SortModeKey})
+		if err != nil {
+			return importResponse{}, fmt.Errorf("failed to list keys: %w", err)
+		}
+		for _, k := range existing {
+			if !bundled[k.Key] {
+				ops = append(ops, store.TxnOp{Key: k.Key, Delete: true, Version: k.UpdatedAt})
+			}
+		}
+	}
+	if len(ops) == 0 {
+		return res, nil
+	}
+
+	results, err := h.Store.Txn(ctx, ops)
+	if err != nil {
+		return importResponse{}, fmt.Errorf("failed to apply import: %w", err)
+	}
+	for i, rs := range results {
+		switch {
+		case ops[i].Delete:
+			res.Deleted++
+		case rs.Created:
+			res.Created++
+		default:
+			res.Updated++
+		}
+		h.txnApplied(r, ops[i], rs.Created)
+	}
+	return res, nil
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/bundle"
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/api/mocks"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
+	"github.com/umputun/stash/app/store"
+)
+
+// memKV is a map backed KVStoreMock, values are keyed by key with the format after a tab.
+func memKV(values map[string]string) *mocks.KVStoreMock {
+	return &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			var res []store.KeyInfo
+			for k, v := range values {
+				if !strings.HasPrefix(k, q.Prefix) || (q.Filter == enum.SecretsFilterKeysOnly && store.IsSecret(k)) {
+					continue
+				}
+				value, format, _ := strings.Cut(v, "\t")
+				res = append(res, store.KeyInfo{Key: k, Size: len(value), Format: format,
+					UpdatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)})
+			}
+			sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+			if q.Allow != nil {
+				names := make([]string, len(res))
+				for i, k := range res {
+					names[i] = k.Key
+				}
+				allowed := q.Allow(names)
+				res = res[:0:0]
+				for _, k := range allowed {
+					value, format, _ := strings.Cut(values[k], "\t")
+					res = append(res, store.KeyInfo{Key: k, Size: len(value), Format: format})
+				}
+			}
+			return res, len(res), nil
+		},
+		GetFunc: func(_ context.Context, key string) ([]byte, error) {
+			v, ok := values[key]
+			if !ok {
+				return nil, store.ErrNotFound
+			}
+			value, _, _ := strings.Cut(v, "\t")
+			return []byte(value), nil
+		},
+		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
+			v, ok := values[key]
+			if !ok {
+				return nil, "", store.ErrNotFound
+			}
+			value, format, _ := strings.Cut(v, "\t")
+			return []byte(value), format, nil
+		},
+		DeleteFunc: func(_ context.Context, key string) error {
+			if _, ok := values[key]; !ok {
+				return store.ErrNotFound
+			}
+			delete(values, key)
+			return nil
+		},
+		TxnFunc: func(_ context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+			for _, op := range ops {
+				if _, exists := values[op.Key]; (op.Absent && exists) || (op.Delete && !exists) {
+					return nil, &store.TxnConflictError{Key: op.Key}
+				}
+			}
+			res := make([]store.TxnResult, len(ops))
+			for i, op := range ops {
+				_, exists := values[op.Key]
+				res[i] = store.TxnResult{Key: op.Key, Created: !exists && !op.Delete}
+				if op.Delete {
+					delete(values, op.Key)
+					continue
+				}
+				values[op.Key] = string(op.Value) + "\t" + op.Format
+			}
+			return res, nil
+		},
+		SecretsEnabledFunc: func() bool { return true },
+	}
+}
+
+func TestHandler_HandleExport(t *testing.T) {
+	values := map[string]string{
+		"app/db":          `{"port":5432}` + "\tjson",
+		"app/name":        "svc\ttext",
+		"app/blob":        "\xff\x00\ttext",
+		"secrets/app/key": "hunter2\ttext",
+		"other/key":       "x\ttext",
+	}
+	noAuth := &mocks.AuthProviderMock{EnabledFunc: func() bool { return false }}
+	export := func(h *Handler, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/_export"+query, http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleExport(rec, req)
+		return rec
+	}
+
+	t.Run("json bundle", func(t *testing.T) {
+		h := newTestHandler(t, memKV(values), noAuth)
+		rec := export(h, "?prefix=app/")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), ".json")
+		b, err := bundle.Read(rec.Body)
+		require.NoError(t, err)
+		updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		assert.Equal(t, []bundle.Key{
+			{Key: "app/blob", Value: "/wA=", Format: "text", Encoding: bundle.EncodingBase64, UpdatedAt: updated},
+			{Key: "app/db", Value: `{"port":5432}`, Format: "json", UpdatedAt: updated},
+			{Key: "app/name", Value: "svc", Format: "text", UpdatedAt: updated},
+		}, b.Keys)
+		assert.False(t, b.ExportedAt.IsZero())
+	})
+
+	t.Run("exported keys reported to audit", func(t *testing.T) {
+		h := newTestHandler(t, memKV(values), noAuth)
+		ctx, records := keyaudit.WithRecorder(t.Context())
+		req := httptest.NewRequest(http.MethodGet, "/kv/_export?prefix=app/", http.NoBody).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		h.handleExport(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		size := func(n int) *int { return &n }
+		assert.Equal(t, []keyaudit.Record{
+			{Key: "app/blob", Action: enum.AuditActionRead, Result: enum.AuditResultSuccess, Size: size(2)},
+			{Key: "app/db", Action: enum.AuditActionRead, Result: enum.AuditResultSuccess, Size: size(13)},
+			{Key: "app/name", Action: enum.AuditActionRead, Result: enum.AuditResultSuccess, Size: size(3)},
+		}, records())
+	})
+
+	t.Run("secrets included unless filtered", func(t *testing.T) {
+		h := newTestHandler(t, memKV(values), noAuth)
+		assert.Contains(t, export(h, "").Body.String(), "hunter2")
+		assert.NotContains(t, export(h, "?filter=keys").Body.String(), "hunter2")
+
+		st := memKV(values)
+		st.SecretsEnabledFunc = func() bool { return false }
+		h = newTestHandler(t, st, noAuth)
+		rec := export(h, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "secrets/app/key", "secrets can't be read without a key")
+		assert.Equal(t, http.StatusBadRequest, export(h, "?filter=secrets").Code)
+	})
+
+	t.Run("filtered by permissions", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc: func() bool { return true },
+			FilterKeysForRequestFunc: func(_ *http.Request, keys []string) []string {
+				var res []string
+				for _, k := range keys {
+					if strings.HasPrefix(k, "app/") {
+						res = append(res, k)
+					}
+				}
+				return res
+			},
+			GetRequestActorFunc: func(*http.Request) (string, string) { return "token", "reader" },
+		}
+		h := newTestHandler(t, memKV(values), auth)
+		b, err := bundle.Read(export(h, "").Body)
+		require.NoError(t, err)
+		require.Len(t, b.Keys, 3)
+		for _, k := range b.Keys {
+			assert.True(t, strings.HasPrefix(k.Key, "app/"), k.Key)
+		}
+	})
+
+	t.Run("tar archive", func(t *testing.T) {
+		h := newTestHandler(t, memKV(values), noAuth)
+		rec := export(h, "?prefix=app/&output=tar")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, tarContentType, rec.Header().Get("Content-Type"))
+
+		files := map[string]string{}
+		tr := tar.NewReader(rec.Body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[hdr.Name] = string(data)
+		}
+		assert.Equal(t, map[string]string{"app/blob.txt": "\xff\x00", "app/db.json": `{"port":5432}`, "app/name.txt": "svc"}, files)
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		h := newTestHandler(t, memKV(values), noAuth)
+		assert.Equal(t, http.StatusBadRequest, export(h, "?output=csv").Code)
+		assert.Equal(t, http.StatusBadRequest, export(h, "?filter=bad").Code)
+	})
+}
+
+func TestHandler_HandleImport(t *testing.T) {
+	noAuth := &mocks.AuthProviderMock{
+		EnabledFunc:         func() bool { return false },
+		GetRequestActorFunc: func(*http.Request) (string, string) { return "", "" },
+	}
+	bundleJSON := func(keys ...bundle.Key) string {
+		data, err := json.Marshal(bundle.Bundle{Version: bundle.Version, Keys: keys})
+		require.NoError(t, err)
+		return string(data)
+	}
+	keys := bundleJSON(
+		bundle.Key{Key: "app/db", Value: `{"port":6432}`, Format: "json"},
+		bundle.Key{Key: "app/new", Value: "fresh"},
+		bundle.NewKey("app/blob", []byte{0xff, 0x01}, "text"),
+	)
+	type result struct{ Created, Updated, Skipped, Deleted int }
+	imp := func(h *Handler, query, contentType, body string) (*httptest.ResponseRecorder, result) {
+		req := httptest.NewRequest(http.MethodPost, "/kv/_import"+query, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.handleImport(rec, req)
+		var res result
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec, res
+	}
+	existing := func() map[string]string {
+		return map[string]string{"app/db": `{"port":5432}` + "\tjson", "app/old": "stale\ttext", "other/key": "x\ttext"}
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		values := existing()
+		gitSvc := &mocks.GitServiceMock{CommitFunc: func(git.CommitRequest) error { return nil }}
+		events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+		h := New(Deps{Store: memKV(values), Auth: noAuth, Validator: defaultFormatValidator(), Git: gitSvc, Events: events})
+		rec, res := imp(h, "", "", keys)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, result{Created: 2, Updated: 1}, res)
+		assert.Equal(t, `{"port":6432}`+"\tjson", values["app/db"])
+		assert.Equal(t, "fresh\ttext", values["app/new"], "empty format is text")
+		assert.Equal(t, "\xff\x01\ttext", values["app/blob"])
+		assert.Contains(t, values, "app/old")
+		assert.Len(t, gitSvc.CommitCalls(), 3)
+		assert.Len(t, events.PublishCalls(), 3)
+		assert.NotEmpty(t, rec.Header().Get("X-Stash-Version"))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		values := existing()
+		h := newTestHandler(t, memKV(values), noAuth)
+		rec, res := imp(h, "?mode=skip", "", keys)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, result{Created: 2, Skipped: 1}, res)
+		assert.Equal(t, `{"port":5432}`+"\tjson", values["app/db"], "existing key is kept")
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		values := existing()
+		gitSvc := &mocks.GitServiceMock{
+			CommitFunc: func(git.CommitRequest) error { return nil },
+			DeleteFunc: func(string, git.Author) error { return nil },
+		}
+		h := New(Deps{Store: memKV(values), Auth: noAuth, Validator: defaultFormatValidator(), Git: gitSvc})
+		rec, res := imp(h, "?mode=overwrite&prefix=app/", "", keys)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, result{Created: 2, Updated: 1, Deleted: 1}, res)
+		assert.NotContains(t, values, "app/old")
+		assert.Contains(t, values, "other/key", "keys outside of the prefix are kept")
+		require.Len(t, gitSvc.DeleteCalls(), 1)
+		assert.Equal(t, "app/old", gitSvc.DeleteCalls()[0].Key)
+
+		rec, _ = imp(h, "?mode=overwrite&prefix=other/", "", keys)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `key \"app/db\" is not under prefix \"other/\"`)
+	})
+
+	t.Run("imported keys reported to audit", func(t *testing.T) {
+		h := newTestHandler(t, memKV(existing()), noAuth)
+		ctx, records := keyaudit.WithRecorder(t.Context())
+		req := httptest.NewRequest(http.MethodPost, "/kv/_import?mode=overwrite&prefix=app/", strings.NewReader(keys)).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		h.handleImport(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		actions := map[string]enum.AuditAction{}
+		for _, r := range records() {
+			assert.Equal(t, enum.AuditResultSuccess, r.Result)
+			assert.Equal(t, r.Action == enum.AuditActionDelete, r.Size == nil, "size of written values only")
+			actions[r.Key] = r.Action
+		}
+		assert.Equal(t, map[string]enum.AuditAction{"app/db": enum.AuditActionUpdate, "app/new": enum.AuditActionCreate,
+			"app/blob": enum.AuditActionCreate, "app/old": enum.AuditActionDelete}, actions)
+	})
+
+	t.Run("overwrite requires prefix", func(t *testing.T) {
+		values := existing()
+		st := memKV(values)
+		h := newTestHandler(t, st, noAuth)
+		for _, query := range []string{"?mode=overwrite", "?mode=overwrite&prefix="} {
+			rec, _ := imp(h, query, "", keys)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "overwrite mode requires a prefix")
+		}
+		assert.Empty(t, st.TxnCalls())
+		assert.Equal(t, existing(), values)
+	})
+
+	t.Run("one transaction, conflict changes nothing", func(t *testing.T) {
+		values := existing()
+		st := memKV(values)
+		gitSvc := &mocks.GitServiceMock{CommitFunc: func(git.CommitRequest) error { return nil }}
+		h := New(Deps{Store: st, Auth: noAuth, Validator: defaultFormatValidator(), Git: gitSvc})
+		st.GetFunc = func(context.Context, string) ([]byte, error) { return nil, store.ErrNotFound } // app/db looks new
+		rec, _ := imp(h, "?mode=skip", "", keys)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), `key \"app/db\"`)
+		require.Len(t, st.TxnCalls(), 1)
+		assert.Len(t, st.TxnCalls()[0].Ops, 3, "all keys in one transaction")
+		assert.Equal(t, existing(), values)
+		assert.Empty(t, gitSvc.CommitCalls())
+	})
+
+	t.Run("tar archive", func(t *testing.T) {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, data := range map[string]string{"app/db.json": `{"port":7432}`, "app/notes": "hello"} {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data))}))
+			_, err := tw.Write([]byte(data))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0o700}))
+		require.NoError(t, tw.Close())
+
+		values := existing()
+		h := newTestHandler(t, memKV(values), noAuth)
+		rec, res := imp(h, "", tarContentType, buf.String())
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, result{Created: 1, Updated: 1}, res)
+		assert.Equal(t, `{"port":7432}`+"\tjson", values["app/db"])
+		assert.Equal(t, "hello\ttext", values["app/notes"])
+	})
+
+	t.Run("invalid bundle changes nothing", func(t *testing.T) {
+		values := existing()
+		st := memKV(values)
+		h := newTestHandler(t, st, noAuth)
+		bad := bundleJSON(bundle.Key{Key: "app/new", Value: "fresh"}, bundle.Key{Key: "app/db", Value: "{", Format: "json"})
+		rec, _ := imp(h, "", "", bad)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "app/db: invalid json")
+		assert.Empty(t, st.TxnCalls())
+
+		for _, body := range []string{`{"version":2,"keys":[]}`, "not json"} {
+			rec, _ = imp(h, "", "", body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		rec, _ = imp(h, "?mode=replace", "", keys)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		st.SecretsEnabledFunc = func() bool { return false }
+		rec, _ = imp(h, "", "", bundleJSON(bundle.Key{Key: "secrets/db", Value: "pw"}))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, st.TxnCalls())
+	})
+
+	t.Run("admin only with auth", func(t *testing.T) {
+		admin := false
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:         func() bool { return true },
+			IsRequestAdminFunc:  func(*http.Request) bool { return admin },
+			GetRequestActorFunc: func(*http.Request) (string, string) { return "user", "alice" },
+		}
+		h := newTestHandler(t, memKV(existing()), auth)
+		rec, _ := imp(h, "", "", keys)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		admin = true
+		rec, _ = imp(h, "", "", keys)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
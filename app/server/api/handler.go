@@ -3,11 +3,19 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
@@ -16,6 +24,12 @@ import (
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/environ"
+	"github.com/umputun/stash/app/server/internal/inventory"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/server/internal/search"
+	"github.com/umputun/stash/app/server/internal/variant"
+	"github.com/umputun/stash/app/server/snapshot"
 	"github.com/umputun/stash/app/store"
 	"github.com/umputun/stash/lib/stash"
 )
@@ -25,10 +39,17 @@ import (
 //go:generate moq -out mocks/formatvalidator.go -pkg mocks -skip-ensure -fmt goimports . FormatValidator
 //go:generate moq -out mocks/gitservice.go -pkg mocks -skip-ensure -fmt goimports . GitService
 //go:generate moq -out mocks/eventpublisher.go -pkg mocks -skip-ensure -fmt goimports . EventPublisher
+//go:generate moq -out mocks/snapshotprovider.go -pkg mocks -skip-ensure -fmt goimports . SnapshotProvider
+//go:generate moq -out mocks/ownerpolicy.go -pkg mocks -skip-ensure -fmt goimports . OwnerPolicy
+//go:generate moq -out mocks/variantstore.go -pkg mocks -skip-ensure -fmt goimports . VariantStore
+//go:generate moq -out mocks/historystore.go -pkg mocks -skip-ensure -fmt goimports . HistoryStore
+//go:generate moq -out mocks/historypolicy.go -pkg mocks -skip-ensure -fmt goimports . HistoryPolicy
 
 // Handler handles API requests for /kv/* endpoints.
 type Handler struct {
 	Deps
+	overridesMu sync.Mutex   // serializes changes of variants specs by overrides
+	lastVersion atomic.Int64 // last consistency token issued, see setVersion
 }
 
 // GitService defines the interface for git operations.
@@ -43,9 +64,11 @@ type GitService interface {
 type KVStore interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	GetWithFormat(ctx context.Context, key string) ([]byte, string, error)
-	Set(ctx context.Context, key string, value []byte, format string) (created bool, err error)
+	GetInfo(ctx context.Context, key string) (store.KeyInfo, error)
+	SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (created bool, err error)
 	Delete(ctx context.Context, key string) error
-	List(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
+	Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
+	ListPage(ctx context.Context, q store.ListQuery) (keys []store.KeyInfo, total int, err error)
 	SecretsEnabled() bool
 }
 
@@ -54,6 +77,8 @@ type AuthProvider interface {
 	Enabled() bool
 	FilterKeysForRequest(r *http.Request, keys []string) []string
 	GetRequestActor(r *http.Request) (actorType, actorName string)
+	IsRequestAdmin(r *http.Request) bool
+	RequestCanChange(r *http.Request, key string, scope enum.Scope) bool
 }
 
 // FormatValidator defines the interface for format validation.
@@ -66,13 +91,32 @@ type EventPublisher interface {
 	Publish(key string, action enum.AuditAction)
 }
 
+// SnapshotProvider defines the interface for change tracking: snapshot tokens, changes made
+// since a token was issued and the time of the latest change (including deletes) under a prefix.
+type SnapshotProvider interface {
+	Token() string
+	ChangedSince(token string) (keys []string, ok bool)
+	LastModified(prefix string) time.Time
+}
+
+// OwnerPolicy defines the interface for checking who can delete owned keys.
+type OwnerPolicy interface {
+	CanDelete(ctx context.Context, key, identity string, admin bool) (bool, error)
+}
+
 // Deps holds dependencies for the API handler.
 type Deps struct {
-	Store     KVStore
-	Auth      AuthProvider
-	Validator FormatValidator
-	Git       GitService     // optional
-	Events    EventPublisher // optional
+	Store         KVStore
+	Auth          AuthProvider
+	Validator     FormatValidator
+	Git           GitService       // optional
+	Events        EventPublisher   // optional
+	Snapshots     SnapshotProvider // optional, enables X-Stash-Snapshot headers
+	Owners        OwnerPolicy      // optional, enforces owner-only delete
+	Envs          *environ.Set     // optional, environments selected with ?env=, keys come already mapped by its middleware
+	Variants      VariantStore     // optional, enables A/B variants of values
+	History       HistoryStore     // optional, previous values kept in the database, serves history and rollback without git
+	HistoryAccess HistoryPolicy    // optional, history and rollback of matched keys are allowed to admins only
 }
 
 // New creates a new API handler.
@@ -84,8 +128,12 @@ func New(deps Deps) *Handler {
 func (h *Handler) Register(r *routegroup.Bundle) {
 	r.HandleFunc("GET /{$}", h.handleList)                 // list keys (must be before {key...})
 	r.HandleFunc("GET /history/{key...}", h.handleHistory) // get key history (before generic key)
+	r.HandleFunc("GET /_export", h.handleExport)           // export keys as a bundle (before generic key)
+	r.HandleFunc("POST /_import", h.handleImport)          // import keys from a bundle
+	r.HandleFunc("POST /_txn", h.handleTxn)                // change several keys atomically
 	r.HandleFunc("GET /{key...}", h.handleGet)             // get specific key
 	r.HandleFunc("PUT /{key...}", h.handleSet)             // set key
+	r.HandleFunc("PATCH /{key...}", h.handlePatch)         // patch json value
 	r.HandleFunc("DELETE /{key...}", h.handleDelete)
 }
 
@@ -93,6 +141,9 @@ func (h *Handler) Register(r *routegroup.Bundle) {
 // GET /kv?prefix=app/config (filter by prefix)
 // GET /kv?filter=secrets (filter to secrets only)
 // GET /kv?filter=keys (filter to non-secrets only)
+// GET /kv?q=format:json+updated:<7d (search query, see search.Parse)
+// GET /kv?label=env=prod&label=team=core (keys with all the labels)
+// GET /kv with Accept: application/x-ndjson (streamed, one key per line)
 func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
 	// parse secrets filter query param
 	filter := enum.SecretsFilterAll
@@ -104,53 +155,201 @@ func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
 		}
 		filter = parsed
 	}
+	output := r.URL.Query().Get("output")
+	if output != "" && output != "json" && output != "csv" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid output parameter, must be json or csv")
+		return
+	}
 
-	keys, err := h.Store.List(r.Context(), filter)
+	q, err := search.Parse(r.URL.Query().Get("q"), time.Now())
 	if err != nil {
-		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to list keys")
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid q parameter, "+search.Help)
 		return
 	}
+	q.Filter = filter
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		if q.Prefix != "" && q.Prefix != prefix {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "prefix parameter conflicts with prefix: in q")
+			return
+		}
+		q.Prefix = prefix
+	}
+	for _, label := range r.URL.Query()["label"] {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok || name == "" || value == "" {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid label parameter, expected name=value")
+			return
+		}
+		if q.Labels == nil {
+			q.Labels = map[string]string{}
+		}
+		if v, exists := q.Labels[name]; exists && v != value {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, fmt.Sprintf("conflicting values of label %q", name))
+			return
+		}
+		q.Labels[name] = value
+	}
+	if h.Auth != nil && h.Auth.Enabled() {
+		q.Allow = func(keys []string) []string { return h.Auth.FilterKeysForRequest(r, keys) }
+	}
 
-	// extract key names for filtering
-	keyNames := make([]string, len(keys))
-	for i, k := range keys {
-		keyNames[i] = k.Key
+	h.setSnapshotHeaders(w, r) // before reading, so a change racing with the read is reported next time
+	w.Header().Add("Vary", "Accept")
+	if output == "" && wantsNDJSON(r) {
+		h.streamList(w, r, q) // streamed lists are not buffered to check If-Modified-Since
+		return
 	}
 
-	// filter by auth permissions
-	filteredNames := h.filterKeysByAuth(r, keyNames)
-	if filteredNames == nil {
-		// no valid auth, but this shouldn't happen since tokenAuth middleware already checked
-		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "unauthorized")
+	var filtered []store.KeyInfo
+	if env := environ.FromContext(r.Context()); env != "" {
+		filtered, err = h.listEnv(r.Context(), q, env)
+	} else {
+		filtered, _, err = h.Store.ListPage(r.Context(), q)
+		if h.Envs != nil {
+			filtered = slices.DeleteFunc(filtered, func(k store.KeyInfo) bool { return environ.IsEnvKey(k.Key) })
+		}
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to list keys")
 		return
 	}
 
-	// convert filtered names back to KeyInfo slice
-	nameSet := make(map[string]bool, len(filteredNames))
-	for _, name := range filteredNames {
-		nameSet[name] = true
+	// changes of inherited keys are tracked under other prefixes, environment lists are always sent in full
+	if environ.FromContext(r.Context()) == "" && h.notModified(w, r, q.Prefix, filtered) {
+		return
 	}
-	filtered := make([]store.KeyInfo, 0, len(filteredNames))
-	for _, k := range keys {
-		if nameSet[k.Key] {
-			filtered = append(filtered, k)
+
+	log.Printf("[DEBUG] list keys: %d found", len(filtered))
+	if output == "csv" {
+		// metadata inventory, e.g. ?filter=secrets&output=csv for a secrets report without values
+		kind := "keys"
+		if filter == enum.SecretsFilterSecretsOnly {
+			kind = "secrets"
+		}
+		w.Header().Set("Content-Type", inventory.ContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+inventory.Filename(kind, time.Now())+`"`)
+		if err := inventory.WriteCSV(w, filtered); err != nil {
+			log.Printf("[WARN] failed to write keys csv: %v", err)
 		}
+		return
 	}
+	rest.RenderJSON(w, filtered)
+}
 
-	// filter by prefix if specified
-	prefix := r.URL.Query().Get("prefix")
-	if prefix != "" {
-		var prefixed []store.KeyInfo
-		for _, k := range filtered {
-			if strings.HasPrefix(k.Key, prefix) {
-				prefixed = append(prefixed, k)
+// listEnv lists keys of the environment with keys it inherits from its base environments, named without the
+// environment prefix. Permissions of the environment apply to inherited keys too, as they are read through it.
+func (h *Handler) listEnv(ctx context.Context, q store.ListQuery, env string) ([]store.KeyInfo, error) {
+	prefix, allow := q.Prefix, q.Allow
+	seen := map[string]bool{}
+	res := []store.KeyInfo{} // rendered as [] rather than null when empty
+	for _, level := range h.Envs.Chain(env) {
+		q.Prefix = environ.Key(level, prefix)
+		if allow != nil {
+			q.Allow = func(keys []string) []string {
+				byStored := make(map[string]string, len(keys))
+				stored := make([]string, 0, len(keys))
+				for _, k := range keys {
+					_, key := environ.Split(k)
+					byStored[environ.Key(env, key)] = k
+					stored = append(stored, environ.Key(env, key))
+				}
+				allowed := allow(stored)
+				for i, k := range allowed {
+					allowed[i] = byStored[k]
+				}
+				return allowed
+			}
+		}
+		keys, _, err := h.Store.ListPage(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			keyEnv, key := environ.Split(k.Key)
+			if keyEnv != level || seen[key] {
+				continue // keys of other environments listed with the default one, or set by a closer one
 			}
+			seen[key] = true
+			k.Key = key
+			res = append(res, k)
 		}
-		filtered = prefixed
 	}
+	sortKeys(res, q.Sort)
+	return res, nil
+}
 
-	log.Printf("[DEBUG] list keys: %d found, %d after auth filter", len(keys), len(filtered))
-	rest.RenderJSON(w, filtered)
+// sortKeys sorts merged key lists the same way as the store sorts a list.
+func sortKeys(keys []store.KeyInfo, mode enum.SortMode) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch mode {
+		case enum.SortModeKey:
+			return a.Key < b.Key
+		case enum.SortModeSize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case enum.SortModeCreated:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+		default:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.After(b.UpdatedAt)
+			}
+		}
+		return a.Key < b.Key
+	})
+}
+
+// notModified sets Last-Modified for the listed keys and responds with 304 if the request's
+// If-Modified-Since is not older than that. The modification time combines updated_at of the keys
+// with the change tracker, which also knows about deletes. Returns true if the 304 was sent.
+func (h *Handler) notModified(w http.ResponseWriter, r *http.Request, prefix string, keys []store.KeyInfo) bool {
+	if h.Snapshots == nil {
+		return false // deletes can't be detected without change tracking
+	}
+	lastModified := h.Snapshots.LastModified(prefix)
+	for _, k := range keys {
+		if k.UpdatedAt.After(lastModified) {
+			lastModified = k.UpdatedAt
+		}
+	}
+
+	// http dates have second precision, a later change within the same second would be missed
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.Before(time.Now().Truncate(time.Second)) {
+		return false
+	}
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(ims) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// valueETag returns a strong entity tag of the returned value. It's derived from the content rather than
+// the update time, so variants, environments and pinned revisions get tags of what they return.
+func valueETag(value []byte, format string) string {
+	h := sha256.New()
+	h.Write([]byte(format))
+	h.Write([]byte{0})
+	h.Write(value)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatch reports whether the If-None-Match header lists the tag, weak tags match by their opaque part.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // filterKeysByAuth filters keys based on the request's authentication.
@@ -170,12 +369,41 @@ func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "key is required")
 		return
 	}
+	if r.URL.Query().Has(variantsParam) {
+		h.handleVariants(w, r, key)
+		return
+	}
 
-	value, format, err := h.Store.GetWithFormat(r.Context(), key)
+	var client version // set if the client reads revisions compatible with its version
+	clientVersion := r.Header.Get("X-Stash-Client-Version")
+	if clientVersion == "" {
+		clientVersion = r.URL.Query().Get("client_version")
+	}
+	if clientVersion != "" {
+		parsed, err := parseVersion(clientVersion)
+		if err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid client version")
+			return
+		}
+		client = parsed
+	}
+
+	ctx, err := readContext(r)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid min version")
+		return
+	}
+
+	h.setSnapshotHeaders(w, r)
+	value, format, source, err := h.getWithFormat(ctx, key)
 	if errors.Is(err, store.ErrSecretsNotConfigured) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "secrets not configured")
 		return
 	}
+	if errors.Is(err, store.ErrSealed) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, err, "secrets sealed")
+		return
+	}
 	if errors.Is(err, store.ErrNotFound) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
 		return
@@ -185,15 +413,105 @@ func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stored := key // key of the returned value, of the base environment for an inherited one
+	if env := environ.FromContext(r.Context()); env != "" {
+		_, bare := environ.Split(key)
+		stored = environ.Key(source, bare)
+	}
+	pinned := false
+	if client != nil && h.Git != nil {
+		rev, pinErr := h.pinnedRevision(stored, client)
+		if errors.Is(pinErr, errNoCompatibleRevision) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, pinErr, "no revision compatible with client version")
+			return
+		}
+		if pinErr != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, pinErr, "failed to get key")
+			return
+		}
+		if rev != nil {
+			value, format, pinned = rev.Value, rev.Format, true
+			w.Header().Set("X-Stash-Revision", rev.Hash)
+		}
+	}
+
+	// variants apply to the current value only, clients pinned to an older revision get it as is
+	if h.Variants != nil && !pinned {
+		if v, ok := h.pickVariant(w, r, stored); ok {
+			value = []byte(v.Value)
+			if v.Format != "" {
+				format = v.Format
+			}
+			w.Header().Set(variant.VariantHeader, v.Name)
+		}
+	}
+
 	log.Printf("[DEBUG] get %s (%d bytes, format=%s)", key, len(value), format)
 
+	if environ.FromContext(r.Context()) != "" {
+		w.Header().Set("X-Stash-Env", source) // empty for the default environment
+	}
+	etag := valueETag(value, format)
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.Header().Set("Content-Type", h.formatToContentType(format))
+	w.Header().Set("X-Stash-Format", format) // content types are shared by formats, e.g. text/plain
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(value); err != nil {
 		log.Printf("[WARN] failed to write response: %v", err)
 	}
 }
 
+// getWithFormat returns the value of the stored key. In an environment, a key the environment doesn't set is
+// inherited from the closest base environment setting it, source is the environment the value comes from.
+func (h *Handler) getWithFormat(ctx context.Context, key string) (value []byte, format, source string, err error) {
+	env := environ.FromContext(ctx)
+	if env == "" {
+		value, format, err = h.Store.GetWithFormat(ctx, key)
+		return value, format, "", err //nolint:wrapcheck // store errors are checked by the caller
+	}
+	_, key = environ.Split(key)
+	for _, level := range h.Envs.Chain(env) {
+		value, format, err = h.Store.GetWithFormat(ctx, environ.Key(level, key))
+		if !errors.Is(err, store.ErrNotFound) {
+			return value, format, level, err //nolint:wrapcheck // store errors are checked by the caller
+		}
+	}
+	return nil, "", "", err //nolint:wrapcheck // store errors are checked by the caller
+}
+
+// setSnapshotHeaders sets X-Stash-Snapshot with the current snapshot token. If the request carries
+// a previously issued token, X-Stash-Changed-Prefixes lists comma-separated parent prefixes of keys
+// changed since then, limited to keys the caller can read. "*" means the token expired and
+// everything should be refreshed; an empty header means nothing changed.
+func (h *Handler) setSnapshotHeaders(w http.ResponseWriter, r *http.Request) {
+	if h.Snapshots == nil {
+		return
+	}
+	current := h.Snapshots.Token() // taken first, so a concurrent change is reported now or next time
+	if token := r.Header.Get("X-Stash-Snapshot"); token != "" {
+		changed, ok := h.Snapshots.ChangedSince(token)
+		switch {
+		case !ok:
+			w.Header().Set("X-Stash-Changed-Prefixes", "*")
+		case len(changed) > 0:
+			w.Header().Set("X-Stash-Changed-Prefixes", strings.Join(snapshot.Prefixes(h.filterKeysByAuth(r, changed)), ","))
+		default:
+			w.Header().Set("X-Stash-Changed-Prefixes", "")
+		}
+	}
+	w.Header().Set("X-Stash-Snapshot", current)
+}
+
+// validFormat returns true for formats known to the server and names of custom client formats,
+// values of custom formats are stored without validation.
+func (h *Handler) validFormat(format string) bool {
+	return h.Validator.IsValidFormat(format) || stash.IsFormatName(format)
+}
+
 // formatToContentType maps storage format to HTTP Content-Type
 func (h *Handler) formatToContentType(format string) string {
 	if f, err := stash.ParseFormat(format); err == nil {
@@ -204,13 +522,29 @@ func (h *Handler) formatToContentType(format string) string {
 
 // handleSet stores a value for a key.
 // PUT /kv/{key...}
-// accepts format via X-Stash-Format header or ?format= query param (defaults to "text")
+// accepts format via X-Stash-Format header or ?format= query param (defaults to "text"),
+// names of custom client formats are stored as is
+// accepts TTL via X-Stash-TTL header or ?ttl= query param, the key is deleted after it; without TTL it doesn't expire
+// accepts labels via X-Stash-Labels header or ?labels= query param (name=value, comma-separated) and description
+// via X-Stash-Description header or ?description= query param, an empty one removes it; without them both are kept
 func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
 	key := store.NormalizeKey(r.PathValue("key"))
 	if key == "" {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "key is required")
 		return
 	}
+	if r.URL.Query().Has(variantsParam) {
+		h.handleVariants(w, r, key)
+		return
+	}
+	if r.URL.Query().Has(overrideParam) {
+		h.handleOverride(w, r, key)
+		return
+	}
+	if r.URL.Query().Has(rollbackParam) {
+		h.handleRollback(w, r, key)
+		return
+	}
 
 	value, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -223,20 +557,72 @@ func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
 	if format == "" {
 		format = r.URL.Query().Get("format")
 	}
-	if !h.Validator.IsValidFormat(format) {
+	switch {
+	case format == "":
 		format = "text"
+	case !h.validFormat(format):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid format")
+		return
+	}
+
+	// minimum client version of the value, clients with a lower one read its newest older revision
+	minVersion := minClientVersion(r)
+	if minVersion != "" {
+		if h.Git == nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "min version requires git integration")
+			return
+		}
+		if _, err := parseVersion(minVersion); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid min version")
+			return
+		}
+	}
+
+	ttlParam := r.Header.Get("X-Stash-TTL")
+	if ttlParam == "" {
+		ttlParam = r.URL.Query().Get("ttl")
+	}
+	var ttl time.Duration
+	if ttlParam != "" {
+		if ttl, err = parseTTL(ttlParam); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid ttl")
+			return
+		}
 	}
 
-	created, err := h.Store.Set(r.Context(), key, value, format)
+	opts, err := metaFromRequest(r)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	// the owner, expiration and metadata are written with the value, a key is never left without them
+	opts.Owner = ownership.Owner(h.getIdentityForLog(r))
+	if ttl > 0 {
+		opts.ExpiresAt = time.Now().Add(ttl)
+	}
+	created, err := h.Store.SetWithOptions(r.Context(), key, value, format, opts)
 	if err != nil {
 		if errors.Is(err, store.ErrSecretsNotConfigured) {
 			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "secrets not configured")
 			return
 		}
+		if errors.Is(err, store.ErrSealed) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, err, "secrets sealed")
+			return
+		}
 		if errors.Is(err, store.ErrInvalidZKPayload) {
 			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid ZK payload")
 			return
 		}
+		if errors.Is(err, store.ErrFrozen) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusLocked, err, frozenMessage(err))
+			return
+		}
+		if errors.Is(err, store.ErrInvalidMeta) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+			return
+		}
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to set key")
 		return
 	}
@@ -245,11 +631,16 @@ func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
 	if created {
 		operation = "create"
 	}
-	log.Printf("[INFO] %s %q (%d bytes, format=%s) by %s", operation, key, len(value), format, h.getIdentityForLog(r))
+	if ttl > 0 {
+		log.Printf("[INFO] %s %q (%d bytes, format=%s, ttl=%s) by %s", operation, key, len(value), format, ttl, h.getIdentityForLog(r))
+	} else {
+		log.Printf("[INFO] %s %q (%d bytes, format=%s) by %s", operation, key, len(value), format, h.getIdentityForLog(r))
+	}
 
 	// commit to git if enabled
 	if h.Git != nil {
-		req := git.CommitRequest{Key: key, Value: value, Operation: operation, Format: format, Author: h.getAuthorFromRequest(r)}
+		req := git.CommitRequest{Key: key, Value: value, Operation: operation, Format: format,
+			Author: h.getAuthorFromRequest(r), MinVersion: minVersion}
 		if err := h.Git.Commit(req); err != nil {
 			log.Printf("[WARN] git commit failed for %s: %v", key, err)
 		}
@@ -264,6 +655,7 @@ func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
 		h.Events.Publish(key, action)
 	}
 
+	h.setVersion(w)
 	if created {
 		w.WriteHeader(http.StatusCreated)
 	} else {
@@ -271,6 +663,79 @@ func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// minClientVersion returns the minimum client version of the set request, empty if not sent.
+// X-Stash-Min-Client-Version and ?min_client_version= are preferred, X-Stash-Min-Version and ?min_version=
+// are accepted on writes for clients pinning values before the rename; on reads they carry consistency tokens.
+func minClientVersion(r *http.Request) string {
+	for _, name := range []string{"X-Stash-Min-Client-Version", minVersionHeader} {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+	}
+	for _, param := range []string{"min_client_version", "min_version"} {
+		if v := r.URL.Query().Get(param); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// metaFromRequest returns the set options with labels and description of the set request from headers
+// or query params, nil if not sent, so the stored ones are kept.
+func metaFromRequest(r *http.Request) (store.SetOptions, error) {
+	var res store.SetOptions
+	if v, ok := headerOrParam(r, "X-Stash-Labels", "labels"); ok {
+		labels, err := store.ParseLabels(v)
+		if err != nil {
+			return store.SetOptions{}, err //nolint:wrapcheck // the message is returned to the client as is
+		}
+		res.Labels = labels
+	}
+	if v, ok := headerOrParam(r, "X-Stash-Description", "description"); ok {
+		v = strings.TrimSpace(v)
+		if err := (store.KeyMeta{Description: v}).Validate(); err != nil {
+			return store.SetOptions{}, err //nolint:wrapcheck // the message is returned to the client as is
+		}
+		res.Description = &v
+	}
+	return res, nil
+}
+
+// headerOrParam returns the header or, without it, the query param, false if the request has neither.
+func headerOrParam(r *http.Request, header, param string) (string, bool) {
+	if vals := r.Header.Values(header); len(vals) > 0 {
+		return vals[0], true
+	}
+	if r.URL.Query().Has(param) {
+		return r.URL.Query().Get(param), true
+	}
+	return "", false
+}
+
+// parseTTL parses the TTL of a key, a duration like 90s or 24h, or whole seconds.
+func parseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if secs, atoiErr := strconv.Atoi(s); atoiErr == nil {
+		ttl, err = time.Duration(secs)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("parse ttl %q: %w", s, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("ttl %q is not positive", s)
+	}
+	return ttl, nil
+}
+
+// frozenMessage returns the message of a write rejected by a frozen prefix, naming the incident of the freeze.
+func frozenMessage(err error) string {
+	var frozen *store.FrozenError
+	if errors.As(err, &frozen) {
+		return frozen.Error()
+	}
+	return "key is frozen"
+}
+
 // handleDelete removes a key from the store.
 // DELETE /kv/{key...}
 func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
@@ -279,12 +744,36 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "key is required")
 		return
 	}
+	if r.URL.Query().Has(variantsParam) {
+		h.handleVariants(w, r, key)
+		return
+	}
+	if r.URL.Query().Has(overrideParam) {
+		h.handleOverride(w, r, key)
+		return
+	}
+
+	if h.Owners != nil {
+		allowed, err := h.Owners.CanDelete(r.Context(), key, h.getIdentityForLog(r), h.Auth != nil && h.Auth.IsRequestAdmin(r))
+		if err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to check key owner")
+			return
+		}
+		if !allowed {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "only the owner or an admin can delete this key")
+			return
+		}
+	}
 
 	err := h.Store.Delete(r.Context(), key)
 	if errors.Is(err, store.ErrNotFound) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
 		return
 	}
+	if errors.Is(err, store.ErrFrozen) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusLocked, err, frozenMessage(err))
+		return
+	}
 	if err != nil {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to delete key")
 		return
@@ -304,23 +793,29 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
 		h.Events.Publish(key, enum.AuditActionDelete)
 	}
 
+	h.setVersion(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// historyResponse represents a single entry in the history response.
+// historyResponse represents a single entry in the history response. Entries from git have a commit
+// hash, author and operation, entries from the database history have a version instead.
 type historyResponse struct {
-	Hash      string `json:"hash"`
+	Hash      string `json:"hash,omitempty"`
+	Version   int64  `json:"version,omitempty"`
 	Timestamp string `json:"timestamp"`
-	Author    string `json:"author"`
-	Operation string `json:"operation"`
+	Author    string `json:"author,omitempty"`
+	Operation string `json:"operation,omitempty"`
 	Format    string `json:"format"`
 	Value     string `json:"value"` // base64 encoded
+	// MinVersion is the minimum client version of the revision, empty if not set
+	MinVersion string `json:"min_version,omitempty"`
 }
 
-// handleHistory returns the commit history for a key.
+// handleHistory returns the commit history for a key, or its previous values kept in the database
+// if git is disabled.
 // GET /kv/history/{key...}
 func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
-	if h.Git == nil {
+	if h.Git == nil && h.History == nil {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, nil, "git integration not enabled")
 		return
 	}
@@ -337,8 +832,17 @@ func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "access denied")
 		return
 	}
+	if !h.historyVisible(r, key) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "history of the key is restricted to admins")
+		return
+	}
+
+	if h.Git == nil {
+		h.handleStoreHistory(w, r, key)
+		return
+	}
 
-	history, err := h.Git.History(key, 50)
+	history, err := h.Git.History(key, historyLimit)
 	if err != nil {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get history")
 		return
@@ -348,12 +852,13 @@ func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
 	resp := make([]historyResponse, len(history))
 	for i, entry := range history {
 		resp[i] = historyResponse{
-			Hash:      entry.Hash,
-			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339),
-			Author:    entry.Author,
-			Operation: entry.Operation,
-			Format:    entry.Format,
-			Value:     base64.StdEncoding.EncodeToString(entry.Value),
+			Hash:       entry.Hash,
+			Timestamp:  entry.Timestamp.UTC().Format(time.RFC3339),
+			Author:     entry.Author,
+			Operation:  entry.Operation,
+			Format:     entry.Format,
+			Value:      base64.StdEncoding.EncodeToString(entry.Value),
+			MinVersion: entry.MinVersion,
 		}
 	}
 
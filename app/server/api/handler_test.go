@@ -2,31 +2,37 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-pkgz/routegroup"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
 	"github.com/umputun/stash/app/server/api/mocks"
+	"github.com/umputun/stash/app/server/internal/environ"
 	"github.com/umputun/stash/app/store"
 )
 
 func TestHandler_HandleList(t *testing.T) {
 	t.Run("returns all keys", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-				assert.Equal(t, enum.SecretsFilterAll, filter, "filter should be All for no filter")
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, enum.SecretsFilterAll, q.Filter, "filter should be All for no filter")
 				return []store.KeyInfo{
 					{Key: "alpha", Size: 50},
 					{Key: "beta", Size: 100},
-				}, nil
+				}, 2, nil
 			},
 		}
 		auth := &mocks.AuthProviderMock{
@@ -43,14 +49,37 @@ func TestHandler_HandleList(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "beta")
 	})
 
+	t.Run("filters by labels", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, q.Labels)
+				return []store.KeyInfo{{Key: "app/db", Size: 50, Labels: q.Labels}}, 1, nil
+			},
+		}
+		h := newTestHandler(t, st, &mocks.AuthProviderMock{EnabledFunc: func() bool { return false }})
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/?label=env=prod&label=team=core&label=env=prod", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"labels":{"env":"prod","team":"core"}`)
+
+		for _, target := range []string{"/kv/?label=env", "/kv/?label=env=prod&label=env=dev"} {
+			rec = httptest.NewRecorder()
+			h.handleList(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+		assert.Len(t, st.ListPageCalls(), 1)
+	})
+
 	t.Run("filters by prefix", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, "app/", q.Prefix, "prefix is filtered by the store")
 				return []store.KeyInfo{
 					{Key: "app/config", Size: 50},
 					{Key: "app/db", Size: 100},
-					{Key: "other/key", Size: 30},
-				}, nil
+				}, 2, nil
 			},
 		}
 		auth := &mocks.AuthProviderMock{
@@ -71,11 +100,11 @@ func TestHandler_HandleList(t *testing.T) {
 
 	t.Run("filters secrets only", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-				assert.Equal(t, enum.SecretsFilterSecretsOnly, filter, "filter should be SecretsOnly")
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, enum.SecretsFilterSecretsOnly, q.Filter, "filter should be SecretsOnly")
 				return []store.KeyInfo{
 					{Key: "secrets/db", Size: 50, Secret: true},
-				}, nil
+				}, 1, nil
 			},
 		}
 		auth := &mocks.AuthProviderMock{
@@ -93,11 +122,11 @@ func TestHandler_HandleList(t *testing.T) {
 
 	t.Run("filters non-secrets only", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-				assert.Equal(t, enum.SecretsFilterKeysOnly, filter, "filter should be KeysOnly")
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, enum.SecretsFilterKeysOnly, q.Filter, "filter should be KeysOnly")
 				return []store.KeyInfo{
 					{Key: "app/config", Size: 50, Secret: false},
-				}, nil
+				}, 1, nil
 			},
 		}
 		auth := &mocks.AuthProviderMock{
@@ -115,11 +144,11 @@ func TestHandler_HandleList(t *testing.T) {
 
 	t.Run("returns ZKEncrypted field in response", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
 				return []store.KeyInfo{
 					{Key: "regular/key", Size: 50, Secret: false, ZKEncrypted: false},
 					{Key: "secrets/zk-key", Size: 100, Secret: true, ZKEncrypted: true},
-				}, nil
+				}, 2, nil
 			},
 		}
 		auth := &mocks.AuthProviderMock{
@@ -150,10 +179,81 @@ func TestHandler_HandleList(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "invalid filter parameter")
 	})
 
+	t.Run("search query", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				assert.Equal(t, "db", q.Search)
+				assert.Equal(t, "app/", q.Prefix)
+				assert.Equal(t, "json", q.Format)
+				assert.Equal(t, 10*1024+1, q.MinSize)
+				assert.WithinDuration(t, time.Now().Add(-7*24*time.Hour), q.UpdatedAfter, time.Minute)
+				assert.Equal(t, enum.SecretsFilterKeysOnly, q.Filter)
+				return []store.KeyInfo{{Key: "app/db", Size: 20000, Format: "json"}}, 1, nil
+			},
+		}
+		h := newTestHandler(t, st, noopAuthMock())
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/?filter=keys&q=db+prefix:app/+format:json+updated:<7d+size:>10kb", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "app/db")
+	})
+
+	t.Run("invalid search query", func(t *testing.T) {
+		st := &mocks.KVStoreMock{}
+		h := newTestHandler(t, st, noopAuthMock())
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/?q=size:big", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid q parameter")
+		assert.Empty(t, st.ListPageCalls())
+	})
+
+	t.Run("prefix parameter conflicts with query", func(t *testing.T) {
+		st := &mocks.KVStoreMock{}
+		h := newTestHandler(t, st, noopAuthMock())
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/?prefix=app/&q=prefix:other/", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "conflicts")
+	})
+
+	t.Run("auth filters keys in store", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				require.NotNil(t, q.Allow)
+				assert.Equal(t, []string{"app/db"}, q.Allow([]string{"app/db", "secret/key"}))
+				return []store.KeyInfo{{Key: "app/db"}}, 1, nil
+			},
+		}
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc: func() bool { return true },
+			FilterKeysForRequestFunc: func(_ *http.Request, keys []string) []string {
+				return slices.DeleteFunc(keys, func(k string) bool { return strings.HasPrefix(k, "secret/") })
+			},
+		}
+		h := newTestHandler(t, st, auth)
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "app/db")
+	})
+
 	t.Run("store error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-				return nil, errors.New("db error")
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				return nil, 0, errors.New("db error")
 			},
 		}
 		auth := noopAuthMock()
@@ -206,6 +306,45 @@ func TestHandler_HandleGet(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "json", rec.Header().Get("X-Stash-Format"))
+	})
+
+	t.Run("etag and if-none-match", func(t *testing.T) {
+		value := "v1"
+		st := &mocks.KVStoreMock{
+			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
+				return []byte(value), "text", nil
+			},
+		}
+		h := newTestHandler(t, st, noopAuthMock())
+		get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/kv/app/name", http.NoBody)
+			req.SetPathValue("key", "app/name")
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			h.handleGet(rec, req)
+			return rec
+		}
+
+		rec := get("")
+		require.Equal(t, http.StatusOK, rec.Code)
+		etag := rec.Header().Get("ETag")
+		assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+		rec = get(etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+		assert.Equal(t, http.StatusNotModified, get(`"other", W/`+etag).Code, "weak tag in a list")
+		assert.Equal(t, http.StatusNotModified, get("*").Code)
+
+		value = "v2"
+		rec = get(etag)
+		assert.Equal(t, http.StatusOK, rec.Code, "changed value")
+		assert.Equal(t, "v2", rec.Body.String())
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
 	})
 
 	t.Run("not found", func(t *testing.T) {
@@ -238,6 +377,23 @@ func TestHandler_HandleGet(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
+	t.Run("sealed secrets return 503", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
+				return nil, "", fmt.Errorf("failed to decrypt key: %w", store.ErrSealed)
+			},
+		}
+		h := newTestHandler(t, st, noopAuthMock())
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/secrets/db", http.NoBody)
+		req.SetPathValue("key", "secrets/db")
+		rec := httptest.NewRecorder()
+		h.handleGet(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Contains(t, rec.Body.String(), "secrets sealed")
+	})
+
 	t.Run("secrets not configured returns 400", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
@@ -260,7 +416,7 @@ func TestHandler_HandleGet(t *testing.T) {
 func TestHandler_HandleSet(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 		}
 		auth := noopAuthMock()
 		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
@@ -271,15 +427,15 @@ func TestHandler_HandleSet(t *testing.T) {
 		h.handleSet(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "newkey", st.SetCalls()[0].Key)
-		assert.Equal(t, "newvalue", string(st.SetCalls()[0].Value))
-		assert.Equal(t, "text", st.SetCalls()[0].Format) // default format
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "newkey", st.SetWithOptionsCalls()[0].Key)
+		assert.Equal(t, "newvalue", string(st.SetWithOptionsCalls()[0].Value))
+		assert.Equal(t, "text", st.SetWithOptionsCalls()[0].Format) // default format
 	})
 
 	t.Run("with format header", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 		}
 		auth := noopAuthMock()
 		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
@@ -291,13 +447,13 @@ func TestHandler_HandleSet(t *testing.T) {
 		h.handleSet(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "json", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "json", st.SetWithOptionsCalls()[0].Format)
 	})
 
 	t.Run("with format query param", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 		}
 		auth := noopAuthMock()
 		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
@@ -308,26 +464,40 @@ func TestHandler_HandleSet(t *testing.T) {
 		h.handleSet(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "yaml", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "yaml", st.SetWithOptionsCalls()[0].Format)
 	})
 
-	t.Run("invalid format defaults to text", func(t *testing.T) {
+	t.Run("custom format stored by name", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 		}
 		auth := noopAuthMock()
 		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
 
 		req := httptest.NewRequest(http.MethodPut, "/kv/key", strings.NewReader("value"))
 		req.SetPathValue("key", "key")
-		req.Header.Set("X-Stash-Format", "invalid")
+		req.Header.Set("X-Stash-Format", "cue")
 		rec := httptest.NewRecorder()
 		h.handleSet(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "text", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "cue", st.SetWithOptionsCalls()[0].Format)
+	})
+
+	t.Run("malformed format rejected", func(t *testing.T) {
+		st := &mocks.KVStoreMock{}
+		auth := noopAuthMock()
+		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
+
+		req := httptest.NewRequest(http.MethodPut, "/kv/key?format=Not%20A%20Format", strings.NewReader("value"))
+		req.SetPathValue("key", "key")
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, st.SetWithOptionsCalls())
 	})
 
 	t.Run("empty key", func(t *testing.T) {
@@ -345,7 +515,9 @@ func TestHandler_HandleSet(t *testing.T) {
 
 	t.Run("store error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return false, errors.New("db error") },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
+				return false, errors.New("db error")
+			},
 		}
 		auth := noopAuthMock()
 		h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator()})
@@ -360,7 +532,7 @@ func TestHandler_HandleSet(t *testing.T) {
 
 	t.Run("secrets not configured returns 400", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) {
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
 				return false, store.ErrSecretsNotConfigured
 			},
 		}
@@ -378,7 +550,7 @@ func TestHandler_HandleSet(t *testing.T) {
 
 	t.Run("invalid ZK payload returns 400", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) {
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
 				return false, store.ErrInvalidZKPayload
 			},
 		}
@@ -395,6 +567,126 @@ func TestHandler_HandleSet(t *testing.T) {
 	})
 }
 
+func TestHandler_HandleSet_TTL(t *testing.T) {
+	newHandler := func() (*Handler, *mocks.KVStoreMock) {
+		st := &mocks.KVStoreMock{
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		}
+		return New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()}), st
+	}
+	put := func(h *Handler, target, ttlHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader("on"))
+		req.SetPathValue("key", "flags/override")
+		if ttlHeader != "" {
+			req.Header.Set("X-Stash-TTL", ttlHeader)
+		}
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name, target, header string
+		want                 time.Duration
+	}{
+		{name: "duration header", target: "/kv/flags/override", header: "90m", want: 90 * time.Minute},
+		{name: "seconds header", target: "/kv/flags/override", header: "3600", want: time.Hour},
+		{name: "query param", target: "/kv/flags/override?ttl=30s", want: 30 * time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, st := newHandler()
+			rec := put(h, tc.target, tc.header)
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			require.Len(t, st.SetWithOptionsCalls(), 1, "value and expiration in one write")
+			assert.Equal(t, "flags/override", st.SetWithOptionsCalls()[0].Key)
+			assert.WithinDuration(t, time.Now().Add(tc.want), st.SetWithOptionsCalls()[0].Opts.ExpiresAt, time.Second)
+		})
+	}
+
+	t.Run("without ttl", func(t *testing.T) {
+		h, st := newHandler()
+		assert.Equal(t, http.StatusCreated, put(h, "/kv/flags/override", "").Code)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.True(t, st.SetWithOptionsCalls()[0].Opts.ExpiresAt.IsZero())
+	})
+
+	for _, ttl := range []string{"soon", "0", "-5m"} {
+		t.Run("invalid ttl "+ttl, func(t *testing.T) {
+			h, st := newHandler()
+			rec := put(h, "/kv/flags/override", ttl)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "invalid ttl")
+			assert.Empty(t, st.SetWithOptionsCalls(), "value not stored")
+		})
+	}
+}
+
+func TestHandler_HandleSet_Meta(t *testing.T) {
+	newHandler := func() (*Handler, *mocks.KVStoreMock) {
+		st := &mocks.KVStoreMock{
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, nil },
+		}
+		return New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()}), st
+	}
+	put := func(h *Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader("v"))
+		req.SetPathValue("key", "app/db")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		return rec
+	}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name, target string
+		headers      map[string]string
+		want         store.SetOptions
+	}{
+		{name: "both headers", target: "/kv/app/db",
+			headers: map[string]string{"X-Stash-Labels": "env=prod, team=core", "X-Stash-Description": "main db"},
+			want:    store.SetOptions{Description: str("main db"), Labels: map[string]string{"env": "prod", "team": "core"}}},
+		{name: "labels only keep description", target: "/kv/app/db", headers: map[string]string{"X-Stash-Labels": "env=prod"},
+			want: store.SetOptions{Labels: map[string]string{"env": "prod"}}},
+		{name: "query params", target: "/kv/app/db?description=new&labels=",
+			want: store.SetOptions{Description: str("new"), Labels: map[string]string{}}},
+		{name: "without metadata", target: "/kv/app/db", want: store.SetOptions{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, st := newHandler()
+			rec := put(h, tc.target, tc.headers)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			require.Len(t, st.SetWithOptionsCalls(), 1, "value and metadata in one write")
+			assert.Equal(t, "app/db", st.SetWithOptionsCalls()[0].Key)
+			assert.Equal(t, tc.want, st.SetWithOptionsCalls()[0].Opts)
+		})
+	}
+
+	t.Run("metadata over the limits", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
+				return false, fmt.Errorf("%w: more than 32 labels", store.ErrInvalidMeta)
+			},
+		}
+		h := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()})
+		rec := put(h, "/kv/app/db", map[string]string{"X-Stash-Labels": "env=prod"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "more than 32 labels")
+	})
+
+	t.Run("invalid labels", func(t *testing.T) {
+		h, st := newHandler()
+		rec := put(h, "/kv/app/db", map[string]string{"X-Stash-Labels": "env"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "expected name=value")
+		assert.Empty(t, st.SetWithOptionsCalls(), "value not stored")
+	})
+}
+
 func TestHandler_HandleDelete(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
@@ -457,6 +749,84 @@ func TestHandler_HandleDelete(t *testing.T) {
 	})
 }
 
+func TestHandler_Frozen(t *testing.T) {
+	frozen := &store.FrozenError{Key: "prod/db", Freeze: store.Freeze{Prefix: "prod", Incident: "INC-7",
+		Message: "db failover", ExpiresAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}}
+	st := &mocks.KVStoreMock{
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, frozen },
+		DeleteFunc:         func(context.Context, string) error { return frozen },
+		TxnFunc:            func(context.Context, []store.TxnOp) ([]store.TxnResult, error) { return nil, frozen },
+	}
+	h := newTestHandler(t, st, noopAuthMock())
+	wantMsg := `key \"prod/db\" is frozen for incident INC-7 until 2026-03-01T12:00:00Z: db failover`
+
+	req := httptest.NewRequest(http.MethodPut, "/kv/prod/db", strings.NewReader("v"))
+	req.SetPathValue("key", "prod/db")
+	rec := httptest.NewRecorder()
+	h.handleSet(rec, req)
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.Contains(t, rec.Body.String(), wantMsg)
+
+	req = httptest.NewRequest(http.MethodDelete, "/kv/prod/db", http.NoBody)
+	req.SetPathValue("key", "prod/db")
+	rec = httptest.NewRecorder()
+	h.handleDelete(rec, req)
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.Contains(t, rec.Body.String(), wantMsg)
+
+	req = httptest.NewRequest(http.MethodPost, "/kv/txn", strings.NewReader(`{"ops":[{"op":"delete","key":"prod/db"}]}`))
+	rec = httptest.NewRecorder()
+	h.handleTxn(rec, req)
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.Contains(t, rec.Body.String(), wantMsg)
+}
+
+func TestHandler_Owners(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		DeleteFunc:         func(context.Context, string) error { return nil },
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:         func() bool { return true },
+		GetRequestActorFunc: func(*http.Request) (string, string) { return "token", "token:ab12****" },
+		IsRequestAdminFunc:  func(*http.Request) bool { return false },
+	}
+	owners := &mocks.OwnerPolicyMock{
+		CanDeleteFunc: func(_ context.Context, key, _ string, _ bool) (bool, error) {
+			if key == "broken" {
+				return false, errors.New("db error")
+			}
+			return key != "owned", nil
+		},
+	}
+	h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator(), Owners: owners})
+
+	t.Run("create records owner", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/kv/app/new", strings.NewReader("v"))
+		req.SetPathValue("key", "app/new")
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "app/new", st.SetWithOptionsCalls()[0].Key)
+		assert.Equal(t, "token:ab12****", st.SetWithOptionsCalls()[0].Opts.Owner)
+	})
+
+	del := func(key string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/kv/"+key, http.NoBody)
+		req.SetPathValue("key", key)
+		rec := httptest.NewRecorder()
+		h.handleDelete(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, del("app/new"))
+	assert.Equal(t, http.StatusForbidden, del("owned"))
+	assert.Equal(t, http.StatusInternalServerError, del("broken"))
+	assert.Len(t, st.DeleteCalls(), 1, "rejected deletes don't reach the store")
+	assert.Equal(t, "token:ab12****", owners.CanDeleteCalls()[0].Identity)
+}
+
 func TestHandler_FormatToContentType(t *testing.T) {
 	st := &mocks.KVStoreMock{}
 	auth := noopAuthMock()
@@ -632,7 +1002,7 @@ func TestHandler_GetIdentityForLog(t *testing.T) {
 
 func TestHandler_HandleSet_WithGit(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 	}
 	auth := noopAuthMock()
 	gitMock := &mocks.GitServiceMock{
@@ -654,6 +1024,252 @@ func TestHandler_HandleSet_WithGit(t *testing.T) {
 	require.Len(t, gitMock.CommitCalls(), 1, "git commit should be called")
 }
 
+func TestHandler_VersionPinning(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("v3"), "yaml", nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, nil },
+	}
+	gitSvc := &mocks.GitServiceMock{
+		HistoryFunc: func(key string, limit int) ([]git.HistoryEntry, error) {
+			return []git.HistoryEntry{
+				{Hash: "ccc3333", Operation: "update", Format: "yaml", Value: []byte("v3-git"), MinVersion: "2.0"},
+				{Hash: "bbb2222", Operation: "update", Format: "json", Value: []byte("v2"), MinVersion: "1.5"},
+				{Hash: "aaa1111", Operation: "create", Format: "text", Value: []byte("v1")},
+			}, nil
+		},
+		CommitFunc: func(git.CommitRequest) error { return nil },
+	}
+	h := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator(), Git: gitSvc})
+
+	get := func(t *testing.T, clientVersion string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/config", http.NoBody)
+		req.SetPathValue("key", "app/config")
+		if clientVersion != "" {
+			req.Header.Set("X-Stash-Client-Version", clientVersion)
+		}
+		rec := httptest.NewRecorder()
+		h.handleGet(rec, req)
+		return rec
+	}
+
+	tbl := []struct {
+		client, value, revision string
+	}{
+		{client: "", value: "v3"},
+		{client: "2.0", value: "v3"},
+		{client: "v2.1.7", value: "v3"},
+		{client: "1.9.9", value: "v2", revision: "bbb2222"},
+		{client: "1.5", value: "v2", revision: "bbb2222"},
+		{client: "1", value: "v1", revision: "aaa1111"},
+	}
+	for _, tt := range tbl {
+		t.Run("client "+tt.client, func(t *testing.T) {
+			rec := get(t, tt.client)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.value, rec.Body.String(), "current value comes from the store, older ones from git")
+			assert.Equal(t, tt.revision, rec.Header().Get("X-Stash-Revision"))
+		})
+	}
+
+	t.Run("format of the revision", func(t *testing.T) {
+		assert.Equal(t, "application/json", get(t, "1.6").Header().Get("Content-Type"))
+	})
+
+	t.Run("invalid client version", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get(t, "1.x").Code)
+	})
+
+	t.Run("no compatible revision", func(t *testing.T) {
+		gitSvc.HistoryFunc = func(string, int) ([]git.HistoryEntry, error) {
+			return []git.HistoryEntry{
+				{Hash: "ccc3333", Operation: "update", Value: []byte("v3"), MinVersion: "2.0"},
+				{Hash: "bbb2222", Operation: "delete"},
+				{Hash: "aaa1111", Operation: "create", Value: []byte("old")},
+			}, nil
+		}
+		rec := get(t, "1.0")
+		assert.Equal(t, http.StatusNotFound, rec.Code, "revisions before the delete are not used")
+		assert.Contains(t, rec.Body.String(), "no revision compatible")
+	})
+
+	t.Run("set records min version", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/kv/app/config?min_client_version=2.1", strings.NewReader("v4"))
+		req.SetPathValue("key", "app/config")
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		calls := gitSvc.CommitCalls()
+		require.NotEmpty(t, calls)
+		assert.Equal(t, "2.1", calls[len(calls)-1].Req.MinVersion)
+	})
+
+	t.Run("set with min version header of older clients", func(t *testing.T) {
+		for _, tc := range []struct{ header, target string }{
+			{header: "X-Stash-Min-Version", target: "/kv/app/config"},
+			{target: "/kv/app/config?min_version=2.2"},
+		} {
+			req := httptest.NewRequest(http.MethodPut, tc.target, strings.NewReader("v5"))
+			req.SetPathValue("key", "app/config")
+			if tc.header != "" {
+				req.Header.Set(tc.header, "2.2")
+			}
+			rec := httptest.NewRecorder()
+			h.handleSet(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			calls := gitSvc.CommitCalls()
+			require.NotEmpty(t, calls)
+			assert.Equal(t, "2.2", calls[len(calls)-1].Req.MinVersion)
+		}
+	})
+
+	t.Run("set with invalid min version", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/kv/app/config", strings.NewReader("v4"))
+		req.SetPathValue("key", "app/config")
+		req.Header.Set("X-Stash-Min-Client-Version", "two")
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("min version without git", func(t *testing.T) {
+		noGit := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()})
+		req := httptest.NewRequest(http.MethodPut, "/kv/app/config?min_client_version=2", strings.NewReader("v4"))
+		req.SetPathValue("key", "app/config")
+		rec := httptest.NewRecorder()
+		noGit.handleSet(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "requires git")
+	})
+}
+
+func TestHandler_Variants(t *testing.T) {
+	specs := map[string]string{"app/banner": `{"variants":[{"name":"eu","value":"{\"eu\":true}","format":"json",` +
+		`"match":{"region":"eu"}},{"name":"all","value":"new","percent":100}]}`, "app/plain": ""}
+	variants := &mocks.VariantStoreMock{
+		GetVariantsFunc: func(_ context.Context, key string) (string, error) {
+			spec, ok := specs[key]
+			if !ok {
+				return "", store.ErrNotFound
+			}
+			return spec, nil
+		},
+		SetVariantsFunc: func(_ context.Context, key, spec string) error {
+			if _, ok := specs[key]; !ok {
+				return store.ErrNotFound
+			}
+			specs[key] = spec
+			return nil
+		},
+	}
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("control"), "text", nil },
+	}
+	events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+	h := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator(), Events: events, Variants: variants})
+
+	do := func(t *testing.T, method, target, body string, hdr map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetPathValue("key", strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], "/kv/"))
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		switch method {
+		case http.MethodPut:
+			h.handleSet(rec, req)
+		case http.MethodDelete:
+			h.handleDelete(rec, req)
+		default:
+			h.handleGet(rec, req)
+		}
+		return rec
+	}
+
+	t.Run("serves targeted variant", func(t *testing.T) {
+		rec := do(t, http.MethodGet, "/kv/app/banner", "", map[string]string{"X-Stash-Attributes": "region=eu"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"eu":true}`, rec.Body.String())
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "eu", rec.Header().Get("X-Stash-Variant"))
+		assert.Contains(t, rec.Header().Get("Vary"), "X-Stash-Subject")
+
+		rec = do(t, http.MethodGet, "/kv/app/banner?subject=user-1", "", nil)
+		assert.Equal(t, "new", rec.Body.String())
+		assert.Equal(t, "all", rec.Header().Get("X-Stash-Variant"))
+	})
+
+	t.Run("serves control without targeting", func(t *testing.T) {
+		rec := do(t, http.MethodGet, "/kv/app/banner", "", nil)
+		assert.Equal(t, "control", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("X-Stash-Variant"))
+
+		rec = do(t, http.MethodGet, "/kv/app/plain?subject=user-1", "", nil)
+		assert.Equal(t, "control", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Vary"), "no variants, no vary")
+	})
+
+	t.Run("get, set and delete spec", func(t *testing.T) {
+		rec := do(t, http.MethodGet, "/kv/app/plain?variants", "", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(t, http.MethodPut, "/kv/app/plain?variants", `{"variants":[{"name":"b","value":"x","percent":10}]}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"variants":[{"name":"b","value":"x","percent":10}]}`, specs["app/plain"])
+		require.Len(t, events.PublishCalls(), 1)
+
+		rec = do(t, http.MethodGet, "/kv/app/plain?variants", "", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, specs["app/plain"], rec.Body.String())
+
+		rec = do(t, http.MethodDelete, "/kv/app/plain?variants", "", nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, specs["app/plain"])
+		assert.Empty(t, st.DeleteCalls(), "key itself is kept")
+	})
+
+	t.Run("set rejects", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do(t, http.MethodPut, "/kv/app/plain?variants", `{"variants":[]}`, nil).Code)
+		assert.Equal(t, http.StatusBadRequest,
+			do(t, http.MethodPut, "/kv/app/plain?variants", `{"variants":[{"name":"a","format":"bad"}]}`, nil).Code)
+		assert.Equal(t, http.StatusBadRequest,
+			do(t, http.MethodPut, "/kv/secrets/db?variants", `{"variants":[{"name":"a"}]}`, nil).Code)
+		assert.Equal(t, http.StatusNotFound,
+			do(t, http.MethodPut, "/kv/missing?variants", `{"variants":[{"name":"a"}]}`, nil).Code)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		rec := do(t, http.MethodPut, "/kv/app/plain?override=instance:web-3", `{"canary":1}`,
+			map[string]string{"X-Stash-Format": "json"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"variants":[{"name":"override:instance:web-3","value":"{\"canary\":1}","format":"json",`+
+			`"match":{"instance":"web-3"}}]}`, specs["app/plain"])
+
+		rec = do(t, http.MethodGet, "/kv/app/plain", "", map[string]string{"X-Stash-Instance": "web-3"})
+		assert.JSONEq(t, `{"canary":1}`, rec.Body.String())
+		assert.Equal(t, "override:instance:web-3", rec.Header().Get("X-Stash-Variant"))
+		assert.Equal(t, "control", do(t, http.MethodGet, "/kv/app/plain", "", map[string]string{"X-Stash-Instance": "web-4"}).Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, do(t, http.MethodPut, "/kv/app/plain?override=web-3", "x", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(t, http.MethodPut, "/kv/missing?override=instance:a", "x", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(t, http.MethodDelete, "/kv/app/plain?override=instance:web-9", "", nil).Code)
+
+		rec = do(t, http.MethodDelete, "/kv/app/plain?override=instance:web-3", "", nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, specs["app/plain"], "last override removes the spec")
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		noVariants := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()})
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/banner?variants", http.NoBody)
+		req.SetPathValue("key", "app/banner")
+		rec := httptest.NewRecorder()
+		noVariants.handleGet(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
 func TestHandler_HandleDelete_WithGit(t *testing.T) {
 	st := &mocks.KVStoreMock{
 		DeleteFunc: func(context.Context, string) error { return nil },
@@ -679,7 +1295,7 @@ func TestHandler_HandleDelete_WithGit(t *testing.T) {
 func TestHandler_HandleSet_PublishesEvent(t *testing.T) {
 	t.Run("create event", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
 		}
 		eventsMock := &mocks.EventPublisherMock{
 			PublishFunc: func(key string, action enum.AuditAction) {},
@@ -699,7 +1315,7 @@ func TestHandler_HandleSet_PublishesEvent(t *testing.T) {
 
 	t.Run("update event", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) { return false, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, nil },
 		}
 		eventsMock := &mocks.EventPublisherMock{
 			PublishFunc: func(key string, action enum.AuditAction) {},
@@ -761,6 +1377,162 @@ func noopAuthMock() *mocks.AuthProviderMock {
 	}
 }
 
+func TestHandler_SnapshotHeaders(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "app/db/host"}}, 1, nil
+		},
+		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
+			return []byte("value"), "text", nil
+		},
+	}
+	snaps := &mocks.SnapshotProviderMock{
+		TokenFunc:        func() string { return "abc-5" },
+		LastModifiedFunc: func(string) time.Time { return time.Now() },
+		ChangedSinceFunc: func(token string) ([]string, bool) {
+			switch token {
+			case "abc-5":
+				return nil, true
+			case "abc-3":
+				return []string{"app/db/host", "app/db/port", "secret/key", "top"}, true
+			default:
+				return nil, false
+			}
+		},
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc: func() bool { return true },
+		FilterKeysForRequestFunc: func(_ *http.Request, keys []string) []string {
+			return slices.DeleteFunc(slices.Clone(keys), func(k string) bool { return strings.HasPrefix(k, "secret/") })
+		},
+	}
+	h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator(), Snapshots: snaps})
+
+	tests := []struct {
+		name, token, changed string
+		hasChanged           bool
+	}{
+		{name: "no token", token: ""},
+		{name: "up to date", token: "abc-5", changed: "", hasChanged: true},
+		{name: "stale", token: "abc-3", changed: "app/db/,top", hasChanged: true},
+		{name: "expired", token: "other-1", changed: "*", hasChanged: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, target := range []string{"/kv/", "/kv/app/db/host"} {
+				req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+				if tc.token != "" {
+					req.Header.Set("X-Stash-Snapshot", tc.token)
+				}
+				rec := httptest.NewRecorder()
+				if target == "/kv/" {
+					h.handleList(rec, req)
+				} else {
+					req.SetPathValue("key", "app/db/host")
+					h.handleGet(rec, req)
+				}
+
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, "abc-5", rec.Header().Get("X-Stash-Snapshot"))
+				changed, ok := rec.Header()["X-Stash-Changed-Prefixes"]
+				assert.Equal(t, tc.hasChanged, ok, "changed prefixes header presence")
+				if tc.hasChanged {
+					assert.Equal(t, []string{tc.changed}, changed)
+				}
+			}
+		})
+	}
+
+	t.Run("disabled without provider", func(t *testing.T) {
+		h := newTestHandler(t, st, noopAuthMock())
+		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+		req.Header.Set("X-Stash-Snapshot", "abc-3")
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		assert.Empty(t, rec.Header().Get("X-Stash-Snapshot"))
+		assert.Empty(t, rec.Header().Values("X-Stash-Changed-Prefixes"))
+	})
+}
+
+func TestHandler_HandleList_IfModifiedSince(t *testing.T) {
+	lastChange := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			keys := slices.DeleteFunc([]store.KeyInfo{
+				{Key: "app/a", UpdatedAt: lastChange.Add(-time.Hour)},
+				{Key: "app/b", UpdatedAt: lastChange.Add(500 * time.Millisecond)},
+				{Key: "other/c", UpdatedAt: lastChange.Add(time.Hour)},
+			}, func(k store.KeyInfo) bool { return !strings.HasPrefix(k.Key, q.Prefix) })
+			return keys, len(keys), nil
+		},
+	}
+	var trackerTime time.Time
+	snaps := &mocks.SnapshotProviderMock{
+		TokenFunc:        func() string { return "abc-1" },
+		ChangedSinceFunc: func(string) ([]string, bool) { return nil, true },
+		LastModifiedFunc: func(prefix string) time.Time {
+			assert.Equal(t, "app/", prefix)
+			return trackerTime
+		},
+	}
+	h := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator(), Snapshots: snaps})
+
+	list := func(ims string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/?prefix=app/", http.NoBody)
+		if ims != "" {
+			req.Header.Set("If-Modified-Since", ims)
+		}
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		return rec
+	}
+
+	t.Run("last modified from keys", func(t *testing.T) {
+		trackerTime = lastChange.Add(-24 * time.Hour)
+		rec := list("")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Wed, 15 Jan 2025 10:00:00 GMT", rec.Header().Get("Last-Modified"))
+
+		rec = list("Wed, 15 Jan 2025 10:00:00 GMT")
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+
+		rec = list("Wed, 15 Jan 2025 09:59:59 GMT")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "app/b")
+	})
+
+	t.Run("delete tracked by change log", func(t *testing.T) {
+		trackerTime = lastChange.Add(time.Minute)
+		rec := list("Wed, 15 Jan 2025 10:00:00 GMT")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Wed, 15 Jan 2025 10:01:00 GMT", rec.Header().Get("Last-Modified"))
+	})
+
+	t.Run("change within current second", func(t *testing.T) {
+		trackerTime = time.Now()
+		rec := list(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Last-Modified"))
+	})
+
+	t.Run("invalid header ignored", func(t *testing.T) {
+		trackerTime = lastChange
+		rec := list("not a date")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("no change tracking", func(t *testing.T) {
+		h := newTestHandler(t, st, noopAuthMock())
+		req := httptest.NewRequest(http.MethodGet, "/kv/?prefix=app/", http.NoBody)
+		req.Header.Set("If-Modified-Since", "Wed, 15 Jan 2030 10:00:00 GMT")
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Last-Modified"))
+	})
+}
+
 func TestHandler_GetAuthorFromRequest(t *testing.T) {
 	st := &mocks.KVStoreMock{}
 
@@ -915,3 +1687,249 @@ func TestHandler_HandleHistory(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "failed to get history")
 	})
 }
+
+func TestHandler_HandleList_CSV(t *testing.T) {
+	updated := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			assert.Equal(t, enum.SecretsFilterSecretsOnly, q.Filter)
+			return []store.KeyInfo{
+				{Key: "secrets/db", Size: 88, Format: "text", Secret: true, CreatedAt: updated, UpdatedAt: updated},
+				{Key: "secrets/=cmd", Size: 40, Format: "json", Secret: true, CreatedAt: updated, UpdatedAt: updated},
+			}, 2, nil
+		},
+	}
+	h := newTestHandler(t, st, noopAuthMock())
+
+	t.Run("secrets inventory", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/kv/?filter=secrets&output=csv", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), `attachment; filename="stash-secrets-`)
+		assert.Equal(t, "key,secret,zk_encrypted,format,size,created_at,updated_at\n"+
+			"secrets/db,true,false,text,88,2025-01-15T10:00:00Z,2025-01-15T10:00:00Z\n"+
+			"secrets/=cmd,true,false,json,40,2025-01-15T10:00:00Z,2025-01-15T10:00:00Z\n", rec.Body.String())
+		assert.Empty(t, st.GetCalls(), "values must not be loaded")
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/kv/?filter=secrets&output=xml", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid output parameter")
+	})
+}
+
+func TestHandler_Environments(t *testing.T) {
+	updated := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	values := map[string]string{"app/db": "base-db", "app/level": "info", "app/name": "svc",
+		"@staging/app/db": "staging-db", "@staging/app/level": "debug", "@prod/app/db": "prod-db", "other/x": "x"}
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
+			v, ok := values[key]
+			if !ok {
+				return nil, "", store.ErrNotFound
+			}
+			return []byte(v), "text", nil
+		},
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			var names []string
+			for k := range values {
+				if strings.HasPrefix(k, q.Prefix) {
+					names = append(names, k)
+				}
+			}
+			slices.Sort(names)
+			if q.Allow != nil {
+				names = q.Allow(names)
+			}
+			res := make([]store.KeyInfo, 0, len(names))
+			for _, k := range names {
+				res = append(res, store.KeyInfo{Key: k, UpdatedAt: updated})
+			}
+			return res, len(res), nil
+		},
+	}
+	envs, err := environ.New([]string{"staging", "prod:staging"})
+	require.NoError(t, err)
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc: func() bool { return true },
+		FilterKeysForRequestFunc: func(_ *http.Request, keys []string) []string {
+			// allowed prod keys and default keys, no staging
+			return slices.DeleteFunc(slices.Clone(keys), func(k string) bool { return strings.HasPrefix(k, "@staging/") })
+		},
+	}
+	h := New(Deps{Store: st, Auth: auth, Validator: defaultFormatValidator(), Envs: envs})
+	router := routegroup.New(http.NewServeMux())
+	router.Mount("/kv").Route(func(kv *routegroup.Bundle) {
+		kv.Use(envs.Middleware)
+		h.Register(kv)
+	})
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, http.NoBody))
+		return rec
+	}
+
+	tbl := []struct {
+		url, value, source string
+	}{
+		{url: "/kv/app/db?env=prod", value: "prod-db", source: "prod"},
+		{url: "/kv/app/level?env=prod", value: "debug", source: "staging"},
+		{url: "/kv/app/name?env=prod", value: "svc", source: ""},
+		{url: "/kv/app/db?env=staging", value: "staging-db", source: "staging"},
+		{url: "/kv/app/db", value: "base-db"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.url, func(t *testing.T) {
+			rec := get(tt.url)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.value, rec.Body.String())
+			assert.Equal(t, tt.source, rec.Header().Get("X-Stash-Env"))
+		})
+	}
+	assert.Equal(t, http.StatusNotFound, get("/kv/app/missing?env=prod").Code)
+
+	t.Run("list merges inherited keys", func(t *testing.T) {
+		rec := get("/kv/?env=prod&prefix=app/")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var keys []store.KeyInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+		names := make([]string, 0, len(keys))
+		for _, k := range keys {
+			names = append(names, k.Key)
+		}
+		assert.Equal(t, []string{"app/db", "app/level", "app/name"}, names)
+		assert.Empty(t, rec.Header().Get("Last-Modified"))
+	})
+
+	t.Run("default list hides environment keys", func(t *testing.T) {
+		rec := get("/kv/")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "@")
+		assert.Contains(t, rec.Body.String(), "other/x")
+	})
+
+	t.Run("list permissions of the environment", func(t *testing.T) {
+		rec := get("/kv/?env=staging")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "[]\n", rec.Body.String(), "staging keys are not allowed, inherited ones neither")
+	})
+}
+
+func TestHandler_ConsistencyTokens(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		DeleteFunc:         func(context.Context, string) error { return nil },
+		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("v1"), "text", nil },
+	}
+	h := New(Deps{Store: st, Auth: noopAuthMock(), Validator: defaultFormatValidator()})
+
+	versions := []int64{}
+	for _, method := range []string{http.MethodPut, http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/kv/app/config", strings.NewReader("v1"))
+		req.SetPathValue("key", "app/config")
+		rec := httptest.NewRecorder()
+		if method == http.MethodDelete {
+			h.handleDelete(rec, req)
+		} else {
+			h.handleSet(rec, req)
+		}
+		require.Less(t, rec.Code, 300)
+		v, err := strconv.ParseInt(rec.Header().Get("X-Stash-Version"), 10, 64)
+		require.NoError(t, err)
+		versions = append(versions, v)
+	}
+	assert.Less(t, versions[0], versions[1])
+	assert.Less(t, versions[1], versions[2])
+
+	tbl := []struct {
+		name, header, query string
+		code                int
+	}{
+		{name: "no token", code: http.StatusOK},
+		{name: "header", header: strconv.FormatInt(versions[2], 10), code: http.StatusOK},
+		{name: "query", query: "?min_version=" + strconv.FormatInt(versions[2], 10), code: http.StatusOK},
+		{name: "invalid", header: "abc", code: http.StatusBadRequest},
+		{name: "negative", query: "?min_version=-5", code: http.StatusBadRequest},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/kv/app/config"+tt.query, http.NoBody)
+			req.SetPathValue("key", "app/config")
+			if tt.header != "" {
+				req.Header.Set("X-Stash-Min-Version", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.handleGet(rec, req)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Empty(t, rec.Header().Get("X-Stash-Version"), "reads don't issue tokens")
+		})
+	}
+}
+
+func TestHandler_HandleList_NDJSON(t *testing.T) {
+	keys := make([]store.KeyInfo, 1200)
+	for i := range keys {
+		keys[i] = store.KeyInfo{Key: fmt.Sprintf("app/key-%04d", i), Size: i, Format: "text"}
+	}
+	failAt := -1
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			if q.Offset == failAt {
+				return nil, 0, errors.New("db gone")
+			}
+			if q.Limit == 0 {
+				return keys, len(keys), nil
+			}
+			return keys[min(q.Offset, len(keys)):min(q.Offset+q.Limit, len(keys))], len(keys), nil
+		},
+	}
+	h := newTestHandler(t, st, noopAuthMock())
+	list := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/?prefix=app/", http.NoBody)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.handleList(rec, req)
+		return rec
+	}
+
+	t.Run("streams all pages", func(t *testing.T) {
+		rec := list("application/json;q=0.5, application/x-ndjson")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		require.Len(t, lines, len(keys))
+		var last store.KeyInfo
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+		assert.Equal(t, "app/key-1199", last.Key)
+		calls := st.ListPageCalls()
+		require.Len(t, calls, 3, "read a page at a time")
+		assert.Equal(t, streamPageSize, calls[2].Q.Limit)
+		assert.Equal(t, 2*streamPageSize, calls[2].Q.Offset)
+	})
+
+	t.Run("json without accept", func(t *testing.T) {
+		rec := list("")
+		assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("error after first page", func(t *testing.T) {
+		failAt = streamPageSize
+		defer func() { failAt = -1 }()
+		rec := list("application/x-ndjson")
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		require.Len(t, lines, streamPageSize+1)
+		assert.JSONEq(t, `{"error":"failed to list keys"}`, lines[streamPageSize])
+	})
+
+	t.Run("error before first key", func(t *testing.T) {
+		failAt = 0
+		defer func() { failAt = -1 }()
+		assert.Equal(t, http.StatusInternalServerError, list("application/x-ndjson").Code)
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/store"
+)
+
+// rollbackParam selects the previous version a key is set back to, PUT /kv/{key...}?rollback=42
+const rollbackParam = "rollback"
+
+// historyLimit is the max number of entries returned by the history endpoint
+const historyLimit = 50
+
+// HistoryStore defines the interface for previous values of keys kept in the database.
+type HistoryStore interface {
+	GetHistory(ctx context.Context, key string, limit int) ([]store.Revision, error)
+	Rollback(ctx context.Context, key string, version int64, owner string) (rev store.Revision, created bool, err error)
+}
+
+// HistoryPolicy defines the interface for keys with history shown to admins only.
+type HistoryPolicy interface {
+	Visible(key string, admin bool) bool
+}
+
+// historyVisible reports whether the caller can see previous revisions of the key. Rollback is covered too,
+// as it would bring an old value back for reading.
+func (h *Handler) historyVisible(r *http.Request, key string) bool {
+	if h.HistoryAccess == nil || h.Auth == nil || !h.Auth.Enabled() {
+		return true
+	}
+	return h.HistoryAccess.Visible(key, h.Auth.IsRequestAdmin(r))
+}
+
+// handleStoreHistory returns the previous values of a key kept in the database, newest first.
+// Used when git is disabled, entries have a version for rollback instead of a commit hash.
+func (h *Handler) handleStoreHistory(w http.ResponseWriter, r *http.Request, key string) {
+	revs, err := h.History.GetHistory(r.Context(), key, historyLimit)
+	if err != nil {
+		if errors.Is(err, store.ErrSecretsNotConfigured) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "secrets not configured")
+			return
+		}
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get history")
+		return
+	}
+
+	resp := make([]historyResponse, len(revs))
+	for i, rev := range revs {
+		resp[i] = historyResponse{
+			Version:   rev.Version,
+			Timestamp: rev.UpdatedAt.UTC().Format(time.RFC3339),
+			Format:    rev.Format,
+			Value:     base64.StdEncoding.EncodeToString(rev.Value),
+		}
+	}
+	rest.RenderJSON(w, resp)
+}
+
+// handleRollback sets the key back to a previous version from the database history, the current value
+// is kept in the history as with any update. Deleted keys can be rolled back too.
+// PUT /kv/{key...}?rollback=42
+func (h *Handler) handleRollback(w http.ResponseWriter, r *http.Request, key string) {
+	if h.History == nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "history is not enabled")
+		return
+	}
+	if !h.historyVisible(r, key) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "history of the key is restricted to admins")
+		return
+	}
+	version, err := strconv.ParseInt(r.URL.Query().Get(rollbackParam), 10, 64)
+	if err != nil || version <= 0 {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid rollback version")
+		return
+	}
+
+	rev, created, err := h.History.Rollback(r.Context(), key, version, ownership.Owner(h.getIdentityForLog(r)))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "version not found")
+		case errors.Is(err, store.ErrSecretsNotConfigured):
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "secrets not configured")
+		case errors.Is(err, store.ErrSealed):
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, err, "secrets sealed")
+		case errors.Is(err, store.ErrFrozen):
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusLocked, err, frozenMessage(err))
+		default:
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to roll back key")
+		}
+		return
+	}
+
+	operation, action := "update", enum.AuditActionUpdate
+	if created {
+		operation, action = "create", enum.AuditActionCreate
+	}
+	log.Printf("[INFO] rollback %q to version %d by %s", key, version, h.getIdentityForLog(r))
+
+	if h.Git != nil {
+		req := git.CommitRequest{Key: key, Value: rev.Value, Operation: operation, Format: rev.Format,
+			Author: h.getAuthorFromRequest(r)}
+		if err := h.Git.Commit(req); err != nil {
+			log.Printf("[WARN] git commit failed for %s: %v", key, err)
+		}
+	}
+	if h.Events != nil {
+		h.Events.Publish(key, action)
+	}
+
+	h.setVersion(w)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
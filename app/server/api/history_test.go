@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/api/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandleHistory_Store(t *testing.T) {
+	auth := &mocks.AuthProviderMock{EnabledFunc: func() bool { return false }}
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	hist := &mocks.HistoryStoreMock{
+		GetHistoryFunc: func(_ context.Context, key string, limit int) ([]store.Revision, error) {
+			if key == "secrets/db" {
+				return nil, store.ErrSecretsNotConfigured
+			}
+			return []store.Revision{{Version: 7, Value: []byte("old"), Format: "text", UpdatedAt: updated}}, nil
+		},
+	}
+	h := New(Deps{Store: &mocks.KVStoreMock{}, Auth: auth, Validator: defaultFormatValidator(), History: hist})
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/history/"+key, http.NoBody)
+		req.SetPathValue("key", key)
+		rec := httptest.NewRecorder()
+		h.handleHistory(rec, req)
+		return rec
+	}
+
+	rec := get("app/config")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"version":7,"timestamp":"2025-03-01T12:00:00Z","format":"text","value":"b2xk"}]`, rec.Body.String())
+	require.Len(t, hist.GetHistoryCalls(), 1)
+	assert.Equal(t, historyLimit, hist.GetHistoryCalls()[0].Limit)
+
+	assert.Equal(t, http.StatusBadRequest, get("secrets/db").Code)
+
+	t.Run("git history takes precedence", func(t *testing.T) {
+		gitSvc := &mocks.GitServiceMock{
+			HistoryFunc: func(string, int) ([]git.HistoryEntry, error) { return []git.HistoryEntry{{Hash: "abc123"}}, nil },
+		}
+		h := New(Deps{Store: &mocks.KVStoreMock{}, Auth: auth, Validator: defaultFormatValidator(), Git: gitSvc, History: hist})
+		req := httptest.NewRequest(http.MethodGet, "/kv/history/app/config", http.NoBody)
+		req.SetPathValue("key", "app/config")
+		rec := httptest.NewRecorder()
+		h.handleHistory(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"hash":"abc123"`)
+	})
+}
+
+func TestHandler_HandleSet_Rollback(t *testing.T) {
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:         func() bool { return false },
+		GetRequestActorFunc: func(*http.Request) (string, string) { return "user", "admin" },
+	}
+	hist := &mocks.HistoryStoreMock{
+		RollbackFunc: func(_ context.Context, key string, version int64, _ string) (store.Revision, bool, error) {
+			switch {
+			case version == 404:
+				return store.Revision{}, false, store.ErrNotFound
+			case version == 500:
+				return store.Revision{}, false, errors.New("db is gone")
+			case key == "app/deleted":
+				return store.Revision{Version: version, Value: []byte("restored"), Format: "text"}, true, nil
+			}
+			return store.Revision{Version: version, Value: []byte(`{"a":1}`), Format: "json"}, false, nil
+		},
+	}
+	gitSvc := &mocks.GitServiceMock{CommitFunc: func(git.CommitRequest) error { return nil }}
+	events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+	h := New(Deps{Store: &mocks.KVStoreMock{}, Auth: auth, Validator: defaultFormatValidator(),
+		History: hist, Git: gitSvc, Events: events})
+
+	put := func(h *Handler, key, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/kv/"+key+"?rollback="+version, http.NoBody)
+		req.SetPathValue("key", key)
+		rec := httptest.NewRecorder()
+		h.handleSet(rec, req)
+		return rec
+	}
+
+	rec := put(h, "app/config", "12")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Stash-Version"))
+	require.Len(t, hist.RollbackCalls(), 1)
+	assert.Equal(t, int64(12), hist.RollbackCalls()[0].Version)
+	assert.Empty(t, hist.RollbackCalls()[0].Owner, "anonymous callers don't own keys")
+	require.Len(t, gitSvc.CommitCalls(), 1)
+	assert.Equal(t, "update", gitSvc.CommitCalls()[0].Req.Operation)
+	assert.Equal(t, "json", gitSvc.CommitCalls()[0].Req.Format)
+	assert.Equal(t, `{"a":1}`, string(gitSvc.CommitCalls()[0].Req.Value))
+	assert.Equal(t, enum.AuditActionUpdate, events.PublishCalls()[0].Action)
+
+	rec = put(h, "app/deleted", "3")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "create", gitSvc.CommitCalls()[1].Req.Operation)
+	assert.Equal(t, enum.AuditActionCreate, events.PublishCalls()[1].Action)
+
+	assert.Equal(t, http.StatusNotFound, put(h, "app/config", "404").Code)
+	assert.Equal(t, http.StatusInternalServerError, put(h, "app/config", "500").Code)
+	assert.Equal(t, http.StatusBadRequest, put(h, "app/config", "latest").Code)
+	assert.Equal(t, http.StatusBadRequest, put(h, "app/config", "-1").Code)
+	assert.Len(t, hist.RollbackCalls(), 4, "invalid versions don't reach the store")
+
+	noHistory := New(Deps{Store: &mocks.KVStoreMock{}, Auth: auth, Validator: defaultFormatValidator()})
+	rec = put(noHistory, "app/config", "12")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "history is not enabled")
+}
+
+func TestHandler_HistoryAccess(t *testing.T) {
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:              func() bool { return true },
+		FilterKeysForRequestFunc: func(_ *http.Request, keys []string) []string { return keys },
+		IsRequestAdminFunc:       func(r *http.Request) bool { return r.Header.Get("X-Admin") == "true" },
+		GetRequestActorFunc:      func(*http.Request) (string, string) { return "user", "admin" },
+	}
+	policy := &mocks.HistoryPolicyMock{
+		VisibleFunc: func(key string, admin bool) bool { return admin || key != "prod/db" },
+	}
+	hist := &mocks.HistoryStoreMock{
+		GetHistoryFunc: func(context.Context, string, int) ([]store.Revision, error) { return nil, nil },
+		RollbackFunc: func(context.Context, string, int64, string) (store.Revision, bool, error) {
+			return store.Revision{Value: []byte("old"), Format: "text"}, false, nil
+		},
+	}
+	h := New(Deps{Store: &mocks.KVStoreMock{}, Auth: auth, Validator: defaultFormatValidator(), History: hist,
+		HistoryAccess: policy})
+
+	call := func(method, target, key string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, http.NoBody)
+		req.SetPathValue("key", key)
+		if admin {
+			req.Header.Set("X-Admin", "true")
+		}
+		rec := httptest.NewRecorder()
+		if method == http.MethodGet {
+			h.handleHistory(rec, req)
+		} else {
+			h.handleSet(rec, req)
+		}
+		return rec
+	}
+
+	rec := call(http.MethodGet, "/kv/history/prod/db", "prod/db", false)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "restricted to admins")
+	assert.Equal(t, http.StatusForbidden, call(http.MethodPut, "/kv/prod/db?rollback=3", "prod/db", false).Code)
+	assert.Empty(t, hist.GetHistoryCalls())
+	assert.Empty(t, hist.RollbackCalls())
+
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/kv/history/app/config", "app/config", false).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/kv/history/prod/db", "prod/db", true).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodPut, "/kv/prod/db?rollback=3", "prod/db", true).Code)
+	assert.Len(t, hist.GetHistoryCalls(), 2)
+	assert.Len(t, hist.RollbackCalls(), 1)
+}
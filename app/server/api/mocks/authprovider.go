@@ -6,6 +6,8 @@ package mocks
 import (
 	"net/http"
 	"sync"
+
+	"github.com/umputun/stash/app/enum"
 )
 
 // AuthProviderMock is a mock implementation of api.AuthProvider.
@@ -23,6 +25,12 @@ import (
 //			GetRequestActorFunc: func(r *http.Request) (string, string) {
 //				panic("mock out the GetRequestActor method")
 //			},
+//			IsRequestAdminFunc: func(r *http.Request) bool {
+//				panic("mock out the IsRequestAdmin method")
+//			},
+//			RequestCanChangeFunc: func(r *http.Request, key string, scope enum.Scope) bool {
+//				panic("mock out the RequestCanChange method")
+//			},
 //		}
 //
 //		// use mockedAuthProvider in code that requires api.AuthProvider
@@ -39,6 +47,12 @@ type AuthProviderMock struct {
 	// GetRequestActorFunc mocks the GetRequestActor method.
 	GetRequestActorFunc func(r *http.Request) (string, string)
 
+	// IsRequestAdminFunc mocks the IsRequestAdmin method.
+	IsRequestAdminFunc func(r *http.Request) bool
+
+	// RequestCanChangeFunc mocks the RequestCanChange method.
+	RequestCanChangeFunc func(r *http.Request, key string, scope enum.Scope) bool
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Enabled holds details about calls to the Enabled method.
@@ -56,10 +70,26 @@ type AuthProviderMock struct {
 			// R is the r argument value.
 			R *http.Request
 		}
+		// IsRequestAdmin holds details about calls to the IsRequestAdmin method.
+		IsRequestAdmin []struct {
+			// R is the r argument value.
+			R *http.Request
+		}
+		// RequestCanChange holds details about calls to the RequestCanChange method.
+		RequestCanChange []struct {
+			// R is the r argument value.
+			R *http.Request
+			// Key is the key argument value.
+			Key string
+			// Scope is the scope argument value.
+			Scope enum.Scope
+		}
 	}
 	lockEnabled              sync.RWMutex
 	lockFilterKeysForRequest sync.RWMutex
 	lockGetRequestActor      sync.RWMutex
+	lockIsRequestAdmin       sync.RWMutex
+	lockRequestCanChange     sync.RWMutex
 }
 
 // Enabled calls EnabledFunc.
@@ -156,3 +186,75 @@ func (mock *AuthProviderMock) GetRequestActorCalls() []struct {
 	mock.lockGetRequestActor.RUnlock()
 	return calls
 }
+
+// IsRequestAdmin calls IsRequestAdminFunc.
+func (mock *AuthProviderMock) IsRequestAdmin(r *http.Request) bool {
+	if mock.IsRequestAdminFunc == nil {
+		panic("AuthProviderMock.IsRequestAdminFunc: method is nil but AuthProvider.IsRequestAdmin was just called")
+	}
+	callInfo := struct {
+		R *http.Request
+	}{
+		R: r,
+	}
+	mock.lockIsRequestAdmin.Lock()
+	mock.calls.IsRequestAdmin = append(mock.calls.IsRequestAdmin, callInfo)
+	mock.lockIsRequestAdmin.Unlock()
+	return mock.IsRequestAdminFunc(r)
+}
+
+// IsRequestAdminCalls gets all the calls that were made to IsRequestAdmin.
+// Check the length with:
+//
+//	len(mockedAuthProvider.IsRequestAdminCalls())
+func (mock *AuthProviderMock) IsRequestAdminCalls() []struct {
+	R *http.Request
+} {
+	var calls []struct {
+		R *http.Request
+	}
+	mock.lockIsRequestAdmin.RLock()
+	calls = mock.calls.IsRequestAdmin
+	mock.lockIsRequestAdmin.RUnlock()
+	return calls
+}
+
+// RequestCanChange calls RequestCanChangeFunc.
+func (mock *AuthProviderMock) RequestCanChange(r *http.Request, key string, scope enum.Scope) bool {
+	if mock.RequestCanChangeFunc == nil {
+		panic("AuthProviderMock.RequestCanChangeFunc: method is nil but AuthProvider.RequestCanChange was just called")
+	}
+	callInfo := struct {
+		R     *http.Request
+		Key   string
+		Scope enum.Scope
+	}{
+		R:     r,
+		Key:   key,
+		Scope: scope,
+	}
+	mock.lockRequestCanChange.Lock()
+	mock.calls.RequestCanChange = append(mock.calls.RequestCanChange, callInfo)
+	mock.lockRequestCanChange.Unlock()
+	return mock.RequestCanChangeFunc(r, key, scope)
+}
+
+// RequestCanChangeCalls gets all the calls that were made to RequestCanChange.
+// Check the length with:
+//
+//	len(mockedAuthProvider.RequestCanChangeCalls())
+func (mock *AuthProviderMock) RequestCanChangeCalls() []struct {
+	R     *http.Request
+	Key   string
+	Scope enum.Scope
+} {
+	var calls []struct {
+		R     *http.Request
+		Key   string
+		Scope enum.Scope
+	}
+	mock.lockRequestCanChange.RLock()
+	calls = mock.calls.RequestCanChange
+	mock.lockRequestCanChange.RUnlock()
+	return calls
+}
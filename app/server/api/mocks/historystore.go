@@ -0,0 +1,147 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// HistoryStoreMock is a mock implementation of api.HistoryStore.
+//
+//	func TestSomethingThatUsesHistoryStore(t *testing.T) {
+//
+//		// make and configure a mocked api.HistoryStore
+//		mockedHistoryStore := &HistoryStoreMock{
+//			GetHistoryFunc: func(ctx context.Context, key string, limit int) ([]store.Revision, error) {
+//				panic("mock out the GetHistory method")
+//			},
+//			RollbackFunc: func(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error) {
+//				panic("mock out the Rollback method")
+//			},
+//		}
+//
+//		// use mockedHistoryStore in code that requires api.HistoryStore
+//		// and then make assertions.
+//
+//	}
+type HistoryStoreMock struct {
+	// GetHistoryFunc mocks the GetHistory method.
+	GetHistoryFunc func(ctx context.Context, key string, limit int) ([]store.Revision, error)
+
+	// RollbackFunc mocks the Rollback method.
+	RollbackFunc func(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// GetHistory holds details about calls to the GetHistory method.
+		GetHistory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// Rollback holds details about calls to the Rollback method.
+		Rollback []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Version is the version argument value.
+			Version int64
+			// Owner is the owner argument value.
+			Owner string
+		}
+	}
+	lockGetHistory sync.RWMutex
+	lockRollback   sync.RWMutex
+}
+
+// GetHistory calls GetHistoryFunc.
+func (mock *HistoryStoreMock) GetHistory(ctx context.Context, key string, limit int) ([]store.Revision, error) {
+	if mock.GetHistoryFunc == nil {
+		panic("HistoryStoreMock.GetHistoryFunc: method is nil but HistoryStore.GetHistory was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Key   string
+		Limit int
+	}{
+		Ctx:   ctx,
+		Key:   key,
+		Limit: limit,
+	}
+	mock.lockGetHistory.Lock()
+	mock.calls.GetHistory = append(mock.calls.GetHistory, callInfo)
+	mock.lockGetHistory.Unlock()
+	return mock.GetHistoryFunc(ctx, key, limit)
+}
+
+// GetHistoryCalls gets all the calls that were made to GetHistory.
+// Check the length with:
+//
+//	len(mockedHistoryStore.GetHistoryCalls())
+func (mock *HistoryStoreMock) GetHistoryCalls() []struct {
+	Ctx   context.Context
+	Key   string
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Key   string
+		Limit int
+	}
+	mock.lockGetHistory.RLock()
+	calls = mock.calls.GetHistory
+	mock.lockGetHistory.RUnlock()
+	return calls
+}
+
+// Rollback calls RollbackFunc.
+func (mock *HistoryStoreMock) Rollback(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error) {
+	if mock.RollbackFunc == nil {
+		panic("HistoryStoreMock.RollbackFunc: method is nil but HistoryStore.Rollback was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Key     string
+		Version int64
+		Owner   string
+	}{
+		Ctx:     ctx,
+		Key:     key,
+		Version: version,
+		Owner:   owner,
+	}
+	mock.lockRollback.Lock()
+	mock.calls.Rollback = append(mock.calls.Rollback, callInfo)
+	mock.lockRollback.Unlock()
+	return mock.RollbackFunc(ctx, key, version, owner)
+}
+
+// RollbackCalls gets all the calls that were made to Rollback.
+// Check the length with:
+//
+//	len(mockedHistoryStore.RollbackCalls())
+func (mock *HistoryStoreMock) RollbackCalls() []struct {
+	Ctx     context.Context
+	Key     string
+	Version int64
+	Owner   string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Key     string
+		Version int64
+		Owner   string
+	}
+	mock.lockRollback.RLock()
+	calls = mock.calls.Rollback
+	mock.lockRollback.RUnlock()
+	return calls
+}
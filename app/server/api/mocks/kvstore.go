@@ -7,7 +7,6 @@ import (
 	"context"
 	"sync"
 
-	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/store"
 )
 
@@ -23,17 +22,23 @@ import (
 //			GetFunc: func(ctx context.Context, key string) ([]byte, error) {
 //				panic("mock out the Get method")
 //			},
+//			GetInfoFunc: func(ctx context.Context, key string) (store.KeyInfo, error) {
+//				panic("mock out the GetInfo method")
+//			},
 //			GetWithFormatFunc: func(ctx context.Context, key string) ([]byte, string, error) {
 //				panic("mock out the GetWithFormat method")
 //			},
-//			ListFunc: func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-//				panic("mock out the List method")
+//			ListPageFunc: func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+//				panic("mock out the ListPage method")
 //			},
 //			SecretsEnabledFunc: func() bool {
 //				panic("mock out the SecretsEnabled method")
 //			},
-//			SetFunc: func(ctx context.Context, key string, value []byte, format string) (bool, error) {
-//				panic("mock out the Set method")
+//			SetWithOptionsFunc: func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+//				panic("mock out the SetWithOptions method")
+//			},
+//			TxnFunc: func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+//				panic("mock out the Txn method")
 //			},
 //		}
 //
@@ -48,17 +53,23 @@ type KVStoreMock struct {
 	// GetFunc mocks the Get method.
 	GetFunc func(ctx context.Context, key string) ([]byte, error)
 
+	// GetInfoFunc mocks the GetInfo method.
+	GetInfoFunc func(ctx context.Context, key string) (store.KeyInfo, error)
+
 	// GetWithFormatFunc mocks the GetWithFormat method.
 	GetWithFormatFunc func(ctx context.Context, key string) ([]byte, string, error)
 
-	// ListFunc mocks the List method.
-	ListFunc func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
+	// ListPageFunc mocks the ListPage method.
+	ListPageFunc func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error)
 
 	// SecretsEnabledFunc mocks the SecretsEnabled method.
 	SecretsEnabledFunc func() bool
 
-	// SetFunc mocks the Set method.
-	SetFunc func(ctx context.Context, key string, value []byte, format string) (bool, error)
+	// SetWithOptionsFunc mocks the SetWithOptions method.
+	SetWithOptionsFunc func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error)
+
+	// TxnFunc mocks the Txn method.
+	TxnFunc func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
@@ -76,6 +87,13 @@ type KVStoreMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// GetInfo holds details about calls to the GetInfo method.
+		GetInfo []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
 		// GetWithFormat holds details about calls to the GetWithFormat method.
 		GetWithFormat []struct {
 			// Ctx is the ctx argument value.
@@ -83,18 +101,18 @@ type KVStoreMock struct {
 			// Key is the key argument value.
 			Key string
 		}
-		// List holds details about calls to the List method.
-		List []struct {
+		// ListPage holds details about calls to the ListPage method.
+		ListPage []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// Filter is the filter argument value.
-			Filter enum.SecretsFilter
+			// Q is the q argument value.
+			Q store.ListQuery
 		}
 		// SecretsEnabled holds details about calls to the SecretsEnabled method.
 		SecretsEnabled []struct {
 		}
-		// Set holds details about calls to the Set method.
-		Set []struct {
+		// SetWithOptions holds details about calls to the SetWithOptions method.
+		SetWithOptions []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// Key is the key argument value.
@@ -103,14 +121,25 @@ type KVStoreMock struct {
 			Value []byte
 			// Format is the format argument value.
 			Format string
+			// Opts is the opts argument value.
+			Opts store.SetOptions
+		}
+		// Txn holds details about calls to the Txn method.
+		Txn []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ops is the ops argument value.
+			Ops []store.TxnOp
 		}
 	}
 	lockDelete         sync.RWMutex
 	lockGet            sync.RWMutex
+	lockGetInfo        sync.RWMutex
 	lockGetWithFormat  sync.RWMutex
-	lockList           sync.RWMutex
+	lockListPage       sync.RWMutex
 	lockSecretsEnabled sync.RWMutex
-	lockSet            sync.RWMutex
+	lockSetWithOptions sync.RWMutex
+	lockTxn            sync.RWMutex
 }
 
 // Delete calls DeleteFunc.
@@ -185,6 +214,42 @@ func (mock *KVStoreMock) GetCalls() []struct {
 	return calls
 }
 
+// GetInfo calls GetInfoFunc.
+func (mock *KVStoreMock) GetInfo(ctx context.Context, key string) (store.KeyInfo, error) {
+	if mock.GetInfoFunc == nil {
+		panic("KVStoreMock.GetInfoFunc: method is nil but KVStore.GetInfo was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockGetInfo.Lock()
+	mock.calls.GetInfo = append(mock.calls.GetInfo, callInfo)
+	mock.lockGetInfo.Unlock()
+	return mock.GetInfoFunc(ctx, key)
+}
+
+// GetInfoCalls gets all the calls that were made to GetInfo.
+// Check the length with:
+//
+//	len(mockedKVStore.GetInfoCalls())
+func (mock *KVStoreMock) GetInfoCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockGetInfo.RLock()
+	calls = mock.calls.GetInfo
+	mock.lockGetInfo.RUnlock()
+	return calls
+}
+
 // GetWithFormat calls GetWithFormatFunc.
 func (mock *KVStoreMock) GetWithFormat(ctx context.Context, key string) ([]byte, string, error) {
 	if mock.GetWithFormatFunc == nil {
@@ -221,39 +286,39 @@ func (mock *KVStoreMock) GetWithFormatCalls() []struct {
 	return calls
 }
 
-// List calls ListFunc.
-func (mock *KVStoreMock) List(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
-	if mock.ListFunc == nil {
-		panic("KVStoreMock.ListFunc: method is nil but KVStore.List was just called")
+// ListPage calls ListPageFunc.
+func (mock *KVStoreMock) ListPage(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+	if mock.ListPageFunc == nil {
+		panic("KVStoreMock.ListPageFunc: method is nil but KVStore.ListPage was just called")
 	}
 	callInfo := struct {
-		Ctx    context.Context
-		Filter enum.SecretsFilter
+		Ctx context.Context
+		Q   store.ListQuery
 	}{
-		Ctx:    ctx,
-		Filter: filter,
+		Ctx: ctx,
+		Q:   q,
 	}
-	mock.lockList.Lock()
-	mock.calls.List = append(mock.calls.List, callInfo)
-	mock.lockList.Unlock()
-	return mock.ListFunc(ctx, filter)
+	mock.lockListPage.Lock()
+	mock.calls.ListPage = append(mock.calls.ListPage, callInfo)
+	mock.lockListPage.Unlock()
+	return mock.ListPageFunc(ctx, q)
 }
 
-// ListCalls gets all the calls that were made to List.
+// ListPageCalls gets all the calls that were made to ListPage.
 // Check the length with:
 //
-//	len(mockedKVStore.ListCalls())
-func (mock *KVStoreMock) ListCalls() []struct {
-	Ctx    context.Context
-	Filter enum.SecretsFilter
+//	len(mockedKVStore.ListPageCalls())
+func (mock *KVStoreMock) ListPageCalls() []struct {
+	Ctx context.Context
+	Q   store.ListQuery
 } {
 	var calls []struct {
-		Ctx    context.Context
-		Filter enum.SecretsFilter
+		Ctx context.Context
+		Q   store.ListQuery
 	}
-	mock.lockList.RLock()
-	calls = mock.calls.List
-	mock.lockList.RUnlock()
+	mock.lockListPage.RLock()
+	calls = mock.calls.ListPage
+	mock.lockListPage.RUnlock()
 	return calls
 }
 
@@ -284,46 +349,86 @@ func (mock *KVStoreMock) SecretsEnabledCalls() []struct {
 	return calls
 }
 
-// Set calls SetFunc.
-func (mock *KVStoreMock) Set(ctx context.Context, key string, value []byte, format string) (bool, error) {
-	if mock.SetFunc == nil {
-		panic("KVStoreMock.SetFunc: method is nil but KVStore.Set was just called")
+// SetWithOptions calls SetWithOptionsFunc.
+func (mock *KVStoreMock) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+	if mock.SetWithOptionsFunc == nil {
+		panic("KVStoreMock.SetWithOptionsFunc: method is nil but KVStore.SetWithOptions was just called")
 	}
 	callInfo := struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
 	}{
 		Ctx:    ctx,
 		Key:    key,
 		Value:  value,
 		Format: format,
+		Opts:   opts,
 	}
-	mock.lockSet.Lock()
-	mock.calls.Set = append(mock.calls.Set, callInfo)
-	mock.lockSet.Unlock()
-	return mock.SetFunc(ctx, key, value, format)
+	mock.lockSetWithOptions.Lock()
+	mock.calls.SetWithOptions = append(mock.calls.SetWithOptions, callInfo)
+	mock.lockSetWithOptions.Unlock()
+	return mock.SetWithOptionsFunc(ctx, key, value, format, opts)
 }
 
-// SetCalls gets all the calls that were made to Set.
+// SetWithOptionsCalls gets all the calls that were made to SetWithOptions.
 // Check the length with:
 //
-//	len(mockedKVStore.SetCalls())
-func (mock *KVStoreMock) SetCalls() []struct {
+//	len(mockedKVStore.SetWithOptionsCalls())
+func (mock *KVStoreMock) SetWithOptionsCalls() []struct {
 	Ctx    context.Context
 	Key    string
 	Value  []byte
 	Format string
+	Opts   store.SetOptions
 } {
 	var calls []struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
+	}
+	mock.lockSetWithOptions.RLock()
+	calls = mock.calls.SetWithOptions
+	mock.lockSetWithOptions.RUnlock()
+	return calls
+}
+
+// Txn calls TxnFunc.
+func (mock *KVStoreMock) Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+	if mock.TxnFunc == nil {
+		panic("KVStoreMock.TxnFunc: method is nil but KVStore.Txn was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ops []store.TxnOp
+	}{
+		Ctx: ctx,
+		Ops: ops,
+	}
+	mock.lockTxn.Lock()
+	mock.calls.Txn = append(mock.calls.Txn, callInfo)
+	mock.lockTxn.Unlock()
+	return mock.TxnFunc(ctx, ops)
+}
+
+// TxnCalls gets all the calls that were made to Txn.
+// Check the length with:
+//
+//	len(mockedKVStore.TxnCalls())
+func (mock *KVStoreMock) TxnCalls() []struct {
+	Ctx context.Context
+	Ops []store.TxnOp
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ops []store.TxnOp
 	}
-	mock.lockSet.RLock()
-	calls = mock.calls.Set
-	mock.lockSet.RUnlock()
+	mock.lockTxn.RLock()
+	calls = mock.calls.Txn
+	mock.lockTxn.RUnlock()
 	return calls
 }
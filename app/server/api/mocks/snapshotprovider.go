@@ -0,0 +1,152 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+	"time"
+)
+
+// SnapshotProviderMock is a mock implementation of api.SnapshotProvider.
+//
+//	func TestSomethingThatUsesSnapshotProvider(t *testing.T) {
+//
+//		// make and configure a mocked api.SnapshotProvider
+//		mockedSnapshotProvider := &SnapshotProviderMock{
+//			ChangedSinceFunc: func(token string) ([]string, bool) {
+//				panic("mock out the ChangedSince method")
+//			},
+//			LastModifiedFunc: func(prefix string) time.Time {
+//				panic("mock out the LastModified method")
+//			},
+//			TokenFunc: func() string {
+//				panic("mock out the Token method")
+//			},
+//		}
+//
+//		// use mockedSnapshotProvider in code that requires api.SnapshotProvider
+//		// and then make assertions.
+//
+//	}
+type SnapshotProviderMock struct {
+	// ChangedSinceFunc mocks the ChangedSince method.
+	ChangedSinceFunc func(token string) ([]string, bool)
+
+	// LastModifiedFunc mocks the LastModified method.
+	LastModifiedFunc func(prefix string) time.Time
+
+	// TokenFunc mocks the Token method.
+	TokenFunc func() string
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ChangedSince holds details about calls to the ChangedSince method.
+		ChangedSince []struct {
+			// Token is the token argument value.
+			Token string
+		}
+		// LastModified holds details about calls to the LastModified method.
+		LastModified []struct {
+			// Prefix is the prefix argument value.
+			Prefix string
+		}
+		// Token holds details about calls to the Token method.
+		Token []struct {
+		}
+	}
+	lockChangedSince sync.RWMutex
+	lockLastModified sync.RWMutex
+	lockToken        sync.RWMutex
+}
+
+// ChangedSince calls ChangedSinceFunc.
+func (mock *SnapshotProviderMock) ChangedSince(token string) ([]string, bool) {
+	if mock.ChangedSinceFunc == nil {
+		panic("SnapshotProviderMock.ChangedSinceFunc: method is nil but SnapshotProvider.ChangedSince was just called")
+	}
+	callInfo := struct {
+		Token string
+	}{
+		Token: token,
+	}
+	mock.lockChangedSince.Lock()
+	mock.calls.ChangedSince = append(mock.calls.ChangedSince, callInfo)
+	mock.lockChangedSince.Unlock()
+	return mock.ChangedSinceFunc(token)
+}
+
+// ChangedSinceCalls gets all the calls that were made to ChangedSince.
+// Check the length with:
+//
+//	len(mockedSnapshotProvider.ChangedSinceCalls())
+func (mock *SnapshotProviderMock) ChangedSinceCalls() []struct {
+	Token string
+} {
+	var calls []struct {
+		Token string
+	}
+	mock.lockChangedSince.RLock()
+	calls = mock.calls.ChangedSince
+	mock.lockChangedSince.RUnlock()
+	return calls
+}
+
+// LastModified calls LastModifiedFunc.
+func (mock *SnapshotProviderMock) LastModified(prefix string) time.Time {
+	if mock.LastModifiedFunc == nil {
+		panic("SnapshotProviderMock.LastModifiedFunc: method is nil but SnapshotProvider.LastModified was just called")
+	}
+	callInfo := struct {
+		Prefix string
+	}{
+		Prefix: prefix,
+	}
+	mock.lockLastModified.Lock()
+	mock.calls.LastModified = append(mock.calls.LastModified, callInfo)
+	mock.lockLastModified.Unlock()
+	return mock.LastModifiedFunc(prefix)
+}
+
+// LastModifiedCalls gets all the calls that were made to LastModified.
+// Check the length with:
+//
+//	len(mockedSnapshotProvider.LastModifiedCalls())
+func (mock *SnapshotProviderMock) LastModifiedCalls() []struct {
+	Prefix string
+} {
+	var calls []struct {
+		Prefix string
+	}
+	mock.lockLastModified.RLock()
+	calls = mock.calls.LastModified
+	mock.lockLastModified.RUnlock()
+	return calls
+}
+
+// Token calls TokenFunc.
+func (mock *SnapshotProviderMock) Token() string {
+	if mock.TokenFunc == nil {
+		panic("SnapshotProviderMock.TokenFunc: method is nil but SnapshotProvider.Token was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockToken.Lock()
+	mock.calls.Token = append(mock.calls.Token, callInfo)
+	mock.lockToken.Unlock()
+	return mock.TokenFunc()
+}
+
+// TokenCalls gets all the calls that were made to Token.
+// Check the length with:
+//
+//	len(mockedSnapshotProvider.TokenCalls())
+func (mock *SnapshotProviderMock) TokenCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockToken.RLock()
+	calls = mock.calls.Token
+	mock.lockToken.RUnlock()
+	return calls
+}
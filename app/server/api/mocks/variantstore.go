@@ -0,0 +1,133 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+)
+
+// VariantStoreMock is a mock implementation of api.VariantStore.
+//
+//	func TestSomethingThatUsesVariantStore(t *testing.T) {
+//
+//		// make and configure a mocked api.VariantStore
+//		mockedVariantStore := &VariantStoreMock{
+//			GetVariantsFunc: func(ctx context.Context, key string) (string, error) {
+//				panic("mock out the GetVariants method")
+//			},
+//			SetVariantsFunc: func(ctx context.Context, key string, spec string) error {
+//				panic("mock out the SetVariants method")
+//			},
+//		}
+//
+//		// use mockedVariantStore in code that requires api.VariantStore
+//		// and then make assertions.
+//
+//	}
+type VariantStoreMock struct {
+	// GetVariantsFunc mocks the GetVariants method.
+	GetVariantsFunc func(ctx context.Context, key string) (string, error)
+
+	// SetVariantsFunc mocks the SetVariants method.
+	SetVariantsFunc func(ctx context.Context, key string, spec string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// GetVariants holds details about calls to the GetVariants method.
+		GetVariants []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+		// SetVariants holds details about calls to the SetVariants method.
+		SetVariants []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Spec is the spec argument value.
+			Spec string
+		}
+	}
+	lockGetVariants sync.RWMutex
+	lockSetVariants sync.RWMutex
+}
+
+// GetVariants calls GetVariantsFunc.
+func (mock *VariantStoreMock) GetVariants(ctx context.Context, key string) (string, error) {
+	if mock.GetVariantsFunc == nil {
+		panic("VariantStoreMock.GetVariantsFunc: method is nil but VariantStore.GetVariants was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockGetVariants.Lock()
+	mock.calls.GetVariants = append(mock.calls.GetVariants, callInfo)
+	mock.lockGetVariants.Unlock()
+	return mock.GetVariantsFunc(ctx, key)
+}
+
+// GetVariantsCalls gets all the calls that were made to GetVariants.
+// Check the length with:
+//
+//	len(mockedVariantStore.GetVariantsCalls())
+func (mock *VariantStoreMock) GetVariantsCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockGetVariants.RLock()
+	calls = mock.calls.GetVariants
+	mock.lockGetVariants.RUnlock()
+	return calls
+}
+
+// SetVariants calls SetVariantsFunc.
+func (mock *VariantStoreMock) SetVariants(ctx context.Context, key string, spec string) error {
+	if mock.SetVariantsFunc == nil {
+		panic("VariantStoreMock.SetVariantsFunc: method is nil but VariantStore.SetVariants was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Key  string
+		Spec string
+	}{
+		Ctx:  ctx,
+		Key:  key,
+		Spec: spec,
+	}
+	mock.lockSetVariants.Lock()
+	mock.calls.SetVariants = append(mock.calls.SetVariants, callInfo)
+	mock.lockSetVariants.Unlock()
+	return mock.SetVariantsFunc(ctx, key, spec)
+}
+
+// SetVariantsCalls gets all the calls that were made to SetVariants.
+// Check the length with:
+//
+//	len(mockedVariantStore.SetVariantsCalls())
+func (mock *VariantStoreMock) SetVariantsCalls() []struct {
+	Ctx  context.Context
+	Key  string
+	Spec string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Key  string
+		Spec string
+	}
+	mock.lockSetVariants.RLock()
+	calls = mock.calls.SetVariants
+	mock.lockSetVariants.RUnlock()
+	return calls
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/jsonpatch"
+	"github.com/umputun/stash/app/store"
+)
+
+// content types of patch requests
+const (
+	mergePatchType = "application/merge-patch+json" // RFC 7386
+	jsonPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+// patchAttempts is the number of times a patch is applied again after the key was changed concurrently
+const patchAttempts = 3
+
+// handlePatch changes a part of a JSON value, with a JSON merge patch or a JSON patch selected by Content-Type.
+// The patch is applied to the current value on the server and stored only if the key wasn't changed meanwhile,
+// otherwise it's applied again to the new value. Responds with the patched value.
+// Returns 409 if a test operation fails or the key keeps changing, 422 if the patch doesn't apply to the value.
+// PATCH /kv/{key...}
+func (h *Handler) handlePatch(w http.ResponseWriter, r *http.Request) {
+	key := store.NormalizeKey(r.PathValue("key"))
+	if key == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "key is required")
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchType && mediaType != jsonPatchType) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnsupportedMediaType, err,
+			fmt.Sprintf("content type must be %s or %s", mergePatchType, jsonPatchType))
+		return
+	}
+	apply := jsonpatch.Merge
+	if mediaType == jsonPatchType {
+		apply = jsonpatch.Apply
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "failed to read body")
+		return
+	}
+
+	for range patchAttempts {
+		info, err := h.Store.GetInfo(r.Context(), key)
+		if err != nil {
+			h.sendPatchStoreError(w, r, err)
+			return
+		}
+		if info.Format != "json" {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil,
+				fmt.Sprintf("only json values can be patched, key has %s format", info.Format))
+			return
+		}
+		if info.ZKEncrypted {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "ZK-encrypted values can't be patched")
+			return
+		}
+		value, err := h.Store.Get(r.Context(), key)
+		if err != nil {
+			h.sendPatchStoreError(w, r, err)
+			return
+		}
+
+		patched, err := apply(value, patch)
+		if err != nil {
+			status := http.StatusUnprocessableEntity
+			switch {
+			case errors.Is(err, jsonpatch.ErrInvalidPatch):
+				status = http.StatusBadRequest
+			case errors.Is(err, jsonpatch.ErrTestFailed):
+				status = http.StatusConflict
+			}
+			rest.SendErrorJSON(w, r, log.Default(), status, err, err.Error())
+			return
+		}
+
+		// the version read with the value guards the write, a change in between fails it and the patch is retried
+		_, err = h.Store.Txn(r.Context(), []store.TxnOp{{Key: key, Value: patched, Format: info.Format, Version: info.UpdatedAt}})
+		var conflict *store.TxnConflictError
+		if errors.As(err, &conflict) {
+			log.Printf("[DEBUG] patch of %q conflicts with a concurrent change, retrying", key)
+			continue
+		}
+		if err != nil {
+			h.sendPatchStoreError(w, r, err)
+			return
+		}
+
+		log.Printf("[INFO] patch %q (%d bytes, %s) by %s", key, len(patched), mediaType, h.getIdentityForLog(r))
+		if h.Git != nil {
+			req := git.CommitRequest{Key: key, Value: patched, Operation: "update", Format: info.Format,
+				Author: h.getAuthorFromRequest(r)}
+			if err := h.Git.Commit(req); err != nil {
+				log.Printf("[WARN] git commit failed for %s: %v", key, err)
+			}
+		}
+		if h.Events != nil {
+			h.Events.Publish(key, enum.AuditActionUpdate)
+		}
+		h.setVersion(w)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(patched)
+		return
+	}
+	rest.SendErrorJSON(w, r, log.Default(), http.StatusConflict, nil,
+		fmt.Sprintf("key was changed concurrently %d times, patch not applied", patchAttempts))
+}
+
+// sendPatchStoreError responds with the status of a store error of a patch.
+func (h *Handler) sendPatchStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
+	case errors.Is(err, store.ErrSecretsNotConfigured):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "secrets not configured")
+	case errors.Is(err, store.ErrSealed):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusServiceUnavailable, err, "secrets sealed")
+	case errors.Is(err, store.ErrFrozen):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusLocked, err, frozenMessage(err))
+	default:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to patch key")
+	}
+}
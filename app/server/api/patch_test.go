@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/api/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandlePatch(t *testing.T) {
+	noAuth := &mocks.AuthProviderMock{
+		EnabledFunc:         func() bool { return false },
+		GetRequestActorFunc: func(*http.Request) (string, string) { return "", "" },
+	}
+	version := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	// patchStore holds a json value, conflicts is the number of writes failing with a concurrent change
+	patchStore := func(value, format string, conflicts int) *mocks.KVStoreMock {
+		st := &mocks.KVStoreMock{
+			GetInfoFunc: func(_ context.Context, key string) (store.KeyInfo, error) {
+				if key != "app/cfg" {
+					return store.KeyInfo{}, store.ErrNotFound
+				}
+				return store.KeyInfo{Key: key, Format: format, UpdatedAt: version}, nil
+			},
+			GetFunc: func(context.Context, string) ([]byte, error) { return []byte(value), nil },
+		}
+		st.TxnFunc = func(_ context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+			if len(st.TxnCalls()) <= conflicts {
+				return nil, &store.TxnConflictError{Key: ops[0].Key, Version: version.Add(time.Second)}
+			}
+			value = string(ops[0].Value)
+			return []store.TxnResult{{Key: ops[0].Key, Version: version.Add(time.Minute)}}, nil
+		}
+		return st
+	}
+	patch := func(h *Handler, key, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/kv/"+key, strings.NewReader(body))
+		req.SetPathValue("key", key)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.handlePatch(rec, req)
+		return rec
+	}
+
+	t.Run("merge patch", func(t *testing.T) {
+		st := patchStore(`{"db":{"host":"h","port":5432},"debug":true}`, "json", 0)
+		gitSvc := &mocks.GitServiceMock{CommitFunc: func(git.CommitRequest) error { return nil }}
+		events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+		h := New(Deps{Store: st, Auth: noAuth, Validator: defaultFormatValidator(), Git: gitSvc, Events: events})
+
+		rec := patch(h, "app/cfg", "application/merge-patch+json", `{"db":{"port":6432},"debug":null}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{"db":{"host":"h","port":6432}}`, rec.Body.String())
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.NotEmpty(t, rec.Header().Get("X-Stash-Version"))
+
+		require.Len(t, st.TxnCalls(), 1)
+		assert.Equal(t, []store.TxnOp{{Key: "app/cfg", Value: []byte(`{"db":{"host":"h","port":6432}}`), Format: "json",
+			Version: version}}, st.TxnCalls()[0].Ops)
+		require.Len(t, gitSvc.CommitCalls(), 1)
+		assert.Equal(t, "update", gitSvc.CommitCalls()[0].Req.Operation)
+		assert.Equal(t, `{"db":{"host":"h","port":6432}}`, string(gitSvc.CommitCalls()[0].Req.Value))
+		require.Len(t, events.PublishCalls(), 1)
+		assert.Equal(t, enum.AuditActionUpdate, events.PublishCalls()[0].Action)
+	})
+
+	t.Run("json patch", func(t *testing.T) {
+		st := patchStore(`{"hosts":["a","b"]}`, "json", 0)
+		rec := patch(newTestHandler(t, st, noAuth), "app/cfg", "application/json-patch+json; charset=utf-8",
+			`[{"op":"test","path":"/hosts/0","value":"a"},{"op":"add","path":"/hosts/-","value":"c"}]`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{"hosts":["a","b","c"]}`, rec.Body.String())
+	})
+
+	t.Run("concurrent change retried", func(t *testing.T) {
+		st := patchStore(`{"n":1}`, "json", 2)
+		rec := patch(newTestHandler(t, st, noAuth), "app/cfg", mergePatchType, `{"m":2}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Len(t, st.TxnCalls(), 3)
+		assert.Len(t, st.GetInfoCalls(), 3, "version read again on each attempt")
+
+		st = patchStore(`{"n":1}`, "json", patchAttempts)
+		rec = patch(newTestHandler(t, st, noAuth), "app/cfg", mergePatchType, `{"m":2}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "changed concurrently")
+		assert.Len(t, st.TxnCalls(), patchAttempts)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name, key, format, contentType, body string
+			status                               int
+			msg                                  string
+		}{
+			{name: "no content type", key: "app/cfg", format: "json", body: `{}`, status: http.StatusUnsupportedMediaType,
+				msg: "content type must be"},
+			{name: "plain json content type", key: "app/cfg", format: "json", contentType: "application/json", body: `{}`,
+				status: http.StatusUnsupportedMediaType, msg: "content type must be"},
+			{name: "not found", key: "app/missing", format: "json", contentType: mergePatchType, body: `{}`,
+				status: http.StatusNotFound, msg: "key not found"},
+			{name: "not json format", key: "app/cfg", format: "yaml", contentType: mergePatchType, body: `{}`,
+				status: http.StatusBadRequest, msg: "key has yaml format"},
+			{name: "invalid patch", key: "app/cfg", format: "json", contentType: jsonPatchType, body: `{"op":"add"}`,
+				status: http.StatusBadRequest, msg: "invalid patch"},
+			{name: "test fails", key: "app/cfg", format: "json", contentType: jsonPatchType,
+				body: `[{"op":"test","path":"/n","value":2}]`, status: http.StatusConflict, msg: "test failed"},
+			{name: "path missing", key: "app/cfg", format: "json", contentType: jsonPatchType,
+				body: `[{"op":"remove","path":"/x"}]`, status: http.StatusUnprocessableEntity, msg: "member not found"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				st := patchStore(`{"n":1}`, tc.format, 0)
+				rec := patch(newTestHandler(t, st, noAuth), tc.key, tc.contentType, tc.body)
+				assert.Equal(t, tc.status, rec.Code)
+				assert.Contains(t, rec.Body.String(), tc.msg)
+				assert.Empty(t, st.TxnCalls())
+			})
+		}
+	})
+
+	t.Run("zk encrypted", func(t *testing.T) {
+		st := &mocks.KVStoreMock{GetInfoFunc: func(context.Context, string) (store.KeyInfo, error) {
+			return store.KeyInfo{Key: "app/cfg", Format: "json", ZKEncrypted: true}, nil
+		}}
+		rec := patch(newTestHandler(t, st, noAuth), "app/cfg", mergePatchType, `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "ZK-encrypted")
+	})
+
+	t.Run("sealed secrets", func(t *testing.T) {
+		st := &mocks.KVStoreMock{GetInfoFunc: func(context.Context, string) (store.KeyInfo, error) {
+			return store.KeyInfo{Key: "secrets/cfg", Format: "json"}, nil
+		}, GetFunc: func(context.Context, string) ([]byte, error) { return nil, store.ErrSealed }}
+		rec := patch(newTestHandler(t, st, noAuth), "secrets/cfg", mergePatchType, `{}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/environ"
+	"github.com/umputun/stash/app/store"
+)
+
+// ndjsonContentType is the media type of key lists streamed one key per line.
+const ndjsonContentType = "application/x-ndjson"
+
+// streamPageSize is the number of keys read from the store at a time while streaming a key list.
+const streamPageSize = 500
+
+// wantsNDJSON reports whether the request accepts a key list streamed as NDJSON.
+func wantsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// streamError is the last line of a list stream that failed after the first key was sent, as the status
+// code can't change anymore.
+type streamError struct {
+	Error string `json:"error"`
+}
+
+// streamList writes the keys of the query as NDJSON, one KeyInfo per line. Keys are read from the store
+// a page at a time and each page is flushed before the next one is read, so a slow consumer holds back
+// the reads instead of the server buffering the whole list. Pages are offset-based, keys changed while
+// streaming may be skipped or sent twice.
+func (h *Handler) streamList(w http.ResponseWriter, r *http.Request, q store.ListQuery) {
+	env := environ.FromContext(r.Context())
+	list := func() ([]store.KeyInfo, error) {
+		if env != "" {
+			return h.listEnv(r.Context(), q, env) // merged with base environments and sorted as a whole
+		}
+		keys, _, err := h.Store.ListPage(r.Context(), q)
+		return keys, err
+	}
+	if env == "" {
+		q.Limit = streamPageSize
+	}
+	page, err := list()
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to list keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	enc := json.NewEncoder(w) // each encoded value ends with a newline
+	rc := http.NewResponseController(w)
+	count := 0
+	for {
+		for _, k := range page {
+			if h.Envs != nil && env == "" && environ.IsEnvKey(k.Key) {
+				continue
+			}
+			if err := enc.Encode(k); err != nil {
+				log.Printf("[DEBUG] list stream closed after %d keys: %v", count, err)
+				return
+			}
+			count++
+		}
+		_ = rc.Flush() // the response is complete either way, flushing only sends the page early
+		if env != "" || len(page) < streamPageSize {
+			break
+		}
+		q.Offset += streamPageSize
+		if page, err = list(); err != nil {
+			log.Printf("[WARN] failed to list keys after %d streamed: %v", count, err)
+			_ = enc.Encode(streamError{Error: "failed to list keys"})
+			return
+		}
+	}
+	log.Printf("[DEBUG] list keys: %d streamed", count)
+}
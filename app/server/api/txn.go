@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/environ"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/store"
+)
+
+// maxTxnOps is the max number of operations in a transaction
+const maxTxnOps = 100
+
+// transaction operations, see handleTxn
+const (
+	txnSet    = "set"
+	txnDelete = "delete"
+)
+
+// txnRequest is the body of a transaction request.
+type txnRequest struct {
+	Ops []txnOp `json:"ops"`
+}
+
+// txnOp is a set or delete of a key with optional preconditions, version and absent are exclusive.
+type txnOp struct {
+	Op       string    `json:"op"`
+	Key      string    `json:"key"`
+	Value    string    `json:"value,omitempty"`
+	Encoding string    `json:"encoding,omitempty"` // "base64" for binary values, empty for UTF-8 text
+	Format   string    `json:"format,omitempty"`
+	Version  time.Time `json:"version,omitzero"` // the key exists and its updated_at is this time
+	Absent   bool      `json:"absent,omitempty"` // the key doesn't exist
+}
+
+// txnResult is the outcome of one operation of a committed transaction.
+type txnResult struct {
+	Op      string    `json:"op"`
+	Key     string    `json:"key"`
+	Created bool      `json:"created,omitempty"`
+	Version time.Time `json:"version,omitzero"` // new updated_at of the key set
+}
+
+// txnResponse lists the results in the order of operations.
+type txnResponse struct {
+	Results []txnResult `json:"results"`
+}
+
+// handleTxn sets and deletes keys atomically, either all operations are applied or none.
+// Each operation can have a precondition: version, the updated_at the key must have, or absent for a key
+// which must not exist yet. If a precondition fails, or a key to delete doesn't exist, nothing is changed
+// and 409 is returned with the key in the message. Permissions are checked for each key.
+// POST /kv/_txn
+func (h *Handler) handleTxn(w http.ResponseWriter, r *http.Request) {
+	if environ.FromContext(r.Context()) != "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "env parameter is not supported by transactions")
+		return
+	}
+	var req txnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid transaction")
+		return
+	}
+	switch {
+	case len(req.Ops) == 0:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "transaction has no operations")
+		return
+	case len(req.Ops) > maxTxnOps:
+		msg := fmt.Sprintf("transaction has %d operations, max %d", len(req.Ops), maxTxnOps)
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, msg)
+		return
+	}
+
+	ops := make([]store.TxnOp, len(req.Ops))
+	seen := make(map[string]bool, len(req.Ops))
+	for i, op := range req.Ops {
+		txo, status, err := h.txnOp(r, op)
+		if err == nil && seen[txo.Key] {
+			status, err = http.StatusBadRequest, fmt.Errorf("key %q is changed more than once", txo.Key)
+		}
+		if err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), status, err, err.Error())
+			return
+		}
+		seen[txo.Key] = true
+		ops[i] = txo
+	}
+
+	res, err := h.Store.Txn(r.Context(), ops)
+	if err != nil {
+		status, msg := http.StatusInternalServerError, "failed to apply transaction"
+		var conflict *store.TxnConflictError
+		switch {
+		case errors.As(err, &conflict):
+			status, msg = http.StatusConflict, conflict.Error()
+		case errors.Is(err, store.ErrSecretsNotConfigured):
+			status, msg = http.StatusBadRequest, "secrets not configured"
+		case errors.Is(err, store.ErrSealed):
+			status, msg = http.StatusServiceUnavailable, "secrets sealed"
+		case errors.Is(err, store.ErrInvalidZKPayload):
+			status, msg = http.StatusBadRequest, "invalid ZK payload"
+		case errors.Is(err, store.ErrFrozen):
+			status, msg = http.StatusLocked, frozenMessage(err)
+		}
+		rest.SendErrorJSON(w, r, log.Default(), status, err, msg)
+		return
+	}
+
+	resp := txnResponse{Results: make([]txnResult, len(res))}
+	for i, rs := range res {
+		resp.Results[i] = txnResult{Op: req.Ops[i].Op, Key: rs.Key, Created: rs.Created, Version: rs.Version}
+		h.txnApplied(r, ops[i], rs.Created)
+	}
+	log.Printf("[INFO] transaction of %d operations by %s", len(ops), h.getIdentityForLog(r))
+	h.setVersion(w)
+	rest.RenderJSON(w, resp)
+}
+
+// txnOp checks the operation of a transaction and the caller's permission to change the key.
+// Returns the store operation, or the response status and error.
+func (h *Handler) txnOp(r *http.Request, op txnOp) (store.TxnOp, int, error) {
+	key := store.NormalizeKey(op.Key)
+	if key == "" {
+		return store.TxnOp{}, http.StatusBadRequest, errors.New("key is required")
+	}
+	if !op.Version.IsZero() && op.Absent {
+		return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("version and absent of key %q are exclusive", key)
+	}
+	res := store.TxnOp{Key: key, Version: op.Version, Absent: op.Absent, Owner: ownership.Owner(h.getIdentityForLog(r))}
+	scope := enum.ScopeWrite
+	switch op.Op {
+	case txnSet:
+		switch op.Encoding {
+		case "":
+			res.Value = []byte(op.Value)
+		case "base64":
+			value, err := base64.StdEncoding.DecodeString(op.Value)
+			if err != nil {
+				return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("invalid base64 value of key %q", key)
+			}
+			res.Value = value
+		default:
+			return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("unknown encoding %q of key %q", op.Encoding, key)
+		}
+		res.Format = op.Format
+		if res.Format == "" {
+			res.Format = "text"
+		}
+		if !h.validFormat(res.Format) {
+			return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("invalid format %q of key %q", op.Format, key)
+		}
+	case txnDelete:
+		if op.Absent {
+			return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("delete of key %q can't require it absent", key)
+		}
+		res.Delete, scope = true, enum.ScopeDelete
+	default:
+		return store.TxnOp{}, http.StatusBadRequest, fmt.Errorf("invalid op %q of key %q, must be set or delete", op.Op, key)
+	}
+
+	// a denied change is audited as an update, whether the key exists is not checked
+	denied := keyaudit.Record{Key: key, Action: enum.AuditActionUpdate, Result: enum.AuditResultDenied}
+	if res.Delete {
+		denied.Action = enum.AuditActionDelete
+	}
+	if h.Auth != nil && h.Auth.Enabled() && !h.Auth.RequestCanChange(r, key, scope) {
+		log.Printf("[INFO] %s denied %s of key %q in transaction", h.getIdentityForLog(r), op.Op, key)
+		keyaudit.Add(r.Context(), denied)
+		return store.TxnOp{}, http.StatusForbidden, fmt.Errorf("no permission to %s key %q", op.Op, key)
+	}
+	if res.Delete && h.Owners != nil {
+		allowed, err := h.Owners.CanDelete(r.Context(), key, h.getIdentityForLog(r), h.Auth != nil && h.Auth.IsRequestAdmin(r))
+		if err != nil {
+			return store.TxnOp{}, http.StatusInternalServerError, fmt.Errorf("failed to check owner of key %q: %w", key, err)
+		}
+		if !allowed {
+			keyaudit.Add(r.Context(), denied)
+			return store.TxnOp{}, http.StatusForbidden, fmt.Errorf("only the owner or an admin can delete key %q", key)
+		}
+	}
+	return res, http.StatusOK, nil
+}
+
+// txnApplied records a committed operation of a transaction or an import as the single-key handlers do,
+// audit entry, git commit and event.
+func (h *Handler) txnApplied(r *http.Request, op store.TxnOp, created bool) {
+	operation, action := "update", enum.AuditActionUpdate
+	switch {
+	case op.Delete:
+		operation, action = "delete", enum.AuditActionDelete
+	case created:
+		operation, action = "create", enum.AuditActionCreate
+	}
+	log.Printf("[DEBUG] transaction %s %q by %s", operation, op.Key, h.getIdentityForLog(r))
+	rec := keyaudit.Record{Key: op.Key, Action: action}
+	if !op.Delete {
+		size := len(op.Value)
+		rec.Size = &size
+	}
+	keyaudit.Add(r.Context(), rec)
+
+	if h.Git != nil {
+		var err error
+		if op.Delete {
+			err = h.Git.Delete(op.Key, h.getAuthorFromRequest(r))
+		} else {
+			err = h.Git.Commit(git.CommitRequest{Key: op.Key, Value: op.Value, Operation: operation, Format: op.Format,
+				Author: h.getAuthorFromRequest(r)})
+		}
+		if err != nil {
+			log.Printf("[WARN] git %s failed for %s: %v", operation, op.Key, err)
+		}
+	}
+	if h.Events != nil {
+		h.Events.Publish(op.Key, action)
+	}
+}
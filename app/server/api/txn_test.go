@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/api/mocks"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandleTxn(t *testing.T) {
+	noAuth := &mocks.AuthProviderMock{
+		EnabledFunc:         func() bool { return false },
+		IsRequestAdminFunc:  func(*http.Request) bool { return false },
+		GetRequestActorFunc: func(*http.Request) (string, string) { return "", "" },
+	}
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	// txnStore applies operations to the map, or fails with the error if set
+	txnStore := func(values map[string]string, fail error) *mocks.KVStoreMock {
+		return &mocks.KVStoreMock{TxnFunc: func(_ context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+			if fail != nil {
+				return nil, fail
+			}
+			res := make([]store.TxnResult, len(ops))
+			for i, op := range ops {
+				res[i].Key = op.Key
+				if op.Delete {
+					delete(values, op.Key)
+					continue
+				}
+				_, exists := values[op.Key]
+				values[op.Key] = string(op.Value) + "\t" + op.Format
+				res[i].Created, res[i].Version = !exists, now
+			}
+			return res, nil
+		}}
+	}
+	// audited runs the transaction and returns the response with the keys reported to audit
+	audited := func(h *Handler, body string) (*httptest.ResponseRecorder, []keyaudit.Record) {
+		ctx, records := keyaudit.WithRecorder(t.Context())
+		req := httptest.NewRequest(http.MethodPost, "/kv/_txn", strings.NewReader(body)).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		h.handleTxn(rec, req)
+		return rec, records()
+	}
+	txn := func(h *Handler, body string) *httptest.ResponseRecorder {
+		rec, _ := audited(h, body)
+		return rec
+	}
+
+	t.Run("applies operations", func(t *testing.T) {
+		values := map[string]string{"app/db": "old\ttext", "app/old": "x\ttext"}
+		st := txnStore(values, nil)
+		gitSvc := &mocks.GitServiceMock{
+			CommitFunc: func(git.CommitRequest) error { return nil },
+			DeleteFunc: func(string, git.Author) error { return nil },
+		}
+		events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+		owners := &mocks.OwnerPolicyMock{
+			CanDeleteFunc: func(context.Context, string, string, bool) (bool, error) { return true, nil },
+		}
+		h := New(Deps{Store: st, Auth: noAuth, Validator: defaultFormatValidator(), Git: gitSvc, Events: events, Owners: owners})
+
+		rec, records := audited(h, `{"ops":[
+			{"op":"set","key":"app/db","value":"{\"port\":6432}","format":"json","version":"2025-05-01T10:00:00.123Z"},
+			{"op":"set","key":"/app/bin/","value":"/wE=","encoding":"base64","absent":true},
+			{"op":"delete","key":"app/old"}]}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotEmpty(t, rec.Header().Get("X-Stash-Version"))
+		assert.JSONEq(t, `{"results":[
+			{"op":"set","key":"app/db","version":"2025-06-01T10:00:00Z"},
+			{"op":"set","key":"app/bin","created":true,"version":"2025-06-01T10:00:00Z"},
+			{"op":"delete","key":"app/old"}]}`, rec.Body.String())
+
+		require.Len(t, st.TxnCalls(), 1)
+		ops := st.TxnCalls()[0].Ops
+		assert.Equal(t, time.Date(2025, 5, 1, 10, 0, 0, 123e6, time.UTC), ops[0].Version)
+		assert.Equal(t, store.TxnOp{Key: "app/bin", Value: []byte{0xff, 0x01}, Format: "text", Absent: true}, ops[1])
+		assert.Equal(t, store.TxnOp{Key: "app/old", Delete: true}, ops[2])
+		assert.Equal(t, `{"port":6432}`+"\tjson", values["app/db"])
+		assert.NotContains(t, values, "app/old")
+
+		require.Len(t, gitSvc.CommitCalls(), 2)
+		assert.Equal(t, "update", gitSvc.CommitCalls()[0].Req.Operation)
+		assert.Equal(t, "create", gitSvc.CommitCalls()[1].Req.Operation)
+		require.Len(t, gitSvc.DeleteCalls(), 1)
+		assert.Equal(t, "app/old", gitSvc.DeleteCalls()[0].Key)
+		require.Len(t, events.PublishCalls(), 3)
+		assert.Equal(t, enum.AuditActionCreate, events.PublishCalls()[1].Action)
+		assert.Equal(t, enum.AuditActionDelete, events.PublishCalls()[2].Action)
+		require.Len(t, owners.CanDeleteCalls(), 1)
+
+		size := func(n int) *int { return &n }
+		assert.Equal(t, []keyaudit.Record{
+			{Key: "app/db", Action: enum.AuditActionUpdate, Result: enum.AuditResultSuccess, Size: size(13)},
+			{Key: "app/bin", Action: enum.AuditActionCreate, Result: enum.AuditResultSuccess, Size: size(2)},
+			{Key: "app/old", Action: enum.AuditActionDelete, Result: enum.AuditResultSuccess},
+		}, records)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		tests := []struct {
+			err    error
+			status int
+			msg    string
+		}{
+			{err: &store.TxnConflictError{Key: "app/db", Version: now}, status: http.StatusConflict, msg: `key \"app/db\" was updated`},
+			{err: &store.TxnConflictError{Key: "app/old"}, status: http.StatusConflict, msg: `key \"app/old\" doesn't exist`},
+			{err: store.ErrSecretsNotConfigured, status: http.StatusBadRequest, msg: "secrets not configured"},
+			{err: fmt.Errorf("encrypt: %w", store.ErrSealed), status: http.StatusServiceUnavailable, msg: "secrets sealed"},
+			{err: store.ErrInvalidZKPayload, status: http.StatusBadRequest, msg: "invalid ZK payload"},
+			{err: assert.AnError, status: http.StatusInternalServerError, msg: "failed to apply transaction"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.msg, func(t *testing.T) {
+				events := &mocks.EventPublisherMock{PublishFunc: func(string, enum.AuditAction) {}}
+				h := New(Deps{Store: txnStore(map[string]string{}, tc.err), Auth: noAuth, Validator: defaultFormatValidator(),
+					Events: events})
+				rec := txn(h, `{"ops":[{"op":"set","key":"app/db","value":"v"},{"op":"delete","key":"app/old"}]}`)
+				assert.Equal(t, tc.status, rec.Code)
+				assert.Contains(t, rec.Body.String(), tc.msg)
+				assert.Empty(t, events.PublishCalls())
+			})
+		}
+	})
+
+	t.Run("invalid transactions", func(t *testing.T) {
+		tooMany := make([]string, maxTxnOps+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf(`{"op":"set","key":"k%d"}`, i)
+		}
+		tests := []struct {
+			name, body, msg string
+		}{
+			{name: "not json", body: "{", msg: "invalid transaction"},
+			{name: "no operations", body: `{"ops":[]}`, msg: "no operations"},
+			{name: "too many operations", body: `{"ops":[` + strings.Join(tooMany, ",") + `]}`, msg: "max 100"},
+			{name: "no key", body: `{"ops":[{"op":"set","key":"/"}]}`, msg: "key is required"},
+			{name: "unknown op", body: `{"ops":[{"op":"get","key":"a"}]}`, msg: "must be set or delete"},
+			{name: "bad encoding", body: `{"ops":[{"op":"set","key":"a","value":"x","encoding":"hex"}]}`, msg: "unknown encoding"},
+			{name: "malformed format", body: `{"ops":[{"op":"set","key":"a","value":"x","format":"Not A Format"}]}`,
+				msg: "invalid format"},
+			{name: "bad base64", body: `{"ops":[{"op":"set","key":"a","value":"!","encoding":"base64"}]}`, msg: "invalid base64"},
+			{name: "version and absent", body: `{"ops":[{"op":"set","key":"a","version":"2025-01-01T00:00:00Z","absent":true}]}`,
+				msg: "exclusive"},
+			{name: "delete absent", body: `{"ops":[{"op":"delete","key":"a","absent":true}]}`, msg: "can't require it absent"},
+			{name: "repeated key", body: `{"ops":[{"op":"set","key":"a"},{"op":"delete","key":"/a"}]}`, msg: "more than once"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				st := txnStore(map[string]string{}, nil)
+				rec := txn(newTestHandler(t, st, noAuth), tc.body)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), tc.msg)
+				assert.Empty(t, st.TxnCalls())
+			})
+		}
+	})
+
+	t.Run("permission of each key", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:         func() bool { return true },
+			IsRequestAdminFunc:  func(*http.Request) bool { return false },
+			GetRequestActorFunc: func(*http.Request) (string, string) { return "token", "token:dev" },
+			RequestCanChangeFunc: func(_ *http.Request, key string, scope enum.Scope) bool {
+				return strings.HasPrefix(key, "app/") && scope == enum.ScopeWrite
+			},
+		}
+		st := txnStore(map[string]string{}, nil)
+		h := newTestHandler(t, st, auth)
+
+		rec, records := audited(h, `{"ops":[{"op":"set","key":"app/a","value":"1"},{"op":"set","key":"other/b","value":"2"}]}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), `no permission to set key \"other/b\"`)
+		assert.Equal(t, []keyaudit.Record{{Key: "other/b", Action: enum.AuditActionUpdate, Result: enum.AuditResultDenied}}, records)
+
+		rec, records = audited(h, `{"ops":[{"op":"set","key":"app/a","value":"1"},{"op":"delete","key":"app/b"}]}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "delete needs the delete scope")
+		assert.Equal(t, []keyaudit.Record{{Key: "app/b", Action: enum.AuditActionDelete, Result: enum.AuditResultDenied}}, records)
+		assert.Empty(t, st.TxnCalls())
+
+		rec = txn(h, `{"ops":[{"op":"set","key":"app/a","value":"1"}]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, st.TxnCalls(), 1)
+		assert.Equal(t, "token:dev", st.TxnCalls()[0].Ops[0].Owner, "caller is the owner of created keys")
+	})
+
+	t.Run("owner-only delete", func(t *testing.T) {
+		st := txnStore(map[string]string{}, nil)
+		owners := &mocks.OwnerPolicyMock{
+			CanDeleteFunc: func(_ context.Context, key, _ string, _ bool) (bool, error) { return key != "app/owned", nil },
+		}
+		h := New(Deps{Store: st, Auth: noAuth, Validator: defaultFormatValidator(), Owners: owners})
+		rec, records := audited(h, `{"ops":[{"op":"delete","key":"app/free"},{"op":"delete","key":"app/owned"}]}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), `only the owner or an admin can delete key \"app/owned\"`)
+		assert.Equal(t, []keyaudit.Record{{Key: "app/owned", Action: enum.AuditActionDelete, Result: enum.AuditResultDenied}}, records)
+		assert.Empty(t, st.TxnCalls())
+	})
+}
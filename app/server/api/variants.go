@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/internal/variant"
+	"github.com/umputun/stash/app/store"
+)
+
+// query parameters selecting the variants spec of the key, or one of its overrides, instead of its value
+const (
+	variantsParam = "variants"
+	overrideParam = "override"
+)
+
+// VariantStore defines the interface for variants specs of keys.
+type VariantStore interface {
+	GetVariants(ctx context.Context, key string) (string, error)
+	SetVariants(ctx context.Context, key, spec string) error
+}
+
+// pickVariant returns the variant of the stored key the request is targeted by. Responses for keys with
+// variants depend on the targeting headers, so Vary is set for caches even if no variant is picked.
+func (h *Handler) pickVariant(w http.ResponseWriter, r *http.Request, key string) (variant.Variant, bool) {
+	spec, err := h.Variants.GetVariants(r.Context(), key)
+	if err != nil || spec == "" {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("[WARN] failed to get variants of %s: %v", key, err)
+		}
+		return variant.Variant{}, false
+	}
+	parsed, err := variant.Parse([]byte(spec))
+	if err != nil {
+		log.Printf("[WARN] invalid variants of %s: %v", key, err)
+		return variant.Variant{}, false
+	}
+	w.Header().Add("Vary", variant.SubjectHeader+", "+variant.AttributesHeader)
+	identity := ""
+	if id := h.getIdentity(r); id.typ != identityAnonymous {
+		identity = h.getIdentityForLog(r)
+	}
+	return parsed.Pick(key, variant.FromRequest(r, identity))
+}
+
+// handleVariants serves requests for the variants spec of the key, selected with ?variants.
+func (h *Handler) handleVariants(w http.ResponseWriter, r *http.Request, key string) {
+	if h.Variants == nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "variants are not enabled")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		h.handleSetVariants(w, r, key)
+	case http.MethodDelete:
+		h.handleDeleteVariants(w, r, key)
+	default:
+		h.handleGetVariants(w, r, key)
+	}
+}
+
+// handleOverride sets or removes the value of the key served to a single caller or instance, the
+// target of the override param. The override is kept in the variants spec of the key.
+// PUT /kv/{key...}?override=instance:web-3
+// DELETE /kv/{key...}?override=caller:token:ab12****
+func (h *Handler) handleOverride(w http.ResponseWriter, r *http.Request, key string) {
+	if h.Variants == nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "variants are not enabled")
+		return
+	}
+	if store.IsSecret(key) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "secrets can't have overrides")
+		return
+	}
+	var value []byte
+	format := ""
+	if r.Method == http.MethodPut {
+		var err error
+		if value, err = io.ReadAll(r.Body); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "failed to read body")
+			return
+		}
+		if format = r.Header.Get("X-Stash-Format"); format == "" {
+			format = r.URL.Query().Get("format")
+		}
+		if format != "" && !h.Validator.IsValidFormat(format) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid format "+format)
+			return
+		}
+	}
+
+	// the spec is read, changed and written back, serialized so concurrent overrides don't drop each other
+	h.overridesMu.Lock()
+	defer h.overridesMu.Unlock()
+	current, err := h.Variants.GetVariants(r.Context(), key)
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get variants")
+		return
+	}
+	var spec variant.Spec
+	if current != "" {
+		if spec, err = variant.Parse([]byte(current)); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "invalid stored variants")
+			return
+		}
+	}
+
+	target := r.URL.Query().Get(overrideParam)
+	if r.Method == http.MethodDelete {
+		if !spec.RemoveOverride(target) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, nil, "no override for "+target)
+			return
+		}
+	} else if err := spec.SetOverride(target, string(value), format); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid override")
+		return
+	}
+
+	updated := ""
+	if len(spec.Variants) > 0 {
+		data, err := json.Marshal(spec)
+		if err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to encode variants")
+			return
+		}
+		updated = string(data)
+	}
+	if err := h.Variants.SetVariants(r.Context(), key, updated); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to set variants")
+		return
+	}
+	log.Printf("[INFO] %s override %s of %q by %s", strings.ToLower(r.Method), target, key, h.getIdentityForLog(r))
+	if h.Events != nil {
+		h.Events.Publish(key, enum.AuditActionUpdate)
+	}
+	h.setVersion(w)
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGetVariants returns the variants spec of a key.
+// GET /kv/{key...}?variants
+func (h *Handler) handleGetVariants(w http.ResponseWriter, r *http.Request, key string) {
+	spec, err := h.Variants.GetVariants(r.Context(), key)
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get variants")
+		return
+	}
+	if spec == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, nil, "key has no variants")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write([]byte(spec)); err != nil {
+		log.Printf("[WARN] failed to write response: %v", err)
+	}
+}
+
+// handleSetVariants sets the variants spec of an existing key. Secrets can't have variants, as variant
+// values are stored in the spec unencrypted.
+// PUT /kv/{key...}?variants
+func (h *Handler) handleSetVariants(w http.ResponseWriter, r *http.Request, key string) {
+	if store.IsSecret(key) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "secrets can't have variants")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "failed to read body")
+		return
+	}
+	spec, err := variant.Parse(body)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid variants")
+		return
+	}
+	for _, v := range spec.Variants {
+		if v.Format != "" && !h.Validator.IsValidFormat(v.Format) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "invalid format of variant "+v.Name)
+			return
+		}
+	}
+	normalized, err := json.Marshal(spec)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to encode variants")
+		return
+	}
+	h.updateVariants(w, r, key, string(normalized))
+}
+
+// handleDeleteVariants removes the variants of a key, all callers get the stored value again.
+// DELETE /kv/{key...}?variants
+func (h *Handler) handleDeleteVariants(w http.ResponseWriter, r *http.Request, key string) {
+	h.updateVariants(w, r, key, "")
+}
+
+// updateVariants stores the spec and notifies subscribers, as values read by callers change with it.
+func (h *Handler) updateVariants(w http.ResponseWriter, r *http.Request, key, spec string) {
+	err := h.Variants.SetVariants(r.Context(), key, spec)
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "key not found")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to set variants")
+		return
+	}
+	log.Printf("[INFO] set variants of %q (%d bytes) by %s", key, len(spec), h.getIdentityForLog(r))
+	if h.Events != nil {
+		h.Events.Publish(key, enum.AuditActionUpdate)
+	}
+	h.setVersion(w)
+	if spec == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/umputun/stash/app/git"
+)
+
+// pinHistoryLimit is the number of revisions searched for the newest one compatible with a client version.
+const pinHistoryLimit = 50
+
+// errNoCompatibleRevision is returned when no revision of a key is compatible with the client version.
+var errNoCompatibleRevision = errors.New("no revision compatible with client version")
+
+// version is a dotted numeric version like 1.2.3 with an optional "v" prefix. Versions are compared
+// component by component, missing components count as zero, so 1.2 equals 1.2.0.
+type version []int
+
+// parseVersion parses a dotted numeric version.
+func parseVersion(s string) (version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, errors.New("empty version")
+	}
+	parts := strings.Split(s, ".")
+	res := make(version, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q, expected dotted numbers like 1.2.3", s)
+		}
+		res[i] = n
+	}
+	return res, nil
+}
+
+// less reports whether v is lower than other.
+func (v version) less(other version) bool {
+	for i := range max(len(v), len(other)) {
+		a, b := 0, 0
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	return false
+}
+
+// accepts reports whether a client of version v can read a revision with the minimum version. A revision
+// without one is readable by any client, an unparsable one by none.
+func (v version) accepts(minVersion string) bool {
+	if minVersion == "" {
+		return true
+	}
+	m, err := parseVersion(minVersion)
+	return err == nil && !v.less(m)
+}
+
+// pinnedRevision returns the newest revision of the key the client version accepts, or nil if it accepts
+// the current value. Revisions before the last delete belong to a previous key and are not searched.
+// Keys set before git was enabled have no history, their current value is accepted by all clients.
+func (h *Handler) pinnedRevision(key string, client version) (*git.HistoryEntry, error) {
+	history, err := h.Git.History(key, pinHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+	for i, entry := range history {
+		if entry.Operation == "delete" {
+			break
+		}
+		if !client.accepts(entry.MinVersion) {
+			continue
+		}
+		if i == 0 {
+			return nil, nil //nolint:nilnil // current value is compatible
+		}
+		return &entry, nil
+	}
+	if len(history) == 0 {
+		return nil, nil //nolint:nilnil // no history, nothing to pin to
+	}
+	return nil, errNoCompatibleRevision
+}
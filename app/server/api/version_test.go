@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	tbl := []struct {
+		client, minVersion string
+		accepts            bool
+	}{
+		{"1.0", "", true},
+		{"1.0", "1.0", true},
+		{"1.2", "1.2.0", true},
+		{"1.10", "1.9", true},
+		{"v2", "1.9.9", true},
+		{"1.9.9", "2", false},
+		{"1.2", "1.2.1", false},
+		{"3", "bad", false},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.client+" "+tt.minVersion, func(t *testing.T) {
+			v, err := parseVersion(tt.client)
+			require.NoError(t, err)
+			assert.Equal(t, tt.accepts, v.accepts(tt.minVersion))
+		})
+	}
+
+	for _, bad := range []string{"", "v", "1.", "1.a", "-1", "1.2-beta"} {
+		_, err := parseVersion(bad)
+		assert.Error(t, err, bad)
+	}
+}
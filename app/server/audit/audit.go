@@ -14,11 +14,21 @@ import (
 
 //go:generate moq -out mocks/store.go -pkg mocks -skip-ensure -fmt goimports . Store
 //go:generate moq -out mocks/auth.go -pkg mocks -skip-ensure -fmt goimports . Auth
+//go:generate moq -out mocks/observer.go -pkg mocks -skip-ensure -fmt goimports . Observer
+//go:generate moq -out mocks/canary.go -pkg mocks -skip-ensure -fmt goimports . CanaryMatcher
 
 // Store defines the interface for audit log storage.
 type Store interface {
 	LogAudit(ctx context.Context, entry store.AuditEntry) error
 	QueryAudit(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, int, error)
+	CountAudit(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error)
+	DeleteAudit(ctx context.Context, q store.AuditQuery) (int64, error)
+}
+
+// Observer receives every audit entry after it is built, e.g. for alerting.
+// admin reports whether the request was made with admin credentials.
+type Observer interface {
+	Observe(entry store.AuditEntry, admin bool)
 }
 
 // Auth defines the interface for auth operations needed by audit.
@@ -79,10 +89,32 @@ func (rc *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return nil, nil, errors.New("ResponseWriter does not implement http.Hijacker")
 }
 
+// CanaryMatcher reports whether a key is a canary; reads of canaries are logged with the canary action.
+type CanaryMatcher interface {
+	IsCanary(key string) bool
+}
+
+// Option configures the audit middleware.
+type Option func(*logger)
+
+// WithObserver passes every audit entry to the observer.
+func WithObserver(o Observer) Option {
+	return func(l *logger) { l.observers = append(l.observers, o) }
+}
+
+// WithCanaries logs reads of keys matched by c as enum.AuditActionCanary instead of a plain read.
+func WithCanaries(c CanaryMatcher) Option {
+	return func(l *logger) { l.canaries = c }
+}
+
 // Middleware creates middleware that logs audit entries after handler completes.
 // This is a convenience function that creates a logger and returns its middleware.
-func Middleware(auditStore Store, authProvider Auth) func(http.Handler) http.Handler {
+// auditStore may be nil to only pass entries to observers without persisting them.
+func Middleware(auditStore Store, authProvider Auth, opts ...Option) func(http.Handler) http.Handler {
 	l := newLogger(auditStore, authProvider)
+	for _, opt := range opts {
+		opt(l)
+	}
 	return l.middleware
 }
 
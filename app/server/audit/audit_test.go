@@ -11,6 +11,7 @@ import (
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/server/audit/mocks"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
 	"github.com/umputun/stash/app/store"
 )
 
@@ -123,12 +124,14 @@ func TestMiddleware(t *testing.T) {
 
 		req := httptest.NewRequest(http.MethodGet, "/kv/app/config", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "test-session"})
+		req.Header.Set(ReasonHeader, "INC-42 config check")
 		rec := httptest.NewRecorder()
 
 		handler.ServeHTTP(rec, req)
 
 		require.Len(t, auditStore.LogAuditCalls(), 1)
 		assert.Equal(t, "app/config", capturedEntry.Key)
+		assert.Equal(t, "INC-42 config check", capturedEntry.Reason)
 		assert.Equal(t, enum.AuditActionRead, capturedEntry.Action)
 		assert.Equal(t, enum.AuditResultSuccess, capturedEntry.Result)
 		assert.Equal(t, "testuser", capturedEntry.Actor)
@@ -406,4 +409,116 @@ func TestMiddleware(t *testing.T) {
 		assert.Equal(t, "req-12345", capturedEntry.RequestID)
 		assert.False(t, capturedEntry.Timestamp.IsZero())
 	})
+
+	t.Run("passes entries to observers", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			LogAuditFunc: func(context.Context, store.AuditEntry) error { return nil },
+		}
+		auth := &mocks.AuthMock{
+			GetRequestActorFunc: func(_ *http.Request) (string, string) { return "token", "token:admi****" },
+			IsRequestAdminFunc:  func(_ *http.Request) bool { return true },
+		}
+		observer := &mocks.ObserverMock{ObserveFunc: func(store.AuditEntry, bool) {}}
+		handler := Middleware(auditStore, auth, WithObserver(observer))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/kv/secrets/db", http.NoBody))
+
+		require.Len(t, auditStore.LogAuditCalls(), 1)
+		require.Len(t, observer.ObserveCalls(), 1)
+		assert.Equal(t, auditStore.LogAuditCalls()[0].Entry, observer.ObserveCalls()[0].Entry)
+		assert.Equal(t, enum.AuditResultDenied, observer.ObserveCalls()[0].Entry.Result)
+		assert.True(t, observer.ObserveCalls()[0].Admin)
+	})
+
+	t.Run("marks canary reads", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			LogAuditFunc: func(context.Context, store.AuditEntry) error { return nil },
+		}
+		canaries := &mocks.CanaryMatcherMock{IsCanaryFunc: func(key string) bool { return key == "honeypot/db" }}
+		handler := Middleware(auditStore, nil, WithCanaries(canaries))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for _, r := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/kv/honeypot/db", http.NoBody),
+			httptest.NewRequest(http.MethodGet, "/kv/app/db", http.NoBody),
+			httptest.NewRequest(http.MethodPut, "/kv/honeypot/db", http.NoBody), // writes are not canary reads
+		} {
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}
+
+		calls := auditStore.LogAuditCalls()
+		require.Len(t, calls, 3)
+		assert.Equal(t, enum.AuditActionCanary, calls[0].Entry.Action)
+		assert.Equal(t, enum.AuditActionRead, calls[1].Entry.Action)
+		assert.Equal(t, enum.AuditActionUpdate, calls[2].Entry.Action)
+	})
+
+	t.Run("bulk requests logged per key", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			LogAuditFunc: func(context.Context, store.AuditEntry) error { return nil },
+		}
+		canaries := &mocks.CanaryMatcherMock{IsCanaryFunc: func(key string) bool { return key == "honeypot/db" }}
+		auth := &mocks.AuthMock{
+			GetRequestActorFunc: func(*http.Request) (string, string) { return "user", "admin" },
+			IsRequestAdminFunc:  func(*http.Request) bool { return true },
+		}
+		handler := Middleware(auditStore, auth, WithCanaries(canaries))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			size := 5
+			if r.Method == http.MethodGet {
+				keyaudit.Add(r.Context(), keyaudit.Record{Key: "app/db", Action: enum.AuditActionRead, Size: &size})
+				keyaudit.Add(r.Context(), keyaudit.Record{Key: "honeypot/db", Action: enum.AuditActionRead, Size: &size})
+			} else {
+				keyaudit.Add(r.Context(), keyaudit.Record{Key: "app/new", Action: enum.AuditActionCreate, Size: &size})
+				keyaudit.Add(r.Context(), keyaudit.Record{Key: "app/old", Action: enum.AuditActionDelete})
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/kv/_export?prefix=app/", http.NoBody))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/kv/_import", http.NoBody))
+
+		calls := auditStore.LogAuditCalls()
+		require.Len(t, calls, 4, "an entry per key, none for the routes")
+		assert.Equal(t, "app/db", calls[0].Entry.Key)
+		assert.Equal(t, enum.AuditActionRead, calls[0].Entry.Action)
+		assert.Equal(t, 5, *calls[0].Entry.ValueSize)
+		assert.Equal(t, "admin", calls[0].Entry.Actor)
+		assert.Equal(t, enum.AuditActionCanary, calls[1].Entry.Action, "canary read inside an export")
+		assert.Equal(t, enum.AuditActionCreate, calls[2].Entry.Action)
+		assert.Equal(t, enum.AuditResultSuccess, calls[2].Entry.Result)
+		assert.Equal(t, "app/old", calls[3].Entry.Key)
+		assert.Equal(t, enum.AuditActionDelete, calls[3].Entry.Action)
+		assert.Nil(t, calls[3].Entry.ValueSize)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/kv/_txn", http.NoBody))
+		calls = auditStore.LogAuditCalls()
+		require.Len(t, calls, 6, "transaction logged per key")
+		assert.Equal(t, "app/new", calls[4].Entry.Key)
+		assert.Equal(t, enum.AuditActionDelete, calls[5].Entry.Action)
+
+		denied := Middleware(auditStore, auth)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		denied.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/kv/_import", http.NoBody))
+		calls = auditStore.LogAuditCalls()
+		require.Len(t, calls, 7, "failed request without keys logged as the route")
+		assert.Equal(t, "_import", calls[6].Entry.Key)
+		assert.Equal(t, enum.AuditResultDenied, calls[6].Entry.Result)
+	})
+
+	t.Run("observers without store", func(t *testing.T) {
+		observer := &mocks.ObserverMock{ObserveFunc: func(store.AuditEntry, bool) {}}
+		handler := Middleware(nil, nil, WithObserver(observer))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/kv/app/key", http.NoBody))
+
+		require.Len(t, observer.ObserveCalls(), 1)
+		assert.Equal(t, enum.AuditActionDelete, observer.ObserveCalls()[0].Entry.Action)
+		assert.False(t, observer.ObserveCalls()[0].Admin)
+	})
 }
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
 	"github.com/go-pkgz/rest"
 
 	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/internal/access"
 	"github.com/umputun/stash/app/store"
 )
 
@@ -47,27 +50,172 @@ type QueryResponse struct {
 	Limit   int                `json:"limit"`
 }
 
+// PruneRequest represents the JSON request for audit pruning, the filters of QueryRequest without a limit
+// and the confirmation. Confirm is the number of matching entries reported by a dry run without it.
+type PruneRequest struct {
+	QueryRequest
+	Confirm *int `json:"confirm,omitempty"`
+}
+
+// PruneResponse represents the JSON response for audit pruning.
+type PruneResponse struct {
+	Matched int   `json:"matched"`
+	Deleted int64 `json:"deleted"`
+	DryRun  bool  `json:"dry_run"`
+}
+
+// StatsResponse represents the JSON response for audit stats.
+type StatsResponse struct {
+	GroupBy string             `json:"group_by"`
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	Total   int                `json:"total"` // sum of counts across all groups, including those cut by limit
+	Counts  []store.AuditCount `json:"counts"`
+}
+
 // HandleQuery handles POST /audit/query requests.
 // Requires admin privileges via session cookie or API token with admin flag.
 func (h *Handler) HandleQuery(w http.ResponseWriter, r *http.Request) {
-	if h.auth == nil {
-		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "unauthorized")
+	if access.RequireAdmin(w, r, h.auth) {
+		h.handleQueryInternal(w, r)
+	}
+}
+
+// HandleStats handles GET /audit/stats requests, returning entry counts grouped by actor, key or action.
+// Query parameters: group_by (required), range (e.g. 24h, 7d; default 7d), optional key, actor,
+// action and result filters, and limit on the number of groups. Requires admin privileges.
+func (h *Handler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
 		return
 	}
 
-	if h.auth.IsRequestAdmin(r) {
-		h.handleQueryInternal(w, r)
+	params := r.URL.Query()
+	groupBy := params.Get("group_by")
+	switch groupBy {
+	case store.AuditGroupActor, store.AuditGroupKey, store.AuditGroupAction:
+	default:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "group_by must be one of actor, key, action")
+		return
+	}
+
+	period, err := parseRange(params.Get("range"))
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid range")
+		return
+	}
+
+	now := time.Now().UTC()
+	query, err := h.buildQuery(QueryRequest{Key: params.Get("key"), Actor: params.Get("actor"),
+		Action: params.Get("action"), Result: params.Get("result")})
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid query parameters")
+		return
+	}
+	query.From, query.To, query.Limit = now.Add(-period), now, 0
+
+	limit := 0
+	if v := params.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid limit")
+			return
+		}
+	}
+
+	counts, err := h.store.CountAudit(r.Context(), query, groupBy)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to count audit entries")
 		return
 	}
 
-	// check if authenticated but not admin (403) vs not authenticated at all (401)
-	actorType, _ := h.auth.GetRequestActor(r)
-	if actorType == enum.ActorTypeUser.String() || actorType == enum.ActorTypeToken.String() {
-		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "admin access required")
+	resp := StatsResponse{GroupBy: groupBy, From: query.From, To: query.To, Counts: []store.AuditCount{}}
+	for _, c := range counts {
+		resp.Total += c.Count
+	}
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	resp.Counts = append(resp.Counts, counts...)
+	rest.RenderJSON(w, resp)
+}
+
+// HandlePrune handles POST /audit/prune requests, removing entries matching the same filters as
+// /audit/query, e.g. all entries of a decommissioned actor. At least one filter is required, pruning
+// by age alone is left to the retention. Without confirm it's a dry run reporting the number of matching
+// entries; entries are deleted only if confirm equals that number, so the caller deletes what it has seen.
+// Requires admin privileges.
+func (h *Handler) HandlePrune(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
 		return
 	}
 
-	rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "unauthorized")
+	var req PruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	if req.Key == "" && req.Actor == "" && req.ActorType == "" && req.Action == "" && req.Result == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil,
+			"prune requires a key, actor, actor_type, action or result filter")
+		return
+	}
+	query, err := h.buildQuery(req.QueryRequest)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid query parameters")
+		return
+	}
+	query.Limit = 1 // only the total is needed
+
+	_, matched, err := h.store.QueryAudit(r.Context(), query)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to query audit log")
+		return
+	}
+	if req.Confirm == nil {
+		rest.RenderJSON(w, PruneResponse{Matched: matched, DryRun: true})
+		return
+	}
+	if *req.Confirm != matched {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusConflict, nil,
+			fmt.Sprintf("confirm is %d, but %d entries match", *req.Confirm, matched))
+		return
+	}
+
+	deleted, err := h.store.DeleteAudit(r.Context(), query)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to prune audit log")
+		return
+	}
+	_, actor := h.auth.GetRequestActor(r)
+	log.Printf("[WARN] audit pruned by %s, %d entries deleted, filter key=%q actor=%q actor_type=%q action=%q result=%q from=%q to=%q",
+		actor, deleted, req.Key, req.Actor, req.ActorType, req.Action, req.Result, req.From, req.To)
+	rest.RenderJSON(w, PruneResponse{Matched: matched, Deleted: deleted})
+}
+
+// maxStatsRange limits how far back audit stats can look.
+const maxStatsRange = 366 * 24 * time.Hour
+
+// parseRange parses a stats range like "7d", "12h" or "90m". Empty value defaults to 7 days.
+func parseRange(s string) (time.Duration, error) {
+	if s == "" {
+		return 7 * 24 * time.Hour, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("parse days %q: %w", s, err)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("parse duration %q: %w", s, err)
+		}
+	}
+	if d <= 0 || d > maxStatsRange {
+		return 0, fmt.Errorf("range %q out of bounds, must be positive and up to 366d", s)
+	}
+	return d, nil
 }
 
 // handleQueryInternal performs the actual audit query after auth is verified.
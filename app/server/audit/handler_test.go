@@ -3,10 +3,12 @@ package audit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -300,6 +302,187 @@ func TestHandler_HandleQuery(t *testing.T) {
 	})
 }
 
+func TestHandler_HandleStats(t *testing.T) {
+	adminAuth := &mocks.AuthMock{IsRequestAdminFunc: func(_ *http.Request) bool { return true }}
+
+	t.Run("returns forbidden for non-admin token", func(t *testing.T) {
+		auth := &mocks.AuthMock{
+			IsRequestAdminFunc:  func(_ *http.Request) bool { return false },
+			GetRequestActorFunc: func(_ *http.Request) (string, string) { return "token", "token:regu****" },
+		}
+		handler := NewHandler(&mocks.StoreMock{}, auth, 100)
+
+		rec := httptest.NewRecorder()
+		handler.HandleStats(rec, httptest.NewRequest(http.MethodGet, "/audit/stats?group_by=actor", http.NoBody))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("returns grouped counts for admin", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			CountAuditFunc: func(_ context.Context, _ store.AuditQuery, _ string) ([]store.AuditCount, error) {
+				return []store.AuditCount{{Value: "alice", Count: 5}, {Value: "bob", Count: 3}, {Value: "carol", Count: 1}}, nil
+			},
+		}
+		handler := NewHandler(auditStore, adminAuth, 100)
+
+		req := httptest.NewRequest(http.MethodGet, "/audit/stats?group_by=actor&range=24h&action=delete&key=app/*&limit=2", http.NoBody)
+		rec := httptest.NewRecorder()
+		handler.HandleStats(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp StatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "actor", resp.GroupBy)
+		assert.Equal(t, 9, resp.Total)
+		assert.Equal(t, []store.AuditCount{{Value: "alice", Count: 5}, {Value: "bob", Count: 3}}, resp.Counts)
+		assert.Equal(t, 24*time.Hour, resp.To.Sub(resp.From))
+
+		calls := auditStore.CountAuditCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, store.AuditGroupActor, calls[0].GroupBy)
+		assert.Equal(t, enum.AuditActionDelete, calls[0].Q.Action)
+		assert.Equal(t, "app/*", calls[0].Q.Key)
+		assert.Equal(t, resp.From, calls[0].Q.From)
+		assert.Zero(t, calls[0].Q.Limit)
+	})
+
+	t.Run("returns empty counts", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			CountAuditFunc: func(context.Context, store.AuditQuery, string) ([]store.AuditCount, error) { return nil, nil },
+		}
+		handler := NewHandler(auditStore, adminAuth, 100)
+
+		rec := httptest.NewRecorder()
+		handler.HandleStats(rec, httptest.NewRequest(http.MethodGet, "/audit/stats?group_by=key", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"counts":[]`)
+		assert.Equal(t, 7*24*time.Hour, auditStore.CountAuditCalls()[0].Q.To.Sub(auditStore.CountAuditCalls()[0].Q.From))
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		handler := NewHandler(&mocks.StoreMock{}, adminAuth, 100)
+		for _, q := range []string{"", "group_by=ip", "group_by=actor&range=1y", "group_by=actor&range=0d",
+			"group_by=actor&action=purge", "group_by=actor&limit=-1"} {
+			rec := httptest.NewRecorder()
+			handler.HandleStats(rec, httptest.NewRequest(http.MethodGet, "/audit/stats?"+q, http.NoBody))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, q)
+		}
+	})
+
+	t.Run("returns error on store failure", func(t *testing.T) {
+		auditStore := &mocks.StoreMock{
+			CountAuditFunc: func(context.Context, store.AuditQuery, string) ([]store.AuditCount, error) {
+				return nil, errors.New("db error")
+			},
+		}
+		handler := NewHandler(auditStore, adminAuth, 100)
+
+		rec := httptest.NewRecorder()
+		handler.HandleStats(rec, httptest.NewRequest(http.MethodGet, "/audit/stats?group_by=action", http.NoBody))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestHandler_HandlePrune(t *testing.T) {
+	adminAuth := &mocks.AuthMock{
+		IsRequestAdminFunc:  func(_ *http.Request) bool { return true },
+		GetRequestActorFunc: func(_ *http.Request) (string, string) { return "user", "admin" },
+	}
+	newStore := func() *mocks.StoreMock {
+		return &mocks.StoreMock{
+			QueryAuditFunc: func(context.Context, store.AuditQuery) ([]store.AuditEntry, int, error) {
+				return []store.AuditEntry{{Key: "app/a"}}, 42, nil
+			},
+			DeleteAuditFunc: func(context.Context, store.AuditQuery) (int64, error) { return 42, nil },
+		}
+	}
+	prune := func(h *Handler, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandlePrune(rec, httptest.NewRequest(http.MethodPost, "/audit/prune", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("dry run without confirm", func(t *testing.T) {
+		st := newStore()
+		rec := prune(NewHandler(st, adminAuth, 100), `{"actor":"token:old1","key":"app/*"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"matched":42,"deleted":0,"dry_run":true}`, rec.Body.String())
+		require.Len(t, st.QueryAuditCalls(), 1)
+		assert.Equal(t, "token:old1", st.QueryAuditCalls()[0].Q.Actor)
+		assert.Empty(t, st.DeleteAuditCalls())
+	})
+
+	t.Run("deletes with matching confirm", func(t *testing.T) {
+		st := newStore()
+		rec := prune(NewHandler(st, adminAuth, 100), `{"actor":"token:old1","action":"read","confirm":42}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"matched":42,"deleted":42,"dry_run":false}`, rec.Body.String())
+		require.Len(t, st.DeleteAuditCalls(), 1)
+		assert.Equal(t, "token:old1", st.DeleteAuditCalls()[0].Q.Actor)
+		assert.Equal(t, enum.AuditActionRead, st.DeleteAuditCalls()[0].Q.Action)
+	})
+
+	t.Run("confirm mismatch", func(t *testing.T) {
+		st := newStore()
+		rec := prune(NewHandler(st, adminAuth, 100), `{"actor":"token:old1","confirm":40}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "42 entries match")
+		assert.Empty(t, st.DeleteAuditCalls())
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		tbl := []struct{ name, body string }{
+			{"no filter", `{"confirm":42}`},
+			{"time range only", `{"from":"2025-01-01T00:00:00Z","confirm":42}`},
+			{"invalid action", `{"action":"drop"}`},
+			{"invalid body", `{`},
+		}
+		for _, tt := range tbl {
+			t.Run(tt.name, func(t *testing.T) {
+				st := newStore()
+				assert.Equal(t, http.StatusBadRequest, prune(NewHandler(st, adminAuth, 100), tt.body).Code)
+				assert.Empty(t, st.DeleteAuditCalls())
+			})
+		}
+	})
+
+	t.Run("forbidden for non-admin", func(t *testing.T) {
+		auth := &mocks.AuthMock{
+			IsRequestAdminFunc:  func(_ *http.Request) bool { return false },
+			GetRequestActorFunc: func(_ *http.Request) (string, string) { return "user", "bob" },
+		}
+		st := newStore()
+		assert.Equal(t, http.StatusForbidden, prune(NewHandler(st, auth, 100), `{"actor":"x","confirm":42}`).Code)
+		assert.Empty(t, st.DeleteAuditCalls())
+	})
+}
+
+func TestParseRange(t *testing.T) {
+	tbl := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "", want: 7 * 24 * time.Hour},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "367d", err: true},
+		{in: "-1h", err: true},
+		{in: "xd", err: true},
+		{in: "week", err: true},
+	}
+	for _, tt := range tbl {
+		d, err := parseRange(tt.in)
+		if tt.err {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, d, tt.in)
+	}
+}
+
 func TestNewHandler(t *testing.T) {
 	t.Run("applies default max limit", func(t *testing.T) {
 		handler := NewHandler(nil, nil, 0)
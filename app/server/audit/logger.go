@@ -8,13 +8,16 @@ import (
 
 	"github.com/go-pkgz/rest/realip"
 	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/internal/keyaudit"
 	"github.com/umputun/stash/app/store"
 )
 
 // logger handles building and logging audit entries.
 type logger struct {
-	store Store
-	auth  Auth
+	store     Store // nil if entries are not persisted
+	auth      Auth
+	observers []Observer
+	canaries  CanaryMatcher // optional
 }
 
 // newLogger creates a new audit logger.
@@ -23,7 +26,8 @@ func newLogger(auditStore Store, authSvc Auth) *logger {
 }
 
 // middleware returns HTTP middleware that logs audit entries after handler completes.
-// Applies only to /kv/* routes. Logs read, create, update, delete actions based on method.
+// Applies only to /kv/* routes. Logs read, create, update, delete actions based on method,
+// bulk requests are logged with an entry per key reported by the handler instead.
 func (a *logger) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// only audit /kv/* routes (not /kv or /kv/ list operations)
@@ -50,43 +54,98 @@ func (a *logger) middleware(next http.Handler) http.Handler {
 
 		// wrap response to capture status and size
 		rc := newResponseCapture(w)
+
+		// bulk requests are logged by the keys their handlers report, one entry per key.
+		// a failed request with no keys reported, e.g. a denied import, is logged as a request to the route
+		if isBulk(r.Method, key) {
+			ctx, records := keyaudit.WithRecorder(r.Context())
+			r = r.WithContext(ctx)
+			next.ServeHTTP(rc, r)
+			recs := records()
+			for _, rec := range recs {
+				a.log(r, a.keyEntry(r, rec))
+			}
+			if len(recs) > 0 || rc.status < http.StatusBadRequest {
+				return
+			}
+			a.log(r, a.buildEntry(r, rc, key))
+			return
+		}
+
 		next.ServeHTTP(rc, r)
 
 		// log audit entry after handler completes
-		entry := a.buildEntry(r, rc, key)
+		a.log(r, a.buildEntry(r, rc, key))
+	})
+}
+
+// log stores the entry and passes it to observers.
+func (a *logger) log(r *http.Request, entry store.AuditEntry) {
+	if a.store != nil {
 		if err := a.store.LogAudit(r.Context(), entry); err != nil {
 			log.Printf("[WARN] failed to log audit entry: %v", err)
 		}
-	})
+	}
+	if len(a.observers) > 0 {
+		admin := a.auth != nil && a.auth.IsRequestAdmin(r)
+		for _, o := range a.observers {
+			o.Observe(entry, admin)
+		}
+	}
 }
 
 // buildEntry creates an audit entry from request and response data.
 func (a *logger) buildEntry(r *http.Request, rc *responseCapture, key string) store.AuditEntry {
-	actor, actorType := a.extractActor(r)
-	action := a.mapAction(r.Method, rc.status)
-	result := a.mapStatus(rc.status)
+	entry := a.requestEntry(r, key, a.mapAction(r.Method, rc.status))
+	entry.Result = a.mapStatus(rc.status)
 
-	ip, _ := realip.Get(r) // ignore error, fallback to empty string
+	// set value size for successful read/create/update operations
+	if entry.Result == enum.AuditResultSuccess && entry.Action != enum.AuditActionDelete {
+		size := rc.bytesWritten
+		entry.ValueSize = &size
+	}
 
-	entry := store.AuditEntry{
+	return entry
+}
+
+// keyEntry creates an audit entry of a key reported by the handler of a bulk request.
+func (a *logger) keyEntry(r *http.Request, rec keyaudit.Record) store.AuditEntry {
+	entry := a.requestEntry(r, rec.Key, rec.Action)
+	entry.Result, entry.ValueSize = rec.Result, rec.Size
+	return entry
+}
+
+// requestEntry creates an audit entry of the action on the key with the actor and client of the request.
+// Reads of canaries are logged with the canary action.
+func (a *logger) requestEntry(r *http.Request, key string, action enum.AuditAction) store.AuditEntry {
+	actor, actorType := a.extractActor(r)
+	if action == enum.AuditActionRead && a.canaries != nil && a.canaries.IsCanary(key) {
+		action = enum.AuditActionCanary
+	}
+	ip, _ := realip.Get(r) // ignore error, fallback to empty string
+	return store.AuditEntry{
 		Timestamp: time.Now(),
 		Action:    action,
 		Key:       key,
 		Actor:     actor,
 		ActorType: actorType,
-		Result:    result,
 		IP:        ip,
 		UserAgent: r.UserAgent(),
 		RequestID: r.Header.Get("X-Request-ID"),
+		Reason:    RequestReason(r),
 	}
+}
 
-	// set value size for successful read/create/update operations
-	if result == enum.AuditResultSuccess && action != enum.AuditActionDelete {
-		size := rc.bytesWritten
-		entry.ValueSize = &size
+// isBulk reports whether the request changes or reads many keys, GET /kv/_export, POST /kv/_import or POST /kv/_txn.
+// Their handlers report the keys with keyaudit, the route itself is logged only if the request failed.
+func isBulk(method, key string) bool {
+	switch method {
+	case http.MethodGet:
+		return key == "_export"
+	case http.MethodPost:
+		return key == "_import" || key == "_txn"
 	}
-
-	return entry
+	return false
 }
 
 // extractActor extracts actor identity from request.
@@ -118,6 +177,8 @@ func (a *logger) mapAction(method string, status int) enum.AuditAction {
 			return enum.AuditActionCreate
 		}
 		return enum.AuditActionUpdate
+	case http.MethodPatch:
+		return enum.AuditActionUpdate
 	case http.MethodDelete:
 		return enum.AuditActionDelete
 	default:
@@ -130,7 +191,7 @@ func (a *logger) mapStatus(status int) enum.AuditResult {
 	switch {
 	case status >= 200 && status < 300:
 		return enum.AuditResultSuccess
-	case status == http.StatusForbidden:
+	case status == http.StatusForbidden, status == http.StatusPreconditionRequired:
 		return enum.AuditResultDenied
 	case status == http.StatusNotFound:
 		return enum.AuditResultNotFound
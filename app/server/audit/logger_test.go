@@ -22,6 +22,7 @@ func TestLogger_MapAction(t *testing.T) {
 		{http.MethodGet, http.StatusNotFound, enum.AuditActionRead},
 		{http.MethodPut, http.StatusOK, enum.AuditActionUpdate},
 		{http.MethodPut, http.StatusCreated, enum.AuditActionCreate},
+		{http.MethodPatch, http.StatusOK, enum.AuditActionUpdate},
 		{http.MethodDelete, http.StatusNoContent, enum.AuditActionDelete},
 		{http.MethodPost, http.StatusOK, enum.AuditActionRead}, // fallback
 	}
@@ -48,6 +49,7 @@ func TestLogger_MapStatus(t *testing.T) {
 		{http.StatusNotFound, enum.AuditResultNotFound},
 		{http.StatusForbidden, enum.AuditResultDenied},
 		{http.StatusUnauthorized, enum.AuditResultDenied},
+		{http.StatusPreconditionRequired, enum.AuditResultDenied},
 		{http.StatusBadRequest, enum.AuditResultNotFound},
 		{http.StatusInternalServerError, enum.AuditResultNotFound},
 	}
@@ -16,6 +16,12 @@ import (
 //
 //		// make and configure a mocked audit.Store
 //		mockedStore := &StoreMock{
+//			CountAuditFunc: func(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error) {
+//				panic("mock out the CountAudit method")
+//			},
+//			DeleteAuditFunc: func(ctx context.Context, q store.AuditQuery) (int64, error) {
+//				panic("mock out the DeleteAudit method")
+//			},
 //			LogAuditFunc: func(ctx context.Context, entry store.AuditEntry) error {
 //				panic("mock out the LogAudit method")
 //			},
@@ -29,6 +35,12 @@ import (
 //
 //	}
 type StoreMock struct {
+	// CountAuditFunc mocks the CountAudit method.
+	CountAuditFunc func(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error)
+
+	// DeleteAuditFunc mocks the DeleteAudit method.
+	DeleteAuditFunc func(ctx context.Context, q store.AuditQuery) (int64, error)
+
 	// LogAuditFunc mocks the LogAudit method.
 	LogAuditFunc func(ctx context.Context, entry store.AuditEntry) error
 
@@ -37,6 +49,22 @@ type StoreMock struct {
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// CountAudit holds details about calls to the CountAudit method.
+		CountAudit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+			// GroupBy is the groupBy argument value.
+			GroupBy string
+		}
+		// DeleteAudit holds details about calls to the DeleteAudit method.
+		DeleteAudit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+		}
 		// LogAudit holds details about calls to the LogAudit method.
 		LogAudit []struct {
 			// Ctx is the ctx argument value.
@@ -52,8 +80,86 @@ type StoreMock struct {
 			Q store.AuditQuery
 		}
 	}
-	lockLogAudit   sync.RWMutex
-	lockQueryAudit sync.RWMutex
+	lockCountAudit  sync.RWMutex
+	lockDeleteAudit sync.RWMutex
+	lockLogAudit    sync.RWMutex
+	lockQueryAudit  sync.RWMutex
+}
+
+// CountAudit calls CountAuditFunc.
+func (mock *StoreMock) CountAudit(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error) {
+	if mock.CountAuditFunc == nil {
+		panic("StoreMock.CountAuditFunc: method is nil but Store.CountAudit was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Q       store.AuditQuery
+		GroupBy string
+	}{
+		Ctx:     ctx,
+		Q:       q,
+		GroupBy: groupBy,
+	}
+	mock.lockCountAudit.Lock()
+	mock.calls.CountAudit = append(mock.calls.CountAudit, callInfo)
+	mock.lockCountAudit.Unlock()
+	return mock.CountAuditFunc(ctx, q, groupBy)
+}
+
+// CountAuditCalls gets all the calls that were made to CountAudit.
+// Check the length with:
+//
+//	len(mockedStore.CountAuditCalls())
+func (mock *StoreMock) CountAuditCalls() []struct {
+	Ctx     context.Context
+	Q       store.AuditQuery
+	GroupBy string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Q       store.AuditQuery
+		GroupBy string
+	}
+	mock.lockCountAudit.RLock()
+	calls = mock.calls.CountAudit
+	mock.lockCountAudit.RUnlock()
+	return calls
+}
+
+// DeleteAudit calls DeleteAuditFunc.
+func (mock *StoreMock) DeleteAudit(ctx context.Context, q store.AuditQuery) (int64, error) {
+	if mock.DeleteAuditFunc == nil {
+		panic("StoreMock.DeleteAuditFunc: method is nil but Store.DeleteAudit was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}{
+		Ctx: ctx,
+		Q:   q,
+	}
+	mock.lockDeleteAudit.Lock()
+	mock.calls.DeleteAudit = append(mock.calls.DeleteAudit, callInfo)
+	mock.lockDeleteAudit.Unlock()
+	return mock.DeleteAuditFunc(ctx, q)
+}
+
+// DeleteAuditCalls gets all the calls that were made to DeleteAudit.
+// Check the length with:
+//
+//	len(mockedStore.DeleteAuditCalls())
+func (mock *StoreMock) DeleteAuditCalls() []struct {
+	Ctx context.Context
+	Q   store.AuditQuery
+} {
+	var calls []struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}
+	mock.lockDeleteAudit.RLock()
+	calls = mock.calls.DeleteAudit
+	mock.lockDeleteAudit.RUnlock()
+	return calls
 }
 
 // LogAudit calls LogAuditFunc.
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// ReasonHeader is the request header with the access reason, recorded in the audit log.
+// Non-ASCII text can be sent percent-encoded.
+const ReasonHeader = "X-Stash-Reason"
+
+// maxReasonLen limits the length of recorded reasons, in runes
+const maxReasonLen = 500
+
+// Justification matches keys under prefixes marked as "justification required". Reads and writes of
+// such keys are rejected with 428 Precondition Required unless the request states the access reason.
+type Justification struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// NewJustification creates a matcher from key patterns: an exact key or a prefix ending with "*",
+// e.g. "prod/db/password" or "prod/*". Returns nil if there are no patterns.
+func NewJustification(patterns []string) *Justification {
+	j := &Justification{keys: map[string]bool{}}
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(strings.TrimSpace(p), "*"); ok {
+			norm := store.NormalizeKey(prefix)
+			if norm == "" {
+				continue // a bare "*" would ask for a reason on every access, it's not a sensitive prefix
+			}
+			if strings.HasSuffix(prefix, "/") {
+				norm += "/" // keep "prod/*" from matching "production"
+			}
+			j.prefixes = append(j.prefixes, norm)
+			continue
+		}
+		if key := store.NormalizeKey(p); key != "" {
+			j.keys[key] = true
+		}
+	}
+	if len(j.keys) == 0 && len(j.prefixes) == 0 {
+		return nil
+	}
+	return j
+}
+
+// RequiresReason reports whether access to the key must be justified. Safe to call on nil.
+func (j *Justification) RequiresReason(key string) bool {
+	if j == nil {
+		return false
+	}
+	key = store.NormalizeKey(key)
+	if j.keys[key] {
+		return true
+	}
+	for _, p := range j.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects /kv requests to keys requiring justification if the request has no reason.
+// Listing keys doesn't expose values and is not checked.
+func (j *Justification) Middleware(next http.Handler) http.Handler {
+	if j == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.URL.Path, "/kv/")
+		if !ok || key == "" || strings.HasPrefix(key, "subscribe/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = strings.TrimPrefix(key, "history/")
+		if j.RequiresReason(key) && RequestReason(r) == "" {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusPreconditionRequired, nil,
+				"access to "+store.NormalizeKey(key)+" requires a reason in "+ReasonHeader+" header")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestReason returns the access reason of the request, empty if not given.
+// Percent-encoded reasons are decoded.
+func RequestReason(r *http.Request) string {
+	reason := r.Header.Get(ReasonHeader)
+	if decoded, err := url.PathUnescape(reason); err == nil {
+		reason = decoded
+	}
+	return NormalizeReason(reason)
+}
+
+// NormalizeReason collapses whitespace in the reason and truncates long ones.
+func NormalizeReason(reason string) string {
+	reason = strings.Join(strings.Fields(reason), " ") // no line breaks in the audit log
+	if !utf8.ValidString(reason) {
+		reason = strings.ToValidUTF8(reason, "?")
+	}
+	if utf8.RuneCountInString(reason) > maxReasonLen {
+		reason = string([]rune(reason)[:maxReasonLen])
+	}
+	return reason
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJustification(t *testing.T) {
+	j := NewJustification([]string{"prod/db/password", " prod/* ", "/legacy/key ", "*", ""})
+
+	for key, want := range map[string]bool{
+		"prod/db/password":  true,
+		"/prod/db/password": true,
+		"prod/app/config":   true,
+		"prod":              false,
+		"production/x":      false,
+		"legacy/key":        true,
+		"legacy/other":      false,
+		"dev/db/password":   false,
+	} {
+		assert.Equal(t, want, j.RequiresReason(key), key)
+	}
+
+	assert.Nil(t, NewJustification(nil))
+	assert.Nil(t, NewJustification([]string{"*", " "}))
+	var empty *Justification
+	assert.False(t, empty.RequiresReason("anything"))
+}
+
+func TestJustification_Middleware(t *testing.T) {
+	handler := NewJustification([]string{"prod/*"}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name, method, path, reason string
+		want                       int
+	}{
+		{name: "read without reason", method: "GET", path: "/kv/prod/db", want: http.StatusPreconditionRequired},
+		{name: "write without reason", method: "PUT", path: "/kv/prod/db", want: http.StatusPreconditionRequired},
+		{name: "delete without reason", method: "DELETE", path: "/kv/prod/db", want: http.StatusPreconditionRequired},
+		{name: "history without reason", method: "GET", path: "/kv/history/prod/db", want: http.StatusPreconditionRequired},
+		{name: "blank reason", method: "GET", path: "/kv/prod/db", reason: "   ", want: http.StatusPreconditionRequired},
+		{name: "read with reason", method: "GET", path: "/kv/prod/db", reason: "INC-42", want: http.StatusOK},
+		{name: "other prefix", method: "GET", path: "/kv/dev/db", want: http.StatusOK},
+		{name: "list", method: "GET", path: "/kv/", want: http.StatusOK},
+		{name: "subscription", method: "GET", path: "/kv/subscribe/prod/*", want: http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			if tc.reason != "" {
+				req.Header.Set(ReasonHeader, tc.reason)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.want, rec.Code)
+		})
+	}
+
+	var disabled *Justification
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	assert.NotNil(t, disabled.Middleware(next), "nil matcher passes requests through")
+}
+
+func TestRequestReason(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"INC-42 rotate creds":      "INC-42 rotate creds",
+		"  multi\tline\n reason ":  "multi line reason",
+		"%D0%BF%D1%80%D0%BE%D0%B4": "прод",
+		"100% sure":                "100% sure", // not percent-encoded, kept as is
+		strings.Repeat("a", 600):   strings.Repeat("a", maxReasonLen),
+	}
+	for header, want := range tests {
+		req := httptest.NewRequest("GET", "/kv/x", http.NoBody)
+		req.Header.Set(ReasonHeader, header)
+		assert.Equal(t, want, RequestReason(req), header)
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// KeyAccess is an identity of the auth config with access to a key, as listed in the key access view.
+type KeyAccess struct {
+	Type       string // user, token, delegated, workload, cloud or public
+	Name       string // user name, masked token, delegated token name, SPIFFE ID or provider:principal
+	Read       bool
+	Write      bool
+	Admin      bool // identity has admin privileges
+	BreakGlass bool // user gets the access only after break-glass elevation
+}
+
+// KeyAccessList is who can access a key according to the ACLs of the auth config.
+type KeyAccessList struct {
+	Entries []KeyAccess // users first, then tokens, delegated tokens, workloads, cloud roles and public access
+	Policy  bool        // an authorization policy decides access and may deny some of the entries
+}
+
+// KeyAccess resolves every user, token, workload and cloud role of the auth config, delegated tokens and
+// public access that can read or write the key. Scopes of tokens are applied, exchanged tokens and tokens
+// minted for cloud logins are covered by their parent entries and not listed.
+func (s *Service) KeyAccess(ctx context.Context, key string) (KeyAccessList, error) {
+	if s == nil || !s.Enabled() {
+		return KeyAccessList{}, nil
+	}
+	var users, tokens, workloads, clouds, public []KeyAccess
+	s.mu.RLock()
+	for name, user := range s.users {
+		a := KeyAccess{Type: "user", Name: name, Admin: user.Admin, Read: user.ACL.CheckKeyPermission(key, false),
+			Write: user.ACL.CheckKeyPermission(key, true)}
+		if !a.Read && !a.Write && user.BreakGlass != nil {
+			a.Read, a.Write = user.BreakGlass.CheckKeyPermission(key, false), user.BreakGlass.CheckKeyPermission(key, true)
+			a.BreakGlass = true
+		}
+		users = appendAccess(users, a)
+	}
+	for token, acl := range s.tokens {
+		tokens = appendAccess(tokens, aclAccess("token", MaskToken(token), acl, key))
+	}
+	for _, w := range s.workloads {
+		name := w.id
+		if w.wildcard {
+			name += "*"
+		}
+		workloads = appendAccess(workloads, aclAccess("workload", name, w.acl, key))
+	}
+	for _, role := range s.cloudRoles {
+		clouds = appendAccess(clouds, aclAccess("cloud", role.key, role.acl, key))
+	}
+	if s.publicACL != nil {
+		public = appendAccess(public, aclAccess("public", "anonymous", *s.publicACL, key))
+	}
+	policy := s.policy != nil
+	s.mu.RUnlock()
+
+	// delegated tokens are checked after the config lock is released, parseDelegated takes it again
+	delegated, err := s.delegatedAccess(ctx, key)
+	if err != nil {
+		return KeyAccessList{}, err
+	}
+
+	res := KeyAccessList{Policy: policy}
+	for _, group := range [][]KeyAccess{users, tokens, delegated, workloads, clouds, public} {
+		sort.Slice(group, func(i, j int) bool { return group[i].Name < group[j].Name })
+		res.Entries = append(res.Entries, group...)
+	}
+	return res, nil
+}
+
+// delegatedAccess returns the active delegated tokens with access to the key.
+func (s *Service) delegatedAccess(ctx context.Context, key string) ([]KeyAccess, error) {
+	if s.sessionStore == nil {
+		return nil, nil
+	}
+	stored, err := s.sessionStore.DelegatedTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delegated tokens: %w", err)
+	}
+	var res []KeyAccess
+	now := time.Now()
+	for _, dt := range stored {
+		if dt.ExpiresAt != nil && now.After(*dt.ExpiresAt) {
+			continue
+		}
+		acl, _, ok := s.parseDelegated(dt)
+		if !ok {
+			continue
+		}
+		res = appendAccess(res, aclAccess("delegated", dt.Name, acl, key))
+	}
+	return res, nil
+}
+
+// aclAccess returns the access of a token-like ACL to the key, read and write need the matching scopes.
+func aclAccess(typ, name string, acl TokenACL, key string) KeyAccess {
+	return KeyAccess{Type: typ, Name: name, Admin: acl.Admin,
+		Read:  acl.AllowsScope(enum.ScopeRead) && acl.CheckKeyPermission(key, false),
+		Write: acl.AllowsScope(enum.ScopeWrite) && acl.CheckKeyPermission(key, true)}
+}
+
+// appendAccess adds the entry if it grants any access.
+func appendAccess(entries []KeyAccess, a KeyAccess) []KeyAccess {
+	if !a.Read && !a.Write {
+		return entries
+	}
+	return append(entries, a)
+}
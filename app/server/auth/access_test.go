@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_KeyAccess(t *testing.T) {
+	const config = `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+    admin: true
+    prefix_admin: ["billing/*"]
+    permissions:
+      - prefix: "*"
+        access: rw
+  - name: bob
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "billing/*"
+        access: r
+  - name: carol
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "search/*"
+        access: rw
+    break_glass:
+      permissions:
+        - prefix: "*"
+          access: rw
+  - name: dave
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "search/*"
+        access: rw
+tokens:
+  - token: "deploy-token"
+    permissions:
+      - prefix: "billing/*"
+        access: rw
+  - token: "list-token"
+    permissions:
+      - prefix: "*"
+        access: r
+    scopes: [list]
+  - token: "*"
+    permissions:
+      - prefix: "billing/db"
+        access: r
+workloads:
+  - spiffe_id: "spiffe://mesh.example/ns/prod/*"
+    permissions:
+      - prefix: "billing/*"
+        access: w
+`
+	svc, err := New(createTempFile(t, config), time.Hour, false, testSessionStore(t), nil)
+	require.NoError(t, err)
+	alice, ok := svc.managerByID("user:alice")
+	require.True(t, ok)
+	_, err = svc.CreateDelegatedToken(t.Context(), alice,
+		DelegatedTokenRequest{Name: "ci", Permissions: []PermissionConfig{{Prefix: "billing/*", Access: "r"}}})
+	require.NoError(t, err)
+	_, err = svc.CreateDelegatedToken(t.Context(), alice,
+		DelegatedTokenRequest{Name: "old", Permissions: []PermissionConfig{{Prefix: "billing/*", Access: "r"}}, TTL: "1ms"})
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	list, err := svc.KeyAccess(t.Context(), "billing/db")
+	require.NoError(t, err)
+	assert.False(t, list.Policy)
+	assert.Equal(t, []KeyAccess{
+		{Type: "user", Name: "alice", Read: true, Write: true, Admin: true},
+		{Type: "user", Name: "bob", Read: true},
+		{Type: "user", Name: "carol", Read: true, Write: true, BreakGlass: true},
+		{Type: "token", Name: MaskToken("deploy-token"), Read: true, Write: true},
+		{Type: "delegated", Name: "ci", Read: true},
+		{Type: "workload", Name: "spiffe://mesh.example/ns/prod/*", Write: true},
+		{Type: "public", Name: "anonymous", Read: true},
+	}, list.Entries, "dave has no access, list-token has no read scope, old delegated token expired")
+
+	list, err = svc.KeyAccess(t.Context(), "billing/secrets/db")
+	require.NoError(t, err)
+	assert.Empty(t, list.Entries, "wildcards don't grant secrets")
+
+	var nilSvc *Service
+	list, err = nilSvc.KeyAccess(t.Context(), "billing/db")
+	require.NoError(t, err)
+	assert.Empty(t, list.Entries)
+}
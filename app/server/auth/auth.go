@@ -3,10 +3,13 @@
 // It supports two authentication methods:
 //   - Session-based authentication for web UI (username/password login)
 //   - Token-based authentication for API (X-Auth-Token header or Authorization: Bearer)
+//   - SPIFFE workload identity for API (X.509 SVID as mTLS client certificate)
+//   - Cloud identity login for API (AWS IAM, GCP service accounts) returning short-lived tokens
 //
 // Token types:
 //   - Named tokens with specific ACL permissions
 //   - Public token (token="*") allowing unauthenticated access with limited permissions
+//   - Exchanged tokens, short-lived JWTs minted from a named token and limited by its ACL
 //
 // Authorization uses prefix-based ACL where permissions are granted per key prefix
 // with access levels: read (r), write (w), or read-write (rw). Wildcards (*) match
@@ -30,32 +33,80 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/server/internal/cookie"
+	"github.com/umputun/stash/app/store"
 )
 
 //go:generate moq -out mocks/sessionstore.go -pkg mocks -skip-ensure -fmt goimports . SessionStore
 
 const defaultSessionCleanupInterval = 1 * time.Hour
 
+// defaultLoginTTL is the max lifetime of sessions without "remember me", short enough for shared workstations
+const defaultLoginTTL = 12 * time.Hour
+
 // Service handles authentication and authorization.
 type Service struct {
-	mu              sync.RWMutex        // protects users, tokens, publicACL (config data)
-	authFile        string              // path to auth config file for reloading
-	users           map[string]User     // username -> User (for web UI auth)
-	tokens          map[string]TokenACL // token string -> ACL (for API auth)
-	publicACL       *TokenACL           // public access ACL (token="*"), nil if not configured
-	sessionStore    SessionStore        // persistent session storage
-	validator       ConfigValidator     // validates auth config, may be nil
-	loginTTL        time.Duration
-	cleanupInterval time.Duration // interval for session cleanup, defaults to 1h
-	hotReload       bool          // watch auth config for changes and reload
+	mu              sync.RWMutex               // protects users, tokens, publicACL, workloads, cloudRoles (config data)
+	authFile        string                     // path to auth config file for reloading
+	users           map[string]User            // username -> User (for web UI auth)
+	tokens          map[string]TokenACL        // token string -> ACL (for API auth)
+	publicACL       *TokenACL                  // public access ACL (token="*"), nil if not configured
+	workloads       []workloadACL              // SPIFFE workload ACLs, sorted for longest match first
+	trustDomain     string                     // SPIFFE trust domain of workloads, empty if workload identities are disabled
+	cloudRoles      []cloudRole                // cloud principal ACLs, sorted for longest match first
+	cloud           *cloudVerifier             // verifies cloud identities, nil if cloud logins are disabled
+	sessionStore    SessionStore               // persistent session storage
+	validator       ConfigValidator            // validates auth config, may be nil
+	loginTTL        time.Duration              // max session lifetime, sessions are not renewed past it
+	sessionIdle     time.Duration              // idle timeout of sliding sessions, zero for fixed loginTTL sessions
+	rememberTTL     time.Duration              // max lifetime of "remember me" sessions, zero if remember me is disabled
+	rememberIdle    time.Duration              // idle timeout of "remember me" sessions, zero for fixed rememberTTL sessions
+	cleanupInterval time.Duration              // interval for session cleanup, defaults to 1h
+	hotReload       bool                       // watch auth config for changes and reload
+	exchangeSecret  []byte                     // signs exchanged tokens, nil if token exchange is disabled
+	exchangeMaxTTL  time.Duration              // max lifetime of exchanged tokens
+	elevMu          sync.Mutex                 // protects elevations
+	elevations      map[string]elevation       // username -> active break-glass elevation, kept in memory only
+	locationHeader  string                     // request header with the approximate client location, empty if not trusted
+	notifier        LoginNotifier              // notifies users on login from a new device, nil if disabled
+	passkeys        *PasskeyConfig             // WebAuthn passkeys of web UI users, nil if disabled
+	ceremonyMu      sync.Mutex                 // protects ceremonies
+	ceremonies      map[string]passkeyCeremony // started passkey registrations and logins by ID, kept in memory only
+	staleTokenAge   time.Duration              // tokens unused for this long are reported stale
+	usageMu         sync.Mutex                 // protects tokenTouches
+	tokenTouches    map[string]tokenTouch      // token fingerprint -> last stored use, throttles usage writes
+	signed          *signedRequests            // verifies requests signed with named tokens, nil if disabled
+	policy          *Policy                    // decides access to keys instead of the ACL, nil if not configured
+	reloadMu        sync.Mutex                 // serializes reloads and protects reloadStatus
+	reloadStatus    ReloadStatus               // outcome of the last reload of the auth file
+}
+
+// Option configures the auth service.
+type Option func(*Service)
+
+// WithSessionIdle enables sliding sessions: a session expires after idle time without activity,
+// every request moves the expiration forward, up to the login TTL from the login.
+func WithSessionIdle(idle time.Duration) Option {
+	return func(s *Service) {
+		s.sessionIdle = idle
+	}
+}
+
+// WithRemember enables the "remember me" login option. Remembered sessions live up to ttl instead of
+// the login TTL, and expire after idle time without activity, zero idle for a fixed ttl.
+func WithRemember(ttl, idle time.Duration) Option {
+	return func(s *Service) {
+		s.rememberTTL, s.rememberIdle = ttl, idle
+	}
 }
 
 // New creates a new Service instance from configuration file.
 // Returns nil if authFile is empty (authentication disabled).
 // sessionStore is required for persistent session storage.
 // hotReload enables watching the config file for changes.
-func New(authFile string, loginTTL time.Duration, hotReload bool, sstore SessionStore, vldt ConfigValidator) (*Service, error) {
+func New(authFile string, loginTTL time.Duration, hotReload bool, sstore SessionStore, vldt ConfigValidator,
+	opts ...Option) (*Service, error) {
 	if authFile == "" {
 		return nil, nil //nolint:nilnil // nil auth means disabled, not an error
 	}
@@ -79,25 +130,43 @@ func New(authFile string, loginTTL time.Duration, hotReload bool, sstore Session
 		return nil, fmt.Errorf("failed to parse tokens: %w", err)
 	}
 
-	if len(users) == 0 && len(tokens) == 0 && publicACL == nil {
-		return nil, errors.New("auth config must have at least one user or token")
+	workloads, err := parseWorkloadConfigs(cfg.Workloads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workloads: %w", err)
+	}
+
+	cloudRoles, err := parseCloudRoleConfigs(cfg.CloudRoles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cloud roles: %w", err)
+	}
+
+	if len(users) == 0 && len(tokens) == 0 && publicACL == nil && len(workloads) == 0 && len(cloudRoles) == 0 {
+		return nil, errors.New("auth config must have at least one user, token, workload or cloud role")
 	}
 
 	if loginTTL == 0 {
-		loginTTL = 30 * 24 * time.Hour // 30 days
+		loginTTL = defaultLoginTTL
 	}
 
-	return &Service{
+	res := &Service{
 		authFile:        authFile,
 		users:           users,
 		tokens:          tokens,
 		publicACL:       publicACL,
+		workloads:       workloads,
+		cloudRoles:      cloudRoles,
 		sessionStore:    sstore,
 		validator:       vldt,
 		loginTTL:        loginTTL,
 		cleanupInterval: defaultSessionCleanupInterval,
+		staleTokenAge:   defaultStaleTokenAge,
 		hotReload:       hotReload,
-	}, nil
+		reloadStatus:    ReloadStatus{File: authFile, Checksum: cfg.checksum, LoadedAt: time.Now(), HotReload: hotReload},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res, nil
 }
 
 // Enabled returns true if authentication is enabled.
@@ -107,7 +176,7 @@ func (s *Service) Enabled() bool {
 	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return len(s.users) > 0 || len(s.tokens) > 0 || s.publicACL != nil
+	return len(s.users) > 0 || len(s.tokens) > 0 || s.publicACL != nil || len(s.workloads) > 0 || len(s.cloudRoles) > 0
 }
 
 // Activate starts auth background tasks: file watcher (if hot-reload enabled) and session cleanup.
@@ -124,27 +193,52 @@ func (s *Service) Activate(ctx context.Context) error {
 
 	// start session cleanup goroutine
 	s.startCleanup(ctx)
+	s.syncTokenUsage(ctx)
 	return nil
 }
 
 // LoginTTL returns the configured login session TTL.
 func (s *Service) LoginTTL() time.Duration {
 	if s == nil {
-		return 30 * 24 * time.Hour // 30 days default
+		return defaultLoginTTL
 	}
 	return s.loginTTL
 }
 
+// RememberEnabled returns true if users can choose "remember me" on login.
+func (s *Service) RememberEnabled() bool {
+	return s != nil && s.rememberTTL > 0
+}
+
+// SessionLimits returns the max lifetime and the idle timeout of sessions, remembered or not.
+// Zero idle means sessions have a fixed TTL and are not renewed on activity.
+func (s *Service) SessionLimits(remember bool) (ttl, idle time.Duration) {
+	if s == nil {
+		return defaultLoginTTL, 0
+	}
+	if remember && s.rememberTTL > 0 {
+		return s.rememberTTL, s.rememberIdle
+	}
+	return s.loginTTL, s.sessionIdle
+}
+
 // Reload reloads the auth configuration from the file.
 // Validates new config before applying. On success, invalidates sessions only for
 // users that were removed or had their password changed.
 // On error, keeps the existing config and returns the error.
+// Called on SIGHUP, the reload status records it as such.
 func (s *Service) Reload(ctx context.Context) error {
 	if s == nil {
 		return errors.New("auth not enabled")
 	}
+	return s.reloadBy(ctx, "SIGHUP")
+}
+
+// reload loads the auth file and swaps the config, returns the checksum of the loaded file and
+// the users with invalidated sessions.
+func (s *Service) reload(ctx context.Context) (checksum string, invalidated []string, err error) {
 	if s.authFile == "" {
-		return errors.New("auth file path not set")
+		return "", nil, errors.New("auth file path not set")
 	}
 
 	// capture old users state for selective session invalidation
@@ -158,31 +252,42 @@ func (s *Service) Reload(ctx context.Context) error {
 	// load and validate new config before acquiring any locks
 	cfg, err := LoadConfig(s.authFile, s.validator)
 	if err != nil {
-		return fmt.Errorf("failed to load auth config: %w", err)
+		return "", nil, fmt.Errorf("failed to load auth config: %w", err)
 	}
 
 	users, err := parseUsers(cfg.Users)
 	if err != nil {
-		return fmt.Errorf("failed to parse users: %w", err)
+		return "", nil, fmt.Errorf("failed to parse users: %w", err)
 	}
 
 	tokens, publicACL, err := parseTokenConfigs(cfg.Tokens)
 	if err != nil {
-		return fmt.Errorf("failed to parse tokens: %w", err)
+		return "", nil, fmt.Errorf("failed to parse tokens: %w", err)
 	}
 
-	if len(users) == 0 && len(tokens) == 0 && publicACL == nil {
-		return errors.New("auth config must have at least one user or token")
+	workloads, err := parseWorkloadConfigs(cfg.Workloads)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse workloads: %w", err)
+	}
+
+	cloudRoles, err := parseCloudRoleConfigs(cfg.CloudRoles)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse cloud roles: %w", err)
+	}
+
+	if len(users) == 0 && len(tokens) == 0 && publicACL == nil && len(workloads) == 0 && len(cloudRoles) == 0 {
+		return "", nil, errors.New("auth config must have at least one user, token, workload or cloud role")
 	}
 
 	s.mu.Lock()
 	s.users = users
 	s.tokens = tokens
 	s.publicACL = publicACL
+	s.workloads = workloads
+	s.cloudRoles = cloudRoles
 	s.mu.Unlock()
 
 	// selective session invalidation: only for users removed or with password changes
-	var invalidated []string
 	s.mu.RLock()
 	for username, oldHash := range oldUsers {
 		newUser, exists := s.users[username]
@@ -204,7 +309,8 @@ func (s *Service) Reload(ctx context.Context) error {
 	} else {
 		log.Printf("[INFO] auth config reloaded from %s, no sessions invalidated", s.authFile)
 	}
-	return nil
+	s.syncTokenUsage(ctx)
+	return cfg.checksum, invalidated, nil
 }
 
 // IsValidUser checks if username/password are valid credentials.
@@ -240,6 +346,12 @@ func (s *Service) getTokenACL(token string) (TokenACL, bool) {
 	s.mu.RLock()
 	acl, ok := s.tokens[token]
 	s.mu.RUnlock()
+	if !ok && s.ExchangeEnabled() {
+		acl, ok = s.exchangedTokenACL(token)
+	}
+	if !ok {
+		return s.delegatedTokenACL(token)
+	}
 	return acl, ok
 }
 
@@ -259,16 +371,33 @@ func (s *Service) checkPermission(token, key string, needWrite bool) bool {
 	return acl.CheckKeyPermission(key, needWrite)
 }
 
-// CreateSession generates a new session token for the given username.
-func (s *Service) CreateSession(ctx context.Context, username string) (string, error) {
+// requestACL returns the ACL of the API client and its name for logs and audit. The client is the token
+// of the request or, for requests without a token, the SPIFFE workload identity of the mTLS client.
+func (s *Service) requestACL(r *http.Request) (acl TokenACL, name string, ok bool) {
+	token := ExtractToken(r)
+	if token == "" {
+		return s.workloadACL(r)
+	}
+	if acl, ok = s.getTokenACL(token); !ok {
+		return TokenACL{}, "", false
+	}
+	if acl.actor != "" {
+		return acl, acl.actor, true
+	}
+	return acl, "token:" + MaskToken(token), true
+}
+
+// CreateSession generates a new session token for the given username. Remembered sessions get the
+// longer "remember me" limits if enabled.
+func (s *Service) CreateSession(ctx context.Context, username string, remember bool) (string, error) {
 	if s == nil {
 		return "", errors.New("auth not enabled")
 	}
 
 	token := uuid.NewString()
-	expiresAt := time.Now().Add(s.loginTTL)
-
-	if err := s.sessionStore.CreateSession(ctx, token, username, expiresAt); err != nil {
+	remember = remember && s.RememberEnabled()
+	now := time.Now()
+	if err := s.sessionStore.CreateSession(ctx, token, username, s.sessionExpiry(remember, now, now), remember); err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 	return token, nil
@@ -278,15 +407,74 @@ func (s *Service) CreateSession(ctx context.Context, username string) (string, e
 // Returns empty string and false if session is invalid or expired.
 // Note: expiration is checked in store.GetSession, which returns ErrNotFound for expired sessions.
 func (s *Service) GetSessionUser(ctx context.Context, token string) (string, bool) {
+	sess, ok := s.SessionInfo(ctx, token)
+	return sess.Username, ok
+}
+
+// SessionInfo returns a valid session without renewing it, false if the session is invalid or expired.
+func (s *Service) SessionInfo(ctx context.Context, token string) (store.Session, bool) {
 	if s == nil {
-		return "", false
+		return store.Session{}, false
+	}
+	sess, err := s.sessionStore.GetSession(ctx, token)
+	if err != nil {
+		return store.Session{}, false
 	}
+	return sess, true
+}
+
+// RenewSession moves the expiration of a sliding session to the full idle timeout from now,
+// capped by the session lifetime. Sessions with a fixed TTL are returned as is.
+func (s *Service) RenewSession(ctx context.Context, token string) (store.Session, error) {
+	if s == nil {
+		return store.Session{}, errors.New("auth not enabled")
+	}
+	return s.renewSession(ctx, token, false)
+}
+
+// touchSession renews a sliding session on activity. The renewal is throttled, see renewSession.
+func (s *Service) touchSession(ctx context.Context, token string) (store.Session, bool) {
+	sess, err := s.renewSession(ctx, token, true)
+	if err != nil {
+		return store.Session{}, false
+	}
+	return sess, true
+}
 
-	username, _, err := s.sessionStore.GetSession(ctx, token)
+// renewSession extends the session to the full idle timeout. Throttled renewal is written only if it moves
+// the expiration by a tenth of the idle timeout or more, to avoid a database write on every request.
+func (s *Service) renewSession(ctx context.Context, token string, throttle bool) (store.Session, error) {
+	sess, err := s.sessionStore.GetSession(ctx, token)
 	if err != nil {
-		return "", false
+		return store.Session{}, fmt.Errorf("failed to get session: %w", err)
 	}
-	return username, true
+	_, idle := s.SessionLimits(sess.Remember)
+	minStep := time.Second
+	if throttle {
+		minStep = max(idle/10, minStep)
+	}
+	expiresAt := s.sessionExpiry(sess.Remember, sess.CreatedAt, time.Now())
+	if idle <= 0 || expiresAt.Sub(sess.ExpiresAt) < minStep {
+		return sess, nil
+	}
+	if err := s.sessionStore.ExtendSession(ctx, token, expiresAt); err != nil {
+		return store.Session{}, fmt.Errorf("failed to extend session: %w", err)
+	}
+	sess.ExpiresAt = expiresAt.UTC()
+	return sess, nil
+}
+
+// sessionExpiry returns the expiration of a session created at created with the last activity at now.
+func (s *Service) sessionExpiry(remember bool, created, now time.Time) time.Time {
+	ttl, idle := s.SessionLimits(remember)
+	maxExpiry := created.Add(ttl)
+	if idle <= 0 {
+		return maxExpiry
+	}
+	if expiresAt := now.Add(idle); expiresAt.Before(maxExpiry) {
+		return expiresAt
+	}
+	return maxExpiry
 }
 
 // CheckUserPermission checks if a user has the required permission for a key.
@@ -295,13 +483,30 @@ func (s *Service) CheckUserPermission(username, key string, needWrite bool) bool
 	if s == nil || !s.Enabled() {
 		return true // no auth = everything allowed
 	}
+	action := enum.ScopeRead
+	if needWrite {
+		action = enum.ScopeWrite
+	}
+	return s.checkUserPermission(context.Background(), username, key, action, PolicyMetadata{})
+}
+
+// checkUserPermission checks if a user can do the action with a key, write and delete need write permission.
+// With a policy the ACL decision is passed to it, the policy decides.
+func (s *Service) checkUserPermission(ctx context.Context, username, key string, action enum.Scope, md PolicyMetadata) bool {
 	s.mu.RLock()
 	user, exists := s.users[username]
 	s.mu.RUnlock()
 	if !exists {
 		return false
 	}
-	return user.ACL.CheckKeyPermission(key, needWrite)
+	needWrite := action == enum.ScopeWrite || action == enum.ScopeDelete
+	aclAllows := user.ACL.CheckKeyPermission(key, needWrite)
+	if !aclAllows {
+		bg, ok := s.breakGlassACL(user)
+		aclAllows = ok && bg.CheckKeyPermission(key, needWrite)
+	}
+	return s.allowed(ctx, PolicyInput{Actor: PolicyActor{Type: "user", Name: username, Admin: user.Admin}, Key: key,
+		Action: action.String(), ACL: aclAllows, Metadata: md})
 }
 
 // FilterUserKeys filters keys based on user's read permissions.
@@ -317,40 +522,16 @@ func (s *Service) FilterUserKeys(username string, keys []string) []string {
 		return nil
 	}
 
-	var filtered []string
-	for _, key := range keys {
-		if user.ACL.CheckKeyPermission(key, false) {
-			filtered = append(filtered, key)
-		}
-	}
-	return filtered
-}
-
-// filterTokenKeys filters keys based on token's read permissions.
-// returns nil if token doesn't exist.
-func (s *Service) filterTokenKeys(token string, keys []string) []string {
-	if s == nil {
-		return keys // no auth = show all keys
-	}
-	s.mu.RLock()
-	acl, ok := s.tokens[token]
-	s.mu.RUnlock()
-	if !ok {
-		return nil
-	}
-
-	var filtered []string
-	for _, key := range keys {
-		if acl.CheckKeyPermission(key, false) {
-			filtered = append(filtered, key)
-		}
-	}
-	return filtered
+	bg, elevated := s.breakGlassACL(user)
+	actor := PolicyActor{Type: "user", Name: username, Admin: user.Admin}
+	return s.allowedKeys(context.Background(), actor, PolicyMetadata{}, keys, func(key string) bool {
+		return user.ACL.CheckKeyPermission(key, false) || (elevated && bg.CheckKeyPermission(key, false))
+	})
 }
 
 // filterPublicKeys filters keys based on public ACL read permissions.
 // returns nil if public access is not configured.
-func (s *Service) filterPublicKeys(keys []string) []string {
+func (s *Service) filterPublicKeys(ctx context.Context, md PolicyMetadata, keys []string) []string {
 	if s == nil {
 		return nil
 	}
@@ -360,14 +541,17 @@ func (s *Service) filterPublicKeys(keys []string) []string {
 	if publicACL == nil {
 		return nil
 	}
+	return s.allowedKeys(ctx, PolicyActor{Type: "public"}, md, keys, func(key string) bool {
+		return publicACL.CheckKeyPermission(key, false)
+	})
+}
 
-	var filtered []string
-	for _, key := range keys {
-		if publicACL.CheckKeyPermission(key, false) {
-			filtered = append(filtered, key)
-		}
+// PublicReadable reports whether anonymous requests can read the key, always true with auth disabled.
+func (s *Service) PublicReadable(key string) bool {
+	if s == nil || !s.Enabled() {
+		return true
 	}
-	return filtered
+	return len(s.filterPublicKeys(context.Background(), PolicyMetadata{}, []string{key})) == 1
 }
 
 // FilterKeysForRequest filters keys based on the request's authentication.
@@ -378,9 +562,14 @@ func (s *Service) FilterKeysForRequest(r *http.Request, keys []string) []string
 		return keys
 	}
 
-	// check for API token first
-	if token := ExtractToken(r); token != "" {
-		if filtered := s.filterTokenKeys(token, keys); filtered != nil {
+	// check for API token first, then for workload identity of mTLS client without a token,
+	// tokens without readable keys fall back to session and public access
+	if acl, name, ok := s.requestACL(r); ok {
+		actor := PolicyActor{Type: "token", Name: name, Admin: acl.Admin}
+		filtered := s.allowedKeys(r.Context(), actor, requestMetadata(r), keys, func(key string) bool {
+			return acl.CheckKeyPermission(key, false)
+		})
+		if filtered != nil || ExtractToken(r) == "" {
 			return filtered
 		}
 	}
@@ -395,7 +584,7 @@ func (s *Service) FilterKeysForRequest(r *http.Request, keys []string) []string
 	}
 
 	// fall back to public access
-	if filtered := s.filterPublicKeys(keys); filtered != nil {
+	if filtered := s.filterPublicKeys(r.Context(), requestMetadata(r), keys); filtered != nil {
 		return filtered
 	}
 	return nil
@@ -408,9 +597,15 @@ func (s *Service) IsRequestAdmin(r *http.Request) bool {
 		return false
 	}
 
-	// check for API token first
-	if token := ExtractToken(r); token != "" && s.hasTokenACL(token) {
-		return s.isTokenAdmin(token)
+	// check for API token first, then for workload identity of mTLS client without a token
+	token := ExtractToken(r)
+	if token != "" {
+		if acl, ok := s.getTokenACL(token); ok {
+			s.recordTokenUse(r, acl)
+			return s.isTokenAdmin(token)
+		}
+	} else if acl, _, ok := s.workloadACL(r); ok {
+		return acl.Admin
 	}
 
 	// check for session cookie
@@ -425,16 +620,49 @@ func (s *Service) IsRequestAdmin(r *http.Request) bool {
 	return false
 }
 
+// RequestCanChange checks if the request may change the key with the operation, enum.ScopeWrite or
+// enum.ScopeDelete. Used by handlers of requests changing many keys, the middleware checks single-key
+// requests. Returns true when auth is disabled.
+func (s *Service) RequestCanChange(r *http.Request, key string, scope enum.Scope) bool {
+	if s == nil || !s.Enabled() {
+		return true
+	}
+
+	// same order as in TokenMiddleware: public access, session cookie, API token or workload identity
+	s.mu.RLock()
+	publicACL := s.publicACL
+	s.mu.RUnlock()
+	md := requestMetadata(r)
+	if publicACL != nil && publicACL.AllowsScope(scope) && s.allowed(r.Context(), PolicyInput{Actor: PolicyActor{Type: "public"},
+		Key: key, Action: scope.String(), ACL: publicACL.CheckKeyPermission(key, true), Metadata: md}) {
+		return true
+	}
+	for _, cookieName := range cookie.SessionCookieNames {
+		if c, err := r.Cookie(cookieName); err == nil {
+			if username, ok := s.GetSessionUser(r.Context(), c.Value); ok {
+				return s.checkUserPermission(r.Context(), username, key, scope, md)
+			}
+		}
+	}
+	if acl, name, ok := s.requestACL(r); ok {
+		return acl.AllowsScope(scope) && s.allowed(r.Context(), PolicyInput{Actor: PolicyActor{Type: "token", Name: name,
+			Admin: acl.Admin}, Key: key, Action: scope.String(), ACL: acl.CheckKeyPermission(key, true), Metadata: md})
+	}
+	return false
+}
+
 // GetRequestActor returns the actor type and name from the request.
 // Returns ("user", username), ("token", masked_token), or ("public", "").
+// Exchanged tokens are reported as the masked parent token with ":exchanged" suffix, delegated tokens
+// as their name with ":delegated" suffix, SPIFFE workloads as ("token", spiffe_id).
 func (s *Service) GetRequestActor(r *http.Request) (actorType, actorName string) {
 	if s == nil || !s.Enabled() {
 		return "public", ""
 	}
 
-	// check for API token first
-	if token := ExtractToken(r); token != "" && s.hasTokenACL(token) {
-		return "token", "token:" + MaskToken(token)
+	// check for API token or workload identity first
+	if _, name, ok := s.requestACL(r); ok {
+		return "token", name
 	}
 
 	// check for session cookie
@@ -466,6 +694,13 @@ func (s *Service) UserCanWrite(username string) bool {
 			return true
 		}
 	}
+	if bg, ok := s.breakGlassACL(user); ok {
+		for _, pp := range bg.prefixes {
+			if pp.permission.CanWrite() {
+				return true
+			}
+		}
+	}
 	return false
 }
 
@@ -489,9 +724,7 @@ func (s *Service) isTokenAdmin(token string) bool {
 	if s == nil || !s.Enabled() {
 		return false
 	}
-	s.mu.RLock()
-	acl, exists := s.tokens[token]
-	s.mu.RUnlock()
+	acl, exists := s.getTokenACL(token)
 	if !exists {
 		return false
 	}
@@ -578,7 +811,7 @@ func (s *Service) startWatcher(ctx context.Context) error {
 					if ctx.Err() != nil {
 						return
 					}
-					if err := s.Reload(ctx); err != nil {
+					if err := s.reloadBy(ctx, "file change"); err != nil {
 						log.Printf("[WARN] failed to reload auth config: %v", err)
 					}
 				})
@@ -595,7 +828,7 @@ func (s *Service) startWatcher(ctx context.Context) error {
 	return nil
 }
 
-// startCleanup starts background cleanup of expired sessions.
+// startCleanup starts background cleanup of expired sessions and nonces of signed requests.
 // runs periodically until context is canceled. default interval is 1 hour.
 func (s *Service) startCleanup(ctx context.Context) {
 	if s == nil {
@@ -620,6 +853,11 @@ func (s *Service) startCleanup(ctx context.Context) {
 				if deleted > 0 {
 					log.Printf("[INFO] cleaned up %d expired sessions", deleted)
 				}
+				if s.SignedRequestsEnabled() {
+					if _, err := s.sessionStore.DeleteExpiredNonces(ctx); err != nil {
+						log.Printf("[WARN] failed to cleanup expired nonces: %v", err)
+					}
+				}
 			}
 		}
 	}()
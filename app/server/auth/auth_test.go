@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/server/auth/mocks"
 	"github.com/umputun/stash/app/store"
 )
@@ -58,7 +59,7 @@ func TestNew_Errors(t *testing.T) {
 		f := createTempFile(t, "users: []\ntokens: []")
 		_, err := New(f, time.Hour, false, testSessionStore(t), nil)
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "at least one user or token")
+		assert.Contains(t, err.Error(), "at least one user, token, workload or cloud role")
 	})
 
 	t.Run("empty user name", func(t *testing.T) {
@@ -410,7 +411,7 @@ users:
 	require.NoError(t, err)
 
 	// create session
-	token, err := svc.CreateSession(t.Context(), "admin")
+	token, err := svc.CreateSession(t.Context(), "admin", false)
 	require.NoError(t, err)
 	assert.NotEmpty(t, token)
 	assert.Len(t, token, 36) // uuid format
@@ -443,7 +444,7 @@ users:
 	svc, err := New(f, 50*time.Millisecond, false, testSessionStore(t), nil)
 	require.NoError(t, err)
 
-	token, err := svc.CreateSession(t.Context(), "admin")
+	token, err := svc.CreateSession(t.Context(), "admin", false)
 	require.NoError(t, err)
 
 	// session should be valid immediately after creation
@@ -461,9 +462,155 @@ users:
 	assert.False(t, valid)
 }
 
+func TestService_SlidingSession(t *testing.T) {
+	content := `
+users:
+  - name: admin
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: rw
+`
+	f := createTempFile(t, content)
+	ss := testSessionStore(t)
+	svc, err := New(f, time.Hour, false, ss, nil, WithSessionIdle(10*time.Minute))
+	require.NoError(t, err)
+	ttl, idle := svc.SessionLimits(false)
+	assert.Equal(t, time.Hour, ttl)
+	assert.Equal(t, 10*time.Minute, idle)
+
+	token, err := svc.CreateSession(t.Context(), "admin", false)
+	require.NoError(t, err)
+	sess, ok := svc.SessionInfo(t.Context(), token)
+	require.True(t, ok)
+	assert.Equal(t, "admin", sess.Username)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), sess.ExpiresAt, 5*time.Second, "expires after idle timeout")
+
+	t.Run("renew moves expiration", func(t *testing.T) {
+		require.NoError(t, ss.ExtendSession(t.Context(), token, time.Now().Add(time.Minute)))
+		sess, err := svc.RenewSession(t.Context(), token)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), sess.ExpiresAt, 5*time.Second)
+		stored, ok := svc.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.Equal(t, sess.ExpiresAt.Unix(), stored.ExpiresAt.Unix())
+	})
+
+	t.Run("activity renews only after a step", func(t *testing.T) {
+		expires := time.Now().Add(9*time.Minute + 30*time.Second).UTC().Truncate(time.Second)
+		require.NoError(t, ss.ExtendSession(t.Context(), token, expires))
+		sess, ok := svc.touchSession(t.Context(), token)
+		require.True(t, ok)
+		assert.Equal(t, expires.Unix(), sess.ExpiresAt.Unix(), "not written, less than a tenth of idle timeout")
+
+		require.NoError(t, ss.ExtendSession(t.Context(), token, time.Now().Add(5*time.Minute)))
+		sess, ok = svc.touchSession(t.Context(), token)
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), sess.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("expired session is not renewed", func(t *testing.T) {
+		svc.InvalidateSession(t.Context(), token)
+		_, err := svc.RenewSession(t.Context(), token)
+		require.ErrorIs(t, err, store.ErrNotFound)
+		_, ok := svc.touchSession(t.Context(), token)
+		assert.False(t, ok)
+	})
+
+	t.Run("capped by login ttl", func(t *testing.T) {
+		capped, err := New(f, 5*time.Minute, false, ss, nil, WithSessionIdle(10*time.Minute))
+		require.NoError(t, err)
+		token, err := capped.CreateSession(t.Context(), "admin", false)
+		require.NoError(t, err)
+		created, ok := capped.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.WithinDuration(t, created.CreatedAt.Add(5*time.Minute), created.ExpiresAt, time.Second)
+
+		sess, err := capped.RenewSession(t.Context(), token)
+		require.NoError(t, err)
+		assert.Equal(t, created.ExpiresAt.Unix(), sess.ExpiresAt.Unix(), "can't be renewed past login ttl")
+	})
+
+	t.Run("fixed ttl without idle timeout", func(t *testing.T) {
+		fixed, err := New(f, time.Hour, false, ss, nil)
+		require.NoError(t, err)
+		_, idle := fixed.SessionLimits(false)
+		assert.Zero(t, idle)
+		token, err := fixed.CreateSession(t.Context(), "admin", false)
+		require.NoError(t, err)
+		require.NoError(t, ss.ExtendSession(t.Context(), token, time.Now().Add(time.Minute)))
+		sess, err := fixed.RenewSession(t.Context(), token)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), sess.ExpiresAt, 5*time.Second, "not renewed")
+	})
+}
+
+func TestService_RememberSession(t *testing.T) {
+	content := `
+users:
+  - name: admin
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: rw
+`
+	f := createTempFile(t, content)
+	ss := testSessionStore(t)
+
+	t.Run("remembered session gets long limits", func(t *testing.T) {
+		svc, err := New(f, 12*time.Hour, false, ss, nil, WithSessionIdle(time.Hour), WithRemember(30*24*time.Hour, 7*24*time.Hour))
+		require.NoError(t, err)
+		assert.True(t, svc.RememberEnabled())
+
+		token, err := svc.CreateSession(t.Context(), "admin", true)
+		require.NoError(t, err)
+		sess, ok := svc.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.True(t, sess.Remember)
+		assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), sess.ExpiresAt, 5*time.Second)
+
+		// renewal uses remember idle timeout
+		require.NoError(t, ss.ExtendSession(t.Context(), token, time.Now().Add(time.Hour)))
+		sess, err = svc.RenewSession(t.Context(), token)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), sess.ExpiresAt, 5*time.Second)
+
+		token, err = svc.CreateSession(t.Context(), "admin", false)
+		require.NoError(t, err)
+		sess, ok = svc.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.False(t, sess.Remember)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), sess.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("remember disabled", func(t *testing.T) {
+		svc, err := New(f, 12*time.Hour, false, ss, nil)
+		require.NoError(t, err)
+		assert.False(t, svc.RememberEnabled())
+
+		token, err := svc.CreateSession(t.Context(), "admin", true)
+		require.NoError(t, err)
+		sess, ok := svc.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.False(t, sess.Remember, "regular session without remember me")
+		assert.WithinDuration(t, time.Now().Add(12*time.Hour), sess.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("default login ttl", func(t *testing.T) {
+		svc, err := New(f, 0, false, ss, nil)
+		require.NoError(t, err)
+		ttl, idle := svc.SessionLimits(true)
+		assert.Equal(t, 12*time.Hour, ttl)
+		assert.Zero(t, idle)
+
+		var nilSvc *Service
+		assert.False(t, nilSvc.RememberEnabled())
+	})
+}
+
 func TestService_CreateSession_NilService(t *testing.T) {
 	var svc *Service
-	_, err := svc.CreateSession(t.Context(), "admin")
+	_, err := svc.CreateSession(t.Context(), "admin", false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "auth not enabled")
 }
@@ -487,9 +634,9 @@ users:
 }
 
 func TestService_LoginTTL(t *testing.T) {
-	t.Run("nil service returns default 30 days", func(t *testing.T) {
+	t.Run("nil service returns default 12 hours", func(t *testing.T) {
 		var svc *Service
-		assert.Equal(t, 30*24*time.Hour, svc.LoginTTL())
+		assert.Equal(t, 12*time.Hour, svc.LoginTTL())
 	})
 
 	t.Run("returns configured value", func(t *testing.T) {
@@ -598,7 +745,7 @@ tokens:
 	assert.False(t, svc.hasTokenACL("token2"))
 
 	// create a session
-	session, err := svc.CreateSession(t.Context(), "admin")
+	session, err := svc.CreateSession(t.Context(), "admin", false)
 	require.NoError(t, err)
 	_, ok := svc.GetSessionUser(t.Context(), session)
 	assert.True(t, ok)
@@ -690,7 +837,7 @@ users:
 	// reload should fail
 	err = svc.Reload(t.Context())
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "at least one user or token")
+	assert.Contains(t, err.Error(), "at least one user, token, workload or cloud role")
 
 	// original config should still work
 	assert.True(t, svc.CheckUserPermission("admin", "test", true))
@@ -822,7 +969,7 @@ func TestService_Reload_SelectiveSessionInvalidation(t *testing.T) {
 			// create sessions and track tokens
 			tokensByUser := make(map[string]string)
 			for _, username := range tt.sessions {
-				token, createErr := svc.CreateSession(t.Context(), username)
+				token, createErr := svc.CreateSession(t.Context(), username, false)
 				require.NoError(t, createErr)
 				tokensByUser[username] = token
 			}
@@ -869,11 +1016,11 @@ func TestService_Reload_DeleteSessionsByUsernameError(t *testing.T) {
 	// track calls and return error for alice
 	var deletedUsers []string
 	mockStore := &mocks.SessionStoreMock{
-		CreateSessionFunc: func(_ context.Context, token, username string, expiresAt time.Time) error {
+		CreateSessionFunc: func(_ context.Context, token, username string, expiresAt time.Time, remember bool) error {
 			return nil
 		},
-		GetSessionFunc: func(_ context.Context, token string) (string, time.Time, error) {
-			return "", time.Time{}, store.ErrNotFound
+		GetSessionFunc: func(_ context.Context, token string) (store.Session, error) {
+			return store.Session{}, store.ErrNotFound
 		},
 		DeleteSessionsByUsernameFunc: func(_ context.Context, username string) error {
 			deletedUsers = append(deletedUsers, username)
@@ -882,6 +1029,8 @@ func TestService_Reload_DeleteSessionsByUsernameError(t *testing.T) {
 			}
 			return nil
 		},
+		SyncTokenUsageFunc: func(context.Context, []string) error { return nil },
+		TokenUsagesFunc:    func(context.Context) ([]store.TokenUsage, error) { return nil, nil },
 	}
 
 	svc, err := New(f, time.Hour, false, mockStore, nil)
@@ -934,12 +1083,14 @@ tokens:
 	}()
 
 	// concurrent reads while reloading
+	tokenReq := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+	tokenReq.Header.Set("X-Auth-Token", "apitoken")
 	for range 1000 {
 		_ = svc.Enabled()
 		_ = svc.hasTokenACL("apitoken")
 		_ = svc.CheckUserPermission("admin", "test", false)
 		_ = svc.FilterUserKeys("admin", []string{"a", "b", "c"})
-		_ = svc.filterTokenKeys("apitoken", []string{"a", "b", "c"})
+		_ = svc.FilterKeysForRequest(tokenReq, []string{"a", "b", "c"})
 		_ = svc.UserCanWrite("admin")
 	}
 
@@ -995,6 +1146,11 @@ tokens:
 
 	// verify old token is gone
 	assert.False(t, svc.hasTokenACL("token1"), "old token should be gone")
+
+	assert.Eventually(t, func() bool {
+		st := svc.ReloadStatus()
+		return st.LastReload != nil && st.LastReload.Trigger == "file change"
+	}, 2*time.Second, 10*time.Millisecond, "reload status should record the file change")
 }
 
 func TestService_startWatcher_AtomicRename(t *testing.T) {
@@ -1143,12 +1299,12 @@ func TestService_startCleanup(t *testing.T) {
 
 		// create expired session
 		expired := time.Now().Add(-time.Hour).UTC()
-		err := ss.CreateSession(ctx, "expired-token", "user", expired)
+		err := ss.CreateSession(ctx, "expired-token", "user", expired, false)
 		require.NoError(t, err)
 
 		// create valid session
 		valid := time.Now().Add(time.Hour).UTC()
-		err = ss.CreateSession(ctx, "valid-token", "user", valid)
+		err = ss.CreateSession(ctx, "valid-token", "user", valid, false)
 		require.NoError(t, err)
 
 		// start cleanup with short interval
@@ -1173,7 +1329,7 @@ func TestService_startCleanup(t *testing.T) {
 		assert.Equal(t, int64(0), deleted, "cleanup should have already deleted expired sessions")
 
 		// valid session should remain
-		_, _, err = ss.GetSession(ctx, "valid-token")
+		_, err = ss.GetSession(ctx, "valid-token")
 		require.NoError(t, err)
 	})
 
@@ -1233,7 +1389,7 @@ tokens:
 	})
 
 	t.Run("with session - full access", func(t *testing.T) {
-		token, err := svc.CreateSession(t.Context(), "admin")
+		token, err := svc.CreateSession(t.Context(), "admin", false)
 		require.NoError(t, err)
 		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: token})
@@ -1242,7 +1398,7 @@ tokens:
 	})
 
 	t.Run("with session - limited access", func(t *testing.T) {
-		token, err := svc.CreateSession(t.Context(), "reader")
+		token, err := svc.CreateSession(t.Context(), "reader", false)
 		require.NoError(t, err)
 		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: token})
@@ -1250,6 +1406,13 @@ tokens:
 		assert.Equal(t, []string{"public/key1"}, filtered)
 	})
 
+	t.Run("public readable", func(t *testing.T) {
+		assert.True(t, svc.PublicReadable("public/key1"))
+		assert.False(t, svc.PublicReadable("private/key2"))
+		var disabled *Service
+		assert.True(t, disabled.PublicReadable("private/key2"), "everything is public without auth")
+	})
+
 	t.Run("public access", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
 		filtered := svc.FilterKeysForRequest(req, keys)
@@ -1314,7 +1477,7 @@ tokens:
 	})
 
 	t.Run("admin user session", func(t *testing.T) {
-		token, err := svc.CreateSession(t.Context(), "admin")
+		token, err := svc.CreateSession(t.Context(), "admin", false)
 		require.NoError(t, err)
 		req := httptest.NewRequest(http.MethodGet, "/audit/query", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: token})
@@ -1322,7 +1485,7 @@ tokens:
 	})
 
 	t.Run("non-admin user session", func(t *testing.T) {
-		token, err := svc.CreateSession(t.Context(), "regular")
+		token, err := svc.CreateSession(t.Context(), "regular", false)
 		require.NoError(t, err)
 		req := httptest.NewRequest(http.MethodGet, "/audit/query", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: token})
@@ -1347,6 +1510,71 @@ tokens:
 	})
 }
 
+func TestService_RequestCanChange(t *testing.T) {
+	content := `
+users:
+  - name: dev
+    password: "$2a$10$mYptn.gre3pNHlkiErjUkuCqVZgkOjWmSG5JzlKqPESw/TU5dtGB6"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+      - prefix: "shared/*"
+        access: r
+tokens:
+  - token: "writer"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+    scopes: [write]
+  - token: "*"
+    permissions:
+      - prefix: "public/*"
+        access: rw
+    scopes: [write, delete]
+`
+	f := createTempFile(t, content)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil)
+	require.NoError(t, err)
+	session, err := svc.CreateSession(t.Context(), "dev", false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		session string
+		key     string
+		scope   enum.Scope
+		want    bool
+	}{
+		{name: "token writes its prefix", token: "writer", key: "app/cfg", scope: enum.ScopeWrite, want: true},
+		{name: "token can't write other prefix", token: "writer", key: "other/cfg", scope: enum.ScopeWrite},
+		{name: "token without delete scope", token: "writer", key: "app/cfg", scope: enum.ScopeDelete},
+		{name: "user writes its prefix", session: session, key: "app/cfg", scope: enum.ScopeDelete, want: true},
+		{name: "user can't write read-only prefix", session: session, key: "shared/cfg", scope: enum.ScopeWrite},
+		{name: "public writes public prefix", key: "public/cfg", scope: enum.ScopeDelete, want: true},
+		{name: "anonymous can't write other prefix", key: "app/cfg", scope: enum.ScopeWrite},
+		{name: "unknown token", token: "bad", key: "app/cfg", scope: enum.ScopeWrite},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/kv/_txn", http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("X-Auth-Token", tc.token)
+			}
+			if tc.session != "" {
+				req.AddCookie(&http.Cookie{Name: "stash-auth", Value: tc.session})
+			}
+			assert.Equal(t, tc.want, svc.RequestCanChange(req, tc.key, tc.scope))
+		})
+	}
+
+	t.Run("auth disabled", func(t *testing.T) {
+		var nilSvc *Service
+		req := httptest.NewRequest(http.MethodPost, "/kv/_txn", http.NoBody)
+		assert.True(t, nilSvc.RequestCanChange(req, "app/cfg", enum.ScopeDelete))
+	})
+}
+
 func TestService_GetRequestActor(t *testing.T) {
 	content := `
 users:
@@ -1394,7 +1622,7 @@ tokens:
 	})
 
 	t.Run("with session", func(t *testing.T) {
-		token, err := svc.CreateSession(t.Context(), "testuser")
+		token, err := svc.CreateSession(t.Context(), "testuser", false)
 		require.NoError(t, err)
 		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
 		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: token})
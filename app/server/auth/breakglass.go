@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultBreakGlassTTL is the elevation window if break_glass has no ttl
+const defaultBreakGlassTTL = time.Hour
+
+// elevation is an active break-glass elevation of a user
+type elevation struct {
+	reason    string
+	expiresAt time.Time
+}
+
+// parseBreakGlass converts break-glass config of the user to ACL and elevation window.
+func parseBreakGlass(username string, cfg *BreakGlassConfig) (*TokenACL, time.Duration, error) {
+	if len(cfg.Permissions) == 0 {
+		return nil, 0, errors.New("permissions are required")
+	}
+	acl, err := parsePermissionConfigs(username, cfg.Permissions)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid permissions: %w", err)
+	}
+	ttl := defaultBreakGlassTTL
+	if cfg.TTL != "" {
+		if ttl, err = time.ParseDuration(cfg.TTL); err != nil {
+			return nil, 0, fmt.Errorf("invalid ttl %q: %w", cfg.TTL, err)
+		}
+		if ttl <= 0 {
+			return nil, 0, fmt.Errorf("ttl must be positive, got %s", cfg.TTL)
+		}
+	}
+	return &acl, ttl, nil
+}
+
+// BreakGlassAllowed returns true if the user can self-elevate to break-glass permissions.
+func (s *Service) BreakGlassAllowed(username string) bool {
+	if s == nil || !s.Enabled() {
+		return false
+	}
+	s.mu.RLock()
+	user, exists := s.users[username]
+	s.mu.RUnlock()
+	return exists && user.BreakGlass != nil
+}
+
+// BreakGlass elevates the user to break-glass permissions for the configured window and returns
+// the expiration time. The reason is mandatory. Elevating again restarts the window.
+func (s *Service) BreakGlass(username, reason string) (time.Time, error) {
+	if s == nil || !s.Enabled() {
+		return time.Time{}, errors.New("auth is disabled")
+	}
+	if strings.TrimSpace(reason) == "" {
+		return time.Time{}, errors.New("reason is required")
+	}
+	s.mu.RLock()
+	user, exists := s.users[username]
+	s.mu.RUnlock()
+	if !exists || user.BreakGlass == nil {
+		return time.Time{}, fmt.Errorf("break-glass is not allowed for user %q", username)
+	}
+
+	expiresAt := time.Now().Add(user.BreakGlassTTL)
+	s.elevMu.Lock()
+	defer s.elevMu.Unlock()
+	if s.elevations == nil {
+		s.elevations = make(map[string]elevation)
+	}
+	s.elevations[username] = elevation{reason: reason, expiresAt: expiresAt}
+	return expiresAt, nil
+}
+
+// EndBreakGlass drops the break-glass elevation of the user, if any.
+func (s *Service) EndBreakGlass(username string) {
+	if s == nil {
+		return
+	}
+	s.elevMu.Lock()
+	defer s.elevMu.Unlock()
+	delete(s.elevations, username)
+}
+
+// ActiveBreakGlass returns the reason and expiration of the user's break-glass elevation.
+// Returns false if the user is not elevated.
+func (s *Service) ActiveBreakGlass(username string) (reason string, expiresAt time.Time, ok bool) {
+	if s == nil {
+		return "", time.Time{}, false
+	}
+	s.elevMu.Lock()
+	defer s.elevMu.Unlock()
+	el, ok := s.elevations[username]
+	if !ok {
+		return "", time.Time{}, false
+	}
+	if time.Now().After(el.expiresAt) {
+		delete(s.elevations, username)
+		return "", time.Time{}, false
+	}
+	return el.reason, el.expiresAt, true
+}
+
+// breakGlassACL returns break-glass ACL of the user if the user is elevated.
+// the ACL comes from the current config, so removing break_glass on reload ends the elevation.
+func (s *Service) breakGlassACL(user User) (*TokenACL, bool) {
+	if user.BreakGlass == nil {
+		return nil, false
+	}
+	if _, _, ok := s.ActiveBreakGlass(user.Name); !ok {
+		return nil, false
+	}
+	return user.BreakGlass, true
+}
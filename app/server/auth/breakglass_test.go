@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const breakGlassTestConfig = `
+users:
+  - name: oncall
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: r
+    break_glass:
+      ttl: 30m
+      permissions:
+        - prefix: "prod/*"
+          access: rw
+  - name: viewer
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "app/*"
+        access: r
+`
+
+func TestParseBreakGlass(t *testing.T) {
+	acl, ttl, err := parseBreakGlass("u", &BreakGlassConfig{Permissions: []PermissionConfig{{Prefix: "*", Access: "rw"}}})
+	require.NoError(t, err)
+	assert.Equal(t, defaultBreakGlassTTL, ttl)
+	assert.True(t, acl.CheckKeyPermission("any", true))
+
+	_, ttl, err = parseBreakGlass("u", &BreakGlassConfig{TTL: "15m", Permissions: []PermissionConfig{{Prefix: "*", Access: "r"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, ttl)
+
+	tests := []struct {
+		name string
+		cfg  BreakGlassConfig
+		err  string
+	}{
+		{"no permissions", BreakGlassConfig{TTL: "1h"}, "permissions are required"},
+		{"bad access", BreakGlassConfig{Permissions: []PermissionConfig{{Prefix: "*", Access: "x"}}}, "invalid permissions"},
+		{"bad ttl", BreakGlassConfig{TTL: "soon", Permissions: []PermissionConfig{{Prefix: "*", Access: "r"}}}, "invalid ttl"},
+		{"zero ttl", BreakGlassConfig{TTL: "0s", Permissions: []PermissionConfig{{Prefix: "*", Access: "r"}}}, "must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseBreakGlass("u", &tt.cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestService_BreakGlass(t *testing.T) {
+	f := createTempFile(t, breakGlassTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil)
+	require.NoError(t, err)
+
+	assert.True(t, svc.BreakGlassAllowed("oncall"))
+	assert.False(t, svc.BreakGlassAllowed("viewer"))
+	assert.False(t, svc.BreakGlassAllowed("unknown"))
+
+	t.Run("not elevated", func(t *testing.T) {
+		assert.False(t, svc.CheckUserPermission("oncall", "prod/db", true))
+		assert.False(t, svc.UserCanWrite("oncall"))
+		_, _, ok := svc.ActiveBreakGlass("oncall")
+		assert.False(t, ok)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		_, err := svc.BreakGlass("oncall", "  ")
+		require.EqualError(t, err, "reason is required")
+		_, err = svc.BreakGlass("viewer", "outage")
+		require.EqualError(t, err, `break-glass is not allowed for user "viewer"`)
+		assert.False(t, svc.CheckUserPermission("viewer", "prod/db", false))
+	})
+
+	t.Run("elevated", func(t *testing.T) {
+		expiresAt, err := svc.BreakGlass("oncall", "db outage")
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), expiresAt, time.Second)
+
+		reason, until, ok := svc.ActiveBreakGlass("oncall")
+		require.True(t, ok)
+		assert.Equal(t, "db outage", reason)
+		assert.Equal(t, expiresAt, until)
+
+		assert.True(t, svc.CheckUserPermission("oncall", "prod/db", true))
+		assert.True(t, svc.CheckUserPermission("oncall", "app/x", false), "regular permissions kept")
+		assert.False(t, svc.CheckUserPermission("oncall", "app/x", true))
+		assert.True(t, svc.UserCanWrite("oncall"))
+		assert.False(t, svc.IsAdmin("oncall"))
+		assert.Equal(t, []string{"app/x", "prod/db"}, svc.FilterUserKeys("oncall", []string{"app/x", "prod/db"}))
+
+		svc.EndBreakGlass("oncall")
+		assert.False(t, svc.CheckUserPermission("oncall", "prod/db", true))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := svc.BreakGlass("oncall", "db outage")
+		require.NoError(t, err)
+		svc.elevMu.Lock()
+		el := svc.elevations["oncall"]
+		el.expiresAt = time.Now().Add(-time.Second)
+		svc.elevations["oncall"] = el
+		svc.elevMu.Unlock()
+
+		assert.False(t, svc.CheckUserPermission("oncall", "prod/db", true))
+		_, _, ok := svc.ActiveBreakGlass("oncall")
+		assert.False(t, ok)
+	})
+
+	t.Run("removed on reload", func(t *testing.T) {
+		_, err := svc.BreakGlass("oncall", "db outage")
+		require.NoError(t, err)
+		require.True(t, svc.CheckUserPermission("oncall", "prod/db", true))
+
+		cfg := breakGlassTestConfig[:strings.Index(breakGlassTestConfig, "    break_glass:")] +
+			breakGlassTestConfig[strings.Index(breakGlassTestConfig, "  - name: viewer"):]
+		require.NoError(t, os.WriteFile(f, []byte(cfg), 0o600))
+		require.NoError(t, svc.Reload(t.Context()))
+		assert.False(t, svc.CheckUserPermission("oncall", "prod/db", true))
+		assert.False(t, svc.BreakGlassAllowed("oncall"))
+	})
+}
+
+func TestService_BreakGlass_NilService(t *testing.T) {
+	var svc *Service
+	assert.False(t, svc.BreakGlassAllowed("oncall"))
+	_, err := svc.BreakGlass("oncall", "outage")
+	require.Error(t, err)
+	svc.EndBreakGlass("oncall")
+	_, _, ok := svc.ActiveBreakGlass("oncall")
+	assert.False(t, ok)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxCBORDepth limits nesting of decoded CBOR, WebAuthn structures are at most a few levels deep
+const maxCBORDepth = 8
+
+// errCBORShort is returned for CBOR data ending in the middle of an item
+var errCBORShort = errors.New("cbor: unexpected end of data")
+
+// decodeCBOR decodes the first CBOR item of data, as used by WebAuthn attestation objects and COSE keys,
+// and returns it with the number of bytes it takes. Integers are int64, byte strings []byte, text strings
+// string, arrays []any, maps map[any]any keyed by int64 or string. Indefinite lengths, tags and floats
+// are not supported, authenticators don't use them in these structures.
+func decodeCBOR(data []byte) (any, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, int, error) {
+	if depth > maxCBORDepth {
+		return nil, 0, errors.New("cbor: nesting too deep")
+	}
+	if len(data) == 0 {
+		return nil, 0, errCBORShort
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22, 23:
+			return nil, 1, nil
+		default:
+			return nil, 0, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+	arg, n, err := cborArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errCBORShort
+		}
+		end := n + int(arg)
+		if major == 2 {
+			return append([]byte{}, data[n:end]...), end, nil
+		}
+		return string(data[n:end]), end, nil
+	case 4:
+		if arg > uint64(len(data)) { // every item takes at least a byte
+			return nil, 0, errCBORShort
+		}
+		arr := make([]any, 0, arg)
+		for range arg {
+			item, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, item)
+			n += size
+		}
+		return arr, n, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, 0, errCBORShort
+		}
+		m := make(map[any]any, arg)
+		for range arg {
+			key, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += size
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			val, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += size
+			m[key] = val
+		}
+		return m, n, nil
+	default:
+		return nil, 0, fmt.Errorf("cbor: unsupported major type %d", major)
+	}
+}
+
+// cborArgument returns the argument of an item head and the head size.
+func cborArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24 && len(data) >= 2:
+		return uint64(data[1]), 2, nil
+	case info == 25 && len(data) >= 3:
+		return uint64(binary.BigEndian.Uint16(data[1:])), 3, nil
+	case info == 26 && len(data) >= 5:
+		return uint64(binary.BigEndian.Uint32(data[1:])), 5, nil
+	case info == 27 && len(data) >= 9:
+		return binary.BigEndian.Uint64(data[1:]), 9, nil
+	case info <= 27:
+		return 0, 0, errCBORShort
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tbl := []struct {
+		name string
+		data []byte
+		want any
+		size int
+	}{
+		{"small int", []byte{0x17}, int64(23), 1},
+		{"one byte int", []byte{0x18, 0xff}, int64(255), 2},
+		{"two byte int", []byte{0x19, 0x01, 0x00}, int64(256), 3},
+		{"negative int", []byte{0x26}, int64(-7), 1},
+		{"negative two byte int", []byte{0x39, 0x01, 0x00}, int64(-257), 3},
+		{"byte string", []byte{0x43, 1, 2, 3}, []byte{1, 2, 3}, 4},
+		{"text string", []byte{0x63, 'f', 'm', 't'}, "fmt", 4},
+		{"array", []byte{0x82, 0x01, 0x61, 'a'}, []any{int64(1), "a"}, 4},
+		{"map", []byte{0xa2, 0x01, 0x02, 0x20, 0x40}, map[any]any{int64(1): int64(2), int64(-1): []byte{}}, 5},
+		{"simple values", []byte{0xf5}, true, 1},
+		{"trailing data", []byte{0x01, 0x02}, int64(1), 1},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			got, size, err := decodeCBOR(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.size, size)
+		})
+	}
+}
+
+func TestDecodeCBOR_Errors(t *testing.T) {
+	deep := make([]byte, 0, 20)
+	for range 20 {
+		deep = append(deep, 0x81)
+	}
+	deep = append(deep, 0x01)
+
+	tbl := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "unexpected end"},
+		{"short argument", []byte{0x19, 0x01}, "unexpected end"},
+		{"short string", []byte{0x45, 1, 2}, "unexpected end"},
+		{"huge array", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "unexpected end"},
+		{"short map", []byte{0xa1, 0x01}, "unexpected end"},
+		{"indefinite length", []byte{0x5f, 0x41, 0x01, 0xff}, "unsupported additional info"},
+		{"tag", []byte{0xc1, 0x01}, "unsupported major type"},
+		{"float", []byte{0xf9, 0x3c, 0x00}, "unsupported simple value"},
+		{"array map key", []byte{0xa1, 0x80, 0x01}, "unsupported map key"},
+		{"integer overflow", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "integer overflow"},
+		{"too deep", deep, "nesting too deep"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeCBOR(tt.data)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+)
+
+// cloudSubjectPrefix is the "sub" claim prefix of tokens minted by cloud logins, followed by the role key
+const cloudSubjectPrefix = "cloud:"
+
+// signingKeysTTL is how long fetched Google and GitHub signing keys are used before refreshing them
+const signingKeysTTL = time.Hour
+
+// default endpoints of cloud identity verification
+const (
+	DefaultSTSURL        = "https://sts.amazonaws.com/"
+	DefaultGCPCertsURL   = "https://www.googleapis.com/oauth2/v3/certs"
+	DefaultGitHubKeysURL = "https://token.actions.githubusercontent.com/.well-known/jwks"
+	githubActionsIssuer  = "https://token.actions.githubusercontent.com"
+)
+
+// ServerIDHeader is the header AWS logins must sign with the configured server id, so a signed request
+// made for one server can't be replayed to another one.
+const ServerIDHeader = "X-Stash-Server-Id"
+
+// CloudAuth configures logins with cloud identities.
+type CloudAuth struct {
+	AWS         bool   // enables AWS IAM logins with signed sts:GetCallerIdentity requests
+	STSURL      string // STS endpoint the signed requests must target, DefaultSTSURL if empty
+	ServerID    string // value of ServerIDHeader AWS logins must sign, required with AWS
+	GCPAudience string // audience of GCP identity tokens, empty disables GCP logins
+	GCPCertsURL string // Google signing keys (JWKS), DefaultGCPCertsURL if empty
+
+	GitHubAudience string // audience of GitHub Actions OIDC tokens, empty disables GitHub logins
+	GitHubKeysURL  string // GitHub Actions signing keys (JWKS), DefaultGitHubKeysURL if empty
+
+	Client *http.Client // client calling STS and fetching signing keys, 10s timeout client if nil
+}
+
+// CloudLoginRequest is the body of the cloud login request, with aws or gcp part for the provider.
+type CloudLoginRequest struct {
+	Provider string `json:"provider"`
+	TTL      string `json:"ttl,omitempty"` // lifetime as go duration, e.g. "15m"
+	AWS      struct {
+		URL     string              `json:"url"`
+		Headers map[string][]string `json:"headers"`
+		Body    string              `json:"body"`
+	} `json:"aws"`
+	GCP struct {
+		Token string `json:"token"` // identity token of the service account
+	} `json:"gcp"`
+	GitHub struct {
+		Token string `json:"token"` // OIDC token of the workflow job
+	} `json:"github"`
+}
+
+// errCloudIdentity is returned when the cloud identity can't be verified
+var errCloudIdentity = errors.New("cloud identity not verified")
+
+// cloudRole is the ACL of a cloud principal, or of all principals with a prefix for wildcard entries.
+type cloudRole struct {
+	key       string // provider:principal as configured, identifies the role in minted tokens
+	provider  string
+	principal string // principal, wildcard entries keep the prefix without trailing "*"
+	wildcard  bool
+	acl       TokenACL
+}
+
+// cloudVerifier verifies cloud identities and keeps fetched signing keys.
+type cloudVerifier struct {
+	CloudAuth
+	mu         sync.Mutex
+	gcpKeys    signingKeys
+	githubKeys signingKeys
+}
+
+// signingKeys are the keys of a JWKS endpoint with the time they were fetched.
+type signingKeys struct {
+	url       string
+	keys      jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// WithCloudAuth enables logins with cloud identities, mapped to the "cloud_roles" of the auth config.
+// Logins get minted tokens, so token exchange must be enabled as well.
+func WithCloudAuth(cfg CloudAuth) Option {
+	return func(s *Service) {
+		if cfg.STSURL == "" {
+			cfg.STSURL = DefaultSTSURL
+		}
+		if cfg.GCPCertsURL == "" {
+			cfg.GCPCertsURL = DefaultGCPCertsURL
+		}
+		if cfg.GitHubKeysURL == "" {
+			cfg.GitHubKeysURL = DefaultGitHubKeysURL
+		}
+		if cfg.Client == nil {
+			cfg.Client = &http.Client{Timeout: 10 * time.Second}
+		}
+		s.cloud = &cloudVerifier{CloudAuth: cfg, gcpKeys: signingKeys{url: cfg.GCPCertsURL},
+			githubKeys: signingKeys{url: cfg.GitHubKeysURL}}
+	}
+}
+
+// CloudEnabled returns true if clients can log in with cloud identities.
+func (s *Service) CloudEnabled() bool {
+	return s.ExchangeEnabled() && s.cloud != nil && (s.cloud.AWS || s.cloud.GCPAudience != "" || s.cloud.GitHubAudience != "")
+}
+
+// HandleCloudLogin verifies the cloud identity of the request and returns a minted token with the ACL
+// of the matching cloud role. POST /auth/cloud with CloudLoginRequest body.
+func (s *Service) HandleCloudLogin(w http.ResponseWriter, r *http.Request) {
+	var req CloudLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	ttl, err := s.exchangeTTL(req.TTL)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	var principal string
+	switch {
+	case req.Provider == "aws" && s.cloud.AWS:
+		principal, err = s.cloud.awsPrincipal(r.Context(), req)
+	case req.Provider == "gcp" && s.cloud.GCPAudience != "":
+		principal, err = s.cloud.gcpPrincipal(r.Context(), req.GCP.Token)
+	case req.Provider == "github" && s.cloud.GitHubAudience != "":
+		principal, err = s.cloud.githubPrincipal(r.Context(), req.GitHub.Token)
+	default:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, fmt.Sprintf("unsupported provider %q", req.Provider))
+		return
+	}
+	if err != nil {
+		log.Printf("[INFO] %s login rejected: %v", req.Provider, err)
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, errCloudIdentity.Error())
+		return
+	}
+
+	role, ok := s.matchCloudRole(req.Provider, principal)
+	if !ok {
+		log.Printf("[INFO] %s principal %q has no cloud role", req.Provider, principal)
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "no cloud role for the principal")
+		return
+	}
+	resp, err := s.mintToken(cloudSubjectPrefix+role.key, exchangeClaims{Principal: req.Provider + ":" + principal}, ttl)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to make token")
+		return
+	}
+	log.Printf("[INFO] %s principal %q logged in with cloud role %q", req.Provider, principal, role.key)
+	rest.RenderJSON(w, resp)
+}
+
+// matchCloudRole returns the role of the provider's principal, the longest matching principal wins.
+func (s *Service) matchCloudRole(provider, principal string) (cloudRole, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, role := range s.cloudRoles {
+		if role.provider != provider {
+			continue
+		}
+		if role.principal == principal || role.wildcard && strings.HasPrefix(principal, role.principal) {
+			return role, true
+		}
+	}
+	return cloudRole{}, false
+}
+
+// cloudRoleACL returns the ACL of the role with the key.
+func (s *Service) cloudRoleACL(key string) (TokenACL, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, role := range s.cloudRoles {
+		if role.key == key {
+			return role.acl, true
+		}
+	}
+	return TokenACL{}, false
+}
+
+// awsPrincipal verifies the signed sts:GetCallerIdentity request by sending it to STS, and returns the
+// caller ARN. Assumed role sessions are reported as their role ARN.
+func (c *cloudVerifier) awsPrincipal(ctx context.Context, req CloudLoginRequest) (string, error) {
+	if req.AWS.URL != c.STSURL {
+		return "", fmt.Errorf("request url %q is not the sts endpoint", req.AWS.URL)
+	}
+	params, err := url.ParseQuery(req.AWS.Body)
+	if err != nil || params.Get("Action") != "GetCallerIdentity" || len(params) != 2 || params.Get("Version") == "" {
+		return "", errors.New("request body is not a GetCallerIdentity call")
+	}
+	headers := http.Header(req.AWS.Headers)
+	authHeader := headers.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "AWS4-HMAC-SHA256 ") {
+		return "", errors.New("request is not signed with sigv4")
+	}
+	if c.ServerID == "" || headers.Get(ServerIDHeader) != c.ServerID ||
+		!slices.Contains(signedHeaders(authHeader), strings.ToLower(ServerIDHeader)) {
+		return "", errors.New("request has no signed server id")
+	}
+
+	stsReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.STSURL, strings.NewReader(req.AWS.Body))
+	if err != nil {
+		return "", fmt.Errorf("failed to make sts request: %w", err)
+	}
+	for k, vv := range headers {
+		if strings.EqualFold(k, "Host") {
+			stsReq.Host = headers.Get(k)
+			continue
+		}
+		for _, v := range vv {
+			stsReq.Header.Add(k, v)
+		}
+	}
+	resp, err := c.Client.Do(stsReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to call sts: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read sts response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sts responded with status %d", resp.StatusCode)
+	}
+	var identity struct {
+		Arn string `xml:"GetCallerIdentityResult>Arn"`
+	}
+	if err = xml.Unmarshal(body, &identity); err != nil || identity.Arn == "" {
+		return "", errors.New("no caller arn in sts response")
+	}
+	return canonicalARN(identity.Arn), nil
+}
+
+// gcpPrincipal verifies the Google-signed identity token of a service account and returns its email.
+func (c *cloudVerifier) gcpPrincipal(ctx context.Context, token string) (string, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != string(jose.RS256) {
+		return "", errors.New("token is not a RS256 jwt")
+	}
+	key, err := c.signingKey(ctx, &c.gcpKeys, parsed.Headers[0].KeyID)
+	if err != nil {
+		return "", err
+	}
+	var claims jwt.Claims
+	var identity struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err = parsed.Claims(key, &claims, &identity); err != nil {
+		return "", fmt.Errorf("bad token signature: %w", err)
+	}
+	if claims.Issuer != "https://accounts.google.com" && claims.Issuer != "accounts.google.com" {
+		return "", fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if err = claims.ValidateWithLeeway(jwt.Expected{Audience: jwt.Audience{c.GCPAudience}, Time: time.Now()}, time.Minute); err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return "", errors.New("token has no verified email")
+	}
+	return identity.Email, nil
+}
+
+// githubPrincipal verifies the OIDC token of a GitHub Actions job and returns its subject,
+// e.g. repo:org/repo:ref:refs/heads/main or repo:org/repo:environment:prod.
+func (c *cloudVerifier) githubPrincipal(ctx context.Context, token string) (string, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != string(jose.RS256) {
+		return "", errors.New("token is not a RS256 jwt")
+	}
+	key, err := c.signingKey(ctx, &c.githubKeys, parsed.Headers[0].KeyID)
+	if err != nil {
+		return "", err
+	}
+	var claims jwt.Claims
+	if err = parsed.Claims(key, &claims); err != nil {
+		return "", fmt.Errorf("bad token signature: %w", err)
+	}
+	expected := jwt.Expected{Issuer: githubActionsIssuer, Audience: jwt.Audience{c.GitHubAudience}, Time: time.Now()}
+	if err = claims.ValidateWithLeeway(expected, time.Minute); err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// signingKey returns the signing key with the id, refreshing the keys when they are stale
+// or the key is unknown, as Google and GitHub rotate them.
+func (c *cloudVerifier) signingKey(ctx context.Context, set *signingKeys, kid string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if keys := set.keys.Key(kid); len(keys) > 0 && time.Since(set.fetchedAt) < signingKeysTTL {
+		return keys[0].Key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, set.url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make signing keys request: %w", err)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing keys endpoint responded with status %d", resp.StatusCode)
+	}
+	var keys jose.JSONWebKeySet
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("failed to decode signing keys: %w", err)
+	}
+	set.keys, set.fetchedAt = keys, time.Now()
+	if found := keys.Key(kid); len(found) > 0 {
+		return found[0].Key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// parseCloudRoleConfigs converts CloudRoleConfig slice to cloud roles, sorted for longest match first.
+func parseCloudRoleConfigs(configs []CloudRoleConfig) ([]cloudRole, error) {
+	res := make([]cloudRole, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, rc := range configs {
+		if rc.Provider != "aws" && rc.Provider != "gcp" && rc.Provider != "github" {
+			return nil, fmt.Errorf("unknown provider %q of cloud role %q", rc.Provider, rc.Principal)
+		}
+		principal, wildcard := strings.CutSuffix(rc.Principal, "*")
+		if principal == "" {
+			return nil, fmt.Errorf("empty principal of %s cloud role", rc.Provider)
+		}
+		key := rc.Provider + ":" + rc.Principal
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate cloud role %q", key)
+		}
+		seen[key] = true
+
+		acl, err := parsePermissionConfigs(key, rc.Permissions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permissions for cloud role %q: %w", key, err)
+		}
+		acl.Admin = rc.Admin
+		if acl.scopes, err = parseScopes(rc.Scopes); err != nil {
+			return nil, fmt.Errorf("invalid scopes for cloud role %q: %w", key, err)
+		}
+		res = append(res, cloudRole{key: key, provider: rc.Provider, principal: principal, wildcard: wildcard, acl: acl})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if len(res[i].principal) != len(res[j].principal) {
+			return len(res[i].principal) > len(res[j].principal)
+		}
+		return !res[i].wildcard && res[j].wildcard
+	})
+	return res, nil
+}
+
+// canonicalARN converts the ARN of an assumed role session, arn:aws:sts::123:assumed-role/name/session,
+// to the role ARN, arn:aws:iam::123:role/name. Other ARNs are returned as is.
+func canonicalARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+	resource := strings.Split(strings.TrimPrefix(parts[5], "assumed-role/"), "/")
+	if len(resource) < 2 {
+		return arn
+	}
+	return fmt.Sprintf("%s:%s:iam::%s:role/%s", parts[0], parts[1], parts[4], resource[0])
+}
+
+// signedHeaders returns the lowercase names of headers covered by a sigv4 Authorization header.
+func signedHeaders(authHeader string) []string {
+	for _, part := range strings.Split(authHeader, ",") {
+		if list, ok := strings.CutPrefix(strings.TrimSpace(part), "SignedHeaders="); ok {
+			return strings.Split(list, ";")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cloudTestConfig = `
+cloud_roles:
+  - provider: aws
+    principal: "arn:aws:iam::123456789012:role/ci-*"
+    permissions:
+      - prefix: "ci/*"
+        access: r
+  - provider: aws
+    principal: "arn:aws:iam::123456789012:role/ci-deployer"
+    permissions:
+      - prefix: "ci/*"
+        access: rw
+  - provider: gcp
+    principal: "reader@proj.iam.gserviceaccount.com"
+    permissions:
+      - prefix: "gke/*"
+        access: r
+  - provider: github
+    principal: "repo:org/app:ref:refs/heads/*"
+    permissions:
+      - prefix: "deploy/app/*"
+        access: rw
+`
+
+const stsResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>%s</Arn>
+    <UserId>AROAEXAMPLE:session</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+</GetCallerIdentityResponse>`
+
+func TestParseCloudRoleConfigs(t *testing.T) {
+	roles, err := parseCloudRoleConfigs([]CloudRoleConfig{
+		{Provider: "aws", Principal: "arn:aws:iam::1:role/*"},
+		{Provider: "aws", Principal: "arn:aws:iam::1:role/ci"},
+		{Provider: "gcp", Principal: "sa@p.iam.gserviceaccount.com"},
+	})
+	require.NoError(t, err)
+	keys := make([]string, 0, len(roles))
+	for _, r := range roles {
+		keys = append(keys, r.key)
+	}
+	assert.Equal(t, []string{"gcp:sa@p.iam.gserviceaccount.com", "aws:arn:aws:iam::1:role/ci", "aws:arn:aws:iam::1:role/*"}, keys)
+
+	_, err = parseCloudRoleConfigs([]CloudRoleConfig{{Provider: "azure", Principal: "x"}})
+	require.EqualError(t, err, `unknown provider "azure" of cloud role "x"`)
+	_, err = parseCloudRoleConfigs([]CloudRoleConfig{{Provider: "aws", Principal: "*"}})
+	require.EqualError(t, err, "empty principal of aws cloud role")
+	_, err = parseCloudRoleConfigs([]CloudRoleConfig{{Provider: "gcp", Principal: "a"}, {Provider: "gcp", Principal: "a"}})
+	require.EqualError(t, err, `duplicate cloud role "gcp:a"`)
+}
+
+func TestCanonicalARN(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:sts::123456789012:assumed-role/ci-deployer/i-0abc": "arn:aws:iam::123456789012:role/ci-deployer",
+		"arn:aws-cn:sts::123:assumed-role/r/s":                      "arn:aws-cn:iam::123:role/r",
+		"arn:aws:iam::123456789012:user/alice":                      "arn:aws:iam::123456789012:user/alice",
+		"arn:aws:sts::123:assumed-role/no-session":                  "arn:aws:sts::123:assumed-role/no-session",
+		"garbage": "garbage",
+	}
+	for arn, want := range tests {
+		assert.Equal(t, want, canonicalARN(arn), arn)
+	}
+}
+
+func TestService_HandleCloudLogin_AWS(t *testing.T) {
+	callerARN := "arn:aws:sts::123456789012:assumed-role/ci-deployer/i-0abc"
+	var stsHeaders http.Header
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stsHeaders = r.Header.Clone()
+		if r.Header.Get("Authorization") == "AWS4-HMAC-SHA256 Credential=bad, SignedHeaders=host;x-stash-server-id, Signature=x" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "Action=GetCallerIdentity&Version=2011-06-15", string(body))
+		_, _ = w.Write([]byte(strings.ReplaceAll(stsResponse, "%s", callerARN)))
+	}))
+	defer sts.Close()
+
+	f := createTempFile(t, cloudTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour),
+		WithCloudAuth(CloudAuth{AWS: true, STSURL: sts.URL + "/", ServerID: "stash.example"}))
+	require.NoError(t, err)
+	require.True(t, svc.CloudEnabled())
+
+	login := func(t *testing.T, mod func(req *CloudLoginRequest)) *httptest.ResponseRecorder {
+		t.Helper()
+		req := CloudLoginRequest{Provider: "aws"}
+		req.AWS.URL = sts.URL + "/"
+		req.AWS.Body = "Action=GetCallerIdentity&Version=2011-06-15"
+		req.AWS.Headers = map[string][]string{
+			"Authorization":     {"AWS4-HMAC-SHA256 Credential=AKIA/20250115/us-east-1/sts/aws4_request, SignedHeaders=host;x-stash-server-id, Signature=abc"},
+			"X-Stash-Server-Id": {"stash.example"},
+			"X-Amz-Date":        {"20250115T100000Z"},
+		}
+		if mod != nil {
+			mod(&req)
+		}
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		svc.HandleCloudLogin(rec, httptest.NewRequest(http.MethodPost, "/auth/cloud", strings.NewReader(string(body))))
+		return rec
+	}
+
+	t.Run("assumed role logs in", func(t *testing.T) {
+		rec := login(t, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "20250115T100000Z", stsHeaders.Get("X-Amz-Date"), "signed headers forwarded")
+		var resp ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("ci/app", true), "exact role wins over wildcard")
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/ci/app", http.NoBody)
+		req.Header.Set("X-Auth-Token", resp.Token)
+		actorType, actorName := svc.GetRequestActor(req)
+		assert.Equal(t, "token", actorType)
+		assert.Equal(t, "aws:arn:aws:iam::123456789012:role/ci-deployer", actorName)
+	})
+
+	t.Run("wildcard role", func(t *testing.T) {
+		callerARN = "arn:aws:sts::123456789012:assumed-role/ci-runner/i-0abc"
+		defer func() { callerARN = "arn:aws:sts::123456789012:assumed-role/ci-deployer/i-0abc" }()
+		rec := login(t, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("ci/app", false))
+		assert.False(t, acl.CheckKeyPermission("ci/app", true))
+	})
+
+	t.Run("no role for principal", func(t *testing.T) {
+		callerARN = "arn:aws:iam::123456789012:user/alice"
+		defer func() { callerARN = "arn:aws:sts::123456789012:assumed-role/ci-deployer/i-0abc" }()
+		assert.Equal(t, http.StatusForbidden, login(t, nil).Code)
+	})
+
+	t.Run("rejected requests", func(t *testing.T) {
+		tests := map[string]func(req *CloudLoginRequest){
+			"other url":       func(req *CloudLoginRequest) { req.AWS.URL = "https://evil.example/" },
+			"other action":    func(req *CloudLoginRequest) { req.AWS.Body = "Action=AssumeRole&Version=2011-06-15" },
+			"extra params":    func(req *CloudLoginRequest) { req.AWS.Body += "&RoleArn=x" },
+			"not signed":      func(req *CloudLoginRequest) { req.AWS.Headers["Authorization"] = []string{"Bearer x"} },
+			"wrong server id": func(req *CloudLoginRequest) { req.AWS.Headers["X-Stash-Server-Id"] = []string{"other"} },
+			"server id unsigned": func(req *CloudLoginRequest) {
+				req.AWS.Headers["Authorization"] = []string{"AWS4-HMAC-SHA256 Credential=a, SignedHeaders=host, Signature=x"}
+			},
+			"sts rejects": func(req *CloudLoginRequest) {
+				req.AWS.Headers["Authorization"] = []string{"AWS4-HMAC-SHA256 Credential=bad, SignedHeaders=host;x-stash-server-id, Signature=x"}
+			},
+		}
+		for name, mod := range tests {
+			assert.Equal(t, http.StatusUnauthorized, login(t, mod).Code, name)
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, login(t, func(req *CloudLoginRequest) { req.Provider = "gcp" }).Code, "gcp disabled")
+		assert.Equal(t, http.StatusBadRequest, login(t, func(req *CloudLoginRequest) { req.TTL = "-1m" }).Code)
+	})
+
+	t.Run("removed role revokes tokens", func(t *testing.T) {
+		rec := login(t, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.True(t, svc.hasTokenACL(resp.Token))
+
+		require.NoError(t, os.WriteFile(f, []byte(strings.ReplaceAll(cloudTestConfig, "role/ci-deployer", "role/ci-other")), 0o600))
+		require.NoError(t, svc.Reload(t.Context()))
+		assert.False(t, svc.hasTokenACL(resp.Token))
+	})
+}
+
+func TestService_HandleCloudLogin_GCP(t *testing.T) {
+	googleKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	certs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &googleKey.PublicKey, KeyID: "key1", Algorithm: "RS256", Use: "sig"}}})
+	}))
+	defer certs.Close()
+
+	f := createTempFile(t, cloudTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour),
+		WithCloudAuth(CloudAuth{GCPAudience: "https://stash.example", GCPCertsURL: certs.URL}))
+	require.NoError(t, err)
+
+	idToken := func(t *testing.T, kid, aud, email string, verified bool) string {
+		t.Helper()
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: googleKey},
+			(&jose.SignerOptions{}).WithHeader("kid", kid))
+		require.NoError(t, err)
+		claims := jwt.Claims{Issuer: "https://accounts.google.com", Audience: jwt.Audience{aud},
+			Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour)), IssuedAt: jwt.NewNumericDate(time.Now())}
+		token, err := jwt.Signed(signer).Claims(claims).
+			Claims(map[string]any{"email": email, "email_verified": verified}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	login := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{"provider": "gcp", "gcp": map[string]string{"token": token}})
+		rec := httptest.NewRecorder()
+		svc.HandleCloudLogin(rec, httptest.NewRequest(http.MethodPost, "/auth/cloud", strings.NewReader(string(body))))
+		return rec
+	}
+
+	t.Run("service account logs in", func(t *testing.T) {
+		rec := login(idToken(t, "key1", "https://stash.example", "reader@proj.iam.gserviceaccount.com", true))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("gke/cfg", false))
+		assert.False(t, acl.CheckKeyPermission("ci/app", false))
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized,
+			login(idToken(t, "key1", "https://other.example", "reader@proj.iam.gserviceaccount.com", true)).Code, "audience")
+		assert.Equal(t, http.StatusUnauthorized,
+			login(idToken(t, "key1", "https://stash.example", "reader@proj.iam.gserviceaccount.com", false)).Code, "unverified")
+		assert.Equal(t, http.StatusUnauthorized,
+			login(idToken(t, "key2", "https://stash.example", "reader@proj.iam.gserviceaccount.com", true)).Code, "unknown key")
+		assert.Equal(t, http.StatusUnauthorized, login("not.a.jwt").Code)
+		assert.Equal(t, http.StatusForbidden,
+			login(idToken(t, "key1", "https://stash.example", "other@proj.iam.gserviceaccount.com", true)).Code, "no role")
+	})
+}
+
+func TestService_HandleCloudLogin_GitHub(t *testing.T) {
+	githubKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var fetches atomic.Int32
+	keys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &githubKey.PublicKey, KeyID: "gh1", Algorithm: "RS256", Use: "sig"}}})
+	}))
+	defer keys.Close()
+
+	f := createTempFile(t, cloudTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour),
+		WithCloudAuth(CloudAuth{GitHubAudience: "stash", GitHubKeysURL: keys.URL}))
+	require.NoError(t, err)
+	assert.True(t, svc.CloudEnabled())
+
+	oidcToken := func(t *testing.T, iss, aud, sub string) string {
+		t.Helper()
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: githubKey},
+			(&jose.SignerOptions{}).WithHeader("kid", "gh1"))
+		require.NoError(t, err)
+		claims := jwt.Claims{Issuer: iss, Audience: jwt.Audience{aud}, Subject: sub,
+			Expiry: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)), IssuedAt: jwt.NewNumericDate(time.Now())}
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	login := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{"provider": "github", "github": map[string]string{"token": token}})
+		rec := httptest.NewRecorder()
+		svc.HandleCloudLogin(rec, httptest.NewRequest(http.MethodPost, "/auth/cloud", strings.NewReader(string(body))))
+		return rec
+	}
+
+	t.Run("workflow on a branch logs in", func(t *testing.T) {
+		rec := login(oidcToken(t, githubActionsIssuer, "stash", "repo:org/app:ref:refs/heads/main"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("deploy/app/version", true))
+		assert.False(t, acl.CheckKeyPermission("deploy/other/version", false))
+
+		rec = login(oidcToken(t, githubActionsIssuer, "stash", "repo:org/app:ref:refs/heads/dev"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, int32(1), fetches.Load(), "signing keys cached")
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized,
+			login(oidcToken(t, githubActionsIssuer, "other", "repo:org/app:ref:refs/heads/main")).Code, "audience")
+		assert.Equal(t, http.StatusUnauthorized,
+			login(oidcToken(t, "https://accounts.google.com", "stash", "repo:org/app:ref:refs/heads/main")).Code, "issuer")
+		assert.Equal(t, http.StatusUnauthorized, login(oidcToken(t, githubActionsIssuer, "stash", "")).Code, "no subject")
+		assert.Equal(t, http.StatusForbidden,
+			login(oidcToken(t, githubActionsIssuer, "stash", "repo:org/app:pull_request")).Code, "no role")
+		assert.Equal(t, http.StatusForbidden,
+			login(oidcToken(t, githubActionsIssuer, "stash", "repo:org/other:ref:refs/heads/main")).Code, "other repo")
+	})
+
+	t.Run("github disabled", func(t *testing.T) {
+		other, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour),
+			WithCloudAuth(CloudAuth{GCPAudience: "https://stash.example"}))
+		require.NoError(t, err)
+		body := `{"provider":"github","github":{"token":"x"}}`
+		rec := httptest.NewRecorder()
+		other.HandleCloudLogin(rec, httptest.NewRequest(http.MethodPost, "/auth/cloud", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
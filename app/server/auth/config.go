@@ -2,9 +2,12 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -17,8 +20,12 @@ import (
 
 // Config represents the auth configuration file (stash-auth.yml).
 type Config struct {
-	Users  []UserConfig  `yaml:"users,omitempty" json:"users,omitempty" jsonschema:"description=users for web UI auth"`
-	Tokens []TokenConfig `yaml:"tokens,omitempty" json:"tokens,omitempty" jsonschema:"description=API tokens"`
+	Users      []UserConfig      `yaml:"users,omitempty" json:"users,omitempty" jsonschema:"description=users for web UI auth"`
+	Tokens     []TokenConfig     `yaml:"tokens,omitempty" json:"tokens,omitempty" jsonschema:"description=API tokens"`
+	Workloads  []WorkloadConfig  `yaml:"workloads,omitempty" json:"workloads,omitempty" jsonschema:"description=SPIFFE workload identities for API auth with mTLS"`
+	CloudRoles []CloudRoleConfig `yaml:"cloud_roles,omitempty" json:"cloud_roles,omitempty" jsonschema:"description=cloud identities (AWS IAM, GCP service accounts) allowed to log in"`
+
+	checksum string // sha256 of the file the config was loaded from
 }
 
 // UserConfig represents a user in the auth config file.
@@ -26,14 +33,43 @@ type UserConfig struct {
 	Name        string             `yaml:"name" json:"name" jsonschema:"required"`
 	Password    string             `yaml:"password" json:"password" jsonschema:"required"` // bcrypt hash
 	Admin       bool               `yaml:"admin,omitempty" json:"admin,omitempty" jsonschema:"description=grants admin privileges (audit access)"`
+	PrefixAdmin []string           `yaml:"prefix_admin,omitempty" json:"prefix_admin,omitempty" jsonschema:"description=key prefixes the user manages delegated tokens under"`
+	Email       string             `yaml:"email,omitempty" json:"email,omitempty" jsonschema:"description=address notified on login from a new device"`
 	Permissions []PermissionConfig `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	BreakGlass  *BreakGlassConfig  `yaml:"break_glass,omitempty" json:"break_glass,omitempty" jsonschema:"description=emergency access the user can self-elevate to for a limited time"`
+}
+
+// BreakGlassConfig defines the emergency access of a user, granted on top of the regular permissions
+// for a time-boxed window after the user states a reason.
+type BreakGlassConfig struct {
+	Permissions []PermissionConfig `yaml:"permissions" json:"permissions" jsonschema:"required"`
+	TTL         string             `yaml:"ttl,omitempty" json:"ttl,omitempty" jsonschema:"description=max elevation window as go duration (default 1h)"`
 }
 
 // TokenConfig represents an API token in the auth config file.
 type TokenConfig struct {
 	Token       string             `yaml:"token" json:"token" jsonschema:"required"`
 	Admin       bool               `yaml:"admin,omitempty" json:"admin,omitempty" jsonschema:"description=grants admin privileges (audit access)"`
+	PrefixAdmin []string           `yaml:"prefix_admin,omitempty" json:"prefix_admin,omitempty" jsonschema:"description=key prefixes the token manages delegated tokens under"`
 	Permissions []PermissionConfig `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	Scopes      []string           `yaml:"scopes,omitempty" json:"scopes,omitempty" jsonschema:"description=operations allowed to the token on top of prefix permissions (all if empty),enum=list,enum=read,enum=write,enum=delete,enum=history,enum=export"`
+}
+
+// WorkloadConfig represents a SPIFFE workload identity in the auth config file.
+type WorkloadConfig struct {
+	SpiffeID    string             `yaml:"spiffe_id" json:"spiffe_id" jsonschema:"required,description=SPIFFE ID or path prefix ending with /* matching all IDs under it"`
+	Admin       bool               `yaml:"admin,omitempty" json:"admin,omitempty" jsonschema:"description=grants admin privileges (audit access)"`
+	Permissions []PermissionConfig `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	Scopes      []string           `yaml:"scopes,omitempty" json:"scopes,omitempty" jsonschema:"description=operations allowed to the workload on top of prefix permissions (all if empty),enum=list,enum=read,enum=write,enum=delete,enum=history,enum=export"`
+}
+
+// CloudRoleConfig maps cloud principals to an ACL in the auth config file.
+type CloudRoleConfig struct {
+	Provider    string             `yaml:"provider" json:"provider" jsonschema:"required,enum=aws,enum=gcp,enum=github"`
+	Principal   string             `yaml:"principal" json:"principal" jsonschema:"required,description=AWS IAM ARN or GCP service account email or GitHub Actions OIDC subject, trailing * matches any suffix"`
+	Admin       bool               `yaml:"admin,omitempty" json:"admin,omitempty" jsonschema:"description=grants admin privileges (audit access)"`
+	Permissions []PermissionConfig `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	Scopes      []string           `yaml:"scopes,omitempty" json:"scopes,omitempty" jsonschema:"description=operations allowed to the role on top of prefix permissions (all if empty),enum=list,enum=read,enum=write,enum=delete,enum=history,enum=export"`
 }
 
 // PermissionConfig represents a prefix-permission pair in the config file.
@@ -44,27 +80,53 @@ type PermissionConfig struct {
 
 // User represents an authenticated user with ACL.
 type User struct {
-	Name         string
-	PasswordHash string
-	Admin        bool     // grants admin privileges (audit access)
-	ACL          TokenACL // reuse ACL structure for permissions
+	Name          string
+	PasswordHash  string
+	Admin         bool          // grants admin privileges (audit access)
+	PrefixAdmin   []string      // prefixes the user manages delegated tokens under
+	Email         string        // address for login notifications, empty if not set
+	ACL           TokenACL      // reuse ACL structure for permissions
+	BreakGlass    *TokenACL     // emergency permissions the user can self-elevate to, nil if not allowed
+	BreakGlassTTL time.Duration // max break-glass elevation window
 }
 
 // TokenACL defines access control for an API token.
 type TokenACL struct {
 	Token    string
 	Admin    bool         // grants admin privileges (audit access)
+	manages  []string     // prefixes the token manages delegated tokens under, named tokens only
 	prefixes []prefixPerm // sorted by prefix length descending for longest-match-first
+	scopes   []enum.Scope // allowed operations, nil allows all operations
+	parent   *TokenACL    // ACL of the parent token for exchanged tokens, access must be allowed by both
+	actor    string       // actor name for logs and audit of minted tokens, empty for named tokens
 }
 
 // SessionStore is the interface for persistent session storage.
 type SessionStore interface {
-	CreateSession(ctx context.Context, token, username string, expiresAt time.Time) error
-	GetSession(ctx context.Context, token string) (username string, expiresAt time.Time, err error)
+	CreateSession(ctx context.Context, token, username string, expiresAt time.Time, remember bool) error
+	GetSession(ctx context.Context, token string) (store.Session, error)
+	ExtendSession(ctx context.Context, token string, expiresAt time.Time) error
 	DeleteSession(ctx context.Context, token string) error
 	DeleteAllSessions(ctx context.Context) error
 	DeleteSessionsByUsername(ctx context.Context, username string) error
 	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	SetSessionDevice(ctx context.Context, token string, dev store.SessionDevice) (bool, error)
+	UserSessions(ctx context.Context, username string) ([]store.Session, error)
+	AddPasskey(ctx context.Context, p store.Passkey) error
+	GetPasskey(ctx context.Context, id string) (store.Passkey, error)
+	Passkeys(ctx context.Context, username string) ([]store.Passkey, error)
+	UsePasskey(ctx context.Context, id string, signCount int64) error
+	DeletePasskey(ctx context.Context, username, id string) error
+	DeletePasskeys(ctx context.Context, username string) (int64, error)
+	SyncTokenUsage(ctx context.Context, fingerprints []string) error
+	TouchToken(ctx context.Context, fingerprint, ip string, at time.Time) error
+	TokenUsages(ctx context.Context) ([]store.TokenUsage, error)
+	UseNonce(ctx context.Context, nonce string, at, expiresAt time.Time) (bool, error)
+	DeleteExpiredNonces(ctx context.Context) (int64, error)
+	AddDelegatedToken(ctx context.Context, t store.DelegatedToken) error
+	GetDelegatedToken(ctx context.Context, fingerprint string) (store.DelegatedToken, error)
+	DelegatedTokens(ctx context.Context) ([]store.DelegatedToken, error)
+	DeleteDelegatedToken(ctx context.Context, fingerprint string) error
 }
 
 // ConfigValidator validates auth configuration data against a schema.
@@ -95,6 +157,8 @@ func LoadConfig(path string, validator ConfigValidator) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse auth config file: %w", err)
 	}
+	sum := sha256.Sum256(data)
+	cfg.checksum = hex.EncodeToString(sum[:])
 
 	return &cfg, nil
 }
@@ -104,6 +168,9 @@ func LoadConfig(path string, validator ConfigValidator) (*Config, error) {
 // must also explicitly contain "secrets" - wildcards like "*" or "app/*"
 // do not grant access to secrets.
 func (acl TokenACL) CheckKeyPermission(key string, needWrite bool) bool {
+	if acl.parent != nil && !acl.parent.CheckKeyPermission(key, needWrite) {
+		return false
+	}
 	isSecretKey := store.IsSecret(key)
 
 	for _, pp := range acl.prefixes {
@@ -122,6 +189,15 @@ func (acl TokenACL) CheckKeyPermission(key string, needWrite bool) bool {
 	return false
 }
 
+// AllowsScope checks if this ACL allows the operation. ACLs without scopes allow all operations,
+// prefix permissions are checked separately.
+func (acl TokenACL) AllowsScope(scope enum.Scope) bool {
+	if acl.parent != nil && !acl.parent.AllowsScope(scope) {
+		return false
+	}
+	return acl.scopes == nil || slices.Contains(acl.scopes, scope)
+}
+
 // parseUsers converts UserConfig slice to users map.
 func parseUsers(configs []UserConfig) (map[string]User, error) {
 	users := make(map[string]User)
@@ -142,12 +218,25 @@ func parseUsers(configs []UserConfig) (map[string]User, error) {
 			return nil, fmt.Errorf("invalid permissions for user %q: %w", uc.Name, err)
 		}
 
-		users[uc.Name] = User{
+		prefixAdmin, err := parsePrefixAdmin(uc.PrefixAdmin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix_admin for user %q: %w", uc.Name, err)
+		}
+
+		user := User{
 			Name:         uc.Name,
 			PasswordHash: uc.Password,
 			Admin:        uc.Admin,
+			PrefixAdmin:  prefixAdmin,
+			Email:        uc.Email,
 			ACL:          acl,
 		}
+		if uc.BreakGlass != nil {
+			if user.BreakGlass, user.BreakGlassTTL, err = parseBreakGlass(uc.Name, uc.BreakGlass); err != nil {
+				return nil, fmt.Errorf("invalid break_glass for user %q: %w", uc.Name, err)
+			}
+		}
+		users[uc.Name] = user
 	}
 
 	return users, nil
@@ -171,6 +260,12 @@ func parseTokenConfigs(configs []TokenConfig) (map[string]TokenACL, *TokenACL, e
 			return nil, nil, fmt.Errorf("invalid permissions for token %q: %w", MaskToken(tc.Token), err)
 		}
 		acl.Admin = tc.Admin
+		if acl.scopes, err = parseScopes(tc.Scopes); err != nil {
+			return nil, nil, fmt.Errorf("invalid scopes for token %q: %w", MaskToken(tc.Token), err)
+		}
+		if acl.manages, err = parsePrefixAdmin(tc.PrefixAdmin); err != nil {
+			return nil, nil, fmt.Errorf("invalid prefix_admin for token %q: %w", MaskToken(tc.Token), err)
+		}
 
 		// token "*" is treated as public access (no auth required)
 		if tc.Token == "*" {
@@ -221,6 +316,40 @@ func parsePermissionConfigs(name string, configs []PermissionConfig) (TokenACL,
 	return acl, nil
 }
 
+// parsePrefixAdmin checks managed prefixes of prefix admins, nil for none.
+func parsePrefixAdmin(prefixes []string) ([]string, error) {
+	var res []string
+	for _, p := range prefixes {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			return nil, errors.New("prefix cannot be empty")
+		}
+		if slices.Contains(res, p) {
+			return nil, fmt.Errorf("duplicate prefix %q", p)
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// parseScopes converts scope strings to enum.Scope values, nil for no scopes.
+func parseScopes(scopes []string) ([]enum.Scope, error) {
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+	res := make([]enum.Scope, 0, len(scopes))
+	for _, sc := range scopes {
+		scope, err := enum.ParseScope(strings.TrimSpace(sc))
+		if err != nil {
+			return nil, fmt.Errorf("unknown scope %q, expected one of %s", sc, strings.Join(enum.ScopeNames, ", "))
+		}
+		if !slices.Contains(res, scope) {
+			res = append(res, scope)
+		}
+	}
+	return res, nil
+}
+
 // parsePermissionString converts a permission string to enum.Permission type.
 func parsePermissionString(s string) (enum.Permission, error) {
 	perm, err := enum.ParsePermission(strings.TrimSpace(s))
@@ -346,3 +346,45 @@ func createTempFile(t *testing.T, content string) string {
 	require.NoError(t, err)
 	return f
 }
+
+func TestParseTokenConfigs_Scopes(t *testing.T) {
+	t.Run("scopes parsed", func(t *testing.T) {
+		configs := []TokenConfig{
+			{Token: "backup", Permissions: []PermissionConfig{{Prefix: "*", Access: "r"}}, Scopes: []string{"read", "export", "read"}},
+			{Token: "full", Permissions: []PermissionConfig{{Prefix: "*", Access: "rw"}}},
+		}
+		tokens, _, err := parseTokenConfigs(configs)
+		require.NoError(t, err)
+
+		backup := tokens["backup"]
+		assert.Equal(t, []enum.Scope{enum.ScopeRead, enum.ScopeExport}, backup.scopes)
+		assert.True(t, backup.AllowsScope(enum.ScopeRead))
+		assert.True(t, backup.AllowsScope(enum.ScopeExport))
+		assert.False(t, backup.AllowsScope(enum.ScopeDelete))
+		assert.False(t, backup.AllowsScope(enum.ScopeList))
+
+		full := tokens["full"]
+		assert.Nil(t, full.scopes)
+		for scope := range enum.ScopeIter() {
+			assert.True(t, full.AllowsScope(scope), "no scopes allow %s", scope)
+		}
+	})
+
+	t.Run("unknown scope rejected", func(t *testing.T) {
+		configs := []TokenConfig{{Token: "bad", Permissions: []PermissionConfig{{Prefix: "*", Access: "r"}}, Scopes: []string{"purge"}}}
+		_, _, err := parseTokenConfigs(configs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown scope "purge"`)
+	})
+}
+
+func TestParseTokenConfigs_PrefixAdmin(t *testing.T) {
+	tokens, _, err := parseTokenConfigs([]TokenConfig{{Token: "team-a", PrefixAdmin: []string{"team-a/*", " team-a/secrets/*"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a/*", "team-a/secrets/*"}, tokens["team-a"].manages)
+
+	_, _, err = parseTokenConfigs([]TokenConfig{{Token: "dup", PrefixAdmin: []string{"team-a/*", "team-a/*"}}})
+	require.ErrorContains(t, err, `duplicate prefix "team-a/*"`)
+	_, _, err = parseTokenConfigs([]TokenConfig{{Token: "empty", PrefixAdmin: []string{""}}})
+	require.ErrorContains(t, err, "prefix cannot be empty")
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/cookie"
+	"github.com/umputun/stash/app/store"
+)
+
+// delegatedTokenPrefix starts every delegated token, only such tokens are looked up in the store
+const delegatedTokenPrefix = "sdt_"
+
+// delegatedNameRe limits names of delegated tokens to ones safe for logs and audit
+var delegatedNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// errNotManaged is returned when a delegated token would get access outside prefixes of its creator
+var errNotManaged = errors.New("prefix not managed")
+
+// DelegatedTokenRequest is the body of the request creating a delegated token. Every permission must be
+// under a prefix managed by the creator, the token never expires without ttl.
+type DelegatedTokenRequest struct {
+	Name        string             `json:"name"`
+	Permissions []PermissionConfig `json:"permissions"`
+	Scopes      []string           `json:"scopes,omitempty"`
+	TTL         string             `json:"ttl,omitempty"` // lifetime as go duration, e.g. "720h"
+}
+
+// DelegatedToken is a token created by a prefix admin, as listed for its managers.
+type DelegatedToken struct {
+	Token       string             `json:"token,omitempty"` // the token itself, returned only on creation
+	Fingerprint string             `json:"fingerprint"`
+	Name        string             `json:"name"`
+	Prefix      string             `json:"prefix"` // managed prefix the token was created under
+	CreatedBy   string             `json:"created_by"`
+	Permissions []PermissionConfig `json:"permissions"`
+	Scopes      []string           `json:"scopes,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty"`
+	Active      bool               `json:"active"` // false if expired or the creator no longer manages the prefix
+}
+
+// delegatedACL is the access of a delegated token, stored as JSON next to the token.
+type delegatedACL struct {
+	Permissions []PermissionConfig `json:"permissions"`
+	Scopes      []string           `json:"scopes,omitempty"`
+}
+
+// prefixManager is a user or a named token allowed to manage delegated tokens. Global admins manage all
+// prefixes, prefix admins only the prefixes from their prefix_admin list.
+type prefixManager struct {
+	id       string // "user:<name>" or "token:<fingerprint>", stored as the creator of tokens
+	admin    bool
+	prefixes []string
+}
+
+// manages checks if the manager may delegate access to the prefix pattern. Patterns granting secrets
+// need a managed prefix granting secrets as well.
+func (m prefixManager) manages(pattern string) bool {
+	if m.admin {
+		return true
+	}
+	secrets := prefixPerm{prefix: pattern}.grantsSecrets()
+	for _, p := range m.prefixes {
+		if coversPattern(p, pattern) && (!secrets || prefixPerm{prefix: p}.grantsSecrets()) {
+			return true
+		}
+	}
+	return false
+}
+
+// managesAll checks if the manager may delegate all the permissions.
+func (m prefixManager) managesAll(perms []PermissionConfig) bool {
+	for _, pc := range perms {
+		if !m.manages(pc.Prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// coveringPrefix returns the most specific managed prefix covering all the permissions, "*" for
+// global admins without such a prefix.
+func (m prefixManager) coveringPrefix(perms []PermissionConfig) (string, bool) {
+	best := ""
+	for _, p := range m.prefixes {
+		covered := true
+		for _, pc := range perms {
+			if !coversPattern(p, pc.Prefix) {
+				covered = false
+				break
+			}
+		}
+		if covered && len(p) > len(best) {
+			best = p
+		}
+	}
+	if best == "" && m.admin {
+		return "*", true
+	}
+	return best, best != ""
+}
+
+// coversPattern checks if every key matched by the pattern is matched by the managed prefix.
+func coversPattern(managed, pattern string) bool {
+	if managed == "*" {
+		return true
+	}
+	managedBase, managedWild := strings.CutSuffix(managed, "*")
+	if patternBase, wild := strings.CutSuffix(pattern, "*"); wild {
+		return managedWild && strings.HasPrefix(patternBase, managedBase)
+	}
+	return matchPrefix(managed, pattern)
+}
+
+// requestManager returns the manager making the request, a named token of the config or a web UI user.
+// Exchanged and delegated tokens can't manage tokens.
+func (s *Service) requestManager(r *http.Request) (prefixManager, bool) {
+	if s == nil || !s.Enabled() {
+		return prefixManager{}, false
+	}
+	if token := ExtractToken(r); token != "" {
+		s.mu.RLock()
+		acl, ok := s.tokens[token]
+		s.mu.RUnlock()
+		if !ok {
+			return prefixManager{}, false
+		}
+		s.recordTokenUse(r, acl)
+		return prefixManager{id: "token:" + tokenFingerprint(token), admin: acl.Admin, prefixes: acl.manages}, true
+	}
+	for _, cookieName := range cookie.SessionCookieNames {
+		if c, err := r.Cookie(cookieName); err == nil {
+			if username, ok := s.GetSessionUser(r.Context(), c.Value); ok {
+				return s.managerByID("user:" + username)
+			}
+		}
+	}
+	return prefixManager{}, false
+}
+
+// managerByID returns the current rights of the creator of delegated tokens, false if the user or
+// the token is gone from the config.
+func (s *Service) managerByID(id string) (prefixManager, bool) {
+	if name, ok := strings.CutPrefix(id, "user:"); ok {
+		s.mu.RLock()
+		user, exists := s.users[name]
+		s.mu.RUnlock()
+		if !exists {
+			return prefixManager{}, false
+		}
+		return prefixManager{id: id, admin: user.Admin, prefixes: user.PrefixAdmin}, true
+	}
+	if fp, ok := strings.CutPrefix(id, "token:"); ok {
+		acl, exists := s.tokenByFingerprint(fp)
+		if !exists {
+			return prefixManager{}, false
+		}
+		return prefixManager{id: id, admin: acl.Admin, prefixes: acl.manages}, true
+	}
+	return prefixManager{}, false
+}
+
+// delegatedTokenACL returns the ACL of a delegated token. Expired tokens and tokens whose creator no
+// longer manages all their permissions are rejected, so dropping a prefix admin from the config
+// revokes the tokens they created.
+func (s *Service) delegatedTokenACL(token string) (TokenACL, bool) {
+	if !strings.HasPrefix(token, delegatedTokenPrefix) || s.sessionStore == nil {
+		return TokenACL{}, false
+	}
+	// token lookups have no request context, same as the ACL of named tokens
+	dt, err := s.sessionStore.GetDelegatedToken(context.Background(), tokenFingerprint(token))
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("[WARN] failed to get delegated token: %v", err)
+		}
+		return TokenACL{}, false
+	}
+	if dt.ExpiresAt != nil && time.Now().After(*dt.ExpiresAt) {
+		return TokenACL{}, false
+	}
+	acl, _, ok := s.parseDelegated(dt)
+	if !ok {
+		return TokenACL{}, false
+	}
+	acl.actor = "token:" + dt.Name + ":delegated"
+	return acl, true
+}
+
+// parseDelegated parses the stored access of a delegated token, false if it's broken or the creator
+// no longer manages it.
+func (s *Service) parseDelegated(dt store.DelegatedToken) (TokenACL, delegatedACL, bool) {
+	var da delegatedACL
+	if err := json.Unmarshal([]byte(dt.ACL), &da); err != nil {
+		log.Printf("[WARN] broken acl of delegated token %q: %v", dt.Name, err)
+		return TokenACL{}, delegatedACL{}, false
+	}
+	creator, ok := s.managerByID(dt.CreatedBy)
+	if !ok || !creator.managesAll(da.Permissions) {
+		return TokenACL{}, da, false
+	}
+	acl, err := parsePermissionConfigs(dt.Name, da.Permissions)
+	if err != nil {
+		return TokenACL{}, da, false
+	}
+	if acl.scopes, err = parseScopes(da.Scopes); err != nil {
+		return TokenACL{}, da, false
+	}
+	return acl, da, true
+}
+
+// CreateDelegatedToken creates a token with access under the prefixes of the manager. The token is
+// returned once, only its fingerprint is stored.
+func (s *Service) CreateDelegatedToken(ctx context.Context, m prefixManager, req DelegatedTokenRequest) (DelegatedToken, error) {
+	if !delegatedNameRe.MatchString(req.Name) {
+		return DelegatedToken{}, fmt.Errorf("invalid name %q, expected up to 64 letters, digits, '.', '_' or '-'", req.Name)
+	}
+	if len(req.Permissions) == 0 {
+		return DelegatedToken{}, errors.New("permissions required")
+	}
+	if _, err := parsePermissionConfigs(req.Name, req.Permissions); err != nil {
+		return DelegatedToken{}, fmt.Errorf("invalid permissions: %w", err)
+	}
+	if _, err := parseScopes(req.Scopes); err != nil {
+		return DelegatedToken{}, fmt.Errorf("invalid scopes: %w", err)
+	}
+	for _, pc := range req.Permissions {
+		if !m.manages(pc.Prefix) {
+			return DelegatedToken{}, fmt.Errorf("%w: prefix %q", errNotManaged, pc.Prefix)
+		}
+	}
+	prefix, ok := m.coveringPrefix(req.Permissions)
+	if !ok {
+		return DelegatedToken{}, fmt.Errorf("%w: permissions span several managed prefixes", errNotManaged)
+	}
+
+	existing, err := s.sessionStore.DelegatedTokens(ctx)
+	if err != nil {
+		return DelegatedToken{}, fmt.Errorf("failed to list delegated tokens: %w", err)
+	}
+	for _, dt := range existing {
+		if dt.Name == req.Name {
+			return DelegatedToken{}, fmt.Errorf("%w: token %q exists", store.ErrConflict, req.Name)
+		}
+	}
+
+	now := time.Now().UTC()
+	var expires *time.Time
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return DelegatedToken{}, fmt.Errorf("invalid ttl %q", req.TTL)
+		}
+		exp := now.Add(ttl)
+		expires = &exp
+	}
+
+	acl, err := json.Marshal(delegatedACL{Permissions: req.Permissions, Scopes: req.Scopes})
+	if err != nil {
+		return DelegatedToken{}, fmt.Errorf("failed to marshal acl: %w", err)
+	}
+	token := delegatedTokenPrefix + rand.Text()
+	dt := store.DelegatedToken{Fingerprint: tokenFingerprint(token), Name: req.Name, Prefix: prefix, CreatedBy: m.id,
+		ACL: string(acl), CreatedAt: now, ExpiresAt: expires}
+	if err = s.sessionStore.AddDelegatedToken(ctx, dt); err != nil {
+		return DelegatedToken{}, fmt.Errorf("failed to store delegated token: %w", err)
+	}
+	return DelegatedToken{Token: token, Fingerprint: dt.Fingerprint, Name: dt.Name, Prefix: prefix, CreatedBy: m.id,
+		Permissions: req.Permissions, Scopes: req.Scopes, CreatedAt: now, ExpiresAt: expires, Active: true}, nil
+}
+
+// DelegatedTokens returns the delegated tokens under prefixes of the manager, all tokens for admins.
+func (s *Service) DelegatedTokens(ctx context.Context, m prefixManager) ([]DelegatedToken, error) {
+	stored, err := s.sessionStore.DelegatedTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delegated tokens: %w", err)
+	}
+	res := []DelegatedToken{}
+	now := time.Now()
+	for _, dt := range stored {
+		if !m.manages(dt.Prefix) {
+			continue
+		}
+		_, da, ok := s.parseDelegated(dt)
+		res = append(res, DelegatedToken{Fingerprint: dt.Fingerprint, Name: dt.Name, Prefix: dt.Prefix,
+			CreatedBy: dt.CreatedBy, Permissions: da.Permissions, Scopes: da.Scopes, CreatedAt: dt.CreatedAt,
+			ExpiresAt: dt.ExpiresAt, Active: ok && (dt.ExpiresAt == nil || now.Before(*dt.ExpiresAt))})
+	}
+	return res, nil
+}
+
+// RevokeDelegatedToken deletes a delegated token under prefixes of the manager, returns store.ErrNotFound
+// for unknown tokens and tokens the manager can't see.
+func (s *Service) RevokeDelegatedToken(ctx context.Context, m prefixManager, fingerprint string) (store.DelegatedToken, error) {
+	dt, err := s.sessionStore.GetDelegatedToken(ctx, fingerprint)
+	if err != nil {
+		return store.DelegatedToken{}, fmt.Errorf("failed to get delegated token: %w", err)
+	}
+	if !m.manages(dt.Prefix) {
+		return store.DelegatedToken{}, store.ErrNotFound
+	}
+	if err = s.sessionStore.DeleteDelegatedToken(ctx, fingerprint); err != nil {
+		return store.DelegatedToken{}, fmt.Errorf("failed to delete delegated token: %w", err)
+	}
+	return dt, nil
+}
+
+// HandleDelegatedTokens lists delegated tokens the caller manages.
+// GET /auth/delegated-tokens
+func (s *Service) HandleDelegatedTokens(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.requireManager(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := s.DelegatedTokens(r.Context(), m)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to list delegated tokens")
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"tokens": tokens})
+}
+
+// HandleCreateDelegatedToken creates a delegated token under a prefix the caller manages.
+// POST /auth/delegated-tokens with DelegatedTokenRequest body
+func (s *Service) HandleCreateDelegatedToken(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.requireManager(w, r)
+	if !ok {
+		return
+	}
+	var req DelegatedTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	dt, err := s.CreateDelegatedToken(r.Context(), m, req)
+	switch {
+	case errors.Is(err, errNotManaged):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, err, err.Error())
+		return
+	case errors.Is(err, store.ErrConflict):
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusConflict, err, err.Error())
+		return
+	case err != nil:
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+		return
+	}
+	log.Printf("[INFO] delegated token %q under %q created by %s", dt.Name, dt.Prefix, m.id)
+	if err := rest.EncodeJSON(w, http.StatusCreated, dt); err != nil {
+		log.Printf("[WARN] failed to write delegated token: %v", err)
+	}
+}
+
+// HandleRevokeDelegatedToken deletes a delegated token under a prefix the caller manages.
+// DELETE /auth/delegated-tokens/{fingerprint}
+func (s *Service) HandleRevokeDelegatedToken(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.requireManager(w, r)
+	if !ok {
+		return
+	}
+	dt, err := s.RevokeDelegatedToken(r.Context(), m, r.PathValue("fingerprint"))
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "delegated token not found")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to revoke delegated token")
+		return
+	}
+	log.Printf("[INFO] delegated token %q under %q revoked by %s", dt.Name, dt.Prefix, m.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireManager answers 401, or 403 for callers who are neither admins nor prefix admins, unless the
+// request is made by a manager of delegated tokens.
+func (s *Service) requireManager(w http.ResponseWriter, r *http.Request) (prefixManager, bool) {
+	m, ok := s.requestManager(r)
+	if !ok {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "named token or login required")
+		return prefixManager{}, false
+	}
+	if !m.admin && len(m.prefixes) == 0 {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "admin or prefix admin access required")
+		return prefixManager{}, false
+	}
+	return m, true
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_DelegatedTokens(t *testing.T) {
+	const config = `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+    prefix_admin: ["team-a/*"]
+  - name: bob
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: r
+tokens:
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "team-b-token"
+    prefix_admin: ["team-b/*", "team-b/secrets/*"]
+`
+	f := createTempFile(t, config)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil)
+	require.NoError(t, err)
+	aliceSession, err := svc.CreateSession(t.Context(), "alice", false)
+	require.NoError(t, err)
+	bobSession, err := svc.CreateSession(t.Context(), "bob", false)
+	require.NoError(t, err)
+
+	call := func(method, path, token, session, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("X-Auth-Token", token)
+		}
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "stash-auth", Value: session})
+		}
+		req.SetPathValue("fingerprint", strings.TrimPrefix(path, "/auth/delegated-tokens/"))
+		rec := httptest.NewRecorder()
+		switch method {
+		case http.MethodPost:
+			svc.HandleCreateDelegatedToken(rec, req)
+		case http.MethodDelete:
+			svc.HandleRevokeDelegatedToken(rec, req)
+		default:
+			svc.HandleDelegatedTokens(rec, req)
+		}
+		return rec
+	}
+	create := func(token, session, body string) DelegatedToken {
+		t.Helper()
+		rec := call(http.MethodPost, "/auth/delegated-tokens", token, session, body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var dt DelegatedToken
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dt))
+		return dt
+	}
+	list := func(token, session string) []DelegatedToken {
+		t.Helper()
+		rec := call(http.MethodGet, "/auth/delegated-tokens", token, session, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			Tokens []DelegatedToken `json:"tokens"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Tokens
+	}
+
+	ci := create("", aliceSession, `{"name":"ci","permissions":[{"prefix":"team-a/ci/*","access":"rw"}],"scopes":["read","write"]}`)
+	assert.True(t, strings.HasPrefix(ci.Token, delegatedTokenPrefix))
+	assert.Equal(t, tokenFingerprint(ci.Token), ci.Fingerprint)
+	assert.Equal(t, "team-a/*", ci.Prefix)
+	assert.Equal(t, "user:alice", ci.CreatedBy)
+
+	t.Run("delegated token gets its permissions only", func(t *testing.T) {
+		acl, ok := svc.getTokenACL(ci.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("team-a/ci/db", true))
+		assert.False(t, acl.CheckKeyPermission("team-a/other", false))
+		assert.False(t, acl.Admin)
+		assert.Equal(t, "token:ci:delegated", acl.actor)
+
+		_, ok = svc.getTokenACL(delegatedTokenPrefix + "unknown")
+		assert.False(t, ok)
+	})
+
+	t.Run("access outside managed prefixes is rejected", func(t *testing.T) {
+		tests := []struct {
+			name, token, session, body string
+			code                       int
+		}{
+			{"other team", "", aliceSession, `{"name":"x","permissions":[{"prefix":"team-b/*","access":"r"}]}`, 403},
+			{"whole tree", "", aliceSession, `{"name":"x","permissions":[{"prefix":"*","access":"r"}]}`, 403},
+			{"sibling prefix", "", aliceSession, `{"name":"x","permissions":[{"prefix":"team-ab/*","access":"r"}]}`, 403},
+			{"secrets", "", aliceSession, `{"name":"x","permissions":[{"prefix":"team-a/secrets/*","access":"r"}]}`, 403},
+			{"not a prefix admin", "", bobSession, `{"name":"x","permissions":[{"prefix":"team-a/*","access":"r"}]}`, 403},
+			{"delegated token", ci.Token, "", `{"name":"x","permissions":[{"prefix":"team-a/ci/*","access":"r"}]}`, 401},
+			{"duplicate name", "", aliceSession, `{"name":"ci","permissions":[{"prefix":"team-a/*","access":"r"}]}`, 409},
+			{"bad name", "", aliceSession, `{"name":"c i","permissions":[{"prefix":"team-a/*","access":"r"}]}`, 400},
+			{"no permissions", "", aliceSession, `{"name":"x"}`, 400},
+			{"bad ttl", "", aliceSession, `{"name":"x","permissions":[{"prefix":"team-a/*","access":"r"}],"ttl":"-1h"}`, 400},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := call(http.MethodPost, "/auth/delegated-tokens", tc.token, tc.session, tc.body)
+				assert.Equal(t, tc.code, rec.Code, rec.Body.String())
+			})
+		}
+	})
+
+	t.Run("secrets need a managed secrets prefix", func(t *testing.T) {
+		dt := create("team-b-token", "", `{"name":"vault","permissions":[{"prefix":"team-b/secrets/*","access":"r"}]}`)
+		assert.Equal(t, "team-b/secrets/*", dt.Prefix, "most specific managed prefix")
+		acl, ok := svc.getTokenACL(dt.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("team-b/secrets/db", false))
+	})
+
+	t.Run("managers see tokens under their prefixes", func(t *testing.T) {
+		assert.Len(t, list("", aliceSession), 1)
+		assert.Len(t, list("team-b-token", ""), 1)
+		assert.Len(t, list("admin-token", ""), 2)
+		rec := call(http.MethodGet, "/auth/delegated-tokens", "", bobSession, "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = call(http.MethodGet, "/auth/delegated-tokens", "", "", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		dt := create("admin-token", "", `{"name":"short","permissions":[{"prefix":"*","access":"r"}],"ttl":"1ms"}`)
+		assert.Equal(t, "*", dt.Prefix)
+		time.Sleep(5 * time.Millisecond)
+		_, ok := svc.getTokenACL(dt.Token)
+		assert.False(t, ok)
+		for _, l := range list("admin-token", "") {
+			assert.Equal(t, l.Name != "short", l.Active, l.Name)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		rec := call(http.MethodDelete, "/auth/delegated-tokens/"+ci.Fingerprint, "team-b-token", "", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code, "other team can't revoke")
+		rec = call(http.MethodDelete, "/auth/delegated-tokens/"+ci.Fingerprint, "", aliceSession, "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		_, ok := svc.getTokenACL(ci.Token)
+		assert.False(t, ok)
+		rec = call(http.MethodDelete, "/auth/delegated-tokens/"+ci.Fingerprint, "", aliceSession, "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("tokens stop working when the creator loses the prefix", func(t *testing.T) {
+		dt := create("team-b-token", "", `{"name":"deploy","permissions":[{"prefix":"team-b/app/*","access":"r"}]}`)
+		_, ok := svc.getTokenACL(dt.Token)
+		require.True(t, ok)
+
+		require.NoError(t, os.WriteFile(f, bytes.ReplaceAll([]byte(config), []byte(`["team-b/*", "team-b/secrets/*"]`),
+			[]byte(`["team-c/*"]`)), 0o600))
+		require.NoError(t, svc.Reload(t.Context()))
+		_, ok = svc.getTokenACL(dt.Token)
+		assert.False(t, ok)
+	})
+}
+
+func TestCoversPattern(t *testing.T) {
+	tests := []struct {
+		managed, pattern string
+		want             bool
+	}{
+		{"*", "anything/*", true},
+		{"team-a/*", "team-a/*", true},
+		{"team-a/*", "team-a/ci/*", true},
+		{"team-a/*", "team-a/key", true},
+		{"team-a/*", "team-ab/*", false},
+		{"team-a/*", "*", false},
+		{"team-a/key", "team-a/key", true},
+		{"team-a/key", "team-a/key*", false},
+		{"team-a/ci", "team-a/*", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, coversPattern(tc.managed, tc.pattern), "%s covers %s", tc.managed, tc.pattern)
+	}
+}
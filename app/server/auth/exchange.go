@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+	"github.com/google/uuid"
+)
+
+// defaultExchangeTTL is the lifetime of exchanged tokens if the request doesn't ask for another one
+const defaultExchangeTTL = 15 * time.Minute
+
+// exchangeIssuer is the "iss" claim of exchanged tokens
+const exchangeIssuer = "stash"
+
+// ExchangeRequest is the body of the token exchange request. Empty permissions and scopes
+// inherit the parent token ones, the child token can't get more access than its parent anyway.
+type ExchangeRequest struct {
+	Permissions []PermissionConfig `json:"permissions,omitempty"`
+	Scopes      []string           `json:"scopes,omitempty"`
+	TTL         string             `json:"ttl,omitempty"` // lifetime as go duration, e.g. "15m"
+}
+
+// ExchangeResponse is the exchanged token returned to the client.
+type ExchangeResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// exchangeClaims are the private claims of minted tokens, next to the registered ones.
+// The parent token is referenced by its fingerprint in the "sub" claim, cloud logins by the role.
+type exchangeClaims struct {
+	Permissions []PermissionConfig `json:"perm,omitempty"`
+	Scopes      []string           `json:"scopes,omitempty"`
+	Principal   string             `json:"prn,omitempty"` // cloud principal of cloud logins
+}
+
+// WithTokenExchange enables exchange of named tokens for short-lived child tokens signed with the secret.
+// Child tokens live up to maxTTL, zero for no limit.
+func WithTokenExchange(secret []byte, maxTTL time.Duration) Option {
+	return func(s *Service) {
+		s.exchangeSecret, s.exchangeMaxTTL = secret, maxTTL
+	}
+}
+
+// ExchangeEnabled returns true if named tokens can be exchanged for child tokens.
+func (s *Service) ExchangeEnabled() bool {
+	return s != nil && len(s.exchangeSecret) > 0
+}
+
+// ExchangeToken mints a child token of the parent named token. The child token is a JWT with requested
+// permissions and scopes, it is accepted only while the parent token is configured and its access is
+// limited by the parent ACL, so it can't be used to escalate access.
+func (s *Service) ExchangeToken(parent string, req ExchangeRequest) (ExchangeResponse, error) {
+	if !s.ExchangeEnabled() {
+		return ExchangeResponse{}, errors.New("token exchange is disabled")
+	}
+	s.mu.RLock()
+	parentACL, ok := s.tokens[parent]
+	s.mu.RUnlock()
+	if !ok {
+		return ExchangeResponse{}, errors.New("unknown parent token")
+	}
+
+	ttl, err := s.exchangeTTL(req.TTL)
+	if err != nil {
+		return ExchangeResponse{}, err
+	}
+
+	// validate the requested access the same way as the config, scopes must be allowed to the parent
+	if _, err = parsePermissionConfigs(parentACL.Token, req.Permissions); err != nil {
+		return ExchangeResponse{}, fmt.Errorf("invalid permissions: %w", err)
+	}
+	scopes, err := parseScopes(req.Scopes)
+	if err != nil {
+		return ExchangeResponse{}, fmt.Errorf("invalid scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if !parentACL.AllowsScope(scope) {
+			return ExchangeResponse{}, fmt.Errorf("scope %q is not allowed to the parent token", scope)
+		}
+	}
+
+	return s.mintToken(tokenFingerprint(parent), exchangeClaims{Permissions: req.Permissions, Scopes: req.Scopes}, ttl)
+}
+
+// exchangeTTL returns the lifetime of minted tokens for the requested ttl, the default for empty one,
+// capped by the max ttl.
+func (s *Service) exchangeTTL(requested string) (time.Duration, error) {
+	ttl := defaultExchangeTTL
+	if requested != "" {
+		var err error
+		if ttl, err = time.ParseDuration(requested); err != nil || ttl <= 0 {
+			return 0, fmt.Errorf("invalid ttl %q", requested)
+		}
+	}
+	if s.exchangeMaxTTL > 0 {
+		ttl = min(ttl, s.exchangeMaxTTL)
+	}
+	return ttl, nil
+}
+
+// mintToken signs a JWT for the subject with private claims, the subject refers to the parent named
+// token or to the cloud role of minted tokens.
+func (s *Service) mintToken(subject string, private exchangeClaims, ttl time.Duration) (ExchangeResponse, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: s.exchangeSecret},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return ExchangeResponse{}, fmt.Errorf("failed to make token signer: %w", err)
+	}
+	now := time.Now()
+	claims := jwt.Claims{
+		Issuer:   exchangeIssuer,
+		Subject:  subject,
+		ID:       uuid.NewString(),
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(ttl)),
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Claims(private).CompactSerialize()
+	if err != nil {
+		return ExchangeResponse{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return ExchangeResponse{Token: token, ExpiresAt: claims.Expiry.Time()}, nil
+}
+
+// HandleTokenExchange exchanges the named token of the request for a child token.
+// POST /auth/token with ExchangeRequest body, exchanged tokens can't be exchanged again.
+func (s *Service) HandleTokenExchange(w http.ResponseWriter, r *http.Request) {
+	parent := ExtractToken(r)
+	s.mu.RLock()
+	parentACL, ok := s.tokens[parent]
+	s.mu.RUnlock()
+	if parent == "" || !ok {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "named token required")
+		return
+	}
+	s.recordTokenUse(r, parentACL)
+
+	var req ExchangeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+			return
+		}
+	}
+
+	resp, err := s.ExchangeToken(parent, req)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+		return
+	}
+	log.Printf("[INFO] token %q exchanged for a child token expiring at %s", MaskToken(parent),
+		resp.ExpiresAt.Format(time.RFC3339))
+	rest.RenderJSON(w, resp)
+}
+
+// exchangedTokenACL returns the ACL of a minted token. Exchanged tokens get the parent token ACL on top
+// of their own permissions, cloud login tokens get the ACL of their cloud role. Tokens with bad signature,
+// expired, or with parent token or role gone from the config are rejected.
+func (s *Service) exchangedTokenACL(token string) (TokenACL, bool) {
+	if strings.Count(token, ".") != 2 {
+		return TokenACL{}, false // not a JWT
+	}
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != string(jose.HS256) {
+		return TokenACL{}, false
+	}
+	var claims jwt.Claims
+	var private exchangeClaims
+	if err = parsed.Claims(s.exchangeSecret, &claims, &private); err != nil {
+		return TokenACL{}, false
+	}
+	if err = claims.ValidateWithLeeway(jwt.Expected{Issuer: exchangeIssuer, Time: time.Now()}, 0); err != nil {
+		return TokenACL{}, false
+	}
+
+	if role, isCloud := strings.CutPrefix(claims.Subject, cloudSubjectPrefix); isCloud {
+		acl, ok := s.cloudRoleACL(role)
+		if !ok {
+			return TokenACL{}, false // role was removed from the config
+		}
+		acl.actor = private.Principal
+		return acl, true
+	}
+
+	parent, ok := s.tokenByFingerprint(claims.Subject)
+	if !ok {
+		return TokenACL{}, false // parent token was removed from the config
+	}
+	acl := TokenACL{Token: parent.Token, prefixes: parent.prefixes}
+	if len(private.Permissions) > 0 {
+		if acl, err = parsePermissionConfigs(parent.Token, private.Permissions); err != nil {
+			return TokenACL{}, false
+		}
+	}
+	if acl.scopes, err = parseScopes(private.Scopes); err != nil {
+		return TokenACL{}, false
+	}
+	acl.parent = &parent
+	acl.actor = "token:" + MaskToken(parent.Token) + ":exchanged"
+	return acl, true
+}
+
+// tokenByFingerprint returns the ACL of the named token with the given fingerprint.
+func (s *Service) tokenByFingerprint(fingerprint string) (TokenACL, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for token, acl := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(tokenFingerprint(token)), []byte(fingerprint)) == 1 {
+			return acl, true
+		}
+	}
+	return TokenACL{}, false
+}
+
+// tokenFingerprint returns the identifier of a named token, safe to put into exchanged tokens.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
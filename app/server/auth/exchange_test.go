@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+const exchangeTestConfig = `
+tokens:
+  - token: "ci-parent-token"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+    scopes: [list, read, write]
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`
+
+var exchangeTestSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestService_ExchangeToken(t *testing.T) {
+	f := createTempFile(t, exchangeTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour))
+	require.NoError(t, err)
+	require.True(t, svc.ExchangeEnabled())
+
+	t.Run("child inherits parent access", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("ci-parent-token", ExchangeRequest{})
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(defaultExchangeTTL), resp.ExpiresAt, 2*time.Second)
+
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("app/cfg", true))
+		assert.False(t, acl.CheckKeyPermission("other/cfg", false))
+		assert.True(t, acl.AllowsScope(enum.ScopeWrite))
+		assert.False(t, acl.AllowsScope(enum.ScopeDelete), "parent scopes apply")
+		assert.False(t, acl.Admin)
+	})
+
+	t.Run("child narrowed by request", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("ci-parent-token", ExchangeRequest{
+			Permissions: []PermissionConfig{{Prefix: "app/db/*", Access: "r"}, {Prefix: "other/*", Access: "rw"}},
+			Scopes:      []string{"read"},
+			TTL:         "5m",
+		})
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(5*time.Minute), resp.ExpiresAt, 2*time.Second)
+
+		acl, ok := svc.getTokenACL(resp.Token)
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("app/db/url", false))
+		assert.False(t, acl.CheckKeyPermission("app/db/url", true), "child is read-only")
+		assert.False(t, acl.CheckKeyPermission("app/cfg", false), "outside of child prefixes")
+		assert.False(t, acl.CheckKeyPermission("other/x", false), "not allowed to parent")
+		assert.True(t, acl.AllowsScope(enum.ScopeRead))
+		assert.False(t, acl.AllowsScope(enum.ScopeList))
+	})
+
+	t.Run("ttl capped by max ttl", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("ci-parent-token", ExchangeRequest{TTL: "48h"})
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), resp.ExpiresAt, 2*time.Second)
+	})
+
+	t.Run("child of admin token is not admin", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("admin-token", ExchangeRequest{})
+		require.NoError(t, err)
+		assert.False(t, svc.isTokenAdmin(resp.Token))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, err := svc.ExchangeToken("unknown", ExchangeRequest{})
+		require.EqualError(t, err, "unknown parent token")
+
+		_, err = svc.ExchangeToken("ci-parent-token", ExchangeRequest{TTL: "soon"})
+		require.EqualError(t, err, `invalid ttl "soon"`)
+
+		_, err = svc.ExchangeToken("ci-parent-token", ExchangeRequest{Scopes: []string{"delete"}})
+		require.EqualError(t, err, `scope "delete" is not allowed to the parent token`)
+
+		_, err = svc.ExchangeToken("ci-parent-token", ExchangeRequest{Permissions: []PermissionConfig{{Prefix: "app/*", Access: "x"}}})
+		require.ErrorContains(t, err, "invalid permissions")
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("ci-parent-token", ExchangeRequest{})
+		require.NoError(t, err)
+
+		other, err := New(f, time.Hour, false, testSessionStore(t), nil,
+			WithTokenExchange([]byte("another-secret-another-secret-00"), time.Hour))
+		require.NoError(t, err)
+		_, ok := other.getTokenACL(resp.Token)
+		assert.False(t, ok, "signed with another secret")
+
+		disabled, err := New(f, time.Hour, false, testSessionStore(t), nil)
+		require.NoError(t, err)
+		_, ok = disabled.getTokenACL(resp.Token)
+		assert.False(t, ok, "exchange disabled")
+
+		_, ok = svc.getTokenACL(resp.Token[:len(resp.Token)-2] + "xx")
+		assert.False(t, ok, "bad signature")
+
+		_, ok = svc.getTokenACL("a.b.c")
+		assert.False(t, ok, "garbage")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		short, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Nanosecond))
+		require.NoError(t, err)
+		resp, err := short.ExchangeToken("ci-parent-token", ExchangeRequest{})
+		require.NoError(t, err)
+		time.Sleep(1100 * time.Millisecond) // numeric dates have second precision
+		_, ok := short.getTokenACL(resp.Token)
+		assert.False(t, ok)
+	})
+
+	t.Run("removed parent revokes children", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("ci-parent-token", ExchangeRequest{})
+		require.NoError(t, err)
+		require.True(t, svc.hasTokenACL(resp.Token))
+
+		require.NoError(t, os.WriteFile(f, []byte(strings.ReplaceAll(exchangeTestConfig, "ci-parent-token", "rotated-token")), 0o600))
+		require.NoError(t, svc.Reload(t.Context()))
+		assert.False(t, svc.hasTokenACL(resp.Token))
+	})
+}
+
+func TestService_HandleTokenExchange(t *testing.T) {
+	f := createTempFile(t, exchangeTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithTokenExchange(exchangeTestSecret, time.Hour))
+	require.NoError(t, err)
+
+	exchange := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		svc.HandleTokenExchange(rec, req)
+		return rec
+	}
+
+	rec := exchange("ci-parent-token", `{"permissions":[{"prefix":"app/*","access":"r"}],"ttl":"15m"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp ExchangeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Token)
+
+	t.Run("child token works with the api", func(t *testing.T) {
+		handler := svc.TokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/cfg", http.NoBody)
+		req.Header.Set("X-Auth-Token", resp.Token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPut, "/kv/app/cfg", http.NoBody)
+		req.Header.Set("X-Auth-Token", resp.Token)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		actorType, actorName := svc.GetRequestActor(req)
+		assert.Equal(t, "token", actorType)
+		assert.Equal(t, "token:ci-p****:exchanged", actorName)
+	})
+
+	t.Run("child token can't be exchanged", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, exchange(resp.Token, "").Code)
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, exchange("", "").Code)
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, exchange("ci-parent-token", "").Code)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, exchange("ci-parent-token", "{bad").Code)
+		assert.Equal(t, http.StatusBadRequest, exchange("ci-parent-token", `{"scopes":["export"]}`).Code)
+	})
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest/realip"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// maxUserAgent is the max length of the user agent stored with a session
+const maxUserAgent = 512
+
+// notifyTimeout limits sending of a login notification, it runs after the login response
+const notifyTimeout = 30 * time.Second
+
+// LoginNotifier tells users about logins from new devices.
+type LoginNotifier interface {
+	NotifyLogin(ctx context.Context, ev LoginEvent) error
+}
+
+// LoginEvent is a login of a user from a device the user didn't log in from before.
+type LoginEvent struct {
+	Username string
+	Email    string
+	Time     time.Time
+	store.SessionDevice
+}
+
+// WithLocationHeader sets the request header with the approximate client location, e.g. CF-IPCountry
+// set by Cloudflare. The header must come from a trusted proxy, clients can send any value.
+func WithLocationHeader(header string) Option {
+	return func(s *Service) {
+		s.locationHeader = header
+	}
+}
+
+// WithLoginNotifier enables notifications of users with an email on login from a new device.
+func WithLoginNotifier(n LoginNotifier) Option {
+	return func(s *Service) {
+		s.notifier = n
+	}
+}
+
+// RecordLogin stores the device of a new session: browser and OS, user agent, client IP and location.
+// If the device is new for the user, the user is notified in the background.
+func (s *Service) RecordLogin(r *http.Request, token, username string) error {
+	if s == nil {
+		return nil
+	}
+	dev := requestDevice(r, s.locationHeader)
+	newDevice, err := s.sessionStore.SetSessionDevice(r.Context(), token, dev)
+	if err != nil {
+		return fmt.Errorf("failed to record login device: %w", err)
+	}
+	if !newDevice || s.notifier == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	email := s.users[username].Email
+	s.mu.RUnlock()
+	if email == "" {
+		return nil
+	}
+	ev := LoginEvent{Username: username, Email: email, Time: time.Now(), SessionDevice: dev}
+	ctx := context.WithoutCancel(r.Context()) // the request ends before the notification is sent
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+		if err := s.notifier.NotifyLogin(ctx, ev); err != nil {
+			log.Printf("[WARN] failed to notify user %q about login from new device: %v", username, err)
+			return
+		}
+		log.Printf("[INFO] notified user %q about login from new device %q", username, dev.Device)
+	}()
+	return nil
+}
+
+// UserSessions returns the active sessions of a user with their devices.
+func (s *Service) UserSessions(ctx context.Context, username string) ([]store.Session, error) {
+	if s == nil {
+		return nil, nil
+	}
+	sessions, err := s.sessionStore.UserSessions(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// requestDevice describes the client of a login request.
+func requestDevice(r *http.Request, locationHeader string) store.SessionDevice {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgent {
+		ua = strings.ToValidUTF8(ua[:maxUserAgent], "")
+	}
+	ip, _ := realip.Get(r) // empty if the address can't be parsed
+	dev := store.SessionDevice{Device: deviceName(ua), UserAgent: ua, IP: ip}
+	if locationHeader != "" {
+		// "XX" is the unknown country of Cloudflare
+		if loc := strings.TrimSpace(r.Header.Get(locationHeader)); loc != "XX" && len(loc) <= 64 {
+			dev.Location = loc
+		}
+	}
+	return dev
+}
+
+// deviceName returns the browser and OS of a user agent, e.g. "Firefox on Linux". Versions are dropped
+// so browser updates don't make a known device look new. Empty for an empty user agent.
+func deviceName(ua string) string {
+	if ua == "" {
+		return ""
+	}
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"}, {"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	// iOS and Android are checked first, their user agents mention macOS and Linux
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"}, {"CrOS", "ChromeOS"},
+		{"Windows", "Windows"}, {"Macintosh", "macOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notifierFunc adapts a function to LoginNotifier, a moq mock would import this package
+type notifierFunc func(ctx context.Context, ev LoginEvent) error
+
+func (f notifierFunc) NotifyLogin(ctx context.Context, ev LoginEvent) error { return f(ctx, ev) }
+
+func TestService_RecordLogin(t *testing.T) {
+	content := `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+    email: alice@example.com
+  - name: bob
+    password: "$2a$10$hash"
+`
+	notified := make(chan LoginEvent, 10)
+	notifier := notifierFunc(func(_ context.Context, ev LoginEvent) error {
+		notified <- ev
+		return nil
+	})
+	svc, err := New(createTempFile(t, content), time.Hour, false, testSessionStore(t), nil,
+		WithLocationHeader("CF-IPCountry"), WithLoginNotifier(notifier))
+	require.NoError(t, err)
+
+	login := func(username, ua, country string) string {
+		t.Helper()
+		token, err := svc.CreateSession(t.Context(), username, false)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.Header.Set("User-Agent", ua)
+		req.Header.Set("CF-IPCountry", country)
+		req.RemoteAddr = "203.0.113.7:4321"
+		require.NoError(t, svc.RecordLogin(req, token, username))
+		return token
+	}
+	const (
+		firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:140.0) Gecko/20100101 Firefox/140.0"
+		iphone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 Version/18.0 Mobile Safari/604.1"
+	)
+
+	t.Run("records session device", func(t *testing.T) {
+		token := login("alice", firefox, "DE")
+		sess, ok := svc.SessionInfo(t.Context(), token)
+		require.True(t, ok)
+		assert.Equal(t, "Firefox on Linux", sess.Device)
+		assert.Equal(t, firefox, sess.UserAgent)
+		assert.Equal(t, "203.0.113.7", sess.IP)
+		assert.Equal(t, "DE", sess.Location)
+
+		sessions, err := svc.UserSessions(t.Context(), "alice")
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, token, sessions[0].Token)
+	})
+
+	t.Run("notifies on new device only", func(t *testing.T) {
+		login("alice", firefox, "DE")
+		login("alice", iphone, "XX")
+		select {
+		case ev := <-notified:
+			assert.Equal(t, "alice", ev.Username)
+			assert.Equal(t, "alice@example.com", ev.Email)
+			assert.Equal(t, "Safari on iOS", ev.Device)
+			assert.Empty(t, ev.Location, "unknown country is dropped")
+		case <-time.After(time.Second):
+			t.Fatal("no notification")
+		}
+		login("alice", iphone, "FR")
+		assert.Never(t, func() bool { return len(notified) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("no notification without email", func(t *testing.T) {
+		login("bob", firefox, "")
+		login("bob", iphone, "")
+		assert.Never(t, func() bool { return len(notified) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		require.Error(t, svc.RecordLogin(req, "no-such-token", "alice"))
+	})
+}
+
+func TestDeviceName(t *testing.T) {
+	tbl := []struct{ ua, want string }{
+		{"", ""},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36 Edg/138.0.0.0",
+			"Edge on Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36",
+			"Chrome on macOS"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+			"Safari on macOS"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Mobile Safari/537.36",
+			"Chrome on Android"},
+		{"Mozilla/5.0 (iPad; CPU OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/138.0 Mobile/15E148 Safari/604.1",
+			"Chrome on iPadOS"},
+		{"curl/8.7.1", "curl"},
+		{"SomeBot/1.0", "Unknown browser"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.want, deviceName(tt.ua), tt.ua)
+	}
+}
+
+func TestRequestDevice(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+	req.Header.Set("User-Agent", "Firefox/1.0 "+string(make([]byte, 1000)))
+	req.Header.Set("X-Location", "Berlin, DE")
+	dev := requestDevice(req, "")
+	assert.Len(t, dev.UserAgent, maxUserAgent)
+	assert.Empty(t, dev.Location, "location header is not trusted unless set")
+	assert.Equal(t, "Berlin, DE", requestDevice(req, "X-Location").Location)
+}
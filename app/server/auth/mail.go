@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// MailConfig is the SMTP server sending login notifications.
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string // SMTP auth is skipped if empty
+	Password string
+	From     string
+}
+
+// Mailer sends login notifications by email. STARTTLS is used if the server supports it.
+type Mailer struct {
+	cfg MailConfig
+}
+
+// NewMailer creates a mailer, host and from address are required.
+func NewMailer(cfg MailConfig) (*Mailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	if cfg.From == "" {
+		return nil, errors.New("from address is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 25
+	}
+	return &Mailer{cfg: cfg}, nil
+}
+
+// NotifyLogin emails the user about the login.
+func (m *Mailer) NotifyLogin(ctx context.Context, ev LoginEvent) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline) // smtp client has no context, the deadline bounds the whole exchange
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		// PlainAuth refuses to send the password over a connection without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := c.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	if err := c.Rcpt(ev.Email); err != nil {
+		return fmt.Errorf("failed to set recipient: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(m.message(ev)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("failed to close smtp session: %w", err)
+	}
+	return nil
+}
+
+// message returns the notification email with headers.
+func (m *Mailer) message(ev LoginEvent) []byte {
+	location := ev.Location
+	if location == "" {
+		location = "unknown"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: New login to stash\r\nDate: %s\r\n", m.cfg.From, ev.Email,
+		ev.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&buf, "Your stash account %q was used to log in from a new device.\r\n\r\n", ev.Username)
+	fmt.Fprintf(&buf, "Time:     %s\r\nDevice:   %s\r\nIP:       %s\r\nLocation: %s\r\n\r\n",
+		ev.Time.UTC().Format(time.RFC1123), ev.Device, ev.IP, location)
+	buf.WriteString("If this wasn't you, change your password and ask an admin to end your sessions.\r\n")
+	return buf.Bytes()
+}
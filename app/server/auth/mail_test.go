@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// fakeSMTP accepts one message over plain SMTP and sends the envelope and data to the returned channel.
+func fakeSMTP(t *testing.T) (port int, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+		reply := func(s string) { _, _ = w.WriteString(s + "\r\n"); _ = w.Flush() }
+		reply("220 fake ESMTP")
+		var msg strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				msg.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					msg.WriteString(data)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				ch <- msg.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	_, p, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	port, err = strconv.Atoi(p)
+	require.NoError(t, err)
+	return port, ch
+}
+
+func TestMailer_NotifyLogin(t *testing.T) {
+	port, received := fakeSMTP(t)
+	m, err := NewMailer(MailConfig{Host: "127.0.0.1", Port: port, From: "stash@example.com"})
+	require.NoError(t, err)
+
+	ev := LoginEvent{Username: "alice", Email: "alice@example.com", Time: time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC),
+		SessionDevice: store.SessionDevice{Device: "Safari on iOS", IP: "203.0.113.7", Location: "DE"}}
+	require.NoError(t, m.NotifyLogin(t.Context(), ev))
+
+	select {
+	case msg := <-received:
+		assert.Contains(t, msg, "MAIL FROM:<stash@example.com>")
+		assert.Contains(t, msg, "RCPT TO:<alice@example.com>")
+		assert.Contains(t, msg, "Subject: New login to stash\r\n")
+		assert.Contains(t, msg, `Your stash account "alice" was used to log in from a new device.`)
+		assert.Contains(t, msg, "Device:   Safari on iOS\r\n")
+		assert.Contains(t, msg, "IP:       203.0.113.7\r\n")
+		assert.Contains(t, msg, "Location: DE\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	_, err := NewMailer(MailConfig{From: "stash@example.com"})
+	require.EqualError(t, err, "smtp host is required")
+	_, err = NewMailer(MailConfig{Host: "smtp.example.com"})
+	require.EqualError(t, err, "from address is required")
+	m, err := NewMailer(MailConfig{Host: "smtp.example.com", From: "stash@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 25, m.cfg.Port)
+}
@@ -3,23 +3,31 @@ package auth
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/go-pkgz/lgr"
 
+	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/server/internal/cookie"
 	"github.com/umputun/stash/app/store"
 )
 
+// SessionExpiresHeader is the response header with the session expiration, in RFC 3339 format.
+// The web UI uses it to schedule the session expiration warning.
+const SessionExpiresHeader = "X-Session-Expires"
+
 // SessionMiddleware returns middleware that requires a valid session cookie.
 // Used for web UI routes. Redirects to loginURL if not authenticated.
 // For HTMX requests, uses HX-Redirect header to trigger full page navigation.
+// Requests renew sliding sessions, the resulting expiration is reported in SessionExpiresHeader.
 func (s *Service) SessionMiddleware(loginURL string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// check session cookie
 			for _, cookieName := range cookie.SessionCookieNames {
 				if c, err := r.Cookie(cookieName); err == nil {
-					if _, ok := s.GetSessionUser(r.Context(), c.Value); ok {
+					if sess, ok := s.touchSession(r.Context(), c.Value); ok {
+						w.Header().Set(SessionExpiresHeader, sess.ExpiresAt.Format(time.RFC3339))
 						next.ServeHTTP(w, r)
 						return
 					}
@@ -43,27 +51,36 @@ func (s *Service) SessionMiddleware(loginURL string) func(http.Handler) http.Han
 // Accepts X-Auth-Token header or Authorization: Bearer <token>. Used for API routes.
 // Returns 401/403 if not authorized.
 // Public access (token="*") is checked first and allows unauthenticated requests.
+// Requests without a token are accepted from SPIFFE workloads identified by mTLS client certificate.
 // For list operations (empty key), only validates token existence, filtering happens in handler.
+// Tokens with scopes are also limited to the operations listed in their scopes.
 func (s *Service) TokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := store.NormalizeKey(strings.TrimPrefix(r.URL.Path, "/kv/"))
-		needWrite := r.Method == http.MethodPut || r.Method == http.MethodDelete
-		isList := key == "" && r.Method == http.MethodGet // list operation has no key
+		needWrite := r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete
+		// list operation has no key, export, import and transactions cover many keys, their handlers check permissions of each
+		isList := (key == "" && r.Method == http.MethodGet) || isBulk(r, key)
+		scope := requestScope(r, key)
+		if scope == enum.ScopeHistory {
+			key = strings.TrimPrefix(key, "history/") // permissions apply to the key itself
+		}
 
 		// check public access first (token="*" in config)
 		// for list operation, public access means pass-through (handler filters results)
 		s.mu.RLock()
 		publicACL := s.publicACL
 		s.mu.RUnlock()
-		if publicACL != nil {
-			if isList || publicACL.CheckKeyPermission(key, needWrite) {
+		md := requestMetadata(r)
+		if publicACL != nil && publicACL.AllowsScope(scope) {
+			if isList || s.allowed(r.Context(), PolicyInput{Actor: PolicyActor{Type: "public"}, Key: key, Action: scope.String(),
+				ACL: publicACL.CheckKeyPermission(key, needWrite), Metadata: md}) {
 				next.ServeHTTP(w, r)
 				return
 			}
 		}
 
 		// also accept session cookie for API (allows UI to call API)
-		if allowed, handled := s.checkSessionAuth(r, key, needWrite, isList, w); handled {
+		if allowed, handled := s.checkSessionAuth(r, key, scope, isList, w); handled {
 			if !allowed {
 				return // already wrote error response
 			}
@@ -71,27 +88,29 @@ func (s *Service) TokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// check API token (X-Auth-Token or Bearer)
-		token := ExtractToken(r)
-		if token == "" {
+		// check API token (X-Auth-Token or Bearer), or workload identity of mTLS client without a token
+		acl, name, ok := s.requestACL(r)
+		if !ok {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		s.recordTokenUse(r, acl)
 
-		// check if token exists
-		if _, ok := s.getTokenACL(token); !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if !acl.AllowsScope(scope) {
+			log.Printf("[INFO] %s denied %s operation on key %q, not in scopes", name, scope, key)
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
-		// for list operation, just verify token exists (handler filters results)
+		// for list operation, just verify the client is known (handler filters results)
 		if isList {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		if !s.checkPermission(token, key, needWrite) {
-			log.Printf("[INFO] token %q denied %s access to key %q", MaskToken(token), r.Method, key)
+		if !s.allowed(r.Context(), PolicyInput{Actor: PolicyActor{Type: "token", Name: name, Admin: acl.Admin}, Key: key,
+			Action: scope.String(), ACL: acl.CheckKeyPermission(key, needWrite), Metadata: md}) {
+			log.Printf("[INFO] %s denied %s access to key %q", name, r.Method, key)
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
@@ -100,6 +119,33 @@ func (s *Service) TokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requestScope returns the operation of the API request, matching the api routes.
+// The csv output of the key list is an export, history requests come with "history/" key prefix.
+// Import needs the write scope, the handler allows it to admins only. Transactions need the write scope too,
+// their handler checks the scope of each operation.
+func requestScope(r *http.Request, key string) enum.Scope {
+	switch {
+	case r.Method == http.MethodPut, r.Method == http.MethodPatch, r.Method == http.MethodPost && (key == "_import" || key == "_txn"):
+		return enum.ScopeWrite
+	case r.Method == http.MethodDelete:
+		return enum.ScopeDelete
+	case key == "" && r.URL.Query().Get("output") == "csv", r.Method == http.MethodGet && key == "_export":
+		return enum.ScopeExport
+	case key == "":
+		return enum.ScopeList
+	case strings.HasPrefix(key, "history/"):
+		return enum.ScopeHistory
+	default:
+		return enum.ScopeRead
+	}
+}
+
+// isBulk reports whether the request is a prefix export, import or transaction, GET /kv/_export,
+// POST /kv/_import or POST /kv/_txn.
+func isBulk(r *http.Request, key string) bool {
+	return (r.Method == http.MethodGet && key == "_export") || (r.Method == http.MethodPost && (key == "_import" || key == "_txn"))
+}
+
 // MaskToken returns a masked version of token for safe logging (shows first 4 chars).
 func MaskToken(token string) string {
 	if len(token) <= 4 {
@@ -126,7 +172,7 @@ func ExtractToken(r *http.Request) string {
 // checkSessionAuth checks if request has valid session cookie with appropriate permissions.
 // returns (allowed, handled): handled=true means caller should return (either success or error written).
 // allowed=false with handled=true means error response was written.
-func (s *Service) checkSessionAuth(r *http.Request, key string, needWrite, isList bool,
+func (s *Service) checkSessionAuth(r *http.Request, key string, scope enum.Scope, isList bool,
 	w http.ResponseWriter) (allowed, handled bool) {
 	for _, cookieName := range cookie.SessionCookieNames {
 		c, err := r.Cookie(cookieName)
@@ -142,7 +188,7 @@ func (s *Service) checkSessionAuth(r *http.Request, key string, needWrite, isLis
 			return true, true
 		}
 		// check user permissions for the key
-		if !s.CheckUserPermission(username, key, needWrite) {
+		if !s.checkUserPermission(r.Context(), username, key, scope, requestMetadata(r)) {
 			log.Printf("[INFO] user %q denied %s access to key %q", username, r.Method, key)
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return false, true
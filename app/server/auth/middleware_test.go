@@ -36,7 +36,7 @@ users:
 	assert.Equal(t, "/login", rec.Header().Get("Location"))
 
 	// with valid session should pass
-	token, err := svc.CreateSession(t.Context(), "admin")
+	token, err := svc.CreateSession(t.Context(), "admin", false)
 	require.NoError(t, err)
 
 	req = httptest.NewRequest("GET", "/", http.NoBody)
@@ -44,6 +44,9 @@ users:
 	rec = httptest.NewRecorder()
 	handler.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	expires, err := time.Parse(time.RFC3339, rec.Header().Get(SessionExpiresHeader))
+	require.NoError(t, err, "session expiration reported")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 5*time.Second)
 
 	// HTMX request without session should return 401 with HX-Redirect header
 	req = httptest.NewRequest("GET", "/web/keys", http.NoBody)
@@ -112,7 +115,7 @@ tokens:
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 
 	// session cookie should also work for API
-	token, err := svc.CreateSession(t.Context(), "admin")
+	token, err := svc.CreateSession(t.Context(), "admin", false)
 	require.NoError(t, err)
 
 	req = httptest.NewRequest("GET", "/kv/test", http.NoBody)
@@ -160,6 +163,78 @@ tokens:
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 }
 
+func TestService_TokenMiddleware_Scopes(t *testing.T) {
+	content := `
+tokens:
+  - token: "backup"
+    permissions:
+      - prefix: "*"
+        access: rw
+    scopes: [read, export]
+  - token: "writer"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+    scopes: [list, read, write, history]
+  - token: "*"
+    permissions:
+      - prefix: "public/*"
+        access: r
+    scopes: [read]
+`
+	f := createTempFile(t, content)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil)
+	require.NoError(t, err)
+
+	handler := svc.TokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		expect int
+	}{
+		{"backup reads", "GET", "/kv/app/cfg", "backup", http.StatusOK},
+		{"backup exports", "GET", "/kv/?output=csv", "backup", http.StatusOK},
+		{"backup can't list", "GET", "/kv/", "backup", http.StatusForbidden},
+		{"backup can't write", "PUT", "/kv/app/cfg", "backup", http.StatusForbidden},
+		{"backup can't delete", "DELETE", "/kv/app/cfg", "backup", http.StatusForbidden},
+		{"backup can't read history", "GET", "/kv/history/app/cfg", "backup", http.StatusForbidden},
+		{"writer lists", "GET", "/kv/", "writer", http.StatusOK},
+		{"writer writes", "PUT", "/kv/app/cfg", "writer", http.StatusOK},
+		{"writer patches", "PATCH", "/kv/app/cfg", "writer", http.StatusOK},
+		{"backup can't patch", "PATCH", "/kv/app/cfg", "backup", http.StatusForbidden},
+		{"writer reads history", "GET", "/kv/history/app/cfg", "writer", http.StatusOK},
+		{"writer can't delete", "DELETE", "/kv/app/cfg", "writer", http.StatusForbidden},
+		{"writer can't export", "GET", "/kv/?output=csv", "writer", http.StatusForbidden},
+		{"writer prefix still checked", "PUT", "/kv/other", "writer", http.StatusForbidden},
+		{"backup exports bundle", "GET", "/kv/_export?prefix=other/", "backup", http.StatusOK},
+		{"writer can't export bundle", "GET", "/kv/_export", "writer", http.StatusForbidden},
+		{"writer reaches import", "POST", "/kv/_import", "writer", http.StatusOK},
+		{"backup can't import", "POST", "/kv/_import", "backup", http.StatusForbidden},
+		{"writer reaches transaction", "POST", "/kv/_txn", "writer", http.StatusOK},
+		{"backup can't run transaction", "POST", "/kv/_txn", "backup", http.StatusForbidden},
+		{"public reads", "GET", "/kv/public/info", "", http.StatusOK},
+		{"public can't patch read-only key", "PATCH", "/kv/public/info", "", http.StatusUnauthorized},
+		{"public can't list", "GET", "/kv/", "", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("X-Auth-Token", tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expect, rec.Code)
+		})
+	}
+}
+
 func TestService_TokenMiddleware_KeyNormalization(t *testing.T) {
 	// ACL for "foo_bar" should match requests for "/kv/foo bar", "/kv/foo_bar/", etc.
 	content := `
@@ -225,7 +300,7 @@ users:
 	}))
 
 	// create session for read-only user
-	sessionToken, err := svc.CreateSession(t.Context(), "readonly")
+	sessionToken, err := svc.CreateSession(t.Context(), "readonly", false)
 	require.NoError(t, err)
 
 	t.Run("readonly user cannot PUT via session cookie", func(t *testing.T) {
@@ -261,7 +336,7 @@ users:
 	})
 
 	// create session for scoped user (app/* only)
-	scopedSession, err := svc.CreateSession(t.Context(), "scoped")
+	scopedSession, err := svc.CreateSession(t.Context(), "scoped", false)
 	require.NoError(t, err)
 
 	t.Run("scoped user can write to allowed prefix via session cookie", func(t *testing.T) {
@@ -428,7 +503,7 @@ tokens:
 	})
 
 	t.Run("list with session cookie passes through", func(t *testing.T) {
-		sessionToken, err := svc.CreateSession(t.Context(), "admin")
+		sessionToken, err := svc.CreateSession(t.Context(), "admin", false)
 		require.NoError(t, err)
 
 		req := httptest.NewRequest("GET", "/kv/", http.NoBody)
@@ -7,6 +7,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/umputun/stash/app/store"
 )
 
 // SessionStoreMock is a mock implementation of auth.SessionStore.
@@ -15,24 +17,78 @@ import (
 //
 //		// make and configure a mocked auth.SessionStore
 //		mockedSessionStore := &SessionStoreMock{
-//			CreateSessionFunc: func(ctx context.Context, token string, username string, expiresAt time.Time) error {
+//			AddDelegatedTokenFunc: func(ctx context.Context, t store.DelegatedToken) error {
+//				panic("mock out the AddDelegatedToken method")
+//			},
+//			AddPasskeyFunc: func(ctx context.Context, p store.Passkey) error {
+//				panic("mock out the AddPasskey method")
+//			},
+//			CreateSessionFunc: func(ctx context.Context, token string, username string, expiresAt time.Time, remember bool) error {
 //				panic("mock out the CreateSession method")
 //			},
+//			DelegatedTokensFunc: func(ctx context.Context) ([]store.DelegatedToken, error) {
+//				panic("mock out the DelegatedTokens method")
+//			},
 //			DeleteAllSessionsFunc: func(ctx context.Context) error {
 //				panic("mock out the DeleteAllSessions method")
 //			},
+//			DeleteDelegatedTokenFunc: func(ctx context.Context, fingerprint string) error {
+//				panic("mock out the DeleteDelegatedToken method")
+//			},
+//			DeleteExpiredNoncesFunc: func(ctx context.Context) (int64, error) {
+//				panic("mock out the DeleteExpiredNonces method")
+//			},
 //			DeleteExpiredSessionsFunc: func(ctx context.Context) (int64, error) {
 //				panic("mock out the DeleteExpiredSessions method")
 //			},
+//			DeletePasskeyFunc: func(ctx context.Context, username string, id string) error {
+//				panic("mock out the DeletePasskey method")
+//			},
+//			DeletePasskeysFunc: func(ctx context.Context, username string) (int64, error) {
+//				panic("mock out the DeletePasskeys method")
+//			},
 //			DeleteSessionFunc: func(ctx context.Context, token string) error {
 //				panic("mock out the DeleteSession method")
 //			},
 //			DeleteSessionsByUsernameFunc: func(ctx context.Context, username string) error {
 //				panic("mock out the DeleteSessionsByUsername method")
 //			},
-//			GetSessionFunc: func(ctx context.Context, token string) (string, time.Time, error) {
+//			ExtendSessionFunc: func(ctx context.Context, token string, expiresAt time.Time) error {
+//				panic("mock out the ExtendSession method")
+//			},
+//			GetDelegatedTokenFunc: func(ctx context.Context, fingerprint string) (store.DelegatedToken, error) {
+//				panic("mock out the GetDelegatedToken method")
+//			},
+//			GetPasskeyFunc: func(ctx context.Context, id string) (store.Passkey, error) {
+//				panic("mock out the GetPasskey method")
+//			},
+//			GetSessionFunc: func(ctx context.Context, token string) (store.Session, error) {
 //				panic("mock out the GetSession method")
 //			},
+//			PasskeysFunc: func(ctx context.Context, username string) ([]store.Passkey, error) {
+//				panic("mock out the Passkeys method")
+//			},
+//			SetSessionDeviceFunc: func(ctx context.Context, token string, dev store.SessionDevice) (bool, error) {
+//				panic("mock out the SetSessionDevice method")
+//			},
+//			SyncTokenUsageFunc: func(ctx context.Context, fingerprints []string) error {
+//				panic("mock out the SyncTokenUsage method")
+//			},
+//			TokenUsagesFunc: func(ctx context.Context) ([]store.TokenUsage, error) {
+//				panic("mock out the TokenUsages method")
+//			},
+//			TouchTokenFunc: func(ctx context.Context, fingerprint string, ip string, at time.Time) error {
+//				panic("mock out the TouchToken method")
+//			},
+//			UseNonceFunc: func(ctx context.Context, nonce string, at time.Time, expiresAt time.Time) (bool, error) {
+//				panic("mock out the UseNonce method")
+//			},
+//			UsePasskeyFunc: func(ctx context.Context, id string, signCount int64) error {
+//				panic("mock out the UsePasskey method")
+//			},
+//			UserSessionsFunc: func(ctx context.Context, username string) ([]store.Session, error) {
+//				panic("mock out the UserSessions method")
+//			},
 //		}
 //
 //		// use mockedSessionStore in code that requires auth.SessionStore
@@ -40,26 +96,94 @@ import (
 //
 //	}
 type SessionStoreMock struct {
+	// AddDelegatedTokenFunc mocks the AddDelegatedToken method.
+	AddDelegatedTokenFunc func(ctx context.Context, t store.DelegatedToken) error
+
+	// AddPasskeyFunc mocks the AddPasskey method.
+	AddPasskeyFunc func(ctx context.Context, p store.Passkey) error
+
 	// CreateSessionFunc mocks the CreateSession method.
-	CreateSessionFunc func(ctx context.Context, token string, username string, expiresAt time.Time) error
+	CreateSessionFunc func(ctx context.Context, token string, username string, expiresAt time.Time, remember bool) error
+
+	// DelegatedTokensFunc mocks the DelegatedTokens method.
+	DelegatedTokensFunc func(ctx context.Context) ([]store.DelegatedToken, error)
 
 	// DeleteAllSessionsFunc mocks the DeleteAllSessions method.
 	DeleteAllSessionsFunc func(ctx context.Context) error
 
+	// DeleteDelegatedTokenFunc mocks the DeleteDelegatedToken method.
+	DeleteDelegatedTokenFunc func(ctx context.Context, fingerprint string) error
+
+	// DeleteExpiredNoncesFunc mocks the DeleteExpiredNonces method.
+	DeleteExpiredNoncesFunc func(ctx context.Context) (int64, error)
+
 	// DeleteExpiredSessionsFunc mocks the DeleteExpiredSessions method.
 	DeleteExpiredSessionsFunc func(ctx context.Context) (int64, error)
 
+	// DeletePasskeyFunc mocks the DeletePasskey method.
+	DeletePasskeyFunc func(ctx context.Context, username string, id string) error
+
+	// DeletePasskeysFunc mocks the DeletePasskeys method.
+	DeletePasskeysFunc func(ctx context.Context, username string) (int64, error)
+
 	// DeleteSessionFunc mocks the DeleteSession method.
 	DeleteSessionFunc func(ctx context.Context, token string) error
 
 	// DeleteSessionsByUsernameFunc mocks the DeleteSessionsByUsername method.
 	DeleteSessionsByUsernameFunc func(ctx context.Context, username string) error
 
+	// ExtendSessionFunc mocks the ExtendSession method.
+	ExtendSessionFunc func(ctx context.Context, token string, expiresAt time.Time) error
+
+	// GetDelegatedTokenFunc mocks the GetDelegatedToken method.
+	GetDelegatedTokenFunc func(ctx context.Context, fingerprint string) (store.DelegatedToken, error)
+
+	// GetPasskeyFunc mocks the GetPasskey method.
+	GetPasskeyFunc func(ctx context.Context, id string) (store.Passkey, error)
+
 	// GetSessionFunc mocks the GetSession method.
-	GetSessionFunc func(ctx context.Context, token string) (string, time.Time, error)
+	GetSessionFunc func(ctx context.Context, token string) (store.Session, error)
+
+	// PasskeysFunc mocks the Passkeys method.
+	PasskeysFunc func(ctx context.Context, username string) ([]store.Passkey, error)
+
+	// SetSessionDeviceFunc mocks the SetSessionDevice method.
+	SetSessionDeviceFunc func(ctx context.Context, token string, dev store.SessionDevice) (bool, error)
+
+	// SyncTokenUsageFunc mocks the SyncTokenUsage method.
+	SyncTokenUsageFunc func(ctx context.Context, fingerprints []string) error
+
+	// TokenUsagesFunc mocks the TokenUsages method.
+	TokenUsagesFunc func(ctx context.Context) ([]store.TokenUsage, error)
+
+	// TouchTokenFunc mocks the TouchToken method.
+	TouchTokenFunc func(ctx context.Context, fingerprint string, ip string, at time.Time) error
+
+	// UseNonceFunc mocks the UseNonce method.
+	UseNonceFunc func(ctx context.Context, nonce string, at time.Time, expiresAt time.Time) (bool, error)
+
+	// UsePasskeyFunc mocks the UsePasskey method.
+	UsePasskeyFunc func(ctx context.Context, id string, signCount int64) error
+
+	// UserSessionsFunc mocks the UserSessions method.
+	UserSessionsFunc func(ctx context.Context, username string) ([]store.Session, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// AddDelegatedToken holds details about calls to the AddDelegatedToken method.
+		AddDelegatedToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T store.DelegatedToken
+		}
+		// AddPasskey holds details about calls to the AddPasskey method.
+		AddPasskey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// P is the p argument value.
+			P store.Passkey
+		}
 		// CreateSession holds details about calls to the CreateSession method.
 		CreateSession []struct {
 			// Ctx is the ctx argument value.
@@ -70,17 +194,52 @@ type SessionStoreMock struct {
 			Username string
 			// ExpiresAt is the expiresAt argument value.
 			ExpiresAt time.Time
+			// Remember is the remember argument value.
+			Remember bool
+		}
+		// DelegatedTokens holds details about calls to the DelegatedTokens method.
+		DelegatedTokens []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 		}
 		// DeleteAllSessions holds details about calls to the DeleteAllSessions method.
 		DeleteAllSessions []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// DeleteDelegatedToken holds details about calls to the DeleteDelegatedToken method.
+		DeleteDelegatedToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fingerprint is the fingerprint argument value.
+			Fingerprint string
+		}
+		// DeleteExpiredNonces holds details about calls to the DeleteExpiredNonces method.
+		DeleteExpiredNonces []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// DeleteExpiredSessions holds details about calls to the DeleteExpiredSessions method.
 		DeleteExpiredSessions []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// DeletePasskey holds details about calls to the DeletePasskey method.
+		DeletePasskey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// ID is the id argument value.
+			ID string
+		}
+		// DeletePasskeys holds details about calls to the DeletePasskeys method.
+		DeletePasskeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
 		// DeleteSession holds details about calls to the DeleteSession method.
 		DeleteSession []struct {
 			// Ctx is the ctx argument value.
@@ -95,6 +254,29 @@ type SessionStoreMock struct {
 			// Username is the username argument value.
 			Username string
 		}
+		// ExtendSession holds details about calls to the ExtendSession method.
+		ExtendSession []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token string
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// GetDelegatedToken holds details about calls to the GetDelegatedToken method.
+		GetDelegatedToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fingerprint is the fingerprint argument value.
+			Fingerprint string
+		}
+		// GetPasskey holds details about calls to the GetPasskey method.
+		GetPasskey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
 		// GetSession holds details about calls to the GetSession method.
 		GetSession []struct {
 			// Ctx is the ctx argument value.
@@ -102,17 +284,173 @@ type SessionStoreMock struct {
 			// Token is the token argument value.
 			Token string
 		}
+		// Passkeys holds details about calls to the Passkeys method.
+		Passkeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// SetSessionDevice holds details about calls to the SetSessionDevice method.
+		SetSessionDevice []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token string
+			// Dev is the dev argument value.
+			Dev store.SessionDevice
+		}
+		// SyncTokenUsage holds details about calls to the SyncTokenUsage method.
+		SyncTokenUsage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fingerprints is the fingerprints argument value.
+			Fingerprints []string
+		}
+		// TokenUsages holds details about calls to the TokenUsages method.
+		TokenUsages []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// TouchToken holds details about calls to the TouchToken method.
+		TouchToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fingerprint is the fingerprint argument value.
+			Fingerprint string
+			// IP is the ip argument value.
+			IP string
+			// At is the at argument value.
+			At time.Time
+		}
+		// UseNonce holds details about calls to the UseNonce method.
+		UseNonce []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Nonce is the nonce argument value.
+			Nonce string
+			// At is the at argument value.
+			At time.Time
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// UsePasskey holds details about calls to the UsePasskey method.
+		UsePasskey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// SignCount is the signCount argument value.
+			SignCount int64
+		}
+		// UserSessions holds details about calls to the UserSessions method.
+		UserSessions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
 	}
+	lockAddDelegatedToken        sync.RWMutex
+	lockAddPasskey               sync.RWMutex
 	lockCreateSession            sync.RWMutex
+	lockDelegatedTokens          sync.RWMutex
 	lockDeleteAllSessions        sync.RWMutex
+	lockDeleteDelegatedToken     sync.RWMutex
+	lockDeleteExpiredNonces      sync.RWMutex
 	lockDeleteExpiredSessions    sync.RWMutex
+	lockDeletePasskey            sync.RWMutex
+	lockDeletePasskeys           sync.RWMutex
 	lockDeleteSession            sync.RWMutex
 	lockDeleteSessionsByUsername sync.RWMutex
+	lockExtendSession            sync.RWMutex
+	lockGetDelegatedToken        sync.RWMutex
+	lockGetPasskey               sync.RWMutex
 	lockGetSession               sync.RWMutex
+	lockPasskeys                 sync.RWMutex
+	lockSetSessionDevice         sync.RWMutex
+	lockSyncTokenUsage           sync.RWMutex
+	lockTokenUsages              sync.RWMutex
+	lockTouchToken               sync.RWMutex
+	lockUseNonce                 sync.RWMutex
+	lockUsePasskey               sync.RWMutex
+	lockUserSessions             sync.RWMutex
+}
+
+// AddDelegatedToken calls AddDelegatedTokenFunc.
+func (mock *SessionStoreMock) AddDelegatedToken(ctx context.Context, t store.DelegatedToken) error {
+	if mock.AddDelegatedTokenFunc == nil {
+		panic("SessionStoreMock.AddDelegatedTokenFunc: method is nil but SessionStore.AddDelegatedToken was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		T   store.DelegatedToken
+	}{
+		Ctx: ctx,
+		T:   t,
+	}
+	mock.lockAddDelegatedToken.Lock()
+	mock.calls.AddDelegatedToken = append(mock.calls.AddDelegatedToken, callInfo)
+	mock.lockAddDelegatedToken.Unlock()
+	return mock.AddDelegatedTokenFunc(ctx, t)
+}
+
+// AddDelegatedTokenCalls gets all the calls that were made to AddDelegatedToken.
+// Check the length with:
+//
+//	len(mockedSessionStore.AddDelegatedTokenCalls())
+func (mock *SessionStoreMock) AddDelegatedTokenCalls() []struct {
+	Ctx context.Context
+	T   store.DelegatedToken
+} {
+	var calls []struct {
+		Ctx context.Context
+		T   store.DelegatedToken
+	}
+	mock.lockAddDelegatedToken.RLock()
+	calls = mock.calls.AddDelegatedToken
+	mock.lockAddDelegatedToken.RUnlock()
+	return calls
+}
+
+// AddPasskey calls AddPasskeyFunc.
+func (mock *SessionStoreMock) AddPasskey(ctx context.Context, p store.Passkey) error {
+	if mock.AddPasskeyFunc == nil {
+		panic("SessionStoreMock.AddPasskeyFunc: method is nil but SessionStore.AddPasskey was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		P   store.Passkey
+	}{
+		Ctx: ctx,
+		P:   p,
+	}
+	mock.lockAddPasskey.Lock()
+	mock.calls.AddPasskey = append(mock.calls.AddPasskey, callInfo)
+	mock.lockAddPasskey.Unlock()
+	return mock.AddPasskeyFunc(ctx, p)
+}
+
+// AddPasskeyCalls gets all the calls that were made to AddPasskey.
+// Check the length with:
+//
+//	len(mockedSessionStore.AddPasskeyCalls())
+func (mock *SessionStoreMock) AddPasskeyCalls() []struct {
+	Ctx context.Context
+	P   store.Passkey
+} {
+	var calls []struct {
+		Ctx context.Context
+		P   store.Passkey
+	}
+	mock.lockAddPasskey.RLock()
+	calls = mock.calls.AddPasskey
+	mock.lockAddPasskey.RUnlock()
+	return calls
 }
 
 // CreateSession calls CreateSessionFunc.
-func (mock *SessionStoreMock) CreateSession(ctx context.Context, token string, username string, expiresAt time.Time) error {
+func (mock *SessionStoreMock) CreateSession(ctx context.Context, token string, username string, expiresAt time.Time, remember bool) error {
 	if mock.CreateSessionFunc == nil {
 		panic("SessionStoreMock.CreateSessionFunc: method is nil but SessionStore.CreateSession was just called")
 	}
@@ -121,16 +459,18 @@ func (mock *SessionStoreMock) CreateSession(ctx context.Context, token string, u
 		Token     string
 		Username  string
 		ExpiresAt time.Time
+		Remember  bool
 	}{
 		Ctx:       ctx,
 		Token:     token,
 		Username:  username,
 		ExpiresAt: expiresAt,
+		Remember:  remember,
 	}
 	mock.lockCreateSession.Lock()
 	mock.calls.CreateSession = append(mock.calls.CreateSession, callInfo)
 	mock.lockCreateSession.Unlock()
-	return mock.CreateSessionFunc(ctx, token, username, expiresAt)
+	return mock.CreateSessionFunc(ctx, token, username, expiresAt, remember)
 }
 
 // CreateSessionCalls gets all the calls that were made to CreateSession.
@@ -142,12 +482,14 @@ func (mock *SessionStoreMock) CreateSessionCalls() []struct {
 	Token     string
 	Username  string
 	ExpiresAt time.Time
+	Remember  bool
 } {
 	var calls []struct {
 		Ctx       context.Context
 		Token     string
 		Username  string
 		ExpiresAt time.Time
+		Remember  bool
 	}
 	mock.lockCreateSession.RLock()
 	calls = mock.calls.CreateSession
@@ -155,6 +497,38 @@ func (mock *SessionStoreMock) CreateSessionCalls() []struct {
 	return calls
 }
 
+// DelegatedTokens calls DelegatedTokensFunc.
+func (mock *SessionStoreMock) DelegatedTokens(ctx context.Context) ([]store.DelegatedToken, error) {
+	if mock.DelegatedTokensFunc == nil {
+		panic("SessionStoreMock.DelegatedTokensFunc: method is nil but SessionStore.DelegatedTokens was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockDelegatedTokens.Lock()
+	mock.calls.DelegatedTokens = append(mock.calls.DelegatedTokens, callInfo)
+	mock.lockDelegatedTokens.Unlock()
+	return mock.DelegatedTokensFunc(ctx)
+}
+
+// DelegatedTokensCalls gets all the calls that were made to DelegatedTokens.
+// Check the length with:
+//
+//	len(mockedSessionStore.DelegatedTokensCalls())
+func (mock *SessionStoreMock) DelegatedTokensCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockDelegatedTokens.RLock()
+	calls = mock.calls.DelegatedTokens
+	mock.lockDelegatedTokens.RUnlock()
+	return calls
+}
+
 // DeleteAllSessions calls DeleteAllSessionsFunc.
 func (mock *SessionStoreMock) DeleteAllSessions(ctx context.Context) error {
 	if mock.DeleteAllSessionsFunc == nil {
@@ -187,6 +561,74 @@ func (mock *SessionStoreMock) DeleteAllSessionsCalls() []struct {
 	return calls
 }
 
+// DeleteDelegatedToken calls DeleteDelegatedTokenFunc.
+func (mock *SessionStoreMock) DeleteDelegatedToken(ctx context.Context, fingerprint string) error {
+	if mock.DeleteDelegatedTokenFunc == nil {
+		panic("SessionStoreMock.DeleteDelegatedTokenFunc: method is nil but SessionStore.DeleteDelegatedToken was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Fingerprint string
+	}{
+		Ctx:         ctx,
+		Fingerprint: fingerprint,
+	}
+	mock.lockDeleteDelegatedToken.Lock()
+	mock.calls.DeleteDelegatedToken = append(mock.calls.DeleteDelegatedToken, callInfo)
+	mock.lockDeleteDelegatedToken.Unlock()
+	return mock.DeleteDelegatedTokenFunc(ctx, fingerprint)
+}
+
+// DeleteDelegatedTokenCalls gets all the calls that were made to DeleteDelegatedToken.
+// Check the length with:
+//
+//	len(mockedSessionStore.DeleteDelegatedTokenCalls())
+func (mock *SessionStoreMock) DeleteDelegatedTokenCalls() []struct {
+	Ctx         context.Context
+	Fingerprint string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Fingerprint string
+	}
+	mock.lockDeleteDelegatedToken.RLock()
+	calls = mock.calls.DeleteDelegatedToken
+	mock.lockDeleteDelegatedToken.RUnlock()
+	return calls
+}
+
+// DeleteExpiredNonces calls DeleteExpiredNoncesFunc.
+func (mock *SessionStoreMock) DeleteExpiredNonces(ctx context.Context) (int64, error) {
+	if mock.DeleteExpiredNoncesFunc == nil {
+		panic("SessionStoreMock.DeleteExpiredNoncesFunc: method is nil but SessionStore.DeleteExpiredNonces was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockDeleteExpiredNonces.Lock()
+	mock.calls.DeleteExpiredNonces = append(mock.calls.DeleteExpiredNonces, callInfo)
+	mock.lockDeleteExpiredNonces.Unlock()
+	return mock.DeleteExpiredNoncesFunc(ctx)
+}
+
+// DeleteExpiredNoncesCalls gets all the calls that were made to DeleteExpiredNonces.
+// Check the length with:
+//
+//	len(mockedSessionStore.DeleteExpiredNoncesCalls())
+func (mock *SessionStoreMock) DeleteExpiredNoncesCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockDeleteExpiredNonces.RLock()
+	calls = mock.calls.DeleteExpiredNonces
+	mock.lockDeleteExpiredNonces.RUnlock()
+	return calls
+}
+
 // DeleteExpiredSessions calls DeleteExpiredSessionsFunc.
 func (mock *SessionStoreMock) DeleteExpiredSessions(ctx context.Context) (int64, error) {
 	if mock.DeleteExpiredSessionsFunc == nil {
@@ -219,6 +661,82 @@ func (mock *SessionStoreMock) DeleteExpiredSessionsCalls() []struct {
 	return calls
 }
 
+// DeletePasskey calls DeletePasskeyFunc.
+func (mock *SessionStoreMock) DeletePasskey(ctx context.Context, username string, id string) error {
+	if mock.DeletePasskeyFunc == nil {
+		panic("SessionStoreMock.DeletePasskeyFunc: method is nil but SessionStore.DeletePasskey was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		ID       string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		ID:       id,
+	}
+	mock.lockDeletePasskey.Lock()
+	mock.calls.DeletePasskey = append(mock.calls.DeletePasskey, callInfo)
+	mock.lockDeletePasskey.Unlock()
+	return mock.DeletePasskeyFunc(ctx, username, id)
+}
+
+// DeletePasskeyCalls gets all the calls that were made to DeletePasskey.
+// Check the length with:
+//
+//	len(mockedSessionStore.DeletePasskeyCalls())
+func (mock *SessionStoreMock) DeletePasskeyCalls() []struct {
+	Ctx      context.Context
+	Username string
+	ID       string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		ID       string
+	}
+	mock.lockDeletePasskey.RLock()
+	calls = mock.calls.DeletePasskey
+	mock.lockDeletePasskey.RUnlock()
+	return calls
+}
+
+// DeletePasskeys calls DeletePasskeysFunc.
+func (mock *SessionStoreMock) DeletePasskeys(ctx context.Context, username string) (int64, error) {
+	if mock.DeletePasskeysFunc == nil {
+		panic("SessionStoreMock.DeletePasskeysFunc: method is nil but SessionStore.DeletePasskeys was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockDeletePasskeys.Lock()
+	mock.calls.DeletePasskeys = append(mock.calls.DeletePasskeys, callInfo)
+	mock.lockDeletePasskeys.Unlock()
+	return mock.DeletePasskeysFunc(ctx, username)
+}
+
+// DeletePasskeysCalls gets all the calls that were made to DeletePasskeys.
+// Check the length with:
+//
+//	len(mockedSessionStore.DeletePasskeysCalls())
+func (mock *SessionStoreMock) DeletePasskeysCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockDeletePasskeys.RLock()
+	calls = mock.calls.DeletePasskeys
+	mock.lockDeletePasskeys.RUnlock()
+	return calls
+}
+
 // DeleteSession calls DeleteSessionFunc.
 func (mock *SessionStoreMock) DeleteSession(ctx context.Context, token string) error {
 	if mock.DeleteSessionFunc == nil {
@@ -291,8 +809,120 @@ func (mock *SessionStoreMock) DeleteSessionsByUsernameCalls() []struct {
 	return calls
 }
 
+// ExtendSession calls ExtendSessionFunc.
+func (mock *SessionStoreMock) ExtendSession(ctx context.Context, token string, expiresAt time.Time) error {
+	if mock.ExtendSessionFunc == nil {
+		panic("SessionStoreMock.ExtendSessionFunc: method is nil but SessionStore.ExtendSession was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Token     string
+		ExpiresAt time.Time
+	}{
+		Ctx:       ctx,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}
+	mock.lockExtendSession.Lock()
+	mock.calls.ExtendSession = append(mock.calls.ExtendSession, callInfo)
+	mock.lockExtendSession.Unlock()
+	return mock.ExtendSessionFunc(ctx, token, expiresAt)
+}
+
+// ExtendSessionCalls gets all the calls that were made to ExtendSession.
+// Check the length with:
+//
+//	len(mockedSessionStore.ExtendSessionCalls())
+func (mock *SessionStoreMock) ExtendSessionCalls() []struct {
+	Ctx       context.Context
+	Token     string
+	ExpiresAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Token     string
+		ExpiresAt time.Time
+	}
+	mock.lockExtendSession.RLock()
+	calls = mock.calls.ExtendSession
+	mock.lockExtendSession.RUnlock()
+	return calls
+}
+
+// GetDelegatedToken calls GetDelegatedTokenFunc.
+func (mock *SessionStoreMock) GetDelegatedToken(ctx context.Context, fingerprint string) (store.DelegatedToken, error) {
+	if mock.GetDelegatedTokenFunc == nil {
+		panic("SessionStoreMock.GetDelegatedTokenFunc: method is nil but SessionStore.GetDelegatedToken was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Fingerprint string
+	}{
+		Ctx:         ctx,
+		Fingerprint: fingerprint,
+	}
+	mock.lockGetDelegatedToken.Lock()
+	mock.calls.GetDelegatedToken = append(mock.calls.GetDelegatedToken, callInfo)
+	mock.lockGetDelegatedToken.Unlock()
+	return mock.GetDelegatedTokenFunc(ctx, fingerprint)
+}
+
+// GetDelegatedTokenCalls gets all the calls that were made to GetDelegatedToken.
+// Check the length with:
+//
+//	len(mockedSessionStore.GetDelegatedTokenCalls())
+func (mock *SessionStoreMock) GetDelegatedTokenCalls() []struct {
+	Ctx         context.Context
+	Fingerprint string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Fingerprint string
+	}
+	mock.lockGetDelegatedToken.RLock()
+	calls = mock.calls.GetDelegatedToken
+	mock.lockGetDelegatedToken.RUnlock()
+	return calls
+}
+
+// GetPasskey calls GetPasskeyFunc.
+func (mock *SessionStoreMock) GetPasskey(ctx context.Context, id string) (store.Passkey, error) {
+	if mock.GetPasskeyFunc == nil {
+		panic("SessionStoreMock.GetPasskeyFunc: method is nil but SessionStore.GetPasskey was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetPasskey.Lock()
+	mock.calls.GetPasskey = append(mock.calls.GetPasskey, callInfo)
+	mock.lockGetPasskey.Unlock()
+	return mock.GetPasskeyFunc(ctx, id)
+}
+
+// GetPasskeyCalls gets all the calls that were made to GetPasskey.
+// Check the length with:
+//
+//	len(mockedSessionStore.GetPasskeyCalls())
+func (mock *SessionStoreMock) GetPasskeyCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGetPasskey.RLock()
+	calls = mock.calls.GetPasskey
+	mock.lockGetPasskey.RUnlock()
+	return calls
+}
+
 // GetSession calls GetSessionFunc.
-func (mock *SessionStoreMock) GetSession(ctx context.Context, token string) (string, time.Time, error) {
+func (mock *SessionStoreMock) GetSession(ctx context.Context, token string) (store.Session, error) {
 	if mock.GetSessionFunc == nil {
 		panic("SessionStoreMock.GetSessionFunc: method is nil but SessionStore.GetSession was just called")
 	}
@@ -326,3 +956,311 @@ func (mock *SessionStoreMock) GetSessionCalls() []struct {
 	mock.lockGetSession.RUnlock()
 	return calls
 }
+
+// Passkeys calls PasskeysFunc.
+func (mock *SessionStoreMock) Passkeys(ctx context.Context, username string) ([]store.Passkey, error) {
+	if mock.PasskeysFunc == nil {
+		panic("SessionStoreMock.PasskeysFunc: method is nil but SessionStore.Passkeys was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockPasskeys.Lock()
+	mock.calls.Passkeys = append(mock.calls.Passkeys, callInfo)
+	mock.lockPasskeys.Unlock()
+	return mock.PasskeysFunc(ctx, username)
+}
+
+// PasskeysCalls gets all the calls that were made to Passkeys.
+// Check the length with:
+//
+//	len(mockedSessionStore.PasskeysCalls())
+func (mock *SessionStoreMock) PasskeysCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockPasskeys.RLock()
+	calls = mock.calls.Passkeys
+	mock.lockPasskeys.RUnlock()
+	return calls
+}
+
+// SetSessionDevice calls SetSessionDeviceFunc.
+func (mock *SessionStoreMock) SetSessionDevice(ctx context.Context, token string, dev store.SessionDevice) (bool, error) {
+	if mock.SetSessionDeviceFunc == nil {
+		panic("SessionStoreMock.SetSessionDeviceFunc: method is nil but SessionStore.SetSessionDevice was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token string
+		Dev   store.SessionDevice
+	}{
+		Ctx:   ctx,
+		Token: token,
+		Dev:   dev,
+	}
+	mock.lockSetSessionDevice.Lock()
+	mock.calls.SetSessionDevice = append(mock.calls.SetSessionDevice, callInfo)
+	mock.lockSetSessionDevice.Unlock()
+	return mock.SetSessionDeviceFunc(ctx, token, dev)
+}
+
+// SetSessionDeviceCalls gets all the calls that were made to SetSessionDevice.
+// Check the length with:
+//
+//	len(mockedSessionStore.SetSessionDeviceCalls())
+func (mock *SessionStoreMock) SetSessionDeviceCalls() []struct {
+	Ctx   context.Context
+	Token string
+	Dev   store.SessionDevice
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token string
+		Dev   store.SessionDevice
+	}
+	mock.lockSetSessionDevice.RLock()
+	calls = mock.calls.SetSessionDevice
+	mock.lockSetSessionDevice.RUnlock()
+	return calls
+}
+
+// SyncTokenUsage calls SyncTokenUsageFunc.
+func (mock *SessionStoreMock) SyncTokenUsage(ctx context.Context, fingerprints []string) error {
+	if mock.SyncTokenUsageFunc == nil {
+		panic("SessionStoreMock.SyncTokenUsageFunc: method is nil but SessionStore.SyncTokenUsage was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		Fingerprints []string
+	}{
+		Ctx:          ctx,
+		Fingerprints: fingerprints,
+	}
+	mock.lockSyncTokenUsage.Lock()
+	mock.calls.SyncTokenUsage = append(mock.calls.SyncTokenUsage, callInfo)
+	mock.lockSyncTokenUsage.Unlock()
+	return mock.SyncTokenUsageFunc(ctx, fingerprints)
+}
+
+// SyncTokenUsageCalls gets all the calls that were made to SyncTokenUsage.
+// Check the length with:
+//
+//	len(mockedSessionStore.SyncTokenUsageCalls())
+func (mock *SessionStoreMock) SyncTokenUsageCalls() []struct {
+	Ctx          context.Context
+	Fingerprints []string
+} {
+	var calls []struct {
+		Ctx          context.Context
+		Fingerprints []string
+	}
+	mock.lockSyncTokenUsage.RLock()
+	calls = mock.calls.SyncTokenUsage
+	mock.lockSyncTokenUsage.RUnlock()
+	return calls
+}
+
+// TokenUsages calls TokenUsagesFunc.
+func (mock *SessionStoreMock) TokenUsages(ctx context.Context) ([]store.TokenUsage, error) {
+	if mock.TokenUsagesFunc == nil {
+		panic("SessionStoreMock.TokenUsagesFunc: method is nil but SessionStore.TokenUsages was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockTokenUsages.Lock()
+	mock.calls.TokenUsages = append(mock.calls.TokenUsages, callInfo)
+	mock.lockTokenUsages.Unlock()
+	return mock.TokenUsagesFunc(ctx)
+}
+
+// TokenUsagesCalls gets all the calls that were made to TokenUsages.
+// Check the length with:
+//
+//	len(mockedSessionStore.TokenUsagesCalls())
+func (mock *SessionStoreMock) TokenUsagesCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockTokenUsages.RLock()
+	calls = mock.calls.TokenUsages
+	mock.lockTokenUsages.RUnlock()
+	return calls
+}
+
+// TouchToken calls TouchTokenFunc.
+func (mock *SessionStoreMock) TouchToken(ctx context.Context, fingerprint string, ip string, at time.Time) error {
+	if mock.TouchTokenFunc == nil {
+		panic("SessionStoreMock.TouchTokenFunc: method is nil but SessionStore.TouchToken was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Fingerprint string
+		IP          string
+		At          time.Time
+	}{
+		Ctx:         ctx,
+		Fingerprint: fingerprint,
+		IP:          ip,
+		At:          at,
+	}
+	mock.lockTouchToken.Lock()
+	mock.calls.TouchToken = append(mock.calls.TouchToken, callInfo)
+	mock.lockTouchToken.Unlock()
+	return mock.TouchTokenFunc(ctx, fingerprint, ip, at)
+}
+
+// TouchTokenCalls gets all the calls that were made to TouchToken.
+// Check the length with:
+//
+//	len(mockedSessionStore.TouchTokenCalls())
+func (mock *SessionStoreMock) TouchTokenCalls() []struct {
+	Ctx         context.Context
+	Fingerprint string
+	IP          string
+	At          time.Time
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Fingerprint string
+		IP          string
+		At          time.Time
+	}
+	mock.lockTouchToken.RLock()
+	calls = mock.calls.TouchToken
+	mock.lockTouchToken.RUnlock()
+	return calls
+}
+
+// UseNonce calls UseNonceFunc.
+func (mock *SessionStoreMock) UseNonce(ctx context.Context, nonce string, at time.Time, expiresAt time.Time) (bool, error) {
+	if mock.UseNonceFunc == nil {
+		panic("SessionStoreMock.UseNonceFunc: method is nil but SessionStore.UseNonce was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Nonce     string
+		At        time.Time
+		ExpiresAt time.Time
+	}{
+		Ctx:       ctx,
+		Nonce:     nonce,
+		At:        at,
+		ExpiresAt: expiresAt,
+	}
+	mock.lockUseNonce.Lock()
+	mock.calls.UseNonce = append(mock.calls.UseNonce, callInfo)
+	mock.lockUseNonce.Unlock()
+	return mock.UseNonceFunc(ctx, nonce, at, expiresAt)
+}
+
+// UseNonceCalls gets all the calls that were made to UseNonce.
+// Check the length with:
+//
+//	len(mockedSessionStore.UseNonceCalls())
+func (mock *SessionStoreMock) UseNonceCalls() []struct {
+	Ctx       context.Context
+	Nonce     string
+	At        time.Time
+	ExpiresAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Nonce     string
+		At        time.Time
+		ExpiresAt time.Time
+	}
+	mock.lockUseNonce.RLock()
+	calls = mock.calls.UseNonce
+	mock.lockUseNonce.RUnlock()
+	return calls
+}
+
+// UsePasskey calls UsePasskeyFunc.
+func (mock *SessionStoreMock) UsePasskey(ctx context.Context, id string, signCount int64) error {
+	if mock.UsePasskeyFunc == nil {
+		panic("SessionStoreMock.UsePasskeyFunc: method is nil but SessionStore.UsePasskey was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		ID        string
+		SignCount int64
+	}{
+		Ctx:       ctx,
+		ID:        id,
+		SignCount: signCount,
+	}
+	mock.lockUsePasskey.Lock()
+	mock.calls.UsePasskey = append(mock.calls.UsePasskey, callInfo)
+	mock.lockUsePasskey.Unlock()
+	return mock.UsePasskeyFunc(ctx, id, signCount)
+}
+
+// UsePasskeyCalls gets all the calls that were made to UsePasskey.
+// Check the length with:
+//
+//	len(mockedSessionStore.UsePasskeyCalls())
+func (mock *SessionStoreMock) UsePasskeyCalls() []struct {
+	Ctx       context.Context
+	ID        string
+	SignCount int64
+} {
+	var calls []struct {
+		Ctx       context.Context
+		ID        string
+		SignCount int64
+	}
+	mock.lockUsePasskey.RLock()
+	calls = mock.calls.UsePasskey
+	mock.lockUsePasskey.RUnlock()
+	return calls
+}
+
+// UserSessions calls UserSessionsFunc.
+func (mock *SessionStoreMock) UserSessions(ctx context.Context, username string) ([]store.Session, error) {
+	if mock.UserSessionsFunc == nil {
+		panic("SessionStoreMock.UserSessionsFunc: method is nil but SessionStore.UserSessions was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockUserSessions.Lock()
+	mock.calls.UserSessions = append(mock.calls.UserSessions, callInfo)
+	mock.lockUserSessions.Unlock()
+	return mock.UserSessionsFunc(ctx, username)
+}
+
+// UserSessionsCalls gets all the calls that were made to UserSessions.
+// Check the length with:
+//
+//	len(mockedSessionStore.UserSessionsCalls())
+func (mock *SessionStoreMock) UserSessionsCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockUserSessions.RLock()
+	calls = mock.calls.UserSessions
+	mock.lockUserSessions.RUnlock()
+	return calls
+}
@@ -0,0 +1,376 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/access"
+	"github.com/umputun/stash/app/store"
+)
+
+// passkeyCeremonyTTL is how long a started registration or login waits for the authenticator
+const passkeyCeremonyTTL = 5 * time.Minute
+
+// maxPasskeyName limits the label of a passkey
+const maxPasskeyName = 64
+
+// PasskeyConfig configures WebAuthn passkeys of web UI users.
+type PasskeyConfig struct {
+	Origin       string // origin of the web UI as seen by browsers, e.g. https://stash.example.com
+	RPID         string // relying party ID, the origin host or a registrable suffix of it
+	SecondFactor bool   // users with passkeys confirm password logins with a passkey, no passwordless login
+}
+
+// passkeyCeremony is a started passkey registration or login, kept in memory until finished or expired.
+type passkeyCeremony struct {
+	challenge []byte
+	username  string // user registering or logging in, empty for passwordless login with any passkey
+	register  bool
+	expiresAt time.Time
+}
+
+// passkeyCredential is the public key credential returned by navigator.credentials, binary fields base64url.
+type passkeyCredential struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"` // registration
+		AuthenticatorData string `json:"authenticatorData,omitempty"` // login
+		Signature         string `json:"signature,omitempty"`         // login
+	} `json:"response"`
+}
+
+// passkeyFinish is the request finishing a passkey ceremony, Name labels a registered passkey.
+type passkeyFinish struct {
+	Ceremony   string            `json:"ceremony"`
+	Credential passkeyCredential `json:"credential"`
+	Name       string            `json:"name,omitempty"`
+}
+
+// WithPasskeys enables WebAuthn passkey login of web UI users.
+func WithPasskeys(cfg PasskeyConfig) Option {
+	return func(s *Service) {
+		s.passkeys = &cfg
+	}
+}
+
+// PasskeysEnabled returns true if users can register passkeys and log in with them.
+func (s *Service) PasskeysEnabled() bool {
+	return s != nil && s.passkeys != nil
+}
+
+// PasswordlessEnabled returns true if users can log in with a passkey alone.
+func (s *Service) PasswordlessEnabled() bool {
+	return s.PasskeysEnabled() && !s.passkeys.SecondFactor
+}
+
+// PasskeyRequired returns true if a password login of the user must be confirmed with a passkey.
+func (s *Service) PasskeyRequired(ctx context.Context, username string) (bool, error) {
+	if !s.PasskeysEnabled() || !s.passkeys.SecondFactor {
+		return false, nil
+	}
+	keys, err := s.sessionStore.Passkeys(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	return len(keys) > 0, nil
+}
+
+// BeginPasskeyRegistration starts registration of a new passkey for the user and returns the ceremony ID
+// with the creation options for navigator.credentials.create.
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, username string) (json.RawMessage, error) {
+	if !s.PasskeysEnabled() {
+		return nil, errors.New("passkeys are disabled")
+	}
+	keys, err := s.sessionStore.Passkeys(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	id, challenge, err := s.startCeremony(passkeyCeremony{username: username, register: true})
+	if err != nil {
+		return nil, err
+	}
+
+	type descriptor struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	exclude := make([]descriptor, 0, len(keys))
+	for _, k := range keys {
+		exclude = append(exclude, descriptor{Type: "public-key", ID: k.ID}) // don't register an authenticator twice
+	}
+	userID := sha256.Sum256([]byte("stash:" + username)) // stable opaque handle, lets authenticators replace old passkeys
+	options := map[string]any{
+		"challenge": b64url.EncodeToString(challenge),
+		"rp":        map[string]string{"id": s.passkeys.RPID, "name": "Stash"},
+		"user":      map[string]string{"id": b64url.EncodeToString(userID[:]), "name": username, "displayName": username},
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": coseES256}, {"type": "public-key", "alg": coseEdDSA}, {"type": "public-key", "alg": coseRS256},
+		},
+		"excludeCredentials":     exclude,
+		"authenticatorSelection": map[string]string{"residentKey": "preferred", "userVerification": "preferred"},
+		"attestation":            "none",
+		"timeout":                passkeyCeremonyTTL.Milliseconds(),
+	}
+	return marshalCeremony(id, options)
+}
+
+// FinishPasskeyRegistration verifies the new credential of the user and stores it as a passkey. The body is
+// the JSON with the ceremony ID, the credential from navigator.credentials.create and an optional name.
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, username string, body []byte) error {
+	var req passkeyFinish
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid passkey registration: %w", err)
+	}
+	c, err := s.takeCeremony(req.Ceremony)
+	if err != nil {
+		return err
+	}
+	if !c.register || c.username != username {
+		return errors.New("passkey ceremony of another user")
+	}
+	clientDataJSON, err := b64url.DecodeString(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return fmt.Errorf("invalid client data encoding: %w", err)
+	}
+	if err := verifyClientData(clientDataJSON, "webauthn.create", c.challenge, s.passkeys.Origin); err != nil {
+		return err
+	}
+	attestation, err := b64url.DecodeString(req.Credential.Response.AttestationObject)
+	if err != nil {
+		return fmt.Errorf("invalid attestation object encoding: %w", err)
+	}
+	rawAuthData, err := parseAttestationObject(attestation)
+	if err != nil {
+		return err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData, s.passkeys.RPID)
+	if err != nil {
+		return err
+	}
+	if authData.credID == nil {
+		return errors.New("no attested credential")
+	}
+	if b64url.EncodeToString(authData.credID) != req.Credential.ID {
+		return errors.New("credential id mismatch")
+	}
+	if _, _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "passkey"
+	}
+	if len(name) > maxPasskeyName {
+		name = strings.ToValidUTF8(name[:maxPasskeyName], "")
+	}
+	passkey := store.Passkey{ID: req.Credential.ID, Username: username, Name: name, PublicKey: authData.publicKey,
+		SignCount: int64(authData.signCount)}
+	if err := s.sessionStore.AddPasskey(ctx, passkey); err != nil {
+		return fmt.Errorf("failed to store passkey: %w", err)
+	}
+	log.Printf("[INFO] passkey %q registered for user %q", name, username)
+	return nil
+}
+
+// BeginPasskeyLogin starts a passkey login and returns the ceremony ID with the request options for
+// navigator.credentials.get. Empty username starts a passwordless login with any passkey of any user,
+// otherwise the login is limited to passkeys of the user, as the second factor after the password.
+func (s *Service) BeginPasskeyLogin(ctx context.Context, username string) (json.RawMessage, error) {
+	if !s.PasskeysEnabled() {
+		return nil, errors.New("passkeys are disabled")
+	}
+	if username == "" && s.passkeys.SecondFactor {
+		return nil, errors.New("passwordless login is disabled")
+	}
+	options := map[string]any{"rpId": s.passkeys.RPID, "timeout": passkeyCeremonyTTL.Milliseconds()}
+	if username == "" {
+		options["userVerification"] = "required" // the passkey is the only factor
+	} else {
+		keys, err := s.sessionStore.Passkeys(ctx, username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get passkeys: %w", err)
+		}
+		allow := make([]map[string]string, 0, len(keys))
+		for _, k := range keys {
+			allow = append(allow, map[string]string{"type": "public-key", "id": k.ID})
+		}
+		options["allowCredentials"], options["userVerification"] = allow, "discouraged"
+	}
+	id, challenge, err := s.startCeremony(passkeyCeremony{username: username})
+	if err != nil {
+		return nil, err
+	}
+	options["challenge"] = b64url.EncodeToString(challenge)
+	return marshalCeremony(id, options)
+}
+
+// FinishPasskeyLogin verifies the assertion of a passkey and returns the user it belongs to. The body is the
+// JSON with the ceremony ID and the credential from navigator.credentials.get. Passkeys of users removed
+// from the auth config are rejected.
+func (s *Service) FinishPasskeyLogin(ctx context.Context, body []byte) (string, error) {
+	var req passkeyFinish
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", fmt.Errorf("invalid passkey login: %w", err)
+	}
+	c, err := s.takeCeremony(req.Ceremony)
+	if err != nil {
+		return "", err
+	}
+	if c.register {
+		return "", errors.New("not a login ceremony")
+	}
+	passkey, err := s.sessionStore.GetPasskey(ctx, req.Credential.ID)
+	if err != nil {
+		return "", fmt.Errorf("unknown passkey: %w", err)
+	}
+	if c.username != "" && c.username != passkey.Username {
+		return "", errors.New("passkey of another user")
+	}
+	s.mu.RLock()
+	_, exists := s.users[passkey.Username]
+	s.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("passkey of unknown user %q", passkey.Username)
+	}
+
+	clientDataJSON, err := b64url.DecodeString(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return "", fmt.Errorf("invalid client data encoding: %w", err)
+	}
+	if err := verifyClientData(clientDataJSON, "webauthn.get", c.challenge, s.passkeys.Origin); err != nil {
+		return "", err
+	}
+	rawAuthData, err := b64url.DecodeString(req.Credential.Response.AuthenticatorData)
+	if err != nil {
+		return "", fmt.Errorf("invalid authenticator data encoding: %w", err)
+	}
+	authData, err := parseAuthenticatorData(rawAuthData, s.passkeys.RPID)
+	if err != nil {
+		return "", err
+	}
+	if c.username == "" && authData.flags&flagUserVerified == 0 {
+		return "", errors.New("user not verified")
+	}
+	sig, err := b64url.DecodeString(req.Credential.Response.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if err := verifyAssertion(passkey.PublicKey, rawAuthData, clientDataJSON, sig); err != nil {
+		return "", err
+	}
+	// authenticators with a counter increase it on every use, a lower one means a cloned authenticator
+	count := int64(authData.signCount)
+	if (count != 0 || passkey.SignCount != 0) && count <= passkey.SignCount {
+		log.Printf("[WARN] passkey %q of user %q rejected, signature counter %d not above %d",
+			passkey.Name, passkey.Username, count, passkey.SignCount)
+		return "", errors.New("passkey signature counter went back")
+	}
+	if err := s.sessionStore.UsePasskey(ctx, passkey.ID, count); err != nil {
+		return "", fmt.Errorf("failed to update passkey: %w", err)
+	}
+	return passkey.Username, nil
+}
+
+// Passkeys returns the passkeys of the user.
+func (s *Service) Passkeys(ctx context.Context, username string) ([]store.Passkey, error) {
+	if !s.PasskeysEnabled() {
+		return nil, nil
+	}
+	keys, err := s.sessionStore.Passkeys(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	return keys, nil
+}
+
+// DeletePasskey removes a passkey of the user.
+func (s *Service) DeletePasskey(ctx context.Context, username, id string) error {
+	if !s.PasskeysEnabled() {
+		return errors.New("passkeys are disabled")
+	}
+	if err := s.sessionStore.DeletePasskey(ctx, username, id); err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	log.Printf("[INFO] passkey deleted by user %q", username)
+	return nil
+}
+
+// HandlePasskeyReset deletes all passkeys of a user, for users who lost their authenticators. With
+// passkeys as the second factor the user can log in with the password alone afterwards.
+// DELETE /auth/passkeys/{username}
+func (s *Service) HandlePasskeyReset(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, s) {
+		return
+	}
+	username := r.PathValue("username")
+	deleted, err := s.sessionStore.DeletePasskeys(r.Context(), username)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to reset passkeys")
+		return
+	}
+	_, admin := s.GetRequestActor(r)
+	log.Printf("[WARN] passkeys of user %q reset by %s, %d deleted", username, admin, deleted)
+	rest.RenderJSON(w, rest.JSON{"deleted": deleted})
+}
+
+// startCeremony stores a new ceremony with a random challenge and returns its ID and the challenge.
+// Expired ceremonies are dropped on the way.
+func (s *Service) startCeremony(c passkeyCeremony) (id string, challenge []byte, err error) {
+	buf := make([]byte, 48)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	id, c.challenge = hex.EncodeToString(buf[:16]), buf[16:]
+	c.expiresAt = time.Now().Add(passkeyCeremonyTTL)
+
+	s.ceremonyMu.Lock()
+	defer s.ceremonyMu.Unlock()
+	if s.ceremonies == nil {
+		s.ceremonies = make(map[string]passkeyCeremony)
+	}
+	now := time.Now()
+	for k, v := range s.ceremonies {
+		if now.After(v.expiresAt) {
+			delete(s.ceremonies, k)
+		}
+	}
+	s.ceremonies[id] = c
+	return id, c.challenge, nil
+}
+
+// takeCeremony removes and returns a started ceremony, each challenge can be answered once.
+func (s *Service) takeCeremony(id string) (passkeyCeremony, error) {
+	if !s.PasskeysEnabled() {
+		return passkeyCeremony{}, errors.New("passkeys are disabled")
+	}
+	s.ceremonyMu.Lock()
+	defer s.ceremonyMu.Unlock()
+	c, ok := s.ceremonies[id]
+	delete(s.ceremonies, id)
+	if !ok || time.Now().After(c.expiresAt) {
+		return passkeyCeremony{}, errors.New("unknown or expired passkey ceremony")
+	}
+	return c, nil
+}
+
+// marshalCeremony returns the JSON sent to the browser to run a ceremony.
+func marshalCeremony(id string, options map[string]any) (json.RawMessage, error) {
+	data, err := json.Marshal(map[string]any{"ceremony": id, "publicKey": options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal passkey options: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,395 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOrigin = "https://stash.example.com"
+	testRPID   = "stash.example.com"
+)
+
+func TestService_Passkeys(t *testing.T) {
+	content := `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+  - name: bob
+    password: "$2a$10$hash"
+`
+	svc, err := New(createTempFile(t, content), time.Hour, false, testSessionStore(t), nil,
+		WithPasskeys(PasskeyConfig{Origin: testOrigin, RPID: testRPID}))
+	require.NoError(t, err)
+	assert.True(t, svc.PasskeysEnabled())
+	assert.True(t, svc.PasswordlessEnabled())
+
+	alice := newFakeAuthenticator(t, false)
+	register(t, svc, "alice", alice)
+
+	t.Run("registered passkey is listed", func(t *testing.T) {
+		keys, err := svc.Passkeys(t.Context(), "alice")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, b64url.EncodeToString(alice.id), keys[0].ID)
+		assert.Equal(t, "laptop", keys[0].Name)
+		required, err := svc.PasskeyRequired(t.Context(), "alice")
+		require.NoError(t, err)
+		assert.False(t, required, "passkeys are an alternative to passwords")
+	})
+
+	t.Run("registration excludes known passkeys", func(t *testing.T) {
+		opts, err := svc.BeginPasskeyRegistration(t.Context(), "alice")
+		require.NoError(t, err)
+		assert.Contains(t, string(opts), b64url.EncodeToString(alice.id))
+	})
+
+	t.Run("passwordless login", func(t *testing.T) {
+		username, err := svc.FinishPasskeyLogin(t.Context(), alice.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", username)
+	})
+
+	t.Run("ed25519 passkey", func(t *testing.T) {
+		bobKey := newFakeAuthenticator(t, true)
+		register(t, svc, "bob", bobKey)
+		username, err := svc.FinishPasskeyLogin(t.Context(), bobKey.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.NoError(t, err)
+		assert.Equal(t, "bob", username)
+	})
+
+	t.Run("ceremony is single use", func(t *testing.T) {
+		ceremony := beginLogin(t, svc, "")
+		_, err := svc.FinishPasskeyLogin(t.Context(), alice.get(t, ceremony, flagUserPresent|flagUserVerified))
+		require.NoError(t, err)
+		_, err = svc.FinishPasskeyLogin(t.Context(), alice.get(t, ceremony, flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "unknown or expired")
+	})
+
+	t.Run("passwordless login requires user verification", func(t *testing.T) {
+		_, err := svc.FinishPasskeyLogin(t.Context(), alice.get(t, beginLogin(t, svc, ""), flagUserPresent))
+		require.ErrorContains(t, err, "user not verified")
+	})
+
+	t.Run("wrong origin rejected", func(t *testing.T) {
+		fake := *alice
+		fake.origin = "https://evil.example.com"
+		_, err := svc.FinishPasskeyLogin(t.Context(), fake.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "unexpected origin")
+	})
+
+	t.Run("wrong relying party rejected", func(t *testing.T) {
+		fake := *alice
+		fake.rpID = "example.com"
+		_, err := svc.FinishPasskeyLogin(t.Context(), fake.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "relying party mismatch")
+	})
+
+	t.Run("signature of another key rejected", func(t *testing.T) {
+		fake := newFakeAuthenticator(t, false)
+		fake.id = alice.id
+		_, err := svc.FinishPasskeyLogin(t.Context(), fake.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "invalid signature")
+	})
+
+	t.Run("signature counter going back rejected", func(t *testing.T) {
+		alice.count = 1
+		_, err := svc.FinishPasskeyLogin(t.Context(), alice.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "counter went back")
+	})
+
+	t.Run("delete passkey", func(t *testing.T) {
+		require.NoError(t, svc.DeletePasskey(t.Context(), "alice", b64url.EncodeToString(alice.id)))
+		keys, err := svc.Passkeys(t.Context(), "alice")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+		_, err = svc.FinishPasskeyLogin(t.Context(), alice.get(t, beginLogin(t, svc, ""), flagUserPresent|flagUserVerified))
+		require.ErrorContains(t, err, "unknown passkey")
+	})
+}
+
+func TestService_PasskeySecondFactor(t *testing.T) {
+	content := `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+  - name: bob
+    password: "$2a$10$hash"
+`
+	svc, err := New(createTempFile(t, content), time.Hour, false, testSessionStore(t), nil,
+		WithPasskeys(PasskeyConfig{Origin: testOrigin, RPID: testRPID, SecondFactor: true}))
+	require.NoError(t, err)
+	assert.False(t, svc.PasswordlessEnabled())
+
+	required, err := svc.PasskeyRequired(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.False(t, required, "users without passkeys log in with the password")
+
+	alice, bob := newFakeAuthenticator(t, false), newFakeAuthenticator(t, false)
+	register(t, svc, "alice", alice)
+	register(t, svc, "bob", bob)
+	required, err = svc.PasskeyRequired(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.True(t, required)
+
+	_, err = svc.BeginPasskeyLogin(t.Context(), "")
+	require.ErrorContains(t, err, "passwordless login is disabled")
+
+	opts, err := svc.BeginPasskeyLogin(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.Contains(t, string(opts), b64url.EncodeToString(alice.id))
+	assert.NotContains(t, string(opts), b64url.EncodeToString(bob.id))
+
+	t.Run("passkey of the user", func(t *testing.T) {
+		username, err := svc.FinishPasskeyLogin(t.Context(), alice.get(t, beginLogin(t, svc, "alice"), flagUserPresent))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", username)
+	})
+
+	t.Run("passkey of another user rejected", func(t *testing.T) {
+		_, err := svc.FinishPasskeyLogin(t.Context(), bob.get(t, beginLogin(t, svc, "alice"), flagUserPresent))
+		require.ErrorContains(t, err, "passkey of another user")
+	})
+
+	t.Run("registration ceremony of another user rejected", func(t *testing.T) {
+		opts, err := svc.BeginPasskeyRegistration(t.Context(), "alice")
+		require.NoError(t, err)
+		err = svc.FinishPasskeyRegistration(t.Context(), "bob", newFakeAuthenticator(t, false).create(t, opts))
+		require.ErrorContains(t, err, "another user")
+	})
+}
+
+func TestService_PasskeysDisabled(t *testing.T) {
+	svc, err := New(createTempFile(t, "users:\n  - name: alice\n    password: \"$2a$10$hash\"\n"), time.Hour, false,
+		testSessionStore(t), nil)
+	require.NoError(t, err)
+	assert.False(t, svc.PasskeysEnabled())
+	assert.False(t, svc.PasswordlessEnabled())
+	_, err = svc.BeginPasskeyRegistration(t.Context(), "alice")
+	require.Error(t, err)
+	_, err = svc.BeginPasskeyLogin(t.Context(), "")
+	require.Error(t, err)
+	_, err = svc.FinishPasskeyLogin(t.Context(), []byte(`{"ceremony":"x"}`))
+	require.Error(t, err)
+	required, err := svc.PasskeyRequired(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.False(t, required)
+}
+
+func TestService_HandlePasskeyReset(t *testing.T) {
+	content := `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+tokens:
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`
+	svc, err := New(createTempFile(t, content), time.Hour, false, testSessionStore(t), nil,
+		WithPasskeys(PasskeyConfig{Origin: testOrigin, RPID: testRPID}))
+	require.NoError(t, err)
+	register(t, svc, "alice", newFakeAuthenticator(t, false))
+	register(t, svc, "alice", newFakeAuthenticator(t, true))
+
+	reset := func(configure func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/auth/passkeys/alice", http.NoBody)
+		req.SetPathValue("username", "alice")
+		configure(req)
+		rec := httptest.NewRecorder()
+		svc.HandlePasskeyReset(rec, req)
+		return rec
+	}
+
+	t.Run("anonymous rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, reset(func(*http.Request) {}).Code)
+	})
+
+	t.Run("user rejected", func(t *testing.T) {
+		token, err := svc.CreateSession(t.Context(), "alice", false)
+		require.NoError(t, err)
+		rec := reset(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "stash-auth", Value: token}) })
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("admin resets passkeys", func(t *testing.T) {
+		rec := reset(func(r *http.Request) { r.Header.Set("X-Auth-Token", "admin-token") })
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"deleted":2}`, rec.Body.String())
+		keys, err := svc.Passkeys(t.Context(), "alice")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+}
+
+// fakeAuthenticator is a software authenticator with a single passkey, signing with ECDSA P-256 or Ed25519
+type fakeAuthenticator struct {
+	id     []byte
+	ec     *ecdsa.PrivateKey
+	ed     ed25519.PrivateKey
+	count  uint32
+	origin string
+	rpID   string
+}
+
+func newFakeAuthenticator(t *testing.T, ed bool) *fakeAuthenticator {
+	t.Helper()
+	a := &fakeAuthenticator{id: make([]byte, 16), origin: testOrigin, rpID: testRPID}
+	_, err := rand.Read(a.id)
+	require.NoError(t, err)
+	if ed {
+		_, a.ed, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		a.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	require.NoError(t, err)
+	return a
+}
+
+// coseKey returns the COSE encoded public key
+func (a *fakeAuthenticator) coseKey(t *testing.T) []byte {
+	t.Helper()
+	if a.ed != nil {
+		return encodeCBOR(map[any]any{1: 1, 3: coseEdDSA, -1: 6, -2: []byte(a.ed.Public().(ed25519.PublicKey))})
+	}
+	point, err := a.ec.PublicKey.Bytes()
+	require.NoError(t, err)
+	return encodeCBOR(map[any]any{1: 2, 3: coseES256, -1: 1, -2: point[1:33], -3: point[33:]})
+}
+
+// authData returns the authenticator data, with the attested credential for registration
+func (a *fakeAuthenticator) authData(t *testing.T, flags byte, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		data = append(data, make([]byte, 16)...) // aaguid
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+		data = append(data, a.id...)
+		data = append(data, a.coseKey(t)...)
+	}
+	return data
+}
+
+func (a *fakeAuthenticator) clientData(t *testing.T, typ string, opts json.RawMessage) (ceremony string, data []byte) {
+	t.Helper()
+	var o struct {
+		Ceremony  string `json:"ceremony"`
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	require.NoError(t, json.Unmarshal(opts, &o))
+	data, err := json.Marshal(map[string]string{"type": typ, "challenge": o.PublicKey.Challenge, "origin": a.origin})
+	require.NoError(t, err)
+	return o.Ceremony, data
+}
+
+// create returns the body finishing a registration with the creation options
+func (a *fakeAuthenticator) create(t *testing.T, opts json.RawMessage) []byte {
+	t.Helper()
+	ceremony, cd := a.clientData(t, "webauthn.create", opts)
+	att := encodeCBOR(map[any]any{"fmt": "none", "attStmt": map[any]any{},
+		"authData": a.authData(t, flagUserPresent|flagUserVerified, true)})
+	body, err := json.Marshal(map[string]any{"ceremony": ceremony, "name": "laptop", "credential": map[string]any{
+		"id":       b64url.EncodeToString(a.id),
+		"response": map[string]string{"clientDataJSON": b64url.EncodeToString(cd), "attestationObject": b64url.EncodeToString(att)},
+	}})
+	require.NoError(t, err)
+	return body
+}
+
+// get returns the body finishing a login with the request options, the counter is increased first
+func (a *fakeAuthenticator) get(t *testing.T, opts json.RawMessage, flags byte) []byte {
+	t.Helper()
+	a.count++
+	ceremony, cd := a.clientData(t, "webauthn.get", opts)
+	authData := a.authData(t, flags, false)
+	clientHash := sha256.Sum256(cd)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+	var sig []byte
+	if a.ed != nil {
+		sig = ed25519.Sign(a.ed, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		var err error
+		sig, err = ecdsa.SignASN1(rand.Reader, a.ec, digest[:])
+		require.NoError(t, err)
+	}
+	body, err := json.Marshal(map[string]any{"ceremony": ceremony, "credential": map[string]any{
+		"id": b64url.EncodeToString(a.id),
+		"response": map[string]string{"clientDataJSON": b64url.EncodeToString(cd),
+			"authenticatorData": b64url.EncodeToString(authData), "signature": b64url.EncodeToString(sig)},
+	}})
+	require.NoError(t, err)
+	return body
+}
+
+func register(t *testing.T, svc *Service, username string, a *fakeAuthenticator) {
+	t.Helper()
+	opts, err := svc.BeginPasskeyRegistration(t.Context(), username)
+	require.NoError(t, err)
+	require.NoError(t, svc.FinishPasskeyRegistration(t.Context(), username, a.create(t, opts)))
+}
+
+func beginLogin(t *testing.T, svc *Service, username string) json.RawMessage {
+	t.Helper()
+	opts, err := svc.BeginPasskeyLogin(t.Context(), username)
+	require.NoError(t, err)
+	return opts
+}
+
+// encodeCBOR encodes ints, byte and text strings and maps, enough for attestation objects and COSE keys
+func encodeCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case map[any]any:
+		buf := head(5, uint64(len(x)))
+		for k, val := range x {
+			buf = append(buf, encodeCBOR(k)...)
+			buf = append(buf, encodeCBOR(val)...)
+		}
+		return buf
+	default:
+		panic("unsupported cbor type")
+	}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-pkgz/lcw/v2"
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// policyCacheSize is the max number of cached policy decisions
+const policyCacheSize = 10000
+
+// PolicyConfig is the OPA policy deciding access to keys instead of the prefix ACL.
+type PolicyConfig struct {
+	URL      string        // decision of the OPA data API, e.g. http://localhost:8181/v1/data/stash/allow
+	Timeout  time.Duration // timeout of a decision, 2s if zero
+	CacheTTL time.Duration // decisions are reused for this long, zero disables caching
+}
+
+// PolicyInput is the input of a policy decision, sent as {"input": ...} to the OPA data API.
+type PolicyInput struct {
+	Actor    PolicyActor    `json:"actor"`
+	Key      string         `json:"key"`
+	Action   string         `json:"action"` // read, write, delete, history or export, as scopes of tokens
+	ACL      bool           `json:"acl"`    // decision of the prefix ACL, policies may keep it and add rules on top
+	Metadata PolicyMetadata `json:"metadata"`
+}
+
+// PolicyActor is the caller asking for access.
+type PolicyActor struct {
+	Type  string `json:"type"` // user, token or public, SPIFFE workloads are tokens named by their ID
+	Name  string `json:"name"` // username, actor of the token or its masked value, empty for public access
+	Admin bool   `json:"admin"`
+}
+
+// PolicyMetadata describes the key and the request. Request fields are empty for checks of the web UI,
+// which are made without the request.
+type PolicyMetadata struct {
+	Secret bool   `json:"secret"` // key is in a secrets path
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	IP     string `json:"ip,omitempty"`
+}
+
+// Policy delegates access decisions to an OPA policy queried over its data API. The decision is the
+// "result" of the response, either a boolean or an object with "allow" boolean, undefined decisions deny.
+type Policy struct {
+	url    string
+	client *http.Client
+	cache  lcw.LoadingCache[bool] // nil if caching is disabled
+}
+
+// NewPolicy creates a policy for the decision URL.
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("policy url is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	res := &Policy{url: cfg.URL, client: &http.Client{Timeout: cfg.Timeout}}
+	if cfg.CacheTTL > 0 {
+		o := lcw.NewOpts[bool]()
+		cache, err := lcw.NewExpirableCache(o.MaxKeys(policyCacheSize), o.TTL(cfg.CacheTTL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create policy cache: %w", err)
+		}
+		res.cache = cache
+	}
+	return res, nil
+}
+
+// WithPolicy makes the policy decide access to keys of users, tokens, workloads and public access.
+// Scopes of tokens and admin rights are still checked by the auth config.
+func WithPolicy(p *Policy) Option {
+	return func(s *Service) {
+		s.policy = p
+	}
+}
+
+// Allow returns the decision of the policy. Errors are not cached, the next check queries the policy again.
+func (p *Policy) Allow(ctx context.Context, in PolicyInput) (bool, error) {
+	body, err := json.Marshal(struct {
+		Input PolicyInput `json:"input"`
+	}{Input: in})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal policy input: %w", err)
+	}
+	if p.cache == nil {
+		return p.query(ctx, body)
+	}
+	allowed, err := p.cache.Get(string(body), func() (bool, error) { return p.query(ctx, body) })
+	if err != nil {
+		return false, fmt.Errorf("policy decision: %w", err)
+	}
+	return allowed, nil
+}
+
+// query posts the input to the data API and parses the decision.
+func (p *Policy) query(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create policy request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to query policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("policy returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var res struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, fmt.Errorf("failed to decode policy response: %w", err)
+	}
+	if len(res.Result) == 0 {
+		return false, nil // undefined decision, no rule matched
+	}
+	var allowed bool
+	if err := json.Unmarshal(res.Result, &allowed); err == nil {
+		return allowed, nil
+	}
+	var obj struct {
+		Allow bool `json:"allow"`
+	}
+	if err := json.Unmarshal(res.Result, &obj); err != nil {
+		return false, fmt.Errorf("unexpected policy result %s", res.Result)
+	}
+	return obj.Allow, nil
+}
+
+// allowed returns the decision of the policy on the access, or the decision of the ACL if no policy
+// is configured. Failed policy queries deny the access.
+func (s *Service) allowed(ctx context.Context, in PolicyInput) bool {
+	if s.policy == nil {
+		return in.ACL
+	}
+	in.Metadata.Secret = store.IsSecret(in.Key)
+	allowed, err := s.policy.Allow(ctx, in)
+	if err != nil {
+		log.Printf("[WARN] %s access of %s %q to key %q denied, %v", in.Action, in.Actor.Type, in.Actor.Name, in.Key, err)
+		return false
+	}
+	return allowed
+}
+
+// allowedKeys returns the keys the actor can read, decided by allowed for each key with aclAllows giving
+// the decision of the ACL. Returns nil if no key is allowed.
+func (s *Service) allowedKeys(ctx context.Context, actor PolicyActor, md PolicyMetadata, keys []string,
+	aclAllows func(key string) bool) []string {
+	var res []string
+	for _, key := range keys {
+		in := PolicyInput{Actor: actor, Key: key, Action: "read", ACL: aclAllows(key), Metadata: md}
+		if s.allowed(ctx, in) {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// requestMetadata returns the request part of the policy metadata.
+func requestMetadata(r *http.Request) PolicyMetadata {
+	return PolicyMetadata{Method: r.Method, Path: r.URL.Path, IP: r.RemoteAddr}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+const policyTestConfig = `
+users:
+  - name: alice
+    password: "$2a$10$C615A0mfUEFBupj9qcqhiuBEyf60EqrsakB90CozUoSON8d2Dc1uS"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+tokens:
+  - token: "apitoken"
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "*"
+    permissions:
+      - prefix: "public/*"
+        access: r
+`
+
+// policyServer is an OPA stand-in keeping the ACL decision, except that tokens can't touch secrets
+// and alice can read ops/ keys outside of her ACL.
+func policyServer(t *testing.T, inputs *[]PolicyInput) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input PolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*inputs = append(*inputs, req.Input)
+		in := req.Input
+		allow := in.ACL && !(in.Actor.Type == "token" && in.Metadata.Secret)
+		allow = allow || (in.Actor.Name == "alice" && in.Action == "read" && strings.HasPrefix(in.Key, "ops/"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": allow})
+	}))
+}
+
+func TestService_Policy(t *testing.T) {
+	var inputs []PolicyInput
+	ts := policyServer(t, &inputs)
+	defer ts.Close()
+	p, err := NewPolicy(PolicyConfig{URL: ts.URL})
+	require.NoError(t, err)
+	svc, err := New(createTempFile(t, policyTestConfig), time.Hour, false, testSessionStore(t), nil, WithPolicy(p))
+	require.NoError(t, err)
+
+	t.Run("user", func(t *testing.T) {
+		inputs = nil
+		assert.True(t, svc.CheckUserPermission("alice", "app/db", true))
+		assert.True(t, svc.CheckUserPermission("alice", "ops/runbook", false), "allowed by the policy only")
+		assert.False(t, svc.CheckUserPermission("alice", "ops/runbook", true))
+		assert.False(t, svc.CheckUserPermission("bob", "app/db", false), "unknown user is not sent to the policy")
+		require.Len(t, inputs, 3)
+		assert.Equal(t, PolicyInput{Actor: PolicyActor{Type: "user", Name: "alice"}, Key: "app/db", Action: "write", ACL: true},
+			inputs[0])
+		assert.Equal(t, []string{"app/db", "ops/runbook"}, svc.FilterUserKeys("alice", []string{"app/db", "ops/runbook", "x"}))
+	})
+
+	t.Run("token", func(t *testing.T) {
+		inputs = nil
+		handler := svc.TokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+		for key, code := range map[string]int{"app/db": http.StatusOK, "secrets/db": http.StatusForbidden} {
+			req := httptest.NewRequest(http.MethodDelete, "/kv/"+key, http.NoBody)
+			req.Header.Set("X-Auth-Token", "apitoken")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, code, rec.Code, key)
+		}
+		// public access is asked first, the policy may allow it beyond the public ACL
+		var in PolicyInput
+		for _, v := range inputs {
+			if v.Actor.Type == "token" && v.Key == "app/db" {
+				in = v
+			}
+		}
+		assert.Equal(t, PolicyActor{Type: "token", Name: "token:apit****"}, in.Actor)
+		assert.Equal(t, "delete", in.Action)
+		assert.Equal(t, PolicyMetadata{Method: http.MethodDelete, Path: "/kv/app/db", IP: "192.0.2.1:1234"}, in.Metadata)
+
+		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+		req.Header.Set("X-Auth-Token", "apitoken")
+		assert.Equal(t, []string{"app/db"}, svc.FilterKeysForRequest(req, []string{"app/db", "secrets/db"}))
+		assert.False(t, svc.RequestCanChange(req, "secrets/db", enum.ScopeWrite))
+	})
+
+	t.Run("public", func(t *testing.T) {
+		inputs = nil
+		assert.True(t, svc.PublicReadable("public/motd"))
+		assert.False(t, svc.PublicReadable("app/db"))
+		require.Len(t, inputs, 2)
+		assert.Equal(t, PolicyActor{Type: "public"}, inputs[0].Actor)
+	})
+
+	t.Run("failed policy denies", func(t *testing.T) {
+		down, err := NewPolicy(PolicyConfig{URL: "http://127.0.0.1:1/v1/data/stash/allow"})
+		require.NoError(t, err)
+		svc.policy = down
+		defer func() { svc.policy = p }()
+		assert.False(t, svc.CheckUserPermission("alice", "app/db", false))
+	})
+}
+
+func TestPolicy_Allow(t *testing.T) {
+	var calls atomic.Int32
+	result := `{"result": true}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if result == "" {
+			http.Error(w, "policy error", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(result))
+	}))
+	defer ts.Close()
+	in := PolicyInput{Actor: PolicyActor{Type: "user", Name: "alice"}, Key: "app/db", Action: "read"}
+
+	tests := []struct {
+		name    string
+		result  string
+		allowed bool
+		err     string
+	}{
+		{name: "boolean", result: `{"result": true}`, allowed: true},
+		{name: "object", result: `{"result": {"allow": true, "reason": "owner"}}`, allowed: true},
+		{name: "denied", result: `{"result": {"allow": false}}`, allowed: false},
+		{name: "undefined", result: `{}`, allowed: false},
+		{name: "unexpected", result: `{"result": "yes"}`, err: "unexpected policy result"},
+		{name: "error status", result: "", err: "policy returned 500: policy error"},
+	}
+	p, err := NewPolicy(PolicyConfig{URL: ts.URL})
+	require.NoError(t, err)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result = tc.result
+			allowed, err := p.Allow(context.Background(), in)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.allowed, allowed)
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		cached, err := NewPolicy(PolicyConfig{URL: ts.URL, CacheTTL: time.Minute})
+		require.NoError(t, err)
+		result = `{"result": true}`
+		before := calls.Load()
+		for range 3 {
+			allowed, err := cached.Allow(context.Background(), in)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+		}
+		other := in
+		other.Action = "write"
+		_, err = cached.Allow(context.Background(), other)
+		require.NoError(t, err)
+		assert.Equal(t, before+2, calls.Load(), "one query per distinct input")
+	})
+
+	_, err = NewPolicy(PolicyConfig{})
+	require.ErrorContains(t, err, "policy url is required")
+}
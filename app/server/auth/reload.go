@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/access"
+)
+
+// ReloadStatus is the state of the auth config of this instance, as reported to admins. Each replica
+// reloads its own copy of the file, so the checksum tells whether replicas run the same config.
+type ReloadStatus struct {
+	File      string    `json:"file"`
+	Checksum  string    `json:"checksum"`  // sha256 of the file of the active config
+	LoadedAt  time.Time `json:"loaded_at"` // when the active config was loaded, on start or by the last good reload
+	HotReload bool      `json:"hot_reload"`
+	Reloads   int       `json:"reloads"`  // successful reloads since start
+	Failures  int       `json:"failures"` // failed reloads since start, the active config is kept on failure
+
+	LastReload *ReloadResult `json:"last_reload,omitempty"` // nil if the config wasn't reloaded since start
+}
+
+// ReloadResult is the outcome of a reload attempt.
+type ReloadResult struct {
+	At          time.Time `json:"at"`
+	Trigger     string    `json:"trigger"`                     // "file change", "SIGHUP" or "api by" with the actor of the admin
+	Error       string    `json:"error,omitempty"`             // validation or parse error, empty on success
+	Checksum    string    `json:"checksum,omitempty"`          // sha256 of the loaded file, empty on failure
+	Invalidated []string  `json:"invalidated_users,omitempty"` // users removed or with a changed password, their sessions are deleted
+}
+
+// reloadBy reloads the auth file and records the outcome in the reload status.
+func (s *Service) reloadBy(ctx context.Context, trigger string) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	checksum, invalidated, err := s.reload(ctx)
+	res := &ReloadResult{At: time.Now(), Trigger: trigger, Checksum: checksum, Invalidated: invalidated}
+	slices.Sort(res.Invalidated)
+	if err != nil {
+		res.Error = err.Error()
+		s.reloadStatus.Failures++
+	} else {
+		s.reloadStatus.Reloads++
+		s.reloadStatus.Checksum, s.reloadStatus.LoadedAt = checksum, res.At
+	}
+	s.reloadStatus.LastReload = res
+	return err
+}
+
+// ReloadStatus returns the state of the auth config and the outcome of the last reload.
+func (s *Service) ReloadStatus() ReloadStatus {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	res := s.reloadStatus
+	if res.LastReload != nil {
+		last := *res.LastReload
+		last.Invalidated = slices.Clone(last.Invalidated)
+		res.LastReload = &last
+	}
+	return res
+}
+
+// HandleReload reloads the auth file on this instance, answers with the reload status. A failed reload
+// keeps the active config and answers 422 with the error in the status.
+// POST /admin/auth/reload
+func (s *Service) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, s) {
+		return
+	}
+	actorType, actorName := s.GetRequestActor(r)
+	trigger := "api by " + actorType
+	if actorName != "" {
+		trigger += " " + actorName
+	}
+	status := http.StatusOK
+	if err := s.reloadBy(r.Context(), trigger); err != nil {
+		log.Printf("[WARN] auth config reload requested by %s %s failed, %v", actorType, actorName, err)
+		status = http.StatusUnprocessableEntity
+	}
+	if err := rest.EncodeJSON(w, status, s.ReloadStatus()); err != nil {
+		log.Printf("[WARN] failed to write reload status: %v", err)
+	}
+}
+
+// HandleReloadStatus reports the checksum of the active auth config, when it was loaded and the outcome
+// of the last reload on this instance.
+// GET /admin/auth/status
+func (s *Service) HandleReloadStatus(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, s) {
+		return
+	}
+	rest.RenderJSON(w, s.ReloadStatus())
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ReloadStatus(t *testing.T) {
+	const initialConfig = `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: rw
+  - name: bob
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: r
+tokens:
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "ci-token"
+    permissions:
+      - prefix: "*"
+        access: r
+`
+	checksum := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	f := createTempFile(t, initialConfig)
+	svc, err := New(f, time.Hour, true, testSessionStore(t), nil)
+	require.NoError(t, err)
+
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		if token != "" {
+			req.Header.Set("X-Auth-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		if method == http.MethodPost {
+			svc.HandleReload(rec, req)
+		} else {
+			svc.HandleReloadStatus(rec, req)
+		}
+		return rec
+	}
+	status := func(rec *httptest.ResponseRecorder) ReloadStatus {
+		var res ReloadStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	t.Run("initial", func(t *testing.T) {
+		rec := call(http.MethodGet, "/admin/auth/status", "admin-token")
+		require.Equal(t, http.StatusOK, rec.Code)
+		st := status(rec)
+		assert.Equal(t, f, st.File)
+		assert.Equal(t, checksum(initialConfig), st.Checksum)
+		assert.True(t, st.HotReload)
+		assert.WithinDuration(t, time.Now(), st.LoadedAt, time.Minute)
+		assert.Nil(t, st.LastReload)
+	})
+
+	t.Run("reload invalidates sessions of changed users", func(t *testing.T) {
+		session, err := svc.CreateSession(t.Context(), "bob", false)
+		require.NoError(t, err)
+		const updated = `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+    permissions:
+      - prefix: "*"
+        access: rw
+tokens:
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "new-token"
+    permissions:
+      - prefix: "app/*"
+        access: r
+`
+		require.NoError(t, os.WriteFile(f, []byte(updated), 0o600))
+
+		rec := call(http.MethodPost, "/admin/auth/reload", "admin-token")
+		require.Equal(t, http.StatusOK, rec.Code)
+		st := status(rec)
+		assert.Equal(t, checksum(updated), st.Checksum)
+		assert.Equal(t, 1, st.Reloads)
+		require.NotNil(t, st.LastReload)
+		assert.Equal(t, "api by token token:admi****", st.LastReload.Trigger)
+		assert.Empty(t, st.LastReload.Error)
+		assert.Equal(t, []string{"bob"}, st.LastReload.Invalidated)
+		assert.True(t, svc.hasTokenACL("new-token"))
+		_, ok := svc.GetSessionUser(t.Context(), session)
+		assert.False(t, ok, "session of the removed user is deleted")
+	})
+
+	t.Run("failed reload keeps config", func(t *testing.T) {
+		before := svc.ReloadStatus()
+		require.NoError(t, os.WriteFile(f, []byte("users: [not valid"), 0o600))
+		rec := call(http.MethodPost, "/admin/auth/reload", "admin-token")
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		st := status(rec)
+		assert.Equal(t, before.Checksum, st.Checksum, "active config is kept")
+		assert.Equal(t, before.LoadedAt.UTC(), st.LoadedAt.UTC())
+		assert.Equal(t, 1, st.Failures)
+		require.NotNil(t, st.LastReload)
+		assert.Contains(t, st.LastReload.Error, "failed to parse auth config file")
+		assert.Empty(t, st.LastReload.Checksum)
+		assert.True(t, svc.hasTokenACL("new-token"))
+	})
+
+	t.Run("signal reload is recorded", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(f, []byte(initialConfig), 0o600))
+		require.NoError(t, svc.Reload(t.Context()))
+		st := svc.ReloadStatus()
+		assert.Equal(t, "SIGHUP", st.LastReload.Trigger)
+		assert.Equal(t, checksum(initialConfig), st.Checksum)
+		assert.Equal(t, 2, st.Reloads)
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		reloads := svc.ReloadStatus().Reloads
+		assert.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/admin/auth/reload", "").Code)
+		assert.Equal(t, http.StatusForbidden, call(http.MethodPost, "/admin/auth/reload", "ci-token").Code)
+		assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/admin/auth/status", "ci-token").Code)
+		assert.Equal(t, reloads, svc.ReloadStatus().Reloads)
+	})
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// signatureScheme is the Authorization scheme of signed requests:
+//
+//	Authorization: Stash-HMAC-SHA256 KeyId=<token fingerprint>, Timestamp=<unix seconds>, Nonce=<random>, Signature=<hex>
+//
+// The signature is the HMAC-SHA256 with the named token as key of the newline-joined method, request URI
+// as sent by the client (path with base URL and query), timestamp, nonce and hex SHA-256 of the body.
+const signatureScheme = "Stash-HMAC-SHA256"
+
+// defaultSignatureSkew is how far the timestamp of a signed request may be from the server time
+const defaultSignatureSkew = 5 * time.Minute
+
+// maxNonceLen limits nonces kept in the store for replay protection
+const maxNonceLen = 64
+
+// signedRequests verifies signed requests. Their nonces are kept in the session store until their
+// timestamps expire.
+type signedRequests struct {
+	skew time.Duration
+	now  func() time.Time
+}
+
+// signature is the parsed Authorization header of a signed request.
+type signature struct {
+	keyID, nonce, sig string
+	ts                time.Time
+}
+
+// WithSignedRequests accepts requests signed with a named token instead of carrying it, see signatureScheme.
+// Timestamps may be up to skew away from the server time, zero for the 5 minutes default. Nonces are
+// kept in the session store, shared by all instances using the same database.
+func WithSignedRequests(skew time.Duration) Option {
+	return func(s *Service) {
+		if skew <= 0 {
+			skew = defaultSignatureSkew
+		}
+		s.signed = &signedRequests{skew: skew, now: time.Now}
+	}
+}
+
+// SignedRequestsEnabled returns true if signed requests are accepted.
+func (s *Service) SignedRequestsEnabled() bool {
+	return s != nil && s.signed != nil
+}
+
+// SignatureMiddleware verifies signed requests and passes them on with the signing token as Bearer token,
+// so token checks, audit and usage tracking downstream see them as requests of the token. The header is
+// set on the server side only, the token never travels with the request. Requests without a signature
+// are passed as is, invalid signatures get 401.
+func (s *Service) SignatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !s.SignedRequestsEnabled() || !strings.HasPrefix(header, signatureScheme+" ") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body) // limited by the size limit middleware in front of it
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		_ = r.Body.Close()
+
+		token, err := s.verifySignature(r, header, body)
+		if err != nil {
+			log.Printf("[INFO] rejected signed request %s %s, %v", r.Method, r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.Header.Del("X-Auth-Token") // the signing token wins over any other token of the request
+		r.Header.Set("Authorization", "Bearer "+token)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verifySignature checks the signature of the request and returns the named token that made it.
+func (s *Service) verifySignature(r *http.Request, header string, body []byte) (string, error) {
+	sig, err := parseSignature(strings.TrimPrefix(header, signatureScheme+" "))
+	if err != nil {
+		return "", err
+	}
+	now := s.signed.now()
+	if d := now.Sub(sig.ts).Abs(); d > s.signed.skew {
+		return "", fmt.Errorf("timestamp is %s away from server time", d.Truncate(time.Second))
+	}
+
+	token, ok := s.signingToken(sig.keyID)
+	if !ok {
+		return "", fmt.Errorf("unknown key id %q", sig.keyID)
+	}
+	uri := r.RequestURI // as sent by the client, before the base URL is stripped
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	want := signRequest(token, r.Method, uri, sig.ts.Unix(), sig.nonce, body)
+	if !hmac.Equal([]byte(want), []byte(sig.sig)) {
+		return "", errors.New("signature mismatch")
+	}
+
+	// the nonce is recorded only for valid signatures, so forged requests can't block nonces of real ones
+	// a request with the nonce is accepted until its timestamp is skew old, at most 2*skew from now
+	fresh, err := s.sessionStore.UseNonce(r.Context(), sig.nonce, now, now.Add(2*s.signed.skew))
+	if err != nil {
+		return "", fmt.Errorf("failed to check nonce: %w", err)
+	}
+	if !fresh {
+		return "", errors.New("nonce was used already")
+	}
+	return token, nil
+}
+
+// signingToken returns the named token with the fingerprint. Exchanged tokens can't sign requests.
+func (s *Service) signingToken(keyID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for token := range s.tokens {
+		if tokenFingerprint(token) == keyID {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// parseSignature parses the parameters of the signature header, comma separated name=value pairs.
+func parseSignature(params string) (signature, error) {
+	var res signature
+	var ts string
+	for _, p := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch name {
+		case "KeyId":
+			res.keyID = value
+		case "Timestamp":
+			ts = value
+		case "Nonce":
+			res.nonce = value
+		case "Signature":
+			res.sig = value
+		}
+	}
+	if res.keyID == "" || ts == "" || res.nonce == "" || res.sig == "" {
+		return signature{}, errors.New("signature needs KeyId, Timestamp, Nonce and Signature")
+	}
+	if len(res.nonce) > maxNonceLen {
+		return signature{}, fmt.Errorf("nonce is longer than %d characters", maxNonceLen)
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	res.ts = time.Unix(unix, 0)
+	return res, nil
+}
+
+// signRequest returns the hex signature of a request made with the token, see signatureScheme.
+func signRequest(token, method, uri string, timestamp int64, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(strings.Join([]string{method, uri, strconv.FormatInt(timestamp, 10), nonce,
+		hex.EncodeToString(bodySum[:])}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
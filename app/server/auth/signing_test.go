@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequest(t *testing.T) {
+	// the same vector is checked by the go client, both sides must sign the same way
+	sig := signRequest("secret-token", http.MethodPut, "/kv/app/name?format=text", 1736935200, "n1", []byte("value"))
+	assert.Equal(t, "209d8185dae75128d460e8e7d23c5f1772422236945faaf3342bb89fcb4f7950", sig)
+}
+
+func TestService_SignatureMiddleware(t *testing.T) {
+	f := createTempFile(t, exchangeTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithSignedRequests(time.Minute))
+	require.NoError(t, err)
+	require.True(t, svc.SignedRequestsEnabled())
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	svc.signed.now = func() time.Time { return now }
+
+	var gotAuth, gotBody string
+	h := svc.SignatureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	keyID := tokenFingerprint("ci-parent-token")
+	signed := func(method, uri, body, token string, ts time.Time, nonce string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, strings.NewReader(body))
+		sig := signRequest(token, method, uri, ts.Unix(), nonce, []byte(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Stash-HMAC-SHA256 KeyId=%s, Timestamp=%d, Nonce=%s, Signature=%s",
+			keyID, ts.Unix(), nonce, sig))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("valid signature passes the token on", func(t *testing.T) {
+		rec := signed(http.MethodPut, "/kv/app/name?format=text", "value", "ci-parent-token", now, "nonce-1")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Bearer ci-parent-token", gotAuth)
+		assert.Equal(t, "value", gotBody, "body is restored")
+	})
+
+	t.Run("replayed nonce", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, signed(http.MethodGet, "/kv/app/name", "", "ci-parent-token", now, "nonce-1").Code)
+	})
+
+	t.Run("timestamp out of skew", func(t *testing.T) {
+		old := now.Add(-2 * time.Minute)
+		assert.Equal(t, http.StatusUnauthorized, signed(http.MethodGet, "/kv/app/name", "", "ci-parent-token", old, "nonce-2").Code)
+		assert.Equal(t, http.StatusOK, signed(http.MethodGet, "/kv/app/name", "", "ci-parent-token", now, "nonce-2").Code,
+			"rejected nonce is not recorded")
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, signed(http.MethodGet, "/kv/app/name", "", "guessed", now, "nonce-3").Code)
+	})
+
+	t.Run("tampered request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/kv/app/name", strings.NewReader("changed"))
+		sig := signRequest("ci-parent-token", http.MethodPut, "/kv/app/name", now.Unix(), "nonce-4", []byte("value"))
+		req.Header.Set("Authorization", fmt.Sprintf("Stash-HMAC-SHA256 KeyId=%s, Timestamp=%d, Nonce=nonce-4, Signature=%s",
+			keyID, now.Unix(), sig))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/name", http.NoBody)
+		req.Header.Set("Authorization", "Stash-HMAC-SHA256 KeyId="+keyID)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("unsigned requests pass as is", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/name", http.NoBody)
+		req.Header.Set("Authorization", "Bearer other")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Bearer other", gotAuth)
+	})
+
+	t.Run("nonces are forgotten after the window", func(t *testing.T) {
+		now = now.Add(3 * time.Minute)
+		assert.Equal(t, http.StatusOK, signed(http.MethodGet, "/kv/app/name", "", "ci-parent-token", now, "nonce-1").Code)
+	})
+}
+
+func TestParseSignature(t *testing.T) {
+	sig, err := parseSignature("KeyId=abc, Timestamp=1736935200, Nonce=n1, Signature=ff")
+	require.NoError(t, err)
+	assert.Equal(t, signature{keyID: "abc", nonce: "n1", sig: "ff", ts: time.Unix(1736935200, 0)}, sig)
+
+	_, err = parseSignature("KeyId=abc, Timestamp=soon, Nonce=n1, Signature=ff")
+	require.EqualError(t, err, `invalid timestamp "soon"`)
+	_, err = parseSignature("KeyId=abc, Timestamp=1, Nonce=" + strings.Repeat("n", 65) + ", Signature=ff")
+	require.EqualError(t, err, "nonce is longer than "+strconv.Itoa(maxNonceLen)+" characters")
+}
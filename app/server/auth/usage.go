@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+	"github.com/go-pkgz/rest/realip"
+
+	"github.com/umputun/stash/app/server/internal/access"
+)
+
+// tokenTouchInterval limits writes of token usage, a busy token is stored once a minute or on a new IP
+const tokenTouchInterval = time.Minute
+
+// defaultStaleTokenAge is how long a token stays unused before it's reported stale
+const defaultStaleTokenAge = 90 * 24 * time.Hour
+
+// tokenTouch is the last stored use of a token.
+type tokenTouch struct {
+	at time.Time
+	ip string
+}
+
+// TokenUsage is a named API token with its last use, as listed for admins.
+type TokenUsage struct {
+	Token       string     `json:"token"`       // masked token
+	Fingerprint string     `json:"fingerprint"` // stable token identifier, same as in exchanged tokens
+	Admin       bool       `json:"admin"`
+	FirstSeen   time.Time  `json:"first_seen"` // when usage tracking of the token started
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+	LastIP      string     `json:"last_ip,omitempty"`
+	Stale       bool       `json:"stale"` // unused for the stale age, never used tokens count from first seen
+}
+
+// WithStaleTokenAge sets how long a token stays unused before it's reported stale.
+func WithStaleTokenAge(age time.Duration) Option {
+	return func(s *Service) {
+		s.staleTokenAge = age
+	}
+}
+
+// recordTokenUse stores the use of a named token of the config by the request. Uses of exchanged
+// tokens count for their parent token, tokens of cloud logins and workloads are not tracked.
+func (s *Service) recordTokenUse(r *http.Request, acl TokenACL) {
+	token := acl.Token
+	if acl.parent != nil {
+		token = acl.parent.Token
+	}
+	s.mu.RLock()
+	_, named := s.tokens[token]
+	s.mu.RUnlock()
+	if token == "" || !named {
+		return
+	}
+
+	fp, now := tokenFingerprint(token), time.Now()
+	ip, _ := realip.Get(r)
+	s.usageMu.Lock()
+	last, ok := s.tokenTouches[fp]
+	if ok && last.ip == ip && now.Sub(last.at) < tokenTouchInterval {
+		s.usageMu.Unlock()
+		return
+	}
+	if s.tokenTouches == nil {
+		s.tokenTouches = make(map[string]tokenTouch)
+	}
+	s.tokenTouches[fp] = tokenTouch{at: now, ip: ip}
+	s.usageMu.Unlock()
+
+	if err := s.sessionStore.TouchToken(r.Context(), fp, ip, now); err != nil {
+		log.Printf("[WARN] failed to record use of token %s: %v", MaskToken(token), err)
+	}
+}
+
+// syncTokenUsage starts usage tracking of new tokens of the config and drops removed tokens,
+// then logs the tokens unused for the stale age.
+func (s *Service) syncTokenUsage(ctx context.Context) {
+	s.mu.RLock()
+	fingerprints := make([]string, 0, len(s.tokens))
+	for token := range s.tokens {
+		fingerprints = append(fingerprints, tokenFingerprint(token))
+	}
+	s.mu.RUnlock()
+	if err := s.sessionStore.SyncTokenUsage(ctx, fingerprints); err != nil {
+		log.Printf("[WARN] failed to sync token usage: %v", err)
+		return
+	}
+
+	usage, err := s.TokenUsages(ctx, s.staleTokenAge)
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		return
+	}
+	var stale []string
+	for _, u := range usage {
+		if u.Stale {
+			stale = append(stale, u.Token)
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("[INFO] %d api tokens unused for %s: %s", len(stale), s.staleTokenAge, strings.Join(stale, ", "))
+	}
+}
+
+// TokenUsages returns the named tokens of the config with their last use, stale tokens first, then the
+// least recently used. Tokens unused for staleAge are stale, never used tokens count from first seen.
+func (s *Service) TokenUsages(ctx context.Context, staleAge time.Duration) ([]TokenUsage, error) {
+	stored, err := s.sessionStore.TokenUsages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token usage: %w", err)
+	}
+	byFingerprint := make(map[string]int, len(stored))
+	for i, u := range stored {
+		byFingerprint[u.Fingerprint] = i
+	}
+
+	now := time.Now()
+	s.mu.RLock()
+	res := make([]TokenUsage, 0, len(s.tokens))
+	for token, acl := range s.tokens {
+		u := TokenUsage{Token: MaskToken(token), Fingerprint: tokenFingerprint(token), Admin: acl.Admin, FirstSeen: now}
+		if i, ok := byFingerprint[u.Fingerprint]; ok {
+			u.FirstSeen, u.LastUsed, u.LastIP = stored[i].FirstSeen, stored[i].LastUsed, stored[i].LastIP
+		}
+		since := u.FirstSeen
+		if u.LastUsed != nil {
+			since = *u.LastUsed
+		}
+		u.Stale = staleAge > 0 && now.Sub(since) >= staleAge
+		res = append(res, u)
+	}
+	s.mu.RUnlock()
+
+	lastUse := func(u TokenUsage) time.Time {
+		if u.LastUsed == nil {
+			return time.Time{}
+		}
+		return *u.LastUsed
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Stale != res[j].Stale {
+			return res[i].Stale
+		}
+		if a, b := lastUse(res[i]), lastUse(res[j]); !a.Equal(b) {
+			return a.Before(b)
+		}
+		return res[i].Fingerprint < res[j].Fingerprint
+	})
+	return res, nil
+}
+
+// HandleTokenUsage lists the named tokens with their last use and client IP, flagging tokens unused for
+// the stale age. The stale_days query parameter overrides the configured age.
+// GET /auth/tokens
+func (s *Service) HandleTokenUsage(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, s) {
+		return
+	}
+	staleAge := s.staleTokenAge
+	if v := r.URL.Query().Get("stale_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "stale_days must be a positive number")
+			return
+		}
+		staleAge = time.Duration(days) * 24 * time.Hour
+	}
+	usage, err := s.TokenUsages(r.Context(), staleAge)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get token usage")
+		return
+	}
+	stale := 0
+	for _, u := range usage {
+		if u.Stale {
+			stale++
+		}
+	}
+	rest.RenderJSON(w, rest.JSON{"tokens": usage, "stale": stale, "stale_days": int(staleAge.Hours() / 24)})
+}
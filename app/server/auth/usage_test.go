@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_TokenUsage(t *testing.T) {
+	content := `
+users:
+  - name: alice
+    password: "$2a$10$hash"
+tokens:
+  - token: "ci-token-123"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+  - token: "old-token-456"
+    permissions:
+      - prefix: "app/*"
+        access: r
+  - token: "admin-token"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`
+	ss := testSessionStore(t)
+	svc, err := New(createTempFile(t, content), time.Hour, false, ss, nil, WithTokenExchange(exchangeTestSecret, time.Hour),
+		WithStaleTokenAge(30*24*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, svc.Activate(t.Context()))
+
+	handler := svc.TokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+	call := func(token, ip string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/cfg", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	usageOf := func(token string) TokenUsage {
+		t.Helper()
+		usage, err := svc.TokenUsages(t.Context(), 30*24*time.Hour)
+		require.NoError(t, err)
+		for _, u := range usage {
+			if u.Fingerprint == tokenFingerprint(token) {
+				return u
+			}
+		}
+		t.Fatalf("no usage of %s", token)
+		return TokenUsage{}
+	}
+
+	t.Run("new tokens are not used and not stale", func(t *testing.T) {
+		u := usageOf("ci-token-123")
+		assert.Equal(t, "ci-t****", u.Token)
+		assert.Nil(t, u.LastUsed)
+		assert.False(t, u.Stale)
+	})
+
+	t.Run("api call records use", func(t *testing.T) {
+		call("ci-token-123", "203.0.113.7")
+		u := usageOf("ci-token-123")
+		require.NotNil(t, u.LastUsed)
+		assert.WithinDuration(t, time.Now(), *u.LastUsed, time.Minute)
+		assert.Equal(t, "203.0.113.7", u.LastIP)
+	})
+
+	t.Run("new ip is recorded without waiting", func(t *testing.T) {
+		call("ci-token-123", "198.51.100.1")
+		assert.Equal(t, "198.51.100.1", usageOf("ci-token-123").LastIP)
+	})
+
+	t.Run("exchanged token use counts for parent", func(t *testing.T) {
+		resp, err := svc.ExchangeToken("old-token-456", ExchangeRequest{})
+		require.NoError(t, err)
+		call(resp.Token, "192.0.2.5")
+		u := usageOf("old-token-456")
+		require.NotNil(t, u.LastUsed)
+		assert.Equal(t, "192.0.2.5", u.LastIP)
+	})
+
+	t.Run("unused token is stale", func(t *testing.T) {
+		old := time.Now().Add(-40 * 24 * time.Hour)
+		require.NoError(t, ss.TouchToken(t.Context(), tokenFingerprint("old-token-456"), "192.0.2.5", old))
+		usage, err := svc.TokenUsages(t.Context(), 30*24*time.Hour)
+		require.NoError(t, err)
+		require.Len(t, usage, 3)
+		assert.Equal(t, tokenFingerprint("old-token-456"), usage[0].Fingerprint, "stale tokens first")
+		assert.True(t, usage[0].Stale)
+		assert.False(t, usage[1].Stale)
+
+		usage, err = svc.TokenUsages(t.Context(), 60*24*time.Hour)
+		require.NoError(t, err)
+		assert.False(t, usage[0].Stale)
+	})
+
+	t.Run("admin listing", func(t *testing.T) {
+		list := func(query string, configure func(r *http.Request)) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/auth/tokens"+query, http.NoBody)
+			configure(req)
+			rec := httptest.NewRecorder()
+			svc.HandleTokenUsage(rec, req)
+			return rec
+		}
+		assert.Equal(t, http.StatusUnauthorized, list("", func(*http.Request) {}).Code)
+		assert.Equal(t, http.StatusForbidden, list("", func(r *http.Request) { r.Header.Set("X-Auth-Token", "ci-token-123") }).Code)
+		admin := func(r *http.Request) { r.Header.Set("X-Auth-Token", "admin-token") }
+		assert.Equal(t, http.StatusBadRequest, list("?stale_days=abc", admin).Code)
+
+		rec := list("?stale_days=7", admin)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			Tokens    []TokenUsage `json:"tokens"`
+			Stale     int          `json:"stale"`
+			StaleDays int          `json:"stale_days"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 7, resp.StaleDays)
+		assert.Equal(t, 1, resp.Stale)
+		require.Len(t, resp.Tokens, 3)
+		assert.Equal(t, "old-****", resp.Tokens[0].Token)
+		assert.NotContains(t, rec.Body.String(), "old-token-456", "tokens are masked")
+
+		// the admin call itself is recorded
+		require.NotNil(t, usageOf("admin-token").LastUsed)
+	})
+}
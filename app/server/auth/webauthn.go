@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// COSE algorithms of supported passkeys, offered to authenticators in this order of preference
+const (
+	coseES256 = -7
+	coseEdDSA = -8
+	coseRS256 = -257
+)
+
+// b64url is the encoding of binary WebAuthn fields in JSON, browsers send them unpadded
+var b64url = base64.RawURLEncoding
+
+// clientData is the part of the collected client data the server checks.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed authenticator data of a registration or an assertion.
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	credID    []byte // attested credential, registration only
+	publicKey []byte // COSE key of the attested credential, registration only
+}
+
+// verifyClientData checks the client data JSON is of the ceremony type, for the challenge and from the origin.
+func verifyClientData(raw []byte, typ string, challenge []byte, origin string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	got, err := b64url.DecodeString(cd.Challenge)
+	if err != nil || !bytes.Equal(got, challenge) {
+		return errors.New("challenge mismatch")
+	}
+	if cd.Origin != origin || cd.CrossOrigin {
+		return fmt.Errorf("unexpected origin %q", cd.Origin)
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the authenticator data and checks it's for the relying party and the user
+// was present. The attested credential is parsed if the data has one.
+func parseAuthenticatorData(data []byte, rpID string) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, errors.New("authenticator data too short")
+	}
+	ad := authenticatorData{rpIDHash: data[:32], flags: data[32], signCount: binary.BigEndian.Uint32(data[33:37])}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return authenticatorData{}, errors.New("relying party mismatch")
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return authenticatorData{}, errors.New("user not present")
+	}
+	if ad.flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	// attested credential data: aaguid (16), credential id length (2), credential id, COSE key
+	rest := data[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, errors.New("attested credential data too short")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return authenticatorData{}, errors.New("credential id too short")
+	}
+	ad.credID, rest = rest[:idLen], rest[idLen:]
+	_, keyLen, err := decodeCBOR(rest) // extensions may follow the key
+	if err != nil {
+		return authenticatorData{}, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	ad.publicKey = rest[:keyLen]
+	return ad, nil
+}
+
+// parseAttestationObject returns the authenticator data of an attestation object. The attestation statement
+// is not verified, passkeys are registered with "none" attestation.
+func parseAttestationObject(data []byte) ([]byte, error) {
+	obj, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, errors.New("attestation object is not a map")
+	}
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+	return authData, nil
+}
+
+// parseCOSEKey returns the public key of a COSE key with one of the supported algorithms.
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	obj, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("COSE key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	crv, _ := m[int64(-1)].(int64)
+	switch {
+	case kty == 2 && alg == coseES256 && crv == 1:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid P-256 coordinates")
+		}
+		// uncompressed SEC 1 point, parsing rejects points off the curve
+		point := append(append([]byte{4}, x...), y...)
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid P-256 key: %w", err)
+		}
+		return pub, alg, nil
+	case kty == 1 && alg == coseEdDSA && crv == 6:
+		x, _ := m[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == coseRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA key")
+		}
+		exp := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verifyAssertion checks the signature of an assertion over the authenticator data and the client data hash.
+func verifyAssertion(coseKey, authData, clientDataJSON, sig []byte) error {
+	pub, _, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, signed, sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// workloadACL is the ACL of a SPIFFE ID, or of all IDs under a path for wildcard entries.
+type workloadACL struct {
+	id       string // SPIFFE ID, wildcard entries keep the path with trailing "/"
+	wildcard bool
+	acl      TokenACL
+}
+
+// matches checks if the SPIFFE ID is covered by this entry.
+func (w workloadACL) matches(id string) bool {
+	if w.wildcard {
+		return strings.HasPrefix(id, w.id)
+	}
+	return id == w.id
+}
+
+// WithSPIFFE enables SPIFFE workload identities from the trust domain. Workloads present X.509 SVIDs as
+// mTLS client certificates, verified against the trust bundle by the TLS server, and get the ACL of the
+// matching "workloads" entry of the auth config.
+func WithSPIFFE(trustDomain string) Option {
+	return func(s *Service) {
+		s.trustDomain = trustDomain
+	}
+}
+
+// workloadACL returns the ACL of the SPIFFE workload identified by the request's client certificate
+// and its SPIFFE ID, false if the request has no verified SVID of the trust domain or the ID is not configured.
+func (s *Service) workloadACL(r *http.Request) (acl TokenACL, id string, ok bool) {
+	if s == nil || s.trustDomain == "" {
+		return TokenACL{}, "", false
+	}
+	if id, ok = s.workloadID(r); !ok {
+		return TokenACL{}, "", false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, w := range s.workloads { // sorted longest first, exact IDs before wildcards of the same length
+		if w.matches(id) {
+			return w.acl, id, true
+		}
+	}
+	return TokenACL{}, "", false
+}
+
+// workloadID returns the SPIFFE ID of the request's client certificate. The certificate chain must be
+// verified by the TLS server, and the X.509 SVID must have exactly one URI SAN with ID of the trust domain.
+func (s *Service) workloadID(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	if len(leaf.URIs) != 1 || leaf.IsCA {
+		return "", false
+	}
+	u := leaf.URIs[0]
+	if err := validateSpiffeID(u); err != nil || u.Host != s.trustDomain || u.Path == "" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// parseWorkloadConfigs converts WorkloadConfig slice to workload ACLs, sorted for longest match first.
+func parseWorkloadConfigs(configs []WorkloadConfig) ([]workloadACL, error) {
+	res := make([]workloadACL, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, wc := range configs {
+		id, wildcard := strings.CutSuffix(wc.SpiffeID, "/*")
+		u, err := url.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spiffe id %q: %w", wc.SpiffeID, err)
+		}
+		if err = validateSpiffeID(u); err != nil {
+			return nil, fmt.Errorf("invalid spiffe id %q: %w", wc.SpiffeID, err)
+		}
+		if !wildcard && u.Path == "" {
+			return nil, fmt.Errorf("invalid spiffe id %q: path is required, use /* suffix for the whole trust domain", wc.SpiffeID)
+		}
+		if seen[wc.SpiffeID] {
+			return nil, fmt.Errorf("duplicate spiffe id %q", wc.SpiffeID)
+		}
+		seen[wc.SpiffeID] = true
+
+		acl, err := parsePermissionConfigs(wc.SpiffeID, wc.Permissions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permissions for spiffe id %q: %w", wc.SpiffeID, err)
+		}
+		acl.Admin = wc.Admin
+		if acl.scopes, err = parseScopes(wc.Scopes); err != nil {
+			return nil, fmt.Errorf("invalid scopes for spiffe id %q: %w", wc.SpiffeID, err)
+		}
+		if wildcard {
+			id += "/"
+		}
+		res = append(res, workloadACL{id: id, wildcard: wildcard, acl: acl})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if len(res[i].id) != len(res[j].id) {
+			return len(res[i].id) > len(res[j].id)
+		}
+		return !res[i].wildcard && res[j].wildcard
+	})
+	return res, nil
+}
+
+// validateSpiffeID checks the SPIFFE ID format: spiffe scheme, trust domain and path without
+// query, fragment, port or user info.
+func validateSpiffeID(u *url.URL) error {
+	switch {
+	case u.Scheme != "spiffe":
+		return errors.New("scheme must be spiffe")
+	case u.Host == "" || u.Port() != "" || u.User != nil:
+		return errors.New("trust domain is required and can't have port or user info")
+	case u.RawQuery != "" || u.Fragment != "" || u.Opaque != "":
+		return errors.New("query and fragment are not allowed")
+	case strings.HasSuffix(u.Path, "/"):
+		return errors.New("path can't end with /")
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+const workloadTestConfig = `
+workloads:
+  - spiffe_id: "spiffe://mesh.example/ns/prod/sa/billing"
+    permissions:
+      - prefix: "billing/*"
+        access: rw
+  - spiffe_id: "spiffe://mesh.example/ns/prod/*"
+    permissions:
+      - prefix: "shared/*"
+        access: r
+    scopes: [read]
+  - spiffe_id: "spiffe://mesh.example/ns/ops/sa/admin"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+tokens:
+  - token: "apitoken"
+    permissions:
+      - prefix: "*"
+        access: r
+`
+
+// workloadRequest makes a request with verified client certificate of the SPIFFE IDs.
+func workloadRequest(method, target string, ids ...string) *http.Request {
+	req := httptest.NewRequest(method, target, http.NoBody)
+	leaf := &x509.Certificate{}
+	for _, id := range ids {
+		u, _ := url.Parse(id)
+		leaf.URIs = append(leaf.URIs, u)
+	}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+	return req
+}
+
+func TestParseWorkloadConfigs(t *testing.T) {
+	t.Run("sorted for longest match", func(t *testing.T) {
+		res, err := parseWorkloadConfigs([]WorkloadConfig{
+			{SpiffeID: "spiffe://td/*"},
+			{SpiffeID: "spiffe://td/ns/a"},
+			{SpiffeID: "spiffe://td/ns/*"},
+		})
+		require.NoError(t, err)
+		ids := make([]string, 0, len(res))
+		for _, w := range res {
+			ids = append(ids, w.id)
+		}
+		assert.Equal(t, []string{"spiffe://td/ns/a", "spiffe://td/ns/", "spiffe://td/"}, ids)
+	})
+
+	tests := []struct {
+		name string
+		id   string
+		err  string
+	}{
+		{"wrong scheme", "https://td/ns/a", "scheme must be spiffe"},
+		{"no trust domain", "spiffe:///ns/a", "trust domain is required"},
+		{"port", "spiffe://td:8080/ns/a", "trust domain is required"},
+		{"query", "spiffe://td/ns/a?x=1", "query and fragment are not allowed"},
+		{"no path", "spiffe://td", "path is required"},
+		{"trailing slash", "spiffe://td/ns/", "path can't end with /"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseWorkloadConfigs([]WorkloadConfig{{SpiffeID: tc.id}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := parseWorkloadConfigs([]WorkloadConfig{{SpiffeID: "spiffe://td/a"}, {SpiffeID: "spiffe://td/a"}})
+		require.EqualError(t, err, `duplicate spiffe id "spiffe://td/a"`)
+	})
+
+	t.Run("invalid scopes", func(t *testing.T) {
+		_, err := parseWorkloadConfigs([]WorkloadConfig{{SpiffeID: "spiffe://td/a", Scopes: []string{"all"}}})
+		require.ErrorContains(t, err, `invalid scopes for spiffe id "spiffe://td/a"`)
+	})
+}
+
+func TestService_WorkloadACL(t *testing.T) {
+	f := createTempFile(t, workloadTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithSPIFFE("mesh.example"))
+	require.NoError(t, err)
+
+	t.Run("exact id", func(t *testing.T) {
+		acl, id, ok := svc.workloadACL(workloadRequest("GET", "/kv/x", "spiffe://mesh.example/ns/prod/sa/billing"))
+		require.True(t, ok)
+		assert.Equal(t, "spiffe://mesh.example/ns/prod/sa/billing", id)
+		assert.True(t, acl.CheckKeyPermission("billing/db", true))
+		assert.False(t, acl.CheckKeyPermission("shared/x", false), "exact entry wins over wildcard")
+	})
+
+	t.Run("wildcard id", func(t *testing.T) {
+		acl, _, ok := svc.workloadACL(workloadRequest("GET", "/kv/x", "spiffe://mesh.example/ns/prod/sa/web"))
+		require.True(t, ok)
+		assert.True(t, acl.CheckKeyPermission("shared/x", false))
+		assert.False(t, acl.AllowsScope(enum.ScopeList))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		for name, req := range map[string]*http.Request{
+			"unknown id":         workloadRequest("GET", "/kv/x", "spiffe://mesh.example/ns/dev/sa/web"),
+			"other trust domain": workloadRequest("GET", "/kv/x", "spiffe://other.example/ns/prod/sa/billing"),
+			"two uri sans":       workloadRequest("GET", "/kv/x", "spiffe://mesh.example/ns/prod/a", "spiffe://mesh.example/ns/prod/b"),
+			"no uri san":         workloadRequest("GET", "/kv/x"),
+			"not spiffe":         workloadRequest("GET", "/kv/x", "https://mesh.example/ns/prod/a"),
+			"no tls":             httptest.NewRequest("GET", "/kv/x", http.NoBody),
+		} {
+			_, _, ok := svc.workloadACL(req)
+			assert.False(t, ok, name)
+		}
+
+		req := httptest.NewRequest("GET", "/kv/x", http.NoBody)
+		u, _ := url.Parse("spiffe://mesh.example/ns/prod/sa/billing")
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{u}}}}
+		_, _, ok := svc.workloadACL(req)
+		assert.False(t, ok, "unverified certificate")
+	})
+
+	t.Run("disabled without trust domain", func(t *testing.T) {
+		disabled, err := New(f, time.Hour, false, testSessionStore(t), nil)
+		require.NoError(t, err)
+		_, _, ok := disabled.workloadACL(workloadRequest("GET", "/kv/x", "spiffe://mesh.example/ns/prod/sa/billing"))
+		assert.False(t, ok)
+	})
+
+	t.Run("request helpers", func(t *testing.T) {
+		req := workloadRequest("GET", "/kv/", "spiffe://mesh.example/ns/prod/sa/billing")
+		actorType, actorName := svc.GetRequestActor(req)
+		assert.Equal(t, "token", actorType)
+		assert.Equal(t, "spiffe://mesh.example/ns/prod/sa/billing", actorName)
+		assert.Equal(t, []string{"billing/db"}, svc.FilterKeysForRequest(req, []string{"billing/db", "shared/x", "other"}))
+		assert.False(t, svc.IsRequestAdmin(req))
+		assert.True(t, svc.IsRequestAdmin(workloadRequest("GET", "/kv/", "spiffe://mesh.example/ns/ops/sa/admin")))
+
+		req.Header.Set("X-Auth-Token", "apitoken")
+		actorType, actorName = svc.GetRequestActor(req)
+		assert.Equal(t, "token", actorType)
+		assert.Equal(t, "token:apit****", actorName, "token takes precedence")
+	})
+}
+
+func TestService_TokenMiddleware_Workload(t *testing.T) {
+	f := createTempFile(t, workloadTestConfig)
+	svc, err := New(f, time.Hour, false, testSessionStore(t), nil, WithSPIFFE("mesh.example"))
+	require.NoError(t, err)
+
+	handler := svc.TokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		expect int
+	}{
+		{"billing writes", workloadRequest("PUT", "/kv/billing/db", "spiffe://mesh.example/ns/prod/sa/billing"), http.StatusOK},
+		{"billing denied outside prefix", workloadRequest("GET", "/kv/other", "spiffe://mesh.example/ns/prod/sa/billing"),
+			http.StatusForbidden},
+		{"wildcard reads", workloadRequest("GET", "/kv/shared/x", "spiffe://mesh.example/ns/prod/sa/web"), http.StatusOK},
+		{"wildcard scope", workloadRequest("GET", "/kv/", "spiffe://mesh.example/ns/prod/sa/web"), http.StatusForbidden},
+		{"unknown workload", workloadRequest("GET", "/kv/shared/x", "spiffe://mesh.example/ns/dev/sa/web"), http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tc.req)
+			assert.Equal(t, tc.expect, rec.Code)
+		})
+	}
+
+	t.Run("invalid token is not replaced by workload", func(t *testing.T) {
+		req := workloadRequest("GET", "/kv/billing/db", "spiffe://mesh.example/ns/prod/sa/billing")
+		req.Header.Set("X-Auth-Token", "bad")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
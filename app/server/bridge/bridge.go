@@ -0,0 +1,184 @@
+// Package bridge forwards key change events to message buses, NATS subjects, Kafka and MQTT topics,
+// so downstream data pipelines consume config changes from the bus instead of subscribing to stash.
+// The same events purge cached URLs of public keys from a CDN.
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// serialization formats
+const (
+	FormatJSON        = "json"        // Event as is
+	FormatCloudEvents = "cloudevents" // CloudEvents 1.0 structured JSON with Event as data
+)
+
+const (
+	queueSize   = 10000 // events waiting to be sent, newer events are dropped when full
+	maxBatch    = 500   // events sent in a single batch
+	maxAttempts = 3     // send attempts of a batch before it's dropped
+	sendTimeout = 10 * time.Second
+)
+
+// Event is a key change event forwarded to the bus.
+type Event struct {
+	Key       string           `json:"key"`
+	Action    enum.AuditAction `json:"action"`
+	Timestamp string           `json:"timestamp"`
+}
+
+// Message is a serialized event, Key is the stash key.
+type Message struct {
+	Key   string
+	Value []byte
+}
+
+// Sender delivers batches of messages to a bus or acts on them otherwise, like a CDN purge.
+type Sender interface {
+	Send(ctx context.Context, msgs []Message) error
+	Close() error
+	String() string
+}
+
+// Bridge forwards key change events to senders. Events are queued and sent in the background,
+// so a slow or unavailable bus never blocks writes. Delivery is best effort: failed batches are
+// retried a few times and then dropped, as are events arriving when the queue is full.
+type Bridge struct {
+	format  string
+	senders []Sender
+	queue   chan Event
+	dropped atomic.Int64
+
+	retryDelay time.Duration // delay after the first failed send, grows with attempts
+}
+
+// New creates a bridge serializing events in the format, Run must be started to send them.
+func New(format string, senders ...Sender) (*Bridge, error) {
+	switch format {
+	case "":
+		format = FormatJSON
+	case FormatJSON, FormatCloudEvents:
+	default:
+		return nil, fmt.Errorf("unknown bridge format %q", format)
+	}
+	return &Bridge{format: format, senders: senders, queue: make(chan Event, queueSize),
+		retryDelay: time.Second}, nil
+}
+
+// Publish queues the key change event, it never blocks.
+func (b *Bridge) Publish(key string, action enum.AuditAction) {
+	event := Event{Key: key, Action: action, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	select {
+	case b.queue <- event:
+	default:
+		// log the first drop and then every 1000th, a stuck bus would flood the log otherwise
+		if n := b.dropped.Add(1); n%1000 == 1 {
+			log.Printf("[WARN] bridge queue is full, %d events dropped so far", n)
+		}
+	}
+}
+
+// Run sends queued events until the context is canceled, and closes the senders then.
+func (b *Bridge) Run(ctx context.Context) {
+	defer func() {
+		for _, s := range b.senders {
+			if err := s.Close(); err != nil {
+				log.Printf("[WARN] failed to close bridge to %s: %v", s, err)
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-b.queue:
+			b.send(ctx, b.batch(event))
+		}
+	}
+}
+
+// batch collects the event and up to maxBatch-1 events already queued after it.
+func (b *Bridge) batch(first Event) []Event {
+	events := []Event{first}
+	for len(events) < maxBatch {
+		select {
+		case e := <-b.queue:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+	return events
+}
+
+// send serializes the events and sends them to every sender.
+func (b *Bridge) send(ctx context.Context, events []Event) {
+	msgs := make([]Message, 0, len(events))
+	for _, e := range events {
+		value, err := b.encode(e)
+		if err != nil {
+			log.Printf("[WARN] bridge event for %q skipped: %v", e.Key, err)
+			continue
+		}
+		msgs = append(msgs, Message{Key: e.Key, Value: value})
+	}
+	for _, s := range b.senders {
+		b.sendWithRetry(ctx, s, msgs)
+	}
+}
+
+// sendWithRetry sends the batch to a single sender, retrying with a growing delay.
+func (b *Bridge) sendWithRetry(ctx context.Context, s Sender, msgs []Message) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		sctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = s.Send(sctx, msgs)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt) * b.retryDelay):
+		}
+	}
+	log.Printf("[WARN] failed to send %d events to %s, dropped: %v", len(msgs), s, err)
+}
+
+// encode serializes the event in the bridge format.
+func (b *Bridge) encode(e Event) ([]byte, error) {
+	var v any = e
+	if b.format == FormatCloudEvents {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		v = map[string]any{
+			"specversion":     "1.0",
+			"id":              hex.EncodeToString(id),
+			"source":          "stash",
+			"type":            "io.stash.key." + e.Action.String(),
+			"subject":         e.Key,
+			"time":            e.Timestamp,
+			"datacontenttype": "application/json",
+			"data":            e,
+		}
+	}
+	res, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,135 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// senderRecorder records sent batches, failing the first fails sends.
+type senderRecorder struct {
+	mu      sync.Mutex
+	batches [][]Message
+	fails   int
+	calls   int
+	closed  bool
+}
+
+func (s *senderRecorder) Send(_ context.Context, msgs []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.fails {
+		return errors.New("bus down")
+	}
+	s.batches = append(s.batches, msgs)
+	return nil
+}
+
+func (s *senderRecorder) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *senderRecorder) String() string { return "recorder" }
+
+func (s *senderRecorder) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []string
+	for _, b := range s.batches {
+		for _, m := range b {
+			res = append(res, m.Key)
+		}
+	}
+	return res
+}
+
+func TestNew(t *testing.T) {
+	b, err := New("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, b.format)
+	_, err = New("avro")
+	require.EqualError(t, err, `unknown bridge format "avro"`)
+}
+
+func TestBridge_Run(t *testing.T) {
+	nats, kafka := &senderRecorder{}, &senderRecorder{fails: 1}
+	b, err := New(FormatJSON, nats, kafka)
+	require.NoError(t, err)
+	b.retryDelay = time.Millisecond
+
+	// queued before run, so sent in a single batch
+	b.Publish("app/a", enum.AuditActionCreate)
+	b.Publish("app/b", enum.AuditActionDelete)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(kafka.keys()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"app/a", "app/b"}, nats.keys())
+	assert.Len(t, nats.batches, 1)
+	assert.Equal(t, 2, kafka.calls, "failed batch is retried")
+
+	var event Event
+	require.NoError(t, json.Unmarshal(nats.batches[0][1].Value, &event))
+	assert.Equal(t, "app/b", event.Key)
+	assert.Equal(t, enum.AuditActionDelete, event.Action)
+	assert.NotEmpty(t, event.Timestamp)
+
+	cancel()
+	<-done
+	assert.True(t, nats.closed)
+	assert.True(t, kafka.closed)
+}
+
+func TestBridge_SendDropsAfterAttempts(t *testing.T) {
+	s := &senderRecorder{fails: 100}
+	b, err := New(FormatJSON, s)
+	require.NoError(t, err)
+	b.retryDelay = time.Millisecond
+	b.send(t.Context(), []Event{{Key: "app/a", Action: enum.AuditActionUpdate}})
+	assert.Equal(t, maxAttempts, s.calls)
+	assert.Empty(t, s.keys())
+}
+
+func TestBridge_PublishQueueFull(t *testing.T) {
+	b, err := New(FormatJSON)
+	require.NoError(t, err)
+	for range queueSize + 5 {
+		b.Publish("app/a", enum.AuditActionUpdate)
+	}
+	assert.Len(t, b.queue, queueSize)
+	assert.Equal(t, int64(5), b.dropped.Load(), "publish never blocks")
+	assert.Len(t, b.batch(<-b.queue), maxBatch)
+}
+
+func TestBridge_EncodeCloudEvents(t *testing.T) {
+	b, err := New(FormatCloudEvents)
+	require.NoError(t, err)
+	data, err := b.encode(Event{Key: "app/db", Action: enum.AuditActionUpdate, Timestamp: "2025-01-03T10:30:00Z"})
+	require.NoError(t, err)
+
+	var ce map[string]any
+	require.NoError(t, json.Unmarshal(data, &ce))
+	assert.Equal(t, "1.0", ce["specversion"])
+	assert.Equal(t, "io.stash.key.update", ce["type"])
+	assert.Equal(t, "stash", ce["source"])
+	assert.Equal(t, "app/db", ce["subject"])
+	assert.Equal(t, "2025-01-03T10:30:00Z", ce["time"])
+	assert.Len(t, ce["id"], 32)
+	assert.Equal(t, map[string]any{"key": "app/db", "action": "update", "timestamp": "2025-01-03T10:30:00Z"}, ce["data"])
+}
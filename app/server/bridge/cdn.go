@@ -0,0 +1,160 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CDN providers
+const (
+	CDNCloudflare = "cloudflare"
+	CDNFastly     = "fastly"
+)
+
+// default purge endpoints
+const (
+	cloudflareAPI   = "https://api.cloudflare.com/client/v4"
+	fastlyAPI       = "https://api.fastly.com"
+	cloudflareBatch = 30 // max urls per cloudflare purge request
+)
+
+// CDNConfig defines the CDN purge.
+type CDNConfig struct {
+	Provider string                // cloudflare or fastly
+	BaseURL  string                // public stash URL served by the CDN, e.g. https://config.example.com
+	Zone     string                // cloudflare zone id
+	Token    string                // cloudflare API token or fastly API key
+	Public   func(key string) bool // reports keys readable anonymously, only those are cached by the CDN
+}
+
+// CDN purges cached URLs of changed public keys, <base url>/kv/<key>, from Cloudflare or Fastly.
+// Keys the public can't read are skipped, the CDN never caches them.
+type CDN struct {
+	cfg    CDNConfig
+	api    string
+	client *http.Client
+}
+
+// NewCDN creates a CDN purge sender.
+func NewCDN(cfg CDNConfig) (*CDN, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("cdn base url must be an absolute http(s) url, got %q", cfg.BaseURL)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("api token is required for %s purge", cfg.Provider)
+	}
+	if cfg.Public == nil {
+		cfg.Public = func(string) bool { return true }
+	}
+	c := &CDN{cfg: cfg, client: &http.Client{Timeout: sendTimeout}}
+	switch cfg.Provider {
+	case CDNCloudflare:
+		if cfg.Zone == "" {
+			return nil, errors.New("zone id is required for cloudflare purge")
+		}
+		c.api = cloudflareAPI
+	case CDNFastly:
+		c.api = fastlyAPI
+	default:
+		return nil, fmt.Errorf("unknown cdn provider %q", cfg.Provider)
+	}
+	return c, nil
+}
+
+// Send purges URLs of the public keys among the messages.
+func (c *CDN) Send(ctx context.Context, msgs []Message) error {
+	seen := make(map[string]bool, len(msgs))
+	var urls []string
+	for _, m := range msgs {
+		if seen[m.Key] || !c.cfg.Public(m.Key) {
+			continue
+		}
+		seen[m.Key] = true
+		u, err := url.JoinPath(c.cfg.BaseURL, "kv", m.Key)
+		if err != nil {
+			return fmt.Errorf("make url of %q: %w", m.Key, err)
+		}
+		urls = append(urls, u)
+	}
+	if c.cfg.Provider == CDNFastly {
+		for _, u := range urls {
+			if err := c.purgeFastly(ctx, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for len(urls) > 0 {
+		batch := urls[:min(len(urls), cloudflareBatch)]
+		urls = urls[len(batch):]
+		if err := c.purgeCloudflare(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeCloudflare purges the urls in the zone.
+func (c *CDN) purgeCloudflare(ctx context.Context, urls []string) error {
+	body, err := json.Marshal(map[string][]string{"files": urls})
+	if err != nil {
+		return fmt.Errorf("marshal purge request: %w", err)
+	}
+	endpoint := c.api + "/zones/" + url.PathEscape(c.cfg.Zone) + "/purge_cache"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	return c.do(req)
+}
+
+// purgeFastly purges a single url, the API takes it without the scheme.
+func (c *CDN) purgeFastly(ctx context.Context, cached string) error {
+	u, err := url.Parse(cached)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+	endpoint := c.api + "/purge/" + u.Host + u.EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Fastly-Key", c.cfg.Token)
+	req.Header.Set("Accept", "application/json")
+	return c.do(req)
+}
+
+// do sends the purge request, non-2xx responses are reported as errors.
+func (c *CDN) do(req *http.Request) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("purge %s cache: %w", c.cfg.Provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s purge returned %d: %s", c.cfg.Provider, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close closes idle API connections.
+func (c *CDN) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// String returns the sender name for logs.
+func (c *CDN) String() string {
+	return c.cfg.Provider + " purge of " + strings.TrimSuffix(c.cfg.BaseURL, "/") + "/kv/"
+}
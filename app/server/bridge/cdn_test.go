@@ -0,0 +1,92 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCDN(t *testing.T) {
+	_, err := NewCDN(CDNConfig{Provider: CDNCloudflare, BaseURL: "https://cdn.example.com", Zone: "z1", Token: "t"})
+	require.NoError(t, err)
+	_, err = NewCDN(CDNConfig{Provider: CDNFastly, BaseURL: "https://cdn.example.com", Token: "t"})
+	require.NoError(t, err)
+
+	_, err = NewCDN(CDNConfig{Provider: CDNCloudflare, BaseURL: "https://cdn.example.com", Token: "t"})
+	require.EqualError(t, err, "zone id is required for cloudflare purge")
+	_, err = NewCDN(CDNConfig{Provider: CDNFastly, BaseURL: "https://cdn.example.com"})
+	require.EqualError(t, err, "api token is required for fastly purge")
+	_, err = NewCDN(CDNConfig{Provider: CDNFastly, BaseURL: "/kv", Token: "t"})
+	require.EqualError(t, err, `cdn base url must be an absolute http(s) url, got "/kv"`)
+	_, err = NewCDN(CDNConfig{Provider: "akamai", BaseURL: "https://cdn.example.com", Token: "t"})
+	require.EqualError(t, err, `unknown cdn provider "akamai"`)
+}
+
+func TestCDN_SendCloudflare(t *testing.T) {
+	var mu sync.Mutex
+	var purged [][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/zones/z1/purge_cache", r.URL.Path)
+		assert.Equal(t, "Bearer cf-token", r.Header.Get("Authorization"))
+		var req struct {
+			Files []string `json:"files"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		purged = append(purged, req.Files)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewCDN(CDNConfig{Provider: CDNCloudflare, BaseURL: "https://cdn.example.com/stash/", Zone: "z1",
+		Token: "cf-token", Public: func(key string) bool { return strings.HasPrefix(key, "public/") }})
+	require.NoError(t, err)
+	c.api = ts.URL
+
+	msgs := []Message{{Key: "public/a"}, {Key: "private/b"}, {Key: "public/a"}, {Key: "public/dir/c d"}}
+	for i := range 30 {
+		msgs = append(msgs, Message{Key: "public/many/" + strings.Repeat("x", i+1)})
+	}
+	require.NoError(t, c.Send(t.Context(), msgs))
+	require.Len(t, purged, 2, "32 urls in batches of 30")
+	assert.Len(t, purged[0], 30)
+	assert.Len(t, purged[1], 2)
+	assert.Equal(t, "https://cdn.example.com/stash/kv/public/a", purged[0][0], "private and duplicate keys skipped")
+	assert.Equal(t, "https://cdn.example.com/stash/kv/public/dir/c%20d", purged[0][1])
+
+	purged = nil
+	require.NoError(t, c.Send(t.Context(), []Message{{Key: "private/b"}}))
+	assert.Empty(t, purged, "nothing public, no request")
+	assert.Equal(t, "cloudflare purge of https://cdn.example.com/stash/kv/", c.String())
+}
+
+func TestCDN_SendFastly(t *testing.T) {
+	var paths []string
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "fastly-key", r.Header.Get("Fastly-Key"))
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewCDN(CDNConfig{Provider: CDNFastly, BaseURL: "https://cdn.example.com", Token: "fastly-key"})
+	require.NoError(t, err)
+	c.api = ts.URL
+
+	require.NoError(t, c.Send(t.Context(), []Message{{Key: "app/a"}, {Key: "app/b c"}}))
+	assert.Equal(t, []string{"/purge/cdn.example.com/kv/app/a", "/purge/cdn.example.com/kv/app/b%20c"}, paths,
+		"all keys are public without a filter")
+
+	status = http.StatusUnauthorized
+	require.EqualError(t, c.Send(t.Context(), []Message{{Key: "app/a"}}), `fastly purge returned 401: {"status":"ok"}`)
+}
@@ -0,0 +1,106 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Kafka produces messages to a Kafka topic through the Kafka REST Proxy (v2 API), so stash needs no
+// Kafka client and broker access. The stash key is the record key, which keeps changes of a key in
+// a single partition and in order. Basic auth credentials can be passed in the proxy URL.
+type Kafka struct {
+	url    string // produce endpoint, <proxy>/topics/<topic>
+	client *http.Client
+}
+
+// NewKafka creates a Kafka sender producing to the topic through the REST proxy at proxyURL.
+func NewKafka(proxyURL, topic string) (*Kafka, error) {
+	if topic == "" {
+		return nil, errors.New("kafka topic is required")
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse kafka rest proxy url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("kafka rest proxy url scheme must be http or https, got %q", u.Scheme)
+	}
+	endpoint := u.JoinPath("topics", topic)
+	return &Kafka{url: endpoint.String(), client: &http.Client{Timeout: sendTimeout}}, nil
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaOffset struct {
+	Error string `json:"error"`
+}
+
+// Send produces the messages in a single request. Records rejected by the proxy fail the whole batch.
+func (k *Kafka) Send(ctx context.Context, msgs []Message) error {
+	records := make([]kafkaRecord, 0, len(msgs))
+	for _, m := range msgs {
+		records = append(records, kafkaRecord{Key: m.Key, Value: m.Value})
+	}
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return fmt.Errorf("marshal kafka records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("produce to kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy returned %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	var res struct {
+		Offsets []kafkaOffset `json:"offsets"`
+	}
+	if err := json.Unmarshal(respBody, &res); err != nil {
+		return nil //nolint:nilerr // records are accepted with 2xx, offsets are only checked for per-record errors
+	}
+	var failed []string
+	for _, o := range res.Offsets {
+		if o.Error != "" {
+			failed = append(failed, o.Error)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("kafka rejected %d of %d records: %s", len(failed), len(msgs), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Close closes idle proxy connections.
+func (k *Kafka) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
+
+// String returns the sender name for logs, without credentials.
+func (k *Kafka) String() string {
+	u, err := url.Parse(k.url)
+	if err != nil {
+		return "kafka"
+	}
+	return "kafka " + u.Redacted()
+}
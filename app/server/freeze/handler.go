@@ -0,0 +1,162 @@
+// Package freeze provides the admin endpoints freezing key prefixes during incidents. Writes of keys under
+// a frozen prefix are rejected by the store with a message referencing the incident, until the freeze
+// expires or an admin lifts it. Reads are not affected.
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/access"
+	"github.com/umputun/stash/app/store"
+)
+
+//go:generate moq -out mocks/store.go -pkg mocks -skip-ensure -fmt goimports . Store
+//go:generate moq -out mocks/auth.go -pkg mocks -skip-ensure -fmt goimports . Auth
+
+const (
+	// DefaultTTL is the freeze duration if the request has no ttl
+	DefaultTTL = time.Hour
+	// MaxTTL limits the freeze duration, a forgotten freeze shouldn't block changes for long after the incident
+	MaxTTL = 7 * 24 * time.Hour
+)
+
+// Store defines the interface for freeze storage.
+type Store interface {
+	FreezePrefix(ctx context.Context, f store.Freeze) error
+	Unfreeze(ctx context.Context, prefix string) error
+	Freezes(ctx context.Context) ([]store.Freeze, error)
+}
+
+// Auth defines the interface for admin checks on freeze requests.
+type Auth interface {
+	GetRequestActor(r *http.Request) (actorType, actorName string)
+	IsRequestAdmin(r *http.Request) bool
+}
+
+// Handler serves the freeze endpoints, all of them admin only.
+type Handler struct {
+	store Store
+	auth  Auth
+}
+
+// NewHandler creates a freeze handler.
+func NewHandler(st Store, authSvc Auth) *Handler {
+	return &Handler{store: st, auth: authSvc}
+}
+
+// Request is the JSON request freezing a prefix. TTL is a duration like "2h" or a number of seconds,
+// DefaultTTL if empty.
+type Request struct {
+	Incident string `json:"incident"`
+	Message  string `json:"message,omitempty"`
+	TTL      string `json:"ttl,omitempty"`
+}
+
+// Freeze checks the request and returns the freeze of the prefix by the actor, starting at now.
+func (q Request) Freeze(prefix, actor string, now time.Time) (store.Freeze, error) {
+	res := store.Freeze{Prefix: store.NormalizeKey(prefix), Incident: strings.TrimSpace(q.Incident),
+		Message: strings.TrimSpace(q.Message), FrozenBy: actor, CreatedAt: now}
+	if res.Prefix == "" {
+		return store.Freeze{}, errors.New("prefix is required")
+	}
+	if res.Incident == "" {
+		return store.Freeze{}, errors.New("incident is required")
+	}
+	ttl := DefaultTTL
+	if q.TTL != "" {
+		var err error
+		if ttl, err = parseTTL(q.TTL); err != nil {
+			return store.Freeze{}, err
+		}
+	}
+	res.ExpiresAt = now.Add(ttl)
+	return res, nil
+}
+
+// parseTTL parses a duration like "2h" or a number of seconds, up to MaxTTL.
+func parseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if secs, atoiErr := strconv.Atoi(s); atoiErr == nil {
+		ttl, err = time.Duration(secs)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q: %w", s, err)
+	}
+	if ttl <= 0 || ttl > MaxTTL {
+		return 0, fmt.Errorf("ttl %q must be positive and at most %s", s, MaxTTL)
+	}
+	return ttl, nil
+}
+
+// HandleList returns the active freezes ordered by prefix (admin only).
+// GET /freezes
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	freezes, err := h.store.Freezes(r.Context())
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to get freezes")
+		return
+	}
+	if freezes == nil {
+		freezes = []store.Freeze{}
+	}
+	rest.RenderJSON(w, freezes)
+}
+
+// HandleFreeze freezes the prefix, or replaces its freeze, e.g. to extend it (admin only).
+// PUT /freezes/{prefix...}
+func (h *Handler) HandleFreeze(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	_, actor := h.auth.GetRequestActor(r)
+	f, err := req.Freeze(r.PathValue("prefix"), actor, time.Now().UTC())
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if err := h.store.FreezePrefix(r.Context(), f); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to freeze prefix")
+		return
+	}
+	log.Printf("[WARN] prefix %q frozen by %s until %s, incident %s", f.Prefix, actor, f.ExpiresAt.Format(time.RFC3339), f.Incident)
+	rest.RenderJSON(w, f)
+}
+
+// HandleUnfreeze lifts the freeze of the prefix before it expires (admin only).
+// DELETE /freezes/{prefix...}
+func (h *Handler) HandleUnfreeze(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	prefix := store.NormalizeKey(r.PathValue("prefix"))
+	err := h.store.Unfreeze(r.Context(), prefix)
+	if errors.Is(err, store.ErrNotFound) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "prefix is not frozen")
+		return
+	}
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to unfreeze prefix")
+		return
+	}
+	_, actor := h.auth.GetRequestActor(r)
+	log.Printf("[INFO] prefix %q unfrozen by %s", prefix, actor)
+	w.WriteHeader(http.StatusNoContent)
+}
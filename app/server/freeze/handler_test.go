@@ -0,0 +1,149 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/freeze/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestRequest_Freeze(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		req     Request
+		prefix  string
+		want    store.Freeze
+		wantErr string
+	}{
+		{name: "default ttl", req: Request{Incident: " INC-1 ", Message: "db failover"}, prefix: "/prod/db/",
+			want: store.Freeze{Prefix: "prod/db", Incident: "INC-1", Message: "db failover", FrozenBy: "admin",
+				CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{name: "duration ttl", req: Request{Incident: "INC-1", TTL: "90m"}, prefix: "prod",
+			want: store.Freeze{Prefix: "prod", Incident: "INC-1", FrozenBy: "admin", CreatedAt: now, ExpiresAt: now.Add(90 * time.Minute)}},
+		{name: "seconds ttl", req: Request{Incident: "INC-1", TTL: "600"}, prefix: "prod",
+			want: store.Freeze{Prefix: "prod", Incident: "INC-1", FrozenBy: "admin", CreatedAt: now, ExpiresAt: now.Add(10 * time.Minute)}},
+		{name: "no prefix", req: Request{Incident: "INC-1"}, prefix: "/", wantErr: "prefix is required"},
+		{name: "no incident", req: Request{Incident: " "}, prefix: "prod", wantErr: "incident is required"},
+		{name: "invalid ttl", req: Request{Incident: "INC-1", TTL: "soon"}, prefix: "prod", wantErr: `invalid ttl "soon"`},
+		{name: "ttl over max", req: Request{Incident: "INC-1", TTL: "200h"}, prefix: "prod", wantErr: "at most 168h0m0s"},
+		{name: "negative ttl", req: Request{Incident: "INC-1", TTL: "-1h"}, prefix: "prod", wantErr: "must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := tc.req.Freeze(tc.prefix, "admin", now)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, f)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	authMock := &mocks.AuthMock{
+		IsRequestAdminFunc: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" },
+		GetRequestActorFunc: func(r *http.Request) (string, string) {
+			switch r.Header.Get("Authorization") {
+			case "":
+				return "public", ""
+			case "Bearer admin":
+				return "user", "admin"
+			}
+			return "token", "token:xxxx****"
+		},
+	}
+	until := time.Now().Add(time.Hour).UTC()
+	st := &mocks.StoreMock{
+		FreezePrefixFunc: func(context.Context, store.Freeze) error { return nil },
+		UnfreezeFunc: func(_ context.Context, prefix string) error {
+			if prefix != "prod" {
+				return store.ErrNotFound
+			}
+			return nil
+		},
+		FreezesFunc: func(context.Context) ([]store.Freeze, error) {
+			return []store.Freeze{{Prefix: "prod", Incident: "INC-1", FrozenBy: "admin", ExpiresAt: until}}, nil
+		},
+	}
+	h := NewHandler(st, authMock)
+
+	call := func(handler http.HandlerFunc, method, prefix, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/freezes/"+prefix, strings.NewReader(body))
+		req.SetPathValue("prefix", prefix)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("freeze", func(t *testing.T) {
+		rec := call(h.HandleFreeze, http.MethodPut, "prod/", "admin", `{"incident":"INC-1","message":"db failover","ttl":"2h"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp store.Freeze
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "prod", resp.Prefix)
+		assert.Equal(t, "admin", resp.FrozenBy)
+		assert.WithinDuration(t, time.Now().Add(2*time.Hour), resp.ExpiresAt, time.Minute)
+
+		calls := st.FreezePrefixCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, "INC-1", calls[0].F.Incident)
+		assert.Equal(t, "db failover", calls[0].F.Message)
+	})
+
+	t.Run("freeze invalid request", func(t *testing.T) {
+		rec := call(h.HandleFreeze, http.MethodPut, "prod", "admin", `{"message":"no incident"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "incident is required")
+		assert.Equal(t, http.StatusBadRequest, call(h.HandleFreeze, http.MethodPut, "prod", "admin", `{`).Code)
+	})
+
+	t.Run("freeze store error", func(t *testing.T) {
+		failing := &mocks.StoreMock{FreezePrefixFunc: func(context.Context, store.Freeze) error { return errors.New("db error") }}
+		rec := call(NewHandler(failing, authMock).HandleFreeze, http.MethodPut, "prod", "admin", `{"incident":"INC-1"}`)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		rec := call(h.HandleList, http.MethodGet, "", "admin", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp []store.Freeze
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp, 1)
+		assert.Equal(t, "INC-1", resp[0].Incident)
+
+		empty := &mocks.StoreMock{FreezesFunc: func(context.Context) ([]store.Freeze, error) { return nil, nil }}
+		rec = call(NewHandler(empty, authMock).HandleList, http.MethodGet, "", "admin", "")
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	t.Run("unfreeze", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, call(h.HandleUnfreeze, http.MethodDelete, "prod", "admin", "").Code)
+		assert.Equal(t, http.StatusNotFound, call(h.HandleUnfreeze, http.MethodDelete, "staging", "admin", "").Code)
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		before := len(st.FreezePrefixCalls())
+		assert.Equal(t, http.StatusForbidden, call(h.HandleFreeze, http.MethodPut, "prod", "user", `{"incident":"INC-1"}`).Code)
+		assert.Equal(t, http.StatusUnauthorized, call(h.HandleFreeze, http.MethodPut, "prod", "", `{"incident":"INC-1"}`).Code)
+		assert.Equal(t, http.StatusForbidden, call(h.HandleUnfreeze, http.MethodDelete, "prod", "user", "").Code)
+		assert.Equal(t, http.StatusForbidden, call(h.HandleList, http.MethodGet, "", "user", "").Code)
+		assert.Len(t, st.FreezePrefixCalls(), before)
+		assert.Equal(t, http.StatusUnauthorized, call(NewHandler(st, nil).HandleList, http.MethodGet, "", "admin", "").Code)
+	})
+}
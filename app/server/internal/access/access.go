@@ -0,0 +1,38 @@
+// Package access guards admin-only endpoints shared by the API packages. Requests of non-admins
+// get 403 if authenticated and 401 otherwise.
+package access
+
+import (
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// Auth defines the request checks needed to guard admin-only endpoints.
+type Auth interface {
+	IsRequestAdmin(r *http.Request) bool
+	GetRequestActor(r *http.Request) (actorType, actorName string)
+}
+
+// RequireAdmin checks admin access, responds with 401 or 403 and returns false if denied.
+// A nil auth denies every request, as there is no way to authenticate an admin.
+func RequireAdmin(w http.ResponseWriter, r *http.Request, auth Auth) bool {
+	if auth == nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "unauthorized")
+		return false
+	}
+	if auth.IsRequestAdmin(r) {
+		return true
+	}
+	// authenticated but not admin (403) vs not authenticated at all (401)
+	actorType, _ := auth.GetRequestActor(r)
+	if actorType == enum.ActorTypeUser.String() || actorType == enum.ActorTypeToken.String() {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusForbidden, nil, "admin access required")
+		return false
+	}
+	rest.SendErrorJSON(w, r, log.Default(), http.StatusUnauthorized, nil, "unauthorized")
+	return false
+}
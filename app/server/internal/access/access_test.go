@@ -0,0 +1,45 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// actorAuth is an Auth of a single actor, an admin if admin is set
+type actorAuth struct {
+	actorType string
+	admin     bool
+}
+
+func (a actorAuth) IsRequestAdmin(*http.Request) bool { return a.admin }
+
+func (a actorAuth) GetRequestActor(*http.Request) (actorType, actorName string) {
+	return a.actorType, "name"
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		auth Auth
+		ok   bool
+		code int
+	}{
+		{name: "admin", auth: actorAuth{actorType: enum.ActorTypeUser.String(), admin: true}, ok: true, code: http.StatusOK},
+		{name: "user", auth: actorAuth{actorType: enum.ActorTypeUser.String()}, code: http.StatusForbidden},
+		{name: "token", auth: actorAuth{actorType: enum.ActorTypeToken.String()}, code: http.StatusForbidden},
+		{name: "anonymous", auth: actorAuth{actorType: enum.ActorTypePublic.String()}, code: http.StatusUnauthorized},
+		{name: "no auth", auth: nil, code: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			assert.Equal(t, tc.ok, RequireAdmin(rec, httptest.NewRequest(http.MethodGet, "/audit/stats", http.NoBody), tc.auth))
+			assert.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Package environ maps environments like dev, staging and prod to stored keys. An environment is selected
+// with the env query parameter of the kv API, and its keys are stored with the "@<env>/" prefix, so key app/db
+// of prod is stored as @prod/app/db, and permission prefixes like "@prod/*" scope access to an environment.
+// Keys without an environment form the default environment. An environment inherits keys it doesn't set from
+// its base environment, from the default environment if no base is set.
+package environ
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Param is the query parameter selecting the environment.
+const Param = "env"
+
+// marker starts stored keys of environments.
+const marker = "@"
+
+// nameRe matches valid environment names.
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Set is the configured environments. A nil Set has no environments.
+type Set struct {
+	bases map[string]string // environment -> base environment, empty for the default one
+}
+
+type ctxKey struct{}
+
+// New parses environment specs, name or name:base, e.g. "dev", "prod:staging". Returns nil for no specs.
+func New(specs []string) (*Set, error) {
+	if len(specs) == 0 {
+		return nil, nil //nolint:nilnil // no environments configured
+	}
+	s := &Set{bases: map[string]string{}}
+	for _, spec := range specs {
+		name, base, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		if !nameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment name %q", name)
+		}
+		if _, dup := s.bases[name]; dup {
+			return nil, fmt.Errorf("duplicate environment %q", name)
+		}
+		s.bases[name] = base
+	}
+	for name, base := range s.bases {
+		if base == "" {
+			continue
+		}
+		if _, ok := s.bases[base]; !ok {
+			return nil, fmt.Errorf("base %q of environment %q is not an environment", base, name)
+		}
+		if len(s.Chain(name)) > len(s.bases)+1 {
+			return nil, fmt.Errorf("environment %q inherits from itself", name)
+		}
+	}
+	return s, nil
+}
+
+// Has reports whether the environment is configured.
+func (s *Set) Has(name string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.bases[name]
+	return ok
+}
+
+// Chain returns the environment followed by its bases, ending with the default environment "".
+// Stops after more steps than environments, which only happens with a cycle.
+func (s *Set) Chain(name string) []string {
+	res := []string{name}
+	for name != "" && len(res) <= len(s.bases)+1 {
+		name = s.bases[name]
+		res = append(res, name)
+	}
+	return res
+}
+
+// Key returns the stored key of the key in the environment, the key itself for the default one.
+func Key(env, key string) string {
+	if env == "" {
+		return key
+	}
+	return marker + env + "/" + key
+}
+
+// Split returns the environment and key of a stored key, empty environment for keys of the default one.
+func Split(stored string) (env, key string) {
+	if rest, ok := strings.CutPrefix(stored, marker); ok {
+		if env, key, ok := strings.Cut(rest, "/"); ok {
+			return env, key
+		}
+	}
+	return "", stored
+}
+
+// IsEnvKey reports whether the stored key belongs to an environment.
+func IsEnvKey(stored string) bool {
+	env, _ := Split(stored)
+	return env != ""
+}
+
+// FromContext returns the environment of the request, empty for the default one.
+func FromContext(ctx context.Context) string {
+	env, _ := ctx.Value(ctxKey{}).(string)
+	return env
+}
+
+// Middleware maps API requests with the env parameter to the stored keys of the environment, rewriting the
+// request path and the key path value, so auth, audit and handlers see the stored key. The environment is
+// added to the request context for lists and inherited reads. Subscriptions don't support environments.
+func (s *Set) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := r.URL.Query().Get(Param)
+		if env == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s == nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "environments are not configured")
+			return
+		}
+		if !s.Has(env) {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, errors.New("unknown environment"),
+				"unknown environment "+env)
+			return
+		}
+		path, ok := strings.CutPrefix(r.URL.Path, "/kv/")
+		if !ok || strings.HasPrefix(path, "subscribe/") {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "env parameter is not supported here")
+			return
+		}
+
+		r = r.Clone(context.WithValue(r.Context(), ctxKey{}, env))
+		if key := store.NormalizeKey(r.PathValue("key")); key != "" {
+			stored := Key(env, key)
+			r.SetPathValue("key", stored)
+			section := ""
+			if strings.HasPrefix(path, "history/") {
+				section = "history/"
+			}
+			r.URL.Path, r.URL.RawPath = "/kv/"+section+stored, ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,114 @@
+package environ
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-pkgz/routegroup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	s, err := New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.False(t, s.Has("prod"))
+
+	s, err = New([]string{"dev", "staging", " prod:staging"})
+	require.NoError(t, err)
+	assert.True(t, s.Has("prod"))
+	assert.False(t, s.Has("qa"))
+	assert.Equal(t, []string{"prod", "staging", ""}, s.Chain("prod"))
+	assert.Equal(t, []string{"dev", ""}, s.Chain("dev"))
+
+	tbl := []struct {
+		specs []string
+		err   string
+	}{
+		{specs: []string{"prod/eu"}, err: `invalid environment name "prod/eu"`},
+		{specs: []string{"@prod"}, err: `invalid environment name "@prod"`},
+		{specs: []string{""}, err: `invalid environment name ""`},
+		{specs: []string{"dev", "dev"}, err: `duplicate environment "dev"`},
+		{specs: []string{"prod:staging"}, err: `base "staging" of environment "prod" is not an environment`},
+		{specs: []string{"a:b", "b:a"}, err: "inherits from itself"},
+		{specs: []string{"a:a"}, err: "inherits from itself"},
+	}
+	for _, tt := range tbl {
+		_, err := New(tt.specs)
+		require.ErrorContains(t, err, tt.err, tt.specs)
+	}
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "@prod/app/db", Key("prod", "app/db"))
+	assert.Equal(t, "app/db", Key("", "app/db"))
+	assert.Equal(t, "@prod/", Key("prod", ""))
+
+	env, key := Split("@prod/app/db")
+	assert.Equal(t, "prod", env)
+	assert.Equal(t, "app/db", key)
+	env, key = Split("app/db")
+	assert.Empty(t, env)
+	assert.Equal(t, "app/db", key)
+	env, key = Split("@mention")
+	assert.Empty(t, env, "no environment without a key")
+	assert.Equal(t, "@mention", key)
+	assert.True(t, IsEnvKey("@dev/x"))
+	assert.False(t, IsEnvKey("dev/x"))
+}
+
+func TestSet_Middleware(t *testing.T) {
+	s, err := New([]string{"dev", "prod"})
+	require.NoError(t, err)
+
+	type seen struct{ path, key, env string }
+	var got seen
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got = seen{path: r.URL.Path, key: r.PathValue("key"), env: FromContext(r.Context())}
+	}
+	router := routegroup.New(http.NewServeMux())
+	router.Mount("/kv").Route(func(kv *routegroup.Bundle) {
+		kv.Use(s.Middleware)
+		kv.HandleFunc("GET /{$}", handler)
+		kv.HandleFunc("GET /history/{key...}", handler)
+		kv.HandleFunc("GET /subscribe/{key...}", handler)
+		kv.HandleFunc("GET /{key...}", handler)
+		kv.HandleFunc("PUT /{key...}", handler)
+	})
+
+	tbl := []struct {
+		method, url string
+		status      int
+		want        seen
+	}{
+		{method: http.MethodGet, url: "/kv/app/db", status: http.StatusOK, want: seen{path: "/kv/app/db", key: "app/db"}},
+		{method: http.MethodGet, url: "/kv/app/db?env=prod", status: http.StatusOK,
+			want: seen{path: "/kv/@prod/app/db", key: "@prod/app/db", env: "prod"}},
+		{method: http.MethodPut, url: "/kv/app/db/?env=dev", status: http.StatusOK,
+			want: seen{path: "/kv/@dev/app/db", key: "@dev/app/db", env: "dev"}},
+		{method: http.MethodGet, url: "/kv/history/app/db?env=prod", status: http.StatusOK,
+			want: seen{path: "/kv/history/@prod/app/db", key: "@prod/app/db", env: "prod"}},
+		{method: http.MethodGet, url: "/kv/?env=prod&prefix=app/", status: http.StatusOK, want: seen{path: "/kv/", env: "prod"}},
+		{method: http.MethodGet, url: "/kv/app/db?env=qa", status: http.StatusBadRequest},
+		{method: http.MethodGet, url: "/kv/subscribe/app/*?env=prod", status: http.StatusBadRequest},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			got = seen{}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, http.NoBody))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("no environments", func(t *testing.T) {
+		var none *Set
+		rec := httptest.NewRecorder()
+		none.Middleware(http.HandlerFunc(handler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/a?env=prod", http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "environments are not configured")
+	})
+}
@@ -0,0 +1,76 @@
+// Package expiry deletes keys past their TTL in the background. Deleted keys get the same git history
+// and change events as keys deleted through the API, with the default git author. The check and delete
+// is a single statement, so with instances sharing a database each expired key is reported by one of them.
+package expiry
+
+import (
+	"context"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+)
+
+// Store defines the interface for deleting expired keys.
+type Store interface {
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+}
+
+// History defines the interface for recording deleted keys in git.
+type History interface {
+	Delete(key string, author git.Author) error
+}
+
+// Publisher defines the interface for publishing key change events.
+type Publisher interface {
+	Publish(key string, action enum.AuditAction)
+}
+
+// Reaper deletes expired keys periodically.
+type Reaper struct {
+	store    Store
+	history  History   // optional, nil without git
+	events   Publisher // optional
+	interval time.Duration
+}
+
+// New creates a reaper deleting expired keys every interval.
+func New(st Store, history History, events Publisher, interval time.Duration) *Reaper {
+	return &Reaper{store: st, history: history, events: events, interval: interval}
+}
+
+// Run deletes expired keys every interval until the context is canceled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reap(ctx)
+		}
+	}
+}
+
+// reap deletes the keys expired by now and reports their deletion.
+func (r *Reaper) reap(ctx context.Context) {
+	keys, err := r.store.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		log.Printf("[WARN] failed to delete expired keys: %v", err)
+		return
+	}
+	for _, key := range keys {
+		log.Printf("[INFO] delete %q, ttl expired", key)
+		if r.history != nil {
+			if err := r.history.Delete(key, git.DefaultAuthor()); err != nil {
+				log.Printf("[WARN] git delete failed for %s: %v", key, err)
+			}
+		}
+		if r.events != nil {
+			r.events.Publish(key, enum.AuditActionDelete)
+		}
+	}
+}
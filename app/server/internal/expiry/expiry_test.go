@@ -0,0 +1,90 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/store"
+)
+
+// recorder records git deletes and published events.
+type recorder struct {
+	mu      sync.Mutex
+	deleted []string
+	events  []string
+}
+
+func (r *recorder) Delete(key string, author git.Author) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, key+" by "+author.Name)
+	return nil
+}
+
+func (r *recorder) Publish(key string, action enum.AuditAction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, action.String()+" "+key)
+}
+
+func (r *recorder) snapshot() (deleted, events []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.deleted...), append([]string{}, r.events...)
+}
+
+// failingStore fails to delete expired keys.
+type failingStore struct{}
+
+func (failingStore) DeleteExpired(context.Context, time.Time) ([]string, error) {
+	return nil, errors.New("db is down")
+}
+
+func TestReaper_Run(t *testing.T) {
+	st, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+	_, err = st.Set(t.Context(), "flags/permanent", []byte("on"), "text")
+	require.NoError(t, err)
+	_, err = st.SetWithOptions(t.Context(), "flags/override", []byte("on"), "text",
+		store.SetOptions{ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	require.NoError(t, err)
+
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		New(st, rec, rec, 10*time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		_, events := rec.snapshot()
+		return len(events) > 0
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	deleted, events := rec.snapshot()
+	assert.Equal(t, []string{"flags/override by stash"}, deleted)
+	assert.Equal(t, []string{"delete flags/override"}, events)
+	_, err = st.Get(t.Context(), "flags/permanent")
+	require.NoError(t, err)
+	require.ErrorIs(t, st.Delete(t.Context(), "flags/override"), store.ErrNotFound)
+}
+
+func TestReaper_StoreError(t *testing.T) {
+	rec := &recorder{}
+	New(failingStore{}, nil, rec, time.Minute).reap(t.Context())
+	_, events := rec.snapshot()
+	assert.Empty(t, events, "nothing published when the store fails")
+}
@@ -0,0 +1,192 @@
+// Package fault injects latency, 500 errors and dropped connections into requests, for testing how clients
+// handle a failing server, e.g. their retries and caching in staging. It must never be enabled in production.
+//
+// Rules are set per route as path prefix, the longest prefix matching the request path applies:
+//
+//	/kv/:latency=200ms,latency-rate=0.5,error-rate=0.1
+//	/kv/subscribe/:drop-rate=1,drop-after=30s
+//
+// Injected faults are marked with the X-Stash-Fault response header, dropped connections have no response.
+package fault
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+)
+
+// Header marks responses with an injected fault.
+const Header = "X-Stash-Fault"
+
+// defaultDropAfter is the max time an event stream is kept before it's dropped.
+const defaultDropAfter = 10 * time.Second
+
+// Rule is the faults injected into requests of a route.
+type Rule struct {
+	Route       string        // path prefix, "/" for all requests
+	Latency     time.Duration // delay added to requests
+	LatencyRate float64       // share of requests delayed, 1 if latency is set without it
+	ErrorRate   float64       // share of requests answered with 500
+	DropRate    float64       // share of connections dropped, without a response or, for event streams, mid-stream
+	DropAfter   time.Duration // max time an event stream is kept before it's dropped, the time is random up to it
+}
+
+// Injector injects the faults of its rules. A nil Injector injects nothing.
+type Injector struct {
+	rules  []Rule                                 // sorted by route length, longest first
+	chance func() float64                         // random number in [0, 1)
+	sleep  func(r *http.Request, d time.Duration) // waits unless the request is canceled
+}
+
+// New parses rule specs, route:param=value,..., e.g. "/kv/:error-rate=0.1,latency=200ms". Returns nil for no specs.
+func New(specs []string) (*Injector, error) {
+	if len(specs) == 0 {
+		return nil, nil //nolint:nilnil // no faults configured
+	}
+	res := &Injector{chance: rand.Float64, sleep: sleep} //nolint:gosec // fault sampling needs no crypto randomness
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		rule, err := parseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[rule.Route] {
+			return nil, fmt.Errorf("duplicate fault route %q", rule.Route)
+		}
+		seen[rule.Route] = true
+		res.rules = append(res.rules, rule)
+	}
+	sort.Slice(res.rules, func(i, j int) bool { return len(res.rules[i].Route) > len(res.rules[j].Route) })
+	return res, nil
+}
+
+// parseRule parses a single rule spec.
+func parseRule(spec string) (Rule, error) {
+	route, params, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok || !strings.HasPrefix(route, "/") || params == "" {
+		return Rule{}, fmt.Errorf("invalid fault rule %q, expected /route:param=value,...", spec)
+	}
+	rule := Rule{Route: route, LatencyRate: -1, DropAfter: defaultDropAfter}
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		var err error
+		switch name {
+		case "latency":
+			rule.Latency, err = time.ParseDuration(value)
+		case "drop-after":
+			rule.DropAfter, err = time.ParseDuration(value)
+		case "latency-rate":
+			rule.LatencyRate, err = parseRate(value)
+		case "error-rate":
+			rule.ErrorRate, err = parseRate(value)
+		case "drop-rate":
+			rule.DropRate, err = parseRate(value)
+		default:
+			return Rule{}, fmt.Errorf("unknown fault parameter %q of route %s", name, route)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid %s of fault route %s: %w", name, route, err)
+		}
+	}
+	if rule.Latency < 0 || rule.DropAfter <= 0 {
+		return Rule{}, fmt.Errorf("fault durations of route %s must be positive", route)
+	}
+	if rule.LatencyRate < 0 {
+		rule.LatencyRate = 0
+		if rule.Latency > 0 {
+			rule.LatencyRate = 1
+		}
+	}
+	return rule, nil
+}
+
+// parseRate parses a share of requests between 0 and 1.
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse rate: %w", err)
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate %v is not between 0 and 1", rate)
+	}
+	return rate, nil
+}
+
+// Rules returns the rules, longest route first.
+func (in *Injector) Rules() []Rule {
+	if in == nil {
+		return nil
+	}
+	return in.rules
+}
+
+// Middleware injects the faults of the rule matching the request: the delay first, then either an error or
+// a dropped connection. The middleware must come before the recoverer, which would turn a drop into a 500.
+func (in *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := in.match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rule.Latency > 0 && in.chance() < rule.LatencyRate {
+			w.Header().Add(Header, "latency")
+			in.sleep(r, rule.Latency)
+		}
+		if in.chance() < rule.ErrorRate {
+			w.Header().Add(Header, "error")
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, nil, "injected fault")
+			return
+		}
+		if in.chance() >= rule.DropRate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !isEventStream(r) {
+			// aborts the handler without a response, the server closes the connection
+			panic(http.ErrAbortHandler)
+		}
+		// the stream handler ends with the canceled context, the client sees the connection closed mid-stream
+		w.Header().Add(Header, "drop")
+		after := time.Duration(in.chance() * float64(rule.DropAfter))
+		ctx, cancel := context.WithTimeout(r.Context(), after)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// match returns the rule with the longest route matching the path.
+func (in *Injector) match(path string) (Rule, bool) {
+	if in == nil {
+		return Rule{}, false
+	}
+	for _, rule := range in.rules {
+		if strings.HasPrefix(path, rule.Route) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// isEventStream reports whether the request subscribes to server-sent events.
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") || strings.Contains(r.URL.Path, "/subscribe/")
+}
+
+// sleep waits for the duration or until the request is canceled.
+func sleep(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,119 @@
+package fault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	in, err := New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, in)
+
+	in, err = New([]string{"/:latency=100ms", "/kv/subscribe/:drop-rate=1,drop-after=30s",
+		"/kv/:error-rate=0.1,latency=200ms,latency-rate=0.5"})
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Route: "/kv/subscribe/", DropRate: 1, DropAfter: 30 * time.Second},
+		{Route: "/kv/", Latency: 200 * time.Millisecond, LatencyRate: 0.5, ErrorRate: 0.1, DropAfter: defaultDropAfter},
+		{Route: "/", Latency: 100 * time.Millisecond, LatencyRate: 1, DropAfter: defaultDropAfter},
+	}, in.Rules())
+
+	tests := []struct {
+		spec, err string
+	}{
+		{spec: "kv:error-rate=0.1", err: "invalid fault rule"},
+		{spec: "/kv/", err: "invalid fault rule"},
+		{spec: "/kv/:error-rate=2", err: "not between 0 and 1"},
+		{spec: "/kv/:latency=fast", err: "invalid latency"},
+		{spec: "/kv/:drop-after=0s", err: "must be positive"},
+		{spec: "/kv/:timeout=1s", err: "unknown fault parameter"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			_, err := New([]string{tc.spec})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	_, err = New([]string{"/kv/:error-rate=1", "/kv/:latency=1s"})
+	require.ErrorContains(t, err, "duplicate fault route")
+}
+
+func TestInjector_Middleware(t *testing.T) {
+	// newInjector makes an injector with fixed random numbers, so rates of 0.5 and above always apply
+	newInjector := func(t *testing.T, specs ...string) (in *Injector, slept *time.Duration) {
+		t.Helper()
+		in, err := New(specs)
+		require.NoError(t, err)
+		slept = new(time.Duration)
+		in.chance = func() float64 { return 0.4 }
+		in.sleep = func(_ *http.Request, d time.Duration) { *slept += d }
+		return in, slept
+	}
+	var deadline time.Time
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+		w.WriteHeader(http.StatusOK)
+	})
+	serve := func(in *Injector, path string) *httptest.ResponseRecorder {
+		deadline = time.Time{}
+		rec := httptest.NewRecorder()
+		in.Middleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+
+	t.Run("latency", func(t *testing.T) {
+		in, slept := newInjector(t, "/kv/:latency=200ms,latency-rate=0.5")
+		rec := serve(in, "/kv/app/db")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 200*time.Millisecond, *slept)
+		assert.Equal(t, "latency", rec.Header().Get(Header))
+
+		in, slept = newInjector(t, "/kv/:latency=200ms,latency-rate=0.3")
+		rec = serve(in, "/kv/app/db")
+		assert.Zero(t, *slept)
+		assert.Empty(t, rec.Header().Get(Header))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in, _ := newInjector(t, "/kv/:error-rate=0.5")
+		rec := serve(in, "/kv/app/db")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "error", rec.Header().Get(Header))
+		assert.JSONEq(t, `{"error":"injected fault"}`, rec.Body.String())
+	})
+
+	t.Run("other routes are not affected", func(t *testing.T) {
+		in, _ := newInjector(t, "/kv/:error-rate=1")
+		assert.Equal(t, http.StatusOK, serve(in, "/audit/query").Code)
+		assert.Equal(t, http.StatusOK, serve(nil, "/kv/app/db").Code, "nil injector passes requests through")
+	})
+
+	t.Run("drop request", func(t *testing.T) {
+		in, _ := newInjector(t, "/kv/:drop-rate=0.5")
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { serve(in, "/kv/app/db") })
+	})
+
+	t.Run("drop event stream", func(t *testing.T) {
+		in, _ := newInjector(t, "/kv/:drop-rate=0.5,drop-after=10s")
+		rec := serve(in, "/kv/subscribe/app/*")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "drop", rec.Header().Get(Header))
+		require.False(t, deadline.IsZero(), "stream is cut by the request context")
+		assert.WithinDuration(t, time.Now().Add(4*time.Second), deadline, time.Second)
+	})
+
+	t.Run("longest route applies", func(t *testing.T) {
+		in, slept := newInjector(t, "/:error-rate=1", "/kv/:latency=1s")
+		assert.Equal(t, http.StatusOK, serve(in, "/kv/app").Code)
+		assert.Equal(t, time.Second, *slept)
+		assert.Equal(t, http.StatusInternalServerError, serve(in, "/web/keys").Code)
+	})
+}
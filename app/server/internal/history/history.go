@@ -0,0 +1,60 @@
+// Package history decides who can see previous revisions of keys, shared by the API and web UI.
+// Revisions of sensitive keys may hold credentials rotated since, so the history of matched keys is
+// shown to admins only, even to users who can read the current value.
+package history
+
+import (
+	"strings"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Policy matches keys with history hidden from non-admins.
+type Policy struct {
+	keys     map[string]bool
+	prefixes []string
+	secrets  bool
+}
+
+// New creates a policy from key patterns: an exact key or a prefix ending with "*", e.g. "prod/db/password"
+// or "prod/*", "*" hides the history of all keys. With secrets, the history of all secret keys is hidden too.
+// Returns nil if nothing is hidden.
+func New(patterns []string, secrets bool) *Policy {
+	p := &Policy{keys: map[string]bool{}, secrets: secrets}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(strings.TrimSpace(pattern), "*"); ok {
+			norm := store.NormalizeKey(prefix)
+			if norm != "" && strings.HasSuffix(prefix, "/") {
+				norm += "/" // keep "prod/*" from matching "production"
+			}
+			p.prefixes = append(p.prefixes, norm)
+			continue
+		}
+		if key := store.NormalizeKey(pattern); key != "" {
+			p.keys[key] = true
+		}
+	}
+	if len(p.keys) == 0 && len(p.prefixes) == 0 && !secrets {
+		return nil
+	}
+	return p
+}
+
+// Visible reports whether the history of the key can be seen by the caller. Safe to call on nil.
+func (p *Policy) Visible(key string, admin bool) bool {
+	return p == nil || admin || !p.hidden(key)
+}
+
+// hidden reports whether the history of the key is hidden from non-admins.
+func (p *Policy) hidden(key string) bool {
+	key = store.NormalizeKey(key)
+	if p.keys[key] || (p.secrets && store.IsSecret(key)) {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Visible(t *testing.T) {
+	p := New([]string{"prod/*", " billing/master-key ", "app*"}, true)
+	tests := []struct {
+		key     string
+		visible bool
+	}{
+		{key: "prod/db/password", visible: false},
+		{key: "/prod/db/", visible: false},
+		{key: "production/db", visible: true},
+		{key: "billing/master-key", visible: false},
+		{key: "billing/other", visible: true},
+		{key: "application/config", visible: false},
+		{key: "svc/secrets/token", visible: false},
+		{key: "@dev/svc/secrets/token", visible: false},
+		{key: "svc/config", visible: true},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.visible, p.Visible(tc.key, false), tc.key)
+		assert.True(t, p.Visible(tc.key, true), "admins see all history, %s", tc.key)
+	}
+
+	all := New([]string{"*"}, false)
+	assert.False(t, all.Visible("any/key", false))
+	assert.True(t, all.Visible("any/key", true))
+}
+
+func TestNew_Empty(t *testing.T) {
+	assert.Nil(t, New(nil, false))
+	assert.Nil(t, New([]string{" ", "/"}, false))
+	var p *Policy
+	assert.True(t, p.Visible("secrets/key", false))
+	assert.NotNil(t, New(nil, true))
+}
@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// auditHeader lists CSV columns of audit reports in order.
+var auditHeader = []string{"timestamp", "action", "key", "actor", "actor_type", "result", "ip", "user_agent",
+	"value_size", "request_id", "reason"}
+
+// AuditWriter writes audit entries as CSV with a header row. Entries are written in batches, e.g. pages
+// of an audit query, and the header is written before the first batch.
+type AuditWriter struct {
+	cw     *csv.Writer
+	header bool
+}
+
+// NewAuditWriter creates an AuditWriter writing to w.
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{cw: csv.NewWriter(w)}
+}
+
+// Write writes a batch of entries. Cells written by callers, like keys, actors, user agents and reasons,
+// are guarded against formula injection.
+func (a *AuditWriter) Write(entries []store.AuditEntry) error {
+	if !a.header {
+		if err := a.cw.Write(auditHeader); err != nil {
+			return fmt.Errorf("write csv header: %w", err)
+		}
+		a.header = true
+	}
+	for _, e := range entries {
+		size := ""
+		if e.ValueSize != nil {
+			size = strconv.Itoa(*e.ValueSize)
+		}
+		row := []string{
+			e.Timestamp.UTC().Format(time.RFC3339),
+			e.Action.String(),
+			safeCell(e.Key),
+			safeCell(e.Actor),
+			e.ActorType.String(),
+			e.Result.String(),
+			safeCell(e.IP),
+			safeCell(e.UserAgent),
+			size,
+			safeCell(e.RequestID),
+			safeCell(e.Reason),
+		}
+		if err := a.cw.Write(row); err != nil {
+			return fmt.Errorf("write csv row: %w", err)
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered rows, and the header if no batch was written.
+func (a *AuditWriter) Flush() error {
+	if !a.header {
+		if err := a.Write(nil); err != nil {
+			return err
+		}
+	}
+	a.cw.Flush()
+	if err := a.cw.Error(); err != nil {
+		return fmt.Errorf("flush csv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestAuditWriter(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*3600))
+	size := 42
+	var buf bytes.Buffer
+	aw := NewAuditWriter(&buf)
+	require.NoError(t, aw.Write([]store.AuditEntry{{Timestamp: ts, Action: enum.AuditActionUpdate, Key: "app/db",
+		Actor: "alice", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess, IP: "10.0.0.1",
+		UserAgent: "curl/8.0", ValueSize: &size, RequestID: "req-1", Reason: "INC-1, rotate"}}))
+	require.NoError(t, aw.Write([]store.AuditEntry{{Timestamp: ts, Action: enum.AuditActionRead, Key: "=cmd",
+		Actor: "token:abcd****", ActorType: enum.ActorTypeToken, Result: enum.AuditResultDenied}}))
+	require.NoError(t, aw.Flush())
+	assert.Equal(t, "timestamp,action,key,actor,actor_type,result,ip,user_agent,value_size,request_id,reason\n"+
+		"2025-01-02T08:04:05Z,update,app/db,alice,user,success,10.0.0.1,curl/8.0,42,req-1,\"INC-1, rotate\"\n"+
+		"2025-01-02T08:04:05Z,read,'=cmd,token:abcd****,token,denied,,,,,\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewAuditWriter(&buf).Flush())
+	assert.Equal(t, "timestamp,action,key,actor,actor_type,result,ip,user_agent,value_size,request_id,reason\n", buf.String(),
+		"header only")
+}
@@ -0,0 +1,62 @@
+// Package inventory renders CSV reports of key metadata, shared by the API and web UI, and of audit
+// entries. Reports are built from key listings and audit entries only, so they never contain values,
+// decrypted or otherwise.
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// ContentType is the content type of CSV reports.
+const ContentType = "text/csv; charset=utf-8"
+
+// header lists CSV columns in order.
+var header = []string{"key", "secret", "zk_encrypted", "format", "size", "created_at", "updated_at"}
+
+// WriteCSV writes keys metadata as CSV with a header row. Size of secrets is the stored,
+// encrypted size, not the size of the plaintext value.
+func WriteCSV(w io.Writer, keys []store.KeyInfo) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("write csv header: %w", err)
+	}
+	for _, k := range keys {
+		row := []string{
+			safeCell(k.Key),
+			strconv.FormatBool(k.Secret),
+			strconv.FormatBool(k.ZKEncrypted),
+			safeCell(k.Format),
+			strconv.Itoa(k.Size),
+			k.CreatedAt.UTC().Format(time.RFC3339),
+			k.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write csv row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("flush csv: %w", err)
+	}
+	return nil
+}
+
+// safeCell prevents spreadsheet formula injection by quoting values starting with a formula character.
+func safeCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// Filename returns the attachment name for a report, e.g. "stash-secrets-20250102.csv".
+func Filename(kind string, now time.Time) string {
+	return "stash-" + kind + "-" + now.UTC().Format("20060102") + ".csv"
+}
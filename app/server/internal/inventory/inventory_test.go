@@ -0,0 +1,44 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+func TestWriteCSV(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*3600))
+	keys := []store.KeyInfo{
+		{Key: "app/config", Size: 12, Format: "yaml", CreatedAt: ts, UpdatedAt: ts},
+		{Key: "secrets/db", Size: 64, Format: "text", Secret: true, ZKEncrypted: true, CreatedAt: ts, UpdatedAt: ts},
+		{Key: "@import", Size: 1, Format: "text", CreatedAt: ts, UpdatedAt: ts},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, keys))
+	assert.Equal(t, "key,secret,zk_encrypted,format,size,created_at,updated_at\n"+
+		"app/config,false,false,yaml,12,2025-01-02T08:04:05Z,2025-01-02T08:04:05Z\n"+
+		"secrets/db,true,true,text,64,2025-01-02T08:04:05Z,2025-01-02T08:04:05Z\n"+
+		"'@import,false,false,text,1,2025-01-02T08:04:05Z,2025-01-02T08:04:05Z\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteCSV(&buf, nil))
+	assert.Equal(t, "key,secret,zk_encrypted,format,size,created_at,updated_at\n", buf.String(), "header only")
+}
+
+func TestSafeCell(t *testing.T) {
+	tbl := []struct{ in, out string }{
+		{"", ""}, {"app/x", "app/x"}, {"=1+2", "'=1+2"}, {"+x", "'+x"}, {"-x", "'-x"}, {"@x", "'@x"}, {"a=b", "a=b"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.out, safeCell(tt.in), tt.in)
+	}
+}
+
+func TestFilename(t *testing.T) {
+	assert.Equal(t, "stash-secrets-20250102.csv", Filename("secrets", time.Date(2025, 1, 2, 23, 0, 0, 0, time.UTC)))
+}
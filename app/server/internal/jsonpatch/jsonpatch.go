@@ -0,0 +1,399 @@
+// Package jsonpatch applies RFC 7386 JSON merge patches and RFC 6902 JSON patches to JSON documents.
+// Numbers are kept as written, object members of the result are sorted by name. An indented document
+// stays indented with its indentation, a compact one is written compact.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch is returned for a patch which isn't a valid patch document, e.g. an unknown operation.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ErrTestFailed is returned when a test operation of a JSON patch doesn't match the document.
+var ErrTestFailed = errors.New("test failed")
+
+// operation is an RFC 6902 patch operation. Value is raw to tell a missing value from null.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Merge applies an RFC 7386 merge patch to the document: members of patch objects replace members of
+// the document, nulls remove them, and any other patch replaces the document.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return encode(mergeValue(target, p), doc)
+}
+
+// Apply applies an RFC 6902 JSON patch, an array of operations, to the document. The operations are applied
+// in order and the patch fails as a whole if one of them fails, ErrTestFailed for a test operation.
+func Apply(doc, patch []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	for i, op := range ops {
+		if root, err = applyOp(root, op); err != nil {
+			if op.Path != nil {
+				return nil, fmt.Errorf("operation %d, %s %q: %w", i, op.Op, *op.Path, err)
+			}
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return encode(root, doc)
+}
+
+// mergeValue merges the patch into the target as defined by RFC 7386.
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeValue(t[k], v)
+	}
+	return t
+}
+
+// applyOp applies the operation to the root and returns the new root.
+func applyOp(root any, op operation) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: %s without path", ErrInvalidPatch, op.Op)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: no value", ErrInvalidPatch)
+		}
+		if value, err = decode(op.Value); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: no from", ErrInvalidPatch)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return add(root, path, value)
+	case "remove":
+		res, _, err := remove(root, path)
+		return res, err
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		res, _, err := remove(root, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(res, path, value)
+	case "move":
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && isPrefix(from, path) {
+			return nil, fmt.Errorf("can't move %q into its child", *op.From)
+		}
+		res, moved, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(res, path, moved)
+	case "copy":
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, deepCopy(v))
+	case "test":
+		v, err := get(root, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrTestFailed, err)
+		}
+		if !equal(v, value) {
+			return nil, fmt.Errorf("%w: value doesn't match", ErrTestFailed)
+		}
+		return root, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped reference tokens, empty for the whole document.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("%w: pointer %q doesn't start with /", ErrInvalidPatch, ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get returns the value at the path.
+func get(node any, path []string) (any, error) {
+	for _, token := range path {
+		child, err := member(node, token)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// add sets the value at the path, inserting it into arrays, and returns the new root.
+func add(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			idx, err := index(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:idx], append([]any{value}, c[idx:]...)...), nil
+		default:
+			return nil, errors.New("parent is not an object or array")
+		}
+	})
+}
+
+// remove deletes the value at the path and returns the new root and the removed value.
+func remove(root any, path []string) (res, removed any, err error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can't remove the whole document")
+	}
+	res, err = update(root, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			v, ok := c[token]
+			if !ok {
+				return nil, errors.New("member not found")
+			}
+			removed = v
+			delete(c, token)
+			return c, nil
+		case []any:
+			idx, err := index(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[idx]
+			return append(c[:idx], c[idx+1:]...), nil
+		default:
+			return nil, errors.New("parent is not an object or array")
+		}
+	})
+	return res, removed, err
+}
+
+// update walks to the parent of the path and replaces it with the result of fn, called with the last token.
+// Arrays change their length, so each level is set back into its parent.
+func update(node any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	child, err := member(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	newChild, err := update(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch c := node.(type) {
+	case map[string]any:
+		c[path[0]] = newChild
+	case []any:
+		idx, _ := index(path[0], len(c)-1) // checked by member
+		c[idx] = newChild
+	}
+	return node, nil
+}
+
+// member returns the member of an object or the element of an array referenced by the token.
+func member(node any, token string) (any, error) {
+	switch c := node.(type) {
+	case map[string]any:
+		v, ok := c[token]
+		if !ok {
+			return nil, errors.New("member not found")
+		}
+		return v, nil
+	case []any:
+		idx, err := index(token, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		return c[idx], nil
+	default:
+		return nil, errors.New("not an object or array")
+	}
+}
+
+// index parses an array index token, which can't exceed maxIdx.
+func index(token string, maxIdx int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if idx > maxIdx {
+		return 0, fmt.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+// isPrefix reports whether path starts with prefix.
+func isPrefix(prefix, path []string) bool {
+	for i, t := range prefix {
+		if path[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+// deepCopy copies objects and arrays, so a copied value isn't changed with its source.
+func deepCopy(v any) any {
+	switch c := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(c))
+		for k, v := range c {
+			res[k] = deepCopy(v)
+		}
+		return res
+	case []any:
+		res := make([]any, len(c))
+		for i, v := range c {
+			res[i] = deepCopy(v)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// equal compares JSON values, numbers by their value, so 1 and 1.0 are equal.
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okX := new(big.Float).SetString(av.String())
+		y, okY := new(big.Float).SetString(bv.String())
+		return okX && okY && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
+
+// decode parses a single JSON value, keeping numbers as json.Number.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// encode writes the value formatted like the original document, indented with the indentation of
+// its second line if it has several lines.
+func encode(v any, like []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if _, rest, multiline := bytes.Cut(bytes.TrimSpace(like), []byte("\n")); multiline {
+		indent := "  "
+		if ws := rest[:len(rest)-len(bytes.TrimLeft(rest, " \t"))]; len(ws) > 0 {
+			indent = string(ws)
+		}
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode patched document: %w", err)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
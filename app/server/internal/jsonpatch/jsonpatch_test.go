@@ -0,0 +1,111 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	tbl := []struct{ name, doc, patch, want string }{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"nested", `{"db":{"host":"h","port":5432}}`, `{"db":{"port":6432,"user":"app"}}`,
+			`{"db":{"host":"h","port":6432,"user":"app"}}`},
+		{"array replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"non-object patch", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"object into scalar", `{"a":"b"}`, `{"a":{"c":1}}`, `{"a":{"c":1}}`},
+		{"numbers kept", `{"big":12345678901234567890,"f":1.50}`, `{"x":1e3}`, `{"big":12345678901234567890,"f":1.50,"x":1e3}`},
+		{"no html escape", `{"a":"<b>"}`, `{"c":"&"}`, `{"a":"<b>","c":"&"}`},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Merge([]byte(tt.doc), []byte(tt.patch))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(res))
+		})
+	}
+
+	t.Run("indentation kept", func(t *testing.T) {
+		res, err := Merge([]byte("{\n    \"a\": 1\n}\n"), []byte(`{"b":[true]}`))
+		require.NoError(t, err)
+		assert.Equal(t, "{\n    \"a\": 1,\n    \"b\": [\n        true\n    ]\n}", string(res))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Merge([]byte(`{"a":1}`), []byte(`{`))
+		require.ErrorIs(t, err, ErrInvalidPatch)
+		_, err = Merge([]byte(`not json`), []byte(`{}`))
+		require.ErrorContains(t, err, "invalid document")
+		require.NotErrorIs(t, err, ErrInvalidPatch)
+		_, err = Merge([]byte(`{"a":1} {}`), []byte(`{}`))
+		require.ErrorContains(t, err, "unexpected data")
+	})
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"foo":"bar","list":[1,2,3],"obj":{"a/b":1,"m~n":2}}`
+	tbl := []struct{ name, patch, want string }{
+		{"add member", `[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"baz":"qux","foo":"bar","list":[1,2,3],"obj":{"a/b":1,"m~n":2}}`},
+		{"add to array", `[{"op":"add","path":"/list/1","value":9}]`, `{"foo":"bar","list":[1,9,2,3],"obj":{"a/b":1,"m~n":2}}`},
+		{"append to array", `[{"op":"add","path":"/list/-","value":4}]`, `{"foo":"bar","list":[1,2,3,4],"obj":{"a/b":1,"m~n":2}}`},
+		{"remove escaped", `[{"op":"remove","path":"/obj/a~1b"},{"op":"remove","path":"/obj/m~0n"}]`,
+			`{"foo":"bar","list":[1,2,3],"obj":{}}`},
+		{"remove from array", `[{"op":"remove","path":"/list/0"}]`, `{"foo":"bar","list":[2,3],"obj":{"a/b":1,"m~n":2}}`},
+		{"replace", `[{"op":"replace","path":"/foo","value":null}]`, `{"foo":null,"list":[1,2,3],"obj":{"a/b":1,"m~n":2}}`},
+		{"replace in array", `[{"op":"replace","path":"/list/2","value":0}]`, `{"foo":"bar","list":[1,2,0],"obj":{"a/b":1,"m~n":2}}`},
+		{"replace root", `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{"move", `[{"op":"move","from":"/foo","path":"/obj/foo"}]`, `{"list":[1,2,3],"obj":{"a/b":1,"foo":"bar","m~n":2}}`},
+		{"move in array", `[{"op":"move","from":"/list/0","path":"/list/-"}]`, `{"foo":"bar","list":[2,3,1],"obj":{"a/b":1,"m~n":2}}`},
+		{"copy", `[{"op":"copy","from":"/list","path":"/copy"},{"op":"add","path":"/copy/-","value":4}]`,
+			`{"copy":[1,2,3,4],"foo":"bar","list":[1,2,3],"obj":{"a/b":1,"m~n":2}}`},
+		{"test passes", `[{"op":"test","path":"/list","value":[1,2,3.0]},{"op":"remove","path":"/obj"}]`,
+			`{"foo":"bar","list":[1,2,3]}`},
+		{"empty patch", `[]`, doc},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Apply([]byte(doc), []byte(tt.patch))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(res))
+		})
+	}
+
+	errs := []struct {
+		name, patch, err string
+		target           error
+	}{
+		{name: "not an array", patch: `{"op":"add"}`, target: ErrInvalidPatch},
+		{name: "unknown op", patch: `[{"op":"inc","path":"/a"}]`, target: ErrInvalidPatch},
+		{name: "no path", patch: `[{"op":"remove"}]`, target: ErrInvalidPatch},
+		{name: "no value", patch: `[{"op":"add","path":"/a"}]`, target: ErrInvalidPatch},
+		{name: "no from", patch: `[{"op":"copy","path":"/a"}]`, target: ErrInvalidPatch},
+		{name: "bad pointer", patch: `[{"op":"remove","path":"foo"}]`, target: ErrInvalidPatch},
+		{name: "test fails", patch: `[{"op":"test","path":"/foo","value":"baz"}]`, target: ErrTestFailed},
+		{name: "test of missing", patch: `[{"op":"test","path":"/nope","value":1}]`, target: ErrTestFailed},
+		{name: "remove missing", patch: `[{"op":"remove","path":"/nope"}]`, err: `operation 0, remove "/nope": member not found`},
+		{name: "replace missing", patch: `[{"op":"replace","path":"/nope","value":1}]`, err: "member not found"},
+		{name: "add without parent", patch: `[{"op":"add","path":"/a/b","value":1}]`, err: "member not found"},
+		{name: "index out of range", patch: `[{"op":"add","path":"/list/4","value":1}]`, err: "out of range"},
+		{name: "leading zero", patch: `[{"op":"remove","path":"/list/01"}]`, err: "invalid array index"},
+		{name: "into scalar", patch: `[{"op":"add","path":"/foo/x","value":1}]`, err: "not an object or array"},
+		{name: "move into child", patch: `[{"op":"move","from":"/obj","path":"/obj/x"}]`, err: "into its child"},
+		{name: "remove root", patch: `[{"op":"remove","path":""}]`, err: "whole document"},
+		{name: "second op fails", patch: `[{"op":"add","path":"/x","value":1},{"op":"remove","path":"/y"}]`, err: "operation 1"},
+	}
+	for _, tt := range errs {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply([]byte(doc), []byte(tt.patch))
+			require.Error(t, err)
+			if tt.target != nil {
+				require.ErrorIs(t, err, tt.target)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+			assert.NotErrorIs(t, err, ErrInvalidPatch)
+		})
+	}
+}
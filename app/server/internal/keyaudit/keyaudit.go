@@ -0,0 +1,51 @@
+// Package keyaudit passes per-key audit records of bulk requests, exports, imports and transactions,
+// from the handlers to the audit middleware. A bulk request touches many keys, so it is audited by the
+// keys and actions the handler reports rather than by its route and method.
+package keyaudit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// Record is an action on a key made by a bulk request.
+type Record struct {
+	Key    string
+	Action enum.AuditAction
+	Result enum.AuditResult // zero value is success
+	Size   *int             // size of the value read or written, nil for deletes and denied actions
+}
+
+// recorder collects the records of a request.
+type recorder struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+type ctxKey struct{}
+
+// WithRecorder returns a context collecting the records added with Add, and a function returning them.
+func WithRecorder(ctx context.Context) (context.Context, func() []Record) {
+	rec := &recorder{}
+	return context.WithValue(ctx, ctxKey{}, rec), func() []Record {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.records
+	}
+}
+
+// Add records an action on a key. It does nothing if the context has no recorder, e.g. with audit disabled.
+func Add(ctx context.Context, r Record) {
+	rec, ok := ctx.Value(ctxKey{}).(*recorder)
+	if !ok {
+		return
+	}
+	if r.Result == (enum.AuditResult{}) {
+		r.Result = enum.AuditResultSuccess
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.records = append(rec.records, r)
+}
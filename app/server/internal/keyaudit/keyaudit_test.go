@@ -0,0 +1,27 @@
+package keyaudit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestRecorder(t *testing.T) {
+	Add(t.Context(), Record{Key: "app/db", Action: enum.AuditActionRead}) // no recorder, ignored
+
+	ctx, records := WithRecorder(t.Context())
+	assert.Empty(t, records())
+	size := 3
+	Add(ctx, Record{Key: "app/db", Action: enum.AuditActionUpdate, Size: &size})
+	Add(ctx, Record{Key: "app/old", Action: enum.AuditActionDelete, Result: enum.AuditResultDenied})
+
+	res := records()
+	require.Len(t, res, 2)
+	assert.Equal(t, enum.AuditResultSuccess, res[0].Result, "success by default")
+	assert.Equal(t, 3, *res[0].Size)
+	assert.Equal(t, enum.AuditResultDenied, res[1].Result)
+	assert.Nil(t, res[1].Size)
+}
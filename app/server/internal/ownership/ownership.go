@@ -0,0 +1,54 @@
+// Package ownership picks owners recorded for created keys and enforces the optional owner-only
+// delete policy, shared by the API and web UI. Owners are identities like "user:alice" or "token:ab12****",
+// keys created anonymously or before owners were recorded have no owner and follow regular ACLs only.
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Store defines the interface for reading key owners.
+type Store interface {
+	GetInfo(ctx context.Context, key string) (store.KeyInfo, error)
+}
+
+// Policy decides who can delete owned keys.
+type Policy struct {
+	store       Store
+	ownerDelete bool
+}
+
+// New creates a policy. With ownerDelete only owners and admins can delete owned keys,
+// regardless of prefix write access.
+func New(st Store, ownerDelete bool) *Policy {
+	return &Policy{store: st, ownerDelete: ownerDelete}
+}
+
+// Owner returns the owner to record for a key created by the identity, empty for anonymous creators.
+// Handlers pass it in store.SetOptions, so the owner is written with the key.
+func Owner(identity string) string {
+	if identity == "anonymous" {
+		return ""
+	}
+	return identity
+}
+
+// CanDelete reports whether the identity can delete the key. Always true if the policy is off,
+// for admins and for keys without an owner. Missing keys are allowed, so callers report them as not found.
+func (p *Policy) CanDelete(ctx context.Context, key, identity string, admin bool) (bool, error) {
+	if !p.ownerDelete || admin {
+		return true, nil
+	}
+	info, err := p.store.GetInfo(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get owner of key %q: %w", key, err)
+	}
+	return info.Owner == "" || info.Owner == identity, nil
+}
@@ -0,0 +1,70 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// ownerStore keeps owners in a map, keys without an entry don't exist.
+type ownerStore struct {
+	owners map[string]string
+	err    error
+}
+
+func (s *ownerStore) GetInfo(_ context.Context, key string) (store.KeyInfo, error) {
+	if s.err != nil {
+		return store.KeyInfo{}, s.err
+	}
+	owner, ok := s.owners[key]
+	if !ok {
+		return store.KeyInfo{}, store.ErrNotFound
+	}
+	return store.KeyInfo{Key: key, Owner: owner}, nil
+}
+
+func TestOwner(t *testing.T) {
+	assert.Equal(t, "user:alice", Owner("user:alice"))
+	assert.Equal(t, "token:ab12****", Owner("token:ab12****"))
+	assert.Empty(t, Owner("anonymous"))
+	assert.Empty(t, Owner(""))
+}
+
+func TestPolicy_CanDelete(t *testing.T) {
+	st := &ownerStore{owners: map[string]string{"app/owned": "user:alice", "app/legacy": ""}}
+
+	tests := []struct {
+		name        string
+		ownerDelete bool
+		key         string
+		identity    string
+		admin       bool
+		want        bool
+	}{
+		{"policy off", false, "app/owned", "user:bob", false, true},
+		{"owner", true, "app/owned", "user:alice", false, true},
+		{"not owner", true, "app/owned", "user:bob", false, false},
+		{"token not owner", true, "app/owned", "token:ab12****", false, false},
+		{"admin", true, "app/owned", "user:bob", true, true},
+		{"no owner", true, "app/legacy", "user:bob", false, true},
+		{"missing key", true, "app/missing", "user:bob", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(st, tt.ownerDelete).CanDelete(t.Context(), tt.key, tt.identity, tt.admin)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("store error", func(t *testing.T) {
+		_, err := New(&ownerStore{err: errors.New("db down")}, true).CanDelete(t.Context(), "app/owned", "user:bob", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "db down")
+	})
+}
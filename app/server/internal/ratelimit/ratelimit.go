@@ -0,0 +1,84 @@
+// Package ratelimit limits requests of each client credential, an API token or a web session, with a token
+// bucket. Limits per IP don't stop a client spreading requests over many addresses, or many clients behind
+// one NAT from starving each other, so the store is protected per credential as well. Rejected requests get
+// 429 with Retry-After.
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/didip/tollbooth/v8"
+	"github.com/didip/tollbooth/v8/limiter"
+)
+
+// Config defines the rate of a Limiter.
+type Config struct {
+	RequestsPerSec float64                      // sustained rate of a credential
+	Burst          int                          // requests a credential can make at once, RequestsPerSec if zero
+	Credential     func(r *http.Request) string // credential of the request, empty for anonymous requests
+}
+
+// Limiter keeps a token bucket for each credential, buckets of idle credentials expire after an hour.
+type Limiter struct {
+	lmt        *limiter.Limiter
+	credential func(r *http.Request) string
+}
+
+// New makes a Limiter.
+func New(cfg Config) (*Limiter, error) {
+	if cfg.RequestsPerSec <= 0 {
+		return nil, fmt.Errorf("requests per second must be positive, got %g", cfg.RequestsPerSec)
+	}
+	if cfg.Credential == nil {
+		return nil, fmt.Errorf("credential func is required")
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = max(1, int(math.Ceil(cfg.RequestsPerSec)))
+	}
+	lmt := tollbooth.NewLimiter(cfg.RequestsPerSec, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour})
+	lmt.SetBurst(cfg.Burst)
+	return &Limiter{lmt: lmt, credential: cfg.Credential}, nil
+}
+
+// Middleware rejects requests of credentials over their rate with 429 and Retry-After. Anonymous requests
+// pass, they are limited per IP only. A nil Limiter passes all requests.
+// X-RateLimit-* headers report the credential's bucket if it has fewer requests left than the one of the IP.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred := l.credential(r)
+		if cred == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// credentials are kept hashed, the buckets live longer than sessions and exchanged tokens
+		sum := sha256.Sum256([]byte(cred))
+		key := hex.EncodeToString(sum[:])
+		limited := l.lmt.LimitReached(key)
+		remaining := l.lmt.Tokens(key)
+		if ipRemaining, err := strconv.Atoi(w.Header().Get("X-RateLimit-Remaining")); err != nil || remaining < ipRemaining {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(math.Round(l.lmt.GetMax()))))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(l.retryAfter()))
+		}
+		if limited {
+			w.Header().Set("Retry-After", strconv.Itoa(l.retryAfter()))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// retryAfter returns seconds until the bucket has a token again, at least one.
+func (l *Limiter) retryAfter() int {
+	return max(1, int(math.Ceil(1/l.lmt.GetMax())))
+}
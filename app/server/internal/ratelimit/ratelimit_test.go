@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	token := func(r *http.Request) string { return r.Header.Get("X-Auth-Token") }
+
+	l, err := New(Config{RequestsPerSec: 2.5, Credential: token})
+	require.NoError(t, err)
+	assert.Equal(t, 3, l.lmt.GetBurst(), "burst defaults to the rate")
+	assert.Equal(t, 1, l.retryAfter())
+
+	l, err = New(Config{RequestsPerSec: 0.1, Burst: 5, Credential: token})
+	require.NoError(t, err)
+	assert.Equal(t, 5, l.lmt.GetBurst())
+	assert.Equal(t, 10, l.retryAfter(), "slow rates ask to wait for the next token")
+
+	_, err = New(Config{Credential: token})
+	require.ErrorContains(t, err, "requests per second must be positive")
+	_, err = New(Config{RequestsPerSec: 1})
+	require.ErrorContains(t, err, "credential func is required")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	l, err := New(Config{RequestsPerSec: 1, Burst: 2, Credential: func(r *http.Request) string { return r.Header.Get("X-Auth-Token") }})
+	require.NoError(t, err)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+	call := func(token, ipRemaining string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/app/db", http.NoBody)
+		if token != "" {
+			req.Header.Set("X-Auth-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		if ipRemaining != "" {
+			rec.Header().Set("X-RateLimit-Remaining", ipRemaining) // set by the per-IP limiter before
+		}
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("token-a", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = call("token-a", "0")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"), "ip limit is closer, its headers are kept")
+
+	rec = call("token-a", "50")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "burst of the token used up")
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, call("token-b", "").Code, "other tokens have their own buckets")
+	for range 5 {
+		assert.Equal(t, http.StatusOK, call("", "").Code, "anonymous requests are limited per ip only")
+	}
+
+	var disabled *Limiter
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	assert.NotNil(t, disabled.Middleware(next))
+}
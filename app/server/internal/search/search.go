@@ -0,0 +1,208 @@
+// Package search parses key list queries shared by the web UI search box and the list API.
+// A query combines free text with qualifiers, e.g. `db prefix:app/ format:json label:env=prod updated:<7d size:>10kb`.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Help is a one-line summary of the query syntax, for hints and error messages.
+const Help = "free text, prefix:app/, format:json, label:env=prod, updated:<7d or updated:>2025-01-31, size:>10kb"
+
+const dateLayout = "2006-01-02"
+
+// Parse parses a query into list conditions. Words without a known qualifier are free text, matched
+// together as a case-insensitive substring of the key, so keys with colons still work as plain text.
+// Double quotes keep spaces in a word or value. Relative times are counted back from now.
+func Parse(query string, now time.Time) (store.ListQuery, error) {
+	var q store.ListQuery
+	words, err := split(query)
+	if err != nil {
+		return store.ListQuery{}, err
+	}
+	var text []string
+	for _, w := range words {
+		name, value, ok := strings.Cut(w, ":")
+		if !ok || w[0] == '"' {
+			text = append(text, strings.Trim(w, `"`))
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(name) {
+		case "prefix":
+			if value == "" {
+				return store.ListQuery{}, errors.New("empty prefix")
+			}
+			q.Prefix = value
+		case "format":
+			if value == "" {
+				return store.ListQuery{}, errors.New("empty format")
+			}
+			q.Format = strings.ToLower(value)
+		case "label":
+			if err := parseLabel(&q, value); err != nil {
+				return store.ListQuery{}, err
+			}
+		case "updated":
+			if err := parseUpdated(&q, value, now); err != nil {
+				return store.ListQuery{}, err
+			}
+		case "size":
+			if err := parseSize(&q, value); err != nil {
+				return store.ListQuery{}, err
+			}
+		default:
+			text = append(text, strings.Trim(w, `"`))
+		}
+	}
+	q.Search = strings.Join(text, " ")
+	return q, nil
+}
+
+// split splits the query into words by spaces outside of double quotes, quotes are kept.
+func split(query string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseLabel adds a name=value label condition, all labels of the query must match.
+func parseLabel(q *store.ListQuery, value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" || val == "" {
+		return fmt.Errorf("invalid label %q, expected name=value", value)
+	}
+	if q.Labels == nil {
+		q.Labels = map[string]string{}
+	}
+	q.Labels[name] = val
+	return nil
+}
+
+// parseUpdated sets the updated range. An age like 7d means within the last 7 days, with "<" (same)
+// or ">" (older than). A date is a whole UTC day, with "<" for before it or ">" for the day and after.
+func parseUpdated(q *store.ListQuery, value string, now time.Time) error {
+	op, arg := cutOp(value)
+	if op == "<=" || op == ">=" {
+		return fmt.Errorf("invalid updated %q, use < or >", value)
+	}
+	if day, err := time.Parse(dateLayout, arg); err == nil {
+		switch op {
+		case "<":
+			q.UpdatedBefore = day
+		case ">":
+			q.UpdatedAfter = day
+		default:
+			q.UpdatedAfter, q.UpdatedBefore = day, day.AddDate(0, 0, 1)
+		}
+		return nil
+	}
+	age, err := parseAge(arg)
+	if err != nil {
+		return fmt.Errorf("invalid updated %q, expected age like 7d or date like %s", value, dateLayout)
+	}
+	if op == ">" {
+		q.UpdatedBefore = now.Add(-age)
+		return nil
+	}
+	q.UpdatedAfter = now.Add(-age)
+	return nil
+}
+
+// parseAge parses an age like 30m, 12h, 7d or 2w.
+func parseAge(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if s == "" {
+		return 0, errors.New("empty age")
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseSize sets the size range from a comparison like >10kb, <=1mb or >=512.
+func parseSize(q *store.ListQuery, value string) error {
+	op, arg := cutOp(value)
+	size, err := parseBytes(arg)
+	if op == "" || err != nil {
+		return fmt.Errorf("invalid size %q, expected like >10kb or <=1mb", value)
+	}
+	switch op {
+	case ">":
+		q.MinSize = size + 1
+	case ">=":
+		q.MinSize = size
+	case "<":
+		if size == 0 {
+			return fmt.Errorf("invalid size %q, nothing is smaller than 0", value)
+		}
+		q.SizeBelow = size
+	case "<=":
+		q.SizeBelow = size + 1
+	}
+	return nil
+}
+
+// parseBytes parses a size with optional b, kb, mb or gb unit, 1kb is 1024 bytes.
+func parseBytes(s string) (int, error) {
+	s = strings.ToLower(s)
+	mult := 1
+	for _, u := range []struct {
+		suffix string
+		mult   int
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"b", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSuffix(s, u.suffix), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int(n * float64(mult)), nil
+}
+
+// cutOp splits a leading comparison operator from the value.
+func cutOp(value string) (op, arg string) {
+	for _, op := range []string{"<=", ">=", "<", ">"} {
+		if rest, ok := strings.CutPrefix(value, op); ok {
+			return op, rest
+		}
+	}
+	return "", value
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2025, 2, 10, 12, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    store.ListQuery
+		wantErr string
+	}{
+		{name: "empty", query: "  ", want: store.ListQuery{}},
+		{name: "free text", query: "db  host", want: store.ListQuery{Search: "db host"}},
+		{name: "all qualifiers", query: "db prefix:app/ format:JSON updated:<7d size:>10kb",
+			want: store.ListQuery{Search: "db", Prefix: "app/", Format: "json", UpdatedAfter: now.Add(-7 * 24 * time.Hour),
+				MinSize: 10*1024 + 1}},
+		{name: "qualifier name ignores case", query: "Prefix:App/", want: store.ListQuery{Prefix: "App/"}},
+		{name: "unknown qualifier is text", query: "redis:6379", want: store.ListQuery{Search: "redis:6379"}},
+		{name: "quoted text", query: `"prefix:app" "two words"`, want: store.ListQuery{Search: "prefix:app two words"}},
+		{name: "quoted value", query: `prefix:"my app/"`, want: store.ListQuery{Prefix: "my app/"}},
+		{name: "older than", query: "updated:>2w", want: store.ListQuery{UpdatedBefore: now.Add(-14 * 24 * time.Hour)}},
+		{name: "age without op", query: "updated:30m", want: store.ListQuery{UpdatedAfter: now.Add(-30 * time.Minute)}},
+		{name: "after date", query: "updated:>2025-01-31", want: store.ListQuery{UpdatedAfter: day}},
+		{name: "before date", query: "updated:<2025-01-31", want: store.ListQuery{UpdatedBefore: day}},
+		{name: "on date", query: "updated:2025-01-31", want: store.ListQuery{UpdatedAfter: day, UpdatedBefore: day.AddDate(0, 0, 1)}},
+		{name: "size range", query: "size:>=1mb size:<=2MB", want: store.ListQuery{MinSize: 1 << 20, SizeBelow: 2<<20 + 1}},
+		{name: "size in bytes", query: "size:<512", want: store.ListQuery{SizeBelow: 512}},
+		{name: "fractional size", query: "size:>1.5kb", want: store.ListQuery{MinSize: 1537}},
+		{name: "labels", query: `label:env=prod label:"team=core infra"`,
+			want: store.ListQuery{Labels: map[string]string{"env": "prod", "team": "core infra"}}},
+		{name: "label without value", query: "label:env", wantErr: "invalid label"},
+		{name: "unterminated quote", query: `prefix:"app`, wantErr: "unterminated quote"},
+		{name: "empty prefix", query: "prefix:", wantErr: "empty prefix"},
+		{name: "empty format", query: "format:", wantErr: "empty format"},
+		{name: "bad age", query: "updated:<7y", wantErr: "invalid updated"},
+		{name: "inclusive updated", query: "updated:<=7d", wantErr: "use < or >"},
+		{name: "size without op", query: "size:10kb", wantErr: "invalid size"},
+		{name: "bad size", query: "size:>big", wantErr: "invalid size"},
+		{name: "smaller than zero", query: "size:<0", wantErr: "nothing is smaller than 0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := Parse(tc.query, now)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package shed limits concurrent in-flight requests by priority. Under overload, low priority requests
+// like key lists and exports are rejected first, then API requests, while the web UI keeps the rest of the
+// capacity, so the dashboard stays usable during API stampedes. Rejected requests get 503 with Retry-After.
+package shed
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Priority of a request, higher priorities are shed later.
+type Priority int
+
+// request priorities
+const (
+	Low    Priority = iota // bulk reads, e.g. key lists and exports
+	Normal                 // interactive API requests
+	High                   // web UI, login and health checks
+)
+
+// retry-after of shed requests, bulk clients are asked to back off longer
+const (
+	retryLow    = 5 * time.Second
+	retryNormal = time.Second
+)
+
+// Config defines the capacity of a Limiter.
+type Config struct {
+	MaxConcurrent int64                        // max in-flight requests of all priorities
+	LowShare      float64                      // share of MaxConcurrent low priority requests can take, (0, 1]
+	NormalShare   float64                      // share of MaxConcurrent normal priority requests can take, (0, 1]
+	Classify      func(*http.Request) Priority // priority of a request
+}
+
+// Limiter admits requests while in-flight requests are below the limit of their priority.
+// Limits count requests of all priorities, so lower priorities run out first.
+type Limiter struct {
+	limits   [3]int64 // max in-flight requests by priority
+	inflight atomic.Int64
+	classify func(*http.Request) Priority
+}
+
+// New makes a Limiter, each priority can take at least one in-flight request.
+func New(cfg Config) (*Limiter, error) {
+	if cfg.MaxConcurrent <= 0 {
+		return nil, fmt.Errorf("max concurrent must be positive, got %d", cfg.MaxConcurrent)
+	}
+	if cfg.LowShare <= 0 || cfg.LowShare > 1 {
+		return nil, fmt.Errorf("low priority share must be in (0, 1], got %g", cfg.LowShare)
+	}
+	if cfg.NormalShare <= 0 || cfg.NormalShare > 1 {
+		return nil, fmt.Errorf("normal priority share must be in (0, 1], got %g", cfg.NormalShare)
+	}
+	if cfg.LowShare > cfg.NormalShare {
+		return nil, fmt.Errorf("low priority share %g is above normal priority share %g", cfg.LowShare, cfg.NormalShare)
+	}
+	if cfg.Classify == nil {
+		return nil, fmt.Errorf("classify func is required")
+	}
+	share := func(v float64) int64 { return max(1, int64(math.Floor(float64(cfg.MaxConcurrent)*v))) }
+	return &Limiter{
+		limits:   [3]int64{Low: share(cfg.LowShare), Normal: share(cfg.NormalShare), High: cfg.MaxConcurrent},
+		classify: cfg.Classify,
+	}, nil
+}
+
+// Middleware rejects requests over the limit of their priority with 503 and Retry-After.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := l.classify(r)
+		if !l.acquire(p) {
+			retry := retryNormal
+			if p == Low {
+				retry = retryLow
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer l.inflight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes an in-flight slot if the limit of the priority allows it.
+func (l *Limiter) acquire(p Priority) bool {
+	limit := l.limits[High]
+	if p >= Low && p < High {
+		limit = l.limits[p]
+	}
+	if l.inflight.Add(1) > limit {
+		l.inflight.Add(-1)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package shed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	normal := func(*http.Request) Priority { return Normal }
+
+	l, err := New(Config{MaxConcurrent: 10, LowShare: 0.5, NormalShare: 0.8, Classify: normal})
+	require.NoError(t, err)
+	assert.Equal(t, [3]int64{5, 8, 10}, l.limits)
+
+	l, err = New(Config{MaxConcurrent: 1, LowShare: 0.1, NormalShare: 0.5, Classify: normal})
+	require.NoError(t, err)
+	assert.Equal(t, [3]int64{1, 1, 1}, l.limits, "each priority gets at least one slot")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{name: "no max", cfg: Config{LowShare: 0.5, NormalShare: 0.8, Classify: normal}, err: "max concurrent must be positive"},
+		{name: "low share", cfg: Config{MaxConcurrent: 10, LowShare: 0, NormalShare: 0.8, Classify: normal},
+			err: "low priority share must be in (0, 1]"},
+		{name: "normal share", cfg: Config{MaxConcurrent: 10, LowShare: 0.5, NormalShare: 1.5, Classify: normal},
+			err: "normal priority share must be in (0, 1]"},
+		{name: "low above normal", cfg: Config{MaxConcurrent: 10, LowShare: 0.9, NormalShare: 0.8, Classify: normal},
+			err: "low priority share 0.9 is above normal priority share 0.8"},
+		{name: "no classify", cfg: Config{MaxConcurrent: 10, LowShare: 0.5, NormalShare: 0.8}, err: "classify func is required"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.cfg)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	priorities := map[string]Priority{"/low": Low, "/normal": Normal, "/high": High}
+	l, err := New(Config{MaxConcurrent: 4, LowShare: 0.5, NormalShare: 0.75,
+		Classify: func(r *http.Request) Priority { return priorities[r.URL.Path] }})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hold") != "" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+
+	// two held requests fill the low priority share
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() { assert.Equal(t, http.StatusOK, call("/low?hold=1").Code) })
+		<-started
+	}
+
+	rec := call("/low")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "low priority is shed first")
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, call("/normal").Code)
+	assert.Equal(t, http.StatusOK, call("/high").Code)
+
+	// a third held request fills the normal priority share
+	wg.Go(func() { assert.Equal(t, http.StatusOK, call("/normal?hold=1").Code) })
+	<-started
+	rec = call("/normal")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "normal priority is shed next")
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, call("/high").Code, "high priority keeps the rest")
+
+	// the last slot is taken by the web UI, nothing else is admitted
+	wg.Go(func() { assert.Equal(t, http.StatusOK, call("/high?hold=1").Code) })
+	<-started
+	assert.Equal(t, http.StatusServiceUnavailable, call("/high").Code)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(0), l.inflight.Load())
+	assert.Equal(t, http.StatusOK, call("/low").Code, "slots are released")
+}
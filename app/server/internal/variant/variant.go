@@ -0,0 +1,196 @@
+// Package variant serves A/B variants of key values for simple experiments. A key can have a variants spec,
+// a JSON document with alternative values and targeting rules. Reading the key evaluates the rules against
+// the caller and returns the first variant it is targeted by, or the stored value, the control, if none.
+// Overrides are variants targeting a single caller identity or instance, like a canary instance getting
+// a config change before the rest of the fleet.
+package variant
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// request headers with the targeting context and the response header naming the served variant
+const (
+	SubjectHeader    = "X-Stash-Subject"    // stable id of the caller, like a user, host or deployment id
+	AttributesHeader = "X-Stash-Attributes" // caller attributes, url-encoded like region=eu&plan=pro
+	InstanceHeader   = "X-Stash-Instance"   // instance id declared by the caller, the instance attribute
+	VariantHeader    = "X-Stash-Variant"
+)
+
+// attributes set by the server rather than the caller
+const (
+	AttrCaller   = "caller"   // authenticated identity as in the audit log, like user:alice or token:ab12****
+	AttrInstance = "instance" // from InstanceHeader
+)
+
+// overridePrefix starts names of override variants, followed by the target.
+const overridePrefix = "override:"
+
+// headerMatch prefixes match conditions checking a request header rather than a caller attribute.
+const headerMatch = "header:"
+
+// Variant is an alternative value of a key with its targeting rules.
+type Variant struct {
+	Name    string            `json:"name"`
+	Value   string            `json:"value"`
+	Format  string            `json:"format,omitempty"`  // format of the value, the key's format if empty
+	Percent int               `json:"percent,omitempty"` // share of subjects served the variant, 0 serves all matching callers
+	Match   map[string]string `json:"match,omitempty"`   // required attribute values, "header:<name>" for request headers
+}
+
+// Spec is the variants of a key, evaluated in order.
+type Spec struct {
+	Variants []Variant `json:"variants"`
+}
+
+// Caller is the targeting context of a request.
+type Caller struct {
+	Subject string
+	Attrs   map[string]string
+	Header  http.Header
+}
+
+// Parse parses and validates a variants spec. Percentages of all variants add up to 100 at most, as each
+// variant with a percentage gets its own range of subject buckets.
+func Parse(data []byte) (Spec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return Spec{}, fmt.Errorf("invalid variants spec: %w", err)
+	}
+	if len(spec.Variants) == 0 {
+		return Spec{}, errors.New("no variants")
+	}
+	names := map[string]bool{}
+	total := 0
+	for _, v := range spec.Variants {
+		if v.Name == "" {
+			return Spec{}, errors.New("variant without a name")
+		}
+		if names[v.Name] {
+			return Spec{}, fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		names[v.Name] = true
+		if v.Percent < 0 || v.Percent > 100 {
+			return Spec{}, fmt.Errorf("percent of variant %q is not within 0-100", v.Name)
+		}
+		total += v.Percent
+		for k := range v.Match {
+			if name, ok := strings.CutPrefix(k, headerMatch); k == "" || (ok && name == "") {
+				return Spec{}, fmt.Errorf("empty match name in variant %q", v.Name)
+			}
+		}
+	}
+	if total > 100 {
+		return Spec{}, fmt.Errorf("percentages add up to %d, over 100", total)
+	}
+	return spec, nil
+}
+
+// Pick returns the first variant of the key the caller is targeted by. A caller is targeted if it matches
+// all conditions of the variant and, for a variant with a percentage, its subject falls into the variant's
+// range of buckets. Buckets depend on the key and subject only, so a subject gets the same variant on every
+// read, and callers without a subject never get variants with a percentage.
+func (s Spec) Pick(key string, c Caller) (Variant, bool) {
+	bucket := -1
+	if c.Subject != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key + "\x00" + c.Subject))
+		bucket = int(h.Sum32() % 100)
+	}
+	start := 0
+	for _, v := range s.Variants {
+		lo := start
+		start += v.Percent
+		if !c.matches(v.Match) {
+			continue
+		}
+		if v.Percent > 0 && (bucket < lo || bucket >= lo+v.Percent) {
+			continue
+		}
+		return v, true
+	}
+	return Variant{}, false
+}
+
+// matches reports whether the caller has all the attribute and header values.
+func (c Caller) matches(match map[string]string) bool {
+	for k, want := range match {
+		got := c.Attrs[k]
+		if name, ok := strings.CutPrefix(k, headerMatch); ok {
+			got = c.Header.Get(name)
+		}
+		if got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// FromRequest returns the targeting context of the request, from headers or the subject and attr.<name>
+// query parameters. Query parameters take precedence. The caller attribute is the authenticated identity,
+// empty for anonymous requests, and can't be set by the caller.
+func FromRequest(r *http.Request, identity string) Caller {
+	c := Caller{Subject: r.Header.Get(SubjectHeader), Attrs: map[string]string{}, Header: r.Header}
+	if attrs, err := url.ParseQuery(r.Header.Get(AttributesHeader)); err == nil {
+		for k := range attrs {
+			c.Attrs[k] = attrs.Get(k)
+		}
+	}
+	query := r.URL.Query()
+	if subject := query.Get("subject"); subject != "" {
+		c.Subject = subject
+	}
+	for k := range query {
+		if name, ok := strings.CutPrefix(k, "attr."); ok && name != "" {
+			c.Attrs[name] = query.Get(k)
+		}
+	}
+	if instance := r.Header.Get(InstanceHeader); instance != "" {
+		c.Attrs[AttrInstance] = instance
+	}
+	delete(c.Attrs, AttrCaller)
+	if identity != "" {
+		c.Attrs[AttrCaller] = identity
+	}
+	return c
+}
+
+// SetOverride sets the value served to a single caller or instance, replacing its previous override.
+// Target is caller:<identity> or instance:<id>. Overrides are kept before other variants in the order
+// they were added, so they win over other variants targeting the same caller.
+func (s *Spec) SetOverride(target, value, format string) error {
+	attr, id, ok := strings.Cut(target, ":")
+	if !ok || id == "" || (attr != AttrCaller && attr != AttrInstance) {
+		return fmt.Errorf("invalid override target %q, expected caller:<identity> or instance:<id>", target)
+	}
+	v := Variant{Name: overridePrefix + target, Value: value, Format: format, Match: map[string]string{attr: id}}
+	pos := 0
+	for i, existing := range s.Variants {
+		if existing.Name == v.Name {
+			s.Variants[i] = v
+			return nil
+		}
+		if strings.HasPrefix(existing.Name, overridePrefix) {
+			pos = i + 1
+		}
+	}
+	s.Variants = slices.Insert(s.Variants, pos, v)
+	return nil
+}
+
+// RemoveOverride removes the override of the target, returns false if there is none.
+func (s *Spec) RemoveOverride(target string) bool {
+	n := len(s.Variants)
+	s.Variants = slices.DeleteFunc(s.Variants, func(v Variant) bool { return v.Name == overridePrefix+target })
+	return len(s.Variants) < n
+}
@@ -0,0 +1,136 @@
+package variant
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(`{"variants":[{"name":"b","value":"new","percent":30},
+		{"name":"eu","value":"eu","format":"json","match":{"region":"eu","header:X-Beta":"1"}}]}`))
+	require.NoError(t, err)
+	require.Len(t, spec.Variants, 2)
+	assert.Equal(t, 30, spec.Variants[0].Percent)
+	assert.Equal(t, map[string]string{"region": "eu", "header:X-Beta": "1"}, spec.Variants[1].Match)
+
+	tbl := []struct{ name, spec, err string }{
+		{"invalid json", `{`, "invalid variants spec"},
+		{"unknown field", `{"variants":[{"name":"a","weight":5}]}`, "unknown field"},
+		{"no variants", `{"variants":[]}`, "no variants"},
+		{"no name", `{"variants":[{"value":"x"}]}`, "without a name"},
+		{"duplicate", `{"variants":[{"name":"a"},{"name":"a"}]}`, "duplicate variant"},
+		{"negative percent", `{"variants":[{"name":"a","percent":-1}]}`, "not within 0-100"},
+		{"over 100", `{"variants":[{"name":"a","percent":60},{"name":"b","percent":50}]}`, "add up to 110"},
+		{"empty header", `{"variants":[{"name":"a","match":{"header:":"x"}}]}`, "empty match name"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.spec))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestSpec_Pick(t *testing.T) {
+	t.Run("matches", func(t *testing.T) {
+		spec := Spec{Variants: []Variant{
+			{Name: "eu-beta", Value: "1", Match: map[string]string{"region": "eu", "header:X-Beta": "yes"}},
+			{Name: "eu", Value: "2", Match: map[string]string{"region": "eu"}},
+		}}
+		beta := http.Header{}
+		beta.Set("X-Beta", "yes")
+		tbl := []struct {
+			caller Caller
+			want   string
+		}{
+			{Caller{Attrs: map[string]string{"region": "eu"}, Header: beta}, "eu-beta"},
+			{Caller{Attrs: map[string]string{"region": "eu"}, Header: http.Header{}}, "eu"},
+			{Caller{Attrs: map[string]string{"region": "us"}, Header: beta}, ""},
+			{Caller{}, ""},
+		}
+		for i, tt := range tbl {
+			v, ok := spec.Pick("key", tt.caller)
+			assert.Equal(t, tt.want != "", ok, "case %d", i)
+			assert.Equal(t, tt.want, v.Name, "case %d", i)
+		}
+	})
+
+	t.Run("percentages", func(t *testing.T) {
+		spec := Spec{Variants: []Variant{{Name: "a", Percent: 20}, {Name: "b", Percent: 30}}}
+		counts := map[string]int{}
+		for i := range 10000 {
+			v, ok := spec.Pick("app/banner", Caller{Subject: fmt.Sprintf("user-%d", i)})
+			if !ok {
+				v.Name = "control"
+			}
+			counts[v.Name]++
+		}
+		assert.InDelta(t, 2000, counts["a"], 300)
+		assert.InDelta(t, 3000, counts["b"], 300)
+		assert.InDelta(t, 5000, counts["control"], 300)
+
+		first, _ := spec.Pick("app/banner", Caller{Subject: "user-1"})
+		for range 10 {
+			v, _ := spec.Pick("app/banner", Caller{Subject: "user-1"})
+			assert.Equal(t, first.Name, v.Name, "stable for a subject")
+		}
+
+		_, ok := spec.Pick("app/banner", Caller{})
+		assert.False(t, ok, "no subject, no percentage variants")
+		all := Spec{Variants: []Variant{{Name: "all", Percent: 100}}}
+		_, ok = all.Pick("app/banner", Caller{Subject: "anyone"})
+		assert.True(t, ok)
+	})
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/kv/key?attr.plan=pro&attr.region=us&other=1", http.NoBody)
+	req.Header.Set(SubjectHeader, "host-1")
+	req.Header.Set(AttributesHeader, "region=eu&tier=gold%20plus&caller=user:admin")
+	req.Header.Set(InstanceHeader, "web-3")
+	c := FromRequest(req, "")
+	assert.Equal(t, "host-1", c.Subject)
+	assert.Equal(t, map[string]string{"region": "us", "tier": "gold plus", "plan": "pro", "instance": "web-3"}, c.Attrs,
+		"query wins, caller can't be declared")
+
+	req = httptest.NewRequest(http.MethodGet, "/kv/key?subject=user-7&attr.caller=user:admin", http.NoBody)
+	req.Header.Set(SubjectHeader, "host-1")
+	c = FromRequest(req, "token:ab12****")
+	assert.Equal(t, "user-7", c.Subject)
+	assert.Equal(t, map[string]string{"caller": "token:ab12****"}, c.Attrs)
+}
+
+func TestSpec_Overrides(t *testing.T) {
+	spec := Spec{Variants: []Variant{{Name: "b", Value: "b", Percent: 100}}}
+	require.NoError(t, spec.SetOverride("instance:web-3", "canary", "json"))
+	require.NoError(t, spec.SetOverride("caller:token:ab12****", "ci", ""))
+	require.NoError(t, spec.SetOverride("instance:web-3", "canary2", ""))
+	names := []string{}
+	for _, v := range spec.Variants {
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"override:instance:web-3", "override:caller:token:ab12****", "b"}, names,
+		"overrides first in the order added, replaced in place")
+
+	v, ok := spec.Pick("key", Caller{Subject: "s", Attrs: map[string]string{AttrInstance: "web-3"}})
+	require.True(t, ok)
+	assert.Equal(t, "canary2", v.Value, "override wins over the variant")
+	v, _ = spec.Pick("key", Caller{Subject: "s", Attrs: map[string]string{AttrCaller: "token:ab12****"}})
+	assert.Equal(t, "ci", v.Value)
+	v, _ = spec.Pick("key", Caller{Subject: "s", Attrs: map[string]string{AttrInstance: "web-4"}})
+	assert.Equal(t, "b", v.Value)
+
+	assert.True(t, spec.RemoveOverride("instance:web-3"))
+	assert.False(t, spec.RemoveOverride("instance:web-3"))
+	assert.Len(t, spec.Variants, 2)
+
+	for _, bad := range []string{"web-3", "instance:", "host:web-3"} {
+		assert.Error(t, spec.SetOverride(bad, "x", ""), bad)
+	}
+}
@@ -21,27 +21,51 @@ import (
 //			DeleteFunc: func(ctx context.Context, key string) error {
 //				panic("mock out the Delete method")
 //			},
+//			DeleteExpiredFunc: func(ctx context.Context, now time.Time) ([]string, error) {
+//				panic("mock out the DeleteExpired method")
+//			},
 //			GetFunc: func(ctx context.Context, key string) ([]byte, error) {
 //				panic("mock out the Get method")
 //			},
+//			GetHistoryFunc: func(ctx context.Context, key string, limit int) ([]store.Revision, error) {
+//				panic("mock out the GetHistory method")
+//			},
 //			GetInfoFunc: func(ctx context.Context, key string) (store.KeyInfo, error) {
 //				panic("mock out the GetInfo method")
 //			},
+//			GetVariantsFunc: func(ctx context.Context, key string) (string, error) {
+//				panic("mock out the GetVariants method")
+//			},
 //			GetWithFormatFunc: func(ctx context.Context, key string) ([]byte, string, error) {
 //				panic("mock out the GetWithFormat method")
 //			},
 //			ListFunc: func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
 //				panic("mock out the List method")
 //			},
+//			ListPageFunc: func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+//				panic("mock out the ListPage method")
+//			},
+//			RollbackFunc: func(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error) {
+//				panic("mock out the Rollback method")
+//			},
 //			SecretsEnabledFunc: func() bool {
 //				panic("mock out the SecretsEnabled method")
 //			},
-//			SetFunc: func(ctx context.Context, key string, value []byte, format string) (bool, error) {
-//				panic("mock out the Set method")
+//			SetMetaFunc: func(ctx context.Context, key string, meta store.KeyMeta) error {
+//				panic("mock out the SetMeta method")
+//			},
+//			SetVariantsFunc: func(ctx context.Context, key string, spec string) error {
+//				panic("mock out the SetVariants method")
+//			},
+//			SetWithOptionsFunc: func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+//				panic("mock out the SetWithOptions method")
 //			},
 //			SetWithVersionFunc: func(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error {
 //				panic("mock out the SetWithVersion method")
 //			},
+//			TxnFunc: func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+//				panic("mock out the Txn method")
+//			},
 //		}
 //
 //		// use mockedKVStore in code that requires server.KVStore
@@ -52,27 +76,51 @@ type KVStoreMock struct {
 	// DeleteFunc mocks the Delete method.
 	DeleteFunc func(ctx context.Context, key string) error
 
+	// DeleteExpiredFunc mocks the DeleteExpired method.
+	DeleteExpiredFunc func(ctx context.Context, now time.Time) ([]string, error)
+
 	// GetFunc mocks the Get method.
 	GetFunc func(ctx context.Context, key string) ([]byte, error)
 
+	// GetHistoryFunc mocks the GetHistory method.
+	GetHistoryFunc func(ctx context.Context, key string, limit int) ([]store.Revision, error)
+
 	// GetInfoFunc mocks the GetInfo method.
 	GetInfoFunc func(ctx context.Context, key string) (store.KeyInfo, error)
 
+	// GetVariantsFunc mocks the GetVariants method.
+	GetVariantsFunc func(ctx context.Context, key string) (string, error)
+
 	// GetWithFormatFunc mocks the GetWithFormat method.
 	GetWithFormatFunc func(ctx context.Context, key string) ([]byte, string, error)
 
 	// ListFunc mocks the List method.
 	ListFunc func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
 
+	// ListPageFunc mocks the ListPage method.
+	ListPageFunc func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error)
+
+	// RollbackFunc mocks the Rollback method.
+	RollbackFunc func(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error)
+
 	// SecretsEnabledFunc mocks the SecretsEnabled method.
 	SecretsEnabledFunc func() bool
 
-	// SetFunc mocks the Set method.
-	SetFunc func(ctx context.Context, key string, value []byte, format string) (bool, error)
+	// SetMetaFunc mocks the SetMeta method.
+	SetMetaFunc func(ctx context.Context, key string, meta store.KeyMeta) error
+
+	// SetVariantsFunc mocks the SetVariants method.
+	SetVariantsFunc func(ctx context.Context, key string, spec string) error
+
+	// SetWithOptionsFunc mocks the SetWithOptions method.
+	SetWithOptionsFunc func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error)
 
 	// SetWithVersionFunc mocks the SetWithVersion method.
 	SetWithVersionFunc func(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error
 
+	// TxnFunc mocks the Txn method.
+	TxnFunc func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Delete holds details about calls to the Delete method.
@@ -82,6 +130,13 @@ type KVStoreMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// DeleteExpired holds details about calls to the DeleteExpired method.
+		DeleteExpired []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Now is the now argument value.
+			Now time.Time
+		}
 		// Get holds details about calls to the Get method.
 		Get []struct {
 			// Ctx is the ctx argument value.
@@ -89,6 +144,15 @@ type KVStoreMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// GetHistory holds details about calls to the GetHistory method.
+		GetHistory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Limit is the limit argument value.
+			Limit int
+		}
 		// GetInfo holds details about calls to the GetInfo method.
 		GetInfo []struct {
 			// Ctx is the ctx argument value.
@@ -96,6 +160,13 @@ type KVStoreMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// GetVariants holds details about calls to the GetVariants method.
+		GetVariants []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
 		// GetWithFormat holds details about calls to the GetWithFormat method.
 		GetWithFormat []struct {
 			// Ctx is the ctx argument value.
@@ -110,11 +181,47 @@ type KVStoreMock struct {
 			// Filter is the filter argument value.
 			Filter enum.SecretsFilter
 		}
+		// ListPage holds details about calls to the ListPage method.
+		ListPage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.ListQuery
+		}
+		// Rollback holds details about calls to the Rollback method.
+		Rollback []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Version is the version argument value.
+			Version int64
+			// Owner is the owner argument value.
+			Owner string
+		}
 		// SecretsEnabled holds details about calls to the SecretsEnabled method.
 		SecretsEnabled []struct {
 		}
-		// Set holds details about calls to the Set method.
-		Set []struct {
+		// SetMeta holds details about calls to the SetMeta method.
+		SetMeta []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Meta is the meta argument value.
+			Meta store.KeyMeta
+		}
+		// SetVariants holds details about calls to the SetVariants method.
+		SetVariants []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Spec is the spec argument value.
+			Spec string
+		}
+		// SetWithOptions holds details about calls to the SetWithOptions method.
+		SetWithOptions []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// Key is the key argument value.
@@ -123,6 +230,8 @@ type KVStoreMock struct {
 			Value []byte
 			// Format is the format argument value.
 			Format string
+			// Opts is the opts argument value.
+			Opts store.SetOptions
 		}
 		// SetWithVersion holds details about calls to the SetWithVersion method.
 		SetWithVersion []struct {
@@ -137,15 +246,30 @@ type KVStoreMock struct {
 			// ExpectedVersion is the expectedVersion argument value.
 			ExpectedVersion time.Time
 		}
+		// Txn holds details about calls to the Txn method.
+		Txn []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ops is the ops argument value.
+			Ops []store.TxnOp
+		}
 	}
 	lockDelete         sync.RWMutex
+	lockDeleteExpired  sync.RWMutex
 	lockGet            sync.RWMutex
+	lockGetHistory     sync.RWMutex
 	lockGetInfo        sync.RWMutex
+	lockGetVariants    sync.RWMutex
 	lockGetWithFormat  sync.RWMutex
 	lockList           sync.RWMutex
+	lockListPage       sync.RWMutex
+	lockRollback       sync.RWMutex
 	lockSecretsEnabled sync.RWMutex
-	lockSet            sync.RWMutex
+	lockSetMeta        sync.RWMutex
+	lockSetVariants    sync.RWMutex
+	lockSetWithOptions sync.RWMutex
 	lockSetWithVersion sync.RWMutex
+	lockTxn            sync.RWMutex
 }
 
 // Delete calls DeleteFunc.
@@ -184,6 +308,42 @@ func (mock *KVStoreMock) DeleteCalls() []struct {
 	return calls
 }
 
+// DeleteExpired calls DeleteExpiredFunc.
+func (mock *KVStoreMock) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	if mock.DeleteExpiredFunc == nil {
+		panic("KVStoreMock.DeleteExpiredFunc: method is nil but KVStore.DeleteExpired was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Now time.Time
+	}{
+		Ctx: ctx,
+		Now: now,
+	}
+	mock.lockDeleteExpired.Lock()
+	mock.calls.DeleteExpired = append(mock.calls.DeleteExpired, callInfo)
+	mock.lockDeleteExpired.Unlock()
+	return mock.DeleteExpiredFunc(ctx, now)
+}
+
+// DeleteExpiredCalls gets all the calls that were made to DeleteExpired.
+// Check the length with:
+//
+//	len(mockedKVStore.DeleteExpiredCalls())
+func (mock *KVStoreMock) DeleteExpiredCalls() []struct {
+	Ctx context.Context
+	Now time.Time
+} {
+	var calls []struct {
+		Ctx context.Context
+		Now time.Time
+	}
+	mock.lockDeleteExpired.RLock()
+	calls = mock.calls.DeleteExpired
+	mock.lockDeleteExpired.RUnlock()
+	return calls
+}
+
 // Get calls GetFunc.
 func (mock *KVStoreMock) Get(ctx context.Context, key string) ([]byte, error) {
 	if mock.GetFunc == nil {
@@ -220,6 +380,46 @@ func (mock *KVStoreMock) GetCalls() []struct {
 	return calls
 }
 
+// GetHistory calls GetHistoryFunc.
+func (mock *KVStoreMock) GetHistory(ctx context.Context, key string, limit int) ([]store.Revision, error) {
+	if mock.GetHistoryFunc == nil {
+		panic("KVStoreMock.GetHistoryFunc: method is nil but KVStore.GetHistory was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Key   string
+		Limit int
+	}{
+		Ctx:   ctx,
+		Key:   key,
+		Limit: limit,
+	}
+	mock.lockGetHistory.Lock()
+	mock.calls.GetHistory = append(mock.calls.GetHistory, callInfo)
+	mock.lockGetHistory.Unlock()
+	return mock.GetHistoryFunc(ctx, key, limit)
+}
+
+// GetHistoryCalls gets all the calls that were made to GetHistory.
+// Check the length with:
+//
+//	len(mockedKVStore.GetHistoryCalls())
+func (mock *KVStoreMock) GetHistoryCalls() []struct {
+	Ctx   context.Context
+	Key   string
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Key   string
+		Limit int
+	}
+	mock.lockGetHistory.RLock()
+	calls = mock.calls.GetHistory
+	mock.lockGetHistory.RUnlock()
+	return calls
+}
+
 // GetInfo calls GetInfoFunc.
 func (mock *KVStoreMock) GetInfo(ctx context.Context, key string) (store.KeyInfo, error) {
 	if mock.GetInfoFunc == nil {
@@ -256,6 +456,42 @@ func (mock *KVStoreMock) GetInfoCalls() []struct {
 	return calls
 }
 
+// GetVariants calls GetVariantsFunc.
+func (mock *KVStoreMock) GetVariants(ctx context.Context, key string) (string, error) {
+	if mock.GetVariantsFunc == nil {
+		panic("KVStoreMock.GetVariantsFunc: method is nil but KVStore.GetVariants was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockGetVariants.Lock()
+	mock.calls.GetVariants = append(mock.calls.GetVariants, callInfo)
+	mock.lockGetVariants.Unlock()
+	return mock.GetVariantsFunc(ctx, key)
+}
+
+// GetVariantsCalls gets all the calls that were made to GetVariants.
+// Check the length with:
+//
+//	len(mockedKVStore.GetVariantsCalls())
+func (mock *KVStoreMock) GetVariantsCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockGetVariants.RLock()
+	calls = mock.calls.GetVariants
+	mock.lockGetVariants.RUnlock()
+	return calls
+}
+
 // GetWithFormat calls GetWithFormatFunc.
 func (mock *KVStoreMock) GetWithFormat(ctx context.Context, key string) ([]byte, string, error) {
 	if mock.GetWithFormatFunc == nil {
@@ -328,6 +564,86 @@ func (mock *KVStoreMock) ListCalls() []struct {
 	return calls
 }
 
+// ListPage calls ListPageFunc.
+func (mock *KVStoreMock) ListPage(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+	if mock.ListPageFunc == nil {
+		panic("KVStoreMock.ListPageFunc: method is nil but KVStore.ListPage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Q   store.ListQuery
+	}{
+		Ctx: ctx,
+		Q:   q,
+	}
+	mock.lockListPage.Lock()
+	mock.calls.ListPage = append(mock.calls.ListPage, callInfo)
+	mock.lockListPage.Unlock()
+	return mock.ListPageFunc(ctx, q)
+}
+
+// ListPageCalls gets all the calls that were made to ListPage.
+// Check the length with:
+//
+//	len(mockedKVStore.ListPageCalls())
+func (mock *KVStoreMock) ListPageCalls() []struct {
+	Ctx context.Context
+	Q   store.ListQuery
+} {
+	var calls []struct {
+		Ctx context.Context
+		Q   store.ListQuery
+	}
+	mock.lockListPage.RLock()
+	calls = mock.calls.ListPage
+	mock.lockListPage.RUnlock()
+	return calls
+}
+
+// Rollback calls RollbackFunc.
+func (mock *KVStoreMock) Rollback(ctx context.Context, key string, version int64, owner string) (store.Revision, bool, error) {
+	if mock.RollbackFunc == nil {
+		panic("KVStoreMock.RollbackFunc: method is nil but KVStore.Rollback was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Key     string
+		Version int64
+		Owner   string
+	}{
+		Ctx:     ctx,
+		Key:     key,
+		Version: version,
+		Owner:   owner,
+	}
+	mock.lockRollback.Lock()
+	mock.calls.Rollback = append(mock.calls.Rollback, callInfo)
+	mock.lockRollback.Unlock()
+	return mock.RollbackFunc(ctx, key, version, owner)
+}
+
+// RollbackCalls gets all the calls that were made to Rollback.
+// Check the length with:
+//
+//	len(mockedKVStore.RollbackCalls())
+func (mock *KVStoreMock) RollbackCalls() []struct {
+	Ctx     context.Context
+	Key     string
+	Version int64
+	Owner   string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Key     string
+		Version int64
+		Owner   string
+	}
+	mock.lockRollback.RLock()
+	calls = mock.calls.Rollback
+	mock.lockRollback.RUnlock()
+	return calls
+}
+
 // SecretsEnabled calls SecretsEnabledFunc.
 func (mock *KVStoreMock) SecretsEnabled() bool {
 	if mock.SecretsEnabledFunc == nil {
@@ -355,47 +671,131 @@ func (mock *KVStoreMock) SecretsEnabledCalls() []struct {
 	return calls
 }
 
-// Set calls SetFunc.
-func (mock *KVStoreMock) Set(ctx context.Context, key string, value []byte, format string) (bool, error) {
-	if mock.SetFunc == nil {
-		panic("KVStoreMock.SetFunc: method is nil but KVStore.Set was just called")
+// SetMeta calls SetMetaFunc.
+func (mock *KVStoreMock) SetMeta(ctx context.Context, key string, meta store.KeyMeta) error {
+	if mock.SetMetaFunc == nil {
+		panic("KVStoreMock.SetMetaFunc: method is nil but KVStore.SetMeta was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Key  string
+		Meta store.KeyMeta
+	}{
+		Ctx:  ctx,
+		Key:  key,
+		Meta: meta,
+	}
+	mock.lockSetMeta.Lock()
+	mock.calls.SetMeta = append(mock.calls.SetMeta, callInfo)
+	mock.lockSetMeta.Unlock()
+	return mock.SetMetaFunc(ctx, key, meta)
+}
+
+// SetMetaCalls gets all the calls that were made to SetMeta.
+// Check the length with:
+//
+//	len(mockedKVStore.SetMetaCalls())
+func (mock *KVStoreMock) SetMetaCalls() []struct {
+	Ctx  context.Context
+	Key  string
+	Meta store.KeyMeta
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Key  string
+		Meta store.KeyMeta
+	}
+	mock.lockSetMeta.RLock()
+	calls = mock.calls.SetMeta
+	mock.lockSetMeta.RUnlock()
+	return calls
+}
+
+// SetVariants calls SetVariantsFunc.
+func (mock *KVStoreMock) SetVariants(ctx context.Context, key string, spec string) error {
+	if mock.SetVariantsFunc == nil {
+		panic("KVStoreMock.SetVariantsFunc: method is nil but KVStore.SetVariants was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Key  string
+		Spec string
+	}{
+		Ctx:  ctx,
+		Key:  key,
+		Spec: spec,
+	}
+	mock.lockSetVariants.Lock()
+	mock.calls.SetVariants = append(mock.calls.SetVariants, callInfo)
+	mock.lockSetVariants.Unlock()
+	return mock.SetVariantsFunc(ctx, key, spec)
+}
+
+// SetVariantsCalls gets all the calls that were made to SetVariants.
+// Check the length with:
+//
+//	len(mockedKVStore.SetVariantsCalls())
+func (mock *KVStoreMock) SetVariantsCalls() []struct {
+	Ctx  context.Context
+	Key  string
+	Spec string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Key  string
+		Spec string
+	}
+	mock.lockSetVariants.RLock()
+	calls = mock.calls.SetVariants
+	mock.lockSetVariants.RUnlock()
+	return calls
+}
+
+// SetWithOptions calls SetWithOptionsFunc.
+func (mock *KVStoreMock) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+	if mock.SetWithOptionsFunc == nil {
+		panic("KVStoreMock.SetWithOptionsFunc: method is nil but KVStore.SetWithOptions was just called")
 	}
 	callInfo := struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
 	}{
 		Ctx:    ctx,
 		Key:    key,
 		Value:  value,
 		Format: format,
+		Opts:   opts,
 	}
-	mock.lockSet.Lock()
-	mock.calls.Set = append(mock.calls.Set, callInfo)
-	mock.lockSet.Unlock()
-	return mock.SetFunc(ctx, key, value, format)
+	mock.lockSetWithOptions.Lock()
+	mock.calls.SetWithOptions = append(mock.calls.SetWithOptions, callInfo)
+	mock.lockSetWithOptions.Unlock()
+	return mock.SetWithOptionsFunc(ctx, key, value, format, opts)
 }
 
-// SetCalls gets all the calls that were made to Set.
+// SetWithOptionsCalls gets all the calls that were made to SetWithOptions.
 // Check the length with:
 //
-//	len(mockedKVStore.SetCalls())
-func (mock *KVStoreMock) SetCalls() []struct {
+//	len(mockedKVStore.SetWithOptionsCalls())
+func (mock *KVStoreMock) SetWithOptionsCalls() []struct {
 	Ctx    context.Context
 	Key    string
 	Value  []byte
 	Format string
+	Opts   store.SetOptions
 } {
 	var calls []struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
 	}
-	mock.lockSet.RLock()
-	calls = mock.calls.Set
-	mock.lockSet.RUnlock()
+	mock.lockSetWithOptions.RLock()
+	calls = mock.calls.SetWithOptions
+	mock.lockSetWithOptions.RUnlock()
 	return calls
 }
 
@@ -446,3 +846,39 @@ func (mock *KVStoreMock) SetWithVersionCalls() []struct {
 	mock.lockSetWithVersion.RUnlock()
 	return calls
 }
+
+// Txn calls TxnFunc.
+func (mock *KVStoreMock) Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+	if mock.TxnFunc == nil {
+		panic("KVStoreMock.TxnFunc: method is nil but KVStore.Txn was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ops []store.TxnOp
+	}{
+		Ctx: ctx,
+		Ops: ops,
+	}
+	mock.lockTxn.Lock()
+	mock.calls.Txn = append(mock.calls.Txn, callInfo)
+	mock.lockTxn.Unlock()
+	return mock.TxnFunc(ctx, ops)
+}
+
+// TxnCalls gets all the calls that were made to Txn.
+// Check the length with:
+//
+//	len(mockedKVStore.TxnCalls())
+func (mock *KVStoreMock) TxnCalls() []struct {
+	Ctx context.Context
+	Ops []store.TxnOp
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ops []store.TxnOp
+	}
+	mock.lockTxn.RLock()
+	calls = mock.calls.Txn
+	mock.lockTxn.RUnlock()
+	return calls
+}
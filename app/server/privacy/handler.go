@@ -0,0 +1,121 @@
+// Package privacy provides the admin endpoint pseudonymizing a user for data-subject erasure requests.
+// The username is replaced with a random pseudonym in the audit log, key owners and git history, so
+// records of what was done are kept while no longer identifying the person.
+package privacy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/access"
+	"github.com/umputun/stash/app/store"
+)
+
+//go:generate moq -out mocks/store.go -pkg mocks -skip-ensure -fmt goimports . Store
+//go:generate moq -out mocks/history.go -pkg mocks -skip-ensure -fmt goimports . History
+//go:generate moq -out mocks/auth.go -pkg mocks -skip-ensure -fmt goimports . Auth
+
+// Store defines the interface for pseudonymizing database records of a user.
+type Store interface {
+	PseudonymizeUser(ctx context.Context, username, pseudonym string) (store.PseudonymizeResult, error)
+}
+
+// History defines the interface for rewriting commit authors of the git history.
+type History interface {
+	RewriteAuthor(name string, to git.Author) (int, error)
+}
+
+// Auth defines the interface for admin checks on pseudonymize requests.
+type Auth interface {
+	GetRequestActor(r *http.Request) (actorType, actorName string)
+	IsRequestAdmin(r *http.Request) bool
+}
+
+// Handler serves the pseudonymize endpoint.
+type Handler struct {
+	store   Store
+	history History // nil without git versioning
+	auth    Auth
+}
+
+// NewHandler creates a new pseudonymize handler, history is nil if git versioning is disabled.
+func NewHandler(st Store, history History, authSvc Auth) *Handler {
+	return &Handler{store: st, history: history, auth: authSvc}
+}
+
+// PseudonymizeRequest is the JSON request naming the user. Confirm repeats the username, as the
+// change can't be undone.
+type PseudonymizeRequest struct {
+	Username string `json:"username"`
+	Confirm  string `json:"confirm"`
+}
+
+// PseudonymizeResponse is the JSON response with the pseudonym and the number of changed records.
+type PseudonymizeResponse struct {
+	Pseudonym string `json:"pseudonym"`
+	store.PseudonymizeResult
+	Commits int `json:"commits"`
+}
+
+// HandlePseudonymize replaces the username with a new random pseudonym. The git history is rewritten
+// first, so a failed push fails the request before the database is changed. The server log records the
+// admin and the pseudonym, but not the username.
+// POST /privacy/pseudonymize
+func (h *Handler) HandlePseudonymize(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	var req PseudonymizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	if req.Username == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "username is required")
+		return
+	}
+	if req.Confirm != req.Username {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, nil, "confirm must repeat the username")
+		return
+	}
+
+	pseudonym, err := newPseudonym()
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to make pseudonym")
+		return
+	}
+	resp := PseudonymizeResponse{Pseudonym: pseudonym}
+	if h.history != nil {
+		// authors of git commits are the usernames, with the same email as set on commits
+		if resp.Commits, err = h.history.RewriteAuthor(req.Username, git.Author{Name: pseudonym, Email: pseudonym + "@stash"}); err != nil {
+			rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to rewrite git history")
+			return
+		}
+	}
+	if resp.PseudonymizeResult, err = h.store.PseudonymizeUser(r.Context(), req.Username, pseudonym); err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to pseudonymize records")
+		return
+	}
+	_, admin := h.auth.GetRequestActor(r)
+	log.Printf("[WARN] user pseudonymized as %s by %s: %d audit entries, %d owners, %d pinned keys, %d saved searches, "+
+		"%d freezes, %d delegated tokens, %d commits", pseudonym, admin, resp.AuditEntries, resp.Owners, resp.PinnedKeys,
+		resp.SavedSearches, resp.Freezes, resp.DelegatedTokens, resp.Commits)
+	rest.RenderJSON(w, resp)
+}
+
+// newPseudonym returns a random pseudonym, unrelated to the username so it can't be reversed.
+func newPseudonym() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return "redacted-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,102 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/privacy/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandlePseudonymize(t *testing.T) {
+	authMock := &mocks.AuthMock{
+		IsRequestAdminFunc: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" },
+		GetRequestActorFunc: func(r *http.Request) (string, string) {
+			if r.Header.Get("Authorization") == "" {
+				return "public", ""
+			}
+			return "token", "token:xxxx****"
+		},
+	}
+	newStore := func() *mocks.StoreMock {
+		return &mocks.StoreMock{PseudonymizeUserFunc: func(context.Context, string, string) (store.PseudonymizeResult, error) {
+			return store.PseudonymizeResult{AuditEntries: 12, Owners: 2}, nil
+		}}
+	}
+	call := func(h *Handler, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/privacy/pseudonymize", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.HandlePseudonymize(rec, req)
+		return rec
+	}
+
+	t.Run("pseudonymizes records and history", func(t *testing.T) {
+		st := newStore()
+		history := &mocks.HistoryMock{RewriteAuthorFunc: func(string, git.Author) (int, error) { return 5, nil }}
+		rec := call(NewHandler(st, history, authMock), "admin", `{"username":"alice","confirm":"alice"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp PseudonymizeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Regexp(t, `^redacted-[0-9a-f]{12}$`, resp.Pseudonym)
+		assert.Equal(t, int64(12), resp.AuditEntries)
+		assert.Equal(t, int64(2), resp.Owners)
+		assert.Equal(t, 5, resp.Commits)
+
+		require.Len(t, history.RewriteAuthorCalls(), 1)
+		assert.Equal(t, "alice", history.RewriteAuthorCalls()[0].Name)
+		assert.Equal(t, git.Author{Name: resp.Pseudonym, Email: resp.Pseudonym + "@stash"}, history.RewriteAuthorCalls()[0].To)
+		require.Len(t, st.PseudonymizeUserCalls(), 1)
+		assert.Equal(t, "alice", st.PseudonymizeUserCalls()[0].Username)
+		assert.Equal(t, resp.Pseudonym, st.PseudonymizeUserCalls()[0].Pseudonym, "same pseudonym everywhere")
+	})
+
+	t.Run("without git", func(t *testing.T) {
+		rec := call(NewHandler(newStore(), nil, authMock), "admin", `{"username":"alice","confirm":"alice"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"commits":0`)
+	})
+
+	t.Run("git failure leaves records unchanged", func(t *testing.T) {
+		st := newStore()
+		history := &mocks.HistoryMock{RewriteAuthorFunc: func(string, git.Author) (int, error) { return 0, errors.New("push rejected") }}
+		rec := call(NewHandler(st, history, authMock), "admin", `{"username":"alice","confirm":"alice"}`)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Empty(t, st.PseudonymizeUserCalls())
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		tbl := []struct{ name, token, body string }{
+			{"no username", "admin", `{"confirm":""}`},
+			{"confirm mismatch", "admin", `{"username":"alice","confirm":"bob"}`},
+			{"invalid body", "admin", `{`},
+		}
+		for _, tt := range tbl {
+			t.Run(tt.name, func(t *testing.T) {
+				st := newStore()
+				assert.Equal(t, http.StatusBadRequest, call(NewHandler(st, nil, authMock), tt.token, tt.body).Code)
+				assert.Empty(t, st.PseudonymizeUserCalls())
+			})
+		}
+	})
+
+	t.Run("requires admin", func(t *testing.T) {
+		st := newStore()
+		h := NewHandler(st, nil, authMock)
+		assert.Equal(t, http.StatusForbidden, call(h, "user", `{"username":"alice","confirm":"alice"}`).Code)
+		assert.Equal(t, http.StatusUnauthorized, call(h, "", `{"username":"alice","confirm":"alice"}`).Code)
+		assert.Empty(t, st.PseudonymizeUserCalls())
+	})
+}
@@ -0,0 +1,78 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/umputun/stash/app/git"
+)
+
+// HistoryMock is a mock implementation of privacy.History.
+//
+//	func TestSomethingThatUsesHistory(t *testing.T) {
+//
+//		// make and configure a mocked privacy.History
+//		mockedHistory := &HistoryMock{
+//			RewriteAuthorFunc: func(name string, to git.Author) (int, error) {
+//				panic("mock out the RewriteAuthor method")
+//			},
+//		}
+//
+//		// use mockedHistory in code that requires privacy.History
+//		// and then make assertions.
+//
+//	}
+type HistoryMock struct {
+	// RewriteAuthorFunc mocks the RewriteAuthor method.
+	RewriteAuthorFunc func(name string, to git.Author) (int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// RewriteAuthor holds details about calls to the RewriteAuthor method.
+		RewriteAuthor []struct {
+			// Name is the name argument value.
+			Name string
+			// To is the to argument value.
+			To git.Author
+		}
+	}
+	lockRewriteAuthor sync.RWMutex
+}
+
+// RewriteAuthor calls RewriteAuthorFunc.
+func (mock *HistoryMock) RewriteAuthor(name string, to git.Author) (int, error) {
+	if mock.RewriteAuthorFunc == nil {
+		panic("HistoryMock.RewriteAuthorFunc: method is nil but History.RewriteAuthor was just called")
+	}
+	callInfo := struct {
+		Name string
+		To   git.Author
+	}{
+		Name: name,
+		To:   to,
+	}
+	mock.lockRewriteAuthor.Lock()
+	mock.calls.RewriteAuthor = append(mock.calls.RewriteAuthor, callInfo)
+	mock.lockRewriteAuthor.Unlock()
+	return mock.RewriteAuthorFunc(name, to)
+}
+
+// RewriteAuthorCalls gets all the calls that were made to RewriteAuthor.
+// Check the length with:
+//
+//	len(mockedHistory.RewriteAuthorCalls())
+func (mock *HistoryMock) RewriteAuthorCalls() []struct {
+	Name string
+	To   git.Author
+} {
+	var calls []struct {
+		Name string
+		To   git.Author
+	}
+	mock.lockRewriteAuthor.RLock()
+	calls = mock.calls.RewriteAuthor
+	mock.lockRewriteAuthor.RUnlock()
+	return calls
+}
@@ -0,0 +1,85 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// StoreMock is a mock implementation of privacy.Store.
+//
+//	func TestSomethingThatUsesStore(t *testing.T) {
+//
+//		// make and configure a mocked privacy.Store
+//		mockedStore := &StoreMock{
+//			PseudonymizeUserFunc: func(ctx context.Context, username string, pseudonym string) (store.PseudonymizeResult, error) {
+//				panic("mock out the PseudonymizeUser method")
+//			},
+//		}
+//
+//		// use mockedStore in code that requires privacy.Store
+//		// and then make assertions.
+//
+//	}
+type StoreMock struct {
+	// PseudonymizeUserFunc mocks the PseudonymizeUser method.
+	PseudonymizeUserFunc func(ctx context.Context, username string, pseudonym string) (store.PseudonymizeResult, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// PseudonymizeUser holds details about calls to the PseudonymizeUser method.
+		PseudonymizeUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Pseudonym is the pseudonym argument value.
+			Pseudonym string
+		}
+	}
+	lockPseudonymizeUser sync.RWMutex
+}
+
+// PseudonymizeUser calls PseudonymizeUserFunc.
+func (mock *StoreMock) PseudonymizeUser(ctx context.Context, username string, pseudonym string) (store.PseudonymizeResult, error) {
+	if mock.PseudonymizeUserFunc == nil {
+		panic("StoreMock.PseudonymizeUserFunc: method is nil but Store.PseudonymizeUser was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Username  string
+		Pseudonym string
+	}{
+		Ctx:       ctx,
+		Username:  username,
+		Pseudonym: pseudonym,
+	}
+	mock.lockPseudonymizeUser.Lock()
+	mock.calls.PseudonymizeUser = append(mock.calls.PseudonymizeUser, callInfo)
+	mock.lockPseudonymizeUser.Unlock()
+	return mock.PseudonymizeUserFunc(ctx, username, pseudonym)
+}
+
+// PseudonymizeUserCalls gets all the calls that were made to PseudonymizeUser.
+// Check the length with:
+//
+//	len(mockedStore.PseudonymizeUserCalls())
+func (mock *StoreMock) PseudonymizeUserCalls() []struct {
+	Ctx       context.Context
+	Username  string
+	Pseudonym string
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Username  string
+		Pseudonym string
+	}
+	mock.lockPseudonymizeUser.RLock()
+	calls = mock.calls.PseudonymizeUser
+	mock.lockPseudonymizeUser.RUnlock()
+	return calls
+}
@@ -0,0 +1,299 @@
+// Package rpc serves the key-value API over gRPC, alongside the HTTP API. Unary calls are translated to
+// requests of the kv HTTP API and served in-process by the routes of the HTTP server, so tokens, permissions,
+// audit, validation, git commits and change events are the same for both. Watch streams key changes.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	log "github.com/go-pkgz/lgr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/umputun/stash/app/server/rpc/pb"
+	"github.com/umputun/stash/app/store"
+)
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative pb/stash.proto
+//go:generate moq -out mocks/auth.go -pkg mocks -skip-ensure -fmt goimports . AuthProvider
+
+// AuthProvider defines the interface for read permission checks of watched keys.
+type AuthProvider interface {
+	Enabled() bool
+	FilterKeysForRequest(r *http.Request, keys []string) []string
+}
+
+// forwardedMetadata lists metadata passed to the HTTP API as request headers of the same name.
+var forwardedMetadata = []string{"authorization", "x-auth-token", "x-stash-reason"}
+
+// httpCodes maps statuses of the HTTP API to gRPC codes.
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.Aborted,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusPreconditionRequired:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+}
+
+// Service implements the KV gRPC service, see pb/stash.proto.
+type Service struct {
+	pb.UnimplementedKVServer
+	auth    AuthProvider
+	handler http.Handler // routes of the HTTP server, set by Serve
+
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+	done     chan struct{} // closed on shutdown, ends watches
+}
+
+// New makes the service. It publishes key changes to watchers, so it must be added to publishers of change events.
+func New(auth AuthProvider) *Service {
+	return &Service{auth: auth, watchers: map[*watcher]struct{}{}, done: make(chan struct{})}
+}
+
+// Serve serves gRPC calls on the listener with the routes of the HTTP server until the context is canceled,
+// then stops gracefully. Without TLS config the connection is plain text.
+func (s *Service) Serve(ctx context.Context, lis net.Listener, handler http.Handler, tlsConfig *tls.Config) error {
+	s.handler = handler
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterKVServer(srv, s)
+
+	go func() {
+		<-ctx.Done()
+		log.Printf("[INFO] shutting down grpc server")
+		close(s.done) // watches never end by themselves, graceful stop would wait for them
+		srv.GracefulStop()
+	}()
+
+	log.Printf("[DEBUG] started grpc server on %s", lis.Addr())
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("grpc server error: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value of a key, GET /kv/{key}.
+func (s *Service) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	key := store.NormalizeKey(req.GetKey())
+	if key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	if reserved(key) {
+		return nil, status.Errorf(codes.InvalidArgument, "key %q can't be read, its path is taken by the HTTP API", key)
+	}
+	resp, err := s.call(ctx, http.MethodGet, "/kv/"+key, envQuery(req.GetEnv()), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetResponse{Value: resp.body.Bytes(), Format: resp.header.Get("X-Stash-Format")}, nil
+}
+
+// Set creates or updates a key, PUT /kv/{key}.
+func (s *Service) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetResponse, error) {
+	key := store.NormalizeKey(req.GetKey())
+	if key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	header := http.Header{}
+	if req.GetFormat() != "" {
+		header.Set("X-Stash-Format", req.GetFormat())
+	}
+	if req.Ttl != nil {
+		header.Set("X-Stash-TTL", req.GetTtl().AsDuration().String())
+	}
+	resp, err := s.call(ctx, http.MethodPut, "/kv/"+key, envQuery(req.GetEnv()), header, req.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SetResponse{Created: resp.code == http.StatusCreated}, nil
+}
+
+// Delete removes a key, DELETE /kv/{key}.
+func (s *Service) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	key := store.NormalizeKey(req.GetKey())
+	if key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	if _, err := s.call(ctx, http.MethodDelete, "/kv/"+key, envQuery(req.GetEnv()), nil, nil); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// List returns keys the caller can read, GET /kv/.
+func (s *Service) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	query := envQuery(req.GetEnv())
+	if req.GetPrefix() != "" {
+		query.Set("prefix", req.GetPrefix())
+	}
+	if req.GetQuery() != "" {
+		query.Set("q", req.GetQuery())
+	}
+	switch req.GetFilter() {
+	case pb.Filter_FILTER_SECRETS:
+		query.Set("filter", "secrets")
+	case pb.Filter_FILTER_KEYS:
+		query.Set("filter", "keys")
+	}
+	resp, err := s.call(ctx, http.MethodGet, "/kv/", query, http.Header{"Accept": {"application/json"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []store.KeyInfo
+	if err := json.Unmarshal(resp.body.Bytes(), &keys); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode key list: %v", err)
+	}
+	res := &pb.ListResponse{Keys: make([]*pb.KeyInfo, 0, len(keys))}
+	for _, k := range keys {
+		info := &pb.KeyInfo{Key: k.Key, Size: int64(k.Size), Format: k.Format, Secret: k.Secret, ZkEncrypted: k.ZKEncrypted,
+			CreatedAt: timestamppb.New(k.CreatedAt), UpdatedAt: timestamppb.New(k.UpdatedAt), Owner: k.Owner}
+		if k.ExpiresAt != nil {
+			info.ExpiresAt = timestamppb.New(*k.ExpiresAt)
+		}
+		res.Keys = append(res.Keys, info)
+	}
+	return res, nil
+}
+
+// call serves the HTTP API request made of the gRPC call and returns its successful response,
+// failed requests are returned as errors with the code matching the HTTP status.
+func (s *Service) call(ctx context.Context, method, path string, query url.Values, header http.Header,
+	body []byte) (resp *response, err error) {
+	r, err := s.request(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+
+	resp = &response{header: http.Header{}}
+	defer func() {
+		// handlers abort with ErrAbortHandler to drop the connection, e.g. by fault injection
+		if rec := recover(); rec != nil {
+			if rec != http.ErrAbortHandler { //nolint:errorlint // the panic value is compared as net/http does
+				panic(rec)
+			}
+			resp, err = nil, status.Error(codes.Unavailable, "connection dropped")
+		}
+	}()
+	s.handler.ServeHTTP(resp, r)
+
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+	if resp.code >= http.StatusBadRequest {
+		return nil, resp.err()
+	}
+	return resp, nil
+}
+
+// request makes the HTTP API request of the call, with credentials from its metadata and connection.
+func (s *Service) request(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Request, error) {
+	u := url.URL{Path: path, RawQuery: query.Encode()}
+	r, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	r.RequestURI = u.RequestURI()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, name := range forwardedMetadata {
+			for _, v := range md.Get(name) {
+				r.Header.Add(name, v)
+			}
+		}
+	}
+	// rate limits and audit use the address of the client, SPIFFE workloads are identified by their certificates
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+	return r, nil
+}
+
+// envQuery returns the query selecting the environment, empty for the default one.
+func envQuery(env string) url.Values {
+	res := url.Values{}
+	if env != "" {
+		res.Set("env", env)
+	}
+	return res
+}
+
+// reserved reports whether GET of the key is routed to another endpoint of the HTTP API.
+func reserved(key string) bool {
+	first, _, _ := strings.Cut(key, "/")
+	return first == "_export" || first == "_import" || first == "history" || first == "subscribe"
+}
+
+// response collects the response of the HTTP API to a call.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+// Header returns the response headers.
+func (w *response) Header() http.Header { return w.header }
+
+// WriteHeader records the status code, the first one wins.
+func (w *response) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// Write appends to the body.
+func (w *response) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b) //nolint:wrapcheck // bytes.Buffer writes don't fail
+}
+
+// err returns the gRPC error of a failed response, with the message of the JSON error or the text body.
+func (w *response) err() error {
+	code, ok := httpCodes[w.code]
+	if !ok {
+		code = codes.Internal
+		if w.code < http.StatusInternalServerError {
+			code = codes.Unknown
+		}
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(w.body.String())
+	if json.Unmarshal(w.body.Bytes(), &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	if msg == "" {
+		msg = http.StatusText(w.code)
+	}
+	return status.Error(code, msg)
+}
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/umputun/stash/app/server/rpc/pb"
+)
+
+// startService serves the service with the handler on a loopback port and returns a client of it.
+func startService(t *testing.T, svc *Service, handler http.Handler) pb.KVClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, svc.Serve(ctx, lis, handler, nil))
+	}()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		<-done
+	})
+	return pb.NewKVClient(conn)
+}
+
+func TestService_Unary(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/kv/":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"key":"app/a","size":3,"format":"json","created_at":"2025-01-15T10:00:00Z",` +
+				`"updated_at":"2025-01-15T11:00:00Z","expires_at":"2025-01-16T10:00:00Z"},{"key":"app/b","secret":true}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/kv/app/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"key not found"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/kv/app/denied":
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		case r.Method == http.MethodGet && r.URL.Path == "/kv/app/dropped":
+			panic(http.ErrAbortHandler)
+		case r.Method == http.MethodGet:
+			w.Header().Set("X-Stash-Format", "yaml")
+			_, _ = w.Write([]byte("a: 1"))
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	client := startService(t, New(nil), handler)
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer secret", "x-stash-reason", "INC-1",
+		"x-other", "dropped")
+
+	t.Run("get", func(t *testing.T) {
+		resp, err := client.Get(ctx, &pb.GetRequest{Key: "/app/config/", Env: "prod"})
+		require.NoError(t, err)
+		assert.Equal(t, "a: 1", string(resp.GetValue()))
+		assert.Equal(t, "yaml", resp.GetFormat())
+		assert.Equal(t, "/kv/app/config", got.URL.Path)
+		assert.Equal(t, "/kv/app/config?env=prod", got.RequestURI)
+		assert.Equal(t, "Bearer secret", got.Header.Get("Authorization"))
+		assert.Equal(t, "INC-1", got.Header.Get("X-Stash-Reason"))
+		assert.Empty(t, got.Header.Get("X-Other"), "only known metadata is passed on")
+		assert.Contains(t, got.RemoteAddr, "127.0.0.1:")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		resp, err := client.Set(ctx, &pb.SetRequest{Key: "app/config", Value: []byte("a: 2"), Format: "yaml",
+			Ttl: durationpb.New(time.Hour)})
+		require.NoError(t, err)
+		assert.True(t, resp.GetCreated())
+		assert.Equal(t, http.MethodPut, got.Method)
+		assert.Equal(t, "a: 2", gotBody)
+		assert.Equal(t, "yaml", got.Header.Get("X-Stash-Format"))
+		assert.Equal(t, "1h0m0s", got.Header.Get("X-Stash-TTL"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := client.Delete(ctx, &pb.DeleteRequest{Key: "app/config"})
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodDelete, got.Method)
+		assert.Equal(t, "/kv/app/config", got.RequestURI)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		resp, err := client.List(ctx, &pb.ListRequest{Prefix: "app/", Query: "format:json", Filter: pb.Filter_FILTER_SECRETS})
+		require.NoError(t, err)
+		assert.Equal(t, "app/", got.URL.Query().Get("prefix"))
+		assert.Equal(t, "format:json", got.URL.Query().Get("q"))
+		assert.Equal(t, "secrets", got.URL.Query().Get("filter"))
+		require.Len(t, resp.GetKeys(), 2)
+		k := resp.GetKeys()[0]
+		assert.Equal(t, "app/a", k.GetKey())
+		assert.Equal(t, int64(3), k.GetSize())
+		assert.Equal(t, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC), k.GetUpdatedAt().AsTime())
+		assert.Equal(t, time.Date(2025, 1, 16, 10, 0, 0, 0, time.UTC), k.GetExpiresAt().AsTime())
+		assert.True(t, resp.GetKeys()[1].GetSecret())
+		assert.Nil(t, resp.GetKeys()[1].GetExpiresAt())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			key  string
+			code codes.Code
+			msg  string
+		}{
+			{key: "app/missing", code: codes.NotFound, msg: "key not found"},
+			{key: "app/denied", code: codes.Unauthenticated, msg: "Unauthorized"},
+			{key: "app/dropped", code: codes.Unavailable, msg: "connection dropped"},
+			{key: "/", code: codes.InvalidArgument, msg: "key is required"},
+			{key: "history/app", code: codes.InvalidArgument, msg: `key "history/app" can't be read, its path is taken by the HTTP API`},
+		}
+		for _, tc := range tests {
+			_, err := client.Get(ctx, &pb.GetRequest{Key: tc.key})
+			st, ok := status.FromError(err)
+			require.True(t, ok, tc.key)
+			assert.Equal(t, tc.code, st.Code(), tc.key)
+			assert.Equal(t, tc.msg, st.Message(), tc.key)
+		}
+	})
+}
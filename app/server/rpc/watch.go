@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/rpc/pb"
+	"github.com/umputun/stash/app/store"
+)
+
+// watchBuffer is the number of events queued for a watcher. A watcher falling this far behind
+// gets its watch ended rather than slowing down writes, the client lists keys again after reconnecting.
+const watchBuffer = 1000
+
+// watcher is a single Watch call: what it watches and events waiting to be sent.
+type watcher struct {
+	events chan *pb.Event
+
+	mu       sync.Mutex
+	keys     map[string]bool // watched keys
+	prefixes map[string]bool // watched prefixes with trailing slash, "" for all keys
+	overflow bool            // events were dropped, events channel is closed
+}
+
+// Publish sends the key change to watchers of the key, without waiting for slow ones.
+func (s *Service) Publish(key string, action enum.AuditAction) {
+	event := &pb.Event{Key: key, Action: eventAction(action), Time: timestamppb.New(time.Now())}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		w.send(event)
+	}
+}
+
+// Watch streams changes of the keys and prefixes the client adds with its requests. Each request is answered
+// with the watched set, entries the caller can't read are denied. Events are checked against the permissions
+// of the caller too, so a prefix watch skips keys under it the caller can't read.
+func (s *Service) Watch(stream pb.KV_WatchServer) error {
+	ctx := stream.Context()
+	r, err := s.request(ctx, http.MethodGet, "/kv/", nil, nil) // carries credentials for permission checks
+	if err != nil {
+		return err
+	}
+	canRead := func(key string) bool {
+		return s.auth == nil || !s.auth.Enabled() || len(s.auth.FilterKeysForRequest(r, []string{key})) > 0
+	}
+
+	w := &watcher{events: make(chan *pb.Event, watchBuffer), keys: map[string]bool{}, prefixes: map[string]bool{}}
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	requests, recvErr := make(chan *pb.WatchRequest), make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req := <-requests:
+			denied := w.update(req, canRead)
+			resp := &pb.WatchResponse{Kind: &pb.WatchResponse_Watching{Watching: &pb.Watching{Entries: w.entries(), Denied: denied}}}
+			if err := stream.Send(resp); err != nil {
+				return err //nolint:wrapcheck // stream errors are grpc statuses already
+			}
+		case event, ok := <-w.events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch fell behind and missed events, list keys and watch again")
+			}
+			if !canRead(event.GetKey()) {
+				continue
+			}
+			if err := stream.Send(&pb.WatchResponse{Kind: &pb.WatchResponse_Event{Event: event}}); err != nil {
+				return err //nolint:wrapcheck // stream errors are grpc statuses already
+			}
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil // the client closed its side, it doesn't change the watched set anymore
+			}
+			return err //nolint:wrapcheck // stream errors are grpc statuses already
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// update adds and removes entries of the request and returns the added entries the caller can't read.
+func (w *watcher) update(req *pb.WatchRequest, canRead func(key string) bool) (denied []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, entry := range req.GetRemove() {
+		if prefix, ok := watchPrefix(entry); ok {
+			delete(w.prefixes, prefix)
+			continue
+		}
+		delete(w.keys, store.NormalizeKey(entry))
+	}
+	for _, entry := range req.GetAdd() {
+		prefix, isPrefix := watchPrefix(entry)
+		key := store.NormalizeKey(entry)
+		switch {
+		case !isPrefix && key == "":
+			denied = append(denied, entry)
+		case isPrefix && !canRead(prefix+"test"): // any key under the prefix, as SSE checks prefix subscriptions
+			denied = append(denied, entry)
+		case isPrefix:
+			w.prefixes[prefix] = true
+		case !canRead(key):
+			denied = append(denied, entry)
+		default:
+			w.keys[key] = true
+		}
+	}
+	return denied
+}
+
+// entries returns the watched set, sorted, prefixes ending with "/*" and "*" for all keys.
+func (w *watcher) entries() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	res := make([]string, 0, len(w.keys)+len(w.prefixes))
+	for k := range w.keys {
+		res = append(res, k)
+	}
+	for p := range w.prefixes {
+		res = append(res, p+"*")
+	}
+	slices.Sort(res)
+	return res
+}
+
+// send queues the event if the key is watched. A full queue ends the watch, see watchBuffer.
+func (w *watcher) send(event *pb.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.overflow || !w.watches(event.GetKey()) {
+		return
+	}
+	select {
+	case w.events <- event:
+	default:
+		log.Printf("[WARN] grpc watch fell behind, dropping it")
+		w.overflow = true
+		close(w.events)
+	}
+}
+
+// watches reports whether the key or one of its prefixes is watched, called with the lock held.
+func (w *watcher) watches(key string) bool {
+	if w.keys[key] || w.prefixes[""] {
+		return true
+	}
+	for i := range len(key) {
+		if key[i] == '/' && w.prefixes[key[:i+1]] {
+			return true
+		}
+	}
+	return false
+}
+
+// watchPrefix returns the prefix with trailing slash of a prefix entry: "app/*" and "app/" watch keys under app,
+// "*" watches all keys. False for entries of a single key.
+func watchPrefix(entry string) (string, bool) {
+	if entry != "*" && !strings.HasSuffix(entry, "/*") && !strings.HasSuffix(entry, "/") {
+		return "", false
+	}
+	prefix := store.NormalizeKey(strings.TrimSuffix(entry, "*"))
+	if prefix != "" {
+		prefix += "/"
+	}
+	return prefix, true
+}
+
+// eventAction maps the audit action of a change to the event action.
+func eventAction(action enum.AuditAction) pb.Action {
+	switch action {
+	case enum.AuditActionCreate:
+		return pb.Action_ACTION_CREATE
+	case enum.AuditActionUpdate:
+		return pb.Action_ACTION_UPDATE
+	case enum.AuditActionDelete:
+		return pb.Action_ACTION_DELETE
+	default:
+		return pb.Action_ACTION_UNSPECIFIED
+	}
+}
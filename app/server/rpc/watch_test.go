@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/rpc/mocks"
+	"github.com/umputun/stash/app/server/rpc/pb"
+)
+
+func TestService_Watch(t *testing.T) {
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc: func() bool { return true },
+		FilterKeysForRequestFunc: func(r *http.Request, keys []string) []string {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return nil
+			}
+			return slices.DeleteFunc(keys, func(k string) bool { return strings.HasPrefix(k, "db/") })
+		},
+	}
+	svc := New(auth)
+	client := startService(t, svc, http.NotFoundHandler())
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer secret")
+	stream, err := client.Watch(ctx)
+	require.NoError(t, err)
+
+	watching := func(req *pb.WatchRequest) *pb.Watching {
+		require.NoError(t, stream.Send(req))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetWatching())
+		return resp.GetWatching()
+	}
+	event := func() *pb.Event {
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetEvent())
+		return resp.GetEvent()
+	}
+
+	w := watching(&pb.WatchRequest{Add: []string{"app/config", "svc/*", "db/*", "db/password"}})
+	assert.Equal(t, []string{"app/config", "svc/*"}, w.GetEntries())
+	assert.Equal(t, []string{"db/*", "db/password"}, w.GetDenied())
+
+	svc.Publish("app/other", enum.AuditActionUpdate) // not watched
+	svc.Publish("svc/a/b", enum.AuditActionCreate)
+	e := event()
+	assert.Equal(t, "svc/a/b", e.GetKey())
+	assert.Equal(t, pb.Action_ACTION_CREATE, e.GetAction())
+
+	// watched set changes on the fly
+	w = watching(&pb.WatchRequest{Add: []string{"*"}, Remove: []string{"svc/*"}})
+	assert.Equal(t, []string{"*", "app/config"}, w.GetEntries())
+	svc.Publish("db/password", enum.AuditActionUpdate) // watched by *, but can't be read
+	svc.Publish("app/other", enum.AuditActionDelete)
+	e = event()
+	assert.Equal(t, "app/other", e.GetKey())
+	assert.Equal(t, pb.Action_ACTION_DELETE, e.GetAction())
+
+	w = watching(&pb.WatchRequest{Remove: []string{"*", "app/config"}})
+	assert.Empty(t, w.GetEntries())
+}
+
+func TestService_WatchDenied(t *testing.T) {
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:              func() bool { return true },
+		FilterKeysForRequestFunc: func(*http.Request, []string) []string { return nil },
+	}
+	client := startService(t, New(auth), http.NotFoundHandler())
+	stream, err := client.Watch(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.WatchRequest{Add: []string{"*"}}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetWatching().GetEntries())
+	assert.Equal(t, []string{"*"}, resp.GetWatching().GetDenied())
+}
+
+func TestService_WatchOverflow(t *testing.T) {
+	svc := New(nil)
+	client := startService(t, svc, http.NotFoundHandler())
+	stream, err := client.Watch(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.WatchRequest{Add: []string{"*"}}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// events are not read by the client, the watch ends when its queue is full rather than blocking writes
+	for range watchBuffer * 10 {
+		svc.Publish("app/key-with-a-long-name-filling-the-transport-buffers-of-the-stream", enum.AuditActionUpdate)
+	}
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestWatcher_Watches(t *testing.T) {
+	w := &watcher{keys: map[string]bool{"app/config": true}, prefixes: map[string]bool{"svc/a/": true}}
+	assert.True(t, w.watches("app/config"))
+	assert.False(t, w.watches("app/config/x"))
+	assert.True(t, w.watches("svc/a/b"))
+	assert.True(t, w.watches("svc/a/b/c"))
+	assert.False(t, w.watches("svc/ab"))
+	assert.False(t, w.watches("svc/a"))
+
+	w.prefixes[""] = true
+	assert.True(t, w.watches("anything"))
+}
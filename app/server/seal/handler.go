@@ -0,0 +1,80 @@
+package seal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/access"
+)
+
+//go:generate moq -out mocks/auth.go -pkg mocks -skip-ensure -fmt goimports . Auth
+
+// Auth defines the interface for admin checks on unseal requests.
+type Auth interface {
+	GetRequestActor(r *http.Request) (actorType, actorName string)
+	IsRequestAdmin(r *http.Request) bool
+}
+
+// Handler serves the unseal endpoints.
+type Handler struct {
+	sealer *Sealer
+	auth   Auth
+}
+
+// NewHandler creates a new unseal handler.
+func NewHandler(sealer *Sealer, authSvc Auth) *Handler {
+	return &Handler{sealer: sealer, auth: authSvc}
+}
+
+// UnsealRequest is the JSON request with one unseal share.
+type UnsealRequest struct {
+	Share string `json:"share"`
+}
+
+// HandleStatus returns the seal status. It reveals no key material and needs no auth,
+// so health checks and deploy scripts can wait for unseal.
+// GET /unseal
+func (h *Handler) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	rest.RenderJSON(w, h.sealer.Status())
+}
+
+// HandleUnseal submits one unseal share (admin only).
+// POST /unseal
+func (h *Handler) HandleUnseal(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	var req UnsealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Share == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "share is required")
+		return
+	}
+
+	_, actor := h.auth.GetRequestActor(r)
+	status, err := h.sealer.Submit(req.Share)
+	if errors.Is(err, ErrInvalidShare) {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "invalid share")
+		return
+	}
+	if err != nil {
+		log.Printf("[WARN] unseal share from %s rejected: %v", actor, err)
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusConflict, err, err.Error())
+		return
+	}
+	log.Printf("[INFO] unseal share accepted from %s, %d/%d", actor, status.Progress, status.Threshold)
+	rest.RenderJSON(w, status)
+}
+
+// HandleReset discards submitted shares (admin only).
+// DELETE /unseal
+func (h *Handler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	if !access.RequireAdmin(w, r, h.auth) {
+		return
+	}
+	h.sealer.Reset()
+	rest.RenderJSON(w, h.sealer.Status())
+}
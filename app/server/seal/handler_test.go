@@ -0,0 +1,66 @@
+package seal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/seal/mocks"
+)
+
+func TestHandler(t *testing.T) {
+	shares, err := Split([]byte("test-master-key-123"), 3, 2)
+	require.NoError(t, err)
+	sealer := newTestSealer(t)
+	authMock := &mocks.AuthMock{
+		IsRequestAdminFunc: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" },
+		GetRequestActorFunc: func(r *http.Request) (string, string) {
+			if r.Header.Get("Authorization") == "" {
+				return "public", ""
+			}
+			return "token", "token:xxxx****"
+		},
+	}
+	h := NewHandler(sealer, authMock)
+
+	call := func(handler http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/unseal", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := call(h.HandleStatus, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"sealed":true,"threshold":0,"progress":0}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, call(h.HandleUnseal, http.MethodPost, "", `{"share":"x"}`).Code)
+	assert.Equal(t, http.StatusForbidden, call(h.HandleUnseal, http.MethodPost, "user", `{"share":"x"}`).Code)
+	assert.Equal(t, http.StatusForbidden, call(h.HandleReset, http.MethodDelete, "user", "").Code)
+	assert.Equal(t, http.StatusBadRequest, call(h.HandleUnseal, http.MethodPost, "admin", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(h.HandleUnseal, http.MethodPost, "admin", `{"share":"x"}`).Code)
+
+	rec = call(h.HandleUnseal, http.MethodPost, "admin", `{"share":"`+shares[0]+`"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"sealed":true,"threshold":2,"progress":1}`, rec.Body.String())
+
+	rec = call(h.HandleUnseal, http.MethodPost, "admin", `{"share":"`+shares[0]+`"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "share already submitted")
+
+	rec = call(h.HandleReset, http.MethodDelete, "admin", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"sealed":true,"threshold":0,"progress":0}`, rec.Body.String())
+
+	call(h.HandleUnseal, http.MethodPost, "admin", `{"share":"`+shares[1]+`"}`)
+	rec = call(h.HandleUnseal, http.MethodPost, "admin", `{"share":"`+shares[2]+`"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"sealed":false,"threshold":0,"progress":0}`, rec.Body.String())
+}
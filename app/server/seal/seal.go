@@ -0,0 +1,136 @@
+// Package seal implements sealed start mode. The server starts without the secrets key and
+// reconstructs it from K-of-N Shamir shares submitted by admins, so the key is never present
+// in flags, environment or on disk.
+package seal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// Status describes the seal state and unseal progress.
+type Status struct {
+	Sealed    bool `json:"sealed"`
+	Threshold int  `json:"threshold"` // shares required, 0 until the first share is submitted
+	Progress  int  `json:"progress"`  // shares submitted so far
+}
+
+// Sealer is a store.Encryptor that fails with store.ErrSealed until unsealed. Once enough shares
+// are submitted, the reconstructed key is passed to the factory and all calls go to its encryptor.
+type Sealer struct {
+	newEncryptor func(key []byte) (store.Encryptor, error)
+
+	mu     sync.RWMutex
+	enc    store.Encryptor // nil while sealed
+	shares []share         // submitted shares, all with the same threshold and checksum
+}
+
+// New creates a sealed Sealer. The factory builds the encryptor from the reconstructed key.
+func New(newEncryptor func(key []byte) (store.Encryptor, error)) *Sealer {
+	return &Sealer{newEncryptor: newEncryptor}
+}
+
+// Encrypt encrypts with the unsealed encryptor.
+func (s *Sealer) Encrypt(value []byte) ([]byte, error) {
+	enc, err := s.encryptor()
+	if err != nil {
+		return nil, err
+	}
+	res, err := enc.Encrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	return res, nil
+}
+
+// Decrypt decrypts with the unsealed encryptor.
+func (s *Sealer) Decrypt(encrypted []byte) ([]byte, error) {
+	enc, err := s.encryptor()
+	if err != nil {
+		return nil, err
+	}
+	res, err := enc.Decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return res, nil
+}
+
+func (s *Sealer) encryptor() (store.Encryptor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.enc == nil {
+		return nil, store.ErrSealed
+	}
+	return s.enc, nil
+}
+
+// Status returns the current seal state.
+func (s *Sealer) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status()
+}
+
+func (s *Sealer) status() Status {
+	st := Status{Sealed: s.enc == nil, Progress: len(s.shares)}
+	if len(s.shares) > 0 {
+		st.Threshold = int(s.shares[0].threshold)
+	}
+	return st
+}
+
+// Submit adds an unseal share. When the threshold is reached the key is reconstructed and the
+// sealer is unsealed. Shares from a different split are rejected, and a failed reconstruction
+// discards all submitted shares, so unsealing can start over. Submitting to an unsealed sealer is a no-op.
+func (s *Sealer) Submit(text string) (Status, error) {
+	sh, err := decodeShare(text)
+	if err != nil {
+		return s.Status(), err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enc != nil {
+		return s.status(), nil
+	}
+	for _, prev := range s.shares {
+		if prev.threshold != sh.threshold || string(prev.checksum) != string(sh.checksum) || len(prev.y) != len(sh.y) {
+			return s.status(), errors.New("share belongs to a different key split")
+		}
+		if prev.x == sh.x {
+			return s.status(), errors.New("share already submitted")
+		}
+	}
+	s.shares = append(s.shares, sh)
+	if len(s.shares) < int(sh.threshold) {
+		log.Printf("[INFO] unseal progress %d/%d", len(s.shares), sh.threshold)
+		return s.status(), nil
+	}
+
+	shares := s.shares
+	s.shares = nil
+	key, err := combine(shares)
+	if err != nil {
+		return s.status(), fmt.Errorf("unseal failed, submitted shares discarded: %w", err)
+	}
+	enc, err := s.newEncryptor(key) // the encryptor keeps the key, don't clear it
+	if err != nil {
+		return s.status(), fmt.Errorf("unseal failed, submitted shares discarded: %w", err)
+	}
+	s.enc = enc
+	log.Printf("[INFO] secrets unsealed")
+	return s.status(), nil
+}
+
+// Reset discards submitted shares, e.g. after a wrong share was submitted.
+func (s *Sealer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = nil
+}
@@ -0,0 +1,84 @@
+package seal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/store"
+)
+
+func newTestSealer(t *testing.T) *Sealer {
+	t.Helper()
+	return New(func(key []byte) (store.Encryptor, error) {
+		return store.NewCrypto(key)
+	})
+}
+
+func TestSealer(t *testing.T) {
+	shares, err := Split([]byte("test-master-key-123"), 3, 2)
+	require.NoError(t, err)
+	sealer := newTestSealer(t)
+
+	_, err = sealer.Encrypt([]byte("value"))
+	require.ErrorIs(t, err, store.ErrSealed)
+	_, err = sealer.Decrypt([]byte("value"))
+	require.ErrorIs(t, err, store.ErrSealed)
+	assert.Equal(t, Status{Sealed: true}, sealer.Status())
+
+	st, err := sealer.Submit(shares[1])
+	require.NoError(t, err)
+	assert.Equal(t, Status{Sealed: true, Threshold: 2, Progress: 1}, st)
+
+	_, err = sealer.Submit(shares[1])
+	require.EqualError(t, err, "share already submitted")
+
+	other, err := Split([]byte("another-master-key"), 3, 2)
+	require.NoError(t, err)
+	_, err = sealer.Submit(other[0])
+	require.EqualError(t, err, "share belongs to a different key split")
+
+	_, err = sealer.Submit("garbage")
+	require.ErrorIs(t, err, ErrInvalidShare)
+
+	st, err = sealer.Submit(shares[0])
+	require.NoError(t, err)
+	assert.Equal(t, Status{Sealed: false}, st)
+
+	enc, err := sealer.Encrypt([]byte("value"))
+	require.NoError(t, err)
+	direct, err := store.NewCrypto([]byte("test-master-key-123"))
+	require.NoError(t, err)
+	plain, err := direct.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(plain), "unsealed with the original key")
+
+	st, err = sealer.Submit(shares[2])
+	require.NoError(t, err, "no-op once unsealed")
+	assert.False(t, st.Sealed)
+}
+
+func TestSealer_ResetAndFailedUnseal(t *testing.T) {
+	shares, err := Split([]byte("short"), 2, 2) // too short for the encryptor
+	require.NoError(t, err)
+	sealer := newTestSealer(t)
+
+	_, err = sealer.Submit(shares[0])
+	require.NoError(t, err)
+	sealer.Reset()
+	assert.Equal(t, Status{Sealed: true}, sealer.Status())
+
+	_, err = sealer.Submit(shares[0])
+	require.NoError(t, err)
+	_, err = sealer.Submit(shares[1])
+	require.ErrorContains(t, err, "unseal failed, submitted shares discarded")
+	assert.Equal(t, Status{Sealed: true}, sealer.Status())
+
+	sealer = New(func([]byte) (store.Encryptor, error) { return nil, errors.New("boom") })
+	for _, sh := range shares {
+		_, err = sealer.Submit(sh)
+	}
+	require.ErrorContains(t, err, "boom")
+}
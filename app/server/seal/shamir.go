@@ -0,0 +1,161 @@
+package seal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sharePrefix marks encoded unseal shares, the rest is base64url of version, threshold, x, checksum and y.
+const sharePrefix = "stash-share-"
+
+const (
+	shareVersion  = 1
+	checksumSize  = 4
+	shareHeadSize = 3 + checksumSize // version, threshold, x, checksum
+)
+
+// ErrInvalidShare is returned for shares that can't be decoded.
+var ErrInvalidShare = errors.New("invalid unseal share")
+
+// share is a decoded unseal share. Each share carries the threshold and a short checksum of the
+// secret, so the server can tell mixed up shares apart and verify the reconstructed key.
+type share struct {
+	threshold byte
+	x         byte   // evaluation point, 1..255
+	checksum  []byte // first bytes of sha256 of the secret
+	y         []byte // polynomial values, one per secret byte
+}
+
+// Split divides the secret into n shares using Shamir's secret sharing over GF(2^8).
+// Any threshold shares reconstruct the secret, fewer reveal nothing about it.
+func Split(secret []byte, n, threshold int) ([]string, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, errors.New("secret is empty")
+	case threshold < 2:
+		return nil, errors.New("threshold must be at least 2")
+	case n < threshold:
+		return nil, fmt.Errorf("shares (%d) must not be fewer than threshold (%d)", n, threshold)
+	case n > 255:
+		return nil, errors.New("at most 255 shares are supported")
+	}
+
+	sum := sha256.Sum256(secret)
+	shares := make([]share, n)
+	for i := range shares {
+		shares[i] = share{threshold: byte(threshold), x: byte(i + 1), checksum: sum[:checksumSize], y: make([]byte, len(secret))}
+	}
+
+	// random polynomial of degree threshold-1 per secret byte, the secret byte is the constant term
+	coeffs := make([]byte, threshold)
+	for pos, b := range secret {
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("generate coefficients: %w", err)
+		}
+		coeffs[0] = b
+		for i := range shares {
+			shares[i].y[pos] = evalPoly(coeffs, shares[i].x)
+		}
+	}
+	clear(coeffs)
+
+	res := make([]string, n)
+	for i, s := range shares {
+		res[i] = s.encode()
+	}
+	return res, nil
+}
+
+// combine reconstructs the secret from at least threshold shares with distinct x and verifies its checksum.
+func combine(shares []share) ([]byte, error) {
+	if len(shares) == 0 || len(shares) < int(shares[0].threshold) {
+		return nil, errors.New("not enough shares")
+	}
+	shares = shares[:shares[0].threshold]
+	secret := make([]byte, len(shares[0].y))
+	for pos := range secret {
+		// lagrange interpolation at x=0, subtraction is xor in GF(2^8)
+		var val byte
+		for i, si := range shares {
+			basis := byte(1)
+			for j, sj := range shares {
+				if i == j {
+					continue
+				}
+				basis = gfMul(basis, gfMul(sj.x, gfInv(si.x^sj.x)))
+			}
+			val ^= gfMul(si.y[pos], basis)
+		}
+		secret[pos] = val
+	}
+	sum := sha256.Sum256(secret)
+	if string(sum[:checksumSize]) != string(shares[0].checksum) {
+		clear(secret)
+		return nil, errors.New("reconstructed key doesn't match share checksum")
+	}
+	return secret, nil
+}
+
+// encode returns the text form of the share.
+func (s share) encode() string {
+	buf := make([]byte, 0, shareHeadSize+len(s.y))
+	buf = append(buf, shareVersion, s.threshold, s.x)
+	buf = append(buf, s.checksum...)
+	buf = append(buf, s.y...)
+	return sharePrefix + base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodeShare parses the text form of a share.
+func decodeShare(text string) (share, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(text), sharePrefix)
+	if !ok {
+		return share{}, fmt.Errorf("%w: missing %q prefix", ErrInvalidShare, sharePrefix)
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return share{}, fmt.Errorf("%w: %w", ErrInvalidShare, err)
+	}
+	if len(buf) <= shareHeadSize || buf[0] != shareVersion || buf[1] < 2 || buf[2] == 0 {
+		return share{}, ErrInvalidShare
+	}
+	return share{threshold: buf[1], x: buf[2], checksum: buf[3:shareHeadSize], y: buf[shareHeadSize:]}, nil
+}
+
+// evalPoly evaluates the polynomial with the given coefficients (constant term first) at x.
+func evalPoly(coeffs []byte, x byte) byte {
+	var res byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		res = gfMul(res, x) ^ coeffs[i]
+	}
+	return res
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse, a^254 in GF(2^8). Zero has no inverse and maps to zero.
+func gfInv(a byte) byte {
+	res := byte(1)
+	for range 254 {
+		res = gfMul(res, a)
+	}
+	return res
+}
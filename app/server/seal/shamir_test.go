@@ -0,0 +1,72 @@
+package seal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("super-secret-master-key")
+	texts, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, texts, 5)
+
+	shares := make([]share, len(texts))
+	for i, text := range texts {
+		assert.Contains(t, text, sharePrefix)
+		shares[i], err = decodeShare(text)
+		require.NoError(t, err)
+		assert.Equal(t, byte(3), shares[i].threshold)
+		assert.Equal(t, byte(i+1), shares[i].x)
+	}
+
+	for _, idx := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		subset := make([]share, 0, len(idx))
+		for _, i := range idx {
+			subset = append(subset, shares[i])
+		}
+		got, err := combine(subset)
+		require.NoError(t, err, idx)
+		assert.Equal(t, secret, got, idx)
+	}
+
+	_, err = combine(shares[:2])
+	require.EqualError(t, err, "not enough shares")
+
+	tampered := []share{shares[0], shares[1], {threshold: 3, x: 3, checksum: shares[2].checksum, y: make([]byte, len(secret))}}
+	_, err = combine(tampered)
+	require.ErrorContains(t, err, "doesn't match share checksum")
+}
+
+func TestSplit_Errors(t *testing.T) {
+	tbl := []struct {
+		secret       string
+		n, threshold int
+		err          string
+	}{
+		{"", 3, 2, "secret is empty"},
+		{"key", 3, 1, "threshold must be at least 2"},
+		{"key", 2, 3, "must not be fewer than threshold"},
+		{"key", 256, 3, "at most 255 shares"},
+	}
+	for _, tt := range tbl {
+		_, err := Split([]byte(tt.secret), tt.n, tt.threshold)
+		require.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestDecodeShare_Invalid(t *testing.T) {
+	for _, text := range []string{"", "abc", sharePrefix + "!!!", sharePrefix + "AQID", sharePrefix + "AgMBAAAAAAE"} {
+		_, err := decodeShare(text)
+		require.ErrorIs(t, err, ErrInvalidShare, text)
+	}
+}
+
+func TestGF(t *testing.T) {
+	assert.Equal(t, byte(0xc1), gfMul(0x57, 0x83), "FIPS-197 example")
+	for a := 1; a < 256; a++ {
+		assert.Equal(t, byte(1), gfMul(byte(a), gfInv(byte(a))), a)
+	}
+}
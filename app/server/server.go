@@ -3,10 +3,13 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"io/fs"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/didip/tollbooth/v8"
@@ -17,9 +20,24 @@ import (
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/alert"
 	"github.com/umputun/stash/app/server/api"
 	"github.com/umputun/stash/app/server/audit"
 	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/bridge"
+	"github.com/umputun/stash/app/server/freeze"
+	"github.com/umputun/stash/app/server/internal/cookie"
+	"github.com/umputun/stash/app/server/internal/environ"
+	"github.com/umputun/stash/app/server/internal/expiry"
+	"github.com/umputun/stash/app/server/internal/fault"
+	"github.com/umputun/stash/app/server/internal/history"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/server/internal/ratelimit"
+	"github.com/umputun/stash/app/server/internal/shed"
+	"github.com/umputun/stash/app/server/privacy"
+	"github.com/umputun/stash/app/server/rpc"
+	"github.com/umputun/stash/app/server/seal"
+	"github.com/umputun/stash/app/server/snapshot"
 	"github.com/umputun/stash/app/server/sse"
 	"github.com/umputun/stash/app/server/web"
 	"github.com/umputun/stash/app/store"
@@ -32,11 +50,23 @@ import (
 type Server struct {
 	Deps
 	Config
-	apiHandler      *api.Handler
-	webHandler      *web.Handler
-	auditHandler    *audit.Handler
-	webAuditHandler *web.AuditHandler
-	staticFS        fs.FS // embedded static files
+	apiHandler       *api.Handler
+	webHandler       *web.Handler
+	auditHandler     *audit.Handler
+	webAuditHandler  *web.AuditHandler
+	dashboardHandler *web.DashboardHandler
+	unsealHandler    *seal.Handler
+	alertHandler     *alert.Handler
+	privacyHandler   *privacy.Handler
+	freezeHandler    *freeze.Handler
+	canaries         *alert.Canaries      // nil if no canary keys configured
+	reasons          *audit.Justification // nil if no keys require an access reason
+	envs             *environ.Set         // nil if no environments configured, ?env= is rejected then
+	faults           *fault.Injector      // nil unless fault injection is enabled for testing clients
+	shedder          *shed.Limiter        // limits in-flight requests, sheds bulk and API requests before the web UI
+	tokenLimiter     *ratelimit.Limiter   // limits requests per API token or web session, nil if disabled
+	reaper           *expiry.Reaper       // nil if expired keys are not deleted by this instance
+	rpc              *rpc.Service         // nil unless the gRPC API is enabled
 }
 
 // KVStore defines the interface for key-value storage operations.
@@ -44,10 +74,18 @@ type KVStore interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	GetWithFormat(ctx context.Context, key string) ([]byte, string, error)
 	GetInfo(ctx context.Context, key string) (store.KeyInfo, error)
-	Set(ctx context.Context, key string, value []byte, format string) (created bool, err error)
+	SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (created bool, err error)
 	SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error
 	Delete(ctx context.Context, key string) error
+	Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
+	SetVariants(ctx context.Context, key, spec string) error
+	GetVariants(ctx context.Context, key string) (string, error)
+	SetMeta(ctx context.Context, key string, meta store.KeyMeta) error
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+	GetHistory(ctx context.Context, key string, limit int) ([]store.Revision, error)
+	Rollback(ctx context.Context, key string, version int64, owner string) (rev store.Revision, created bool, err error)
 	List(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
+	ListPage(ctx context.Context, q store.ListQuery) (keys []store.KeyInfo, total int, err error)
 	SecretsEnabled() bool
 }
 
@@ -57,6 +95,7 @@ type GitService interface {
 	Delete(key string, author git.Author) error
 	History(key string, limit int) ([]git.HistoryEntry, error)
 	GetRevision(key string, rev string) ([]byte, string, error)
+	RewriteAuthor(name string, to git.Author) (int, error)
 }
 
 // Validator defines the interface for format validation.
@@ -69,58 +108,126 @@ type Validator interface {
 // Config holds server configuration.
 type Config struct {
 	Address         string
+	GRPCAddress     string // address of the gRPC API, disabled if empty
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
 	ShutdownTimeout time.Duration
 	Version         string
-	BaseURL         string // base URL path for reverse proxy (e.g., /stash)
-	PageSize        int    // keys per page in web UI (0 = unlimited)
+	BaseURL         string   // base URL path for reverse proxy (e.g., /stash)
+	PageSize        int      // keys per page in web UI (0 = unlimited)
+	FrameAncestors  []string // origins allowed to embed the web UI in a frame (CSP frame-ancestors)
+
+	TLSCert   string         // TLS certificate file, serves plain HTTP if empty
+	TLSKey    string         // TLS key file
+	ClientCAs *x509.CertPool // CAs verifying optional client certificates (SPIFFE trust bundle), nil to ignore them
 
 	BodySizeLimit    int64   // max request body size in bytes
 	RequestsPerSec   float64 // max requests per second (rate limit)
+	TokenRequests    float64 // max requests per second of an API token or web session on /kv and web UI routes, 0 disables
+	TokenBurst       int     // requests an API token or web session can make at once, TokenRequests if zero
 	MaxConcurrent    int64   // max concurrent in-flight requests
 	LoginConcurrency int64   // max concurrent login attempts
+	ShedLowShare     float64 // share of MaxConcurrent for key lists, exports and imports (default 0.5)
+	ShedAPIShare     float64 // share of MaxConcurrent for API requests, the rest is kept for the web UI (default 0.8)
 
 	AuditEnabled    bool // enable audit logging
 	AuditQueryLimit int  // max entries per audit query (default 10000)
+
+	Canaries []string // canary key patterns (exact key or prefix with * suffix), reads are audited and alerted
+	Justify  []string // key patterns (exact key or prefix with * suffix) requiring an access reason
+
+	OwnerDelete bool // only owners and admins can delete keys with a recorded owner
+
+	Environments []string // environments selected with ?env= in the kv API, as name or name:base
+	Variants     bool     // serve A/B variants of values, costs a lookup per kv API read
+	History      bool     // the store keeps previous values, served by the kv history API without git and for rollback
+
+	HistoryHide    []string // key patterns (exact key or prefix with * suffix) with history shown to admins only
+	HistorySecrets bool     // history of secret keys is shown to admins only
+
+	FaultInjection []string // fault rules per route, route:param=value,..., injecting latency, errors and drops; testing only
+	Profiler       bool     // serve pprof and expvar under /debug/ to loopback clients; testing only
+
+	ExpiryInterval time.Duration // how often keys past their TTL are deleted, 0 disables deleting them
 }
 
 // Deps holds server dependencies.
 type Deps struct {
 	Store      KVStore
 	Validator  Validator
-	Git        GitService    // optional, nil to disable git versioning
-	Auth       *auth.Service // optional, nil to disable authentication
-	AuditStore *store.Store  // optional, nil to disable audit logging
-	SSE        *sse.Service  // optional, nil to disable key change subscriptions
+	Git        GitService     // optional, nil to disable git versioning
+	Auth       *auth.Service  // optional, nil to disable authentication
+	AuditStore *store.Store   // optional, nil to disable audit logging
+	PrefsStore *store.Store   // optional, nil to disable pinned keys and saved searches
+	Records    *store.Store   // optional, audit log and key owners pseudonymized on erasure requests by admins
+	SSE        *sse.Service   // optional, nil to disable key change subscriptions
+	Alerts     audit.Observer // optional, nil to disable suspicious activity alerts
+	Outbox     *alert.Outbox  // optional, persisted alert deliveries with dead letters managed by admins
+	Sealer     *seal.Sealer   // optional, nil unless started sealed; secrets unlock via unseal shares
+	Bridge     *bridge.Bridge // optional, nil to disable forwarding key change events to NATS or Kafka
+	Freezes    *store.Store   // optional, prefixes frozen by admins during incidents, their keys can't be changed
 }
 
 // New creates a new Server instance.
 func New(deps Deps, cfg Config) (*Server, error) {
-	staticContent, err := web.StaticFS()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load static files: %w", err)
-	}
-
 	s := &Server{
-		Deps:     deps,
-		Config:   cfg,
-		staticFS: staticContent,
+		Deps:   deps,
+		Config: cfg,
 	}
 
 	// create web handler with optional audit logger and events
-	webDeps := web.Deps{Store: deps.Store, Auth: deps.Auth, Validator: deps.Validator, Git: deps.Git}
+	owners := ownership.New(deps.Store, cfg.OwnerDelete)
+	webDeps := web.Deps{Store: deps.Store, Auth: deps.Auth, Validator: deps.Validator, Git: deps.Git, Owners: owners}
 	if cfg.AuditEnabled && deps.AuditStore != nil {
-		webDeps.Audit = deps.AuditStore
+		webDeps.Audit, webDeps.Changes = deps.AuditStore, deps.AuditStore
+	}
+	if deps.Alerts != nil {
+		webDeps.Alerts = deps.Alerts
+	}
+	// sidebar with pinned keys, saved searches and recent keys is for logged-in users, recent keys come from audit
+	if deps.PrefsStore != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		webDeps.Prefs = deps.PrefsStore
+	}
+	if cfg.AuditEnabled && deps.AuditStore != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		webDeps.Recent = deps.AuditStore
+	}
+	if deps.Auth != nil && deps.Auth.Enabled() {
+		webDeps.BreakGlass = deps.Auth
+		webDeps.Access = deps.Auth
 	}
+	if deps.Freezes != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		webDeps.Freezes = deps.Freezes
+	}
+	if s.canaries = alert.NewCanaries(cfg.Canaries); s.canaries != nil {
+		webDeps.Canaries = s.canaries
+	}
+	if s.reasons = audit.NewJustification(cfg.Justify); s.reasons != nil {
+		webDeps.Reasons = s.reasons
+	}
+	historyAccess := history.New(cfg.HistoryHide, cfg.HistorySecrets)
+	if historyAccess != nil {
+		webDeps.HistoryAccess = historyAccess
+	}
+	// key changes go to the snapshot tracker and, if enabled, to SSE subscribers and the message bus bridge
+	snapshots := snapshot.New(0, 0)
+	events := publishers{snapshots}
 	if deps.SSE != nil {
-		webDeps.Events = deps.SSE
+		events = append(events, deps.SSE)
 	}
+	if deps.Bridge != nil {
+		events = append(events, deps.Bridge)
+	}
+	if cfg.GRPCAddress != "" {
+		s.rpc = rpc.New(deps.Auth)
+		events = append(events, s.rpc) // gRPC watches
+	}
+	webDeps.Events = events
 	webHandler, err := web.New(webDeps, web.Config{
-		BaseURL:      cfg.BaseURL,
-		PageSize:     cfg.PageSize,
-		AuditEnabled: cfg.AuditEnabled && deps.AuditStore != nil,
+		BaseURL:        cfg.BaseURL,
+		PageSize:       cfg.PageSize,
+		AuditEnabled:   cfg.AuditEnabled && deps.AuditStore != nil,
+		FrameAncestors: cfg.FrameAncestors,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create web handler: %w", err)
@@ -128,12 +235,39 @@ func New(deps Deps, cfg Config) (*Server, error) {
 	s.webHandler = webHandler
 
 	// create api handler
-	// note: only set Events if SSE is not nil to avoid nil interface issue
-	apiDeps := api.Deps{Store: deps.Store, Auth: deps.Auth, Validator: deps.Validator, Git: deps.Git}
-	if deps.SSE != nil {
-		apiDeps.Events = deps.SSE
+	if s.envs, err = environ.New(cfg.Environments); err != nil {
+		return nil, fmt.Errorf("invalid environments: %w", err)
+	}
+	if s.faults, err = fault.New(cfg.FaultInjection); err != nil {
+		return nil, fmt.Errorf("invalid fault injection: %w", err)
+	}
+	s.shedder, err = shed.New(shed.Config{MaxConcurrent: s.maxConcurrent(), LowShare: s.shedLowShare(),
+		NormalShare: s.shedAPIShare(), Classify: requestPriority})
+	if err != nil {
+		return nil, fmt.Errorf("invalid load shedding limits: %w", err)
+	}
+	if cfg.TokenRequests > 0 {
+		s.tokenLimiter, err = ratelimit.New(ratelimit.Config{RequestsPerSec: cfg.TokenRequests, Burst: cfg.TokenBurst,
+			Credential: requestCredential})
+		if err != nil {
+			return nil, fmt.Errorf("invalid token rate limit: %w", err)
+		}
+	}
+	apiDeps := api.Deps{Store: deps.Store, Auth: deps.Auth, Validator: deps.Validator, Git: deps.Git,
+		Events: events, Snapshots: snapshots, Owners: owners, Envs: s.envs}
+	if cfg.Variants {
+		apiDeps.Variants = deps.Store
+	}
+	if cfg.History {
+		apiDeps.History = deps.Store
+	}
+	if historyAccess != nil {
+		apiDeps.HistoryAccess = historyAccess
 	}
 	s.apiHandler = api.New(apiDeps)
+	if cfg.ExpiryInterval > 0 {
+		s.reaper = expiry.New(deps.Store, deps.Git, events, cfg.ExpiryInterval)
+	}
 
 	// create audit handlers if audit is enabled
 	if cfg.AuditEnabled && deps.AuditStore != nil {
@@ -141,9 +275,53 @@ func New(deps Deps, cfg Config) (*Server, error) {
 		s.webAuditHandler = web.NewAuditHandler(deps.AuditStore, deps.Auth, webHandler)
 	}
 
+	// usage dashboard needs auth to tell admins apart, audit-based stats are optional
+	if deps.Auth != nil && deps.Auth.Enabled() {
+		var auditStats web.AuditStats
+		if cfg.AuditEnabled && deps.AuditStore != nil {
+			auditStats = deps.AuditStore
+		}
+		s.dashboardHandler = web.NewDashboardHandler(auditStats, deps.Auth, webHandler)
+	}
+
+	if deps.Sealer != nil {
+		s.unsealHandler = seal.NewHandler(deps.Sealer, deps.Auth)
+	}
+
+	// dead letters are managed by admins, so they need auth
+	if deps.Outbox != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		s.alertHandler = alert.NewHandler(deps.Outbox, deps.Auth)
+	}
+
+	// pseudonymization of users is an admin operation, usernames exist only with auth
+	if deps.Records != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		var gitHistory privacy.History
+		if deps.Git != nil {
+			gitHistory = deps.Git
+		}
+		s.privacyHandler = privacy.NewHandler(deps.Records, gitHistory, deps.Auth)
+	}
+
+	// freezes are set and lifted by admins, the store rejects writes of frozen keys regardless
+	if deps.Freezes != nil && deps.Auth != nil && deps.Auth.Enabled() {
+		s.freezeHandler = freeze.NewHandler(deps.Freezes, deps.Auth)
+	}
+
 	return s, nil
 }
 
+// publishers fans out key change events to multiple publishers.
+type publishers []interface {
+	Publish(key string, action enum.AuditAction)
+}
+
+// Publish sends the event to all publishers.
+func (p publishers) Publish(key string, action enum.AuditAction) {
+	for _, pub := range p {
+		pub.Publish(key, action)
+	}
+}
+
 // Run starts the HTTP server and blocks until context is canceled.
 func (s *Server) Run(ctx context.Context) error {
 	httpServer := &http.Server{
@@ -154,6 +332,15 @@ func (s *Server) Run(ctx context.Context) error {
 		IdleTimeout:       s.IdleTimeout,
 	}
 
+	if s.reaper != nil {
+		go s.reaper.Run(ctx)
+	}
+
+	grpcDone, err := s.runGRPC(ctx)
+	if err != nil {
+		return err
+	}
+
 	// graceful shutdown
 	go func() {
 		<-ctx.Done()
@@ -177,12 +364,63 @@ func (s *Server) Run(ctx context.Context) error {
 	}()
 
 	log.Printf("[DEBUG] started server on %s", s.Address)
-	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if s.TLSCert != "" {
+		httpServer.TLSConfig = s.tlsConfig()
+		err = httpServer.ListenAndServeTLS(s.TLSCert, s.TLSKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("server error: %w", err)
 	}
+	<-grpcDone
 	return nil
 }
 
+// runGRPC starts the gRPC API, if enabled, with the TLS certificate of the HTTP server. The returned channel
+// is closed when the gRPC server stopped, after the context is canceled.
+func (s *Server) runGRPC(ctx context.Context) (<-chan struct{}, error) {
+	done := make(chan struct{})
+	if s.rpc == nil {
+		close(done)
+		return done, nil
+	}
+
+	var tlsConfig *tls.Config
+	if s.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCert, s.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls certificate for grpc: %w", err)
+		}
+		tlsConfig = s.tlsConfig()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	lis, err := net.Listen("tcp", s.GRPCAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for grpc on %s: %w", s.GRPCAddress, err)
+	}
+
+	// calls go through the same routes as HTTP requests, with their own rate limiter
+	go func() {
+		defer close(done)
+		if err := s.rpc.Serve(ctx, lis, s.routes(), tlsConfig); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}()
+	return done, nil
+}
+
+// tlsConfig returns the TLS config of the server. With client CAs, clients may present certificates,
+// verified ones identify SPIFFE workloads, requests without a certificate use other auth methods.
+func (s *Server) tlsConfig() *tls.Config {
+	res := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.ClientCAs != nil {
+		res.ClientAuth = tls.VerifyClientCertIfGiven
+		res.ClientCAs = s.ClientCAs
+	}
+	return res
+}
+
 // handler returns the HTTP handler, wrapping routes with base URL support if configured.
 func (s *Server) handler() http.Handler {
 	routes := s.routes()
@@ -203,14 +441,22 @@ func (s *Server) handler() http.Handler {
 func (s *Server) routes() http.Handler {
 	router := routegroup.New(http.NewServeMux())
 
-	// global middleware (applies to all routes)
+	// signed requests get their token after the body is limited and before any token check
+	signedRequests := noopMiddleware
+	if s.Auth.SignedRequestsEnabled() {
+		signedRequests = s.Auth.SignatureMiddleware
+	}
+
+	// global middleware (applies to all routes), injected faults come first, the recoverer would answer drops
 	router.Use(
+		s.faults.Middleware,
 		rest.Recoverer(log.Default()),
 		rest.RealIP, // must be before rate limiting to limit by real client IP
 		s.rateLimiter(),
-		rest.Throttle(s.maxConcurrent()),
+		s.shedder.Middleware, // replaces a flat throttle, the web UI keeps capacity when API clients stampede
 		rest.Trace,
 		rest.SizeLimit(s.bodySizeLimit()),
+		signedRequests,
 		rest.AppInfo("stash", "umputun", s.Version),
 		rest.Ping,
 	)
@@ -223,29 +469,45 @@ func (s *Server) routes() http.Handler {
 	}
 
 	// public routes (no auth required)
-	router.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(s.staticFS))))
+	router.Handle("GET /static/", http.StripPrefix("/static/", s.webHandler.Assets()))
+	if s.Profiler {
+		// pprof and expvar for leak hunting, realip accepts only public addresses from headers, so loopback can't be spoofed
+		router.Handle("/debug/", http.StripPrefix("/debug", rest.Profiler("127.0.0.1", "::1")))
+	}
 	if s.Auth != nil && s.Auth.Enabled() {
-		s.webHandler.RegisterAuth(router)
-		// stricter throttle on login to prevent brute-force
-		s.webHandler.RegisterLogin(router, rest.Throttle(s.loginConcurrency()))
+		router.Group().Route(func(loginRouter *routegroup.Bundle) {
+			loginRouter.Use(s.webHandler.SecurityHeaders)
+			s.webHandler.RegisterAuth(loginRouter)
+			// stricter throttle on login to prevent brute-force
+			s.webHandler.RegisterLogin(loginRouter, rest.Throttle(s.loginConcurrency()))
+		})
 	}
 
 	// web UI routes (session auth)
 	router.Group().Route(func(webRouter *routegroup.Bundle) {
-		webRouter.Use(sessionAuth)
+		webRouter.Use(s.tokenLimiter.Middleware, s.webHandler.SecurityHeaders, sessionAuth)
 		s.webHandler.Register(webRouter)
 
 		// audit web UI routes (admin only, handled inside handler)
 		if s.webAuditHandler != nil {
 			webRouter.HandleFunc("GET /audit", s.webAuditHandler.HandleAuditPage)
 			webRouter.HandleFunc("GET /web/audit", s.webAuditHandler.HandleAuditTable)
+			webRouter.HandleFunc("GET /web/audit/export", s.webAuditHandler.HandleAuditExport)
+		}
+
+		// usage dashboard (admin only, handled inside handler)
+		if s.dashboardHandler != nil {
+			webRouter.HandleFunc("GET /dashboard", s.dashboardHandler.HandleDashboardPage)
 		}
 	})
 
 	// kv API routes (audit wraps auth to capture denied requests)
 	router.Mount("/kv").Route(func(kv *routegroup.Bundle) {
+		kv.Use(s.tokenLimiter.Middleware) // per-token limit on top of the per-IP one, before any store access
+		kv.Use(s.envs.Middleware)         // maps keys of ?env= to stored keys first, auth and audit check those
 		kv.Use(s.auditMiddleware())
 		kv.Use(tokenAuth)
+		kv.Use(s.reasons.Middleware)
 		s.apiHandler.Register(kv)
 
 		// SSE subscription endpoint (if enabled)
@@ -255,9 +517,62 @@ func (s *Server) routes() http.Handler {
 		}
 	})
 
-	// audit query route (admin only, requires auth)
+	// audit query, stats and prune routes (admin only, requires auth)
 	if s.auditHandler != nil {
 		router.HandleFunc("POST /audit/query", s.auditHandler.HandleQuery)
+		router.HandleFunc("GET /audit/stats", s.auditHandler.HandleStats)
+		router.HandleFunc("POST /audit/prune", s.auditHandler.HandlePrune)
+	}
+
+	// token exchange, named tokens mint short-lived child tokens
+	if s.Auth.ExchangeEnabled() {
+		router.HandleFunc("POST /auth/token", s.Auth.HandleTokenExchange)
+	}
+
+	// cloud identity login, verified cloud principals get short-lived tokens of their cloud role
+	if s.Auth.CloudEnabled() {
+		router.HandleFunc("POST /auth/cloud", s.Auth.HandleCloudLogin)
+	}
+
+	// last use of api tokens for finding stale tokens, reload of the auth file on this instance, admin only
+	if s.Auth != nil && s.Auth.Enabled() {
+		router.HandleFunc("GET /auth/tokens", s.Auth.HandleTokenUsage)
+		router.HandleFunc("POST /admin/auth/reload", s.Auth.HandleReload)
+		router.HandleFunc("GET /admin/auth/status", s.Auth.HandleReloadStatus)
+		// delegated tokens, admins and prefix admins manage tokens under their prefixes
+		router.HandleFunc("GET /auth/delegated-tokens", s.Auth.HandleDelegatedTokens)
+		router.HandleFunc("POST /auth/delegated-tokens", s.Auth.HandleCreateDelegatedToken)
+		router.HandleFunc("DELETE /auth/delegated-tokens/{fingerprint}", s.Auth.HandleRevokeDelegatedToken)
+	}
+
+	// passkey reset for users who lost their authenticators, admin only
+	if s.Auth.PasskeysEnabled() {
+		router.HandleFunc("DELETE /auth/passkeys/{username}", s.Auth.HandlePasskeyReset)
+	}
+
+	// unseal routes for sealed start, status is public, submitting shares is admin only
+	if s.unsealHandler != nil {
+		router.HandleFunc("GET /unseal", s.unsealHandler.HandleStatus)
+		router.HandleFunc("POST /unseal", s.unsealHandler.HandleUnseal)
+		router.HandleFunc("DELETE /unseal", s.unsealHandler.HandleReset)
+	}
+
+	// alert dead letters, admin only
+	if s.alertHandler != nil {
+		router.HandleFunc("GET /alerts/dead-letters", s.alertHandler.HandleDeadLetters)
+		router.HandleFunc("POST /alerts/dead-letters/{id}/redrive", s.alertHandler.HandleRedrive)
+	}
+
+	// user pseudonymization for erasure requests, admin only
+	if s.privacyHandler != nil {
+		router.HandleFunc("POST /privacy/pseudonymize", s.privacyHandler.HandlePseudonymize)
+	}
+
+	// prefix freezes for incidents, admin only
+	if s.freezeHandler != nil {
+		router.HandleFunc("GET /freezes", s.freezeHandler.HandleList)
+		router.HandleFunc("PUT /freezes/{prefix...}", s.freezeHandler.HandleFreeze)
+		router.HandleFunc("DELETE /freezes/{prefix...}", s.freezeHandler.HandleUnfreeze)
 	}
 
 	return router
@@ -287,6 +602,37 @@ func (s *Server) maxConcurrent() int64 {
 	return 1000
 }
 
+// shedLowShare returns the configured share of max concurrent for bulk requests, or default 0.5 if not set.
+func (s *Server) shedLowShare() float64 {
+	if s.ShedLowShare > 0 {
+		return s.ShedLowShare
+	}
+	return 0.5
+}
+
+// shedAPIShare returns the configured share of max concurrent for API requests, or default 0.8 if not set.
+func (s *Server) shedAPIShare() float64 {
+	if s.ShedAPIShare > 0 {
+		return s.ShedAPIShare
+	}
+	return 0.8
+}
+
+// requestPriority classifies requests for load shedding. Key lists, exports, imports and audit queries
+// are bulk work shed first, other API requests next, the web UI, login and ping are shed last.
+func requestPriority(r *http.Request) shed.Priority {
+	switch p := r.URL.Path; {
+	case p == "/kv/" && r.Method == http.MethodGet, p == "/kv/_export", p == "/kv/_import", p == "/audit/query",
+		p == "/web/audit/export":
+		return shed.Low
+	case strings.HasPrefix(p, "/kv/"), strings.HasPrefix(p, "/audit/"), strings.HasPrefix(p, "/auth/"),
+		strings.HasPrefix(p, "/alerts/"), strings.HasPrefix(p, "/privacy/"), strings.HasPrefix(p, "/admin/"), p == "/unseal":
+		return shed.Normal
+	default:
+		return shed.High
+	}
+}
+
 // loginConcurrency returns the configured login concurrency limit, or default 5 if not set.
 func (s *Server) loginConcurrency() int64 {
 	if s.LoginConcurrency > 0 {
@@ -296,12 +642,44 @@ func (s *Server) loginConcurrency() int64 {
 }
 
 // rateLimiter returns middleware that limits requests per second using tollbooth.
+// Responses carry the limit status in X-RateLimit-* headers, rejected ones also Retry-After.
 func (s *Server) rateLimiter() func(http.Handler) http.Handler {
 	lmt := tollbooth.NewLimiter(s.requestsPerSec(), &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour})
 	lmt.SetIPLookup(limiter.IPLookup{Name: "RemoteAddr", IndexFromRight: 0}) // use RemoteAddr (RealIP middleware sets it)
 	lmt.SetBurst(int(s.requestsPerSec()))                                    // burst equals rate limit
+	lmt.SetOnLimitReached(func(w http.ResponseWriter, _ *http.Request) {
+		setRateLimitHeaders(w.Header())
+		w.Header().Set("Retry-After", w.Header().Get("X-RateLimit-Reset"))
+	})
 	return func(next http.Handler) http.Handler {
-		return tollbooth.LimitHandler(lmt, next)
+		return tollbooth.LimitHandler(lmt, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setRateLimitHeaders(w.Header())
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// requestCredential returns the API token or the session of the request for per-token rate limits,
+// empty for anonymous requests. Signed requests carry the token by now, see auth.SignatureMiddleware.
+func requestCredential(r *http.Request) string {
+	if token := auth.ExtractToken(r); token != "" {
+		return token
+	}
+	for _, name := range cookie.SessionCookieNames {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// setRateLimitHeaders copies the RateLimit-* headers set by tollbooth to the X-RateLimit-* ones
+// most clients look for. Reset is in seconds.
+func setRateLimitHeaders(h http.Header) {
+	for _, name := range []string{"Limit", "Remaining", "Reset"} {
+		if v := h.Get("RateLimit-" + name); v != "" {
+			h.Set("X-RateLimit-"+name, v)
+		}
 	}
 }
 
@@ -315,10 +693,21 @@ func noopMiddleware(next http.Handler) http.Handler {
 	return next
 }
 
-// auditMiddleware returns the audit middleware or noop if audit is disabled.
+// auditMiddleware returns the audit middleware or noop if both audit and alerts are disabled.
+// With alerts only, entries are passed to the alert detector without being stored.
 func (s *Server) auditMiddleware() func(http.Handler) http.Handler {
+	var opts []audit.Option
+	if s.canaries != nil {
+		opts = append(opts, audit.WithCanaries(s.canaries))
+	}
+	if s.Alerts != nil {
+		opts = append(opts, audit.WithObserver(s.Alerts))
+	}
 	if !s.AuditEnabled || s.AuditStore == nil {
-		return audit.NoopMiddleware
+		if s.Alerts == nil {
+			return audit.NoopMiddleware
+		}
+		return audit.Middleware(nil, s.Auth, opts...)
 	}
-	return audit.Middleware(s.AuditStore, s.Auth)
+	return audit.Middleware(s.AuditStore, s.Auth, opts...)
 }
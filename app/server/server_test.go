@@ -3,21 +3,44 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/alert"
+	auditmocks "github.com/umputun/stash/app/server/audit/mocks"
 	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/internal/shed"
 	"github.com/umputun/stash/app/server/mocks"
+	"github.com/umputun/stash/app/server/rpc/pb"
 	"github.com/umputun/stash/app/store"
 	"github.com/umputun/stash/app/validator"
+	"github.com/umputun/stash/lib/stash"
 )
 
 func TestServer_HandleGet(t *testing.T) {
@@ -32,7 +55,7 @@ func TestServer_HandleGet(t *testing.T) {
 				return nil, "", store.ErrNotFound
 			}
 		},
-		ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc: listPageFunc(nil),
 	}
 	srv := newTestServer(t, st)
 
@@ -83,7 +106,7 @@ func TestServer_HandleGet_ContentType(t *testing.T) {
 		t.Run(tc.format, func(t *testing.T) {
 			st := &mocks.KVStoreMock{
 				GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("value"), tc.format, nil },
-				ListFunc:          func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+				ListPageFunc:      listPageFunc(nil),
 			}
 			srv := newTestServer(t, st)
 
@@ -100,8 +123,8 @@ func TestServer_HandleGet_ContentType(t *testing.T) {
 func TestServer_HandleSet(t *testing.T) {
 	t.Run("set new key", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -111,15 +134,15 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "newkey", st.SetCalls()[0].Key)
-		assert.Equal(t, []byte("newvalue"), st.SetCalls()[0].Value)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "newkey", st.SetWithOptionsCalls()[0].Key)
+		assert.Equal(t, []byte("newvalue"), st.SetWithOptionsCalls()[0].Value)
 	})
 
 	t.Run("update existing key", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return false, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -129,15 +152,15 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "existing", st.SetCalls()[0].Key)
-		assert.Equal(t, []byte("updated"), st.SetCalls()[0].Value)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "existing", st.SetWithOptionsCalls()[0].Key)
+		assert.Equal(t, []byte("updated"), st.SetWithOptionsCalls()[0].Value)
 	})
 
 	t.Run("set key with slashes", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -147,15 +170,15 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "a/b/c", st.SetCalls()[0].Key)
-		assert.Equal(t, []byte("nested"), st.SetCalls()[0].Value)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "a/b/c", st.SetWithOptionsCalls()[0].Key)
+		assert.Equal(t, []byte("nested"), st.SetWithOptionsCalls()[0].Value)
 	})
 
 	t.Run("valid format via header", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -166,14 +189,14 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "json", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "json", st.SetWithOptionsCalls()[0].Format)
 	})
 
 	t.Run("valid format via query param", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -183,32 +206,49 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "yaml", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "yaml", st.SetWithOptionsCalls()[0].Format)
 	})
 
-	t.Run("invalid format defaults to text", func(t *testing.T) {
+	t.Run("custom format stored by name", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
 		body := bytes.NewBufferString("value")
 		req := httptest.NewRequest(http.MethodPut, "/kv/config", body)
-		req.Header.Set("X-Stash-Format", "invalid-format")
+		req.Header.Set("X-Stash-Format", "msgpack")
 		rec := httptest.NewRecorder()
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "text", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "msgpack", st.SetWithOptionsCalls()[0].Format, "custom client formats are kept")
+	})
+
+	t.Run("malformed format rejected", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
+		}
+		srv := newTestServer(t, st)
+
+		body := bytes.NewBufferString("value")
+		req := httptest.NewRequest(http.MethodPut, "/kv/config", body)
+		req.Header.Set("X-Stash-Format", "Not A Format")
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, st.SetWithOptionsCalls())
 	})
 
 	t.Run("empty format defaults to text", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -218,16 +258,16 @@ func TestServer_HandleSet(t *testing.T) {
 		srv.routes().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "text", st.SetCalls()[0].Format)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "text", st.SetWithOptionsCalls()[0].Format)
 	})
 }
 
 func TestServer_HandleDelete(t *testing.T) {
 	t.Run("delete existing key", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			DeleteFunc: func(context.Context, string) error { return nil },
-			ListFunc:   func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			DeleteFunc:   func(context.Context, string) error { return nil },
+			ListPageFunc: listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -242,8 +282,8 @@ func TestServer_HandleDelete(t *testing.T) {
 
 	t.Run("delete nonexistent key returns 404", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			DeleteFunc: func(context.Context, string) error { return store.ErrNotFound },
-			ListFunc:   func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			DeleteFunc:   func(context.Context, string) error { return store.ErrNotFound },
+			ListPageFunc: listPageFunc(nil),
 		}
 		srv := newTestServer(t, st)
 
@@ -259,7 +299,7 @@ func TestServer_HandleDelete(t *testing.T) {
 
 func TestServer_Ping(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc: listPageFunc(nil),
 	}
 	srv := newTestServer(t, st)
 
@@ -271,10 +311,47 @@ func TestServer_Ping(t *testing.T) {
 	assert.Equal(t, "pong", rec.Body.String())
 }
 
+func TestServer_SecurityHeaders(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("v"), "text", nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	srv := newTestServer(t, st)
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-")
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/key", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"), "api responses are not pages")
+
+	_, err := New(Deps{Store: st, Validator: validator.NewService()}, Config{FrameAncestors: []string{"a b"}})
+	require.ErrorContains(t, err, "invalid frame ancestor")
+}
+
+func TestServer_Static(t *testing.T) {
+	srv := newTestServer(t, &mocks.KVStoreMock{})
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/"+srv.webHandler.Assets().Path("app.js"), http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.js", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+}
+
 func TestServer_HandleGet_InternalError(t *testing.T) {
 	st := &mocks.KVStoreMock{
 		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", errors.New("db error") },
-		ListFunc:          func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:      listPageFunc(nil),
 	}
 	srv := newTestServer(t, st)
 
@@ -289,8 +366,10 @@ func TestServer_HandleGet_InternalError(t *testing.T) {
 
 func TestServer_HandleSet_InternalError(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return false, errors.New("db error") },
-		ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
+			return false, errors.New("db error")
+		},
+		ListPageFunc: listPageFunc(nil),
 	}
 	srv := newTestServer(t, st)
 
@@ -300,14 +379,14 @@ func TestServer_HandleSet_InternalError(t *testing.T) {
 	srv.routes().ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	require.Len(t, st.SetCalls(), 1)
-	assert.Equal(t, "testkey", st.SetCalls()[0].Key)
+	require.Len(t, st.SetWithOptionsCalls(), 1)
+	assert.Equal(t, "testkey", st.SetWithOptionsCalls()[0].Key)
 }
 
 func TestServer_HandleDelete_InternalError(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		DeleteFunc: func(context.Context, string) error { return errors.New("db error") },
-		ListFunc:   func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		DeleteFunc:   func(context.Context, string) error { return errors.New("db error") },
+		ListPageFunc: listPageFunc(nil),
 	}
 	srv := newTestServer(t, st)
 
@@ -328,8 +407,8 @@ func TestServer_Handler_BaseURL(t *testing.T) {
 			}
 			return nil, "", store.ErrNotFound
 		},
-		SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-		ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		ListPageFunc:       listPageFunc(nil),
 	}
 
 	t.Run("without base URL routes work at root", func(t *testing.T) {
@@ -395,8 +474,8 @@ func TestServer_Handler_BaseURL(t *testing.T) {
 		srv.handler().ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		require.Len(t, st.SetCalls(), 1)
-		assert.Equal(t, "newkey", st.SetCalls()[0].Key)
+		require.Len(t, st.SetWithOptionsCalls(), 1)
+		assert.Equal(t, "newkey", st.SetWithOptionsCalls()[0].Key)
 	})
 }
 
@@ -407,6 +486,22 @@ func newTestServer(t *testing.T, st KVStore) *Server {
 	return srv
 }
 
+// listPageFunc returns a ListPage mock over keys, applying the prefix and allow filter the way the store does.
+func listPageFunc(keys []store.KeyInfo) func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+	return func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+		res := slices.DeleteFunc(slices.Clone(keys), func(k store.KeyInfo) bool { return !strings.HasPrefix(k.Key, q.Prefix) })
+		if q.Allow != nil {
+			names := make([]string, len(res))
+			for i, k := range res {
+				names[i] = k.Key
+			}
+			allowed := q.Allow(names)
+			res = slices.DeleteFunc(res, func(k store.KeyInfo) bool { return !slices.Contains(allowed, k.Key) })
+		}
+		return res, len(res), nil
+	}
+}
+
 // testSessionStore creates an in-memory SQLite store for testing session operations.
 func testSessionStore(t *testing.T) *store.Store {
 	t.Helper()
@@ -417,12 +512,12 @@ func testSessionStore(t *testing.T) *store.Store {
 }
 
 // testAuthService creates an auth service from config string for testing.
-func testAuthService(t *testing.T, authConfig string) *auth.Service {
+func testAuthService(t *testing.T, authConfig string, opts ...auth.Option) *auth.Service {
 	t.Helper()
 	tmpDir := t.TempDir()
 	authFile := tmpDir + "/auth.yml"
 	require.NoError(t, os.WriteFile(authFile, []byte(authConfig), 0o600))
-	svc, err := auth.New(authFile, time.Hour, false, testSessionStore(t), nil)
+	svc, err := auth.New(authFile, time.Hour, false, testSessionStore(t), nil, opts...)
 	require.NoError(t, err)
 	return svc
 }
@@ -437,7 +532,7 @@ func TestServer_HandleList(t *testing.T) {
 
 	t.Run("list all keys without auth", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv := newTestServer(t, st)
 
@@ -453,7 +548,7 @@ func TestServer_HandleList(t *testing.T) {
 
 	t.Run("list keys with prefix filter", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv := newTestServer(t, st)
 
@@ -469,7 +564,7 @@ func TestServer_HandleList(t *testing.T) {
 
 	t.Run("list empty keys", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return []store.KeyInfo{}, nil },
+			ListPageFunc: listPageFunc([]store.KeyInfo{}),
 		}
 		srv := newTestServer(t, st)
 
@@ -483,8 +578,8 @@ func TestServer_HandleList(t *testing.T) {
 
 	t.Run("list returns internal error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) {
-				return nil, errors.New("db error")
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+				return nil, 0, errors.New("db error")
 			},
 		}
 		srv := newTestServer(t, st)
@@ -497,6 +592,465 @@ func TestServer_HandleList(t *testing.T) {
 	})
 }
 
+func TestServer_SnapshotHeaders(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc:       listPageFunc(nil),
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		DeleteFunc:         func(context.Context, string) error { return nil },
+	}
+	srv := newTestServer(t, st)
+
+	list := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+		if token != "" {
+			req.Header.Set("X-Stash-Snapshot", token)
+		}
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	token := list("").Header().Get("X-Stash-Snapshot")
+	require.NotEmpty(t, token)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/kv/app/db/host", bytes.NewBufferString("h")),
+		httptest.NewRequest(http.MethodDelete, "/kv/top", http.NoBody),
+	} {
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, r)
+		require.Less(t, rec.Code, 300)
+	}
+
+	rec := list(token)
+	assert.Equal(t, "app/db/,top", rec.Header().Get("X-Stash-Changed-Prefixes"))
+	latest := rec.Header().Get("X-Stash-Snapshot")
+	assert.NotEqual(t, token, latest)
+
+	rec = list(latest)
+	assert.Equal(t, []string{""}, rec.Header().Values("X-Stash-Changed-Prefixes"), "nothing changed")
+}
+
+func TestServer_AlertsWithoutAudit(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
+		ListPageFunc:      listPageFunc(nil),
+	}
+	var observed []store.AuditEntry
+	alerts := &auditmocks.ObserverMock{ObserveFunc: func(e store.AuditEntry, _ bool) { observed = append(observed, e) }}
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Alerts: alerts}, Config{Version: "test"})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/secrets/missing", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	require.Len(t, observed, 1, "alerts get audit entries even with audit logging disabled")
+	assert.Equal(t, "secrets/missing", observed[0].Key)
+	assert.Equal(t, enum.AuditResultNotFound, observed[0].Result)
+
+	t.Run("canary keys", func(t *testing.T) {
+		observed = nil
+		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Alerts: alerts},
+			Config{Version: "test", Canaries: []string{"honeypot/*"}})
+		require.NoError(t, err)
+
+		for _, path := range []string{"/kv/honeypot/aws", "/kv/app/aws"} {
+			srv.routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		}
+		require.Len(t, observed, 2)
+		assert.Equal(t, enum.AuditActionCanary, observed[0].Action)
+		assert.Equal(t, enum.AuditActionRead, observed[1].Action)
+	})
+}
+
+func TestServer_JustifiedKeys(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("v"), "text", nil },
+		ListPageFunc:      listPageFunc(nil),
+	}
+	var observed []store.AuditEntry
+	alerts := &auditmocks.ObserverMock{ObserveFunc: func(e store.AuditEntry, _ bool) { observed = append(observed, e) }}
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Alerts: alerts},
+		Config{Version: "test", Justify: []string{"prod/*"}})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/prod/db", http.NoBody))
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/kv/prod/db", http.NoBody)
+	req.Header.Set("X-Stash-Reason", "INC-42")
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/dev/db", http.NoBody))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	require.Len(t, observed, 3)
+	assert.Equal(t, enum.AuditResultDenied, observed[0].Result, "missing reason audited as denied")
+	assert.Equal(t, enum.AuditResultSuccess, observed[1].Result)
+	assert.Equal(t, "INC-42", observed[1].Reason)
+	assert.Empty(t, observed[2].Reason)
+}
+
+func TestServer_OwnerDelete(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "alpha-team"
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "bravo-team"
+    permissions:
+      - prefix: "*"
+        access: rw
+  - token: "admin"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc},
+		Config{Version: "test", OwnerDelete: true})
+	require.NoError(t, err)
+
+	do := func(method, key, token string) int {
+		req := httptest.NewRequest(method, "/kv/"+key, strings.NewReader("v"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "app/a", "alpha-team"))
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "app/b", "alpha-team"))
+	info, err := st.GetInfo(t.Context(), "app/a")
+	require.NoError(t, err)
+	assert.Equal(t, "token:alph****", info.Owner)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "app/a", "bravo-team"), "updates follow prefix ACL")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "app/a", "bravo-team"))
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "app/a", "alpha-team"))
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "app/b", "admin"))
+}
+
+func TestServer_AlertDeadLetters(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "reader-token"
+    permissions:
+      - prefix: "*"
+        access: r
+  - token: "admin"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	id, err := st.EnqueueDelivery(t.Context(), []byte(`{"rule":"canary_read"}`))
+	require.NoError(t, err)
+	require.NoError(t, st.FailDelivery(t.Context(), id, "webhook down", time.Now(), true))
+	outbox := alert.NewOutbox(st, nil, alert.OutboxConfig{})
+
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc, Outbox: outbox}, Config{Version: "test"})
+	require.NoError(t, err)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/alerts/dead-letters", "reader-token").Code)
+	rec := do(http.MethodGet, "/alerts/dead-letters", "admin")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"last_error":"webhook down"`)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, fmt.Sprintf("/alerts/dead-letters/%d/redrive", id), "admin").Code)
+	due, err := st.DueDeliveries(t.Context(), time.Now().Add(time.Second), 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 1)
+
+	t.Run("no routes without auth", func(t *testing.T) {
+		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Outbox: outbox}, Config{Version: "test"})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/alerts/dead-letters", http.NoBody))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestServer_Pseudonymize(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "admin"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	require.NoError(t, st.LogAudit(t.Context(), store.AuditEntry{Timestamp: time.Now(), Action: enum.AuditActionRead, Key: "app/a",
+		Actor: "alice", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess, IP: "10.0.0.1"}))
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc, Records: st}, Config{Version: "test"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/privacy/pseudonymize", strings.NewReader(`{"username":"alice","confirm":"alice"}`))
+	req.Header.Set("Authorization", "Bearer admin")
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"audit_entries":1`)
+
+	_, total, err := st.QueryAudit(t.Context(), store.AuditQuery{Actor: "alice"})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestServer_Environments(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "app-team"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+  - token: "prod-ops"
+    permissions:
+      - prefix: "@prod/*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc},
+		Config{Version: "test", Environments: []string{"staging", "prod:staging"}})
+	require.NoError(t, err)
+
+	do := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/db", "app-team", "base").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/name", "app-team", "svc").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/kv/app/db?env=prod", "app-team", "x").Code,
+		"default environment permissions don't cover prod")
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/db?env=prod", "prod-ops", "prod").Code)
+	value, err := st.Get(t.Context(), "@prod/app/db")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", string(value))
+
+	rec := do(http.MethodGet, "/kv/app/db?env=prod", "prod-ops", "")
+	assert.Equal(t, "prod", rec.Body.String())
+	rec = do(http.MethodGet, "/kv/app/name?env=prod", "prod-ops", "")
+	assert.Equal(t, "svc", rec.Body.String(), "inherited from the default environment")
+	assert.Empty(t, rec.Header().Get("X-Stash-Env"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/kv/app/db", "prod-ops", "").Code)
+	assert.Equal(t, "base", do(http.MethodGet, "/kv/app/db", "app-team", "").Body.String())
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/kv/app/db?env=dev", "app-team", "").Code)
+
+	rec = do(http.MethodGet, "/kv/?env=prod", "prod-ops", "")
+	assert.Contains(t, rec.Body.String(), `"key":"app/db"`)
+	assert.Contains(t, rec.Body.String(), `"key":"app/name"`)
+	assert.NotContains(t, do(http.MethodGet, "/kv/", "app-team", "").Body.String(), "@prod")
+
+	_, err = New(Deps{Store: st, Validator: validator.NewService()}, Config{Environments: []string{"prod:qa"}})
+	require.ErrorContains(t, err, "invalid environments")
+}
+
+func TestServer_FaultInjection(t *testing.T) {
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService()},
+		Config{Version: "test", FaultInjection: []string{"/kv/:error-rate=1", "/kv/dropped/:drop-rate=1"}})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/kv/app/db")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "error", resp.Header.Get("X-Stash-Fault"))
+
+	resp, err = http.Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "routes without rules are not affected")
+
+	_, err = http.Get(ts.URL + "/kv/dropped/key") //nolint:bodyclose // no response on a dropped connection
+	require.Error(t, err, "connection is dropped without a response")
+
+	_, err = New(Deps{Store: st, Validator: validator.NewService()}, Config{FaultInjection: []string{"kv:error-rate=1"}})
+	require.ErrorContains(t, err, "invalid fault injection")
+}
+
+func TestServer_ExpiringKeys(t *testing.T) {
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test", ExpiryInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.NotNil(t, srv.reaper)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go srv.reaper.Run(ctx)
+
+	req := httptest.NewRequest(http.MethodPut, "/kv/flags/override", strings.NewReader("on"))
+	req.Header.Set("X-Stash-TTL", "200ms")
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	info, err := st.GetInfo(t.Context(), "flags/override")
+	require.NoError(t, err)
+	require.NotNil(t, info.ExpiresAt)
+
+	require.Eventually(t, func() bool {
+		keys, err := st.List(t.Context(), enum.SecretsFilterAll) // lists keys expired but not deleted yet
+		require.NoError(t, err)
+		return len(keys) == 0
+	}, 2*time.Second, 20*time.Millisecond, "expired key is deleted by the reaper")
+
+	srv, err = New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test"})
+	require.NoError(t, err)
+	assert.Nil(t, srv.reaper, "no reaper without interval")
+}
+
+func TestServer_StoreHistory(t *testing.T) {
+	st, err := store.New(":memory:", store.WithHistory(10))
+	require.NoError(t, err)
+	defer st.Close()
+	srv, err := New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test", History: true})
+	require.NoError(t, err)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/mode", "blue").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/kv/app/mode", "green").Code)
+
+	rec := do(http.MethodGet, "/kv/history/app/mode", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var history []struct {
+		Version int64  `json:"version"`
+		Value   []byte `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history, 1)
+	assert.Equal(t, "blue", string(history[0].Value))
+
+	rollback := fmt.Sprintf("/kv/app/mode?rollback=%d", history[0].Version)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, rollback, "").Code)
+	assert.Equal(t, "blue", do(http.MethodGet, "/kv/app/mode", "").Body.String())
+
+	srv, err = New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/kv/history/app/mode", "").Code, "history not enabled")
+}
+
+func TestServer_Profiler(t *testing.T) {
+	get := func(srv *Server, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", http.NoBody)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+	srv, err := New(Deps{Store: &mocks.KVStoreMock{}, Validator: validator.NewService()}, Config{Version: "test", Profiler: true})
+	require.NoError(t, err)
+	rec := get(srv, "127.0.0.1:12345")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile: total")
+	assert.Equal(t, http.StatusForbidden, get(srv, "192.0.2.1:12345").Code, "remote clients are rejected")
+
+	srv, err = New(Deps{Store: &mocks.KVStoreMock{}, Validator: validator.NewService()}, Config{Version: "test"})
+	require.NoError(t, err)
+	assert.NotContains(t, get(srv, "127.0.0.1:12345").Body.String(), "goroutine profile", "disabled by default")
+}
+
+func TestServer_ExportImport(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "reader"
+    permissions:
+      - prefix: "app/*"
+        access: r
+  - token: "admin"
+    admin: true
+    permissions:
+      - prefix: "*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{Version: "test"})
+	require.NoError(t, err)
+
+	do := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/name", "admin", "svc").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/db/host", "admin", "localhost").Code)
+
+	rec := do(http.MethodGet, "/kv/_export", "reader", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	exported := rec.Body.String()
+	assert.Contains(t, exported, `"key":"app/name"`)
+	assert.NotContains(t, exported, "db/host", "export is limited to readable keys")
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/kv/_import", "reader", exported).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/kv/app/name", "admin", "changed").Code)
+	rec = do(http.MethodPost, "/kv/_import", "admin", exported)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"created":0,"updated":1,"skipped":0,"deleted":0}`, rec.Body.String())
+	assert.Equal(t, "svc", do(http.MethodGet, "/kv/app/name", "reader", "").Body.String())
+}
+
+func TestServer_Variants(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "reader"
+    permissions:
+      - prefix: "app/*"
+        access: r
+  - token: "writer"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+`)
+	st := testSessionStore(t)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{Version: "test", Variants: true})
+	require.NoError(t, err)
+
+	do := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Stash-Attributes", "region=eu")
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	spec := `{"variants":[{"name":"eu","value":"hallo","match":{"region":"eu"}}]}`
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/kv/app/greeting", "writer", "hello").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/kv/app/greeting?variants", "reader", spec).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/kv/app/greeting?variants", "writer", spec).Code)
+
+	rec := do(http.MethodGet, "/kv/app/greeting", "reader", "")
+	assert.Equal(t, "hallo", rec.Body.String())
+	assert.Equal(t, "eu", rec.Header().Get("X-Stash-Variant"))
+
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/kv/app/greeting?override=caller:token:read****", "writer", "hi").Code)
+	assert.Equal(t, "hi", do(http.MethodGet, "/kv/app/greeting", "reader", "").Body.String(), "override of the reader token")
+	assert.Equal(t, "hallo", do(http.MethodGet, "/kv/app/greeting", "writer", "").Body.String())
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/kv/app/greeting?variants", "writer", "").Code)
+	assert.Equal(t, "hello", do(http.MethodGet, "/kv/app/greeting", "reader", "").Body.String())
+}
+
 func TestServer_HandleList_WithAuth(t *testing.T) {
 	now := time.Now()
 	testKeys := []store.KeyInfo{
@@ -514,7 +1068,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 `
 		authSvc := testAuthService(t, authConfig)
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{
 			Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test",
@@ -541,7 +1095,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 `
 		authSvc := testAuthService(t, authConfig)
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{
 			Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test",
@@ -567,7 +1121,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 `
 		authSvc := testAuthService(t, authConfig)
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{
 			Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test",
@@ -575,7 +1129,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 		require.NoError(t, err)
 
 		// create session for user
-		sessionToken, err := authSvc.CreateSession(t.Context(), "dbadmin")
+		sessionToken, err := authSvc.CreateSession(t.Context(), "dbadmin", false)
 		require.NoError(t, err)
 
 		req := httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
@@ -598,7 +1152,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 `
 		authSvc := testAuthService(t, authConfig)
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{
 			Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test",
@@ -630,7 +1184,7 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 `
 		authSvc := testAuthService(t, authConfig)
 		st := &mocks.KVStoreMock{
-			ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return testKeys, nil },
+			ListPageFunc: listPageFunc(testKeys),
 		}
 		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: authSvc}, Config{
 			Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test",
@@ -650,9 +1204,128 @@ func TestServer_HandleList_WithAuth(t *testing.T) {
 	})
 }
 
+func TestServer_TokenExchange(t *testing.T) {
+	now := time.Now()
+	testKeys := []store.KeyInfo{
+		{Key: "app/config", Size: 100, Format: "json", CreatedAt: now, UpdatedAt: now},
+		{Key: "app/db/url", Size: 50, Format: "text", CreatedAt: now, UpdatedAt: now},
+	}
+	authConfig := `tokens:
+  - token: "parenttoken"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+`
+	newServer := func(t *testing.T, opts ...auth.Option) *Server {
+		t.Helper()
+		st := &mocks.KVStoreMock{ListPageFunc: listPageFunc(testKeys)}
+		srv, err := New(Deps{Store: st, Validator: validator.NewService(), Auth: testAuthService(t, authConfig, opts...)},
+			Config{Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test"})
+		require.NoError(t, err)
+		return srv
+	}
+
+	t.Run("exchanged token lists its keys", func(t *testing.T) {
+		srv := newServer(t, auth.WithTokenExchange([]byte("0123456789abcdef0123456789abcdef"), time.Hour))
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/token",
+			strings.NewReader(`{"permissions":[{"prefix":"app/db/*","access":"r"}]}`))
+		req.Header.Set("Authorization", "Bearer parenttoken")
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp auth.ExchangeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		req = httptest.NewRequest(http.MethodGet, "/kv/", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+resp.Token)
+		rec = httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"key":"app/db/url"`)
+		assert.NotContains(t, rec.Body.String(), `"key":"app/config"`)
+	})
+
+	t.Run("exchange disabled", func(t *testing.T) {
+		srv := newServer(t)
+		req := httptest.NewRequest(http.MethodPost, "/auth/token", http.NoBody)
+		req.Header.Set("Authorization", "Bearer parenttoken")
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		assert.NotEqual(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestServer_SPIFFEWorkload(t *testing.T) {
+	// trust bundle CA and workload X.509 SVID
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "mesh ca"}, IsCA: true,
+		BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign, NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour)}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	svidKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	spiffeID, err := url.Parse("spiffe://mesh.example/ns/prod/sa/billing")
+	require.NoError(t, err)
+	svidTmpl := &x509.Certificate{SerialNumber: big.NewInt(2), URIs: []*url.URL{spiffeID},
+		KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	svidDER, err := x509.CreateCertificate(rand.Reader, svidTmpl, caCert, &svidKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	authConfig := `workloads:
+  - spiffe_id: "spiffe://mesh.example/ns/prod/sa/billing"
+    permissions:
+      - prefix: "billing/*"
+        access: r
+`
+	st := &mocks.KVStoreMock{
+		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
+			return []byte("value of " + key), "text", nil
+		},
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	srv, err := New(Deps{Store: st, Validator: validator.NewService(),
+		Auth: testAuthService(t, authConfig, auth.WithSPIFFE("mesh.example"))},
+		Config{Address: ":8080", ReadTimeout: 5 * time.Second, Version: "test", ClientCAs: pool})
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(srv.routes())
+	ts.TLS = srv.tlsConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(t *testing.T, certs []tls.Certificate, path string) int {
+		t.Helper()
+		tr := ts.Client().Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = certs
+		client := &http.Client{Transport: tr}
+		resp, err := client.Get(ts.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	svid := []tls.Certificate{{Certificate: [][]byte{svidDER}, PrivateKey: svidKey}}
+
+	t.Run("workload with svid", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get(t, svid, "/kv/billing/db"))
+		assert.Equal(t, http.StatusForbidden, get(t, svid, "/kv/other"))
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get(t, nil, "/kv/billing/db"))
+	})
+}
+
 func TestServer_LimitHelpers(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc: func(_ context.Context, _ enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc: listPageFunc(nil),
 	}
 
 	t.Run("defaults when not configured", func(t *testing.T) {
@@ -664,6 +1337,8 @@ func TestServer_LimitHelpers(t *testing.T) {
 		assert.InDelta(t, 100.0, srv.requestsPerSec(), 0.001)
 		assert.Equal(t, int64(1000), srv.maxConcurrent())
 		assert.Equal(t, int64(5), srv.loginConcurrency())
+		assert.InDelta(t, 0.5, srv.shedLowShare(), 0.001)
+		assert.InDelta(t, 0.8, srv.shedAPIShare(), 0.001)
 	})
 
 	t.Run("uses configured values", func(t *testing.T) {
@@ -675,11 +1350,257 @@ func TestServer_LimitHelpers(t *testing.T) {
 			RequestsPerSec:   50.5,
 			MaxConcurrent:    200,
 			LoginConcurrency: 3,
+			ShedLowShare:     0.25,
+			ShedAPIShare:     0.9,
 		})
 		require.NoError(t, err)
 		assert.Equal(t, int64(500), srv.bodySizeLimit())
 		assert.InDelta(t, 50.5, srv.requestsPerSec(), 0.001)
 		assert.Equal(t, int64(200), srv.maxConcurrent())
 		assert.Equal(t, int64(3), srv.loginConcurrency())
+		assert.InDelta(t, 0.25, srv.shedLowShare(), 0.001)
+		assert.InDelta(t, 0.9, srv.shedAPIShare(), 0.001)
+	})
+
+	t.Run("rejects bulk share above api share", func(t *testing.T) {
+		_, err := New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test", ShedLowShare: 0.9})
+		require.ErrorContains(t, err, "invalid load shedding limits")
+	})
+}
+
+func TestServer_RateLimitHeaders(t *testing.T) {
+	srv, err := New(Deps{Store: &mocks.KVStoreMock{}, Validator: validator.NewService()},
+		Config{Version: "test", RequestsPerSec: 2})
+	require.NoError(t, err)
+	handler := srv.routes()
+	ping := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := ping()
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	ping()
+	rec = ping()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func TestServer_TokenRateLimit(t *testing.T) {
+	srv, err := New(Deps{Store: testSessionStore(t), Validator: validator.NewService()},
+		Config{Version: "test", TokenRequests: 1, TokenBurst: 2})
+	require.NoError(t, err)
+	handler := srv.routes()
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/kv/app/db", "token-a").Code)
+	assert.Equal(t, http.StatusNotFound, get("/kv/app/db", "token-a").Code)
+	rec := get("/kv/app/db", "token-a")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/web/keys", "token-a").Code, "web routes share the bucket")
+
+	assert.Equal(t, http.StatusNotFound, get("/kv/app/db", "token-b").Code, "other tokens are not affected")
+	assert.Equal(t, http.StatusOK, get("/ping", "token-a").Code, "only kv and web routes are limited per token")
+	for range 3 {
+		assert.Equal(t, http.StatusNotFound, get("/kv/app/db", "").Code, "anonymous requests are limited per ip")
+	}
+}
+
+func TestServer_RequestPriority(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         shed.Priority
+	}{
+		{method: http.MethodGet, path: "/kv/", want: shed.Low},
+		{method: http.MethodGet, path: "/kv/?prefix=app/&format=ndjson", want: shed.Low},
+		{method: http.MethodGet, path: "/kv/_export?prefix=app/", want: shed.Low},
+		{method: http.MethodPost, path: "/kv/_import", want: shed.Low},
+		{method: http.MethodPost, path: "/audit/query", want: shed.Low},
+		{method: http.MethodGet, path: "/web/audit/export", want: shed.Low},
+		{method: http.MethodGet, path: "/kv/app/config", want: shed.Normal},
+		{method: http.MethodPut, path: "/kv/app/config", want: shed.Normal},
+		{method: http.MethodGet, path: "/kv/history/app/config", want: shed.Normal},
+		{method: http.MethodGet, path: "/kv/subscribe/app/*", want: shed.Normal},
+		{method: http.MethodPost, path: "/auth/token", want: shed.Normal},
+		{method: http.MethodPost, path: "/admin/auth/reload", want: shed.Normal},
+		{method: http.MethodPost, path: "/unseal", want: shed.Normal},
+		{method: http.MethodGet, path: "/", want: shed.High},
+		{method: http.MethodGet, path: "/web/keys", want: shed.High},
+		{method: http.MethodGet, path: "/web/keys/export", want: shed.High},
+		{method: http.MethodGet, path: "/audit", want: shed.High},
+		{method: http.MethodPost, path: "/login", want: shed.High},
+		{method: http.MethodGet, path: "/ping", want: shed.High},
+	}
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, requestPriority(httptest.NewRequest(tc.method, tc.path, http.NoBody)))
+		})
+	}
+}
+
+func TestServer_ReadAfterWrite(t *testing.T) {
+	st := testSessionStore(t)
+	newInstance := func() *Server {
+		cached, err := store.NewCached(st, 100) // instances share the database, each has its own cache
+		require.NoError(t, err)
+		srv, err := New(Deps{Store: cached, Validator: validator.NewService()}, Config{Version: "test"})
+		require.NoError(t, err)
+		return srv
+	}
+	a, b := newInstance(), newInstance()
+	do := func(srv *Server, method, url, body string, hdr ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		for i := 0; i+1 < len(hdr); i += 2 {
+			req.Header.Set(hdr[i], hdr[i+1])
+		}
+		rec := httptest.NewRecorder()
+		srv.routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := do(a, http.MethodPut, "/kv/app/config", "v1")
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "v1", do(b, http.MethodGet, "/kv/app/config", "").Body.String(), "cached by b")
+
+	time.Sleep(1100 * time.Millisecond) // b's cache entry is older than the margin for clock differences
+	rec := do(a, http.MethodPut, "/kv/app/config", "v2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	token := rec.Header().Get("X-Stash-Version")
+	require.NotEmpty(t, token)
+	assert.Greater(t, token, first.Header().Get("X-Stash-Version"))
+
+	assert.Equal(t, "v1", do(b, http.MethodGet, "/kv/app/config", "").Body.String(), "stale without the token")
+	assert.Equal(t, "v2", do(b, http.MethodGet, "/kv/app/config", "", "X-Stash-Min-Version", token).Body.String())
+	assert.Equal(t, "v2", do(b, http.MethodGet, "/kv/app/config", "").Body.String(), "cache refreshed")
+	assert.Equal(t, http.StatusBadRequest, do(b, http.MethodGet, "/kv/app/config?min_version=abc", "").Code)
+}
+
+func TestServer_ListNDJSON(t *testing.T) {
+	st := testSessionStore(t)
+	for i := range 1100 {
+		_, err := st.Set(t.Context(), fmt.Sprintf("bulk/key-%04d", i), []byte("v"), "text")
+		require.NoError(t, err)
+	}
+	srv, err := New(Deps{Store: st, Validator: validator.NewService()}, Config{Version: "test"})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	c, err := stash.New(ts.URL)
+	require.NoError(t, err)
+	seen := map[string]bool{}
+	err = c.ListStream(t.Context(), "bulk/", func(k stash.KeyInfo) error {
+		assert.False(t, seen[k.Key], "duplicate %s", k.Key)
+		seen[k.Key] = true
+		return nil
 	})
+	require.NoError(t, err)
+	assert.Len(t, seen, 1100)
+}
+
+func TestServer_SignedRequests(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "signing-token"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+`, auth.WithSignedRequests(time.Minute))
+	srv, err := New(Deps{Store: testSessionStore(t), Validator: validator.NewService(), Auth: authSvc},
+		Config{Version: "test", BaseURL: "/stash"})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	c, err := stash.New(ts.URL+"/stash", stash.WithToken("signing-token"), stash.WithRequestSigning(), stash.WithRetry(0, 0))
+	require.NoError(t, err)
+	require.NoError(t, c.Set(t.Context(), "app/name", "value"))
+	value, err := c.Get(t.Context(), "app/name")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	require.ErrorIs(t, c.Set(t.Context(), "db/host", "value"), stash.ErrForbidden, "signed requests get the permissions of the token")
+
+	other, err := stash.New(ts.URL+"/stash", stash.WithToken("guessed-token"), stash.WithRequestSigning(), stash.WithRetry(0, 0))
+	require.NoError(t, err)
+	_, err = other.Get(t.Context(), "app/name")
+	require.ErrorIs(t, err, stash.ErrUnauthorized)
+}
+
+func TestServer_GRPC(t *testing.T) {
+	authSvc := testAuthService(t, `tokens:
+  - token: "grpc-token"
+    permissions:
+      - prefix: "app/*"
+        access: rw
+`)
+	srv, err := New(Deps{Store: testSessionStore(t), Validator: validator.NewService(), Auth: authSvc},
+		Config{Version: "test", GRPCAddress: "127.0.0.1:0"})
+	require.NoError(t, err)
+	require.NotNil(t, srv.rpc)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, srv.rpc.Serve(ctx, lis, srv.routes(), nil))
+	}()
+	defer func() { cancel(); <-done }()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewKVClient(conn)
+	authCtx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer grpc-token")
+
+	stream, err := client.Watch(authCtx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.WatchRequest{Add: []string{"app/*"}}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	setResp, err := client.Set(authCtx, &pb.SetRequest{Key: "app/config", Value: []byte(`{"a":1}`), Format: "json"})
+	require.NoError(t, err)
+	assert.True(t, setResp.GetCreated())
+	event, err := stream.Recv()
+	require.NoError(t, err, "changes made over grpc are published to watchers")
+	assert.Equal(t, "app/config", event.GetEvent().GetKey())
+	assert.Equal(t, pb.Action_ACTION_CREATE, event.GetEvent().GetAction())
+
+	getResp, err := client.Get(authCtx, &pb.GetRequest{Key: "app/config"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":1}`, string(getResp.GetValue()))
+	assert.Equal(t, "json", getResp.GetFormat())
+
+	listResp, err := client.List(authCtx, &pb.ListRequest{})
+	require.NoError(t, err)
+	require.Len(t, listResp.GetKeys(), 1)
+	assert.Equal(t, "json", listResp.GetKeys()[0].GetFormat())
+
+	_, err = client.Set(authCtx, &pb.SetRequest{Key: "db/host", Value: []byte("x")})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "same permissions as the http api")
+	_, err = client.Get(t.Context(), &pb.GetRequest{Key: "app/config"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.Delete(authCtx, &pb.DeleteRequest{Key: "app/missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Delete(authCtx, &pb.DeleteRequest{Key: "app/config"})
+	require.NoError(t, err)
 }
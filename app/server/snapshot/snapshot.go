@@ -0,0 +1,143 @@
+// Package snapshot tracks recent key changes so clients can ask what changed since their last read.
+// Responses carry an opaque snapshot token; a client echoing the token back learns which
+// keys changed after it was issued and can refresh only the affected prefixes instead of
+// reloading everything or holding a watch connection open.
+package snapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+// defaults for the change log
+const (
+	DefaultMaxChanges = 10000
+	DefaultTTL        = 10 * time.Minute
+)
+
+// Tracker keeps a bounded, in-memory log of key changes. Tokens are valid as long as the
+// log still covers every change made after they were issued, i.e. they are short-lived:
+// a token expires once changes following it are evicted by size or age, or the server restarts.
+type Tracker struct {
+	id         string // random instance id, invalidates tokens issued before restart
+	maxChanges int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	seq     uint64    // sequence number of the latest change
+	evicted uint64    // highest sequence number dropped from the log
+	covered time.Time // changes before this time are unknown (tracker start or last evicted change)
+	log     []change  // ordered by seq
+}
+
+type change struct {
+	seq uint64
+	key string
+	ts  time.Time
+}
+
+// New creates a tracker retaining up to maxChanges changes for at most ttl.
+// Non-positive values select the defaults.
+func New(maxChanges int, ttl time.Duration) *Tracker {
+	if maxChanges <= 0 {
+		maxChanges = DefaultMaxChanges
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return &Tracker{id: hex.EncodeToString(b), maxChanges: maxChanges, ttl: ttl, covered: time.Now()}
+}
+
+// Publish records a key change. Implements the event publisher interface used by api and web handlers.
+func (t *Tracker) Publish(key string, _ enum.AuditAction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.seq++
+	t.log = append(t.log, change{seq: t.seq, key: key, ts: now})
+
+	// evict by size and age, keeping track of the last dropped change
+	drop := max(len(t.log)-t.maxChanges, 0)
+	for drop < len(t.log) && now.Sub(t.log[drop].ts) > t.ttl {
+		drop++
+	}
+	if drop > 0 {
+		t.evicted, t.covered = t.log[drop-1].seq, t.log[drop-1].ts
+		t.log = append(t.log[:0:0], t.log[drop:]...)
+	}
+}
+
+// Token returns the token for the current state.
+func (t *Tracker) Token() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.id + "-" + strconv.FormatUint(t.seq, 10)
+}
+
+// ChangedSince returns keys changed after the token was issued, without duplicates.
+// ok is false if the token is malformed, issued by another server instance or already expired;
+// the caller can't tell what changed and should refresh everything.
+func (t *Tracker) ChangedSince(token string) (keys []string, ok bool) {
+	id, seqStr, found := strings.Cut(token, "-")
+	if !found || id != t.id {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq > t.seq || seq < t.evicted {
+		return nil, false
+	}
+	seen := map[string]bool{}
+	for _, c := range t.log {
+		if c.seq <= seq || seen[c.key] {
+			continue
+		}
+		seen[c.key] = true
+		keys = append(keys, c.key)
+	}
+	return keys, true
+}
+
+// LastModified returns the time of the latest change to a key with the given prefix, including deletes.
+// Changes older than the log are unknown, so the result is never earlier than the log coverage start.
+func (t *Tracker) LastModified(prefix string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.log) - 1; i >= 0; i-- {
+		if strings.HasPrefix(t.log[i].key, prefix) {
+			return t.log[i].ts
+		}
+	}
+	return t.covered
+}
+
+// Prefixes reduces keys to their parent prefixes, e.g. "app/db/" for "app/db/host".
+// Top-level keys have no parent and are returned as is. Order of first appearance is kept.
+func Prefixes(keys []string) []string {
+	seen := map[string]bool{}
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		p := k
+		if i := strings.LastIndex(k, "/"); i >= 0 {
+			p = k[:i+1]
+		}
+		if !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
+	return res
+}
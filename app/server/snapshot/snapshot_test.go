@@ -0,0 +1,103 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestTracker_ChangedSince(t *testing.T) {
+	tr := New(0, 0)
+	initial := tr.Token()
+
+	keys, ok := tr.ChangedSince(initial)
+	require.True(t, ok)
+	assert.Empty(t, keys)
+
+	tr.Publish("app/db/host", enum.AuditActionCreate)
+	tr.Publish("app/db/port", enum.AuditActionUpdate)
+	mid := tr.Token()
+	tr.Publish("app/db/host", enum.AuditActionUpdate)
+	tr.Publish("top", enum.AuditActionDelete)
+
+	keys, ok = tr.ChangedSince(initial)
+	require.True(t, ok)
+	assert.Equal(t, []string{"app/db/host", "app/db/port", "top"}, keys)
+
+	keys, ok = tr.ChangedSince(mid)
+	require.True(t, ok)
+	assert.Equal(t, []string{"app/db/host", "top"}, keys)
+
+	keys, ok = tr.ChangedSince(tr.Token())
+	require.True(t, ok)
+	assert.Empty(t, keys)
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		for _, token := range []string{"", "garbage", tr.id + "-x", tr.id + "-100", "deadbeef-1", New(0, 0).Token()} {
+			_, ok := tr.ChangedSince(token)
+			assert.False(t, ok, token)
+		}
+	})
+}
+
+func TestTracker_Eviction(t *testing.T) {
+	t.Run("by size", func(t *testing.T) {
+		tr := New(2, time.Hour)
+		initial := tr.Token()
+		tr.Publish("a", enum.AuditActionCreate)
+		afterA := tr.Token()
+		tr.Publish("b", enum.AuditActionCreate)
+		tr.Publish("c", enum.AuditActionCreate)
+
+		_, ok := tr.ChangedSince(initial)
+		assert.False(t, ok, "change a was evicted, token can't be served")
+
+		keys, ok := tr.ChangedSince(afterA)
+		require.True(t, ok)
+		assert.Equal(t, []string{"b", "c"}, keys)
+	})
+
+	t.Run("by age", func(t *testing.T) {
+		tr := New(100, 10*time.Millisecond)
+		initial := tr.Token()
+		tr.Publish("a", enum.AuditActionCreate)
+		time.Sleep(20 * time.Millisecond)
+		afterA := tr.Token()
+		tr.Publish("b", enum.AuditActionCreate)
+
+		_, ok := tr.ChangedSince(initial)
+		assert.False(t, ok)
+		keys, ok := tr.ChangedSince(afterA)
+		require.True(t, ok)
+		assert.Equal(t, []string{"b"}, keys)
+		assert.Len(t, tr.log, 1)
+	})
+}
+
+func TestTracker_LastModified(t *testing.T) {
+	tr := New(2, time.Hour)
+	start := tr.LastModified("app/")
+	assert.False(t, start.IsZero(), "unknown history is treated as changed at tracker start")
+
+	tr.Publish("app/a", enum.AuditActionCreate)
+	afterA := tr.LastModified("app/")
+	assert.True(t, afterA.After(start) || afterA.Equal(start))
+	assert.Equal(t, afterA, tr.LastModified(""))
+	assert.Equal(t, start, tr.LastModified("other/"))
+
+	time.Sleep(time.Millisecond)
+	tr.Publish("other/b", enum.AuditActionDelete)
+	tr.Publish("other/c", enum.AuditActionDelete)
+	assert.Equal(t, afterA, tr.LastModified("app/"), "evicted change still bounds the result")
+	assert.True(t, tr.LastModified("other/").After(afterA))
+}
+
+func TestPrefixes(t *testing.T) {
+	assert.Equal(t, []string{"app/db/", "app/", "top"},
+		Prefixes([]string{"app/db/host", "app/name", "app/db/port", "top"}))
+	assert.Empty(t, Prefixes(nil))
+}
@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// defaults for the journal
+const (
+	DefaultJournalSize = 10000
+	DefaultJournalTTL  = 10 * time.Minute
+)
+
+// journal keeps recently published messages and replays them to subscribers reconnecting with
+// Last-Event-ID, implements sse.Replayer. Message ids are "<instance>-<seq>" with seq counting
+// published messages, so ids issued by another instance or before a restart are never taken for known ones.
+// Subscribers with an id the journal can't resume from get a "reset" event instead.
+type journal struct {
+	id      string // random instance id
+	maxSize int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	seq     uint64 // sequence number of the latest message
+	evicted uint64 // highest sequence number dropped from the journal
+	entries []journalEntry
+}
+
+type journalEntry struct {
+	seq    uint64
+	msg    *sse.Message
+	topics []string
+	ts     time.Time
+}
+
+// newJournal creates a journal retaining up to maxSize messages for at most ttl.
+func newJournal(maxSize int, ttl time.Duration) *journal {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return &journal{id: hex.EncodeToString(b), maxSize: maxSize, ttl: ttl}
+}
+
+// Put assigns the next id to a copy of the message and records it.
+func (j *journal) Put(msg *sse.Message, topics []string) (*sse.Message, error) {
+	if len(topics) == 0 {
+		return nil, sse.ErrNoTopic
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	msg = msg.Clone()
+	msg.ID = sse.ID(j.id + "-" + strconv.FormatUint(j.seq, 10))
+	now := time.Now()
+	j.entries = append(j.entries, journalEntry{seq: j.seq, msg: msg, topics: topics, ts: now})
+	j.evict(now)
+	return msg, nil
+}
+
+// Replay sends messages published after the subscriber's Last-Event-ID to the matching topics,
+// or a "reset" event if the id is unknown or the messages after it are evicted already.
+func (j *journal) Replay(sub sse.Subscription) error {
+	if !sub.LastEventID.IsSet() {
+		return nil
+	}
+	j.mu.Lock()
+	j.evict(time.Now())
+	seq, ok := j.resumeFrom(sub.LastEventID.String())
+	var msgs []*sse.Message
+	for _, e := range j.entries {
+		if ok && e.seq > seq && topicsIntersect(sub.Topics, e.topics) {
+			msgs = append(msgs, e.msg)
+		}
+	}
+	j.mu.Unlock()
+
+	if !ok {
+		reset, err := message("reset", map[string]string{"timestamp": time.Now().UTC().Format(time.RFC3339)})
+		if err != nil {
+			return err
+		}
+		msgs = []*sse.Message{reset}
+	}
+	for _, m := range msgs {
+		if err := sub.Client.Send(m); err != nil {
+			return err //nolint:wrapcheck // returned to the sse provider as is
+		}
+	}
+	return sub.Client.Flush() //nolint:wrapcheck // returned to the sse provider as is
+}
+
+// resumeFrom parses the last event id, ok is false if the journal can't tell what was missed after it.
+func (j *journal) resumeFrom(lastID string) (seq uint64, ok bool) {
+	id, seqStr, found := strings.Cut(lastID, "-")
+	if !found || id != j.id {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || seq > j.seq || seq < j.evicted {
+		return 0, false
+	}
+	return seq, true
+}
+
+// evict drops messages over the size limit and older than ttl, caller holds the lock.
+func (j *journal) evict(now time.Time) {
+	drop := max(len(j.entries)-j.maxSize, 0)
+	for drop < len(j.entries) && now.Sub(j.entries[drop].ts) > j.ttl {
+		drop++
+	}
+	if drop > 0 {
+		j.evicted = j.entries[drop-1].seq
+		j.entries = append(j.entries[:0:0], j.entries[drop:]...)
+	}
+}
+
+// topicsIntersect reports whether the subscriber topics include any of the message topics.
+func topicsIntersect(subTopics, msgTopics []string) bool {
+	for _, t := range msgTopics {
+		if slices.Contains(subTopics, t) {
+			return true
+		}
+	}
+	return false
+}
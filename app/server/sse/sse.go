@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
@@ -23,6 +24,9 @@ type AuthProvider interface {
 	FilterKeysForRequest(r *http.Request, keys []string) []string
 }
 
+// maxBatch limits the number of events in a single coalesced "changes" message.
+const maxBatch = 1000
+
 // Event represents a key change event sent to subscribers.
 type Event struct {
 	Key       string           `json:"key"`
@@ -32,14 +36,33 @@ type Event struct {
 
 // Service handles SSE subscriptions for key change events.
 type Service struct {
-	server *sse.Server
-	auth   AuthProvider
+	server  *sse.Server
+	auth    AuthProvider
+	journal *journal // recent messages replayed to reconnecting subscribers
+
+	window  time.Duration // coalescing window, 0 publishes events immediately
+	mu      sync.Mutex
+	pending map[string]*Event // coalesced events by key, published when the window ends
+	order   []string          // pending keys in the order of their first change
+	timer   *time.Timer
+}
+
+// Option configures the SSE service.
+type Option func(*Service)
+
+// WithCoalesce enables coalescing of events within the window, see Publish.
+func WithCoalesce(window time.Duration) Option {
+	return func(s *Service) { s.window = window }
 }
 
 // New creates a new SSE service.
-func New(auth AuthProvider) *Service {
-	s := &Service{auth: auth}
+func New(auth AuthProvider, opts ...Option) *Service {
+	s := &Service{auth: auth, journal: newJournal(DefaultJournalSize, DefaultJournalTTL)}
+	for _, opt := range opts {
+		opt(s)
+	}
 	s.server = &sse.Server{
+		Provider:  &sse.Joe{Replayer: s.journal},
 		OnSession: s.onSession,
 	}
 	return s
@@ -106,36 +129,126 @@ func (s *Service) onSession(w http.ResponseWriter, r *http.Request) ([]string, b
 }
 
 // Publish sends a key change event to all matching subscribers.
-// It publishes to the exact key topic and all prefix topics.
+// It publishes to the exact key topic and all prefix topics. Every message gets an id, and subscribers
+// reconnecting with Last-Event-ID get the messages they missed replayed from the journal.
+// With coalescing, events are collected until the window started by the first of them ends, see flush.
 func (s *Service) Publish(key string, action enum.AuditAction) {
 	event := Event{
 		Key:       key,
 		Action:    action,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
+	if s.window <= 0 {
+		s.publish(event)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = map[string]*Event{}
+	}
+	prev, ok := s.pending[key]
+	if !ok {
+		s.pending[key] = &event
+		s.order = append(s.order, key)
+	} else {
+		// the last action wins, except a key created in this window stays created
+		if prev.Action == enum.AuditActionCreate && action == enum.AuditActionUpdate {
+			event.Action = enum.AuditActionCreate
+		}
+		*prev = event
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.flush)
+	}
+}
 
-	data, err := json.Marshal(event)
+// publish sends a single event to the exact key topic and all prefix topics.
+func (s *Service) publish(event Event) {
+	msg, err := message("change", event)
 	if err != nil {
 		log.Printf("[WARN] sse: failed to marshal event: %v", err)
 		return
 	}
 
-	msg := &sse.Message{}
-	msg.AppendData(string(data))
-	msg.Type = sse.Type("change")
+	// a single message for all topics, so it gets one id and is journaled once
+	topics := keyToTopics(event.Key)
+	if err := s.server.Publish(msg, topics...); err != nil {
+		log.Printf("[WARN] sse: failed to publish event for %q: %v", event.Key, err)
+	}
+	log.Printf("[DEBUG] sse: published %s event for %q to %d topics", event.Action.String(), event.Key, len(topics))
+}
+
+// flush publishes coalesced events. A topic with a single changed key gets the usual "change" event,
+// a topic with more keys gets "changes" events with lists of up to maxBatch events each,
+// so a bulk import sends a few messages to every subscriber instead of one per key.
+func (s *Service) flush() {
+	s.mu.Lock()
+	events := make([]Event, 0, len(s.order))
+	for _, key := range s.order {
+		events = append(events, *s.pending[key])
+	}
+	s.pending, s.order, s.timer = nil, nil, nil
+	s.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	byTopic := map[string][]Event{}
+	var topics []string
+	for _, e := range events {
+		for _, topic := range keyToTopics(e.Key) {
+			if _, ok := byTopic[topic]; !ok {
+				topics = append(topics, topic)
+			}
+			byTopic[topic] = append(byTopic[topic], e)
+		}
+	}
 
-	// publish to exact key topic and all prefix topics
-	topics := keyToTopics(key)
 	for _, topic := range topics {
-		if err := s.server.Publish(msg, topic); err != nil {
-			log.Printf("[WARN] sse: failed to publish to topic %q: %v", topic, err)
+		topicEvents := byTopic[topic]
+		for len(topicEvents) > 0 {
+			batch := topicEvents[:min(len(topicEvents), maxBatch)]
+			topicEvents = topicEvents[len(batch):]
+			var msg *sse.Message
+			var err error
+			if len(batch) == 1 {
+				msg, err = message("change", batch[0])
+			} else {
+				msg, err = message("changes", batch)
+			}
+			if err != nil {
+				log.Printf("[WARN] sse: failed to marshal events: %v", err)
+				continue
+			}
+			if err := s.server.Publish(msg, topic); err != nil {
+				log.Printf("[WARN] sse: failed to publish to topic %q: %v", topic, err)
+			}
 		}
 	}
-	log.Printf("[DEBUG] sse: published %s event for %q to %d topics", action.String(), key, len(topics))
+	log.Printf("[DEBUG] sse: published %d coalesced events to %d topics", len(events), len(topics))
 }
 
-// Shutdown gracefully shuts down the SSE server.
+// message makes an SSE message of the given type with JSON data.
+func message(typ string, data any) (*sse.Message, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s event: %w", typ, err)
+	}
+	msg := &sse.Message{Type: sse.Type(typ)}
+	msg.AppendData(string(b))
+	return msg, nil
+}
+
+// Shutdown gracefully shuts down the SSE server, pending coalesced events are published first.
 func (s *Service) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+	s.flush()
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("shutdown sse server: %w", err)
 	}
@@ -1,15 +1,20 @@
 package sse
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tmaxmax/go-sse"
 
+	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/server/sse/mocks"
 )
 
@@ -208,3 +213,177 @@ func TestService_Shutdown_WithActiveConnection(t *testing.T) {
 		t.Fatal("connection goroutine did not complete after shutdown")
 	}
 }
+
+func TestService_Coalesce(t *testing.T) {
+	svc := New(nil, WithCoalesce(50*time.Millisecond))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("key", r.URL.Query().Get("key"))
+		svc.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	prefixSub, keySub := subscribe(t.Context(), server.URL+"?key=app/*", ""), subscribe(t.Context(), server.URL+"?key=app/a", "")
+	time.Sleep(50 * time.Millisecond) // let subscriptions establish
+
+	svc.Publish("app/a", enum.AuditActionCreate)
+	svc.Publish("app/a", enum.AuditActionUpdate)
+	svc.Publish("app/b", enum.AuditActionUpdate)
+	svc.Publish("app/b", enum.AuditActionDelete)
+	svc.Publish("web/x", enum.AuditActionUpdate)
+
+	msg := next(t, prefixSub)
+	assert.Equal(t, "changes", msg.typ)
+	var events []Event
+	require.NoError(t, json.Unmarshal([]byte(msg.data), &events))
+	require.Len(t, events, 2)
+	assert.Equal(t, "app/a", events[0].Key)
+	assert.Equal(t, enum.AuditActionCreate, events[0].Action, "update after create stays create")
+	assert.Equal(t, "app/b", events[1].Key)
+	assert.Equal(t, enum.AuditActionDelete, events[1].Action)
+
+	msg = next(t, keySub)
+	assert.Equal(t, "change", msg.typ, "single key in a topic gets a regular event")
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(msg.data), &event))
+	assert.Equal(t, "app/a", event.Key)
+	assert.Equal(t, enum.AuditActionCreate, event.Action)
+
+	t.Run("shutdown publishes pending events", func(t *testing.T) {
+		svc.Publish("app/a", enum.AuditActionDelete)
+		svc.mu.Lock()
+		assert.Len(t, svc.pending, 1)
+		svc.mu.Unlock()
+		require.NoError(t, svc.Shutdown(t.Context()))
+		svc.mu.Lock()
+		defer svc.mu.Unlock()
+		assert.Empty(t, svc.pending)
+		assert.Nil(t, svc.timer)
+	})
+}
+
+func TestService_Resume(t *testing.T) {
+	svc := New(nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("key", r.URL.Query().Get("key"))
+		svc.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	sub := subscribe(ctx, server.URL+"?key=app/*", "")
+	time.Sleep(50 * time.Millisecond) // let subscription establish
+	svc.Publish("app/a", enum.AuditActionCreate)
+	first := next(t, sub)
+	require.Equal(t, "change", first.typ)
+	require.NotEmpty(t, first.id)
+	cancel() // connection drops, events below are missed
+
+	svc.Publish("app/b", enum.AuditActionUpdate)
+	svc.Publish("web/x", enum.AuditActionUpdate)
+	svc.Publish("app/c", enum.AuditActionDelete)
+
+	t.Run("replays missed events", func(t *testing.T) {
+		resumed := subscribe(t.Context(), server.URL+"?key=app/*", first.id)
+		var keys []string
+		for range 2 {
+			msg := next(t, resumed)
+			assert.Equal(t, "change", msg.typ)
+			var event Event
+			require.NoError(t, json.Unmarshal([]byte(msg.data), &event))
+			keys = append(keys, event.Key)
+		}
+		assert.Equal(t, []string{"app/b", "app/c"}, keys, "only missed events of the topic, in order")
+		svc.Publish("app/d", enum.AuditActionCreate)
+		msg := next(t, resumed)
+		assert.Equal(t, "change", msg.typ)
+		assert.Contains(t, msg.data, `"key":"app/d"`, "live events follow the replay")
+	})
+
+	t.Run("unknown id gets reset", func(t *testing.T) {
+		for _, id := range []string{"other-1", svc.journal.id + "-999", "garbage"} {
+			msg := next(t, subscribe(t.Context(), server.URL+"?key=app/*", id))
+			assert.Equal(t, "reset", msg.typ, id)
+			assert.Contains(t, msg.data, `"timestamp"`)
+		}
+	})
+}
+
+func TestJournal_Evict(t *testing.T) {
+	j := newJournal(2, time.Hour)
+	msg := &sse.Message{Type: sse.Type("change")}
+	msg.AppendData("{}")
+	for range 3 {
+		m, err := j.Put(msg, []string{"app/"})
+		require.NoError(t, err)
+		assert.NotEqual(t, msg.ID, m.ID, "put returns a copy with id")
+	}
+	require.Len(t, j.entries, 2)
+	assert.Equal(t, uint64(1), j.evicted)
+
+	_, ok := j.resumeFrom(j.id + "-0")
+	assert.False(t, ok, "first message after it is evicted")
+	seq, ok := j.resumeFrom(j.id + "-1")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), seq)
+	_, ok = j.resumeFrom(j.id + "-4")
+	assert.False(t, ok, "not issued yet")
+
+	j.entries[0].ts = time.Now().Add(-2 * time.Hour)
+	j.evict(time.Now())
+	require.Len(t, j.entries, 1, "expired message dropped")
+	assert.Equal(t, uint64(2), j.evicted)
+
+	_, err := j.Put(msg, nil)
+	require.ErrorIs(t, err, sse.ErrNoTopic)
+}
+
+type sseMessage struct{ id, typ, data string }
+
+// subscribe streams messages of the subscription, the response comes with the first message only.
+// The stream ends with the context.
+func subscribe(ctx context.Context, url, lastEventID string) <-chan sseMessage {
+	ch := make(chan sseMessage, 10)
+	go func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			return
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		var msg sseMessage
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				msg.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				msg.typ = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				msg.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && msg.data != "":
+				ch <- msg
+				msg = sseMessage{}
+			}
+		}
+	}()
+	return ch
+}
+
+// next waits for the next message of the subscription.
+func next(t *testing.T, ch <-chan sseMessage) sseMessage {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return sseMessage{}
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// handleKeyAccess renders the access modal listing every user and token that can read or write the key,
+// resolved from the auth config. Shown to admins and the owner of the key.
+func (h *Handler) handleKeyAccess(w http.ResponseWriter, r *http.Request) {
+	key := store.NormalizeKey(r.PathValue("key"))
+	if h.Access == nil {
+		http.Error(w, "access view not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	username := h.getCurrentUser(r)
+	info, err := h.Store.GetInfo(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "key not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[ERROR] failed to get key info: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !h.accessVisible(username, info) {
+		http.Error(w, "access of the key is shown to admins and the key owner only", http.StatusForbidden)
+		return
+	}
+
+	list, err := h.Access.KeyAccess(r.Context(), key)
+	if err != nil {
+		log.Printf("[ERROR] failed to resolve access to %s: %v", key, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[DEBUG] access of %s viewed by %s, %d entries", key, username, len(list.Entries))
+
+	data := templateData{
+		Key:        key,
+		Theme:      h.getTheme(r),
+		BaseURL:    h.BaseURL,
+		Username:   username,
+		accessData: accessData{AccessVisible: true, Access: list.Entries, AccessPolicy: list.Policy},
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "access", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
+
+// accessVisible checks if the user can see who has access to the key: admins and the user who created it.
+func (h *Handler) accessVisible(username string, info store.KeyInfo) bool {
+	if h.Access == nil || username == "" {
+		return false
+	}
+	return h.Auth.IsAdmin(username) || info.Owner == "user:"+username
+}
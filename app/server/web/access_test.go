@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandleKeyAccess(t *testing.T) {
+	user := "alice"
+	authMock := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return true },
+		GetSessionUserFunc:      func(context.Context, string) (string, bool) { return user, user != "" },
+		IsAdminFunc:             func(username string) bool { return username == "alice" },
+		CheckUserPermissionFunc: func(string, string, bool) bool { return true },
+	}
+	st := &mocks.KVStoreMock{
+		GetInfoFunc: func(_ context.Context, key string) (store.KeyInfo, error) {
+			if key == "missing" {
+				return store.KeyInfo{}, store.ErrNotFound
+			}
+			return store.KeyInfo{Key: key, Owner: "user:bob"}, nil
+		},
+		GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("v"), "text", nil },
+	}
+	resolver := &mocks.AccessResolverMock{
+		KeyAccessFunc: func(_ context.Context, key string) (auth.KeyAccessList, error) {
+			if key == "broken" {
+				return auth.KeyAccessList{}, errors.New("db error")
+			}
+			return auth.KeyAccessList{Policy: true, Entries: []auth.KeyAccess{
+				{Type: "user", Name: "alice", Read: true, Write: true, Admin: true},
+				{Type: "token", Name: "depl****", Read: true},
+				{Type: "user", Name: "carol", Read: true, Write: true, BreakGlass: true},
+			}}, nil
+		},
+	}
+	h, err := New(Deps{Store: st, Auth: authMock, Validator: defaultValidatorMock(), Access: resolver}, Config{})
+	require.NoError(t, err)
+	get := func(handler http.HandlerFunc, path, key string) *httptest.ResponseRecorder {
+		req := prefsRequest(http.MethodGet, path+key, "")
+		req.SetPathValue("key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("admin sees access", func(t *testing.T) {
+		user = "alice"
+		rec := get(h.handleKeyAccess, "/web/keys/access/", "billing/db")
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "Access: billing/db")
+		assert.Contains(t, body, "depl****")
+		assert.Contains(t, body, "read, write")
+		assert.Contains(t, body, "break-glass")
+		assert.Contains(t, body, "authorization policy")
+	})
+
+	t.Run("owner sees access", func(t *testing.T) {
+		user = "bob"
+		assert.Equal(t, http.StatusOK, get(h.handleKeyAccess, "/web/keys/access/", "billing/db").Code)
+		assert.Contains(t, get(h.handleKeyView, "/web/keys/view/", "billing/db").Body.String(), "/web/keys/access/billing%2Fdb")
+	})
+
+	t.Run("others are rejected", func(t *testing.T) {
+		user = "carol"
+		assert.Equal(t, http.StatusForbidden, get(h.handleKeyAccess, "/web/keys/access/", "billing/db").Code)
+		assert.NotContains(t, get(h.handleKeyView, "/web/keys/view/", "billing/db").Body.String(), "/web/keys/access/")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		user = "alice"
+		assert.Equal(t, http.StatusNotFound, get(h.handleKeyAccess, "/web/keys/access/", "missing").Code)
+		assert.Equal(t, http.StatusInternalServerError, get(h.handleKeyAccess, "/web/keys/access/", "broken").Code)
+	})
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Assets serves static files, each also available under a content-hash name like app.3f2a9c1b4d.js.
+// Templates link to hashed names, so those are cached for a year and a new build changes the links,
+// while plain names are revalidated by ETag on every use.
+type Assets struct {
+	files  map[string]assetFile // by original name
+	hashed map[string]string    // hashed name to original name
+}
+
+type assetFile struct {
+	data      []byte
+	hashed    string // content-hash file name
+	integrity string // subresource integrity value, sha384-<base64>
+	etag      string
+}
+
+// NewAssets loads and fingerprints all files of the given filesystem.
+func NewAssets(fsys fs.FS) (*Assets, error) {
+	a := &Assets{files: map[string]assetFile{}, hashed: map[string]string{}}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		sum := sha512.Sum384(data)
+		ext := path.Ext(name)
+		f := assetFile{
+			data:      data,
+			hashed:    strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:5]) + ext,
+			integrity: "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+			etag:      `"` + hex.EncodeToString(sum[:8]) + `"`,
+		}
+		a.files[name] = f
+		a.hashed[f.hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load static assets: %w", err)
+	}
+	return a, nil
+}
+
+// Path returns the content-hash name of a static file, or the name itself for unknown files.
+func (a *Assets) Path(name string) string {
+	if f, ok := a.files[name]; ok {
+		return f.hashed
+	}
+	return name
+}
+
+// Integrity returns the subresource integrity value of a static file, empty for unknown files.
+func (a *Assets) Integrity(name string) string {
+	return a.files[name].integrity
+}
+
+// ServeHTTP serves a static file by its plain or content-hash name, the path is relative to the static root.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	cacheControl := "no-cache"
+	if orig, ok := a.hashed[name]; ok {
+		name, cacheControl = orig, "public, max-age=31536000, immutable"
+	}
+	f, ok := a.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", f.etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.data))
+}
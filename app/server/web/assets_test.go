@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssets(t *testing.T) {
+	a, err := NewAssets(fstest.MapFS{
+		"app.js":       {Data: []byte("console.log('v1')")},
+		"htmx.min.js":  {Data: []byte("var htmx")},
+		"img/logo.svg": {Data: []byte("<svg/>")},
+	})
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^app\.[0-9a-f]{10}\.js$`, a.Path("app.js"))
+	assert.Regexp(t, `^htmx\.min\.[0-9a-f]{10}\.js$`, a.Path("htmx.min.js"))
+	assert.Regexp(t, `^img/logo\.[0-9a-f]{10}\.svg$`, a.Path("img/logo.svg"))
+	assert.Equal(t, "missing.js", a.Path("missing.js"))
+	assert.Regexp(t, `^sha384-[A-Za-z0-9+/]{64}$`, a.Integrity("app.js"))
+	assert.Empty(t, a.Integrity("missing.js"))
+
+	t.Run("content hash changes with content", func(t *testing.T) {
+		other, err := NewAssets(fstest.MapFS{"app.js": {Data: []byte("console.log('v2')")}})
+		require.NoError(t, err)
+		assert.NotEqual(t, a.Path("app.js"), other.Path("app.js"))
+		assert.NotEqual(t, a.Integrity("app.js"), other.Integrity("app.js"))
+	})
+
+	t.Run("hashed name cached for long", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+a.Path("app.js"), http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "console.log('v1')", rec.Body.String())
+		assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+		assert.Contains(t, rec.Header().Get("Content-Type"), "javascript")
+	})
+
+	t.Run("plain name revalidated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app.js", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		etag := rec.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		req := httptest.NewRequest(http.MethodGet, "/app.js", http.NoBody)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		a.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+	})
+
+	t.Run("unknown and stale names", func(t *testing.T) {
+		for _, p := range []string{"/missing.js", "/app.0000000000.js", "/", "/img"} {
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, http.NoBody))
+			assert.Equal(t, http.StatusNotFound, rec.Code, p)
+		}
+	})
+}
+
+func TestHandler_TemplatesLinkHashedAssets(t *testing.T) {
+	h := newTestHandler(t)
+	rec := httptest.NewRecorder()
+	h.handleLoginForm(rec, httptest.NewRequest(http.MethodGet, "/login", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `href="/static/`+h.assets.Path("style.css")+`"`)
+	assert.Regexp(t, regexp.MustCompile(`integrity="sha384-[^"]+"`), body)
+	assert.NotContains(t, body, `/static/style.css"`)
+}
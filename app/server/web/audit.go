@@ -8,12 +8,18 @@ import (
 	"strings"
 	"time"
 
+	log "github.com/go-pkgz/lgr"
+
 	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/internal/inventory"
 	"github.com/umputun/stash/app/store"
 )
 
 //go:generate moq -out mocks/auditstore.go -pkg mocks -skip-ensure -fmt goimports . AuditStore
 
+// auditExportBatch is the number of entries read per query by the CSV export
+const auditExportBatch = 1000
+
 // AuditStore defines the interface for audit log queries.
 type AuditStore interface {
 	QueryAudit(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, int, error)
@@ -65,6 +71,7 @@ type auditTemplateData struct {
 	Theme       enum.Theme
 	AuthEnabled bool
 	BaseURL     string
+	CSPNonce    string // nonce for inline scripts, full page only
 	Error       string
 }
 
@@ -86,6 +93,7 @@ func (h *AuditHandler) HandleAuditPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := h.buildAuditData(r)
+	data.CSPNonce = cspNonce(r.Context())
 	if err := h.parent.tmpl.ExecuteTemplate(w, "audit.html", data); err != nil {
 		http.Error(w, "template error", http.StatusInternalServerError)
 	}
@@ -113,55 +121,12 @@ func (h *AuditHandler) HandleAuditTable(w http.ResponseWriter, r *http.Request)
 func (h *AuditHandler) buildAuditData(r *http.Request) auditTemplateData {
 	q := r.URL.Query()
 
-	// parse filter params
-	key := q.Get("key")
-	actor := q.Get("actor")
-	action := q.Get("action")
-	result := q.Get("result")
-	actorType := q.Get("actor_type")
-	from := q.Get("from")
-	to := q.Get("to")
 	page, _ := strconv.Atoi(q.Get("page"))
 	if page < 1 {
 		page = 1
 	}
-
-	// build query
-	query := store.AuditQuery{
-		Key:    key,
-		Actor:  actor,
-		Limit:  h.pageSize,
-		Offset: (page - 1) * h.pageSize,
-	}
-
-	// parse enums
-	if action != "" && action != "all" {
-		if a, err := enum.ParseAuditAction(action); err == nil {
-			query.Action = a
-		}
-	}
-	if result != "" && result != "all" {
-		if res, err := enum.ParseAuditResult(result); err == nil {
-			query.Result = res
-		}
-	}
-	if actorType != "" && actorType != "all" {
-		if at, err := enum.ParseActorType(actorType); err == nil {
-			query.ActorType = at
-		}
-	}
-
-	// parse timestamps
-	if from != "" {
-		if t, err := time.Parse("2006-01-02T15:04", from); err == nil {
-			query.From = t
-		}
-	}
-	if to != "" {
-		if t, err := time.Parse("2006-01-02T15:04", to); err == nil {
-			query.To = t
-		}
-	}
+	query := auditQuery(q)
+	query.Limit, query.Offset = h.pageSize, (page-1)*h.pageSize
 
 	// execute query
 	entries, total, err := h.store.QueryAudit(r.Context(), query)
@@ -203,13 +168,13 @@ func (h *AuditHandler) buildAuditData(r *http.Request) auditTemplateData {
 		Entries:     entries,
 		Total:       total,
 		Page:        page,
-		Key:         key,
-		Actor:       actor,
-		Action:      action,
-		Result:      result,
-		ActorType:   actorType,
-		From:        from,
-		To:          to,
+		Key:         q.Get("key"),
+		Actor:       q.Get("actor"),
+		Action:      q.Get("action"),
+		Result:      q.Get("result"),
+		ActorType:   q.Get("actor_type"),
+		From:        q.Get("from"),
+		To:          q.Get("to"),
 		TotalPages:  totalPages,
 		HasPrev:     page > 1,
 		HasNext:     page < totalPages,
@@ -219,6 +184,91 @@ func (h *AuditHandler) buildAuditData(r *http.Request) auditTemplateData {
 	}
 }
 
+// auditQuery builds the audit query from filter params of the audit page, invalid values are ignored.
+func auditQuery(q url.Values) store.AuditQuery {
+	query := store.AuditQuery{Key: q.Get("key"), Actor: q.Get("actor")}
+
+	// parse enums
+	if action := q.Get("action"); action != "" && action != "all" {
+		if a, err := enum.ParseAuditAction(action); err == nil {
+			query.Action = a
+		}
+	}
+	if result := q.Get("result"); result != "" && result != "all" {
+		if res, err := enum.ParseAuditResult(result); err == nil {
+			query.Result = res
+		}
+	}
+	if actorType := q.Get("actor_type"); actorType != "" && actorType != "all" {
+		if at, err := enum.ParseActorType(actorType); err == nil {
+			query.ActorType = at
+		}
+	}
+
+	// parse timestamps
+	if from := q.Get("from"); from != "" {
+		if t, err := time.Parse("2006-01-02T15:04", from); err == nil {
+			query.From = t
+		}
+	}
+	if to := q.Get("to"); to != "" {
+		if t, err := time.Parse("2006-01-02T15:04", to); err == nil {
+			query.To = t
+		}
+	}
+	return query
+}
+
+// HandleAuditExport handles GET /web/audit/export - downloads all entries matching the filters of
+// the audit page as CSV, newest first. Entries logged after the export started are not included.
+func (h *AuditHandler) HandleAuditExport(w http.ResponseWriter, r *http.Request) {
+	username := h.parent.getCurrentUser(r)
+	if username == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !h.auth.IsAdmin(username) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	query := auditQuery(r.URL.Query())
+	now := time.Now()
+	if query.To.IsZero() || query.To.After(now) {
+		query.To = now // keeps pages stable while new entries are logged
+	}
+	query.Limit = auditExportBatch
+
+	// the first batch is read before the response starts, so a failing query still gets an error status
+	entries, _, err := h.store.QueryAudit(r.Context(), query)
+	if err != nil {
+		log.Printf("[ERROR] failed to query audit log for export: %v", err)
+		http.Error(w, "failed to query audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", inventory.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+inventory.Filename("audit", now)+`"`)
+	aw := inventory.NewAuditWriter(w)
+	for {
+		if err := aw.Write(entries); err != nil {
+			log.Printf("[WARN] failed to write audit csv: %v", err)
+			return
+		}
+		if len(entries) < auditExportBatch {
+			break
+		}
+		query.Offset += auditExportBatch
+		if entries, _, err = h.store.QueryAudit(r.Context(), query); err != nil {
+			log.Printf("[WARN] failed to query audit log for export, at %d: %v", query.Offset, err)
+			return
+		}
+	}
+	if err := aw.Flush(); err != nil {
+		log.Printf("[WARN] failed to write audit csv: %v", err)
+	}
+	log.Printf("[INFO] audit export of %d entries by %s", query.Offset+len(entries), username)
+}
+
 // actionClass returns CSS class for action badge.
 func actionClass(action enum.AuditAction) string {
 	switch action {
@@ -230,6 +280,10 @@ func actionClass(action enum.AuditAction) string {
 		return "action-update"
 	case enum.AuditActionDelete:
 		return "action-delete"
+	case enum.AuditActionCanary:
+		return "action-canary"
+	case enum.AuditActionBreakGlass:
+		return "action-breakglass"
 	default:
 		return ""
 	}
@@ -2,8 +2,10 @@ package web
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -296,6 +298,77 @@ func TestAuditHandler_BuildAuditData(t *testing.T) {
 	})
 }
 
+func TestAuditHandler_HandleAuditExport(t *testing.T) {
+	admin := &mocks.AuthProviderMock{
+		GetSessionUserFunc: func(_ context.Context, token string) (string, bool) { return token, token != "" },
+		IsAdminFunc:        func(username string) bool { return username == "admin" },
+	}
+	exportRequest := func(target, user string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		if user != "" {
+			req.AddCookie(&http.Cookie{Name: "stash-auth", Value: user})
+		}
+		return req
+	}
+
+	t.Run("all matching entries in batches", func(t *testing.T) {
+		total := auditExportBatch + 2
+		var queries []store.AuditQuery
+		auditStore := &mocks.AuditStoreMock{
+			QueryAuditFunc: func(_ context.Context, q store.AuditQuery) ([]store.AuditEntry, int, error) {
+				queries = append(queries, q)
+				var res []store.AuditEntry
+				for i := q.Offset; i < min(q.Offset+q.Limit, total); i++ {
+					res = append(res, store.AuditEntry{Key: "app/db", Actor: "alice", Action: enum.AuditActionRead,
+						ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess})
+				}
+				return res, total, nil
+			},
+		}
+		h := newTestAuditHandler(t, auditStore, admin)
+		rec := httptest.NewRecorder()
+		h.HandleAuditExport(rec, exportRequest("/web/audit/export?key=app/*&action=read&from=2024-01-15T14:30", "admin"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), "stash-audit-")
+
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		require.Len(t, lines, total+1)
+		assert.True(t, strings.HasPrefix(lines[0], "timestamp,action,key,actor"))
+		assert.Contains(t, lines[1], ",read,app/db,alice,user,success,")
+
+		require.Len(t, queries, 2)
+		assert.Equal(t, "app/*", queries[0].Key)
+		assert.Equal(t, enum.AuditActionRead, queries[0].Action)
+		assert.Equal(t, 2024, queries[0].From.Year())
+		assert.WithinDuration(t, time.Now(), queries[0].To, time.Minute, "export ends at its start")
+		assert.Equal(t, queries[0].To, queries[1].To)
+		assert.Equal(t, auditExportBatch, queries[1].Offset)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		auditStore := &mocks.AuditStoreMock{
+			QueryAuditFunc: func(context.Context, store.AuditQuery) ([]store.AuditEntry, int, error) {
+				return nil, 0, errors.New("db error")
+			},
+		}
+		h := newTestAuditHandler(t, auditStore, admin)
+		rec := httptest.NewRecorder()
+		h.HandleAuditExport(rec, exportRequest("/web/audit/export", "admin"))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		h := newTestAuditHandler(t, nil, admin)
+		rec := httptest.NewRecorder()
+		h.HandleAuditExport(rec, exportRequest("/web/audit/export", "bob"))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = httptest.NewRecorder()
+		h.HandleAuditExport(rec, exportRequest("/web/audit/export", ""))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
 func TestAuditTemplateFuncs(t *testing.T) {
 	funcs := AuditTemplateFuncs()
 
@@ -305,6 +378,7 @@ func TestAuditTemplateFuncs(t *testing.T) {
 		assert.Equal(t, "action-read", actionClassFn(enum.AuditActionRead))
 		assert.Equal(t, "action-update", actionClassFn(enum.AuditActionUpdate))
 		assert.Equal(t, "action-delete", actionClassFn(enum.AuditActionDelete))
+		assert.Equal(t, "action-canary", actionClassFn(enum.AuditActionCanary))
 	})
 
 	t.Run("resultClass returns correct classes", func(t *testing.T) {
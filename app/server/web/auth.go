@@ -1,6 +1,7 @@
 package web
 
 import (
+	"fmt"
 	"net/http"
 
 	log "github.com/go-pkgz/lgr"
@@ -11,8 +12,10 @@ import (
 // handleLoginForm renders the login page.
 func (h *Handler) handleLoginForm(w http.ResponseWriter, r *http.Request) {
 	data := templateData{
-		Theme:   h.getTheme(r),
-		BaseURL: h.BaseURL,
+		Theme:           h.getTheme(r),
+		BaseURL:         h.BaseURL,
+		RememberEnabled: h.Auth.RememberEnabled(),
+		PasskeyLogin:    h.Auth.PasswordlessEnabled(),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.tmpl.ExecuteTemplate(w, "login.html", data); err != nil {
@@ -39,13 +42,38 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// create session
-	token, err := h.Auth.CreateSession(r.Context(), username)
+	// "remember me" sessions are long-lived
+	remember := r.FormValue("remember") != "" && h.Auth.RememberEnabled()
+
+	// users with passkeys as the second factor confirm the password with a passkey before the session
+	required, err := h.Auth.PasskeyRequired(r.Context(), username)
 	if err != nil {
-		log.Printf("[ERROR] failed to create session: %v", err)
+		log.Printf("[ERROR] failed to check passkeys of %q: %v", username, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if required {
+		h.renderPasskeyStep(w, r, username, remember)
+		return
+	}
+
+	if err := h.startSession(w, r, username, remember); err != nil {
+		log.Printf("[ERROR] %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	http.Redirect(w, r, h.url("/"), http.StatusSeeOther)
+}
+
+// startSession creates a session of the user logged in, records the login device and sets the session cookie.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, username string, remember bool) error {
+	token, err := h.Auth.CreateSession(r.Context(), username, remember)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := h.Auth.RecordLogin(r, token, username); err != nil {
+		log.Printf("[WARN] %v", err) // the login works without device details
+	}
 
 	// set cookie - use __Host- prefix for enhanced security over HTTPS (only when no base URL)
 	// __Host- prefix requires Path="/" which doesn't work with base URL
@@ -55,17 +83,22 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		cookieName = cookie.NameSecure
 	}
 
+	// regular sessions use a browser session cookie, gone with the browser on a shared workstation
+	maxAge := 0
+	if remember {
+		ttl, _ := h.Auth.SessionLimits(true)
+		maxAge = int(ttl.Seconds())
+	}
 	http.SetCookie(w, &http.Cookie{
 		Name:     cookieName,
 		Value:    token,
 		Path:     h.cookiePath(),
-		MaxAge:   int(h.Auth.LoginTTL().Seconds()),
+		MaxAge:   maxAge,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
 		Secure:   secure,
 	})
-
-	http.Redirect(w, r, h.url("/"), http.StatusSeeOther)
+	return nil
 }
 
 // handleLogout logs the user out by clearing the session.
@@ -111,9 +144,11 @@ func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
 // renderLoginError renders the login page with an error message.
 func (h *Handler) renderLoginError(w http.ResponseWriter, r *http.Request, errMsg string) {
 	data := templateData{
-		Theme:   h.getTheme(r),
-		Error:   errMsg,
-		BaseURL: h.BaseURL,
+		Theme:           h.getTheme(r),
+		Error:           errMsg,
+		BaseURL:         h.BaseURL,
+		RememberEnabled: h.Auth.RememberEnabled(),
+		PasskeyLogin:    h.Auth.PasswordlessEnabled(),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusUnauthorized)
@@ -22,14 +22,24 @@ func TestHandler_HandleLoginForm(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Login")
+	assert.NotContains(t, rec.Body.String(), "Remember me")
+
+	h = newTestHandlerWithAuth(t, &mocks.AuthProviderMock{RememberEnabledFunc: func() bool { return true },
+		PasswordlessEnabledFunc: func() bool { return false }})
+	rec = httptest.NewRecorder()
+	h.handleLoginForm(rec, req)
+	assert.Contains(t, rec.Body.String(), `name="remember"`)
 }
 
 func TestHandler_HandleLogin(t *testing.T) {
 	t.Run("valid credentials redirects", func(t *testing.T) {
 		auth := &mocks.AuthProviderMock{
-			IsValidUserFunc:   func(username, password string) bool { return username == "admin" && password == "testpass" },
-			CreateSessionFunc: func(_ context.Context, username string) (string, error) { return "session-token", nil },
-			LoginTTLFunc:      func() time.Duration { return 24 * time.Hour },
+			IsValidUserFunc:         func(username, password string) bool { return username == "admin" && password == "testpass" },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "session-token", nil },
+			RecordLoginFunc:         func(*http.Request, string, string) error { return nil },
+			RememberEnabledFunc:     func() bool { return true },
+			PasswordlessEnabledFunc: func() bool { return false },
 		}
 		h := newTestHandlerWithAuth(t, auth)
 
@@ -50,11 +60,89 @@ func TestHandler_HandleLogin(t *testing.T) {
 			}
 		}
 		require.NotNil(t, authCookie)
+		assert.Zero(t, authCookie.MaxAge, "browser session cookie without remember me")
+		require.Len(t, auth.CreateSessionCalls(), 1)
+		assert.False(t, auth.CreateSessionCalls()[0].Remember)
+		require.Len(t, auth.RecordLoginCalls(), 1)
+		assert.Equal(t, "session-token", auth.RecordLoginCalls()[0].Token)
+		assert.Equal(t, "admin", auth.RecordLoginCalls()[0].Username)
+	})
+
+	t.Run("login works if device is not recorded", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			IsValidUserFunc:         func(username, password string) bool { return true },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "session-token", nil },
+			RecordLoginFunc:         func(*http.Request, string, string) error { return assert.AnError },
+			RememberEnabledFunc:     func() bool { return false },
+			PasswordlessEnabledFunc: func() bool { return false },
+		}
+		h := newTestHandlerWithAuth(t, auth)
+
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.PostForm = map[string][]string{"username": {"admin"}, "password": {"testpass"}}
+		rec := httptest.NewRecorder()
+		h.handleLogin(rec, req)
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Len(t, rec.Result().Cookies(), 1)
+	})
+
+	t.Run("remember me", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			IsValidUserFunc:         func(username, password string) bool { return true },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "session-token", nil },
+			RecordLoginFunc:         func(*http.Request, string, string) error { return nil },
+			RememberEnabledFunc:     func() bool { return true },
+			PasswordlessEnabledFunc: func() bool { return false },
+			SessionLimitsFunc: func(remember bool) (time.Duration, time.Duration) {
+				assert.True(t, remember)
+				return 30 * 24 * time.Hour, 7 * 24 * time.Hour
+			},
+		}
+		h := newTestHandlerWithAuth(t, auth)
+
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.PostForm = map[string][]string{"username": {"admin"}, "password": {"testpass"}, "remember": {"on"}}
+		rec := httptest.NewRecorder()
+		h.handleLogin(rec, req)
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		require.Len(t, rec.Result().Cookies(), 1)
+		assert.Equal(t, 30*24*3600, rec.Result().Cookies()[0].MaxAge)
+		require.Len(t, auth.CreateSessionCalls(), 1)
+		assert.True(t, auth.CreateSessionCalls()[0].Remember)
+	})
+
+	t.Run("remember me disabled", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			IsValidUserFunc:         func(username, password string) bool { return true },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "session-token", nil },
+			RecordLoginFunc:         func(*http.Request, string, string) error { return nil },
+			RememberEnabledFunc:     func() bool { return false },
+			PasswordlessEnabledFunc: func() bool { return false },
+		}
+		h := newTestHandlerWithAuth(t, auth)
+
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.PostForm = map[string][]string{"username": {"admin"}, "password": {"testpass"}, "remember": {"on"}}
+		rec := httptest.NewRecorder()
+		h.handleLogin(rec, req)
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		require.Len(t, auth.CreateSessionCalls(), 1)
+		assert.False(t, auth.CreateSessionCalls()[0].Remember)
 	})
 
 	t.Run("invalid credentials shows error", func(t *testing.T) {
 		auth := &mocks.AuthProviderMock{
-			IsValidUserFunc: func(username, password string) bool { return false },
+			IsValidUserFunc:         func(username, password string) bool { return false },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			RememberEnabledFunc:     func() bool { return false },
+			PasswordlessEnabledFunc: func() bool { return false },
 		}
 		h := newTestHandlerWithAuth(t, auth)
 
@@ -83,8 +171,11 @@ func TestHandler_HandleLogin(t *testing.T) {
 
 	t.Run("session creation error returns 500", func(t *testing.T) {
 		auth := &mocks.AuthProviderMock{
-			IsValidUserFunc:   func(username, password string) bool { return true },
-			CreateSessionFunc: func(_ context.Context, username string) (string, error) { return "", assert.AnError },
+			IsValidUserFunc:         func(username, password string) bool { return true },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "", assert.AnError },
+			RememberEnabledFunc:     func() bool { return false },
+			PasswordlessEnabledFunc: func() bool { return false },
 		}
 		h := newTestHandlerWithAuth(t, auth)
 
@@ -99,9 +190,12 @@ func TestHandler_HandleLogin(t *testing.T) {
 
 	t.Run("HTTPS sets secure cookie with host prefix", func(t *testing.T) {
 		auth := &mocks.AuthProviderMock{
-			IsValidUserFunc:   func(username, password string) bool { return true },
-			CreateSessionFunc: func(_ context.Context, username string) (string, error) { return "token", nil },
-			LoginTTLFunc:      func() time.Duration { return time.Hour },
+			IsValidUserFunc:         func(username, password string) bool { return true },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return false, nil },
+			CreateSessionFunc:       func(_ context.Context, username string, remember bool) (string, error) { return "token", nil },
+			RecordLoginFunc:         func(*http.Request, string, string) error { return nil },
+			RememberEnabledFunc:     func() bool { return false },
+			PasswordlessEnabledFunc: func() bool { return false },
 		}
 		h := newTestHandlerWithAuth(t, auth)
 
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/audit"
+)
+
+// handleBreakGlass elevates the current user to break-glass permissions for a time-boxed window.
+// The reason is mandatory, it is recorded in the audit log and sent to alerts.
+func (h *Handler) handleBreakGlass(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if username == "" || h.BreakGlass == nil || !h.BreakGlass.BreakGlassAllowed(username) {
+		h.logBreakGlass(r, "", enum.AuditResultDenied)
+		http.Error(w, "break-glass access is not allowed", http.StatusForbidden)
+		return
+	}
+	reason := r.FormValue("reason")
+	if reason == "" {
+		reason = r.Header.Get("HX-Prompt") // the header button asks with hx-prompt
+	}
+	reason = audit.NormalizeReason(reason)
+	if reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := h.BreakGlass.BreakGlass(username, reason)
+	if err != nil {
+		log.Printf("[WARN] break-glass for %s failed: %v", username, err)
+		h.logBreakGlass(r, reason, enum.AuditResultDenied)
+		http.Error(w, "break-glass access is not allowed", http.StatusForbidden)
+		return
+	}
+	log.Printf("[WARN] break-glass access by %s until %s, reason: %s", username, expiresAt.Format(time.RFC3339), reason)
+	h.logBreakGlass(r, reason, enum.AuditResultSuccess)
+
+	// elevated permissions change what the page shows
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleBreakGlassEnd drops the break-glass elevation of the current user before it expires.
+func (h *Handler) handleBreakGlassEnd(w http.ResponseWriter, r *http.Request) {
+	if username := h.getCurrentUser(r); username != "" && h.BreakGlass != nil {
+		if _, _, ok := h.BreakGlass.ActiveBreakGlass(username); ok {
+			h.BreakGlass.EndBreakGlass(username)
+			log.Printf("[INFO] break-glass access by %s ended", username)
+		}
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// logBreakGlass records the elevation attempt in the audit log, key "*" as it is not tied to a key.
+func (h *Handler) logBreakGlass(r *http.Request, reason string, result enum.AuditResult) {
+	entry := h.auditEntry(r, "*", enum.AuditActionBreakGlass, result)
+	entry.Reason = reason
+	h.recordAudit(r, entry)
+}
+
+// loadBreakGlass returns the break-glass state of the user for the page header.
+func (h *Handler) loadBreakGlass(username string) breakGlassData {
+	if username == "" || h.BreakGlass == nil {
+		return breakGlassData{}
+	}
+	res := breakGlassData{BreakGlassAllowed: h.BreakGlass.BreakGlassAllowed(username)}
+	if reason, expiresAt, ok := h.BreakGlass.ActiveBreakGlass(username); ok {
+		res.BreakGlassReason, res.BreakGlassUntil = reason, expiresAt
+	}
+	return res
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_BreakGlass(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	var active string
+	bg := &mocks.BreakGlassProviderMock{
+		BreakGlassAllowedFunc: func(username string) bool { return username == "alice" },
+		BreakGlassFunc: func(_, reason string) (time.Time, error) {
+			if reason == "fail" {
+				return time.Time{}, errors.New("failed")
+			}
+			active = reason
+			return expiresAt, nil
+		},
+		ActiveBreakGlassFunc: func(string) (string, time.Time, bool) { return active, expiresAt, active != "" },
+		EndBreakGlassFunc:    func(string) { active = "" },
+	}
+	user := "alice"
+	auth := &mocks.AuthProviderMock{
+		GetSessionUserFunc: func(context.Context, string) (string, bool) { return user, user != "" },
+		IsAdminFunc:        func(string) bool { return false },
+	}
+	auditLog := &mocks.AuditLoggerMock{LogAuditFunc: func(context.Context, store.AuditEntry) error { return nil }}
+	alerts := &mocks.AlertObserverMock{ObserveFunc: func(store.AuditEntry, bool) {}}
+	h := &Handler{Deps: Deps{Auth: auth, BreakGlass: bg, Audit: auditLog, Alerts: alerts}}
+
+	t.Run("elevate", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleBreakGlass(rec, prefsRequest(http.MethodPost, "/web/break-glass", "reason=db+is%0Adown"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+		assert.Equal(t, "db is down", active)
+
+		require.Len(t, auditLog.LogAuditCalls(), 1)
+		entry := auditLog.LogAuditCalls()[0].Entry
+		assert.Equal(t, enum.AuditActionBreakGlass, entry.Action)
+		assert.Equal(t, enum.AuditResultSuccess, entry.Result)
+		assert.Equal(t, "*", entry.Key)
+		assert.Equal(t, "alice", entry.Actor)
+		assert.Equal(t, "db is down", entry.Reason)
+		require.Len(t, alerts.ObserveCalls(), 1)
+	})
+
+	t.Run("actions while elevated carry the reason", func(t *testing.T) {
+		h.logAudit(prefsRequest(http.MethodGet, "/web/keys/view/prod/db", ""), "prod/db", enum.AuditActionRead,
+			enum.AuditResultSuccess, nil)
+		calls := auditLog.LogAuditCalls()
+		assert.Equal(t, "break-glass: db is down", calls[len(calls)-1].Entry.Reason)
+	})
+
+	t.Run("header state", func(t *testing.T) {
+		data := h.loadBreakGlass("alice")
+		assert.True(t, data.BreakGlassAllowed)
+		assert.Equal(t, "db is down", data.BreakGlassReason)
+		assert.Equal(t, expiresAt, data.BreakGlassUntil)
+		assert.Equal(t, breakGlassData{}, h.loadBreakGlass(""))
+	})
+
+	t.Run("end", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleBreakGlassEnd(rec, prefsRequest(http.MethodDelete, "/web/break-glass", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, active)
+		require.Len(t, bg.EndBreakGlassCalls(), 1)
+	})
+
+	t.Run("reason from prompt", func(t *testing.T) {
+		req := prefsRequest(http.MethodPost, "/web/break-glass", "")
+		req.Header.Set("HX-Prompt", "pager fired")
+		rec := httptest.NewRecorder()
+		h.handleBreakGlass(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "pager fired", active)
+		h.handleBreakGlassEnd(httptest.NewRecorder(), prefsRequest(http.MethodDelete, "/web/break-glass", ""))
+	})
+
+	t.Run("no reason", func(t *testing.T) {
+		calls := len(bg.BreakGlassCalls())
+		rec := httptest.NewRecorder()
+		h.handleBreakGlass(rec, prefsRequest(http.MethodPost, "/web/break-glass", "reason=+"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Len(t, bg.BreakGlassCalls(), calls)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleBreakGlass(rec, prefsRequest(http.MethodPost, "/web/break-glass", "reason=fail"))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		calls := auditLog.LogAuditCalls()
+		assert.Equal(t, enum.AuditResultDenied, calls[len(calls)-1].Entry.Result)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		user = "bob"
+		defer func() { user = "alice" }()
+		rec := httptest.NewRecorder()
+		h.handleBreakGlass(rec, prefsRequest(http.MethodPost, "/web/break-glass", "reason=outage"))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		calls := auditLog.LogAuditCalls()
+		assert.Equal(t, enum.AuditActionBreakGlass, calls[len(calls)-1].Entry.Action)
+		assert.Equal(t, enum.AuditResultDenied, calls[len(calls)-1].Entry.Result)
+		assert.Equal(t, "bob", calls[len(calls)-1].Entry.Actor)
+	})
+}
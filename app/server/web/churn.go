@@ -0,0 +1,65 @@
+package web
+
+import (
+	"context"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// churnDays is the number of days covered by the change frequency sparkline of keys
+const churnDays = 30
+
+// churnData is the change frequency of a key, successful writes per day over the last churnDays days.
+type churnData struct {
+	Total int       // changes in the period, zero for abandoned keys
+	Bars  []float64 // daily changes, oldest first, percent of the busiest day of the key
+}
+
+// addChurn sets the change frequency of the listed keys from the audit log, helping to spot keys changed
+// unexpectedly often or not at all. Without the audit log the sparkline is not shown, a failed query
+// leaves it empty rather than failing the list.
+func (h *Handler) addChurn(ctx context.Context, keys []keyWithPermission) {
+	if h.Changes == nil || len(keys) == 0 {
+		return
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.Key
+	}
+	// whole days in UTC, today is the last bar
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(churnDays - 1))
+	q := store.AuditQuery{Keys: names, From: from, Result: enum.AuditResultSuccess,
+		Actions: []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate}}
+	timelines, err := h.Changes.AuditKeyTimelines(ctx, q, 24*time.Hour)
+	if err != nil {
+		log.Printf("[WARN] failed to load change frequency of %d keys: %v", len(keys), err)
+		return
+	}
+	for i := range keys {
+		keys[i].Churn = newChurnData(timelines[keys[i].Key])
+	}
+}
+
+// newChurnData scales daily counts to the busiest day, no counts make an empty sparkline of churnDays days.
+func newChurnData(counts []int) *churnData {
+	res := &churnData{Bars: make([]float64, churnDays)}
+	peak := 0
+	for _, c := range counts {
+		res.Total += c
+		peak = max(peak, c)
+	}
+	// the timeline may have a day more than churnDays if the day changed during the query, keep the latest
+	if len(counts) > churnDays {
+		counts = counts[len(counts)-churnDays:]
+	}
+	for i, c := range counts {
+		if peak > 0 {
+			res.Bars[i] = float64(c) * 100 / float64(peak)
+		}
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_KeyListChurn(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "app/busy", Size: 10}, {Key: "app/stale", Size: 20}}, 2, nil
+		},
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return false },
+		GetSessionUserFunc:      func(context.Context, string) (string, bool) { return "", false },
+		UserCanWriteFunc:        func(string) bool { return true },
+		CheckUserPermissionFunc: func(string, string, bool) bool { return true },
+	}
+	counts := make([]int, churnDays)
+	counts[churnDays-1], counts[churnDays-2] = 4, 2
+	changes := &mocks.ChangeLogMock{
+		AuditKeyTimelinesFunc: func(context.Context, store.AuditQuery, time.Duration) (map[string][]int, error) {
+			return map[string][]int{"app/busy": counts}, nil
+		},
+	}
+	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Changes: changes}, Config{})
+	require.NoError(t, err)
+
+	t.Run("sparkline of each key", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/web/keys", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "view_mode", Value: "table"})
+		h.handleKeyList(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `<th title="Changes in the last 30 days">Changes</th>`)
+		assert.Contains(t, body, `title="6 changes in the last 30 days"`)
+		assert.Contains(t, body, `title="no changes in 30 days"`)
+		assert.Contains(t, body, `<span class="churn-bar" style="height:50%"></span><span class="churn-bar" style="height:100%"></span>`)
+
+		call := changes.AuditKeyTimelinesCalls()[0]
+		assert.Equal(t, []string{"app/busy", "app/stale"}, call.Q.Keys)
+		assert.Equal(t, []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate}, call.Q.Actions)
+		assert.Equal(t, enum.AuditResultSuccess, call.Q.Result)
+		assert.Equal(t, 24*time.Hour, call.Interval)
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		assert.Equal(t, today.AddDate(0, 0, -(churnDays-1)), call.Q.From)
+	})
+
+	t.Run("audit error keeps the list", func(t *testing.T) {
+		failing := &mocks.ChangeLogMock{
+			AuditKeyTimelinesFunc: func(context.Context, store.AuditQuery, time.Duration) (map[string][]int, error) {
+				return nil, assert.AnError
+			},
+		}
+		hf, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Changes: failing}, Config{})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		hf.handleKeyList(rec, httptest.NewRequest(http.MethodGet, "/web/keys", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "app/busy")
+		assert.NotContains(t, rec.Body.String(), `class="churn"`)
+	})
+
+	t.Run("no sparkline without audit log", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newTestHandlerWithStore(t, st).handleKeyList(rec, httptest.NewRequest(http.MethodGet, "/web/keys", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "churn")
+	})
+}
+
+func TestNewChurnData(t *testing.T) {
+	empty := newChurnData(nil)
+	assert.Zero(t, empty.Total)
+	assert.Len(t, empty.Bars, churnDays)
+
+	counts := make([]int, churnDays+1)
+	counts[0], counts[1], counts[churnDays] = 1, 1, 2
+	res := newChurnData(counts)
+	assert.Equal(t, 4, res.Total)
+	require.Len(t, res.Bars, churnDays, "extra day of the query is dropped")
+	assert.InDelta(t, 50, res.Bars[0], 0.001)
+	assert.InDelta(t, 100, res.Bars[churnDays-1], 0.001)
+}
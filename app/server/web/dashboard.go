@@ -0,0 +1,320 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+//go:generate moq -out mocks/auditstats.go -pkg mocks -skip-ensure -fmt goimports . AuditStats
+
+// AuditStats defines the interface for aggregated audit queries.
+type AuditStats interface {
+	CountAudit(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error)
+	AuditTimeline(ctx context.Context, q store.AuditQuery, interval time.Duration) ([]store.AuditBucket, error)
+}
+
+// dashboard limits
+const (
+	dashboardTreemapTiles = 20 // prefixes shown in the treemap, the rest is grouped into "other"
+	dashboardTopActors    = 10
+)
+
+// dashboardRanges maps the range query parameter to its duration and chart interval.
+var dashboardRanges = map[string]struct {
+	period, interval time.Duration
+}{
+	"24h": {24 * time.Hour, time.Hour},
+	"7d":  {7 * 24 * time.Hour, 6 * time.Hour},
+	"30d": {30 * 24 * time.Hour, 24 * time.Hour},
+}
+
+// DashboardHandler handles the admin usage dashboard.
+type DashboardHandler struct {
+	audit  AuditStats // optional, nil if audit is disabled
+	auth   AuthProvider
+	parent *Handler
+}
+
+// NewDashboardHandler creates a new dashboard handler. auditStats may be nil,
+// in which case only key statistics are shown.
+func NewDashboardHandler(auditStats AuditStats, auth AuthProvider, h *Handler) *DashboardHandler {
+	return &DashboardHandler{audit: auditStats, auth: auth, parent: h}
+}
+
+// prefixStat holds key count and total size for a top-level prefix.
+type prefixStat struct {
+	Prefix string
+	Keys   int
+	Size   int
+}
+
+// treemapTile is a positioned treemap rectangle, coordinates are percents of the container.
+type treemapTile struct {
+	prefixStat
+	Left, Top, Width, Height float64
+}
+
+// rateBar is a single bar of the write rate chart.
+type rateBar struct {
+	store.AuditBucket
+	Height float64 // percent of the tallest bar
+}
+
+// dashboardTemplateData holds data passed to the dashboard template.
+type dashboardTemplateData struct {
+	TotalKeys   int
+	TotalSize   int
+	SecretKeys  int
+	Prefixes    []prefixStat
+	Treemap     []treemapTile
+	Range       string
+	Ranges      []string
+	AuditData   bool // audit-derived sections available
+	WriteRate   []rateBar
+	TotalWrites int
+	TotalReads  int
+	TopWriters  []store.AuditCount
+	TopReaders  []store.AuditCount
+
+	Theme       enum.Theme
+	AuthEnabled bool
+	BaseURL     string
+	CSPNonce    string // nonce for inline scripts
+	Error       string
+}
+
+// HandleDashboardPage handles GET /dashboard - renders usage statistics for admins.
+func (h *DashboardHandler) HandleDashboardPage(w http.ResponseWriter, r *http.Request) {
+	username := h.parent.getCurrentUser(r)
+	if username == "" {
+		http.Redirect(w, r, h.parent.BaseURL+"/login?return="+url.QueryEscape(h.parent.BaseURL+"/dashboard"), http.StatusFound)
+		return
+	}
+
+	if !h.auth.IsAdmin(username) {
+		w.WriteHeader(http.StatusForbidden)
+		_ = h.parent.tmpl.ExecuteTemplate(w, "error", map[string]any{
+			"Error":   "Admin access required",
+			"BaseURL": h.parent.BaseURL,
+		})
+		return
+	}
+
+	data := h.buildDashboardData(r)
+	if err := h.parent.tmpl.ExecuteTemplate(w, "dashboard.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// buildDashboardData collects key and audit statistics for the selected range.
+func (h *DashboardHandler) buildDashboardData(r *http.Request) dashboardTemplateData {
+	data := dashboardTemplateData{
+		Range:       r.URL.Query().Get("range"),
+		Ranges:      []string{"24h", "7d", "30d"},
+		Theme:       h.parent.getTheme(r),
+		AuthEnabled: h.auth.Enabled(),
+		BaseURL:     h.parent.BaseURL,
+		CSPNonce:    cspNonce(r.Context()),
+	}
+	rng, ok := dashboardRanges[data.Range]
+	if !ok {
+		data.Range, rng = "7d", dashboardRanges["7d"]
+	}
+
+	keys, err := h.parent.Store.List(r.Context(), enum.SecretsFilterAll)
+	if err != nil {
+		log.Printf("[WARN] dashboard: failed to list keys: %v", err)
+		data.Error = "Failed to load key statistics"
+		return data
+	}
+	data.TotalKeys = len(keys)
+	for _, k := range keys {
+		data.TotalSize += k.Size
+		if k.Secret {
+			data.SecretKeys++
+		}
+	}
+	data.Prefixes = prefixStats(keys)
+	data.Treemap = layoutTreemap(data.Prefixes)
+
+	if h.audit == nil {
+		return data
+	}
+	data.AuditData = true
+
+	// align the range to the chart interval so bars cover whole intervals
+	now := time.Now()
+	from := now.Add(-rng.period).Truncate(rng.interval).Add(rng.interval)
+	writes := []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate, enum.AuditActionDelete}
+	success := enum.AuditResultSuccess
+
+	buckets, err := h.audit.AuditTimeline(r.Context(), store.AuditQuery{From: from, To: now, Actions: writes, Result: success}, rng.interval)
+	if err != nil {
+		log.Printf("[WARN] dashboard: failed to get write rate: %v", err)
+		data.Error = "Failed to load audit statistics"
+		return data
+	}
+	data.WriteRate = rateBars(buckets)
+	for _, b := range buckets {
+		data.TotalWrites += b.Count
+	}
+
+	writeQuery := store.AuditQuery{From: from, Actions: writes, Result: success, Limit: dashboardTopActors}
+	if data.TopWriters, err = h.audit.CountAudit(r.Context(), writeQuery, store.AuditGroupActor); err != nil {
+		log.Printf("[WARN] dashboard: failed to get top writers: %v", err)
+		data.Error = "Failed to load audit statistics"
+		return data
+	}
+
+	readers, err := h.audit.CountAudit(r.Context(), store.AuditQuery{From: from, Action: enum.AuditActionRead, Result: success}, store.AuditGroupActor)
+	if err != nil {
+		log.Printf("[WARN] dashboard: failed to get top readers: %v", err)
+		data.Error = "Failed to load audit statistics"
+		return data
+	}
+	for _, c := range readers {
+		data.TotalReads += c.Count
+	}
+	data.TopReaders = readers[:min(len(readers), dashboardTopActors)]
+	return data
+}
+
+// prefixStats aggregates keys by top-level prefix ("app/" for "app/db/host"), ordered by size descending.
+// Keys without a slash are grouped under "/".
+func prefixStats(keys []store.KeyInfo) []prefixStat {
+	idx := map[string]int{}
+	var res []prefixStat
+	for _, k := range keys {
+		prefix := "/"
+		if before, _, found := strings.Cut(k.Key, "/"); found {
+			prefix = before + "/"
+		}
+		i, ok := idx[prefix]
+		if !ok {
+			i = len(res)
+			idx[prefix] = i
+			res = append(res, prefixStat{Prefix: prefix})
+		}
+		res[i].Keys++
+		res[i].Size += k.Size
+	}
+	slices.SortFunc(res, func(a, b prefixStat) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Prefix, b.Prefix))
+	})
+	return res
+}
+
+// rateBars converts timeline buckets to chart bars scaled to the busiest interval.
+func rateBars(buckets []store.AuditBucket) []rateBar {
+	peak := 0
+	for _, b := range buckets {
+		peak = max(peak, b.Count)
+	}
+	bars := make([]rateBar, len(buckets))
+	for i, b := range buckets {
+		bars[i].AuditBucket = b
+		if peak > 0 {
+			bars[i].Height = float64(b.Count) * 100 / float64(peak)
+		}
+	}
+	return bars
+}
+
+// treemap layout area, wider than tall to match the rendered container
+const treemapW, treemapH = 200.0, 100.0
+
+// layoutTreemap positions prefixes in a squarified treemap with areas proportional to size.
+// Expects stats sorted by size descending; prefixes beyond the tile limit are merged into "other".
+func layoutTreemap(stats []prefixStat) []treemapTile {
+	items := make([]prefixStat, 0, min(len(stats), dashboardTreemapTiles))
+	for i, s := range stats {
+		if s.Size <= 0 {
+			break // sorted by size, the rest is empty too
+		}
+		if i < dashboardTreemapTiles-1 || len(stats) == dashboardTreemapTiles {
+			items = append(items, s)
+			continue
+		}
+		if len(items) < dashboardTreemapTiles {
+			items = append(items, prefixStat{Prefix: "other"})
+		}
+		items[len(items)-1].Keys += s.Keys
+		items[len(items)-1].Size += s.Size
+	}
+
+	total := 0
+	for _, s := range items {
+		total += s.Size
+	}
+	if total == 0 {
+		return nil
+	}
+
+	areas := make([]float64, len(items))
+	for i, s := range items {
+		areas[i] = float64(s.Size) / float64(total) * treemapW * treemapH
+	}
+
+	tiles := make([]treemapTile, 0, len(items))
+	x, y, w, h := 0.0, 0.0, treemapW, treemapH
+	for len(areas) > 0 {
+		// grow the row while it keeps tiles closer to squares
+		side := min(w, h)
+		n := 1
+		for n < len(areas) && worstRatio(areas[:n+1], side) <= worstRatio(areas[:n], side) {
+			n++
+		}
+		rowSum := 0.0
+		for _, a := range areas[:n] {
+			rowSum += a
+		}
+
+		// lay out the row along the shorter side
+		for _, a := range areas[:n] {
+			t := treemapTile{prefixStat: items[len(tiles)]}
+			if w >= h {
+				t.Left, t.Top, t.Width, t.Height = x, y, rowSum/h, a/(rowSum/h)
+				y += t.Height
+			} else {
+				t.Left, t.Top, t.Width, t.Height = x, y, a/(rowSum/w), rowSum/w
+				x += t.Width
+			}
+			tiles = append(tiles, t)
+		}
+		if w >= h {
+			x, y, w = x+rowSum/h, y-h, w-rowSum/h
+		} else {
+			x, y, h = x-w, y+rowSum/w, h-rowSum/w
+		}
+		areas = areas[n:]
+	}
+
+	// convert to percents of the container
+	for i := range tiles {
+		tiles[i].Left *= 100 / treemapW
+		tiles[i].Width *= 100 / treemapW
+		tiles[i].Top *= 100 / treemapH
+		tiles[i].Height *= 100 / treemapH
+	}
+	return tiles
+}
+
+// worstRatio returns the worst aspect ratio of tiles in a row laid along a side of the given length.
+func worstRatio(row []float64, side float64) float64 {
+	sum, hi, lo := 0.0, row[0], row[0]
+	for _, a := range row {
+		sum += a
+		hi, lo = max(hi, a), min(lo, a)
+	}
+	return max(side*side*hi/(sum*sum), sum*sum/(side*side*lo))
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestDashboardHandler_HandleDashboardPage(t *testing.T) {
+	keys := []store.KeyInfo{
+		{Key: "app/db/host", Size: 3000},
+		{Key: "app/db/pass", Size: 1000, Secret: true},
+		{Key: "svc/name", Size: 1000},
+		{Key: "root", Size: 500},
+	}
+	adminAuth := &mocks.AuthProviderMock{
+		GetSessionUserFunc: func(_ context.Context, _ string) (string, bool) { return "admin", true },
+		IsAdminFunc:        func(string) bool { return true },
+		EnabledFunc:        func() bool { return true },
+	}
+
+	t.Run("redirects unauthenticated user to login", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			GetSessionUserFunc: func(_ context.Context, _ string) (string, bool) { return "", false },
+		}
+		h := newTestDashboardHandler(t, keys, nil, auth)
+
+		rec := httptest.NewRecorder()
+		h.HandleDashboardPage(rec, httptest.NewRequest(http.MethodGet, "/dashboard", http.NoBody))
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Contains(t, rec.Header().Get("Location"), "/login?return=%2Fdashboard")
+	})
+
+	t.Run("returns 403 for non-admin user", func(t *testing.T) {
+		auth := &mocks.AuthProviderMock{
+			GetSessionUserFunc: func(_ context.Context, _ string) (string, bool) { return "user", true },
+			IsAdminFunc:        func(string) bool { return false },
+		}
+		h := newTestDashboardHandler(t, keys, nil, auth)
+
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "token"})
+		rec := httptest.NewRecorder()
+		h.HandleDashboardPage(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Admin access required")
+	})
+
+	t.Run("renders key stats without audit", func(t *testing.T) {
+		h := newTestDashboardHandler(t, keys, nil, adminAuth)
+
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "token"})
+		rec := httptest.NewRecorder()
+		h.HandleDashboardPage(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `id="total-keys">4<`)
+		assert.Contains(t, body, `id="total-size">5.4 KB<`)
+		assert.Contains(t, body, `class="treemap-label">app/<`)
+		assert.Contains(t, body, `class="treemap-label">svc/<`)
+		assert.Contains(t, body, "Enable audit logging")
+		assert.NotContains(t, body, "Top writers")
+	})
+
+	t.Run("renders audit stats for selected range", func(t *testing.T) {
+		now := time.Now()
+		auditStats := &mocks.AuditStatsMock{
+			AuditTimelineFunc: func(_ context.Context, q store.AuditQuery, interval time.Duration) ([]store.AuditBucket, error) {
+				return []store.AuditBucket{{Start: now.Add(-2 * time.Hour), Count: 2}, {Start: now.Add(-time.Hour), Count: 4}}, nil
+			},
+			CountAuditFunc: func(_ context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error) {
+				if q.Action == enum.AuditActionRead {
+					return []store.AuditCount{{Value: "reader-bot", Count: 7}, {Value: "alice", Count: 3}}, nil
+				}
+				return []store.AuditCount{{Value: "deployer", Count: 6}}, nil
+			},
+		}
+		h := newTestDashboardHandler(t, keys, auditStats, adminAuth)
+
+		req := httptest.NewRequest(http.MethodGet, "/dashboard?range=24h", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "token"})
+		rec := httptest.NewRecorder()
+		h.HandleDashboardPage(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `id="total-writes">6<`)
+		assert.Contains(t, body, `id="total-reads">10<`)
+		assert.Contains(t, body, "deployer")
+		assert.Contains(t, body, "reader-bot")
+		assert.Contains(t, body, `style="height:100.0%"`)
+		assert.Contains(t, body, `style="height:50.0%"`)
+
+		timeline := auditStats.AuditTimelineCalls()
+		require.Len(t, timeline, 1)
+		assert.Equal(t, time.Hour, timeline[0].Interval)
+		assert.WithinDuration(t, now.Add(-24*time.Hour), timeline[0].Q.From, time.Hour)
+		assert.Equal(t, enum.AuditResultSuccess, timeline[0].Q.Result)
+		assert.Len(t, timeline[0].Q.Actions, 3)
+
+		counts := auditStats.CountAuditCalls()
+		require.Len(t, counts, 2)
+		assert.Equal(t, store.AuditGroupActor, counts[0].GroupBy)
+		assert.Equal(t, dashboardTopActors, counts[0].Q.Limit)
+	})
+
+	t.Run("unknown range falls back to 7d", func(t *testing.T) {
+		auditStats := &mocks.AuditStatsMock{
+			AuditTimelineFunc: func(context.Context, store.AuditQuery, time.Duration) ([]store.AuditBucket, error) { return nil, nil },
+			CountAuditFunc:    func(context.Context, store.AuditQuery, string) ([]store.AuditCount, error) { return nil, nil },
+		}
+		h := newTestDashboardHandler(t, nil, auditStats, adminAuth)
+
+		req := httptest.NewRequest(http.MethodGet, "/dashboard?range=1y", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "token"})
+		rec := httptest.NewRecorder()
+		h.HandleDashboardPage(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "No stored values")
+		assert.Equal(t, 6*time.Hour, auditStats.AuditTimelineCalls()[0].Interval)
+	})
+}
+
+func TestPrefixStats(t *testing.T) {
+	stats := prefixStats([]store.KeyInfo{
+		{Key: "b/x", Size: 10},
+		{Key: "a/x/y", Size: 10},
+		{Key: "a/z", Size: 5},
+		{Key: "top", Size: 20},
+	})
+	assert.Equal(t, []prefixStat{
+		{Prefix: "/", Keys: 1, Size: 20},
+		{Prefix: "a/", Keys: 2, Size: 15},
+		{Prefix: "b/", Keys: 1, Size: 10},
+	}, stats)
+	assert.Empty(t, prefixStats(nil))
+}
+
+func TestLayoutTreemap(t *testing.T) {
+	t.Run("areas proportional to size", func(t *testing.T) {
+		tiles := layoutTreemap([]prefixStat{{Prefix: "a/", Size: 60}, {Prefix: "b/", Size: 30}, {Prefix: "c/", Size: 10}})
+		require.Len(t, tiles, 3)
+		total := 0.0
+		for _, tl := range tiles {
+			assert.GreaterOrEqual(t, tl.Left, -0.001)
+			assert.GreaterOrEqual(t, tl.Top, -0.001)
+			assert.LessOrEqual(t, tl.Left+tl.Width, 100.001)
+			assert.LessOrEqual(t, tl.Top+tl.Height, 100.001)
+			total += tl.Width * tl.Height
+		}
+		assert.InDelta(t, 10000, total, 0.1)
+		assert.InDelta(t, 0.6, tiles[0].Width*tiles[0].Height/total, 0.001)
+		assert.InDelta(t, 0.1, tiles[2].Width*tiles[2].Height/total, 0.001)
+	})
+
+	t.Run("merges small prefixes into other", func(t *testing.T) {
+		stats := make([]prefixStat, dashboardTreemapTiles+5)
+		for i := range stats {
+			stats[i] = prefixStat{Prefix: string(rune('a'+i)) + "/", Keys: 1, Size: 100 - i}
+		}
+		tiles := layoutTreemap(stats)
+		require.Len(t, tiles, dashboardTreemapTiles)
+		last := tiles[len(tiles)-1]
+		assert.Equal(t, "other", last.Prefix)
+		assert.Equal(t, 6, last.Keys)
+	})
+
+	t.Run("empty values", func(t *testing.T) {
+		assert.Empty(t, layoutTreemap(nil))
+		assert.Empty(t, layoutTreemap([]prefixStat{{Prefix: "a/", Keys: 2}}))
+	})
+}
+
+func newTestDashboardHandler(t *testing.T, keys []store.KeyInfo, auditStats AuditStats, auth AuthProvider) *DashboardHandler {
+	t.Helper()
+	st := &mocks.KVStoreMock{
+		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return keys, nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	parentHandler, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{})
+	require.NoError(t, err)
+	return NewDashboardHandler(auditStats, auth, parentHandler)
+}
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	gitdiff "github.com/go-git/go-git/v5/utils/diff"
+	log "github.com/go-pkgz/lgr"
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// diffTimeout limits the time spent on a diff of large values, the rest is shown as deleted and added
+const diffTimeout = time.Second
+
+// diff view modes
+const (
+	diffUnified = "unified"
+	diffSplit   = "split" // side by side
+)
+
+// line kinds of a diff
+const (
+	lineSame    = "same"
+	lineDeleted = "del"
+	lineAdded   = "add"
+)
+
+// diffLine is a line of the unified diff view. OldNum and NewNum are 1-based line numbers in the compared
+// revisions, 0 for a line the revision doesn't have.
+type diffLine struct {
+	Kind   string
+	OldNum int
+	NewNum int
+	Text   template.HTML
+}
+
+// diffCell is a side of a row of the side-by-side diff view, empty Kind for no line.
+type diffCell struct {
+	Kind string
+	Num  int
+	Text template.HTML
+}
+
+// diffRow is a row of the side-by-side diff view, deleted lines are paired with the lines added in their place.
+type diffRow struct {
+	Old diffCell
+	New diffCell
+}
+
+// diffData holds a diff between two revisions of a key.
+type diffData struct {
+	FromRev  string
+	ToRev    string
+	DiffMode string
+	Unified  []diffLine
+	Split    []diffRow
+	Changes  int // number of deleted and added lines
+}
+
+// handleKeyDiff renders the changes between two revisions of a key, unified or side by side,
+// with lines highlighted in the format of their revision.
+func (h *Handler) handleKeyDiff(w http.ResponseWriter, r *http.Request) {
+	key := store.NormalizeKey(r.PathValue("key"))
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+
+	if h.Git == nil {
+		http.Error(w, "git not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	username := h.getCurrentUser(r)
+	if !h.Auth.CheckUserPermission(username, key, false) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !h.historyVisible(username, key) {
+		http.Error(w, "history of the key is restricted to admins", http.StatusForbidden)
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
+
+	if from == "" || to == "" {
+		http.Error(w, "revisions to compare required", http.StatusBadRequest)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode != diffSplit {
+		mode = diffUnified
+	}
+
+	oldValue, oldFormat, err := h.Git.GetRevision(key, from)
+	if err != nil {
+		log.Printf("[ERROR] failed to get revision %s for %s: %v", from, key, err)
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	newValue, newFormat, err := h.Git.GetRevision(key, to)
+	if err != nil {
+		log.Printf("[ERROR] failed to get revision %s for %s: %v", to, key, err)
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	history, err := h.Git.History(key, 50)
+	if err != nil {
+		log.Printf("[WARN] failed to get history for %s: %v", key, err)
+	}
+
+	dd := diffData{FromRev: from, ToRev: to, DiffMode: mode}
+	oldText, oldBinary := h.valueForDisplay(oldValue)
+	newText, newBinary := h.valueForDisplay(newValue)
+	isBinary := oldBinary || newBinary // no diff of binary values, the template shows a notice instead
+	if !isBinary {
+		dd.Unified = buildDiff(oldText, newText, h.highlighter.Lines(oldText, oldFormat), h.highlighter.Lines(newText, newFormat))
+		dd.Split = splitDiff(dd.Unified)
+		for _, l := range dd.Unified {
+			if l.Kind != lineSame {
+				dd.Changes++
+			}
+		}
+	}
+
+	data := templateData{
+		Key:         key,
+		Format:      newFormat,
+		Theme:       h.getTheme(r),
+		BaseURL:     h.BaseURL,
+		Username:    username,
+		IsBinary:    isBinary,
+		historyData: historyData{GitEnabled: true, History: history, RevHash: to},
+		diffData:    dd,
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "diff", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
+
+// buildDiff compares the texts by lines and returns the unified diff with lines taken from their highlighted
+// versions, oldLines and newLines have a line per line of the texts.
+func buildDiff(oldText, newText string, oldLines, newLines []template.HTML) []diffLine {
+	var res []diffLine
+	oldIdx, newIdx := 0, 0
+	for _, d := range gitdiff.DoWithTimeout(lineEnded(oldText), lineEnded(newText), diffTimeout) {
+		for range strings.Count(d.Text, "\n") {
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				res = append(res, diffLine{Kind: lineSame, OldNum: oldIdx + 1, NewNum: newIdx + 1, Text: lineAt(newLines, newIdx)})
+				oldIdx++
+				newIdx++
+			case diffmatchpatch.DiffDelete:
+				res = append(res, diffLine{Kind: lineDeleted, OldNum: oldIdx + 1, Text: lineAt(oldLines, oldIdx)})
+				oldIdx++
+			case diffmatchpatch.DiffInsert:
+				res = append(res, diffLine{Kind: lineAdded, NewNum: newIdx + 1, Text: lineAt(newLines, newIdx)})
+				newIdx++
+			}
+		}
+	}
+	return res
+}
+
+// splitDiff pairs the lines of the unified diff into rows of the side-by-side view.
+func splitDiff(lines []diffLine) []diffRow {
+	var res []diffRow
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == lineSame {
+			l := lines[i]
+			res = append(res, diffRow{Old: diffCell{Kind: lineSame, Num: l.OldNum, Text: l.Text},
+				New: diffCell{Kind: lineSame, Num: l.NewNum, Text: l.Text}})
+			i++
+			continue
+		}
+		// a block of changes, deleted lines come first, then the added ones
+		var deleted, added []diffLine
+		for ; i < len(lines) && lines[i].Kind == lineDeleted; i++ {
+			deleted = append(deleted, lines[i])
+		}
+		for ; i < len(lines) && lines[i].Kind == lineAdded; i++ {
+			added = append(added, lines[i])
+		}
+		for j := range max(len(deleted), len(added)) {
+			var row diffRow
+			if j < len(deleted) {
+				row.Old = diffCell{Kind: lineDeleted, Num: deleted[j].OldNum, Text: deleted[j].Text}
+			}
+			if j < len(added) {
+				row.New = diffCell{Kind: lineAdded, Num: added[j].NewNum, Text: added[j].Text}
+			}
+			res = append(res, row)
+		}
+	}
+	return res
+}
+
+// lineEnded returns the text with a line end after its last line, so a change of the last line isn't
+// a change of the line end. Empty text has no lines.
+func lineEnded(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+// lineAt returns the line, or nothing if the highlighter returned fewer lines than the text has.
+func lineAt(lines []template.HTML, idx int) template.HTML {
+	if idx < len(lines) {
+		return lines[idx]
+	}
+	return ""
+}
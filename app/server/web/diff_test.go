@@ -0,0 +1,166 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestBuildDiff(t *testing.T) {
+	lines := func(ss ...string) []template.HTML {
+		res := make([]template.HTML, len(ss))
+		for i, s := range ss {
+			res[i] = template.HTML(s) //nolint:gosec // test data
+		}
+		return res
+	}
+
+	t.Run("changed line", func(t *testing.T) {
+		res := buildDiff("a\nb\nc", "a\nx\nc\nd", lines("a", "b", "c"), lines("a", "x", "c", "d"))
+		assert.Equal(t, []diffLine{
+			{Kind: lineSame, OldNum: 1, NewNum: 1, Text: "a"},
+			{Kind: lineDeleted, OldNum: 2, Text: "b"},
+			{Kind: lineAdded, NewNum: 2, Text: "x"},
+			{Kind: lineSame, OldNum: 3, NewNum: 3, Text: "c"},
+			{Kind: lineAdded, NewNum: 4, Text: "d"},
+		}, res)
+	})
+
+	t.Run("last line without line end", func(t *testing.T) {
+		res := buildDiff("a\n", "a", lines("a"), lines("a"))
+		assert.Equal(t, []diffLine{{Kind: lineSame, OldNum: 1, NewNum: 1, Text: "a"}}, res)
+	})
+
+	t.Run("from empty value", func(t *testing.T) {
+		res := buildDiff("", "a\nb", nil, lines("a", "b"))
+		assert.Equal(t, []diffLine{{Kind: lineAdded, NewNum: 1, Text: "a"}, {Kind: lineAdded, NewNum: 2, Text: "b"}}, res)
+	})
+
+	t.Run("missing highlighted lines", func(t *testing.T) {
+		res := buildDiff("a", "b", nil, nil)
+		assert.Equal(t, []diffLine{{Kind: lineDeleted, OldNum: 1}, {Kind: lineAdded, NewNum: 1}}, res)
+	})
+}
+
+func TestSplitDiff(t *testing.T) {
+	rows := splitDiff([]diffLine{
+		{Kind: lineSame, OldNum: 1, NewNum: 1, Text: "a"},
+		{Kind: lineDeleted, OldNum: 2, Text: "b"},
+		{Kind: lineDeleted, OldNum: 3, Text: "c"},
+		{Kind: lineAdded, NewNum: 2, Text: "x"},
+		{Kind: lineSame, OldNum: 4, NewNum: 3, Text: "d"},
+		{Kind: lineAdded, NewNum: 4, Text: "e"},
+	})
+	assert.Equal(t, []diffRow{
+		{Old: diffCell{Kind: lineSame, Num: 1, Text: "a"}, New: diffCell{Kind: lineSame, Num: 1, Text: "a"}},
+		{Old: diffCell{Kind: lineDeleted, Num: 2, Text: "b"}, New: diffCell{Kind: lineAdded, Num: 2, Text: "x"}},
+		{Old: diffCell{Kind: lineDeleted, Num: 3, Text: "c"}},
+		{Old: diffCell{Kind: lineSame, Num: 4, Text: "d"}, New: diffCell{Kind: lineSame, Num: 3, Text: "d"}},
+		{New: diffCell{Kind: lineAdded, Num: 4, Text: "e"}},
+	}, rows)
+}
+
+func TestHandler_HandleKeyDiff(t *testing.T) {
+	revisions := map[string]string{"aaa1111": "name: old\nport: 5432\n", "bbb2222": "name: new\nport: 5432\n"}
+	gitSvc := &mocks.GitServiceMock{
+		GetRevisionFunc: func(key, rev string) ([]byte, string, error) {
+			if rev == "bin3333" {
+				return []byte{0xff, 0x00, 0xfe}, "text", nil
+			}
+			v, ok := revisions[rev]
+			if !ok {
+				return nil, "", errors.New("not found")
+			}
+			return []byte(v), "yaml", nil
+		},
+		HistoryFunc: func(string, int) ([]git.HistoryEntry, error) {
+			return []git.HistoryEntry{{Hash: "bbb2222", Format: "yaml"}, {Hash: "aaa1111", Format: "yaml"}}, nil
+		},
+	}
+	h := newTestHandlerWithGit(t, gitSvc)
+
+	call := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		req.SetPathValue("key", "app/cfg")
+		rec := httptest.NewRecorder()
+		h.handleKeyDiff(rec, req)
+		return rec
+	}
+
+	t.Run("unified", func(t *testing.T) {
+		rec := call("/web/keys/diff/app/cfg?from=aaa1111&to=bbb2222")
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `<tr class="diff-del">`)
+		assert.Contains(t, body, `<tr class="diff-add">`)
+		assert.Contains(t, body, `<tr class="diff-same">`)
+		assert.Contains(t, body, "old")
+		assert.Contains(t, body, "new")
+		assert.Contains(t, body, `class="diff-form"`)
+		assert.Contains(t, body, `<option value="split" >`)
+	})
+
+	t.Run("side by side", func(t *testing.T) {
+		rec := call("/web/keys/diff/app/cfg?from=aaa1111&to=bbb2222&mode=split")
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "diff-split")
+		assert.Contains(t, body, `class="diff-code diff-del"`)
+		assert.Contains(t, body, `class="diff-code diff-add"`)
+	})
+
+	t.Run("same value", func(t *testing.T) {
+		rec := call("/web/keys/diff/app/cfg?from=aaa1111&to=aaa1111")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "same value")
+	})
+
+	t.Run("binary value", func(t *testing.T) {
+		rec := call("/web/keys/diff/app/cfg?from=aaa1111&to=bin3333")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "can't be compared")
+		assert.NotContains(t, rec.Body.String(), "diff-table")
+	})
+
+	t.Run("missing revision", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, call("/web/keys/diff/app/cfg?from=aaa1111").Code)
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, call("/web/keys/diff/app/cfg?from=aaa1111&to=ccc3333").Code)
+	})
+
+	t.Run("git disabled", func(t *testing.T) {
+		h := newTestHandler(t)
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/diff/app/cfg?from=aaa1111&to=bbb2222", http.NoBody)
+		req.SetPathValue("key", "app/cfg")
+		rec := httptest.NewRecorder()
+		h.handleKeyDiff(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+			SecretsEnabledFunc: func() bool { return false },
+		}
+		auth := &mocks.AuthProviderMock{CheckUserPermissionFunc: func(string, string, bool) bool { return false }}
+		h := newTestHandlerWithStoreAndAuth(t, st, auth)
+		h.Git = gitSvc
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/diff/app/cfg?from=aaa1111&to=bbb2222", http.NoBody)
+		req.SetPathValue("key", "app/cfg")
+		rec := httptest.NewRecorder()
+		h.handleKeyDiff(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
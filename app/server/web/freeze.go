@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/server/freeze"
+	"github.com/umputun/stash/app/store"
+)
+
+// handleFreezeNew renders the form freezing a prefix, prefilled with ?prefix=.
+func (h *Handler) handleFreezeNew(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if !h.canFreeze(username) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	data := templateData{
+		BaseURL:  h.BaseURL,
+		Username: username,
+		freezeData: freezeData{CanFreeze: true, FreezePrefix: r.URL.Query().Get("prefix"),
+			FreezeForm: freeze.Request{TTL: freeze.DefaultTTL.String()}},
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "freeze-form", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
+
+// handleFreeze freezes the prefix of the form, writes of its keys are rejected until the freeze expires
+// or is lifted. The page is refreshed to show the freeze banner.
+func (h *Handler) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if !h.canFreeze(username) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	req := freeze.Request{Incident: r.FormValue("incident"), Message: r.FormValue("message"), TTL: r.FormValue("ttl")}
+	f, err := req.Freeze(r.FormValue("prefix"), username, time.Now().UTC())
+	if err == nil {
+		err = h.Freezes.FreezePrefix(r.Context(), f)
+	}
+	if err != nil {
+		log.Printf("[WARN] failed to freeze prefix %q by %s: %v", r.FormValue("prefix"), username, err)
+		w.Header().Set("HX-Retarget", "#modal-content")
+		w.Header().Set("HX-Reswap", "innerHTML")
+		data := templateData{
+			BaseURL:    h.BaseURL,
+			Username:   username,
+			Error:      err.Error(),
+			freezeData: freezeData{CanFreeze: true, FreezePrefix: r.FormValue("prefix"), FreezeForm: req},
+		}
+		if err := h.tmpl.ExecuteTemplate(w, "freeze-form", data); err != nil {
+			log.Printf("[ERROR] failed to execute template: %v", err)
+		}
+		return
+	}
+	log.Printf("[WARN] prefix %q frozen by %s until %s, incident %s", f.Prefix, username, f.ExpiresAt.Format(time.RFC3339), f.Incident)
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUnfreeze lifts the freeze of the prefix. A freeze expired in the meantime is not an error.
+func (h *Handler) handleUnfreeze(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if !h.canFreeze(username) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	prefix := store.NormalizeKey(r.PathValue("prefix"))
+	if err := h.Freezes.Unfreeze(r.Context(), prefix); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("[ERROR] failed to unfreeze prefix %q: %v", prefix, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[INFO] prefix %q unfrozen by %s", prefix, username)
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// canFreeze reports whether the user can freeze and unfreeze prefixes, admins only.
+func (h *Handler) canFreeze(username string) bool {
+	return h.Freezes != nil && username != "" && h.Auth.IsAdmin(username)
+}
+
+// loadFreezes returns the active freezes for the main page banner, shown to all users as their writes
+// are rejected too.
+func (h *Handler) loadFreezes(ctx context.Context, username string) freezeData {
+	if h.Freezes == nil {
+		return freezeData{}
+	}
+	freezes, err := h.Freezes.Freezes(ctx)
+	if err != nil {
+		log.Printf("[WARN] failed to get freezes: %v", err)
+	}
+	return freezeData{Freezes: freezes, CanFreeze: h.canFreeze(username)}
+}
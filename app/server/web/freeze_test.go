@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_Freeze(t *testing.T) {
+	var active []store.Freeze
+	freezes := &mocks.FreezeStoreMock{
+		FreezePrefixFunc: func(_ context.Context, f store.Freeze) error {
+			active = append(active, f)
+			return nil
+		},
+		UnfreezeFunc: func(context.Context, string) error {
+			if len(active) == 0 {
+				return store.ErrNotFound
+			}
+			active = nil
+			return nil
+		},
+		FreezesFunc: func(context.Context) ([]store.Freeze, error) { return active, nil },
+	}
+	user := "alice"
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return true },
+		GetSessionUserFunc:      func(context.Context, string) (string, bool) { return user, user != "" },
+		IsAdminFunc:             func(username string) bool { return username == "alice" },
+		FilterUserKeysFunc:      func(_ string, keys []string) []string { return keys },
+		CheckUserPermissionFunc: func(string, string, bool) bool { return true },
+		UserCanWriteFunc:        func(string) bool { return true },
+	}
+	st := &mocks.KVStoreMock{
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Freezes: freezes}, Config{})
+	require.NoError(t, err)
+
+	t.Run("form", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleFreezeNew(rec, prefsRequest(http.MethodGet, "/web/freezes/new?prefix=prod/db", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `value="prod/db"`)
+		assert.Contains(t, rec.Body.String(), `value="1h0m0s"`)
+	})
+
+	t.Run("freeze", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleFreeze(rec, prefsRequest(http.MethodPost, "/web/freezes", "prefix=prod%2F&incident=INC-1&message=db+failover&ttl=2h"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+		require.Len(t, active, 1)
+		assert.Equal(t, "prod", active[0].Prefix)
+		assert.Equal(t, "INC-1", active[0].Incident)
+		assert.Equal(t, "alice", active[0].FrozenBy)
+		assert.WithinDuration(t, time.Now().Add(2*time.Hour), active[0].ExpiresAt, time.Minute)
+	})
+
+	t.Run("banner", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "prod is frozen")
+		assert.Contains(t, body, "INC-1")
+		assert.Contains(t, body, "/web/freezes/prod")
+
+		user = "bob"
+		defer func() { user = "alice" }()
+		rec = httptest.NewRecorder()
+		h.handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+		assert.Contains(t, rec.Body.String(), "prod is frozen", "all users see the freeze")
+		assert.NotContains(t, rec.Body.String(), "/web/freezes/prod", "only admins can unfreeze")
+	})
+
+	t.Run("invalid form keeps values", func(t *testing.T) {
+		calls := len(freezes.FreezePrefixCalls())
+		rec := httptest.NewRecorder()
+		h.handleFreeze(rec, prefsRequest(http.MethodPost, "/web/freezes", "prefix=prod&incident=INC-2&ttl=soon"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "#modal-content", rec.Header().Get("HX-Retarget"))
+		assert.Contains(t, rec.Body.String(), "invalid ttl")
+		assert.Contains(t, rec.Body.String(), `value="INC-2"`)
+		assert.Len(t, freezes.FreezePrefixCalls(), calls)
+	})
+
+	t.Run("unfreeze", func(t *testing.T) {
+		req := prefsRequest(http.MethodDelete, "/web/freezes/prod", "")
+		req.SetPathValue("prefix", "prod")
+		rec := httptest.NewRecorder()
+		h.handleUnfreeze(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+		assert.Empty(t, active)
+
+		rec = httptest.NewRecorder()
+		h.handleUnfreeze(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, "expired freeze is not an error")
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		user = "bob"
+		defer func() { user = "alice" }()
+		calls := len(freezes.FreezePrefixCalls())
+		rec := httptest.NewRecorder()
+		h.handleFreezeNew(rec, prefsRequest(http.MethodGet, "/web/freezes/new", ""))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = httptest.NewRecorder()
+		h.handleFreeze(rec, prefsRequest(http.MethodPost, "/web/freezes", "prefix=prod&incident=INC-1"))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = httptest.NewRecorder()
+		h.handleUnfreeze(rec, prefsRequest(http.MethodDelete, "/web/freezes/prod", ""))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Len(t, freezes.FreezePrefixCalls(), calls)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		noFreezes, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		noFreezes.handleFreezeNew(rec, prefsRequest(http.MethodGet, "/web/freezes/new", ""))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, freezeData{}, noFreezes.loadFreezes(context.Background(), "alice"))
+	})
+}
+
+func TestHandler_HandleKeyDeleteFrozen(t *testing.T) {
+	frozen := &store.FrozenError{Key: "prod/db", Freeze: store.Freeze{Prefix: "prod", Incident: "INC-1",
+		ExpiresAt: time.Now().Add(time.Hour)}}
+	st := &mocks.KVStoreMock{
+		DeleteFunc:         func(context.Context, string) error { return frozen },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	h := newTestHandlerWithStore(t, st)
+
+	req := httptest.NewRequest(http.MethodDelete, "/web/keys/prod/db", http.NoBody)
+	req.SetPathValue("key", "prod/db")
+	rec := httptest.NewRecorder()
+	h.handleKeyDelete(rec, req)
+	assert.Equal(t, "#modal-content", rec.Header().Get("HX-Retarget"))
+	assert.Contains(t, rec.Body.String(), "frozen for incident INC-1")
+}
@@ -5,13 +5,13 @@ import (
 	"context"
 	"embed"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"maps"
 	"net/http"
 	"net/url"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +23,9 @@ import (
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/audit"
+	"github.com/umputun/stash/app/server/auth"
+	"github.com/umputun/stash/app/server/freeze"
 	"github.com/umputun/stash/app/server/internal/cookie"
 	"github.com/umputun/stash/app/store"
 )
@@ -32,6 +35,17 @@ import (
 //go:generate moq -out mocks/authprovider.go -pkg mocks -skip-ensure -fmt goimports . AuthProvider
 //go:generate moq -out mocks/gitservice.go -pkg mocks -skip-ensure -fmt goimports . GitService
 //go:generate moq -out mocks/auditlogger.go -pkg mocks -skip-ensure -fmt goimports . AuditLogger
+//go:generate moq -out mocks/alertobserver.go -pkg mocks -skip-ensure -fmt goimports . AlertObserver
+//go:generate moq -out mocks/canarymatcher.go -pkg mocks -skip-ensure -fmt goimports . CanaryMatcher
+//go:generate moq -out mocks/historypolicy.go -pkg mocks -skip-ensure -fmt goimports . HistoryPolicy
+//go:generate moq -out mocks/reasonpolicy.go -pkg mocks -skip-ensure -fmt goimports . ReasonPolicy
+//go:generate moq -out mocks/breakglass.go -pkg mocks -skip-ensure -fmt goimports . BreakGlassProvider
+//go:generate moq -out mocks/ownerpolicy.go -pkg mocks -skip-ensure -fmt goimports . OwnerPolicy
+//go:generate moq -out mocks/userprefs.go -pkg mocks -skip-ensure -fmt goimports . UserPrefs
+//go:generate moq -out mocks/recentactivity.go -pkg mocks -skip-ensure -fmt goimports . RecentActivity
+//go:generate moq -out mocks/freezestore.go -pkg mocks -skip-ensure -fmt goimports . FreezeStore
+//go:generate moq -out mocks/accessresolver.go -pkg mocks -skip-ensure -fmt goimports . AccessResolver
+//go:generate moq -out mocks/changelog.go -pkg mocks -skip-ensure -fmt goimports . ChangeLog
 
 //go:embed static
 var staticFS embed.FS
@@ -52,10 +66,13 @@ func StaticFS() (fs.FS, error) {
 type KVStore interface {
 	GetWithFormat(ctx context.Context, key string) ([]byte, string, error)
 	GetInfo(ctx context.Context, key string) (store.KeyInfo, error)
-	Set(ctx context.Context, key string, value []byte, format string) (created bool, err error)
+	SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (created bool, err error)
 	SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error
+	SetMeta(ctx context.Context, key string, meta store.KeyMeta) error
 	Delete(ctx context.Context, key string) error
+	Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
 	List(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
+	ListPage(ctx context.Context, q store.ListQuery) (keys []store.KeyInfo, total int, err error)
 	SecretsEnabled() bool
 }
 
@@ -76,9 +93,25 @@ type AuthProvider interface {
 	IsAdmin(username string) bool
 
 	IsValidUser(username, password string) bool
-	CreateSession(ctx context.Context, username string) (string, error)
+	CreateSession(ctx context.Context, username string, remember bool) (string, error)
 	InvalidateSession(ctx context.Context, token string)
-	LoginTTL() time.Duration
+	RememberEnabled() bool
+
+	SessionInfo(ctx context.Context, token string) (store.Session, bool)
+	RenewSession(ctx context.Context, token string) (store.Session, error)
+	SessionLimits(remember bool) (ttl, idle time.Duration)
+	RecordLogin(r *http.Request, token, username string) error
+	UserSessions(ctx context.Context, username string) ([]store.Session, error)
+
+	PasskeysEnabled() bool
+	PasswordlessEnabled() bool
+	PasskeyRequired(ctx context.Context, username string) (bool, error)
+	BeginPasskeyRegistration(ctx context.Context, username string) (json.RawMessage, error)
+	FinishPasskeyRegistration(ctx context.Context, username string, body []byte) error
+	BeginPasskeyLogin(ctx context.Context, username string) (json.RawMessage, error)
+	FinishPasskeyLogin(ctx context.Context, body []byte) (string, error)
+	Passkeys(ctx context.Context, username string) ([]store.Passkey, error)
+	DeletePasskey(ctx context.Context, username, id string) error
 }
 
 // GitService defines the interface for git operations.
@@ -94,6 +127,72 @@ type AuditLogger interface {
 	LogAudit(ctx context.Context, entry store.AuditEntry) error
 }
 
+// AlertObserver defines the interface for passing audit entries to suspicious activity alerts.
+type AlertObserver interface {
+	Observe(entry store.AuditEntry, admin bool)
+}
+
+// CanaryMatcher defines the interface for detecting canary keys.
+type CanaryMatcher interface {
+	IsCanary(key string) bool
+}
+
+// ReasonPolicy defines the interface for detecting keys whose access must be justified with a reason.
+type ReasonPolicy interface {
+	RequiresReason(key string) bool
+}
+
+// HistoryPolicy defines the interface for keys with history shown to admins only.
+type HistoryPolicy interface {
+	Visible(key string, admin bool) bool
+}
+
+// BreakGlassProvider defines the interface for time-boxed self-elevation of users to emergency permissions.
+type BreakGlassProvider interface {
+	BreakGlassAllowed(username string) bool
+	BreakGlass(username, reason string) (time.Time, error)
+	EndBreakGlass(username string)
+	ActiveBreakGlass(username string) (reason string, expiresAt time.Time, ok bool)
+}
+
+// OwnerPolicy defines the interface for checking who can delete owned keys.
+type OwnerPolicy interface {
+	CanDelete(ctx context.Context, key, identity string, admin bool) (bool, error)
+}
+
+// UserPrefs defines the interface for per-user pinned keys and saved searches.
+type UserPrefs interface {
+	PinKey(ctx context.Context, username, key string) error
+	UnpinKey(ctx context.Context, username, key string) error
+	PinnedKeys(ctx context.Context, username string) ([]string, error)
+	SaveSearch(ctx context.Context, username, name, query string) error
+	DeleteSearch(ctx context.Context, username, name string) error
+	SavedSearches(ctx context.Context, username string) ([]store.SavedSearch, error)
+}
+
+// RecentActivity defines the interface for keys recently used by a user, from the audit log.
+type RecentActivity interface {
+	RecentAuditKeys(ctx context.Context, q store.AuditQuery) ([]store.AuditRecentKey, error)
+}
+
+// ChangeLog defines the interface for the last changes and change frequency of keys, from the audit log.
+type ChangeLog interface {
+	LastAuditEntries(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, error)
+	AuditKeyTimelines(ctx context.Context, q store.AuditQuery, interval time.Duration) (map[string][]int, error)
+}
+
+// FreezeStore defines the interface for prefixes frozen by admins during incidents.
+type FreezeStore interface {
+	FreezePrefix(ctx context.Context, f store.Freeze) error
+	Unfreeze(ctx context.Context, prefix string) error
+	Freezes(ctx context.Context) ([]store.Freeze, error)
+}
+
+// AccessResolver defines the interface for listing who can access a key according to the auth config.
+type AccessResolver interface {
+	KeyAccess(ctx context.Context, key string) (auth.KeyAccessList, error)
+}
+
 // EventPublisher defines the interface for publishing key change events.
 type EventPublisher interface {
 	Publish(key string, action enum.AuditAction)
@@ -101,19 +200,31 @@ type EventPublisher interface {
 
 // Config holds web handler configuration.
 type Config struct {
-	BaseURL      string
-	PageSize     int
-	AuditEnabled bool
+	BaseURL        string
+	PageSize       int
+	AuditEnabled   bool
+	FrameAncestors []string // origins allowed to embed the UI in a frame, none if empty
 }
 
 // Deps holds dependencies for the web handler.
 type Deps struct {
-	Store     KVStore
-	Auth      AuthProvider
-	Validator Validator
-	Git       GitService     // optional
-	Audit     AuditLogger    // optional
-	Events    EventPublisher // optional
+	Store         KVStore
+	Auth          AuthProvider
+	Validator     Validator
+	Git           GitService         // optional
+	Audit         AuditLogger        // optional
+	Events        EventPublisher     // optional
+	Alerts        AlertObserver      // optional
+	Canaries      CanaryMatcher      // optional, reads of canaries are audited as canary action
+	Reasons       ReasonPolicy       // optional, access to matched keys requires a reason recorded in the audit log
+	BreakGlass    BreakGlassProvider // optional, users with break_glass config can self-elevate
+	Owners        OwnerPolicy        // optional, enforces owner-only delete
+	Prefs         UserPrefs          // optional, pinned keys and saved searches of logged-in users
+	Recent        RecentActivity     // optional, recently viewed and edited keys of logged-in users
+	HistoryAccess HistoryPolicy      // optional, history, revisions and restore of matched keys are allowed to admins only
+	Freezes       FreezeStore        // optional, active freezes are shown on the main page, admins set and lift them
+	Access        AccessResolver     // optional, admins and key owners see who can access a key
+	Changes       ChangeLog          // optional, last change of each key in the key report and change frequency in the list
 }
 
 // Handler handles web UI requests.
@@ -122,11 +233,23 @@ type Handler struct {
 	Config
 	highlighter *Highlighter
 	tmpl        *template.Template
+	assets      *Assets
 }
 
 // New creates a new web handler.
 func New(deps Deps, cfg Config) (*Handler, error) {
-	tmpl, err := parseTemplates()
+	if err := validateFrameAncestors(cfg.FrameAncestors); err != nil {
+		return nil, err
+	}
+	static, err := StaticFS()
+	if err != nil {
+		return nil, err
+	}
+	assets, err := NewAssets(static)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseTemplates(assets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse templates: %w", err)
 	}
@@ -136,18 +259,31 @@ func New(deps Deps, cfg Config) (*Handler, error) {
 		Config:      cfg,
 		highlighter: NewHighlighter(),
 		tmpl:        tmpl,
+		assets:      assets,
 	}, nil
 }
 
+// Assets returns the static files handler, to be mounted at /static/ with the prefix stripped.
+func (h *Handler) Assets() *Assets {
+	return h.assets
+}
+
 // Register registers web UI routes on the given router.
 func (h *Handler) Register(r *routegroup.Bundle) {
 	r.HandleFunc("GET /{$}", h.handleIndex)
 	r.HandleFunc("GET /web/keys", h.handleKeyList)
 	r.HandleFunc("GET /web/keys/new", h.handleKeyNew)
+	r.HandleFunc("GET /web/keys/rows", h.handleKeyRows)
+	r.HandleFunc("GET /web/keys/export", h.handleKeyExport)
+	r.HandleFunc("GET /web/report", h.handleKeyReport)
+	r.HandleFunc("GET /web/keys/tree", h.handleKeyTree)
+	r.HandleFunc("DELETE /web/keys/folder/{prefix...}", h.handleFolderDelete)
 	r.HandleFunc("GET /web/keys/view/{key...}", h.handleKeyView)
 	r.HandleFunc("GET /web/keys/edit/{key...}", h.handleKeyEdit)
 	r.HandleFunc("GET /web/keys/history/{key...}", h.handleKeyHistory)
+	r.HandleFunc("GET /web/keys/access/{key...}", h.handleKeyAccess)
 	r.HandleFunc("GET /web/keys/revision/{key...}", h.handleKeyRevision)
+	r.HandleFunc("GET /web/keys/diff/{key...}", h.handleKeyDiff)
 	r.HandleFunc("POST /web/keys/restore/{key...}", h.handleKeyRestore)
 	r.HandleFunc("POST /web/keys", h.handleKeyCreate)
 	r.HandleFunc("PUT /web/keys/{key...}", h.handleKeyUpdate)
@@ -156,17 +292,34 @@ func (h *Handler) Register(r *routegroup.Bundle) {
 	r.HandleFunc("POST /web/view-mode", h.handleViewModeToggle)
 	r.HandleFunc("POST /web/sort", h.handleSortToggle)
 	r.HandleFunc("POST /web/secrets-filter", h.handleSecretsFilterToggle)
+	r.HandleFunc("POST /web/pins/{key...}", h.handlePin)
+	r.HandleFunc("DELETE /web/pins/{key...}", h.handleUnpin)
+	r.HandleFunc("POST /web/searches", h.handleSearchSave)
+	r.HandleFunc("DELETE /web/searches/{name}", h.handleSearchDelete)
+	r.HandleFunc("POST /web/session/renew", h.handleSessionRenew)
+	r.HandleFunc("POST /web/break-glass", h.handleBreakGlass)
+	r.HandleFunc("DELETE /web/break-glass", h.handleBreakGlassEnd)
+	r.HandleFunc("GET /web/freezes/new", h.handleFreezeNew)
+	r.HandleFunc("POST /web/freezes", h.handleFreeze)
+	r.HandleFunc("DELETE /web/freezes/{prefix...}", h.handleUnfreeze)
+	r.HandleFunc("GET /profile", h.handleProfile)
+	r.HandleFunc("POST /web/passkeys/register/begin", h.handlePasskeyRegisterBegin)
+	r.HandleFunc("POST /web/passkeys/register/finish", h.handlePasskeyRegisterFinish)
+	r.HandleFunc("DELETE /web/passkeys/{id}", h.handlePasskeyDelete)
 }
 
 // RegisterAuth registers auth routes (login/logout) on the given router.
 func (h *Handler) RegisterAuth(r *routegroup.Bundle) {
 	r.HandleFunc("GET /login", h.handleLoginForm)
 	r.HandleFunc("POST /logout", h.handleLogout)
+	r.HandleFunc("GET /web/session", h.handleSessionStatus)
 }
 
-// RegisterLogin registers the login POST handler with custom middleware.
+// RegisterLogin registers the login POST handlers with custom middleware.
 func (h *Handler) RegisterLogin(r *routegroup.Bundle, middleware func(http.Handler) http.Handler) {
 	r.Handle("POST /login", middleware(http.HandlerFunc(h.handleLogin)))
+	r.Handle("POST /web/passkeys/login/begin", middleware(http.HandlerFunc(h.handlePasskeyLoginBegin)))
+	r.Handle("POST /web/passkeys/login/finish", middleware(http.HandlerFunc(h.handlePasskeyLoginFinish)))
 }
 
 // templateFuncs returns custom template functions.
@@ -191,6 +344,8 @@ func templateFuncs() template.FuncMap {
 			return strconv.FormatFloat(float64(size)/(1024*1024), 'f', 1, 64) + " MB"
 		},
 		"urlEncode":     url.PathEscape,
+		"queryEncode":   url.QueryEscape,
+		"trimPrefix":    strings.TrimPrefix,
 		"sortModeLabel": sortModeLabel,
 		"add":           func(a, b int) int { return a + b },
 		"sub":           func(a, b int) int { return a - b },
@@ -203,8 +358,12 @@ func templateFuncs() template.FuncMap {
 }
 
 // parseTemplates parses all templates from embedded filesystem.
-func parseTemplates() (*template.Template, error) {
-	tmpl := template.New("").Funcs(templateFuncs())
+// Static files are linked with "asset" (content-hash name) and "integrity" (SRI value) functions.
+func parseTemplates(assets *Assets) (*template.Template, error) {
+	tmpl := template.New("").Funcs(templateFuncs()).Funcs(template.FuncMap{
+		"asset":     assets.Path,
+		"integrity": assets.Integrity,
+	})
 
 	// parse base template
 	baseContent, err := templatesFS.ReadFile("templates/base.html")
@@ -246,8 +405,39 @@ func parseTemplates() (*template.Template, error) {
 		return nil, fmt.Errorf("parse audit.html: %w", err)
 	}
 
+	// parse dashboard template
+	dashboardContent, err := templatesFS.ReadFile("templates/dashboard.html")
+	if err != nil {
+		return nil, fmt.Errorf("read dashboard.html: %w", err)
+	}
+	_, err = tmpl.New("dashboard.html").Parse(string(dashboardContent))
+	if err != nil {
+		return nil, fmt.Errorf("parse dashboard.html: %w", err)
+	}
+
+	// parse key report template
+	reportContent, err := templatesFS.ReadFile("templates/report.html")
+	if err != nil {
+		return nil, fmt.Errorf("read report.html: %w", err)
+	}
+	_, err = tmpl.New("report.html").Parse(string(reportContent))
+	if err != nil {
+		return nil, fmt.Errorf("parse report.html: %w", err)
+	}
+
+	// parse profile template
+	profileContent, err := templatesFS.ReadFile("templates/profile.html")
+	if err != nil {
+		return nil, fmt.Errorf("read profile.html: %w", err)
+	}
+	_, err = tmpl.New("profile.html").Parse(string(profileContent))
+	if err != nil {
+		return nil, fmt.Errorf("parse profile.html: %w", err)
+	}
+
 	// parse partials
-	partials := []string{"keys-table", "form", "view", "history", "revision", "error", "audit-table"}
+	partials := []string{"keys-table", "form", "view", "history", "revision", "diff", "error", "audit-table", "sidebar", "freeze", "tree",
+		"access"}
 	for _, name := range partials {
 		content, readErr := templatesFS.ReadFile("templates/partials/" + name + ".html")
 		if readErr != nil {
@@ -265,7 +455,8 @@ func parseTemplates() (*template.Template, error) {
 // keyWithPermission wraps KeyInfo with per-key write permission.
 type keyWithPermission struct {
 	store.KeyInfo
-	CanWrite bool // user has write permission for this specific key
+	CanWrite bool       // user has write permission for this specific key
+	Churn    *churnData // change frequency over the last 30 days, nil without audit log
 }
 
 // conflictData holds conflict detection fields for optimistic locking.
@@ -299,6 +490,47 @@ type historyData struct {
 	RevHash    string             // specific revision hash being viewed
 }
 
+// breakGlassData holds the break-glass state of the logged-in user.
+type breakGlassData struct {
+	BreakGlassAllowed bool      // user can self-elevate to break-glass permissions
+	BreakGlassReason  string    // reason of the active elevation, empty if not elevated
+	BreakGlassUntil   time.Time // expiration of the active elevation
+}
+
+// freezeData holds the active prefix freezes for the main page banner.
+type freezeData struct {
+	Freezes      []store.Freeze
+	CanFreeze    bool           // user is an admin and can freeze and unfreeze prefixes
+	FreezePrefix string         // prefix of the freeze form
+	FreezeForm   freeze.Request // incident, message and ttl of the freeze form, kept on errors
+}
+
+// metaData holds the description and labels of the viewed or edited key.
+type metaData struct {
+	Description string
+	Labels      map[string]string // labels shown as chips in the view modal
+	LabelsText  string            // labels in the form, e.g. "env=prod, team=payments"
+}
+
+// accessData holds who can access the viewed key, shown to admins and the key owner.
+type accessData struct {
+	AccessVisible bool             // user can open the access view of the key
+	Access        []auth.KeyAccess // identities with access to the key
+	AccessPolicy  bool             // an authorization policy decides access on top of the listed ACLs
+}
+
+// sidebarData holds pinned keys, saved searches and recent keys of the logged-in user.
+type sidebarData struct {
+	PrefsEnabled  bool                // preferences available for the current user
+	Pinned        bool                // viewed key is pinned
+	PinnedKeys    []string            // pinned keys the user can still read
+	SavedSearches []store.SavedSearch // saved searches ordered by name
+	PrefsError    string              // rejected pin or saved search, shown in the sidebar
+	RecentEnabled bool                // recent keys available for the current user
+	RecentViewed  []store.AuditRecentKey
+	RecentEdited  []store.AuditRecentKey
+}
+
 // templateData holds data passed to templates.
 type templateData struct {
 	// key display fields
@@ -316,19 +548,27 @@ type templateData struct {
 	Theme    enum.Theme
 	ViewMode enum.ViewMode
 	SortMode enum.SortMode
+	Churn    bool // change frequency column shown, audit log is available
 
 	// form state
-	Search   string
-	Error    string
-	CanForce bool // allow force submit despite error (for validation errors, not conflicts)
+	Search      string
+	SearchError string // invalid search query, shown instead of results
+	OpenKey     string // key of a shared link, its view modal opens with the page
+	Error       string
+	CanForce    bool // allow force submit despite error (for validation errors, not conflicts)
 
 	// auth and permissions
-	AuthEnabled  bool
-	AuditEnabled bool // audit feature enabled (for showing audit link)
-	BaseURL      string
-	CanWrite     bool   // user has write permission (for showing edit controls)
-	Username     string // current logged-in username
-	IsAdmin      bool   // user has admin privileges
+	AuthEnabled     bool
+	AuditEnabled    bool // audit feature enabled (for showing audit link)
+	BaseURL         string
+	CSPNonce        string // nonce for inline scripts, full pages only
+	CanWrite        bool   // user has write permission (for showing edit controls)
+	Username        string // current logged-in username
+	IsAdmin         bool   // user has admin privileges
+	RememberEnabled bool   // login page offers "remember me"
+	PasskeyLogin    bool   // login page offers passwordless passkey login
+	PasskeyStep     string // passkey request options confirming a password login, JSON
+	Remember        bool   // "remember me" chosen with the password, kept for the passkey step
 
 	// modal sizing
 	ModalWidth     int
@@ -336,9 +576,16 @@ type templateData struct {
 
 	// embedded groups
 	conflictData
+	metaData
+	accessData
 	paginationData
 	secretsData
 	historyData
+	diffData
+	sidebarData
+	breakGlassData
+	freezeData
+	treeData
 }
 
 // getTheme returns the current theme from cookie, defaulting to system.
@@ -361,6 +608,18 @@ func (h *Handler) getViewMode(r *http.Request) enum.ViewMode {
 	return enum.ViewModeGrid
 }
 
+// requestPage returns the 1-based page from the query or form. The table view always starts at the first
+// page, next rows are loaded by infinite scroll.
+func requestPage(r *http.Request, viewMode enum.ViewMode) int {
+	if viewMode != enum.ViewModeCards {
+		return 1
+	}
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil && page > 0 {
+		return page
+	}
+	return 1
+}
+
 // getSortMode returns the current sort mode from cookie, defaulting to updated.
 func (h *Handler) getSortMode(r *http.Request) enum.SortMode {
 	if c, err := r.Cookie("sort_mode"); err == nil {
@@ -402,6 +661,8 @@ func (h *Handler) getListParams(w http.ResponseWriter, r *http.Request) listPara
 			p.viewMode = enum.ViewModeCards
 		case strings.Contains(c, "view_mode=grid"):
 			p.viewMode = enum.ViewModeGrid
+		case strings.Contains(c, "view_mode=tree"):
+			p.viewMode = enum.ViewModeTree
 		case strings.Contains(c, "sort_mode=key"):
 			p.sortMode = enum.SortModeKey
 		case strings.Contains(c, "sort_mode=size"):
@@ -463,28 +724,6 @@ func (h *Handler) getAuthor(username string) git.Author {
 	return git.Author{Name: username, Email: username + "@stash"}
 }
 
-// sortByMode sorts a slice by the given mode using a key accessor.
-func (h *Handler) sortByMode(keys []keyWithPermission, mode enum.SortMode) {
-	switch mode {
-	case enum.SortModeKey:
-		sort.Slice(keys, func(i, j int) bool {
-			return strings.ToLower(keys[i].Key) < strings.ToLower(keys[j].Key)
-		})
-	case enum.SortModeSize:
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].Size > keys[j].Size // largest first
-		})
-	case enum.SortModeCreated:
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].CreatedAt.After(keys[j].CreatedAt) // newest first
-		})
-	default: // updated
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].UpdatedAt.After(keys[j].UpdatedAt) // newest first
-		})
-	}
-}
-
 // valueForDisplay converts a byte slice to a display string, detecting binary content.
 func (h *Handler) valueForDisplay(value []byte) (string, bool) {
 	if !utf8.Valid(value) {
@@ -505,102 +744,101 @@ func (h *Handler) valueFromForm(value string, isBinary bool) ([]byte, error) {
 	return []byte(value), nil
 }
 
-// filterBySearch filters keys by search term.
-func (h *Handler) filterBySearch(keys []keyWithPermission, search string) []keyWithPermission {
-	if search == "" {
-		return keys
+// metaFromForm reads the description and labels of the key form. The returned metaData keeps
+// the text as entered, so the form can be re-rendered with it on errors.
+func metaFromForm(r *http.Request) (store.KeyMeta, metaData, error) {
+	md := metaData{Description: strings.TrimSpace(r.FormValue("description")), LabelsText: r.FormValue("labels")}
+	labels, err := store.ParseLabels(md.LabelsText)
+	if err != nil {
+		return store.KeyMeta{}, md, err //nolint:wrapcheck // the message is shown in the form as is
 	}
-	search = strings.ToLower(search)
-	var filtered []keyWithPermission
-	for _, k := range keys {
-		if strings.Contains(strings.ToLower(k.Key), search) {
-			filtered = append(filtered, k)
-		}
+	meta := store.KeyMeta{Description: md.Description, Labels: labels}
+	if err := meta.Validate(); err != nil {
+		return store.KeyMeta{}, md, err //nolint:wrapcheck // the message is shown in the form as is
 	}
-	return filtered
-}
-
-// paginateResult holds the result of pagination.
-type paginateResult struct {
-	keys       []keyWithPermission
-	page       int
-	totalPages int
-	hasPrev    bool
-	hasNext    bool
+	return meta, md, nil
 }
 
-// paginate applies pagination to a slice of keys and returns pagination info.
-// page is 1-based, pageSize is the max keys per page.
-func (h *Handler) paginate(keys []keyWithPermission, page, pageSize int) paginateResult {
-	total := len(keys)
-	if pageSize <= 0 {
-		return paginateResult{keys: keys, page: 1, totalPages: 1}
+// userListQuery limits the list query to keys the user can read, without a filter if auth is disabled.
+func (h *Handler) userListQuery(username string, q store.ListQuery) store.ListQuery {
+	if h.Auth.Enabled() {
+		q.Allow = func(keys []string) []string { return h.Auth.FilterUserKeys(username, keys) }
 	}
+	return q
+}
 
-	totalPages := (total + pageSize - 1) / pageSize
-	if totalPages == 0 {
-		totalPages = 1
-	}
-	if page < 1 {
-		page = 1
+// listPage loads one page of keys visible to the user, filtering, sorting and paging run in the store.
+// page is 1-based, pages past the end are clamped to the last page unless clamp is false (infinite scroll).
+func (h *Handler) listPage(ctx context.Context, username string, q store.ListQuery, page int,
+	clamp bool) ([]keyWithPermission, paginationData, error) {
+	q = h.userListQuery(username, q)
+	page = max(page, 1)
+	if h.PageSize > 0 {
+		q.Limit, q.Offset = h.PageSize, (page-1)*h.PageSize
 	}
-	if page > totalPages {
-		page = totalPages
+	keys, total, err := h.Store.ListPage(ctx, q)
+	if err != nil {
+		return nil, paginationData{}, fmt.Errorf("list keys: %w", err)
 	}
 
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	if start >= total {
-		return paginateResult{page: page, totalPages: totalPages, hasPrev: page > 1}
-	}
-	if end > total {
-		end = total
+	pd := paginationData{Page: 1, TotalPages: 1, TotalKeys: total}
+	if h.PageSize > 0 {
+		pd.TotalPages = max((total+h.PageSize-1)/h.PageSize, 1)
+		pd.Page = page
+		if clamp && page > pd.TotalPages {
+			return h.listPage(ctx, username, q, pd.TotalPages, false)
+		}
+		pd.HasPrev, pd.HasNext = page > 1, page < pd.TotalPages
 	}
-	return paginateResult{
-		keys: keys[start:end], page: page, totalPages: totalPages, hasPrev: page > 1, hasNext: page < totalPages,
+
+	res := make([]keyWithPermission, len(keys))
+	for i, k := range keys {
+		res[i] = keyWithPermission{KeyInfo: k, CanWrite: h.Auth.CheckUserPermission(username, k.Key, true)}
 	}
+	return res, pd, nil
 }
 
-// filterKeysByPermission filters keys based on user permissions and wraps with write permission info.
-func (h *Handler) filterKeysByPermission(username string, keys []store.KeyInfo) []keyWithPermission {
-	keyNames := make([]string, len(keys))
-	for i, k := range keys {
-		keyNames[i] = k.Key
-	}
-	allowedKeys := h.Auth.FilterUserKeys(username, keyNames)
-	allowedSet := make(map[string]bool, len(allowedKeys))
-	for _, k := range allowedKeys {
-		allowedSet[k] = true
-	}
-	var filtered []keyWithPermission
-	for _, k := range keys {
-		if allowedSet[k.Key] {
-			filtered = append(filtered, keyWithPermission{
-				KeyInfo:  k,
-				CanWrite: h.Auth.CheckUserPermission(username, k.Key, true),
-			})
-		}
+// checkReason responds with 428 Precondition Required and returns false if access to the key requires
+// a reason and the request has none. The UI prompts for the reason and repeats the request with it.
+func (h *Handler) checkReason(w http.ResponseWriter, r *http.Request, key string) bool {
+	if h.Reasons == nil || !h.Reasons.RequiresReason(key) || audit.RequestReason(r) != "" {
+		return true
 	}
-	return filtered
+	http.Error(w, "Reason for accessing "+key+" (recorded in the audit log):", http.StatusPreconditionRequired)
+	return false
 }
 
 // logAudit logs an audit entry if audit logging is enabled.
 func (h *Handler) logAudit(r *http.Request, key string, action enum.AuditAction, result enum.AuditResult, valueSize *int) {
-	if h.Audit == nil {
+	if h.Audit == nil && h.Alerts == nil {
 		return
 	}
+	if action == enum.AuditActionRead && h.Canaries != nil && h.Canaries.IsCanary(key) {
+		action = enum.AuditActionCanary
+	}
+	entry := h.auditEntry(r, key, action, result)
+	entry.ValueSize = valueSize
+	if entry.Reason == "" && h.BreakGlass != nil && entry.ActorType == enum.ActorTypeUser {
+		// everything done while elevated is attributed to the break-glass reason
+		if reason, _, ok := h.BreakGlass.ActiveBreakGlass(entry.Actor); ok {
+			entry.Reason = "break-glass: " + reason
+		}
+	}
+	h.recordAudit(r, entry)
+}
 
-	username := h.getCurrentUser(r)
+// auditEntry makes an audit entry for the request of the current user.
+func (h *Handler) auditEntry(r *http.Request, key string, action enum.AuditAction, result enum.AuditResult) store.AuditEntry {
 	actorType := enum.ActorTypePublic
 	actor := "anonymous"
-	if username != "" {
+	if username := h.getCurrentUser(r); username != "" {
 		actor = username
 		actorType = enum.ActorTypeUser
 	}
 
 	ip, _ := realip.Get(r)
 
-	entry := store.AuditEntry{
+	return store.AuditEntry{
 		Timestamp: time.Now(),
 		Action:    action,
 		Key:       key,
@@ -610,10 +848,18 @@ func (h *Handler) logAudit(r *http.Request, key string, action enum.AuditAction,
 		IP:        ip,
 		UserAgent: r.UserAgent(),
 		RequestID: r.Header.Get("X-Request-ID"),
-		ValueSize: valueSize,
+		Reason:    audit.RequestReason(r),
 	}
+}
 
-	if err := h.Audit.LogAudit(r.Context(), entry); err != nil {
-		log.Printf("[WARN] failed to log audit entry for web operation: %v", err)
+// recordAudit stores the audit entry and passes it to alerts.
+func (h *Handler) recordAudit(r *http.Request, entry store.AuditEntry) {
+	if h.Audit != nil {
+		if err := h.Audit.LogAudit(r.Context(), entry); err != nil {
+			log.Printf("[WARN] failed to log audit entry for web operation: %v", err)
+		}
+	}
+	if h.Alerts != nil {
+		h.Alerts.Observe(entry, entry.ActorType == enum.ActorTypeUser && h.Auth.IsAdmin(entry.Actor))
 	}
 }
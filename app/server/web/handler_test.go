@@ -2,11 +2,11 @@ package web
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"slices"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,6 +53,7 @@ func TestHandler_GetViewMode(t *testing.T) {
 		{name: "no cookie returns grid", cookie: "", expected: enum.ViewModeGrid},
 		{name: "grid", cookie: "grid", expected: enum.ViewModeGrid},
 		{name: "cards", cookie: "cards", expected: enum.ViewModeCards},
+		{name: "tree", cookie: "tree", expected: enum.ViewModeTree},
 		{name: "invalid returns grid", cookie: "invalid", expected: enum.ViewModeGrid},
 	}
 
@@ -136,59 +137,6 @@ func TestHandler_CookiePath(t *testing.T) {
 	}
 }
 
-func TestHandler_SortByMode(t *testing.T) {
-	h := newTestHandler(t)
-	now := time.Now()
-
-	t.Run("sort by updated descending", func(t *testing.T) {
-		keys := []keyWithPermission{
-			{KeyInfo: store.KeyInfo{Key: "b", UpdatedAt: now.Add(-2 * time.Hour)}},
-			{KeyInfo: store.KeyInfo{Key: "a", UpdatedAt: now}},
-			{KeyInfo: store.KeyInfo{Key: "c", UpdatedAt: now.Add(-1 * time.Hour)}},
-		}
-		h.sortByMode(keys, enum.SortModeUpdated)
-		assert.Equal(t, "a", keys[0].Key)
-		assert.Equal(t, "c", keys[1].Key)
-		assert.Equal(t, "b", keys[2].Key)
-	})
-
-	t.Run("sort by key ascending", func(t *testing.T) {
-		keys := []keyWithPermission{
-			{KeyInfo: store.KeyInfo{Key: "Zulu"}},
-			{KeyInfo: store.KeyInfo{Key: "alpha"}},
-			{KeyInfo: store.KeyInfo{Key: "Beta"}},
-		}
-		h.sortByMode(keys, enum.SortModeKey)
-		assert.Equal(t, "alpha", keys[0].Key)
-		assert.Equal(t, "Beta", keys[1].Key)
-		assert.Equal(t, "Zulu", keys[2].Key)
-	})
-
-	t.Run("sort by size descending", func(t *testing.T) {
-		keys := []keyWithPermission{
-			{KeyInfo: store.KeyInfo{Key: "small", Size: 10}},
-			{KeyInfo: store.KeyInfo{Key: "large", Size: 1000}},
-			{KeyInfo: store.KeyInfo{Key: "medium", Size: 100}},
-		}
-		h.sortByMode(keys, enum.SortModeSize)
-		assert.Equal(t, "large", keys[0].Key)
-		assert.Equal(t, "medium", keys[1].Key)
-		assert.Equal(t, "small", keys[2].Key)
-	})
-
-	t.Run("sort by created descending", func(t *testing.T) {
-		keys := []keyWithPermission{
-			{KeyInfo: store.KeyInfo{Key: "old", CreatedAt: now.Add(-2 * time.Hour)}},
-			{KeyInfo: store.KeyInfo{Key: "new", CreatedAt: now}},
-			{KeyInfo: store.KeyInfo{Key: "mid", CreatedAt: now.Add(-1 * time.Hour)}},
-		}
-		h.sortByMode(keys, enum.SortModeCreated)
-		assert.Equal(t, "new", keys[0].Key)
-		assert.Equal(t, "mid", keys[1].Key)
-		assert.Equal(t, "old", keys[2].Key)
-	})
-}
-
 func TestHandler_ValueForDisplay(t *testing.T) {
 	h := newTestHandler(t)
 
@@ -233,141 +181,110 @@ func TestHandler_ValueFromForm(t *testing.T) {
 	})
 }
 
-func TestHandler_FilterBySearch(t *testing.T) {
-	h := newTestHandler(t)
-
-	keys := []keyWithPermission{
-		{KeyInfo: store.KeyInfo{Key: "config/db"}},
-		{KeyInfo: store.KeyInfo{Key: "config/app"}},
-		{KeyInfo: store.KeyInfo{Key: "secrets/api"}},
+func TestHandler_ListPage(t *testing.T) {
+	all := make([]store.KeyInfo, 10)
+	for i := range all {
+		all[i] = store.KeyInfo{Key: "key" + string(rune('a'+i))}
+	}
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			keys := all
+			if q.Allow != nil {
+				names := make([]string, len(all))
+				for i, k := range all {
+					names[i] = k.Key
+				}
+				allowed := q.Allow(names)
+				keys = all[:len(allowed)]
+			}
+			if q.Limit == 0 {
+				return keys, len(keys), nil
+			}
+			start, end := min(q.Offset, len(keys)), min(q.Offset+q.Limit, len(keys))
+			return keys[start:end], len(keys), nil
+		},
 	}
 
-	t.Run("empty search returns all", func(t *testing.T) {
-		result := h.filterBySearch(keys, "")
-		assert.Len(t, result, 3)
-	})
-
-	t.Run("filters by substring", func(t *testing.T) {
-		result := h.filterBySearch(keys, "config")
-		assert.Len(t, result, 2)
-	})
-
-	t.Run("case insensitive", func(t *testing.T) {
-		result := h.filterBySearch(keys, "CONFIG")
-		assert.Len(t, result, 2)
-	})
-
-	t.Run("no matches returns empty", func(t *testing.T) {
-		result := h.filterBySearch(keys, "notfound")
-		assert.Empty(t, result)
-	})
-}
-
-func TestHandler_Paginate(t *testing.T) {
-	h := newTestHandler(t)
-
-	makeKeys := func(n int) []keyWithPermission {
-		keys := make([]keyWithPermission, n)
-		for i := range keys {
-			keys[i] = keyWithPermission{KeyInfo: store.KeyInfo{Key: "key" + string(rune('a'+i))}}
+	newHandler := func(pageSize int, authEnabled bool) *Handler {
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return authEnabled },
+			FilterUserKeysFunc:      func(_ string, keys []string) []string { return keys[:4] },
+			CheckUserPermissionFunc: func(_, key string, _ bool) bool { return key == "keya" },
 		}
-		return keys
+		h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{PageSize: pageSize})
+		require.NoError(t, err)
+		return h
 	}
 
 	tests := []struct {
 		name      string
-		keys      []keyWithPermission
-		page      int
 		pageSize  int
-		wantLen   int
-		wantPage  int
-		wantTotal int
-		wantPrev  bool
-		wantNext  bool
+		auth      bool
+		page      int
+		clamp     bool
+		wantKeys  []string
+		wantPages paginationData
 	}{
-		{name: "first", keys: makeKeys(10), page: 1, pageSize: 3, wantLen: 3, wantPage: 1, wantTotal: 4, wantPrev: false, wantNext: true},
-		{name: "middle", keys: makeKeys(10), page: 2, pageSize: 3, wantLen: 3, wantPage: 2, wantTotal: 4, wantPrev: true, wantNext: true},
-		{name: "last partial", keys: makeKeys(10), page: 4, pageSize: 3, wantLen: 1, wantPage: 4, wantTotal: 4, wantPrev: true, wantNext: false},
-		{name: "beyond total", keys: makeKeys(10), page: 10, pageSize: 3, wantLen: 1, wantPage: 4, wantTotal: 4, wantPrev: true, wantNext: false},
-		{name: "page zero", keys: makeKeys(10), page: 0, pageSize: 3, wantLen: 3, wantPage: 1, wantTotal: 4, wantPrev: false, wantNext: true},
-		{name: "negative", keys: makeKeys(10), page: -5, pageSize: 3, wantLen: 3, wantPage: 1, wantTotal: 4, wantPrev: false, wantNext: true},
-		{name: "empty keys", keys: nil, page: 1, pageSize: 3, wantLen: 0, wantPage: 1, wantTotal: 1, wantPrev: false, wantNext: false},
-		{name: "exact fit", keys: makeKeys(6), page: 2, pageSize: 3, wantLen: 3, wantPage: 2, wantTotal: 2, wantPrev: true, wantNext: false},
-		{name: "single", keys: makeKeys(2), page: 1, pageSize: 3, wantLen: 2, wantPage: 1, wantTotal: 1, wantPrev: false, wantNext: false},
-		{name: "size zero", keys: makeKeys(5), page: 1, pageSize: 0, wantLen: 5, wantPage: 1, wantTotal: 1, wantPrev: false, wantNext: false},
+		{name: "first", pageSize: 3, page: 1, clamp: true, wantKeys: []string{"keya", "keyb", "keyc"},
+			wantPages: paginationData{Page: 1, TotalPages: 4, TotalKeys: 10, HasNext: true}},
+		{name: "middle", pageSize: 3, page: 2, clamp: true, wantKeys: []string{"keyd", "keye", "keyf"},
+			wantPages: paginationData{Page: 2, TotalPages: 4, TotalKeys: 10, HasPrev: true, HasNext: true}},
+		{name: "last partial", pageSize: 3, page: 4, clamp: true, wantKeys: []string{"keyj"},
+			wantPages: paginationData{Page: 4, TotalPages: 4, TotalKeys: 10, HasPrev: true}},
+		{name: "beyond total clamped", pageSize: 3, page: 10, clamp: true, wantKeys: []string{"keyj"},
+			wantPages: paginationData{Page: 4, TotalPages: 4, TotalKeys: 10, HasPrev: true}},
+		{name: "beyond total not clamped", pageSize: 3, page: 10, clamp: false, wantKeys: []string{},
+			wantPages: paginationData{Page: 10, TotalPages: 4, TotalKeys: 10, HasPrev: true}},
+		{name: "page zero", pageSize: 3, page: 0, clamp: true, wantKeys: []string{"keya", "keyb", "keyc"},
+			wantPages: paginationData{Page: 1, TotalPages: 4, TotalKeys: 10, HasNext: true}},
+		{name: "size zero", pageSize: 0, page: 2, clamp: true, wantKeys: []string{"keya", "keyb", "keyc", "keyd", "keye",
+			"keyf", "keyg", "keyh", "keyi", "keyj"}, wantPages: paginationData{Page: 1, TotalPages: 1, TotalKeys: 10}},
+		{name: "auth filters in store", pageSize: 3, auth: true, page: 2, clamp: true, wantKeys: []string{"keyd"},
+			wantPages: paginationData{Page: 2, TotalPages: 2, TotalKeys: 4, HasPrev: true}},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			pr := h.paginate(tc.keys, tc.page, tc.pageSize)
-			assert.Len(t, pr.keys, tc.wantLen, "result length")
-			assert.Equal(t, tc.wantPage, pr.page, "page")
-			assert.Equal(t, tc.wantTotal, pr.totalPages, "totalPages")
-			assert.Equal(t, tc.wantPrev, pr.hasPrev, "hasPrev")
-			assert.Equal(t, tc.wantNext, pr.hasNext, "hasNext")
-		})
-	}
-}
-
-func TestHandler_FilterKeysByPermission(t *testing.T) {
-	auth := &mocks.AuthProviderMock{
-		FilterUserKeysFunc: func(username string, keys []string) []string {
-			if username == "admin" {
-				return keys // admin sees all
-			}
-			if username == "scoped" {
-				// scoped user sees only app/*
-				var filtered []string
-				for _, k := range keys {
-					if len(k) >= 4 && k[:4] == "app/" {
-						filtered = append(filtered, k)
-					}
-				}
-				return filtered
-			}
-			return nil // unknown user sees nothing
-		},
-		CheckUserPermissionFunc: func(username, key string, write bool) bool {
-			if username == "admin" {
-				return true
+			h := newHandler(tc.pageSize, tc.auth)
+			keys, pd, err := h.listPage(t.Context(), "user", store.ListQuery{}, tc.page, tc.clamp)
+			require.NoError(t, err)
+			names := make([]string, len(keys))
+			for i, k := range keys {
+				names[i] = k.Key
+				assert.Equal(t, k.Key == "keya", k.CanWrite, "write permission for %s", k.Key)
 			}
-			if username == "readonly" {
-				return !write // can read but not write
-			}
-			if username == "scoped" {
-				return len(key) >= 4 && key[:4] == "app/"
-			}
-			return false
-		},
-	}
-	h := newTestHandlerWithAuth(t, auth)
-
-	keys := []store.KeyInfo{
-		{Key: "app/config", Size: 50},
-		{Key: "app/db", Size: 100},
-		{Key: "other/secret", Size: 200},
+			assert.Equal(t, tc.wantKeys, names)
+			assert.Equal(t, tc.wantPages, pd)
+		})
 	}
 
-	t.Run("admin sees all keys with write permission", func(t *testing.T) {
-		result := h.filterKeysByPermission("admin", keys)
-		assert.Len(t, result, 3)
-		for _, k := range result {
-			assert.True(t, k.CanWrite, "admin should have write permission for %s", k.Key)
-		}
-	})
-
-	t.Run("scoped sees only allowed prefix with write permission", func(t *testing.T) {
-		result := h.filterKeysByPermission("scoped", keys)
-		assert.Len(t, result, 2)
-		for _, k := range result {
-			assert.True(t, k.CanWrite, "scoped should have write permission for %s", k.Key)
-			assert.Contains(t, k.Key, "app/")
-		}
+	t.Run("allow set only with auth enabled", func(t *testing.T) {
+		var got store.ListQuery
+		st := &mocks.KVStoreMock{ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			got = q
+			return nil, 0, nil
+		}}
+		h, err := New(Deps{Store: st, Auth: &mocks.AuthProviderMock{EnabledFunc: func() bool { return false }},
+			Validator: defaultValidatorMock()}, Config{PageSize: 5})
+		require.NoError(t, err)
+		_, _, err = h.listPage(t.Context(), "", store.ListQuery{Search: "app", Sort: enum.SortModeSize}, 3, false)
+		require.NoError(t, err)
+		assert.Nil(t, got.Allow)
+		assert.Equal(t, "app", got.Search)
+		assert.Equal(t, enum.SortModeSize, got.Sort)
+		assert.Equal(t, 5, got.Limit)
+		assert.Equal(t, 10, got.Offset)
 	})
 
-	t.Run("unknown user sees nothing", func(t *testing.T) {
-		result := h.filterKeysByPermission("unknown", keys)
-		assert.Empty(t, result)
+	t.Run("store error", func(t *testing.T) {
+		st := &mocks.KVStoreMock{ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return nil, 0, errors.New("db error")
+		}}
+		h, err := New(Deps{Store: st, Auth: &mocks.AuthProviderMock{EnabledFunc: func() bool { return false }},
+			Validator: defaultValidatorMock()}, Config{})
+		require.NoError(t, err)
+		_, _, err = h.listPage(t.Context(), "", store.ListQuery{}, 1, true)
+		require.ErrorContains(t, err, "db error")
 	})
 }
 
@@ -494,7 +411,7 @@ func defaultValidatorMock() *mocks.ValidatorMock {
 func newTestHandler(t *testing.T) *Handler {
 	t.Helper()
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -503,6 +420,8 @@ func newTestHandler(t *testing.T) *Handler {
 		FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
 		CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
 		UserCanWriteFunc:        func(username string) bool { return true },
+		RememberEnabledFunc:     func() bool { return false },
+		PasswordlessEnabledFunc: func() bool { return false },
 	}
 	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{})
 	require.NoError(t, err)
@@ -513,7 +432,7 @@ func newTestHandler(t *testing.T) *Handler {
 func newTestHandlerWithBaseURL(t *testing.T, baseURL string) *Handler {
 	t.Helper()
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -554,7 +473,7 @@ func TestHandler_GetAuthor(t *testing.T) {
 func newTestHandlerWithAuth(t *testing.T, auth AuthProvider) *Handler {
 	t.Helper()
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{})
@@ -566,9 +485,11 @@ func newTestHandlerWithAuth(t *testing.T, auth AuthProvider) *Handler {
 func newTestHandlerWithGit(t *testing.T, gitSvc GitService) *Handler {
 	t.Helper()
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
-		GetWithFormatFunc:  func(_ context.Context, key string) ([]byte, string, error) { return []byte("value"), "text", nil },
-		SetFunc:            func(_ context.Context, key string, value []byte, format string) (bool, error) { return true, nil },
+		ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) { return []byte("value"), "text", nil },
+		SetWithOptionsFunc: func(_ context.Context, key string, value []byte, format string, _ store.SetOptions) (bool, error) {
+			return true, nil
+		},
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -58,3 +58,54 @@ func (h *Highlighter) Code(code, format string) template.HTML {
 
 	return template.HTML(buf.String()) //nolint:gosec // chroma output is safe
 }
+
+// Lines highlights code based on format and returns its lines, for views showing lines separately, like diffs.
+// Tokens are split at line ends, so a multi-line string keeps its color on each line. Lines are rendered as
+// spans with the classes Code uses, and are plain escaped text for "text" format or unknown formats.
+func (h *Highlighter) Lines(code, format string) []template.HTML {
+	code = strings.TrimSuffix(code, "\n")
+	if code == "" {
+		return nil
+	}
+	plain := func() []template.HTML {
+		lines := strings.Split(code, "\n")
+		res := make([]template.HTML, len(lines))
+		for i, l := range lines {
+			res[i] = template.HTML(html.EscapeString(l)) //nolint:gosec // escaped
+		}
+		return res
+	}
+	if format == "" || format == "text" {
+		return plain()
+	}
+	lexer := lexers.Get(strings.ToUpper(format))
+	if lexer == nil {
+		return plain()
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code+"\n")
+	if err != nil {
+		return plain()
+	}
+
+	tokenLines := chroma.SplitTokensIntoLines(iterator.Tokens())
+	res := make([]template.HTML, 0, len(tokenLines))
+	for _, tokens := range tokenLines {
+		var sb strings.Builder
+		for _, t := range tokens {
+			text := html.EscapeString(strings.TrimSuffix(t.Value, "\n"))
+			if text == "" {
+				continue
+			}
+			if class := chroma.StandardTypes[t.Type]; class != "" {
+				sb.WriteString(`<span class="` + class + `">` + text + "</span>")
+				continue
+			}
+			sb.WriteString(text)
+		}
+		res = append(res, template.HTML(sb.String())) //nolint:gosec // token values are escaped
+	}
+	if want := strings.Count(code, "\n") + 1; len(res) != want {
+		return plain() // lexer dropped or added lines, keep the line numbers right
+	}
+	return res
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHighlighter_Code(t *testing.T) {
@@ -92,3 +93,26 @@ func TestHighlighter_Code(t *testing.T) {
 		assert.Contains(t, string(result), "items")
 	})
 }
+
+func TestHighlighter_Lines(t *testing.T) {
+	h := NewHighlighter()
+
+	t.Run("text format escapes lines", func(t *testing.T) {
+		lines := h.Lines("a<b\nc\n", "text")
+		require.Len(t, lines, 2)
+		assert.Equal(t, "a&lt;b", string(lines[0]))
+		assert.Equal(t, "c", string(lines[1]))
+	})
+
+	t.Run("json format highlights each line", func(t *testing.T) {
+		lines := h.Lines("{\n  \"name\": \"test\"\n}", "json")
+		require.Len(t, lines, 3)
+		assert.Contains(t, string(lines[1]), `<span class="`)
+		assert.Contains(t, string(lines[1]), "name")
+		assert.NotContains(t, string(lines[1]), "\n")
+	})
+
+	t.Run("empty code has no lines", func(t *testing.T) {
+		assert.Empty(t, h.Lines("", "yaml"))
+	})
+}
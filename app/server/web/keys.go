@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,9 @@ import (
 
 	"github.com/umputun/stash/app/enum"
 	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/internal/inventory"
+	"github.com/umputun/stash/app/server/internal/ownership"
+	"github.com/umputun/stash/app/server/internal/search"
 	"github.com/umputun/stash/app/store"
 	"github.com/umputun/stash/lib/stash"
 )
@@ -21,66 +25,121 @@ import (
 // handleKeyList renders the keys table partial (for HTMX).
 func (h *Handler) handleKeyList(w http.ResponseWriter, r *http.Request) {
 	params := h.getListParams(w, r)
-
-	keys, err := h.Store.List(r.Context(), params.secretsFilter)
+	data, err := h.keyListData(r, params, requestPage(r, params.viewMode), true)
 	if err != nil {
 		log.Printf("[ERROR] failed to list keys: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("HX-Replace-Url", h.listURL(data)) // keep the address bar a shareable link to the list
+	if err := h.tmpl.ExecuteTemplate(w, "keys-table", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
 
-	username := h.getCurrentUser(r)
-	filteredKeys := h.filterKeysByPermission(username, keys)
-
-	// check URL query first, then form values (for POST requests with hx-include)
-	search := r.URL.Query().Get("search")
-	if search == "" {
-		search = r.FormValue("search")
-	}
-	filteredKeys = h.filterBySearch(filteredKeys, search)
-	h.sortByMode(filteredKeys, params.sortMode)
-
-	// pagination - check query then form value
-	totalKeys := len(filteredKeys)
-	page := 1
-	if p := r.URL.Query().Get("page"); p != "" {
-		if parsed, parseErr := strconv.Atoi(p); parseErr == nil && parsed > 0 {
-			page = parsed
-		}
-	} else if p := r.FormValue("page"); p != "" {
-		if parsed, parseErr := strconv.Atoi(p); parseErr == nil && parsed > 0 {
-			page = parsed
-		}
+// handleKeyRows renders the next page of table rows for infinite scroll, with a loader row if more follow.
+func (h *Handler) handleKeyRows(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
 	}
-	pr := h.paginate(filteredKeys, page, h.PageSize)
+	data, err := h.keyListData(r, h.getListParams(w, r), page, false)
+	if err != nil {
+		log.Printf("[ERROR] failed to list keys: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "keys-rows", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
 
+// keyListData loads a page of keys for the list partials, with search query from the query or form values
+// (for POST requests with hx-include). An invalid search query is reported in SearchError, not as error.
+// The tree view loads the level of the ?prefix= folder instead of a page.
+func (h *Handler) keyListData(r *http.Request, params listParams, page int, clamp bool) (templateData, error) {
+	query := r.URL.Query().Get("search")
+	if query == "" {
+		query = r.FormValue("search")
+	}
+	username := h.getCurrentUser(r)
 	data := templateData{
-		Keys:     pr.keys,
-		Search:   search,
+		Search:   query,
 		Theme:    h.getTheme(r),
 		ViewMode: params.viewMode,
 		SortMode: params.sortMode,
+		Churn:    h.Changes != nil,
 		BaseURL:  h.BaseURL,
 		CanWrite: h.Auth.UserCanWrite(username),
 		Username: username,
-		paginationData: paginationData{
-			Page:       pr.page,
-			TotalPages: pr.totalPages,
-			TotalKeys:  totalKeys,
-			HasPrev:    pr.hasPrev,
-			HasNext:    pr.hasNext,
-		},
 		secretsData: secretsData{
 			SecretsFilter:  params.secretsFilter,
 			SecretsEnabled: h.Store.SecretsEnabled(),
 		},
 	}
+	q, err := search.Parse(query, time.Now())
+	if err != nil {
+		data.SearchError = fmt.Sprintf("invalid search: %v (%s)", err, search.Help)
+		return data, nil
+	}
+	q.Filter, q.Sort = params.secretsFilter, params.sortMode
+	if params.viewMode == enum.ViewModeTree {
+		data.CanFreeze = h.canFreeze(username)
+		data.Keys, data.treeData, data.paginationData, err = h.listTree(r.Context(), username, q, r.URL.Query().Get("prefix"))
+	} else {
+		data.Keys, data.paginationData, err = h.listPage(r.Context(), username, q, page, clamp)
+	}
+	if err != nil {
+		return templateData{}, err
+	}
+	h.addChurn(r.Context(), data.Keys)
+	return data, nil
+}
 
-	if err := h.tmpl.ExecuteTemplate(w, "keys-table", data); err != nil {
-		log.Printf("[ERROR] failed to execute template: %v", err)
+// handleKeyExport downloads metadata of the listed keys as CSV. It follows the current secrets
+// filter and search, and never includes values.
+func (h *Handler) handleKeyExport(w http.ResponseWriter, r *http.Request) {
+	secretsFilter := h.getSecretsFilter(r)
+	q, err := search.Parse(r.URL.Query().Get("search"), time.Now())
+	if err != nil {
+		http.Error(w, "invalid search: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Filter, q.Sort = secretsFilter, h.getSortMode(r)
+	keys, _, err := h.Store.ListPage(r.Context(), h.userListQuery(h.getCurrentUser(r), q))
+	if err != nil {
+		log.Printf("[ERROR] failed to list keys: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	kind := "keys"
+	if secretsFilter == enum.SecretsFilterSecretsOnly {
+		kind = "secrets"
+	}
+	w.Header().Set("Content-Type", inventory.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+inventory.Filename(kind, time.Now())+`"`)
+	if err := inventory.WriteCSV(w, keys); err != nil {
+		log.Printf("[WARN] failed to write keys csv: %v", err)
 	}
 }
 
+// storeErrorMessage returns the UI message for store errors shown to the user: secrets that can't be used,
+// because no key is configured or the server waits for unseal shares, and keys frozen for an incident.
+func storeErrorMessage(err error) (string, bool) {
+	var frozen *store.FrozenError
+	switch {
+	case errors.As(err, &frozen):
+		return "Frozen: " + frozen.Error(), true
+	case errors.Is(err, store.ErrSecretsNotConfigured):
+		return "Secrets not configured: keys with 'secrets' in path require --secrets.key", true
+	case errors.Is(err, store.ErrSealed):
+		return "Secrets sealed: the server waits for unseal shares, try again after unseal", true
+	}
+	return "", false
+}
+
 // handleKeyNew renders the new key form.
 func (h *Handler) handleKeyNew(w http.ResponseWriter, r *http.Request) {
 	// check if user can write at all
@@ -114,11 +173,14 @@ func (h *Handler) handleKeyView(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
 
 	value, format, err := h.Store.GetWithFormat(r.Context(), key)
 	if err != nil {
-		if errors.Is(err, store.ErrSecretsNotConfigured) {
-			h.renderError(w, "Secrets not configured: keys with 'secrets' in path require --secrets.key")
+		if msg, ok := storeErrorMessage(err); ok {
+			h.renderError(w, msg)
 			return
 		}
 		if errors.Is(err, store.ErrNotFound) {
@@ -143,6 +205,13 @@ func (h *Handler) handleKeyView(w http.ResponseWriter, r *http.Request) {
 		highlightedVal = h.highlighter.Code(displayValue, format)
 	}
 
+	var meta metaData
+	var access accessData
+	if info, infoErr := h.Store.GetInfo(r.Context(), key); infoErr == nil {
+		meta = metaData{Description: info.Description, Labels: info.Labels}
+		access.AccessVisible = h.accessVisible(username, info)
+	}
+
 	data := templateData{
 		Key:            key,
 		Value:          displayValue,
@@ -156,7 +225,16 @@ func (h *Handler) handleKeyView(w http.ResponseWriter, r *http.Request) {
 		TextareaHeight: textareaHeight,
 		CanWrite:       h.Auth.CheckUserPermission(username, key, true),
 		Username:       username,
-		historyData:    historyData{GitEnabled: h.Git != nil},
+		metaData:       meta,
+		accessData:     access,
+		historyData:    historyData{GitEnabled: h.Git != nil && h.historyVisible(username, key)},
+	}
+	if h.Prefs != nil && username != "" {
+		pinned, err := h.Prefs.PinnedKeys(r.Context(), username)
+		if err != nil {
+			log.Printf("[WARN] failed to get pinned keys: %v", err)
+		}
+		data.PrefsEnabled, data.Pinned = true, slices.Contains(pinned, key)
 	}
 
 	if err := h.tmpl.ExecuteTemplate(w, "view", data); err != nil {
@@ -174,11 +252,14 @@ func (h *Handler) handleKeyEdit(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
 
 	value, format, err := h.Store.GetWithFormat(r.Context(), key)
 	if err != nil {
-		if errors.Is(err, store.ErrSecretsNotConfigured) {
-			h.renderError(w, "Secrets not configured: keys with 'secrets' in path require --secrets.key")
+		if msg, ok := storeErrorMessage(err); ok {
+			h.renderError(w, msg)
 			return
 		}
 		if errors.Is(err, store.ErrNotFound) {
@@ -197,10 +278,12 @@ func (h *Handler) handleKeyEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get key info for conflict detection (updated_at timestamp as nanoseconds)
+	// get key info for conflict detection (updated_at timestamp as nanoseconds) and the form metadata
 	var updatedAt int64
+	var meta metaData
 	if info, infoErr := h.Store.GetInfo(r.Context(), key); infoErr == nil {
 		updatedAt = info.UpdatedAt.UnixNano()
+		meta = metaData{Description: info.Description, LabelsText: store.FormatLabels(info.Labels)}
 	}
 
 	displayValue, isBinary := h.valueForDisplay(value)
@@ -218,6 +301,7 @@ func (h *Handler) handleKeyEdit(w http.ResponseWriter, r *http.Request) {
 		CanWrite:       true,
 		Username:       username,
 		conflictData:   conflictData{UpdatedAt: updatedAt},
+		metaData:       meta,
 	}
 
 	if err := h.tmpl.ExecuteTemplate(w, "form", data); err != nil {
@@ -239,6 +323,7 @@ func (h *Handler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 	if !h.Validator.IsValidFormat(format) {
 		format = stash.FormatText.String()
 	}
+	meta, formMeta, metaErr := metaFromForm(r)
 
 	if key == "" {
 		http.Error(w, "key is required", http.StatusBadRequest)
@@ -251,7 +336,18 @@ func (h *Handler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 		h.renderFormError(w, templateData{
 			Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
 			IsNew: true, Error: "Access denied: you don't have write permission for this key prefix",
-			BaseURL: h.BaseURL, CanWrite: false, Username: username,
+			BaseURL: h.BaseURL, CanWrite: false, Username: username, metaData: formMeta,
+		})
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
+	if metaErr != nil {
+		h.renderFormError(w, templateData{
+			Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
+			IsNew: true, Error: metaErr.Error(),
+			BaseURL: h.BaseURL, CanWrite: true, Username: username, metaData: formMeta,
 		})
 		return
 	}
@@ -259,11 +355,11 @@ func (h *Handler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 	// check if key already exists
 	_, _, getErr := h.Store.GetWithFormat(r.Context(), key)
 	if getErr != nil && !errors.Is(getErr, store.ErrNotFound) {
-		if errors.Is(getErr, store.ErrSecretsNotConfigured) {
+		if msg, ok := storeErrorMessage(getErr); ok {
 			h.renderFormError(w, templateData{
 				Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
-				IsNew: true, Error: "Secrets not configured: keys with 'secrets' in path require --secrets.key",
-				BaseURL: h.BaseURL, CanWrite: true, Username: username,
+				IsNew: true, Error: msg,
+				BaseURL: h.BaseURL, CanWrite: true, Username: username, metaData: formMeta,
 			})
 			return
 		}
@@ -275,7 +371,7 @@ func (h *Handler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 		h.renderFormError(w, templateData{
 			Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
 			IsNew: true, Error: fmt.Sprintf("key %q already exists", key),
-			BaseURL: h.BaseURL, CanWrite: true, Username: username,
+			BaseURL: h.BaseURL, CanWrite: true, Username: username, metaData: formMeta,
 		})
 		return
 	}
@@ -293,18 +389,26 @@ func (h *Handler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 			h.renderFormError(w, templateData{
 				Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
 				IsNew: true, Error: err.Error(), CanForce: true,
-				BaseURL: h.BaseURL, CanWrite: true, Username: username,
+				BaseURL: h.BaseURL, CanWrite: true, Username: username, metaData: formMeta,
 			})
 			return
 		}
 	}
 
-	if _, err := h.Store.Set(r.Context(), key, value, format); err != nil {
-		if errors.Is(err, store.ErrSecretsNotConfigured) {
+	// owner and metadata are written with the value
+	opts := store.SetOptions{Owner: ownership.Owner(h.getIdentityForLog(r))}
+	if len(meta.Labels) > 0 {
+		opts.Labels = meta.Labels
+	}
+	if meta.Description != "" {
+		opts.Description = &meta.Description
+	}
+	if _, err := h.Store.SetWithOptions(r.Context(), key, value, format, opts); err != nil {
+		if msg, ok := storeErrorMessage(err); ok {
 			h.renderFormError(w, templateData{
 				Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
-				IsNew: true, Error: "Secrets not configured: keys with 'secrets' in path require --secrets.key",
-				BaseURL: h.BaseURL, CanWrite: true, Username: username,
+				IsNew: true, Error: msg,
+				BaseURL: h.BaseURL, CanWrite: true, Username: username, metaData: formMeta,
 			})
 			return
 		}
@@ -336,6 +440,7 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 	if !h.Validator.IsValidFormat(format) {
 		format = stash.FormatText.String()
 	}
+	meta, formMeta, metaErr := metaFromForm(r)
 
 	// check write permission
 	username := h.getCurrentUser(r)
@@ -345,7 +450,18 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 			Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
 			IsBinary: isBinary, IsNew: false, Error: "Access denied: you don't have write permission for this key",
 			BaseURL: h.BaseURL, ModalWidth: modalWidth, TextareaHeight: textareaHeight,
-			CanWrite: false, Username: username,
+			CanWrite: false, Username: username, metaData: formMeta,
+		})
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
+	formUpdatedAt, _ := strconv.ParseInt(r.FormValue("updated_at"), 10, 64)
+	if metaErr != nil {
+		h.renderValidationError(w, validationErrorParams{
+			Key: key, Value: valueStr, Format: format, IsBinary: isBinary,
+			Username: username, Error: metaErr.Error(), UpdatedAt: formUpdatedAt, Meta: formMeta,
 		})
 		return
 	}
@@ -358,12 +474,11 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 
 	// validate value unless force flag is set or value is binary
 	force := r.FormValue("force") == "true"
-	formUpdatedAt, _ := strconv.ParseInt(r.FormValue("updated_at"), 10, 64)
 	if !force && !isBinary {
 		if validationErr := h.Validator.Validate(format, value); validationErr != nil {
 			h.renderValidationError(w, validationErrorParams{
 				Key: key, Value: valueStr, Format: format, IsBinary: isBinary,
-				Username: username, Error: validationErr.Error(), UpdatedAt: formUpdatedAt,
+				Username: username, Error: validationErr.Error(), UpdatedAt: formUpdatedAt, Meta: formMeta,
 			})
 			return
 		}
@@ -377,14 +492,14 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.Store.SetWithVersion(r.Context(), key, value, format, expectedVersion); err != nil {
-		if errors.Is(err, store.ErrSecretsNotConfigured) {
+		if msg, ok := storeErrorMessage(err); ok {
 			modalWidth, textareaHeight := h.calculateModalDimensions(valueStr)
 			h.renderFormError(w, templateData{
 				Key: key, Value: valueStr, Format: format, Formats: h.Validator.SupportedFormats(),
 				IsBinary: isBinary, IsNew: false,
-				Error:   "Secrets not configured: keys with 'secrets' in path require --secrets.key",
+				Error:   msg,
 				BaseURL: h.BaseURL, ModalWidth: modalWidth, TextareaHeight: textareaHeight,
-				CanWrite: true, Username: username,
+				CanWrite: true, Username: username, metaData: formMeta,
 			})
 			return
 		}
@@ -392,7 +507,7 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 		if errors.As(err, &conflictErr) {
 			h.renderConflictError(w, conflictErrorParams{
 				Key: key, Value: valueStr, Format: format, IsBinary: isBinary,
-				Username: username, FormUpdatedAt: formUpdatedAt, ConflictErr: conflictErr,
+				Username: username, FormUpdatedAt: formUpdatedAt, ConflictErr: conflictErr, Meta: formMeta,
 			})
 			return
 		}
@@ -406,6 +521,14 @@ func (h *Handler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 	h.logAudit(r, key, enum.AuditActionUpdate, enum.AuditResultSuccess, &valueSize)
 	h.commitToGit(key, value, "set", format, username)
 	h.publishEvent(key, enum.AuditActionUpdate)
+	// the form always sends both fields, cleared ones remove the metadata
+	if r.Form.Has("labels") || r.Form.Has("description") {
+		if err := h.Store.SetMeta(r.Context(), key, meta); err != nil {
+			log.Printf("[ERROR] failed to set metadata of %s: %v", key, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
 	h.handleKeyList(w, r) // return updated keys table
 }
 
@@ -419,12 +542,33 @@ func (h *Handler) handleKeyDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "access denied", http.StatusForbidden)
 		return
 	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
+	if h.Owners != nil {
+		allowed, err := h.Owners.CanDelete(r.Context(), key, h.getIdentityForLog(r), h.Auth.IsAdmin(username))
+		if err != nil {
+			log.Printf("[ERROR] failed to check owner of %s: %v", key, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "only the owner or an admin can delete this key", http.StatusForbidden)
+			return
+		}
+	}
 
 	if err := h.Store.Delete(r.Context(), key); err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, "key not found", http.StatusNotFound)
 			return
 		}
+		if msg, ok := storeErrorMessage(err); ok {
+			w.Header().Set("HX-Retarget", "#modal-content")
+			w.Header().Set("HX-Reswap", "innerHTML")
+			h.renderError(w, msg)
+			return
+		}
 		log.Printf("[ERROR] failed to delete key: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -460,6 +604,13 @@ func (h *Handler) handleKeyHistory(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	if !h.historyVisible(username, key) {
+		http.Error(w, "history of the key is restricted to admins", http.StatusForbidden)
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
 
 	history, err := h.Git.History(key, 50)
 	if err != nil {
@@ -499,6 +650,13 @@ func (h *Handler) handleKeyRevision(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	if !h.historyVisible(username, key) {
+		http.Error(w, "history of the key is restricted to admins", http.StatusForbidden)
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
 
 	if rev == "" {
 		http.Error(w, "revision required", http.StatusBadRequest)
@@ -556,6 +714,13 @@ func (h *Handler) handleKeyRestore(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	if !h.historyVisible(username, key) {
+		http.Error(w, "history of the key is restricted to admins", http.StatusForbidden)
+		return
+	}
+	if !h.checkReason(w, r, key) {
+		return
+	}
 
 	rev := r.FormValue("rev")
 	if rev == "" {
@@ -571,10 +736,13 @@ func (h *Handler) handleKeyRestore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// save to store
-	if _, err := h.Store.Set(r.Context(), key, value, format); err != nil {
-		if errors.Is(err, store.ErrSecretsNotConfigured) {
-			h.renderError(w, "Secrets not configured: keys with 'secrets' in path require --secrets.key")
+	// save to store, restore of a deleted key creates it again
+	opts := store.SetOptions{Owner: ownership.Owner(h.getIdentityForLog(r))}
+	if _, err := h.Store.SetWithOptions(r.Context(), key, value, format, opts); err != nil {
+		if msg, ok := storeErrorMessage(err); ok {
+			w.Header().Set("HX-Retarget", "#modal-content")
+			w.Header().Set("HX-Reswap", "innerHTML")
+			h.renderError(w, msg)
 			return
 		}
 		log.Printf("[ERROR] failed to set key %s: %v", key, err)
@@ -590,6 +758,14 @@ func (h *Handler) handleKeyRestore(w http.ResponseWriter, r *http.Request) {
 	h.handleKeyList(w, r) // return updated keys table
 }
 
+// historyVisible reports whether the user can see previous revisions of the key.
+func (h *Handler) historyVisible(username, key string) bool {
+	if h.HistoryAccess == nil || !h.Auth.Enabled() {
+		return true
+	}
+	return h.HistoryAccess.Visible(key, h.Auth.IsAdmin(username))
+}
+
 // calculateModalDimensions estimates modal width and textarea height based on content.
 // returns width and textarea height in pixels.
 func (h *Handler) calculateModalDimensions(value string) (width, textareaHeight int) {
@@ -636,7 +812,8 @@ type validationErrorParams struct {
 	IsBinary  bool
 	Username  string
 	Error     string
-	UpdatedAt int64 // original timestamp from form (preserve for conflict detection on retry)
+	UpdatedAt int64    // original timestamp from form (preserve for conflict detection on retry)
+	Meta      metaData // description and labels as entered
 }
 
 // renderValidationError re-renders the form with a validation error message.
@@ -660,6 +837,7 @@ func (h *Handler) renderValidationError(w http.ResponseWriter, p validationError
 		CanWrite:       true,
 		Username:       p.Username,
 		conflictData:   conflictData{UpdatedAt: p.UpdatedAt},
+		metaData:       p.Meta,
 	}
 	if err := h.tmpl.ExecuteTemplate(w, "form", data); err != nil {
 		log.Printf("[ERROR] failed to execute template: %v", err)
@@ -693,6 +871,7 @@ type conflictErrorParams struct {
 	Username      string
 	FormUpdatedAt int64
 	ConflictErr   *store.ConflictError
+	Meta          metaData // description and labels as entered
 }
 
 // renderConflictError renders the form with conflict data when optimistic lock fails.
@@ -720,6 +899,7 @@ func (h *Handler) renderConflictError(w http.ResponseWriter, p conflictErrorPara
 			ServerUpdatedAt: p.ConflictErr.Info.CurrentVersion.UnixNano(),
 			UpdatedAt:       p.FormUpdatedAt,
 		},
+		metaData: p.Meta,
 	}
 	if err := h.tmpl.ExecuteTemplate(w, "form", data); err != nil {
 		log.Printf("[ERROR] failed to execute template: %v", err)
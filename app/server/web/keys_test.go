@@ -3,8 +3,10 @@ package web
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
@@ -21,11 +23,15 @@ import (
 
 func TestHandler_HandleKeyList(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) {
-			return []store.KeyInfo{
-				{Key: "alpha", Size: 50},
-				{Key: "beta", Size: 100},
-			}, nil
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			var res []store.KeyInfo
+			keys := []store.KeyInfo{{Key: "alpha", Size: 50}, {Key: "beta", Size: 100, Labels: map[string]string{"env": "prod"}}}
+			for _, k := range keys {
+				if strings.Contains(k.Key, q.Search) {
+					res = append(res, k)
+				}
+			}
+			return res, len(res), nil
 		},
 		SecretsEnabledFunc: func() bool { return false },
 	}
@@ -39,6 +45,7 @@ func TestHandler_HandleKeyList(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Contains(t, rec.Body.String(), "alpha")
 		assert.Contains(t, rec.Body.String(), "beta")
+		assert.Contains(t, rec.Body.String(), `<span class="label-chip">env=prod</span>`)
 	})
 
 	t.Run("filters with search query param", func(t *testing.T) {
@@ -50,6 +57,140 @@ func TestHandler_HandleKeyList(t *testing.T) {
 		body := rec.Body.String()
 		assert.Contains(t, body, "alpha")
 		assert.NotContains(t, body, ">beta<")
+		assert.Equal(t, "alpha", st.ListPageCalls()[1].Q.Search)
+		assert.Equal(t, "/?search=alpha&sort=updated", rec.Header().Get("HX-Replace-Url"))
+	})
+
+	t.Run("search from form value", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/web/keys", strings.NewReader("search=beta"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.handleKeyList(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "beta")
+		assert.NotContains(t, rec.Body.String(), ">alpha<")
+	})
+
+	t.Run("search qualifiers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/keys?search="+url.QueryEscape("al prefix:a format:json"), http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyList(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		q := st.ListPageCalls()[len(st.ListPageCalls())-1].Q
+		assert.Equal(t, "al", q.Search)
+		assert.Equal(t, "a", q.Prefix)
+		assert.Equal(t, "json", q.Format)
+	})
+
+	t.Run("invalid search", func(t *testing.T) {
+		calls := len(st.ListPageCalls())
+		req := httptest.NewRequest(http.MethodGet, "/web/keys?search="+url.QueryEscape("size:huge"), http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyList(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid search: invalid size")
+		assert.Len(t, st.ListPageCalls(), calls, "store not queried")
+	})
+}
+
+func TestHandler_HandleKeyRows(t *testing.T) {
+	keys := make([]store.KeyInfo, 5)
+	for i := range keys {
+		keys[i] = store.KeyInfo{Key: "key" + string(rune('a'+i)), Size: 10}
+	}
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			return keys[min(q.Offset, len(keys)):min(q.Offset+q.Limit, len(keys))], len(keys), nil
+		},
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return false },
+		CheckUserPermissionFunc: func(string, string, bool) bool { return true },
+		UserCanWriteFunc:        func(string) bool { return true },
+	}
+	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{PageSize: 2})
+	require.NoError(t, err)
+
+	t.Run("middle page with loader row", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/rows?page=2&search=key%26x", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyRows(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "keyc")
+		assert.Contains(t, body, "keyd")
+		assert.NotContains(t, body, "keyb")
+		assert.NotContains(t, body, "<table", "rows only")
+		assert.Contains(t, body, `hx-get="/web/keys/rows?page=3&search=key%26x"`)
+		assert.Equal(t, "key&x", st.ListPageCalls()[0].Q.Search)
+	})
+
+	t.Run("last page without loader row", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/rows?page=3", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyRows(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "keye")
+		assert.NotContains(t, rec.Body.String(), "load-more")
+	})
+
+	t.Run("past the end is empty, not clamped", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/rows?page=7", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyRows(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "<tr")
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		for _, page := range []string{"", "0", "abc"} {
+			req := httptest.NewRequest(http.MethodGet, "/web/keys/rows?page="+page, http.NoBody)
+			rec := httptest.NewRecorder()
+			h.handleKeyRows(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "page %q", page)
+		}
+	})
+}
+
+func TestHandler_HandleKeyExport(t *testing.T) {
+	ts := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			assert.Equal(t, enum.SecretsFilterSecretsOnly, q.Filter)
+			assert.Equal(t, "db", q.Search)
+			assert.Equal(t, enum.SortModeKey, q.Sort)
+			assert.Zero(t, q.Limit, "export is not paged")
+			return []store.KeyInfo{{Key: "secrets/db", Size: 88, Format: "text", Secret: true, CreatedAt: ts, UpdatedAt: ts}}, 1, nil
+		},
+	}
+	h := newTestHandlerWithStore(t, st)
+
+	req := httptest.NewRequest(http.MethodGet, "/web/keys/export?search=db", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "secrets_filter", Value: "secretsonly"})
+	req.AddCookie(&http.Cookie{Name: "sort_mode", Value: "key"})
+	rec := httptest.NewRecorder()
+	h.handleKeyExport(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `filename="stash-secrets-`)
+	assert.Equal(t, "key,secret,zk_encrypted,format,size,created_at,updated_at\n"+
+		"secrets/db,true,false,text,88,2025-01-15T10:00:00Z,2025-01-15T10:00:00Z\n", rec.Body.String())
+	assert.Empty(t, st.GetWithFormatCalls(), "values must not be loaded")
+
+	t.Run("invalid search", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/export?search="+url.QueryEscape("updated:<=1d"), http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleKeyExport(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid search")
 	})
 }
 
@@ -85,7 +226,10 @@ func TestHandler_HandleKeyView(t *testing.T) {
 			}
 			return nil, "", store.ErrNotFound
 		},
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		GetInfoFunc: func(_ context.Context, key string) (store.KeyInfo, error) {
+			return store.KeyInfo{Key: key, Description: "test description", Labels: map[string]string{"env": "prod"}}, nil
+		},
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -101,6 +245,8 @@ func TestHandler_HandleKeyView(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Contains(t, rec.Body.String(), "testvalue")
+		assert.Contains(t, rec.Body.String(), "test description")
+		assert.Contains(t, rec.Body.String(), `<span class="label-chip">env=prod</span>`)
 	})
 
 	t.Run("not found", func(t *testing.T) {
@@ -136,9 +282,9 @@ func TestHandler_HandleKeyEdit(t *testing.T) {
 			return nil, "", store.ErrNotFound
 		},
 		GetInfoFunc: func(_ context.Context, key string) (store.KeyInfo, error) {
-			return store.KeyInfo{Key: key, UpdatedAt: time.Now()}, nil
+			return store.KeyInfo{Key: key, UpdatedAt: time.Now(), Labels: map[string]string{"team": "core", "env": "prod"}}, nil
 		},
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -154,6 +300,7 @@ func TestHandler_HandleKeyEdit(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Contains(t, rec.Body.String(), "editvalue")
+		assert.Contains(t, rec.Body.String(), `name="labels" value="env=prod, team=core"`)
 	})
 
 	t.Run("not found", func(t *testing.T) {
@@ -168,7 +315,7 @@ func TestHandler_HandleKeyEdit(t *testing.T) {
 	t.Run("permission denied", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("val"), "text", nil },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return false },
@@ -186,7 +333,7 @@ func TestHandler_HandleKeyEdit(t *testing.T) {
 	t.Run("store error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", errors.New("db error") },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -206,7 +353,7 @@ func TestHandler_HandleKeyEdit(t *testing.T) {
 			GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
 				return []byte("$ZK$dGVzdA=="), "text", nil // ZK-encrypted value
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -230,7 +377,8 @@ func TestHandler_HandleKeyView_ZKEncrypted(t *testing.T) {
 		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
 			return []byte("$ZK$dGVzdA=="), "text", nil // ZK-encrypted value
 		},
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		GetInfoFunc:        func(_ context.Context, key string) (store.KeyInfo, error) { return store.KeyInfo{Key: key}, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -251,11 +399,12 @@ func TestHandler_HandleKeyView_ZKEncrypted(t *testing.T) {
 func TestHandler_HandleKeyCreate(t *testing.T) {
 	st := &mocks.KVStoreMock{
 		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-		SetFunc:            func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return true },
 		CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
 		FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
 		UserCanWriteFunc:        func(username string) bool { return true },
@@ -272,16 +421,29 @@ func TestHandler_HandleKeyCreate(t *testing.T) {
 	h.handleKeyCreate(rec, req)
 
 	assert.Equal(t, http.StatusOK, rec.Code)
-	require.Len(t, st.SetCalls(), 1)
-	assert.Equal(t, "newkey", st.SetCalls()[0].Key)
-	assert.Equal(t, "newvalue", string(st.SetCalls()[0].Value))
+	require.Len(t, st.SetWithOptionsCalls(), 1)
+	assert.Equal(t, "newkey", st.SetWithOptionsCalls()[0].Key)
+	assert.Equal(t, "newvalue", string(st.SetWithOptionsCalls()[0].Value))
+	assert.Nil(t, st.SetWithOptionsCalls()[0].Opts.Labels, "no metadata sent")
+	assert.Nil(t, st.SetWithOptionsCalls()[0].Opts.Description)
+
+	req = httptest.NewRequest(http.MethodPost, "/web/keys", http.NoBody)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.PostForm = map[string][]string{"key": {"labeled"}, "value": {"v"}, "labels": {"env=dev"}, "description": {""}}
+	rec = httptest.NewRecorder()
+	h.handleKeyCreate(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, st.SetWithOptionsCalls(), 2)
+	assert.Equal(t, "labeled", st.SetWithOptionsCalls()[1].Key)
+	assert.Equal(t, map[string]string{"env": "dev"}, st.SetWithOptionsCalls()[1].Opts.Labels, "metadata written with the value")
+	assert.Empty(t, st.SetMetaCalls())
 }
 
 func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 	t.Run("empty key", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			SetFunc:  func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		h := newTestHandlerWithStore(t, st)
 
@@ -292,14 +454,16 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 		h.handleKeyCreate(rec, req)
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Empty(t, st.SetCalls())
+		assert.Empty(t, st.SetWithOptionsCalls())
 	})
 
 	t.Run("store error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-			SetFunc:           func(context.Context, string, []byte, string) (bool, error) { return false, errors.New("db error") },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
+				return false, errors.New("db error")
+			},
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -317,9 +481,9 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 
 	t.Run("duplicate key", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return []byte("existing"), "text", nil },
-			SetFunc:           func(context.Context, string, []byte, string) (bool, error) { return true, nil },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("existing"), "text", nil },
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return true, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -335,13 +499,13 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code) // form re-rendered with error
 		body := rec.Body.String()
 		assert.Contains(t, body, "already exists")
-		assert.Empty(t, st.SetCalls(), "Set should not be called for duplicate key")
+		assert.Empty(t, st.SetWithOptionsCalls(), "Set should not be called for duplicate key")
 	})
 
 	t.Run("permission denied", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return false },
@@ -367,7 +531,7 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
 				return nil, "", errors.New("db connection failed")
 			},
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -386,7 +550,7 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 	t.Run("invalid base64 in binary mode", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -409,7 +573,7 @@ func TestHandler_HandleKeyCreate_Errors(t *testing.T) {
 	t.Run("validation error shows form with error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -443,10 +607,11 @@ func TestHandler_HandleKeyUpdate(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			SetWithVersionFunc: func(context.Context, string, []byte, string, time.Time) error { return nil },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
 			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
 			UserCanWriteFunc:        func(username string) bool { return true },
@@ -466,9 +631,52 @@ func TestHandler_HandleKeyUpdate(t *testing.T) {
 		assert.Equal(t, "updated", string(st.SetWithVersionCalls()[0].Value))
 	})
 
+	t.Run("labels and description", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			SetWithVersionFunc: func(context.Context, string, []byte, string, time.Time) error { return nil },
+			SetMetaFunc:        func(context.Context, string, store.KeyMeta) error { return nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+			SecretsEnabledFunc: func() bool { return false },
+		}
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
+			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
+			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
+			UserCanWriteFunc:        func(username string) bool { return true },
+		}
+		h := newTestHandlerWithAll(t, st, defaultValidatorMock(), auth)
+		update := func(form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPut, "/web/keys/app/db", http.NoBody)
+			req.SetPathValue("key", "app/db")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.PostForm = form
+			rec := httptest.NewRecorder()
+			h.handleKeyUpdate(rec, req)
+			return rec
+		}
+
+		rec := update(url.Values{"value": {"v"}, "labels": {"env=prod, team=core"}, "description": {" main db "}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, st.SetMetaCalls(), 1)
+		assert.Equal(t, store.KeyMeta{Description: "main db", Labels: map[string]string{"env": "prod", "team": "core"}},
+			st.SetMetaCalls()[0].Meta)
+
+		rec = update(url.Values{"value": {"v"}, "labels": {""}, "description": {""}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, st.SetMetaCalls(), 2)
+		assert.Equal(t, store.KeyMeta{Labels: map[string]string{}}, st.SetMetaCalls()[1].Meta, "cleared fields remove metadata")
+
+		rec = update(url.Values{"value": {"v"}, "labels": {"env"}, "description": {"main db"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "expected name=value")
+		assert.Contains(t, rec.Body.String(), `value="main db"`, "entered description kept")
+		assert.Len(t, st.SetWithVersionCalls(), 2, "value not saved with invalid labels")
+		assert.Len(t, st.SetMetaCalls(), 2)
+	})
+
 	t.Run("permission denied", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return false },
@@ -496,7 +704,7 @@ func TestHandler_HandleKeyUpdate(t *testing.T) {
 			SetWithVersionFunc: func(context.Context, string, []byte, string, time.Time) error {
 				return errors.New("db error")
 			},
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -515,7 +723,7 @@ func TestHandler_HandleKeyUpdate(t *testing.T) {
 
 	t.Run("validation error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -541,7 +749,7 @@ func TestHandler_HandleKeyUpdate(t *testing.T) {
 
 	t.Run("invalid base64 in binary mode", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
-			ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		}
 		auth := &mocks.AuthProviderMock{
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
@@ -566,10 +774,11 @@ func TestHandler_HandleKeyDelete(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			DeleteFunc:         func(context.Context, string) error { return nil },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
 			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
 			UserCanWriteFunc:        func(username string) bool { return true },
@@ -586,10 +795,46 @@ func TestHandler_HandleKeyDelete(t *testing.T) {
 		assert.Equal(t, "deletekey", st.DeleteCalls()[0].Key)
 	})
 
+	t.Run("owner-only delete", func(t *testing.T) {
+		st := &mocks.KVStoreMock{
+			DeleteFunc:         func(context.Context, string) error { return nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+			SecretsEnabledFunc: func() bool { return false },
+		}
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
+			GetSessionUserFunc:      func(context.Context, string) (string, bool) { return "bob", true },
+			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
+			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
+			UserCanWriteFunc:        func(username string) bool { return true },
+			IsAdminFunc:             func(username string) bool { return false },
+		}
+		owners := &mocks.OwnerPolicyMock{
+			CanDeleteFunc: func(_ context.Context, key, _ string, _ bool) (bool, error) { return key != "owned", nil },
+		}
+		h := newTestHandlerWithStoreAndAuth(t, st, auth)
+		h.Owners = owners
+
+		del := func(key string) int {
+			req := httptest.NewRequest(http.MethodDelete, "/web/keys/"+key, http.NoBody)
+			req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "bob-token"})
+			req.SetPathValue("key", key)
+			rec := httptest.NewRecorder()
+			h.handleKeyDelete(rec, req)
+			return rec.Code
+		}
+		assert.Equal(t, http.StatusForbidden, del("owned"))
+		assert.Empty(t, st.DeleteCalls())
+		assert.Equal(t, http.StatusOK, del("shared"))
+		require.Len(t, st.DeleteCalls(), 1)
+		require.Len(t, owners.CanDeleteCalls(), 2)
+		assert.Equal(t, "user:bob", owners.CanDeleteCalls()[0].Identity)
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			DeleteFunc:         func(context.Context, string) error { return store.ErrNotFound },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -608,7 +853,7 @@ func TestHandler_HandleKeyDelete(t *testing.T) {
 	t.Run("internal error", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			DeleteFunc:         func(context.Context, string) error { return errors.New("db error") },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -627,7 +872,7 @@ func TestHandler_HandleKeyDelete(t *testing.T) {
 	t.Run("permission denied", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			DeleteFunc:         func(context.Context, string) error { return nil },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -661,7 +906,7 @@ func TestHandler_HandleKeyUpdate_ConflictDetection(t *testing.T) {
 					},
 				}
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -692,10 +937,11 @@ func TestHandler_HandleKeyUpdate_ConflictDetection(t *testing.T) {
 			SetWithVersionFunc: func(context.Context, string, []byte, string, time.Time) error {
 				return nil // success
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
 			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
 			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
 			UserCanWriteFunc:        func(username string) bool { return true },
@@ -858,9 +1104,11 @@ func TestHandler_HandleKeyRestore(t *testing.T) {
 			CommitFunc:      func(req git.CommitRequest) error { return nil },
 		}
 		st := &mocks.KVStoreMock{
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) { return []byte("value"), "text", nil },
-			SetFunc:           func(_ context.Context, key string, value []byte, format string) (bool, error) { return true, nil },
+			SetWithOptionsFunc: func(_ context.Context, key string, value []byte, format string, _ store.SetOptions) (bool, error) {
+				return true, nil
+			},
 		}
 		auth := &mocks.AuthProviderMock{
 			EnabledFunc:             func() bool { return true },
@@ -886,9 +1134,9 @@ func TestHandler_HandleKeyRestore(t *testing.T) {
 			CommitFunc:      func(req git.CommitRequest) error { return nil },
 		}
 		st := &mocks.KVStoreMock{
-			ListFunc:          func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:      func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) { return []byte("value"), "text", nil },
-			SetFunc: func(_ context.Context, key string, value []byte, format string) (bool, error) {
+			SetWithOptionsFunc: func(_ context.Context, key string, value []byte, format string, _ store.SetOptions) (bool, error) {
 				return false, errors.New("db error")
 			},
 		}
@@ -926,6 +1174,62 @@ func TestHandler_HandleKeyRestore(t *testing.T) {
 	})
 }
 
+func TestHandler_HistoryAccess(t *testing.T) {
+	gitSvc := &mocks.GitServiceMock{
+		HistoryFunc:     func(string, int) ([]git.HistoryEntry, error) { return []git.HistoryEntry{{Hash: "abc1234"}}, nil },
+		GetRevisionFunc: func(string, string) ([]byte, string, error) { return []byte("old"), "text", nil },
+		CommitFunc:      func(git.CommitRequest) error { return nil },
+	}
+	st := &mocks.KVStoreMock{
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("value"), "text", nil },
+		SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) { return false, nil },
+		GetInfoFunc:        func(_ context.Context, key string) (store.KeyInfo, error) { return store.KeyInfo{Key: key}, nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:             func() bool { return true },
+		GetSessionUserFunc:      func(_ context.Context, token string) (string, bool) { return token, true },
+		CheckUserPermissionFunc: func(string, string, bool) bool { return true },
+		FilterUserKeysFunc:      func(_ string, keys []string) []string { return keys },
+		UserCanWriteFunc:        func(string) bool { return true },
+		IsAdminFunc:             func(username string) bool { return username == "alice" },
+	}
+	h := newTestHandlerWithStoreAndAuth(t, st, auth)
+	h.Git = gitSvc
+	h.HistoryAccess = &mocks.HistoryPolicyMock{VisibleFunc: func(key string, admin bool) bool { return admin || key != "prod/db" }}
+
+	call := func(handler http.HandlerFunc, method, target, key, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: user})
+		req.SetPathValue("key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := call(h.handleKeyHistory, http.MethodGet, "/web/keys/history/prod/db", "prod/db", "bob")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "restricted to admins")
+	rec = call(h.handleKeyRevision, http.MethodGet, "/web/keys/revision/prod/db?rev=abc1234", "prod/db", "bob")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = call(h.handleKeyRestore, http.MethodPost, "/web/keys/restore/prod/db?rev=abc1234", "prod/db", "bob")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, gitSvc.HistoryCalls())
+	assert.Empty(t, gitSvc.GetRevisionCalls())
+
+	rec = call(h.handleKeyView, http.MethodGet, "/web/keys/view/prod/db", "prod/db", "bob")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "/web/keys/history/", "no history button")
+	rec = call(h.handleKeyView, http.MethodGet, "/web/keys/view/prod/db", "prod/db", "alice")
+	assert.Contains(t, rec.Body.String(), "/web/keys/history/")
+
+	assert.Equal(t, http.StatusOK, call(h.handleKeyHistory, http.MethodGet, "/web/keys/history/app/db", "app/db", "bob").Code)
+	assert.Equal(t, http.StatusOK, call(h.handleKeyHistory, http.MethodGet, "/web/keys/history/prod/db", "prod/db", "alice").Code)
+	rec = call(h.handleKeyRevision, http.MethodGet, "/web/keys/revision/prod/db?rev=abc1234", "prod/db", "alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
 func TestHandler_SecretsNotConfigured(t *testing.T) {
 	// tests that handlers render error template when operating on secret paths without secrets configured
 	// returns 200 for HTMX compatibility (HTMX doesn't swap content on 4xx responses)
@@ -935,7 +1239,7 @@ func TestHandler_SecretsNotConfigured(t *testing.T) {
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) {
 				return nil, "", store.ErrSecretsNotConfigured
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{CheckUserPermissionFunc: func(string, string, bool) bool { return true }}
@@ -956,7 +1260,7 @@ func TestHandler_SecretsNotConfigured(t *testing.T) {
 				return nil, "", store.ErrSecretsNotConfigured
 			},
 			GetInfoFunc:        func(context.Context, string) (store.KeyInfo, error) { return store.KeyInfo{}, nil },
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{CheckUserPermissionFunc: func(string, string, bool) bool { return true }}
@@ -974,10 +1278,10 @@ func TestHandler_SecretsNotConfigured(t *testing.T) {
 	t.Run("handleKeyCreate", func(t *testing.T) {
 		st := &mocks.KVStoreMock{
 			GetWithFormatFunc: func(context.Context, string) ([]byte, string, error) { return nil, "", store.ErrNotFound },
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) {
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
 				return false, store.ErrSecretsNotConfigured
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{
@@ -1001,7 +1305,7 @@ func TestHandler_SecretsNotConfigured(t *testing.T) {
 			SetWithVersionFunc: func(context.Context, string, []byte, string, time.Time) error {
 				return store.ErrSecretsNotConfigured
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{CheckUserPermissionFunc: func(string, string, bool) bool { return true }}
@@ -1023,10 +1327,10 @@ func TestHandler_SecretsNotConfigured(t *testing.T) {
 			GetRevisionFunc: func(key, rev string) ([]byte, string, error) { return []byte("value"), "text", nil },
 		}
 		st := &mocks.KVStoreMock{
-			SetFunc: func(context.Context, string, []byte, string) (bool, error) {
+			SetWithOptionsFunc: func(context.Context, string, []byte, string, store.SetOptions) (bool, error) {
 				return false, store.ErrSecretsNotConfigured
 			},
-			ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+			ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 			SecretsEnabledFunc: func() bool { return false },
 		}
 		auth := &mocks.AuthProviderMock{CheckUserPermissionFunc: func(string, string, bool) bool { return true }}
@@ -1071,7 +1375,7 @@ func TestHandler_AuditLogging(t *testing.T) {
 	}
 
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) {
 			if key == "existing" {
@@ -1079,9 +1383,12 @@ func TestHandler_AuditLogging(t *testing.T) {
 			}
 			return nil, "", store.ErrNotFound
 		},
-		SetFunc:            func(_ context.Context, key string, value []byte, format string) (bool, error) { return true, nil },
+		SetWithOptionsFunc: func(_ context.Context, key string, value []byte, format string, _ store.SetOptions) (bool, error) {
+			return true, nil
+		},
 		SetWithVersionFunc: func(_ context.Context, key string, value []byte, format string, _ time.Time) error { return nil },
 		DeleteFunc:         func(_ context.Context, key string) error { return nil },
+		GetInfoFunc:        func(_ context.Context, key string) (store.KeyInfo, error) { return store.KeyInfo{Key: key}, nil },
 	}
 	auth := &mocks.AuthProviderMock{
 		EnabledFunc:             func() bool { return true },
@@ -1166,6 +1473,88 @@ func TestHandler_AuditLogging(t *testing.T) {
 		assert.Equal(t, len("value"), *capturedEntries[0].ValueSize) // "value" from GetWithFormatFunc
 	})
 
+	t.Run("view of canary key logs canary action and alerts", func(t *testing.T) {
+		var observed []store.AuditEntry
+		alerts := &mocks.AlertObserverMock{ObserveFunc: func(e store.AuditEntry, _ bool) { observed = append(observed, e) }}
+		canaries := &mocks.CanaryMatcherMock{IsCanaryFunc: func(key string) bool { return key == "existing" }}
+		adminAuth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
+			GetSessionUserFunc:      func(_ context.Context, token string) (string, bool) { return "testuser", true },
+			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
+			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
+			UserCanWriteFunc:        func(username string) bool { return true },
+			IsAdminFunc:             func(string) bool { return true },
+		}
+		hCanary, err := New(Deps{Store: st, Auth: adminAuth, Validator: defaultValidatorMock(), Audit: auditLogger,
+			Alerts: alerts, Canaries: canaries}, Config{})
+		require.NoError(t, err)
+
+		capturedEntries = nil
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/view/existing", http.NoBody)
+		req.SetPathValue("key", "existing")
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "testtoken"})
+		hCanary.handleKeyView(httptest.NewRecorder(), req)
+
+		require.Len(t, capturedEntries, 1)
+		assert.Equal(t, enum.AuditActionCanary, capturedEntries[0].Action)
+		require.Len(t, observed, 1)
+		assert.Equal(t, capturedEntries[0], observed[0])
+		assert.True(t, alerts.ObserveCalls()[0].Admin)
+	})
+
+	t.Run("key requiring reason", func(t *testing.T) {
+		reasons := &mocks.ReasonPolicyMock{RequiresReasonFunc: func(key string) bool { return key == "existing" }}
+		hReason, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Audit: auditLogger,
+			Reasons: reasons}, Config{})
+		require.NoError(t, err)
+
+		capturedEntries = nil
+		req := httptest.NewRequest(http.MethodGet, "/web/keys/view/existing", http.NoBody)
+		req.SetPathValue("key", "existing")
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "testtoken"})
+		rec := httptest.NewRecorder()
+		hReason.handleKeyView(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Reason for accessing existing")
+		assert.Empty(t, capturedEntries, "rejected view is not logged")
+
+		req.Header.Set("X-Stash-Reason", "INC-42%20rotate")
+		rec = httptest.NewRecorder()
+		hReason.handleKeyView(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, capturedEntries, 1)
+		assert.Equal(t, "INC-42 rotate", capturedEntries[0].Reason)
+
+		req = httptest.NewRequest(http.MethodDelete, "/web/keys/existing", http.NoBody)
+		req.SetPathValue("key", "existing")
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "testtoken"})
+		rec = httptest.NewRecorder()
+		hReason.handleKeyDelete(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	})
+
+	t.Run("alerts without audit logger", func(t *testing.T) {
+		alerts := &mocks.AlertObserverMock{ObserveFunc: func(store.AuditEntry, bool) {}}
+		userAuth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
+			GetSessionUserFunc:      func(_ context.Context, token string) (string, bool) { return "testuser", true },
+			CheckUserPermissionFunc: func(username, key string, write bool) bool { return true },
+			FilterUserKeysFunc:      func(username string, keys []string) []string { return keys },
+			UserCanWriteFunc:        func(username string) bool { return true },
+			IsAdminFunc:             func(string) bool { return false },
+		}
+		hAlerts, err := New(Deps{Store: st, Auth: userAuth, Validator: defaultValidatorMock(), Alerts: alerts}, Config{})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodDelete, "/web/keys/existing", http.NoBody)
+		req.SetPathValue("key", "existing")
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "testtoken"})
+		hAlerts.handleKeyDelete(httptest.NewRecorder(), req)
+
+		require.Len(t, alerts.ObserveCalls(), 1)
+		assert.Equal(t, enum.AuditActionDelete, alerts.ObserveCalls()[0].Entry.Action)
+	})
+
 	t.Run("no audit when audit logger is nil", func(t *testing.T) {
 		// create handler without audit logger
 		hNoAudit, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock()}, Config{})
@@ -1182,3 +1571,22 @@ func TestHandler_AuditLogging(t *testing.T) {
 		assert.Empty(t, capturedEntries, "no audit entries when audit logger is nil")
 	})
 }
+
+func TestStoreErrorMessage(t *testing.T) {
+	msg, ok := storeErrorMessage(store.ErrSecretsNotConfigured)
+	assert.True(t, ok)
+	assert.Contains(t, msg, "require --secrets.key")
+
+	msg, ok = storeErrorMessage(fmt.Errorf("failed to decrypt key: %w", store.ErrSealed))
+	assert.True(t, ok)
+	assert.Contains(t, msg, "Secrets sealed")
+
+	frozen := &store.FrozenError{Key: "prod/db", Freeze: store.Freeze{Prefix: "prod", Incident: "INC-1",
+		ExpiresAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}}
+	msg, ok = storeErrorMessage(fmt.Errorf("failed to set: %w", frozen))
+	assert.True(t, ok)
+	assert.Contains(t, msg, "frozen for incident INC-1")
+
+	_, ok = storeErrorMessage(store.ErrNotFound)
+	assert.False(t, ok)
+}
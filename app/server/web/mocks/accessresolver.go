@@ -0,0 +1,79 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/server/auth"
+)
+
+// AccessResolverMock is a mock implementation of web.AccessResolver.
+//
+//	func TestSomethingThatUsesAccessResolver(t *testing.T) {
+//
+//		// make and configure a mocked web.AccessResolver
+//		mockedAccessResolver := &AccessResolverMock{
+//			KeyAccessFunc: func(ctx context.Context, key string) (auth.KeyAccessList, error) {
+//				panic("mock out the KeyAccess method")
+//			},
+//		}
+//
+//		// use mockedAccessResolver in code that requires web.AccessResolver
+//		// and then make assertions.
+//
+//	}
+type AccessResolverMock struct {
+	// KeyAccessFunc mocks the KeyAccess method.
+	KeyAccessFunc func(ctx context.Context, key string) (auth.KeyAccessList, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// KeyAccess holds details about calls to the KeyAccess method.
+		KeyAccess []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockKeyAccess sync.RWMutex
+}
+
+// KeyAccess calls KeyAccessFunc.
+func (mock *AccessResolverMock) KeyAccess(ctx context.Context, key string) (auth.KeyAccessList, error) {
+	if mock.KeyAccessFunc == nil {
+		panic("AccessResolverMock.KeyAccessFunc: method is nil but AccessResolver.KeyAccess was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockKeyAccess.Lock()
+	mock.calls.KeyAccess = append(mock.calls.KeyAccess, callInfo)
+	mock.lockKeyAccess.Unlock()
+	return mock.KeyAccessFunc(ctx, key)
+}
+
+// KeyAccessCalls gets all the calls that were made to KeyAccess.
+// Check the length with:
+//
+//	len(mockedAccessResolver.KeyAccessCalls())
+func (mock *AccessResolverMock) KeyAccessCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockKeyAccess.RLock()
+	calls = mock.calls.KeyAccess
+	mock.lockKeyAccess.RUnlock()
+	return calls
+}
@@ -0,0 +1,78 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// AlertObserverMock is a mock implementation of web.AlertObserver.
+//
+//	func TestSomethingThatUsesAlertObserver(t *testing.T) {
+//
+//		// make and configure a mocked web.AlertObserver
+//		mockedAlertObserver := &AlertObserverMock{
+//			ObserveFunc: func(entry store.AuditEntry, admin bool)  {
+//				panic("mock out the Observe method")
+//			},
+//		}
+//
+//		// use mockedAlertObserver in code that requires web.AlertObserver
+//		// and then make assertions.
+//
+//	}
+type AlertObserverMock struct {
+	// ObserveFunc mocks the Observe method.
+	ObserveFunc func(entry store.AuditEntry, admin bool)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Observe holds details about calls to the Observe method.
+		Observe []struct {
+			// Entry is the entry argument value.
+			Entry store.AuditEntry
+			// Admin is the admin argument value.
+			Admin bool
+		}
+	}
+	lockObserve sync.RWMutex
+}
+
+// Observe calls ObserveFunc.
+func (mock *AlertObserverMock) Observe(entry store.AuditEntry, admin bool) {
+	if mock.ObserveFunc == nil {
+		panic("AlertObserverMock.ObserveFunc: method is nil but AlertObserver.Observe was just called")
+	}
+	callInfo := struct {
+		Entry store.AuditEntry
+		Admin bool
+	}{
+		Entry: entry,
+		Admin: admin,
+	}
+	mock.lockObserve.Lock()
+	mock.calls.Observe = append(mock.calls.Observe, callInfo)
+	mock.lockObserve.Unlock()
+	mock.ObserveFunc(entry, admin)
+}
+
+// ObserveCalls gets all the calls that were made to Observe.
+// Check the length with:
+//
+//	len(mockedAlertObserver.ObserveCalls())
+func (mock *AlertObserverMock) ObserveCalls() []struct {
+	Entry store.AuditEntry
+	Admin bool
+} {
+	var calls []struct {
+		Entry store.AuditEntry
+		Admin bool
+	}
+	mock.lockObserve.RLock()
+	calls = mock.calls.Observe
+	mock.lockObserve.RUnlock()
+	return calls
+}
@@ -0,0 +1,142 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// AuditStatsMock is a mock implementation of web.AuditStats.
+//
+//	func TestSomethingThatUsesAuditStats(t *testing.T) {
+//
+//		// make and configure a mocked web.AuditStats
+//		mockedAuditStats := &AuditStatsMock{
+//			AuditTimelineFunc: func(ctx context.Context, q store.AuditQuery, interval time.Duration) ([]store.AuditBucket, error) {
+//				panic("mock out the AuditTimeline method")
+//			},
+//			CountAuditFunc: func(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error) {
+//				panic("mock out the CountAudit method")
+//			},
+//		}
+//
+//		// use mockedAuditStats in code that requires web.AuditStats
+//		// and then make assertions.
+//
+//	}
+type AuditStatsMock struct {
+	// AuditTimelineFunc mocks the AuditTimeline method.
+	AuditTimelineFunc func(ctx context.Context, q store.AuditQuery, interval time.Duration) ([]store.AuditBucket, error)
+
+	// CountAuditFunc mocks the CountAudit method.
+	CountAuditFunc func(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AuditTimeline holds details about calls to the AuditTimeline method.
+		AuditTimeline []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+			// Interval is the interval argument value.
+			Interval time.Duration
+		}
+		// CountAudit holds details about calls to the CountAudit method.
+		CountAudit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+			// GroupBy is the groupBy argument value.
+			GroupBy string
+		}
+	}
+	lockAuditTimeline sync.RWMutex
+	lockCountAudit    sync.RWMutex
+}
+
+// AuditTimeline calls AuditTimelineFunc.
+func (mock *AuditStatsMock) AuditTimeline(ctx context.Context, q store.AuditQuery, interval time.Duration) ([]store.AuditBucket, error) {
+	if mock.AuditTimelineFunc == nil {
+		panic("AuditStatsMock.AuditTimelineFunc: method is nil but AuditStats.AuditTimeline was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Q        store.AuditQuery
+		Interval time.Duration
+	}{
+		Ctx:      ctx,
+		Q:        q,
+		Interval: interval,
+	}
+	mock.lockAuditTimeline.Lock()
+	mock.calls.AuditTimeline = append(mock.calls.AuditTimeline, callInfo)
+	mock.lockAuditTimeline.Unlock()
+	return mock.AuditTimelineFunc(ctx, q, interval)
+}
+
+// AuditTimelineCalls gets all the calls that were made to AuditTimeline.
+// Check the length with:
+//
+//	len(mockedAuditStats.AuditTimelineCalls())
+func (mock *AuditStatsMock) AuditTimelineCalls() []struct {
+	Ctx      context.Context
+	Q        store.AuditQuery
+	Interval time.Duration
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Q        store.AuditQuery
+		Interval time.Duration
+	}
+	mock.lockAuditTimeline.RLock()
+	calls = mock.calls.AuditTimeline
+	mock.lockAuditTimeline.RUnlock()
+	return calls
+}
+
+// CountAudit calls CountAuditFunc.
+func (mock *AuditStatsMock) CountAudit(ctx context.Context, q store.AuditQuery, groupBy string) ([]store.AuditCount, error) {
+	if mock.CountAuditFunc == nil {
+		panic("AuditStatsMock.CountAuditFunc: method is nil but AuditStats.CountAudit was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Q       store.AuditQuery
+		GroupBy string
+	}{
+		Ctx:     ctx,
+		Q:       q,
+		GroupBy: groupBy,
+	}
+	mock.lockCountAudit.Lock()
+	mock.calls.CountAudit = append(mock.calls.CountAudit, callInfo)
+	mock.lockCountAudit.Unlock()
+	return mock.CountAuditFunc(ctx, q, groupBy)
+}
+
+// CountAuditCalls gets all the calls that were made to CountAudit.
+// Check the length with:
+//
+//	len(mockedAuditStats.CountAuditCalls())
+func (mock *AuditStatsMock) CountAuditCalls() []struct {
+	Ctx     context.Context
+	Q       store.AuditQuery
+	GroupBy string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Q       store.AuditQuery
+		GroupBy string
+	}
+	mock.lockCountAudit.RLock()
+	calls = mock.calls.CountAudit
+	mock.lockCountAudit.RUnlock()
+	return calls
+}
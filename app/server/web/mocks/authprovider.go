@@ -5,8 +5,12 @@ package mocks
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"sync"
 	"time"
+
+	"github.com/umputun/stash/app/store"
 )
 
 // AuthProviderMock is a mock implementation of web.AuthProvider.
@@ -15,18 +19,33 @@ import (
 //
 //		// make and configure a mocked web.AuthProvider
 //		mockedAuthProvider := &AuthProviderMock{
+//			BeginPasskeyLoginFunc: func(ctx context.Context, username string) (json.RawMessage, error) {
+//				panic("mock out the BeginPasskeyLogin method")
+//			},
+//			BeginPasskeyRegistrationFunc: func(ctx context.Context, username string) (json.RawMessage, error) {
+//				panic("mock out the BeginPasskeyRegistration method")
+//			},
 //			CheckUserPermissionFunc: func(username string, key string, write bool) bool {
 //				panic("mock out the CheckUserPermission method")
 //			},
-//			CreateSessionFunc: func(ctx context.Context, username string) (string, error) {
+//			CreateSessionFunc: func(ctx context.Context, username string, remember bool) (string, error) {
 //				panic("mock out the CreateSession method")
 //			},
+//			DeletePasskeyFunc: func(ctx context.Context, username string, id string) error {
+//				panic("mock out the DeletePasskey method")
+//			},
 //			EnabledFunc: func() bool {
 //				panic("mock out the Enabled method")
 //			},
 //			FilterUserKeysFunc: func(username string, keys []string) []string {
 //				panic("mock out the FilterUserKeys method")
 //			},
+//			FinishPasskeyLoginFunc: func(ctx context.Context, body []byte) (string, error) {
+//				panic("mock out the FinishPasskeyLogin method")
+//			},
+//			FinishPasskeyRegistrationFunc: func(ctx context.Context, username string, body []byte) error {
+//				panic("mock out the FinishPasskeyRegistration method")
+//			},
 //			GetSessionUserFunc: func(ctx context.Context, token string) (string, bool) {
 //				panic("mock out the GetSessionUser method")
 //			},
@@ -39,12 +58,39 @@ import (
 //			IsValidUserFunc: func(username string, password string) bool {
 //				panic("mock out the IsValidUser method")
 //			},
-//			LoginTTLFunc: func() time.Duration {
-//				panic("mock out the LoginTTL method")
+//			PasskeyRequiredFunc: func(ctx context.Context, username string) (bool, error) {
+//				panic("mock out the PasskeyRequired method")
+//			},
+//			PasskeysFunc: func(ctx context.Context, username string) ([]store.Passkey, error) {
+//				panic("mock out the Passkeys method")
+//			},
+//			PasskeysEnabledFunc: func() bool {
+//				panic("mock out the PasskeysEnabled method")
+//			},
+//			PasswordlessEnabledFunc: func() bool {
+//				panic("mock out the PasswordlessEnabled method")
+//			},
+//			RecordLoginFunc: func(r *http.Request, token string, username string) error {
+//				panic("mock out the RecordLogin method")
+//			},
+//			RememberEnabledFunc: func() bool {
+//				panic("mock out the RememberEnabled method")
+//			},
+//			RenewSessionFunc: func(ctx context.Context, token string) (store.Session, error) {
+//				panic("mock out the RenewSession method")
+//			},
+//			SessionInfoFunc: func(ctx context.Context, token string) (store.Session, bool) {
+//				panic("mock out the SessionInfo method")
+//			},
+//			SessionLimitsFunc: func(remember bool) (time.Duration, time.Duration) {
+//				panic("mock out the SessionLimits method")
 //			},
 //			UserCanWriteFunc: func(username string) bool {
 //				panic("mock out the UserCanWrite method")
 //			},
+//			UserSessionsFunc: func(ctx context.Context, username string) ([]store.Session, error) {
+//				panic("mock out the UserSessions method")
+//			},
 //		}
 //
 //		// use mockedAuthProvider in code that requires web.AuthProvider
@@ -52,11 +98,20 @@ import (
 //
 //	}
 type AuthProviderMock struct {
+	// BeginPasskeyLoginFunc mocks the BeginPasskeyLogin method.
+	BeginPasskeyLoginFunc func(ctx context.Context, username string) (json.RawMessage, error)
+
+	// BeginPasskeyRegistrationFunc mocks the BeginPasskeyRegistration method.
+	BeginPasskeyRegistrationFunc func(ctx context.Context, username string) (json.RawMessage, error)
+
 	// CheckUserPermissionFunc mocks the CheckUserPermission method.
 	CheckUserPermissionFunc func(username string, key string, write bool) bool
 
 	// CreateSessionFunc mocks the CreateSession method.
-	CreateSessionFunc func(ctx context.Context, username string) (string, error)
+	CreateSessionFunc func(ctx context.Context, username string, remember bool) (string, error)
+
+	// DeletePasskeyFunc mocks the DeletePasskey method.
+	DeletePasskeyFunc func(ctx context.Context, username string, id string) error
 
 	// EnabledFunc mocks the Enabled method.
 	EnabledFunc func() bool
@@ -64,6 +119,12 @@ type AuthProviderMock struct {
 	// FilterUserKeysFunc mocks the FilterUserKeys method.
 	FilterUserKeysFunc func(username string, keys []string) []string
 
+	// FinishPasskeyLoginFunc mocks the FinishPasskeyLogin method.
+	FinishPasskeyLoginFunc func(ctx context.Context, body []byte) (string, error)
+
+	// FinishPasskeyRegistrationFunc mocks the FinishPasskeyRegistration method.
+	FinishPasskeyRegistrationFunc func(ctx context.Context, username string, body []byte) error
+
 	// GetSessionUserFunc mocks the GetSessionUser method.
 	GetSessionUserFunc func(ctx context.Context, token string) (string, bool)
 
@@ -76,14 +137,55 @@ type AuthProviderMock struct {
 	// IsValidUserFunc mocks the IsValidUser method.
 	IsValidUserFunc func(username string, password string) bool
 
-	// LoginTTLFunc mocks the LoginTTL method.
-	LoginTTLFunc func() time.Duration
+	// PasskeyRequiredFunc mocks the PasskeyRequired method.
+	PasskeyRequiredFunc func(ctx context.Context, username string) (bool, error)
+
+	// PasskeysFunc mocks the Passkeys method.
+	PasskeysFunc func(ctx context.Context, username string) ([]store.Passkey, error)
+
+	// PasskeysEnabledFunc mocks the PasskeysEnabled method.
+	PasskeysEnabledFunc func() bool
+
+	// PasswordlessEnabledFunc mocks the PasswordlessEnabled method.
+	PasswordlessEnabledFunc func() bool
+
+	// RecordLoginFunc mocks the RecordLogin method.
+	RecordLoginFunc func(r *http.Request, token string, username string) error
+
+	// RememberEnabledFunc mocks the RememberEnabled method.
+	RememberEnabledFunc func() bool
+
+	// RenewSessionFunc mocks the RenewSession method.
+	RenewSessionFunc func(ctx context.Context, token string) (store.Session, error)
+
+	// SessionInfoFunc mocks the SessionInfo method.
+	SessionInfoFunc func(ctx context.Context, token string) (store.Session, bool)
+
+	// SessionLimitsFunc mocks the SessionLimits method.
+	SessionLimitsFunc func(remember bool) (time.Duration, time.Duration)
 
 	// UserCanWriteFunc mocks the UserCanWrite method.
 	UserCanWriteFunc func(username string) bool
 
+	// UserSessionsFunc mocks the UserSessions method.
+	UserSessionsFunc func(ctx context.Context, username string) ([]store.Session, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// BeginPasskeyLogin holds details about calls to the BeginPasskeyLogin method.
+		BeginPasskeyLogin []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// BeginPasskeyRegistration holds details about calls to the BeginPasskeyRegistration method.
+		BeginPasskeyRegistration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
 		// CheckUserPermission holds details about calls to the CheckUserPermission method.
 		CheckUserPermission []struct {
 			// Username is the username argument value.
@@ -99,6 +201,17 @@ type AuthProviderMock struct {
 			Ctx context.Context
 			// Username is the username argument value.
 			Username string
+			// Remember is the remember argument value.
+			Remember bool
+		}
+		// DeletePasskey holds details about calls to the DeletePasskey method.
+		DeletePasskey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// ID is the id argument value.
+			ID string
 		}
 		// Enabled holds details about calls to the Enabled method.
 		Enabled []struct {
@@ -110,6 +223,22 @@ type AuthProviderMock struct {
 			// Keys is the keys argument value.
 			Keys []string
 		}
+		// FinishPasskeyLogin holds details about calls to the FinishPasskeyLogin method.
+		FinishPasskeyLogin []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Body is the body argument value.
+			Body []byte
+		}
+		// FinishPasskeyRegistration holds details about calls to the FinishPasskeyRegistration method.
+		FinishPasskeyRegistration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Body is the body argument value.
+			Body []byte
+		}
 		// GetSessionUser holds details about calls to the GetSessionUser method.
 		GetSessionUser []struct {
 			// Ctx is the ctx argument value.
@@ -136,25 +265,166 @@ type AuthProviderMock struct {
 			// Password is the password argument value.
 			Password string
 		}
-		// LoginTTL holds details about calls to the LoginTTL method.
-		LoginTTL []struct {
+		// PasskeyRequired holds details about calls to the PasskeyRequired method.
+		PasskeyRequired []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// Passkeys holds details about calls to the Passkeys method.
+		Passkeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// PasskeysEnabled holds details about calls to the PasskeysEnabled method.
+		PasskeysEnabled []struct {
+		}
+		// PasswordlessEnabled holds details about calls to the PasswordlessEnabled method.
+		PasswordlessEnabled []struct {
+		}
+		// RecordLogin holds details about calls to the RecordLogin method.
+		RecordLogin []struct {
+			// R is the r argument value.
+			R *http.Request
+			// Token is the token argument value.
+			Token string
+			// Username is the username argument value.
+			Username string
+		}
+		// RememberEnabled holds details about calls to the RememberEnabled method.
+		RememberEnabled []struct {
+		}
+		// RenewSession holds details about calls to the RenewSession method.
+		RenewSession []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token string
+		}
+		// SessionInfo holds details about calls to the SessionInfo method.
+		SessionInfo []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token string
+		}
+		// SessionLimits holds details about calls to the SessionLimits method.
+		SessionLimits []struct {
+			// Remember is the remember argument value.
+			Remember bool
 		}
 		// UserCanWrite holds details about calls to the UserCanWrite method.
 		UserCanWrite []struct {
 			// Username is the username argument value.
 			Username string
 		}
+		// UserSessions holds details about calls to the UserSessions method.
+		UserSessions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+	}
+	lockBeginPasskeyLogin         sync.RWMutex
+	lockBeginPasskeyRegistration  sync.RWMutex
+	lockCheckUserPermission       sync.RWMutex
+	lockCreateSession             sync.RWMutex
+	lockDeletePasskey             sync.RWMutex
+	lockEnabled                   sync.RWMutex
+	lockFilterUserKeys            sync.RWMutex
+	lockFinishPasskeyLogin        sync.RWMutex
+	lockFinishPasskeyRegistration sync.RWMutex
+	lockGetSessionUser            sync.RWMutex
+	lockInvalidateSession         sync.RWMutex
+	lockIsAdmin                   sync.RWMutex
+	lockIsValidUser               sync.RWMutex
+	lockPasskeyRequired           sync.RWMutex
+	lockPasskeys                  sync.RWMutex
+	lockPasskeysEnabled           sync.RWMutex
+	lockPasswordlessEnabled       sync.RWMutex
+	lockRecordLogin               sync.RWMutex
+	lockRememberEnabled           sync.RWMutex
+	lockRenewSession              sync.RWMutex
+	lockSessionInfo               sync.RWMutex
+	lockSessionLimits             sync.RWMutex
+	lockUserCanWrite              sync.RWMutex
+	lockUserSessions              sync.RWMutex
+}
+
+// BeginPasskeyLogin calls BeginPasskeyLoginFunc.
+func (mock *AuthProviderMock) BeginPasskeyLogin(ctx context.Context, username string) (json.RawMessage, error) {
+	if mock.BeginPasskeyLoginFunc == nil {
+		panic("AuthProviderMock.BeginPasskeyLoginFunc: method is nil but AuthProvider.BeginPasskeyLogin was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
 	}
-	lockCheckUserPermission sync.RWMutex
-	lockCreateSession       sync.RWMutex
-	lockEnabled             sync.RWMutex
-	lockFilterUserKeys      sync.RWMutex
-	lockGetSessionUser      sync.RWMutex
-	lockInvalidateSession   sync.RWMutex
-	lockIsAdmin             sync.RWMutex
-	lockIsValidUser         sync.RWMutex
-	lockLoginTTL            sync.RWMutex
-	lockUserCanWrite        sync.RWMutex
+	mock.lockBeginPasskeyLogin.Lock()
+	mock.calls.BeginPasskeyLogin = append(mock.calls.BeginPasskeyLogin, callInfo)
+	mock.lockBeginPasskeyLogin.Unlock()
+	return mock.BeginPasskeyLoginFunc(ctx, username)
+}
+
+// BeginPasskeyLoginCalls gets all the calls that were made to BeginPasskeyLogin.
+// Check the length with:
+//
+//	len(mockedAuthProvider.BeginPasskeyLoginCalls())
+func (mock *AuthProviderMock) BeginPasskeyLoginCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockBeginPasskeyLogin.RLock()
+	calls = mock.calls.BeginPasskeyLogin
+	mock.lockBeginPasskeyLogin.RUnlock()
+	return calls
+}
+
+// BeginPasskeyRegistration calls BeginPasskeyRegistrationFunc.
+func (mock *AuthProviderMock) BeginPasskeyRegistration(ctx context.Context, username string) (json.RawMessage, error) {
+	if mock.BeginPasskeyRegistrationFunc == nil {
+		panic("AuthProviderMock.BeginPasskeyRegistrationFunc: method is nil but AuthProvider.BeginPasskeyRegistration was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockBeginPasskeyRegistration.Lock()
+	mock.calls.BeginPasskeyRegistration = append(mock.calls.BeginPasskeyRegistration, callInfo)
+	mock.lockBeginPasskeyRegistration.Unlock()
+	return mock.BeginPasskeyRegistrationFunc(ctx, username)
+}
+
+// BeginPasskeyRegistrationCalls gets all the calls that were made to BeginPasskeyRegistration.
+// Check the length with:
+//
+//	len(mockedAuthProvider.BeginPasskeyRegistrationCalls())
+func (mock *AuthProviderMock) BeginPasskeyRegistrationCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockBeginPasskeyRegistration.RLock()
+	calls = mock.calls.BeginPasskeyRegistration
+	mock.lockBeginPasskeyRegistration.RUnlock()
+	return calls
 }
 
 // CheckUserPermission calls CheckUserPermissionFunc.
@@ -198,21 +468,23 @@ func (mock *AuthProviderMock) CheckUserPermissionCalls() []struct {
 }
 
 // CreateSession calls CreateSessionFunc.
-func (mock *AuthProviderMock) CreateSession(ctx context.Context, username string) (string, error) {
+func (mock *AuthProviderMock) CreateSession(ctx context.Context, username string, remember bool) (string, error) {
 	if mock.CreateSessionFunc == nil {
 		panic("AuthProviderMock.CreateSessionFunc: method is nil but AuthProvider.CreateSession was just called")
 	}
 	callInfo := struct {
 		Ctx      context.Context
 		Username string
+		Remember bool
 	}{
 		Ctx:      ctx,
 		Username: username,
+		Remember: remember,
 	}
 	mock.lockCreateSession.Lock()
 	mock.calls.CreateSession = append(mock.calls.CreateSession, callInfo)
 	mock.lockCreateSession.Unlock()
-	return mock.CreateSessionFunc(ctx, username)
+	return mock.CreateSessionFunc(ctx, username, remember)
 }
 
 // CreateSessionCalls gets all the calls that were made to CreateSession.
@@ -222,10 +494,12 @@ func (mock *AuthProviderMock) CreateSession(ctx context.Context, username string
 func (mock *AuthProviderMock) CreateSessionCalls() []struct {
 	Ctx      context.Context
 	Username string
+	Remember bool
 } {
 	var calls []struct {
 		Ctx      context.Context
 		Username string
+		Remember bool
 	}
 	mock.lockCreateSession.RLock()
 	calls = mock.calls.CreateSession
@@ -233,6 +507,46 @@ func (mock *AuthProviderMock) CreateSessionCalls() []struct {
 	return calls
 }
 
+// DeletePasskey calls DeletePasskeyFunc.
+func (mock *AuthProviderMock) DeletePasskey(ctx context.Context, username string, id string) error {
+	if mock.DeletePasskeyFunc == nil {
+		panic("AuthProviderMock.DeletePasskeyFunc: method is nil but AuthProvider.DeletePasskey was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		ID       string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		ID:       id,
+	}
+	mock.lockDeletePasskey.Lock()
+	mock.calls.DeletePasskey = append(mock.calls.DeletePasskey, callInfo)
+	mock.lockDeletePasskey.Unlock()
+	return mock.DeletePasskeyFunc(ctx, username, id)
+}
+
+// DeletePasskeyCalls gets all the calls that were made to DeletePasskey.
+// Check the length with:
+//
+//	len(mockedAuthProvider.DeletePasskeyCalls())
+func (mock *AuthProviderMock) DeletePasskeyCalls() []struct {
+	Ctx      context.Context
+	Username string
+	ID       string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		ID       string
+	}
+	mock.lockDeletePasskey.RLock()
+	calls = mock.calls.DeletePasskey
+	mock.lockDeletePasskey.RUnlock()
+	return calls
+}
+
 // Enabled calls EnabledFunc.
 func (mock *AuthProviderMock) Enabled() bool {
 	if mock.EnabledFunc == nil {
@@ -296,6 +610,82 @@ func (mock *AuthProviderMock) FilterUserKeysCalls() []struct {
 	return calls
 }
 
+// FinishPasskeyLogin calls FinishPasskeyLoginFunc.
+func (mock *AuthProviderMock) FinishPasskeyLogin(ctx context.Context, body []byte) (string, error) {
+	if mock.FinishPasskeyLoginFunc == nil {
+		panic("AuthProviderMock.FinishPasskeyLoginFunc: method is nil but AuthProvider.FinishPasskeyLogin was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Body []byte
+	}{
+		Ctx:  ctx,
+		Body: body,
+	}
+	mock.lockFinishPasskeyLogin.Lock()
+	mock.calls.FinishPasskeyLogin = append(mock.calls.FinishPasskeyLogin, callInfo)
+	mock.lockFinishPasskeyLogin.Unlock()
+	return mock.FinishPasskeyLoginFunc(ctx, body)
+}
+
+// FinishPasskeyLoginCalls gets all the calls that were made to FinishPasskeyLogin.
+// Check the length with:
+//
+//	len(mockedAuthProvider.FinishPasskeyLoginCalls())
+func (mock *AuthProviderMock) FinishPasskeyLoginCalls() []struct {
+	Ctx  context.Context
+	Body []byte
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Body []byte
+	}
+	mock.lockFinishPasskeyLogin.RLock()
+	calls = mock.calls.FinishPasskeyLogin
+	mock.lockFinishPasskeyLogin.RUnlock()
+	return calls
+}
+
+// FinishPasskeyRegistration calls FinishPasskeyRegistrationFunc.
+func (mock *AuthProviderMock) FinishPasskeyRegistration(ctx context.Context, username string, body []byte) error {
+	if mock.FinishPasskeyRegistrationFunc == nil {
+		panic("AuthProviderMock.FinishPasskeyRegistrationFunc: method is nil but AuthProvider.FinishPasskeyRegistration was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		Body     []byte
+	}{
+		Ctx:      ctx,
+		Username: username,
+		Body:     body,
+	}
+	mock.lockFinishPasskeyRegistration.Lock()
+	mock.calls.FinishPasskeyRegistration = append(mock.calls.FinishPasskeyRegistration, callInfo)
+	mock.lockFinishPasskeyRegistration.Unlock()
+	return mock.FinishPasskeyRegistrationFunc(ctx, username, body)
+}
+
+// FinishPasskeyRegistrationCalls gets all the calls that were made to FinishPasskeyRegistration.
+// Check the length with:
+//
+//	len(mockedAuthProvider.FinishPasskeyRegistrationCalls())
+func (mock *AuthProviderMock) FinishPasskeyRegistrationCalls() []struct {
+	Ctx      context.Context
+	Username string
+	Body     []byte
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		Body     []byte
+	}
+	mock.lockFinishPasskeyRegistration.RLock()
+	calls = mock.calls.FinishPasskeyRegistration
+	mock.lockFinishPasskeyRegistration.RUnlock()
+	return calls
+}
+
 // GetSessionUser calls GetSessionUserFunc.
 func (mock *AuthProviderMock) GetSessionUser(ctx context.Context, token string) (string, bool) {
 	if mock.GetSessionUserFunc == nil {
@@ -436,30 +826,300 @@ func (mock *AuthProviderMock) IsValidUserCalls() []struct {
 	return calls
 }
 
-// LoginTTL calls LoginTTLFunc.
-func (mock *AuthProviderMock) LoginTTL() time.Duration {
-	if mock.LoginTTLFunc == nil {
-		panic("AuthProviderMock.LoginTTLFunc: method is nil but AuthProvider.LoginTTL was just called")
+// PasskeyRequired calls PasskeyRequiredFunc.
+func (mock *AuthProviderMock) PasskeyRequired(ctx context.Context, username string) (bool, error) {
+	if mock.PasskeyRequiredFunc == nil {
+		panic("AuthProviderMock.PasskeyRequiredFunc: method is nil but AuthProvider.PasskeyRequired was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockPasskeyRequired.Lock()
+	mock.calls.PasskeyRequired = append(mock.calls.PasskeyRequired, callInfo)
+	mock.lockPasskeyRequired.Unlock()
+	return mock.PasskeyRequiredFunc(ctx, username)
+}
+
+// PasskeyRequiredCalls gets all the calls that were made to PasskeyRequired.
+// Check the length with:
+//
+//	len(mockedAuthProvider.PasskeyRequiredCalls())
+func (mock *AuthProviderMock) PasskeyRequiredCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockPasskeyRequired.RLock()
+	calls = mock.calls.PasskeyRequired
+	mock.lockPasskeyRequired.RUnlock()
+	return calls
+}
+
+// Passkeys calls PasskeysFunc.
+func (mock *AuthProviderMock) Passkeys(ctx context.Context, username string) ([]store.Passkey, error) {
+	if mock.PasskeysFunc == nil {
+		panic("AuthProviderMock.PasskeysFunc: method is nil but AuthProvider.Passkeys was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockPasskeys.Lock()
+	mock.calls.Passkeys = append(mock.calls.Passkeys, callInfo)
+	mock.lockPasskeys.Unlock()
+	return mock.PasskeysFunc(ctx, username)
+}
+
+// PasskeysCalls gets all the calls that were made to Passkeys.
+// Check the length with:
+//
+//	len(mockedAuthProvider.PasskeysCalls())
+func (mock *AuthProviderMock) PasskeysCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockPasskeys.RLock()
+	calls = mock.calls.Passkeys
+	mock.lockPasskeys.RUnlock()
+	return calls
+}
+
+// PasskeysEnabled calls PasskeysEnabledFunc.
+func (mock *AuthProviderMock) PasskeysEnabled() bool {
+	if mock.PasskeysEnabledFunc == nil {
+		panic("AuthProviderMock.PasskeysEnabledFunc: method is nil but AuthProvider.PasskeysEnabled was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockPasskeysEnabled.Lock()
+	mock.calls.PasskeysEnabled = append(mock.calls.PasskeysEnabled, callInfo)
+	mock.lockPasskeysEnabled.Unlock()
+	return mock.PasskeysEnabledFunc()
+}
+
+// PasskeysEnabledCalls gets all the calls that were made to PasskeysEnabled.
+// Check the length with:
+//
+//	len(mockedAuthProvider.PasskeysEnabledCalls())
+func (mock *AuthProviderMock) PasskeysEnabledCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockPasskeysEnabled.RLock()
+	calls = mock.calls.PasskeysEnabled
+	mock.lockPasskeysEnabled.RUnlock()
+	return calls
+}
+
+// PasswordlessEnabled calls PasswordlessEnabledFunc.
+func (mock *AuthProviderMock) PasswordlessEnabled() bool {
+	if mock.PasswordlessEnabledFunc == nil {
+		panic("AuthProviderMock.PasswordlessEnabledFunc: method is nil but AuthProvider.PasswordlessEnabled was just called")
 	}
 	callInfo := struct {
 	}{}
-	mock.lockLoginTTL.Lock()
-	mock.calls.LoginTTL = append(mock.calls.LoginTTL, callInfo)
-	mock.lockLoginTTL.Unlock()
-	return mock.LoginTTLFunc()
+	mock.lockPasswordlessEnabled.Lock()
+	mock.calls.PasswordlessEnabled = append(mock.calls.PasswordlessEnabled, callInfo)
+	mock.lockPasswordlessEnabled.Unlock()
+	return mock.PasswordlessEnabledFunc()
+}
+
+// PasswordlessEnabledCalls gets all the calls that were made to PasswordlessEnabled.
+// Check the length with:
+//
+//	len(mockedAuthProvider.PasswordlessEnabledCalls())
+func (mock *AuthProviderMock) PasswordlessEnabledCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockPasswordlessEnabled.RLock()
+	calls = mock.calls.PasswordlessEnabled
+	mock.lockPasswordlessEnabled.RUnlock()
+	return calls
+}
+
+// RecordLogin calls RecordLoginFunc.
+func (mock *AuthProviderMock) RecordLogin(r *http.Request, token string, username string) error {
+	if mock.RecordLoginFunc == nil {
+		panic("AuthProviderMock.RecordLoginFunc: method is nil but AuthProvider.RecordLogin was just called")
+	}
+	callInfo := struct {
+		R        *http.Request
+		Token    string
+		Username string
+	}{
+		R:        r,
+		Token:    token,
+		Username: username,
+	}
+	mock.lockRecordLogin.Lock()
+	mock.calls.RecordLogin = append(mock.calls.RecordLogin, callInfo)
+	mock.lockRecordLogin.Unlock()
+	return mock.RecordLoginFunc(r, token, username)
 }
 
-// LoginTTLCalls gets all the calls that were made to LoginTTL.
+// RecordLoginCalls gets all the calls that were made to RecordLogin.
 // Check the length with:
 //
-//	len(mockedAuthProvider.LoginTTLCalls())
-func (mock *AuthProviderMock) LoginTTLCalls() []struct {
+//	len(mockedAuthProvider.RecordLoginCalls())
+func (mock *AuthProviderMock) RecordLoginCalls() []struct {
+	R        *http.Request
+	Token    string
+	Username string
 } {
 	var calls []struct {
+		R        *http.Request
+		Token    string
+		Username string
 	}
-	mock.lockLoginTTL.RLock()
-	calls = mock.calls.LoginTTL
-	mock.lockLoginTTL.RUnlock()
+	mock.lockRecordLogin.RLock()
+	calls = mock.calls.RecordLogin
+	mock.lockRecordLogin.RUnlock()
+	return calls
+}
+
+// RememberEnabled calls RememberEnabledFunc.
+func (mock *AuthProviderMock) RememberEnabled() bool {
+	if mock.RememberEnabledFunc == nil {
+		panic("AuthProviderMock.RememberEnabledFunc: method is nil but AuthProvider.RememberEnabled was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockRememberEnabled.Lock()
+	mock.calls.RememberEnabled = append(mock.calls.RememberEnabled, callInfo)
+	mock.lockRememberEnabled.Unlock()
+	return mock.RememberEnabledFunc()
+}
+
+// RememberEnabledCalls gets all the calls that were made to RememberEnabled.
+// Check the length with:
+//
+//	len(mockedAuthProvider.RememberEnabledCalls())
+func (mock *AuthProviderMock) RememberEnabledCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRememberEnabled.RLock()
+	calls = mock.calls.RememberEnabled
+	mock.lockRememberEnabled.RUnlock()
+	return calls
+}
+
+// RenewSession calls RenewSessionFunc.
+func (mock *AuthProviderMock) RenewSession(ctx context.Context, token string) (store.Session, error) {
+	if mock.RenewSessionFunc == nil {
+		panic("AuthProviderMock.RenewSessionFunc: method is nil but AuthProvider.RenewSession was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token string
+	}{
+		Ctx:   ctx,
+		Token: token,
+	}
+	mock.lockRenewSession.Lock()
+	mock.calls.RenewSession = append(mock.calls.RenewSession, callInfo)
+	mock.lockRenewSession.Unlock()
+	return mock.RenewSessionFunc(ctx, token)
+}
+
+// RenewSessionCalls gets all the calls that were made to RenewSession.
+// Check the length with:
+//
+//	len(mockedAuthProvider.RenewSessionCalls())
+func (mock *AuthProviderMock) RenewSessionCalls() []struct {
+	Ctx   context.Context
+	Token string
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token string
+	}
+	mock.lockRenewSession.RLock()
+	calls = mock.calls.RenewSession
+	mock.lockRenewSession.RUnlock()
+	return calls
+}
+
+// SessionInfo calls SessionInfoFunc.
+func (mock *AuthProviderMock) SessionInfo(ctx context.Context, token string) (store.Session, bool) {
+	if mock.SessionInfoFunc == nil {
+		panic("AuthProviderMock.SessionInfoFunc: method is nil but AuthProvider.SessionInfo was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token string
+	}{
+		Ctx:   ctx,
+		Token: token,
+	}
+	mock.lockSessionInfo.Lock()
+	mock.calls.SessionInfo = append(mock.calls.SessionInfo, callInfo)
+	mock.lockSessionInfo.Unlock()
+	return mock.SessionInfoFunc(ctx, token)
+}
+
+// SessionInfoCalls gets all the calls that were made to SessionInfo.
+// Check the length with:
+//
+//	len(mockedAuthProvider.SessionInfoCalls())
+func (mock *AuthProviderMock) SessionInfoCalls() []struct {
+	Ctx   context.Context
+	Token string
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token string
+	}
+	mock.lockSessionInfo.RLock()
+	calls = mock.calls.SessionInfo
+	mock.lockSessionInfo.RUnlock()
+	return calls
+}
+
+// SessionLimits calls SessionLimitsFunc.
+func (mock *AuthProviderMock) SessionLimits(remember bool) (time.Duration, time.Duration) {
+	if mock.SessionLimitsFunc == nil {
+		panic("AuthProviderMock.SessionLimitsFunc: method is nil but AuthProvider.SessionLimits was just called")
+	}
+	callInfo := struct {
+		Remember bool
+	}{
+		Remember: remember,
+	}
+	mock.lockSessionLimits.Lock()
+	mock.calls.SessionLimits = append(mock.calls.SessionLimits, callInfo)
+	mock.lockSessionLimits.Unlock()
+	return mock.SessionLimitsFunc(remember)
+}
+
+// SessionLimitsCalls gets all the calls that were made to SessionLimits.
+// Check the length with:
+//
+//	len(mockedAuthProvider.SessionLimitsCalls())
+func (mock *AuthProviderMock) SessionLimitsCalls() []struct {
+	Remember bool
+} {
+	var calls []struct {
+		Remember bool
+	}
+	mock.lockSessionLimits.RLock()
+	calls = mock.calls.SessionLimits
+	mock.lockSessionLimits.RUnlock()
 	return calls
 }
 
@@ -494,3 +1154,39 @@ func (mock *AuthProviderMock) UserCanWriteCalls() []struct {
 	mock.lockUserCanWrite.RUnlock()
 	return calls
 }
+
+// UserSessions calls UserSessionsFunc.
+func (mock *AuthProviderMock) UserSessions(ctx context.Context, username string) ([]store.Session, error) {
+	if mock.UserSessionsFunc == nil {
+		panic("AuthProviderMock.UserSessionsFunc: method is nil but AuthProvider.UserSessions was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockUserSessions.Lock()
+	mock.calls.UserSessions = append(mock.calls.UserSessions, callInfo)
+	mock.lockUserSessions.Unlock()
+	return mock.UserSessionsFunc(ctx, username)
+}
+
+// UserSessionsCalls gets all the calls that were made to UserSessions.
+// Check the length with:
+//
+//	len(mockedAuthProvider.UserSessionsCalls())
+func (mock *AuthProviderMock) UserSessionsCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockUserSessions.RLock()
+	calls = mock.calls.UserSessions
+	mock.lockUserSessions.RUnlock()
+	return calls
+}
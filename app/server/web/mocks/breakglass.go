@@ -0,0 +1,209 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakGlassProviderMock is a mock implementation of web.BreakGlassProvider.
+//
+//	func TestSomethingThatUsesBreakGlassProvider(t *testing.T) {
+//
+//		// make and configure a mocked web.BreakGlassProvider
+//		mockedBreakGlassProvider := &BreakGlassProviderMock{
+//			ActiveBreakGlassFunc: func(username string) (string, time.Time, bool) {
+//				panic("mock out the ActiveBreakGlass method")
+//			},
+//			BreakGlassFunc: func(username string, reason string) (time.Time, error) {
+//				panic("mock out the BreakGlass method")
+//			},
+//			BreakGlassAllowedFunc: func(username string) bool {
+//				panic("mock out the BreakGlassAllowed method")
+//			},
+//			EndBreakGlassFunc: func(username string)  {
+//				panic("mock out the EndBreakGlass method")
+//			},
+//		}
+//
+//		// use mockedBreakGlassProvider in code that requires web.BreakGlassProvider
+//		// and then make assertions.
+//
+//	}
+type BreakGlassProviderMock struct {
+	// ActiveBreakGlassFunc mocks the ActiveBreakGlass method.
+	ActiveBreakGlassFunc func(username string) (string, time.Time, bool)
+
+	// BreakGlassFunc mocks the BreakGlass method.
+	BreakGlassFunc func(username string, reason string) (time.Time, error)
+
+	// BreakGlassAllowedFunc mocks the BreakGlassAllowed method.
+	BreakGlassAllowedFunc func(username string) bool
+
+	// EndBreakGlassFunc mocks the EndBreakGlass method.
+	EndBreakGlassFunc func(username string)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ActiveBreakGlass holds details about calls to the ActiveBreakGlass method.
+		ActiveBreakGlass []struct {
+			// Username is the username argument value.
+			Username string
+		}
+		// BreakGlass holds details about calls to the BreakGlass method.
+		BreakGlass []struct {
+			// Username is the username argument value.
+			Username string
+			// Reason is the reason argument value.
+			Reason string
+		}
+		// BreakGlassAllowed holds details about calls to the BreakGlassAllowed method.
+		BreakGlassAllowed []struct {
+			// Username is the username argument value.
+			Username string
+		}
+		// EndBreakGlass holds details about calls to the EndBreakGlass method.
+		EndBreakGlass []struct {
+			// Username is the username argument value.
+			Username string
+		}
+	}
+	lockActiveBreakGlass  sync.RWMutex
+	lockBreakGlass        sync.RWMutex
+	lockBreakGlassAllowed sync.RWMutex
+	lockEndBreakGlass     sync.RWMutex
+}
+
+// ActiveBreakGlass calls ActiveBreakGlassFunc.
+func (mock *BreakGlassProviderMock) ActiveBreakGlass(username string) (string, time.Time, bool) {
+	if mock.ActiveBreakGlassFunc == nil {
+		panic("BreakGlassProviderMock.ActiveBreakGlassFunc: method is nil but BreakGlassProvider.ActiveBreakGlass was just called")
+	}
+	callInfo := struct {
+		Username string
+	}{
+		Username: username,
+	}
+	mock.lockActiveBreakGlass.Lock()
+	mock.calls.ActiveBreakGlass = append(mock.calls.ActiveBreakGlass, callInfo)
+	mock.lockActiveBreakGlass.Unlock()
+	return mock.ActiveBreakGlassFunc(username)
+}
+
+// ActiveBreakGlassCalls gets all the calls that were made to ActiveBreakGlass.
+// Check the length with:
+//
+//	len(mockedBreakGlassProvider.ActiveBreakGlassCalls())
+func (mock *BreakGlassProviderMock) ActiveBreakGlassCalls() []struct {
+	Username string
+} {
+	var calls []struct {
+		Username string
+	}
+	mock.lockActiveBreakGlass.RLock()
+	calls = mock.calls.ActiveBreakGlass
+	mock.lockActiveBreakGlass.RUnlock()
+	return calls
+}
+
+// BreakGlass calls BreakGlassFunc.
+func (mock *BreakGlassProviderMock) BreakGlass(username string, reason string) (time.Time, error) {
+	if mock.BreakGlassFunc == nil {
+		panic("BreakGlassProviderMock.BreakGlassFunc: method is nil but BreakGlassProvider.BreakGlass was just called")
+	}
+	callInfo := struct {
+		Username string
+		Reason   string
+	}{
+		Username: username,
+		Reason:   reason,
+	}
+	mock.lockBreakGlass.Lock()
+	mock.calls.BreakGlass = append(mock.calls.BreakGlass, callInfo)
+	mock.lockBreakGlass.Unlock()
+	return mock.BreakGlassFunc(username, reason)
+}
+
+// BreakGlassCalls gets all the calls that were made to BreakGlass.
+// Check the length with:
+//
+//	len(mockedBreakGlassProvider.BreakGlassCalls())
+func (mock *BreakGlassProviderMock) BreakGlassCalls() []struct {
+	Username string
+	Reason   string
+} {
+	var calls []struct {
+		Username string
+		Reason   string
+	}
+	mock.lockBreakGlass.RLock()
+	calls = mock.calls.BreakGlass
+	mock.lockBreakGlass.RUnlock()
+	return calls
+}
+
+// BreakGlassAllowed calls BreakGlassAllowedFunc.
+func (mock *BreakGlassProviderMock) BreakGlassAllowed(username string) bool {
+	if mock.BreakGlassAllowedFunc == nil {
+		panic("BreakGlassProviderMock.BreakGlassAllowedFunc: method is nil but BreakGlassProvider.BreakGlassAllowed was just called")
+	}
+	callInfo := struct {
+		Username string
+	}{
+		Username: username,
+	}
+	mock.lockBreakGlassAllowed.Lock()
+	mock.calls.BreakGlassAllowed = append(mock.calls.BreakGlassAllowed, callInfo)
+	mock.lockBreakGlassAllowed.Unlock()
+	return mock.BreakGlassAllowedFunc(username)
+}
+
+// BreakGlassAllowedCalls gets all the calls that were made to BreakGlassAllowed.
+// Check the length with:
+//
+//	len(mockedBreakGlassProvider.BreakGlassAllowedCalls())
+func (mock *BreakGlassProviderMock) BreakGlassAllowedCalls() []struct {
+	Username string
+} {
+	var calls []struct {
+		Username string
+	}
+	mock.lockBreakGlassAllowed.RLock()
+	calls = mock.calls.BreakGlassAllowed
+	mock.lockBreakGlassAllowed.RUnlock()
+	return calls
+}
+
+// EndBreakGlass calls EndBreakGlassFunc.
+func (mock *BreakGlassProviderMock) EndBreakGlass(username string) {
+	if mock.EndBreakGlassFunc == nil {
+		panic("BreakGlassProviderMock.EndBreakGlassFunc: method is nil but BreakGlassProvider.EndBreakGlass was just called")
+	}
+	callInfo := struct {
+		Username string
+	}{
+		Username: username,
+	}
+	mock.lockEndBreakGlass.Lock()
+	mock.calls.EndBreakGlass = append(mock.calls.EndBreakGlass, callInfo)
+	mock.lockEndBreakGlass.Unlock()
+	mock.EndBreakGlassFunc(username)
+}
+
+// EndBreakGlassCalls gets all the calls that were made to EndBreakGlass.
+// Check the length with:
+//
+//	len(mockedBreakGlassProvider.EndBreakGlassCalls())
+func (mock *BreakGlassProviderMock) EndBreakGlassCalls() []struct {
+	Username string
+} {
+	var calls []struct {
+		Username string
+	}
+	mock.lockEndBreakGlass.RLock()
+	calls = mock.calls.EndBreakGlass
+	mock.lockEndBreakGlass.RUnlock()
+	return calls
+}
@@ -0,0 +1,70 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+)
+
+// CanaryMatcherMock is a mock implementation of web.CanaryMatcher.
+//
+//	func TestSomethingThatUsesCanaryMatcher(t *testing.T) {
+//
+//		// make and configure a mocked web.CanaryMatcher
+//		mockedCanaryMatcher := &CanaryMatcherMock{
+//			IsCanaryFunc: func(key string) bool {
+//				panic("mock out the IsCanary method")
+//			},
+//		}
+//
+//		// use mockedCanaryMatcher in code that requires web.CanaryMatcher
+//		// and then make assertions.
+//
+//	}
+type CanaryMatcherMock struct {
+	// IsCanaryFunc mocks the IsCanary method.
+	IsCanaryFunc func(key string) bool
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// IsCanary holds details about calls to the IsCanary method.
+		IsCanary []struct {
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockIsCanary sync.RWMutex
+}
+
+// IsCanary calls IsCanaryFunc.
+func (mock *CanaryMatcherMock) IsCanary(key string) bool {
+	if mock.IsCanaryFunc == nil {
+		panic("CanaryMatcherMock.IsCanaryFunc: method is nil but CanaryMatcher.IsCanary was just called")
+	}
+	callInfo := struct {
+		Key string
+	}{
+		Key: key,
+	}
+	mock.lockIsCanary.Lock()
+	mock.calls.IsCanary = append(mock.calls.IsCanary, callInfo)
+	mock.lockIsCanary.Unlock()
+	return mock.IsCanaryFunc(key)
+}
+
+// IsCanaryCalls gets all the calls that were made to IsCanary.
+// Check the length with:
+//
+//	len(mockedCanaryMatcher.IsCanaryCalls())
+func (mock *CanaryMatcherMock) IsCanaryCalls() []struct {
+	Key string
+} {
+	var calls []struct {
+		Key string
+	}
+	mock.lockIsCanary.RLock()
+	calls = mock.calls.IsCanary
+	mock.lockIsCanary.RUnlock()
+	return calls
+}
@@ -0,0 +1,136 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// ChangeLogMock is a mock implementation of web.ChangeLog.
+//
+//	func TestSomethingThatUsesChangeLog(t *testing.T) {
+//
+//		// make and configure a mocked web.ChangeLog
+//		mockedChangeLog := &ChangeLogMock{
+//			AuditKeyTimelinesFunc: func(ctx context.Context, q store.AuditQuery, interval time.Duration) (map[string][]int, error) {
+//				panic("mock out the AuditKeyTimelines method")
+//			},
+//			LastAuditEntriesFunc: func(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, error) {
+//				panic("mock out the LastAuditEntries method")
+//			},
+//		}
+//
+//		// use mockedChangeLog in code that requires web.ChangeLog
+//		// and then make assertions.
+//
+//	}
+type ChangeLogMock struct {
+	// AuditKeyTimelinesFunc mocks the AuditKeyTimelines method.
+	AuditKeyTimelinesFunc func(ctx context.Context, q store.AuditQuery, interval time.Duration) (map[string][]int, error)
+
+	// LastAuditEntriesFunc mocks the LastAuditEntries method.
+	LastAuditEntriesFunc func(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AuditKeyTimelines holds details about calls to the AuditKeyTimelines method.
+		AuditKeyTimelines []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+			// Interval is the interval argument value.
+			Interval time.Duration
+		}
+		// LastAuditEntries holds details about calls to the LastAuditEntries method.
+		LastAuditEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+		}
+	}
+	lockAuditKeyTimelines sync.RWMutex
+	lockLastAuditEntries  sync.RWMutex
+}
+
+// AuditKeyTimelines calls AuditKeyTimelinesFunc.
+func (mock *ChangeLogMock) AuditKeyTimelines(ctx context.Context, q store.AuditQuery, interval time.Duration) (map[string][]int, error) {
+	if mock.AuditKeyTimelinesFunc == nil {
+		panic("ChangeLogMock.AuditKeyTimelinesFunc: method is nil but ChangeLog.AuditKeyTimelines was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Q        store.AuditQuery
+		Interval time.Duration
+	}{
+		Ctx:      ctx,
+		Q:        q,
+		Interval: interval,
+	}
+	mock.lockAuditKeyTimelines.Lock()
+	mock.calls.AuditKeyTimelines = append(mock.calls.AuditKeyTimelines, callInfo)
+	mock.lockAuditKeyTimelines.Unlock()
+	return mock.AuditKeyTimelinesFunc(ctx, q, interval)
+}
+
+// AuditKeyTimelinesCalls gets all the calls that were made to AuditKeyTimelines.
+// Check the length with:
+//
+//	len(mockedChangeLog.AuditKeyTimelinesCalls())
+func (mock *ChangeLogMock) AuditKeyTimelinesCalls() []struct {
+	Ctx      context.Context
+	Q        store.AuditQuery
+	Interval time.Duration
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Q        store.AuditQuery
+		Interval time.Duration
+	}
+	mock.lockAuditKeyTimelines.RLock()
+	calls = mock.calls.AuditKeyTimelines
+	mock.lockAuditKeyTimelines.RUnlock()
+	return calls
+}
+
+// LastAuditEntries calls LastAuditEntriesFunc.
+func (mock *ChangeLogMock) LastAuditEntries(ctx context.Context, q store.AuditQuery) ([]store.AuditEntry, error) {
+	if mock.LastAuditEntriesFunc == nil {
+		panic("ChangeLogMock.LastAuditEntriesFunc: method is nil but ChangeLog.LastAuditEntries was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}{
+		Ctx: ctx,
+		Q:   q,
+	}
+	mock.lockLastAuditEntries.Lock()
+	mock.calls.LastAuditEntries = append(mock.calls.LastAuditEntries, callInfo)
+	mock.lockLastAuditEntries.Unlock()
+	return mock.LastAuditEntriesFunc(ctx, q)
+}
+
+// LastAuditEntriesCalls gets all the calls that were made to LastAuditEntries.
+// Check the length with:
+//
+//	len(mockedChangeLog.LastAuditEntriesCalls())
+func (mock *ChangeLogMock) LastAuditEntriesCalls() []struct {
+	Ctx context.Context
+	Q   store.AuditQuery
+} {
+	var calls []struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}
+	mock.lockLastAuditEntries.RLock()
+	calls = mock.calls.LastAuditEntries
+	mock.lockLastAuditEntries.RUnlock()
+	return calls
+}
@@ -0,0 +1,173 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// FreezeStoreMock is a mock implementation of web.FreezeStore.
+//
+//	func TestSomethingThatUsesFreezeStore(t *testing.T) {
+//
+//		// make and configure a mocked web.FreezeStore
+//		mockedFreezeStore := &FreezeStoreMock{
+//			FreezePrefixFunc: func(ctx context.Context, f store.Freeze) error {
+//				panic("mock out the FreezePrefix method")
+//			},
+//			FreezesFunc: func(ctx context.Context) ([]store.Freeze, error) {
+//				panic("mock out the Freezes method")
+//			},
+//			UnfreezeFunc: func(ctx context.Context, prefix string) error {
+//				panic("mock out the Unfreeze method")
+//			},
+//		}
+//
+//		// use mockedFreezeStore in code that requires web.FreezeStore
+//		// and then make assertions.
+//
+//	}
+type FreezeStoreMock struct {
+	// FreezePrefixFunc mocks the FreezePrefix method.
+	FreezePrefixFunc func(ctx context.Context, f store.Freeze) error
+
+	// FreezesFunc mocks the Freezes method.
+	FreezesFunc func(ctx context.Context) ([]store.Freeze, error)
+
+	// UnfreezeFunc mocks the Unfreeze method.
+	UnfreezeFunc func(ctx context.Context, prefix string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// FreezePrefix holds details about calls to the FreezePrefix method.
+		FreezePrefix []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// F is the f argument value.
+			F store.Freeze
+		}
+		// Freezes holds details about calls to the Freezes method.
+		Freezes []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Unfreeze holds details about calls to the Unfreeze method.
+		Unfreeze []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Prefix is the prefix argument value.
+			Prefix string
+		}
+	}
+	lockFreezePrefix sync.RWMutex
+	lockFreezes      sync.RWMutex
+	lockUnfreeze     sync.RWMutex
+}
+
+// FreezePrefix calls FreezePrefixFunc.
+func (mock *FreezeStoreMock) FreezePrefix(ctx context.Context, f store.Freeze) error {
+	if mock.FreezePrefixFunc == nil {
+		panic("FreezeStoreMock.FreezePrefixFunc: method is nil but FreezeStore.FreezePrefix was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		F   store.Freeze
+	}{
+		Ctx: ctx,
+		F:   f,
+	}
+	mock.lockFreezePrefix.Lock()
+	mock.calls.FreezePrefix = append(mock.calls.FreezePrefix, callInfo)
+	mock.lockFreezePrefix.Unlock()
+	return mock.FreezePrefixFunc(ctx, f)
+}
+
+// FreezePrefixCalls gets all the calls that were made to FreezePrefix.
+// Check the length with:
+//
+//	len(mockedFreezeStore.FreezePrefixCalls())
+func (mock *FreezeStoreMock) FreezePrefixCalls() []struct {
+	Ctx context.Context
+	F   store.Freeze
+} {
+	var calls []struct {
+		Ctx context.Context
+		F   store.Freeze
+	}
+	mock.lockFreezePrefix.RLock()
+	calls = mock.calls.FreezePrefix
+	mock.lockFreezePrefix.RUnlock()
+	return calls
+}
+
+// Freezes calls FreezesFunc.
+func (mock *FreezeStoreMock) Freezes(ctx context.Context) ([]store.Freeze, error) {
+	if mock.FreezesFunc == nil {
+		panic("FreezeStoreMock.FreezesFunc: method is nil but FreezeStore.Freezes was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockFreezes.Lock()
+	mock.calls.Freezes = append(mock.calls.Freezes, callInfo)
+	mock.lockFreezes.Unlock()
+	return mock.FreezesFunc(ctx)
+}
+
+// FreezesCalls gets all the calls that were made to Freezes.
+// Check the length with:
+//
+//	len(mockedFreezeStore.FreezesCalls())
+func (mock *FreezeStoreMock) FreezesCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockFreezes.RLock()
+	calls = mock.calls.Freezes
+	mock.lockFreezes.RUnlock()
+	return calls
+}
+
+// Unfreeze calls UnfreezeFunc.
+func (mock *FreezeStoreMock) Unfreeze(ctx context.Context, prefix string) error {
+	if mock.UnfreezeFunc == nil {
+		panic("FreezeStoreMock.UnfreezeFunc: method is nil but FreezeStore.Unfreeze was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Prefix string
+	}{
+		Ctx:    ctx,
+		Prefix: prefix,
+	}
+	mock.lockUnfreeze.Lock()
+	mock.calls.Unfreeze = append(mock.calls.Unfreeze, callInfo)
+	mock.lockUnfreeze.Unlock()
+	return mock.UnfreezeFunc(ctx, prefix)
+}
+
+// UnfreezeCalls gets all the calls that were made to Unfreeze.
+// Check the length with:
+//
+//	len(mockedFreezeStore.UnfreezeCalls())
+func (mock *FreezeStoreMock) UnfreezeCalls() []struct {
+	Ctx    context.Context
+	Prefix string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Prefix string
+	}
+	mock.lockUnfreeze.RLock()
+	calls = mock.calls.Unfreeze
+	mock.lockUnfreeze.RUnlock()
+	return calls
+}
@@ -0,0 +1,76 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+)
+
+// HistoryPolicyMock is a mock implementation of web.HistoryPolicy.
+//
+//	func TestSomethingThatUsesHistoryPolicy(t *testing.T) {
+//
+//		// make and configure a mocked web.HistoryPolicy
+//		mockedHistoryPolicy := &HistoryPolicyMock{
+//			VisibleFunc: func(key string, admin bool) bool {
+//				panic("mock out the Visible method")
+//			},
+//		}
+//
+//		// use mockedHistoryPolicy in code that requires web.HistoryPolicy
+//		// and then make assertions.
+//
+//	}
+type HistoryPolicyMock struct {
+	// VisibleFunc mocks the Visible method.
+	VisibleFunc func(key string, admin bool) bool
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Visible holds details about calls to the Visible method.
+		Visible []struct {
+			// Key is the key argument value.
+			Key string
+			// Admin is the admin argument value.
+			Admin bool
+		}
+	}
+	lockVisible sync.RWMutex
+}
+
+// Visible calls VisibleFunc.
+func (mock *HistoryPolicyMock) Visible(key string, admin bool) bool {
+	if mock.VisibleFunc == nil {
+		panic("HistoryPolicyMock.VisibleFunc: method is nil but HistoryPolicy.Visible was just called")
+	}
+	callInfo := struct {
+		Key   string
+		Admin bool
+	}{
+		Key:   key,
+		Admin: admin,
+	}
+	mock.lockVisible.Lock()
+	mock.calls.Visible = append(mock.calls.Visible, callInfo)
+	mock.lockVisible.Unlock()
+	return mock.VisibleFunc(key, admin)
+}
+
+// VisibleCalls gets all the calls that were made to Visible.
+// Check the length with:
+//
+//	len(mockedHistoryPolicy.VisibleCalls())
+func (mock *HistoryPolicyMock) VisibleCalls() []struct {
+	Key   string
+	Admin bool
+} {
+	var calls []struct {
+		Key   string
+		Admin bool
+	}
+	mock.lockVisible.RLock()
+	calls = mock.calls.Visible
+	mock.lockVisible.RUnlock()
+	return calls
+}
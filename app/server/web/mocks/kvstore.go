@@ -30,15 +30,24 @@ import (
 //			ListFunc: func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error) {
 //				panic("mock out the List method")
 //			},
+//			ListPageFunc: func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+//				panic("mock out the ListPage method")
+//			},
 //			SecretsEnabledFunc: func() bool {
 //				panic("mock out the SecretsEnabled method")
 //			},
-//			SetFunc: func(ctx context.Context, key string, value []byte, format string) (bool, error) {
-//				panic("mock out the Set method")
+//			SetMetaFunc: func(ctx context.Context, key string, meta store.KeyMeta) error {
+//				panic("mock out the SetMeta method")
+//			},
+//			SetWithOptionsFunc: func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+//				panic("mock out the SetWithOptions method")
 //			},
 //			SetWithVersionFunc: func(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error {
 //				panic("mock out the SetWithVersion method")
 //			},
+//			TxnFunc: func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+//				panic("mock out the Txn method")
+//			},
 //		}
 //
 //		// use mockedKVStore in code that requires web.KVStore
@@ -58,15 +67,24 @@ type KVStoreMock struct {
 	// ListFunc mocks the List method.
 	ListFunc func(ctx context.Context, filter enum.SecretsFilter) ([]store.KeyInfo, error)
 
+	// ListPageFunc mocks the ListPage method.
+	ListPageFunc func(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error)
+
 	// SecretsEnabledFunc mocks the SecretsEnabled method.
 	SecretsEnabledFunc func() bool
 
-	// SetFunc mocks the Set method.
-	SetFunc func(ctx context.Context, key string, value []byte, format string) (bool, error)
+	// SetMetaFunc mocks the SetMeta method.
+	SetMetaFunc func(ctx context.Context, key string, meta store.KeyMeta) error
+
+	// SetWithOptionsFunc mocks the SetWithOptions method.
+	SetWithOptionsFunc func(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error)
 
 	// SetWithVersionFunc mocks the SetWithVersion method.
 	SetWithVersionFunc func(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error
 
+	// TxnFunc mocks the Txn method.
+	TxnFunc func(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Delete holds details about calls to the Delete method.
@@ -97,11 +115,27 @@ type KVStoreMock struct {
 			// Filter is the filter argument value.
 			Filter enum.SecretsFilter
 		}
+		// ListPage holds details about calls to the ListPage method.
+		ListPage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.ListQuery
+		}
 		// SecretsEnabled holds details about calls to the SecretsEnabled method.
 		SecretsEnabled []struct {
 		}
-		// Set holds details about calls to the Set method.
-		Set []struct {
+		// SetMeta holds details about calls to the SetMeta method.
+		SetMeta []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Meta is the meta argument value.
+			Meta store.KeyMeta
+		}
+		// SetWithOptions holds details about calls to the SetWithOptions method.
+		SetWithOptions []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// Key is the key argument value.
@@ -110,6 +144,8 @@ type KVStoreMock struct {
 			Value []byte
 			// Format is the format argument value.
 			Format string
+			// Opts is the opts argument value.
+			Opts store.SetOptions
 		}
 		// SetWithVersion holds details about calls to the SetWithVersion method.
 		SetWithVersion []struct {
@@ -124,14 +160,24 @@ type KVStoreMock struct {
 			// ExpectedVersion is the expectedVersion argument value.
 			ExpectedVersion time.Time
 		}
+		// Txn holds details about calls to the Txn method.
+		Txn []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ops is the ops argument value.
+			Ops []store.TxnOp
+		}
 	}
 	lockDelete         sync.RWMutex
 	lockGetInfo        sync.RWMutex
 	lockGetWithFormat  sync.RWMutex
 	lockList           sync.RWMutex
+	lockListPage       sync.RWMutex
 	lockSecretsEnabled sync.RWMutex
-	lockSet            sync.RWMutex
+	lockSetMeta        sync.RWMutex
+	lockSetWithOptions sync.RWMutex
 	lockSetWithVersion sync.RWMutex
+	lockTxn            sync.RWMutex
 }
 
 // Delete calls DeleteFunc.
@@ -278,6 +324,42 @@ func (mock *KVStoreMock) ListCalls() []struct {
 	return calls
 }
 
+// ListPage calls ListPageFunc.
+func (mock *KVStoreMock) ListPage(ctx context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+	if mock.ListPageFunc == nil {
+		panic("KVStoreMock.ListPageFunc: method is nil but KVStore.ListPage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Q   store.ListQuery
+	}{
+		Ctx: ctx,
+		Q:   q,
+	}
+	mock.lockListPage.Lock()
+	mock.calls.ListPage = append(mock.calls.ListPage, callInfo)
+	mock.lockListPage.Unlock()
+	return mock.ListPageFunc(ctx, q)
+}
+
+// ListPageCalls gets all the calls that were made to ListPage.
+// Check the length with:
+//
+//	len(mockedKVStore.ListPageCalls())
+func (mock *KVStoreMock) ListPageCalls() []struct {
+	Ctx context.Context
+	Q   store.ListQuery
+} {
+	var calls []struct {
+		Ctx context.Context
+		Q   store.ListQuery
+	}
+	mock.lockListPage.RLock()
+	calls = mock.calls.ListPage
+	mock.lockListPage.RUnlock()
+	return calls
+}
+
 // SecretsEnabled calls SecretsEnabledFunc.
 func (mock *KVStoreMock) SecretsEnabled() bool {
 	if mock.SecretsEnabledFunc == nil {
@@ -305,47 +387,91 @@ func (mock *KVStoreMock) SecretsEnabledCalls() []struct {
 	return calls
 }
 
-// Set calls SetFunc.
-func (mock *KVStoreMock) Set(ctx context.Context, key string, value []byte, format string) (bool, error) {
-	if mock.SetFunc == nil {
-		panic("KVStoreMock.SetFunc: method is nil but KVStore.Set was just called")
+// SetMeta calls SetMetaFunc.
+func (mock *KVStoreMock) SetMeta(ctx context.Context, key string, meta store.KeyMeta) error {
+	if mock.SetMetaFunc == nil {
+		panic("KVStoreMock.SetMetaFunc: method is nil but KVStore.SetMeta was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Key  string
+		Meta store.KeyMeta
+	}{
+		Ctx:  ctx,
+		Key:  key,
+		Meta: meta,
+	}
+	mock.lockSetMeta.Lock()
+	mock.calls.SetMeta = append(mock.calls.SetMeta, callInfo)
+	mock.lockSetMeta.Unlock()
+	return mock.SetMetaFunc(ctx, key, meta)
+}
+
+// SetMetaCalls gets all the calls that were made to SetMeta.
+// Check the length with:
+//
+//	len(mockedKVStore.SetMetaCalls())
+func (mock *KVStoreMock) SetMetaCalls() []struct {
+	Ctx  context.Context
+	Key  string
+	Meta store.KeyMeta
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Key  string
+		Meta store.KeyMeta
+	}
+	mock.lockSetMeta.RLock()
+	calls = mock.calls.SetMeta
+	mock.lockSetMeta.RUnlock()
+	return calls
+}
+
+// SetWithOptions calls SetWithOptionsFunc.
+func (mock *KVStoreMock) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts store.SetOptions) (bool, error) {
+	if mock.SetWithOptionsFunc == nil {
+		panic("KVStoreMock.SetWithOptionsFunc: method is nil but KVStore.SetWithOptions was just called")
 	}
 	callInfo := struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
 	}{
 		Ctx:    ctx,
 		Key:    key,
 		Value:  value,
 		Format: format,
+		Opts:   opts,
 	}
-	mock.lockSet.Lock()
-	mock.calls.Set = append(mock.calls.Set, callInfo)
-	mock.lockSet.Unlock()
-	return mock.SetFunc(ctx, key, value, format)
+	mock.lockSetWithOptions.Lock()
+	mock.calls.SetWithOptions = append(mock.calls.SetWithOptions, callInfo)
+	mock.lockSetWithOptions.Unlock()
+	return mock.SetWithOptionsFunc(ctx, key, value, format, opts)
 }
 
-// SetCalls gets all the calls that were made to Set.
+// SetWithOptionsCalls gets all the calls that were made to SetWithOptions.
 // Check the length with:
 //
-//	len(mockedKVStore.SetCalls())
-func (mock *KVStoreMock) SetCalls() []struct {
+//	len(mockedKVStore.SetWithOptionsCalls())
+func (mock *KVStoreMock) SetWithOptionsCalls() []struct {
 	Ctx    context.Context
 	Key    string
 	Value  []byte
 	Format string
+	Opts   store.SetOptions
 } {
 	var calls []struct {
 		Ctx    context.Context
 		Key    string
 		Value  []byte
 		Format string
+		Opts   store.SetOptions
 	}
-	mock.lockSet.RLock()
-	calls = mock.calls.Set
-	mock.lockSet.RUnlock()
+	mock.lockSetWithOptions.RLock()
+	calls = mock.calls.SetWithOptions
+	mock.lockSetWithOptions.RUnlock()
 	return calls
 }
 
@@ -396,3 +522,39 @@ func (mock *KVStoreMock) SetWithVersionCalls() []struct {
 	mock.lockSetWithVersion.RUnlock()
 	return calls
 }
+
+// Txn calls TxnFunc.
+func (mock *KVStoreMock) Txn(ctx context.Context, ops []store.TxnOp) ([]store.TxnResult, error) {
+	if mock.TxnFunc == nil {
+		panic("KVStoreMock.TxnFunc: method is nil but KVStore.Txn was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ops []store.TxnOp
+	}{
+		Ctx: ctx,
+		Ops: ops,
+	}
+	mock.lockTxn.Lock()
+	mock.calls.Txn = append(mock.calls.Txn, callInfo)
+	mock.lockTxn.Unlock()
+	return mock.TxnFunc(ctx, ops)
+}
+
+// TxnCalls gets all the calls that were made to Txn.
+// Check the length with:
+//
+//	len(mockedKVStore.TxnCalls())
+func (mock *KVStoreMock) TxnCalls() []struct {
+	Ctx context.Context
+	Ops []store.TxnOp
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ops []store.TxnOp
+	}
+	mock.lockTxn.RLock()
+	calls = mock.calls.Txn
+	mock.lockTxn.RUnlock()
+	return calls
+}
@@ -0,0 +1,89 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+)
+
+// OwnerPolicyMock is a mock implementation of web.OwnerPolicy.
+//
+//	func TestSomethingThatUsesOwnerPolicy(t *testing.T) {
+//
+//		// make and configure a mocked web.OwnerPolicy
+//		mockedOwnerPolicy := &OwnerPolicyMock{
+//			CanDeleteFunc: func(ctx context.Context, key string, identity string, admin bool) (bool, error) {
+//				panic("mock out the CanDelete method")
+//			},
+//		}
+//
+//		// use mockedOwnerPolicy in code that requires web.OwnerPolicy
+//		// and then make assertions.
+//
+//	}
+type OwnerPolicyMock struct {
+	// CanDeleteFunc mocks the CanDelete method.
+	CanDeleteFunc func(ctx context.Context, key string, identity string, admin bool) (bool, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CanDelete holds details about calls to the CanDelete method.
+		CanDelete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Identity is the identity argument value.
+			Identity string
+			// Admin is the admin argument value.
+			Admin bool
+		}
+	}
+	lockCanDelete sync.RWMutex
+}
+
+// CanDelete calls CanDeleteFunc.
+func (mock *OwnerPolicyMock) CanDelete(ctx context.Context, key string, identity string, admin bool) (bool, error) {
+	if mock.CanDeleteFunc == nil {
+		panic("OwnerPolicyMock.CanDeleteFunc: method is nil but OwnerPolicy.CanDelete was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Key      string
+		Identity string
+		Admin    bool
+	}{
+		Ctx:      ctx,
+		Key:      key,
+		Identity: identity,
+		Admin:    admin,
+	}
+	mock.lockCanDelete.Lock()
+	mock.calls.CanDelete = append(mock.calls.CanDelete, callInfo)
+	mock.lockCanDelete.Unlock()
+	return mock.CanDeleteFunc(ctx, key, identity, admin)
+}
+
+// CanDeleteCalls gets all the calls that were made to CanDelete.
+// Check the length with:
+//
+//	len(mockedOwnerPolicy.CanDeleteCalls())
+func (mock *OwnerPolicyMock) CanDeleteCalls() []struct {
+	Ctx      context.Context
+	Key      string
+	Identity string
+	Admin    bool
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Key      string
+		Identity string
+		Admin    bool
+	}
+	mock.lockCanDelete.RLock()
+	calls = mock.calls.CanDelete
+	mock.lockCanDelete.RUnlock()
+	return calls
+}
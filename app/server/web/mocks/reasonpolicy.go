@@ -0,0 +1,70 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+)
+
+// ReasonPolicyMock is a mock implementation of web.ReasonPolicy.
+//
+//	func TestSomethingThatUsesReasonPolicy(t *testing.T) {
+//
+//		// make and configure a mocked web.ReasonPolicy
+//		mockedReasonPolicy := &ReasonPolicyMock{
+//			RequiresReasonFunc: func(key string) bool {
+//				panic("mock out the RequiresReason method")
+//			},
+//		}
+//
+//		// use mockedReasonPolicy in code that requires web.ReasonPolicy
+//		// and then make assertions.
+//
+//	}
+type ReasonPolicyMock struct {
+	// RequiresReasonFunc mocks the RequiresReason method.
+	RequiresReasonFunc func(key string) bool
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// RequiresReason holds details about calls to the RequiresReason method.
+		RequiresReason []struct {
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockRequiresReason sync.RWMutex
+}
+
+// RequiresReason calls RequiresReasonFunc.
+func (mock *ReasonPolicyMock) RequiresReason(key string) bool {
+	if mock.RequiresReasonFunc == nil {
+		panic("ReasonPolicyMock.RequiresReasonFunc: method is nil but ReasonPolicy.RequiresReason was just called")
+	}
+	callInfo := struct {
+		Key string
+	}{
+		Key: key,
+	}
+	mock.lockRequiresReason.Lock()
+	mock.calls.RequiresReason = append(mock.calls.RequiresReason, callInfo)
+	mock.lockRequiresReason.Unlock()
+	return mock.RequiresReasonFunc(key)
+}
+
+// RequiresReasonCalls gets all the calls that were made to RequiresReason.
+// Check the length with:
+//
+//	len(mockedReasonPolicy.RequiresReasonCalls())
+func (mock *ReasonPolicyMock) RequiresReasonCalls() []struct {
+	Key string
+} {
+	var calls []struct {
+		Key string
+	}
+	mock.lockRequiresReason.RLock()
+	calls = mock.calls.RequiresReason
+	mock.lockRequiresReason.RUnlock()
+	return calls
+}
@@ -0,0 +1,79 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// RecentActivityMock is a mock implementation of web.RecentActivity.
+//
+//	func TestSomethingThatUsesRecentActivity(t *testing.T) {
+//
+//		// make and configure a mocked web.RecentActivity
+//		mockedRecentActivity := &RecentActivityMock{
+//			RecentAuditKeysFunc: func(ctx context.Context, q store.AuditQuery) ([]store.AuditRecentKey, error) {
+//				panic("mock out the RecentAuditKeys method")
+//			},
+//		}
+//
+//		// use mockedRecentActivity in code that requires web.RecentActivity
+//		// and then make assertions.
+//
+//	}
+type RecentActivityMock struct {
+	// RecentAuditKeysFunc mocks the RecentAuditKeys method.
+	RecentAuditKeysFunc func(ctx context.Context, q store.AuditQuery) ([]store.AuditRecentKey, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// RecentAuditKeys holds details about calls to the RecentAuditKeys method.
+		RecentAuditKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q store.AuditQuery
+		}
+	}
+	lockRecentAuditKeys sync.RWMutex
+}
+
+// RecentAuditKeys calls RecentAuditKeysFunc.
+func (mock *RecentActivityMock) RecentAuditKeys(ctx context.Context, q store.AuditQuery) ([]store.AuditRecentKey, error) {
+	if mock.RecentAuditKeysFunc == nil {
+		panic("RecentActivityMock.RecentAuditKeysFunc: method is nil but RecentActivity.RecentAuditKeys was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}{
+		Ctx: ctx,
+		Q:   q,
+	}
+	mock.lockRecentAuditKeys.Lock()
+	mock.calls.RecentAuditKeys = append(mock.calls.RecentAuditKeys, callInfo)
+	mock.lockRecentAuditKeys.Unlock()
+	return mock.RecentAuditKeysFunc(ctx, q)
+}
+
+// RecentAuditKeysCalls gets all the calls that were made to RecentAuditKeys.
+// Check the length with:
+//
+//	len(mockedRecentActivity.RecentAuditKeysCalls())
+func (mock *RecentActivityMock) RecentAuditKeysCalls() []struct {
+	Ctx context.Context
+	Q   store.AuditQuery
+} {
+	var calls []struct {
+		Ctx context.Context
+		Q   store.AuditQuery
+	}
+	mock.lockRecentAuditKeys.RLock()
+	calls = mock.calls.RecentAuditKeys
+	mock.lockRecentAuditKeys.RUnlock()
+	return calls
+}
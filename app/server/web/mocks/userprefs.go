@@ -0,0 +1,359 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/umputun/stash/app/store"
+)
+
+// UserPrefsMock is a mock implementation of web.UserPrefs.
+//
+//	func TestSomethingThatUsesUserPrefs(t *testing.T) {
+//
+//		// make and configure a mocked web.UserPrefs
+//		mockedUserPrefs := &UserPrefsMock{
+//			DeleteSearchFunc: func(ctx context.Context, username string, name string) error {
+//				panic("mock out the DeleteSearch method")
+//			},
+//			PinKeyFunc: func(ctx context.Context, username string, key string) error {
+//				panic("mock out the PinKey method")
+//			},
+//			PinnedKeysFunc: func(ctx context.Context, username string) ([]string, error) {
+//				panic("mock out the PinnedKeys method")
+//			},
+//			SaveSearchFunc: func(ctx context.Context, username string, name string, query string) error {
+//				panic("mock out the SaveSearch method")
+//			},
+//			SavedSearchesFunc: func(ctx context.Context, username string) ([]store.SavedSearch, error) {
+//				panic("mock out the SavedSearches method")
+//			},
+//			UnpinKeyFunc: func(ctx context.Context, username string, key string) error {
+//				panic("mock out the UnpinKey method")
+//			},
+//		}
+//
+//		// use mockedUserPrefs in code that requires web.UserPrefs
+//		// and then make assertions.
+//
+//	}
+type UserPrefsMock struct {
+	// DeleteSearchFunc mocks the DeleteSearch method.
+	DeleteSearchFunc func(ctx context.Context, username string, name string) error
+
+	// PinKeyFunc mocks the PinKey method.
+	PinKeyFunc func(ctx context.Context, username string, key string) error
+
+	// PinnedKeysFunc mocks the PinnedKeys method.
+	PinnedKeysFunc func(ctx context.Context, username string) ([]string, error)
+
+	// SaveSearchFunc mocks the SaveSearch method.
+	SaveSearchFunc func(ctx context.Context, username string, name string, query string) error
+
+	// SavedSearchesFunc mocks the SavedSearches method.
+	SavedSearchesFunc func(ctx context.Context, username string) ([]store.SavedSearch, error)
+
+	// UnpinKeyFunc mocks the UnpinKey method.
+	UnpinKeyFunc func(ctx context.Context, username string, key string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// DeleteSearch holds details about calls to the DeleteSearch method.
+		DeleteSearch []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Name is the name argument value.
+			Name string
+		}
+		// PinKey holds details about calls to the PinKey method.
+		PinKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Key is the key argument value.
+			Key string
+		}
+		// PinnedKeys holds details about calls to the PinnedKeys method.
+		PinnedKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// SaveSearch holds details about calls to the SaveSearch method.
+		SaveSearch []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Name is the name argument value.
+			Name string
+			// Query is the query argument value.
+			Query string
+		}
+		// SavedSearches holds details about calls to the SavedSearches method.
+		SavedSearches []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// UnpinKey holds details about calls to the UnpinKey method.
+		UnpinKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockDeleteSearch  sync.RWMutex
+	lockPinKey        sync.RWMutex
+	lockPinnedKeys    sync.RWMutex
+	lockSaveSearch    sync.RWMutex
+	lockSavedSearches sync.RWMutex
+	lockUnpinKey      sync.RWMutex
+}
+
+// DeleteSearch calls DeleteSearchFunc.
+func (mock *UserPrefsMock) DeleteSearch(ctx context.Context, username string, name string) error {
+	if mock.DeleteSearchFunc == nil {
+		panic("UserPrefsMock.DeleteSearchFunc: method is nil but UserPrefs.DeleteSearch was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		Name     string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		Name:     name,
+	}
+	mock.lockDeleteSearch.Lock()
+	mock.calls.DeleteSearch = append(mock.calls.DeleteSearch, callInfo)
+	mock.lockDeleteSearch.Unlock()
+	return mock.DeleteSearchFunc(ctx, username, name)
+}
+
+// DeleteSearchCalls gets all the calls that were made to DeleteSearch.
+// Check the length with:
+//
+//	len(mockedUserPrefs.DeleteSearchCalls())
+func (mock *UserPrefsMock) DeleteSearchCalls() []struct {
+	Ctx      context.Context
+	Username string
+	Name     string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		Name     string
+	}
+	mock.lockDeleteSearch.RLock()
+	calls = mock.calls.DeleteSearch
+	mock.lockDeleteSearch.RUnlock()
+	return calls
+}
+
+// PinKey calls PinKeyFunc.
+func (mock *UserPrefsMock) PinKey(ctx context.Context, username string, key string) error {
+	if mock.PinKeyFunc == nil {
+		panic("UserPrefsMock.PinKeyFunc: method is nil but UserPrefs.PinKey was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		Key      string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		Key:      key,
+	}
+	mock.lockPinKey.Lock()
+	mock.calls.PinKey = append(mock.calls.PinKey, callInfo)
+	mock.lockPinKey.Unlock()
+	return mock.PinKeyFunc(ctx, username, key)
+}
+
+// PinKeyCalls gets all the calls that were made to PinKey.
+// Check the length with:
+//
+//	len(mockedUserPrefs.PinKeyCalls())
+func (mock *UserPrefsMock) PinKeyCalls() []struct {
+	Ctx      context.Context
+	Username string
+	Key      string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		Key      string
+	}
+	mock.lockPinKey.RLock()
+	calls = mock.calls.PinKey
+	mock.lockPinKey.RUnlock()
+	return calls
+}
+
+// PinnedKeys calls PinnedKeysFunc.
+func (mock *UserPrefsMock) PinnedKeys(ctx context.Context, username string) ([]string, error) {
+	if mock.PinnedKeysFunc == nil {
+		panic("UserPrefsMock.PinnedKeysFunc: method is nil but UserPrefs.PinnedKeys was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockPinnedKeys.Lock()
+	mock.calls.PinnedKeys = append(mock.calls.PinnedKeys, callInfo)
+	mock.lockPinnedKeys.Unlock()
+	return mock.PinnedKeysFunc(ctx, username)
+}
+
+// PinnedKeysCalls gets all the calls that were made to PinnedKeys.
+// Check the length with:
+//
+//	len(mockedUserPrefs.PinnedKeysCalls())
+func (mock *UserPrefsMock) PinnedKeysCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockPinnedKeys.RLock()
+	calls = mock.calls.PinnedKeys
+	mock.lockPinnedKeys.RUnlock()
+	return calls
+}
+
+// SaveSearch calls SaveSearchFunc.
+func (mock *UserPrefsMock) SaveSearch(ctx context.Context, username string, name string, query string) error {
+	if mock.SaveSearchFunc == nil {
+		panic("UserPrefsMock.SaveSearchFunc: method is nil but UserPrefs.SaveSearch was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		Name     string
+		Query    string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		Name:     name,
+		Query:    query,
+	}
+	mock.lockSaveSearch.Lock()
+	mock.calls.SaveSearch = append(mock.calls.SaveSearch, callInfo)
+	mock.lockSaveSearch.Unlock()
+	return mock.SaveSearchFunc(ctx, username, name, query)
+}
+
+// SaveSearchCalls gets all the calls that were made to SaveSearch.
+// Check the length with:
+//
+//	len(mockedUserPrefs.SaveSearchCalls())
+func (mock *UserPrefsMock) SaveSearchCalls() []struct {
+	Ctx      context.Context
+	Username string
+	Name     string
+	Query    string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		Name     string
+		Query    string
+	}
+	mock.lockSaveSearch.RLock()
+	calls = mock.calls.SaveSearch
+	mock.lockSaveSearch.RUnlock()
+	return calls
+}
+
+// SavedSearches calls SavedSearchesFunc.
+func (mock *UserPrefsMock) SavedSearches(ctx context.Context, username string) ([]store.SavedSearch, error) {
+	if mock.SavedSearchesFunc == nil {
+		panic("UserPrefsMock.SavedSearchesFunc: method is nil but UserPrefs.SavedSearches was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockSavedSearches.Lock()
+	mock.calls.SavedSearches = append(mock.calls.SavedSearches, callInfo)
+	mock.lockSavedSearches.Unlock()
+	return mock.SavedSearchesFunc(ctx, username)
+}
+
+// SavedSearchesCalls gets all the calls that were made to SavedSearches.
+// Check the length with:
+//
+//	len(mockedUserPrefs.SavedSearchesCalls())
+func (mock *UserPrefsMock) SavedSearchesCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockSavedSearches.RLock()
+	calls = mock.calls.SavedSearches
+	mock.lockSavedSearches.RUnlock()
+	return calls
+}
+
+// UnpinKey calls UnpinKeyFunc.
+func (mock *UserPrefsMock) UnpinKey(ctx context.Context, username string, key string) error {
+	if mock.UnpinKeyFunc == nil {
+		panic("UserPrefsMock.UnpinKeyFunc: method is nil but UserPrefs.UnpinKey was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+		Key      string
+	}{
+		Ctx:      ctx,
+		Username: username,
+		Key:      key,
+	}
+	mock.lockUnpinKey.Lock()
+	mock.calls.UnpinKey = append(mock.calls.UnpinKey, callInfo)
+	mock.lockUnpinKey.Unlock()
+	return mock.UnpinKeyFunc(ctx, username, key)
+}
+
+// UnpinKeyCalls gets all the calls that were made to UnpinKey.
+// Check the length with:
+//
+//	len(mockedUserPrefs.UnpinKeyCalls())
+func (mock *UserPrefsMock) UnpinKeyCalls() []struct {
+	Ctx      context.Context
+	Username string
+	Key      string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+		Key      string
+	}
+	mock.lockUnpinKey.RLock()
+	calls = mock.calls.UnpinKey
+	mock.lockUnpinKey.RUnlock()
+	return calls
+}
@@ -2,94 +2,96 @@ package web
 
 import (
 	"net/http"
+	"net/url"
 	"strconv"
 
 	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
 )
 
-// handleIndex renders the main page.
+// handleIndex renders the main page. The list state can come from a shared link, see applyLinkState.
 func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
-	secretsFilter := h.getSecretsFilter(r)
-	keys, err := h.Store.List(r.Context(), secretsFilter)
+	h.applyLinkState(w, r)
+	params := h.getListParams(w, r)
+	username := h.getCurrentUser(r)
+	data, err := h.keyListData(r, params, requestPage(r, params.viewMode), true)
 	if err != nil {
 		log.Printf("[ERROR] failed to list keys: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	username := h.getCurrentUser(r)
-	filteredKeys := h.filterKeysByPermission(username, keys)
-
-	sortMode := h.getSortMode(r)
-	h.sortByMode(filteredKeys, sortMode)
-
-	// pagination
-	totalKeys := len(filteredKeys)
-	page := 1
-	if p := r.URL.Query().Get("page"); p != "" {
-		if parsed, parseErr := strconv.Atoi(p); parseErr == nil && parsed > 0 {
-			page = parsed
-		}
-	}
-	pr := h.paginate(filteredKeys, page, h.PageSize)
-
-	data := templateData{
-		Keys:         pr.keys,
-		Theme:        h.getTheme(r),
-		ViewMode:     h.getViewMode(r),
-		SortMode:     sortMode,
-		AuthEnabled:  h.Auth.Enabled(),
-		AuditEnabled: h.AuditEnabled,
-		BaseURL:      h.BaseURL,
-		CanWrite:     h.Auth.UserCanWrite(username),
-		Username:     username,
-		IsAdmin:      h.Auth.IsAdmin(username),
-		paginationData: paginationData{
-			Page:       pr.page,
-			TotalPages: pr.totalPages,
-			TotalKeys:  totalKeys,
-			HasPrev:    pr.hasPrev,
-			HasNext:    pr.hasNext,
-		},
-		secretsData: secretsData{
-			SecretsFilter:  secretsFilter,
-			SecretsEnabled: h.Store.SecretsEnabled(),
-		},
-	}
+	data.OpenKey = store.NormalizeKey(r.URL.Query().Get("key"))
+	data.AuthEnabled = h.Auth.Enabled()
+	data.AuditEnabled = h.AuditEnabled
+	data.CSPNonce = cspNonce(r.Context())
+	data.IsAdmin = h.Auth.IsAdmin(username)
+	data.sidebarData = h.loadSidebar(r.Context(), username)
+	data.breakGlassData = h.loadBreakGlass(username)
+	data.freezeData = h.loadFreezes(r.Context(), username)
 
 	if err := h.tmpl.ExecuteTemplate(w, "base.html", data); err != nil {
 		log.Printf("[ERROR] failed to execute template: %v", err)
 	}
 }
 
-// handleThemeToggle toggles the theme between light and dark.
-func (h *Handler) handleThemeToggle(w http.ResponseWriter, r *http.Request) {
-	newTheme := h.getTheme(r).Toggle()
+// applyLinkState keeps the sort and secrets filter of a shared link (?sort=, ?filter=) in the cookies,
+// so the page opens as it was when the link was copied and the toggles go on from there.
+// Search, page and the open key are plain query parameters and need nothing stored. Unknown values are ignored.
+func (h *Handler) applyLinkState(w http.ResponseWriter, r *http.Request) {
+	if mode, err := enum.ParseSortMode(r.URL.Query().Get("sort")); err == nil {
+		h.setPrefCookie(w, "sort_mode", mode.String())
+	}
+	if filter, err := enum.ParseSecretsFilter(r.URL.Query().Get("filter")); err == nil {
+		h.setPrefCookie(w, "secrets_filter", filter.String())
+	}
+}
+
+// listURL returns the link to the main page with the list state of data: search, sort, secrets filter
+// and the page of the cards view. List responses send it in HX-Replace-Url to keep the address bar shareable.
+func (h *Handler) listURL(data templateData) string {
+	v := url.Values{}
+	if data.Search != "" {
+		v.Set("search", data.Search)
+	}
+	v.Set("sort", data.SortMode.String())
+	if data.SecretsEnabled {
+		v.Set("filter", data.SecretsFilter.String())
+	}
+	if data.ViewMode == enum.ViewModeCards && data.Page > 1 {
+		v.Set("page", strconv.Itoa(data.Page))
+	}
+	return h.url("/?" + v.Encode())
+}
+
+// setPrefCookie stores a display preference, like the theme or the sort mode, for a year.
+func (h *Handler) setPrefCookie(w http.ResponseWriter, name, value string) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "theme",
-		Value:    newTheme.String(),
+		Name:     name,
+		Value:    value,
 		Path:     h.cookiePath(),
 		MaxAge:   365 * 24 * 60 * 60, // 1 year
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	})
+}
+
+// handleThemeToggle toggles the theme between light and dark.
+func (h *Handler) handleThemeToggle(w http.ResponseWriter, r *http.Request) {
+	newTheme := h.getTheme(r).Toggle()
+	h.setPrefCookie(w, "theme", newTheme.String())
 
 	// trigger full page refresh
 	w.Header().Set("HX-Refresh", "true")
 	w.WriteHeader(http.StatusOK)
 }
 
-// handleViewModeToggle toggles the view mode between grid and cards.
+// handleViewModeToggle cycles through view modes: grid -> cards -> tree -> grid.
 func (h *Handler) handleViewModeToggle(w http.ResponseWriter, r *http.Request) {
-	newMode := h.getViewMode(r).Toggle()
-	http.SetCookie(w, &http.Cookie{
-		Name:     "view_mode",
-		Value:    newMode.String(),
-		Path:     h.cookiePath(),
-		MaxAge:   365 * 24 * 60 * 60, // 1 year
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	newMode := h.getViewMode(r).Next()
+	h.setPrefCookie(w, "view_mode", newMode.String())
 
 	// return updated keys table with new view mode
 	h.handleKeyList(w, r)
@@ -98,14 +100,7 @@ func (h *Handler) handleViewModeToggle(w http.ResponseWriter, r *http.Request) {
 // handleSortToggle cycles through sort modes: updated -> key -> size -> created -> updated.
 func (h *Handler) handleSortToggle(w http.ResponseWriter, r *http.Request) {
 	newMode := h.getSortMode(r).Next()
-	http.SetCookie(w, &http.Cookie{
-		Name:     "sort_mode",
-		Value:    newMode.String(),
-		Path:     h.cookiePath(),
-		MaxAge:   365 * 24 * 60 * 60, // 1 year
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	h.setPrefCookie(w, "sort_mode", newMode.String())
 
 	// return updated keys table with new sort mode
 	h.handleKeyList(w, r)
@@ -114,14 +109,7 @@ func (h *Handler) handleSortToggle(w http.ResponseWriter, r *http.Request) {
 // handleSecretsFilterToggle cycles through secrets filters: all -> secrets -> keys -> all.
 func (h *Handler) handleSecretsFilterToggle(w http.ResponseWriter, r *http.Request) {
 	newFilter := h.getSecretsFilter(r).Next()
-	http.SetCookie(w, &http.Cookie{
-		Name:     "secrets_filter",
-		Value:    newFilter.String(),
-		Path:     h.cookiePath(),
-		MaxAge:   365 * 24 * 60 * 60, // 1 year
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	h.setPrefCookie(w, "secrets_filter", newFilter.String())
 
 	// return updated keys table with new filter
 	h.handleKeyList(w, r)
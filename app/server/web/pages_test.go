@@ -16,8 +16,8 @@ import (
 
 func TestHandler_HandleIndex(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) {
-			return []store.KeyInfo{{Key: "test", Size: 100}}, nil
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "test", Size: 100}}, 1, nil
 		},
 		SecretsEnabledFunc: func() bool { return false },
 	}
@@ -32,10 +32,50 @@ func TestHandler_HandleIndex(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "test")
 }
 
+func TestHandler_HandleIndex_DeepLink(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "prod/db", Size: 100}}, 1, nil
+		},
+		SecretsEnabledFunc: func() bool { return true },
+	}
+	h := newTestHandlerWithStore(t, st)
+
+	req := httptest.NewRequest(http.MethodGet, "/?search=prod&sort=key&filter=secretsonly&key=prod/db", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "sort_mode", Value: "size"})
+	rec := httptest.NewRecorder()
+	h.handleIndex(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `value="prod"`)
+	assert.Contains(t, body, `<span id="key-count">1 key matching "prod"</span>`)
+	assert.Contains(t, body, `hx-get="/web/keys/view/prod%2Fdb" hx-trigger="load"`)
+	require.Len(t, st.ListPageCalls(), 1)
+	q := st.ListPageCalls()[0].Q
+	assert.Equal(t, "prod", q.Search)
+	assert.Equal(t, enum.SortModeKey, q.Sort, "link overrides the cookie")
+	assert.Equal(t, enum.SecretsFilterSecretsOnly, q.Filter)
+	cookies := map[string]string{}
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	assert.Equal(t, map[string]string{"sort_mode": "key", "secrets_filter": "secretsonly"}, cookies, "toggles continue from the link")
+
+	t.Run("invalid values are ignored", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?sort=bogus&filter=bogus", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Result().Cookies())
+		assert.NotContains(t, rec.Body.String(), `hx-trigger="load"`)
+	})
+}
+
 func TestHandler_HandleIndex_StoreError(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc: func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) {
-			return nil, assert.AnError
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return nil, 0, assert.AnError
 		},
 		SecretsEnabledFunc: func() bool { return false },
 	}
@@ -54,7 +94,9 @@ func TestHandler_HandleIndex_WithPagination(t *testing.T) {
 		keys[i] = store.KeyInfo{Key: "key" + string(rune('a'+i)), Size: 100}
 	}
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return keys, nil },
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			return keys[min(q.Offset, len(keys)):min(q.Offset+q.Limit, len(keys))], len(keys), nil
+		},
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	auth := &mocks.AuthProviderMock{
@@ -69,6 +111,7 @@ func TestHandler_HandleIndex_WithPagination(t *testing.T) {
 
 	t.Run("first page", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "view_mode", Value: "cards"})
 		rec := httptest.NewRecorder()
 		h.handleIndex(rec, req)
 
@@ -80,18 +123,34 @@ func TestHandler_HandleIndex_WithPagination(t *testing.T) {
 
 	t.Run("page 2 via query param", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/?page=2", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "view_mode", Value: "cards"})
 		rec := httptest.NewRecorder()
 		h.handleIndex(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		body := rec.Body.String()
 		assert.Contains(t, body, "2 / 4") // page indicator
+		assert.Contains(t, body, "keyd")
+		assert.NotContains(t, body, "keya")
+	})
+
+	t.Run("table view starts at first page with infinite scroll", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?page=2", http.NoBody)
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "keya")
+		assert.NotContains(t, body, "1 / 4", "no page controls in table view")
+		assert.Contains(t, body, `hx-get="/web/keys/rows?page=2&search="`)
+		assert.Contains(t, body, `hx-trigger="revealed"`)
 	})
 }
 
 func TestHandler_HandleThemeToggle(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	h := newTestHandlerWithStore(t, st)
@@ -131,7 +190,7 @@ func TestHandler_HandleThemeToggle(t *testing.T) {
 
 func TestHandler_HandleViewModeToggle(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	h := newTestHandlerWithStore(t, st)
@@ -143,7 +202,8 @@ func TestHandler_HandleViewModeToggle(t *testing.T) {
 	}{
 		{"no mode to cards", "", "cards"},
 		{"grid to cards", "grid", "cards"},
-		{"cards to grid", "cards", "grid"},
+		{"cards to tree", "cards", "tree"},
+		{"tree to grid", "tree", "grid"},
 	}
 
 	for _, tc := range tests {
@@ -171,7 +231,7 @@ func TestHandler_HandleViewModeToggle(t *testing.T) {
 
 func TestHandler_HandleSortToggle(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return []store.KeyInfo{}, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return []store.KeyInfo{}, 0, nil },
 		SecretsEnabledFunc: func() bool { return false },
 	}
 	h := newTestHandlerWithStore(t, st)
@@ -211,7 +271,7 @@ func TestHandler_HandleSortToggle(t *testing.T) {
 
 func TestHandler_HandleSecretsFilterToggle(t *testing.T) {
 	st := &mocks.KVStoreMock{
-		ListFunc:           func(context.Context, enum.SecretsFilter) ([]store.KeyInfo, error) { return nil, nil },
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
 		SecretsEnabledFunc: func() bool { return true },
 	}
 	h := newTestHandlerWithStore(t, st)
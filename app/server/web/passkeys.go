@@ -0,0 +1,137 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+)
+
+// maxPasskeyBody limits the JSON finishing a passkey ceremony, credentials are a few KB at most
+const maxPasskeyBody = 64 * 1024
+
+// renderPasskeyStep renders the login page asking for a passkey of the user after a valid password.
+// The session is created only when the passkey login is finished.
+func (h *Handler) renderPasskeyStep(w http.ResponseWriter, r *http.Request, username string, remember bool) {
+	opts, err := h.Auth.BeginPasskeyLogin(r.Context(), username)
+	if err != nil {
+		log.Printf("[ERROR] failed to start passkey login of %q: %v", username, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	data := templateData{
+		Theme:           h.getTheme(r),
+		BaseURL:         h.BaseURL,
+		RememberEnabled: h.Auth.RememberEnabled(),
+		PasskeyStep:     string(opts),
+		Remember:        remember,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "login.html", data); err != nil {
+		log.Printf("[ERROR] failed to execute login template: %v", err)
+	}
+}
+
+// handlePasskeyLoginBegin handles POST /web/passkeys/login/begin - starts a passwordless login with
+// any registered passkey. Not available with passkeys as the second factor.
+func (h *Handler) handlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	if !h.Auth.PasswordlessEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	opts, err := h.Auth.BeginPasskeyLogin(r.Context(), "")
+	if err != nil {
+		log.Printf("[ERROR] failed to start passkey login: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	rest.RenderJSON(w, opts)
+}
+
+// handlePasskeyLoginFinish handles POST /web/passkeys/login/finish - verifies the passkey assertion,
+// creates the session and returns the page to go to.
+func (h *Handler) handlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	if !h.Auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPasskeyBody))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	username, err := h.Auth.FinishPasskeyLogin(r.Context(), body)
+	if err != nil {
+		log.Printf("[WARN] passkey login failed: %v", err)
+		http.Error(w, "passkey login failed", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Remember bool `json:"remember"`
+	}
+	_ = json.Unmarshal(body, &req) // already parsed by the auth provider, remember is optional
+	if err := h.startSession(w, r, username, req.Remember && h.Auth.RememberEnabled()); err != nil {
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[INFO] user %q logged in with passkey", username)
+	rest.RenderJSON(w, rest.JSON{"redirect": h.url("/")})
+}
+
+// handlePasskeyRegisterBegin handles POST /web/passkeys/register/begin - starts registration of a new
+// passkey of the current user.
+func (h *Handler) handlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if username == "" || !h.Auth.PasskeysEnabled() {
+		http.Error(w, "passkeys are not available", http.StatusForbidden)
+		return
+	}
+	opts, err := h.Auth.BeginPasskeyRegistration(r.Context(), username)
+	if err != nil {
+		log.Printf("[ERROR] failed to start passkey registration of %q: %v", username, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	rest.RenderJSON(w, opts)
+}
+
+// handlePasskeyRegisterFinish handles POST /web/passkeys/register/finish - verifies and stores the new
+// passkey of the current user.
+func (h *Handler) handlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if username == "" || !h.Auth.PasskeysEnabled() {
+		http.Error(w, "passkeys are not available", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPasskeyBody))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := h.Auth.FinishPasskeyRegistration(r.Context(), username, body); err != nil {
+		log.Printf("[WARN] passkey registration of %q failed: %v", username, err)
+		http.Error(w, "passkey registration failed", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePasskeyDelete handles DELETE /web/passkeys/{id} - removes a passkey of the current user.
+func (h *Handler) handlePasskeyDelete(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	if username == "" || !h.Auth.PasskeysEnabled() {
+		http.Error(w, "passkeys are not available", http.StatusForbidden)
+		return
+	}
+	if err := h.Auth.DeletePasskey(r.Context(), username, r.PathValue("id")); err != nil {
+		log.Printf("[WARN] failed to delete passkey of %q: %v", username, err)
+		http.Error(w, "passkey not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,192 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+)
+
+func TestHandler_PasskeyLogin(t *testing.T) {
+	newAuth := func(secondFactor bool) *mocks.AuthProviderMock {
+		return &mocks.AuthProviderMock{
+			IsValidUserFunc:         func(username, password string) bool { return password == "testpass" },
+			RememberEnabledFunc:     func() bool { return true },
+			PasskeysEnabledFunc:     func() bool { return true },
+			PasswordlessEnabledFunc: func() bool { return !secondFactor },
+			PasskeyRequiredFunc:     func(context.Context, string) (bool, error) { return secondFactor, nil },
+			BeginPasskeyLoginFunc: func(_ context.Context, username string) (json.RawMessage, error) {
+				return json.RawMessage(`{"ceremony":"c1","publicKey":{"challenge":"abc","user":"` + username + `"}}`), nil
+			},
+			FinishPasskeyLoginFunc: func(_ context.Context, body []byte) (string, error) {
+				if !strings.Contains(string(body), `"ceremony":"c1"`) {
+					return "", assert.AnError
+				}
+				return "alice", nil
+			},
+			CreateSessionFunc: func(context.Context, string, bool) (string, error) { return "session-token", nil },
+			RecordLoginFunc:   func(*http.Request, string, string) error { return nil },
+			SessionLimitsFunc: func(bool) (ttl, idle time.Duration) { return time.Hour, 0 },
+		}
+	}
+
+	t.Run("login form offers passwordless login", func(t *testing.T) {
+		h := newTestHandlerWithAuth(t, newAuth(false))
+		rec := httptest.NewRecorder()
+		h.handleLoginForm(rec, httptest.NewRequest(http.MethodGet, "/login", http.NoBody))
+		assert.Contains(t, rec.Body.String(), `data-passkey-login="/web/passkeys/login"`)
+		assert.Contains(t, rec.Body.String(), "/static/"+h.assets.Path("passkey.js"))
+	})
+
+	t.Run("password login asks for passkey", func(t *testing.T) {
+		auth := newAuth(true)
+		h := newTestHandlerWithAuth(t, auth)
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.PostForm = map[string][]string{"username": {"alice"}, "password": {"testpass"}, "remember": {"on"}}
+		rec := httptest.NewRecorder()
+		h.handleLogin(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "data-passkey-step=")
+		assert.Contains(t, body, `data-remember="true"`)
+		assert.NotContains(t, body, `data-passkey-login`, "no passwordless login with second factor")
+		assert.Empty(t, auth.CreateSessionCalls(), "no session before the passkey")
+		assert.Empty(t, rec.Result().Cookies())
+		require.Len(t, auth.BeginPasskeyLoginCalls(), 1)
+		assert.Equal(t, "alice", auth.BeginPasskeyLoginCalls()[0].Username)
+	})
+
+	t.Run("passkey check error fails login", func(t *testing.T) {
+		auth := newAuth(true)
+		auth.PasskeyRequiredFunc = func(context.Context, string) (bool, error) { return false, assert.AnError }
+		h := newTestHandlerWithAuth(t, auth)
+		req := httptest.NewRequest(http.MethodPost, "/login", http.NoBody)
+		req.PostForm = map[string][]string{"username": {"alice"}, "password": {"testpass"}}
+		rec := httptest.NewRecorder()
+		h.handleLogin(rec, req)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Empty(t, auth.CreateSessionCalls())
+	})
+
+	t.Run("begin passwordless login", func(t *testing.T) {
+		auth := newAuth(false)
+		h := newTestHandlerWithAuth(t, auth)
+		rec := httptest.NewRecorder()
+		h.handlePasskeyLoginBegin(rec, httptest.NewRequest(http.MethodPost, "/web/passkeys/login/begin", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"ceremony":"c1","publicKey":{"challenge":"abc","user":""}}`, rec.Body.String())
+	})
+
+	t.Run("no passwordless login with second factor", func(t *testing.T) {
+		auth := newAuth(true)
+		h := newTestHandlerWithAuth(t, auth)
+		rec := httptest.NewRecorder()
+		h.handlePasskeyLoginBegin(rec, httptest.NewRequest(http.MethodPost, "/web/passkeys/login/begin", http.NoBody))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, auth.BeginPasskeyLoginCalls())
+	})
+
+	t.Run("finish login creates session", func(t *testing.T) {
+		auth := newAuth(false)
+		h := newTestHandlerWithAuth(t, auth)
+		req := httptest.NewRequest(http.MethodPost, "/web/passkeys/login/finish",
+			strings.NewReader(`{"ceremony":"c1","credential":{"id":"x"},"remember":true}`))
+		rec := httptest.NewRecorder()
+		h.handlePasskeyLoginFinish(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"redirect":"/"}`, rec.Body.String())
+		require.Len(t, auth.CreateSessionCalls(), 1)
+		assert.Equal(t, "alice", auth.CreateSessionCalls()[0].Username)
+		assert.True(t, auth.CreateSessionCalls()[0].Remember)
+		require.Len(t, auth.RecordLoginCalls(), 1)
+		require.Len(t, rec.Result().Cookies(), 1)
+		assert.Equal(t, "session-token", rec.Result().Cookies()[0].Value)
+		assert.Equal(t, 3600, rec.Result().Cookies()[0].MaxAge)
+	})
+
+	t.Run("failed passkey login", func(t *testing.T) {
+		auth := newAuth(false)
+		h := newTestHandlerWithAuth(t, auth)
+		req := httptest.NewRequest(http.MethodPost, "/web/passkeys/login/finish", strings.NewReader(`{"ceremony":"other"}`))
+		rec := httptest.NewRecorder()
+		h.handlePasskeyLoginFinish(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, auth.CreateSessionCalls())
+		assert.Empty(t, rec.Result().Cookies())
+	})
+}
+
+func TestHandler_PasskeyRegistration(t *testing.T) {
+	auth := &mocks.AuthProviderMock{
+		PasskeysEnabledFunc: func() bool { return true },
+		GetSessionUserFunc: func(_ context.Context, token string) (string, bool) {
+			return "alice", token == "alice-token"
+		},
+		BeginPasskeyRegistrationFunc: func(context.Context, string) (json.RawMessage, error) {
+			return json.RawMessage(`{"ceremony":"c2","publicKey":{"challenge":"xyz"}}`), nil
+		},
+		FinishPasskeyRegistrationFunc: func(_ context.Context, _ string, body []byte) error {
+			if !strings.Contains(string(body), `"ceremony":"c2"`) {
+				return assert.AnError
+			}
+			return nil
+		},
+		DeletePasskeyFunc: func(_ context.Context, _, id string) error {
+			if id != "cred-1" {
+				return assert.AnError
+			}
+			return nil
+		},
+	}
+	h := newTestHandlerWithAuth(t, auth)
+
+	t.Run("begin", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handlePasskeyRegisterBegin(rec, prefsRequest(http.MethodPost, "/web/passkeys/register/begin", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"ceremony":"c2","publicKey":{"challenge":"xyz"}}`, rec.Body.String())
+		require.Len(t, auth.BeginPasskeyRegistrationCalls(), 1)
+		assert.Equal(t, "alice", auth.BeginPasskeyRegistrationCalls()[0].Username)
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handlePasskeyRegisterFinish(rec, prefsRequest(http.MethodPost, "/web/passkeys/register/finish", `{"ceremony":"c2"}`))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = httptest.NewRecorder()
+		h.handlePasskeyRegisterFinish(rec, prefsRequest(http.MethodPost, "/web/passkeys/register/finish", `{"ceremony":"bad"}`))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req := prefsRequest(http.MethodDelete, "/web/passkeys/cred-1", "")
+		req.SetPathValue("id", "cred-1")
+		rec := httptest.NewRecorder()
+		h.handlePasskeyDelete(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+
+		req = prefsRequest(http.MethodDelete, "/web/passkeys/other", "")
+		req.SetPathValue("id", "other")
+		rec = httptest.NewRecorder()
+		h.handlePasskeyDelete(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("requires session", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handlePasskeyRegisterBegin(rec, httptest.NewRequest(http.MethodPost, "/web/passkeys/register/begin", http.NoBody))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
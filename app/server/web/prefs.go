@@ -0,0 +1,139 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/server/internal/search"
+	"github.com/umputun/stash/app/store"
+)
+
+// limits of user preferences, pins and saved searches are for frequent use, not bookmarking everything
+const (
+	maxPinnedKeys    = 100
+	maxSavedSearches = 50
+	maxSearchNameLen = 64
+)
+
+// prefsUser returns the logged-in user if preferences are available, empty string otherwise.
+func (h *Handler) prefsUser(r *http.Request) string {
+	if h.Prefs == nil {
+		return ""
+	}
+	return h.getCurrentUser(r)
+}
+
+// handlePin pins a key for the current user.
+func (h *Handler) handlePin(w http.ResponseWriter, r *http.Request) {
+	key := store.NormalizeKey(r.PathValue("key"))
+	username := h.prefsUser(r)
+	if username == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !h.Auth.CheckUserPermission(username, key, false) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	pinned, err := h.Prefs.PinnedKeys(r.Context(), username)
+	if err != nil {
+		log.Printf("[ERROR] failed to get pinned keys: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(pinned) >= maxPinnedKeys && !slices.Contains(pinned, key) {
+		h.renderSidebar(w, r, username, key, fmt.Sprintf("can't pin more than %d keys", maxPinnedKeys))
+		return
+	}
+	if err := h.Prefs.PinKey(r.Context(), username, key); err != nil {
+		log.Printf("[ERROR] failed to pin key: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.renderSidebar(w, r, username, key, "")
+}
+
+// handleUnpin removes a pinned key of the current user.
+func (h *Handler) handleUnpin(w http.ResponseWriter, r *http.Request) {
+	key := store.NormalizeKey(r.PathValue("key"))
+	username := h.prefsUser(r)
+	if username == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Prefs.UnpinKey(r.Context(), username, key); err != nil {
+		log.Printf("[ERROR] failed to unpin key: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.renderSidebar(w, r, username, key, "")
+}
+
+// handleSearchSave saves the current search query under a name for the current user.
+// A search with the same name is replaced.
+func (h *Handler) handleSearchSave(w http.ResponseWriter, r *http.Request) {
+	username := h.prefsUser(r)
+	if username == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	name, query := strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("search"))
+	if msg := h.checkSavedSearch(r.Context(), username, name, query); msg != "" {
+		h.renderSidebar(w, r, username, "", msg)
+		return
+	}
+	if err := h.Prefs.SaveSearch(r.Context(), username, name, query); err != nil {
+		log.Printf("[ERROR] failed to save search: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.renderSidebar(w, r, username, "", "")
+}
+
+// checkSavedSearch returns the reason a search can't be saved, empty if it can.
+func (h *Handler) checkSavedSearch(ctx context.Context, username, name, query string) string {
+	switch {
+	case name == "":
+		return "search name is required"
+	case utf8.RuneCountInString(name) > maxSearchNameLen:
+		return fmt.Sprintf("search name is longer than %d characters", maxSearchNameLen)
+	case query == "":
+		return "nothing to save, search is empty"
+	}
+	if _, err := search.Parse(query, time.Now()); err != nil {
+		return "invalid search: " + err.Error()
+	}
+	searches, err := h.Prefs.SavedSearches(ctx, username)
+	if err != nil {
+		log.Printf("[WARN] failed to get saved searches: %v", err)
+		return "failed to save search"
+	}
+	exists := slices.ContainsFunc(searches, func(s store.SavedSearch) bool { return s.Name == name })
+	if len(searches) >= maxSavedSearches && !exists {
+		return fmt.Sprintf("can't save more than %d searches", maxSavedSearches)
+	}
+	return ""
+}
+
+// handleSearchDelete removes a saved search of the current user.
+func (h *Handler) handleSearchDelete(w http.ResponseWriter, r *http.Request) {
+	username := h.prefsUser(r)
+	if username == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Prefs.DeleteSearch(r.Context(), username, r.PathValue("name")); err != nil {
+		log.Printf("[ERROR] failed to delete search: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.renderSidebar(w, r, username, "", "")
+}
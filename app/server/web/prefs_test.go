@@ -0,0 +1,268 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+// newPrefsMock returns a user prefs mock keeping pins and searches of a single user in memory.
+func newPrefsMock() *mocks.UserPrefsMock {
+	var pins []string
+	var searches []store.SavedSearch
+	return &mocks.UserPrefsMock{
+		PinKeyFunc: func(_ context.Context, _, key string) error {
+			if !slices.Contains(pins, key) {
+				pins = append(pins, key)
+				slices.Sort(pins)
+			}
+			return nil
+		},
+		UnpinKeyFunc: func(_ context.Context, _, key string) error {
+			pins = slices.DeleteFunc(pins, func(k string) bool { return k == key })
+			return nil
+		},
+		PinnedKeysFunc: func(context.Context, string) ([]string, error) { return slices.Clone(pins), nil },
+		SaveSearchFunc: func(_ context.Context, _, name, query string) error {
+			searches = slices.DeleteFunc(searches, func(s store.SavedSearch) bool { return s.Name == name })
+			searches = append(searches, store.SavedSearch{Name: name, Query: query})
+			return nil
+		},
+		DeleteSearchFunc: func(_ context.Context, _, name string) error {
+			searches = slices.DeleteFunc(searches, func(s store.SavedSearch) bool { return s.Name == name })
+			return nil
+		},
+		SavedSearchesFunc: func(context.Context, string) ([]store.SavedSearch, error) { return slices.Clone(searches), nil },
+	}
+}
+
+func newPrefsTestHandler(t *testing.T, prefs UserPrefs) *Handler {
+	t.Helper()
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "app/db"}}, 1, nil
+		},
+		GetWithFormatFunc:  func(context.Context, string) ([]byte, string, error) { return []byte("v"), "text", nil },
+		GetInfoFunc:        func(_ context.Context, key string) (store.KeyInfo, error) { return store.KeyInfo{Key: key}, nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc: func() bool { return true },
+		GetSessionUserFunc: func(_ context.Context, token string) (string, bool) {
+			return "alice", token == "alice-token"
+		},
+		FilterUserKeysFunc: func(_ string, keys []string) []string {
+			return slices.DeleteFunc(keys, func(k string) bool { return strings.HasPrefix(k, "private/") })
+		},
+		CheckUserPermissionFunc: func(_, key string, _ bool) bool { return !strings.HasPrefix(key, "private/") },
+		UserCanWriteFunc:        func(string) bool { return true },
+		IsAdminFunc:             func(string) bool { return false },
+	}
+	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Prefs: prefs}, Config{})
+	require.NoError(t, err)
+	return h
+}
+
+func prefsRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "alice-token"})
+	return req
+}
+
+func TestHandler_HandlePin(t *testing.T) {
+	prefs := newPrefsMock()
+	h := newPrefsTestHandler(t, prefs)
+
+	t.Run("pin from sidebar", func(t *testing.T) {
+		req := prefsRequest(http.MethodPost, "/web/pins/app/db", "")
+		req.SetPathValue("key", "app/db")
+		rec := httptest.NewRecorder()
+		h.handlePin(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `hx-swap-oob="innerHTML:#sidebar"`)
+		assert.Contains(t, body, `hx-get="/web/keys/view/app%2Fdb"`)
+		assert.NotContains(t, body, "#pin-slot", "pin button only for requests from it")
+		assert.Equal(t, "alice", prefs.PinKeyCalls()[0].Username)
+	})
+
+	t.Run("pin button gets new state", func(t *testing.T) {
+		req := prefsRequest(http.MethodPost, "/web/pins/app/cfg", "")
+		req.SetPathValue("key", "app/cfg")
+		req.Header.Set("HX-Trigger", "pin-btn")
+		rec := httptest.NewRecorder()
+		h.handlePin(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `hx-swap-oob="innerHTML:#pin-slot"`)
+		assert.Contains(t, body, `hx-delete="/web/pins/app%2Fcfg"`)
+		assert.Contains(t, body, "Unpin")
+	})
+
+	t.Run("no read permission", func(t *testing.T) {
+		req := prefsRequest(http.MethodPost, "/web/pins/private/x", "")
+		req.SetPathValue("key", "private/x")
+		rec := httptest.NewRecorder()
+		h.handlePin(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("not logged in", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/web/pins/app/db", http.NoBody)
+		req.SetPathValue("key", "app/db")
+		rec := httptest.NewRecorder()
+		h.handlePin(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("prefs disabled", func(t *testing.T) {
+		req := prefsRequest(http.MethodPost, "/web/pins/app/db", "")
+		req.SetPathValue("key", "app/db")
+		rec := httptest.NewRecorder()
+		newTestHandler(t).handlePin(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("too many pins", func(t *testing.T) {
+		for i := range maxPinnedKeys {
+			require.NoError(t, prefs.PinKey(t.Context(), "alice", fmt.Sprintf("bulk/%03d", i)))
+		}
+		calls := len(prefs.PinKeyCalls())
+		req := prefsRequest(http.MethodPost, "/web/pins/app/new", "")
+		req.SetPathValue("key", "app/new")
+		rec := httptest.NewRecorder()
+		h.handlePin(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "can&#39;t pin more than 100 keys")
+		assert.Len(t, prefs.PinKeyCalls(), calls)
+	})
+}
+
+func TestHandler_HandleUnpin(t *testing.T) {
+	prefs := newPrefsMock()
+	require.NoError(t, prefs.PinKey(t.Context(), "alice", "app/db"))
+	require.NoError(t, prefs.PinKey(t.Context(), "alice", "private/x"))
+	h := newPrefsTestHandler(t, prefs)
+
+	req := prefsRequest(http.MethodDelete, "/web/pins/app/db", "")
+	req.SetPathValue("key", "app/db")
+	req.Header.Set("HX-Trigger", "pin-btn")
+	rec := httptest.NewRecorder()
+	h.handleUnpin(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `hx-post="/web/pins/app%2Fdb"`, "pin button back to pin")
+	assert.Contains(t, body, "Pin keys from the key view", "no pins left the user can read")
+	assert.NotContains(t, body, "private/x")
+}
+
+func TestHandler_HandleSearchSave(t *testing.T) {
+	prefs := newPrefsMock()
+	h := newPrefsTestHandler(t, prefs)
+
+	save := func(body string) string {
+		rec := httptest.NewRecorder()
+		h.handleSearchSave(rec, prefsRequest(http.MethodPost, "/web/searches", body))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	body := save("name=prod+json&search=prefix%3Aprod%2F+format%3Ajson")
+	assert.Contains(t, body, `data-search="prefix:prod/ format:json"`)
+	assert.Contains(t, body, ">prod json<")
+	assert.Contains(t, body, `hx-delete="/web/searches/prod%20json"`)
+
+	tests := []struct{ name, body, err string }{
+		{"no name", "name=+&search=db", "search name is required"},
+		{"long name", "name=" + strings.Repeat("x", maxSearchNameLen+1) + "&search=db", "search name is longer than 64 characters"},
+		{"empty search", "name=empty&search=", "nothing to save, search is empty"},
+		{"invalid search", "name=bad&search=size%3Ahuge", "invalid search: invalid size"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := len(prefs.SaveSearchCalls())
+			assert.Contains(t, save(tc.body), tc.err)
+			assert.Len(t, prefs.SaveSearchCalls(), calls)
+		})
+	}
+
+	t.Run("too many searches", func(t *testing.T) {
+		for i := range maxSavedSearches - 1 {
+			require.NoError(t, prefs.SaveSearch(t.Context(), "alice", fmt.Sprintf("s%02d", i), "db"))
+		}
+		assert.Contains(t, save("name=one+more&search=db"), "can&#39;t save more than 50 searches")
+		assert.NotContains(t, save("name=prod+json&search=prefix%3Aprod%2F"), "can&#39;t save", "existing name is replaced")
+	})
+}
+
+func TestHandler_HandleSearchDelete(t *testing.T) {
+	prefs := newPrefsMock()
+	require.NoError(t, prefs.SaveSearch(t.Context(), "alice", "a/b", "prefix:a/b"))
+	h := newPrefsTestHandler(t, prefs)
+
+	req := prefsRequest(http.MethodDelete, "/web/searches/a%2Fb", "")
+	req.SetPathValue("name", "a/b")
+	rec := httptest.NewRecorder()
+	h.handleSearchDelete(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, prefs.DeleteSearchCalls(), 1)
+	assert.Equal(t, "a/b", prefs.DeleteSearchCalls()[0].Name)
+	assert.NotContains(t, rec.Body.String(), "data-search")
+}
+
+func TestHandler_PrefsPages(t *testing.T) {
+	prefs := newPrefsMock()
+	require.NoError(t, prefs.PinKey(t.Context(), "alice", "app/db"))
+	require.NoError(t, prefs.SaveSearch(t.Context(), "alice", "prod", "prefix:prod/"))
+	h := newPrefsTestHandler(t, prefs)
+
+	t.Run("index shows sidebar", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `<aside id="sidebar"`)
+		assert.Contains(t, body, `title="app/db">app/db</button>`)
+		assert.Contains(t, body, `data-search="prefix:prod/"`)
+	})
+
+	t.Run("no sidebar without login", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		assert.NotContains(t, rec.Body.String(), `id="sidebar"`)
+	})
+
+	t.Run("view shows pin state", func(t *testing.T) {
+		req := prefsRequest(http.MethodGet, "/web/keys/view/app/db", "")
+		req.SetPathValue("key", "app/db")
+		rec := httptest.NewRecorder()
+		h.handleKeyView(rec, req)
+		assert.Contains(t, rec.Body.String(), `<span id="pin-slot">`)
+		assert.Contains(t, rec.Body.String(), "Unpin")
+
+		req = prefsRequest(http.MethodGet, "/web/keys/view/app/other", "")
+		req.SetPathValue("key", "app/other")
+		rec = httptest.NewRecorder()
+		h.handleKeyView(rec, req)
+		assert.Contains(t, rec.Body.String(), `hx-post="/web/pins/app%2Fother"`)
+	})
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// profileSession is a session shown on the profile page.
+type profileSession struct {
+	store.Session
+	Current bool // session of the request
+}
+
+// profileTemplateData holds data passed to the profile template.
+type profileTemplateData struct {
+	Username        string
+	Sessions        []profileSession
+	PasskeysEnabled bool
+	Passkeys        []store.Passkey
+
+	Theme       enum.Theme
+	AuthEnabled bool
+	BaseURL     string
+	CSPNonce    string // nonce for inline scripts
+	Error       string
+}
+
+// handleProfile handles GET /profile - renders the active sessions of the user with the device, IP
+// and location each one was logged in from, and the passkeys of the user if passkeys are enabled.
+func (h *Handler) handleProfile(w http.ResponseWriter, r *http.Request) {
+	token, sess, ok := h.currentSession(r)
+	if !ok {
+		http.Redirect(w, r, h.BaseURL+"/login?return="+url.QueryEscape(h.BaseURL+"/profile"), http.StatusFound)
+		return
+	}
+
+	data := profileTemplateData{
+		Username:    sess.Username,
+		Theme:       h.getTheme(r),
+		AuthEnabled: h.Auth.Enabled(),
+		BaseURL:     h.BaseURL,
+		CSPNonce:    cspNonce(r.Context()),
+	}
+	sessions, err := h.Auth.UserSessions(r.Context(), sess.Username)
+	if err != nil {
+		log.Printf("[WARN] profile: failed to get sessions of %q: %v", sess.Username, err)
+		data.Error = "Failed to load sessions"
+	}
+	for _, s := range sessions {
+		data.Sessions = append(data.Sessions, profileSession{Session: s, Current: s.Token == token})
+	}
+	if h.Auth.PasskeysEnabled() {
+		data.PasskeysEnabled = true
+		if data.Passkeys, err = h.Auth.Passkeys(r.Context(), sess.Username); err != nil {
+			log.Printf("[WARN] profile: failed to get passkeys of %q: %v", sess.Username, err)
+			data.Error = "Failed to load passkeys"
+		}
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "profile.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
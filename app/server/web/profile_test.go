@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandleProfile(t *testing.T) {
+	created := time.Now().Add(-time.Hour).UTC()
+	laptop := store.Session{Token: "alice-token", Username: "alice", CreatedAt: created, ExpiresAt: created.Add(2 * time.Hour),
+		SessionDevice: store.SessionDevice{Device: "Firefox on Linux", UserAgent: "Mozilla/5.0 Firefox/140.0", IP: "203.0.113.7",
+			Location: "DE"}}
+	phone := store.Session{Token: "other-token", Username: "alice", CreatedAt: created.Add(-24 * time.Hour),
+		ExpiresAt: created.Add(24 * time.Hour), Remember: true, SessionDevice: store.SessionDevice{Device: "Safari on iOS", IP: "198.51.100.1"}}
+	sessionsErr, passkeysEnabled := error(nil), false
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc: func() bool { return true },
+		SessionInfoFunc: func(_ context.Context, token string) (store.Session, bool) {
+			return laptop, token == "alice-token"
+		},
+		UserSessionsFunc: func(context.Context, string) ([]store.Session, error) {
+			return []store.Session{laptop, phone}, sessionsErr
+		},
+		PasskeysEnabledFunc: func() bool { return passkeysEnabled },
+		PasskeysFunc: func(context.Context, string) ([]store.Passkey, error) {
+			return []store.Passkey{{ID: "cred-1", Username: "alice", Name: "yubikey", CreatedAt: created, LastUsedAt: created}}, nil
+		},
+	}
+	h := newTestHandlerWithAuth(t, auth)
+
+	t.Run("lists sessions", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleProfile(rec, prefsRequest(http.MethodGet, "/profile", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "Firefox on Linux")
+		assert.Contains(t, body, `title="Mozilla/5.0 Firefox/140.0"`)
+		assert.Contains(t, body, "203.0.113.7")
+		assert.Contains(t, body, "Safari on iOS")
+		assert.Contains(t, body, "(remembered)")
+		assert.Equal(t, 1, strings.Count(body, `class="session-current"`), "only the request session is current")
+		require.Len(t, auth.UserSessionsCalls(), 1)
+		assert.Equal(t, "alice", auth.UserSessionsCalls()[0].Username)
+		assert.NotContains(t, body, "Passkeys")
+		assert.Empty(t, auth.PasskeysCalls())
+	})
+
+	t.Run("lists passkeys", func(t *testing.T) {
+		passkeysEnabled = true
+		defer func() { passkeysEnabled = false }()
+		rec := httptest.NewRecorder()
+		h.handleProfile(rec, prefsRequest(http.MethodGet, "/profile", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "yubikey")
+		assert.Contains(t, body, `hx-delete="/web/passkeys/cred-1"`)
+		assert.Contains(t, body, `data-passkey-register="/web/passkeys/register"`)
+		assert.Contains(t, body, "/static/"+h.assets.Path("passkey.js"))
+	})
+
+	t.Run("sessions error", func(t *testing.T) {
+		sessionsErr = assert.AnError
+		defer func() { sessionsErr = nil }()
+		rec := httptest.NewRecorder()
+		h.handleProfile(rec, prefsRequest(http.MethodGet, "/profile", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Failed to load sessions")
+	})
+
+	t.Run("redirects without session", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleProfile(rec, httptest.NewRequest(http.MethodGet, "/profile", http.NoBody))
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "/login?return=%2Fprofile", rec.Header().Get("Location"))
+	})
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// reportValueLimit is the longest value printed in the key report, longer values are cut
+const reportValueLimit = 2048
+
+// reportEntry is a key of the report.
+type reportEntry struct {
+	store.KeyInfo
+	Value    string            // printed value, empty if values are not included or the value is withheld
+	Withheld string            // why the value is not printed, e.g. "secret"
+	Change   *store.AuditEntry // last successful write from the audit log, nil if not recorded
+}
+
+// reportTemplateData holds data passed to the key report template.
+type reportTemplateData struct {
+	Prefix      string
+	Values      bool // values of regular keys are printed
+	Entries     []reportEntry
+	TotalSize   int
+	Secrets     int
+	Changes     bool // last change column shown, audit log is available
+	GeneratedAt time.Time
+	GeneratedBy string
+
+	Theme    enum.Theme
+	BaseURL  string
+	CSPNonce string
+}
+
+// handleKeyReport renders a printable report of the keys under ?prefix= the user can read, with metadata,
+// owners and the last change of each key, for change reviews and audits. Browsers save it as PDF with print.
+// Values are printed only with ?values=1 and never for secrets, zk-encrypted and binary keys and keys
+// requiring a reason.
+func (h *Handler) handleKeyReport(w http.ResponseWriter, r *http.Request) {
+	username := h.getCurrentUser(r)
+	prefix := strings.TrimLeft(r.URL.Query().Get("prefix"), "/")
+	q := h.userListQuery(username, store.ListQuery{Prefix: prefix, Sort: enum.SortModeKey})
+	keys, _, err := h.Store.ListPage(r.Context(), q)
+	if err != nil {
+		log.Printf("[ERROR] failed to list keys for report: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	data := reportTemplateData{
+		Prefix:      prefix,
+		Values:      r.URL.Query().Get("values") == "1",
+		Changes:     h.Changes != nil,
+		GeneratedAt: time.Now(),
+		GeneratedBy: username,
+		Theme:       h.getTheme(r),
+		BaseURL:     h.BaseURL,
+		CSPNonce:    cspNonce(r.Context()),
+	}
+	changes := h.lastChanges(r.Context(), prefix)
+	for _, k := range keys {
+		e := reportEntry{KeyInfo: k}
+		if c, ok := changes[k.Key]; ok {
+			e.Change = &c
+		}
+		if data.Values {
+			e.Value, e.Withheld = h.reportValue(r, k)
+		}
+		data.TotalSize += k.Size
+		if k.Secret {
+			data.Secrets++
+		}
+		data.Entries = append(data.Entries, e)
+	}
+	log.Printf("[INFO] key report of %q with %d keys by %s, values: %v", prefix, len(keys), username, data.Values)
+
+	if err := h.tmpl.ExecuteTemplate(w, "report.html", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
+
+// lastChanges returns the last successful create or update of the keys under the prefix, by key.
+// Missing audit log or a failed query leave the column empty rather than failing the report.
+func (h *Handler) lastChanges(ctx context.Context, prefix string) map[string]store.AuditEntry {
+	if h.Changes == nil {
+		return nil
+	}
+	entries, err := h.Changes.LastAuditEntries(ctx, store.AuditQuery{Key: prefix + "*",
+		Actions: []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate}, Result: enum.AuditResultSuccess})
+	if err != nil {
+		log.Printf("[WARN] failed to load last changes of %q: %v", prefix, err)
+		return nil
+	}
+	res := make(map[string]store.AuditEntry, len(entries))
+	for _, e := range entries {
+		res[e.Key] = e
+	}
+	return res
+}
+
+// reportValue returns the value of the key to print, or why it's withheld. Printed values are audited
+// as reads, the same as values shown in the view modal.
+func (h *Handler) reportValue(r *http.Request, k store.KeyInfo) (value, withheld string) {
+	switch {
+	case k.Secret:
+		return "", "secret"
+	case k.ZKEncrypted:
+		return "", "zk-encrypted"
+	case h.Reasons != nil && h.Reasons.RequiresReason(k.Key):
+		return "", "requires reason"
+	}
+	val, _, err := h.Store.GetWithFormat(r.Context(), k.Key)
+	if err != nil {
+		log.Printf("[WARN] failed to get %s for report: %v", k.Key, err)
+		return "", "not available"
+	}
+	size := len(val)
+	h.logAudit(r, k.Key, enum.AuditActionRead, enum.AuditResultSuccess, &size)
+	if !utf8.Valid(val) {
+		return "", "binary"
+	}
+	if len(val) > reportValueLimit {
+		return strings.ToValidUTF8(string(val[:reportValueLimit]), "") + "…", ""
+	}
+	return string(val), ""
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_HandleKeyReport(t *testing.T) {
+	values := map[string][]byte{"prod/db": []byte("postgres://db"), "prod/blob": {0xff, 0xfe}}
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{
+				{Key: "prod/blob", Size: 2},
+				{Key: "prod/db", Size: 13, Owner: "user:alice", Description: "main database", Labels: map[string]string{"env": "prod"}},
+				{Key: "prod/secrets/token", Size: 40, Secret: true},
+			}, 3, nil
+		},
+		GetWithFormatFunc: func(_ context.Context, key string) ([]byte, string, error) { return values[key], "text", nil },
+	}
+	changes := &mocks.ChangeLogMock{
+		LastAuditEntriesFunc: func(context.Context, store.AuditQuery) ([]store.AuditEntry, error) {
+			return []store.AuditEntry{{Key: "prod/db", Action: enum.AuditActionUpdate, Actor: "bob",
+				Timestamp: time.Date(2025, 3, 4, 10, 30, 0, 0, time.Local)}}, nil
+		},
+	}
+	audit := &mocks.AuditLoggerMock{LogAuditFunc: func(context.Context, store.AuditEntry) error { return nil }}
+	auth := &mocks.AuthProviderMock{
+		EnabledFunc:        func() bool { return false },
+		GetSessionUserFunc: func(context.Context, string) (string, bool) { return "", false },
+	}
+	h, err := New(Deps{Store: st, Auth: auth, Validator: defaultValidatorMock(), Changes: changes, Audit: audit}, Config{})
+	require.NoError(t, err)
+
+	t.Run("metadata without values", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleKeyReport(rec, httptest.NewRequest(http.MethodGet, "/web/report?prefix=/prod/", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "Key Report: prod/")
+		assert.Contains(t, body, "3 keys, 55 B, 1 secret.")
+		assert.Contains(t, body, "user:alice")
+		assert.Contains(t, body, "main database")
+		assert.Contains(t, body, "env=prod")
+		assert.Contains(t, body, "update by bob, 2025-03-04 10:30")
+		assert.Contains(t, body, "Values are not included.")
+		assert.NotContains(t, body, "postgres://db")
+		assert.Empty(t, st.GetWithFormatCalls())
+
+		assert.Equal(t, "prod/", st.ListPageCalls()[0].Q.Prefix)
+		q := changes.LastAuditEntriesCalls()[0].Q
+		assert.Equal(t, "prod/*", q.Key)
+		assert.Equal(t, []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate}, q.Actions)
+	})
+
+	t.Run("with values", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleKeyReport(rec, httptest.NewRequest(http.MethodGet, "/web/report?prefix=prod/&values=1", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `<pre class="report-value">postgres://db</pre>`)
+		assert.Contains(t, body, "value withheld: binary")
+		assert.Contains(t, body, "value withheld: secret")
+		require.Len(t, st.GetWithFormatCalls(), 2, "secret value is never read")
+		require.Len(t, audit.LogAuditCalls(), 2, "printed values are audited as reads")
+		assert.Equal(t, enum.AuditActionRead, audit.LogAuditCalls()[0].Entry.Action)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		failing := &mocks.KVStoreMock{
+			ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, assert.AnError },
+		}
+		hf := newTestHandlerWithStore(t, failing)
+		rec := httptest.NewRecorder()
+		hf.handleKeyReport(rec, httptest.NewRequest(http.MethodGet, "/web/report", http.NoBody))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_SecurityHeaders(t *testing.T) {
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) {
+			return []store.KeyInfo{{Key: "test", Size: 100}}, 1, nil
+		},
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	h := newTestHandlerWithStore(t, st)
+	srv := h.SecurityHeaders(http.HandlerFunc(h.handleIndex))
+
+	nonceRe := regexp.MustCompile(`script-src 'self' 'nonce-([A-Za-z0-9_-]+)'`)
+	nonces := make([]string, 0, 2)
+	for range 2 {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		csp := rec.Header().Get("Content-Security-Policy")
+		m := nonceRe.FindStringSubmatch(csp)
+		require.Len(t, m, 2, csp)
+		assert.Contains(t, csp, "frame-ancestors 'none'")
+		assert.Contains(t, csp, "object-src 'none'")
+		assert.NotContains(t, csp, "unsafe-eval")
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "same-origin", rec.Header().Get("Referrer-Policy"))
+		assert.Contains(t, rec.Body.String(), `<script nonce="`+m[1]+`">`, "inline script carries the request nonce")
+		assert.NotContains(t, rec.Body.String(), "onclick=")
+		nonces = append(nonces, m[1])
+	}
+	assert.NotEqual(t, nonces[0], nonces[1], "nonce is per request")
+
+	t.Run("frame ancestors", func(t *testing.T) {
+		h.FrameAncestors = []string{"'self'", "https://portal.example.com"}
+		defer func() { h.FrameAncestors = nil }()
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors 'self' https://portal.example.com")
+		assert.Empty(t, rec.Header().Get("X-Frame-Options"), "can't express an allow list, csp is used instead")
+	})
+}
+
+func TestValidateFrameAncestors(t *testing.T) {
+	require.NoError(t, validateFrameAncestors(nil))
+	require.NoError(t, validateFrameAncestors([]string{"'self'", "https://portal.example.com", "https://*.example.com"}))
+
+	for _, bad := range []string{"", "https://a.com; script-src *", "https://a.com https://b.com", "'unsafe-inline'"} {
+		assert.Error(t, validateFrameAncestors([]string{bad}), bad)
+	}
+
+	_, err := New(Deps{}, Config{FrameAncestors: []string{"'none'; script-src *"}})
+	require.ErrorContains(t, err, "invalid frame ancestor")
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/rest"
+
+	"github.com/umputun/stash/app/server/internal/cookie"
+	"github.com/umputun/stash/app/store"
+)
+
+// sessionWarnBefore is how long before the session expiration the UI warns about it.
+// Short idle timeouts get a quarter of the timeout instead.
+const sessionWarnBefore = 5 * time.Minute
+
+// sessionStatus is the session expiration reported to the UI.
+type sessionStatus struct {
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxExpiresAt time.Time `json:"max_expires_at"`
+	WarnBefore   int       `json:"warn_before"` // seconds
+	Renewable    bool      `json:"renewable"`
+}
+
+// handleSessionStatus reports the expiration of the current session. Registered without the session
+// middleware, checking the status is not an activity and doesn't renew the session.
+func (h *Handler) handleSessionStatus(w http.ResponseWriter, r *http.Request) {
+	_, sess, ok := h.currentSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rest.RenderJSON(w, h.sessionStatus(sess))
+}
+
+// handleSessionRenew renews the current session, used by the "stay signed in" button of the expiration warning.
+func (h *Handler) handleSessionRenew(w http.ResponseWriter, r *http.Request) {
+	token, _, ok := h.currentSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sess, err := h.Auth.RenewSession(r.Context(), token)
+	if err != nil {
+		log.Printf("[WARN] failed to renew session: %v", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rest.RenderJSON(w, h.sessionStatus(sess))
+}
+
+// currentSession returns the token and the session of the first valid session cookie, false if there is none.
+func (h *Handler) currentSession(r *http.Request) (string, store.Session, bool) {
+	for _, cookieName := range cookie.SessionCookieNames {
+		if c, err := r.Cookie(cookieName); err == nil {
+			if sess, ok := h.Auth.SessionInfo(r.Context(), c.Value); ok {
+				return c.Value, sess, true
+			}
+		}
+	}
+	return "", store.Session{}, false
+}
+
+// sessionStatus makes the status of the session. Sessions can be renewed while they expire before
+// their max lifetime, sessions with a fixed TTL can't be renewed at all.
+func (h *Handler) sessionStatus(sess store.Session) sessionStatus {
+	ttl, idle := h.Auth.SessionLimits(sess.Remember)
+	warnBefore := sessionWarnBefore
+	if idle > 0 && idle/4 < warnBefore {
+		warnBefore = idle / 4
+	}
+	maxExpiresAt := sess.CreatedAt.Add(ttl)
+	return sessionStatus{
+		ExpiresAt:    sess.ExpiresAt,
+		MaxExpiresAt: maxExpiresAt,
+		WarnBefore:   int(warnBefore.Seconds()),
+		Renewable:    idle > 0 && sess.ExpiresAt.Before(maxExpiresAt),
+	}
+}
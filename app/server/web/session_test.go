@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_Session(t *testing.T) {
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	sess := store.Session{Username: "alice", CreatedAt: created, ExpiresAt: created.Add(2 * time.Hour)}
+	auth := &mocks.AuthProviderMock{
+		SessionInfoFunc: func(_ context.Context, token string) (store.Session, bool) {
+			return sess, token == "alice-token"
+		},
+		RenewSessionFunc: func(_ context.Context, token string) (store.Session, error) {
+			if token != "alice-token" {
+				return store.Session{}, errors.New("not found")
+			}
+			renewed := sess
+			renewed.ExpiresAt = created.Add(3 * time.Hour)
+			return renewed, nil
+		},
+		SessionLimitsFunc: func(bool) (time.Duration, time.Duration) { return 24 * time.Hour, 2 * time.Hour },
+	}
+	h := &Handler{Deps: Deps{Auth: auth}}
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) sessionStatus {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res sessionStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	t.Run("status", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleSessionStatus(rec, prefsRequest(http.MethodGet, "/web/session", ""))
+		res := decode(t, rec)
+		assert.Equal(t, sess.ExpiresAt.Unix(), res.ExpiresAt.Unix())
+		assert.Equal(t, created.Add(24*time.Hour).Unix(), res.MaxExpiresAt.Unix())
+		assert.Equal(t, 300, res.WarnBefore)
+		assert.True(t, res.Renewable)
+		assert.Empty(t, auth.RenewSessionCalls(), "status doesn't renew")
+	})
+
+	t.Run("renew", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleSessionRenew(rec, prefsRequest(http.MethodPost, "/web/session/renew", ""))
+		res := decode(t, rec)
+		assert.Equal(t, created.Add(3*time.Hour).Unix(), res.ExpiresAt.Unix())
+		require.Len(t, auth.RenewSessionCalls(), 1)
+		assert.Equal(t, "alice-token", auth.RenewSessionCalls()[0].Token)
+	})
+
+	t.Run("no session", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleSessionStatus(rec, httptest.NewRequest(http.MethodGet, "/web/session", http.NoBody))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = httptest.NewRecorder()
+		h.handleSessionRenew(rec, httptest.NewRequest(http.MethodPost, "/web/session/renew", http.NoBody))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestHandler_SessionStatus(t *testing.T) {
+	created := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		idle       time.Duration
+		expires    time.Time
+		warnBefore int
+		renewable  bool
+	}{
+		{name: "sliding", idle: time.Hour, expires: created.Add(time.Hour), warnBefore: 300, renewable: true},
+		{name: "short idle timeout", idle: 8 * time.Minute, expires: created.Add(8 * time.Minute), warnBefore: 120, renewable: true},
+		{name: "reached max lifetime", idle: time.Hour, expires: created.Add(24 * time.Hour), warnBefore: 300},
+		{name: "fixed ttl", expires: created.Add(24 * time.Hour), warnBefore: 300},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{Deps: Deps{Auth: &mocks.AuthProviderMock{
+				SessionLimitsFunc: func(bool) (time.Duration, time.Duration) { return 24 * time.Hour, tc.idle },
+			}}}
+			res := h.sessionStatus(store.Session{CreatedAt: created, ExpiresAt: tc.expires})
+			assert.Equal(t, tc.warnBefore, res.WarnBefore)
+			assert.Equal(t, tc.renewable, res.Renewable)
+			assert.Equal(t, created.Add(24*time.Hour), res.MaxExpiresAt)
+		})
+	}
+}
+
+func TestHandler_SessionWarningPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newPrefsTestHandler(t, nil).handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<body data-session-url="/web/session">`)
+	assert.Contains(t, rec.Body.String(), `id="session-modal"`)
+
+	rec = httptest.NewRecorder()
+	st := &mocks.KVStoreMock{
+		ListPageFunc:       func(context.Context, store.ListQuery) ([]store.KeyInfo, int, error) { return nil, 0, nil },
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	newTestHandlerWithStore(t, st).handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "data-session-url", "no session without auth")
+	assert.NotContains(t, rec.Body.String(), "session-modal")
+}
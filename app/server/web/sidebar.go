@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// recentKeysLimit is the number of keys in each of the recent sections of the sidebar.
+const recentKeysLimit = 10
+
+// loadSidebar loads the sidebar of the user: pinned keys and saved searches, and recently viewed and edited
+// keys. Failures are logged and leave the section empty, they should not break the page.
+func (h *Handler) loadSidebar(ctx context.Context, username string) sidebarData {
+	var res sidebarData
+	if username == "" {
+		return res
+	}
+	if h.Prefs != nil {
+		res.PrefsEnabled = true
+		keys, err := h.Prefs.PinnedKeys(ctx, username)
+		if err != nil {
+			log.Printf("[WARN] failed to load pinned keys of %q: %v", username, err)
+		}
+		// permissions may have changed since the key was pinned
+		res.PinnedKeys = h.Auth.FilterUserKeys(username, keys)
+		if res.SavedSearches, err = h.Prefs.SavedSearches(ctx, username); err != nil {
+			log.Printf("[WARN] failed to load saved searches of %q: %v", username, err)
+		}
+	}
+	if h.Recent != nil {
+		res.RecentEnabled = true
+		res.RecentViewed = h.recentKeys(ctx, username, enum.AuditActionRead)
+		res.RecentEdited = h.recentKeys(ctx, username, enum.AuditActionCreate, enum.AuditActionUpdate)
+	}
+	return res
+}
+
+// recentKeys returns keys the user recently used with one of the actions, by successful audit entries,
+// limited to keys the user can still read.
+func (h *Handler) recentKeys(ctx context.Context, username string, actions ...enum.AuditAction) []store.AuditRecentKey {
+	recent, err := h.Recent.RecentAuditKeys(ctx, store.AuditQuery{Actor: username, ActorType: enum.ActorTypeUser,
+		Actions: actions, Result: enum.AuditResultSuccess, Limit: recentKeysLimit})
+	if err != nil {
+		log.Printf("[WARN] failed to load recent keys of %q: %v", username, err)
+		return nil
+	}
+	names := make([]string, len(recent))
+	for i, k := range recent {
+		names[i] = k.Key
+	}
+	allowed := h.Auth.FilterUserKeys(username, names)
+	return slices.DeleteFunc(recent, func(k store.AuditRecentKey) bool { return !slices.Contains(allowed, k.Key) })
+}
+
+// renderSidebar renders the updated sidebar, with the error if the change was rejected. Requests from the pin
+// button of the key view also get the button back, showing the new pin state of the key.
+func (h *Handler) renderSidebar(w http.ResponseWriter, r *http.Request, username, key, errMsg string) {
+	data := templateData{BaseURL: h.BaseURL, sidebarData: h.loadSidebar(r.Context(), username)}
+	data.PrefsError = errMsg
+	if key != "" && r.Header.Get("HX-Trigger") == "pin-btn" {
+		data.Key, data.Pinned = key, slices.Contains(data.PinnedKeys, key)
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "sidebar-update", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
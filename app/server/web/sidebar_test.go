@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestHandler_LoadSidebar(t *testing.T) {
+	ts := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	recent := &mocks.RecentActivityMock{
+		RecentAuditKeysFunc: func(_ context.Context, q store.AuditQuery) ([]store.AuditRecentKey, error) {
+			assert.Equal(t, "alice", q.Actor)
+			assert.Equal(t, enum.ActorTypeUser, q.ActorType)
+			assert.Equal(t, enum.AuditResultSuccess, q.Result)
+			assert.Equal(t, recentKeysLimit, q.Limit)
+			if slices.Contains(q.Actions, enum.AuditActionRead) {
+				return []store.AuditRecentKey{{Key: "app/viewed", Last: ts}, {Key: "private/x", Last: ts}}, nil
+			}
+			assert.Equal(t, []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate}, q.Actions)
+			return []store.AuditRecentKey{{Key: "app/edited", Last: ts}}, nil
+		},
+	}
+	h := newPrefsTestHandler(t, nil)
+	h.Recent = recent
+
+	t.Run("recent keys the user can read", func(t *testing.T) {
+		res := h.loadSidebar(t.Context(), "alice")
+		assert.True(t, res.RecentEnabled)
+		assert.False(t, res.PrefsEnabled)
+		assert.Equal(t, []store.AuditRecentKey{{Key: "app/viewed", Last: ts}}, res.RecentViewed)
+		assert.Equal(t, []store.AuditRecentKey{{Key: "app/edited", Last: ts}}, res.RecentEdited)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		calls := len(recent.RecentAuditKeysCalls())
+		assert.Equal(t, sidebarData{}, h.loadSidebar(t.Context(), ""))
+		assert.Len(t, recent.RecentAuditKeysCalls(), calls)
+	})
+
+	t.Run("index shows recent sections", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `<aside id="sidebar"`)
+		assert.Contains(t, body, "Recently viewed")
+		assert.Contains(t, body, `title="app/viewed, 2025-01-15 10:00">app/viewed</button>`)
+		assert.Contains(t, body, "Recently edited by you")
+		assert.Contains(t, body, `hx-get="/web/keys/view/app%2Fedited"`)
+		assert.NotContains(t, body, "private/x")
+		assert.NotContains(t, body, "Saved searches", "prefs not enabled")
+	})
+
+	t.Run("audit failure leaves sections empty", func(t *testing.T) {
+		h.Recent = &mocks.RecentActivityMock{
+			RecentAuditKeysFunc: func(context.Context, store.AuditQuery) ([]store.AuditRecentKey, error) {
+				return nil, errors.New("db error")
+			},
+		}
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, prefsRequest(http.MethodGet, "/", ""))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "No keys viewed yet")
+		assert.Contains(t, rec.Body.String(), "No keys edited yet")
+	})
+}
@@ -13,10 +13,29 @@ function hideModal(id) {
     }
 }
 
+// hides all modals except the ones marked with data-keep-modal, like the session expiration warning
 function hideAllModals() {
-    document.querySelectorAll('.modal-backdrop').forEach(function(modal) {
+    document.querySelectorAll('.modal-backdrop:not([data-keep-modal])').forEach(function(modal) {
         modal.classList.remove('active');
     });
+    setOpenKey(null);
+}
+
+// The key open in the modal is kept in the address bar as ?key=, next to the list state the server
+// puts there, so a copied link opens the same list with the same key. Only the main page has the list.
+function setOpenKey(key) {
+    if (window.location.pathname !== (window.BASE_URL || '') + '/') {
+        return;
+    }
+    const url = new URL(window.location.href);
+    if (key) {
+        url.searchParams.set('key', key);
+    } else if (url.searchParams.has('key')) {
+        url.searchParams.delete('key');
+    } else {
+        return;
+    }
+    history.replaceState(history.state, '', url);
 }
 
 // Close modal on backdrop click (only for view modals, not edit/create forms)
@@ -48,21 +67,209 @@ document.addEventListener('keydown', function(e) {
 // Custom confirm dialog for delete
 let confirmCallback = null;
 
-function showConfirmDelete(key, deleteUrl) {
+function showConfirmDelete(key, deleteUrl, question) {
     const modal = document.getElementById('confirm-modal');
     const keySpan = document.getElementById('confirm-key');
     const confirmBtn = document.getElementById('confirm-delete-btn');
+    const questionText = document.getElementById('confirm-question');
 
     if (modal && keySpan && confirmBtn) {
         keySpan.textContent = key;
+        if (questionText) {
+            questionText.textContent = question || 'Are you sure you want to delete this key?';
+        }
         confirmBtn.setAttribute('hx-delete', deleteUrl);
         htmx.process(confirmBtn);
         showModal('confirm-modal');
     }
 }
 
+// Declarative handlers for data attributes, inline on* handlers are blocked by the content security policy
+document.addEventListener('click', function(e) {
+    const closeBtn = e.target.closest('[data-hide-modal]');
+    if (closeBtn) {
+        hideModal(closeBtn.dataset.hideModal);
+        return;
+    }
+    const deleteBtn = e.target.closest('[data-confirm-delete]');
+    if (deleteBtn) {
+        showConfirmDelete(deleteBtn.dataset.confirmDelete, deleteBtn.dataset.deleteUrl, deleteBtn.dataset.confirmQuestion);
+        return;
+    }
+    // tree folder: expand or collapse, children are loaded by htmx on the first click
+    const treeToggle = e.target.closest('[data-tree-toggle]');
+    if (treeToggle) {
+        treeToggle.closest('.tree-folder').classList.toggle('open');
+        return;
+    }
+    if (e.target.closest('[data-print]')) {
+        window.print();
+        return;
+    }
+    if (e.target.closest('[data-session-renew]')) {
+        renewSession();
+        return;
+    }
+    // saved search: fill the search box and run it, the box triggers the list request on "search"
+    const savedSearch = e.target.closest('[data-search]');
+    if (savedSearch) {
+        const input = document.querySelector('input[name="search"]');
+        if (input) {
+            input.value = savedSearch.dataset.search;
+            input.dispatchEvent(new Event('search'));
+        }
+    }
+});
+
+// Clear form error on edit; after a validation error, editing the value brings back the regular save button
+document.addEventListener('input', function(e) {
+    if (!e.target.hasAttribute('data-clear-error')) {
+        return;
+    }
+    const formError = document.getElementById('form-error');
+    if (formError) {
+        formError.remove();
+    }
+    if (e.target.hasAttribute('data-reset-force')) {
+        const saveBtn = document.getElementById('save-btn');
+        if (saveBtn) {
+            saveBtn.style.display = '';
+        }
+        const forceBtn = document.getElementById('force-btn');
+        if (forceBtn) {
+            forceBtn.style.display = 'none';
+        }
+    }
+});
+
+// Session expiration warning. The session is renewed by activity on the server, every response reports the
+// new expiration. Tabs share the expiration through localStorage, so activity in one tab moves the warning
+// in all of them, and renewing or signing out in one tab closes the warning everywhere.
+const sessionURL = document.body.dataset.sessionUrl;
+const sessionStorageKey = 'stash-session';
+let session = null; // {expires, max, warnBefore, renewable}, times in ms
+let sessionTimer = null;
+let sessionCountdown = null;
+
+function setSession(status, share) {
+    session = {
+        expires: Date.parse(status.expires_at),
+        max: Date.parse(status.max_expires_at),
+        warnBefore: status.warn_before * 1000,
+        renewable: status.renewable
+    };
+    if (share) {
+        localStorage.setItem(sessionStorageKey, JSON.stringify(status));
+    }
+    scheduleSessionWarning();
+}
+
+function scheduleSessionWarning() {
+    clearTimeout(sessionTimer);
+    const warnIn = session.expires - session.warnBefore - Date.now();
+    if (warnIn > 0) {
+        hideSessionWarning();
+        sessionTimer = setTimeout(checkSession, warnIn);
+        return;
+    }
+    showSessionWarning();
+}
+
+// checkSession asks the server for the expiration before warning, activity of other tabs may have renewed it
+function checkSession() {
+    fetch(sessionURL, {credentials: 'same-origin'}).then(function(resp) {
+        if (resp.status === 401) {
+            sessionExpired();
+            return null;
+        }
+        return resp.ok ? resp.json() : null;
+    }).then(function(status) {
+        if (status) {
+            setSession(status, true);
+        }
+    }).catch(function() {
+        sessionTimer = setTimeout(checkSession, 30000); // server unreachable, try again later
+    });
+}
+
+function showSessionWarning() {
+    const left = session.expires - Date.now();
+    if (left <= 0) {
+        checkSession();
+        return;
+    }
+    const message = document.getElementById('session-message');
+    const renewBtn = document.getElementById('session-renew-btn');
+    if (!message || !renewBtn) {
+        return;
+    }
+    const mins = Math.floor(left / 60000);
+    const secs = String(Math.floor(left / 1000) % 60).padStart(2, '0');
+    message.textContent = session.renewable
+        ? 'Your session expires in ' + mins + ':' + secs + ' due to inactivity.'
+        : 'Your session reaches its maximum lifetime in ' + mins + ':' + secs + ', sign in again to continue.';
+    renewBtn.style.display = session.renewable ? '' : 'none';
+    showModal('session-modal');
+    clearTimeout(sessionCountdown);
+    sessionCountdown = setTimeout(showSessionWarning, 1000);
+}
+
+function hideSessionWarning() {
+    clearTimeout(sessionCountdown);
+    hideModal('session-modal');
+}
+
+function renewSession() {
+    fetch(sessionURL + '/renew', {method: 'POST', credentials: 'same-origin'}).then(function(resp) {
+        if (resp.status === 401) {
+            sessionExpired();
+            return null;
+        }
+        return resp.ok ? resp.json() : null;
+    }).then(function(status) {
+        if (status) {
+            setSession(status, true);
+        }
+    });
+}
+
+function sessionExpired() {
+    localStorage.removeItem(sessionStorageKey);
+    window.location.href = window.BASE_URL + '/login';
+}
+
+if (sessionURL) {
+    checkSession();
+    window.addEventListener('storage', function(e) {
+        if (e.key !== sessionStorageKey) {
+            return;
+        }
+        if (!e.newValue) {
+            sessionExpired(); // signed out or expired in another tab
+            return;
+        }
+        setSession(JSON.parse(e.newValue), false);
+    });
+    document.addEventListener('submit', function(e) {
+        if (e.target.matches('form[action$="/logout"]')) {
+            localStorage.removeItem(sessionStorageKey); // other tabs follow to the login page
+        }
+    });
+}
+
 // HTMX event handlers
 document.body.addEventListener('htmx:afterRequest', function(evt) {
+    // requests renew the session, move the expiration warning in all tabs
+    const expires = evt.detail.xhr && evt.detail.xhr.getResponseHeader('X-Session-Expires');
+    if (session && expires && Date.parse(expires) !== session.expires) {
+        setSession({
+            expires_at: expires,
+            max_expires_at: new Date(session.max).toISOString(),
+            warn_before: session.warnBefore / 1000,
+            renewable: Date.parse(expires) < session.max
+        }, true);
+    }
+
     // Close modal after successful create/edit/delete
     if (evt.detail.successful) {
         const trigger = evt.detail.elt;
@@ -77,5 +284,53 @@ document.body.addEventListener('htmx:afterSwap', function(evt) {
     const target = evt.detail.target;
     if (target.id === 'modal-content') {
         showModal('main-modal');
+        // edit, history and access of the viewed key keep it in the link, other modals drop it
+        const path = evt.detail.pathInfo ? evt.detail.pathInfo.requestPath : '';
+        const m = path.match(/\/web\/keys\/view\/([^?]+)/);
+        if (m) {
+            setOpenKey(decodeURIComponent(m[1]));
+        } else if (!/\/web\/keys\/(edit|history|revision|diff|access)\//.test(path)) {
+            setOpenKey(null);
+        }
     }
 });
+
+// Keys under prefixes requiring justification answer 428 until the request states a reason.
+// Ask for it and repeat the request; the reason is kept per key for the page lifetime,
+// so view, edit and save of the same key ask only once.
+const accessReasons = {};
+
+function reasonKey(cfg) {
+    const m = (cfg.path || '').match(/\/web\/keys\/(?:(?:view|edit|history|revision|diff|restore|folder)\/)?([^?]+)/);
+    if (m && !['new', 'rows', 'export', 'tree'].includes(m[1])) {
+        return decodeURIComponent(m[1]);
+    }
+    const params = cfg.parameters;
+    return params ? (typeof params.get === 'function' ? params.get('key') : params.key) : null;
+}
+
+document.body.addEventListener('htmx:configRequest', function(evt) {
+    const key = reasonKey(evt.detail);
+    if (key && accessReasons[key]) {
+        evt.detail.headers['X-Stash-Reason'] = encodeURIComponent(accessReasons[key]);
+    }
+});
+
+document.body.addEventListener('htmx:responseError', function(evt) {
+    if (evt.detail.xhr.status !== 428) {
+        return;
+    }
+    const cfg = evt.detail.requestConfig;
+    const reason = window.prompt(evt.detail.xhr.responseText.trim());
+    if (!reason || !reason.trim()) {
+        return;
+    }
+    const key = reasonKey(cfg);
+    if (key) {
+        accessReasons[key] = reason.trim();
+    }
+    htmx.ajax(cfg.verb.toUpperCase(), cfg.path, {
+        source: cfg.elt,
+        headers: {'X-Stash-Reason': encodeURIComponent(reason.trim())}
+    });
+});
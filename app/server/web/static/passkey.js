@@ -0,0 +1,121 @@
+// Passkey login and registration with WebAuthn. The server sends binary fields base64url encoded,
+// the browser API takes and returns ArrayBuffers.
+
+function b64urlToBuffer(s) {
+    var b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+    var bin = atob(b64 + '==='.slice((b64.length + 3) % 4));
+    var buf = new Uint8Array(bin.length);
+    for (var i = 0; i < bin.length; i++) {
+        buf[i] = bin.charCodeAt(i);
+    }
+    return buf.buffer;
+}
+
+function bufferToB64url(buf) {
+    var bin = '';
+    new Uint8Array(buf).forEach(function(b) { bin += String.fromCharCode(b); });
+    return btoa(bin).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function decodeCredentialList(list) {
+    return (list || []).map(function(c) { return Object.assign({}, c, {id: b64urlToBuffer(c.id)}); });
+}
+
+// credentialJSON returns the credential of a finished ceremony with binary fields encoded
+function credentialJSON(cred) {
+    var resp = {clientDataJSON: bufferToB64url(cred.response.clientDataJSON)};
+    if (cred.response.attestationObject) {
+        resp.attestationObject = bufferToB64url(cred.response.attestationObject);
+    } else {
+        resp.authenticatorData = bufferToB64url(cred.response.authenticatorData);
+        resp.signature = bufferToB64url(cred.response.signature);
+    }
+    return {id: cred.id, type: cred.type, response: resp};
+}
+
+function showPasskeyError(msg) {
+    var el = document.querySelector('.passkey-error');
+    if (el) {
+        el.textContent = msg;
+        el.hidden = false;
+    }
+}
+
+// postJSON posts the body and returns the parsed response, rejecting with the response text on errors
+function postJSON(url, body) {
+    return fetch(url, {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: {'Content-Type': 'application/json'},
+        body: body === undefined ? '' : JSON.stringify(body)
+    }).then(function(resp) {
+        if (!resp.ok) {
+            return resp.text().then(function(text) { throw new Error(text.trim() || resp.statusText); });
+        }
+        return resp.status === 204 ? null : resp.json();
+    });
+}
+
+// loginWithPasskey asks the authenticator for an assertion and finishes the login with it
+function loginWithPasskey(options, finishURL, remember) {
+    var publicKey = Object.assign({}, options.publicKey, {
+        challenge: b64urlToBuffer(options.publicKey.challenge),
+        allowCredentials: decodeCredentialList(options.publicKey.allowCredentials)
+    });
+    return navigator.credentials.get({publicKey: publicKey}).then(function(cred) {
+        return postJSON(finishURL, {ceremony: options.ceremony, credential: credentialJSON(cred), remember: remember});
+    }).then(function(resp) {
+        window.location.href = resp.redirect;
+    });
+}
+
+function registerPasskey(baseURL) {
+    var name = window.prompt('Name of the passkey, e.g. the device it is on', 'passkey');
+    if (name === null) {
+        return Promise.resolve();
+    }
+    return postJSON(baseURL + '/begin').then(function(options) {
+        var publicKey = Object.assign({}, options.publicKey, {
+            challenge: b64urlToBuffer(options.publicKey.challenge),
+            user: Object.assign({}, options.publicKey.user, {id: b64urlToBuffer(options.publicKey.user.id)}),
+            excludeCredentials: decodeCredentialList(options.publicKey.excludeCredentials)
+        });
+        return navigator.credentials.create({publicKey: publicKey}).then(function(cred) {
+            return postJSON(baseURL + '/finish', {ceremony: options.ceremony, name: name, credential: credentialJSON(cred)});
+        });
+    }).then(function() {
+        window.location.reload();
+    });
+}
+
+document.addEventListener('click', function(e) {
+    var login = e.target.closest('[data-passkey-login]');
+    var step = e.target.closest('[data-passkey-step] button');
+    var register = e.target.closest('[data-passkey-register]');
+    if (!login && !step && !register) {
+        return;
+    }
+    if (!window.PublicKeyCredential) {
+        showPasskeyError('This browser does not support passkeys');
+        return;
+    }
+
+    var done;
+    if (login) {
+        var base = login.getAttribute('data-passkey-login');
+        var remember = document.querySelector('input[name="remember"]');
+        done = postJSON(base + '/begin').then(function(options) {
+            return loginWithPasskey(options, base + '/finish', !!(remember && remember.checked));
+        });
+    } else if (step) {
+        var box = step.closest('[data-passkey-step]');
+        done = loginWithPasskey(JSON.parse(box.getAttribute('data-passkey-step')),
+            box.getAttribute('data-passkey-finish'), box.getAttribute('data-remember') === 'true');
+    } else {
+        done = registerPasskey(register.getAttribute('data-passkey-register'));
+    }
+    done.catch(function(err) {
+        // NotAllowedError is a cancelled or timed out browser dialog
+        showPasskeyError(err.name === 'NotAllowedError' ? 'Passkey request was cancelled' : err.message);
+    });
+});
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/store"
+)
+
+// treeFolder is a folder of the tree view, a path segment shared by the keys below it.
+type treeFolder struct {
+	Name     string // last segment of the path
+	Prefix   string // full path with the trailing slash
+	Keys     int    // keys below the folder, nested folders included
+	Size     int    // total size of the values below the folder
+	CanWrite bool   // user can write all keys below the folder, allows deleting it
+}
+
+// treeData holds one level of the tree view, folders directly under TreePrefix. Keys of the level are
+// in templateData.Keys.
+type treeData struct {
+	TreePrefix  string
+	TreeFolders []treeFolder
+}
+
+// handleKeyTree renders the folders and keys directly under ?prefix= for an expanded tree folder,
+// following the current search and secrets filter.
+func (h *Handler) handleKeyTree(w http.ResponseWriter, r *http.Request) {
+	params := h.getListParams(w, r)
+	params.viewMode = enum.ViewModeTree
+	data, err := h.keyListData(r, params, 1, false)
+	if err != nil {
+		log.Printf("[ERROR] failed to list keys: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "tree-level", data); err != nil {
+		log.Printf("[ERROR] failed to execute template: %v", err)
+	}
+}
+
+// listTree loads all keys visible to the user under the prefix and groups them into one level of the tree.
+// Counts of the pagination data are of all matching keys, the tree isn't paged.
+func (h *Handler) listTree(ctx context.Context, username string, q store.ListQuery,
+	prefix string) ([]keyWithPermission, treeData, paginationData, error) {
+	// the folder narrows down the prefix: qualifier of the search, buildTree drops keys outside of it otherwise
+	if strings.HasPrefix(prefix, q.Prefix) {
+		q.Prefix = prefix
+	}
+	keys, total, err := h.Store.ListPage(ctx, h.userListQuery(username, q))
+	if err != nil {
+		return nil, treeData{}, paginationData{}, fmt.Errorf("list keys: %w", err)
+	}
+	all := make([]keyWithPermission, len(keys))
+	for i, k := range keys {
+		all[i] = keyWithPermission{KeyInfo: k, CanWrite: h.Auth.CheckUserPermission(username, k.Key, true)}
+	}
+	folders, level := buildTree(all, prefix)
+	return level, treeData{TreePrefix: prefix, TreeFolders: folders}, paginationData{Page: 1, TotalPages: 1, TotalKeys: total}, nil
+}
+
+// buildTree groups keys under the prefix by their next path segment. Returns folders ordered by name and
+// the keys directly under the prefix in the order of the list. Keys outside of the prefix are skipped.
+func buildTree(keys []keyWithPermission, prefix string) (folders []treeFolder, level []keyWithPermission) {
+	index := map[string]int{}
+	for _, k := range keys {
+		rest, ok := strings.CutPrefix(k.Key, prefix)
+		if !ok {
+			continue
+		}
+		name, _, nested := strings.Cut(rest, "/")
+		if !nested {
+			level = append(level, k)
+			continue
+		}
+		i, found := index[name]
+		if !found {
+			i = len(folders)
+			index[name] = i
+			folders = append(folders, treeFolder{Name: name, Prefix: prefix + name + "/", CanWrite: true})
+		}
+		folders[i].Keys++
+		folders[i].Size += k.Size
+		folders[i].CanWrite = folders[i].CanWrite && k.CanWrite
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	return folders, level
+}
+
+// handleFolderDelete deletes all keys under a folder of the tree view the user can see, in one transaction,
+// so either all of them are deleted or none. Refused if the user can't delete one of them.
+func (h *Handler) handleFolderDelete(w http.ResponseWriter, r *http.Request) {
+	prefix := store.NormalizeKey(r.PathValue("prefix"))
+	if prefix == "" {
+		http.Error(w, "prefix is required", http.StatusBadRequest)
+		return
+	}
+	prefix += "/"
+	username := h.getCurrentUser(r)
+	keys, _, err := h.Store.ListPage(r.Context(), h.userListQuery(username, store.ListQuery{Prefix: prefix}))
+	if err != nil {
+		log.Printf("[ERROR] failed to list keys under %s: %v", prefix, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	ops := make([]store.TxnOp, 0, len(keys))
+	for _, k := range keys {
+		if !h.Auth.CheckUserPermission(username, k.Key, true) {
+			h.renderFolderError(w, fmt.Sprintf("Access denied: you don't have write permission for %s", k.Key))
+			return
+		}
+		if !h.checkReason(w, r, k.Key) {
+			return
+		}
+		if h.Owners != nil {
+			allowed, err := h.Owners.CanDelete(r.Context(), k.Key, h.getIdentityForLog(r), h.Auth.IsAdmin(username))
+			if err != nil {
+				log.Printf("[ERROR] failed to check owner of %s: %v", k.Key, err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				h.renderFolderError(w, fmt.Sprintf("Only the owner or an admin can delete %s", k.Key))
+				return
+			}
+		}
+		ops = append(ops, store.TxnOp{Key: k.Key, Delete: true})
+	}
+
+	if len(ops) > 0 {
+		if _, err := h.Store.Txn(r.Context(), ops); err != nil {
+			var conflict *store.TxnConflictError
+			switch msg, ok := storeErrorMessage(err); {
+			case ok:
+				h.renderFolderError(w, msg)
+			case errors.As(err, &conflict):
+				h.renderFolderError(w, fmt.Sprintf("Keys under %s were changed meanwhile, nothing deleted, try again", prefix))
+			default:
+				log.Printf("[ERROR] failed to delete keys under %s: %v", prefix, err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	log.Printf("[INFO] delete %d keys under %q by %s", len(ops), prefix, h.getIdentityForLog(r))
+	for _, op := range ops {
+		h.logAudit(r, op.Key, enum.AuditActionDelete, enum.AuditResultSuccess, nil)
+		if h.Git != nil {
+			if err := h.Git.Delete(op.Key, h.getAuthor(username)); err != nil {
+				log.Printf("[WARN] git delete failed for %s: %v", op.Key, err)
+			}
+		}
+		h.publishEvent(op.Key, enum.AuditActionDelete)
+	}
+	h.handleKeyList(w, r) // return updated keys table
+}
+
+// renderFolderError shows the error of a folder action in the modal instead of the keys table.
+func (h *Handler) renderFolderError(w http.ResponseWriter, msg string) {
+	w.Header().Set("HX-Retarget", "#modal-content")
+	w.Header().Set("HX-Reswap", "innerHTML")
+	h.renderError(w, msg)
+}
@@ -0,0 +1,210 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/app/git"
+	"github.com/umputun/stash/app/server/web/mocks"
+	"github.com/umputun/stash/app/store"
+)
+
+func TestBuildTree(t *testing.T) {
+	key := func(k string, size int, canWrite bool) keyWithPermission {
+		return keyWithPermission{KeyInfo: store.KeyInfo{Key: k, Size: size}, CanWrite: canWrite}
+	}
+	keys := []keyWithPermission{
+		key("readme", 1, true), key("prod/db/host", 10, true), key("app/name", 5, true),
+		key("prod/db/pass", 20, false), key("prod/api", 3, true), key("prod", 2, true),
+	}
+
+	t.Run("root", func(t *testing.T) {
+		folders, level := buildTree(keys, "")
+		assert.Equal(t, []treeFolder{
+			{Name: "app", Prefix: "app/", Keys: 1, Size: 5, CanWrite: true},
+			{Name: "prod", Prefix: "prod/", Keys: 3, Size: 33, CanWrite: false},
+		}, folders)
+		assert.Equal(t, []keyWithPermission{key("readme", 1, true), key("prod", 2, true)}, level, "list order kept")
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		folders, level := buildTree(keys, "prod/")
+		assert.Equal(t, []treeFolder{{Name: "db", Prefix: "prod/db/", Keys: 2, Size: 30, CanWrite: false}}, folders)
+		assert.Equal(t, []keyWithPermission{key("prod/api", 3, true)}, level)
+	})
+
+	t.Run("leaf folder", func(t *testing.T) {
+		folders, level := buildTree(keys, "prod/db/")
+		assert.Empty(t, folders)
+		assert.Len(t, level, 2)
+	})
+
+	t.Run("no keys under prefix", func(t *testing.T) {
+		folders, level := buildTree(keys, "staging/")
+		assert.Empty(t, folders)
+		assert.Empty(t, level)
+	})
+}
+
+func TestHandler_TreeView(t *testing.T) {
+	now := time.Now()
+	all := []store.KeyInfo{
+		{Key: "prod/db/host", Size: 10, UpdatedAt: now}, {Key: "prod/api", Size: 3, UpdatedAt: now},
+		{Key: "readme", Size: 1, UpdatedAt: now},
+	}
+	st := &mocks.KVStoreMock{
+		ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+			var res []store.KeyInfo
+			for _, k := range all {
+				if strings.HasPrefix(k.Key, q.Prefix) {
+					res = append(res, k)
+				}
+			}
+			return res, len(res), nil
+		},
+		SecretsEnabledFunc: func() bool { return false },
+	}
+	h := newTestHandlerWithStore(t, st)
+	h.PageSize = 1 // the tree isn't paged
+
+	treeRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "view_mode", Value: "tree"})
+		return req
+	}
+
+	t.Run("index shows root level", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleIndex(rec, treeRequest("/"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `class="tree"`)
+		assert.Contains(t, body, "prod/</span>")
+		assert.Contains(t, body, "2 keys")
+		assert.Contains(t, body, "/web/keys/tree?prefix=prod%2F")
+		assert.Contains(t, body, "/web/keys/folder/prod%2F")
+		assert.Contains(t, body, "/web/keys/view/readme")
+		assert.NotContains(t, body, "/web/keys/view/prod%2Fapi", "nested keys are loaded on expand")
+		assert.Contains(t, body, "3 keys", "key count of all keys")
+	})
+
+	t.Run("folder level", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleKeyTree(rec, treeRequest("/web/keys/tree?prefix=prod/"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "/web/keys/view/prod%2Fapi")
+		assert.Contains(t, body, `title="prod/api">api`)
+		assert.Contains(t, body, "/web/keys/tree?prefix=prod%2Fdb%2F")
+		assert.NotContains(t, body, "readme")
+		calls := st.ListPageCalls()
+		assert.Equal(t, "prod/", calls[len(calls)-1].Q.Prefix)
+		assert.Zero(t, calls[len(calls)-1].Q.Limit)
+	})
+
+	t.Run("search prefix kept when folder is outside of it", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.handleKeyTree(rec, treeRequest("/web/keys/tree?prefix=prod/&search=prefix:prod/db/"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		calls := st.ListPageCalls()
+		assert.Equal(t, "prod/db/", calls[len(calls)-1].Q.Prefix)
+		assert.NotContains(t, rec.Body.String(), "prod%2Fapi")
+	})
+
+	t.Run("view mode toggle to tree", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/web/view-mode", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "view_mode", Value: enum.ViewModeCards.String()})
+		rec := httptest.NewRecorder()
+		h.handleViewModeToggle(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `class="tree"`)
+	})
+}
+
+func TestHandler_HandleFolderDelete(t *testing.T) {
+	keys := []store.KeyInfo{{Key: "prod/db/host"}, {Key: "prod/db/pass"}}
+	newStore := func(txnErr error) *mocks.KVStoreMock {
+		return &mocks.KVStoreMock{
+			ListPageFunc: func(_ context.Context, q store.ListQuery) ([]store.KeyInfo, int, error) {
+				if q.Prefix == "prod/db/" {
+					return keys, len(keys), nil
+				}
+				return nil, 0, nil
+			},
+			TxnFunc:            func(context.Context, []store.TxnOp) ([]store.TxnResult, error) { return nil, txnErr },
+			SecretsEnabledFunc: func() bool { return false },
+		}
+	}
+	deleteRequest := func(prefix string) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, "/web/keys/folder/"+prefix, http.NoBody)
+		req.SetPathValue("prefix", prefix)
+		return req
+	}
+
+	t.Run("deletes all keys in one transaction", func(t *testing.T) {
+		st := newStore(nil)
+		gitSvc := &mocks.GitServiceMock{DeleteFunc: func(string, git.Author) error { return nil }}
+		h := newTestHandlerWithStore(t, st)
+		h.Git = gitSvc
+		rec := httptest.NewRecorder()
+		h.handleFolderDelete(rec, deleteRequest("prod/db/"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, st.TxnCalls(), 1)
+		assert.Equal(t, []store.TxnOp{{Key: "prod/db/host", Delete: true}, {Key: "prod/db/pass", Delete: true}},
+			st.TxnCalls()[0].Ops)
+		assert.Len(t, gitSvc.DeleteCalls(), 2)
+	})
+
+	t.Run("no write permission for one key", func(t *testing.T) {
+		st := newStore(nil)
+		auth := &mocks.AuthProviderMock{
+			EnabledFunc:             func() bool { return true },
+			GetSessionUserFunc:      func(context.Context, string) (string, bool) { return "bob", true },
+			FilterUserKeysFunc:      func(_ string, keys []string) []string { return keys },
+			CheckUserPermissionFunc: func(_, key string, _ bool) bool { return key != "prod/db/pass" },
+			UserCanWriteFunc:        func(string) bool { return true },
+			IsAdminFunc:             func(string) bool { return false },
+		}
+		h := newTestHandlerWithAuth(t, auth)
+		h.Store = st
+		req := deleteRequest("prod/db")
+		req.AddCookie(&http.Cookie{Name: "stash-auth", Value: "token"})
+		rec := httptest.NewRecorder()
+		h.handleFolderDelete(rec, req)
+		assert.Equal(t, "#modal-content", rec.Header().Get("HX-Retarget"))
+		assert.Contains(t, rec.Body.String(), "write permission for prod/db/pass")
+		assert.Empty(t, st.TxnCalls())
+	})
+
+	t.Run("changed meanwhile", func(t *testing.T) {
+		st := newStore(&store.TxnConflictError{Key: "prod/db/pass"})
+		h := newTestHandlerWithStore(t, st)
+		rec := httptest.NewRecorder()
+		h.handleFolderDelete(rec, deleteRequest("prod/db"))
+		assert.Equal(t, "#modal-content", rec.Header().Get("HX-Retarget"))
+		assert.Contains(t, rec.Body.String(), "nothing deleted")
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		frozen := &store.FrozenError{Key: "prod/db/host", Freeze: store.Freeze{Prefix: "prod", Incident: "INC-1"}}
+		h := newTestHandlerWithStore(t, newStore(frozen))
+		rec := httptest.NewRecorder()
+		h.handleFolderDelete(rec, deleteRequest("prod/db"))
+		assert.Contains(t, rec.Body.String(), "frozen for incident INC-1")
+	})
+
+	t.Run("no prefix", func(t *testing.T) {
+		h := newTestHandlerWithStore(t, newStore(nil))
+		rec := httptest.NewRecorder()
+		h.handleFolderDelete(rec, deleteRequest("/"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// ErrDataKeyNotConfigured is returned when a value is encrypted at rest but the data key is not configured.
+var ErrDataKeyNotConfigured = errors.New("data encryption key not configured")
+
+// WithDataEncryptor encrypts values of all keys at rest, not only secrets. Values outside secrets paths
+// are encrypted with the given encryptor, which can be the secrets one or a separate data key. Plaintext
+// values stay readable and are encrypted on the next write, EncryptAll converts them at once.
+func WithDataEncryptor(enc Encryptor) Option {
+	return func(s *Store) {
+		s.dataEncryptor = enc
+	}
+}
+
+// DataEncryptionEnabled returns true if values of regular keys are encrypted at rest.
+func (s *Store) DataEncryptionEnabled() bool {
+	return s.dataEncryptor != nil
+}
+
+// sealValue returns the value as it is stored: secrets are encrypted with the secrets key, other values
+// with the data key if configured. ZK-encrypted values are stored as is. encrypted is true for values
+// encrypted with the data key, it is stored with the value in the encrypted column. The value itself is
+// not tagged, so any user data, including values looking like ciphertext, round-trips unchanged.
+func (s *Store) sealValue(key string, value []byte) (stored []byte, encrypted bool, err error) {
+	switch {
+	case stash.IsZKEncrypted(value):
+		return value, false, nil
+	case IsSecret(key):
+		stored, err = s.encrypt(key, value)
+		return stored, false, err
+	case s.dataEncryptor == nil:
+		return value, false, nil
+	}
+	enc, err := s.dataEncryptor.Encrypt(value)
+	if err != nil {
+		return nil, false, fmt.Errorf("data key: %w", err)
+	}
+	return enc, true, nil
+}
+
+// openValue reverses sealValue, encrypted is the flag stored with the value. Values of regular keys
+// without the flag are plaintext and returned as is.
+func (s *Store) openValue(key string, value []byte, encrypted bool) ([]byte, error) {
+	switch {
+	case stash.IsZKEncrypted(value):
+		return value, nil
+	case IsSecret(key):
+		return s.decrypt(value)
+	case !encrypted:
+		return value, nil
+	case s.dataEncryptor == nil:
+		return nil, ErrDataKeyNotConfigured
+	}
+	plain, err := s.dataEncryptor.Decrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+	return plain, nil
+}
+
+// EncryptAll encrypts plaintext values of regular keys with the data key, e.g. after encryption at rest
+// was enabled for a database with existing keys. Previous values in the history are encrypted too.
+// Secrets and ZK-encrypted values are skipped and updated_at is kept, so clients holding a version
+// don't see a conflict. Rows are converted in batches, each under the store lock, so writes of other
+// requests go on in between. Returns the number of encrypted keys, history revisions are not counted.
+func (s *Store) EncryptAll(ctx context.Context) (int, error) {
+	if !s.DataEncryptionEnabled() {
+		return 0, ErrDataKeyNotConfigured
+	}
+	encrypted, err := s.encryptTable(ctx, "kv", "key", "")
+	if err != nil {
+		return encrypted, err
+	}
+	if _, err := s.encryptTable(ctx, "kv_history", "id", 0); err != nil {
+		return encrypted, err
+	}
+	return encrypted, nil
+}
+
+// encryptTable encrypts plaintext values of regular keys in a table with key and value columns, in batches
+// of listBatch rows ordered by the id column, starting after the given id. Returns the number of encrypted rows.
+func (s *Store) encryptTable(ctx context.Context, table, id string, after any) (int, error) {
+	encrypted := 0
+	for {
+		n, last, done, err := s.encryptBatch(ctx, table, id, after)
+		encrypted += n
+		if err != nil || done {
+			return encrypted, err
+		}
+		after = last
+	}
+}
+
+// encryptBatch encrypts the next batch of plaintext rows after the given id under the store lock.
+// Returns the number of encrypted rows, the id of the last row read and true if no rows are left.
+func (s *Store) encryptBatch(ctx context.Context, table, id string, after any) (encrypted int, last any, done bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []struct {
+		ID    any    `db:"id"` // the value of the id column as scanned, passed back to the update
+		Key   string `db:"key"`
+		Value []byte `db:"value"`
+	}
+	query := fmt.Sprintf("SELECT %[1]s AS id, key, value FROM %[2]s WHERE NOT encrypted AND %[1]s > ? ORDER BY %[1]s LIMIT %[3]d",
+		id, table, listBatch)
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery(query), after); err != nil {
+		return 0, nil, false, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+
+	// the row is skipped if another writer sealed it since it was read
+	update := s.adoptQuery(fmt.Sprintf("UPDATE %s SET value = ?, encrypted = ? WHERE %s = ? AND NOT encrypted", table, id))
+	for _, r := range rows {
+		last = r.ID
+		if IsSecret(r.Key) || stash.IsZKEncrypted(r.Value) {
+			continue
+		}
+		sealed, flag, err := s.sealValue(r.Key, r.Value)
+		if err != nil {
+			return encrypted, last, false, fmt.Errorf("failed to encrypt key %q: %w", r.Key, err)
+		}
+		res, err := s.db.ExecContext(ctx, update, sealed, flag, r.ID)
+		if err != nil {
+			return encrypted, last, false, fmt.Errorf("failed to update key %q in %s: %w", r.Key, table, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			continue
+		}
+		log.Printf("[DEBUG] encrypted %s %v of key %q at rest", table, r.ID, r.Key)
+		encrypted++
+	}
+	return encrypted, last, len(rows) < listBatch, nil
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_DataEncryption(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			ctx := t.Context()
+			prefix := "atrest/" + engine + "/"
+			secretsKey, err := NewCrypto([]byte("test-secret-key-1234"))
+			require.NoError(t, err)
+			dataKey, err := NewCrypto([]byte("test-data-key-12345"))
+			require.NoError(t, err)
+			st := newTestStore(t, engine, WithEncryptor(secretsKey), WithDataEncryptor(dataKey), WithHistory(5))
+			assert.True(t, st.DataEncryptionEnabled())
+
+			readRaw := func(key string) string {
+				var raw []byte
+				require.NoError(t, st.db.GetContext(ctx, &raw, st.adoptQuery("SELECT value FROM kv WHERE key = ?"), key))
+				return string(raw)
+			}
+			isEncrypted := func(key string) bool {
+				var encrypted bool
+				require.NoError(t, st.db.GetContext(ctx, &encrypted, st.adoptQuery("SELECT encrypted FROM kv WHERE key = ?"), key))
+				return encrypted
+			}
+
+			_, err = st.Set(ctx, prefix+"app/db", []byte("postgres://db"), "text")
+			require.NoError(t, err)
+			_, err = st.Set(ctx, prefix+"app/secrets/token", []byte("sk_1"), "text")
+			require.NoError(t, err)
+			_, err = st.Set(ctx, prefix+"app/zk", []byte("$ZK$c2VjcmV0"), "text")
+			require.NoError(t, err)
+
+			assert.True(t, isEncrypted(prefix+"app/db"))
+			assert.NotContains(t, readRaw(prefix+"app/db"), "postgres://db")
+			assert.False(t, isEncrypted(prefix+"app/secrets/token"), "secrets keep their encryption")
+			assert.NotContains(t, readRaw(prefix+"app/secrets/token"), "sk_1")
+			assert.Equal(t, "$ZK$c2VjcmV0", readRaw(prefix+"app/zk"), "zk values stored as is")
+			assert.False(t, isEncrypted(prefix+"app/zk"))
+
+			value, format, err := st.GetWithFormat(ctx, prefix+"app/db")
+			require.NoError(t, err)
+			assert.Equal(t, "postgres://db", string(value))
+			assert.Equal(t, "text", format)
+			value, err = st.Get(ctx, prefix+"app/secrets/token")
+			require.NoError(t, err)
+			assert.Equal(t, "sk_1", string(value))
+
+			t.Run("history and conflicts are decrypted", func(t *testing.T) {
+				info, err := st.GetInfo(ctx, prefix+"app/db")
+				require.NoError(t, err)
+				require.NoError(t, st.SetWithVersion(ctx, prefix+"app/db", []byte("postgres://db2"), "text", info.UpdatedAt))
+				assert.True(t, isEncrypted(prefix+"app/db"))
+
+				revs, err := st.GetHistory(ctx, prefix+"app/db", 0)
+				require.NoError(t, err)
+				require.Len(t, revs, 1)
+				assert.Equal(t, "postgres://db", string(revs[0].Value))
+
+				err = st.SetWithVersion(ctx, prefix+"app/db", []byte("stale"), "text", info.UpdatedAt.Add(-time.Hour))
+				var conflict *ConflictError
+				require.ErrorAs(t, err, &conflict)
+				assert.Equal(t, "postgres://db2", string(conflict.Info.CurrentValue))
+			})
+
+			t.Run("transaction values are encrypted", func(t *testing.T) {
+				_, err := st.Txn(ctx, []TxnOp{{Key: prefix + "app/txn", Value: []byte("in-txn")}})
+				require.NoError(t, err)
+				assert.True(t, isEncrypted(prefix+"app/txn"))
+				value, err := st.Get(ctx, prefix+"app/txn")
+				require.NoError(t, err)
+				assert.Equal(t, "in-txn", string(value))
+			})
+
+			t.Run("plaintext rows readable and encrypted on write", func(t *testing.T) {
+				for _, legacy := range []string{"legacy", "$ENC$legacy"} {
+					_, err := st.db.ExecContext(ctx, st.adoptQuery("UPDATE kv SET value = ?, encrypted = ? WHERE key = ?"),
+						[]byte(legacy), false, prefix+"app/db")
+					require.NoError(t, err)
+					value, err := st.Get(ctx, prefix+"app/db")
+					require.NoError(t, err)
+					assert.Equal(t, legacy, string(value), "plaintext looking like ciphertext is plaintext")
+				}
+
+				_, err = st.Set(ctx, prefix+"app/db", []byte("migrated"), "text")
+				require.NoError(t, err)
+				assert.True(t, isEncrypted(prefix+"app/db"))
+			})
+
+			t.Run("marker-like values round-trip", func(t *testing.T) {
+				_, err := st.Set(ctx, prefix+"app/marker", []byte("$ENC$hello"), "text")
+				require.NoError(t, err)
+				value, err := st.Get(ctx, prefix+"app/marker")
+				require.NoError(t, err)
+				assert.Equal(t, "$ENC$hello", string(value))
+			})
+
+			t.Run("encrypted values need the data key", func(t *testing.T) {
+				noData := &Store{db: st.db, dbType: st.dbType, mu: st.mu, encryptor: secretsKey}
+				_, err := noData.Get(ctx, prefix+"app/db")
+				require.ErrorIs(t, err, ErrDataKeyNotConfigured)
+				value, err := noData.Get(ctx, prefix+"app/secrets/token")
+				require.NoError(t, err)
+				assert.Equal(t, "sk_1", string(value))
+
+				otherKey, err := NewCrypto([]byte("other-data-key-12345"))
+				require.NoError(t, err)
+				wrongData := &Store{db: st.db, dbType: st.dbType, mu: st.mu, encryptor: secretsKey, dataEncryptor: otherKey}
+				_, err = wrongData.Get(ctx, prefix+"app/db")
+				require.ErrorIs(t, err, ErrDecryptionFailed)
+			})
+		})
+	}
+}
+
+func TestStore_EncryptAll(t *testing.T) {
+	ctx := t.Context()
+	secretsKey, err := NewCrypto([]byte("test-secret-key-1234"))
+	require.NoError(t, err)
+	plain := newTestStore(t, "sqlite", WithEncryptor(secretsKey), WithHistory(5))
+	for _, kv := range [][2]string{{"app/db", "v1"}, {"app/db", "v2"}, {"app/empty", ""}, {"app/secrets/token", "sk_1"},
+		{"app/zk", "$ZK$c2VjcmV0"}, {"app/marker", "$ENC$hello"}} {
+		_, err = plain.Set(ctx, kv[0], []byte(kv[1]), "text")
+		require.NoError(t, err)
+	}
+	value, err := plain.Get(ctx, "app/marker")
+	require.NoError(t, err, "marker-like value readable without encryption at rest")
+	assert.Equal(t, "$ENC$hello", string(value))
+	_, err = plain.EncryptAll(ctx)
+	require.ErrorIs(t, err, ErrDataKeyNotConfigured)
+
+	st := &Store{db: plain.db, dbType: plain.dbType, mu: plain.mu, encryptor: secretsKey, dataEncryptor: secretsKey,
+		historyLimit: 5}
+	before, err := st.GetInfo(ctx, "app/db")
+	require.NoError(t, err)
+
+	n, err := st.EncryptAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n, "app/db, app/empty and app/marker, secrets and zk values skipped")
+
+	var plaintext int
+	require.NoError(t, st.db.GetContext(ctx, &plaintext, "SELECT COUNT(*) FROM kv_history WHERE NOT encrypted"))
+	assert.Zero(t, plaintext, "history encrypted too")
+
+	after, err := st.GetInfo(ctx, "app/db")
+	require.NoError(t, err)
+	assert.True(t, before.UpdatedAt.Equal(after.UpdatedAt), "encrypt-all keeps version")
+	value, err = st.Get(ctx, "app/db")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(value))
+	value, err = st.Get(ctx, "app/marker")
+	require.NoError(t, err)
+	assert.Equal(t, "$ENC$hello", string(value), "marker-like plaintext encrypted and read back")
+	revs, err := st.GetHistory(ctx, "app/db", 0)
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	assert.Equal(t, "v1", string(revs[0].Value))
+
+	report, err := st.GCReport(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app/empty"}, gcKeys(report.Empty), "encrypted empty values found by gc")
+
+	n, err = st.EncryptAll(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing left to encrypt")
+}
+
+func TestStore_EncryptAllBatches(t *testing.T) {
+	ctx := t.Context()
+	key, err := NewCrypto([]byte("test-secret-key-1234"))
+	require.NoError(t, err)
+	plain := newTestStore(t, "sqlite", WithEncryptor(key))
+	for i := range listBatch + 10 {
+		_, err = plain.Set(ctx, fmt.Sprintf("app/k%04d", i), []byte("v"), "text")
+		require.NoError(t, err)
+		if i%100 == 0 { // skipped rows don't stop the batches
+			_, err = plain.Set(ctx, fmt.Sprintf("app/k%04d/secrets/s", i), []byte("s"), "text")
+			require.NoError(t, err)
+		}
+	}
+
+	st := &Store{db: plain.db, dbType: plain.dbType, mu: plain.mu, encryptor: key, dataEncryptor: prefixEncryptor{}}
+	n, err := st.EncryptAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, listBatch+10, n, "keys of all batches encrypted")
+
+	var plaintext int
+	require.NoError(t, st.db.GetContext(ctx, &plaintext, "SELECT COUNT(*) FROM kv WHERE NOT encrypted"))
+	assert.Equal(t, 6, plaintext, "only secrets left unflagged")
+	value, err := st.Get(ctx, fmt.Sprintf("app/k%04d", listBatch+9))
+	require.NoError(t, err)
+	assert.Equal(t, "v", string(value))
+}
+
+// prefixEncryptor is a cheap reversible Encryptor for tests with many values, Crypto derives a key per value.
+type prefixEncryptor struct{}
+
+func (prefixEncryptor) Encrypt(value []byte) ([]byte, error) {
+	return append([]byte("enc:"), value...), nil
+}
+
+func (prefixEncryptor) Decrypt(value []byte) ([]byte, error) {
+	plain, ok := bytes.CutPrefix(value, []byte("enc:"))
+	if !ok {
+		return nil, errors.New("not encrypted")
+	}
+	return plain, nil
+}
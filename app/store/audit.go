@@ -24,19 +24,22 @@ type AuditEntry struct {
 	UserAgent string           `json:"user_agent,omitempty" db:"user_agent"`
 	ValueSize *int             `json:"value_size,omitempty" db:"value_size"`
 	RequestID string           `json:"request_id,omitempty" db:"request_id"`
+	Reason    string           `json:"reason,omitempty" db:"reason"` // access justification given by the caller
 }
 
 // AuditQuery defines filters for querying audit logs.
 type AuditQuery struct {
-	Key       string           // prefix match with * suffix, e.g., "app/*"
-	Actor     string           // exact match
-	ActorType enum.ActorType   // exact match (zero value = any)
-	Action    enum.AuditAction // exact match (zero value = any)
-	Result    enum.AuditResult // exact match (zero value = any)
-	From      time.Time        // inclusive
-	To        time.Time        // inclusive
-	Limit     int              // max entries to return
-	Offset    int              // skip entries for pagination
+	Key       string             // prefix match with * suffix, e.g., "app/*"
+	Keys      []string           // any of the listed keys, exact match (empty = any)
+	Actor     string             // exact match
+	ActorType enum.ActorType     // exact match (zero value = any)
+	Action    enum.AuditAction   // exact match (zero value = any)
+	Actions   []enum.AuditAction // any of the listed actions (empty = any), for matching several actions at once
+	Result    enum.AuditResult   // exact match (zero value = any)
+	From      time.Time          // inclusive
+	To        time.Time          // inclusive
+	Limit     int                // max entries to return
+	Offset    int                // skip entries for pagination
 }
 
 // LogAudit inserts an audit entry into the audit_log table.
@@ -45,8 +48,8 @@ func (s *Store) LogAudit(ctx context.Context, entry AuditEntry) error {
 	defer s.mu.Unlock()
 
 	query := s.adoptQuery(`
-		INSERT INTO audit_log (timestamp, action, key, actor, actor_type, result, ip, user_agent, value_size, request_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO audit_log (timestamp, action, key, actor, actor_type, result, ip, user_agent, value_size, request_id, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 
 	_, err := s.db.ExecContext(ctx, query,
@@ -60,6 +63,7 @@ func (s *Store) LogAudit(ctx context.Context, entry AuditEntry) error {
 		entry.UserAgent,
 		entry.ValueSize,
 		entry.RequestID,
+		entry.Reason,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert audit entry: %w", err)
@@ -73,7 +77,253 @@ func (s *Store) QueryAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, int
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// build WHERE clause dynamically
+	whereClause, args := auditWhere(q)
+
+	// get total count first
+	countQuery := s.adoptQuery("SELECT COUNT(*) FROM audit_log" + whereClause)
+	var total int
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	// get entries with limit and offset
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 10000 // default limit
+	}
+
+	selectQuery := s.adoptQuery("SELECT id, timestamp, action, key, actor, actor_type, result, ip," +
+		" user_agent, value_size, request_id, reason FROM audit_log" + whereClause + " ORDER BY timestamp DESC LIMIT ? OFFSET ?")
+	args = append(args, limit, q.Offset)
+
+	rows, err := s.db.QueryxContext(ctx, selectQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e auditRow
+		if err := rows.StructScan(&e); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e.toAuditEntry())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit rows: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// audit log columns CountAudit can group by
+const (
+	AuditGroupActor  = "actor"
+	AuditGroupKey    = "key"
+	AuditGroupAction = "action"
+)
+
+// maxAuditBuckets limits the number of intervals AuditTimeline can return.
+const maxAuditBuckets = 1000
+
+// AuditCount is the number of audit entries sharing the same value of the grouping column.
+type AuditCount struct {
+	Value string `json:"value" db:"grp"`
+	Count int    `json:"count" db:"cnt"`
+}
+
+// AuditBucket is the number of audit entries in the time interval starting at Start.
+type AuditBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// CountAudit counts audit entries matching the filters, grouped by the given column
+// (AuditGroupActor, AuditGroupKey or AuditGroupAction). Groups are ordered by count descending,
+// q.Limit limits the number of groups (0 = all), q.Offset is ignored.
+func (s *Store) CountAudit(ctx context.Context, q AuditQuery, groupBy string) ([]AuditCount, error) {
+	switch groupBy {
+	case AuditGroupActor, AuditGroupKey, AuditGroupAction:
+	default:
+		return nil, fmt.Errorf("invalid audit group %q", groupBy)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	whereClause, args := auditWhere(q)
+	query := "SELECT " + groupBy + " AS grp, COUNT(*) AS cnt FROM audit_log" + whereClause +
+		" GROUP BY " + groupBy + " ORDER BY cnt DESC, grp"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	var counts []AuditCount
+	if err := s.db.SelectContext(ctx, &counts, s.adoptQuery(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	return counts, nil
+}
+
+// AuditRecentKey is a key with the time of its latest matching audit entry.
+type AuditRecentKey struct {
+	Key  string    `json:"key"`
+	Last time.Time `json:"last"`
+}
+
+// RecentAuditKeys returns keys of the audit entries matching the filters, each key once, most
+// recently used first. Keys deleted since are skipped. q.Limit limits the number of keys (0 = all),
+// q.Offset is ignored.
+func (s *Store) RecentAuditKeys(ctx context.Context, q AuditQuery) ([]AuditRecentKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	whereClause, args := auditWhere(q)
+	query := "SELECT r.key, r.last FROM (SELECT key, MAX(timestamp) AS last FROM audit_log" + whereClause +
+		" GROUP BY key) r JOIN kv ON kv.key = r.key ORDER BY r.last DESC, r.key"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	var rows []struct {
+		Key  string `db:"key"`
+		Last string `db:"last"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query recent audit keys: %w", err)
+	}
+	res := make([]AuditRecentKey, 0, len(rows))
+	for _, r := range rows {
+		last, err := time.Parse(time.RFC3339, r.Last)
+		if err != nil {
+			log.Printf("[WARN] failed to parse audit timestamp %q: %v", r.Last, err)
+		}
+		res = append(res, AuditRecentKey{Key: r.Key, Last: last})
+	}
+	return res, nil
+}
+
+// LastAuditEntries returns the latest audit entry matching the filters of each key, e.g. the last
+// successful write, ordered by key. q.Limit and q.Offset are ignored.
+func (s *Store) LastAuditEntries(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// ids grow with inserts, the highest id of a key is its latest entry even within the same second
+	whereClause, args := auditWhere(q)
+	query := "SELECT id, timestamp, action, key, actor, actor_type, result, ip, user_agent, value_size, request_id, reason" +
+		" FROM audit_log WHERE id IN (SELECT MAX(id) FROM audit_log" + whereClause + " GROUP BY key) ORDER BY key"
+
+	var rows []auditRow
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query last audit entries: %w", err)
+	}
+	res := make([]AuditEntry, 0, len(rows))
+	for _, r := range rows {
+		res = append(res, r.toAuditEntry())
+	}
+	return res, nil
+}
+
+// AuditTimeline counts audit entries matching the filters in consecutive intervals of the given
+// size, starting at q.From (required) up to q.To or now if not set. Intervals without entries
+// are included with zero count. Limit and Offset are ignored.
+func (s *Store) AuditTimeline(ctx context.Context, q AuditQuery, interval time.Duration) ([]AuditBucket, error) {
+	n, err := timelineIntervals(q, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// bucketing is done here rather than in SQL to avoid dialect-specific date functions
+	whereClause, args := auditWhere(q)
+	var timestamps []string
+	if err := s.db.SelectContext(ctx, &timestamps, s.adoptQuery("SELECT timestamp FROM audit_log"+whereClause), args...); err != nil {
+		return nil, fmt.Errorf("failed to query audit timeline: %w", err)
+	}
+
+	buckets := make([]AuditBucket, n)
+	for i := range buckets {
+		buckets[i].Start = q.From.Add(time.Duration(i) * interval)
+	}
+	for _, v := range timestamps {
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			log.Printf("[WARN] failed to parse audit timestamp %q: %v", v, err)
+			continue
+		}
+		if i := int(ts.Sub(q.From) / interval); i >= 0 && i < n {
+			buckets[i].Count++
+		}
+	}
+	return buckets, nil
+}
+
+// AuditKeyTimelines counts audit entries matching the filters per key in the intervals of AuditTimeline,
+// e.g. the writes of each listed key per day. Keys without entries are not included, counts are ordered
+// by interval starting at q.From. Limit and Offset are ignored.
+func (s *Store) AuditKeyTimelines(ctx context.Context, q AuditQuery, interval time.Duration) (map[string][]int, error) {
+	n, err := timelineIntervals(q, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	whereClause, args := auditWhere(q)
+	var rows []struct {
+		Key       string `db:"key"`
+		Timestamp string `db:"timestamp"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery("SELECT key, timestamp FROM audit_log"+whereClause), args...); err != nil {
+		return nil, fmt.Errorf("failed to query audit key timelines: %w", err)
+	}
+
+	res := make(map[string][]int)
+	for _, r := range rows {
+		ts, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			log.Printf("[WARN] failed to parse audit timestamp %q: %v", r.Timestamp, err)
+			continue
+		}
+		i := int(ts.Sub(q.From) / interval)
+		if i < 0 || i >= n {
+			continue
+		}
+		if res[r.Key] == nil {
+			res[r.Key] = make([]int, n)
+		}
+		res[r.Key][i]++
+	}
+	return res, nil
+}
+
+// timelineIntervals returns the number of intervals from q.From up to q.To or now, limited to maxAuditBuckets.
+func timelineIntervals(q AuditQuery, interval time.Duration) (int, error) {
+	if q.From.IsZero() || interval <= 0 {
+		return 0, fmt.Errorf("invalid audit timeline range: from=%v, interval=%v", q.From, interval)
+	}
+	to := q.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	n := int(to.Sub(q.From)/interval) + 1
+	if n <= 0 || n > maxAuditBuckets {
+		return 0, fmt.Errorf("invalid audit timeline range: %d intervals, max %d", n, maxAuditBuckets)
+	}
+	return n, nil
+}
+
+// auditWhere builds the WHERE clause (with leading space, or empty) and its arguments for the query filters.
+// Limit and Offset are not used.
+func auditWhere(q AuditQuery) (string, []any) {
 	var conditions []string
 	var args []any
 
@@ -87,6 +337,13 @@ func (s *Store) QueryAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, int
 		}
 	}
 
+	if len(q.Keys) > 0 {
+		conditions = append(conditions, "key IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(q.Keys)), ", ")+")")
+		for _, k := range q.Keys {
+			args = append(args, k)
+		}
+	}
+
 	if q.Actor != "" {
 		conditions = append(conditions, "actor = ?")
 		args = append(args, q.Actor)
@@ -102,6 +359,13 @@ func (s *Store) QueryAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, int
 		args = append(args, q.Action.String())
 	}
 
+	if len(q.Actions) > 0 {
+		conditions = append(conditions, "action IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(q.Actions)), ", ")+")")
+		for _, a := range q.Actions {
+			args = append(args, a.String())
+		}
+	}
+
 	if q.Result.String() != "" && q.Result != (enum.AuditResult{}) {
 		conditions = append(conditions, "result = ?")
 		args = append(args, q.Result.String())
@@ -117,48 +381,10 @@ func (s *Store) QueryAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, int
 		args = append(args, q.To.Format(time.RFC3339))
 	}
 
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = " WHERE " + strings.Join(conditions, " AND ")
-	}
-
-	// get total count first
-	countQuery := s.adoptQuery("SELECT COUNT(*) FROM audit_log" + whereClause)
-	var total int
-	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
-		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
-	}
-
-	// get entries with limit and offset
-	limit := q.Limit
-	if limit <= 0 {
-		limit = 10000 // default limit
-	}
-
-	selectQuery := s.adoptQuery("SELECT id, timestamp, action, key, actor, actor_type, result, ip," +
-		" user_agent, value_size, request_id FROM audit_log" + whereClause + " ORDER BY timestamp DESC LIMIT ? OFFSET ?")
-	args = append(args, limit, q.Offset)
-
-	rows, err := s.db.QueryxContext(ctx, selectQuery, args...)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to query audit entries: %w", err)
-	}
-	defer rows.Close()
-
-	var entries []AuditEntry
-	for rows.Next() {
-		var e auditRow
-		if err := rows.StructScan(&e); err != nil {
-			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
-		}
-		entries = append(entries, e.toAuditEntry())
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating audit rows: %w", err)
+	if len(conditions) == 0 {
+		return "", args
 	}
-
-	return entries, total, nil
+	return " WHERE " + strings.Join(conditions, " AND "), args
 }
 
 // auditRow is used for scanning audit log rows from the database.
@@ -174,6 +400,7 @@ type auditRow struct {
 	UserAgent *string `db:"user_agent"`
 	ValueSize *int    `db:"value_size"`
 	RequestID *string `db:"request_id"`
+	Reason    *string `db:"reason"`
 }
 
 // toAuditEntry converts the database row to an AuditEntry.
@@ -215,6 +442,9 @@ func (r auditRow) toAuditEntry() AuditEntry {
 	if r.RequestID != nil {
 		e.RequestID = *r.RequestID
 	}
+	if r.Reason != nil {
+		e.Reason = *r.Reason
+	}
 
 	return e
 }
@@ -240,3 +470,21 @@ func (s *Store) DeleteAuditOlderThan(ctx context.Context, olderThan time.Time) (
 	}
 	return count, nil
 }
+
+// DeleteAudit removes audit entries matching the filters, Limit and Offset are not used.
+// Returns the number of deleted entries.
+func (s *Store) DeleteAudit(ctx context.Context, q AuditQuery) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	whereClause, args := auditWhere(q)
+	result, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM audit_log"+whereClause), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit entries: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return count, nil
+}
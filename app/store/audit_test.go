@@ -24,7 +24,7 @@ func TestStore_AuditLog(t *testing.T) {
 		entries := []AuditEntry{
 			{Timestamp: now.Add(-2 * time.Hour), Action: enum.AuditActionRead, Key: "app/config", Actor: "admin", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess, IP: "192.168.1.1", UserAgent: "test/1.0"},
 			{Timestamp: now.Add(-1 * time.Hour), Action: enum.AuditActionUpdate, Key: "app/config", Actor: "admin", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess, ValueSize: intPtr(100)},
-			{Timestamp: now, Action: enum.AuditActionRead, Key: "db/password", Actor: "token:abcd", ActorType: enum.ActorTypeToken, Result: enum.AuditResultDenied},
+			{Timestamp: now, Action: enum.AuditActionRead, Key: "db/password", Actor: "token:abcd", ActorType: enum.ActorTypeToken, Result: enum.AuditResultDenied, Reason: "INC-42 db outage"},
 		}
 
 		for _, e := range entries {
@@ -37,6 +37,8 @@ func TestStore_AuditLog(t *testing.T) {
 		assert.Equal(t, 3, total)
 		assert.Len(t, results, 3)
 		assert.Equal(t, "db/password", results[0].Key, "newest first")
+		assert.Equal(t, "INC-42 db outage", results[0].Reason)
+		assert.Empty(t, results[1].Reason)
 
 		// query by key prefix
 		results, total, err = st.QueryAudit(ctx, AuditQuery{Key: "app/*"})
@@ -93,6 +95,35 @@ func TestStore_AuditLog(t *testing.T) {
 		assert.Len(t, results, 2)
 	})
 
+	t.Run("delete by query", func(t *testing.T) {
+		st, err := New(":memory:")
+		require.NoError(t, err)
+		defer st.Close()
+
+		now := time.Now()
+		for _, e := range []AuditEntry{
+			{Timestamp: now, Action: enum.AuditActionRead, Key: "app/a", Actor: "token:old1", ActorType: enum.ActorTypeToken, Result: enum.AuditResultSuccess},
+			{Timestamp: now, Action: enum.AuditActionUpdate, Key: "app/b", Actor: "token:old1", ActorType: enum.ActorTypeToken, Result: enum.AuditResultSuccess},
+			{Timestamp: now, Action: enum.AuditActionRead, Key: "db/x", Actor: "token:old1", ActorType: enum.ActorTypeToken, Result: enum.AuditResultSuccess},
+			{Timestamp: now, Action: enum.AuditActionRead, Key: "app/a", Actor: "admin", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess},
+		} {
+			require.NoError(t, st.LogAudit(ctx, e))
+		}
+
+		deleted, err := st.DeleteAudit(ctx, AuditQuery{Actor: "token:old1", Key: "app/*", Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted, "limit is not used")
+
+		results, total, err := st.QueryAudit(ctx, AuditQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.ElementsMatch(t, []string{"db/x", "app/a"}, []string{results[0].Key, results[1].Key})
+
+		deleted, err = st.DeleteAudit(ctx, AuditQuery{Actor: "nobody"})
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+	})
+
 	t.Run("offset pagination", func(t *testing.T) {
 		st, err := New(":memory:")
 		require.NoError(t, err)
@@ -163,6 +194,125 @@ func TestStore_AuditLog(t *testing.T) {
 	})
 }
 
+func TestStore_AuditAggregates(t *testing.T) {
+	ctx := context.Background()
+	st, err := New(":memory:")
+	require.NoError(t, err)
+	defer st.Close()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	entry := func(offset time.Duration, action enum.AuditAction, key, actor string) AuditEntry {
+		return AuditEntry{Timestamp: base.Add(offset), Action: action, Key: key, Actor: actor,
+			ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess}
+	}
+	for _, e := range []AuditEntry{
+		entry(0, enum.AuditActionCreate, "app/a", "alice"),
+		entry(10*time.Minute, enum.AuditActionUpdate, "app/a", "alice"),
+		entry(70*time.Minute, enum.AuditActionDelete, "app/b", "bob"),
+		entry(80*time.Minute, enum.AuditActionRead, "app/a", "bob"),
+		entry(90*time.Minute, enum.AuditActionRead, "db/host", "bob"),
+		entry(3*time.Hour, enum.AuditActionRead, "db/host", "carol"),
+	} {
+		require.NoError(t, st.LogAudit(ctx, e))
+	}
+	writes := []enum.AuditAction{enum.AuditActionCreate, enum.AuditActionUpdate, enum.AuditActionDelete}
+
+	t.Run("count by actor", func(t *testing.T) {
+		counts, err := st.CountAudit(ctx, AuditQuery{}, AuditGroupActor)
+		require.NoError(t, err)
+		assert.Equal(t, []AuditCount{{Value: "bob", Count: 3}, {Value: "alice", Count: 2}, {Value: "carol", Count: 1}}, counts)
+
+		counts, err = st.CountAudit(ctx, AuditQuery{Actions: writes}, AuditGroupActor)
+		require.NoError(t, err)
+		assert.Equal(t, []AuditCount{{Value: "alice", Count: 2}, {Value: "bob", Count: 1}}, counts)
+	})
+
+	t.Run("count by key with filters and limit", func(t *testing.T) {
+		counts, err := st.CountAudit(ctx, AuditQuery{Action: enum.AuditActionRead, Limit: 1}, AuditGroupKey)
+		require.NoError(t, err)
+		assert.Equal(t, []AuditCount{{Value: "db/host", Count: 2}}, counts)
+
+		counts, err = st.CountAudit(ctx, AuditQuery{From: base.Add(time.Hour)}, AuditGroupAction)
+		require.NoError(t, err)
+		assert.Equal(t, []AuditCount{{Value: "read", Count: 3}, {Value: "delete", Count: 1}}, counts)
+	})
+
+	t.Run("invalid group", func(t *testing.T) {
+		_, err := st.CountAudit(ctx, AuditQuery{}, "ip; DROP TABLE audit_log")
+		require.Error(t, err)
+	})
+
+	t.Run("recent keys", func(t *testing.T) {
+		for _, k := range []string{"app/a", "db/host"} {
+			_, err := st.Set(ctx, k, []byte("v"), "text")
+			require.NoError(t, err)
+		}
+		recent, err := st.RecentAuditKeys(ctx, AuditQuery{Actor: "bob"})
+		require.NoError(t, err)
+		assert.Equal(t, []AuditRecentKey{{Key: "db/host", Last: base.Add(90 * time.Minute)},
+			{Key: "app/a", Last: base.Add(80 * time.Minute)}}, recent, "deleted app/b skipped")
+
+		recent, err = st.RecentAuditKeys(ctx, AuditQuery{Actor: "alice", Actions: writes})
+		require.NoError(t, err)
+		assert.Equal(t, []AuditRecentKey{{Key: "app/a", Last: base.Add(10 * time.Minute)}}, recent, "each key once, latest time")
+
+		recent, err = st.RecentAuditKeys(ctx, AuditQuery{Action: enum.AuditActionRead, Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []AuditRecentKey{{Key: "db/host", Last: base.Add(3 * time.Hour)}}, recent)
+	})
+
+	t.Run("last entries", func(t *testing.T) {
+		last, err := st.LastAuditEntries(ctx, AuditQuery{Key: "app/*", Actions: writes, Result: enum.AuditResultSuccess})
+		require.NoError(t, err)
+		require.Len(t, last, 2)
+		assert.Equal(t, "app/a", last[0].Key)
+		assert.Equal(t, enum.AuditActionUpdate, last[0].Action)
+		assert.Equal(t, base.Add(10*time.Minute), last[0].Timestamp.UTC())
+		assert.Equal(t, "app/b", last[1].Key, "deleted keys are kept")
+		assert.Equal(t, "bob", last[1].Actor)
+
+		last, err = st.LastAuditEntries(ctx, AuditQuery{Key: "db/*", Actions: writes})
+		require.NoError(t, err)
+		assert.Empty(t, last)
+	})
+
+	t.Run("timeline", func(t *testing.T) {
+		buckets, err := st.AuditTimeline(ctx, AuditQuery{From: base, To: base.Add(3 * time.Hour), Actions: writes}, time.Hour)
+		require.NoError(t, err)
+		require.Len(t, buckets, 4)
+		assert.Equal(t, base, buckets[0].Start)
+		assert.Equal(t, base.Add(3*time.Hour), buckets[3].Start)
+		assert.Equal(t, []int{2, 1, 0, 0}, []int{buckets[0].Count, buckets[1].Count, buckets[2].Count, buckets[3].Count})
+
+		buckets, err = st.AuditTimeline(ctx, AuditQuery{From: base, To: base.Add(3 * time.Hour)}, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 1, buckets[3].Count)
+	})
+
+	t.Run("key timelines", func(t *testing.T) {
+		timelines, err := st.AuditKeyTimelines(ctx, AuditQuery{From: base, To: base.Add(3 * time.Hour),
+			Keys: []string{"app/a", "db/host", "missing"}}, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]int{"app/a": {2, 1, 0, 0}, "db/host": {0, 1, 0, 1}}, timelines)
+
+		timelines, err = st.AuditKeyTimelines(ctx, AuditQuery{From: base, To: base.Add(2 * time.Hour), Actions: writes}, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]int{"app/a": {2, 0, 0}, "app/b": {0, 1, 0}}, timelines)
+
+		_, err = st.AuditKeyTimelines(ctx, AuditQuery{}, time.Hour)
+		require.Error(t, err)
+	})
+
+	t.Run("timeline invalid range", func(t *testing.T) {
+		_, err := st.AuditTimeline(ctx, AuditQuery{}, time.Hour)
+		require.Error(t, err)
+		_, err = st.AuditTimeline(ctx, AuditQuery{From: base}, 0)
+		require.Error(t, err)
+		_, err = st.AuditTimeline(ctx, AuditQuery{From: base, To: base.Add(2000 * time.Hour)}, time.Hour)
+		require.Error(t, err)
+	})
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/go-pkgz/lcw/v2"
@@ -14,6 +15,17 @@ import (
 type cacheEntry struct {
 	value  []byte
 	format string
+	loaded time.Time // when the entry was read from the store
+}
+
+// loadedAfterKey is the context key of the time set by WithLoadedAfter.
+type loadedAfterKey struct{}
+
+// WithLoadedAfter returns a context for reads which don't use cache entries loaded before the time, but
+// read the store again. Used for read-after-write consistency with other instances sharing the database,
+// which don't invalidate this instance's cache on their writes.
+func WithLoadedAfter(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, loadedAfterKey{}, t)
 }
 
 // Cached wraps a store Interface with a loading cache and satisfies the Interface itself.
@@ -45,15 +57,9 @@ func (c *Cached) Get(ctx context.Context, key string) ([]byte, error) {
 		return val, nil
 	}
 
-	entry, err := c.cache.Get(key, func() (cacheEntry, error) {
-		val, format, loadErr := c.store.GetWithFormat(ctx, key)
-		if loadErr != nil {
-			return cacheEntry{}, fmt.Errorf("load from store: %w", loadErr)
-		}
-		return cacheEntry{value: val, format: format}, nil
-	})
+	entry, err := c.load(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("cache get: %w", err)
+		return nil, err
 	}
 	return entry.value, nil
 }
@@ -70,17 +76,33 @@ func (c *Cached) GetWithFormat(ctx context.Context, key string) ([]byte, string,
 		return val, format, nil
 	}
 
+	entry, err := c.load(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry.value, entry.format, nil
+}
+
+// load returns the cache entry of the key, loading it from the store if missing, or if the context
+// requires an entry loaded later than the cached one.
+func (c *Cached) load(ctx context.Context, key string) (cacheEntry, error) {
+	if after, ok := ctx.Value(loadedAfterKey{}).(time.Time); ok {
+		if entry, found := c.cache.Peek(key); found && entry.loaded.Before(after) {
+			c.cache.Delete(key)
+		}
+	}
 	entry, err := c.cache.Get(key, func() (cacheEntry, error) {
+		loaded := time.Now()
 		val, format, loadErr := c.store.GetWithFormat(ctx, key)
 		if loadErr != nil {
 			return cacheEntry{}, fmt.Errorf("load from store: %w", loadErr)
 		}
-		return cacheEntry{value: val, format: format}, nil
+		return cacheEntry{value: val, format: format, loaded: loaded}, nil
 	})
 	if err != nil {
-		return nil, "", fmt.Errorf("cache get: %w", err)
+		return cacheEntry{}, fmt.Errorf("cache get: %w", err)
 	}
-	return entry.value, entry.format, nil
+	return entry, nil
 }
 
 // Set stores a value and invalidates the cache entry.
@@ -94,6 +116,16 @@ func (c *Cached) Set(ctx context.Context, key string, value []byte, format strin
 	return created, nil
 }
 
+// SetWithOptions stores a value with its options and invalidates the cache entry.
+func (c *Cached) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts SetOptions) (created bool, err error) {
+	created, err = c.store.SetWithOptions(ctx, key, value, format, opts)
+	if err != nil {
+		return false, fmt.Errorf("store set: %w", err)
+	}
+	c.cache.Invalidate(func(k string) bool { return k == key })
+	return created, nil
+}
+
 // SetWithVersion stores a value with version check and invalidates the cache entry on success.
 func (c *Cached) SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error {
 	if err := c.store.SetWithVersion(ctx, key, value, format, expectedVersion); err != nil {
@@ -114,6 +146,91 @@ func (c *Cached) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Txn applies the operations atomically in the underlying store and invalidates cache entries of their keys.
+func (c *Cached) Txn(ctx context.Context, ops []TxnOp) ([]TxnResult, error) {
+	// invalidate regardless of error - keys might have been cached
+	c.cache.Invalidate(func(k string) bool {
+		return slices.ContainsFunc(ops, func(op TxnOp) bool { return op.Key == k })
+	})
+	res, err := c.store.Txn(ctx, ops)
+	if err != nil {
+		// don't wrap - let caller check error type directly (TxnConflictError, ErrSecretsNotConfigured, etc.)
+		return nil, err //nolint:wrapcheck // intentionally pass through for error type checks
+	}
+	return res, nil
+}
+
+// SetMeta sets the description and labels of a key in the underlying store, metadata is not cached.
+func (c *Cached) SetMeta(ctx context.Context, key string, meta KeyMeta) error {
+	if err := c.store.SetMeta(ctx, key, meta); err != nil {
+		return fmt.Errorf("store set meta: %w", err)
+	}
+	return nil
+}
+
+// SetVariants stores the variants spec of a key in the underlying store, variants are not cached.
+func (c *Cached) SetVariants(ctx context.Context, key, spec string) error {
+	if err := c.store.SetVariants(ctx, key, spec); err != nil {
+		return fmt.Errorf("store set variants: %w", err)
+	}
+	return nil
+}
+
+// GetVariants returns the variants spec of a key from the underlying store (not cached).
+func (c *Cached) GetVariants(ctx context.Context, key string) (string, error) {
+	spec, err := c.store.GetVariants(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("store get variants: %w", err)
+	}
+	return spec, nil
+}
+
+// DeleteExpired deletes expired keys in the underlying store and invalidates their cache entries.
+// Cached values of expired keys are served until then.
+func (c *Cached) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	keys, err := c.store.DeleteExpired(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("store delete expired: %w", err)
+	}
+	for _, key := range keys {
+		c.cache.Invalidate(func(k string) bool { return k == key })
+	}
+	return keys, nil
+}
+
+// GetHistory returns previous values of a key from the underlying store, the history is not cached.
+func (c *Cached) GetHistory(ctx context.Context, key string, limit int) ([]Revision, error) {
+	revs, err := c.store.GetHistory(ctx, key, limit)
+	if err != nil {
+		return nil, fmt.Errorf("store get history: %w", err)
+	}
+	return revs, nil
+}
+
+// GetVersion returns a previous value of a key from the underlying store (not cached).
+func (c *Cached) GetVersion(ctx context.Context, key string, version int64) (Revision, error) {
+	rev, err := c.store.GetVersion(ctx, key, version)
+	if err != nil {
+		return Revision{}, fmt.Errorf("store get version: %w", err)
+	}
+	return rev, nil
+}
+
+// Rollback sets a key to a previous value and invalidates the cache entry.
+func (c *Cached) Rollback(ctx context.Context, key string, version int64, owner string) (rev Revision, created bool, err error) {
+	rev, created, err = c.store.Rollback(ctx, key, version, owner)
+	if err != nil {
+		return Revision{}, false, fmt.Errorf("store rollback: %w", err)
+	}
+	c.cache.Invalidate(func(k string) bool { return k == key })
+	return rev, created, nil
+}
+
+// HistoryEnabled returns whether the underlying store keeps previous values.
+func (c *Cached) HistoryEnabled() bool {
+	return c.store.HistoryEnabled()
+}
+
 // GetInfo retrieves metadata for a key from the underlying store (not cached).
 func (c *Cached) GetInfo(ctx context.Context, key string) (KeyInfo, error) {
 	info, err := c.store.GetInfo(ctx, key)
@@ -132,6 +249,15 @@ func (c *Cached) List(ctx context.Context, filter enum.SecretsFilter) ([]KeyInfo
 	return keys, nil
 }
 
+// ListPage returns a page of keys from the underlying store (not cached).
+func (c *Cached) ListPage(ctx context.Context, q ListQuery) ([]KeyInfo, int, error) {
+	keys, total, err := c.store.ListPage(ctx, q)
+	if err != nil {
+		return nil, 0, fmt.Errorf("store list page: %w", err)
+	}
+	return keys, total, nil
+}
+
 // SecretsEnabled returns whether secrets encryption is enabled in the underlying store.
 func (c *Cached) SecretsEnabled() bool {
 	return c.store.SecretsEnabled()
@@ -75,6 +75,13 @@ func TestCached_GetWithFormat(t *testing.T) {
 		// should have 2 misses (initial load + after invalidation)
 		stats := cached.Stats()
 		assert.Equal(t, int64(2), stats.Misses)
+
+		// set with options invalidates too
+		_, err = cached.SetWithOptions(t.Context(), "key1", []byte("with ttl"), "text", SetOptions{ExpiresAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		val, _, err = cached.GetWithFormat(t.Context(), "key1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("with ttl"), val)
 	})
 
 	t.Run("invalidates cache on Delete", func(t *testing.T) {
@@ -114,6 +121,84 @@ func TestCached_GetWithFormat(t *testing.T) {
 	})
 }
 
+func TestCached_DeleteExpired(t *testing.T) {
+	underlying, err := New(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	defer underlying.Close()
+	cached, err := NewCached(underlying, 100)
+	require.NoError(t, err)
+
+	_, err = cached.SetWithOptions(t.Context(), "flags/override", []byte("on"), "text", SetOptions{ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	val, err := cached.Get(t.Context(), "flags/override")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("on"), val)
+
+	keys, err := cached.DeleteExpired(t.Context(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"flags/override"}, keys)
+	_, err = cached.Get(t.Context(), "flags/override")
+	require.ErrorIs(t, err, ErrNotFound, "cache entry invalidated")
+}
+
+func TestCached_Txn(t *testing.T) {
+	underlying, err := New(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	defer underlying.Close()
+	cached, err := NewCached(underlying, 100)
+	require.NoError(t, err)
+
+	for _, key := range []string{"app/a", "app/b"} {
+		_, err = cached.Set(t.Context(), key, []byte("old"), "text")
+		require.NoError(t, err)
+		_, err = cached.Get(t.Context(), key) // populate cache
+		require.NoError(t, err)
+	}
+
+	res, err := cached.Txn(t.Context(), []TxnOp{{Key: "app/a", Value: []byte("new")}, {Key: "app/b", Delete: true}})
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+	val, err := cached.Get(t.Context(), "app/a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), val, "cache entry invalidated")
+	_, err = cached.Get(t.Context(), "app/b")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = cached.Txn(t.Context(), []TxnOp{{Key: "app/a", Value: []byte("newer")}, {Key: "app/b", Delete: true}})
+	var conflict *TxnConflictError
+	require.ErrorAs(t, err, &conflict, "conflict passed through unwrapped")
+	assert.Equal(t, "app/b", conflict.Key)
+}
+
+func TestCached_Rollback(t *testing.T) {
+	underlying, err := New(t.TempDir()+"/test.db", WithHistory(5))
+	require.NoError(t, err)
+	defer underlying.Close()
+	cached, err := NewCached(underlying, 100)
+	require.NoError(t, err)
+	assert.True(t, cached.HistoryEnabled())
+
+	for _, v := range []string{"v1", "v2"} {
+		_, err = cached.Set(t.Context(), "app/mode", []byte(v), "text")
+		require.NoError(t, err)
+	}
+	val, err := cached.Get(t.Context(), "app/mode")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), val)
+
+	revs, err := cached.GetHistory(t.Context(), "app/mode", 0)
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	_, _, err = cached.Rollback(t.Context(), "app/mode", revs[0].Version, "")
+	require.NoError(t, err)
+	val, err = cached.Get(t.Context(), "app/mode")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1"), val, "cache entry invalidated")
+
+	_, _, err = cached.Rollback(t.Context(), "app/mode", 999, "")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
 func TestCached_Get(t *testing.T) {
 	t.Run("caches and returns value without format", func(t *testing.T) {
 		dbPath := t.TempDir() + "/test.db"
@@ -142,6 +227,40 @@ func TestCached_Get(t *testing.T) {
 	})
 }
 
+func TestCached_WithLoadedAfter(t *testing.T) {
+	underlying, err := New(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	defer underlying.Close()
+	cached, err := NewCached(underlying, 100)
+	require.NoError(t, err)
+
+	_, err = cached.Set(t.Context(), "key1", []byte("value1"), "text")
+	require.NoError(t, err)
+	val, err := cached.Get(t.Context(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value1"), val)
+
+	// another instance sharing the database changes the key, this cache isn't invalidated
+	written := time.Now()
+	_, err = underlying.Set(t.Context(), "key1", []byte("value2"), "text")
+	require.NoError(t, err)
+	val, err = cached.Get(t.Context(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value1"), val, "stale cached value")
+
+	val, _, err = cached.GetWithFormat(WithLoadedAfter(t.Context(), written), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value2"), val, "entry loaded before the write is reloaded")
+	val, err = cached.Get(t.Context(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value2"), val, "reloaded entry is cached")
+
+	misses := cached.Stats().Misses
+	_, err = cached.Get(WithLoadedAfter(t.Context(), written), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, misses, cached.Stats().Misses, "entry loaded after the write is used")
+}
+
 func TestCached_List(t *testing.T) {
 	t.Run("delegates to underlying store", func(t *testing.T) {
 		dbPath := t.TempDir() + "/test.db"
@@ -160,6 +279,12 @@ func TestCached_List(t *testing.T) {
 		keys, err := cached.List(t.Context(), enum.SecretsFilterAll)
 		require.NoError(t, err)
 		assert.Len(t, keys, 2)
+
+		keys, total, err := cached.ListPage(t.Context(), ListQuery{Sort: enum.SortModeKey, Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "key2", keys[0].Key)
 	})
 }
 
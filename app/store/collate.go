@@ -0,0 +1,27 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// sortKey returns the collation key of a key name, stored in the sort_key column. Sort keys compare
+// bytewise in both sqlite and postgres, so "apple", "Banana" and "émile" sort as a reader expects,
+// unlike byte order of the names or ascii-only LOWER().
+// The key is the lower-cased name without accents, then a zero byte and the lower-cased decomposed name,
+// so names differing in accents only are ordered next to each other, while case is ignored entirely.
+func sortKey(key string) []byte {
+	decomposed := strings.ToLower(norm.NFD.String(key))
+	base := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1 // drop combining marks, i.e. accents
+		}
+		return r
+	}, decomposed)
+	res := make([]byte, 0, len(base)+1+len(decomposed))
+	res = append(res, base...)
+	res = append(res, 0)
+	return append(res, decomposed...)
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortKey(t *testing.T) {
+	keys := []string{"zebra", "émile", "Banana", "apple", "Emil", "app/b", "APP/a"}
+	slices.SortFunc(keys, func(a, b string) int { return bytes.Compare(sortKey(a), sortKey(b)) })
+	assert.Equal(t, []string{"APP/a", "app/b", "apple", "Banana", "Emil", "émile", "zebra"}, keys)
+
+	assert.Equal(t, sortKey("Config/DB"), sortKey("config/db"), "case is ignored")
+	assert.NotEqual(t, sortKey("resume"), sortKey("résumé"), "accents are not ignored")
+}
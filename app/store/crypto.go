@@ -28,6 +28,9 @@ var ErrDecryptionFailed = errors.New("decryption failed")
 // ErrSecretsNotConfigured is returned when trying to access secrets without a key configured.
 var ErrSecretsNotConfigured = errors.New("secrets key not configured")
 
+// ErrSealed is returned when secrets are configured but the key is not unsealed yet.
+var ErrSealed = errors.New("secrets sealed")
+
 // IsSecret checks if a key should be treated as a secret based on its path.
 // A key is a secret if it contains "secrets" as a path segment:
 //   - secrets/db/password → true (starts with secrets/)
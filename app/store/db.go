@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,6 +33,12 @@ type Store struct {
 	dbType    DBType
 	mu        RWLocker
 	encryptor Encryptor // for encrypting secrets (nil = secrets disabled)
+	keyring   *Keyring  // optional per-prefix keys layered under the server key
+
+	// encrypts values of regular keys at rest, nil = stored as is
+	dataEncryptor Encryptor
+	// previous values kept per key in kv_history, 0 = history disabled
+	historyLimit int
 }
 
 // Option configures Store behavior.
@@ -44,6 +51,14 @@ func WithEncryptor(enc Encryptor) Option {
 	}
 }
 
+// WithKeyring adds per-prefix encryption keys for secrets. Requires WithEncryptor, prefix keys
+// are applied in addition to the server key, not instead of it.
+func WithKeyring(kr *Keyring) Option {
+	return func(s *Store) {
+		s.keyring = kr
+	}
+}
+
 // SecretsEnabled returns true if the store is configured for secrets encryption.
 func (s *Store) SecretsEnabled() bool {
 	return s.encryptor != nil
@@ -146,9 +161,12 @@ func connectPostgres(dbURL string) (*sqlx.DB, error) {
 	return db, nil
 }
 
-// createSchema creates the kv, sessions, and audit_log tables if they don't exist.
+// createSchema creates the kv, kv_history, sessions, audit_log, user preferences, webhook deliveries and freezes tables
+// if they don't exist.
+// kv indexes match the ORDER BY of each sort mode in listOrder, size uses the engine's length expression
+// as adoptQuery rewrites it for postgres.
 func (s *Store) createSchema() error {
-	var kvSchema, sessionsSchema, auditSchema string
+	var kvSchema, sessionsSchema, auditSchema, prefsSchema, deliveriesSchema, freezesSchema string
 	switch s.dbType {
 	case DBTypePostgres:
 		kvSchema = `
@@ -157,16 +175,77 @@ func (s *Store) createSchema() error {
 				value BYTEA NOT NULL,
 				format TEXT NOT NULL DEFAULT 'text',
 				created_at TIMESTAMP DEFAULT NOW(),
-				updated_at TIMESTAMP DEFAULT NOW()
-			)`
+				updated_at TIMESTAMP DEFAULT NOW(),
+				sort_key BYTEA,
+				owner TEXT,
+				variants TEXT,
+				expires_at TIMESTAMP,
+				encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+				description TEXT,
+				labels TEXT
+			);
+			CREATE INDEX IF NOT EXISTS idx_kv_updated ON kv(updated_at DESC, key);
+			CREATE INDEX IF NOT EXISTS idx_kv_created ON kv(created_at DESC, key);
+			CREATE INDEX IF NOT EXISTS idx_kv_size ON kv(octet_length(value) DESC, key);
+			CREATE TABLE IF NOT EXISTS kv_history (
+				id SERIAL PRIMARY KEY,
+				key TEXT NOT NULL,
+				value BYTEA NOT NULL,
+				format TEXT NOT NULL,
+				updated_at TIMESTAMP,
+				encrypted BOOLEAN NOT NULL DEFAULT FALSE
+			);
+			CREATE INDEX IF NOT EXISTS idx_kv_history_key ON kv_history(key, id)`
 		sessionsSchema = `
 			CREATE TABLE IF NOT EXISTS sessions (
 				token TEXT PRIMARY KEY,
 				username TEXT NOT NULL,
-				expires_at TIMESTAMPTZ NOT NULL
+				created_at TIMESTAMPTZ NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				remember BOOLEAN NOT NULL DEFAULT FALSE,
+				device TEXT NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT ''
 			);
 			CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
-			CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username)`
+			CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username);
+			CREATE TABLE IF NOT EXISTS login_devices (
+				username TEXT NOT NULL,
+				device TEXT NOT NULL,
+				last_seen TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (username, device)
+			);
+			CREATE TABLE IF NOT EXISTS passkeys (
+				id TEXT PRIMARY KEY,
+				username TEXT NOT NULL,
+				name TEXT NOT NULL,
+				public_key BYTEA NOT NULL,
+				sign_count BIGINT NOT NULL DEFAULT 0,
+				created_at TIMESTAMPTZ NOT NULL,
+				last_used_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_passkeys_username ON passkeys(username);
+			CREATE TABLE IF NOT EXISTS token_usage (
+				fingerprint TEXT PRIMARY KEY,
+				first_seen TIMESTAMPTZ NOT NULL,
+				last_used TIMESTAMPTZ,
+				last_ip TEXT NOT NULL DEFAULT ''
+			);
+			CREATE TABLE IF NOT EXISTS request_nonces (
+				nonce TEXT PRIMARY KEY,
+				expires_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
+			CREATE TABLE IF NOT EXISTS delegated_tokens (
+				fingerprint TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				prefix TEXT NOT NULL,
+				created_by TEXT NOT NULL,
+				acl TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				expires_at TIMESTAMPTZ
+			)`
 		auditSchema = `
 			CREATE TABLE IF NOT EXISTS audit_log (
 				id SERIAL PRIMARY KEY,
@@ -179,12 +258,47 @@ func (s *Store) createSchema() error {
 				ip TEXT,
 				user_agent TEXT,
 				value_size INTEGER,
-				request_id TEXT
+				request_id TEXT,
+				reason TEXT
 			);
 			CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp);
 			CREATE INDEX IF NOT EXISTS idx_audit_key ON audit_log(key);
 			CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_log(actor);
 			CREATE INDEX IF NOT EXISTS idx_audit_ts_key ON audit_log(timestamp, key)`
+		prefsSchema = `
+			CREATE TABLE IF NOT EXISTS pinned_keys (
+				username TEXT NOT NULL,
+				key TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (username, key)
+			);
+			CREATE TABLE IF NOT EXISTS saved_searches (
+				username TEXT NOT NULL,
+				name TEXT NOT NULL,
+				query TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (username, name)
+			)`
+		deliveriesSchema = `
+			CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id SERIAL PRIMARY KEY,
+				payload TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt TIMESTAMPTZ NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				dead BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_deliveries_next ON webhook_deliveries(dead, next_attempt)`
+		freezesSchema = `
+			CREATE TABLE IF NOT EXISTS freezes (
+				prefix TEXT PRIMARY KEY,
+				incident TEXT NOT NULL,
+				message TEXT NOT NULL DEFAULT '',
+				frozen_by TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL
+			)`
 	default:
 		kvSchema = `
 			CREATE TABLE IF NOT EXISTS kv (
@@ -192,16 +306,77 @@ func (s *Store) createSchema() error {
 				value BLOB NOT NULL,
 				format TEXT NOT NULL DEFAULT 'text',
 				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-			)`
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				sort_key BLOB,
+				owner TEXT,
+				variants TEXT,
+				expires_at DATETIME,
+				encrypted INTEGER NOT NULL DEFAULT 0,
+				description TEXT,
+				labels TEXT
+			);
+			CREATE INDEX IF NOT EXISTS idx_kv_updated ON kv(updated_at DESC, key);
+			CREATE INDEX IF NOT EXISTS idx_kv_created ON kv(created_at DESC, key);
+			CREATE INDEX IF NOT EXISTS idx_kv_size ON kv(length(value) DESC, key);
+			CREATE TABLE IF NOT EXISTS kv_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				key TEXT NOT NULL,
+				value BLOB NOT NULL,
+				format TEXT NOT NULL,
+				updated_at DATETIME,
+				encrypted INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE INDEX IF NOT EXISTS idx_kv_history_key ON kv_history(key, id)`
 		sessionsSchema = `
 			CREATE TABLE IF NOT EXISTS sessions (
 				token TEXT PRIMARY KEY,
 				username TEXT NOT NULL,
-				expires_at DATETIME NOT NULL
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL,
+				remember INTEGER NOT NULL DEFAULT 0,
+				device TEXT NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT ''
 			);
 			CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
-			CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username)`
+			CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username);
+			CREATE TABLE IF NOT EXISTS login_devices (
+				username TEXT NOT NULL,
+				device TEXT NOT NULL,
+				last_seen DATETIME NOT NULL,
+				PRIMARY KEY (username, device)
+			);
+			CREATE TABLE IF NOT EXISTS passkeys (
+				id TEXT PRIMARY KEY,
+				username TEXT NOT NULL,
+				name TEXT NOT NULL,
+				public_key BLOB NOT NULL,
+				sign_count INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				last_used_at DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_passkeys_username ON passkeys(username);
+			CREATE TABLE IF NOT EXISTS token_usage (
+				fingerprint TEXT PRIMARY KEY,
+				first_seen DATETIME NOT NULL,
+				last_used DATETIME,
+				last_ip TEXT NOT NULL DEFAULT ''
+			);
+			CREATE TABLE IF NOT EXISTS request_nonces (
+				nonce TEXT PRIMARY KEY,
+				expires_at DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
+			CREATE TABLE IF NOT EXISTS delegated_tokens (
+				fingerprint TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				prefix TEXT NOT NULL,
+				created_by TEXT NOT NULL,
+				acl TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME
+			)`
 		auditSchema = `
 			CREATE TABLE IF NOT EXISTS audit_log (
 				id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -214,12 +389,47 @@ func (s *Store) createSchema() error {
 				ip TEXT,
 				user_agent TEXT,
 				value_size INTEGER,
-				request_id TEXT
+				request_id TEXT,
+				reason TEXT
 			);
 			CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp);
 			CREATE INDEX IF NOT EXISTS idx_audit_key ON audit_log(key);
 			CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_log(actor);
 			CREATE INDEX IF NOT EXISTS idx_audit_ts_key ON audit_log(timestamp, key)`
+		prefsSchema = `
+			CREATE TABLE IF NOT EXISTS pinned_keys (
+				username TEXT NOT NULL,
+				key TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (username, key)
+			);
+			CREATE TABLE IF NOT EXISTS saved_searches (
+				username TEXT NOT NULL,
+				name TEXT NOT NULL,
+				query TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (username, name)
+			)`
+		deliveriesSchema = `
+			CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				payload TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt TEXT NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				dead BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TEXT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_deliveries_next ON webhook_deliveries(dead, next_attempt)`
+		freezesSchema = `
+			CREATE TABLE IF NOT EXISTS freezes (
+				prefix TEXT PRIMARY KEY,
+				incident TEXT NOT NULL,
+				message TEXT NOT NULL DEFAULT '',
+				frozen_by TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL
+			)`
 	}
 
 	if _, err := s.db.Exec(kvSchema); err != nil { //nolint:noctx // init-time, no context available
@@ -231,6 +441,15 @@ func (s *Store) createSchema() error {
 	if _, err := s.db.Exec(auditSchema); err != nil { //nolint:noctx // init-time, no context available
 		return fmt.Errorf("failed to create audit_log table: %w", err)
 	}
+	if _, err := s.db.Exec(prefsSchema); err != nil { //nolint:noctx // init-time, no context available
+		return fmt.Errorf("failed to create user preferences tables: %w", err)
+	}
+	if _, err := s.db.Exec(deliveriesSchema); err != nil { //nolint:noctx // init-time, no context available
+		return fmt.Errorf("failed to create webhook_deliveries table: %w", err)
+	}
+	if _, err := s.db.Exec(freezesSchema); err != nil { //nolint:noctx // init-time, no context available
+		return fmt.Errorf("failed to create freezes table: %w", err)
+	}
 	return nil
 }
 
@@ -251,6 +470,197 @@ func (s *Store) migrate() error {
 		}
 	}
 
+	hasSortKey, err := s.hasColumn("kv", "sort_key")
+	if err != nil {
+		return fmt.Errorf("failed to check sort_key column: %w", err)
+	}
+	if !hasSortKey {
+		log.Printf("[INFO] migrating database: adding sort_key column to kv table")
+		alter := "ALTER TABLE kv ADD COLUMN sort_key BLOB"
+		if s.dbType == DBTypePostgres {
+			alter = "ALTER TABLE kv ADD COLUMN sort_key BYTEA"
+		}
+		if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add sort_key column: %w", err)
+		}
+	}
+	if err := s.fillSortKeys(); err != nil {
+		return err
+	}
+
+	hasOwner, err := s.hasColumn("kv", "owner")
+	if err != nil {
+		return fmt.Errorf("failed to check owner column: %w", err)
+	}
+	if !hasOwner {
+		log.Printf("[INFO] migrating database: adding owner column to kv table")
+		if _, err := s.db.Exec("ALTER TABLE kv ADD COLUMN owner TEXT"); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add owner column: %w", err)
+		}
+	}
+
+	hasVariants, err := s.hasColumn("kv", "variants")
+	if err != nil {
+		return fmt.Errorf("failed to check variants column: %w", err)
+	}
+	if !hasVariants {
+		log.Printf("[INFO] migrating database: adding variants column to kv table")
+		if _, err := s.db.Exec("ALTER TABLE kv ADD COLUMN variants TEXT"); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add variants column: %w", err)
+		}
+	}
+
+	hasExpires, err := s.hasColumn("kv", "expires_at")
+	if err != nil {
+		return fmt.Errorf("failed to check expires_at column: %w", err)
+	}
+	if !hasExpires {
+		log.Printf("[INFO] migrating database: adding expires_at column to kv table")
+		alter := "ALTER TABLE kv ADD COLUMN expires_at DATETIME"
+		if s.dbType == DBTypePostgres {
+			alter = "ALTER TABLE kv ADD COLUMN expires_at TIMESTAMP"
+		}
+		if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add expires_at column: %w", err)
+		}
+	}
+
+	for _, col := range []string{"description", "labels"} {
+		has, err := s.hasColumn("kv", col)
+		if err != nil {
+			return fmt.Errorf("failed to check %s column: %w", col, err)
+		}
+		if !has {
+			log.Printf("[INFO] migrating database: adding %s column to kv table", col)
+			if _, err := s.db.Exec("ALTER TABLE kv ADD COLUMN " + col + " TEXT"); err != nil { //nolint:noctx // init-time, no context available
+				return fmt.Errorf("failed to add %s column: %w", col, err)
+			}
+		}
+	}
+
+	// values encrypted at rest are flagged rather than recognized by the value, any plaintext can look like
+	// ciphertext. Rows without the flag are plaintext written before encryption at rest was enabled
+	for _, table := range []string{"kv", "kv_history"} {
+		has, err := s.hasColumn(table, "encrypted")
+		if err != nil {
+			return fmt.Errorf("failed to check %s encrypted column: %w", table, err)
+		}
+		if !has {
+			log.Printf("[INFO] migrating database: adding encrypted column to %s table", table)
+			alter := "ALTER TABLE " + table + " ADD COLUMN encrypted INTEGER NOT NULL DEFAULT 0"
+			if s.dbType == DBTypePostgres {
+				alter = "ALTER TABLE " + table + " ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE"
+			}
+			if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+				return fmt.Errorf("failed to add %s encrypted column: %w", table, err)
+			}
+		}
+	}
+
+	if err := s.migrateSessions(); err != nil {
+		return err
+	}
+	hasReason, err := s.hasColumn("audit_log", "reason")
+	if err != nil {
+		return fmt.Errorf("failed to check audit_log reason column: %w", err)
+	}
+	if !hasReason {
+		log.Printf("[INFO] migrating database: adding reason column to audit_log table")
+		if _, err := s.db.Exec("ALTER TABLE audit_log ADD COLUMN reason TEXT"); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add audit_log reason column: %w", err)
+		}
+	}
+	index := "CREATE INDEX IF NOT EXISTS idx_kv_sort_key ON kv(sort_key, key)"
+	if _, err := s.db.Exec(index); err != nil { //nolint:noctx // init-time, no context available
+		return fmt.Errorf("failed to create sort_key index: %w", err)
+	}
+	index = "CREATE INDEX IF NOT EXISTS idx_kv_expires ON kv(expires_at)"
+	if _, err := s.db.Exec(index); err != nil { //nolint:noctx // init-time, no context available
+		return fmt.Errorf("failed to create expires_at index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateSessions adds the created_at, remember and device columns to sessions. Sessions created before
+// them are treated as created at migration time and remembered, they were long-lived sessions. The device
+// of older sessions is unknown and left empty.
+func (s *Store) migrateSessions() error {
+	hasCreated, err := s.hasColumn("sessions", "created_at")
+	if err != nil {
+		return fmt.Errorf("failed to check sessions created_at column: %w", err)
+	}
+	if !hasCreated {
+		log.Printf("[INFO] migrating database: adding created_at column to sessions table")
+		alter := "ALTER TABLE sessions ADD COLUMN created_at DATETIME"
+		if s.dbType == DBTypePostgres {
+			alter = "ALTER TABLE sessions ADD COLUMN created_at TIMESTAMPTZ"
+		}
+		if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add sessions created_at column: %w", err)
+		}
+		update := s.adoptQuery("UPDATE sessions SET created_at = ? WHERE created_at IS NULL")
+		if _, err := s.db.Exec(update, time.Now().UTC()); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to set sessions created_at: %w", err)
+		}
+	}
+
+	hasRemember, err := s.hasColumn("sessions", "remember")
+	if err != nil {
+		return fmt.Errorf("failed to check sessions remember column: %w", err)
+	}
+	if !hasRemember {
+		log.Printf("[INFO] migrating database: adding remember column to sessions table")
+		alter := "ALTER TABLE sessions ADD COLUMN remember INTEGER NOT NULL DEFAULT 1"
+		if s.dbType == DBTypePostgres {
+			alter = "ALTER TABLE sessions ADD COLUMN remember BOOLEAN NOT NULL DEFAULT TRUE"
+		}
+		if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add sessions remember column: %w", err)
+		}
+	}
+
+	for _, col := range []string{"device", "user_agent", "ip", "location"} {
+		has, err := s.hasColumn("sessions", col)
+		if err != nil {
+			return fmt.Errorf("failed to check sessions %s column: %w", col, err)
+		}
+		if has {
+			continue
+		}
+		log.Printf("[INFO] migrating database: adding %s column to sessions table", col)
+		alter := "ALTER TABLE sessions ADD COLUMN " + col + " TEXT NOT NULL DEFAULT ''"
+		if _, err := s.db.Exec(alter); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to add sessions %s column: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// fillSortKeys sets missing sort keys, for rows written before the sort_key column was added.
+func (s *Store) fillSortKeys() error {
+	var keys []string
+	if err := s.db.Select(&keys, "SELECT key FROM kv WHERE sort_key IS NULL"); err != nil {
+		return fmt.Errorf("failed to find keys without sort key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	log.Printf("[INFO] migrating database: setting sort keys of %d keys", len(keys))
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	query := s.adoptQuery("UPDATE kv SET sort_key = ? WHERE key = ?")
+	for _, key := range keys {
+		if _, err := tx.Exec(query, sortKey(key), key); err != nil { //nolint:noctx // init-time, no context available
+			return fmt.Errorf("failed to set sort key of %q: %w", key, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sort keys: %w", err)
+	}
 	return nil
 }
 
@@ -313,9 +723,12 @@ func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
 		return nil, ErrSecretsNotConfigured
 	}
 
-	var value []byte
-	query := s.adoptQuery("SELECT value FROM kv WHERE key = ?")
-	err := s.db.GetContext(ctx, &value, query, key)
+	var result struct {
+		Value     []byte `db:"value"`
+		Encrypted bool   `db:"encrypted"`
+	}
+	query := s.adoptQuery("SELECT value, encrypted FROM kv WHERE key = ?" + notExpired)
+	err := s.db.GetContext(ctx, &result, query, key, time.Now().UTC())
 	if errors.Is(err, sql.ErrNoRows) {
 		log.Printf("[DEBUG] get key %q: not found", key)
 		return nil, ErrNotFound
@@ -324,13 +737,10 @@ func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to get key %q: %w", key, err)
 	}
 
-	// decrypt if this is a secret (skip if ZK-encrypted - client handles decryption)
-	if IsSecret(key) && !stash.IsZKEncrypted(value) {
-		decrypted, err := s.encryptor.Decrypt(value)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt key %q: %w", key, err)
-		}
-		value = decrypted
+	// decrypt secrets and values encrypted at rest (ZK-encrypted values are decrypted by the client)
+	value, err := s.openValue(key, result.Value, result.Encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key %q: %w", key, err)
 	}
 
 	log.Printf("[DEBUG] get key %q: %d bytes", key, len(value))
@@ -350,11 +760,12 @@ func (s *Store) GetWithFormat(ctx context.Context, key string) ([]byte, string,
 	}
 
 	var result struct {
-		Value  []byte `db:"value"`
-		Format string `db:"format"`
+		Value     []byte `db:"value"`
+		Format    string `db:"format"`
+		Encrypted bool   `db:"encrypted"`
 	}
-	query := s.adoptQuery("SELECT value, format FROM kv WHERE key = ?")
-	err := s.db.GetContext(ctx, &result, query, key)
+	query := s.adoptQuery("SELECT value, format, encrypted FROM kv WHERE key = ?" + notExpired)
+	err := s.db.GetContext(ctx, &result, query, key, time.Now().UTC())
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, "", ErrNotFound
 	}
@@ -362,13 +773,9 @@ func (s *Store) GetWithFormat(ctx context.Context, key string) ([]byte, string,
 		return nil, "", fmt.Errorf("failed to get key %q: %w", key, err)
 	}
 
-	// decrypt if this is a secret (skip if ZK-encrypted - client handles decryption)
-	if IsSecret(key) && !stash.IsZKEncrypted(result.Value) {
-		decrypted, err := s.encryptor.Decrypt(result.Value)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to decrypt key %q: %w", key, err)
-		}
-		result.Value = decrypted
+	// decrypt secrets and values encrypted at rest (ZK-encrypted values are decrypted by the client)
+	if result.Value, err = s.openValue(key, result.Value, result.Encrypted); err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt key %q: %w", key, err)
 	}
 
 	return result.Value, result.Format, nil
@@ -386,13 +793,9 @@ func (s *Store) GetInfo(ctx context.Context, key string) (KeyInfo, error) {
 		return KeyInfo{}, ErrSecretsNotConfigured
 	}
 
-	var result struct {
-		KeyInfo
-		ValuePrefix []byte `db:"value_prefix"`
-	}
-	query := s.adoptQuery(`SELECT key, length(value) as size, format, created_at, updated_at,
-		SUBSTR(value, 1, 5) as value_prefix FROM kv WHERE key = ?`)
-	err := s.db.GetContext(ctx, &result, query, key)
+	var result keyInfoRow
+	query := s.adoptQuery(listSelect + " WHERE key = ?" + notExpired)
+	err := s.db.GetContext(ctx, &result, query, key, time.Now().UTC())
 	if errors.Is(err, sql.ErrNoRows) {
 		return KeyInfo{}, ErrNotFound
 	}
@@ -400,23 +803,50 @@ func (s *Store) GetInfo(ctx context.Context, key string) (KeyInfo, error) {
 		return KeyInfo{}, fmt.Errorf("failed to get info for key %q: %w", key, err)
 	}
 
-	// set secret flag based on key path
-	result.Secret = IsSecret(key)
-	// set ZK encrypted flag based on value prefix
-	result.ZKEncrypted = stash.IsZKEncrypted(result.ValuePrefix)
+	return result.keyInfo(), nil
+}
 
-	return result.KeyInfo, nil
+// SetOptions are the attributes of a key written together with its value by SetWithOptions.
+type SetOptions struct {
+	Owner       string            // identity recorded as the owner of a created key, like "user:alice"; updates keep the owner
+	ExpiresAt   time.Time         // time the key expires at, zero for a key that doesn't expire
+	Labels      map[string]string // labels replacing the key's ones, nil keeps them and empty removes them
+	Description *string           // description replacing the key's one, nil keeps it and empty removes it
 }
 
 // Set stores the value for the given key with the specified format.
 // Creates a new key or updates an existing one.
 // If format is empty, defaults to "text".
 // Returns ErrSecretsNotConfigured if key is a secret path but secrets are not enabled.
+// Returns *FrozenError if the key is under a frozen prefix.
 // Returns (true, nil) if a new key was created, (false, nil) if an existing key was updated.
 func (s *Store) Set(ctx context.Context, key string, value []byte, format string) (created bool, err error) {
+	return s.SetWithOptions(ctx, key, value, format, SetOptions{})
+}
+
+// SetWithOptions stores the value as Set does, with the options written in the same statement,
+// so a value is never seen without its owner, expiration or metadata.
+// Returns ErrInvalidMeta if the labels or description are over the limits.
+func (s *Store) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts SetOptions) (created bool, err error) {
+	var description string
+	if opts.Description != nil {
+		description = *opts.Description
+	}
+	if err = (KeyMeta{Labels: opts.Labels, Description: description}).Validate(); err != nil {
+		return false, err
+	}
+	labels, err := labelsColumn(opts.Labels)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal labels of key %q: %w", key, err)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err = s.checkFrozen(ctx, key); err != nil {
+		return false, err
+	}
+
 	// check if trying to store secret without key configured
 	if IsSecret(key) && !s.SecretsEnabled() {
 		return false, ErrSecretsNotConfigured
@@ -431,22 +861,27 @@ func (s *Store) Set(ctx context.Context, key string, value []byte, format string
 		return false, ErrInvalidZKPayload
 	}
 
-	// encrypt secrets (skip if already ZK-encrypted)
-	storeValue := value
-	if IsSecret(key) && !stash.IsZKEncrypted(value) {
-		var encrypted []byte
-		encrypted, err = s.encryptor.Encrypt(value)
-		if err != nil {
-			return false, fmt.Errorf("failed to encrypt key %q: %w", key, err)
-		}
-		storeValue = encrypted
+	// encrypt secrets and, with encryption at rest, other values (skip if already ZK-encrypted)
+	storeValue, encrypted, err := s.sealValue(key, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to encrypt key %q: %w", key, err)
 	}
 
 	now := time.Now().UTC()
+	var owner any // NULL for keys without owner
+	if opts.Owner != "" {
+		owner = opts.Owner
+	}
+	var expiresAt any // NULL for no expiration
+	if !opts.ExpiresAt.IsZero() {
+		expiresAt = opts.ExpiresAt.UTC()
+	}
 
 	// try insert first
-	insertQuery := s.adoptQuery(`INSERT INTO kv (key, value, format, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`)
-	_, err = s.db.ExecContext(ctx, insertQuery, key, storeValue, format, now, now)
+	insertQuery := s.adoptQuery(`INSERT INTO kv (key, value, format, created_at, updated_at, sort_key, owner, encrypted,
+		expires_at, description, labels) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	_, err = s.db.ExecContext(ctx, insertQuery, key, storeValue, format, now, now, sortKey(key), owner, encrypted, expiresAt,
+		descriptionColumn(description), labels)
 	if err == nil {
 		log.Printf("[DEBUG] created key %q: %d bytes, format=%s", key, len(value), format)
 		return true, nil
@@ -457,15 +892,92 @@ func (s *Store) Set(ctx context.Context, key string, value []byte, format string
 		return false, fmt.Errorf("failed to set key %q: %w", key, err)
 	}
 
-	// update existing key
-	updateQuery := s.adoptQuery(`UPDATE kv SET value = ?, format = ?, updated_at = ? WHERE key = ?`)
-	if _, err = s.db.ExecContext(ctx, updateQuery, storeValue, format, now, key); err != nil {
+	// update existing key, a value set without TTL doesn't expire; metadata not given is kept
+	updateQuery := "UPDATE kv SET value = ?, encrypted = ?, format = ?, updated_at = ?, expires_at = ?"
+	args := []any{storeValue, encrypted, format, now, expiresAt}
+	if opts.Labels != nil {
+		updateQuery += ", labels = ?"
+		args = append(args, labels)
+	}
+	if opts.Description != nil {
+		updateQuery += ", description = ?"
+		args = append(args, descriptionColumn(description))
+	}
+	args = append(args, key)
+	if _, err = s.updateWithHistory(ctx, key, s.adoptQuery(updateQuery+" WHERE key = ?"), args...); err != nil {
 		return false, fmt.Errorf("failed to update key %q: %w", key, err)
 	}
 	log.Printf("[DEBUG] updated key %q: %d bytes, format=%s", key, len(value), format)
 	return false, nil
 }
 
+// SetVariants stores the variants spec of the key, a JSON document served instead of the value to
+// targeted callers; an empty spec removes the variants. Variants are kept when the value changes and
+// removed with the key. Returns ErrNotFound if the key does not exist.
+func (s *Store) SetVariants(ctx context.Context, key, spec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var value any // NULL for no variants
+	if spec != "" {
+		value = spec
+	}
+	result, err := s.db.ExecContext(ctx, s.adoptQuery("UPDATE kv SET variants = ? WHERE key = ?"), value, key)
+	if err != nil {
+		return fmt.Errorf("failed to set variants of key %q: %w", key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetVariants returns the variants spec of the key, empty if it has none.
+// Returns ErrNotFound if the key does not exist.
+func (s *Store) GetVariants(ctx context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var spec string
+	err := s.db.GetContext(ctx, &spec, s.adoptQuery("SELECT COALESCE(variants, '') FROM kv WHERE key = ?"), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get variants of key %q: %w", key, err)
+	}
+	return spec, nil
+}
+
+// unexpired is the condition of reads and lists skipping keys past their expiration but not deleted yet,
+// the current time is its argument.
+const unexpired = "(expires_at IS NULL OR expires_at > ?)"
+
+// notExpired is the unexpired condition appended to the key condition of single key reads.
+const notExpired = " AND " + unexpired
+
+// DeleteExpired deletes keys expired at or before now and returns them. A key is checked and deleted
+// in one statement, so a key set again without TTL in the meantime is kept. Expired values are not
+// archived to the history, a TTL is set for values that shouldn't outlive it.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	query := s.adoptQuery("DELETE FROM kv WHERE expires_at <= ? RETURNING key")
+	if err := s.db.SelectContext(ctx, &keys, query, now.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to delete expired keys: %w", err)
+	}
+	if len(keys) > 0 {
+		log.Printf("[DEBUG] deleted %d expired keys", len(keys))
+	}
+	return keys, nil
+}
+
 // isUniqueViolation checks if error is a unique constraint violation.
 func isUniqueViolation(err error) bool {
 	if err == nil {
@@ -491,7 +1003,8 @@ func isUniqueViolation(err error) bool {
 // SetWithVersion stores the value only if the key's updated_at matches expectedVersion.
 // Returns *ConflictError with current state if the key was modified since expectedVersion.
 // If expectedVersion is zero, behaves like regular Set (no version check).
-// Returns ErrSecretsNotConfigured if key is a secret path but secrets are not enabled.
+// Returns ErrSecretsNotConfigured if key is a secret path but secrets are not enabled,
+// and *FrozenError if the key is under a frozen prefix.
 func (s *Store) SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error {
 	if expectedVersion.IsZero() {
 		_, err := s.Set(ctx, key, value, format)
@@ -506,6 +1019,10 @@ func (s *Store) SetWithVersion(ctx context.Context, key string, value []byte, fo
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkFrozen(ctx, key); err != nil {
+		return err
+	}
+
 	if format == "" {
 		format = "text"
 	}
@@ -515,30 +1032,22 @@ func (s *Store) SetWithVersion(ctx context.Context, key string, value []byte, fo
 		return ErrInvalidZKPayload
 	}
 
-	// encrypt secrets (skip if already ZK-encrypted)
-	storeValue := value
-	if IsSecret(key) && !stash.IsZKEncrypted(value) {
-		encrypted, err := s.encryptor.Encrypt(value)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt key %q: %w", key, err)
-		}
-		storeValue = encrypted
+	// encrypt secrets and, with encryption at rest, other values (skip if already ZK-encrypted)
+	storeValue, encrypted, err := s.sealValue(key, value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key %q: %w", key, err)
 	}
 
 	now := time.Now().UTC()
 
 	// atomic update: only succeeds if version matches
-	query := s.adoptQuery(`UPDATE kv SET value = ?, format = ?, updated_at = ? WHERE key = ? AND updated_at = ?`)
-	result, err := s.db.ExecContext(ctx, query, storeValue, format, now, key, expectedVersion)
+	query := s.adoptQuery(`UPDATE kv SET value = ?, encrypted = ?, format = ?, updated_at = ?, expires_at = NULL
+		WHERE key = ? AND updated_at = ?`)
+	rows, err := s.updateWithHistory(ctx, key, query, storeValue, encrypted, format, now, key, expectedVersion)
 	if err != nil {
 		return fmt.Errorf("failed to update key %q: %w", key, err)
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check affected rows: %w", err)
-	}
-
 	if rows == 0 {
 		// either key doesn't exist or version mismatch - fetch current state
 		return s.buildConflictError(ctx, key, expectedVersion)
@@ -554,8 +1063,9 @@ func (s *Store) buildConflictError(ctx context.Context, key string, attemptedVer
 		Value     []byte    `db:"value"`
 		Format    string    `db:"format"`
 		UpdatedAt time.Time `db:"updated_at"`
+		Encrypted bool      `db:"encrypted"`
 	}
-	query := s.adoptQuery("SELECT value, format, updated_at FROM kv WHERE key = ?")
+	query := s.adoptQuery("SELECT value, format, updated_at, encrypted FROM kv WHERE key = ?")
 	err := s.db.GetContext(ctx, &result, query, key)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrNotFound // key was deleted
@@ -564,13 +1074,13 @@ func (s *Store) buildConflictError(ctx context.Context, key string, attemptedVer
 		return fmt.Errorf("failed to get current state for conflict: %w", err)
 	}
 
-	// decrypt value if this is a secret key (skip if ZK-encrypted)
+	// decrypt secrets and values encrypted at rest (skip if ZK-encrypted)
 	currentValue := result.Value
-	if IsSecret(key) && s.encryptor != nil && !stash.IsZKEncrypted(result.Value) {
-		if decrypted, decErr := s.encryptor.Decrypt(result.Value); decErr == nil {
+	if !IsSecret(key) || s.SecretsEnabled() {
+		if decrypted, decErr := s.openValue(key, result.Value, result.Encrypted); decErr == nil {
 			currentValue = decrypted
 		} else {
-			log.Printf("[WARN] failed to decrypt secret value for conflict on key %q: %v", key, decErr)
+			log.Printf("[WARN] failed to decrypt value for conflict on key %q: %v", key, decErr)
 		}
 	}
 
@@ -585,20 +1095,18 @@ func (s *Store) buildConflictError(ctx context.Context, key string, attemptedVer
 }
 
 // Delete removes the key from the store.
-// Returns ErrNotFound if the key does not exist.
+// Returns ErrNotFound if the key does not exist, and *FrozenError if it is under a frozen prefix.
 func (s *Store) Delete(ctx context.Context, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := s.adoptQuery("DELETE FROM kv WHERE key = ?")
-	result, err := s.db.ExecContext(ctx, query, key)
-	if err != nil {
-		return fmt.Errorf("failed to delete key %q: %w", key, err)
+	if err := s.checkFrozen(ctx, key); err != nil {
+		return err
 	}
 
-	rows, err := result.RowsAffected()
+	rows, err := s.updateWithHistory(ctx, key, s.adoptQuery("DELETE FROM kv WHERE key = ?"), key)
 	if err != nil {
-		return fmt.Errorf("failed to check affected rows: %w", err)
+		return fmt.Errorf("failed to delete key %q: %w", key, err)
 	}
 	if rows == 0 {
 		log.Printf("[DEBUG] delete key %q: not found", key)
@@ -615,40 +1123,388 @@ func (s *Store) List(ctx context.Context, filter enum.SecretsFilter) ([]KeyInfo,
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	type keyWithPrefix struct {
-		KeyInfo
-		ValuePrefix []byte `db:"value_prefix"`
-	}
-	var keys []keyWithPrefix
-	query := s.adoptQuery(`SELECT key, length(value) as size, format, created_at, updated_at,
-		SUBSTR(value, 1, 5) as value_prefix FROM kv ORDER BY updated_at DESC`)
-	if err := s.db.SelectContext(ctx, &keys, query); err != nil {
+	var keys []keyInfoRow
+	query := s.adoptQuery(listSelect + " WHERE " + unexpired + " ORDER BY updated_at DESC")
+	if err := s.db.SelectContext(ctx, &keys, query, time.Now().UTC()); err != nil {
 		return nil, fmt.Errorf("failed to list keys: %w", err)
 	}
 
 	// set secret/ZK flags and filter if needed
 	result := make([]KeyInfo, 0, len(keys))
-	for i := range keys {
-		keys[i].Secret = IsSecret(keys[i].Key)
-		keys[i].ZKEncrypted = stash.IsZKEncrypted(keys[i].ValuePrefix)
-
+	for _, row := range keys {
+		info := row.keyInfo()
 		switch filter {
 		case enum.SecretsFilterSecretsOnly:
-			if !keys[i].Secret {
+			if !info.Secret {
 				continue // want secrets only, this is not a secret
 			}
 		case enum.SecretsFilterKeysOnly:
-			if keys[i].Secret {
+			if info.Secret {
 				continue // want non-secrets only, this is a secret
 			}
 		}
-		result = append(result, keys[i].KeyInfo)
+		result = append(result, info)
 	}
 
 	log.Printf("[DEBUG] list keys: %d keys (filter=%s)", len(result), filter)
 	return result, nil
 }
 
+// listBatch is the number of key names passed to ListQuery.Allow at once, and the max number of keys
+// loaded per query by key names.
+const listBatch = 500
+
+// ListPage returns a page of keys and the total number of matching keys. Secrets filter, search, sorting
+// and paging run in SQL, so only the page is loaded. With q.Allow, key names are streamed in sort order
+// through it instead, and only the allowed keys of the page are loaded.
+func (s *Store) ListPage(ctx context.Context, q ListQuery) ([]KeyInfo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	where, args := listConditions(q)
+	if q.Allow != nil {
+		names, total, err := s.allowedKeyNames(ctx, q, where, args)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys, err := s.keyInfosByName(ctx, names)
+		if err != nil {
+			return nil, 0, err
+		}
+		return keys, total, nil
+	}
+
+	var total int
+	if err := s.db.GetContext(ctx, &total, s.adoptQuery("SELECT COUNT(*) FROM kv"+where), args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count keys: %w", err)
+	}
+	query := listSelect + where + listOrder(q.Sort)
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	keys, err := s.selectKeyInfos(ctx, s.adoptQuery(query), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return keys, total, nil
+}
+
+// listSelect selects key metadata, value_prefix is for ZK detection.
+const listSelect = `SELECT key, length(value) as size, format, created_at, updated_at, expires_at,
+	COALESCE(owner, '') as owner, COALESCE(description, '') as description, COALESCE(labels, '') as labels,
+	SUBSTR(value, 1, 5) as value_prefix FROM kv`
+
+// keyInfoRow is a row of listSelect.
+type keyInfoRow struct {
+	KeyInfo
+	LabelsJSON  string `db:"labels"`
+	ValuePrefix []byte `db:"value_prefix"`
+}
+
+// keyInfo returns the key metadata of the row with secret and ZK flags and decoded labels.
+func (r keyInfoRow) keyInfo() KeyInfo {
+	info := r.KeyInfo
+	info.Secret = IsSecret(r.Key)
+	info.ZKEncrypted = stash.IsZKEncrypted(r.ValuePrefix)
+	info.Labels = decodeLabels(r.LabelsJSON)
+	return info
+}
+
+// listConditions returns the WHERE clause for the secrets filter, search and optional conditions of the query.
+// A key is secret if it has "secrets" as a path segment, same as IsSecret. Expired keys not deleted yet are skipped.
+func listConditions(q ListQuery) (where string, args []any) {
+	const secret = "instr('/' || key || '/', '/secrets/') > 0"
+	conds, args := []string{unexpired}, []any{time.Now().UTC()}
+	switch q.Filter {
+	case enum.SecretsFilterSecretsOnly:
+		conds = append(conds, secret)
+	case enum.SecretsFilterKeysOnly:
+		conds = append(conds, "NOT ("+secret+")")
+	}
+	if q.Search != "" {
+		conds = append(conds, "instr(LOWER(key), ?) > 0")
+		args = append(args, strings.ToLower(q.Search))
+	}
+	if q.Prefix != "" {
+		conds = append(conds, "instr(key, ?) = 1") // no LIKE, it ignores case in sqlite and needs escaping
+		args = append(args, q.Prefix)
+	}
+	if q.Format != "" {
+		conds = append(conds, "format = ?")
+		args = append(args, q.Format)
+	}
+	if !q.UpdatedAfter.IsZero() {
+		conds = append(conds, "updated_at >= ?")
+		args = append(args, q.UpdatedAfter.UTC())
+	}
+	if !q.UpdatedBefore.IsZero() {
+		conds = append(conds, "updated_at < ?")
+		args = append(args, q.UpdatedBefore.UTC())
+	}
+	if q.MinSize > 0 {
+		conds = append(conds, "length(value) >= ?")
+		args = append(args, q.MinSize)
+	}
+	if q.SizeBelow > 0 {
+		conds = append(conds, "length(value) < ?")
+		args = append(args, q.SizeBelow)
+	}
+	for name, value := range q.Labels {
+		conds = append(conds, "instr(labels, ?) > 0")
+		args = append(args, labelFragment(name, value))
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// listOrder returns the ORDER BY clause for the sort mode, each backed by a kv index. Key order follows
+// the collation sort key. Ties are ordered by key, so pages don't overlap.
+func listOrder(mode enum.SortMode) string {
+	switch mode {
+	case enum.SortModeKey:
+		return " ORDER BY sort_key, key"
+	case enum.SortModeSize:
+		return " ORDER BY length(value) DESC, key"
+	case enum.SortModeCreated:
+		return " ORDER BY created_at DESC, key"
+	default:
+		return " ORDER BY updated_at DESC, key"
+	}
+}
+
+// allowedKeyNames streams matching key names in sort order through q.Allow and returns the allowed
+// names of the requested page with the total number of allowed keys.
+func (s *Store) allowedKeyNames(ctx context.Context, q ListQuery, where string, args []any) ([]string, int, error) {
+	rows, err := s.db.QueryContext(ctx, s.adoptQuery("SELECT key FROM kv"+where+listOrder(q.Sort)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list key names: %w", err)
+	}
+	defer rows.Close()
+
+	var page []string
+	var total int
+	batch := make([]string, 0, listBatch)
+	flush := func() {
+		for _, key := range q.Allow(batch) {
+			if total >= q.Offset && (q.Limit <= 0 || len(page) < q.Limit) {
+				page = append(page, key)
+			}
+			total++
+		}
+		batch = batch[:0]
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan key name: %w", err)
+		}
+		if batch = append(batch, key); len(batch) == listBatch {
+			flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list key names: %w", err)
+	}
+	flush()
+	return page, total, nil
+}
+
+// keyInfosByName loads metadata of the given keys in their order, skipping keys deleted meanwhile.
+func (s *Store) keyInfosByName(ctx context.Context, names []string) ([]KeyInfo, error) {
+	byName := make(map[string]KeyInfo, len(names))
+	for chunk := range slices.Chunk(names, listBatch) {
+		query, args, err := sqlx.In(listSelect+" WHERE key IN (?)", chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build key query: %w", err)
+		}
+		keys, err := s.selectKeyInfos(ctx, s.adoptQuery(query), args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			byName[k.Key] = k
+		}
+	}
+	result := make([]KeyInfo, 0, len(names))
+	for _, name := range names {
+		if k, ok := byName[name]; ok {
+			result = append(result, k)
+		}
+	}
+	return result, nil
+}
+
+// selectKeyInfos runs a listSelect query and sets secret and ZK flags.
+func (s *Store) selectKeyInfos(ctx context.Context, query string, args ...any) ([]KeyInfo, error) {
+	var rows []keyInfoRow
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	result := make([]KeyInfo, len(rows))
+	for i, r := range rows {
+		result[i] = r.keyInfo()
+	}
+	return result, nil
+}
+
+// Rekey re-encrypts secrets whose stored prefix key id differs from the active one, e.g. after a new
+// key was added for a prefix or a prefix key was configured for existing secrets. ZK-encrypted values
+// are skipped and updated_at is kept, so clients holding a version don't see a conflict. Previous values
+// in the history are re-encrypted too, so the retired key isn't needed to read them.
+// Returns the number of re-encrypted keys, history revisions are not counted.
+func (s *Store) Rekey(ctx context.Context) (int, error) {
+	if !s.SecretsEnabled() {
+		return 0, ErrSecretsNotConfigured
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rekeyed, failed, err := s.rekeyTable(ctx, "kv", "key")
+	if err != nil {
+		return rekeyed, err
+	}
+	_, failedRevisions, err := s.rekeyTable(ctx, "kv_history", "id")
+	if err != nil {
+		return rekeyed, err
+	}
+	if failed += failedRevisions; failed > 0 {
+		return rekeyed, fmt.Errorf("failed to decrypt %d keys, check retired prefix keys are still configured", failed)
+	}
+	return rekeyed, nil
+}
+
+// rekeyTable re-encrypts the secrets of a table with key and value columns, rows are updated by the id
+// column. Returns the number of re-encrypted rows and of rows failed to decrypt.
+func (s *Store) rekeyTable(ctx context.Context, table, id string) (rekeyed, failed int, err error) {
+	var rows []struct {
+		ID    any    `db:"id"` // the value of the id column as scanned, passed back to the update
+		Key   string `db:"key"`
+		Value []byte `db:"value"`
+	}
+	query := fmt.Sprintf("SELECT %s AS id, key, value FROM %s", id, table)
+	if err := s.db.SelectContext(ctx, &rows, query); err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+
+	update := s.adoptQuery(fmt.Sprintf("UPDATE %s SET value = ? WHERE %s = ?", table, id))
+	for _, r := range rows {
+		if !IsSecret(r.Key) || stash.IsZKEncrypted(r.Value) {
+			continue
+		}
+		storedID, _, _ := splitPrefixKeyID(r.Value)
+		pk, _ := s.keyring.forKey(r.Key)
+		if storedID == pk.id {
+			continue // already encrypted with the active key, or no prefix key on either side
+		}
+		plain, err := s.decrypt(r.Value)
+		if err != nil {
+			log.Printf("[WARN] failed to decrypt %s %v of key %q for rekey: %v", table, r.ID, r.Key, err)
+			failed++
+			continue
+		}
+		encrypted, err := s.encrypt(r.Key, plain)
+		if err != nil {
+			return rekeyed, failed, fmt.Errorf("failed to encrypt key %q: %w", r.Key, err)
+		}
+		if _, err = s.db.ExecContext(ctx, update, encrypted, r.ID); err != nil {
+			return rekeyed, failed, fmt.Errorf("failed to update key %q in %s: %w", r.Key, table, err)
+		}
+		log.Printf("[DEBUG] rekeyed %s %v of key %q: %q -> %q", table, r.ID, r.Key, storedID, pk.id)
+		rekeyed++
+	}
+	return rekeyed, failed, nil
+}
+
+// encrypt encrypts a secret value with the server key. If the key is under a prefix with its own key,
+// the value is encrypted with the prefix key first and the result is tagged with the prefix key id.
+func (s *Store) encrypt(key string, value []byte) ([]byte, error) {
+	pk, ok := s.keyring.forKey(key)
+	if !ok {
+		enc, err := s.encryptor.Encrypt(value)
+		if err != nil {
+			return nil, fmt.Errorf("server key: %w", err)
+		}
+		return enc, nil
+	}
+	inner, err := pk.crypto.Encrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("prefix key %s: %w", pk.id, err)
+	}
+	outer, err := s.encryptor.Encrypt(inner)
+	if err != nil {
+		return nil, fmt.Errorf("server key: %w", err)
+	}
+	return append([]byte(prefixKeyMarker+pk.id+"$"), outer...), nil
+}
+
+// decrypt reverses encrypt. The prefix key is picked by the stored id rather than by the key path,
+// so values encrypted with rotated out keys stay readable as long as those keys are configured.
+func (s *Store) decrypt(value []byte) ([]byte, error) {
+	id, body, tagged := splitPrefixKeyID(value)
+	if !tagged {
+		plain, err := s.encryptor.Decrypt(value)
+		if err != nil {
+			return nil, fmt.Errorf("server key: %w", err)
+		}
+		return plain, nil
+	}
+	inner, err := s.encryptor.Decrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("server key: %w", err)
+	}
+	c := s.keyring.lookup(id)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPrefixKeyNotFound, id)
+	}
+	plain, err := c.Decrypt(inner)
+	if err != nil {
+		return nil, fmt.Errorf("prefix key %s: %w", id, err)
+	}
+	return plain, nil
+}
+
+// EncryptSecret encrypts a value of the secret key the way it is stored in the database,
+// e.g. for git history which must not hold plaintext secrets.
+func (s *Store) EncryptSecret(key string, value []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.SecretsEnabled() {
+		return nil, ErrSecretsNotConfigured
+	}
+	return s.encrypt(key, value)
+}
+
+// DecryptSecret decrypts a value encrypted by EncryptSecret.
+func (s *Store) DecryptSecret(value []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.SecretsEnabled() {
+		return nil, ErrSecretsNotConfigured
+	}
+	return s.decrypt(value)
+}
+
+// writeProbeKey is the key written and rolled back by CheckWrite, valid text for postgres which rejects NUL
+const writeProbeKey = "_stash/doctor-probe"
+
+// CheckWrite checks the database accepts writes, with a probe key written and rolled back.
+// Nothing is changed, but a read-only file, a locked database or a read-only replica fail.
+func (s *Store) CheckWrite(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	query := s.adoptQuery("INSERT INTO kv (key, value, format) VALUES (?, ?, 'text') ON CONFLICT(key) DO NOTHING")
+	if _, err := tx.ExecContext(ctx, query, writeProbeKey, []byte{}); err != nil {
+		return fmt.Errorf("failed to write probe key: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database connection.
 func (s *Store) Close() error {
 	if err := s.db.Close(); err != nil {
@@ -659,7 +1515,7 @@ func (s *Store) Close() error {
 
 // adoptQuery converts SQLite query syntax to PostgreSQL:
 // - placeholders: ? → $1, $2, ...
-// - functions: length( → octet_length(
+// - functions: length( → octet_length(, instr( → strpos(
 // - case: excluded. → EXCLUDED.
 func (s *Store) adoptQuery(query string) string {
 	if s.dbType != DBTypePostgres {
@@ -668,6 +1524,7 @@ func (s *Store) adoptQuery(query string) string {
 
 	// function and keyword mappings
 	query = strings.ReplaceAll(query, "length(", "octet_length(")
+	query = strings.ReplaceAll(query, "instr(", "strpos(")
 	query = strings.ReplaceAll(query, "excluded.", "EXCLUDED.")
 
 	// placeholder conversion
@@ -685,14 +1542,25 @@ func (s *Store) adoptQuery(query string) string {
 	return string(result)
 }
 
+// Session is a login session of a web UI user.
+type Session struct {
+	Token     string    `db:"token"` // set by UserSessions only
+	Username  string    `db:"username"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Remember  bool      `db:"remember"` // long-lived "remember me" session
+	SessionDevice
+}
+
 // CreateSession stores a new session in the database.
-func (s *Store) CreateSession(ctx context.Context, token, username string, expiresAt time.Time) error {
+func (s *Store) CreateSession(ctx context.Context, token, username string, expiresAt time.Time, remember bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := s.adoptQuery(`INSERT INTO sessions (token, username, expires_at) VALUES (?, ?, ?)
-		ON CONFLICT(token) DO UPDATE SET username = excluded.username, expires_at = excluded.expires_at`)
-	if _, err := s.db.ExecContext(ctx, query, token, username, expiresAt.UTC()); err != nil {
+	query := s.adoptQuery(`INSERT INTO sessions (token, username, created_at, expires_at, remember) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(token) DO UPDATE SET username = excluded.username, created_at = excluded.created_at,
+		expires_at = excluded.expires_at, remember = excluded.remember`)
+	if _, err := s.db.ExecContext(ctx, query, token, username, time.Now().UTC(), expiresAt.UTC(), remember); err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	log.Printf("[DEBUG] create session for user %q", username)
@@ -701,29 +1569,47 @@ func (s *Store) CreateSession(ctx context.Context, token, username string, expir
 
 // GetSession retrieves session data by token.
 // Returns ErrNotFound if the session doesn't exist or is expired.
-func (s *Store) GetSession(ctx context.Context, token string) (username string, expiresAt time.Time, err error) {
+func (s *Store) GetSession(ctx context.Context, token string) (Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result struct {
-		Username  string    `db:"username"`
-		ExpiresAt time.Time `db:"expires_at"`
-	}
-	query := s.adoptQuery("SELECT username, expires_at FROM sessions WHERE token = ?")
+	var result Session
+	query := s.adoptQuery("SELECT username, created_at, expires_at, remember, device, user_agent, ip, location FROM sessions WHERE token = ?")
 	if err := s.db.GetContext(ctx, &result, query, token); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", time.Time{}, ErrNotFound
+			return Session{}, ErrNotFound
 		}
-		return "", time.Time{}, fmt.Errorf("failed to get session: %w", err)
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
 	}
 
 	// check expiration and normalize to UTC for consistency
-	expiresUTC := result.ExpiresAt.UTC()
-	if time.Now().UTC().After(expiresUTC) {
-		return "", time.Time{}, ErrNotFound
+	result.CreatedAt, result.ExpiresAt = result.CreatedAt.UTC(), result.ExpiresAt.UTC()
+	if time.Now().UTC().After(result.ExpiresAt) {
+		return Session{}, ErrNotFound
 	}
 
-	return result.Username, expiresUTC, nil
+	return result, nil
+}
+
+// ExtendSession moves the expiration of a session.
+// Returns ErrNotFound if the session doesn't exist or is already expired.
+func (s *Store) ExtendSession(ctx context.Context, token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery("UPDATE sessions SET expires_at = ? WHERE token = ? AND expires_at > ?")
+	result, err := s.db.ExecContext(ctx, query, expiresAt.UTC(), token, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // DeleteSession removes a session by token.
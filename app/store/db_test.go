@@ -2,10 +2,17 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/go-pkgz/testutils/containers"
@@ -297,6 +304,217 @@ func TestStore_GetInfo(t *testing.T) {
 	}
 }
 
+func TestStore_SetWithOptions_Owner(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			created, err := st.SetWithOptions(t.Context(), "owned/key", []byte("v"), "text", SetOptions{Owner: "user:alice"})
+			require.NoError(t, err)
+			assert.True(t, created)
+			_, err = st.Set(t.Context(), "plain/key", []byte("v"), "text")
+			require.NoError(t, err)
+
+			created, err = st.SetWithOptions(t.Context(), "owned/key", []byte("v2"), "text", SetOptions{Owner: "user:bob"})
+			require.NoError(t, err)
+			assert.False(t, created)
+
+			info, err := st.GetInfo(t.Context(), "owned/key")
+			require.NoError(t, err)
+			assert.Equal(t, "user:alice", info.Owner, "kept on update")
+			keys, _, err := st.ListPage(t.Context(), ListQuery{Prefix: "owned/"})
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+			assert.Equal(t, "user:alice", keys[0].Owner)
+
+			info, err = st.GetInfo(t.Context(), "plain/key")
+			require.NoError(t, err)
+			assert.Empty(t, info.Owner, "no owner without option")
+		})
+	}
+}
+
+func TestStore_Variants(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			_, err := st.Set(t.Context(), "ab/banner", []byte("control"), "text")
+			require.NoError(t, err)
+
+			spec, err := st.GetVariants(t.Context(), "ab/banner")
+			require.NoError(t, err)
+			assert.Empty(t, spec)
+
+			require.NoError(t, st.SetVariants(t.Context(), "ab/banner", `{"variants":[]}`))
+			_, err = st.Set(t.Context(), "ab/banner", []byte("control2"), "text")
+			require.NoError(t, err)
+			spec, err = st.GetVariants(t.Context(), "ab/banner")
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"variants":[]}`, spec, "kept on update")
+
+			require.NoError(t, st.SetVariants(t.Context(), "ab/banner", ""))
+			spec, err = st.GetVariants(t.Context(), "ab/banner")
+			require.NoError(t, err)
+			assert.Empty(t, spec, "removed")
+
+			require.NoError(t, st.SetVariants(t.Context(), "ab/banner", `{"variants":[]}`))
+			require.NoError(t, st.Delete(t.Context(), "ab/banner"))
+			_, err = st.Set(t.Context(), "ab/banner", []byte("new"), "text")
+			require.NoError(t, err)
+			spec, err = st.GetVariants(t.Context(), "ab/banner")
+			require.NoError(t, err)
+			assert.Empty(t, spec, "removed with the key")
+
+			assert.ErrorIs(t, st.SetVariants(t.Context(), "missing/key", "{}"), ErrNotFound)
+			_, err = st.GetVariants(t.Context(), "missing/key")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestStore_Expiration(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			prefix := "ttl-" + engine + "/"
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			for key, opts := range map[string]SetOptions{"expired": {ExpiresAt: time.Now().Add(-time.Second)},
+				"later": {ExpiresAt: expiresAt}, "reset": {ExpiresAt: expiresAt}} {
+				_, err := st.SetWithOptions(t.Context(), prefix+key, []byte("v"), "text", opts)
+				require.NoError(t, err)
+			}
+
+			_, err := st.Get(t.Context(), prefix+"expired")
+			require.ErrorIs(t, err, ErrNotFound, "expired key is not returned before it's deleted")
+			_, _, err = st.GetWithFormat(t.Context(), prefix+"expired")
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = st.GetInfo(t.Context(), prefix+"expired")
+			require.ErrorIs(t, err, ErrNotFound)
+			keys, total, err := st.ListPage(t.Context(), ListQuery{Prefix: prefix})
+			require.NoError(t, err)
+			assert.Equal(t, 2, total, "expired key is not counted")
+			require.Len(t, keys, 2)
+			for _, k := range keys {
+				assert.NotEqual(t, prefix+"expired", k.Key, "expired key is not listed")
+			}
+			all, err := st.List(t.Context(), enum.SecretsFilterAll)
+			require.NoError(t, err)
+			for _, k := range all {
+				assert.NotEqual(t, prefix+"expired", k.Key, "expired key is not listed")
+			}
+
+			info, err := st.GetInfo(t.Context(), prefix+"later")
+			require.NoError(t, err)
+			require.NotNil(t, info.ExpiresAt)
+			assert.True(t, expiresAt.Equal(*info.ExpiresAt), "expires at %v, got %v", expiresAt, *info.ExpiresAt)
+			keys, _, err = st.ListPage(t.Context(), ListQuery{Prefix: prefix + "later"})
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+			assert.NotNil(t, keys[0].ExpiresAt)
+
+			_, err = st.Set(t.Context(), prefix+"reset", []byte("v2"), "text")
+			require.NoError(t, err)
+			info, err = st.GetInfo(t.Context(), prefix+"reset")
+			require.NoError(t, err)
+			assert.Nil(t, info.ExpiresAt, "setting the value removes the expiration")
+
+			deleted, err := st.DeleteExpired(t.Context(), time.Now())
+			require.NoError(t, err)
+			assert.Contains(t, deleted, prefix+"expired")
+			assert.NotContains(t, deleted, prefix+"later")
+			require.ErrorIs(t, st.Delete(t.Context(), prefix+"expired"), ErrNotFound, "deleted")
+
+			deleted, err = st.DeleteExpired(t.Context(), expiresAt)
+			require.NoError(t, err)
+			assert.Contains(t, deleted, prefix+"later")
+			assert.NotContains(t, deleted, prefix+"reset")
+			_, err = st.Get(t.Context(), prefix+"reset")
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStore_SetWithOptions(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			key := "ttl-opts-" + engine + "/key"
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+			created, err := st.SetWithOptions(t.Context(), key, []byte("v1"), "text", SetOptions{ExpiresAt: expiresAt})
+			require.NoError(t, err)
+			assert.True(t, created)
+			info, err := st.GetInfo(t.Context(), key)
+			require.NoError(t, err)
+			require.NotNil(t, info.ExpiresAt, "created with the expiration")
+			assert.True(t, expiresAt.Equal(*info.ExpiresAt))
+
+			later := expiresAt.Add(time.Hour)
+			created, err = st.SetWithOptions(t.Context(), key, []byte("v2"), "json", SetOptions{ExpiresAt: later})
+			require.NoError(t, err)
+			assert.False(t, created)
+			info, err = st.GetInfo(t.Context(), key)
+			require.NoError(t, err)
+			require.NotNil(t, info.ExpiresAt, "updated with the expiration")
+			assert.True(t, later.Equal(*info.ExpiresAt))
+			value, format, err := st.GetWithFormat(t.Context(), key)
+			require.NoError(t, err)
+			assert.Equal(t, "v2", string(value))
+			assert.Equal(t, "json", format)
+
+			_, err = st.SetWithOptions(t.Context(), key, []byte("v3"), "text", SetOptions{})
+			require.NoError(t, err)
+			info, err = st.GetInfo(t.Context(), key)
+			require.NoError(t, err)
+			assert.Nil(t, info.ExpiresAt, "no expiration without the option")
+
+			_, err = st.SetWithOptions(t.Context(), key, []byte("v4"), "text", SetOptions{ExpiresAt: time.Now().Add(-time.Second)})
+			require.NoError(t, err)
+			_, err = st.Get(t.Context(), key)
+			require.ErrorIs(t, err, ErrNotFound, "set already expired")
+		})
+	}
+}
+
+func TestStore_SetWithOptions_Meta(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			key := "meta-opts-" + engine + "/key"
+			description := "main db"
+			meta := func() KeyMeta {
+				info, err := st.GetInfo(t.Context(), key)
+				require.NoError(t, err)
+				return KeyMeta{Labels: info.Labels, Description: info.Description}
+			}
+
+			_, err := st.SetWithOptions(t.Context(), key, []byte("v1"), "text",
+				SetOptions{Labels: map[string]string{"env": "prod"}, Description: &description})
+			require.NoError(t, err)
+			assert.Equal(t, KeyMeta{Labels: map[string]string{"env": "prod"}, Description: "main db"}, meta(), "created with metadata")
+
+			_, err = st.SetWithOptions(t.Context(), key, []byte("v2"), "text", SetOptions{Labels: map[string]string{"team": "core"}})
+			require.NoError(t, err)
+			assert.Equal(t, KeyMeta{Labels: map[string]string{"team": "core"}, Description: "main db"}, meta(), "description kept")
+
+			_, err = st.Set(t.Context(), key, []byte("v3"), "text")
+			require.NoError(t, err)
+			assert.Equal(t, KeyMeta{Labels: map[string]string{"team": "core"}, Description: "main db"}, meta(), "set keeps metadata")
+
+			empty := ""
+			_, err = st.SetWithOptions(t.Context(), key, []byte("v4"), "text", SetOptions{Labels: map[string]string{}, Description: &empty})
+			require.NoError(t, err)
+			assert.Equal(t, KeyMeta{}, meta(), "empty metadata removed")
+
+			long := strings.Repeat("x", maxDescriptionLen+1)
+			_, err = st.SetWithOptions(t.Context(), key, []byte("v5"), "text", SetOptions{Description: &long})
+			require.ErrorIs(t, err, ErrInvalidMeta)
+			value, err := st.Get(t.Context(), key)
+			require.NoError(t, err)
+			assert.Equal(t, "v4", string(value), "value not changed with invalid metadata")
+		})
+	}
+}
+
 func TestStore_ZKEncrypted(t *testing.T) {
 	// create valid ZK payload
 	zk, err := stash.NewZKCrypto([]byte("test-passphrase-min-16"))
@@ -507,6 +725,85 @@ func TestStore_Migration(t *testing.T) {
 		assert.Len(t, keys, 2)
 	})
 
+	t.Run("sqlite/add audit reason column", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "legacy-audit.db")
+		db, err := sqlx.Connect("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`
+			CREATE TABLE audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				timestamp TEXT NOT NULL,
+				action TEXT NOT NULL,
+				key TEXT NOT NULL,
+				actor TEXT NOT NULL,
+				actor_type TEXT NOT NULL,
+				result TEXT NOT NULL,
+				ip TEXT,
+				user_agent TEXT,
+				value_size INTEGER,
+				request_id TEXT
+			)
+		`)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO audit_log (timestamp, action, key, actor, actor_type, result)
+			VALUES (?, 'read', 'app/db', 'admin', 'user', 'success')`, time.Now().Format(time.RFC3339))
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		store, err := New(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+
+		require.NoError(t, store.LogAudit(t.Context(), AuditEntry{Timestamp: time.Now(), Action: enum.AuditActionRead,
+			Key: "prod/db", Actor: "admin", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess, Reason: "INC-7"}))
+		entries, total, err := store.QueryAudit(t.Context(), AuditQuery{Key: "*"})
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+		reasons := map[string]string{entries[0].Key: entries[0].Reason, entries[1].Key: entries[1].Reason}
+		assert.Equal(t, map[string]string{"app/db": "", "prod/db": "INC-7"}, reasons)
+	})
+
+	t.Run("sqlite/add encrypted columns", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "legacy-encrypted.db")
+		db, err := sqlx.Connect("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`
+			CREATE TABLE kv (
+				key TEXT PRIMARY KEY,
+				value BLOB NOT NULL,
+				format TEXT NOT NULL DEFAULT 'text',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE kv_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				key TEXT NOT NULL,
+				value BLOB NOT NULL,
+				format TEXT NOT NULL,
+				updated_at DATETIME
+			)
+		`)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO kv (key, value) VALUES (?, ?)`, "app/key", []byte("$ENC$plain"))
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO kv_history (key, value, format, updated_at) VALUES (?, ?, 'text', ?)`,
+			"app/key", []byte("$ENC$old"), time.Now().UTC())
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		store, err := New(dbPath, WithHistory(5))
+		require.NoError(t, err)
+		defer store.Close()
+
+		value, err := store.Get(t.Context(), "app/key")
+		require.NoError(t, err)
+		assert.Equal(t, "$ENC$plain", string(value), "existing rows are plaintext")
+		revs, err := store.GetHistory(t.Context(), "app/key", 0)
+		require.NoError(t, err)
+		require.Len(t, revs, 1)
+		assert.Equal(t, "$ENC$old", string(revs[0].Value))
+	})
+
 	t.Run("sqlite/already migrated", func(t *testing.T) {
 		dbPath := filepath.Join(t.TempDir(), "already-migrated.db")
 
@@ -528,6 +825,85 @@ func TestStore_Migration(t *testing.T) {
 		assert.Equal(t, []byte("test-value"), value)
 		assert.Equal(t, "yaml", format)
 	})
+
+	t.Run("sqlite/add sort_key column", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "legacy-sort.db")
+		db, err := sqlx.Connect("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`
+			CREATE TABLE kv (
+				key TEXT PRIMARY KEY,
+				value BLOB NOT NULL,
+				format TEXT NOT NULL DEFAULT 'text',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		require.NoError(t, err)
+		for _, k := range []string{"zeta", "Beta", "alpha"} {
+			_, err = db.Exec(`INSERT INTO kv (key, value) VALUES (?, ?)`, k, []byte("v"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, db.Close())
+
+		store, err := New(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+
+		var missing int
+		require.NoError(t, store.db.Get(&missing, "SELECT COUNT(*) FROM kv WHERE sort_key IS NULL"))
+		assert.Zero(t, missing, "sort keys of existing rows filled")
+		keys, _, err := store.ListPage(t.Context(), ListQuery{Sort: enum.SortModeKey})
+		require.NoError(t, err)
+		require.Len(t, keys, 3)
+		assert.Equal(t, []string{"alpha", "Beta", "zeta"}, []string{keys[0].Key, keys[1].Key, keys[2].Key})
+		assert.Empty(t, keys[0].Owner, "owner column added, legacy keys have no owner")
+	})
+
+	t.Run("sqlite/add sessions created_at and remember columns", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "legacy-sessions.db")
+		db, err := sqlx.Connect("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`CREATE TABLE sessions (token TEXT PRIMARY KEY, username TEXT NOT NULL, expires_at DATETIME NOT NULL)`)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO sessions (token, username, expires_at) VALUES (?, ?, ?)`,
+			"legacy-token", "alice", time.Now().Add(time.Hour).UTC())
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		store, err := New(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+
+		sess, err := store.GetSession(t.Context(), "legacy-token")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", sess.Username)
+		assert.WithinDuration(t, time.Now(), sess.CreatedAt, time.Minute, "existing sessions created at migration time")
+		assert.True(t, sess.Remember, "existing sessions were long-lived")
+		assert.Equal(t, SessionDevice{}, sess.SessionDevice, "device of existing sessions is unknown")
+	})
+}
+
+func TestStore_ListSortIndexes(t *testing.T) {
+	store := newTestStore(t, "sqlite")
+	for _, mode := range enum.SortModeValues {
+		rows, err := store.db.Query("EXPLAIN QUERY PLAN " + listSelect + listOrder(mode))
+		require.NoError(t, err)
+		var plan []string
+		for rows.Next() {
+			var id, parent, notUsed int
+			var detail string
+			require.NoError(t, rows.Scan(&id, &parent, &notUsed, &detail))
+			plan = append(plan, detail)
+		}
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+		require.NotEmpty(t, plan)
+		assert.Contains(t, plan[0], "INDEX idx_kv_", "sort mode %s", mode)
+		for _, detail := range plan {
+			assert.NotContains(t, detail, "TEMP B-TREE", "sort mode %s sorts without index", mode)
+		}
+	}
 }
 
 func TestStore_SetWithVersion(t *testing.T) {
@@ -657,6 +1033,233 @@ func TestStore_SetWithVersion(t *testing.T) {
 	}
 }
 
+// versionOp is a random store operation of the versioning property tests.
+type versionOp struct {
+	Kind  uint8  // set, set with the current version, set with a stale version, delete or get
+	Key   uint8  // one of a few keys, so operations hit the same keys
+	Value uint16 // value to set, picks the stale version too
+}
+
+// TestStore_SetWithVersion_Properties runs random sequences of writes, versioned writes, deletes and reads
+// against a model of the keys. A versioned write succeeds only with the current version, fails with
+// a conflict reporting the current state for older versions of an existing key and with ErrNotFound
+// for a deleted key. Every write gets a version newer than all versions the key had before.
+func TestStore_SetWithVersion_Properties(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			run := 0
+
+			check := func(ops []versionOp) bool {
+				run++
+				current := map[string]string{}       // value of existing keys
+				versions := map[string][]time.Time{} // versions of each key, oldest first, kept after delete
+				fail := func(i int, op versionOp, format string, args ...any) bool {
+					t.Logf("op %d %+v: %s", i, op, fmt.Sprintf(format, args...))
+					return false
+				}
+				// written checks the version of a successful write and records it
+				written := func(key, value string) error {
+					info, err := st.GetInfo(ctx, key)
+					if err != nil {
+						return err
+					}
+					if seen := versions[key]; len(seen) > 0 && !info.UpdatedAt.After(seen[len(seen)-1]) {
+						return fmt.Errorf("version %v is not newer than %v", info.UpdatedAt, seen[len(seen)-1])
+					}
+					current[key] = value
+					versions[key] = append(versions[key], info.UpdatedAt)
+					return nil
+				}
+
+				for i, op := range ops {
+					key := fmt.Sprintf("prop/%s/%d/%d", engine, run, op.Key%3)
+					value := strconv.Itoa(int(op.Value))
+					want, exists := current[key]
+					seen := versions[key]
+
+					switch op.Kind % 5 {
+					case 0:
+						created, err := st.Set(ctx, key, []byte(value), "text")
+						if err != nil || created == exists {
+							return fail(i, op, "set: created %v, err %v, key existed %v", created, err, exists)
+						}
+						if err = written(key, value); err != nil {
+							return fail(i, op, "set: %v", err)
+						}
+					case 1:
+						if !exists {
+							continue // no current version
+						}
+						if err := st.SetWithVersion(ctx, key, []byte(value), "text", seen[len(seen)-1]); err != nil {
+							return fail(i, op, "set with current version: %v", err)
+						}
+						if err := written(key, value); err != nil {
+							return fail(i, op, "set with current version: %v", err)
+						}
+					case 2:
+						stale := len(seen)
+						if exists {
+							stale-- // the last version is the current one
+						}
+						if stale == 0 {
+							continue // no older version
+						}
+						version := seen[int(op.Value)%stale]
+						err := st.SetWithVersion(ctx, key, []byte(value), "text", version)
+						if !exists {
+							if !errors.Is(err, ErrNotFound) {
+								return fail(i, op, "set deleted key with old version: want ErrNotFound, got %v", err)
+							}
+							continue
+						}
+						var conflict *ConflictError
+						if !errors.As(err, &conflict) {
+							return fail(i, op, "set with stale version: want conflict, got %v", err)
+						}
+						if string(conflict.Info.CurrentValue) != want || !conflict.Info.CurrentVersion.Equal(seen[len(seen)-1]) ||
+							!conflict.Info.AttemptedVersion.Equal(version) {
+							return fail(i, op, "set with stale version: conflict %+v, want value %q", conflict.Info, want)
+						}
+					case 3:
+						err := st.Delete(ctx, key)
+						if exists != (err == nil) || (!exists && !errors.Is(err, ErrNotFound)) {
+							return fail(i, op, "delete: err %v, key existed %v", err, exists)
+						}
+						delete(current, key)
+					case 4:
+						got, err := st.Get(ctx, key)
+						if exists && (err != nil || string(got) != want) {
+							return fail(i, op, "get: %q, %v, want %q", got, err, want)
+						}
+						if !exists && !errors.Is(err, ErrNotFound) {
+							return fail(i, op, "get deleted key: want ErrNotFound, got %v", err)
+						}
+					}
+				}
+				return true
+			}
+			require.NoError(t, quick.Check(check, &quick.Config{MaxCount: 30}))
+		})
+	}
+}
+
+// TestStore_SetWithVersion_Concurrent runs read-modify-write increments of a counter from several
+// goroutines, retried on conflicts, with a delete racing them in some runs. Without the delete no
+// increment is lost, with it no versioned write brings the deleted key back.
+func TestStore_SetWithVersion_Concurrent(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			run := 0
+
+			// increment adds one to the counter, returns false if the key is deleted
+			increment := func(key string) (bool, error) {
+				for {
+					// version is read before the value, a value newer than the version fails the write
+					info, err := st.GetInfo(ctx, key)
+					if errors.Is(err, ErrNotFound) {
+						return false, nil
+					}
+					if err != nil {
+						return false, err
+					}
+					value, err := st.Get(ctx, key)
+					if errors.Is(err, ErrNotFound) {
+						return false, nil
+					}
+					if err != nil {
+						return false, err
+					}
+					n, err := strconv.Atoi(string(value))
+					if err != nil {
+						return false, err
+					}
+					runtime.Gosched() // let other workers read the same version
+					err = st.SetWithVersion(ctx, key, []byte(strconv.Itoa(n+1)), "text", info.UpdatedAt)
+					var conflict *ConflictError
+					switch {
+					case err == nil:
+						return true, nil
+					case errors.As(err, &conflict):
+						continue
+					case errors.Is(err, ErrNotFound):
+						return false, nil
+					default:
+						return false, err
+					}
+				}
+			}
+
+			check := func(workers, increments, deleteAfter uint8, withDelete bool) bool {
+				run++
+				key := fmt.Sprintf("prop/%s/counter/%d", engine, run)
+				if _, err := st.Set(ctx, key, []byte("0"), "text"); err != nil {
+					t.Logf("set: %v", err)
+					return false
+				}
+				w, n := int(workers%4)+2, int(increments%8)+3
+
+				var done atomic.Int64
+				var wg sync.WaitGroup
+				errs := make(chan error, w+1)
+				for range w {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for range n {
+							ok, err := increment(key)
+							if err != nil {
+								errs <- err
+								return
+							}
+							if !ok {
+								return
+							}
+							done.Add(1)
+						}
+					}()
+				}
+				if withDelete {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for range deleteAfter {
+							runtime.Gosched() // let some increments run first
+						}
+						if err := st.Delete(ctx, key); err != nil {
+							errs <- err
+						}
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					t.Logf("workers %d, increments %d, delete %v: %v", w, n, withDelete, err)
+					return false
+				}
+
+				value, err := st.Get(ctx, key)
+				if withDelete {
+					if !errors.Is(err, ErrNotFound) {
+						t.Logf("deleted key is back: %q, %v", value, err)
+						return false
+					}
+					return true
+				}
+				if err != nil || string(value) != strconv.FormatInt(done.Load(), 10) || done.Load() != int64(w*n) {
+					t.Logf("workers %d, increments %d: counter %q, %v, want %d", w, n, value, err, w*n)
+					return false
+				}
+				return true
+			}
+			require.NoError(t, quick.Check(check, &quick.Config{MaxCount: 20}))
+		})
+	}
+}
+
 func TestStore_Session(t *testing.T) {
 	for _, engine := range testEngines {
 		t.Run(engine, func(t *testing.T) {
@@ -666,57 +1269,64 @@ func TestStore_Session(t *testing.T) {
 
 			t.Run("create and get session", func(t *testing.T) {
 				expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
-				err := store.CreateSession(ctx, prefix+"token1", "user1", expires)
+				err := store.CreateSession(ctx, prefix+"token1", "user1", expires, false)
+				require.NoError(t, err)
+
+				sess, err := store.GetSession(ctx, prefix+"token1")
 				require.NoError(t, err)
+				assert.Equal(t, "user1", sess.Username)
+				assert.Equal(t, expires.Unix(), sess.ExpiresAt.Unix())
+				assert.WithinDuration(t, time.Now(), sess.CreatedAt, time.Minute)
+				assert.False(t, sess.Remember)
 
-				username, expiresAt, err := store.GetSession(ctx, prefix+"token1")
+				require.NoError(t, store.CreateSession(ctx, prefix+"token-remember", "user1", expires, true))
+				sess, err = store.GetSession(ctx, prefix+"token-remember")
 				require.NoError(t, err)
-				assert.Equal(t, "user1", username)
-				assert.Equal(t, expires.Unix(), expiresAt.Unix())
+				assert.True(t, sess.Remember)
 			})
 
 			t.Run("get nonexistent session", func(t *testing.T) {
-				_, _, err := store.GetSession(ctx, prefix+"nonexistent")
+				_, err := store.GetSession(ctx, prefix+"nonexistent")
 				require.ErrorIs(t, err, ErrNotFound)
 			})
 
 			t.Run("delete session", func(t *testing.T) {
 				expires := time.Now().Add(time.Hour).UTC()
-				err := store.CreateSession(ctx, prefix+"token-delete", "user", expires)
+				err := store.CreateSession(ctx, prefix+"token-delete", "user", expires, false)
 				require.NoError(t, err)
 
 				err = store.DeleteSession(ctx, prefix+"token-delete")
 				require.NoError(t, err)
 
-				_, _, err = store.GetSession(ctx, prefix+"token-delete")
+				_, err = store.GetSession(ctx, prefix+"token-delete")
 				require.ErrorIs(t, err, ErrNotFound)
 			})
 
 			t.Run("delete all sessions", func(t *testing.T) {
 				expires := time.Now().Add(time.Hour).UTC()
-				err := store.CreateSession(ctx, prefix+"token-a", "user", expires)
+				err := store.CreateSession(ctx, prefix+"token-a", "user", expires, false)
 				require.NoError(t, err)
-				err = store.CreateSession(ctx, prefix+"token-b", "user", expires)
+				err = store.CreateSession(ctx, prefix+"token-b", "user", expires, false)
 				require.NoError(t, err)
 
 				err = store.DeleteAllSessions(ctx)
 				require.NoError(t, err)
 
-				_, _, err = store.GetSession(ctx, prefix+"token-a")
+				_, err = store.GetSession(ctx, prefix+"token-a")
 				require.ErrorIs(t, err, ErrNotFound)
-				_, _, err = store.GetSession(ctx, prefix+"token-b")
+				_, err = store.GetSession(ctx, prefix+"token-b")
 				require.ErrorIs(t, err, ErrNotFound)
 			})
 
 			t.Run("delete expired sessions", func(t *testing.T) {
 				// create expired session
 				expired := time.Now().Add(-time.Hour).UTC()
-				err := store.CreateSession(ctx, prefix+"expired-token", "user", expired)
+				err := store.CreateSession(ctx, prefix+"expired-token", "user", expired, false)
 				require.NoError(t, err)
 
 				// create valid session
 				valid := time.Now().Add(time.Hour).UTC()
-				err = store.CreateSession(ctx, prefix+"valid-token", "user", valid)
+				err = store.CreateSession(ctx, prefix+"valid-token", "user", valid, false)
 				require.NoError(t, err)
 
 				deleted, err := store.DeleteExpiredSessions(ctx)
@@ -724,43 +1334,43 @@ func TestStore_Session(t *testing.T) {
 				assert.Positive(t, deleted)
 
 				// expired should be gone
-				_, _, err = store.GetSession(ctx, prefix+"expired-token")
+				_, err = store.GetSession(ctx, prefix+"expired-token")
 				require.ErrorIs(t, err, ErrNotFound)
 
 				// valid should remain
-				_, _, err = store.GetSession(ctx, prefix+"valid-token")
+				_, err = store.GetSession(ctx, prefix+"valid-token")
 				require.NoError(t, err)
 			})
 
 			t.Run("duplicate token replaces session", func(t *testing.T) {
 				expires := time.Now().Add(time.Hour).UTC()
-				err := store.CreateSession(ctx, prefix+"dup-token", "user1", expires)
+				err := store.CreateSession(ctx, prefix+"dup-token", "user1", expires, false)
 				require.NoError(t, err)
 
-				err = store.CreateSession(ctx, prefix+"dup-token", "user2", expires)
+				err = store.CreateSession(ctx, prefix+"dup-token", "user2", expires, false)
 				require.NoError(t, err)
 
-				username, _, err := store.GetSession(ctx, prefix+"dup-token")
+				sess, err := store.GetSession(ctx, prefix+"dup-token")
 				require.NoError(t, err)
-				assert.Equal(t, "user2", username)
+				assert.Equal(t, "user2", sess.Username)
 			})
 
 			t.Run("session expiration respects UTC timezone", func(t *testing.T) {
 				// store session with explicit UTC time
 				expires := time.Now().Add(30 * time.Minute).UTC().Truncate(time.Second)
-				err := store.CreateSession(ctx, prefix+"tz-token", "tzuser", expires)
+				err := store.CreateSession(ctx, prefix+"tz-token", "tzuser", expires, false)
 				require.NoError(t, err)
 
 				// retrieve and verify UTC is preserved
-				username, expiresAt, err := store.GetSession(ctx, prefix+"tz-token")
+				sess, err := store.GetSession(ctx, prefix+"tz-token")
 				require.NoError(t, err)
-				assert.Equal(t, "tzuser", username)
+				assert.Equal(t, "tzuser", sess.Username)
 
 				// verify the time instant matches (same point in time)
-				assert.Equal(t, expires.Unix(), expiresAt.Unix(), "expiration instant should match")
+				assert.Equal(t, expires.Unix(), sess.ExpiresAt.Unix(), "expiration instant should match")
 
 				// verify the returned time is in UTC location
-				assert.Equal(t, "UTC", expiresAt.Location().String(), "returned time should be in UTC")
+				assert.Equal(t, "UTC", sess.ExpiresAt.Location().String(), "returned time should be in UTC")
 			})
 
 			t.Run("delete sessions by username", func(t *testing.T) {
@@ -768,11 +1378,11 @@ func TestStore_Session(t *testing.T) {
 				aliceUser := prefix + "alice"
 				bobUser := prefix + "bob"
 				// create sessions for different users
-				err := store.CreateSession(ctx, prefix+"token-alice-1", aliceUser, expires)
+				err := store.CreateSession(ctx, prefix+"token-alice-1", aliceUser, expires, false)
 				require.NoError(t, err)
-				err = store.CreateSession(ctx, prefix+"token-alice-2", aliceUser, expires)
+				err = store.CreateSession(ctx, prefix+"token-alice-2", aliceUser, expires, false)
 				require.NoError(t, err)
-				err = store.CreateSession(ctx, prefix+"token-bob-1", bobUser, expires)
+				err = store.CreateSession(ctx, prefix+"token-bob-1", bobUser, expires, false)
 				require.NoError(t, err)
 
 				// delete alice's sessions only
@@ -780,15 +1390,37 @@ func TestStore_Session(t *testing.T) {
 				require.NoError(t, err)
 
 				// alice's sessions should be gone
-				_, _, err = store.GetSession(ctx, prefix+"token-alice-1")
+				_, err = store.GetSession(ctx, prefix+"token-alice-1")
 				require.ErrorIs(t, err, ErrNotFound)
-				_, _, err = store.GetSession(ctx, prefix+"token-alice-2")
+				_, err = store.GetSession(ctx, prefix+"token-alice-2")
 				require.ErrorIs(t, err, ErrNotFound)
 
 				// bob's session should remain
-				username, _, err := store.GetSession(ctx, prefix+"token-bob-1")
+				sess, err := store.GetSession(ctx, prefix+"token-bob-1")
+				require.NoError(t, err)
+				assert.Equal(t, bobUser, sess.Username)
+			})
+
+			t.Run("extend session", func(t *testing.T) {
+				err := store.CreateSession(ctx, prefix+"extend-token", "user", time.Now().Add(time.Minute), false)
+				require.NoError(t, err)
+				created, err := store.GetSession(ctx, prefix+"extend-token")
+				require.NoError(t, err)
+
+				expires := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+				require.NoError(t, store.ExtendSession(ctx, prefix+"extend-token", expires))
+				sess, err := store.GetSession(ctx, prefix+"extend-token")
+				require.NoError(t, err)
+				assert.Equal(t, expires.Unix(), sess.ExpiresAt.Unix())
+				assert.Equal(t, created.CreatedAt.Unix(), sess.CreatedAt.Unix(), "creation time is kept")
+
+				err = store.ExtendSession(ctx, prefix+"nonexistent", expires)
+				require.ErrorIs(t, err, ErrNotFound)
+
+				err = store.CreateSession(ctx, prefix+"extend-expired", "user", time.Now().Add(-time.Minute), false)
 				require.NoError(t, err)
-				assert.Equal(t, bobUser, username)
+				err = store.ExtendSession(ctx, prefix+"extend-expired", expires)
+				require.ErrorIs(t, err, ErrNotFound, "expired session can't be brought back")
 			})
 
 			t.Run("delete sessions by username - no sessions", func(t *testing.T) {
@@ -825,6 +1457,31 @@ func TestStore_SecretsEnabled(t *testing.T) {
 	}
 }
 
+func TestStore_CheckWrite(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			store := newTestStore(t, engine)
+			require.NoError(t, store.CheckWrite(t.Context()))
+
+			keys, err := store.List(t.Context(), enum.SecretsFilterAll)
+			require.NoError(t, err)
+			assert.Empty(t, keys, "probe key is rolled back")
+
+			// an existing key of the probe name is kept
+			_, err = store.Set(t.Context(), writeProbeKey, []byte("mine"), "text")
+			require.NoError(t, err)
+			require.NoError(t, store.CheckWrite(t.Context()))
+			value, err := store.Get(t.Context(), writeProbeKey)
+			require.NoError(t, err)
+			assert.Equal(t, "mine", string(value))
+
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+			assert.Error(t, store.CheckWrite(ctx))
+		})
+	}
+}
+
 func TestStore_Secrets_CRUD(t *testing.T) {
 	for _, engine := range testEngines {
 		t.Run(engine, func(t *testing.T) {
@@ -1130,6 +1787,96 @@ func TestStore_Secrets_WithoutEncryptor(t *testing.T) {
 	}
 }
 
+func TestStore_ListPage(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			store := newTestStoreWithEncryptor(t, engine)
+			prefix := "listpage-" + engine + "/" // search by the prefix isolates from other tests on postgres
+			for _, k := range []string{"b", "A", "c/secrets/x", "secrets", "d", "E-big"} {
+				value := []byte("v")
+				if k == "E-big" {
+					value = []byte("much longer value")
+				}
+				_, err := store.Set(t.Context(), prefix+k, value, "text")
+				require.NoError(t, err)
+			}
+			names := func(keys []KeyInfo) []string {
+				res := make([]string, len(keys))
+				for i, k := range keys {
+					res[i] = strings.TrimPrefix(k.Key, prefix)
+				}
+				return res
+			}
+
+			keys, total, err := store.ListPage(t.Context(), ListQuery{Search: prefix, Sort: enum.SortModeKey, Limit: 4})
+			require.NoError(t, err)
+			assert.Equal(t, 6, total)
+			assert.Equal(t, []string{"A", "b", "c/secrets/x", "d"}, names(keys), "case-insensitive key order")
+			assert.True(t, keys[2].Secret)
+
+			keys, total, err = store.ListPage(t.Context(), ListQuery{Search: prefix, Sort: enum.SortModeKey, Limit: 4, Offset: 4})
+			require.NoError(t, err)
+			assert.Equal(t, 6, total)
+			assert.Equal(t, []string{"E-big", "secrets"}, names(keys))
+
+			keys, _, err = store.ListPage(t.Context(), ListQuery{Search: strings.ToUpper(prefix), Sort: enum.SortModeSize, Limit: 1,
+				Filter: enum.SecretsFilterKeysOnly})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"E-big"}, names(keys), "largest first, search ignores case")
+
+			keys, total, err = store.ListPage(t.Context(), ListQuery{Search: prefix, Sort: enum.SortModeKey,
+				Filter: enum.SecretsFilterSecretsOnly})
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, []string{"c/secrets/x", "secrets"}, names(keys))
+
+			_, total, err = store.ListPage(t.Context(), ListQuery{Search: prefix, Filter: enum.SecretsFilterKeysOnly})
+			require.NoError(t, err)
+			assert.Equal(t, 4, total)
+
+			t.Run("allow", func(t *testing.T) {
+				var calls int
+				allow := func(keys []string) []string {
+					calls++
+					var res []string
+					for _, k := range keys {
+						if !strings.HasSuffix(k, "/b") && !strings.HasSuffix(k, "/d") {
+							res = append(res, k)
+						}
+					}
+					return res
+				}
+				keys, total, err := store.ListPage(t.Context(), ListQuery{Search: prefix, Sort: enum.SortModeKey, Limit: 2,
+					Offset: 1, Allow: allow})
+				require.NoError(t, err)
+				assert.Equal(t, 4, total, "only allowed keys counted")
+				assert.Equal(t, []string{"c/secrets/x", "E-big"}, names(keys))
+				assert.Equal(t, 17, keys[1].Size, "loaded with metadata")
+				assert.Positive(t, calls)
+			})
+
+			t.Run("conditions", func(t *testing.T) {
+				_, err := store.Set(t.Context(), prefix+"f.json", []byte(`{"a":1}`), "json")
+				require.NoError(t, err)
+				list := func(q ListQuery) []string {
+					q.Prefix, q.Sort = prefix+q.Prefix, enum.SortModeKey
+					keys, _, err := store.ListPage(t.Context(), q)
+					require.NoError(t, err)
+					return names(keys)
+				}
+				assert.Equal(t, []string{"c/secrets/x"}, list(ListQuery{Prefix: "c/"}))
+				assert.Empty(t, list(ListQuery{Prefix: "C/"}), "prefix is case-sensitive")
+				assert.Equal(t, []string{"f.json"}, list(ListQuery{Format: "json"}))
+				assert.Equal(t, []string{"E-big"}, list(ListQuery{MinSize: 10, Filter: enum.SecretsFilterKeysOnly}))
+				assert.Equal(t, []string{"A", "b", "d"}, list(ListQuery{SizeBelow: 2, Filter: enum.SecretsFilterKeysOnly}))
+				assert.Len(t, list(ListQuery{UpdatedAfter: time.Now().Add(-time.Hour)}), 7)
+				assert.Empty(t, list(ListQuery{UpdatedBefore: time.Now().Add(-time.Hour)}))
+				assert.Empty(t, list(ListQuery{UpdatedAfter: time.Now().Add(time.Hour)}))
+			})
+		})
+	}
+}
+
 func TestStore_Secrets_ListFilter(t *testing.T) {
 	for _, engine := range testEngines {
 		t.Run(engine, func(t *testing.T) {
@@ -1259,3 +2006,88 @@ func TestStore_Secrets_SetWithVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestStore_Secrets_PrefixKeys(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			ctx := t.Context()
+			prefix := "sec/pk/" + engine + "/"
+			payKey, logKey := prefix+"secrets/pay/stripe", prefix+"secrets/log/token"
+			kr, err := NewKeyring([]PrefixKey{{Prefix: prefix + "secrets/pay/*", ID: "pay-1", Key: []byte("payments-key-0001")}})
+			require.NoError(t, err)
+			enc, err := NewCrypto([]byte("test-secret-key-1234"))
+			require.NoError(t, err)
+			store := newTestStore(t, engine, WithEncryptor(enc), WithKeyring(kr))
+
+			readRaw := func(key string) []byte {
+				var raw []byte
+				require.NoError(t, store.db.GetContext(ctx, &raw, store.adoptQuery("SELECT value FROM kv WHERE key = ?"), key))
+				return raw
+			}
+
+			_, err = store.Set(ctx, payKey, []byte("sk_live_1"), "text")
+			require.NoError(t, err)
+			_, err = store.Set(ctx, logKey, []byte("log-token"), "text")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(readRaw(payKey)), "$PK$pay-1$"), "value tagged with key id")
+			assert.False(t, strings.HasPrefix(string(readRaw(logKey)), "$PK$"), "no prefix key for other secrets")
+
+			value, err := store.Get(ctx, payKey)
+			require.NoError(t, err)
+			assert.Equal(t, "sk_live_1", string(value))
+
+			t.Run("server key alone is not enough", func(t *testing.T) {
+				serverOnly := &Store{db: store.db, dbType: store.dbType, mu: store.mu, encryptor: enc}
+				_, err := serverOnly.Get(ctx, payKey)
+				require.ErrorIs(t, err, ErrPrefixKeyNotFound)
+				value, err := serverOnly.Get(ctx, logKey)
+				require.NoError(t, err)
+				assert.Equal(t, "log-token", string(value))
+			})
+
+			t.Run("rotation keeps old values readable and rekey migrates them", func(t *testing.T) {
+				store.keyring, err = NewKeyring([]PrefixKey{
+					{Prefix: prefix + "secrets/pay/", ID: "pay-1", Key: []byte("payments-key-0001")},
+					{Prefix: prefix + "secrets/pay/", ID: "pay-2", Key: []byte("payments-key-0002")},
+					{Prefix: prefix + "secrets/log/", ID: "log-1", Key: []byte("logging-key-00001")},
+				})
+				require.NoError(t, err)
+				value, err := store.Get(ctx, payKey)
+				require.NoError(t, err)
+				assert.Equal(t, "sk_live_1", string(value))
+				before, err := store.GetInfo(ctx, payKey)
+				require.NoError(t, err)
+
+				n, err := store.Rekey(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, 2, n)
+				assert.True(t, strings.HasPrefix(string(readRaw(payKey)), "$PK$pay-2$"))
+				assert.True(t, strings.HasPrefix(string(readRaw(logKey)), "$PK$log-1$"))
+				after, err := store.GetInfo(ctx, payKey)
+				require.NoError(t, err)
+				assert.True(t, before.UpdatedAt.Equal(after.UpdatedAt), "rekey keeps version")
+
+				n, err = store.Rekey(ctx)
+				require.NoError(t, err)
+				assert.Zero(t, n, "nothing left to rekey")
+
+				// retired key removed from config, new values stay readable
+				store.keyring, err = NewKeyring([]PrefixKey{
+					{Prefix: prefix + "secrets/pay/", ID: "pay-2", Key: []byte("payments-key-0002")},
+					{Prefix: prefix + "secrets/log/", ID: "log-1", Key: []byte("logging-key-00001")},
+				})
+				require.NoError(t, err)
+				value, err = store.Get(ctx, payKey)
+				require.NoError(t, err)
+				assert.Equal(t, "sk_live_1", string(value))
+			})
+
+			t.Run("missing key fails rekey", func(t *testing.T) {
+				store.keyring = nil
+				_, err := store.Rekey(ctx)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to decrypt 2 keys")
+			})
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// DelegatedToken is an API token created at runtime by a prefix admin, limited to the admin's prefix.
+// Tokens are kept by fingerprint, the token itself is shown once on creation and never stored.
+type DelegatedToken struct {
+	Fingerprint string     `db:"fingerprint"`
+	Name        string     `db:"name"`       // label given on creation, e.g. "ci deploy"
+	Prefix      string     `db:"prefix"`     // managed prefix the token was created under, e.g. "team-a/*"
+	CreatedBy   string     `db:"created_by"` // creator identity, "user:alice" or "token:<fingerprint>"
+	ACL         string     `db:"acl"`        // permissions and scopes as JSON, opaque to the store
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   *time.Time `db:"expires_at"` // nil if the token doesn't expire
+}
+
+// AddDelegatedToken stores a new delegated token. Returns ErrConflict if a token with the same
+// fingerprint exists.
+func (s *Store) AddDelegatedToken(ctx context.Context, t DelegatedToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exists int
+	query := s.adoptQuery("SELECT COUNT(*) FROM delegated_tokens WHERE fingerprint = ?")
+	if err := s.db.GetContext(ctx, &exists, query, t.Fingerprint); err != nil {
+		return fmt.Errorf("failed to check delegated token: %w", err)
+	}
+	if exists > 0 {
+		return ErrConflict
+	}
+	var expires *time.Time
+	if t.ExpiresAt != nil {
+		utc := t.ExpiresAt.UTC()
+		expires = &utc
+	}
+	query = s.adoptQuery(`INSERT INTO delegated_tokens (fingerprint, name, prefix, created_by, acl, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if _, err := s.db.ExecContext(ctx, query, t.Fingerprint, t.Name, t.Prefix, t.CreatedBy, t.ACL, t.CreatedAt.UTC(),
+		expires); err != nil {
+		return fmt.Errorf("failed to add delegated token: %w", err)
+	}
+	log.Printf("[DEBUG] add delegated token %q under %q by %s", t.Name, t.Prefix, t.CreatedBy)
+	return nil
+}
+
+// GetDelegatedToken returns a delegated token by fingerprint, expired ones included.
+// Returns ErrNotFound if it doesn't exist.
+func (s *Store) GetDelegatedToken(ctx context.Context, fingerprint string) (DelegatedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var t DelegatedToken
+	query := s.adoptQuery(`SELECT fingerprint, name, prefix, created_by, acl, created_at, expires_at
+		FROM delegated_tokens WHERE fingerprint = ?`)
+	if err := s.db.GetContext(ctx, &t, query, fingerprint); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DelegatedToken{}, ErrNotFound
+		}
+		return DelegatedToken{}, fmt.Errorf("failed to get delegated token: %w", err)
+	}
+	return t.utc(), nil
+}
+
+// DelegatedTokens returns all delegated tokens, the oldest first.
+func (s *Store) DelegatedTokens(ctx context.Context) ([]DelegatedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []DelegatedToken
+	query := `SELECT fingerprint, name, prefix, created_by, acl, created_at, expires_at
+		FROM delegated_tokens ORDER BY created_at, fingerprint`
+	if err := s.db.SelectContext(ctx, &res, query); err != nil {
+		return nil, fmt.Errorf("failed to get delegated tokens: %w", err)
+	}
+	for i := range res {
+		res[i] = res[i].utc()
+	}
+	return res, nil
+}
+
+// DeleteDelegatedToken removes a delegated token. Returns ErrNotFound if it doesn't exist.
+func (s *Store) DeleteDelegatedToken(ctx context.Context, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM delegated_tokens WHERE fingerprint = ?"), fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to delete delegated token: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	log.Printf("[DEBUG] delete delegated token %s", fingerprint)
+	return nil
+}
+
+// utc returns the token with times in UTC, as they are stored.
+func (t DelegatedToken) utc() DelegatedToken {
+	t.CreatedAt = t.CreatedAt.UTC()
+	if t.ExpiresAt != nil {
+		expires := t.ExpiresAt.UTC()
+		t.ExpiresAt = &expires
+	}
+	return t
+}
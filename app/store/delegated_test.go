@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_DelegatedTokens(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			created := time.Now().Add(-time.Hour).Truncate(time.Second)
+			expires := created.Add(24 * time.Hour)
+
+			ci := DelegatedToken{Fingerprint: "fp-ci", Name: "ci", Prefix: "team-a/*", CreatedBy: "user:alice",
+				ACL: `{"permissions":[{"prefix":"team-a/ci/*","access":"r"}]}`, CreatedAt: created, ExpiresAt: &expires}
+			require.NoError(t, st.AddDelegatedToken(ctx, ci))
+			require.ErrorIs(t, st.AddDelegatedToken(ctx, ci), ErrConflict)
+			require.NoError(t, st.AddDelegatedToken(ctx, DelegatedToken{Fingerprint: "fp-deploy", Name: "deploy",
+				Prefix: "team-b/*", CreatedBy: "token:fp-admin", ACL: "{}", CreatedAt: created.Add(time.Minute)}))
+
+			got, err := st.GetDelegatedToken(ctx, "fp-ci")
+			require.NoError(t, err)
+			assert.Equal(t, "ci", got.Name)
+			assert.Equal(t, "team-a/*", got.Prefix)
+			assert.Equal(t, "user:alice", got.CreatedBy)
+			assert.Equal(t, ci.ACL, got.ACL)
+			assert.True(t, created.Equal(got.CreatedAt))
+			require.NotNil(t, got.ExpiresAt)
+			assert.True(t, expires.Equal(*got.ExpiresAt))
+			_, err = st.GetDelegatedToken(ctx, "fp-unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			all, err := st.DelegatedTokens(ctx)
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "fp-ci", all[0].Fingerprint, "oldest first")
+			assert.Nil(t, all[1].ExpiresAt)
+
+			require.NoError(t, st.DeleteDelegatedToken(ctx, "fp-ci"))
+			require.ErrorIs(t, st.DeleteDelegatedToken(ctx, "fp-ci"), ErrNotFound)
+			all, err = st.DelegatedTokens(ctx)
+			require.NoError(t, err)
+			require.Len(t, all, 1)
+			assert.Equal(t, "fp-deploy", all[0].Fingerprint)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// Delivery is a persisted outgoing webhook delivery, retried until it succeeds or is marked dead.
+type Delivery struct {
+	ID          int64     `json:"id"`
+	Payload     []byte    `json:"payload"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Dead        bool      `json:"dead"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// deliveryRow is the database representation of Delivery, timestamps are RFC3339 strings as in audit_log.
+type deliveryRow struct {
+	ID          int64  `db:"id"`
+	Payload     string `db:"payload"`
+	Attempts    int    `db:"attempts"`
+	NextAttempt string `db:"next_attempt"`
+	LastError   string `db:"last_error"`
+	Dead        bool   `db:"dead"`
+	CreatedAt   string `db:"created_at"`
+}
+
+const deliverySelect = "SELECT id, payload, attempts, next_attempt, last_error, dead, created_at FROM webhook_deliveries"
+
+// EnqueueDelivery persists a delivery due now and returns its id.
+func (s *Store) EnqueueDelivery(ctx context.Context, payload []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	query := "INSERT INTO webhook_deliveries (payload, next_attempt, created_at) VALUES (?, ?, ?) RETURNING id"
+	if err := s.db.GetContext(ctx, &id, s.adoptQuery(query), string(payload), now, now); err != nil {
+		return 0, fmt.Errorf("failed to enqueue delivery: %w", err)
+	}
+	return id, nil
+}
+
+// DueDeliveries returns up to limit pending deliveries with the next attempt not after now, oldest first.
+func (s *Store) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := deliverySelect + " WHERE dead = ? AND next_attempt <= ? ORDER BY next_attempt, id LIMIT ?"
+	return s.selectDeliveries(ctx, s.adoptQuery(query), false, now.UTC().Format(time.RFC3339), limit)
+}
+
+// DeadDeliveries returns deliveries that ran out of attempts, oldest first.
+func (s *Store) DeadDeliveries(ctx context.Context) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.selectDeliveries(ctx, s.adoptQuery(deliverySelect+" WHERE dead = ? ORDER BY id"), true)
+}
+
+// CompleteDelivery removes a delivered delivery.
+func (s *Store) CompleteDelivery(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM webhook_deliveries WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to complete delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// FailDelivery records a failed attempt with its error, schedules the next one or marks the delivery dead.
+func (s *Store) FailDelivery(ctx context.Context, id int64, lastErr string, next time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := "UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt = ?, dead = ? WHERE id = ?"
+	if _, err := s.db.ExecContext(ctx, s.adoptQuery(query), lastErr, next.UTC().Format(time.RFC3339), dead, id); err != nil {
+		return fmt.Errorf("failed to update delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// RedriveDelivery moves a dead delivery back to pending with fresh attempts, due now.
+// Returns ErrNotFound if there is no dead delivery with the id.
+func (s *Store) RedriveDelivery(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := "UPDATE webhook_deliveries SET dead = ?, attempts = 0, next_attempt = ? WHERE id = ? AND dead = ?"
+	res, err := s.db.ExecContext(ctx, s.adoptQuery(query), false, time.Now().UTC().Format(time.RFC3339), id, true)
+	if err != nil {
+		return fmt.Errorf("failed to redrive delivery %d: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// selectDeliveries runs a delivery query and converts the rows.
+func (s *Store) selectDeliveries(ctx context.Context, query string, args ...any) ([]Delivery, error) {
+	var rows []deliveryRow
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	res := make([]Delivery, 0, len(rows))
+	for _, r := range rows {
+		d := Delivery{ID: r.ID, Payload: []byte(r.Payload), Attempts: r.Attempts, LastError: r.LastError, Dead: r.Dead}
+		var err error
+		if d.NextAttempt, err = time.Parse(time.RFC3339, r.NextAttempt); err != nil {
+			log.Printf("[WARN] failed to parse delivery next attempt %q: %v", r.NextAttempt, err)
+		}
+		if d.CreatedAt, err = time.Parse(time.RFC3339, r.CreatedAt); err != nil {
+			log.Printf("[WARN] failed to parse delivery created_at %q: %v", r.CreatedAt, err)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Deliveries(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+
+			id1, err := st.EnqueueDelivery(ctx, []byte(`{"rule":"a"}`))
+			require.NoError(t, err)
+			id2, err := st.EnqueueDelivery(ctx, []byte(`{"rule":"b"}`))
+			require.NoError(t, err)
+			assert.NotEqual(t, id1, id2)
+
+			due, err := st.DueDeliveries(ctx, time.Now().Add(time.Second), 10)
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			assert.Equal(t, id1, due[0].ID)
+			assert.JSONEq(t, `{"rule":"a"}`, string(due[0].Payload))
+			assert.WithinDuration(t, time.Now(), due[0].CreatedAt, 5*time.Second)
+
+			// first fails and is retried later, second is delivered
+			require.NoError(t, st.FailDelivery(ctx, id1, "connection refused", time.Now().Add(time.Hour), false))
+			require.NoError(t, st.CompleteDelivery(ctx, id2))
+			due, err = st.DueDeliveries(ctx, time.Now().Add(time.Second), 10)
+			require.NoError(t, err)
+			assert.Empty(t, due)
+			due, err = st.DueDeliveries(ctx, time.Now().Add(2*time.Hour), 10)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, 1, due[0].Attempts)
+			assert.Equal(t, "connection refused", due[0].LastError)
+
+			// out of attempts
+			require.NoError(t, st.FailDelivery(ctx, id1, "503", time.Now(), true))
+			due, err = st.DueDeliveries(ctx, time.Now().Add(2*time.Hour), 10)
+			require.NoError(t, err)
+			assert.Empty(t, due, "dead deliveries are not due")
+			dead, err := st.DeadDeliveries(ctx)
+			require.NoError(t, err)
+			require.Len(t, dead, 1)
+			assert.True(t, dead[0].Dead)
+			assert.Equal(t, 2, dead[0].Attempts)
+			assert.Equal(t, "503", dead[0].LastError)
+
+			require.ErrorIs(t, st.RedriveDelivery(ctx, id2), ErrNotFound, "completed delivery")
+			require.NoError(t, st.RedriveDelivery(ctx, id1))
+			require.ErrorIs(t, st.RedriveDelivery(ctx, id1), ErrNotFound, "not dead anymore")
+			dead, err = st.DeadDeliveries(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, dead)
+			due, err = st.DueDeliveries(ctx, time.Now().Add(time.Second), 10)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, 0, due[0].Attempts, "redrive resets attempts")
+		})
+	}
+}
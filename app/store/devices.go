@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/jmoiron/sqlx"
+)
+
+// SessionDevice describes where a session was logged in from.
+type SessionDevice struct {
+	Device    string `db:"device"`     // browser and OS, e.g. "Firefox on Linux"
+	UserAgent string `db:"user_agent"` // raw User-Agent header
+	IP        string `db:"ip"`
+	Location  string `db:"location"` // approximate location, empty if unknown
+}
+
+// SetSessionDevice records the device of a session and remembers the device for the session user.
+// Returns true if the device is new for a user who logged in from other devices before. The first
+// known device of a user is not reported as new, so users are not notified on their first login.
+// Returns ErrNotFound if the session doesn't exist.
+func (s *Store) SetSessionDevice(ctx context.Context, token string, dev SessionDevice) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var username string
+	if err := tx.GetContext(ctx, &username, s.adoptQuery("SELECT username FROM sessions WHERE token = ?"), token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNotFound
+		}
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	query := s.adoptQuery("UPDATE sessions SET device = ?, user_agent = ?, ip = ?, location = ? WHERE token = ?")
+	if _, err := tx.ExecContext(ctx, query, dev.Device, dev.UserAgent, dev.IP, dev.Location, token); err != nil {
+		return false, fmt.Errorf("failed to set session device: %w", err)
+	}
+	newDevice, err := s.rememberDevice(ctx, tx, username, dev.Device)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit session device: %w", err)
+	}
+	if newDevice {
+		log.Printf("[DEBUG] new login device %q for user %q", dev.Device, username)
+	}
+	return newDevice, nil
+}
+
+// rememberDevice stores the device of a user login, returns true if the user has other known devices
+// but not this one. Empty device is not remembered.
+func (s *Store) rememberDevice(ctx context.Context, tx *sqlx.Tx, username, device string) (bool, error) {
+	if device == "" {
+		return false, nil
+	}
+	var known, same int
+	query := s.adoptQuery("SELECT COUNT(*), COALESCE(SUM(CASE WHEN device = ? THEN 1 ELSE 0 END), 0) FROM login_devices WHERE username = ?")
+	if err := tx.QueryRowxContext(ctx, query, device, username).Scan(&known, &same); err != nil {
+		return false, fmt.Errorf("failed to check login devices: %w", err)
+	}
+	query = s.adoptQuery(`INSERT INTO login_devices (username, device, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT(username, device) DO UPDATE SET last_seen = excluded.last_seen`)
+	if _, err := tx.ExecContext(ctx, query, username, device, time.Now().UTC()); err != nil {
+		return false, fmt.Errorf("failed to remember login device: %w", err)
+	}
+	return known > 0 && same == 0, nil
+}
+
+// UserSessions returns the active sessions of a user, the latest first.
+func (s *Store) UserSessions(ctx context.Context, username string) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []Session
+	query := s.adoptQuery(`SELECT token, username, created_at, expires_at, remember, device, user_agent, ip, location
+		FROM sessions WHERE username = ? AND expires_at > ? ORDER BY created_at DESC`)
+	if err := s.db.SelectContext(ctx, &res, query, username, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get sessions of user %q: %w", username, err)
+	}
+	for i := range res {
+		res[i].CreatedAt, res[i].ExpiresAt = res[i].CreatedAt.UTC(), res[i].ExpiresAt.UTC()
+	}
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SessionDevice(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			user := "device-user-" + engine
+			expires := time.Now().Add(time.Hour).UTC()
+			login := func(token string, dev SessionDevice) bool {
+				t.Helper()
+				require.NoError(t, st.CreateSession(ctx, token, user, expires, false))
+				newDevice, err := st.SetSessionDevice(ctx, token, dev)
+				require.NoError(t, err)
+				return newDevice
+			}
+			laptop := SessionDevice{Device: "Firefox on Linux", UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/140.0",
+				IP: "10.0.0.1", Location: "DE"}
+			phone := SessionDevice{Device: "Safari on iOS", UserAgent: "Mozilla/5.0 (iPhone) Safari/604.1", IP: "10.0.0.2"}
+
+			assert.False(t, login("dev-1-"+engine, laptop), "first device of a user is not new")
+			assert.False(t, login("dev-2-"+engine, laptop), "known device")
+			assert.True(t, login("dev-3-"+engine, phone), "other device after the first one")
+			assert.False(t, login("dev-4-"+engine, phone))
+			assert.False(t, login("dev-5-"+engine, SessionDevice{IP: "10.0.0.3"}), "unknown device is not remembered")
+
+			sess, err := st.GetSession(ctx, "dev-1-"+engine)
+			require.NoError(t, err)
+			assert.Equal(t, laptop, sess.SessionDevice)
+
+			sessions, err := st.UserSessions(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, sessions, 5)
+			tokens := map[string]SessionDevice{}
+			for _, s := range sessions {
+				assert.Equal(t, user, s.Username)
+				tokens[s.Token] = s.SessionDevice
+			}
+			assert.Equal(t, phone, tokens["dev-3-"+engine])
+
+			require.NoError(t, st.CreateSession(ctx, "dev-expired-"+engine, user, time.Now().Add(-time.Hour), false))
+			sessions, err = st.UserSessions(ctx, user)
+			require.NoError(t, err)
+			assert.Len(t, sessions, 5, "expired sessions are skipped")
+
+			_, err = st.SetSessionDevice(ctx, "no-such-session", laptop)
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirFormats maps key file extensions to value formats, the extension is not a part of the key.
+// Files with other extensions are text keys named by the full file name.
+var dirFormats = map[string]string{".txt": "text", ".json": "json", ".yaml": "yaml", ".yml": "yaml", ".xml": "xml",
+	".toml": "toml", ".ini": "ini", ".hcl": "hcl", ".sh": "shell"}
+
+// FileKey returns the key and format of a key file by its slash separated path relative to the key directory.
+// The format is empty for files without a format extension, the full path is the key then.
+func FileKey(rel string) (key, format string) {
+	ext := path.Ext(rel)
+	if f, ok := dirFormats[strings.ToLower(ext)]; ok {
+		return NormalizeKey(strings.TrimSuffix(rel, ext)), f
+	}
+	return NormalizeKey(rel), ""
+}
+
+// KeyFile returns the slash separated path of a new file of the key, the key with the format extension.
+func KeyFile(key, format string) string {
+	switch format {
+	case "yaml", "json", "xml", "toml", "ini", "hcl":
+		return key + "." + format
+	case "shell":
+		return key + ".sh"
+	default:
+		return key + ".txt"
+	}
+}
+
+// LoadDir stores every file under dir as a key and returns the file of each key, for stash dev.
+// The key is the relative path without the format extension, e.g. app/db.json is the json key app/db.
+// Hidden files and directories are skipped. Two files mapping to the same key are an error.
+func LoadDir(ctx context.Context, st Interface, dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		key, format := FileKey(filepath.ToSlash(rel))
+		if format == "" {
+			format = "text"
+		}
+		if prev, ok := files[key]; ok {
+			return fmt.Errorf("%s and %s are both key %q", prev, rel, key)
+		}
+		value, err := os.ReadFile(p) //nolint:gosec // files of the dev directory set by the user
+		if err != nil {
+			return err
+		}
+		if _, err := st.Set(ctx, key, value, format); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		files[key] = rel
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// DirMirror wraps a store and writes changed keys back to files of a directory loaded by LoadDir.
+// Keys keep their files, new keys get a file named by the key with the format extension.
+// Failed file writes are returned after the store is changed, the directory is a copy for development.
+type DirMirror struct {
+	Interface
+	dir   string
+	mu    sync.Mutex
+	files map[string]string // key -> file relative to dir
+}
+
+// NewDirMirror makes a store writing changes back to the dir, files are key files returned by LoadDir.
+func NewDirMirror(st Interface, dir string, files map[string]string) *DirMirror {
+	return &DirMirror{Interface: st, dir: dir, files: files}
+}
+
+// Set stores the value and writes it to the key file.
+func (m *DirMirror) Set(ctx context.Context, key string, value []byte, format string) (bool, error) {
+	created, err := m.Interface.Set(ctx, key, value, format)
+	if err != nil {
+		return false, err //nolint:wrapcheck // store errors are passed as is
+	}
+	return created, m.write(key, value, format)
+}
+
+// SetWithOptions stores the value with its options and writes it to the key file.
+func (m *DirMirror) SetWithOptions(ctx context.Context, key string, value []byte, format string, opts SetOptions) (bool, error) {
+	created, err := m.Interface.SetWithOptions(ctx, key, value, format, opts)
+	if err != nil {
+		return false, err //nolint:wrapcheck // store errors are passed as is
+	}
+	return created, m.write(key, value, format)
+}
+
+// SetWithVersion stores the value with version check and writes it to the key file.
+func (m *DirMirror) SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error {
+	if err := m.Interface.SetWithVersion(ctx, key, value, format, expectedVersion); err != nil {
+		return err //nolint:wrapcheck // callers check conflict error types
+	}
+	return m.write(key, value, format)
+}
+
+// Delete removes the key and its file.
+func (m *DirMirror) Delete(ctx context.Context, key string) error {
+	if err := m.Interface.Delete(ctx, key); err != nil {
+		return err //nolint:wrapcheck // callers check ErrNotFound
+	}
+	return m.remove(key)
+}
+
+// Rollback sets the key to a previous value and writes it to the key file.
+func (m *DirMirror) Rollback(ctx context.Context, key string, version int64, owner string) (Revision, bool, error) {
+	rev, created, err := m.Interface.Rollback(ctx, key, version, owner)
+	if err != nil {
+		return Revision{}, false, err //nolint:wrapcheck // callers check ErrNotFound
+	}
+	return rev, created, m.write(key, rev.Value, rev.Format)
+}
+
+// DeleteExpired removes expired keys and their files.
+func (m *DirMirror) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	keys, err := m.Interface.DeleteExpired(ctx, now)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // store errors are passed as is
+	}
+	for _, key := range keys {
+		if err := m.remove(key); err != nil {
+			return keys, err
+		}
+	}
+	return keys, nil
+}
+
+// remove deletes the key file, if the key has one.
+func (m *DirMirror) remove(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[key]
+	if !ok {
+		return nil
+	}
+	delete(m.files, key)
+	if err := os.Remove(filepath.Join(m.dir, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove key file: %w", err)
+	}
+	return nil
+}
+
+// write saves the value to the key file. A key with a changed format moves to the file with the new
+// extension, unless its file has no format extension.
+func (m *DirMirror) write(key string, value []byte, format string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[key]
+	if _, f := FileKey(filepath.ToSlash(file)); ok && f != "" && f != format {
+		if err := os.Remove(filepath.Join(m.dir, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove key file: %w", err)
+		}
+		ok = false
+	}
+	if !ok {
+		file = filepath.FromSlash(KeyFile(key, format))
+	}
+	if !filepath.IsLocal(file) {
+		return fmt.Errorf("key %q is outside of the directory", key)
+	}
+
+	full := filepath.Join(m.dir, file)
+	if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+		return fmt.Errorf("failed to make key directory: %w", err)
+	}
+	if err := os.WriteFile(full, value, 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	m.files[key] = file
+	return nil
+}
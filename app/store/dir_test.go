@@ -0,0 +1,137 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileKey(t *testing.T) {
+	tbl := []struct{ rel, key, format string }{
+		{"app/db.json", "app/db", "json"},
+		{"app/flags.YML", "app/flags", "yaml"},
+		{"run.sh", "run", "shell"},
+		{"app/VERSION", "app/VERSION", ""},
+		{"app/my notes.md", "app/my_notes.md", ""},
+	}
+	for _, tt := range tbl {
+		key, format := FileKey(tt.rel)
+		assert.Equal(t, tt.key, key, tt.rel)
+		assert.Equal(t, tt.format, format, tt.rel)
+	}
+	assert.Equal(t, "app/db.json", KeyFile("app/db", "json"))
+	assert.Equal(t, "run.sh", KeyFile("run", "shell"))
+	assert.Equal(t, "app/name.txt", KeyFile("app/name", "text"))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app/db.json":         `{"port":5432}`,
+		"app/flags.YML":       "a: true",
+		"app/name.txt":        "svc",
+		"app/VERSION":         "1.2.3",
+		"app/run.sh":          "export A=1",
+		".git/HEAD":           "ref",
+		"app/.DS_Store":       "x",
+		"shared/secrets/pass": "pw",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	crypto, err := NewCrypto([]byte("1234567890123456"))
+	require.NoError(t, err)
+	st, err := New(":memory:", WithEncryptor(crypto))
+	require.NoError(t, err)
+	defer st.Close()
+
+	files, err := LoadDir(t.Context(), st, dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app/db": "app/db.json", "app/flags": "app/flags.YML", "app/name": "app/name.txt",
+		"app/VERSION": "app/VERSION", "app/run": "app/run.sh", "shared/secrets/pass": "shared/secrets/pass"}, files)
+
+	for key, want := range map[string][2]string{"app/db": {`{"port":5432}`, "json"}, "app/flags": {"a: true", "yaml"},
+		"app/name": {"svc", "text"}, "app/VERSION": {"1.2.3", "text"}, "app/run": {"export A=1", "shell"},
+		"shared/secrets/pass": {"pw", "text"}} {
+		value, format, err := st.GetWithFormat(t.Context(), key)
+		require.NoError(t, err, key)
+		assert.Equal(t, want, [2]string{string(value), format}, key)
+	}
+
+	t.Run("conflicting files", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app/db.yaml"), []byte("port: 1"), 0o600))
+		_, err := LoadDir(t.Context(), st, dir)
+		require.ErrorContains(t, err, `are both key "app/db"`)
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		_, err := LoadDir(t.Context(), st, filepath.Join(dir, "missing"))
+		require.Error(t, err)
+	})
+}
+
+func TestDirMirror(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "app"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app/db.json"), []byte(`{}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app/VERSION"), []byte("1"), 0o600))
+
+	st, err := New(":memory:")
+	require.NoError(t, err)
+	defer st.Close()
+	files, err := LoadDir(t.Context(), st, dir)
+	require.NoError(t, err)
+	m := NewDirMirror(st, dir, files)
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	_, err = m.Set(t.Context(), "app/db", []byte(`{"port":1}`), "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":1}`, read("app/db.json"), "existing file updated")
+
+	_, err = m.Set(t.Context(), "app/db", []byte("port: 2"), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "port: 2", read("app/db.yaml"), "format change moves the file")
+	assert.NoFileExists(t, filepath.Join(dir, "app/db.json"))
+
+	_, err = m.Set(t.Context(), "app/VERSION", []byte(`{"v":2}`), "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":2}`, read("app/VERSION"), "file without format extension kept")
+
+	_, err = m.Set(t.Context(), "new/deep/key", []byte("v"), "text")
+	require.NoError(t, err)
+	assert.Equal(t, "v", read("new/deep/key.txt"))
+	value, err := st.Get(t.Context(), "new/deep/key")
+	require.NoError(t, err)
+	assert.Equal(t, "v", string(value), "stored too")
+
+	info, err := st.GetInfo(t.Context(), "new/deep/key")
+	require.NoError(t, err)
+	require.NoError(t, m.SetWithVersion(t.Context(), "new/deep/key", []byte("v2"), "text", info.UpdatedAt))
+	assert.Equal(t, "v2", read("new/deep/key.txt"))
+	_, err = m.SetWithOptions(t.Context(), "new/deep/key", []byte("v3"), "text", SetOptions{ExpiresAt: time.Now().Add(time.Second)})
+	require.NoError(t, err)
+	assert.Equal(t, "v3", read("new/deep/key.txt"))
+
+	require.NoError(t, m.Delete(t.Context(), "app/db"))
+	assert.NoFileExists(t, filepath.Join(dir, "app/db.yaml"))
+	require.ErrorIs(t, m.Delete(t.Context(), "app/db"), ErrNotFound)
+
+	deleted, err := m.DeleteExpired(t.Context(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new/deep/key"}, deleted)
+	assert.NoFileExists(t, filepath.Join(dir, "new/deep/key.txt"), "expired key file removed")
+
+	_, err = m.Set(t.Context(), "../outside", []byte("x"), "text")
+	require.EqualError(t, err, `key "../outside" is outside of the directory`)
+	assert.NoFileExists(t, filepath.Join(dir, "../outside.txt"))
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// ErrFrozen is returned when a write is rejected because the key is under a frozen prefix.
+var ErrFrozen = errors.New("prefix frozen")
+
+// Freeze stops changes of keys under the prefix until it expires or is lifted, to keep configuration
+// stable during an outage. Incident is the reference shown to writers, like "INC-4521".
+type Freeze struct {
+	Prefix    string    `json:"prefix" db:"prefix"`
+	Incident  string    `json:"incident" db:"incident"`
+	Message   string    `json:"message,omitempty" db:"message"`
+	FrozenBy  string    `json:"frozen_by,omitempty" db:"frozen_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// Covers reports whether the key is frozen by the freeze: the prefix itself and keys below it,
+// "prod" covers "prod/db" but not "production".
+func (f Freeze) Covers(key string) bool {
+	return key == f.Prefix || strings.HasPrefix(key, f.Prefix+"/")
+}
+
+// FrozenError wraps ErrFrozen with the freeze that rejected the write.
+type FrozenError struct {
+	Key    string
+	Freeze Freeze
+}
+
+// Error returns the message for the writer, with the incident and the end of the freeze.
+func (e *FrozenError) Error() string {
+	msg := fmt.Sprintf("key %q is frozen for incident %s until %s", e.Key, e.Freeze.Incident,
+		e.Freeze.ExpiresAt.UTC().Format(time.RFC3339))
+	if e.Freeze.Message != "" {
+		msg += ": " + e.Freeze.Message
+	}
+	return msg
+}
+
+// Unwrap returns the underlying ErrFrozen sentinel.
+func (e *FrozenError) Unwrap() error {
+	return ErrFrozen
+}
+
+// FreezePrefix freezes the prefix until f.ExpiresAt, replacing the freeze of the same prefix, e.g. to extend it.
+// The prefix is normalized as keys are. Expired freezes are dropped along the way.
+func (s *Store) FreezePrefix(ctx context.Context, f Freeze) error {
+	f.Prefix = NormalizeKey(f.Prefix)
+	now := time.Now().UTC()
+	switch {
+	case f.Prefix == "":
+		return errors.New("freeze prefix is required")
+	case f.Incident == "":
+		return errors.New("freeze incident is required")
+	case !f.ExpiresAt.After(now):
+		return errors.New("freeze expiration must be in the future")
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM freezes WHERE expires_at <= ?"), now); err != nil {
+		return fmt.Errorf("failed to drop expired freezes: %w", err)
+	}
+	query := s.adoptQuery(`INSERT INTO freezes (prefix, incident, message, frozen_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT(prefix) DO UPDATE SET incident = excluded.incident,
+		message = excluded.message, frozen_by = excluded.frozen_by, created_at = excluded.created_at,
+		expires_at = excluded.expires_at`)
+	_, err := s.db.ExecContext(ctx, query, f.Prefix, f.Incident, f.Message, f.FrozenBy, f.CreatedAt.UTC(), f.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to freeze prefix %q: %w", f.Prefix, err)
+	}
+	log.Printf("[DEBUG] freeze prefix %q until %s, incident %s", f.Prefix, f.ExpiresAt.Format(time.RFC3339), f.Incident)
+	return nil
+}
+
+// Unfreeze lifts the freeze of the prefix before it expires. Returns ErrNotFound if the prefix isn't frozen.
+func (s *Store) Unfreeze(ctx context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix = NormalizeKey(prefix)
+	query := s.adoptQuery("DELETE FROM freezes WHERE prefix = ? AND expires_at > ?")
+	result, err := s.db.ExecContext(ctx, query, prefix, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to unfreeze prefix %q: %w", prefix, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	log.Printf("[DEBUG] unfreeze prefix %q", prefix)
+	return nil
+}
+
+// Freezes returns the active freezes ordered by prefix.
+func (s *Store) Freezes(ctx context.Context) ([]Freeze, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeFreezes(ctx)
+}
+
+// activeFreezes returns the freezes not expired yet, must be called with lock held.
+func (s *Store) activeFreezes(ctx context.Context) ([]Freeze, error) {
+	query := s.adoptQuery(`SELECT prefix, incident, message, frozen_by, created_at, expires_at FROM freezes
+		WHERE expires_at > ? ORDER BY prefix`)
+	var res []Freeze
+	if err := s.db.SelectContext(ctx, &res, query, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get freezes: %w", err)
+	}
+	return res, nil
+}
+
+// checkFrozen returns *FrozenError if one of the keys is under an active freeze, must be called with lock held.
+func (s *Store) checkFrozen(ctx context.Context, keys ...string) error {
+	freezes, err := s.activeFreezes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range freezes {
+		for _, key := range keys {
+			if f.Covers(key) {
+				return &FrozenError{Key: key, Freeze: f}
+			}
+		}
+	}
+	return nil
+}
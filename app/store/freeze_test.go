@@ -0,0 +1,100 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_FreezePrefix(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			prefix := "freeze-" + engine
+			_, err := st.Set(ctx, prefix+"/db", []byte("v1"), "text")
+			require.NoError(t, err)
+
+			until := time.Now().Add(time.Hour)
+			require.NoError(t, st.FreezePrefix(ctx, Freeze{Prefix: "/" + prefix + "/", Incident: "INC-1", Message: "db failover",
+				FrozenBy: "admin", ExpiresAt: until}))
+			t.Cleanup(func() { _ = st.Unfreeze(ctx, prefix) })
+
+			freezes, err := st.Freezes(ctx)
+			require.NoError(t, err)
+			require.Len(t, freezes, 1)
+			assert.Equal(t, prefix, freezes[0].Prefix, "normalized")
+			assert.Equal(t, "INC-1", freezes[0].Incident)
+			assert.Equal(t, "admin", freezes[0].FrozenBy)
+			assert.WithinDuration(t, until, freezes[0].ExpiresAt, time.Second)
+
+			_, err = st.Set(ctx, prefix+"/db", []byte("v2"), "text")
+			var frozen *FrozenError
+			require.ErrorAs(t, err, &frozen)
+			require.ErrorIs(t, err, ErrFrozen)
+			assert.Equal(t, prefix+"/db", frozen.Key)
+			assert.Contains(t, err.Error(), "frozen for incident INC-1 until")
+			assert.Contains(t, err.Error(), ": db failover")
+
+			info, err := st.GetInfo(ctx, prefix+"/db")
+			require.NoError(t, err)
+			require.ErrorIs(t, st.SetWithVersion(ctx, prefix+"/db", []byte("v2"), "text", info.UpdatedAt), ErrFrozen)
+			require.ErrorIs(t, st.Delete(ctx, prefix+"/db"), ErrFrozen)
+			_, err = st.Set(ctx, prefix, []byte("v"), "text")
+			require.ErrorIs(t, err, ErrFrozen, "the prefix key itself is frozen")
+			_, err = st.Txn(ctx, []TxnOp{{Key: prefix + "-other/a", Value: []byte("v")}, {Key: prefix + "/new", Value: []byte("v")}})
+			require.ErrorIs(t, err, ErrFrozen)
+			_, err = st.Get(ctx, prefix+"-other/a")
+			require.ErrorIs(t, err, ErrNotFound, "nothing written by rejected transaction")
+
+			_, err = st.Set(ctx, prefix+"-other/a", []byte("v"), "text")
+			require.NoError(t, err, "sibling prefix is not frozen")
+			value, err := st.Get(ctx, prefix+"/db")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", string(value), "reads are not affected")
+
+			require.NoError(t, st.Unfreeze(ctx, prefix))
+			require.ErrorIs(t, st.Unfreeze(ctx, prefix), ErrNotFound)
+			_, err = st.Set(ctx, prefix+"/db", []byte("v2"), "text")
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStore_FreezeExpiration(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			prefix := "freeze-exp-" + engine
+
+			require.NoError(t, st.FreezePrefix(ctx, Freeze{Prefix: prefix, Incident: "INC-2", ExpiresAt: time.Now().Add(time.Hour)}))
+			// replacing the freeze changes its expiration, as an admin shortening it would
+			require.NoError(t, st.FreezePrefix(ctx, Freeze{Prefix: prefix, Incident: "INC-2", ExpiresAt: time.Now().Add(300 * time.Millisecond)}))
+			_, err := st.Set(ctx, prefix+"/a", []byte("v"), "text")
+			require.ErrorIs(t, err, ErrFrozen)
+
+			time.Sleep(400 * time.Millisecond)
+			_, err = st.Set(ctx, prefix+"/a", []byte("v"), "text")
+			require.NoError(t, err, "freeze expired")
+			freezes, err := st.Freezes(ctx)
+			require.NoError(t, err)
+			for _, f := range freezes {
+				assert.NotEqual(t, prefix, f.Prefix)
+			}
+			require.ErrorIs(t, st.Unfreeze(ctx, prefix), ErrNotFound, "expired freeze can't be lifted")
+		})
+	}
+}
+
+func TestStore_FreezePrefixInvalid(t *testing.T) {
+	st := newTestStore(t, "sqlite")
+	until := time.Now().Add(time.Hour)
+	require.EqualError(t, st.FreezePrefix(t.Context(), Freeze{Prefix: "/", Incident: "INC-1", ExpiresAt: until}),
+		"freeze prefix is required")
+	require.EqualError(t, st.FreezePrefix(t.Context(), Freeze{Prefix: "app", ExpiresAt: until}), "freeze incident is required")
+	require.EqualError(t, st.FreezePrefix(t.Context(), Freeze{Prefix: "app", Incident: "INC-1", ExpiresAt: time.Now()}),
+		"freeze expiration must be in the future")
+}
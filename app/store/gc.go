@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/stash/app/enum"
+	"github.com/umputun/stash/lib/stash"
+)
+
+// GCReport lists keys that look like garbage, candidates for removal after a review.
+type GCReport struct {
+	Empty   []KeyInfo // keys with empty values, encrypted ones included if their key is configured
+	StaleZK []KeyInfo // ZK-encrypted keys not updated and not read since the cutoff
+	// AuditSince is the time of the oldest audit entry, zero if the audit log is empty. Reads before
+	// it are unknown, so stale ZK keys are reliable only if it is before the cutoff.
+	AuditSince time.Time
+}
+
+// GCReport finds keys with empty values and ZK-encrypted keys not updated and not read since unreadSince.
+// Reads come from the audit log, a ZK key is read if it has a successful read audited since the cutoff.
+func (s *Store) GCReport(ctx context.Context, unreadSince time.Time) (GCReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res GCReport
+	empty, err := s.selectKeyInfos(ctx, s.adoptQuery(listSelect+" WHERE length(value) = 0 ORDER BY key"))
+	if err != nil {
+		return GCReport{}, fmt.Errorf("failed to find empty keys: %w", err)
+	}
+	if res.Empty, err = s.appendEmptyEncrypted(ctx, empty); err != nil {
+		return GCReport{}, err
+	}
+
+	// ZK values start with "$ZK$", see stash.IsZKEncrypted
+	staleQuery := listSelect + ` WHERE SUBSTR(value, 1, 4) = ? AND updated_at < ? AND NOT EXISTS (
+		SELECT 1 FROM audit_log a WHERE a.key = kv.key AND a.action IN (?, ?) AND a.result = ? AND a.timestamp >= ?)
+		ORDER BY key`
+	res.StaleZK, err = s.selectKeyInfos(ctx, s.adoptQuery(staleQuery), []byte("$ZK$"), unreadSince.UTC(),
+		"read", "canary", "success", unreadSince.Format(time.RFC3339))
+	if err != nil {
+		return GCReport{}, fmt.Errorf("failed to find stale zk keys: %w", err)
+	}
+
+	var oldest sql.NullString
+	if err := s.db.GetContext(ctx, &oldest, "SELECT MIN(timestamp) FROM audit_log"); err != nil &&
+		!errors.Is(err, sql.ErrNoRows) {
+		return GCReport{}, fmt.Errorf("failed to get oldest audit entry: %w", err)
+	}
+	if oldest.Valid {
+		if res.AuditSince, err = time.Parse(time.RFC3339, oldest.String); err != nil {
+			log.Printf("[WARN] failed to parse audit timestamp %q: %v", oldest.String, err)
+		}
+	}
+	return res, nil
+}
+
+// appendEmptyEncrypted adds secrets and values encrypted at rest with empty decrypted values, their stored
+// values are never empty. They can't be checked without their key and are skipped then, ZK-encrypted
+// values are never empty.
+func (s *Store) appendEmptyEncrypted(ctx context.Context, empty []KeyInfo) ([]KeyInfo, error) {
+	type row struct {
+		Key       string `db:"key"`
+		Value     []byte `db:"value"`
+		Encrypted bool   `db:"encrypted"`
+	}
+	var rows []row
+	if s.SecretsEnabled() {
+		where, args := listConditions(ListQuery{Filter: enum.SecretsFilterSecretsOnly})
+		if err := s.db.SelectContext(ctx, &rows, s.adoptQuery("SELECT key, value, encrypted FROM kv"+where), args...); err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+	}
+	if s.DataEncryptionEnabled() {
+		var data []row
+		query := "SELECT key, value, encrypted FROM kv WHERE encrypted"
+		if err := s.db.SelectContext(ctx, &data, query); err != nil {
+			return nil, fmt.Errorf("failed to list values encrypted at rest: %w", err)
+		}
+		rows = append(rows, data...)
+	}
+	var names []string
+	for _, r := range rows {
+		if len(r.Value) == 0 || stash.IsZKEncrypted(r.Value) {
+			continue // plain empty values are found already
+		}
+		value, err := s.openValue(r.Key, r.Value, r.Encrypted)
+		if err != nil {
+			log.Printf("[WARN] gc: can't decrypt %q, skipped: %v", r.Key, err)
+			continue
+		}
+		if len(value) == 0 {
+			names = append(names, r.Key)
+		}
+	}
+	if len(names) == 0 {
+		return empty, nil
+	}
+	slices.Sort(names)
+	infos, err := s.keyInfosByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	return append(empty, infos...), nil
+}
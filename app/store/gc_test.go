@@ -0,0 +1,68 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestStore_GCReport(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStoreWithEncryptor(t, engine)
+			ctx := t.Context()
+			yearAgo := time.Now().Add(-365 * 24 * time.Hour)
+
+			for key, value := range map[string]string{
+				"app/empty": "", "app/full": "v", "app/secrets/empty": "", "app/secrets/full": "v",
+				"zk/old": "$ZK$b2xk", "zk/old-read": "$ZK$cmVhZA", "zk/fresh": "$ZK$ZnJlc2g",
+			} {
+				_, err := st.Set(ctx, key, []byte(value), "text")
+				require.NoError(t, err)
+			}
+			for _, key := range []string{"zk/old", "zk/old-read"} {
+				_, err := st.db.ExecContext(ctx, st.adoptQuery("UPDATE kv SET updated_at = ? WHERE key = ?"),
+					yearAgo.Add(-time.Hour).UTC(), key)
+				require.NoError(t, err)
+			}
+			auditSince := time.Now().Add(-400 * 24 * time.Hour).Truncate(time.Second)
+			require.NoError(t, st.LogAudit(ctx, AuditEntry{Timestamp: auditSince, Action: enum.AuditActionCreate,
+				Key: "zk/old", Actor: "alice", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess}))
+			require.NoError(t, st.LogAudit(ctx, AuditEntry{Timestamp: time.Now().Add(-24 * time.Hour), Action: enum.AuditActionRead,
+				Key: "zk/old-read", Actor: "alice", ActorType: enum.ActorTypeUser, Result: enum.AuditResultSuccess}))
+			require.NoError(t, st.LogAudit(ctx, AuditEntry{Timestamp: time.Now().Add(-24 * time.Hour), Action: enum.AuditActionRead,
+				Key: "zk/old", Actor: "bob", ActorType: enum.ActorTypeUser, Result: enum.AuditResultDenied}))
+
+			report, err := st.GCReport(ctx, yearAgo)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"app/empty", "app/secrets/empty"}, gcKeys(report.Empty))
+			assert.Equal(t, []string{"zk/old"}, gcKeys(report.StaleZK), "denied reads don't count")
+			assert.True(t, report.StaleZK[0].ZKEncrypted)
+			assert.True(t, auditSince.Equal(report.AuditSince), "got %v", report.AuditSince)
+		})
+	}
+}
+
+func TestStore_GCReport_NoSecretsKey(t *testing.T) {
+	st := newTestStore(t, "sqlite")
+	_, err := st.Set(t.Context(), "app/empty", []byte{}, "text")
+	require.NoError(t, err)
+
+	report, err := st.GCReport(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app/empty"}, gcKeys(report.Empty))
+	assert.Empty(t, report.StaleZK)
+	assert.True(t, report.AuditSince.IsZero(), "empty audit log")
+}
+
+func gcKeys(keys []KeyInfo) []string {
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, k.Key)
+	}
+	return res
+}
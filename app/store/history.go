@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/jmoiron/sqlx"
+)
+
+// Revision is a previous value of a key, kept in the kv_history table.
+type Revision struct {
+	Version   int64     `db:"id"` // increases with every archived value, unique across keys
+	Value     []byte    `db:"value"`
+	Format    string    `db:"format"`
+	UpdatedAt time.Time `db:"updated_at"` // when the value was set
+}
+
+// revisionRow is a row of kv_history with the flag of values encrypted at rest.
+type revisionRow struct {
+	Revision
+	Encrypted bool `db:"encrypted"`
+}
+
+// WithHistory keeps up to revisions previous values of each key in the database, independent of git.
+// Values are archived when a key is updated or deleted, zero disables the history.
+func WithHistory(revisions int) Option {
+	return func(s *Store) {
+		s.historyLimit = revisions
+	}
+}
+
+// HistoryEnabled returns true if the store keeps previous values of keys.
+func (s *Store) HistoryEnabled() bool {
+	return s.historyLimit > 0
+}
+
+// GetHistory returns up to limit previous values of the key, newest first, limit 0 returns all kept.
+// Secrets and values encrypted at rest are decrypted, ZK-encrypted values are returned as stored.
+// The history of a deleted key is kept, so the key can be rolled back. Returns an empty list for keys
+// without history.
+func (s *Store) GetHistory(ctx context.Context, key string, limit int) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if IsSecret(key) && !s.SecretsEnabled() {
+		return nil, ErrSecretsNotConfigured
+	}
+
+	query := "SELECT id, value, format, updated_at, encrypted FROM kv_history WHERE key = ? ORDER BY id DESC"
+	args := []any{key}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	var rows []revisionRow
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to get history of key %q: %w", key, err)
+	}
+	res := make([]Revision, len(rows))
+	for i, r := range rows {
+		value, err := s.openValue(key, r.Value, r.Encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt version %d of key %q: %w", r.Version, key, err)
+		}
+		res[i] = r.Revision
+		res[i].Value = value
+	}
+	return res, nil
+}
+
+// GetVersion returns a previous value of the key by its version, as listed by GetHistory.
+// Returns ErrNotFound if the key has no such version.
+func (s *Store) GetVersion(ctx context.Context, key string, version int64) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getVersion(ctx, key, version)
+}
+
+// getVersion returns a previous value of the key, must be called with lock held.
+func (s *Store) getVersion(ctx context.Context, key string, version int64) (Revision, error) {
+	if IsSecret(key) && !s.SecretsEnabled() {
+		return Revision{}, ErrSecretsNotConfigured
+	}
+
+	var row revisionRow
+	query := s.adoptQuery("SELECT id, value, format, updated_at, encrypted FROM kv_history WHERE key = ? AND id = ?")
+	err := s.db.GetContext(ctx, &row, query, key, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Revision{}, ErrNotFound
+	}
+	if err != nil {
+		return Revision{}, fmt.Errorf("failed to get version %d of key %q: %w", version, key, err)
+	}
+	res := row.Revision
+	if res.Value, err = s.openValue(key, row.Value, row.Encrypted); err != nil {
+		return Revision{}, fmt.Errorf("failed to decrypt version %d of key %q: %w", version, key, err)
+	}
+	return res, nil
+}
+
+// Rollback sets the key to a previous value and format, the current value goes to the history as with
+// any update. Deleted keys are restored, with the owner recorded as for a new key.
+// Returns the restored revision and true if the key was created. Returns ErrNotFound if the key has no such version.
+func (s *Store) Rollback(ctx context.Context, key string, version int64, owner string) (rev Revision, created bool, err error) {
+	s.mu.RLock()
+	rev, err = s.getVersion(ctx, key, version)
+	s.mu.RUnlock()
+	if err != nil {
+		return Revision{}, false, err
+	}
+	if created, err = s.SetWithOptions(ctx, key, rev.Value, rev.Format, SetOptions{Owner: owner}); err != nil {
+		return Revision{}, false, fmt.Errorf("failed to roll back key %q to version %d: %w", key, version, err)
+	}
+	log.Printf("[DEBUG] rolled back key %q to version %d", key, version)
+	return rev, created, nil
+}
+
+// updateWithHistory runs a query changing or removing an existing key and returns the number of changed
+// rows. With history enabled, the current value is archived in the same transaction first, and dropped
+// with it if the query changes nothing, e.g. on a version mismatch.
+func (s *Store) updateWithHistory(ctx context.Context, key, query string, args ...any) (int64, error) {
+	if !s.HistoryEnabled() {
+		result, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err //nolint:wrapcheck // callers wrap with the key and operation
+		}
+		return result.RowsAffected() //nolint:wrapcheck // callers wrap with the key and operation
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = s.archive(ctx, tx, key); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // callers wrap with the key and operation
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows == 0 {
+		return 0, err //nolint:wrapcheck // callers wrap with the key and operation
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return rows, nil
+}
+
+// archive copies the current value of the key to kv_history and drops revisions over the limit.
+func (s *Store) archive(ctx context.Context, tx *sqlx.Tx, key string) error {
+	insert := s.adoptQuery(`INSERT INTO kv_history (key, value, format, updated_at, encrypted)
+		SELECT key, value, format, updated_at, encrypted FROM kv WHERE key = ?`)
+	if _, err := tx.ExecContext(ctx, insert, key); err != nil {
+		return fmt.Errorf("failed to archive key %q: %w", key, err)
+	}
+	prune := s.adoptQuery(`DELETE FROM kv_history WHERE key = ? AND id NOT IN (
+		SELECT id FROM kv_history WHERE key = ? ORDER BY id DESC LIMIT ?)`)
+	if _, err := tx.ExecContext(ctx, prune, key, key, s.historyLimit); err != nil {
+		return fmt.Errorf("failed to prune history of key %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_History(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			enc, err := NewCrypto([]byte("test-secret-key-1234"))
+			require.NoError(t, err)
+			st := newTestStore(t, engine, WithEncryptor(enc), WithHistory(3))
+			ctx := t.Context()
+			prefix := "history/" + engine + "/"
+			values := func(revs []Revision) (res []string) {
+				for _, r := range revs {
+					res = append(res, string(r.Value))
+				}
+				return res
+			}
+
+			t.Run("keeps limited previous values", func(t *testing.T) {
+				key := prefix + "app/config"
+				for _, v := range []string{"v1", "v2", "v3", "v4", "v5"} {
+					_, err := st.Set(ctx, key, []byte(v), "text")
+					require.NoError(t, err)
+				}
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"v4", "v3", "v2"}, values(revs), "newest first, up to the limit")
+				assert.Greater(t, revs[0].Version, revs[1].Version)
+				assert.False(t, revs[0].UpdatedAt.IsZero())
+
+				revs, err = st.GetHistory(ctx, key, 1)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"v4"}, values(revs))
+
+				revs, err = st.GetHistory(ctx, prefix+"missing", 0)
+				require.NoError(t, err)
+				assert.Empty(t, revs)
+			})
+
+			t.Run("get version and rollback", func(t *testing.T) {
+				key := prefix + "app/json"
+				_, err := st.Set(ctx, key, []byte(`{"a":1}`), "json")
+				require.NoError(t, err)
+				_, err = st.Set(ctx, key, []byte("plain"), "text")
+				require.NoError(t, err)
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				require.Len(t, revs, 1)
+
+				rev, err := st.GetVersion(ctx, key, revs[0].Version)
+				require.NoError(t, err)
+				assert.Equal(t, `{"a":1}`, string(rev.Value))
+				assert.Equal(t, "json", rev.Format)
+				_, err = st.GetVersion(ctx, prefix+"app/config", revs[0].Version)
+				require.ErrorIs(t, err, ErrNotFound, "versions belong to their key")
+
+				rev, created, err := st.Rollback(ctx, key, revs[0].Version, "")
+				require.NoError(t, err)
+				assert.False(t, created)
+				assert.Equal(t, "json", rev.Format)
+				value, format, err := st.GetWithFormat(ctx, key)
+				require.NoError(t, err)
+				assert.Equal(t, `{"a":1}`, string(value))
+				assert.Equal(t, "json", format)
+
+				revs, err = st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"plain", `{"a":1}`}, values(revs), "rolled back value is archived")
+
+				_, _, err = st.Rollback(ctx, key, 1<<40, "")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("deleted key is kept and restored", func(t *testing.T) {
+				key := prefix + "app/deleted"
+				_, err := st.Set(ctx, key, []byte("last"), "text")
+				require.NoError(t, err)
+				require.NoError(t, st.Delete(ctx, key))
+
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				require.Equal(t, []string{"last"}, values(revs))
+				_, created, err := st.Rollback(ctx, key, revs[0].Version, "user:alice")
+				require.NoError(t, err)
+				assert.True(t, created)
+				value, err := st.Get(ctx, key)
+				require.NoError(t, err)
+				assert.Equal(t, "last", string(value))
+				info, err := st.GetInfo(ctx, key)
+				require.NoError(t, err)
+				assert.Equal(t, "user:alice", info.Owner, "restored key is owned by who restored it")
+			})
+
+			t.Run("version conflict archives nothing", func(t *testing.T) {
+				key := prefix + "app/versioned"
+				_, err := st.Set(ctx, key, []byte("v1"), "text")
+				require.NoError(t, err)
+				err = st.SetWithVersion(ctx, key, []byte("v2"), "text", time.Now().Add(-time.Hour))
+				var conflict *ConflictError
+				require.ErrorAs(t, err, &conflict)
+
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				assert.Empty(t, revs)
+			})
+
+			t.Run("secrets are encrypted at rest", func(t *testing.T) {
+				key := prefix + "secrets/db"
+				for _, v := range []string{"hunter1", "hunter2"} {
+					_, err := st.Set(ctx, key, []byte(v), "text")
+					require.NoError(t, err)
+				}
+				var raw []byte
+				require.NoError(t, st.db.GetContext(ctx, &raw, st.adoptQuery("SELECT value FROM kv_history WHERE key = ?"), key))
+				assert.NotContains(t, string(raw), "hunter1")
+
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"hunter1"}, values(revs))
+			})
+
+			t.Run("rekey re-encrypts previous values", func(t *testing.T) {
+				key := prefix + "secrets/pay/stripe"
+				st.keyring, err = NewKeyring([]PrefixKey{{Prefix: prefix + "secrets/pay/*", ID: "pay-1", Key: []byte("payments-key-0001")}})
+				require.NoError(t, err)
+				defer func() { st.keyring = nil }()
+				for _, v := range []string{"sk_1", "sk_2"} {
+					_, err := st.Set(ctx, key, []byte(v), "text")
+					require.NoError(t, err)
+				}
+
+				st.keyring, err = NewKeyring([]PrefixKey{
+					{Prefix: prefix + "secrets/pay/*", ID: "pay-2", Key: []byte("payments-key-0002")},
+					{Prefix: prefix + "secrets/retired/*", ID: "pay-1", Key: []byte("payments-key-0001")},
+				})
+				require.NoError(t, err)
+				_, err = st.Rekey(ctx)
+				require.NoError(t, err)
+				var raw string
+				require.NoError(t, st.db.GetContext(ctx, &raw, st.adoptQuery("SELECT value FROM kv_history WHERE key = ?"), key))
+				assert.True(t, strings.HasPrefix(raw, "$PK$pay-2$"), "revision encrypted with the active key")
+				revs, err := st.GetHistory(ctx, key, 0)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"sk_1"}, values(revs))
+			})
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		st := newTestStore(t, "sqlite")
+		assert.False(t, st.HistoryEnabled())
+		_, err := st.Set(t.Context(), "key", []byte("v1"), "text")
+		require.NoError(t, err)
+		_, err = st.Set(t.Context(), "key", []byte("v2"), "text")
+		require.NoError(t, err)
+		revs, err := st.GetHistory(t.Context(), "key", 0)
+		require.NoError(t, err)
+		assert.Empty(t, revs)
+	})
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// prefixKeyMarker tags values double-encrypted with a prefix key: $PK$<id>$<ciphertext>.
+// The id stays readable, so values can be matched to keys and re-encrypted after rotation.
+const prefixKeyMarker = "$PK$"
+
+// ErrPrefixKeyNotFound is returned when a value is encrypted with a prefix key id that is not configured.
+var ErrPrefixKeyNotFound = errors.New("prefix key not found")
+
+var prefixKeyIDRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// PrefixKey is an additional encryption key for secrets under a key prefix.
+type PrefixKey struct {
+	Prefix string // key prefix, e.g. "secrets/payments/" or "secrets/payments/*"
+	ID     string // key id stored with each value
+	Key    []byte // key material, at least 16 bytes
+}
+
+// Keyring holds per-prefix encryption keys. Secrets under a prefix are encrypted with the prefix key
+// and then with the server key, so a leaked server key alone doesn't reveal them. When a prefix has
+// several keys, the last one encrypts new values and the others are kept for decryption only.
+type Keyring struct {
+	active []prefixCrypto     // active key per prefix, longest prefix first
+	byID   map[string]*Crypto // all keys, including rotated out ones
+}
+
+type prefixCrypto struct {
+	prefix string
+	id     string
+	crypto *Crypto
+}
+
+// NewKeyring creates a keyring from prefix keys. Ids must be unique and may contain letters, digits,
+// dots, dashes and underscores.
+func NewKeyring(keys []PrefixKey) (*Keyring, error) {
+	kr := &Keyring{byID: map[string]*Crypto{}}
+	active := map[string]prefixCrypto{}
+	for _, k := range keys {
+		if !prefixKeyIDRe.MatchString(k.ID) {
+			return nil, fmt.Errorf("invalid prefix key id %q", k.ID)
+		}
+		if _, dup := kr.byID[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate prefix key id %q", k.ID)
+		}
+		raw := strings.TrimSuffix(strings.TrimSpace(k.Prefix), "*")
+		prefix := NormalizeKey(raw)
+		if prefix == "" {
+			return nil, fmt.Errorf("empty prefix for key id %q", k.ID)
+		}
+		if strings.HasSuffix(raw, "/") {
+			prefix += "/" // "secrets/pay/" must not cover "secrets/payroll"
+		}
+		c, err := NewCrypto(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("prefix key %q: %w", k.ID, err)
+		}
+		kr.byID[k.ID] = c
+		active[prefix] = prefixCrypto{prefix: prefix, id: k.ID, crypto: c}
+	}
+	for _, pc := range active {
+		kr.active = append(kr.active, pc)
+	}
+	sort.Slice(kr.active, func(i, j int) bool { return len(kr.active[i].prefix) > len(kr.active[j].prefix) })
+	return kr, nil
+}
+
+// forKey returns the active prefix key for the given key, longest prefix wins. Safe to call on nil.
+func (k *Keyring) forKey(key string) (prefixCrypto, bool) {
+	if k == nil {
+		return prefixCrypto{}, false
+	}
+	for _, pc := range k.active {
+		if strings.HasPrefix(key, pc.prefix) {
+			return pc, true
+		}
+	}
+	return prefixCrypto{}, false
+}
+
+// lookup returns the key with the given id, or nil. Safe to call on nil.
+func (k *Keyring) lookup(id string) *Crypto {
+	if k == nil {
+		return nil
+	}
+	return k.byID[id]
+}
+
+// splitPrefixKeyID splits a tagged value into the key id and the server-key ciphertext.
+func splitPrefixKeyID(value []byte) (id string, body []byte, ok bool) {
+	rest, found := bytes.CutPrefix(value, []byte(prefixKeyMarker))
+	if !found {
+		return "", value, false
+	}
+	idx := bytes.IndexByte(rest, '$')
+	if idx <= 0 {
+		return "", value, false
+	}
+	return string(rest[:idx]), rest[idx+1:], true
+}
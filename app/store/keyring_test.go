@@ -0,0 +1,61 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyring(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	kr, err := NewKeyring([]PrefixKey{
+		{Prefix: "secrets/*", ID: "all-1", Key: key},
+		{Prefix: "secrets/pay/*", ID: "pay-1", Key: key},
+		{Prefix: "/secrets/pay/", ID: "pay-2", Key: key}, // same prefix, becomes active
+		{Prefix: "app/secrets", ID: "app.v1", Key: key},
+	})
+	require.NoError(t, err)
+	assert.Len(t, kr.byID, 4)
+
+	tbl := []struct{ key, id string }{
+		{"secrets/pay/stripe", "pay-2"},
+		{"secrets/payroll", "all-1"},
+		{"secrets/db", "all-1"},
+		{"app/secrets", "app.v1"},
+		{"app/secrets/db", "app.v1"},
+		{"other/secrets/db", ""},
+	}
+	for _, tt := range tbl {
+		pk, _ := kr.forKey(tt.key)
+		assert.Equal(t, tt.id, pk.id, tt.key)
+	}
+	assert.NotNil(t, kr.lookup("pay-1"), "rotated out key kept for decryption")
+
+	var nilRing *Keyring
+	_, ok := nilRing.forKey("secrets/x")
+	assert.False(t, ok)
+	assert.Nil(t, nilRing.lookup("x"))
+
+	_, err = NewKeyring([]PrefixKey{{Prefix: "secrets/a/", ID: "bad id", Key: key}})
+	require.ErrorContains(t, err, "invalid prefix key id")
+	_, err = NewKeyring([]PrefixKey{{Prefix: "secrets/a/", ID: "a", Key: key}, {Prefix: "secrets/b/", ID: "a", Key: key}})
+	require.ErrorContains(t, err, "duplicate prefix key id")
+	_, err = NewKeyring([]PrefixKey{{Prefix: "*", ID: "a", Key: key}})
+	require.ErrorContains(t, err, "empty prefix")
+	_, err = NewKeyring([]PrefixKey{{Prefix: "secrets/a/", ID: "a", Key: []byte("short")}})
+	require.ErrorContains(t, err, "at least 16 bytes")
+}
+
+func TestSplitPrefixKeyID(t *testing.T) {
+	id, body, ok := splitPrefixKeyID([]byte("$PK$pay-1$Zm9v"))
+	assert.True(t, ok)
+	assert.Equal(t, "pay-1", id)
+	assert.Equal(t, "Zm9v", string(body))
+
+	for _, v := range []string{"Zm9v", "$PK$", "$PK$$Zm9v", "$ZK$Zm9v"} {
+		_, body, ok = splitPrefixKeyID([]byte(v))
+		assert.False(t, ok, v)
+		assert.Equal(t, v, string(body))
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// limits of key metadata, keep labels short enough for chips in the web UI
+const (
+	maxLabels         = 32
+	maxLabelName      = 63
+	maxLabelValue     = 255
+	maxDescriptionLen = 1024
+)
+
+// ErrInvalidMeta is returned for labels or description over the limits or with invalid characters.
+var ErrInvalidMeta = errors.New("invalid key metadata")
+
+// labelNameRe allows label names like env, app.kubernetes.io/name or team_id
+var labelNameRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_./-]*[A-Za-z0-9])?$`)
+
+// KeyMeta is the descriptive metadata of a key: free-form description and key=value labels for
+// grouping and filtering. It's kept when the value changes and removed with the key.
+type KeyMeta struct {
+	Description string
+	Labels      map[string]string
+}
+
+// ParseLabels parses labels written as comma-separated name=value pairs, e.g. "env=prod, team=payments".
+// Returns no labels for an empty string. Values can't contain commas.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return labels, nil
+	}
+	for pair := range strings.SplitSeq(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%w: label %q, expected name=value", ErrInvalidMeta, strings.TrimSpace(pair))
+		}
+		if _, dup := labels[name]; dup {
+			return nil, fmt.Errorf("%w: duplicate label %q", ErrInvalidMeta, name)
+		}
+		labels[name] = value
+	}
+	if err := validateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// FormatLabels formats labels the way ParseLabels reads them, sorted by name.
+func FormatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + labels[name]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Validate checks the description and labels are within limits.
+func (m KeyMeta) Validate() error {
+	if utf8.RuneCountInString(m.Description) > maxDescriptionLen {
+		return fmt.Errorf("%w: description longer than %d characters", ErrInvalidMeta, maxDescriptionLen)
+	}
+	if strings.ContainsFunc(m.Description, func(r rune) bool { return unicode.IsControl(r) && r != '\n' && r != '\t' }) {
+		return fmt.Errorf("%w: description contains control characters", ErrInvalidMeta)
+	}
+	return validateLabels(m.Labels)
+}
+
+// validateLabels checks label names and values, values are any printable text without commas.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("%w: more than %d labels", ErrInvalidMeta, maxLabels)
+	}
+	for name, value := range labels {
+		if len(name) > maxLabelName || !labelNameRe.MatchString(name) {
+			return fmt.Errorf("%w: label name %q, expected up to %d letters, digits, '.', '_', '-' or '/'",
+				ErrInvalidMeta, name, maxLabelName)
+		}
+		if value == "" || utf8.RuneCountInString(value) > maxLabelValue || strings.Contains(value, ",") ||
+			strings.ContainsFunc(value, unicode.IsControl) {
+			return fmt.Errorf("%w: value of label %q, expected up to %d characters without commas",
+				ErrInvalidMeta, name, maxLabelValue)
+		}
+	}
+	return nil
+}
+
+// SetMeta replaces the description and labels of the key, empty ones are removed.
+// Returns ErrNotFound if the key does not exist, ErrInvalidMeta if the metadata is over the limits.
+func (s *Store) SetMeta(ctx context.Context, key string, meta KeyMeta) error {
+	if err := meta.Validate(); err != nil {
+		return err
+	}
+	description := descriptionColumn(meta.Description)
+	labels, err := labelsColumn(meta.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels of key %q: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := s.adoptQuery("UPDATE kv SET description = ?, labels = ? WHERE key = ?")
+	result, err := s.db.ExecContext(ctx, query, description, labels, key)
+	if err != nil {
+		return fmt.Errorf("failed to set metadata of key %q: %w", key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	log.Printf("[DEBUG] set metadata of key %q, %d labels", key, len(meta.Labels))
+	return nil
+}
+
+// descriptionColumn returns the stored description, NULL for none.
+func descriptionColumn(description string) sql.NullString {
+	return sql.NullString{String: description, Valid: description != ""}
+}
+
+// labelsColumn returns the stored labels as JSON, NULL for none.
+func labelsColumn(labels map[string]string) (sql.NullString, error) {
+	if len(labels) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(labels) // map keys are sorted, so label conditions match the stored JSON
+	if err != nil {
+		return sql.NullString{}, err //nolint:wrapcheck // callers wrap with the key
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// labelFragment returns the fragment of the stored labels JSON matching the label, labels are stored
+// as a JSON object with sorted names, so a label matches if its "name":"value" pair is in the object.
+func labelFragment(name, value string) string {
+	n, _ := json.Marshal(name) // strings always marshal
+	v, _ := json.Marshal(value)
+	return string(n) + ":" + string(v)
+}
+
+// decodeLabels decodes the stored labels JSON, nil for keys without labels.
+func decodeLabels(raw string) map[string]string {
+	if raw == "" {
+		return nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(raw), &labels); err != nil {
+		log.Printf("[WARN] invalid labels %q: %v", raw, err)
+		return nil
+	}
+	return labels
+}
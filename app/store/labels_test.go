@@ -0,0 +1,125 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr string
+	}{
+		{in: "", want: map[string]string{}},
+		{in: "env=prod", want: map[string]string{"env": "prod"}},
+		{in: " env = prod , app.kubernetes.io/name=billing ", want: map[string]string{"env": "prod", "app.kubernetes.io/name": "billing"}},
+		{in: "note=a = b", want: map[string]string{"note": "a = b"}},
+		{in: "env", wantErr: `label "env", expected name=value`},
+		{in: "env=", wantErr: `label "env=", expected name=value`},
+		{in: "env=prod,env=dev", wantErr: `duplicate label "env"`},
+		{in: "-env=prod", wantErr: `label name "-env"`},
+		{in: "env=prod,", wantErr: `label "", expected name=value`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseLabels(tc.in)
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrInvalidMeta)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	assert.Equal(t, "app=billing, env=prod", FormatLabels(map[string]string{"env": "prod", "app": "billing"}))
+	assert.Empty(t, FormatLabels(nil))
+}
+
+func TestKeyMeta_Validate(t *testing.T) {
+	require.NoError(t, KeyMeta{Description: "db url\nof the billing app", Labels: map[string]string{"env": "prod"}}.Validate())
+	require.ErrorIs(t, KeyMeta{Description: strings.Repeat("x", maxDescriptionLen+1)}.Validate(), ErrInvalidMeta)
+	require.ErrorIs(t, KeyMeta{Description: "bell\a"}.Validate(), ErrInvalidMeta)
+	require.ErrorIs(t, KeyMeta{Labels: map[string]string{"env": "a,b"}}.Validate(), ErrInvalidMeta)
+	require.ErrorIs(t, KeyMeta{Labels: map[string]string{"env": strings.Repeat("x", maxLabelValue+1)}}.Validate(), ErrInvalidMeta)
+	labels := map[string]string{}
+	for i := range maxLabels + 1 {
+		labels["l"+strings.Repeat("x", i)] = "v"
+	}
+	require.ErrorIs(t, KeyMeta{Labels: labels}.Validate(), ErrInvalidMeta)
+}
+
+func TestStore_SetMeta(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			ctx := t.Context()
+			st := newTestStore(t, engine)
+			prefix := "meta/" + engine + "/"
+			for _, key := range []string{"billing/db", "billing/api", "search/db"} {
+				_, err := st.Set(ctx, prefix+key, []byte("v"), "text")
+				require.NoError(t, err)
+			}
+			require.NoError(t, st.SetMeta(ctx, prefix+"billing/db", KeyMeta{Description: "primary database",
+				Labels: map[string]string{"env": "prod", "team": "billing"}}))
+			require.NoError(t, st.SetMeta(ctx, prefix+"billing/api", KeyMeta{Labels: map[string]string{"env": "dev", "team": "billing"}}))
+			require.NoError(t, st.SetMeta(ctx, prefix+"search/db", KeyMeta{Labels: map[string]string{"env": "production"}}))
+			require.ErrorIs(t, st.SetMeta(ctx, prefix+"missing", KeyMeta{Description: "x"}), ErrNotFound)
+			require.ErrorIs(t, st.SetMeta(ctx, prefix+"search/db", KeyMeta{Labels: map[string]string{"bad name": "x"}}), ErrInvalidMeta)
+
+			info, err := st.GetInfo(ctx, prefix+"billing/db")
+			require.NoError(t, err)
+			assert.Equal(t, "primary database", info.Description)
+			assert.Equal(t, map[string]string{"env": "prod", "team": "billing"}, info.Labels)
+
+			t.Run("metadata kept on value change", func(t *testing.T) {
+				_, err := st.Set(ctx, prefix+"billing/db", []byte("v2"), "text")
+				require.NoError(t, err)
+				info, err := st.GetInfo(ctx, prefix+"billing/db")
+				require.NoError(t, err)
+				assert.Equal(t, "primary database", info.Description)
+				assert.Len(t, info.Labels, 2)
+			})
+
+			t.Run("list filters by labels", func(t *testing.T) {
+				names := func(q ListQuery) []string {
+					q.Prefix = prefix
+					keys, total, err := st.ListPage(ctx, q)
+					require.NoError(t, err)
+					require.Len(t, keys, total)
+					res := make([]string, 0, len(keys))
+					for _, k := range keys {
+						res = append(res, strings.TrimPrefix(k.Key, prefix))
+					}
+					return res
+				}
+				assert.ElementsMatch(t, []string{"billing/db", "billing/api"}, names(ListQuery{Labels: map[string]string{"team": "billing"}}))
+				assert.Equal(t, []string{"billing/db"}, names(ListQuery{Labels: map[string]string{"env": "prod"}}), "exact value")
+				assert.Equal(t, []string{"billing/api"}, names(ListQuery{Labels: map[string]string{"env": "dev", "team": "billing"}}))
+				assert.Empty(t, names(ListQuery{Labels: map[string]string{"env": "prod", "team": "search"}}))
+
+				all, err := st.List(ctx, enum.SecretsFilterAll)
+				require.NoError(t, err)
+				for _, k := range all {
+					if k.Key == prefix+"search/db" {
+						assert.Equal(t, map[string]string{"env": "production"}, k.Labels)
+					}
+				}
+			})
+
+			t.Run("empty metadata removed", func(t *testing.T) {
+				require.NoError(t, st.SetMeta(ctx, prefix+"billing/db", KeyMeta{}))
+				info, err := st.GetInfo(ctx, prefix+"billing/db")
+				require.NoError(t, err)
+				assert.Empty(t, info.Description)
+				assert.Nil(t, info.Labels)
+			})
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// Passkey is a WebAuthn credential a web UI user logs in with.
+type Passkey struct {
+	ID         string    `db:"id"` // base64url credential ID
+	Username   string    `db:"username"`
+	Name       string    `db:"name"`       // label given by the user, e.g. "laptop"
+	PublicKey  []byte    `db:"public_key"` // COSE encoded
+	SignCount  int64     `db:"sign_count"` // signature counter of the authenticator, zero if it has none
+	CreatedAt  time.Time `db:"created_at"`
+	LastUsedAt time.Time `db:"last_used_at"`
+}
+
+// AddPasskey stores a new passkey. Returns ErrConflict if a passkey with the same ID exists.
+func (s *Store) AddPasskey(ctx context.Context, p Passkey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exists int
+	if err := s.db.GetContext(ctx, &exists, s.adoptQuery("SELECT COUNT(*) FROM passkeys WHERE id = ?"), p.ID); err != nil {
+		return fmt.Errorf("failed to check passkey: %w", err)
+	}
+	if exists > 0 {
+		return ErrConflict
+	}
+	now := time.Now().UTC()
+	query := s.adoptQuery(`INSERT INTO passkeys (id, username, name, public_key, sign_count, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if _, err := s.db.ExecContext(ctx, query, p.ID, p.Username, p.Name, p.PublicKey, p.SignCount, now, now); err != nil {
+		return fmt.Errorf("failed to add passkey: %w", err)
+	}
+	log.Printf("[DEBUG] add passkey %q for user %q", p.Name, p.Username)
+	return nil
+}
+
+// GetPasskey returns a passkey by ID. Returns ErrNotFound if it doesn't exist.
+func (s *Store) GetPasskey(ctx context.Context, id string) (Passkey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var p Passkey
+	query := s.adoptQuery("SELECT id, username, name, public_key, sign_count, created_at, last_used_at FROM passkeys WHERE id = ?")
+	if err := s.db.GetContext(ctx, &p, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Passkey{}, ErrNotFound
+		}
+		return Passkey{}, fmt.Errorf("failed to get passkey: %w", err)
+	}
+	p.CreatedAt, p.LastUsedAt = p.CreatedAt.UTC(), p.LastUsedAt.UTC()
+	return p, nil
+}
+
+// Passkeys returns the passkeys of a user, the oldest first.
+func (s *Store) Passkeys(ctx context.Context, username string) ([]Passkey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []Passkey
+	query := s.adoptQuery(`SELECT id, username, name, public_key, sign_count, created_at, last_used_at
+		FROM passkeys WHERE username = ? ORDER BY created_at, id`)
+	if err := s.db.SelectContext(ctx, &res, query, username); err != nil {
+		return nil, fmt.Errorf("failed to get passkeys of user %q: %w", username, err)
+	}
+	for i := range res {
+		res[i].CreatedAt, res[i].LastUsedAt = res[i].CreatedAt.UTC(), res[i].LastUsedAt.UTC()
+	}
+	return res, nil
+}
+
+// UsePasskey records a login with the passkey and the new signature counter.
+// Returns ErrNotFound if the passkey doesn't exist.
+func (s *Store) UsePasskey(ctx context.Context, id string, signCount int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery("UPDATE passkeys SET sign_count = ?, last_used_at = ? WHERE id = ?")
+	res, err := s.db.ExecContext(ctx, query, signCount, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update passkey: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeletePasskey removes a passkey of the user. Returns ErrNotFound if the user has no such passkey.
+func (s *Store) DeletePasskey(ctx context.Context, username, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM passkeys WHERE username = ? AND id = ?"), username, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	log.Printf("[DEBUG] delete passkey of user %q", username)
+	return nil
+}
+
+// DeletePasskeys removes all passkeys of the user, returns the number of deleted passkeys.
+func (s *Store) DeletePasskeys(ctx context.Context, username string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.ExecContext(ctx, s.adoptQuery("DELETE FROM passkeys WHERE username = ?"), username)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete passkeys of user %q: %w", username, err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	log.Printf("[DEBUG] delete passkeys of user %q: %d deleted", username, count)
+	return count, nil
+}
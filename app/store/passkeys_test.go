@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Passkeys(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			user := "passkey-user-" + engine
+			laptop := Passkey{ID: "cred-1-" + engine, Username: user, Name: "laptop", PublicKey: []byte{0xa5, 0x01, 0x02}}
+			phone := Passkey{ID: "cred-2-" + engine, Username: user, Name: "phone", PublicKey: []byte{0xa4, 0x01, 0x01}, SignCount: 3}
+
+			require.NoError(t, st.AddPasskey(ctx, laptop))
+			require.NoError(t, st.AddPasskey(ctx, phone))
+			require.ErrorIs(t, st.AddPasskey(ctx, laptop), ErrConflict)
+
+			got, err := st.GetPasskey(ctx, phone.ID)
+			require.NoError(t, err)
+			assert.Equal(t, user, got.Username)
+			assert.Equal(t, phone.PublicKey, got.PublicKey)
+			assert.Equal(t, int64(3), got.SignCount)
+			assert.False(t, got.CreatedAt.IsZero())
+			_, err = st.GetPasskey(ctx, "no-such-passkey")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, st.UsePasskey(ctx, phone.ID, 7))
+			got, err = st.GetPasskey(ctx, phone.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), got.SignCount)
+			require.ErrorIs(t, st.UsePasskey(ctx, "no-such-passkey", 1), ErrNotFound)
+
+			list, err := st.Passkeys(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "laptop", list[0].Name)
+
+			require.ErrorIs(t, st.DeletePasskey(ctx, "other-user", laptop.ID), ErrNotFound, "only own passkeys")
+			require.NoError(t, st.DeletePasskey(ctx, user, laptop.ID))
+			list, err = st.Passkeys(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+
+			require.NoError(t, st.AddPasskey(ctx, laptop))
+			deleted, err := st.DeletePasskeys(ctx, user)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted)
+			list, err = st.Passkeys(ctx, user)
+			require.NoError(t, err)
+			assert.Empty(t, list)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// SavedSearch is a named key list search query of a user.
+type SavedSearch struct {
+	Name      string    `json:"name" db:"name"`
+	Query     string    `json:"query" db:"query"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// PinKey pins a key for the user. Pinning an already pinned key is a no-op.
+func (s *Store) PinKey(ctx context.Context, username, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery(`INSERT INTO pinned_keys (username, key, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(username, key) DO NOTHING`)
+	if _, err := s.db.ExecContext(ctx, query, username, key, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to pin key %q: %w", key, err)
+	}
+	log.Printf("[DEBUG] pin key %q for user %q", key, username)
+	return nil
+}
+
+// UnpinKey removes a pinned key of the user.
+// Returns nil even if the key isn't pinned (idempotent).
+func (s *Store) UnpinKey(ctx context.Context, username, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery("DELETE FROM pinned_keys WHERE username = ? AND key = ?")
+	if _, err := s.db.ExecContext(ctx, query, username, key); err != nil {
+		return fmt.Errorf("failed to unpin key %q: %w", key, err)
+	}
+	log.Printf("[DEBUG] unpin key %q for user %q", key, username)
+	return nil
+}
+
+// PinnedKeys returns the pinned keys of the user in key order. Pins of deleted keys are
+// skipped, and show up again if the key is created again.
+func (s *Store) PinnedKeys(ctx context.Context, username string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := s.adoptQuery(`SELECT p.key FROM pinned_keys p JOIN kv ON kv.key = p.key
+		WHERE p.username = ? ORDER BY kv.sort_key, p.key`)
+	var keys []string
+	if err := s.db.SelectContext(ctx, &keys, query, username); err != nil {
+		return nil, fmt.Errorf("failed to get pinned keys: %w", err)
+	}
+	return keys, nil
+}
+
+// SaveSearch stores a named search query of the user, replacing the query of an existing
+// search with the same name.
+func (s *Store) SaveSearch(ctx context.Context, username, name, searchQuery string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery(`INSERT INTO saved_searches (username, name, query, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(username, name) DO UPDATE SET query = excluded.query`)
+	if _, err := s.db.ExecContext(ctx, query, username, name, searchQuery, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save search %q: %w", name, err)
+	}
+	log.Printf("[DEBUG] save search %q for user %q", name, username)
+	return nil
+}
+
+// DeleteSearch removes a saved search of the user.
+// Returns nil even if the search doesn't exist (idempotent).
+func (s *Store) DeleteSearch(ctx context.Context, username, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery("DELETE FROM saved_searches WHERE username = ? AND name = ?")
+	if _, err := s.db.ExecContext(ctx, query, username, name); err != nil {
+		return fmt.Errorf("failed to delete search %q: %w", name, err)
+	}
+	log.Printf("[DEBUG] delete search %q for user %q", name, username)
+	return nil
+}
+
+// SavedSearches returns the saved searches of the user ordered by name.
+func (s *Store) SavedSearches(ctx context.Context, username string) ([]SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := s.adoptQuery("SELECT name, query, created_at FROM saved_searches WHERE username = ? ORDER BY name")
+	var searches []SavedSearch
+	if err := s.db.SelectContext(ctx, &searches, query, username); err != nil {
+		return nil, fmt.Errorf("failed to get saved searches: %w", err)
+	}
+	for i := range searches {
+		searches[i].CreatedAt = searches[i].CreatedAt.UTC()
+	}
+	return searches, nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_PinnedKeys(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			store := newTestStore(t, engine)
+			ctx := t.Context()
+			prefix := "pins/" + engine + "/"
+			user, other := "pin-user-"+engine, "pin-other-"+engine
+			for _, k := range []string{"b", "A", "c"} {
+				_, err := store.Set(ctx, prefix+k, []byte("v"), "text")
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, store.PinKey(ctx, user, prefix+"c"))
+			require.NoError(t, store.PinKey(ctx, user, prefix+"b"))
+			require.NoError(t, store.PinKey(ctx, user, prefix+"A"))
+			require.NoError(t, store.PinKey(ctx, user, prefix+"b"), "pinning twice is a no-op")
+			require.NoError(t, store.PinKey(ctx, other, prefix+"c"))
+
+			keys, err := store.PinnedKeys(ctx, user)
+			require.NoError(t, err)
+			assert.Equal(t, []string{prefix + "A", prefix + "b", prefix + "c"}, keys, "in key order")
+
+			require.NoError(t, store.UnpinKey(ctx, user, prefix+"c"))
+			require.NoError(t, store.UnpinKey(ctx, user, prefix+"missing"))
+			keys, err = store.PinnedKeys(ctx, user)
+			require.NoError(t, err)
+			assert.Equal(t, []string{prefix + "A", prefix + "b"}, keys)
+
+			keys, err = store.PinnedKeys(ctx, other)
+			require.NoError(t, err)
+			assert.Equal(t, []string{prefix + "c"}, keys, "other user's pins not affected")
+
+			t.Run("deleted key is skipped until created again", func(t *testing.T) {
+				require.NoError(t, store.Delete(ctx, prefix+"A"))
+				keys, err := store.PinnedKeys(ctx, user)
+				require.NoError(t, err)
+				assert.Equal(t, []string{prefix + "b"}, keys)
+
+				_, err = store.Set(ctx, prefix+"A", []byte("v2"), "text")
+				require.NoError(t, err)
+				keys, err = store.PinnedKeys(ctx, user)
+				require.NoError(t, err)
+				assert.Equal(t, []string{prefix + "A", prefix + "b"}, keys)
+			})
+
+			t.Run("no pins", func(t *testing.T) {
+				keys, err := store.PinnedKeys(ctx, "nobody-"+engine)
+				require.NoError(t, err)
+				assert.Empty(t, keys)
+			})
+		})
+	}
+}
+
+func TestStore_SavedSearches(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			store := newTestStore(t, engine)
+			ctx := t.Context()
+			user := "search-user-" + engine
+
+			require.NoError(t, store.SaveSearch(ctx, user, "prod", "prefix:prod/"))
+			require.NoError(t, store.SaveSearch(ctx, user, "json", "format:json"))
+			require.NoError(t, store.SaveSearch(ctx, "search-other-"+engine, "prod", "prefix:other/"))
+
+			searches, err := store.SavedSearches(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, searches, 2)
+			assert.Equal(t, "json", searches[0].Name, "ordered by name")
+			assert.Equal(t, "format:json", searches[0].Query)
+			assert.Equal(t, "prod", searches[1].Name)
+			assert.Equal(t, "prefix:prod/", searches[1].Query)
+			assert.WithinDuration(t, time.Now(), searches[1].CreatedAt, time.Minute)
+
+			require.NoError(t, store.SaveSearch(ctx, user, "prod", "prefix:prod/ updated:<1d"))
+			searches, err = store.SavedSearches(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, searches, 2)
+			assert.Equal(t, "prefix:prod/ updated:<1d", searches[1].Query, "same name replaces query")
+
+			require.NoError(t, store.DeleteSearch(ctx, user, "json"))
+			require.NoError(t, store.DeleteSearch(ctx, user, "missing"))
+			searches, err = store.SavedSearches(ctx, user)
+			require.NoError(t, err)
+			require.Len(t, searches, 1)
+			assert.Equal(t, "prod", searches[0].Name)
+
+			searches, err = store.SavedSearches(ctx, "search-other-"+engine)
+			require.NoError(t, err)
+			require.Len(t, searches, 1)
+			assert.Equal(t, "prefix:other/", searches[0].Query)
+		})
+	}
+}
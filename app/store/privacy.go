@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// PseudonymizeResult counts the records changed by PseudonymizeUser.
+type PseudonymizeResult struct {
+	AuditEntries    int64 `json:"audit_entries"`
+	Owners          int64 `json:"owners"`
+	PinnedKeys      int64 `json:"pinned_keys"`
+	SavedSearches   int64 `json:"saved_searches"`
+	Freezes         int64 `json:"freezes"`
+	DelegatedTokens int64 `json:"delegated_tokens"`
+}
+
+// PseudonymizeUser replaces the username with the pseudonym in the audit log, key owners, pinned keys,
+// saved searches, freezes and delegated tokens made by the user, for erasure requests of a user.
+// Audit entries of the user keep their action, key and time but lose the IP and user agent. Entries of
+// tokens and other actor types are not changed even if named the same. Sessions and known login devices
+// of the user are deleted, as they record where the user logged in from, and so are passkeys registered
+// under the username.
+func (s *Store) PseudonymizeUser(ctx context.Context, username, pseudonym string) (PseudonymizeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return PseudonymizeResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// update runs the query and returns the number of changed rows
+	update := func(what, query string, args ...any) (int64, error) {
+		res, err := tx.ExecContext(ctx, s.adoptQuery(query), args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to pseudonymize %s: %w", what, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows of %s: %w", what, err)
+		}
+		return n, nil
+	}
+
+	var res PseudonymizeResult
+	if res.AuditEntries, err = update("audit entries",
+		"UPDATE audit_log SET actor = ?, ip = NULL, user_agent = NULL WHERE actor = ? AND actor_type = 'user'",
+		pseudonym, username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+	if res.Owners, err = update("key owners", "UPDATE kv SET owner = ? WHERE owner = ?",
+		"user:"+pseudonym, "user:"+username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+	if res.PinnedKeys, err = update("pinned keys", "UPDATE pinned_keys SET username = ? WHERE username = ?",
+		pseudonym, username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+	if res.SavedSearches, err = update("saved searches", "UPDATE saved_searches SET username = ? WHERE username = ?",
+		pseudonym, username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+	if res.Freezes, err = update("freezes", "UPDATE freezes SET frozen_by = ? WHERE frozen_by = ?",
+		pseudonym, username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+	if res.DelegatedTokens, err = update("delegated tokens", "UPDATE delegated_tokens SET created_by = ? WHERE created_by = ?",
+		"user:"+pseudonym, "user:"+username); err != nil {
+		return PseudonymizeResult{}, err
+	}
+
+	for _, table := range []string{"sessions", "login_devices", "passkeys"} {
+		if _, err := tx.ExecContext(ctx, s.adoptQuery("DELETE FROM "+table+" WHERE username = ?"), username); err != nil {
+			return PseudonymizeResult{}, fmt.Errorf("failed to delete %s of user: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return PseudonymizeResult{}, fmt.Errorf("failed to commit pseudonymization: %w", err)
+	}
+	return res, nil
+}
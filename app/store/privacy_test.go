@@ -0,0 +1,103 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestStore_PseudonymizeUser(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			user, pseudonym := "gdpr-user-"+engine, "redacted-"+engine
+			key := "privacy/" + engine + "/owned"
+			_, err := st.SetWithOptions(ctx, key, []byte("v"), "text", SetOptions{Owner: "user:" + user})
+			require.NoError(t, err)
+
+			now := time.Now().UTC().Truncate(time.Second)
+			for _, e := range []AuditEntry{
+				{Timestamp: now, Action: enum.AuditActionRead, Key: key, Actor: user, ActorType: enum.ActorTypeUser,
+					Result: enum.AuditResultSuccess, IP: "10.0.0.1", UserAgent: "firefox", RequestID: "r1"},
+				{Timestamp: now, Action: enum.AuditActionUpdate, Key: key, Actor: user, ActorType: enum.ActorTypeUser,
+					Result: enum.AuditResultSuccess, IP: "10.0.0.1"},
+				{Timestamp: now, Action: enum.AuditActionRead, Key: key, Actor: user, ActorType: enum.ActorTypeToken,
+					Result: enum.AuditResultSuccess, IP: "10.0.0.2"},
+			} {
+				require.NoError(t, st.LogAudit(ctx, e))
+			}
+
+			token := "privacy-session-" + engine
+			require.NoError(t, st.CreateSession(ctx, token, user, now.Add(time.Hour), false))
+			_, err = st.SetSessionDevice(ctx, token, SessionDevice{Device: "Firefox on Linux", IP: "10.0.0.1"})
+			require.NoError(t, err)
+
+			require.NoError(t, st.PinKey(ctx, user, key))
+			require.NoError(t, st.SaveSearch(ctx, user, "mine", "prefix:privacy/"))
+			freeze := Freeze{Prefix: "privacy/" + engine, Incident: "INC-1", FrozenBy: user, ExpiresAt: now.Add(time.Hour)}
+			require.NoError(t, st.FreezePrefix(ctx, freeze))
+			delegated := DelegatedToken{Fingerprint: "privacy-" + engine, Name: "ci", Prefix: "privacy/*", CreatedBy: "user:" + user,
+				ACL: "{}", CreatedAt: now}
+			require.NoError(t, st.AddDelegatedToken(ctx, delegated))
+
+			res, err := st.PseudonymizeUser(ctx, user, pseudonym)
+			require.NoError(t, err)
+			assert.Equal(t, PseudonymizeResult{AuditEntries: 2, Owners: 1, PinnedKeys: 1, SavedSearches: 1, Freezes: 1,
+				DelegatedTokens: 1}, res)
+
+			_, err = st.GetSession(ctx, token)
+			require.ErrorIs(t, err, ErrNotFound, "sessions of the user are deleted")
+			var devices int
+			require.NoError(t, st.db.GetContext(ctx, &devices, st.adoptQuery("SELECT COUNT(*) FROM login_devices WHERE username = ?"), user))
+			assert.Zero(t, devices)
+
+			entries, total, err := st.QueryAudit(ctx, AuditQuery{Actor: pseudonym})
+			require.NoError(t, err)
+			require.Equal(t, 2, total)
+			for _, e := range entries {
+				assert.Empty(t, e.IP)
+				assert.Empty(t, e.UserAgent)
+				assert.Equal(t, key, e.Key)
+			}
+			_, total, err = st.QueryAudit(ctx, AuditQuery{Actor: user})
+			require.NoError(t, err)
+			assert.Equal(t, 1, total, "token entry with the same name is kept")
+
+			info, err := st.GetInfo(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, "user:"+pseudonym, info.Owner)
+
+			pinned, err := st.PinnedKeys(ctx, pseudonym)
+			require.NoError(t, err)
+			assert.Equal(t, []string{key}, pinned, "pins move to the pseudonym")
+			pinned, err = st.PinnedKeys(ctx, user)
+			require.NoError(t, err)
+			assert.Empty(t, pinned)
+			searches, err := st.SavedSearches(ctx, pseudonym)
+			require.NoError(t, err)
+			require.Len(t, searches, 1)
+			assert.Equal(t, "mine", searches[0].Name)
+			searches, err = st.SavedSearches(ctx, user)
+			require.NoError(t, err)
+			assert.Empty(t, searches)
+
+			freezes, err := st.Freezes(ctx)
+			require.NoError(t, err)
+			require.Len(t, freezes, 1)
+			assert.Equal(t, pseudonym, freezes[0].FrozenBy)
+
+			dt, err := st.GetDelegatedToken(ctx, delegated.Fingerprint)
+			require.NoError(t, err)
+			assert.Equal(t, "user:"+pseudonym, dt.CreatedBy)
+
+			res, err = st.PseudonymizeUser(ctx, user, pseudonym)
+			require.NoError(t, err)
+			assert.Equal(t, PseudonymizeResult{}, res, "nothing left to change")
+		})
+	}
+}
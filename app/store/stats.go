@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// sizeBuckets are inclusive upper bounds of the value size histogram, the last one is open.
+var sizeBuckets = []int64{0, 64, 256, 1024, 4 * 1024, 16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024, math.MaxInt64}
+
+// DBStats describes how the database is used, to guide quota and retention settings.
+type DBStats struct {
+	Keys       int           // number of keys
+	ValueBytes int64         // total size of stored values, encrypted values are counted as stored
+	DBBytes    int64         // database size on disk, 0 if unknown
+	Sizes      []SizeBucket  // value size histogram
+	Prefixes   []PrefixStats // per-prefix totals, largest first
+	Growth     []GrowthStats // monthly changes from the audit log, oldest first, empty without audit
+}
+
+// SizeBucket counts values with sizes up to Max bytes and above the previous bucket's Max.
+type SizeBucket struct {
+	Max   int64 // inclusive upper bound, math.MaxInt64 for the last bucket
+	Keys  int
+	Bytes int64
+}
+
+// PrefixStats is the total of keys under a prefix, "" for keys without a folder.
+type PrefixStats struct {
+	Prefix string
+	Keys   int
+	Bytes  int64
+}
+
+// GrowthStats counts successful key changes in a calendar month (UTC).
+type GrowthStats struct {
+	Month   time.Time // first day of the month
+	Created int
+	Updated int
+	Deleted int
+	Written int64 // bytes written by creates and updates
+}
+
+// Projected estimates the database size after the given number of months, assuming keys keep growing
+// by the average monthly net count (created minus deleted) with the current average value size.
+// Audit log and index growth are not included.
+func (s DBStats) Projected(months int) int64 {
+	if len(s.Growth) == 0 || s.Keys == 0 {
+		return s.DBBytes
+	}
+	var net int
+	for _, g := range s.Growth {
+		net += g.Created - g.Deleted
+	}
+	perMonth := float64(net) / float64(len(s.Growth)) * float64(s.ValueBytes) / float64(s.Keys)
+	return max(0, s.DBBytes+int64(perMonth*float64(months)))
+}
+
+// DBStats collects value size histogram, totals for prefixes made of the first depth key folders,
+// monthly growth from the audit log and the database size.
+func (s *Store) DBStats(ctx context.Context, depth int) (DBStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []struct {
+		Key  string `db:"key"`
+		Size int64  `db:"size"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery("SELECT key, length(value) AS size FROM kv")); err != nil {
+		return DBStats{}, fmt.Errorf("failed to get value sizes: %w", err)
+	}
+
+	res := DBStats{Keys: len(rows), Sizes: make([]SizeBucket, len(sizeBuckets))}
+	for i, m := range sizeBuckets {
+		res.Sizes[i].Max = m
+	}
+	prefixes := map[string]*PrefixStats{}
+	for _, r := range rows {
+		res.ValueBytes += r.Size
+		i := sort.Search(len(sizeBuckets), func(i int) bool { return sizeBuckets[i] >= r.Size })
+		res.Sizes[i].Keys++
+		res.Sizes[i].Bytes += r.Size
+
+		prefix := keyPrefix(r.Key, depth)
+		p, ok := prefixes[prefix]
+		if !ok {
+			p = &PrefixStats{Prefix: prefix}
+			prefixes[prefix] = p
+		}
+		p.Keys++
+		p.Bytes += r.Size
+	}
+	for _, p := range prefixes {
+		res.Prefixes = append(res.Prefixes, *p)
+	}
+	sort.Slice(res.Prefixes, func(i, j int) bool {
+		if res.Prefixes[i].Bytes != res.Prefixes[j].Bytes {
+			return res.Prefixes[i].Bytes > res.Prefixes[j].Bytes
+		}
+		return res.Prefixes[i].Prefix < res.Prefixes[j].Prefix
+	})
+
+	var err error
+	if res.Growth, err = s.auditGrowth(ctx); err != nil {
+		return DBStats{}, err
+	}
+	if res.DBBytes, err = s.dbSize(ctx); err != nil {
+		return DBStats{}, err
+	}
+	return res, nil
+}
+
+// auditGrowth counts successful creates, updates and deletes per month. Months are bucketed here,
+// as with AuditTimeline, to avoid dialect-specific date functions.
+func (s *Store) auditGrowth(ctx context.Context) ([]GrowthStats, error) {
+	var rows []struct {
+		Timestamp string `db:"timestamp"`
+		Action    string `db:"action"`
+		ValueSize *int64 `db:"value_size"`
+	}
+	query := "SELECT timestamp, action, value_size FROM audit_log WHERE result = ? AND action IN (?, ?, ?) ORDER BY timestamp"
+	if err := s.db.SelectContext(ctx, &rows, s.adoptQuery(query), "success", "create", "update", "delete"); err != nil {
+		return nil, fmt.Errorf("failed to query audit growth: %w", err)
+	}
+
+	var res []GrowthStats
+	for _, r := range rows {
+		ts, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			log.Printf("[WARN] failed to parse audit timestamp %q: %v", r.Timestamp, err)
+			continue
+		}
+		ts = ts.UTC()
+		month := time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if len(res) == 0 || !res[len(res)-1].Month.Equal(month) {
+			res = append(res, GrowthStats{Month: month})
+		}
+		g := &res[len(res)-1]
+		switch r.Action {
+		case "create":
+			g.Created++
+		case "update":
+			g.Updated++
+		case "delete":
+			g.Deleted++
+		}
+		if r.ValueSize != nil && r.Action != "delete" {
+			g.Written += *r.ValueSize
+		}
+	}
+	return res, nil
+}
+
+// dbSize returns the size of the database, all tables and indexes included.
+func (s *Store) dbSize(ctx context.Context) (int64, error) {
+	query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if s.dbType == DBTypePostgres {
+		query = "SELECT pg_database_size(current_database())"
+	}
+	var size int64
+	if err := s.db.GetContext(ctx, &size, query); err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return size, nil
+}
+
+// keyPrefix returns up to depth leading folders of the key with a trailing slash, "" if the key has no folder.
+func keyPrefix(key string, depth int) string {
+	parts := strings.Split(key, "/")
+	n := min(depth, len(parts)-1)
+	if n <= 0 {
+		return ""
+	}
+	return strings.Join(parts[:n], "/") + "/"
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/app/enum"
+)
+
+func TestStore_DBStats(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+
+			for key, size := range map[string]int{"top": 10, "app/db/host": 100, "app/db/port": 4, "app/blob": 2000, "web/x": 0} {
+				_, err := st.Set(ctx, key, []byte(strings.Repeat("x", size)), "text")
+				require.NoError(t, err)
+			}
+			jan := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+			feb := time.Date(2025, 2, 3, 12, 0, 0, 0, time.UTC)
+			for _, e := range []AuditEntry{
+				{Timestamp: jan, Action: enum.AuditActionCreate, ValueSize: intPtr(100), Result: enum.AuditResultSuccess},
+				{Timestamp: jan.Add(time.Hour), Action: enum.AuditActionCreate, ValueSize: intPtr(4), Result: enum.AuditResultSuccess},
+				{Timestamp: jan.Add(2 * time.Hour), Action: enum.AuditActionCreate, Result: enum.AuditResultDenied},
+				{Timestamp: jan.Add(3 * time.Hour), Action: enum.AuditActionRead, Result: enum.AuditResultSuccess},
+				{Timestamp: feb, Action: enum.AuditActionUpdate, ValueSize: intPtr(50), Result: enum.AuditResultSuccess},
+				{Timestamp: feb.Add(time.Hour), Action: enum.AuditActionDelete, Result: enum.AuditResultSuccess},
+			} {
+				e.Key, e.Actor, e.ActorType = "app/db/host", "alice", enum.ActorTypeUser
+				require.NoError(t, st.LogAudit(ctx, e))
+			}
+
+			stats, err := st.DBStats(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 5, stats.Keys)
+			assert.Equal(t, int64(2114), stats.ValueBytes)
+			assert.Positive(t, stats.DBBytes)
+
+			require.Len(t, stats.Sizes, len(sizeBuckets))
+			assert.Equal(t, SizeBucket{Max: 0, Keys: 1}, stats.Sizes[0])
+			assert.Equal(t, SizeBucket{Max: 64, Keys: 2, Bytes: 14}, stats.Sizes[1])
+			assert.Equal(t, SizeBucket{Max: 256, Keys: 1, Bytes: 100}, stats.Sizes[2])
+			assert.Equal(t, SizeBucket{Max: 4096, Keys: 1, Bytes: 2000}, stats.Sizes[4])
+			assert.Equal(t, int64(math.MaxInt64), stats.Sizes[len(stats.Sizes)-1].Max)
+
+			assert.Equal(t, []PrefixStats{{Prefix: "app/", Keys: 3, Bytes: 2104}, {Prefix: "", Keys: 1, Bytes: 10},
+				{Prefix: "web/", Keys: 1, Bytes: 0}}, stats.Prefixes)
+
+			assert.Equal(t, []GrowthStats{
+				{Month: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Created: 2, Written: 104},
+				{Month: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Updated: 1, Deleted: 1, Written: 50},
+			}, stats.Growth)
+		})
+	}
+}
+
+func TestDBStats_Projected(t *testing.T) {
+	stats := DBStats{Keys: 10, ValueBytes: 1000, DBBytes: 10000, Growth: []GrowthStats{{Created: 30}, {Created: 20, Deleted: 10}}}
+	assert.Equal(t, int64(10000+20*100*12), stats.Projected(12), "20 keys a month, 100 bytes each")
+	assert.Equal(t, int64(10000), DBStats{DBBytes: 10000}.Projected(12), "no growth data")
+	assert.Equal(t, int64(0), DBStats{Keys: 1, ValueBytes: 10, DBBytes: 5, Growth: []GrowthStats{{Deleted: 5}}}.Projected(12))
+}
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key   string
+		depth int
+		want  string
+	}{
+		{"top", 1, ""},
+		{"app/db/host", 1, "app/"},
+		{"app/db/host", 2, "app/db/"},
+		{"app/host", 2, "app/"},
+		{"app/db/host", 0, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, keyPrefix(tt.key, tt.depth), "%s depth %d", tt.key, tt.depth)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/umputun/stash/app/enum"
 )
@@ -49,22 +50,59 @@ type Interface interface {
 	GetWithFormat(ctx context.Context, key string) ([]byte, string, error)
 	GetInfo(ctx context.Context, key string) (KeyInfo, error)
 	Set(ctx context.Context, key string, value []byte, format string) (created bool, err error)
+	SetWithOptions(ctx context.Context, key string, value []byte, format string, opts SetOptions) (created bool, err error)
 	SetWithVersion(ctx context.Context, key string, value []byte, format string, expectedVersion time.Time) error
 	Delete(ctx context.Context, key string) error
+	Txn(ctx context.Context, ops []TxnOp) ([]TxnResult, error)
+	SetVariants(ctx context.Context, key, spec string) error
+	GetVariants(ctx context.Context, key string) (string, error)
+	SetMeta(ctx context.Context, key string, meta KeyMeta) error
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+	GetHistory(ctx context.Context, key string, limit int) ([]Revision, error)
+	GetVersion(ctx context.Context, key string, version int64) (Revision, error)
+	Rollback(ctx context.Context, key string, version int64, owner string) (rev Revision, created bool, err error)
+	HistoryEnabled() bool
 	List(ctx context.Context, filter enum.SecretsFilter) ([]KeyInfo, error)
+	ListPage(ctx context.Context, q ListQuery) (keys []KeyInfo, total int, err error)
 	SecretsEnabled() bool
 	Close() error
 }
 
+// ListQuery selects a page of keys for ListPage.
+type ListQuery struct {
+	Filter enum.SecretsFilter
+	Search string // case-insensitive substring of the key
+	Sort   enum.SortMode
+	Limit  int // max keys per page, 0 for all
+	Offset int
+
+	// optional conditions, zero values don't filter
+	Prefix        string            // case-sensitive key prefix
+	Format        string            // exact value format
+	UpdatedAfter  time.Time         // inclusive
+	UpdatedBefore time.Time         // exclusive
+	MinSize       int               // stored value size in bytes, inclusive
+	SizeBelow     int               // stored value size in bytes, exclusive
+	Labels        map[string]string // all labels must match
+
+	// Allow filters a batch of key names, e.g. by user permissions, and returns the allowed ones
+	// in the same order. Optional; only allowed keys are counted and paged.
+	Allow func(keys []string) []string
+}
+
 // KeyInfo holds metadata about a stored key.
 type KeyInfo struct {
-	Key         string    `json:"key" db:"key"`
-	Size        int       `json:"size" db:"size"`
-	Format      string    `json:"format" db:"format"`
-	Secret      bool      `json:"secret" db:"-"`
-	ZKEncrypted bool      `json:"zk_encrypted" db:"-"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Key         string            `json:"key" db:"key"`
+	Size        int               `json:"size" db:"size"` // stored size, encrypted values count with their ciphertext
+	Format      string            `json:"format" db:"format"`
+	Secret      bool              `json:"secret" db:"-"`
+	ZKEncrypted bool              `json:"zk_encrypted" db:"-"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+	Owner       string            `json:"owner,omitempty" db:"owner"`           // creator of the key, empty for keys created before owners were recorded
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty" db:"expires_at"` // nil for keys set without TTL
+	Description string            `json:"description,omitempty" db:"description"`
+	Labels      map[string]string `json:"labels,omitempty" db:"-"` // name=value labels, nil if the key has none
 }
 
 // DBType is an alias for enum.DbType for compatibility.
@@ -93,10 +131,10 @@ func (noopLocker) Lock()    {}
 func (noopLocker) Unlock()  {}
 
 // NormalizeKey normalizes a key by trimming spaces, leading/trailing slashes,
-// and replacing spaces with underscores.
+// and replacing spaces with underscores. Spaces and slashes are trimmed together,
+// so normalizing a normalized key doesn't change it, e.g. "/\t/foo" is "foo".
 func NormalizeKey(key string) string {
-	key = strings.TrimSpace(key)
-	key = strings.Trim(key, "/")
+	key = strings.TrimFunc(key, func(r rune) bool { return r == '/' || unicode.IsSpace(r) })
 	key = strings.ReplaceAll(key, " ", "_")
 	return key
 }
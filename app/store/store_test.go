@@ -1,6 +1,7 @@
 package store
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,6 +22,7 @@ func TestNormalizeKey(t *testing.T) {
 		{"multiple leading slashes", "//foo//bar//", "foo//bar"},
 		{"empty key", "", ""},
 		{"only slashes", "///", ""},
+		{"whitespace between slashes", "/\t/foo/ /", "foo"},
 	}
 
 	for _, tc := range tests {
@@ -29,3 +31,16 @@ func TestNormalizeKey(t *testing.T) {
 		})
 	}
 }
+
+func FuzzNormalizeKey(f *testing.F) {
+	for _, seed := range []string{"foo", "/foo/bar/", " /foo bar/ ", "//foo//bar//", "/\t/foo", "foo/ /", " /key", "\xff/a b/"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, key string) {
+		res := NormalizeKey(key)
+		assert.Equal(t, res, NormalizeKey(res), "normalization is idempotent")
+		assert.NotContains(t, res, " ")
+		assert.False(t, strings.HasPrefix(res, "/") || strings.HasSuffix(res, "/"), "no leading or trailing slash in %q", res)
+		assert.Equal(t, strings.TrimSpace(res), res, "no surrounding whitespace in %q", res)
+	})
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// TokenUsage is the last use of an API token of the auth config. Tokens are kept by fingerprint,
+// the store never sees the token itself.
+type TokenUsage struct {
+	Fingerprint string     `db:"fingerprint"`
+	FirstSeen   time.Time  `db:"first_seen"` // when the token showed up in the auth config
+	LastUsed    *time.Time `db:"last_used"`  // nil if the token was never used
+	LastIP      string     `db:"last_ip"`    // client IP of the last use
+}
+
+// SyncTokenUsage starts tracking of the given tokens and drops usage of tokens not in the list anymore.
+// Tokens tracked already keep their usage.
+func (s *Store) SyncTokenUsage(ctx context.Context, fingerprints []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var tracked []string
+	if err := tx.SelectContext(ctx, &tracked, "SELECT fingerprint FROM token_usage"); err != nil {
+		return fmt.Errorf("failed to get tracked tokens: %w", err)
+	}
+	current := make(map[string]bool, len(fingerprints))
+	for _, fp := range fingerprints {
+		current[fp] = true
+	}
+	for _, fp := range tracked {
+		if current[fp] {
+			delete(current, fp)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, s.adoptQuery("DELETE FROM token_usage WHERE fingerprint = ?"), fp); err != nil {
+			return fmt.Errorf("failed to drop usage of removed token: %w", err)
+		}
+	}
+	now := time.Now().UTC()
+	for fp := range current {
+		query := s.adoptQuery("INSERT INTO token_usage (fingerprint, first_seen, last_ip) VALUES (?, ?, '')")
+		if _, err := tx.ExecContext(ctx, query, fp, now); err != nil {
+			return fmt.Errorf("failed to track token: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit token usage: %w", err)
+	}
+	log.Printf("[DEBUG] token usage synced, %d tokens, %d new", len(fingerprints), len(current))
+	return nil
+}
+
+// TouchToken records a use of the token from the client IP. Tokens not tracked yet are added.
+func (s *Store) TouchToken(ctx context.Context, fingerprint, ip string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at = at.UTC()
+	query := s.adoptQuery(`INSERT INTO token_usage (fingerprint, first_seen, last_used, last_ip) VALUES (?, ?, ?, ?)
+		ON CONFLICT(fingerprint) DO UPDATE SET last_used = excluded.last_used, last_ip = excluded.last_ip`)
+	if _, err := s.db.ExecContext(ctx, query, fingerprint, at, at, ip); err != nil {
+		return fmt.Errorf("failed to record token use: %w", err)
+	}
+	return nil
+}
+
+// TokenUsages returns the usage of all tracked tokens.
+func (s *Store) TokenUsages(ctx context.Context) ([]TokenUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []TokenUsage
+	query := "SELECT fingerprint, first_seen, last_used, last_ip FROM token_usage ORDER BY fingerprint"
+	if err := s.db.SelectContext(ctx, &res, query); err != nil {
+		return nil, fmt.Errorf("failed to get token usage: %w", err)
+	}
+	for i := range res {
+		res[i].FirstSeen = res[i].FirstSeen.UTC()
+		if res[i].LastUsed != nil {
+			used := res[i].LastUsed.UTC()
+			res[i].LastUsed = &used
+		}
+	}
+	return res, nil
+}
+
+// UseNonce records the nonce of a signed request, kept until expiresAt, and returns false if it is
+// recorded already and not expired at the time of use. Nonces are shared by all instances of the store.
+func (s *Store) UseNonce(ctx context.Context, nonce string, at, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// an expired nonce not cleaned up yet can be used again
+	query := s.adoptQuery("DELETE FROM request_nonces WHERE nonce = ? AND expires_at < ?")
+	if _, err := tx.ExecContext(ctx, query, nonce, at.UTC()); err != nil {
+		return false, fmt.Errorf("failed to drop expired nonce: %w", err)
+	}
+	query = s.adoptQuery("INSERT INTO request_nonces (nonce, expires_at) VALUES (?, ?)")
+	if _, err := tx.ExecContext(ctx, query, nonce, expiresAt.UTC()); err != nil {
+		if isUniqueViolation(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit nonce: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteExpiredNonces removes nonces of signed requests past their expiration.
+// Returns the number of nonces deleted.
+func (s *Store) DeleteExpiredNonces(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.adoptQuery("DELETE FROM request_nonces WHERE expires_at < ?")
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired nonces: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if count > 0 {
+		log.Printf("[DEBUG] delete expired nonces: %d deleted", count)
+	}
+	return count, nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_TokenUsage(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+
+			require.NoError(t, st.SyncTokenUsage(ctx, []string{"fp-ci", "fp-deploy"}))
+			usage, err := st.TokenUsages(ctx)
+			require.NoError(t, err)
+			require.Len(t, usage, 2)
+			assert.Equal(t, "fp-ci", usage[0].Fingerprint)
+			assert.Nil(t, usage[0].LastUsed, "never used")
+			assert.WithinDuration(t, time.Now(), usage[0].FirstSeen, time.Minute)
+
+			used := time.Now().Add(-time.Hour).Truncate(time.Second)
+			require.NoError(t, st.TouchToken(ctx, "fp-ci", "203.0.113.7", used))
+			usage, err = st.TokenUsages(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, usage[0].LastUsed)
+			assert.True(t, used.Equal(*usage[0].LastUsed))
+			assert.Equal(t, "203.0.113.7", usage[0].LastIP)
+
+			// sync keeps usage of known tokens, adds new ones and drops removed ones
+			firstSeen := usage[0].FirstSeen
+			require.NoError(t, st.SyncTokenUsage(ctx, []string{"fp-ci", "fp-new"}))
+			usage, err = st.TokenUsages(ctx)
+			require.NoError(t, err)
+			require.Len(t, usage, 2)
+			assert.Equal(t, "fp-ci", usage[0].Fingerprint)
+			assert.Equal(t, firstSeen, usage[0].FirstSeen)
+			require.NotNil(t, usage[0].LastUsed)
+			assert.Equal(t, "fp-new", usage[1].Fingerprint)
+
+			// untracked token is added on use
+			require.NoError(t, st.TouchToken(ctx, "fp-other", "", used))
+			usage, err = st.TokenUsages(ctx)
+			require.NoError(t, err)
+			require.Len(t, usage, 3)
+		})
+	}
+}
+
+func TestStore_UseNonce(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			st := newTestStore(t, engine)
+			ctx := t.Context()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			ok, err := st.UseNonce(ctx, "n1", now, now.Add(time.Minute))
+			require.NoError(t, err)
+			assert.True(t, ok, "first use")
+			ok, err = st.UseNonce(ctx, "n1", now.Add(30*time.Second), now.Add(time.Minute))
+			require.NoError(t, err)
+			assert.False(t, ok, "replay within the window")
+
+			ok, err = st.UseNonce(ctx, "n1", now.Add(2*time.Minute), now.Add(3*time.Minute))
+			require.NoError(t, err)
+			assert.True(t, ok, "expired nonce is accepted again")
+
+			// expired nonces are cleaned up, others kept
+			ok, err = st.UseNonce(ctx, "old", now.Add(-2*time.Hour), now.Add(-time.Hour))
+			require.NoError(t, err)
+			require.True(t, ok)
+			deleted, err := st.DeleteExpiredNonces(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+			ok, err = st.UseNonce(ctx, "n1", now.Add(2*time.Minute), now.Add(3*time.Minute))
+			require.NoError(t, err)
+			assert.False(t, ok, "live nonce kept by cleanup")
+		})
+	}
+}
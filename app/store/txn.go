@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// TxnOp is a change of one key in a transaction, see Txn.
+type TxnOp struct {
+	Key    string
+	Delete bool // removes the key, Value and Format are not used
+	Value  []byte
+	Format string // defaults to "text"
+	Owner  string // recorded if the key is created, see SetOptions
+
+	// optional preconditions, the transaction fails with TxnConflictError if one is not met
+	Version time.Time // the key exists and was last updated at this time
+	Absent  bool      // the key doesn't exist
+}
+
+// TxnResult is the outcome of one operation of a committed transaction.
+type TxnResult struct {
+	Key     string
+	Created bool      // the key didn't exist before
+	Version time.Time // update time of the key set, zero for a deleted key
+}
+
+// TxnConflictError is returned by Txn if a precondition of an operation is not met or the key to delete
+// doesn't exist. Nothing is changed then.
+type TxnConflictError struct {
+	Key     string
+	Version time.Time // current update time of the key, zero if it doesn't exist
+}
+
+// Error returns a string representation of the conflict.
+func (e *TxnConflictError) Error() string {
+	if e.Version.IsZero() {
+		return fmt.Sprintf("transaction conflict: key %q doesn't exist", e.Key)
+	}
+	return fmt.Sprintf("transaction conflict: key %q was updated at %s", e.Key, e.Version.Format(time.RFC3339Nano))
+}
+
+// Unwrap returns the underlying ErrConflict sentinel.
+func (e *TxnConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// Txn applies the operations in a single database transaction, all of them or none. A key can be changed
+// by one operation only. Values are stored as with Set: secrets are encrypted, TTL is cleared and the
+// previous values are archived if history is enabled. Returns the results in the order of operations,
+// or *FrozenError without changes if one of the keys is under a frozen prefix.
+func (s *Store) Txn(ctx context.Context, ops []TxnOp) ([]TxnResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = op.Key
+	}
+	if err := s.checkFrozen(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	// values are checked and encrypted first, nothing is written if one of them is rejected
+	values, encrypted := make([][]byte, len(ops)), make([]bool, len(ops))
+	seen := make(map[string]bool, len(ops))
+	for i, op := range ops {
+		if seen[op.Key] {
+			return nil, fmt.Errorf("key %q is changed more than once in transaction", op.Key)
+		}
+		seen[op.Key] = true
+		if op.Delete {
+			continue
+		}
+		if IsSecret(op.Key) && !s.SecretsEnabled() {
+			return nil, ErrSecretsNotConfigured
+		}
+		if IsSecret(op.Key) && stash.IsZKEncrypted(op.Value) && !stash.IsValidZKPayload(op.Value) {
+			return nil, ErrInvalidZKPayload
+		}
+		var err error
+		if values[i], encrypted[i], err = s.sealValue(op.Key, op.Value); err != nil {
+			return nil, fmt.Errorf("failed to encrypt key %q: %w", op.Key, err)
+		}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	res := make([]TxnResult, len(ops))
+	for i, op := range ops {
+		created, err := s.txnApply(ctx, tx, op, values[i], encrypted[i], now)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = TxnResult{Key: op.Key, Created: created}
+		if !op.Delete {
+			res[i].Version = now
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	log.Printf("[DEBUG] transaction of %d operations committed", len(ops))
+	return res, nil
+}
+
+// txnApply checks the preconditions of the operation and applies it in the transaction, value is sealed
+// and encrypted is its flag of encryption at rest. Returns true if the key was created.
+func (s *Store) txnApply(ctx context.Context, tx *sqlx.Tx, op TxnOp, value []byte, encrypted bool, now time.Time) (bool, error) {
+	var current time.Time
+	err := tx.GetContext(ctx, &current, s.adoptQuery("SELECT updated_at FROM kv WHERE key = ?"), op.Key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to get key %q: %w", op.Key, err)
+	}
+	exists := err == nil
+	conflict := &TxnConflictError{Key: op.Key, Version: current}
+	if (op.Absent && exists) || (op.Delete && !exists) || (!op.Version.IsZero() && (!exists || !current.Equal(op.Version))) {
+		return false, conflict
+	}
+
+	format := op.Format
+	if format == "" {
+		format = "text"
+	}
+	if !exists {
+		var owner any // NULL for keys without owner
+		if op.Owner != "" {
+			owner = op.Owner
+		}
+		insert := s.adoptQuery(`INSERT INTO kv (key, value, format, created_at, updated_at, sort_key, owner, encrypted)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if _, err = tx.ExecContext(ctx, insert, op.Key, value, format, now, now, sortKey(op.Key), owner, encrypted); err != nil {
+			if isUniqueViolation(err) {
+				return false, conflict // created by another instance sharing the database
+			}
+			return false, fmt.Errorf("failed to set key %q: %w", op.Key, err)
+		}
+		return true, nil
+	}
+
+	if s.HistoryEnabled() {
+		if err = s.archive(ctx, tx, op.Key); err != nil {
+			return false, err
+		}
+	}
+	// the update time is checked again, another instance sharing the database could change the key
+	query := s.adoptQuery(`UPDATE kv SET value = ?, encrypted = ?, format = ?, updated_at = ?, expires_at = NULL
+		WHERE key = ? AND updated_at = ?`)
+	args := []any{value, encrypted, format, now, op.Key, current}
+	if op.Delete {
+		query, args = s.adoptQuery("DELETE FROM kv WHERE key = ? AND updated_at = ?"), []any{op.Key, current}
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to change key %q: %w", op.Key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return false, conflict
+	}
+	return false, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Txn(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			enc, err := NewCrypto([]byte("test-secret-key-1234"))
+			require.NoError(t, err)
+			st := newTestStore(t, engine, WithEncryptor(enc), WithHistory(3))
+			ctx := t.Context()
+			prefix := "txn/" + engine + "/"
+
+			t.Run("applies all operations", func(t *testing.T) {
+				_, err := st.SetWithOptions(ctx, prefix+"a", []byte("a1"), "text", SetOptions{ExpiresAt: time.Now().Add(time.Hour)})
+				require.NoError(t, err)
+				_, err = st.Set(ctx, prefix+"b", []byte("b1"), "text")
+				require.NoError(t, err)
+				info, err := st.GetInfo(ctx, prefix+"a")
+				require.NoError(t, err)
+
+				res, err := st.Txn(ctx, []TxnOp{
+					{Key: prefix + "a", Value: []byte(`{"v":2}`), Format: "json", Version: info.UpdatedAt},
+					{Key: prefix + "b", Delete: true},
+					{Key: prefix + "c", Value: []byte("c1"), Absent: true, Owner: "user:alice"},
+					{Key: prefix + "secrets/d", Value: []byte("hidden")},
+				})
+				require.NoError(t, err)
+				require.Len(t, res, 4)
+				assert.False(t, res[0].Created)
+				assert.False(t, res[0].Version.IsZero())
+				assert.True(t, res[1].Version.IsZero(), "deleted key has no version")
+				assert.True(t, res[2].Created)
+				assert.True(t, res[3].Created)
+
+				value, format, err := st.GetWithFormat(ctx, prefix+"a")
+				require.NoError(t, err)
+				assert.JSONEq(t, `{"v":2}`, string(value))
+				assert.Equal(t, "json", format)
+				info, err = st.GetInfo(ctx, prefix+"a")
+				require.NoError(t, err)
+				assert.Nil(t, info.ExpiresAt, "set clears ttl")
+				assert.True(t, info.UpdatedAt.Equal(res[0].Version))
+
+				_, err = st.Get(ctx, prefix+"b")
+				require.ErrorIs(t, err, ErrNotFound)
+				value, format, err = st.GetWithFormat(ctx, prefix+"c")
+				require.NoError(t, err)
+				assert.Equal(t, "c1", string(value))
+				assert.Equal(t, "text", format)
+				info, err = st.GetInfo(ctx, prefix+"c")
+				require.NoError(t, err)
+				assert.Equal(t, "user:alice", info.Owner, "owner recorded with the created key")
+				value, err = st.Get(ctx, prefix+"secrets/d")
+				require.NoError(t, err)
+				assert.Equal(t, "hidden", string(value))
+
+				revs, err := st.GetHistory(ctx, prefix+"b", 0)
+				require.NoError(t, err)
+				require.Len(t, revs, 1, "deleted value is archived")
+				assert.Equal(t, "b1", string(revs[0].Value))
+			})
+
+			t.Run("failed precondition changes nothing", func(t *testing.T) {
+				_, err := st.Set(ctx, prefix+"p1", []byte("one"), "text")
+				require.NoError(t, err)
+				_, err = st.Set(ctx, prefix+"p2", []byte("two"), "text")
+				require.NoError(t, err)
+				info, err := st.GetInfo(ctx, prefix+"p2")
+				require.NoError(t, err)
+
+				tests := []struct {
+					name    string
+					op      TxnOp
+					version bool // conflict reports the current version of the key
+				}{
+					{name: "stale version", op: TxnOp{Key: prefix + "p2", Value: []byte("x"), Version: info.UpdatedAt.Add(-time.Hour)},
+						version: true},
+					{name: "version of missing key", op: TxnOp{Key: prefix + "p3", Value: []byte("x"), Version: info.UpdatedAt}},
+					{name: "existing key not absent", op: TxnOp{Key: prefix + "p2", Value: []byte("x"), Absent: true}, version: true},
+					{name: "delete missing key", op: TxnOp{Key: prefix + "p3", Delete: true}},
+				}
+				for _, tc := range tests {
+					t.Run(tc.name, func(t *testing.T) {
+						ops := []TxnOp{{Key: prefix + "p1", Value: []byte("changed")}, {Key: prefix + "new", Value: []byte("new")}, tc.op}
+						_, err := st.Txn(ctx, ops)
+						require.ErrorIs(t, err, ErrConflict)
+						var conflict *TxnConflictError
+						require.ErrorAs(t, err, &conflict)
+						assert.Equal(t, tc.op.Key, conflict.Key)
+						assert.Equal(t, tc.version, !conflict.Version.IsZero())
+
+						value, err := st.Get(ctx, prefix+"p1")
+						require.NoError(t, err)
+						assert.Equal(t, "one", string(value), "earlier operation rolled back")
+						_, err = st.Get(ctx, prefix+"new")
+						require.ErrorIs(t, err, ErrNotFound, "created key rolled back")
+						value, err = st.Get(ctx, prefix+"p2")
+						require.NoError(t, err)
+						assert.Equal(t, "two", string(value))
+					})
+				}
+
+				revs, err := st.GetHistory(ctx, prefix+"p1", 0)
+				require.NoError(t, err)
+				assert.Empty(t, revs, "archived values rolled back")
+			})
+
+			t.Run("rejects repeated key", func(t *testing.T) {
+				_, err := st.Txn(ctx, []TxnOp{{Key: prefix + "r", Value: []byte("1")}, {Key: prefix + "r", Delete: true}})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "more than once")
+				_, err = st.Get(ctx, prefix+"r")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("rejects invalid ZK payload", func(t *testing.T) {
+				_, err := st.Txn(ctx, []TxnOp{{Key: prefix + "z", Value: []byte("1")},
+					{Key: prefix + "secrets/zk", Value: []byte("$ZK$not-valid")}})
+				require.ErrorIs(t, err, ErrInvalidZKPayload)
+				_, err = st.Get(ctx, prefix+"z")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+		})
+	}
+
+	t.Run("secrets not configured", func(t *testing.T) {
+		st := newTestStore(t, "sqlite")
+		_, err := st.Txn(t.Context(), []TxnOp{{Key: "app/a", Value: []byte("1")}, {Key: "app/secrets/b", Value: []byte("2")}})
+		require.ErrorIs(t, err, ErrSecretsNotConfigured)
+		_, err = st.Get(t.Context(), "app/a")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -180,3 +180,23 @@ func TestService_IsValidFormat(t *testing.T) {
 		})
 	}
 }
+
+func FuzzService_Validate(f *testing.F) {
+	seeds := map[string][]string{
+		"json": {`{"key": "value"}`, `[1, {"a": null}]`, `{"key": "value",}`},
+		"yaml": {"key: value\nlist:\n  - a\n  - b\n", "a: &x [*x]\n", "key: [unclosed"},
+		"xml":  {`<root><item id="1">v</item></root>`, `<?xml version="1.0"?><a/>`, `<a><b></a>`},
+		"toml": {"[server]\nport = 8080\n", "a = [1, [2]]\n", "[[a]]\nb = {c = 1}\n"},
+		"ini":  {"[section]\nkey = value\n", "key=\"\"\"multi\nline\"\"\"\n", "[unclosed\n"},
+		"hcl":  {"resource \"a\" \"b\" {\n  x = 1\n}\n", "a = \"${b}\"\n", "block {\n"},
+	}
+	for format, values := range seeds {
+		for _, v := range values {
+			f.Add(format, []byte(v))
+		}
+	}
+	svc := NewService()
+	f.Fuzz(func(t *testing.T, format string, value []byte) {
+		_ = svc.Validate(format, value) // must not panic on any input
+	})
+}
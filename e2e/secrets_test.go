@@ -243,7 +243,9 @@ func TestSecrets_CardViewLockIcon(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, visible, "lock icon should be displayed in card view for secret keys")
 
-	// switch back to table view for cleanup
+	// switch back to table view for cleanup, through the tree view
+	require.NoError(t, page.Locator(`button[title="Toggle view mode"]`).Click())
+	waitVisible(t, page.Locator(".tree"))
 	require.NoError(t, page.Locator(`button[title="Toggle view mode"]`).Click())
 	waitVisible(t, page.Locator("table"))
 
@@ -0,0 +1,570 @@
+//go:build soak
+
+// Package soak runs long mixed workloads against a real stash server and checks it for leaks.
+//
+// The server is built from ./app and restarted every SOAK_RESTART_EVERY. While it runs, writers set, expire
+// and delete keys of a fixed keyspace, readers get and list them, watchers follow the changes across restarts
+// and short subscriptions come and go. The auth config is rewritten every SOAK_RELOAD_EVERY, reloaded by the
+// file watcher and every other time by SIGHUP too, and the reload is checked with a token it adds or removes.
+//
+// Before each restart the load is paused and the server is checked: goroutines and open files must come back
+// to where they were after it started, and it must shut down cleanly. The keyspace is fixed, so the database
+// must stop growing once it's filled. The server runs with --debug.profiler, goroutines are counted by pprof.
+//
+// Run with make soak, SOAK_DURATION sets the length, 2m by default for a quick run.
+package soak
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+const (
+	adminToken    = "soak-admin"
+	rotatingToken = "soak-rotating" // added and removed by config reloads
+	keyspace      = 200             // keys under soak/ set by writers, the database size is bounded by it
+
+	goroutineSlack = 25               // goroutines a paused server may have over its start, e.g. timers and pools
+	fdSlack        = 10               // open files a paused server may have over its start
+	dbGrowthSlack  = 8 << 20          // bytes the database may grow over twice its size after the first run
+	settleTimeout  = 15 * time.Second // max time for goroutines of the paused load to finish
+)
+
+// config is the soak run length and pacing, set by SOAK_* environment variables.
+type config struct {
+	duration     time.Duration // total run time
+	restartEvery time.Duration // server run time between restarts
+	reloadEvery  time.Duration // time between auth config reloads
+	sampleEvery  time.Duration // time between logged samples under load
+	workers      int           // writers and readers each, plus subscription churners
+	watchers     int           // watches of soak/ kept across restarts
+}
+
+// sample is the resource usage of the server process.
+type sample struct {
+	goroutines int
+	fds        int   // -1 if not available on the platform
+	dbSize     int64 // database and its WAL, bytes
+}
+
+func (s sample) String() string {
+	return fmt.Sprintf("goroutines=%d fds=%d db=%dKB", s.goroutines, s.fds, s.dbSize>>10)
+}
+
+// soak is a run of the workload against a server process restarted over time.
+type soak struct {
+	t        *testing.T
+	cfg      config
+	dir      string
+	bin      string
+	addr     string
+	dbPath   string
+	authPath string
+
+	cmd    *exec.Cmd
+	exited chan error
+
+	transport *http.Transport // shared by clients, idle connections are closed before checks
+	client    *stash.Client
+
+	gate     sync.RWMutex // load operations hold it for reading, checks take it for writing to pause the load
+	ops      atomic.Int64
+	failures atomic.Int64
+	errMu    sync.Mutex
+	errs     []string // first failures, for the report
+
+	watchEvents []atomic.Int64 // per watcher
+	watchResets []atomic.Int64 // per watcher, one per reconnect
+	restarts    int
+	reloads     int
+	rotating    bool // the rotating token is in the auth config
+}
+
+func TestSoak(t *testing.T) {
+	cfg := config{
+		duration:     envDuration(t, "SOAK_DURATION", 2*time.Minute),
+		restartEvery: envDuration(t, "SOAK_RESTART_EVERY", time.Minute),
+		reloadEvery:  envDuration(t, "SOAK_RELOAD_EVERY", 10*time.Second),
+		sampleEvery:  envDuration(t, "SOAK_SAMPLE_EVERY", 15*time.Second),
+		workers:      envInt(t, "SOAK_WORKERS", 4),
+		watchers:     envInt(t, "SOAK_WATCHERS", 2),
+	}
+	t.Logf("soak for %s, restart every %s, reload every %s, %d workers, %d watchers",
+		cfg.duration, cfg.restartEvery, cfg.reloadEvery, cfg.workers, cfg.watchers)
+
+	s := newSoak(t, cfg)
+	s.start()
+	defer s.kill()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	s.runLoad(ctx, &wg)
+
+	var dbSizes []int64 // after each clean shutdown
+	deadline := time.Now().Add(cfg.duration)
+	for {
+		s.pause()
+		baseline := s.settledSample()
+		s.resume()
+		t.Logf("server started (restart %d): %s", s.restarts, baseline)
+
+		runUntil := time.Now().Add(cfg.restartEvery)
+		if runUntil.After(deadline) {
+			runUntil = deadline
+		}
+		s.runFor(time.Until(runUntil))
+
+		s.pause()
+		final := s.checkLeaks(baseline)
+		t.Logf("server before stop: %s, ops=%d failures=%d", final, s.ops.Load(), s.failures.Load())
+		s.stop()
+		dbSizes = append(dbSizes, s.dbSize())
+		if time.Now().After(deadline) {
+			break
+		}
+		s.start()
+		s.restarts++
+		s.waitWatchers()
+		s.resume()
+	}
+	cancel()
+	s.resume()
+	wg.Wait()
+
+	s.report(dbSizes)
+}
+
+func newSoak(t *testing.T, cfg config) *soak {
+	dir, err := os.MkdirTemp("", "stash-soak-")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("server log and goroutine dumps kept in %s", dir)
+			return
+		}
+		_ = os.RemoveAll(dir)
+	})
+	s := &soak{t: t, cfg: cfg, dir: dir, bin: filepath.Join(dir, "stash"), dbPath: filepath.Join(dir, "soak.db"),
+		authPath: filepath.Join(dir, "auth.yml"), transport: http.DefaultTransport.(*http.Transport).Clone(),
+		watchEvents: make([]atomic.Int64, cfg.watchers), watchResets: make([]atomic.Int64, cfg.watchers)}
+
+	build := exec.Command("go", "build", "-o", s.bin, "./app")
+	build.Dir = filepath.Join("..", "..")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, "build failed: %s", out)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s.addr = l.Addr().String()
+	require.NoError(t, l.Close())
+
+	s.writeAuth()
+	s.client, err = stash.New("http://"+s.addr, stash.WithToken(adminToken), stash.WithRetry(0, 0),
+		stash.WithHTTPClient(&http.Client{Transport: s.transport, Timeout: 10 * time.Second}))
+	require.NoError(t, err)
+	return s
+}
+
+// start runs the server and waits until it answers. The load stays paused by the caller.
+func (s *soak) start() {
+	logFile, err := os.OpenFile(filepath.Join(s.dir, "server.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(s.t, err)
+	cmd := exec.Command(s.bin, "server", "--dbg",
+		"--server.address="+s.addr,
+		"--db="+s.dbPath,
+		"--auth.file="+s.authPath,
+		"--auth.hot-reload",
+		"--history.revisions=5",
+		"--server.expiry-interval=1s",
+		"--limits.requests-per-sec=100000",
+		"--debug.profiler",
+	)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	require.NoError(s.t, cmd.Start())
+	s.cmd, s.exited = cmd, make(chan error, 1)
+	go func() {
+		s.exited <- cmd.Wait()
+		_ = logFile.Close()
+	}()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := s.client.Ping(context.Background()); err == nil {
+			return
+		}
+		select {
+		case err := <-s.exited:
+			s.t.Fatalf("server exited on start: %v, see %s", err, s.tailLog())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	s.t.Fatalf("server not ready after 30s, see %s", s.tailLog())
+}
+
+// stop sends SIGTERM and requires the server to shut down cleanly.
+func (s *soak) stop() {
+	require.NoError(s.t, s.cmd.Process.Signal(syscall.SIGTERM))
+	select {
+	case err := <-s.exited:
+		require.NoError(s.t, err, "server didn't exit cleanly, see %s", s.tailLog())
+	case <-time.After(20 * time.Second):
+		s.t.Fatalf("server didn't stop in 20s, see %s", s.tailLog())
+	}
+	s.cmd = nil
+}
+
+// kill stops a server left running by a failed check.
+func (s *soak) kill() {
+	if s.cmd != nil && s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+		<-s.exited
+	}
+}
+
+// runLoad starts the workers, they run until ctx is canceled.
+func (s *soak) runLoad(ctx context.Context, wg *sync.WaitGroup) {
+	for i := range s.cfg.watchers {
+		events, err := s.client.Watch(ctx, "soak/")
+		require.NoError(s.t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ev := range events {
+				if ev.Action == "reset" {
+					s.watchResets[i].Add(1)
+					continue
+				}
+				s.watchEvents[i].Add(1)
+			}
+		}()
+	}
+	for i := range s.cfg.workers {
+		for _, worker := range []func(context.Context, *rand.Rand){s.write, s.read, s.churn} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rnd := rand.New(rand.NewPCG(uint64(i), uint64(time.Now().UnixNano()))) //nolint:gosec // load pattern
+				for ctx.Err() == nil {
+					worker(ctx, rnd)
+					time.Sleep(time.Duration(5+rnd.IntN(10)) * time.Millisecond)
+				}
+			}()
+		}
+	}
+}
+
+// do runs an operation of the load, unless the load is paused, and counts its failures.
+func (s *soak) do(ctx context.Context, op string, fn func() error) {
+	s.gate.RLock()
+	defer s.gate.RUnlock()
+	if ctx.Err() != nil {
+		return
+	}
+	s.ops.Add(1)
+	err := fn()
+	if err == nil || errors.Is(err, stash.ErrNotFound) || ctx.Err() != nil {
+		return
+	}
+	s.failures.Add(1)
+	s.errMu.Lock()
+	if len(s.errs) < 10 {
+		s.errs = append(s.errs, fmt.Sprintf("%s: %v", op, err))
+	}
+	s.errMu.Unlock()
+}
+
+// write sets, expires or deletes a key.
+func (s *soak) write(ctx context.Context, rnd *rand.Rand) {
+	key := fmt.Sprintf("soak/k%03d", rnd.IntN(keyspace))
+	value := strings.Repeat(strconv.Itoa(rnd.IntN(10)), 16+rnd.IntN(1024))
+	switch p := rnd.Float64(); {
+	case p < 0.5:
+		s.do(ctx, "set", func() error { return s.client.Set(ctx, key, value) })
+	case p < 0.65:
+		doc := fmt.Sprintf(`{"n":%d,"v":%q}`, rnd.IntN(1000), value[:16])
+		s.do(ctx, "set json", func() error { return s.client.SetWithFormat(ctx, key, doc, stash.FormatJSON) })
+	case p < 0.85:
+		ttlKey := fmt.Sprintf("soak/ttl/%02d", rnd.IntN(20))
+		s.do(ctx, "set ttl", func() error { return s.client.SetWithTTL(ctx, ttlKey, value, 2*time.Second) })
+	default:
+		s.do(ctx, "delete", func() error { return s.client.Delete(ctx, key) })
+	}
+}
+
+// read gets a key or lists the keyspace.
+func (s *soak) read(ctx context.Context, rnd *rand.Rand) {
+	if rnd.IntN(10) == 0 {
+		s.do(ctx, "list", func() error {
+			_, err := s.client.List(ctx, "soak/")
+			return err
+		})
+		return
+	}
+	key := fmt.Sprintf("soak/k%03d", rnd.IntN(keyspace))
+	s.do(ctx, "get", func() error {
+		_, err := s.client.Get(ctx, key)
+		return err
+	})
+}
+
+// churn opens a short subscription, the server must release it once closed.
+func (s *soak) churn(ctx context.Context, rnd *rand.Rand) {
+	key := fmt.Sprintf("soak/k%03d", rnd.IntN(keyspace))
+	s.do(ctx, "subscribe", func() error {
+		sub, err := s.client.Subscribe(ctx, key)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(50+rnd.IntN(150)) * time.Millisecond):
+		case <-ctx.Done():
+		}
+		sub.Close()
+		return nil
+	})
+}
+
+// runFor keeps the load running for d, reloading the auth config and logging samples on the way.
+func (s *soak) runFor(d time.Duration) {
+	end := time.After(d)
+	reload, logSample := time.NewTicker(s.cfg.reloadEvery), time.NewTicker(s.cfg.sampleEvery)
+	defer reload.Stop()
+	defer logSample.Stop()
+	for {
+		select {
+		case <-end:
+			return
+		case <-reload.C:
+			s.reload()
+		case <-logSample.C:
+			smp := s.sample()
+			s.t.Logf("under load: %s, ops=%d failures=%d", smp, s.ops.Load(), s.failures.Load())
+		}
+	}
+}
+
+// reload adds or removes the rotating token by rewriting the watched auth file, every other time with SIGHUP
+// on top, and waits until the server applies it.
+func (s *soak) reload() {
+	s.rotating = !s.rotating
+	s.reloads++
+	s.writeAuth()
+	if s.reloads%2 == 0 {
+		require.NoError(s.t, s.cmd.Process.Signal(syscall.SIGHUP))
+	}
+
+	want := http.StatusUnauthorized
+	if s.rotating {
+		want = http.StatusNotFound
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		req, err := http.NewRequest(http.MethodGet, "http://"+s.addr+"/kv/soak/no-such-key", http.NoBody)
+		require.NoError(s.t, err)
+		req.Header.Set("Authorization", "Bearer "+rotatingToken)
+		resp, err := s.transport.RoundTrip(req)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode == want {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			s.t.Errorf("auth reload %d not applied in 10s, rotating token expected to get %d", s.reloads, want)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// writeAuth writes the auth config, with the rotating token if it's enabled.
+func (s *soak) writeAuth() {
+	conf := "tokens:\n" +
+		"  - token: \"" + adminToken + "\"\n    admin: true\n    permissions:\n      - prefix: \"*\"\n        access: rw\n"
+	if s.rotating {
+		conf += "  - token: \"" + rotatingToken + "\"\n    permissions:\n      - prefix: \"soak/*\"\n        access: r\n"
+	}
+	require.NoError(s.t, os.WriteFile(s.authPath, []byte(conf), 0o600))
+}
+
+// pause stops new operations of the load and waits for running ones, watchers stay connected.
+func (s *soak) pause() {
+	s.gate.Lock()
+}
+
+// resume lets the paused load continue.
+func (s *soak) resume() {
+	s.gate.Unlock()
+}
+
+// settledSample samples the paused server once connections of the load are closed.
+func (s *soak) settledSample() sample {
+	s.transport.CloseIdleConnections()
+	time.Sleep(2 * time.Second)
+	return s.sample()
+}
+
+// checkLeaks waits for goroutines and open files of the paused server to come back close to the baseline,
+// and fails the test with a goroutine dump if they don't.
+func (s *soak) checkLeaks(baseline sample) sample {
+	s.transport.CloseIdleConnections()
+	deadline := time.Now().Add(settleTimeout)
+	for {
+		time.Sleep(500 * time.Millisecond)
+		smp := s.sample()
+		grown := smp.goroutines > baseline.goroutines+goroutineSlack || (smp.fds >= 0 && smp.fds > baseline.fds+fdSlack)
+		if !grown {
+			return smp
+		}
+		if time.Now().After(deadline) {
+			dump := filepath.Join(s.dir, fmt.Sprintf("goroutines-%d.txt", s.restarts))
+			_ = os.WriteFile(dump, []byte(s.profile(2)), 0o600)
+			s.t.Errorf("leak before restart %d: %s, started with %s, goroutines in %s", s.restarts+1, smp, baseline, dump)
+			return smp
+		}
+	}
+}
+
+// waitWatchers waits until every watcher reconnected after the restart, so the baseline counts their connections.
+func (s *soak) waitWatchers() {
+	deadline := time.Now().Add(45 * time.Second) // the watch backoff goes up to 30s
+	for i := range s.watchResets {
+		for s.watchResets[i].Load() < int64(s.restarts) {
+			if time.Now().After(deadline) {
+				s.t.Errorf("watcher %d didn't reconnect after restart %d", i, s.restarts)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// sample returns the current resource usage of the server.
+func (s *soak) sample() sample {
+	res := sample{fds: -1, dbSize: s.dbSize()}
+	line, _, _ := strings.Cut(s.profile(1), "\n")
+	total, ok := strings.CutPrefix(line, "goroutine profile: total ")
+	require.True(s.t, ok, "unexpected goroutine profile %q", line)
+	var err error
+	res.goroutines, err = strconv.Atoi(total)
+	require.NoError(s.t, err)
+	if entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", s.cmd.Process.Pid)); err == nil {
+		res.fds = len(entries)
+	}
+	return res
+}
+
+// profile returns the goroutine profile of the server, debug 1 counts stacks, 2 lists every goroutine.
+func (s *soak) profile(debug int) string {
+	url := fmt.Sprintf("http://%s/debug/pprof/goroutine?debug=%d", s.addr, debug)
+	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	require.NoError(s.t, err)
+	req.Close = true // the profile connection is not a part of the sample
+	resp, err := s.transport.RoundTrip(req)
+	require.NoError(s.t, err)
+	defer resp.Body.Close()
+	require.Equal(s.t, http.StatusOK, resp.StatusCode)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.t, err)
+	return string(data)
+}
+
+// dbSize returns the size of the database with its WAL.
+func (s *soak) dbSize() int64 {
+	var res int64
+	for _, p := range []string{s.dbPath, s.dbPath + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			res += fi.Size()
+		}
+	}
+	return res
+}
+
+// report logs totals and checks failures, watchers and database growth over the whole run.
+func (s *soak) report(dbSizes []int64) {
+	s.t.Logf("done: restarts=%d reloads=%d ops=%d failures=%d db sizes (KB) %v",
+		s.restarts, s.reloads, s.ops.Load(), s.failures.Load(), kb(dbSizes))
+	for _, e := range s.errs {
+		s.t.Logf("failure: %s", e)
+	}
+	if n := s.failures.Load(); n > 0 {
+		s.t.Errorf("%d of %d operations failed", n, s.ops.Load())
+	}
+	for i := range s.watchEvents {
+		s.t.Logf("watcher %d: events=%d resets=%d", i, s.watchEvents[i].Load(), s.watchResets[i].Load())
+		if s.watchEvents[i].Load() == 0 {
+			s.t.Errorf("watcher %d got no events", i)
+		}
+	}
+	if limit := 2*dbSizes[0] + dbGrowthSlack; dbSizes[len(dbSizes)-1] > limit {
+		s.t.Errorf("database keeps growing: %d bytes, limit %d", dbSizes[len(dbSizes)-1], limit)
+	}
+}
+
+// tailLog returns the path of the server log with its last lines.
+func (s *soak) tailLog() string {
+	path := filepath.Join(s.dir, "server.log")
+	f, err := os.Open(path) //nolint:gosec // test log in the temp dir
+	if err != nil {
+		return path
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > 20 {
+			lines = lines[1:]
+		}
+	}
+	return path + ":\n" + strings.Join(lines, "\n")
+}
+
+func kb(sizes []int64) []int64 {
+	res := make([]int64, len(sizes))
+	for i, v := range sizes {
+		res[i] = v >> 10
+	}
+	return res
+}
+
+func envDuration(t *testing.T, name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	require.NoError(t, err, "invalid %s", name)
+	return d
+}
+
+func envInt(t *testing.T, name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	require.NoError(t, err, "invalid %s", name)
+	return n
+}
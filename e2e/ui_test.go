@@ -111,6 +111,13 @@ func TestUI_ViewModeToggle(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, visible, "cards container should be visible")
 
+	// toggle to tree, the key is under its folder
+	require.NoError(t, page.Locator(`button[title="Toggle view mode"]`).Click())
+	folder := page.Locator(`.tree-folder:has(.tree-name:text-is("e2e-ui/"))`)
+	waitVisible(t, folder)
+	require.NoError(t, folder.Locator(".tree-toggle").Click())
+	waitVisible(t, folder.Locator(`.tree-key .tree-name:text-is("viewmode")`))
+
 	// toggle back to table
 	require.NoError(t, page.Locator(`button[title="Toggle view mode"]`).Click())
 	waitVisible(t, page.Locator("table"))
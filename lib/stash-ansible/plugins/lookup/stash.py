@@ -0,0 +1,90 @@
+"""Ansible lookup plugin reading stash keys."""
+
+from __future__ import annotations
+
+DOCUMENTATION = r"""
+name: stash
+short_description: Read keys from Stash
+description:
+  - Returns values of the keys from a Stash server, one per term.
+  - Lookups run on the controller. Mark tasks using secret values with C(no_log) to keep them out of output.
+options:
+  _terms:
+    description: Key names.
+    required: true
+  url:
+    description: Stash server URL.
+    type: str
+    env:
+      - name: STASH_URL
+    vars:
+      - name: stash_url
+  token:
+    description: API token.
+    type: str
+    env:
+      - name: STASH_TOKEN
+    vars:
+      - name: stash_token
+  default:
+    description: Value returned for missing keys, missing keys fail the lookup if not set.
+    type: str
+  timeout:
+    description: Request timeout in seconds.
+    type: int
+    default: 30
+  validate_certs:
+    description: Verify the server TLS certificate.
+    type: bool
+    default: true
+"""
+
+EXAMPLES = r"""
+- name: Use a config value
+  ansible.builtin.debug:
+    msg: "{{ lookup('umputun.stash.stash', 'app/db/host') }}"
+
+- name: Template a secret without logging it
+  ansible.builtin.template:
+    src: db.conf.j2
+    dest: /etc/app/db.conf
+  vars:
+    db_password: "{{ lookup('umputun.stash.stash', 'app/secrets/db-password') }}"
+  no_log: true
+
+- name: Several keys with a fallback for missing ones
+  ansible.builtin.set_fact:
+    features: "{{ query('umputun.stash.stash', 'app/feature/a', 'app/feature/b', default='off') }}"
+"""
+
+RETURN = r"""
+_raw:
+  description: Values of the keys.
+  type: list
+  elements: str
+"""
+
+from ansible.errors import AnsibleError
+from ansible.plugins.lookup import LookupBase
+
+from ansible_collections.umputun.stash.plugins.module_utils.stash_api import StashAPI, StashError
+
+
+class LookupModule(LookupBase):
+    def run(self, terms, variables=None, **kwargs):
+        self.set_options(var_options=variables, direct=kwargs)
+        default = self.get_option("default")
+        try:
+            api = StashAPI(self.get_option("url"), self.get_option("token"), self.get_option("timeout"),
+                           self.get_option("validate_certs"))
+            values = []
+            for key in terms:
+                value = api.get(key)
+                if value is None:
+                    if default is None:
+                        raise AnsibleError(f"stash key {key!r} not found")
+                    value = default
+                values.append(value)
+        except StashError as e:
+            raise AnsibleError(f"stash lookup failed: {e}") from None
+        return values
@@ -0,0 +1,145 @@
+"""Stash HTTP API client shared by the stash lookup and module.
+
+Uses the standard library only, so the module runs on managed hosts without extra packages.
+Errors never include values or tokens, they may end up in task output.
+"""
+
+from __future__ import annotations
+
+import json
+import ssl
+from urllib.error import HTTPError, URLError
+from urllib.parse import quote, urlencode
+from urllib.request import Request, urlopen
+
+FORMATS = ["text", "json", "yaml", "xml", "toml", "ini", "hcl", "shell"]
+
+
+class StashError(Exception):
+    """Failed request, status is the HTTP status or 0 for connection errors."""
+
+    def __init__(self, msg: str, status: int = 0):
+        super().__init__(msg)
+        self.status = status
+
+
+class StashAPI:
+    """Minimal client of the /kv API."""
+
+    def __init__(self, url: str | None, token: str | None = None, timeout: float = 30, validate_certs: bool = True):
+        if not url:
+            raise StashError("stash url is required, set url or STASH_URL")
+        self.url = url.rstrip("/")
+        self.token = token
+        self.timeout = timeout
+        self.context = None if validate_certs else ssl._create_unverified_context()  # noqa: S323
+
+    def get(self, key: str) -> str | None:
+        """Returns the value of the key, None if it doesn't exist."""
+        try:
+            return self._request("GET", self._key_path(key)).decode("utf-8")
+        except StashError as e:
+            if e.status == 404:
+                return None
+            raise
+
+    def info(self, key: str) -> dict | None:
+        """Returns metadata of the key (format, secret, size, updated_at), None if it doesn't exist."""
+        for item in self.list(key):
+            if item.get("key") == key:
+                return item
+        return None
+
+    def list(self, prefix: str = "") -> list[dict]:
+        """Returns metadata of keys under the prefix, all keys for an empty prefix."""
+        path = "/kv/"
+        if prefix:
+            path += "?" + urlencode({"prefix": prefix})
+        return json.loads(self._request("GET", path) or b"[]")
+
+    def set(self, key: str, value: str, fmt: str = "text") -> None:
+        """Stores the value with the format."""
+        self._request("PUT", self._key_path(key), value.encode("utf-8"), {"X-Stash-Format": fmt})
+
+    def delete(self, key: str) -> bool:
+        """Deletes the key, returns False if it didn't exist."""
+        try:
+            self._request("DELETE", self._key_path(key))
+        except StashError as e:
+            if e.status == 404:
+                return False
+            raise
+        return True
+
+    def _key_path(self, key: str) -> str:
+        key = key.strip("/")
+        if not key:
+            raise StashError("key is required")
+        return "/kv/" + quote(key)
+
+    def _request(self, method: str, path: str, body: bytes | None = None, headers: dict | None = None) -> bytes:
+        req = Request(self.url + path, data=body, method=method, headers=headers or {})
+        if self.token:
+            req.add_header("Authorization", "Bearer " + self.token)
+        try:
+            with urlopen(req, timeout=self.timeout, context=self.context) as resp:  # noqa: S310
+                return resp.read()
+        except HTTPError as e:
+            raise StashError(f"{method} {path}: {e.code} {_error_message(e)}", e.code) from None
+        except URLError as e:
+            raise StashError(f"{method} {path}: {e.reason}") from None
+
+
+def _error_message(e: HTTPError) -> str:
+    """Extracts the error of a json error response, the status text otherwise."""
+    try:
+        return json.loads(e.read())["error"]
+    except (ValueError, KeyError, TypeError):
+        return str(e.reason)
+
+
+def ensure_key(
+    api: StashAPI, key: str, value: str | None = None, fmt: str = "text", state: str = "present", check_mode: bool = False
+) -> dict:
+    """Brings the key to the state, the result of the stash_key module.
+
+    The value is written only if it or the format differ. Diff has values only for non-secret keys,
+    secret values never leave the module.
+    """
+    key = key.strip("/")
+    info = api.info(key)
+    secret = is_secret(key) or bool(info and info.get("secret"))
+    result = {"changed": False, "key": key, "secret": secret}
+
+    if state == "absent":
+        if info is None:
+            return result
+        result["changed"] = True
+        result["diff"] = {"before": _shown(None if secret else api.get(key), secret), "after": ""}
+        if not check_mode:
+            api.delete(key)
+        return result
+
+    if value is None:
+        raise StashError("value is required with state=present")
+    current = api.get(key) if info is not None else None
+    result["format"] = fmt
+    if current == value and info.get("format", "text") == fmt:
+        return result
+    result["changed"] = True
+    result["diff"] = {"before": _shown(current or "", secret), "after": _shown(value, secret)}
+    if not check_mode:
+        api.set(key, value, fmt)
+    return result
+
+
+def is_secret(key: str) -> bool:
+    """Reports keys with a "secrets" path segment, the ones the server stores encrypted."""
+    return "secrets" in key.strip("/").split("/")
+
+
+def _shown(value: str | None, secret: bool) -> str:
+    """Returns the value for diff output, masked for secrets."""
+    if secret and value:
+        return "********"
+    return value or ""
@@ -0,0 +1,118 @@
+"""Ansible module managing a stash key."""
+
+from __future__ import annotations
+
+DOCUMENTATION = r"""
+module: stash_key
+short_description: Manage keys in Stash
+description:
+  - Creates, updates or deletes a key in a Stash server through the HTTP API.
+  - The value is written only when it or the format differ from the stored ones.
+  - The value is never logged. Diff output shows values of non-secret keys only, keys with a
+    C(secrets) path segment are masked.
+options:
+  url:
+    description: Stash server URL, C(STASH_URL) environment variable if not set.
+    type: str
+  token:
+    description: API token, C(STASH_TOKEN) environment variable if not set.
+    type: str
+  key:
+    description: Key name, e.g. C(app/db/host).
+    type: str
+    required: true
+  value:
+    description: Value of the key, required with O(state=present).
+    type: str
+  format:
+    description: Value format.
+    type: str
+    default: text
+    choices: [text, json, yaml, xml, toml, ini, hcl, shell]
+  state:
+    description: Whether the key should exist.
+    type: str
+    default: present
+    choices: [present, absent]
+  timeout:
+    description: Request timeout in seconds.
+    type: int
+    default: 30
+  validate_certs:
+    description: Verify the server TLS certificate.
+    type: bool
+    default: true
+"""
+
+EXAMPLES = r"""
+- name: Set database host
+  umputun.stash.stash_key:
+    url: https://stash.example.com
+    key: app/db/host
+    value: db1.internal
+
+- name: Store JSON config
+  umputun.stash.stash_key:
+    key: app/config
+    value: "{{ app_config | to_json }}"
+    format: json
+
+- name: Store a secret, masked in diff and logs
+  umputun.stash.stash_key:
+    key: app/secrets/db-password
+    value: "{{ vault_db_password }}"
+
+- name: Delete a key
+  umputun.stash.stash_key:
+    key: app/legacy
+    state: absent
+"""
+
+RETURN = r"""
+key:
+  description: Key name.
+  returned: always
+  type: str
+format:
+  description: Format of the value.
+  returned: state is present
+  type: str
+secret:
+  description: Whether the key is a secret.
+  returned: always
+  type: bool
+"""
+
+from ansible.module_utils.basic import AnsibleModule, env_fallback
+
+from ansible_collections.umputun.stash.plugins.module_utils.stash_api import FORMATS, StashAPI, StashError, ensure_key
+
+
+def main():
+    module = AnsibleModule(
+        argument_spec={
+            "url": {"type": "str", "fallback": (env_fallback, ["STASH_URL"])},
+            "token": {"type": "str", "no_log": True, "fallback": (env_fallback, ["STASH_TOKEN"])},
+            "key": {"type": "str", "required": True, "no_log": False},
+            "value": {"type": "str", "no_log": True},
+            "format": {"type": "str", "default": "text", "choices": FORMATS},
+            "state": {"type": "str", "default": "present", "choices": ["present", "absent"]},
+            "timeout": {"type": "int", "default": 30},
+            "validate_certs": {"type": "bool", "default": True},
+        },
+        required_if=[("state", "present", ["value"])],
+        supports_check_mode=True,
+    )
+    p = module.params
+    try:
+        api = StashAPI(p["url"], p["token"], p["timeout"], p["validate_certs"])
+        result = ensure_key(api, p["key"], p["value"], p["format"], p["state"], module.check_mode)
+    except StashError as e:
+        module.fail_json(msg=str(e), key=p["key"])
+    if not module._diff:
+        result.pop("diff", None)
+    module.exit_json(**result)
+
+
+if __name__ == "__main__":
+    main()
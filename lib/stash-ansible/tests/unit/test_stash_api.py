@@ -0,0 +1,181 @@
+"""Tests for the stash API client and ensure_key, against a fake stash server."""
+
+import importlib.util
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from pathlib import Path
+from urllib.parse import parse_qs, unquote, urlparse
+
+import pytest
+
+# module_utils is loaded by path, the collection is not installed under ansible_collections in tests
+_spec = importlib.util.spec_from_file_location(
+    "stash_api", Path(__file__).parents[2] / "plugins" / "module_utils" / "stash_api.py"
+)
+stash_api = importlib.util.module_from_spec(_spec)
+_spec.loader.exec_module(stash_api)
+
+
+class FakeStash(ThreadingHTTPServer):
+    """Keeps keys in memory and records requests as (method, path, authorization)."""
+
+    def __init__(self):
+        super().__init__(("127.0.0.1", 0), FakeHandler)
+        self.keys = {}  # key -> (value, format)
+        self.requests = []
+
+    @property
+    def url(self):
+        return f"http://127.0.0.1:{self.server_address[1]}"
+
+
+class FakeHandler(BaseHTTPRequestHandler):
+    def log_message(self, *args):
+        pass
+
+    def _reply(self, status, body=b""):
+        self.send_response(status)
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def _handle(self):
+        srv = self.server
+        srv.requests.append((self.command, self.path, self.headers.get("Authorization")))
+        if self.headers.get("Authorization") == "Bearer bad":
+            return self._reply(401, b'{"error":"unauthorized"}')
+        u = urlparse(self.path)
+        if u.path == "/kv/":
+            prefix = parse_qs(u.query).get("prefix", [""])[0]
+            items = [
+                {"key": k, "format": f, "size": len(v), "secret": "secrets" in k.split("/")}
+                for k, (v, f) in sorted(srv.keys.items())
+                if k.startswith(prefix)
+            ]
+            return self._reply(200, json.dumps(items).encode())
+        key = unquote(u.path[len("/kv/") :])
+        if self.command == "GET":
+            if key not in srv.keys:
+                return self._reply(404, b'{"error":"not found"}')
+            return self._reply(200, srv.keys[key][0].encode())
+        if self.command == "PUT":
+            body = self.rfile.read(int(self.headers.get("Content-Length", 0))).decode()
+            srv.keys[key] = (body, self.headers.get("X-Stash-Format", "text"))
+            return self._reply(200)
+        if self.command == "DELETE":
+            if srv.keys.pop(key, None) is None:
+                return self._reply(404, b'{"error":"not found"}')
+            return self._reply(204)
+        return self._reply(405)
+
+    do_GET = do_PUT = do_DELETE = _handle  # noqa: N815
+
+
+@pytest.fixture
+def server():
+    srv = FakeStash()
+    thread = threading.Thread(target=srv.serve_forever, daemon=True)
+    thread.start()
+    yield srv
+    srv.shutdown()
+    srv.server_close()
+
+
+class TestStashAPI:
+    def test_url_required(self):
+        with pytest.raises(stash_api.StashError, match="stash url is required"):
+            stash_api.StashAPI("")
+
+    def test_get_set_delete(self, server):
+        api = stash_api.StashAPI(server.url + "/", token="t1")
+        assert api.get("app/a") is None
+        api.set("app/a b", "v1", "yaml")
+        assert server.keys["app/a b"] == ("v1", "yaml")
+        assert api.get("app/a b") == "v1"
+        assert api.info("app/a b")["format"] == "yaml"
+        assert api.info("app/a") is None
+        assert api.delete("app/a b") is True
+        assert api.delete("app/a b") is False
+        assert all(auth == "Bearer t1" for _, _, auth in server.requests)
+        assert ("PUT", "/kv/app/a%20b", "Bearer t1") in server.requests
+
+    def test_list(self, server):
+        server.keys = {"app/a": ("1", "text"), "app/b": ("2", "json"), "other": ("3", "text")}
+        api = stash_api.StashAPI(server.url)
+        assert [i["key"] for i in api.list("app/")] == ["app/a", "app/b"]
+        assert len(api.list()) == 3
+
+    def test_error_has_no_secrets(self, server):
+        api = stash_api.StashAPI(server.url, token="bad")
+        with pytest.raises(stash_api.StashError) as e:
+            api.set("app/secrets/pass", "s3cr3t")
+        assert e.value.status == 401
+        assert str(e.value) == "PUT /kv/app/secrets/pass: 401 unauthorized"
+
+    def test_connection_error(self):
+        api = stash_api.StashAPI("http://127.0.0.1:1", timeout=1)
+        with pytest.raises(stash_api.StashError) as e:
+            api.get("a")
+        assert e.value.status == 0
+
+
+class TestEnsureKey:
+    def test_create_update_unchanged(self, server):
+        api = stash_api.StashAPI(server.url)
+        res = stash_api.ensure_key(api, "app/host", "db1")
+        assert res["changed"] is True
+        assert res["diff"] == {"before": "", "after": "db1"}
+        assert server.keys["app/host"] == ("db1", "text")
+
+        res = stash_api.ensure_key(api, "app/host", "db1")
+        assert res["changed"] is False
+        assert "diff" not in res
+
+        res = stash_api.ensure_key(api, "app/host", "db1", fmt="yaml")
+        assert res["changed"] is True, "format change is a change"
+        assert server.keys["app/host"] == ("db1", "yaml")
+
+    def test_check_mode(self, server):
+        api = stash_api.StashAPI(server.url)
+        res = stash_api.ensure_key(api, "app/host", "db1", check_mode=True)
+        assert res["changed"] is True
+        assert server.keys == {}
+
+        server.keys["app/host"] = ("db1", "text")
+        res = stash_api.ensure_key(api, "app/host", state="absent", check_mode=True)
+        assert res["changed"] is True
+        assert "app/host" in server.keys
+
+    def test_absent(self, server):
+        server.keys["app/host"] = ("db1", "text")
+        api = stash_api.StashAPI(server.url)
+        res = stash_api.ensure_key(api, "app/host", state="absent")
+        assert res["changed"] is True
+        assert res["diff"] == {"before": "db1", "after": ""}
+        assert server.keys == {}
+        assert stash_api.ensure_key(api, "app/host", state="absent")["changed"] is False
+
+    def test_secret_masked(self, server):
+        api = stash_api.StashAPI(server.url)
+        res = stash_api.ensure_key(api, "app/secrets/pass", "s3cr3t")
+        assert res["secret"] is True
+        assert res["diff"] == {"before": "", "after": "********"}
+        assert "s3cr3t" not in json.dumps(res)
+
+        res = stash_api.ensure_key(api, "app/secrets/pass", state="absent")
+        assert "s3cr3t" not in json.dumps(res)
+        assert not any(m == "GET" and p.startswith("/kv/app/secrets") for m, p, _ in server.requests[-3:]), (
+            "secret value not read for delete"
+        )
+
+    def test_value_required(self, server):
+        with pytest.raises(stash_api.StashError, match="value is required"):
+            stash_api.ensure_key(stash_api.StashAPI(server.url), "app/host")
+
+
+def test_is_secret():
+    assert stash_api.is_secret("secrets/db")
+    assert stash_api.is_secret("app/secrets/db")
+    assert stash_api.is_secret("/app/secrets/")
+    assert not stash_api.is_secret("my-secrets/db")
@@ -0,0 +1,157 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ImportMode defines how Import treats keys that already exist.
+type ImportMode string
+
+// import modes
+const (
+	ImportMerge     ImportMode = "merge"     // set all bundled keys, keep other keys
+	ImportSkip      ImportMode = "skip"      // set only keys that don't exist yet
+	ImportOverwrite ImportMode = "overwrite" // set all bundled keys, delete other keys under the prefix (required)
+)
+
+// Bundle is a set of keys exported from the server, accepted by Import.
+type Bundle struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at,omitzero"`
+	Keys       []BundleKey `json:"keys"`
+}
+
+// BundleKey is an exported key. Values that are not valid UTF-8 are base64 encoded, see Bytes.
+type BundleKey struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	Format    string    `json:"format,omitempty"`
+	Encoding  string    `json:"encoding,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Bytes returns the decoded value of the key.
+func (k BundleKey) Bytes() ([]byte, error) {
+	switch k.Encoding {
+	case "":
+		return []byte(k.Value), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(k.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value of %q: %w", k.Key, err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q of %q", k.Encoding, k.Key)
+	}
+}
+
+// ExportRequest selects keys exported by Export. Empty prefix exports all keys the client can read.
+type ExportRequest struct {
+	Prefix      string
+	SkipSecrets bool // leave secrets out, they are exported decrypted otherwise
+}
+
+// ImportRequest defines a bundle restored by Import. Empty mode is ImportMerge.
+// Prefix limits the keys deleted by ImportOverwrite and is required by it, bundled keys must be under it.
+type ImportRequest struct {
+	Bundle Bundle
+	Mode   ImportMode
+	Prefix string
+}
+
+// ImportResult is the number of keys changed by Import. Skipped keys existed already in ImportSkip mode.
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+}
+
+// Export returns the keys the client can read with values and formats, for a backup or a copy
+// to another server with Import.
+func (c *Client) Export(ctx context.Context, er ExportRequest) (Bundle, error) {
+	base, err := c.base(ctx)
+	if err != nil {
+		return Bundle{}, err
+	}
+	query := url.Values{}
+	if er.Prefix != "" {
+		query.Set("prefix", er.Prefix)
+	}
+	if er.SkipSecrets {
+		query.Set("filter", "keys")
+	}
+	u := base + "/kv/_export"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.do(req, OpExport)
+	if err != nil {
+		return Bundle{}, err
+	}
+	defer resp.Body.Close()
+
+	var res Bundle
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Bundle{}, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	return res, nil
+}
+
+// Import restores keys of a bundle made by Export. The server requires an admin token when auth is enabled,
+// and checks the whole bundle before changing anything.
+func (c *Client) Import(ctx context.Context, ir ImportRequest) (ImportResult, error) {
+	base, err := c.base(ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	query := url.Values{}
+	if ir.Mode != "" {
+		query.Set("mode", string(ir.Mode))
+	}
+	if ir.Prefix != "" {
+		query.Set("prefix", ir.Prefix)
+	}
+	u := base + "/kv/_import"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	data, err := json.Marshal(ir.Bundle)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req, OpImport)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if c.cache != nil {
+		c.cache.clear() // any key under the prefix may have changed or gone
+	}
+
+	var res ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to decode import result: %w", err)
+	}
+	return res, nil
+}
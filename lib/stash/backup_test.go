@@ -0,0 +1,81 @@
+package stash
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Export(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kv/_export", r.URL.Path)
+		assert.Equal(t, http.MethodGet, r.Method)
+		if r.URL.Query().Get("prefix") == "denied/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "app/", r.URL.Query().Get("prefix"))
+		assert.Equal(t, "keys", r.URL.Query().Get("filter"))
+		_, _ = w.Write([]byte(`{"version":1,"exported_at":"2025-01-15T10:00:00Z","keys":[
+			{"key":"app/db","value":"{\"port\":5432}","format":"json","updated_at":"2025-01-14T09:00:00Z"},
+			{"key":"app/blob","value":"/wA=","format":"text","encoding":"base64"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+
+	b, err := client.Export(t.Context(), ExportRequest{Prefix: "app/", SkipSecrets: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.Version)
+	require.Len(t, b.Keys, 2)
+	assert.Equal(t, "app/db", b.Keys[0].Key)
+	assert.Equal(t, 2025, b.Keys[0].UpdatedAt.Year())
+	value, err := b.Keys[0].Bytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":5432}`, string(value))
+	value, err = b.Keys[1].Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00}, value)
+
+	_, err = client.Export(t.Context(), ExportRequest{Prefix: "denied/"})
+	require.ErrorIs(t, err, ErrForbidden)
+
+	_, err = BundleKey{Key: "k", Value: "x", Encoding: "hex"}.Bytes()
+	require.EqualError(t, err, `unknown encoding "hex" of "k"`)
+}
+
+func TestClient_Import(t *testing.T) {
+	var got Bundle
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kv/_import", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "overwrite", r.URL.Query().Get("mode"))
+		assert.Equal(t, "app/", r.URL.Query().Get("prefix"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = w.Write([]byte(`{"created":1,"updated":0,"skipped":0,"deleted":2}`))
+	}))
+	defer server.Close()
+
+	b := Bundle{Version: 1, Keys: []BundleKey{{Key: "app/name", Value: "svc"}}}
+	client, err := New(server.URL, WithToken("admin"), WithRetry(0, 0))
+	require.NoError(t, err)
+	res, err := client.Import(t.Context(), ImportRequest{Bundle: b, Mode: ImportOverwrite, Prefix: "app/"})
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 1, Deleted: 2}, res)
+	assert.Equal(t, b, got)
+
+	reader, err := New(server.URL, WithToken("reader"), WithRetry(0, 0))
+	require.NoError(t, err)
+	_, err = reader.Import(t.Context(), ImportRequest{Bundle: b})
+	require.ErrorIs(t, err, ErrForbidden)
+}
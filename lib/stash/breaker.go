@@ -0,0 +1,132 @@
+package stash
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-pkgz/requester/middleware"
+)
+
+// CircuitState is the state of the circuit breaker set by WithCircuitBreaker.
+type CircuitState string
+
+// circuit breaker states
+const (
+	CircuitClosed   CircuitState = "closed"    // requests are sent
+	CircuitOpen     CircuitState = "open"      // requests fail with ErrCircuitOpen until the cooldown is over
+	CircuitHalfOpen CircuitState = "half-open" // one probe request is sent, its result closes or opens the circuit
+)
+
+// Health is the state of the server as seen by the client, see Client.Health.
+type Health struct {
+	State       CircuitState // always closed without WithCircuitBreaker
+	Failures    int          // consecutive failed requests, network errors and 5xx responses
+	OpenUntil   time.Time    // when an open circuit lets a probe through, zero unless open
+	RetryBudget int          // retries left in the current window of RetryBudget, -1 without a budget
+}
+
+// WithCircuitBreaker stops sending requests after the given number of consecutive failures, network errors
+// and 5xx responses, retries included. For the cooldown requests fail with ErrCircuitOpen without reaching
+// the server, then a single probe request decides whether to close the circuit or to wait another cooldown.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.breakerFailures, cfg.breakerCooldown = failures, cooldown
+	}
+}
+
+// circuitBreaker tracks consecutive failures of requests.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openUntil time.Time
+	probing   bool // the probe of a half-open circuit is in flight
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+// middleware returns the middleware failing requests with ErrCircuitOpen while the circuit is open.
+// It must be placed inside the retry middleware, so every attempt counts and retries stop on open circuit.
+func (b *circuitBreaker) middleware() middleware.RoundTripperHandler {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := b.allow(); err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if req.Context().Err() != nil {
+				b.release() // canceled by the caller, says nothing about the server
+				return resp, err
+			}
+			b.done(err == nil && resp.StatusCode < http.StatusInternalServerError)
+			return resp, err
+		})
+	}
+}
+
+// allow checks if a request can be sent, moving an open circuit to half-open after the cooldown.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.state == CircuitOpen && time.Now().Before(b.openUntil):
+		return fmt.Errorf("%w until %s", ErrCircuitOpen, b.openUntil.Format(time.RFC3339))
+	case b.state == CircuitOpen:
+		b.state = CircuitHalfOpen
+	case b.state == CircuitHalfOpen && b.probing:
+		return fmt.Errorf("%w, waiting for the probe request", ErrCircuitOpen)
+	}
+	b.probing = b.state == CircuitHalfOpen
+	return nil
+}
+
+// done records the result of an allowed request.
+func (b *circuitBreaker) done(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ok {
+		b.state, b.failures, b.openUntil = CircuitClosed, 0, time.Time{}
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state, b.openUntil = CircuitOpen, time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends an allowed request without a result, letting another probe through.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// health returns the state of the breaker, closed on nil breaker.
+func (b *circuitBreaker) health() Health {
+	if b == nil {
+		return Health{State: CircuitClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := Health{State: b.state, Failures: b.failures}
+	if b.state == CircuitOpen {
+		res.OpenUntil = b.openUntil
+	}
+	return res
+}
+
+// Health returns the state of the circuit breaker and the retry budget. It doesn't send a request, see
+// Ping for checking the server.
+func (c *Client) Health() Health {
+	res := c.breaker.health()
+	res.RetryBudget = c.budget.remaining()
+	return res
+}
@@ -0,0 +1,77 @@
+package stash
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(5, time.Millisecond), WithCircuitBreaker(3, 50*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, Health{State: CircuitClosed, RetryBudget: -1}, c.Health())
+
+	_, err = c.Get(context.Background(), "app/db")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), calls.Load(), "retries stop once the circuit opens")
+	h := c.Health()
+	assert.Equal(t, CircuitOpen, h.State)
+	assert.Equal(t, 3, h.Failures)
+	assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), h.OpenUntil, 50*time.Millisecond)
+
+	_, err = c.Get(context.Background(), "app/db")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), calls.Load(), "open circuit doesn't reach the server")
+
+	// failed probe opens the circuit for another cooldown
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.Get(context.Background(), "app/db")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(4), calls.Load())
+	assert.Equal(t, CircuitOpen, c.Health().State)
+
+	// successful probe closes it
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	val, err := c.Get(context.Background(), "app/db")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, Health{State: CircuitClosed, RetryBudget: -1}, c.Health())
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+	require.NoError(t, b.allow())
+	b.done(false)
+	require.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, b.allow(), "probe after the cooldown")
+	assert.Equal(t, CircuitHalfOpen, b.health().State)
+	require.ErrorIs(t, b.allow(), ErrCircuitOpen, "one probe at a time")
+	b.release()
+	require.NoError(t, b.allow(), "canceled probe lets another one through")
+	b.done(true)
+	assert.Equal(t, CircuitClosed, b.health().State)
+
+	var nilBreaker *circuitBreaker
+	assert.Equal(t, Health{State: CircuitClosed}, nilBreaker.health())
+}
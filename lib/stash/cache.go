@@ -0,0 +1,83 @@
+package stash
+
+import (
+	"sync"
+	"time"
+)
+
+// valueCache keeps values returned by the server with their validators. Entries younger than ttl are
+// used without a request, older ones are revalidated with a conditional request and kept while unchanged.
+// Values are kept as received, so zero-knowledge encrypted values stay encrypted in memory.
+type valueCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached response body with the validators of the response.
+type cacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	fetched      time.Time // when the server last confirmed the value
+}
+
+func newValueCache(ttl time.Duration) *valueCache {
+	return &valueCache{ttl: ttl, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+// fresh returns the body of the key if it was confirmed within ttl.
+func (vc *valueCache) fresh(key string) ([]byte, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	e, ok := vc.entries[key]
+	if !ok || vc.now().Sub(e.fetched) >= vc.ttl {
+		return nil, false
+	}
+	return e.body, true
+}
+
+// get returns the entry of the key, fresh or not.
+func (vc *valueCache) get(key string) (cacheEntry, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	e, ok := vc.entries[key]
+	return e, ok
+}
+
+// put stores a body with its validators, bodies without validators can't be revalidated and are dropped.
+func (vc *valueCache) put(key string, body []byte, etag, lastModified string) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if etag == "" && lastModified == "" {
+		delete(vc.entries, key)
+		return
+	}
+	vc.entries[key] = cacheEntry{body: body, etag: etag, lastModified: lastModified, fetched: vc.now()}
+}
+
+// touch marks the entry of the key confirmed by the server now, unless it was replaced or removed meanwhile.
+func (vc *valueCache) touch(key, etag string) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if e, ok := vc.entries[key]; ok && e.etag == etag {
+		e.fetched = vc.now()
+		vc.entries[key] = e
+	}
+}
+
+// remove drops the entry of the key, e.g. after it was written or deleted.
+func (vc *valueCache) remove(key string) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	delete(vc.entries, key)
+}
+
+// clear drops all entries.
+func (vc *valueCache) clear() {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	clear(vc.entries)
+}
@@ -0,0 +1,136 @@
+package stash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithCache(t *testing.T) {
+	var mu sync.Mutex
+	values := map[string]string{"app/name": "v1"}
+	var requests, downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/kv/")
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			values[key] = string(body)
+			return
+		case http.MethodDelete:
+			delete(values, key)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		requests++
+		value, ok := values[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := sha256.Sum256([]byte(value))
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		_, _ = w.Write([]byte(value))
+	}))
+	defer server.Close()
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests, downloads
+	}
+
+	client, err := New(server.URL, WithCache(time.Minute), WithRetry(0, 0))
+	require.NoError(t, err)
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	client.cache.now = func() time.Time { return now }
+
+	get := func() string {
+		value, err := client.Get(t.Context(), "app/name")
+		require.NoError(t, err)
+		return value
+	}
+
+	assert.Equal(t, "v1", get())
+	assert.Equal(t, "v1", get())
+	r, d := counts()
+	assert.Equal(t, 1, r, "fresh value is used without a request")
+	assert.Equal(t, 1, d)
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "v1", get())
+	assert.Equal(t, "v1", get())
+	r, d = counts()
+	assert.Equal(t, 2, r, "stale value is revalidated once")
+	assert.Equal(t, 1, d, "unchanged value is not downloaded again")
+
+	mu.Lock()
+	values["app/name"] = "v2"
+	mu.Unlock()
+	assert.Equal(t, "v1", get(), "changes by others are seen after ttl")
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "v2", get())
+	r, d = counts()
+	assert.Equal(t, 3, r)
+	assert.Equal(t, 2, d)
+
+	require.NoError(t, client.Set(t.Context(), "app/name", "v3"))
+	assert.Equal(t, "v3", get(), "own write drops the cached value")
+
+	require.NoError(t, client.Delete(t.Context(), "app/name"))
+	_, err = client.Get(t.Context(), "app/name")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, ok := client.cache.get("app/name")
+	assert.False(t, ok)
+
+	value, err := client.GetBytes(t.Context(), "app/other")
+	require.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, value)
+}
+
+func TestClient_WithCache_ZK(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			stored, _ = io.ReadAll(r.Body)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(stored)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithCache(time.Minute), WithZKKey("a-long-enough-passphrase"), WithRetry(0, 0))
+	require.NoError(t, err)
+	require.NoError(t, client.Set(t.Context(), "secret", "plain"))
+
+	for range 2 {
+		value, err := client.GetBytes(t.Context(), "secret")
+		require.NoError(t, err)
+		assert.Equal(t, "plain", string(value))
+		value[0] = 'X' // callers may modify the result
+	}
+	e, ok := client.cache.get("secret")
+	require.True(t, ok)
+	assert.True(t, IsZKEncrypted(e.body), "cached value stays encrypted")
+
+	client.Close()
+	_, ok = client.cache.get("secret")
+	assert.False(t, ok, "close clears the cache")
+}
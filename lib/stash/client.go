@@ -1,18 +1,22 @@
 package stash
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-pkgz/requester"
 	"github.com/go-pkgz/requester/middleware"
+	"golang.org/x/sync/singleflight"
 )
 
 // defaults for client configuration
@@ -22,27 +26,56 @@ const (
 	defaultRetryDelay = 100 * time.Millisecond
 )
 
+// consistency token headers, the token returned on writes is passed back on reads
+const (
+	versionHeader    = "X-Stash-Version"
+	minVersionHeader = "X-Stash-Min-Version"
+)
+
+// ndjsonContentType is the media type of key lists streamed one key per line.
+const ndjsonContentType = "application/x-ndjson"
+
 // Client is a Stash KV service client.
 type Client struct {
 	baseURL   string
+	endpoints *endpointResolver // dynamic endpoint discovery (nil = use baseURL)
 	requester *requester.Requester
-	zkCrypto  *ZKCrypto // for client-side ZK encryption (nil = disabled)
+	zkCrypto  *ZKCrypto          // for client-side ZK encryption (nil = disabled)
+	inflight  singleflight.Group // coalesces concurrent gets of the same key
+	metrics   MetricsReporter    // optional, nil = disabled
+	minRead   atomic.Value       // consistency token of the last write, sent with reads
+	rateLimit atomic.Value       // RateLimit of the last response reporting it
+	cache     *valueCache        // values of recent gets, nil = disabled
+	breaker   *circuitBreaker    // nil = disabled
+	budget    *retryBudget       // retries left to the client, nil = not limited
 }
 
 // clientConfig holds configuration options during client construction.
 type clientConfig struct {
 	token        string
+	tokenFunc    func(ctx context.Context) (string, error) // token per request, set by WithTokenFunc or WithTokenFile
+	signRequests bool                                      // sign requests with the token instead of sending it
 	timeout      time.Duration
 	retryCount   int
 	retryDelay   time.Duration
+	retryOpts    []RetryOption
 	httpClient   *http.Client
 	zkPassphrase string // for client-side ZK encryption
+	resolver     Resolver
+	resolveEvery time.Duration
+	metrics      MetricsReporter
+	version      string        // client version sent with requests, for values pinned to versions
+	cacheTTL     time.Duration // how long cached values are used without revalidation, 0 disables the cache
+
+	breakerFailures int           // consecutive failures opening the circuit, 0 disables the breaker
+	breakerCooldown time.Duration // how long an open circuit fails requests before a probe
 }
 
 // Option is a functional option for configuring the client.
 type Option func(*clientConfig)
 
-// WithToken sets the Bearer token for authentication.
+// WithToken sets the Bearer token for authentication. See WithTokenFile and WithTokenFunc for tokens
+// changing while the client is used.
 func WithToken(token string) Option {
 	return func(cfg *clientConfig) {
 		cfg.token = token
@@ -56,11 +89,13 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
-// WithRetry configures retry behavior.
-func WithRetry(count int, delay time.Duration) Option {
+// WithRetry configures retry behavior: count attempts of failing requests with delay before the first retry,
+// doubled for every next one. Options set the max delay, jitter, statuses to retry and a retry budget.
+func WithRetry(count int, delay time.Duration, opts ...RetryOption) Option {
 	return func(cfg *clientConfig) {
 		cfg.retryCount = count
 		cfg.retryDelay = delay
+		cfg.retryOpts = opts
 	}
 }
 
@@ -84,30 +119,71 @@ func WithZKKey(passphrase string) Option {
 	}
 }
 
+// WithMetrics reports per-operation counts, latencies, retries and cache hits to the given reporter.
+// See NewPrometheusMetrics for a ready-made Prometheus implementation.
+func WithMetrics(reporter MetricsReporter) Option {
+	return func(cfg *clientConfig) {
+		cfg.metrics = reporter
+	}
+}
+
+// WithCache keeps values returned by gets. Within ttl a cached value is returned without a request,
+// after it the value is revalidated with a conditional request, so an unchanged value is not downloaded again.
+// Writes and deletes by the client drop the key from the cache, changes by others are seen after ttl.
+func WithCache(ttl time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.cacheTTL = ttl
+	}
+}
+
+// WithResolver discovers server endpoints dynamically using the given resolver.
+// Resolved endpoints are cached and refreshed periodically (see WithResolveInterval).
+// When set, the base URL passed to New is optional and used only as a fallback
+// while no endpoints could be resolved.
+func WithResolver(r Resolver) Option {
+	return func(cfg *clientConfig) {
+		cfg.resolver = r
+	}
+}
+
+// WithClientVersion sets the version of the client, e.g. the config schema version the application supports.
+// The server then returns the newest revision of a value whose minimum version is not above it, so a value
+// migrated to a new format doesn't break deployments still running the old version.
+func WithClientVersion(version string) Option {
+	return func(cfg *clientConfig) {
+		cfg.version = version
+	}
+}
+
+// WithResolveInterval sets how often resolved endpoints are refreshed (default 30s).
+func WithResolveInterval(interval time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.resolveEvery = interval
+	}
+}
+
 // KeyInfo contains metadata about a stored key.
 type KeyInfo struct {
-	Key         string    `json:"key"`
-	Size        int       `json:"size"`
-	Format      string    `json:"format"`
-	Secret      bool      `json:"secret"`
-	ZKEncrypted bool      `json:"zk_encrypted"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Key         string     `json:"key"`
+	Size        int        `json:"size"`
+	Format      string     `json:"format"`
+	Secret      bool       `json:"secret"`
+	ZKEncrypted bool       `json:"zk_encrypted"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Owner       string     `json:"owner,omitempty"`      // creator identity, empty if not recorded
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // nil if the key doesn't expire
 }
 
 // New creates a new Stash client with the given base URL and options.
+// Base URL in the form srv://_stash._tcp.example.com (or srv+https://...) resolves
+// server endpoints from DNS SRV records and refreshes them periodically.
 func New(baseURL string, opts ...Option) (*Client, error) {
-	if baseURL == "" {
-		return nil, errors.New("base URL is required")
-	}
-
-	// normalize base URL
-	baseURL = strings.TrimSuffix(baseURL, "/")
-
 	cfg := &clientConfig{
-		timeout:    defaultTimeout,
-		retryCount: defaultRetryCount,
-		retryDelay: defaultRetryDelay,
+		timeout:      defaultTimeout,
+		retryCount:   defaultRetryCount,
+		retryDelay:   defaultRetryDelay,
+		resolveEvery: defaultResolveInterval,
 	}
 
 	// apply options
@@ -115,13 +191,66 @@ func New(baseURL string, opts ...Option) (*Client, error) {
 		opt(cfg)
 	}
 
+	if srv := parseSRVURL(baseURL); srv != nil {
+		if srv.Name == "" {
+			return nil, errors.New("SRV record name is required")
+		}
+		cfg.resolver, baseURL = srv, ""
+	}
+
+	if baseURL == "" && cfg.resolver == nil {
+		return nil, errors.New("base URL is required")
+	}
+	if cfg.token != "" && cfg.tokenFunc != nil {
+		return nil, errors.New("WithToken can't be combined with WithTokenFile or WithTokenFunc")
+	}
+	tokenFunc := cfg.tokenFunc
+	if cfg.token != "" {
+		tokenFunc = func(context.Context) (string, error) { return cfg.token, nil }
+	}
+	if cfg.signRequests && tokenFunc == nil {
+		return nil, errors.New("WithRequestSigning needs a token")
+	}
+
+	// normalize base URL
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var endpoints *endpointResolver
+	if cfg.resolver != nil {
+		endpoints = &endpointResolver{resolver: cfg.resolver, interval: cfg.resolveEvery, fallback: baseURL}
+	}
+
 	// build requester with middleware
 	var middlewares []middleware.RoundTripperHandler
+	if cfg.metrics != nil {
+		middlewares = append(middlewares, countRetries(cfg.metrics)) // innermost, sees every attempt
+	}
+	if cfg.signRequests {
+		middlewares = append(middlewares, signedRequests(tokenFunc)) // inside retries and failover, each attempt is signed anew
+	}
+	if endpoints != nil {
+		middlewares = append(middlewares, endpoints.failover) // requests sent to another endpoint count as retries
+	}
+	var breaker *circuitBreaker
+	if cfg.breakerFailures > 0 {
+		breaker = newCircuitBreaker(cfg.breakerFailures, cfg.breakerCooldown)
+		middlewares = append(middlewares, breaker.middleware()) // inside retries, an open circuit stops them
+	}
+	var budget *retryBudget
 	if cfg.retryCount > 0 {
-		middlewares = append(middlewares, middleware.Retry(cfg.retryCount, cfg.retryDelay))
+		retry := newRetryPolicy(cfg.retryCount, cfg.retryDelay, cfg.retryOpts...)
+		budget = retry.budget
+		middlewares = append(middlewares, retry.middleware())
 	}
-	if cfg.token != "" {
+	switch {
+	case cfg.signRequests:
+	case cfg.token != "":
 		middlewares = append(middlewares, middleware.Header("Authorization", "Bearer "+cfg.token))
+	case cfg.tokenFunc != nil:
+		middlewares = append(middlewares, bearerToken(cfg.tokenFunc))
+	}
+	if cfg.version != "" {
+		middlewares = append(middlewares, middleware.Header("X-Stash-Client-Version", cfg.version))
 	}
 
 	httpClient := cfg.httpClient
@@ -139,10 +268,20 @@ func New(baseURL string, opts ...Option) (*Client, error) {
 		}
 	}
 
+	var cache *valueCache
+	if cfg.cacheTTL > 0 {
+		cache = newValueCache(cfg.cacheTTL)
+	}
+
 	return &Client{
 		baseURL:   baseURL,
+		endpoints: endpoints,
 		requester: requester.New(*httpClient, middlewares...),
 		zkCrypto:  zk,
+		metrics:   cfg.metrics,
+		cache:     cache,
+		breaker:   breaker,
+		budget:    budget,
 	}, nil
 }
 
@@ -165,45 +304,103 @@ func (c *Client) GetOrDefault(ctx context.Context, key, defaultValue string) (st
 }
 
 // GetBytes retrieves a value by key as raw bytes.
+// Concurrent calls for the same key share a single HTTP request.
 func (c *Client) GetBytes(ctx context.Context, key string) ([]byte, error) {
 	if key == "" {
 		return nil, errors.New("key is required")
 	}
+	if c.cache != nil {
+		if body, ok := c.cache.fresh(key); ok {
+			if c.metrics != nil {
+				c.metrics.ObserveCache(OpGet, true)
+			}
+			return c.decode(body)
+		}
+	}
+
+	// the shared request must not be canceled by whichever caller started it,
+	// so it runs detached and each caller waits with its own context
+	var leader bool // set only by the caller that performs the request
+	ch := c.inflight.DoChan(key, func() (any, error) {
+		leader = true
+		return c.getBytes(context.WithoutCancel(ctx), key)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request failed: %w", ctx.Err())
+	case res := <-ch:
+		if c.metrics != nil {
+			c.metrics.ObserveCache(OpGet, !leader)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return c.decode(res.Val.([]byte))
+	}
+}
+
+// decode returns a copy of the value received from the server, decrypted if it's ZK-encrypted and
+// the client has the key. Received values are shared by callers and the cache, callers may modify the result.
+func (c *Client) decode(body []byte) ([]byte, error) {
+	if c.zkCrypto != nil && IsZKEncrypted(body) {
+		decrypted, err := c.zkCrypto.Decrypt(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt ZK value: %w", err)
+		}
+		return decrypted, nil
+	}
+	return bytes.Clone(body), nil
+}
 
-	u, err := url.JoinPath(c.baseURL, "kv", key)
+// getBytes performs the actual GET request for a key and returns the value as received.
+// With the cache enabled, a cached value is revalidated and kept if the server responds with 304.
+func (c *Client) getBytes(ctx context.Context, key string) ([]byte, error) {
+	u, err := c.keyURL(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build URL: %w", err)
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	if token, ok := c.minRead.Load().(string); ok {
+		req.Header.Set(minVersionHeader, token)
+	}
+	var cached cacheEntry
+	var revalidate bool // set if the request is conditional on the cached value
+	if c.cache != nil {
+		if cached, revalidate = c.cache.get(key); revalidate {
+			if cached.etag != "" {
+				req.Header.Set("If-None-Match", cached.etag)
+			}
+			if cached.lastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.lastModified)
+			}
+		}
+	}
 
-	resp, err := c.requester.Do(req)
+	resp, err := c.do(req, OpGet)
+	var respErr *ResponseError
+	if revalidate && errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotModified {
+		c.cache.touch(key, cached.etag)
+		return cached.body, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		if c.cache != nil && errors.Is(err, ErrNotFound) {
+			c.cache.remove(key)
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if errResp := c.checkResponse(resp); errResp != nil {
-		return nil, errResp
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-
-	// decrypt if ZK-encrypted and we have the key
-	if c.zkCrypto != nil && IsZKEncrypted(body) {
-		decrypted, err := c.zkCrypto.Decrypt(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt ZK value: %w", err)
-		}
-		return decrypted, nil
+	if c.cache != nil {
+		c.cache.put(key, body, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
 	}
-
 	return body, nil
 }
 
@@ -237,13 +434,32 @@ func (c *Client) Set(ctx context.Context, key, value string) error {
 
 // SetWithFormat stores a value with explicit format.
 func (c *Client) SetWithFormat(ctx context.Context, key, value string, format Format) error {
+	return c.set(ctx, key, value, format, 0)
+}
+
+// SetWithTTL stores a value with text format, deleted by the server after the TTL. Setting the key
+// again without TTL, e.g. with Set, keeps it.
+func (c *Client) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	return c.set(ctx, key, value, FormatText, ttl)
+}
+
+// set stores a value, the key expires after ttl unless it's zero.
+func (c *Client) set(ctx context.Context, key, value string, format Format, ttl time.Duration) error {
 	if key == "" {
 		return errors.New("key is required")
 	}
+	// only built-in and registered formats are sent, not a zero Format
+	format, err := LookupFormat(format.String())
+	if err != nil {
+		return err
+	}
 
-	u, err := url.JoinPath(c.baseURL, "kv", key)
+	u, err := c.keyURL(ctx, key)
 	if err != nil {
-		return fmt.Errorf("failed to build URL: %w", err)
+		return err
 	}
 
 	// encrypt if ZK key is configured
@@ -262,14 +478,17 @@ func (c *Client) SetWithFormat(ctx context.Context, key, value string, format Fo
 	}
 
 	req.Header.Set("X-Stash-Format", format.String())
+	if ttl > 0 {
+		req.Header.Set("X-Stash-TTL", ttl.String())
+	}
 
-	resp, err := c.requester.Do(req)
+	resp, err := c.do(req, OpSet)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	return c.checkResponse(resp)
+	_ = resp.Body.Close()
+	c.written(key, resp)
+	return nil
 }
 
 // Delete removes a key.
@@ -278,9 +497,9 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 		return errors.New("key is required")
 	}
 
-	u, err := url.JoinPath(c.baseURL, "kv", key)
+	u, err := c.keyURL(ctx, key)
 	if err != nil {
-		return fmt.Errorf("failed to build URL: %w", err)
+		return err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, http.NoBody)
@@ -288,19 +507,23 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.requester.Do(req)
+	resp, err := c.do(req, OpDelete)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	return c.checkResponse(resp)
+	_ = resp.Body.Close()
+	c.written(key, resp)
+	return nil
 }
 
 // List returns all keys, optionally filtered by prefix.
 // Pass empty string to list all keys.
 func (c *Client) List(ctx context.Context, prefix string) ([]KeyInfo, error) {
-	u := c.baseURL + "/kv/"
+	base, err := c.base(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := base + "/kv/"
 	if prefix != "" {
 		u += "?prefix=" + url.QueryEscape(prefix)
 	}
@@ -310,15 +533,11 @@ func (c *Client) List(ctx context.Context, prefix string) ([]KeyInfo, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.requester.Do(req)
+	resp, err := c.do(req, OpList)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if err := c.checkResponse(resp); err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var keys []KeyInfo
 	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
@@ -328,22 +547,86 @@ func (c *Client) List(ctx context.Context, prefix string) ([]KeyInfo, error) {
 	return keys, nil
 }
 
-// Ping checks server connectivity.
-func (c *Client) Ping(ctx context.Context) error {
-	u := c.baseURL + "/ping"
+// ListStream calls fn for each key, optionally filtered by prefix, as the server streams them, without
+// loading the whole list in memory. The server reads the next keys only after earlier ones are consumed,
+// so a slow fn slows the stream down rather than the response piling up. An error returned by fn stops
+// the stream and is returned as is.
+func (c *Client) ListStream(ctx context.Context, prefix string, fn func(KeyInfo) error) error {
+	base, err := c.base(ctx)
+	if err != nil {
+		return err
+	}
+	u := base + "/kv/"
+	if prefix != "" {
+		u += "?prefix=" + url.QueryEscape(prefix)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Accept", ndjsonContentType)
 
-	resp, err := c.requester.Do(req)
+	resp, err := c.do(req, OpList)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	return c.checkResponse(resp)
+	// servers without streaming ignore the accept header and send a json array
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != ndjsonContentType {
+		var keys []KeyInfo
+		if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		for _, k := range keys {
+			if err := fn(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var line struct {
+			KeyInfo
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("list stream failed: %s", line.Error)
+		}
+		if err := fn(line.KeyInfo); err != nil {
+			return err
+		}
+	}
+}
+
+// Ping checks server connectivity.
+func (c *Client) Ping(ctx context.Context) error {
+	base, err := c.base(ctx)
+	if err != nil {
+		return err
+	}
+	u := base + "/ping"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req, OpPing)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
 }
 
 // Close clears sensitive data from memory.
@@ -352,6 +635,71 @@ func (c *Client) Close() {
 	if c.zkCrypto != nil {
 		c.zkCrypto.Clear()
 	}
+	if c.cache != nil {
+		c.cache.clear()
+	}
+}
+
+// written records the consistency token of a completed write, so following reads don't get values older
+// than the write from another server instance. Gets of the key started before the write are not shared
+// with later callers, and the cached value of the key is dropped.
+func (c *Client) written(key string, resp *http.Response) {
+	c.inflight.Forget(key)
+	if c.cache != nil {
+		c.cache.remove(key)
+	}
+	if token := resp.Header.Get(versionHeader); token != "" {
+		c.minRead.Store(token)
+	}
+}
+
+// base returns the server base URL, resolving it dynamically if a resolver is configured.
+func (c *Client) base(ctx context.Context) (string, error) {
+	if c.endpoints == nil {
+		return c.baseURL, nil
+	}
+	return c.endpoints.endpoint(ctx)
+}
+
+// keyURL builds the /kv/{key} URL for the given key.
+func (c *Client) keyURL(ctx context.Context, key string) (string, error) {
+	base, err := c.base(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.JoinPath(base, "kv", key)
+	if err != nil {
+		return "", fmt.Errorf("failed to build URL: %w", err)
+	}
+	return u, nil
+}
+
+// do executes the request and converts error status codes to errors.
+// On error the response body is already closed. Reports metrics for the operation if enabled.
+func (c *Client) do(req *http.Request, op string) (*http.Response, error) {
+	if c.metrics == nil {
+		return c.doRequest(req)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), attemptsCtxKey{}, &requestAttempts{op: op}))
+	st := time.Now()
+	resp, err := c.doRequest(req)
+	c.metrics.ObserveRequest(op, time.Since(st), err)
+	return resp, err
+}
+
+func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
+	resp, err := c.requester.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if rl, ok := parseRateLimit(resp.Header); ok {
+		c.rateLimit.Store(rl)
+	}
+	if err := c.checkResponse(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
 }
 
 // checkResponse handles HTTP response status codes and returns appropriate errors.
@@ -365,7 +713,12 @@ func (c *Client) checkResponse(resp *http.Response) error {
 		return ErrUnauthorized
 	case http.StatusForbidden:
 		return ErrForbidden
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusLocked:
+		return ErrFrozen
 	default:
-		return &ResponseError{StatusCode: resp.StatusCode}
+		rl, _ := parseRateLimit(resp.Header)
+		return &ResponseError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header), RateLimit: rl}
 	}
 }
@@ -3,9 +3,12 @@ package stash
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -65,6 +68,52 @@ func TestClient_Get(t *testing.T) {
 		assert.Equal(t, `{"debug": true}`, val)
 	})
 
+	t.Run("sends client version", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "1.4", r.Header.Get("X-Stash-Client-Version"))
+			_, _ = w.Write([]byte("v1"))
+		}))
+		defer srv.Close()
+
+		c, err := New(srv.URL, WithRetry(0, 0), WithClientVersion("1.4"))
+		require.NoError(t, err)
+
+		val, err := c.Get(context.Background(), "app/config")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", val)
+	})
+
+	t.Run("reads own writes", func(t *testing.T) {
+		var minVersions []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				minVersions = append(minVersions, r.Header.Get("X-Stash-Min-Version"))
+				_, _ = w.Write([]byte("v1"))
+			case http.MethodPut:
+				w.Header().Set("X-Stash-Version", "100")
+			case http.MethodDelete:
+				w.Header().Set("X-Stash-Version", "200")
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		defer srv.Close()
+
+		c, err := New(srv.URL, WithRetry(0, 0))
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		_, err = c.Get(ctx, "app/config")
+		require.NoError(t, err)
+		require.NoError(t, c.Set(ctx, "app/config", "v1"))
+		_, err = c.Get(ctx, "app/config")
+		require.NoError(t, err)
+		require.NoError(t, c.Delete(ctx, "other"))
+		_, err = c.Get(ctx, "app/config")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"", "100", "200"}, minVersions)
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNotFound)
@@ -198,6 +247,86 @@ func TestClient_GetBytes(t *testing.T) {
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "key is required")
 	})
+
+	t.Run("concurrent gets share one request", func(t *testing.T) {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			_, _ = w.Write([]byte("value-" + r.URL.Path))
+		}))
+		defer srv.Close()
+
+		c, err := New(srv.URL, WithRetry(0, 0))
+		require.NoError(t, err)
+
+		const readers = 10
+		results := make(chan []byte, readers)
+		var wg sync.WaitGroup
+		for range readers {
+			wg.Go(func() {
+				val, err := c.GetBytes(context.Background(), "app/key")
+				assert.NoError(t, err)
+				results <- val
+			})
+		}
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(50 * time.Millisecond) // let all readers join the in-flight request
+		close(release)
+		wg.Wait()
+		close(results)
+
+		assert.Equal(t, int32(1), calls.Load())
+		var first []byte
+		for val := range results {
+			assert.Equal(t, "value-/kv/app/key", string(val))
+			if first == nil {
+				first = val
+				first[0] = 'X' // results are independent copies
+				continue
+			}
+			assert.Equal(t, byte('v'), val[0])
+		}
+
+		// different keys are not coalesced, a later call makes a new request
+		_, err = c.GetBytes(context.Background(), "app/other")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("canceled caller does not fail others", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			_, _ = w.Write([]byte("value"))
+		}))
+		defer srv.Close()
+
+		c, err := New(srv.URL, WithRetry(0, 0))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := c.GetBytes(ctx, "app/key")
+			firstErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		second := make(chan string, 1)
+		go func() {
+			val, err := c.Get(context.Background(), "app/key")
+			assert.NoError(t, err)
+			second <- val
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-firstErr, context.Canceled)
+		close(release)
+		assert.Equal(t, "value", <-second)
+	})
 }
 
 func TestClient_Set(t *testing.T) {
@@ -245,6 +374,19 @@ func TestClient_Set(t *testing.T) {
 		require.ErrorAs(t, err, &respErr)
 		assert.Equal(t, http.StatusInternalServerError, respErr.StatusCode)
 	})
+
+	t.Run("frozen", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusLocked)
+		}))
+		defer srv.Close()
+
+		c, err := New(srv.URL, WithRetry(0, 0))
+		require.NoError(t, err)
+
+		err = c.Set(context.Background(), "key", "value")
+		require.ErrorIs(t, err, ErrFrozen)
+	})
 }
 
 func TestClient_SetWithFormat(t *testing.T) {
@@ -272,6 +414,27 @@ func TestClient_SetWithFormat(t *testing.T) {
 	})
 }
 
+func TestClient_SetWithTTL(t *testing.T) {
+	var gotTTL, gotFormat string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		gotTTL, gotFormat = r.Header.Get("X-Stash-TTL"), r.Header.Get("X-Stash-Format")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetWithTTL(context.Background(), "flags/override", "on", 90*time.Minute))
+	assert.Equal(t, "1h30m0s", gotTTL)
+	assert.Equal(t, "text", gotFormat)
+
+	require.NoError(t, c.Set(context.Background(), "flags/override", "on"))
+	assert.Empty(t, gotTTL, "no ttl with plain set")
+
+	require.EqualError(t, c.SetWithTTL(context.Background(), "flags/override", "on", 0), "ttl must be positive")
+}
+
 func TestClient_Delete(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -497,6 +660,74 @@ func TestClient_List_InvalidJSON(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to decode response")
 }
 
+func TestClient_ListStream(t *testing.T) {
+	ndjson := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Accept"))
+			assert.Equal(t, "app/", r.URL.Query().Get("prefix"))
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	collect := func(t *testing.T, url string) ([]string, error) {
+		t.Helper()
+		c, err := New(url, WithRetry(0, 0))
+		require.NoError(t, err)
+		var got []string
+		err = c.ListStream(context.Background(), "app/", func(k KeyInfo) error {
+			got = append(got, k.Key)
+			return nil
+		})
+		return got, err
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		srv := ndjson(`{"key":"app/a","size":1,"format":"text"}` + "\n" + `{"key":"app/b","size":2,"format":"json"}` + "\n")
+		defer srv.Close()
+		got, err := collect(t, srv.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app/a", "app/b"}, got)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		srv := ndjson(`{"key":"app/a"}` + "\n" + `{"error":"failed to list keys"}` + "\n")
+		defer srv.Close()
+		got, err := collect(t, srv.URL)
+		require.EqualError(t, err, "list stream failed: failed to list keys")
+		assert.Equal(t, []string{"app/a"}, got)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		srv := ndjson(`{"key":"app/a"}` + "\n" + `{"key":"ap`)
+		defer srv.Close()
+		_, err := collect(t, srv.URL)
+		require.ErrorContains(t, err, "failed to decode response")
+	})
+
+	t.Run("json array from older server", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"key":"app/a"},{"key":"app/b"}]`))
+		}))
+		defer srv.Close()
+		got, err := collect(t, srv.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app/a", "app/b"}, got)
+	})
+
+	t.Run("callback error stops", func(t *testing.T) {
+		srv := ndjson(`{"key":"app/a"}` + "\n" + `{"key":"app/b"}` + "\n")
+		defer srv.Close()
+		c, err := New(srv.URL, WithRetry(0, 0))
+		require.NoError(t, err)
+		stop := errors.New("stop")
+		calls := 0
+		err = c.ListStream(context.Background(), "app/", func(KeyInfo) error { calls++; return stop })
+		require.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+	})
+}
+
 func TestClient_Info_ListError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
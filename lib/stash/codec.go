@@ -0,0 +1,189 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec encodes and decodes values stored in a particular format.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// codecRegistry holds codecs and custom formats registered via RegisterFormat.
+var codecRegistry = struct {
+	mu      sync.RWMutex
+	codecs  map[string]Codec  // format name -> codec
+	formats map[string]Format // custom (non built-in) formats by name
+}{
+	codecs: map[string]Codec{
+		FormatJSON.String(): jsonCodec{},
+		FormatYAML.String(): yamlCodec{},
+		FormatTOML.String(): tomlCodec{},
+	},
+	formats: map[string]Format{},
+}
+
+// formatName is the pattern of format names: a letter, then up to 31 letters, digits, dots, dashes,
+// underscores or pluses, in lower case, e.g. "cue" or "vnd.acme+conf".
+var formatName = regexp.MustCompile(`^[a-z][a-z0-9._+-]{0,31}$`)
+
+// IsFormatName returns true if name is a valid format name. The server stores values of formats it doesn't
+// know under such names without validating them, so custom formats made by RegisterFormat round-trip.
+func IsFormatName(name string) bool {
+	return formatName.MatchString(name)
+}
+
+// RegisterFormat registers a codec for the named format and returns the format value
+// to use with SetWithFormat, SetObject and GetObject.
+// Built-in format names (json, yaml, ...) replace the default codec for that format.
+// Other names create a custom format; the server stores custom formats under their name
+// without validation, the codec is applied on the client side only.
+// Names are case-insensitive and must match IsFormatName once lower-cased. Safe for concurrent use.
+func RegisterFormat(name string, codec Codec) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Format{}, errors.New("format name is required")
+	}
+	if !IsFormatName(name) {
+		return Format{}, fmt.Errorf("invalid format name %q", name)
+	}
+	if codec == nil {
+		return Format{}, errors.New("codec is required")
+	}
+
+	codecRegistry.mu.Lock()
+	defer codecRegistry.mu.Unlock()
+
+	f, err := ParseFormat(name)
+	if err != nil {
+		// not a built-in format, reuse existing custom format or create a new one
+		var ok bool
+		if f, ok = codecRegistry.formats[name]; !ok {
+			f = Format{name: name, value: len(FormatValues) + len(codecRegistry.formats)}
+			codecRegistry.formats[name] = f
+		}
+	}
+	codecRegistry.codecs[name] = codec
+	return f, nil
+}
+
+// LookupFormat returns the format for a built-in or registered custom format name.
+func LookupFormat(name string) (Format, error) {
+	if f, err := ParseFormat(name); err == nil {
+		return f, nil
+	}
+	codecRegistry.mu.RLock()
+	defer codecRegistry.mu.RUnlock()
+	if f, ok := codecRegistry.formats[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return Format{}, fmt.Errorf("invalid format: %s", name)
+}
+
+// codecFor returns the codec registered for the format, looked up by name with LookupFormat.
+func codecFor(f Format) (Codec, error) {
+	f, err := LookupFormat(f.String())
+	if err != nil {
+		return nil, err
+	}
+	codecRegistry.mu.RLock()
+	defer codecRegistry.mu.RUnlock()
+	if c, ok := codecRegistry.codecs[f.String()]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("no codec registered for format %q", f.String())
+}
+
+// SetObject marshals v with the codec registered for format and stores the result.
+func (c *Client) SetObject(ctx context.Context, key string, v any, format Format) error {
+	codec, err := codecFor(format)
+	if err != nil {
+		return err
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value as %s: %w", format, err)
+	}
+	return c.SetWithFormat(ctx, key, string(data), format)
+}
+
+// GetObject retrieves a value and unmarshals it into target with the codec registered for format.
+func (c *Client) GetObject(ctx context.Context, key string, format Format, target any) error {
+	codec, err := codecFor(format)
+	if err != nil {
+		return err
+	}
+	data, err := c.GetBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := codec.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal %s value of key %q: %w", format, key, err)
+	}
+	return nil
+}
+
+// jsonCodec is the default codec for FormatJSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("json marshal: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("json unmarshal: %w", err)
+	}
+	return nil
+}
+
+// yamlCodec is the default codec for FormatYAML.
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v any) ([]byte, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("yaml marshal: %w", err)
+	}
+	return data, nil
+}
+
+func (yamlCodec) Unmarshal(data []byte, v any) error {
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("yaml unmarshal: %w", err)
+	}
+	return nil
+}
+
+// tomlCodec is the default codec for FormatTOML.
+type tomlCodec struct{}
+
+func (tomlCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("toml marshal: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (tomlCodec) Unmarshal(data []byte, v any) error {
+	if err := toml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("toml unmarshal: %w", err)
+	}
+	return nil
+}
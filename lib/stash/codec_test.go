@@ -0,0 +1,158 @@
+package stash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperCodec is a test codec storing strings in upper case.
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("string expected")
+	}
+	return []byte(strings.ToUpper(s)), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*string)
+	if !ok {
+		return errors.New("*string expected")
+	}
+	*p = strings.ToLower(string(data))
+	return nil
+}
+
+func TestRegisterFormat(t *testing.T) {
+	t.Run("custom format", func(t *testing.T) {
+		f, err := RegisterFormat("Upper-Test", upperCodec{})
+		require.NoError(t, err)
+		assert.Equal(t, "upper-test", f.String())
+		assert.GreaterOrEqual(t, f.Index(), len(FormatValues))
+
+		again, err := RegisterFormat("upper-test", upperCodec{})
+		require.NoError(t, err)
+		assert.Equal(t, f, again, "re-registering returns the same format")
+
+		looked, err := LookupFormat("UPPER-TEST")
+		require.NoError(t, err)
+		assert.Equal(t, f, looked)
+		assert.NotContains(t, FormatNames, "upper-test", "built-in format list is not modified")
+	})
+
+	t.Run("built-in format", func(t *testing.T) {
+		f, err := RegisterFormat("json", jsonCodec{})
+		require.NoError(t, err)
+		assert.Equal(t, FormatJSON, f)
+	})
+
+	t.Run("invalid registration", func(t *testing.T) {
+		_, err := RegisterFormat(" ", upperCodec{})
+		require.Error(t, err)
+		_, err = RegisterFormat("nocodec", nil)
+		require.Error(t, err)
+		_, err = RegisterFormat("bad name", upperCodec{})
+		require.Error(t, err)
+		assert.False(t, IsFormatName("bad name"))
+		assert.True(t, IsFormatName("vnd.acme+conf"))
+	})
+
+	t.Run("lookup unknown", func(t *testing.T) {
+		_, err := LookupFormat("unknown-format")
+		require.Error(t, err)
+	})
+}
+
+func TestClient_SetObjectGetObject(t *testing.T) {
+	var stored []byte
+	var storedFormat string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			stored, _ = io.ReadAll(r.Body)
+			storedFormat = r.Header.Get("X-Stash-Format")
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			_, _ = w.Write(stored)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("json", func(t *testing.T) {
+		type cfg struct {
+			Debug bool `json:"debug"`
+			Port  int  `json:"port"`
+		}
+		require.NoError(t, c.SetObject(ctx, "app/config", cfg{Debug: true, Port: 8080}, FormatJSON))
+		assert.JSONEq(t, `{"debug":true,"port":8080}`, string(stored))
+		assert.Equal(t, "json", storedFormat)
+
+		var got cfg
+		require.NoError(t, c.GetObject(ctx, "app/config", FormatJSON, &got))
+		assert.Equal(t, cfg{Debug: true, Port: 8080}, got)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		require.NoError(t, c.SetObject(ctx, "app/config", map[string]int{"port": 9090}, FormatYAML))
+		assert.Equal(t, "port: 9090\n", string(stored))
+
+		var got map[string]int
+		require.NoError(t, c.GetObject(ctx, "app/config", FormatYAML, &got))
+		assert.Equal(t, 9090, got["port"])
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		require.NoError(t, c.SetObject(ctx, "app/config", map[string]string{"name": "stash"}, FormatTOML))
+		var got map[string]string
+		require.NoError(t, c.GetObject(ctx, "app/config", FormatTOML, &got))
+		assert.Equal(t, "stash", got["name"])
+	})
+
+	t.Run("custom codec", func(t *testing.T) {
+		f, err := RegisterFormat("upper-client", upperCodec{})
+		require.NoError(t, err)
+		require.NoError(t, c.SetObject(ctx, "app/name", "hello", f))
+		assert.Equal(t, "HELLO", string(stored))
+		assert.Equal(t, "upper-client", storedFormat)
+
+		var got string
+		require.NoError(t, c.GetObject(ctx, "app/name", f, &got))
+		assert.Equal(t, "hello", got)
+	})
+
+	t.Run("no codec for format", func(t *testing.T) {
+		err := c.SetObject(ctx, "app/config", "x", FormatINI)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no codec registered")
+	})
+
+	t.Run("zero format", func(t *testing.T) {
+		err := c.SetWithFormat(ctx, "app/config", "x", Format{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid format")
+	})
+
+	t.Run("unmarshal error", func(t *testing.T) {
+		stored = []byte("not json")
+		var got map[string]any
+		err := c.GetObject(ctx, "app/config", FormatJSON, &got)
+		require.Error(t, err)
+		var syntaxErr *json.SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr)
+	})
+}
@@ -0,0 +1,131 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// environment variables read by NewFromEnv, the same the stash CLI and terraform provider use where they overlap
+const (
+	envURL        = "STASH_URL"
+	envToken      = "STASH_TOKEN"
+	envTokenFile  = "STASH_TOKEN_FILE"
+	envZKKey      = "STASH_ZK_KEY"
+	envTimeout    = "STASH_TIMEOUT"
+	envRetryCount = "STASH_RETRY_COUNT"
+	envRetryDelay = "STASH_RETRY_DELAY"
+	envSign       = "STASH_SIGN_REQUESTS"
+)
+
+// defaultClient is the client returned by Default, made on first use.
+var defaultClient struct {
+	mu     sync.Mutex
+	client *Client
+	err    error
+	pid    int // process the client was made in, 0 if not made yet
+}
+
+// getpid returns the current process id, replaced in tests.
+var getpid = os.Getpid
+
+// Default returns a client configured from environment variables, see NewFromEnv. The client is made on
+// the first call and shared by all goroutines after it, so small tools don't have to pass one around.
+// A configuration error is returned on every call, the environment is not read again.
+//
+// A process forked without exec gets its own client instead of sharing connections with the parent.
+func Default() (*Client, error) {
+	defaultClient.mu.Lock()
+	defer defaultClient.mu.Unlock()
+	if pid := getpid(); defaultClient.pid != pid {
+		defaultClient.client, defaultClient.err = NewFromEnv()
+		defaultClient.pid = pid
+	}
+	return defaultClient.client, defaultClient.err
+}
+
+// SetDefault replaces the client returned by Default, e.g. with one made by New in tests.
+// A nil client makes the next Default call read the environment again.
+func SetDefault(c *Client) {
+	defaultClient.mu.Lock()
+	defer defaultClient.mu.Unlock()
+	defaultClient.client, defaultClient.err, defaultClient.pid = c, nil, 0
+	if c != nil {
+		defaultClient.pid = getpid()
+	}
+}
+
+// NewFromEnv creates a client configured from environment variables:
+//
+//	STASH_URL            server URL, required, srv:// URLs resolve endpoints from DNS
+//	STASH_TOKEN          API token
+//	STASH_TOKEN_FILE     file with the API token, read again when it changes, see WithTokenFile
+//	STASH_ZK_KEY         passphrase of zero-knowledge encrypted values
+//	STASH_TIMEOUT        request timeout, e.g. 10s
+//	STASH_RETRY_COUNT    attempts of failing requests, as the count of WithRetry, 0 disables retries
+//	STASH_RETRY_DELAY    delay before the first retry, e.g. 200ms
+//	STASH_SIGN_REQUESTS  true to sign requests with the token instead of sending it, see WithRequestSigning
+//
+// Unset variables keep the defaults of New. Options override the environment.
+func NewFromEnv(opts ...Option) (*Client, error) {
+	baseURL := os.Getenv(envURL)
+	if baseURL == "" {
+		return nil, errors.New(envURL + " is not set")
+	}
+
+	var envOpts []Option
+	token, tokenFile := os.Getenv(envToken), os.Getenv(envTokenFile)
+	switch {
+	case token != "" && tokenFile != "":
+		return nil, errors.New(envToken + " and " + envTokenFile + " can't be set both")
+	case token != "":
+		envOpts = append(envOpts, WithToken(token))
+	case tokenFile != "":
+		envOpts = append(envOpts, WithTokenFile(tokenFile))
+	}
+	if passphrase := os.Getenv(envZKKey); passphrase != "" {
+		envOpts = append(envOpts, WithZKKey(passphrase))
+	}
+	if v := os.Getenv(envTimeout); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a positive duration", envTimeout, v)
+		}
+		envOpts = append(envOpts, WithTimeout(timeout))
+	}
+
+	retryCount, retryDelay := defaultRetryCount, defaultRetryDelay
+	if v := os.Getenv(envRetryCount); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a non-negative number", envRetryCount, v)
+		}
+		retryCount = n
+	}
+	if v := os.Getenv(envRetryDelay); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a non-negative duration", envRetryDelay, v)
+		}
+		retryDelay = d
+	}
+	envOpts = append(envOpts, WithRetry(retryCount, retryDelay))
+	if v := os.Getenv(envSign); v != "" {
+		sign, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, expected true or false", envSign, v)
+		}
+		if sign {
+			envOpts = append(envOpts, WithRequestSigning())
+		}
+	}
+
+	client, err := New(baseURL, append(envOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client from environment: %w", err)
+	}
+	return client, nil
+}
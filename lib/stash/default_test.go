@@ -0,0 +1,144 @@
+package stash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/kv/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "Bearer env-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("value"))
+	}))
+	defer server.Close()
+
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("STASH_URL", server.URL)
+		t.Setenv("STASH_TOKEN", "env-token")
+		t.Setenv("STASH_TIMEOUT", "5s")
+		t.Setenv("STASH_RETRY_COUNT", "2")
+		t.Setenv("STASH_RETRY_DELAY", "1ms")
+		client, err := NewFromEnv()
+		require.NoError(t, err)
+
+		value, err := client.Get(t.Context(), "app/name")
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+
+		attempts.Store(0)
+		_, err = client.Get(t.Context(), "broken")
+		require.Error(t, err)
+		assert.Equal(t, int32(2), attempts.Load(), "retry count is the number of attempts")
+	})
+
+	t.Run("token file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("env-token\n"), 0o600))
+		t.Setenv("STASH_URL", server.URL)
+		t.Setenv("STASH_TOKEN", "")
+		t.Setenv("STASH_TOKEN_FILE", path)
+		client, err := NewFromEnv()
+		require.NoError(t, err)
+		value, err := client.Get(t.Context(), "app/name")
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+	})
+
+	t.Run("options override environment", func(t *testing.T) {
+		t.Setenv("STASH_URL", server.URL)
+		t.Setenv("STASH_TOKEN", "env-token")
+		t.Setenv("STASH_RETRY_COUNT", "5")
+		client, err := NewFromEnv(WithRetry(0, 0))
+		require.NoError(t, err)
+		attempts.Store(0)
+		_, err = client.Get(t.Context(), "broken")
+		require.Error(t, err)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	tests := []struct {
+		name, env, value, err string
+	}{
+		{name: "no url", env: "STASH_URL", value: "", err: "STASH_URL is not set"},
+		{name: "bad timeout", env: "STASH_TIMEOUT", value: "soon", err: `invalid STASH_TIMEOUT "soon"`},
+		{name: "zero timeout", env: "STASH_TIMEOUT", value: "0s", err: `invalid STASH_TIMEOUT "0s"`},
+		{name: "bad retry count", env: "STASH_RETRY_COUNT", value: "-1", err: `invalid STASH_RETRY_COUNT "-1"`},
+		{name: "bad retry delay", env: "STASH_RETRY_DELAY", value: "1", err: `invalid STASH_RETRY_DELAY "1"`},
+		{name: "bad sign requests", env: "STASH_SIGN_REQUESTS", value: "maybe", err: `invalid STASH_SIGN_REQUESTS "maybe"`},
+		{name: "token and token file", env: "STASH_TOKEN_FILE", value: "/run/token", err: "can't be set both"},
+		{name: "short zk key", env: "STASH_ZK_KEY", value: "short", err: "failed to create client from environment"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("STASH_URL", server.URL)
+			t.Setenv("STASH_TOKEN", "env-token")
+			t.Setenv(tc.env, tc.value)
+			_, err := NewFromEnv()
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	pid := 100
+	origPid := getpid
+	getpid = func() int { return pid }
+	t.Cleanup(func() { getpid = origPid; SetDefault(nil) })
+	SetDefault(nil)
+
+	t.Setenv("STASH_URL", "http://localhost:1")
+	var wg sync.WaitGroup
+	clients := make([]*Client, 10)
+	for i := range clients {
+		wg.Go(func() {
+			c, err := Default()
+			assert.NoError(t, err)
+			clients[i] = c
+		})
+	}
+	wg.Wait()
+	require.NotNil(t, clients[0])
+	for _, c := range clients {
+		assert.Same(t, clients[0], c, "all goroutines share one client")
+	}
+
+	t.Setenv("STASH_URL", "http://localhost:2")
+	c, err := Default()
+	require.NoError(t, err)
+	assert.Same(t, clients[0], c, "environment is read once")
+
+	pid = 101
+	c, err = Default()
+	require.NoError(t, err)
+	assert.NotSame(t, clients[0], c, "forked process gets its own client")
+	assert.Equal(t, "http://localhost:2", c.baseURL)
+
+	custom, err := New("http://custom")
+	require.NoError(t, err)
+	SetDefault(custom)
+	c, err = Default()
+	require.NoError(t, err)
+	assert.Same(t, custom, c)
+
+	SetDefault(nil)
+	t.Setenv("STASH_URL", "")
+	_, err = Default()
+	require.EqualError(t, err, "STASH_URL is not set")
+	t.Setenv("STASH_URL", "http://localhost:3")
+	_, err = Default()
+	require.EqualError(t, err, "STASH_URL is not set", "configuration error is kept")
+}
@@ -35,4 +35,8 @@
 //	    stash.WithTimeout(10*time.Second),
 //	    stash.WithRetry(5, 200*time.Millisecond),
 //	)
+//
+// Configured from STASH_URL, STASH_TOKEN and other environment variables, shared by all goroutines:
+//
+//	client, err := stash.Default()
 package stash
@@ -3,6 +3,7 @@ package stash
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // sentinel errors for common API responses
@@ -10,14 +11,24 @@ var (
 	ErrNotFound     = errors.New("key not found")
 	ErrUnauthorized = errors.New("unauthorized")
 	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict") // a precondition of a transaction or a patch test failed
+	ErrFrozen       = errors.New("frozen")   // the key is under a prefix frozen by an admin during an incident
+
+	// ErrCircuitOpen is returned without sending the request after repeated failures, see WithCircuitBreaker
+	ErrCircuitOpen = errors.New("circuit breaker is open")
 )
 
 // ResponseError represents an HTTP error response from the server.
 type ResponseError struct {
 	StatusCode int
+	RetryAfter time.Duration // from Retry-After of 429 and 503 responses, zero if not set
+	RateLimit  RateLimit     // rate limit status of the response, zero if the server didn't report it
 }
 
 // Error implements the error interface.
 func (e *ResponseError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("stash: HTTP %d, retry after %s", e.StatusCode, e.RetryAfter)
+	}
 	return fmt.Sprintf("stash: HTTP %d", e.StatusCode)
 }
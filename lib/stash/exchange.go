@@ -0,0 +1,92 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Permission grants access to keys under a prefix, access is "r", "w" or "rw".
+type Permission struct {
+	Prefix string `json:"prefix"`
+	Access string `json:"access"`
+}
+
+// TokenRequest defines a child token requested by ExchangeToken. Empty permissions and scopes
+// inherit the ones of the client token, zero TTL selects the server default.
+type TokenRequest struct {
+	Permissions []Permission
+	Scopes      []string
+	TTL         time.Duration
+}
+
+// Token is a short-lived child token minted by the server.
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExchangeToken exchanges the client token for a short-lived child token with the same or narrower access.
+// The server must run with token exchange enabled.
+func (c *Client) ExchangeToken(ctx context.Context, tr TokenRequest) (Token, error) {
+	body := struct {
+		Permissions []Permission `json:"permissions,omitempty"`
+		Scopes      []string     `json:"scopes,omitempty"`
+		TTL         string       `json:"ttl,omitempty"`
+	}{Permissions: tr.Permissions, Scopes: tr.Scopes}
+	if tr.TTL > 0 {
+		body.TTL = tr.TTL.String()
+	}
+	return c.requestToken(ctx, "/auth/token", body)
+}
+
+// GitHubLogin exchanges the OIDC token of a GitHub Actions job for a short-lived token with the ACL of
+// the matching cloud role, zero TTL selects the server default. The server must run with
+// --auth.cloud.github-audience, and the OIDC token must be requested for that audience.
+func (c *Client) GitHubLogin(ctx context.Context, oidcToken string, ttl time.Duration) (Token, error) {
+	body := struct {
+		Provider string `json:"provider"`
+		TTL      string `json:"ttl,omitempty"`
+		GitHub   struct {
+			Token string `json:"token"`
+		} `json:"github"`
+	}{Provider: "github"}
+	body.GitHub.Token = oidcToken
+	if ttl > 0 {
+		body.TTL = ttl.String()
+	}
+	return c.requestToken(ctx, "/auth/cloud", body)
+}
+
+// requestToken posts the token request to the auth endpoint and decodes the minted token.
+func (c *Client) requestToken(ctx context.Context, path string, body any) (Token, error) {
+	base, err := c.base(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to marshal token request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+path, bytes.NewReader(data))
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req, OpExchange)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+
+	var res Token
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Token{}, fmt.Errorf("failed to decode token: %w", err)
+	}
+	return res, nil
+}
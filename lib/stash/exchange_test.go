@@ -0,0 +1,76 @@
+package stash
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ExchangeToken(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/auth/token", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		if r.Header.Get("Authorization") != "Bearer parent" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		got = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = w.Write([]byte(`{"token":"child.jwt","expires_at":"2025-01-15T10:15:00Z"}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithToken("parent"))
+	require.NoError(t, err)
+
+	tok, err := client.ExchangeToken(t.Context(), TokenRequest{Permissions: []Permission{{Prefix: "app/*", Access: "r"}},
+		Scopes: []string{"read"}, TTL: 30 * time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, "child.jwt", tok.Token)
+	assert.Equal(t, time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC), tok.ExpiresAt)
+	assert.Equal(t, map[string]any{"permissions": []any{map[string]any{"prefix": "app/*", "access": "r"}},
+		"scopes": []any{"read"}, "ttl": "30m0s"}, got)
+
+	_, err = client.ExchangeToken(t.Context(), TokenRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, got, "defaults are left to the server")
+
+	anon, err := New(server.URL)
+	require.NoError(t, err)
+	_, err = anon.ExchangeToken(t.Context(), TokenRequest{})
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestClient_GitHubLogin(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/auth/cloud", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		got = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got["github"].(map[string]any)["token"] != "oidc.jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"minted.jwt","expires_at":"2025-01-15T10:15:00Z"}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	require.NoError(t, err)
+
+	tok, err := client.GitHubLogin(t.Context(), "oidc.jwt", 15*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "minted.jwt", tok.Token)
+	assert.Equal(t, map[string]any{"provider": "github", "ttl": "15m0s", "github": map[string]any{"token": "oidc.jwt"}}, got)
+
+	_, err = client.GitHubLogin(t.Context(), "forged.jwt", 0)
+	require.ErrorIs(t, err, ErrUnauthorized)
+	assert.NotContains(t, got, "ttl")
+}
@@ -0,0 +1,304 @@
+package stash
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadTag is the struct tag binding fields to keys in Load
+const loadTag = "stash"
+
+// LoadOption configures Load.
+type LoadOption func(*loadConfig)
+
+// loadConfig holds the options of Load.
+type loadConfig struct {
+	onReload func(v any, err error)
+}
+
+// OnReload keeps the loaded config current. Load watches the keys under the prefix and, after a bound key
+// changes, loads all of them again into a new value of the target type and passes a pointer to it to fn.
+// The new value starts from the defaults the target had before Load, as the target did. A failed reload
+// passes its error instead and the caller keeps the last good value. fn is called from a single goroutine
+// until ctx of Load is canceled. The target itself isn't changed after Load returns, so the caller swaps
+// in new values, e.g. with atomic.Pointer.
+func OnReload(fn func(v any, err error)) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.onReload = fn
+	}
+}
+
+// boundField is a struct field bound to a key by its tag.
+type boundField struct {
+	index    []int  // field index in the struct, nested for embedded structs
+	name     string // field name for errors
+	key      string // full key
+	required bool   // the key must exist
+	format   Format // format decoding the value, overrides the format of the key if set
+}
+
+// Load lists the keys under the prefix and sets the fields of the struct target points to from them.
+// A field is bound to a key with a tag holding the key name relative to the prefix, e.g. `stash:"db/url"`
+// for key "<prefix>/db/url". Untagged fields are left alone, fields of embedded structs are bound too.
+//
+// Strings and []byte get the value as is. Numbers, bools (as in GetBool), durations and types implementing
+// encoding.TextUnmarshaler, like time.Time, are parsed from the trimmed value. Structs, maps and slices are
+// decoded with the codec of the key format, json, yaml or toml, keys in other formats are decoded as JSON.
+// Tag options follow the name: "required" fails if the key doesn't exist, "format=yaml" decodes with the
+// codec of the format regardless of the format of the key.
+//
+// Fields of missing keys keep their values, so the target can hold defaults. Values that don't parse return
+// a *ParseError. The target is set only if all keys loaded. See OnReload for updates on changes.
+func (c *Client) Load(ctx context.Context, prefix string, target any, opts ...LoadOption) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("stash: load target must be a non-nil pointer to a struct, got %T", target)
+	}
+	var cfg loadConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	prefix = strings.Trim(prefix, "/")
+	fields, err := bindFields(rv.Elem().Type(), prefix, nil)
+	if err != nil {
+		return err
+	}
+	defaults := reflect.New(rv.Elem().Type()).Elem()
+	defaults.Set(rv.Elem())
+
+	// watch before loading, so changes made during the load are not missed
+	var events <-chan Event
+	if cfg.onReload != nil {
+		if events, err = c.Watch(ctx, prefix); err != nil {
+			return fmt.Errorf("failed to watch %q: %w", prefix, err)
+		}
+	}
+	loaded, err := c.loadFields(ctx, prefix, fields, defaults)
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(loaded.Elem())
+	if cfg.onReload != nil {
+		go c.reloadOnChange(ctx, events, prefix, fields, defaults, cfg.onReload)
+	}
+	return nil
+}
+
+// reloadOnChange loads the fields again after changes of bound keys and reconnects of the watch,
+// which may have missed changes.
+func (c *Client) reloadOnChange(ctx context.Context, events <-chan Event, prefix string, fields []boundField,
+	defaults reflect.Value, fn func(v any, err error)) {
+	bound := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		bound[f.key] = true
+	}
+	for ev := range events {
+		if ev.Action != "reset" && !bound[ev.Key] {
+			continue
+		}
+		// changes often come in bursts, e.g. from a transaction or an import, reload once for all pending
+	drain:
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			default:
+				break drain
+			}
+		}
+		loaded, err := c.loadFields(ctx, prefix, fields, defaults)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fn(nil, err)
+			continue
+		}
+		fn(loaded.Interface(), nil)
+	}
+}
+
+// loadFields loads the bound keys into a copy of defaults and returns a pointer to it.
+func (c *Client) loadFields(ctx context.Context, prefix string, fields []boundField, defaults reflect.Value) (reflect.Value, error) {
+	keys, err := c.List(ctx, prefix)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("failed to list keys of %q: %w", prefix, err)
+	}
+	formats := make(map[string]string, len(keys))
+	for _, k := range keys {
+		formats[k.Key] = k.Format
+	}
+
+	res := reflect.New(defaults.Type())
+	res.Elem().Set(defaults)
+	for _, f := range fields {
+		format, exists := formats[f.key]
+		var data []byte
+		if exists {
+			data, err = c.GetBytes(ctx, f.key)
+			if errors.Is(err, ErrNotFound) {
+				exists = false // deleted since listed
+			} else if err != nil {
+				return reflect.Value{}, fmt.Errorf("failed to load field %s: %w", f.name, err)
+			}
+		}
+		if !exists {
+			if f.required {
+				return reflect.Value{}, fmt.Errorf("stash: key %q of required field %s: %w", f.key, f.name, ErrNotFound)
+			}
+			continue
+		}
+		if f.format.String() != "" {
+			format = f.format.String()
+		}
+		if err := setField(res.Elem().FieldByIndex(f.index), f.key, format, data); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return res, nil
+}
+
+// bindFields returns the fields of the struct type tagged with keys, walking untagged embedded structs.
+func bindFields(t reflect.Type, prefix string, index []int) ([]boundField, error) {
+	var res []boundField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		idx := append(slices.Clone(index), i)
+		tag, tagged := f.Tag.Lookup(loadTag)
+		if !tagged {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				nested, err := bindFields(f.Type, prefix, idx)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, nested...)
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("stash: field %s with %s tag is not exported", f.Name, loadTag)
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name = strings.Trim(name, "/"); name == "" {
+			return nil, fmt.Errorf("stash: empty key in %s tag of field %s", loadTag, f.Name)
+		}
+		bf := boundField{index: idx, name: f.Name, key: name}
+		if prefix != "" {
+			bf.key = prefix + "/" + name
+		}
+		for opt := range strings.SplitSeq(opts, ",") {
+			switch {
+			case opt == "":
+			case opt == "required":
+				bf.required = true
+			case strings.HasPrefix(opt, "format="):
+				format, err := LookupFormat(strings.TrimPrefix(opt, "format="))
+				if err == nil {
+					_, err = codecFor(format)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("stash: %s tag of field %s: %w", loadTag, f.Name, err)
+				}
+				bf.format = format
+			default:
+				return nil, fmt.Errorf("stash: unknown option %q in %s tag of field %s", opt, loadTag, f.Name)
+			}
+		}
+		res = append(res, bf)
+	}
+	return res, nil
+}
+
+// durationType is parsed with time.ParseDuration rather than as a number of nanoseconds
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setField sets the field from the value of the key.
+func setField(v reflect.Value, key, format string, data []byte) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setField(elem.Elem(), key, format, data); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(strings.TrimSpace(string(data)))); err != nil {
+			return newParseError(key, v.Type().String(), string(data), err)
+		}
+		return nil
+	}
+
+	text := strings.TrimSpace(string(data))
+	var err error
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(data))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(slices.Clone(data))
+	case v.Kind() == reflect.Bool:
+		var b bool
+		if b, err = parseBool(text); err == nil {
+			v.SetBool(b)
+		}
+	case v.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(text); err == nil {
+			v.SetInt(int64(d))
+		}
+	case v.CanInt():
+		var n int64
+		if n, err = strconv.ParseInt(text, 10, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case v.CanUint():
+		var n uint64
+		if n, err = strconv.ParseUint(text, 10, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case v.CanFloat():
+		var f float64
+		if f, err = strconv.ParseFloat(text, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	default:
+		return decodeField(v, key, format, data)
+	}
+	if err != nil {
+		return newParseError(key, v.Type().String(), string(data), err)
+	}
+	return nil
+}
+
+// decodeField decodes structs, maps and slices with the codec of the format, JSON if the format has none.
+// Structs are decoded over their current value to keep defaults, maps and slices into new ones, so the
+// defaults shared by reloads are never modified.
+func decodeField(v reflect.Value, key, format string, data []byte) error {
+	codec, name := Codec(jsonCodec{}), FormatJSON.String()
+	if f, err := LookupFormat(format); err == nil {
+		if fc, err := codecFor(f); err == nil {
+			codec, name = fc, f.String()
+		}
+	}
+	target := reflect.New(v.Type())
+	if v.Kind() == reflect.Struct {
+		target.Elem().Set(v)
+	}
+	if err := codec.Unmarshal(data, target.Interface()); err != nil {
+		return newParseError(key, name, string(data), err)
+	}
+	v.Set(target.Elem())
+	return nil
+}
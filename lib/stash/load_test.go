@@ -0,0 +1,205 @@
+package stash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadServer serves keys for Load tests, changes are sent to the active watch.
+type loadServer struct {
+	mu      sync.Mutex
+	values  map[string]string
+	formats map[string]string
+	events  chan Event
+}
+
+func (s *loadServer) set(key, value string) {
+	s.mu.Lock()
+	s.values[key] = value
+	s.mu.Unlock()
+	s.events <- Event{Key: key, Action: "update"}
+}
+
+func (s *loadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/kv/")
+	switch {
+	case strings.HasPrefix(path, "subscribe/"):
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case ev := <-s.events:
+				data, _ := json.Marshal(ev)
+				_, _ = w.Write([]byte("event: change\ndata: " + string(data) + "\n\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	case path == "":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var keys []KeyInfo
+		for k := range s.values {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, KeyInfo{Key: k, Format: s.formats[k]})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		v, ok := s.values[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(v))
+	}
+}
+
+func TestClient_Load(t *testing.T) {
+	type DB struct {
+		Host string `json:"host" yaml:"host"`
+		Port int    `json:"port" yaml:"port"`
+	}
+	type Common struct {
+		Name string `stash:"name"`
+	}
+	type config struct {
+		Common
+		Port     int               `stash:"port,required"`
+		Ratio    float64           `stash:"ratio"`
+		Debug    bool              `stash:"debug"`
+		Timeout  time.Duration     `stash:"timeout"`
+		Started  time.Time         `stash:"started"`
+		Key      []byte            `stash:"key"`
+		Workers  *uint8            `stash:"workers"`
+		DB       DB                `stash:"db"`
+		Limits   map[string]int    `stash:"limits,format=yaml"`
+		Hosts    []string          `stash:"hosts"`
+		Retries  int               `stash:"retries"`
+		Headers  map[string]string `stash:"headers"`
+		Internal string
+	}
+	srv := &loadServer{values: map[string]string{
+		"app/name":    "billing\n",
+		"app/port":    "8080\n",
+		"app/ratio":   "0.5",
+		"app/debug":   "yes",
+		"app/timeout": "1m30s",
+		"app/started": "2025-01-03T10:30:00Z",
+		"app/key":     "secret",
+		"app/workers": "4",
+		"app/db":      "host: db\n",
+		"app/limits":  "rps: 10\n",
+		"app/hosts":   `["a","b"]`,
+	}, formats: map[string]string{"app/db": "yaml", "app/hosts": "json"}, events: make(chan Event, 10)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	c, err := New(ts.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+
+	t.Run("binds fields", func(t *testing.T) {
+		cfg := config{Retries: 3, DB: DB{Port: 5432}, Internal: "kept"}
+		require.NoError(t, c.Load(t.Context(), "app/", &cfg))
+		assert.Equal(t, "billing\n", cfg.Name, "strings are not trimmed")
+		assert.Equal(t, 8080, cfg.Port)
+		assert.InDelta(t, 0.5, cfg.Ratio, 1e-9)
+		assert.True(t, cfg.Debug)
+		assert.Equal(t, 90*time.Second, cfg.Timeout)
+		assert.Equal(t, time.Date(2025, 1, 3, 10, 30, 0, 0, time.UTC), cfg.Started)
+		assert.Equal(t, []byte("secret"), cfg.Key)
+		require.NotNil(t, cfg.Workers)
+		assert.Equal(t, uint8(4), *cfg.Workers)
+		assert.Equal(t, DB{Host: "db", Port: 5432}, cfg.DB, "yaml key decoded over the default port")
+		assert.Equal(t, map[string]int{"rps": 10}, cfg.Limits)
+		assert.Equal(t, []string{"a", "b"}, cfg.Hosts)
+		assert.Equal(t, 3, cfg.Retries, "missing key keeps the default")
+		assert.Nil(t, cfg.Headers)
+		assert.Equal(t, "kept", cfg.Internal)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var cfg config
+		err := c.Load(t.Context(), "other", &cfg)
+		require.ErrorIs(t, err, ErrNotFound, "required port is missing")
+		assert.Contains(t, err.Error(), `"other/port"`)
+
+		srv.mu.Lock()
+		srv.values["bad/port"] = "eighty"
+		srv.mu.Unlock()
+		cfg.Name = "unchanged"
+		var perr *ParseError
+		require.ErrorAs(t, c.Load(t.Context(), "bad", &cfg), &perr)
+		assert.Equal(t, "bad/port", perr.Key)
+		assert.Equal(t, "unchanged", cfg.Name, "target is not changed on errors")
+
+		require.Error(t, c.Load(t.Context(), "app", cfg), "not a pointer")
+		require.Error(t, c.Load(t.Context(), "app", new(int)), "not a struct")
+		require.Error(t, c.Load(t.Context(), "app", &struct {
+			Port int `stash:"port,optional"`
+		}{}), "unknown tag option")
+		require.Error(t, c.Load(t.Context(), "app", &struct {
+			Port int `stash:"port,format=ini"`
+		}{}), "unknown format")
+	})
+
+	t.Run("reload on change", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		type reload struct {
+			cfg *config
+			err error
+		}
+		reloads := make(chan reload, 10)
+		cfg := config{Retries: 3}
+		err := c.Load(ctx, "app", &cfg, OnReload(func(v any, err error) {
+			r := reload{err: err}
+			if v != nil {
+				r.cfg = v.(*config)
+			}
+			reloads <- r
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 8080, cfg.Port)
+
+		wait := func() reload {
+			select {
+			case r := <-reloads:
+				return r
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout waiting for reload")
+				return reload{}
+			}
+		}
+		srv.events <- Event{Key: "app/unbound", Action: "update"}
+		srv.set("app/port", "9090")
+		r := wait()
+		require.NoError(t, r.err)
+		assert.Equal(t, 9090, r.cfg.Port)
+		assert.Equal(t, 3, r.cfg.Retries, "reload starts from the defaults")
+		assert.Equal(t, 8080, cfg.Port, "target is not changed by reloads")
+
+		srv.set("app/port", "ninety")
+		r = wait()
+		var perr *ParseError
+		require.ErrorAs(t, r.err, &perr)
+		assert.Nil(t, r.cfg)
+
+		srv.set("app/port", "8080")
+		r = wait()
+		require.NoError(t, r.err)
+		assert.Equal(t, 8080, r.cfg.Port)
+	})
+}
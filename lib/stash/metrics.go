@@ -0,0 +1,205 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pkgz/requester/middleware"
+)
+
+// operation names reported to MetricsReporter
+const (
+	OpGet      = "get"
+	OpSet      = "set"
+	OpDelete   = "delete"
+	OpList     = "list"
+	OpPing     = "ping"
+	OpExchange = "exchange"
+	OpExport   = "export"
+	OpImport   = "import"
+	OpTxn      = "txn"
+	OpPatch    = "patch"
+)
+
+// MetricsReporter receives client-side metrics. Implementations must be safe for concurrent use.
+type MetricsReporter interface {
+	// ObserveRequest is called once per HTTP request made by an operation, after retries.
+	// err is nil on success and carries ErrNotFound, ResponseError etc. otherwise.
+	ObserveRequest(op string, duration time.Duration, err error)
+	// ObserveRetry is called for each retry attempt of an operation.
+	ObserveRetry(op string)
+	// ObserveCache is called for reads that may be served without a request of their own.
+	// hit is true when the value came from a shared in-flight request or from the cache enabled by WithCache.
+	ObserveCache(op string, hit bool)
+}
+
+// attemptsCtxKey is the request context key holding *requestAttempts.
+type attemptsCtxKey struct{}
+
+// requestAttempts tracks attempts of a single request. Attempts are sequential, no locking needed.
+type requestAttempts struct {
+	op    string
+	count int
+}
+
+// countRetries returns middleware reporting every attempt after the first one as a retry.
+// It must be placed inside the retry middleware to see individual attempts.
+func countRetries(m MetricsReporter) middleware.RoundTripperHandler {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if a, ok := req.Context().Value(attemptsCtxKey{}).(*requestAttempts); ok {
+				if a.count++; a.count > 1 {
+					m.ObserveRetry(a.op)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// defaultDurationBuckets are the histogram buckets for request latency, in seconds.
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is a MetricsReporter collecting metrics in memory and exposing them
+// in the Prometheus text exposition format. Mount it as an http.Handler (e.g. on /metrics)
+// or call WriteTo from an existing metrics endpoint. No Prometheus client dependency is required.
+type PrometheusMetrics struct {
+	namespace string
+
+	mu        sync.Mutex
+	requests  map[[2]string]uint64 // {op, result} -> count
+	durations map[string]*histogram
+	retries   map[string]uint64
+	cache     map[[2]string]uint64 // {op, hit|miss} -> count
+}
+
+// histogram is a cumulative latency histogram.
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics creates a Prometheus reporter. Metric names are prefixed with
+// namespace, "stash" is used if empty.
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = "stash"
+	}
+	return &PrometheusMetrics{
+		namespace: namespace,
+		requests:  map[[2]string]uint64{},
+		durations: map[string]*histogram{},
+		retries:   map[string]uint64{},
+		cache:     map[[2]string]uint64{},
+	}
+}
+
+// ObserveRequest implements MetricsReporter.
+func (p *PrometheusMetrics) ObserveRequest(op string, duration time.Duration, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, ErrNotFound):
+		result = "not_found"
+	case err != nil:
+		result = "error"
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[[2]string{op, result}]++
+	h, ok := p.durations[op]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(defaultDurationBuckets))}
+		p.durations[op] = h
+	}
+	secs := duration.Seconds()
+	if i, _ := slices.BinarySearch(defaultDurationBuckets, secs); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += secs
+}
+
+// ObserveRetry implements MetricsReporter.
+func (p *PrometheusMetrics) ObserveRetry(op string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries[op]++
+}
+
+// ObserveCache implements MetricsReporter.
+func (p *PrometheusMetrics) ObserveCache(op string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache[[2]string{op, result}]++
+}
+
+// ServeHTTP writes metrics in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo writes metrics in the Prometheus text format to w.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	name := p.namespace + "_client_requests_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of requests by operation and result.\n# TYPE %s counter\n", name, name)
+	for _, k := range sortedKeys(p.requests) {
+		fmt.Fprintf(&b, "%s{op=%q,result=%q} %d\n", name, k[0], k[1], p.requests[k])
+	}
+
+	name = p.namespace + "_client_request_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Request latency by operation, including retries.\n# TYPE %s histogram\n", name, name)
+	for _, op := range sortedKeys(p.durations) {
+		h := p.durations[op]
+		var cumulative uint64
+		for i, le := range defaultDurationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{op=%q,le=%q} %d\n", name, op, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{op=%q,le=\"+Inf\"} %d\n", name, op, h.count)
+		fmt.Fprintf(&b, "%s_sum{op=%q} %s\n", name, op, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{op=%q} %d\n", name, op, h.count)
+	}
+
+	name = p.namespace + "_client_retries_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of retry attempts by operation.\n# TYPE %s counter\n", name, name)
+	for _, op := range sortedKeys(p.retries) {
+		fmt.Fprintf(&b, "%s{op=%q} %d\n", name, op, p.retries[op])
+	}
+
+	name = p.namespace + "_client_cache_total"
+	fmt.Fprintf(&b, "# HELP %s Reads served from a shared request (hit) or a request of their own (miss).\n# TYPE %s counter\n", name, name)
+	for _, k := range sortedKeys(p.cache) {
+		fmt.Fprintf(&b, "%s{op=%q,result=%q} %d\n", name, k[0], k[1], p.cache[k])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// sortedKeys returns map keys in a stable order for deterministic output.
+func sortedKeys[K interface{ ~string | ~[2]string }, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b K) int { return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)) })
+	return keys
+}
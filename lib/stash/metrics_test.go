@@ -0,0 +1,127 @@
+package stash
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricsRecorder is a MetricsReporter keeping observed calls for assertions.
+type metricsRecorder struct {
+	mu       sync.Mutex
+	requests []string // op:result
+	retries  []string
+	cache    []bool
+}
+
+func (m *metricsRecorder) ObserveRequest(op string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	m.requests = append(m.requests, op+":"+result)
+}
+
+func (m *metricsRecorder) ObserveRetry(op string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, op)
+}
+
+func (m *metricsRecorder) ObserveCache(_ string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache = append(m.cache, hit)
+}
+
+func TestClient_WithMetrics(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kv/flaky":
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("value"))
+		case "/kv/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	rec := &metricsRecorder{}
+	c, err := New(srv.URL, WithRetry(3, time.Millisecond), WithMetrics(rec))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	val, err := c.Get(ctx, "flaky")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	_, err = c.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, c.Set(ctx, "app/key", "v"))
+	require.NoError(t, c.Delete(ctx, "app/key"))
+	require.NoError(t, c.Ping(ctx))
+
+	assert.Equal(t, []string{"get:ok", "get:" + ErrNotFound.Error(), "set:ok", "delete:ok", "ping:ok"}, rec.requests)
+	assert.Equal(t, []string{"get"}, rec.retries)
+	assert.Equal(t, []bool{false, false}, rec.cache, "sequential gets make their own requests")
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	p := NewPrometheusMetrics("")
+	p.ObserveRequest(OpGet, 3*time.Millisecond, nil)
+	p.ObserveRequest(OpGet, 200*time.Millisecond, ErrNotFound)
+	p.ObserveRequest(OpSet, 20*time.Second, &ResponseError{StatusCode: 500})
+	p.ObserveRetry(OpSet)
+	p.ObserveCache(OpGet, true)
+	p.ObserveCache(OpGet, false)
+
+	rr := httptest.NewRecorder()
+	p.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	out := rr.Body.String()
+	for _, line := range []string{
+		"# TYPE stash_client_requests_total counter",
+		`stash_client_requests_total{op="get",result="not_found"} 1`,
+		`stash_client_requests_total{op="get",result="ok"} 1`,
+		`stash_client_requests_total{op="set",result="error"} 1`,
+		"# TYPE stash_client_request_duration_seconds histogram",
+		`stash_client_request_duration_seconds_bucket{op="get",le="0.005"} 1`,
+		`stash_client_request_duration_seconds_bucket{op="get",le="0.1"} 1`,
+		`stash_client_request_duration_seconds_bucket{op="get",le="0.25"} 2`,
+		`stash_client_request_duration_seconds_bucket{op="get",le="+Inf"} 2`,
+		`stash_client_request_duration_seconds_count{op="get"} 2`,
+		`stash_client_request_duration_seconds_bucket{op="set",le="10"} 0`,
+		`stash_client_request_duration_seconds_bucket{op="set",le="+Inf"} 1`,
+		`stash_client_request_duration_seconds_sum{op="set"} 20`,
+		`stash_client_retries_total{op="set"} 1`,
+		`stash_client_cache_total{op="get",result="hit"} 1`,
+		`stash_client_cache_total{op="get",result="miss"} 1`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+
+	t.Run("custom namespace", func(t *testing.T) {
+		p := NewPrometheusMetrics("myapp")
+		p.ObserveRetry(OpList)
+		rr := httptest.NewRecorder()
+		p.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+		assert.Contains(t, rr.Body.String(), `myapp_client_retries_total{op="list"} 1`)
+	})
+}
@@ -0,0 +1,116 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Patch is a change of a JSON value applied by the server, see MergePatch and JSONPatch.
+type Patch struct {
+	contentType string
+	body        []byte
+	err         error
+}
+
+// PatchOp is an operation of a JSON patch (RFC 6902): add, remove, replace, move, copy or test.
+// Path and From are JSON pointers like "/db/port", From is used by move and copy only. Value is sent
+// for add, replace and test, where nil is JSON null.
+type PatchOp struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// MarshalJSON encodes the operation with the members its op has.
+func (o PatchOp) MarshalJSON() ([]byte, error) {
+	type op struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		From  string `json:"from,omitempty"`
+		Value *any   `json:"value,omitempty"`
+	}
+	res := op{Op: o.Op, Path: o.Path, From: o.From}
+	switch o.Op {
+	case "add", "replace", "test":
+		res.Value = &o.Value
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch operation: %w", err)
+	}
+	return data, nil
+}
+
+// MergePatch makes a JSON merge patch (RFC 7386) from the value marshaled to JSON, use json.RawMessage for
+// a patch already in JSON. Members of the patch object replace members of the value, null members remove them.
+//
+//	client.Patch(ctx, "app/config", stash.MergePatch(map[string]any{"db": map[string]any{"port": 6432}}))
+func MergePatch(v any) Patch {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Patch{err: fmt.Errorf("failed to marshal merge patch: %w", err)}
+	}
+	return Patch{contentType: "application/merge-patch+json", body: data}
+}
+
+// JSONPatch makes a JSON patch (RFC 6902) of the operations, applied in order. A failed test operation
+// makes Patch fail with ErrConflict.
+//
+//	client.Patch(ctx, "app/config", stash.JSONPatch(
+//		stash.PatchOp{Op: "test", Path: "/db/port", Value: 5432},
+//		stash.PatchOp{Op: "replace", Path: "/db/port", Value: 6432}))
+func JSONPatch(ops ...PatchOp) Patch {
+	if len(ops) == 0 {
+		return Patch{err: errors.New("patch has no operations")}
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return Patch{err: fmt.Errorf("failed to marshal json patch: %w", err)}
+	}
+	return Patch{contentType: "application/json-patch+json", body: data}
+}
+
+// Patch changes a part of the JSON value of the key on the server and returns the new value. The patch is
+// applied to the current value, so concurrent changes of other fields are kept, unlike with Get and Set.
+// Only keys with json format can be patched, ZK-encrypted values can't as the server doesn't see them.
+// Returns ErrNotFound if the key doesn't exist, and ErrConflict if a test operation fails.
+func (c *Client) Patch(ctx context.Context, key string, patch Patch) (string, error) {
+	if key == "" {
+		return "", errors.New("key is required")
+	}
+	if patch.err != nil {
+		return "", patch.err
+	}
+	if patch.contentType == "" {
+		return "", errors.New("patch is empty, use MergePatch or JSONPatch")
+	}
+
+	u, err := c.keyURL(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u, bytes.NewReader(patch.body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", patch.contentType)
+
+	resp, err := c.do(req, OpPatch)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	c.written(key, resp)
+
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read patched value: %w", err)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,86 @@
+package stash
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Patch(t *testing.T) {
+	var gotType, gotBody string
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			_, _ = w.Write([]byte(`{"port":5432}`))
+			return
+		}
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/kv/app/cfg", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		gotType, gotBody = r.Header.Get("Content-Type"), string(body)
+		if gotBody == `[{"op":"test","path":"/port","value":1}]` {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		_, _ = w.Write([]byte(`{"port":6432}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithRetry(0, 0), WithCache(time.Minute))
+	require.NoError(t, err)
+
+	t.Run("merge patch", func(t *testing.T) {
+		_, err := client.Get(t.Context(), "app/cfg")
+		require.NoError(t, err)
+		value, err := client.Patch(t.Context(), "app/cfg", MergePatch(map[string]any{"port": 6432, "debug": nil}))
+		require.NoError(t, err)
+		assert.Equal(t, `{"port":6432}`, value)
+		assert.Equal(t, "application/merge-patch+json", gotType)
+		assert.JSONEq(t, `{"port":6432,"debug":null}`, gotBody)
+
+		_, err = client.Get(t.Context(), "app/cfg")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), gets.Load(), "cached value dropped after patch")
+
+		_, err = client.Patch(t.Context(), "app/cfg", MergePatch(json.RawMessage(`{"port":6432}`)))
+		require.NoError(t, err)
+		assert.Equal(t, `{"port":6432}`, gotBody)
+	})
+
+	t.Run("json patch", func(t *testing.T) {
+		_, err := client.Patch(t.Context(), "app/cfg", JSONPatch(
+			PatchOp{Op: "test", Path: "/port", Value: 5432},
+			PatchOp{Op: "replace", Path: "/port", Value: nil},
+			PatchOp{Op: "move", From: "/a", Path: "/b"},
+			PatchOp{Op: "remove", Path: "/c"}))
+		require.NoError(t, err)
+		assert.Equal(t, "application/json-patch+json", gotType)
+		assert.JSONEq(t, `[{"op":"test","path":"/port","value":5432},{"op":"replace","path":"/port","value":null},
+			{"op":"move","from":"/a","path":"/b"},{"op":"remove","path":"/c"}]`, gotBody)
+	})
+
+	t.Run("failed test is a conflict", func(t *testing.T) {
+		_, err := client.Patch(t.Context(), "app/cfg", JSONPatch(PatchOp{Op: "test", Path: "/port", Value: 1}))
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("invalid patches", func(t *testing.T) {
+		_, err := client.Patch(t.Context(), "", MergePatch(map[string]any{}))
+		require.EqualError(t, err, "key is required")
+		_, err = client.Patch(t.Context(), "app/cfg", Patch{})
+		require.ErrorContains(t, err, "patch is empty")
+		_, err = client.Patch(t.Context(), "app/cfg", JSONPatch())
+		require.EqualError(t, err, "patch has no operations")
+		_, err = client.Patch(t.Context(), "app/cfg", MergePatch(func() {}))
+		require.ErrorContains(t, err, "failed to marshal merge patch")
+	})
+}
@@ -0,0 +1,47 @@
+package stash
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit is the rate limit status of the client as reported by the server in X-RateLimit-* headers.
+type RateLimit struct {
+	Limit     int           // requests allowed per second
+	Remaining int           // requests left before the server starts rejecting them with 429
+	Reset     time.Duration // time until the limit is replenished
+}
+
+// RateLimit returns the rate limit status of the last response, false if no response reported it yet.
+// Callers sending many requests can slow down once Remaining gets low.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	rl, ok := c.rateLimit.Load().(RateLimit)
+	return rl, ok
+}
+
+// parseRateLimit reads the rate limit status from the response headers, false if the server didn't set them.
+func parseRateLimit(h http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	remaining, _ := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.Atoi(h.Get("X-RateLimit-Reset"))
+	return RateLimit{Limit: limit, Remaining: remaining, Reset: time.Duration(reset) * time.Second}, true
+}
+
+// parseRetryAfter reads the Retry-After header given in seconds or as an HTTP date, zero if not set.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
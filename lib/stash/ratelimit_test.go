@@ -0,0 +1,76 @@
+package stash
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	remaining := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "3")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1")
+		if remaining == 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		remaining--
+		w.Header().Set("X-RateLimit-Remaining", "2")
+		_, _ = w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+	_, ok := c.RateLimit()
+	assert.False(t, ok, "no response yet")
+
+	_, err = c.Get(context.Background(), "app/db")
+	require.NoError(t, err)
+	rl, ok := c.RateLimit()
+	require.True(t, ok)
+	assert.Equal(t, RateLimit{Limit: 3, Remaining: 2, Reset: time.Second}, rl)
+
+	remaining = 0
+	_, err = c.Get(context.Background(), "app/db")
+	var respErr *ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, http.StatusTooManyRequests, respErr.StatusCode)
+	assert.Equal(t, time.Second, respErr.RetryAfter)
+	assert.Equal(t, RateLimit{Limit: 3, Remaining: 0, Reset: time.Second}, respErr.RateLimit)
+	assert.Equal(t, "stash: HTTP 429, retry after 1s", err.Error())
+	rl, _ = c.RateLimit()
+	assert.Equal(t, 0, rl.Remaining, "status of error responses is recorded too")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tbl := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "seconds", value: "5", want: 5 * time.Second},
+		{name: "not set", value: "", want: 0},
+		{name: "invalid", value: "soon", want: 0},
+		{name: "past date", value: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			h.Set("Retry-After", tt.value)
+			assert.Equal(t, tt.want, parseRetryAfter(h))
+		})
+	}
+
+	h := http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, time.Minute, parseRetryAfter(h), float64(2*time.Second), "http date")
+}
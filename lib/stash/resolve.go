@@ -0,0 +1,266 @@
+package stash
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pkgz/requester/middleware"
+)
+
+// defaultResolveInterval is how long resolved endpoints are cached before refreshing.
+const defaultResolveInterval = 30 * time.Second
+
+// Resolver returns base URLs of available stash servers, in order of preference.
+// Used to discover endpoints dynamically instead of a fixed base URL.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc is an adapter to use a plain function as a Resolver.
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+// Resolve calls f(ctx).
+func (f ResolverFunc) Resolve(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// SRVResolver resolves endpoints from DNS SRV records, e.g. _stash._tcp.example.com.
+// Records are returned sorted by priority and randomized by weight within a priority.
+type SRVResolver struct {
+	Name   string // full SRV record name
+	Scheme string // endpoint scheme, http (default) or https
+
+	lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewSRVResolver creates a resolver for the given SRV record name using the default DNS resolver.
+func NewSRVResolver(name, scheme string) *SRVResolver {
+	return &SRVResolver{Name: name, Scheme: scheme, lookup: net.DefaultResolver.LookupSRV}
+}
+
+// Resolve looks up the SRV record and converts targets to base URLs.
+func (r *SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	lookup := r.lookup
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	_, records, err := lookup(ctx, "", "", r.Name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup SRV %s: %w", r.Name, err)
+	}
+
+	orderSRV(records)
+	urls := make([]string, 0, len(records))
+	for _, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		if host == "" {
+			continue
+		}
+		urls = append(urls, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+	}
+	return urls, nil
+}
+
+// orderSRV sorts records by priority, lowest first, and orders records of the same priority
+// by weighted random selection as described in RFC 2782. Zero-weight records go last.
+func orderSRV(records []*net.SRV) {
+	slices.SortStableFunc(records, func(a, b *net.SRV) int { return cmp.Compare(a.Priority, b.Priority) })
+	for i := 0; i < len(records); {
+		j := i + 1
+		for j < len(records) && records[j].Priority == records[i].Priority {
+			j++
+		}
+		shuffleByWeight(records[i:j])
+		i = j
+	}
+}
+
+// shuffleByWeight picks records one by one, each with probability proportional to its weight
+// among the records not picked yet.
+func shuffleByWeight(records []*net.SRV) {
+	sum := 0
+	for _, rec := range records {
+		sum += int(rec.Weight)
+	}
+	for sum > 0 && len(records) > 1 {
+		n, s := rand.IntN(sum), 0 //nolint:gosec // load balancing, not security sensitive
+		for i := range records {
+			s += int(records[i].Weight)
+			if s > n {
+				records[0], records[i] = records[i], records[0]
+				break
+			}
+		}
+		sum -= int(records[0].Weight)
+		records = records[1:]
+	}
+}
+
+// parseSRVURL detects srv:// and srv+https:// base URLs and returns the matching resolver.
+// Returns nil for regular URLs.
+func parseSRVURL(baseURL string) *SRVResolver {
+	lower := strings.ToLower(baseURL)
+	switch {
+	case strings.HasPrefix(lower, "srv+https://"):
+		return NewSRVResolver(strings.Trim(baseURL[len("srv+https://"):], "/"), "https")
+	case strings.HasPrefix(lower, "srv://"):
+		return NewSRVResolver(strings.Trim(baseURL[len("srv://"):], "/"), "http")
+	default:
+		return nil
+	}
+}
+
+// endpointResolver caches endpoints returned by a Resolver and refreshes them periodically.
+// If a refresh fails, the last known endpoints are kept until the next attempt.
+// Only one refresh runs at a time; callers arriving meanwhile use the stale endpoints or wait for it.
+type endpointResolver struct {
+	resolver Resolver
+	interval time.Duration
+	fallback string // static base URL used when nothing was resolved yet (may be empty)
+
+	mu        sync.Mutex
+	urls      []string
+	expires   time.Time
+	resolving chan struct{} // closed when the running refresh completes, nil if none runs
+}
+
+// endpoint returns the preferred base URL, refreshing the cached list if expired.
+func (e *endpointResolver) endpoint(ctx context.Context) (string, error) {
+	for {
+		e.mu.Lock()
+		if len(e.urls) > 0 && (time.Now().Before(e.expires) || e.resolving != nil) {
+			u := e.urls[0]
+			e.mu.Unlock()
+			return u, nil
+		}
+		if e.resolving == nil {
+			break // refresh in this call, still holding the lock
+		}
+		// nothing resolved yet and another call refreshes, wait for it and check again
+		done := e.resolving
+		e.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	done := make(chan struct{})
+	e.resolving = done
+	e.mu.Unlock()
+
+	urls, err := e.resolver.Resolve(ctx)
+	for i := range urls {
+		urls[i] = strings.TrimSuffix(urls[i], "/")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolving = nil
+	close(done)
+
+	switch {
+	case err == nil && len(urls) > 0:
+		e.urls = urls
+		e.expires = time.Now().Add(e.interval)
+	case len(e.urls) > 0:
+		e.expires = time.Now().Add(e.interval) // keep stale endpoints, retry on next interval
+	case e.fallback != "":
+		return e.fallback, nil
+	case err != nil:
+		return "", fmt.Errorf("resolve endpoints: %w", err)
+	default:
+		return "", errors.New("resolve endpoints: no endpoints found")
+	}
+	return e.urls[0], nil
+}
+
+// failed moves the endpoint serving rawURL to the end of the list and returns its base URL
+// and the endpoint to try next. Returns false if rawURL isn't served by a resolved endpoint
+// or there is no other endpoint to try.
+func (e *endpointResolver) failed(rawURL string) (base, next string, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.urls) < 2 {
+		return "", "", false
+	}
+	for i, u := range e.urls {
+		if rawURL != u && !strings.HasPrefix(rawURL, u+"/") {
+			continue
+		}
+		e.urls = append(slices.Delete(slices.Clone(e.urls), i, i+1), u)
+		return u, e.urls[0], true
+	}
+	return "", "", false
+}
+
+// failover is a middleware sending the request to the next endpoint if the connection to the current
+// one can't be established. Each endpoint is tried once per request.
+func (e *endpointResolver) failover(next http.RoundTripper) http.RoundTripper {
+	return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		tried := map[string]bool{}
+		for {
+			resp, err := next.RoundTrip(req)
+			if err == nil || !isConnError(err) || req.Context().Err() != nil {
+				return resp, err
+			}
+			base, alt, ok := e.failed(req.URL.String())
+			if !ok || tried[alt] {
+				return resp, err
+			}
+			tried[base] = true
+			retry, rerr := rebaseRequest(req, base, alt)
+			if rerr != nil {
+				return resp, err
+			}
+			req = retry
+		}
+	})
+}
+
+// rebaseRequest clones the request with its URL moved from one base URL to another.
+// Fails if the request has a body which can't be replayed.
+func rebaseRequest(req *http.Request, from, to string) (*http.Request, error) {
+	u, err := url.Parse(to + strings.TrimPrefix(req.URL.String(), from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build URL: %w", err)
+	}
+	res := req.Clone(req.Context())
+	res.URL, res.Host = u, u.Host
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("request body can't be replayed")
+		}
+		if res.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("failed to replay body: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// isConnError returns true for errors of establishing a connection, i.e. the request wasn't sent.
+func isConnError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
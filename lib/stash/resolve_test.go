@@ -0,0 +1,253 @@
+package stash
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRVResolver_Resolve(t *testing.T) {
+	t.Run("builds urls from records", func(t *testing.T) {
+		r := &SRVResolver{Name: "_stash._tcp.example.com", Scheme: "https",
+			lookup: func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				assert.Empty(t, service)
+				assert.Empty(t, proto)
+				assert.Equal(t, "_stash._tcp.example.com", name)
+				return "", []*net.SRV{{Target: "a.example.com.", Port: 8484}, {Target: "", Port: 1}, {Target: "b.example.com.", Port: 443}}, nil
+			}}
+		urls, err := r.Resolve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://a.example.com:8484", "https://b.example.com:443"}, urls)
+	})
+
+	t.Run("default scheme", func(t *testing.T) {
+		r := &SRVResolver{Name: "_stash._tcp.local",
+			lookup: func(context.Context, string, string, string) (string, []*net.SRV, error) {
+				return "", []*net.SRV{{Target: "host", Port: 80}}, nil
+			}}
+		urls, err := r.Resolve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://host:80"}, urls)
+	})
+
+	t.Run("orders by priority and weight", func(t *testing.T) {
+		r := &SRVResolver{Name: "_stash._tcp.local",
+			lookup: func(context.Context, string, string, string) (string, []*net.SRV, error) {
+				return "", []*net.SRV{{Target: "backup", Port: 80, Priority: 20, Weight: 10},
+					{Target: "idle", Port: 80, Priority: 10, Weight: 0}, {Target: "main", Port: 80, Priority: 10, Weight: 100}}, nil
+			}}
+		urls, err := r.Resolve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://main:80", "http://idle:80", "http://backup:80"}, urls)
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		r := &SRVResolver{Name: "_stash._tcp.local",
+			lookup: func(context.Context, string, string, string) (string, []*net.SRV, error) {
+				return "", nil, errors.New("no such host")
+			}}
+		_, err := r.Resolve(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "lookup SRV _stash._tcp.local")
+	})
+}
+
+func TestParseSRVURL(t *testing.T) {
+	tests := []struct {
+		in, name, scheme string
+		isSRV            bool
+	}{
+		{in: "srv://_stash._tcp.example.com", name: "_stash._tcp.example.com", scheme: "http", isSRV: true},
+		{in: "SRV+HTTPS://_stash._tcp.example.com/", name: "_stash._tcp.example.com", scheme: "https", isSRV: true},
+		{in: "http://localhost:8484"},
+		{in: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			r := parseSRVURL(tt.in)
+			if !tt.isSRV {
+				assert.Nil(t, r)
+				return
+			}
+			require.NotNil(t, r)
+			assert.Equal(t, tt.name, r.Name)
+			assert.Equal(t, tt.scheme, r.Scheme)
+		})
+	}
+}
+
+func TestEndpointResolver(t *testing.T) {
+	t.Run("caches until interval expires", func(t *testing.T) {
+		var calls atomic.Int32
+		e := &endpointResolver{interval: time.Hour, resolver: ResolverFunc(func(context.Context) ([]string, error) {
+			calls.Add(1)
+			return []string{"http://one/", "http://two"}, nil
+		})}
+		for range 3 {
+			u, err := e.endpoint(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "http://one", u)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("keeps stale endpoints on failure", func(t *testing.T) {
+		fail := false
+		e := &endpointResolver{interval: time.Nanosecond, resolver: ResolverFunc(func(context.Context) ([]string, error) {
+			if fail {
+				return nil, errors.New("dns down")
+			}
+			return []string{"http://one"}, nil
+		})}
+		u, err := e.endpoint(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "http://one", u)
+
+		fail = true
+		time.Sleep(time.Millisecond)
+		u, err = e.endpoint(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "http://one", u)
+	})
+
+	t.Run("fallback and errors", func(t *testing.T) {
+		failing := ResolverFunc(func(context.Context) ([]string, error) { return nil, errors.New("dns down") })
+		e := &endpointResolver{interval: time.Minute, resolver: failing, fallback: "http://static"}
+		u, err := e.endpoint(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "http://static", u)
+
+		e = &endpointResolver{interval: time.Minute, resolver: failing}
+		_, err = e.endpoint(context.Background())
+		require.EqualError(t, err, "resolve endpoints: dns down")
+
+		empty := ResolverFunc(func(context.Context) ([]string, error) { return nil, nil })
+		e = &endpointResolver{interval: time.Minute, resolver: empty}
+		_, err = e.endpoint(context.Background())
+		require.EqualError(t, err, "resolve endpoints: no endpoints found")
+	})
+}
+
+func TestEndpointResolver_SingleRefresh(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	e := &endpointResolver{interval: time.Hour, resolver: ResolverFunc(func(context.Context) ([]string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return []string{"http://a"}, nil
+	})}
+
+	results := make(chan string, 10)
+	go func() {
+		u, err := e.endpoint(context.Background())
+		assert.NoError(t, err)
+		results <- u
+	}()
+	<-started
+	for range 9 {
+		go func() {
+			u, err := e.endpoint(context.Background())
+			assert.NoError(t, err)
+			results <- u
+		}()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.endpoint(ctx)
+	require.ErrorIs(t, err, context.Canceled, "waiting for the refresh honors context")
+
+	close(release)
+	for range 10 {
+		assert.Equal(t, "http://a", <-results)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_WithResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kv/app/key":
+			_, _ = w.Write([]byte("resolved"))
+		case "/ping":
+			_, _ = w.Write([]byte("pong"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("resolver without base url", func(t *testing.T) {
+		c, err := New("", WithRetry(0, 0), WithResolver(ResolverFunc(func(context.Context) ([]string, error) {
+			return []string{srv.URL}, nil
+		})))
+		require.NoError(t, err)
+
+		val, err := c.Get(context.Background(), "app/key")
+		require.NoError(t, err)
+		assert.Equal(t, "resolved", val)
+		require.NoError(t, c.Ping(context.Background()))
+	})
+
+	t.Run("fails over to next endpoint", func(t *testing.T) {
+		dead := httptest.NewServer(http.NotFoundHandler())
+		dead.Close()
+		c, err := New("", WithRetry(0, 0), WithResolver(ResolverFunc(func(context.Context) ([]string, error) {
+			return []string{dead.URL, srv.URL}, nil
+		})))
+		require.NoError(t, err)
+
+		require.NoError(t, c.Set(context.Background(), "app/key", "value"))
+		assert.Equal(t, []string{srv.URL, dead.URL}, c.endpoints.urls, "failed endpoint moved to the end")
+		val, err := c.Get(context.Background(), "app/key")
+		require.NoError(t, err)
+		assert.Equal(t, "resolved", val)
+	})
+
+	t.Run("all endpoints down", func(t *testing.T) {
+		dead1, dead2 := httptest.NewServer(http.NotFoundHandler()), httptest.NewServer(http.NotFoundHandler())
+		dead1.Close()
+		dead2.Close()
+		c, err := New("", WithRetry(0, 0), WithResolver(ResolverFunc(func(context.Context) ([]string, error) {
+			return []string{dead1.URL, dead2.URL}, nil
+		})))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/key")
+		require.Error(t, err)
+	})
+
+	t.Run("resolver error surfaces", func(t *testing.T) {
+		c, err := New("", WithRetry(0, 0), WithResolver(ResolverFunc(func(context.Context) ([]string, error) {
+			return nil, errors.New("dns down")
+		})))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/key")
+		require.ErrorContains(t, err, "resolve endpoints: dns down")
+	})
+
+	t.Run("srv base url", func(t *testing.T) {
+		c, err := New("srv://_stash._tcp.example.com", WithResolveInterval(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, c.baseURL)
+		require.NotNil(t, c.endpoints)
+		assert.Equal(t, time.Minute, c.endpoints.interval)
+		srvRes, ok := c.endpoints.resolver.(*SRVResolver)
+		require.True(t, ok)
+		assert.Equal(t, "_stash._tcp.example.com", srvRes.Name)
+	})
+
+	t.Run("empty srv name", func(t *testing.T) {
+		_, err := New("srv://")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,223 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-pkgz/requester/middleware"
+)
+
+// defaults of retries, the delay doubles with every attempt up to the max delay
+const (
+	defaultRetryMaxDelay = 30 * time.Second
+	defaultRetryJitter   = 0.5
+	retryBudgetWindow    = 10 * time.Second
+	retryBudgetMin       = 10 // retries allowed in every window, so a quiet client can still retry
+)
+
+// RetryOption configures retries set by WithRetry.
+type RetryOption func(*retryPolicy)
+
+// RetryMaxDelay caps the delay between attempts (default 30s). A Retry-After of the server above the cap
+// is not waited for, the response is returned instead.
+func RetryMaxDelay(d time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.maxDelay = d
+	}
+}
+
+// RetryJitter sets the random part of the delay, from 0 for exact delays to 1 for a delay anywhere
+// between zero and the backoff (default 0.5), so clients failing together don't retry together.
+func RetryJitter(factor float64) RetryOption {
+	return func(p *retryPolicy) {
+		p.jitter = min(max(factor, 0), 1)
+	}
+}
+
+// RetryOnStatus retries responses with the given status codes too, e.g. 429. By default network errors
+// and 5xx responses are retried.
+func RetryOnStatus(codes ...int) RetryOption {
+	return func(p *retryPolicy) {
+		for _, code := range codes {
+			p.statuses[code] = true
+		}
+	}
+}
+
+// NoRetryOnStatus doesn't retry responses with the given status codes, e.g. 501.
+func NoRetryOnStatus(codes ...int) RetryOption {
+	return func(p *retryPolicy) {
+		for _, code := range codes {
+			p.statuses[code] = false
+		}
+	}
+}
+
+// RetryBudget limits retries of the client to the ratio of its requests in the last 10 seconds, plus
+// 10 retries, e.g. 0.2 for at most 20% more load on a failing server. Without a budget every failing
+// request is retried up to the attempts of WithRetry.
+func RetryBudget(ratio float64) RetryOption {
+	return func(p *retryPolicy) {
+		p.budget = &retryBudget{ratio: ratio}
+	}
+}
+
+// retryPolicy retries failing requests with exponential backoff and jitter.
+type retryPolicy struct {
+	attempts int
+	delay    time.Duration // delay before the first retry, doubled for every next one
+	maxDelay time.Duration
+	jitter   float64
+	statuses map[int]bool // status code -> retry, overrides the default of retrying 5xx
+	budget   *retryBudget // nil if retries are not limited
+}
+
+// newRetryPolicy makes the policy of WithRetry.
+func newRetryPolicy(attempts int, delay time.Duration, opts ...RetryOption) *retryPolicy {
+	p := &retryPolicy{attempts: attempts, delay: delay, maxDelay: defaultRetryMaxDelay, jitter: defaultRetryJitter,
+		statuses: map[int]bool{}}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// middleware returns the retry middleware. Requests with a body are retried only if the body can be
+// read again, which is the case for all requests of the client.
+func (p *retryPolicy) middleware() middleware.RoundTripperHandler {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts := p.attempts
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				attempts = 1
+			}
+			p.budget.request()
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= attempts || req.Context().Err() != nil || !p.retryable(resp, err) {
+					return resp, err
+				}
+				wait := p.backoff(attempt)
+				if resp != nil {
+					// the server knows better when to come back, but no later than the max delay
+					if after := parseRetryAfter(resp.Header); after > p.maxDelay {
+						return resp, nil
+					} else if after > wait {
+						wait = after
+					}
+				}
+				if !p.budget.retry() {
+					return resp, err
+				}
+				if resp != nil {
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+					_ = resp.Body.Close()
+				}
+
+				timer := time.NewTimer(wait)
+				select {
+				case <-req.Context().Done():
+					timer.Stop()
+					return nil, fmt.Errorf("retry canceled: %w", req.Context().Err())
+				case <-timer.C:
+				}
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, fmt.Errorf("failed to reset request body: %w", err)
+					}
+					req.Body = body
+				}
+			}
+		})
+	}
+}
+
+// retryable tells if the attempt failed with a network error or a status to retry. Requests rejected
+// by an open circuit breaker are not retried.
+func (p *retryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+	if retry, ok := p.statuses[resp.StatusCode]; ok {
+		return retry
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// backoff returns the delay after the attempt, doubled for every attempt, capped and randomized by jitter.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	d := p.delay
+	for i := 1; i < attempt && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	if p.jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.jitter * float64(d)) //nolint:gosec // jitter doesn't need crypto randomness
+	}
+	return d
+}
+
+// retryBudget counts requests and retries in fixed windows. Methods are safe on nil budget, which
+// allows all retries.
+type retryBudget struct {
+	ratio float64
+
+	mu       sync.Mutex
+	start    time.Time // start of the current window
+	requests int
+	retries  int
+}
+
+// request counts a request made by the client.
+func (b *retryBudget) request() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	b.requests++
+}
+
+// retry takes a retry from the budget, false if none is left in the window.
+func (b *retryBudget) retry() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	if b.retries >= b.limit() {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+// remaining returns retries left in the current window, -1 on nil budget.
+func (b *retryBudget) remaining() int {
+	if b == nil {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	return max(b.limit()-b.retries, 0)
+}
+
+func (b *retryBudget) limit() int {
+	return retryBudgetMin + int(b.ratio*float64(b.requests))
+}
+
+// roll starts a new window once the current one is over.
+func (b *retryBudget) roll() {
+	if now := time.Now(); now.Sub(b.start) >= retryBudgetWindow {
+		b.start, b.requests, b.retries = now, 0, 0
+	}
+}
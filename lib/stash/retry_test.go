@@ -0,0 +1,176 @@
+package stash
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Retry(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	failures := int32(2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+	reset := func(code int, fail int32) {
+		calls.Store(0)
+		status, failures = code, fail
+	}
+
+	t.Run("5xx retried", func(t *testing.T) {
+		reset(http.StatusServiceUnavailable, 2)
+		c, err := New(srv.URL, WithRetry(3, time.Millisecond))
+		require.NoError(t, err)
+		val, err := c.Get(context.Background(), "app/db")
+		require.NoError(t, err)
+		assert.Equal(t, "value", val)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		reset(http.StatusBadGateway, 5)
+		c, err := New(srv.URL, WithRetry(2, time.Millisecond))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/db")
+		var respErr *ResponseError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusBadGateway, respErr.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("status rules", func(t *testing.T) {
+		reset(http.StatusTooManyRequests, 1)
+		c, err := New(srv.URL, WithRetry(3, time.Millisecond))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/db")
+		require.Error(t, err, "429 is not retried by default")
+		assert.Equal(t, int32(1), calls.Load())
+
+		reset(http.StatusTooManyRequests, 1)
+		c, err = New(srv.URL, WithRetry(3, time.Millisecond, RetryOnStatus(http.StatusTooManyRequests)))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/db")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+
+		reset(http.StatusNotImplemented, 1)
+		c, err = New(srv.URL, WithRetry(3, time.Millisecond, NoRetryOnStatus(http.StatusNotImplemented)))
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), "app/db")
+		require.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("body is sent again", func(t *testing.T) {
+		var bodies []string
+		bodySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if len(bodies) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer bodySrv.Close()
+		c, err := New(bodySrv.URL, WithRetry(2, time.Millisecond))
+		require.NoError(t, err)
+		require.NoError(t, c.Set(context.Background(), "app/db", "value"))
+		assert.Equal(t, []string{"value", "value"}, bodies)
+	})
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	retryAfter := "1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(2, time.Millisecond))
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = c.Get(context.Background(), "app/db")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "waits for Retry-After instead of the backoff")
+
+	calls.Store(0)
+	retryAfter = "60"
+	c, err = New(srv.URL, WithRetry(2, time.Millisecond, RetryMaxDelay(time.Second)))
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), "app/db")
+	var respErr *ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, time.Minute, respErr.RetryAfter)
+	assert.Equal(t, int32(1), calls.Load(), "Retry-After above the max delay is not waited for")
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := newRetryPolicy(5, 100*time.Millisecond, RetryJitter(0), RetryMaxDelay(time.Second))
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3))
+	assert.Equal(t, time.Second, p.backoff(5), "capped by the max delay")
+
+	p = newRetryPolicy(5, 100*time.Millisecond, RetryJitter(0.5))
+	for range 100 {
+		d := p.backoff(2)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 200*time.Millisecond)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := &retryBudget{ratio: 0.5}
+	for range 20 {
+		b.request()
+	}
+	assert.Equal(t, 20, b.remaining(), "10 retries plus half of the requests")
+	for range 20 {
+		require.True(t, b.retry())
+	}
+	assert.False(t, b.retry())
+	assert.Equal(t, 0, b.remaining())
+
+	b.start = time.Now().Add(-retryBudgetWindow)
+	assert.Equal(t, retryBudgetMin, b.remaining(), "new window")
+
+	var nilBudget *retryBudget
+	assert.True(t, nilBudget.retry())
+	assert.Equal(t, -1, nilBudget.remaining())
+
+	t.Run("client stops retrying", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		c, err := New(srv.URL, WithRetry(3, time.Millisecond, RetryBudget(0)))
+		require.NoError(t, err)
+		for range 10 {
+			_, _ = c.Get(context.Background(), "app/db")
+		}
+		assert.Equal(t, int32(10+retryBudgetMin), calls.Load(), "only the minimum of retries is made")
+		assert.Equal(t, 0, c.Health().RetryBudget)
+	})
+}
@@ -15,7 +15,7 @@ import (
 // Event represents a key change event from the server.
 type Event struct {
 	Key       string `json:"key"`
-	Action    string `json:"action"` // create, update, delete
+	Action    string `json:"action"` // create, update, delete, or reset with an empty key
 	Timestamp string `json:"timestamp"`
 }
 
@@ -69,7 +69,12 @@ func (c *Client) SubscribeAll(ctx context.Context) (*Subscription, error) {
 
 // subscribe creates an SSE subscription for the given path.
 func (c *Client) subscribe(ctx context.Context, path string) (*Subscription, error) {
-	u, err := url.JoinPath(c.baseURL, "kv", "subscribe", path)
+	// with a resolver the endpoint is picked once; reconnects keep using it
+	base, err := c.base(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.JoinPath(base, "kv", "subscribe", path)
 	if err != nil {
 		return nil, fmt.Errorf("build URL: %w", err)
 	}
@@ -89,9 +94,12 @@ func (c *Client) subscribe(ctx context.Context, path string) (*Subscription, err
 		cancel: cancel,
 	}
 
-	// create SSE client with the same HTTP client (to inherit auth headers via requester)
+	// create SSE client with the same HTTP client (to inherit auth headers via requester).
+	// the request timeout would cut the long-lived stream, losing events sent until it reconnects
+	httpClient := c.requester.Client()
+	httpClient.Timeout = 0
 	sseClient := &sse.Client{
-		HTTPClient: c.requester.Client(),
+		HTTPClient: httpClient,
 		Backoff: sse.Backoff{
 			InitialInterval: time.Second,
 			MaxInterval:     30 * time.Second,
@@ -103,16 +111,31 @@ func (c *Client) subscribe(ctx context.Context, path string) (*Subscription, err
 	conn.SubscribeEvent("change", func(e sse.Event) {
 		var ev Event
 		if err := json.Unmarshal([]byte(e.Data), &ev); err != nil {
-			select {
-			case sub.errors <- fmt.Errorf("parse event: %w", err):
-			default:
-			}
+			sub.sendErr(fmt.Errorf("parse event: %w", err))
 			return
 		}
-		select {
-		case sub.events <- ev:
-		case <-ctx.Done():
+		sub.send(ctx, ev)
+	})
+	// servers coalescing events send batches of changes, delivered one by one
+	conn.SubscribeEvent("changes", func(e sse.Event) {
+		var evs []Event
+		if err := json.Unmarshal([]byte(e.Data), &evs); err != nil {
+			sub.sendErr(fmt.Errorf("parse events: %w", err))
+			return
+		}
+		for _, ev := range evs {
+			sub.send(ctx, ev)
+		}
+	})
+
+	// reconnects resume from the last event id, the server sends reset if some events can't be replayed
+	conn.SubscribeEvent("reset", func(e sse.Event) {
+		var ev Event
+		if err := json.Unmarshal([]byte(e.Data), &ev); err != nil {
+			sub.sendErr(fmt.Errorf("parse reset event: %w", err))
+			return
 		}
+		sub.send(ctx, Event{Action: "reset", Timestamp: ev.Timestamp})
 	})
 
 	// start connection in background
@@ -131,3 +154,19 @@ func (c *Client) subscribe(ctx context.Context, path string) (*Subscription, err
 
 	return sub, nil
 }
+
+// send delivers the event unless the subscription is closed.
+func (s *Subscription) send(ctx context.Context, ev Event) {
+	select {
+	case s.events <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// sendErr reports the error if the errors channel has room, dropping it otherwise.
+func (s *Subscription) sendErr(err error) {
+	select {
+	case s.errors <- err:
+	default:
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -104,6 +105,82 @@ func TestClient_SubscribePrefix(t *testing.T) {
 	}
 }
 
+func TestClient_SubscribePrefix_CoalescedChanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		events := []Event{{Key: "app/a", Action: "create"}, {Key: "app/b", Action: "delete"}}
+		data, _ := json.Marshal(events)
+		_, _ = w.Write([]byte("event: changes\n"))
+		_, _ = w.Write([]byte("data: " + string(data) + "\n\n"))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sub, err := client.SubscribePrefix(ctx, "app")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	var got []Event
+	for len(got) < 2 {
+		select {
+		case ev := <-sub.Events():
+			got = append(got, ev)
+		case err := <-sub.Errors():
+			t.Fatalf("unexpected error: %v", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for events")
+		}
+	}
+	assert.Equal(t, []Event{{Key: "app/a", Action: "create"}, {Key: "app/b", Action: "delete"}}, got)
+}
+
+func TestClient_Subscribe_ResumeReset(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if conns.Add(1) == 1 {
+			_, _ = w.Write([]byte("id: abc-1\nevent: change\ndata: {\"key\":\"app/a\",\"action\":\"update\"}\n\n"))
+			return // connection drops
+		}
+		assert.Equal(t, "abc-1", r.Header.Get("Last-Event-ID"), "reconnect resumes from the last event")
+		_, _ = w.Write([]byte("event: reset\ndata: {\"timestamp\":\"2025-01-03T10:30:00Z\"}\n\n"))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, "app/a")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	var got []Event
+	for len(got) < 2 {
+		select {
+		case ev := <-sub.Events():
+			got = append(got, ev)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for events")
+		}
+	}
+	assert.Equal(t, []Event{{Key: "app/a", Action: "update"}, {Action: "reset", Timestamp: "2025-01-03T10:30:00Z"}}, got)
+}
+
 func TestClient_SubscribeAll(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "/kv/subscribe/*", r.URL.Path)
@@ -229,3 +306,36 @@ func TestClient_Subscribe_CloseTerminatesConnection(t *testing.T) {
 		t.Fatal("errors channel not closed after Close()")
 	}
 }
+
+func TestClient_SubscribeOutlivesTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		// the event comes after the client request timeout
+		time.Sleep(300 * time.Millisecond)
+		data, _ := json.Marshal(Event{Key: "app/db", Action: "update"})
+		_, _ = w.Write([]byte("event: change\ndata: " + string(data) + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sub, err := client.SubscribePrefix(ctx, "app")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	select {
+	case ev := <-sub.Events():
+		assert.Equal(t, "app/db", ev.Key)
+	case err := <-sub.Errors():
+		t.Fatalf("unexpected error: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for event")
+	}
+}
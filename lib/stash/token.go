@@ -0,0 +1,156 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pkgz/requester/middleware"
+)
+
+// tokenFileRecheck is how often a token file is read even if its modification time and size didn't change,
+// for rewrites within the resolution of file times.
+const tokenFileRecheck = time.Minute
+
+// signatureScheme is the Authorization scheme of requests signed by WithRequestSigning.
+const signatureScheme = "Stash-HMAC-SHA256"
+
+// WithTokenFunc sets a function returning the Bearer token, called for each request, e.g. to get
+// a token from a secret manager or identity provider. The function should cache the token itself.
+// An error fails the request, an empty token sends the request without credentials.
+func WithTokenFunc(fn func(ctx context.Context) (string, error)) Option {
+	return func(cfg *clientConfig) {
+		cfg.tokenFunc = fn
+	}
+}
+
+// WithTokenFile reads the Bearer token from a file and reads it again when the file changes, e.g. a projected
+// Kubernetes service account token rotated by the kubelet. Surrounding whitespace is trimmed.
+// The file is not read before the first request, so it may be written after the client is made.
+func WithTokenFile(path string) Option {
+	return func(cfg *clientConfig) {
+		tf := &tokenFile{path: path, now: time.Now}
+		cfg.tokenFunc = tf.token
+	}
+}
+
+// WithRequestSigning signs requests with the token instead of sending it, for servers started with
+// --auth.signed-requests. Each attempt of a request carries an HMAC-SHA256 signature of the method,
+// path, query, body, time and a random nonce, so a captured request can't be altered or replayed.
+// The token is set by WithToken, WithTokenFile or WithTokenFunc, it must be a named token of the server.
+func WithRequestSigning() Option {
+	return func(cfg *clientConfig) {
+		cfg.signRequests = true
+	}
+}
+
+// tokenFile is a token read from a file, cached until the file changes.
+type tokenFile struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.Mutex
+	value   string
+	modTime time.Time
+	size    int64
+	readAt  time.Time
+}
+
+// token returns the token of the file, read again if the file changed or was read too long ago.
+func (tf *tokenFile) token(context.Context) (string, error) {
+	info, err := os.Stat(tf.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to check token file: %w", err)
+	}
+
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+	now := tf.now()
+	if tf.value != "" && info.ModTime().Equal(tf.modTime) && info.Size() == tf.size && now.Sub(tf.readAt) < tokenFileRecheck {
+		return tf.value, nil
+	}
+	data, err := os.ReadFile(tf.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("token file %s is empty", tf.path)
+	}
+	tf.value, tf.modTime, tf.size, tf.readAt = value, info.ModTime(), info.Size(), now
+	return value, nil
+}
+
+// bearerToken returns middleware setting the Authorization header to the token returned by fn.
+func bearerToken(fn func(ctx context.Context) (string, error)) middleware.RoundTripperHandler {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			token, err := fn(req.Context())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get token: %w", err)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// signedRequests returns middleware signing each request attempt with the token returned by fn. It must
+// be placed inside the retry middleware, so retries get a new nonce and time.
+func signedRequests(fn func(ctx context.Context) (string, error)) middleware.RoundTripperHandler {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			token, err := fn(req.Context())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get token: %w", err)
+			}
+			if token == "" {
+				return next.RoundTrip(req)
+			}
+			var body []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				if body, err = io.ReadAll(req.Body); err != nil {
+					return nil, fmt.Errorf("failed to read request body: %w", err)
+				}
+				_ = req.Body.Close()
+			}
+			nonce := make([]byte, 16)
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, fmt.Errorf("failed to make nonce: %w", err)
+			}
+
+			signed := req.Clone(req.Context())
+			if body != nil {
+				signed.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			ts, n := time.Now().Unix(), hex.EncodeToString(nonce)
+			keyID := sha256.Sum256([]byte(token)) // token fingerprint, as listed by the server for admins
+			signed.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s, Timestamp=%d, Nonce=%s, Signature=%s", signatureScheme,
+				hex.EncodeToString(keyID[:16]), ts, n, signRequest(token, req.Method, req.URL.RequestURI(), ts, n, body)))
+			return next.RoundTrip(signed)
+		})
+	}
+}
+
+// signRequest returns the hex HMAC-SHA256 of the request with the token as key, over the newline-joined
+// method, request URI, unix time, nonce and hex SHA-256 of the body.
+func signRequest(token, method, uri string, timestamp int64, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(strings.Join([]string{method, uri, strconv.FormatInt(timestamp, 10), nonce,
+		hex.EncodeToString(bodySum[:])}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
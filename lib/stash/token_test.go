@@ -0,0 +1,165 @@
+package stash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authEcho returns a server answering gets with the Authorization header of the request.
+func authEcho(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_WithTokenFunc(t *testing.T) {
+	server := authEcho(t)
+	var calls atomic.Int32
+	tokens := []string{"first", "second", ""}
+	client, err := New(server.URL, WithRetry(0, 0), WithTokenFunc(func(context.Context) (string, error) {
+		return tokens[calls.Add(1)-1], nil
+	}))
+	require.NoError(t, err)
+
+	for _, want := range []string{"Bearer first", "Bearer second", ""} {
+		got, err := client.Get(t.Context(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	failing, err := New(server.URL, WithRetry(0, 0), WithTokenFunc(func(context.Context) (string, error) {
+		return "", errors.New("vault is down")
+	}))
+	require.NoError(t, err)
+	_, err = failing.Get(t.Context(), "key")
+	require.ErrorContains(t, err, "failed to get token: vault is down")
+
+	_, err = New(server.URL, WithToken("static"), WithTokenFile("/tmp/token"))
+	require.EqualError(t, err, "WithToken can't be combined with WithTokenFile or WithTokenFunc")
+}
+
+func TestClient_WithTokenFile(t *testing.T) {
+	server := authEcho(t)
+	path := filepath.Join(t.TempDir(), "token")
+	client, err := New(server.URL, WithRetry(0, 0), WithTokenFile(path))
+	require.NoError(t, err)
+	get := func() string {
+		got, err := client.Get(t.Context(), "key")
+		require.NoError(t, err)
+		return got
+	}
+
+	_, err = client.Get(t.Context(), "key")
+	require.ErrorContains(t, err, "failed to check token file", "file doesn't exist yet")
+
+	require.NoError(t, os.WriteFile(path, []byte("token-1\n"), 0o600))
+	assert.Equal(t, "Bearer token-1", get())
+
+	// rotated token with a new modification time, like a projected service account token
+	require.NoError(t, os.WriteFile(path, []byte("token-2\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	assert.Equal(t, "Bearer token-2", get())
+
+	require.NoError(t, os.WriteFile(path, []byte("  \n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = client.Get(t.Context(), "key")
+	require.ErrorContains(t, err, "is empty")
+}
+
+func TestTokenFile_Recheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("token-1"), 0o600))
+	mtime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	now := time.Now()
+	tf := &tokenFile{path: path, now: func() time.Time { return now }}
+	token, err := tf.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// same size and modification time, the change is missed until the recheck interval passes
+	require.NoError(t, os.WriteFile(path, []byte("token-2"), 0o600))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+	token, err = tf.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	now = now.Add(tokenFileRecheck)
+	token, err = tf.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+}
+
+func TestSignRequest(t *testing.T) {
+	// the same vector is checked by the server, both sides must sign the same way
+	sig := signRequest("secret-token", http.MethodPut, "/kv/app/name?format=text", 1736935200, "n1", []byte("value"))
+	assert.Equal(t, "209d8185dae75128d460e8e7d23c5f1772422236945faaf3342bb89fcb4f7950", sig)
+}
+
+func TestClient_WithRequestSigning(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	nonces := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		params, ok := strings.CutPrefix(r.Header.Get("Authorization"), signatureScheme+" ")
+		require.True(t, ok, "request is signed")
+		p := map[string]string{}
+		for _, kv := range strings.Split(params, ", ") {
+			name, value, _ := strings.Cut(kv, "=")
+			p[name] = value
+		}
+		keyID := sha256.Sum256([]byte("secret-token"))
+		assert.Equal(t, hex.EncodeToString(keyID[:16]), p["KeyId"])
+		ts, err := strconv.ParseInt(p["Timestamp"], 10, 64)
+		require.NoError(t, err)
+		assert.InDelta(t, time.Now().Unix(), ts, 5)
+		assert.False(t, nonces[p["Nonce"]], "nonce is not reused")
+		nonces[p["Nonce"]] = true
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, signRequest("secret-token", r.Method, r.RequestURI, ts, p["Nonce"], body), p["Signature"])
+
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // retried with a new signature
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s", body)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL+"/base", WithToken("secret-token"), WithRequestSigning(), WithRetry(2, time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, client.Set(t.Context(), "app/name", "value"))
+	_, err = client.Get(t.Context(), "app/name")
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, nonces, 3)
+	mu.Unlock()
+
+	_, err = New(server.URL, WithRequestSigning())
+	require.EqualError(t, err, "WithRequestSigning needs a token")
+}
@@ -0,0 +1,158 @@
+package stash
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// Txn collects changes of several keys applied atomically by Commit, all of them or none.
+// Create it with Client.Txn, a key can be changed once in a transaction.
+//
+//	_, err := client.Txn(ctx).
+//		Set("app/db/host", "db2").
+//		Set("app/db/port", "6432", stash.IfVersion(info.UpdatedAt)).
+//		Delete("app/db/legacy").
+//		Commit()
+type Txn struct {
+	client *Client
+	ctx    context.Context
+	ops    []txnOp
+	err    error // first error of the builder, returned by Commit
+}
+
+// TxnCond is a precondition of a transaction operation, see IfVersion and IfAbsent.
+type TxnCond func(op *txnOp)
+
+// TxnResult is the outcome of an operation of a committed transaction.
+type TxnResult struct {
+	Op      string    `json:"op"` // "set" or "delete"
+	Key     string    `json:"key"`
+	Created bool      `json:"created,omitempty"`
+	Version time.Time `json:"version,omitzero"` // new update time of a set key, as KeyInfo.UpdatedAt
+}
+
+// txnOp is an operation of the transaction request.
+type txnOp struct {
+	Op       string    `json:"op"`
+	Key      string    `json:"key"`
+	Value    string    `json:"value,omitempty"`
+	Encoding string    `json:"encoding,omitempty"`
+	Format   string    `json:"format,omitempty"`
+	Version  time.Time `json:"version,omitzero"`
+	Absent   bool      `json:"absent,omitempty"`
+}
+
+// IfVersion requires the key to exist and to be last updated at the time, as reported by Info or List
+// in KeyInfo.UpdatedAt. Commit fails with ErrConflict otherwise.
+func IfVersion(updatedAt time.Time) TxnCond {
+	return func(op *txnOp) { op.Version = updatedAt }
+}
+
+// IfAbsent requires the key not to exist, e.g. to create it only once. Commit fails with ErrConflict otherwise.
+func IfAbsent() TxnCond {
+	return func(op *txnOp) { op.Absent = true }
+}
+
+// Txn starts a transaction. Nothing is sent to the server until Commit.
+func (c *Client) Txn(ctx context.Context) *Txn {
+	return &Txn{client: c, ctx: ctx}
+}
+
+// Set stores a value with text format.
+func (t *Txn) Set(key, value string, conds ...TxnCond) *Txn {
+	return t.SetWithFormat(key, value, FormatText, conds...)
+}
+
+// SetWithFormat stores a value with the format. The value is encrypted if a ZK key is configured.
+func (t *Txn) SetWithFormat(key, value string, format Format, conds ...TxnCond) *Txn {
+	data := []byte(value)
+	if t.client.zkCrypto != nil {
+		encrypted, err := t.client.zkCrypto.Encrypt(data)
+		if err != nil {
+			t.fail(fmt.Errorf("failed to encrypt value of %q: %w", key, err))
+			return t
+		}
+		data = encrypted
+	}
+	op := txnOp{Op: "set", Key: key, Value: string(data), Format: format.String()}
+	if !utf8.Valid(data) {
+		op.Value, op.Encoding = base64.StdEncoding.EncodeToString(data), "base64"
+	}
+	return t.add(op, conds)
+}
+
+// Delete removes the key, Commit fails with ErrConflict if it doesn't exist.
+func (t *Txn) Delete(key string, conds ...TxnCond) *Txn {
+	return t.add(txnOp{Op: "delete", Key: key}, conds)
+}
+
+// Commit sends the transaction to the server. Returns results in the order of operations, or ErrConflict
+// if a precondition failed or a key to delete doesn't exist; nothing is changed then. Permissions are
+// checked for each key, ErrForbidden is returned if one of them can't be changed.
+func (t *Txn) Commit() ([]TxnResult, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if len(t.ops) == 0 {
+		return nil, errors.New("transaction has no operations")
+	}
+	base, err := t.client.base(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(struct {
+		Ops []txnOp `json:"ops"`
+	}{Ops: t.ops})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, base+"/kv/_txn", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.do(req, OpTxn)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	for _, op := range t.ops {
+		t.client.written(op.Key, resp)
+	}
+
+	var res struct {
+		Results []TxnResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction results: %w", err)
+	}
+	return res.Results, nil
+}
+
+// add appends the operation with its preconditions.
+func (t *Txn) add(op txnOp, conds []TxnCond) *Txn {
+	if op.Key == "" {
+		t.fail(errors.New("key is required"))
+		return t
+	}
+	for _, cond := range conds {
+		cond(&op)
+	}
+	t.ops = append(t.ops, op)
+	return t
+}
+
+// fail records the first error of the builder.
+func (t *Txn) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
@@ -0,0 +1,93 @@
+package stash
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Txn(t *testing.T) {
+	version := time.Date(2025, 5, 1, 10, 0, 0, 123e6, time.UTC)
+	var got struct {
+		Ops []txnOp `json:"ops"`
+	}
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			_, _ = w.Write([]byte("value"))
+			return
+		}
+		assert.Equal(t, "/kv/_txn", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.Ops[0].Key == "app/stale" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results":[{"op":"set","key":"app/db","version":"2025-06-01T10:00:00Z"},
+			{"op":"set","key":"app/bin","created":true,"version":"2025-06-01T10:00:00Z"},{"op":"delete","key":"app/old"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithRetry(0, 0), WithCache(time.Minute))
+	require.NoError(t, err)
+
+	t.Run("commits operations", func(t *testing.T) {
+		_, err := client.Get(t.Context(), "app/db")
+		require.NoError(t, err)
+		res, err := client.Txn(t.Context()).
+			SetWithFormat("app/db", `{"port":6432}`, FormatJSON, IfVersion(version)).
+			Set("app/bin", "\xff\x01", IfAbsent()).
+			Delete("app/old").
+			Commit()
+		require.NoError(t, err)
+
+		want := []txnOp{
+			{Op: "set", Key: "app/db", Value: `{"port":6432}`, Format: "json", Version: version},
+			{Op: "set", Key: "app/bin", Value: "/wE=", Encoding: "base64", Format: "text", Absent: true},
+			{Op: "delete", Key: "app/old"},
+		}
+		assert.Equal(t, want, got.Ops)
+		updated := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+		assert.Equal(t, []TxnResult{
+			{Op: "set", Key: "app/db", Version: updated},
+			{Op: "set", Key: "app/bin", Created: true, Version: updated},
+			{Op: "delete", Key: "app/old"},
+		}, res)
+
+		_, err = client.Get(t.Context(), "app/db")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), gets.Load(), "cached value dropped after commit")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := client.Txn(t.Context()).Set("app/stale", "v", IfVersion(version)).Commit()
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("builder errors", func(t *testing.T) {
+		_, err := client.Txn(t.Context()).Commit()
+		require.EqualError(t, err, "transaction has no operations")
+		_, err = client.Txn(t.Context()).Set("a", "1").Delete("").Commit()
+		require.EqualError(t, err, "key is required")
+	})
+
+	t.Run("zk encrypted values", func(t *testing.T) {
+		zk, err := New(server.URL, WithRetry(0, 0), WithZKKey("test-passphrase-1234"))
+		require.NoError(t, err)
+		_, err = zk.Txn(t.Context()).Set("app/db", "secret").Commit()
+		require.NoError(t, err)
+		require.Len(t, got.Ops, 1)
+		assert.True(t, strings.HasPrefix(got.Ops[0].Value, "$ZK$"))
+		assert.Empty(t, got.Ops[0].Encoding)
+	})
+}
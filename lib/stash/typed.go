@@ -0,0 +1,113 @@
+package stash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxParseErrorValue limits the value quoted in a ParseError
+const maxParseErrorValue = 32
+
+// ParseError is returned by typed getters when the value of a key can't be parsed as the requested type.
+type ParseError struct {
+	Key   string
+	Type  string // requested type, e.g. int, bool, duration or json
+	Value string // the value, truncated, empty for keys in a secrets path so errors can be logged
+	Err   error  // parse error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("stash: value of key %q is not a valid %s: %v", e.Key, e.Type, e.Err)
+	}
+	return fmt.Sprintf("stash: value %q of key %q is not a valid %s: %v", e.Value, e.Key, e.Type, e.Err)
+}
+
+// Unwrap returns the parse error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// GetInt retrieves a value by key as an integer, e.g. "8080". Surrounding whitespace is ignored.
+func (c *Client) GetInt(ctx context.Context, key string) (int, error) {
+	return getParsed(ctx, c, key, "int", strconv.Atoi)
+}
+
+// GetFloat retrieves a value by key as a floating point number, e.g. "0.25". Surrounding whitespace is ignored.
+func (c *Client) GetFloat(ctx context.Context, key string) (float64, error) {
+	return getParsed(ctx, c, key, "float", func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+// GetBool retrieves a value by key as a boolean. Accepts the values of strconv.ParseBool, like "true",
+// "false", "1" and "0", and yes, no, on and off in any case. Surrounding whitespace is ignored.
+func (c *Client) GetBool(ctx context.Context, key string) (bool, error) {
+	return getParsed(ctx, c, key, "bool", parseBool)
+}
+
+// GetDuration retrieves a value by key as a duration in time.ParseDuration format, e.g. "1m30s".
+// Surrounding whitespace is ignored.
+func (c *Client) GetDuration(ctx context.Context, key string) (time.Duration, error) {
+	return getParsed(ctx, c, key, "duration", time.ParseDuration)
+}
+
+// GetJSON retrieves a value by key and unmarshals it as JSON into target,
+// with the codec registered for FormatJSON.
+func (c *Client) GetJSON(ctx context.Context, key string, target any) error {
+	codec, err := codecFor(FormatJSON)
+	if err != nil {
+		return err
+	}
+	data, err := c.GetBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := codec.Unmarshal(data, target); err != nil {
+		return newParseError(key, "json", string(data), err)
+	}
+	return nil
+}
+
+// getParsed retrieves the value of the key and parses it, trimmed, with parse.
+func getParsed[T any](ctx context.Context, c *Client, key, typ string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	res, err := parse(strings.TrimSpace(value))
+	if err != nil {
+		return zero, newParseError(key, typ, value, err)
+	}
+	return res, nil
+}
+
+// newParseError makes a ParseError. The strconv wrapper is dropped from the error, as it repeats the value.
+func newParseError(key, typ, value string, err error) *ParseError {
+	if numErr := (*strconv.NumError)(nil); errors.As(err, &numErr) {
+		err = numErr.Err
+	}
+	switch {
+	case slices.Contains(strings.Split(key, "/"), "secrets"):
+		value = ""
+	case len(value) > maxParseErrorValue:
+		value = value[:maxParseErrorValue] + "..."
+	}
+	return &ParseError{Key: key, Type: typ, Value: value, Err: err}
+}
+
+// parseBool parses a boolean, with yes/no and on/off on top of strconv.ParseBool.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
@@ -0,0 +1,103 @@
+package stash
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_TypedGetters(t *testing.T) {
+	values := map[string]string{
+		"app/port":         "8080\n",
+		"app/ratio":        " 0.25 ",
+		"app/debug":        "On",
+		"app/disabled":     "false",
+		"app/timeout":      "1m30s",
+		"app/name":         "not-a-number-but-a-rather-long-value-to-truncate",
+		"app/config":       `{"host":"db","port":5432}`,
+		"secrets/app/port": "hunter2",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, ok := values[strings.TrimPrefix(r.URL.Path, "/kv/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(v))
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("valid values", func(t *testing.T) {
+		port, err := c.GetInt(ctx, "app/port")
+		require.NoError(t, err)
+		assert.Equal(t, 8080, port)
+
+		ratio, err := c.GetFloat(ctx, "app/ratio")
+		require.NoError(t, err)
+		assert.InDelta(t, 0.25, ratio, 1e-9)
+
+		debug, err := c.GetBool(ctx, "app/debug")
+		require.NoError(t, err)
+		assert.True(t, debug)
+		disabled, err := c.GetBool(ctx, "app/disabled")
+		require.NoError(t, err)
+		assert.False(t, disabled)
+
+		timeout, err := c.GetDuration(ctx, "app/timeout")
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Second, timeout)
+
+		var cfg struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		}
+		require.NoError(t, c.GetJSON(ctx, "app/config", &cfg))
+		assert.Equal(t, "db", cfg.Host)
+		assert.Equal(t, 5432, cfg.Port)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		_, err := c.GetInt(ctx, "app/name")
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, "app/name", parseErr.Key)
+		assert.Equal(t, "int", parseErr.Type)
+		require.ErrorIs(t, err, strconv.ErrSyntax)
+		assert.EqualError(t, err,
+			`stash: value "not-a-number-but-a-rather-long-v..." of key "app/name" is not a valid int: invalid syntax`)
+
+		_, err = c.GetBool(ctx, "app/port")
+		assert.EqualError(t, err, `stash: value "8080\n" of key "app/port" is not a valid bool: invalid syntax`)
+
+		_, err = c.GetDuration(ctx, "app/port")
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, "duration", parseErr.Type)
+
+		var target map[string]any
+		err = c.GetJSON(ctx, "app/name", &target)
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, "json", parseErr.Type)
+	})
+
+	t.Run("secret values are not quoted", func(t *testing.T) {
+		_, err := c.GetInt(ctx, "secrets/app/port")
+		assert.EqualError(t, err, `stash: value of key "secrets/app/port" is not a valid int: invalid syntax`)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := c.GetInt(ctx, "app/missing")
+		require.ErrorIs(t, err, ErrNotFound)
+		var target any
+		require.ErrorIs(t, c.GetJSON(ctx, "app/missing", &target), ErrNotFound)
+	})
+}
@@ -0,0 +1,83 @@
+package stash
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// watchBackoff is the delay between reconnects of a watch after its connection failed,
+// doubled on each failed attempt up to the max.
+var watchBackoff = struct {
+	initial, max time.Duration
+}{initial: time.Second, max: 30 * time.Second}
+
+// Watch delivers changes of the keys under the prefix, or of all keys with an empty prefix, until ctx is canceled.
+// The returned channel is closed when ctx is done.
+//
+// Unlike subscriptions, a watch survives connections the server refused, e.g. with 503 during a restart,
+// or 401 while a token is rotated: it reconnects with exponential backoff, resolving the endpoint again
+// with a resolver set. Changes made while disconnected are lost, so a reconnect delivers an event with
+// Action "reset" and an empty key, the caller should reload the watched keys on it.
+func (c *Client) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	path := "*"
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		path = prefix + "/*"
+	}
+	sub, err := c.subscribe(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan Event, 16)
+	go c.watch(ctx, path, sub, events)
+	return events, nil
+}
+
+// watch forwards events of the subscription and replaces it with a new one each time its connection ends.
+func (c *Client) watch(ctx context.Context, path string, sub *Subscription, events chan<- Event) {
+	defer close(events)
+	send := func(ev Event) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	delay := watchBackoff.initial
+	for {
+		started := time.Now()
+		for ev := range sub.Events() {
+			if !send(ev) {
+				sub.Close()
+				return
+			}
+		}
+		sub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		// a connection which lasted a while was fine, start the backoff over
+		if time.Since(started) > watchBackoff.max {
+			delay = watchBackoff.initial
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, watchBackoff.max)
+
+		next, err := c.subscribe(ctx, path)
+		if err != nil {
+			continue // the closed subscription ends the next round at once, retried after the delay
+		}
+		sub = next
+		if !send(Event{Action: "reset", Timestamp: time.Now().UTC().Format(time.RFC3339)}) {
+			sub.Close()
+			return
+		}
+	}
+}
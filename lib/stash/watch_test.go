@@ -0,0 +1,112 @@
+package stash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Watch(t *testing.T) {
+	paths := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		data, _ := json.Marshal(Event{Key: "app/db", Action: "update", Timestamp: "2025-01-03T10:30:00Z"})
+		_, _ = w.Write([]byte("event: change\ndata: " + string(data) + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		prefix, path string
+	}{
+		{prefix: "app", path: "/kv/subscribe/app/*"},
+		{prefix: "app/", path: "/kv/subscribe/app/*"},
+		{prefix: "", path: "/kv/subscribe/*"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.prefix, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			events, err := client.Watch(ctx, tc.prefix)
+			require.NoError(t, err)
+
+			select {
+			case ev := <-events:
+				assert.Equal(t, Event{Key: "app/db", Action: "update", Timestamp: "2025-01-03T10:30:00Z"}, ev)
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for event")
+			}
+			assert.Equal(t, tc.path, <-paths)
+
+			cancel()
+			select {
+			case _, ok := <-events:
+				assert.False(t, ok, "events channel is closed with the context")
+			case <-time.After(time.Second):
+				t.Fatal("events channel not closed")
+			}
+		})
+	}
+}
+
+func TestClient_Watch_Reconnect(t *testing.T) {
+	backoff := watchBackoff
+	watchBackoff.initial, watchBackoff.max = 10*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { watchBackoff = backoff })
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first connections are refused, like by a restarting server
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		data, _ := json.Marshal(Event{Key: "app/cfg", Action: "create"})
+		_, _ = w.Write([]byte("event: change\ndata: " + string(data) + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client, err := New(server.URL, WithRetry(0, 0))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := client.Watch(ctx, "app")
+	require.NoError(t, err)
+
+	var got []Event
+	for len(got) < 3 {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for events, got %+v", got)
+		}
+	}
+	assert.Equal(t, "reset", got[0].Action, "reconnect after the first refused connection")
+	assert.Empty(t, got[0].Key)
+	assert.NotEmpty(t, got[0].Timestamp)
+	assert.Equal(t, "reset", got[1].Action)
+	assert.Equal(t, Event{Key: "app/cfg", Action: "create"}, got[2])
+	assert.Equal(t, int32(3), calls.Load())
+
+	// the watch is done before the backoff is restored
+	cancel()
+	for range events {
+	}
+}
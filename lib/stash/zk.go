@@ -34,22 +34,32 @@ func IsZKEncrypted(value []byte) bool {
 }
 
 // IsValidZKPayload checks if a ZK value has valid format.
-// Returns true if value has $ZK$ prefix followed by valid base64 of sufficient length.
+// Returns true if value has $ZK$ prefix followed by canonical base64 of sufficient length.
 // This validates format only, not cryptographic correctness (zero-knowledge preserved).
 func IsValidZKPayload(value []byte) bool {
+	_, err := decodeZKPayload(value)
+	return err == nil
+}
+
+// decodeZKPayload returns the decoded salt || nonce || ciphertext of a ZK value. Base64 with line breaks or
+// non-zero padding bits is rejected, the decoder would accept it but the payload isn't what Encrypt makes.
+func decodeZKPayload(value []byte) ([]byte, error) {
 	if !IsZKEncrypted(value) {
-		return false
+		return nil, ErrZKDecryptionFailed
 	}
-	encoded := value[len(zkPrefix):]
-
-	// decode base64
-	decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+	encoded := string(value[len(zkPrefix):])
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("base64 decode: %w", err)
+	}
+	if base64.StdEncoding.EncodeToString(decoded) != encoded {
+		return nil, errors.New("base64 decode: not canonical encoding")
 	}
-
 	// check minimum size: salt(16) + nonce(12) + tag(16) = 44 bytes
-	return len(decoded) >= zkMinDataSize
+	if len(decoded) < zkMinDataSize {
+		return nil, ErrZKDecryptionFailed
+	}
+	return decoded, nil
 }
 
 // ZKCrypto handles client-side zero-knowledge encryption using AES-256-GCM with Argon2id key derivation.
@@ -111,21 +121,9 @@ func (z *ZKCrypto) Encrypt(plaintext []byte) ([]byte, error) {
 
 // Decrypt decrypts a ZK-encrypted value.
 func (z *ZKCrypto) Decrypt(encrypted []byte) ([]byte, error) {
-	// check and remove prefix
-	if !IsZKEncrypted(encrypted) {
-		return nil, ErrZKDecryptionFailed
-	}
-	encoded := encrypted[len(zkPrefix):]
-
-	// decode base64
-	decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+	decoded, err := decodeZKPayload(encrypted)
 	if err != nil {
-		return nil, fmt.Errorf("base64 decode: %w", err)
-	}
-
-	// check minimum size
-	if len(decoded) < zkMinDataSize {
-		return nil, ErrZKDecryptionFailed
+		return nil, err
 	}
 
 	// extract salt, nonce, ciphertext
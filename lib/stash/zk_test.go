@@ -1,6 +1,7 @@
 package stash
 
 import (
+	"encoding/base64"
 	"os"
 	"testing"
 
@@ -38,6 +39,7 @@ func TestIsValidZKPayload(t *testing.T) {
 	require.NoError(t, err)
 	validEncrypted, err := zk.Encrypt([]byte("test data"))
 	require.NoError(t, err)
+	withNewline := append(append([]byte{}, validEncrypted[:20]...), append([]byte("\n"), validEncrypted[20:]...)...)
 
 	tests := []struct {
 		name     string
@@ -53,6 +55,7 @@ func TestIsValidZKPayload(t *testing.T) {
 		{"zk prefix with invalid base64", []byte("$ZK$not-valid-base64!!!"), false},
 		{"zk prefix with newlines", []byte("$ZK$aGVs\nbG8="), false},
 		{"valid zk encrypted value", validEncrypted, true},
+		{"valid zk encrypted value with newline", withNewline, false},
 	}
 
 	for _, tc := range tests {
@@ -276,3 +279,23 @@ func TestZKCrypto_DecryptPythonFixture(t *testing.T) {
 	assert.Equal(t, string(expectedPlaintext), string(decrypted))
 	t.Logf("successfully decrypted Python fixture: %s", string(decrypted))
 }
+
+func FuzzDecodeZKPayload(f *testing.F) {
+	zk, err := NewZKCrypto([]byte("test-passphrase-min-16"))
+	require.NoError(f, err)
+	valid, err := zk.Encrypt([]byte("test data"))
+	require.NoError(f, err)
+	for _, seed := range [][]byte{valid, []byte("$ZK$"), []byte("$ZK$aGVsbG8="), []byte("$ZK$not-valid-base64!!!"),
+		append(append([]byte{}, valid[:20]...), append([]byte("\n"), valid[20:]...)...)} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		decoded, err := decodeZKPayload(value)
+		assert.Equal(t, err == nil, IsValidZKPayload(value))
+		if err != nil {
+			return
+		}
+		assert.GreaterOrEqual(t, len(decoded), zkMinDataSize)
+		assert.Equal(t, string(value), zkPrefix+base64.StdEncoding.EncodeToString(decoded), "payload is canonical")
+	})
+}
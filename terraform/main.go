@@ -0,0 +1,27 @@
+// Command terraform-provider-stash is the terraform provider for stash, managing keys and tokens
+// declaratively through the lib/stash client.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
+
+	"github.com/umputun/stash/terraform/provider"
+)
+
+func main() {
+	debug := flag.Bool("debug", false, "run the provider with support for debuggers like delve")
+	flag.Parse()
+
+	var opts []tf6server.ServeOpt
+	if *debug {
+		opts = append(opts, tf6server.WithManagedDebug())
+	}
+	err := tf6server.Serve("registry.terraform.io/umputun/stash", func() tfprotov6.ProviderServer { return provider.New() }, opts...)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// keyData reads a single key, data source stash_key.
+type keyData struct{}
+
+var keyDataSchema = &tfprotov6.Schema{Block: &tfprotov6.SchemaBlock{
+	Description: "Reads a key from stash.",
+	Attributes: []*tfprotov6.SchemaAttribute{
+		{Name: "key", Type: tftypes.String, Required: true, Description: "Key name."},
+		{Name: "value", Type: tftypes.String, Computed: true, Description: "Value, decrypted when the provider has zk_key set."},
+		{Name: "format", Type: tftypes.String, Computed: true, Description: "Value format."},
+		{Name: "secret", Type: tftypes.Bool, Computed: true, Description: "Whether the key is a secret."},
+		{Name: "updated_at", Type: tftypes.String, Computed: true, Description: "Last update time, RFC3339."},
+	},
+}}
+
+func (keyData) schema() *tfprotov6.Schema { return keyDataSchema }
+
+func (keyData) read(ctx context.Context, c *stash.Client, config tftypes.Value) (tftypes.Value, error) {
+	o, err := toObject(config)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	key := o.str("key")
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("get %s: %w", key, err)
+	}
+	info, err := c.Info(ctx, key)
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("get info of %s: %w", key, err)
+	}
+	o["value"] = stringValue(value)
+	o["format"] = stringValue(info.Format)
+	o["secret"] = tftypes.NewValue(tftypes.Bool, info.Secret)
+	o["updated_at"] = stringValue(info.UpdatedAt.UTC().Format(time.RFC3339))
+	return o.value(keyDataSchema), nil
+}
+
+// keysData lists keys under a prefix with their metadata, data source stash_keys.
+type keysData struct{}
+
+var keyInfoType = tftypes.Object{AttributeTypes: map[string]tftypes.Type{
+	"key": tftypes.String, "format": tftypes.String, "size": tftypes.Number, "secret": tftypes.Bool,
+	"updated_at": tftypes.String,
+}}
+
+var keysDataSchema = &tfprotov6.Schema{Block: &tfprotov6.SchemaBlock{
+	Description: "Lists keys in stash, values are not included.",
+	Attributes: []*tfprotov6.SchemaAttribute{
+		{Name: "prefix", Type: tftypes.String, Optional: true, Description: "Key prefix, all keys if not set."},
+		{Name: "keys", Type: tftypes.List{ElementType: keyInfoType}, Computed: true,
+			Description: "Keys readable by the provider token with key, format, size, secret and updated_at."},
+	},
+}}
+
+func (keysData) schema() *tfprotov6.Schema { return keysDataSchema }
+
+func (keysData) read(ctx context.Context, c *stash.Client, config tftypes.Value) (tftypes.Value, error) {
+	o, err := toObject(config)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	infos, err := c.List(ctx, o.str("prefix"))
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("list keys: %w", err)
+	}
+	keys := make([]tftypes.Value, 0, len(infos))
+	for _, info := range infos {
+		keys = append(keys, tftypes.NewValue(keyInfoType, map[string]tftypes.Value{
+			"key":        stringValue(info.Key),
+			"format":     stringValue(info.Format),
+			"size":       tftypes.NewValue(tftypes.Number, info.Size),
+			"secret":     tftypes.NewValue(tftypes.Bool, info.Secret),
+			"updated_at": stringValue(info.UpdatedAt.UTC().Format(time.RFC3339)),
+		}))
+	}
+	o["keys"] = tftypes.NewValue(tftypes.List{ElementType: keyInfoType}, keys)
+	return o.value(keysDataSchema), nil
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyData_Read(t *testing.T) {
+	p, f := newTestProvider(t)
+	f.set("secrets/db/pass", "s3cr3t", "text")
+
+	resp, err := p.ReadDataSource(t.Context(), &tfprotov6.ReadDataSourceRequest{TypeName: "stash_key",
+		Config: dynamic(t, keyDataSchema, object{"key": stringValue("secrets/db/pass")})})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+	o := attributes(t, resp.State, keyDataSchema)
+	assert.Equal(t, "s3cr3t", o.str("value"))
+	assert.Equal(t, "text", o.str("format"))
+	assert.Equal(t, "2025-01-02T03:04:05Z", o.str("updated_at"))
+	var secret bool
+	require.NoError(t, o["secret"].As(&secret))
+	assert.True(t, secret)
+
+	resp, err = p.ReadDataSource(t.Context(), &tfprotov6.ReadDataSourceRequest{TypeName: "stash_key",
+		Config: dynamic(t, keyDataSchema, object{"key": stringValue("app/missing")})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "get app/missing: key not found", resp.Diagnostics[0].Detail)
+}
+
+func TestKeysData_Read(t *testing.T) {
+	p, f := newTestProvider(t)
+	f.set("app/a", "1", "text")
+	f.set("app/b", `{"x":1}`, "json")
+	f.set("other/c", "3", "text")
+
+	resp, err := p.ReadDataSource(t.Context(), &tfprotov6.ReadDataSourceRequest{TypeName: "stash_keys",
+		Config: dynamic(t, keysDataSchema, object{"prefix": stringValue("app/")})})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+	var keys []tftypes.Value
+	require.NoError(t, attributes(t, resp.State, keysDataSchema)["keys"].As(&keys))
+	require.Len(t, keys, 2)
+	second, err := toObject(keys[1])
+	require.NoError(t, err)
+	assert.Equal(t, "app/b", second.str("key"))
+	assert.Equal(t, "json", second.str("format"))
+	var size big.Float
+	require.NoError(t, second["size"].As(&size))
+	assert.Equal(t, "7", size.String())
+
+	resp, err = p.ReadDataSource(t.Context(), &tfprotov6.ReadDataSourceRequest{TypeName: "stash_keys",
+		Config: dynamic(t, keysDataSchema, object{})})
+	require.NoError(t, err)
+	require.NoError(t, attributes(t, resp.State, keysDataSchema)["keys"].As(&keys))
+	assert.Len(t, keys, 3, "all keys without a prefix")
+}
@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// keyResource manages a single key, stash_key. Changing the key name replaces the resource,
+// value and format are updated in place.
+type keyResource struct{}
+
+var keySchema = &tfprotov6.Schema{Block: &tfprotov6.SchemaBlock{
+	Description: "Manages a key in stash.",
+	Attributes: []*tfprotov6.SchemaAttribute{
+		{Name: "id", Type: tftypes.String, Computed: true, Description: "Same as key."},
+		{Name: "key", Type: tftypes.String, Required: true, Description: "Key name, e.g. app/db/host."},
+		{Name: "value", Type: tftypes.String, Required: true,
+			Description: "Value, encrypted on the client when the provider has zk_key set."},
+		{Name: "format", Type: tftypes.String, Optional: true, Computed: true,
+			Description: "Value format for syntax highlighting and validation, text by default."},
+	},
+}}
+
+func (keyResource) schema() *tfprotov6.Schema { return keySchema }
+
+func (keyResource) validate(config tftypes.Value) []*tfprotov6.Diagnostic {
+	o, err := toObject(config)
+	if err != nil {
+		return errorDiag("Invalid configuration", err)
+	}
+	if o.known("format") {
+		if _, err := stash.ParseFormat(o.str("format")); err != nil {
+			return []*tfprotov6.Diagnostic{attrDiag("format", "Invalid format", err.Error())}
+		}
+	}
+	return nil
+}
+
+func (keyResource) plan(prior, proposed tftypes.Value) (tftypes.Value, []*tftypes.AttributePath, error) {
+	o, err := toObject(proposed)
+	if err != nil {
+		return tftypes.Value{}, nil, err
+	}
+	if o["format"].IsNull() {
+		o["format"] = stringValue(stash.FormatText.String())
+	}
+	o["id"] = o["key"]
+	if prior.IsNull() {
+		return o.value(keySchema), nil, nil
+	}
+	p, err := toObject(prior)
+	if err != nil {
+		return tftypes.Value{}, nil, err
+	}
+	if changed(p, o, "key") {
+		return o.value(keySchema), []*tftypes.AttributePath{attrPath("key")}, nil
+	}
+	return o.value(keySchema), nil, nil
+}
+
+func (keyResource) apply(ctx context.Context, c *stash.Client, _, planned tftypes.Value) (tftypes.Value, error) {
+	o, err := toObject(planned)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	format, err := stash.ParseFormat(o.str("format"))
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("format: %w", err)
+	}
+	if err := c.SetWithFormat(ctx, o.str("key"), o.str("value"), format); err != nil {
+		return tftypes.Value{}, fmt.Errorf("set %s: %w", o.str("key"), err)
+	}
+	return planned, nil
+}
+
+func (keyResource) destroy(ctx context.Context, c *stash.Client, prior tftypes.Value) error {
+	o, err := toObject(prior)
+	if err != nil {
+		return err
+	}
+	if err := c.Delete(ctx, o.str("key")); err != nil && !errors.Is(err, stash.ErrNotFound) {
+		return fmt.Errorf("delete %s: %w", o.str("key"), err)
+	}
+	return nil
+}
+
+// read refreshes value and format, a key deleted outside terraform is removed from the state.
+func (keyResource) read(ctx context.Context, c *stash.Client, state tftypes.Value) (tftypes.Value, error) {
+	o, err := toObject(state)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	return readKey(ctx, c, o.str("key"))
+}
+
+func (keyResource) importState(ctx context.Context, c *stash.Client, id string) (tftypes.Value, error) {
+	v, err := readKey(ctx, c, id)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	if v.IsNull() {
+		return tftypes.Value{}, fmt.Errorf("key %q: %w", id, stash.ErrNotFound)
+	}
+	return v, nil
+}
+
+// readKey loads the key state, null if the key doesn't exist.
+func readKey(ctx context.Context, c *stash.Client, key string) (tftypes.Value, error) {
+	value, err := c.Get(ctx, key)
+	if errors.Is(err, stash.ErrNotFound) {
+		return tftypes.NewValue(keySchema.ValueType(), nil), nil
+	}
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("get %s: %w", key, err)
+	}
+	info, err := c.Info(ctx, key)
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("get info of %s: %w", key, err)
+	}
+	if info.Format == "" {
+		info.Format = stash.FormatText.String()
+	}
+	return object{"id": stringValue(key), "key": stringValue(key), "value": stringValue(value),
+		"format": stringValue(info.Format)}.value(keySchema), nil
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyResource_Lifecycle(t *testing.T) {
+	p, f := newTestProvider(t)
+	ctx := t.Context()
+
+	// create with the default format
+	config := dynamic(t, keySchema, object{"key": stringValue("app/db/host"), "value": stringValue("db1")})
+	plan, err := p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_key",
+		PriorState: nullDynamic(t, keySchema), ProposedNewState: config, Config: config})
+	require.NoError(t, err)
+	require.Empty(t, plan.Diagnostics)
+	planned := attributes(t, plan.PlannedState, keySchema)
+	assert.Equal(t, "text", planned.str("format"))
+	assert.Equal(t, "app/db/host", planned.str("id"))
+
+	applied, err := p.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{TypeName: "stash_key",
+		PriorState: nullDynamic(t, keySchema), PlannedState: plan.PlannedState, Config: config})
+	require.NoError(t, err)
+	require.Empty(t, applied.Diagnostics)
+	stored, ok := f.get("app/db/host")
+	require.True(t, ok)
+	assert.Equal(t, fakeKey{value: "db1", format: "text"}, stored)
+
+	// refresh picks up changes made outside terraform
+	f.set("app/db/host", "db2", "yaml")
+	read, err := p.ReadResource(ctx, &tfprotov6.ReadResourceRequest{TypeName: "stash_key", CurrentState: applied.NewState})
+	require.NoError(t, err)
+	require.Empty(t, read.Diagnostics)
+	state := attributes(t, read.NewState, keySchema)
+	assert.Equal(t, "db2", state.str("value"))
+	assert.Equal(t, "yaml", state.str("format"))
+
+	// value change is an update, key change a replacement
+	proposed := dynamic(t, keySchema, object{"id": stringValue("app/db/host"), "key": stringValue("app/db/host"),
+		"value": stringValue("db3"), "format": stringValue("yaml")})
+	plan, err = p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_key",
+		PriorState: read.NewState, ProposedNewState: proposed, Config: proposed})
+	require.NoError(t, err)
+	assert.Empty(t, plan.RequiresReplace)
+	applied, err = p.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{TypeName: "stash_key",
+		PriorState: read.NewState, PlannedState: plan.PlannedState, Config: proposed})
+	require.NoError(t, err)
+	require.Empty(t, applied.Diagnostics)
+	stored, _ = f.get("app/db/host")
+	assert.Equal(t, fakeKey{value: "db3", format: "yaml"}, stored)
+
+	proposed = dynamic(t, keySchema, object{"id": stringValue("app/db/host"), "key": stringValue("app/db/addr"),
+		"value": stringValue("db3"), "format": stringValue("yaml")})
+	plan, err = p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_key",
+		PriorState: applied.NewState, ProposedNewState: proposed, Config: proposed})
+	require.NoError(t, err)
+	require.Len(t, plan.RequiresReplace, 1)
+	assert.True(t, plan.RequiresReplace[0].Equal(tftypes.NewAttributePath().WithAttributeName("key")))
+	assert.Equal(t, "app/db/addr", attributes(t, plan.PlannedState, keySchema).str("id"))
+
+	// destroy, twice to check a missing key is fine
+	for range 2 {
+		applied, err = p.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{TypeName: "stash_key",
+			PriorState: read.NewState, PlannedState: nullDynamic(t, keySchema)})
+		require.NoError(t, err)
+		require.Empty(t, applied.Diagnostics)
+		assert.Nil(t, attributes(t, applied.NewState, keySchema))
+	}
+	_, ok = f.get("app/db/host")
+	assert.False(t, ok)
+
+	// refresh of a deleted key removes it from the state
+	read, err = p.ReadResource(ctx, &tfprotov6.ReadResourceRequest{TypeName: "stash_key", CurrentState: read.NewState})
+	require.NoError(t, err)
+	require.Empty(t, read.Diagnostics)
+	assert.Nil(t, attributes(t, read.NewState, keySchema))
+}
+
+func TestKeyResource_Validate(t *testing.T) {
+	p := New()
+	resp, err := p.ValidateResourceConfig(t.Context(), &tfprotov6.ValidateResourceConfigRequest{TypeName: "stash_key",
+		Config: dynamic(t, keySchema, object{"key": stringValue("a"), "value": stringValue("v"), "format": stringValue("xml")})})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Diagnostics)
+
+	resp, err = p.ValidateResourceConfig(t.Context(), &tfprotov6.ValidateResourceConfigRequest{TypeName: "stash_key",
+		Config: dynamic(t, keySchema, object{"key": stringValue("a"), "value": stringValue("v"), "format": stringValue("docx")})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "invalid format: docx", resp.Diagnostics[0].Detail)
+
+	resp, err = p.ValidateResourceConfig(t.Context(), &tfprotov6.ValidateResourceConfigRequest{TypeName: "stash_key",
+		Config: dynamic(t, keySchema, object{"key": stringValue("a"), "value": stringValue("v"), "format": unknownString()})})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Diagnostics, "unknown format is checked at apply")
+}
+
+func TestKeyResource_Import(t *testing.T) {
+	p, f := newTestProvider(t)
+	f.set("app/cfg", `{"a":1}`, "json")
+
+	resp, err := p.ImportResourceState(t.Context(), &tfprotov6.ImportResourceStateRequest{TypeName: "stash_key", ID: "app/cfg"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+	require.Len(t, resp.ImportedResources, 1)
+	o := attributes(t, resp.ImportedResources[0].State, keySchema)
+	assert.Equal(t, "app/cfg", o.str("id"))
+	assert.JSONEq(t, `{"a":1}`, o.str("value"))
+	assert.Equal(t, "json", o.str("format"))
+
+	resp, err = p.ImportResourceState(t.Context(), &tfprotov6.ImportResourceStateRequest{TypeName: "stash_key", ID: "app/missing"})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, `key "app/missing": key not found`, resp.Diagnostics[0].Detail)
+}
@@ -0,0 +1,406 @@
+// Package provider implements the stash terraform provider on top of the lib/stash client. It serves
+// the plugin protocol v6 directly, resource stash_key and stash_token, data sources stash_key and stash_keys.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// environment variables used when the provider block leaves an attribute unset
+const (
+	envEndpoint = "STASH_URL"
+	envToken    = "STASH_TOKEN"
+	envZKKey    = "STASH_ZK_KEY"
+)
+
+// resource is a managed resource type. Plan fills computed attributes and reports attributes forcing
+// replacement, read returns a null value for objects gone from the server.
+type resource interface {
+	schema() *tfprotov6.Schema
+	validate(config tftypes.Value) []*tfprotov6.Diagnostic
+	plan(prior, proposed tftypes.Value) (tftypes.Value, []*tftypes.AttributePath, error)
+	apply(ctx context.Context, c *stash.Client, prior, planned tftypes.Value) (tftypes.Value, error)
+	destroy(ctx context.Context, c *stash.Client, prior tftypes.Value) error
+	read(ctx context.Context, c *stash.Client, state tftypes.Value) (tftypes.Value, error)
+	importState(ctx context.Context, c *stash.Client, id string) (tftypes.Value, error)
+}
+
+// dataSource is a data source type.
+type dataSource interface {
+	schema() *tfprotov6.Schema
+	read(ctx context.Context, c *stash.Client, config tftypes.Value) (tftypes.Value, error)
+}
+
+var providerSchema = &tfprotov6.Schema{Block: &tfprotov6.SchemaBlock{
+	Attributes: []*tfprotov6.SchemaAttribute{
+		{Name: "endpoint", Type: tftypes.String, Optional: true,
+			Description: "Stash server URL, " + envEndpoint + " if not set."},
+		{Name: "token", Type: tftypes.String, Optional: true, Sensitive: true,
+			Description: "API token, " + envToken + " if not set."},
+		{Name: "zk_key", Type: tftypes.String, Optional: true, Sensitive: true,
+			Description: "Passphrase for client-side zero-knowledge encryption, " + envZKKey + " if not set."},
+	},
+}}
+
+// Provider is the terraform provider server.
+type Provider struct {
+	getenv      func(string) string
+	resources   map[string]resource
+	dataSources map[string]dataSource
+
+	mu     sync.RWMutex
+	client *stash.Client
+}
+
+// New creates the provider.
+func New() *Provider {
+	return &Provider{
+		getenv:      os.Getenv,
+		resources:   map[string]resource{"stash_key": keyResource{}, "stash_token": tokenResource{now: time.Now}},
+		dataSources: map[string]dataSource{"stash_key": keyData{}, "stash_keys": keysData{}},
+	}
+}
+
+// GetMetadata returns the resource and data source types.
+func (p *Provider) GetMetadata(context.Context, *tfprotov6.GetMetadataRequest) (*tfprotov6.GetMetadataResponse, error) {
+	resp := &tfprotov6.GetMetadataResponse{ServerCapabilities: &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true}}
+	for _, name := range sortedNames(p.resources) {
+		resp.Resources = append(resp.Resources, tfprotov6.ResourceMetadata{TypeName: name})
+	}
+	for _, name := range sortedNames(p.dataSources) {
+		resp.DataSources = append(resp.DataSources, tfprotov6.DataSourceMetadata{TypeName: name})
+	}
+	return resp, nil
+}
+
+// GetProviderSchema returns schemas of the provider, resources and data sources.
+func (p *Provider) GetProviderSchema(context.Context, *tfprotov6.GetProviderSchemaRequest) (*tfprotov6.GetProviderSchemaResponse, error) {
+	resp := &tfprotov6.GetProviderSchemaResponse{
+		ServerCapabilities: &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true},
+		Provider:           providerSchema,
+		ResourceSchemas:    make(map[string]*tfprotov6.Schema, len(p.resources)),
+		DataSourceSchemas:  make(map[string]*tfprotov6.Schema, len(p.dataSources)),
+		Functions:          map[string]*tfprotov6.Function{},
+	}
+	for name, r := range p.resources {
+		resp.ResourceSchemas[name] = r.schema()
+	}
+	for name, d := range p.dataSources {
+		resp.DataSourceSchemas[name] = d.schema()
+	}
+	return resp, nil
+}
+
+// GetResourceIdentitySchemas returns no identity schemas, resources are identified by id.
+func (p *Provider) GetResourceIdentitySchemas(context.Context, *tfprotov6.GetResourceIdentitySchemasRequest) (*tfprotov6.GetResourceIdentitySchemasResponse, error) {
+	return &tfprotov6.GetResourceIdentitySchemasResponse{IdentitySchemas: map[string]*tfprotov6.ResourceIdentitySchema{}}, nil
+}
+
+// ValidateProviderConfig accepts any provider config, the endpoint may come from the environment.
+func (p *Provider) ValidateProviderConfig(_ context.Context, req *tfprotov6.ValidateProviderConfigRequest) (*tfprotov6.ValidateProviderConfigResponse, error) {
+	return &tfprotov6.ValidateProviderConfigResponse{PreparedConfig: req.Config}, nil
+}
+
+// ConfigureProvider makes the stash client from the provider block and the environment.
+func (p *Provider) ConfigureProvider(_ context.Context, req *tfprotov6.ConfigureProviderRequest) (*tfprotov6.ConfigureProviderResponse, error) {
+	config, err := decode(req.Config, providerSchema)
+	if err != nil {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: errorDiag("Invalid provider configuration", err)}, nil
+	}
+	o := object{}
+	if !config.IsNull() {
+		if o, err = toObject(config); err != nil {
+			return &tfprotov6.ConfigureProviderResponse{Diagnostics: errorDiag("Invalid provider configuration", err)}, nil
+		}
+	}
+	setting := func(name, env string) string {
+		if o.known(name) {
+			return o.str(name)
+		}
+		return p.getenv(env)
+	}
+	endpoint := setting("endpoint", envEndpoint)
+	if endpoint == "" {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: []*tfprotov6.Diagnostic{attrDiag("endpoint",
+			"Missing endpoint", "set endpoint in the provider block or "+envEndpoint+" environment variable")}}, nil
+	}
+	var opts []stash.Option
+	if token := setting("token", envToken); token != "" {
+		opts = append(opts, stash.WithToken(token))
+	}
+	if zk := setting("zk_key", envZKKey); zk != "" {
+		opts = append(opts, stash.WithZKKey(zk))
+	}
+	client, err := stash.New(endpoint, opts...)
+	if err != nil {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: errorDiag("Can't create stash client", err)}, nil
+	}
+
+	p.mu.Lock()
+	if p.client != nil {
+		p.client.Close()
+	}
+	p.client = client
+	p.mu.Unlock()
+	return &tfprotov6.ConfigureProviderResponse{}, nil
+}
+
+// StopProvider closes the client, in-flight requests are canceled by terraform through their contexts.
+func (p *Provider) StopProvider(context.Context, *tfprotov6.StopProviderRequest) (*tfprotov6.StopProviderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		p.client.Close()
+	}
+	return &tfprotov6.StopProviderResponse{}, nil
+}
+
+// ValidateResourceConfig checks attribute values known at validation time.
+func (p *Provider) ValidateResourceConfig(_ context.Context, req *tfprotov6.ValidateResourceConfigRequest) (*tfprotov6.ValidateResourceConfigResponse, error) {
+	r, ok := p.resources[req.TypeName]
+	if !ok {
+		return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: unknownType(req.TypeName)}, nil
+	}
+	config, err := decode(req.Config, r.schema())
+	if err != nil {
+		return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: errorDiag("Invalid configuration", err)}, nil
+	}
+	return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: r.validate(config)}, nil
+}
+
+// UpgradeResourceState decodes the stored state, all resources are at schema version 0.
+func (p *Provider) UpgradeResourceState(_ context.Context, req *tfprotov6.UpgradeResourceStateRequest) (*tfprotov6.UpgradeResourceStateResponse, error) {
+	r, ok := p.resources[req.TypeName]
+	if !ok {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: unknownType(req.TypeName)}, nil
+	}
+	state, err := req.RawState.Unmarshal(r.schema().ValueType())
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: errorDiag("Can't read stored state", err)}, nil
+	}
+	dv, err := encode(state)
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: errorDiag("Can't upgrade state", err)}, nil
+	}
+	return &tfprotov6.UpgradeResourceStateResponse{UpgradedState: dv}, nil
+}
+
+// UpgradeResourceIdentity is not supported, resources have no identity schema.
+func (p *Provider) UpgradeResourceIdentity(_ context.Context, req *tfprotov6.UpgradeResourceIdentityRequest) (*tfprotov6.UpgradeResourceIdentityResponse, error) {
+	return &tfprotov6.UpgradeResourceIdentityResponse{Diagnostics: unsupported("resource identity", req.TypeName)}, nil
+}
+
+// ReadResource refreshes the state from the server.
+func (p *Provider) ReadResource(ctx context.Context, req *tfprotov6.ReadResourceRequest) (*tfprotov6.ReadResourceResponse, error) {
+	r, c, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diags}, nil
+	}
+	state, err := decode(req.CurrentState, r.schema())
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: errorDiag("Invalid state", err)}, nil
+	}
+	if !state.IsNull() {
+		if state, err = r.read(ctx, c, state); err != nil {
+			return &tfprotov6.ReadResourceResponse{Diagnostics: errorDiag("Can't read "+req.TypeName, err)}, nil
+		}
+	}
+	dv, err := encode(state)
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: errorDiag("Can't read "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ReadResourceResponse{NewState: dv, Private: req.Private}, nil
+}
+
+// PlanResourceChange plans create, update or replace. Destroy plans are returned as proposed.
+func (p *Provider) PlanResourceChange(_ context.Context, req *tfprotov6.PlanResourceChangeRequest) (*tfprotov6.PlanResourceChangeResponse, error) {
+	r, ok := p.resources[req.TypeName]
+	if !ok {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: unknownType(req.TypeName)}, nil
+	}
+	prior, err := decode(req.PriorState, r.schema())
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiag("Invalid prior state", err)}, nil
+	}
+	proposed, err := decode(req.ProposedNewState, r.schema())
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiag("Invalid proposed state", err)}, nil
+	}
+	planned, replace := proposed, []*tftypes.AttributePath(nil)
+	if !proposed.IsNull() {
+		if planned, replace, err = r.plan(prior, proposed); err != nil {
+			return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiag("Can't plan "+req.TypeName, err)}, nil
+		}
+	}
+	dv, err := encode(planned)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiag("Can't plan "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.PlanResourceChangeResponse{PlannedState: dv, RequiresReplace: replace, PlannedPrivate: req.PriorPrivate}, nil
+}
+
+// ApplyResourceChange applies the planned change, null planned state means destroy.
+func (p *Provider) ApplyResourceChange(ctx context.Context, req *tfprotov6.ApplyResourceChangeRequest) (*tfprotov6.ApplyResourceChangeResponse, error) {
+	r, c, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diags}, nil
+	}
+	prior, err := decode(req.PriorState, r.schema())
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiag("Invalid prior state", err)}, nil
+	}
+	planned, err := decode(req.PlannedState, r.schema())
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiag("Invalid planned state", err)}, nil
+	}
+	newState := planned
+	if planned.IsNull() {
+		if err = r.destroy(ctx, c, prior); err != nil {
+			return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiag("Can't delete "+req.TypeName, err)}, nil
+		}
+	} else if newState, err = r.apply(ctx, c, prior, planned); err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiag("Can't apply "+req.TypeName, err)}, nil
+	}
+	dv, err := encode(newState)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiag("Can't apply "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ApplyResourceChangeResponse{NewState: dv, Private: req.PlannedPrivate}, nil
+}
+
+// ImportResourceState imports an existing object by id.
+func (p *Provider) ImportResourceState(ctx context.Context, req *tfprotov6.ImportResourceStateRequest) (*tfprotov6.ImportResourceStateResponse, error) {
+	r, c, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: diags}, nil
+	}
+	state, err := r.importState(ctx, c, req.ID)
+	if err != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: errorDiag("Can't import "+req.TypeName, err)}, nil
+	}
+	dv, err := encode(state)
+	if err != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: errorDiag("Can't import "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ImportResourceStateResponse{ImportedResources: []*tfprotov6.ImportedResource{
+		{TypeName: req.TypeName, State: dv}}}, nil
+}
+
+// MoveResourceState is not supported, there are no resources to move state from.
+func (p *Provider) MoveResourceState(_ context.Context, req *tfprotov6.MoveResourceStateRequest) (*tfprotov6.MoveResourceStateResponse, error) {
+	return &tfprotov6.MoveResourceStateResponse{Diagnostics: unsupported("moving state", req.TargetTypeName)}, nil
+}
+
+// ValidateDataResourceConfig accepts any data source config known to the provider.
+func (p *Provider) ValidateDataResourceConfig(_ context.Context, req *tfprotov6.ValidateDataResourceConfigRequest) (*tfprotov6.ValidateDataResourceConfigResponse, error) {
+	if _, ok := p.dataSources[req.TypeName]; !ok {
+		return &tfprotov6.ValidateDataResourceConfigResponse{Diagnostics: unknownType(req.TypeName)}, nil
+	}
+	return &tfprotov6.ValidateDataResourceConfigResponse{}, nil
+}
+
+// ReadDataSource reads the data source.
+func (p *Provider) ReadDataSource(ctx context.Context, req *tfprotov6.ReadDataSourceRequest) (*tfprotov6.ReadDataSourceResponse, error) {
+	d, ok := p.dataSources[req.TypeName]
+	if !ok {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: unknownType(req.TypeName)}, nil
+	}
+	c, diags := p.configured()
+	if diags != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: diags}, nil
+	}
+	config, err := decode(req.Config, d.schema())
+	if err != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: errorDiag("Invalid configuration", err)}, nil
+	}
+	state, err := d.read(ctx, c, config)
+	if err != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: errorDiag("Can't read "+req.TypeName, err)}, nil
+	}
+	dv, err := encode(state)
+	if err != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: errorDiag("Can't read "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ReadDataSourceResponse{State: dv}, nil
+}
+
+// GetFunctions returns no functions.
+func (p *Provider) GetFunctions(context.Context, *tfprotov6.GetFunctionsRequest) (*tfprotov6.GetFunctionsResponse, error) {
+	return &tfprotov6.GetFunctionsResponse{Functions: map[string]*tfprotov6.Function{}}, nil
+}
+
+// CallFunction fails, the provider has no functions.
+func (p *Provider) CallFunction(_ context.Context, req *tfprotov6.CallFunctionRequest) (*tfprotov6.CallFunctionResponse, error) {
+	return &tfprotov6.CallFunctionResponse{Error: &tfprotov6.FunctionError{Text: fmt.Sprintf("unknown function %q", req.Name)}}, nil
+}
+
+// ValidateEphemeralResourceConfig fails, the provider has no ephemeral resources.
+func (p *Provider) ValidateEphemeralResourceConfig(_ context.Context, req *tfprotov6.ValidateEphemeralResourceConfigRequest) (*tfprotov6.ValidateEphemeralResourceConfigResponse, error) {
+	return &tfprotov6.ValidateEphemeralResourceConfigResponse{Diagnostics: unknownType(req.TypeName)}, nil
+}
+
+// OpenEphemeralResource fails, the provider has no ephemeral resources.
+func (p *Provider) OpenEphemeralResource(_ context.Context, req *tfprotov6.OpenEphemeralResourceRequest) (*tfprotov6.OpenEphemeralResourceResponse, error) {
+	return &tfprotov6.OpenEphemeralResourceResponse{Diagnostics: unknownType(req.TypeName)}, nil
+}
+
+// RenewEphemeralResource fails, the provider has no ephemeral resources.
+func (p *Provider) RenewEphemeralResource(_ context.Context, req *tfprotov6.RenewEphemeralResourceRequest) (*tfprotov6.RenewEphemeralResourceResponse, error) {
+	return &tfprotov6.RenewEphemeralResourceResponse{Diagnostics: unknownType(req.TypeName)}, nil
+}
+
+// CloseEphemeralResource fails, the provider has no ephemeral resources.
+func (p *Provider) CloseEphemeralResource(_ context.Context, req *tfprotov6.CloseEphemeralResourceRequest) (*tfprotov6.CloseEphemeralResourceResponse, error) {
+	return &tfprotov6.CloseEphemeralResourceResponse{Diagnostics: unknownType(req.TypeName)}, nil
+}
+
+// resource returns the resource type and the configured client.
+func (p *Provider) resource(typeName string) (resource, *stash.Client, []*tfprotov6.Diagnostic) {
+	r, ok := p.resources[typeName]
+	if !ok {
+		return nil, nil, unknownType(typeName)
+	}
+	c, diags := p.configured()
+	return r, c, diags
+}
+
+// configured returns the client made by ConfigureProvider.
+func (p *Provider) configured() (*stash.Client, []*tfprotov6.Diagnostic) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.client == nil {
+		return nil, errorDiag("Provider not configured", errors.New("stash client is not initialized"))
+	}
+	return p.client, nil
+}
+
+// unknownType reports a type not served by the provider.
+func unknownType(typeName string) []*tfprotov6.Diagnostic {
+	return errorDiag("Unknown type", fmt.Errorf("%q is not supported by the stash provider", typeName))
+}
+
+// unsupported reports an operation the provider doesn't implement.
+func unsupported(op, typeName string) []*tfprotov6.Diagnostic {
+	return errorDiag("Unsupported operation", fmt.Errorf("%s is not supported for %s", op, typeName))
+}
+
+// sortedNames returns map keys in order.
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// fakeKey is a key stored by fakeStash.
+type fakeKey struct {
+	value  string
+	format string
+}
+
+// fakeStash emulates the stash API used by the provider, keys in memory and token exchange.
+type fakeStash struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     map[string]fakeKey
+	auth     string          // authorization header of the last request
+	exchange json.RawMessage // body of the last token exchange
+}
+
+func newFakeStash(t *testing.T) *fakeStash {
+	t.Helper()
+	f := &fakeStash{keys: map[string]fakeKey{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeStash) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+	if r.URL.Path == "/auth/token" {
+		f.exchange, _ = io.ReadAll(r.Body)
+		_ = json.NewEncoder(w).Encode(stash.Token{Token: "child-token", ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)})
+		return
+	}
+	if r.URL.Path == "/kv/" {
+		infos := []stash.KeyInfo{}
+		for k, v := range f.keys {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				infos = append(infos, stash.KeyInfo{Key: k, Format: v.format, Size: len(v.value), Secret: strings.HasPrefix(k, "secrets/"),
+					UpdatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)})
+			}
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+		_ = json.NewEncoder(w).Encode(infos)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/kv/")
+	switch r.Method {
+	case http.MethodGet:
+		v, ok := f.keys[key]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(v.value))
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.keys[key] = fakeKey{value: string(body), format: r.Header.Get("X-Stash-Format")}
+	case http.MethodDelete:
+		if _, ok := f.keys[key]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		delete(f.keys, key)
+	}
+}
+
+func (f *fakeStash) get(key string) (fakeKey, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.keys[key]
+	return v, ok
+}
+
+func (f *fakeStash) set(key, value, format string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[key] = fakeKey{value: value, format: format}
+}
+
+// newTestProvider returns a provider configured for a fake stash server.
+func newTestProvider(t *testing.T) (*Provider, *fakeStash) {
+	t.Helper()
+	f := newFakeStash(t)
+	p := New()
+	p.getenv = func(string) string { return "" }
+	resp, err := p.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{
+		Config: dynamic(t, providerSchema, object{"endpoint": stringValue(f.URL), "token": stringValue("parent-token"),
+			"zk_key": tftypes.NewValue(tftypes.String, nil)})})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+	return p, f
+}
+
+// dynamic encodes attributes as a value of the schema type, missing attributes are null.
+func dynamic(t *testing.T, s *tfprotov6.Schema, o object) *tfprotov6.DynamicValue {
+	t.Helper()
+	full := object{}
+	for _, a := range s.Block.Attributes {
+		full[a.Name] = tftypes.NewValue(a.Type, nil)
+		if v, ok := o[a.Name]; ok {
+			full[a.Name] = v
+		}
+	}
+	dv, err := encode(full.value(s))
+	require.NoError(t, err)
+	return dv
+}
+
+// nullDynamic encodes a null value of the schema type.
+func nullDynamic(t *testing.T, s *tfprotov6.Schema) *tfprotov6.DynamicValue {
+	t.Helper()
+	dv, err := encode(tftypes.NewValue(s.ValueType(), nil))
+	require.NoError(t, err)
+	return dv
+}
+
+// attributes decodes a response value, nil for a null value.
+func attributes(t *testing.T, dv *tfprotov6.DynamicValue, s *tfprotov6.Schema) object {
+	t.Helper()
+	require.NotNil(t, dv)
+	v, err := decode(dv, s)
+	require.NoError(t, err)
+	if v.IsNull() {
+		return nil
+	}
+	o, err := toObject(v)
+	require.NoError(t, err)
+	return o
+}
+
+func TestProvider_Schema(t *testing.T) {
+	p := New()
+	meta, err := p.GetMetadata(t.Context(), &tfprotov6.GetMetadataRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []tfprotov6.ResourceMetadata{{TypeName: "stash_key"}, {TypeName: "stash_token"}}, meta.Resources)
+	assert.Equal(t, []tfprotov6.DataSourceMetadata{{TypeName: "stash_key"}, {TypeName: "stash_keys"}}, meta.DataSources)
+
+	resp, err := p.GetProviderSchema(t.Context(), &tfprotov6.GetProviderSchemaRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, providerSchema, resp.Provider)
+	assert.Len(t, resp.ResourceSchemas, 2)
+	assert.Len(t, resp.DataSourceSchemas, 2)
+}
+
+func TestProvider_Configure(t *testing.T) {
+	f := newFakeStash(t)
+	p := New()
+	env := map[string]string{"STASH_URL": f.URL, "STASH_TOKEN": "env-token"}
+	p.getenv = func(name string) string { return env[name] }
+
+	resp, err := p.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{Config: dynamic(t, providerSchema, object{})})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics, "endpoint and token from the environment")
+	f.set("app/a", "v", "text")
+	_, err = p.ReadDataSource(t.Context(), &tfprotov6.ReadDataSourceRequest{TypeName: "stash_key",
+		Config: dynamic(t, keyDataSchema, object{"key": stringValue("app/a")})})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer env-token", f.auth)
+
+	delete(env, "STASH_URL")
+	resp, err = p.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{Config: dynamic(t, providerSchema, object{})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "Missing endpoint", resp.Diagnostics[0].Summary)
+
+	resp, err = p.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{Config: dynamic(t, providerSchema,
+		object{"endpoint": stringValue(f.URL), "zk_key": stringValue("short")})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "Can't create stash client", resp.Diagnostics[0].Summary)
+}
+
+func TestProvider_NotConfigured(t *testing.T) {
+	p := New()
+	resp, err := p.ReadResource(t.Context(), &tfprotov6.ReadResourceRequest{TypeName: "stash_key",
+		CurrentState: dynamic(t, keySchema, object{"key": stringValue("a")})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "Provider not configured", resp.Diagnostics[0].Summary)
+
+	plan, err := p.PlanResourceChange(t.Context(), &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_secret"})
+	require.NoError(t, err)
+	require.Len(t, plan.Diagnostics, 1)
+	assert.Equal(t, `"stash_secret" is not supported by the stash provider`, plan.Diagnostics[0].Detail)
+}
+
+func TestProvider_UpgradeResourceState(t *testing.T) {
+	p := New()
+	resp, err := p.UpgradeResourceState(t.Context(), &tfprotov6.UpgradeResourceStateRequest{TypeName: "stash_key",
+		RawState: &tfprotov6.RawState{JSON: []byte(`{"id":"a","key":"a","value":"v","format":"json"}`)}})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+	o := attributes(t, resp.UpgradedState, keySchema)
+	assert.Equal(t, "json", o.str("format"))
+}
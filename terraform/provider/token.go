@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/umputun/stash/lib/stash"
+)
+
+// tokenResource mints a short-lived child token of the provider token, stash_token. The server has no
+// token management API, tokens come from the token exchange and can't be revoked, so destroy only
+// forgets the token. Once the token is about to expire, refresh drops it from the state and the next
+// apply mints a new one.
+type tokenResource struct {
+	now func() time.Time
+}
+
+var tokenSchema = &tfprotov6.Schema{Block: &tfprotov6.SchemaBlock{
+	Description: "Short-lived token exchanged for the provider token, with the same or narrower access.",
+	Attributes: []*tfprotov6.SchemaAttribute{
+		{Name: "id", Type: tftypes.String, Computed: true, Description: "Token fingerprint."},
+		{Name: "permissions", Type: tftypes.Map{ElementType: tftypes.String}, Optional: true,
+			Description: "Key prefix to access (r, w or rw), the provider token permissions if not set."},
+		{Name: "scopes", Type: tftypes.List{ElementType: tftypes.String}, Optional: true,
+			Description: "Scopes of the token, the provider token scopes if not set."},
+		{Name: "ttl", Type: tftypes.String, Optional: true,
+			Description: "Token lifetime as a go duration, e.g. 24h, the server default if not set."},
+		{Name: "renew_before", Type: tftypes.String, Optional: true,
+			Description: "Duration before expiration when the token is replaced on the next apply."},
+		{Name: "token", Type: tftypes.String, Computed: true, Sensitive: true, Description: "The token."},
+		{Name: "expires_at", Type: tftypes.String, Computed: true, Description: "Expiration time, RFC3339."},
+	},
+}}
+
+// tokenComputed lists attributes set by the server.
+var tokenComputed = []string{"id", "token", "expires_at"}
+
+func (tokenResource) schema() *tfprotov6.Schema { return tokenSchema }
+
+func (tokenResource) validate(config tftypes.Value) []*tfprotov6.Diagnostic {
+	o, err := toObject(config)
+	if err != nil {
+		return errorDiag("Invalid configuration", err)
+	}
+	var diags []*tfprotov6.Diagnostic
+	for _, name := range []string{"ttl", "renew_before"} {
+		if !o.known(name) {
+			continue
+		}
+		if d, err := time.ParseDuration(o.str(name)); err != nil || d <= 0 {
+			diags = append(diags, attrDiag(name, "Invalid duration", fmt.Sprintf("%q is not a positive go duration", o.str(name))))
+		}
+	}
+	for prefix, access := range o.stringMap("permissions") {
+		if access != "r" && access != "w" && access != "rw" {
+			diags = append(diags, attrDiag("permissions", "Invalid access",
+				fmt.Sprintf("access to %q must be r, w or rw, got %q", prefix, access)))
+		}
+	}
+	return diags
+}
+
+// plan replaces the token when its permissions, scopes or ttl change, the server can't change a minted token.
+func (tokenResource) plan(prior, proposed tftypes.Value) (tftypes.Value, []*tftypes.AttributePath, error) {
+	o, err := toObject(proposed)
+	if err != nil {
+		return tftypes.Value{}, nil, err
+	}
+	var replace []*tftypes.AttributePath
+	if !prior.IsNull() {
+		p, err := toObject(prior)
+		if err != nil {
+			return tftypes.Value{}, nil, err
+		}
+		for _, name := range []string{"permissions", "scopes", "ttl"} {
+			if changed(p, o, name) {
+				replace = append(replace, attrPath(name))
+			}
+		}
+		if len(replace) == 0 {
+			return o.value(tokenSchema), nil, nil
+		}
+	}
+	for _, name := range tokenComputed {
+		o[name] = unknownString()
+	}
+	return o.value(tokenSchema), replace, nil
+}
+
+// apply mints the token on create, updates only touch renew_before and keep the token.
+func (tokenResource) apply(ctx context.Context, c *stash.Client, prior, planned tftypes.Value) (tftypes.Value, error) {
+	if !prior.IsNull() {
+		return planned, nil
+	}
+	o, err := toObject(planned)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	req := stash.TokenRequest{Scopes: o.strings("scopes")}
+	for prefix, access := range o.stringMap("permissions") {
+		req.Permissions = append(req.Permissions, stash.Permission{Prefix: prefix, Access: access})
+	}
+	sort.Slice(req.Permissions, func(i, j int) bool { return req.Permissions[i].Prefix < req.Permissions[j].Prefix })
+	if o.known("ttl") {
+		if req.TTL, err = time.ParseDuration(o.str("ttl")); err != nil {
+			return tftypes.Value{}, fmt.Errorf("ttl: %w", err)
+		}
+	}
+	token, err := c.ExchangeToken(ctx, req)
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("exchange token: %w", err)
+	}
+	sum := sha256.Sum256([]byte(token.Token))
+	o["id"] = stringValue(hex.EncodeToString(sum[:8]))
+	o["token"] = stringValue(token.Token)
+	o["expires_at"] = stringValue(token.ExpiresAt.UTC().Format(time.RFC3339))
+	return o.value(tokenSchema), nil
+}
+
+func (tokenResource) destroy(context.Context, *stash.Client, tftypes.Value) error { return nil }
+
+// read drops expired tokens and the ones within renew_before of expiration from the state.
+func (r tokenResource) read(_ context.Context, _ *stash.Client, state tftypes.Value) (tftypes.Value, error) {
+	o, err := toObject(state)
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	expires, err := time.Parse(time.RFC3339, o.str("expires_at"))
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("expires_at: %w", err)
+	}
+	var renewBefore time.Duration
+	if o.known("renew_before") {
+		if renewBefore, err = time.ParseDuration(o.str("renew_before")); err != nil {
+			return tftypes.Value{}, fmt.Errorf("renew_before: %w", err)
+		}
+	}
+	if !r.now().Before(expires.Add(-renewBefore)) {
+		return tftypes.NewValue(tokenSchema.ValueType(), nil), nil
+	}
+	return state, nil
+}
+
+func (tokenResource) importState(context.Context, *stash.Client, string) (tftypes.Value, error) {
+	return tftypes.Value{}, errors.New("tokens can't be imported, the server doesn't keep them")
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenResource_Lifecycle(t *testing.T) {
+	p, f := newTestProvider(t)
+	now := time.Date(2029, 12, 1, 0, 0, 0, 0, time.UTC)
+	p.resources["stash_token"] = tokenResource{now: func() time.Time { return now }}
+	ctx := t.Context()
+
+	perms := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+		"app/*": stringValue("r"), "app/cache/*": stringValue("rw")})
+	config := dynamic(t, tokenSchema, object{"permissions": perms, "ttl": stringValue("24h"), "renew_before": stringValue("48h")})
+	plan, err := p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_token",
+		PriorState: nullDynamic(t, tokenSchema), ProposedNewState: config, Config: config})
+	require.NoError(t, err)
+	require.Empty(t, plan.Diagnostics)
+	planned := attributes(t, plan.PlannedState, tokenSchema)
+	assert.False(t, planned["token"].IsKnown(), "token is known after apply")
+
+	applied, err := p.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{TypeName: "stash_token",
+		PriorState: nullDynamic(t, tokenSchema), PlannedState: plan.PlannedState, Config: config})
+	require.NoError(t, err)
+	require.Empty(t, applied.Diagnostics)
+	state := attributes(t, applied.NewState, tokenSchema)
+	assert.Equal(t, "child-token", state.str("token"))
+	assert.Equal(t, "2030-01-02T03:04:05Z", state.str("expires_at"))
+	assert.Len(t, state.str("id"), 16)
+	assert.Equal(t, "Bearer parent-token", f.auth)
+	assert.JSONEq(t, `{"permissions":[{"prefix":"app/*","access":"r"},{"prefix":"app/cache/*","access":"rw"}],"ttl":"24h0m0s"}`,
+		string(f.exchange))
+
+	// renew_before change keeps the token, ttl change replaces it
+	changed := dynamic(t, tokenSchema, object{"id": state["id"], "permissions": perms, "ttl": stringValue("24h"),
+		"renew_before": stringValue("1h"), "token": state["token"], "expires_at": state["expires_at"]})
+	plan, err = p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_token",
+		PriorState: applied.NewState, ProposedNewState: changed, Config: changed})
+	require.NoError(t, err)
+	assert.Empty(t, plan.RequiresReplace)
+	assert.Equal(t, "child-token", attributes(t, plan.PlannedState, tokenSchema).str("token"))
+
+	changed = dynamic(t, tokenSchema, object{"id": state["id"], "permissions": perms, "ttl": stringValue("1h"),
+		"renew_before": stringValue("48h"), "token": state["token"], "expires_at": state["expires_at"]})
+	plan, err = p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{TypeName: "stash_token",
+		PriorState: applied.NewState, ProposedNewState: changed, Config: changed})
+	require.NoError(t, err)
+	require.Len(t, plan.RequiresReplace, 1)
+	assert.True(t, plan.RequiresReplace[0].Equal(tftypes.NewAttributePath().WithAttributeName("ttl")))
+	assert.False(t, attributes(t, plan.PlannedState, tokenSchema)["token"].IsKnown())
+
+	// token stays in the state until renew_before of expiration
+	read, err := p.ReadResource(ctx, &tfprotov6.ReadResourceRequest{TypeName: "stash_token", CurrentState: applied.NewState})
+	require.NoError(t, err)
+	require.Empty(t, read.Diagnostics)
+	assert.Equal(t, "child-token", attributes(t, read.NewState, tokenSchema).str("token"))
+
+	now = time.Date(2029, 12, 31, 4, 0, 0, 0, time.UTC)
+	read, err = p.ReadResource(ctx, &tfprotov6.ReadResourceRequest{TypeName: "stash_token", CurrentState: applied.NewState})
+	require.NoError(t, err)
+	require.Empty(t, read.Diagnostics)
+	assert.Nil(t, attributes(t, read.NewState, tokenSchema), "due for renewal, dropped from state")
+}
+
+func TestTokenResource_Validate(t *testing.T) {
+	p := New()
+	perms := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{"app/*": stringValue("x")})
+	resp, err := p.ValidateResourceConfig(t.Context(), &tfprotov6.ValidateResourceConfigRequest{TypeName: "stash_token",
+		Config: dynamic(t, tokenSchema, object{"permissions": perms, "ttl": stringValue("1d"), "renew_before": stringValue("-1h")})})
+	require.NoError(t, err)
+	require.Len(t, resp.Diagnostics, 3)
+	assert.Equal(t, `"1d" is not a positive go duration`, resp.Diagnostics[0].Detail)
+	assert.Equal(t, `"-1h" is not a positive go duration`, resp.Diagnostics[1].Detail)
+	assert.Equal(t, `access to "app/*" must be r, w or rw, got "x"`, resp.Diagnostics[2].Detail)
+
+	resp, err = p.ValidateResourceConfig(t.Context(), &tfprotov6.ValidateResourceConfigRequest{TypeName: "stash_token",
+		Config: dynamic(t, tokenSchema, object{"ttl": stringValue("15m")})})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Diagnostics)
+}
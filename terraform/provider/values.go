@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// object is a decoded terraform object, attribute name to value.
+type object map[string]tftypes.Value
+
+// decode unmarshals a dynamic value of the schema type, null object for a nil value.
+func decode(dv *tfprotov6.DynamicValue, s *tfprotov6.Schema) (tftypes.Value, error) {
+	typ := s.ValueType()
+	if dv == nil {
+		return tftypes.NewValue(typ, nil), nil
+	}
+	v, err := dv.Unmarshal(typ)
+	if err != nil {
+		return tftypes.Value{}, fmt.Errorf("decode value: %w", err)
+	}
+	return v, nil
+}
+
+// encode marshals the value for a response.
+func encode(v tftypes.Value) (*tfprotov6.DynamicValue, error) {
+	dv, err := tfprotov6.NewDynamicValue(v.Type(), v)
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+	return &dv, nil
+}
+
+// toObject splits a known, non-null object value into attributes.
+func toObject(v tftypes.Value) (object, error) {
+	m := map[string]tftypes.Value{}
+	if err := v.As(&m); err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+	return m, nil
+}
+
+// value assembles the object back into a value of the schema type.
+func (o object) value(s *tfprotov6.Schema) tftypes.Value {
+	return tftypes.NewValue(s.ValueType(), map[string]tftypes.Value(o))
+}
+
+// str returns a string attribute, empty for null and unknown values.
+func (o object) str(name string) string {
+	var s string
+	if v, ok := o[name]; ok && v.IsKnown() {
+		_ = v.As(&s)
+	}
+	return s
+}
+
+// strings returns a list attribute of strings, nil for null and unknown values.
+func (o object) strings(name string) []string {
+	v, ok := o[name]
+	if !ok || !v.IsFullyKnown() || v.IsNull() {
+		return nil
+	}
+	var elems []tftypes.Value
+	_ = v.As(&elems)
+	res := make([]string, 0, len(elems))
+	for _, e := range elems {
+		var s string
+		_ = e.As(&s)
+		res = append(res, s)
+	}
+	return res
+}
+
+// stringMap returns a map attribute of strings, nil for null and unknown values.
+func (o object) stringMap(name string) map[string]string {
+	v, ok := o[name]
+	if !ok || !v.IsFullyKnown() || v.IsNull() {
+		return nil
+	}
+	elems := map[string]tftypes.Value{}
+	_ = v.As(&elems)
+	res := make(map[string]string, len(elems))
+	for k, e := range elems {
+		var s string
+		_ = e.As(&s)
+		res[k] = s
+	}
+	return res
+}
+
+// known reports whether the attribute is set to a known value.
+func (o object) known(name string) bool {
+	v, ok := o[name]
+	return ok && v.IsKnown() && !v.IsNull()
+}
+
+// changed reports whether the attribute differs between two objects.
+func changed(prior, planned object, name string) bool {
+	return !prior[name].Equal(planned[name])
+}
+
+// stringValue makes a string value.
+func stringValue(s string) tftypes.Value {
+	return tftypes.NewValue(tftypes.String, s)
+}
+
+// unknownString makes a string value known only after apply.
+func unknownString() tftypes.Value {
+	return tftypes.NewValue(tftypes.String, tftypes.UnknownValue)
+}
+
+// attrPath returns the path of a top-level attribute.
+func attrPath(name string) *tftypes.AttributePath {
+	return tftypes.NewAttributePath().WithAttributeName(name)
+}
+
+// errorDiag reports a failed operation.
+func errorDiag(summary string, err error) []*tfprotov6.Diagnostic {
+	return []*tfprotov6.Diagnostic{{Severity: tfprotov6.DiagnosticSeverityError, Summary: summary, Detail: err.Error()}}
+}
+
+// attrDiag reports an invalid attribute value.
+func attrDiag(name, summary, detail string) *tfprotov6.Diagnostic {
+	return &tfprotov6.Diagnostic{Severity: tfprotov6.DiagnosticSeverityError, Summary: summary, Detail: detail,
+		Attribute: attrPath(name)}
+}